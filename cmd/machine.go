@@ -13,18 +13,29 @@ import (
 	"github.com/docker/machine/drivers/amazonec2"
 	"github.com/docker/machine/drivers/azure"
 	"github.com/docker/machine/drivers/digitalocean"
+	"github.com/docker/machine/drivers/equinixmetal"
 	"github.com/docker/machine/drivers/exoscale"
+	"github.com/docker/machine/drivers/firecracker"
 	"github.com/docker/machine/drivers/generic"
 	"github.com/docker/machine/drivers/google"
+	"github.com/docker/machine/drivers/hetzner"
 	"github.com/docker/machine/drivers/hyperv"
+	"github.com/docker/machine/drivers/kvm"
+	"github.com/docker/machine/drivers/linode"
+	"github.com/docker/machine/drivers/lxd"
 	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/drivers/oci"
 	"github.com/docker/machine/drivers/openstack"
+	"github.com/docker/machine/drivers/proxmox"
 	"github.com/docker/machine/drivers/rackspace"
+	"github.com/docker/machine/drivers/scaleway"
 	"github.com/docker/machine/drivers/softlayer"
 	"github.com/docker/machine/drivers/virtualbox"
 	"github.com/docker/machine/drivers/vmwarefusion"
 	"github.com/docker/machine/drivers/vmwarevcloudair"
 	"github.com/docker/machine/drivers/vmwarevsphere"
+	"github.com/docker/machine/drivers/vultr"
+	"github.com/docker/machine/drivers/vzmac"
 	"github.com/docker/machine/libmachine/drivers/plugin"
 	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/docker/machine/libmachine/log"
@@ -117,6 +128,18 @@ func main() {
 			Value:  mcndirs.GetBaseDir(),
 			Usage:  "Configures storage path",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_STORAGE_URI",
+			Name:   "storage-uri",
+			Usage:  "Store the machines in a shared backend, e.g. s3://bucket/prefix, etcd://host:2379/prefix or consul://host:8500/prefix",
+			Value:  "",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_PROFILE",
+			Name:   "profile",
+			Usage:  "Profile whose storage and driver defaults are used, see 'profile list'",
+			Value:  "",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_TLS_CA_CERT",
 			Name:   "tls-ca-cert",
@@ -141,6 +164,66 @@ func main() {
 			Usage:  "Private key used in client TLS auth",
 			Value:  "",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_TLS_CA_BACKEND",
+			Name:   "tls-ca-backend",
+			Usage:  "Certificate authority signing the certificates: [local, vault]",
+			Value:  "local",
+		},
+		cli.StringFlag{
+			EnvVar: "VAULT_ADDR",
+			Name:   "vault-addr",
+			Usage:  "Address of the Vault server signing the certificates",
+			Value:  "",
+		},
+		cli.StringFlag{
+			EnvVar: "VAULT_TOKEN",
+			Name:   "vault-token",
+			Usage:  "Token to authenticate to Vault",
+			Value:  "",
+		},
+		cli.StringFlag{
+			EnvVar: "VAULT_CACERT",
+			Name:   "vault-ca-cert",
+			Usage:  "CA to verify the Vault server against",
+			Value:  "",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_VAULT_PKI_PATH",
+			Name:   "vault-pki-path",
+			Usage:  "Path where the PKI secrets engine of Vault is mounted",
+			Value:  "pki",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_VAULT_ROLE",
+			Name:   "vault-role",
+			Usage:  "Role of the PKI secrets engine of Vault signing the certificates",
+			Value:  "",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_SSH_CA_BACKEND",
+			Name:   "ssh-ca-backend",
+			Usage:  "Certificate authority signing the SSH keys of the machines: [none, local, vault]",
+			Value:  "none",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_SSH_CERT_TTL",
+			Name:   "ssh-cert-ttl",
+			Usage:  "Validity of the SSH certificates, renewed when they expire",
+			Value:  "1h",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_VAULT_SSH_PATH",
+			Name:   "vault-ssh-path",
+			Usage:  "Path where the SSH secrets engine of Vault is mounted",
+			Value:  "ssh",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_VAULT_SSH_ROLE",
+			Name:   "vault-ssh-role",
+			Usage:  "Role of the SSH secrets engine of Vault signing the SSH keys",
+			Value:  "",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_GITHUB_API_TOKEN",
 			Name:   "github-api-token",
@@ -152,6 +235,29 @@ func main() {
 			Name:   "native-ssh",
 			Usage:  "Use the native (Go-based) SSH implementation.",
 		},
+		cli.BoolFlag{
+			EnvVar: "MACHINE_NO_SSH_MULTIPLEXING",
+			Name:   "no-ssh-multiplexing",
+			Usage:  "Open a new SSH connection for every command instead of reusing one per machine.",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_RETRY_BUDGET",
+			Name:   "retry-budget",
+			Usage:  "Time budgets of the retries of transient errors, e.g. instance=10m,ssh=5m,command=1m",
+			Value:  "",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_OUTPUT",
+			Name:   "output",
+			Usage:  "Output format: [text, json]",
+			Value:  "text",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_LOG_FORMAT",
+			Name:   "log-format",
+			Usage:  "Log format: [text, json]",
+			Value:  "text",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_BUGSNAG_API_TOKEN",
 			Name:   "bugsnag-api-token",
@@ -173,20 +279,38 @@ func runDriver(driverName string) {
 		plugin.RegisterDriver(azure.NewDriver("", ""))
 	case "digitalocean":
 		plugin.RegisterDriver(digitalocean.NewDriver("", ""))
+	case "equinixmetal":
+		plugin.RegisterDriver(equinixmetal.NewDriver("", ""))
 	case "exoscale":
 		plugin.RegisterDriver(exoscale.NewDriver("", ""))
+	case "firecracker":
+		plugin.RegisterDriver(firecracker.NewDriver("", ""))
 	case "generic":
 		plugin.RegisterDriver(generic.NewDriver("", ""))
 	case "google":
 		plugin.RegisterDriver(google.NewDriver("", ""))
+	case "hetzner":
+		plugin.RegisterDriver(hetzner.NewDriver("", ""))
 	case "hyperv":
 		plugin.RegisterDriver(hyperv.NewDriver("", ""))
+	case "kvm":
+		plugin.RegisterDriver(kvm.NewDriver("", ""))
+	case "linode":
+		plugin.RegisterDriver(linode.NewDriver("", ""))
+	case "lxd":
+		plugin.RegisterDriver(lxd.NewDriver("", ""))
 	case "none":
 		plugin.RegisterDriver(none.NewDriver("", ""))
+	case "oci":
+		plugin.RegisterDriver(oci.NewDriver("", ""))
 	case "openstack":
 		plugin.RegisterDriver(openstack.NewDriver("", ""))
+	case "proxmox":
+		plugin.RegisterDriver(proxmox.NewDriver("", ""))
 	case "rackspace":
 		plugin.RegisterDriver(rackspace.NewDriver("", ""))
+	case "scaleway":
+		plugin.RegisterDriver(scaleway.NewDriver("", ""))
 	case "softlayer":
 		plugin.RegisterDriver(softlayer.NewDriver("", ""))
 	case "virtualbox":
@@ -197,6 +321,10 @@ func runDriver(driverName string) {
 		plugin.RegisterDriver(vmwarevcloudair.NewDriver("", ""))
 	case "vmwarevsphere":
 		plugin.RegisterDriver(vmwarevsphere.NewDriver("", ""))
+	case "vultr":
+		plugin.RegisterDriver(vultr.NewDriver("", ""))
+	case "vzmac":
+		plugin.RegisterDriver(vzmac.NewDriver("", ""))
 	default:
 		fmt.Fprintf(os.Stderr, "Unsupported driver: %s\n", driverName)
 		os.Exit(1)
@@ -31,7 +31,7 @@ func cmdActive(c CommandLine, api libmachine.API) error {
 	}
 
 	timeout := time.Duration(c.Int("timeout")) * time.Second
-	items := getHostListItems(hosts, hostsInError, timeout)
+	items := getHostListItems(hosts, hostsInError, timeout, lsDefaultParallel)
 
 	active, err := activeHost(items)
 
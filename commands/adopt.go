@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+const adoptDriverName = "generic"
+
+var (
+	errNoAdoptAddress = errors.New("Error: Expected the address of the server with --address")
+)
+
+// cmdAdopt provisions an existing server and stores it as a machine.  The
+// server is reached over SSH with the generic driver, so the OS detection and
+// the provisioning are the ones of create.
+func cmdAdopt(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrTooManyArguments
+	}
+
+	name := c.Args().First()
+	if name == "" {
+		return errNoMachineName
+	}
+
+	if !host.ValidateHostName(name) {
+		return fmt.Errorf("Error adopting machine: %s", mcnerror.ErrInvalidHostname)
+	}
+
+	if c.String("address") == "" {
+		return errNoAdoptAddress
+	}
+
+	exists, err := api.Exists(name)
+	if err != nil {
+		return fmt.Errorf("Error checking if host exists: %s", err)
+	}
+	if exists {
+		return mcnerror.ErrHostAlreadyExists{
+			Name: name,
+		}
+	}
+
+	rawDriver, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: name,
+		StorePath:   mcndirs.GetBaseDir(),
+	})
+	if err != nil {
+		return fmt.Errorf("Error attempting to marshal bare driver data: %s", err)
+	}
+
+	h, err := api.NewHost(adoptDriverName, rawDriver)
+	if err != nil {
+		return fmt.Errorf("Error getting new host: %s", err)
+	}
+
+	h.HostOptions = adoptHostOptions(c, name)
+
+	driverOpts := adoptDriverOpts(c, h.Driver.GetCreateFlags())
+
+	if c.String("ssh-proxy-jump") != "" {
+		if err := setSSHProxyJump(h, driverOpts, c.String("ssh-proxy-jump")); err != nil {
+			return err
+		}
+	}
+
+	if err := h.Driver.SetConfigFromFlags(driverOpts); err != nil {
+		return fmt.Errorf("Error setting machine configuration from flags provided: %s", err)
+	}
+
+	log.Infof("Adopting %s at %s...", name, c.String("address"))
+	if err := api.Create(h); err != nil {
+		return fmt.Errorf("Error adopting %s: %s", name, err)
+	}
+
+	if err := api.Save(h); err != nil {
+		return fmt.Errorf("Error attempting to save store: %s", err)
+	}
+
+	if isJSONOutput(c) {
+		return printCreatedJSON(h)
+	}
+
+	log.Infof("To see how to connect your Docker Client to the Docker Engine running on this machine, run: %s env %s", os.Args[0], name)
+
+	return nil
+}
+
+// adoptHostOptions returns the options of an adopted machine, with the
+// certificates of create and the engine installed from the default URL.
+func adoptHostOptions(c CommandLine, name string) *host.Options {
+	return &host.Options{
+		AuthOptions: &auth.Options{
+			CertDir:          mcndirs.GetMachineCertDir(),
+			CaCertPath:       tlsPath(c, "tls-ca-cert", "ca.pem"),
+			CaPrivateKeyPath: tlsPath(c, "tls-ca-key", "ca-key.pem"),
+			ClientCertPath:   tlsPath(c, "tls-client-cert", "cert.pem"),
+			ClientKeyPath:    tlsPath(c, "tls-client-key", "key.pem"),
+			ServerCertPath:   filepath.Join(mcndirs.GetMachineDir(), name, "server.pem"),
+			ServerKeyPath:    filepath.Join(mcndirs.GetMachineDir(), name, "server-key.pem"),
+			StorePath:        filepath.Join(mcndirs.GetMachineDir(), name),
+		},
+		EngineOptions: &engine.Options{
+			Labels:     c.StringSlice("engine-label"),
+			TLSVerify:  true,
+			InstallURL: drivers.DefaultEngineInstallURL,
+		},
+		SwarmOptions: &swarm.Options{},
+	}
+}
+
+// adoptDriverOpts returns the options of the generic driver for the server
+// given to adopt.
+func adoptDriverOpts(c CommandLine, mcnflags []mcnflag.Flag) drivers.DriverOptions {
+	driverOpts := defaultDriverOpts(mcnflags)
+
+	driverOpts.Values["generic-ip-address"] = c.String("address")
+	driverOpts.Values["generic-ssh-user"] = c.String("ssh-user")
+	driverOpts.Values["generic-ssh-key"] = c.String("ssh-key")
+	driverOpts.Values["generic-ssh-port"] = c.Int("ssh-port")
+	driverOpts.Values["generic-engine-port"] = c.Int("engine-port")
+
+	return driverOpts
+}
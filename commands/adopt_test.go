@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/generic"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdAdoptRequiresName(t *testing.T) {
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdAdopt(&commandstest.FakeCommandLine{}, api)
+	assert.Equal(t, errNoMachineName, err)
+
+	err = cmdAdopt(&commandstest.FakeCommandLine{CliArgs: []string{"dev", "dev2"}}, api)
+	assert.Equal(t, ErrTooManyArguments, err)
+}
+
+func TestCmdAdoptRequiresAddress(t *testing.T) {
+	err := cmdAdopt(&commandstest.FakeCommandLine{
+		CliArgs:    []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}, &libmachinetest.FakeAPI{})
+
+	assert.Equal(t, errNoAdoptAddress, err)
+}
+
+func TestCmdAdoptExistingMachine(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{{Name: "dev"}},
+	}
+
+	err := cmdAdopt(&commandstest.FakeCommandLine{
+		CliArgs: []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"address": "1.2.3.4",
+		}},
+	}, api)
+
+	assert.EqualError(t, err, `Host already exists: "dev"`)
+}
+
+func TestAdoptDriverOpts(t *testing.T) {
+	driver := generic.NewDriver("dev", "/store").(*generic.Driver)
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"address":     "1.2.3.4",
+			"ssh-user":    "ubuntu",
+			"ssh-key":     "/keys/id_rsa",
+			"ssh-port":    2222,
+			"engine-port": 2376,
+		}},
+	}
+
+	driverOpts := adoptDriverOpts(commandLine, driver.GetCreateFlags())
+	assert.NoError(t, driver.SetConfigFromFlags(driverOpts))
+
+	assert.Equal(t, "1.2.3.4", driver.IPAddress)
+	assert.Equal(t, "ubuntu", driver.SSHUser)
+	assert.Equal(t, 2222, driver.SSHPort)
+	assert.Equal(t, 2376, driver.EnginePort)
+	assert.Equal(t, "", driver.SSHProxyJump)
+}
+
+func TestAdoptHostOptions(t *testing.T) {
+	options := adoptHostOptions(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"engine-label": []string{"env=prod"},
+		}},
+	}, "dev")
+
+	assert.True(t, options.EngineOptions.TLSVerify)
+	assert.Equal(t, []string{"env=prod"}, options.EngineOptions.Labels)
+	assert.False(t, options.SwarmOptions.IsSwarm)
+	assert.Contains(t, options.AuthOptions.ServerCertPath, "dev")
+}
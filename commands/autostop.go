@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errAutostopIdleAndDisable = errors.New("Error: --idle and --disable can't be used together")
+	errAutostopInvalidIdle    = errors.New("Error: The idle time must be a positive duration, e.g. 30m")
+)
+
+func cmdAutostop(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	idleFlag := c.String("idle")
+	disable := c.Bool("disable")
+	if idleFlag != "" && disable {
+		return errAutostopIdleAndDisable
+	}
+
+	var idle time.Duration
+	if idleFlag != "" {
+		var err error
+		idle, err = time.ParseDuration(idleFlag)
+		if err != nil || idle <= 0 {
+			return errAutostopInvalidIdle
+		}
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case disable:
+		h.AutoStop = nil
+	case idleFlag != "":
+		h.AutoStop = &host.AutoStop{Idle: idle}
+	default:
+		if h.AutoStop == nil {
+			fmt.Println("disabled")
+		} else {
+			fmt.Println(h.AutoStop.Idle)
+		}
+		return nil
+	}
+
+	if err := api.Save(h); err != nil {
+		return err
+	}
+
+	if h.AutoStop == nil {
+		log.Infof("Machine %q won't be auto-stopped anymore.", h.Name)
+	} else {
+		log.Infof("Machine %q will be stopped by \"docker-machine watch\" after its engine runs no container for %s.", h.Name, idle)
+	}
+
+	return nil
+}
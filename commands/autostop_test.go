@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdAutostop(t *testing.T) {
+	h := &host.Host{
+		Name:   "myhost",
+		Driver: &fakedriver.Driver{MockState: state.Running},
+	}
+	api := &libmachinetest.FakeAPI{Hosts: []*host.Host{h}}
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"myhost"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"idle":    "30m",
+				"disable": false,
+			},
+		},
+	}
+
+	assert.NoError(t, cmdAutostop(commandLine, api))
+	assert.Equal(t, &host.AutoStop{Idle: 30 * time.Minute}, h.AutoStop)
+}
+
+func TestCmdAutostopDisable(t *testing.T) {
+	h := &host.Host{
+		Name:     "myhost",
+		Driver:   &fakedriver.Driver{MockState: state.Running},
+		AutoStop: &host.AutoStop{Idle: time.Hour},
+	}
+	api := &libmachinetest.FakeAPI{Hosts: []*host.Host{h}}
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"myhost"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"idle":    "",
+				"disable": true,
+			},
+		},
+	}
+
+	assert.NoError(t, cmdAutostop(commandLine, api))
+	assert.Nil(t, h.AutoStop)
+}
+
+func TestCmdAutostopInvalidIdle(t *testing.T) {
+	for _, idle := range []string{"soon", "-5m", "0s"} {
+		commandLine := &commandstest.FakeCommandLine{
+			CliArgs: []string{"myhost"},
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{
+					"idle":    idle,
+					"disable": false,
+				},
+			},
+		}
+
+		assert.Equal(t, errAutostopInvalidIdle, cmdAutostop(commandLine, &libmachinetest.FakeAPI{}))
+	}
+}
+
+func TestCmdAutostopIdleAndDisable(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"myhost"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"idle":    "30m",
+				"disable": true,
+			},
+		},
+	}
+
+	assert.Equal(t, errAutostopIdleAndDisable, cmdAutostop(commandLine, &libmachinetest.FakeAPI{}))
+}
@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+var (
+	errNoMachineMatches = errors.New("Error: No machine matches the given names and filters")
+	errAllWithNames     = errors.New("Error: --all can't be used with machine names")
+)
+
+// isNamePattern tells whether a machine name given as argument is a shell
+// pattern, e.g. 'ci-*'.
+func isNamePattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// batchTargetNames returns the names of the machines given as arguments, where
+// the groups, e.g. @web, and the shell patterns are expanded, restricted to
+// the machines matching the --filter expressions of ls.  Without arguments,
+// all the machines matching the filters, or all the machines with --all, are
+// returned.
+func batchTargetNames(c CommandLine, api libmachine.API) ([]string, error) {
+	all := c.Bool("all")
+	if all && len(c.Args()) > 0 {
+		return nil, errAllWithNames
+	}
+
+	args, err := expandGroups(c.Args(), api)
+	if err != nil {
+		return nil, err
+	}
+	filters := c.StringSlice("filter")
+
+	hasPattern := false
+	for _, arg := range args {
+		if isNamePattern(arg) {
+			hasPattern = true
+		}
+	}
+
+	if !hasPattern && len(filters) == 0 && !all {
+		return uniqueNames(args), nil
+	}
+
+	filterOptions, err := parseFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	hostList, _, err := persist.LoadAllHosts(api)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{}
+	for _, h := range filterHosts(hostList, filterOptions) {
+		candidates = append(candidates, h.Name)
+	}
+
+	if len(args) == 0 {
+		if len(candidates) == 0 {
+			return nil, errNoMachineMatches
+		}
+		return candidates, nil
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+	for _, arg := range args {
+		matches, err := matchNames(arg, candidates, len(filters) > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range matches {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errNoMachineMatches
+	}
+
+	return names, nil
+}
+
+// uniqueNames returns the names without duplicates, e.g. of machines in
+// several of the given groups.
+func uniqueNames(names []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// matchNames returns the candidates matching an argument.  A name which isn't
+// a pattern is kept as is, so that a missing machine is reported, unless it
+// must be one of the filtered candidates.
+func matchNames(arg string, candidates []string, filtered bool) ([]string, error) {
+	if !isNamePattern(arg) {
+		if !filtered {
+			return []string{arg}, nil
+		}
+
+		for _, candidate := range candidates {
+			if candidate == arg {
+				return []string{arg}, nil
+			}
+		}
+
+		return nil, nil
+	}
+
+	matches := []string{}
+	for _, candidate := range candidates {
+		matched, err := path.Match(arg, candidate)
+		if err != nil {
+			return nil, fmt.Errorf("Error: invalid machine name pattern %q: %s", arg, err)
+		}
+
+		if matched {
+			matches = append(matches, candidate)
+		}
+	}
+
+	return matches, nil
+}
+
+// runBatchAction runs an action concurrently on the target machines, and
+// prints a summary of the outcome on each of them when there are several.
+func runBatchAction(actionName string, c CommandLine, api libmachine.API) error {
+	hosts, err := loadTargetHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	return runBatchActionOnHosts(actionName, c, hosts, api)
+}
+
+// runBatchActionOnHosts runs an action concurrently on the machines, and
+// saves the ones it succeeded on even if it failed on others.  The outcome on
+// each machine is printed as a table, or as a JSON list with --output json.
+func runBatchActionOnHosts(actionName string, c CommandLine, hosts []*host.Host, api libmachine.API) error {
+	if len(hosts) == 1 {
+		return runActionOnHosts(actionName, hosts, api)
+	}
+
+	results := runActionForeachMachineResults(actionName, hosts)
+	syncInventoriesAfter(actionName, api)
+
+	byName := map[string]*host.Host{}
+	for _, h := range hosts {
+		byName[h.Name] = h
+	}
+
+	errs := []error{}
+	for i, result := range results {
+		if result.err != nil {
+			errs = append(errs, result.err)
+			continue
+		}
+
+		if err := api.Save(byName[result.name]); err != nil {
+			results[i].err = fmt.Errorf("Error saving host to store: %s", err)
+			errs = append(errs, results[i].err)
+		}
+	}
+
+	if isJSONOutput(c) {
+		if err := printJSON(batchItems(results)); err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			return errJSONReported
+		}
+		return nil
+	}
+
+	printBatchSummary(results)
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+type actionResultsByName []actionResult
+
+func (r actionResultsByName) Len() int           { return len(r) }
+func (r actionResultsByName) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r actionResultsByName) Less(i, j int) bool { return r[i].name < r[j].name }
+
+// batchItems returns the outcome of an action on each machine as printed with
+// --output json.
+func batchItems(results []actionResult) []BatchItem {
+	sort.Sort(actionResultsByName(results))
+
+	items := []BatchItem{}
+	for _, result := range results {
+		item := BatchItem{Name: result.name}
+		if result.err != nil {
+			jsonErr := newJSONError(result.err)
+			item.Error = &jsonErr
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// printBatchSummary prints the outcome of an action on each machine as a
+// table.
+func printBatchSummary(results []actionResult) {
+	sort.Sort(actionResultsByName(results))
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NAME\tRESULT\tERROR")
+	for _, result := range results {
+		if result.err != nil {
+			fmt.Fprintf(w, "%s\tFailed\t%s\n", result.name, strings.Replace(result.err.Error(), "\n", " ", -1))
+		} else {
+			fmt.Fprintf(w, "%s\tDone\t\n", result.name)
+		}
+	}
+}
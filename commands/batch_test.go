@@ -0,0 +1,234 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBatchTestAPI() *libmachinetest.FakeAPI {
+	return &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "ci-1",
+				DriverName: "fakedriver",
+				Driver: &fakedriver.Driver{
+					MockName:  "ci-1",
+					MockState: state.Running,
+				},
+			},
+			{
+				Name:       "ci-2",
+				DriverName: "fakedriver",
+				Driver: &fakedriver.Driver{
+					MockName:  "ci-2",
+					MockState: state.Stopped,
+				},
+			},
+			{
+				Name:       "dev",
+				DriverName: "fakedriver",
+				Driver: &fakedriver.Driver{
+					MockName:  "dev",
+					MockState: state.Running,
+				},
+			},
+		},
+	}
+}
+
+func TestBatchTargetNamesWithoutPatterns(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"dev", "missing"},
+	}
+
+	names, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev", "missing"}, names)
+}
+
+func TestBatchTargetNamesWithPattern(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"ci-*", "dev", "ci-1"},
+	}
+
+	names, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ci-1", "ci-2", "dev"}, names)
+}
+
+func TestBatchTargetNamesWithFilter(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"filter": []string{"state=Running"},
+			},
+		},
+	}
+
+	names, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ci-1", "dev"}, names)
+}
+
+func TestBatchTargetNamesWithPatternAndFilter(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"ci-*"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"filter": []string{"state=Running"},
+			},
+		},
+	}
+
+	names, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ci-1"}, names)
+}
+
+func TestBatchTargetNamesAll(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"all": true,
+			},
+		},
+	}
+
+	names, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ci-1", "ci-2", "dev"}, names)
+}
+
+func TestBatchTargetNamesAllWithFilter(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"all":    true,
+				"filter": []string{"state=Stopped"},
+			},
+		},
+	}
+
+	names, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ci-2"}, names)
+}
+
+func TestBatchTargetNamesAllWithNames(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"all": true,
+			},
+		},
+	}
+
+	_, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.Equal(t, errAllWithNames, err)
+}
+
+func TestBatchTargetNamesNoMatch(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"prod-*"},
+	}
+
+	_, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.Equal(t, errNoMachineMatches, err)
+}
+
+func TestBatchTargetNamesInvalidPattern(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"ci-["},
+	}
+
+	_, err := batchTargetNames(commandLine, newBatchTestAPI())
+
+	assert.EqualError(t, err, `Error: invalid machine name pattern "ci-[": syntax error in pattern`)
+}
+
+func TestCmdStopPattern(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"*"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"filter": []string{"state=Running"},
+			},
+		},
+	}
+	api := newBatchTestAPI()
+
+	err := cmdStop(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, libmachinetest.State(api, "ci-1"))
+	assert.Equal(t, state.Stopped, libmachinetest.State(api, "dev"))
+	output := stdoutGetter.Output()
+	assert.Contains(t, output, "ci-1   Done")
+	assert.Contains(t, output, "dev    Done")
+	assert.NotContains(t, output, "ci-2")
+}
+
+func TestCmdRmFilter(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"filter": []string{"name=^ci-"},
+				"y":      true,
+			},
+		},
+	}
+	api := newBatchTestAPI()
+
+	err := cmdRm(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.False(t, libmachinetest.Exists(api, "ci-1"))
+	assert.False(t, libmachinetest.Exists(api, "ci-2"))
+	assert.True(t, libmachinetest.Exists(api, "dev"))
+}
+
+func TestCmdStopPatternJSON(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"*"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"filter": []string{"state=Running"},
+			},
+		},
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{"output": "json"}},
+	}
+	api := newBatchTestAPI()
+
+	err := cmdStop(commandLine, api)
+
+	assert.NoError(t, err)
+
+	items := []BatchItem{}
+	assert.NoError(t, json.Unmarshal([]byte(stdoutGetter.Output()), &items))
+	assert.Equal(t, []BatchItem{{Name: "ci-1"}, {Name: "dev"}}, items)
+}
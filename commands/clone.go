@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+var (
+	errNoCloneNames = errors.New("Error: Expected the name of the machine to clone and the name of the clone as arguments")
+)
+
+func cmdClone(c CommandLine, api libmachine.API) error {
+	switch {
+	case len(c.Args()) > 2:
+		return ErrTooManyArguments
+	case len(c.Args()) < 2:
+		return errNoCloneNames
+	}
+
+	srcName, name := c.Args()[0], c.Args()[1]
+
+	if !host.ValidateHostName(name) {
+		return fmt.Errorf("Error creating machine: %s", mcnerror.ErrInvalidHostname)
+	}
+
+	exists, err := api.Exists(name)
+	if err != nil {
+		return fmt.Errorf("Error checking if host exists: %s", err)
+	}
+	if exists {
+		return mcnerror.ErrHostAlreadyExists{
+			Name: name,
+		}
+	}
+
+	src, err := api.Load(srcName)
+	if err != nil {
+		return err
+	}
+
+	rawDriver, err := cloneDriverConfig(src, name, mcndirs.GetBaseDir())
+	if err != nil {
+		return err
+	}
+
+	h, err := api.NewHost(src.DriverName, rawDriver)
+	if err != nil {
+		return fmt.Errorf("Error getting new host: %s", err)
+	}
+
+	h.HostOptions, err = cloneHostOptions(src.HostOptions, name)
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("no-disk") {
+		if err := cloneDisk(src, h); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Cloning %q to %q...", src.Name, name)
+	if err := api.Create(h); err != nil {
+		return fmt.Errorf("Error creating the clone of %s: %s", src.Name, err)
+	}
+
+	if err := api.Save(h); err != nil {
+		return fmt.Errorf("Error attempting to save store: %s", err)
+	}
+
+	if isJSONOutput(c) {
+		return printCreatedJSON(h)
+	}
+
+	log.Infof("To see how to connect your Docker Client to the Docker Engine running on this virtual machine, run: %s env %s", os.Args[0], name)
+
+	return nil
+}
+
+// cloneDriverConfig returns the configuration of the driver of a machine for
+// a new machine: the flags it was created with are kept, but not its name,
+// its IP address, or the SSH key generated in its directory.  The IDs of the
+// resources of the machine at the provider are replaced when the clone is
+// created.
+func cloneDriverConfig(src *host.Host, name, storePath string) ([]byte, error) {
+	rawDriver := src.RawDriver
+	if len(rawDriver) == 0 {
+		var err error
+		if rawDriver, err = json.Marshal(src.Driver); err != nil {
+			return nil, fmt.Errorf("Error reading the driver configuration of %s: %s", src.Name, err)
+		}
+	}
+
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(rawDriver, &config); err != nil {
+		return nil, fmt.Errorf("Error reading the driver configuration of %s: %s", src.Name, err)
+	}
+
+	if sshKeyPath, ok := config["SSHKeyPath"].(string); ok {
+		srcStorePath, _ := config["StorePath"].(string)
+		if strings.HasPrefix(sshKeyPath, filepath.Join(srcStorePath, "machines", src.Name)+string(filepath.Separator)) {
+			delete(config, "SSHKeyPath")
+		}
+	}
+
+	delete(config, "IPAddress")
+	config["MachineName"] = name
+	config["StorePath"] = storePath
+
+	return json.Marshal(config)
+}
+
+// cloneHostOptions copies the options of a machine for a new machine, with
+// the paths of its own server certificates so that they are regenerated.
+func cloneHostOptions(options *host.Options, name string) (*host.Options, error) {
+	content, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := &host.Options{}
+	if err := json.Unmarshal(content, clone); err != nil {
+		return nil, err
+	}
+
+	if clone.AuthOptions != nil {
+		machineDir := filepath.Join(mcndirs.GetMachineDir(), name)
+		clone.AuthOptions.ServerCertPath = filepath.Join(machineDir, "server.pem")
+		clone.AuthOptions.ServerKeyPath = filepath.Join(machineDir, "server-key.pem")
+		clone.AuthOptions.StorePath = machineDir
+	}
+
+	return clone, nil
+}
+
+// cloneDisk makes the clone be created from a copy of the disk of the source
+// machine if its driver supports it.  Otherwise the clone is created from
+// scratch with the same configuration.
+func cloneDisk(src, clone *host.Host) error {
+	srcCloner, ok := src.Driver.(drivers.Cloner)
+	if !ok {
+		log.Infof("The %s driver can't clone disks, %q will be created from scratch", src.DriverName, clone.Name)
+		return nil
+	}
+
+	log.Infof("Copying the disk of %q...", src.Name)
+	disk, err := srcCloner.CloneDisk(clone.Name)
+	if err == drivers.ErrCloneNotSupported {
+		log.Infof("The %s driver can't clone disks, %q will be created from scratch", src.DriverName, clone.Name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error copying the disk of %s: %s", src.Name, err)
+	}
+
+	cloner, ok := clone.Driver.(drivers.Cloner)
+	if !ok {
+		return drivers.ErrCloneNotSupported
+	}
+
+	return cloner.SetClonedDisk(disk)
+}
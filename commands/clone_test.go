@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCloneDriver struct {
+	fakedriver.Driver
+	clonedDisk string
+}
+
+func (d *fakeCloneDriver) CloneDisk(name string) (string, error) {
+	return "image-" + name, nil
+}
+
+func (d *fakeCloneDriver) SetClonedDisk(disk string) error {
+	d.clonedDisk = disk
+	return nil
+}
+
+func TestCmdCloneRequiresTwoNames(t *testing.T) {
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdClone(&commandstest.FakeCommandLine{CliArgs: []string{"dev"}}, api)
+	assert.Equal(t, errNoCloneNames, err)
+
+	err = cmdClone(&commandstest.FakeCommandLine{CliArgs: []string{"dev", "dev2", "dev3"}}, api)
+	assert.Equal(t, ErrTooManyArguments, err)
+}
+
+func TestCmdCloneExistingMachine(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{{Name: "dev"}, {Name: "dev2"}},
+	}
+
+	err := cmdClone(&commandstest.FakeCommandLine{CliArgs: []string{"dev", "dev2"}}, api)
+	assert.EqualError(t, err, `Host already exists: "dev2"`)
+}
+
+func TestCloneDriverConfig(t *testing.T) {
+	src := &host.Host{
+		Name:       "dev",
+		DriverName: "digitalocean",
+		RawDriver:  []byte(`{"MachineName":"dev","StorePath":"/store","IPAddress":"1.2.3.4","SSHKeyPath":"/store/machines/dev/id_rsa","DropletID":42,"Region":"ams3"}`),
+	}
+
+	rawDriver, err := cloneDriverConfig(src, "dev2", "/other")
+	assert.NoError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(rawDriver, &config))
+	assert.Equal(t, map[string]interface{}{
+		"MachineName": "dev2",
+		"StorePath":   "/other",
+		"DropletID":   float64(42),
+		"Region":      "ams3",
+	}, config)
+}
+
+func TestCloneDriverConfigKeepsGivenSSHKey(t *testing.T) {
+	src := &host.Host{
+		Name:      "dev",
+		RawDriver: []byte(`{"MachineName":"dev","StorePath":"/store","SSHKeyPath":"/home/user/.ssh/id_rsa"}`),
+	}
+
+	rawDriver, err := cloneDriverConfig(src, "dev2", "/store")
+	assert.NoError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(rawDriver, &config))
+	assert.Equal(t, "/home/user/.ssh/id_rsa", config["SSHKeyPath"])
+}
+
+func TestCloneHostOptions(t *testing.T) {
+	options := &host.Options{
+		AuthOptions: &auth.Options{
+			CaCertPath:     "/certs/ca.pem",
+			ServerCertPath: "/machines/dev/server.pem",
+			ServerKeyPath:  "/machines/dev/server-key.pem",
+			StorePath:      "/machines/dev",
+		},
+		EngineOptions: &engine.Options{
+			Labels: []string{"env=dev"},
+		},
+	}
+
+	clone, err := cloneHostOptions(options, "dev2")
+	assert.NoError(t, err)
+
+	machineDir := filepath.Join(mcndirs.GetMachineDir(), "dev2")
+	assert.Equal(t, "/certs/ca.pem", clone.AuthOptions.CaCertPath)
+	assert.Equal(t, filepath.Join(machineDir, "server.pem"), clone.AuthOptions.ServerCertPath)
+	assert.Equal(t, filepath.Join(machineDir, "server-key.pem"), clone.AuthOptions.ServerKeyPath)
+	assert.Equal(t, machineDir, clone.AuthOptions.StorePath)
+	assert.Equal(t, []string{"env=dev"}, clone.EngineOptions.Labels)
+	assert.Equal(t, "/machines/dev/server.pem", options.AuthOptions.ServerCertPath)
+}
+
+func TestCloneDisk(t *testing.T) {
+	src := &host.Host{Name: "dev", Driver: &fakeCloneDriver{}}
+	driver := &fakeCloneDriver{}
+	clone := &host.Host{Name: "dev2", Driver: driver}
+
+	assert.NoError(t, cloneDisk(src, clone))
+	assert.Equal(t, "image-dev2", driver.clonedDisk)
+}
+
+func TestCloneDiskNotSupported(t *testing.T) {
+	src := &host.Host{Name: "dev", DriverName: "fakedriver", Driver: &fakedriver.Driver{}}
+	clone := &host.Host{Name: "dev2", Driver: &fakedriver.Driver{}}
+
+	assert.NoError(t, cloneDisk(src, clone))
+}
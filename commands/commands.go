@@ -4,18 +4,29 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/codegangsta/cli"
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/crashreport"
+	"github.com/docker/machine/libmachine/dnshook"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/hooks"
 	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/inventory"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/retry"
 	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+	machinesync "github.com/docker/machine/libmachine/sync"
 )
 
 const (
@@ -95,6 +106,35 @@ func targetHost(c CommandLine, api libmachine.API) (string, error) {
 }
 
 func runAction(actionName string, c CommandLine, api libmachine.API) error {
+	hosts, err := loadTargetHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	return runActionOnHosts(actionName, hosts, api)
+}
+
+// runActionOnHosts runs an action on hosts already loaded, and saves them.
+func runActionOnHosts(actionName string, hosts []*host.Host, api libmachine.API) error {
+	errs := runActionForeachMachine(actionName, hosts)
+	syncInventoriesAfter(actionName, api)
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	for _, h := range hosts {
+		if err := api.Save(h); err != nil {
+			return fmt.Errorf("Error saving host to store: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// loadTargetHosts loads the machines given as arguments, whose patterns are
+// expanded, or matching the --filter expressions, or all of them with --all,
+// or the default machine if there are none.
+func loadTargetHosts(c CommandLine, api libmachine.API) ([]*host.Host, error) {
 	var (
 		hostsToLoad []string
 	)
@@ -102,15 +142,20 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 	// If user did not specify a machine name explicitly, use the 'default'
 	// machine if it exists.  This allows short form commands such as
 	// 'docker-machine stop' for convenience.
-	if len(c.Args()) == 0 {
+	if len(c.Args()) == 0 && len(c.StringSlice("filter")) == 0 && !c.Bool("all") {
 		target, err := targetHost(c, api)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		hostsToLoad = []string{target}
 	} else {
-		hostsToLoad = c.Args()
+		names, err := batchTargetNames(c, api)
+		if err != nil {
+			return nil, err
+		}
+
+		hostsToLoad = names
 	}
 
 	hosts, hostsInError := persist.LoadHosts(api, hostsToLoad)
@@ -120,23 +165,99 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 		for _, err := range hostsInError {
 			errs = append(errs, err)
 		}
-		return consolidateErrs(errs)
+		if len(errs) == 1 {
+			// Keep the error as is for the JSON error code
+			return nil, errs[0]
+		}
+		return nil, consolidateErrs(errs)
 	}
 
 	if len(hosts) == 0 {
-		return ErrHostLoad
+		return nil, ErrHostLoad
 	}
 
-	if errs := runActionForeachMachine(actionName, hosts); len(errs) > 0 {
-		return consolidateErrs(errs)
+	return hosts, nil
+}
+
+// configureCertGenerator makes an external certificate authority sign the
+// certificates if one is selected with --tls-ca-backend.
+func configureCertGenerator(c CommandLine) error {
+	switch c.GlobalString("tls-ca-backend") {
+	case "", "local":
+		return nil
+	case "vault":
+		signer, err := cert.NewVaultSigner(
+			c.GlobalString("vault-addr"),
+			c.GlobalString("vault-token"),
+			c.GlobalString("vault-pki-path"),
+			c.GlobalString("vault-role"),
+			c.GlobalString("vault-ca-cert"),
+		)
+		if err != nil {
+			return err
+		}
+
+		cert.SetCertGenerator(cert.NewSignerCertGenerator(signer))
+		return nil
+	default:
+		return fmt.Errorf("Invalid TLS CA backend %q, expected local or vault", c.GlobalString("tls-ca-backend"))
 	}
+}
 
-	for _, h := range hosts {
-		if err := api.Save(h); err != nil {
-			return fmt.Errorf("Error saving host to store: %s", err)
+// configureSSHSigner makes Machine access the machines with SSH certificates
+// if a certificate authority is selected with --ssh-ca-backend.
+func configureSSHSigner(c CommandLine) error {
+	var signer cert.SSHSigner
+
+	switch c.GlobalString("ssh-ca-backend") {
+	case "", "none":
+		return nil
+	case "local":
+		signer = cert.NewLocalSSHSigner(mcndirs.GetMachineCertDir())
+	case "vault":
+		vaultSigner, err := cert.NewVaultSSHSigner(
+			c.GlobalString("vault-addr"),
+			c.GlobalString("vault-token"),
+			c.GlobalString("vault-ssh-path"),
+			c.GlobalString("vault-ssh-role"),
+			c.GlobalString("vault-ca-cert"),
+		)
+		if err != nil {
+			return err
 		}
+		signer = vaultSigner
+	default:
+		return fmt.Errorf("Invalid SSH CA backend %q, expected none, local or vault", c.GlobalString("ssh-ca-backend"))
 	}
 
+	ttl := cert.DefaultSSHCertificateTTL
+	if c.GlobalString("ssh-cert-ttl") != "" {
+		var err error
+		if ttl, err = time.ParseDuration(c.GlobalString("ssh-cert-ttl")); err != nil || ttl <= 0 {
+			return fmt.Errorf("Invalid SSH certificate TTL %q, expected a duration such as 1h", c.GlobalString("ssh-cert-ttl"))
+		}
+	}
+
+	cert.SetSSHSigner(signer, ttl)
+	ssh.SetCertificateRenewer(cert.IssueSSHCertificate)
+	return nil
+}
+
+// loadHooks loads the hooks and the DNS registration of the configuration
+// files of the storage path.
+func loadHooks(storePath string) error {
+	h, err := hooks.Load(filepath.Join(storePath, hooks.ConfigFileName))
+	if err != nil {
+		return err
+	}
+
+	dns, err := dnshook.Load(filepath.Join(storePath, dnshook.ConfigFileName))
+	if err != nil {
+		return err
+	}
+
+	hooks.SetDefault(h)
+	dnshook.SetDefault(dns)
 	return nil
 }
 
@@ -149,7 +270,8 @@ func runCommand(command func(commandLine CommandLine, api libmachine.API) error)
 			api.SSHClientType = ssh.Native
 		}
 		api.GithubAPIToken = context.GlobalString("github-api-token")
-		api.Filestore.Path = context.GlobalString("storage-path")
+		storagePath, storageURI, profileErr := selectProfile(&contextCommandLine{context})
+		api.Filestore.Path = storagePath
 
 		// TODO (nathanleclaire): These should ultimately be accessed
 		// through the libmachine client by the rest of the code and
@@ -159,9 +281,60 @@ func runCommand(command func(commandLine CommandLine, api libmachine.API) error)
 		mcndirs.BaseDir = api.Filestore.Path
 		mcnutils.GithubAPIToken = api.GithubAPIToken
 		ssh.SetDefaultClient(api.SSHClientType)
+		ssh.SetMultiplexing(!context.GlobalBool("no-ssh-multiplexing"))
 
-		if err := command(&contextCommandLine{context}, api); err != nil {
-			log.Error(err)
+		jsonOutput := context.GlobalString("output") == outputJSON
+		if jsonOutput {
+			// Keep stdout for the JSON document only
+			log.SetOutWriter(os.Stderr)
+		}
+
+		err := validateOutput(context.GlobalString("output"))
+		if err == nil {
+			err = profileErr
+		}
+		if err == nil {
+			err = log.SetFormat(context.GlobalString("log-format"))
+		}
+		if err == nil {
+			err = configureCertGenerator(&contextCommandLine{context})
+		}
+		if err == nil {
+			err = configureSSHSigner(&contextCommandLine{context})
+		}
+		if err == nil && context.GlobalString("retry-budget") != "" {
+			err = retry.SetBudgets(context.GlobalString("retry-budget"))
+		}
+		if err == nil {
+			err = loadHooks(api.Filestore.Path)
+		}
+		if err == nil {
+			api.Store, err = persist.NewStore(storageURI, api.Filestore)
+		}
+		if err == nil {
+			err = command(&contextCommandLine{context}, api)
+		}
+
+		if status, ok := err.(errExitStatus); ok {
+			osExit(int(status))
+			return
+		}
+
+		exitStatus := 1
+		if waitErr, ok := err.(errStatusWait); ok {
+			exitStatus = waitErr.status
+			err = waitErr.err
+		}
+
+		if err != nil {
+			switch {
+			case err == errJSONReported:
+				// The failures are part of the document already printed
+			case jsonOutput:
+				printJSONError(err)
+			default:
+				log.Error(err)
+			}
 
 			if crashErr, ok := err.(crashreport.CrashError); ok {
 				crashReporter := crashreport.NewCrashReporter(mcndirs.GetBaseDir(), context.GlobalString("bugsnag-api-token"))
@@ -173,7 +346,13 @@ func runCommand(command func(commandLine CommandLine, api libmachine.API) error)
 				}
 			}
 
-			osExit(1)
+			// The checks of create --dry-run
+			if _, ok := err.(mcnerror.ErrDuringPreCreate); ok {
+				osExit(3)
+				return
+			}
+
+			osExit(exitStatus)
 			return
 		}
 	}
@@ -205,6 +384,74 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "adopt",
+		Usage:       "Provision an existing server and manage it as a machine",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdAdopt),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "address",
+				Usage: "IP address or hostname of the server",
+			},
+			cli.StringFlag{
+				Name:  "ssh-user",
+				Usage: "SSH user of the server",
+				Value: drivers.DefaultSSHUser,
+			},
+			cli.StringFlag{
+				Name:  "ssh-key",
+				Usage: "SSH private key path (if not provided, default SSH key will be used)",
+			},
+			cli.IntFlag{
+				Name:  "ssh-port",
+				Usage: "SSH port of the server",
+				Value: drivers.DefaultSSHPort,
+			},
+			cli.IntFlag{
+				Name:  "engine-port",
+				Usage: "Docker engine port",
+				Value: engine.DefaultPort,
+			},
+			cli.StringFlag{
+				Name:  "ssh-proxy-jump",
+				Usage: "Bastion host to connect to the server through over SSH, as [user@]host[:port]",
+			},
+			cli.StringSliceFlag{
+				Name:  "engine-label",
+				Usage: "Specify labels for the created engine",
+				Value: &cli.StringSlice{},
+			},
+		},
+	},
+	{
+		Name:        "autostop",
+		Usage:       "Stop a machine from \"watch\" once its engine has run no container for some time",
+		Description: "Argument is a machine name. Without flags, prints the idle time of the machine.",
+		Action:      runCommand(cmdAutostop),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "idle",
+				Usage: "Idle time after which the machine is stopped, e.g. 30m",
+			},
+			cli.BoolFlag{
+				Name:  "disable",
+				Usage: "Don't stop the machine when it is idle anymore",
+			},
+		},
+	},
+	{
+		Name:        "clone",
+		Usage:       "Create a machine with the configuration and the disk of another machine",
+		Description: "Arguments are the name of the machine to clone and the name of the clone.",
+		Action:      runCommand(cmdClone),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "no-disk",
+				Usage: "Create the clone from scratch rather than from a copy of the disk of the machine",
+			},
+		},
+	},
 	{
 		Name:        "config",
 		Usage:       "Print the connection config for machine",
@@ -217,6 +464,44 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "config-engine",
+		Usage:       "Update the registry settings of the engine of a machine",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdConfigEngine),
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "engine-registry-mirror",
+				Usage: "Registry mirrors of the engine, replacing the current ones, empty to remove them",
+				Value: &cli.StringSlice{},
+			},
+			cli.StringSliceFlag{
+				Name:  "engine-insecure-registry",
+				Usage: "Insecure registries of the engine, replacing the current ones, empty to remove them",
+				Value: &cli.StringSlice{},
+			},
+		},
+	},
+	{
+		Name:        "config-proxy",
+		Usage:       "Update the proxy settings of the engine of a machine",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdConfigProxy),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "http-proxy",
+				Usage: "HTTP proxy of the engine, empty to remove it",
+			},
+			cli.StringFlag{
+				Name:  "https-proxy",
+				Usage: "HTTPS proxy of the engine, empty to remove it",
+			},
+			cli.StringFlag{
+				Name:  "no-proxy",
+				Usage: "Hosts the engine reaches without the proxy, empty to remove them",
+			},
+		},
+	},
 	{
 		Flags:           SharedCreateFlags,
 		Name:            "create",
@@ -225,6 +510,18 @@ var Commands = []cli.Command{
 		Action:          runCommand(cmdCreateOuter),
 		SkipFlagParsing: true,
 	},
+	{
+		Name:        "driver-exec",
+		Usage:       "Run an operation of the provider of a machine, e.g. attaching a volume",
+		Description: "Arguments are [machine-name] -- [operation] [json-args]. Without an operation, the operations of the driver are listed.",
+		Action:      runCommand(cmdDriverExec),
+	},
+	{
+		Name:        "driver-info",
+		Usage:       "Show the features a driver supports",
+		Description: "Argument is a driver name.",
+		Action:      runCommand(cmdDriverInfo),
+	},
 	{
 		Name:        "env",
 		Usage:       "Display the commands to set up the environment for the Docker client",
@@ -247,8 +544,112 @@ var Commands = []cli.Command{
 				Name:  "no-proxy",
 				Usage: "Add machine IP to NO_PROXY environment variable",
 			},
+			cli.BoolFlag{
+				Name:  "context",
+				Usage: "Create or update a Docker CLI context named after the machine instead of displaying the environment",
+			},
+			cli.IntFlag{
+				Name:  "tunnel-port",
+				Usage: "Local port the engine of a machine reached through a bastion is forwarded to, default is the engine port",
+			},
+		},
+	},
+	{
+		Name:        "events",
+		Usage:       "Stream the state changes of machines",
+		Description: "Argument(s) are zero or more machine names, all the machines are watched by default.",
+		Action:      runCommand(cmdEvents),
+	},
+	{
+		Name:        "export",
+		Usage:       "Export the configuration, certificates and SSH keys of a machine to an archive",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdExport),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "File of the archive (default: <name>.tar.gz)",
+			},
+			cli.BoolFlag{
+				Name:  "include-ca-key",
+				Usage: "Export the private key of the CA, needed to regenerate the certificates of the machine",
+			},
+		},
+	},
+	{
+		Name:        "export-inventory",
+		Usage:       "Export the running machines as an Ansible inventory or an OpenSSH configuration",
+		Description: "Writes the address, SSH port, user and key of each machine, to the standard output or to a file kept in sync with --sync.",
+		Action:      runCommand(cmdExportInventory),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "Format of the inventory: ansible (a YAML inventory) or ssh-config (a file to Include in ~/.ssh/config)",
+				Value: inventory.FormatAnsible,
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter the machines like ls (e.g. driver=kvm, label=env=ci)",
+				Value: &cli.StringSlice{},
+			},
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "File of the inventory (default: the standard output)",
+			},
+			cli.BoolFlag{
+				Name:  "sync",
+				Usage: "Rewrite the file when a machine is created, started, stopped, provisioned or removed",
+			},
+			cli.BoolFlag{
+				Name:  "stop-sync",
+				Usage: "Stop rewriting the file",
+			},
 		},
 	},
+	{
+		Flags:           GCFlags,
+		Name:            "gc",
+		Usage:           "List or delete the resources of a provider left behind by removed machines",
+		Description:     fmt.Sprintf("Run '%s gc --driver name' to include the flags for that driver in the help text.", os.Args[0]),
+		Action:          runCommand(cmdGCOuter),
+		SkipFlagParsing: true,
+	},
+	{
+		Name:  "group",
+		Usage: "Manage named groups of machines, given as @name to start, stop, restart, rm and upgrade",
+		Subcommands: []cli.Command{
+			{
+				Name:        "create",
+				Usage:       "Create a group",
+				Description: "Arguments are a group name and the machines of the group, if any.",
+				Action:      runCommand(cmdGroupCreate),
+			},
+			{
+				Name:        "add",
+				Usage:       "Add machines to a group",
+				Description: "Arguments are a group name and one or more machine names.",
+				Action:      runCommand(cmdGroupAdd),
+			},
+			{
+				Name:        "remove",
+				Usage:       "Remove machines from a group, or the group without machines as arguments",
+				Description: "Arguments are a group name and the machines to remove from the group, if any.",
+				Action:      runCommand(cmdGroupRemove),
+			},
+			{
+				Name:    "ls",
+				Aliases: []string{"list"},
+				Usage:   "List the groups and their machines",
+				Action:  runCommand(cmdGroupLs),
+			},
+		},
+	},
+	{
+		Name:        "import",
+		Usage:       "Import a machine exported with export",
+		Description: "Argument is the file of the exported machine.",
+		Action:      runCommand(cmdImport),
+	},
 	{
 		Name:        "inspect",
 		Usage:       "Inspect information about a machine",
@@ -267,6 +668,16 @@ var Commands = []cli.Command{
 		Usage:       "Get the IP address of a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdIP),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "private",
+				Usage: "Get the IP address of the machine on its private network",
+			},
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "Format the output using the given go template",
+			},
+		},
 	},
 	{
 		Name:        "kill",
@@ -274,6 +685,18 @@ var Commands = []cli.Command{
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdKill),
 	},
+	{
+		Name:        "kubeconfig",
+		Usage:       "Print the admin kubeconfig of a Kubernetes control plane or a k3s server",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdKubeconfig),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "path",
+				Usage: "Print the path of the kubeconfig instead, e.g. for KUBECONFIG",
+			},
+		},
+	},
 	{
 		Name:   "ls",
 		Usage:  "List machines",
@@ -290,19 +713,134 @@ var Commands = []cli.Command{
 			},
 			cli.IntFlag{
 				Name:  "timeout, t",
-				Usage: fmt.Sprintf("Timeout in seconds, default to %ds", lsDefaultTimeout),
+				Usage: fmt.Sprintf("Timeout in seconds for each machine, default to %ds", lsDefaultTimeout),
 				Value: lsDefaultTimeout,
 			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Maximum number of machines queried at the same time",
+				Value: lsDefaultParallel,
+			},
+			cli.BoolFlag{
+				Name:  "cached",
+				Usage: "List the machines in the state they were last listed in, without querying them",
+			},
+			cli.BoolFlag{
+				Name:  "stats",
+				Usage: "Query the engines for their containers, the disk usage of their images and their memory",
+			},
 			cli.StringFlag{
 				Name:  "format, f",
 				Usage: "Pretty-print machines using a Go template",
 			},
 		},
 	},
+	{
+		Name:        "mount",
+		Usage:       "Mount or unmount a directory from a machine with SSHFS, or sync it with a local directory",
+		Description: "Arguments are machine:path and a local directory.",
+		Action:      runCommand(cmdMount),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "unmount, u",
+				Usage: "Unmount instead of mount",
+			},
+			cli.BoolFlag{
+				Name:  "sync",
+				Usage: "Sync the local directory with the directory of the machine both ways with rsync, until interrupted",
+			},
+			cli.IntFlag{
+				Name:  "sync-interval",
+				Usage: "Interval in seconds between two pulls of the changes of the machine with --sync",
+				Value: int(machinesync.DefaultRemoteInterval / time.Second),
+			},
+		},
+	},
+	{
+		Name:        "port-forward",
+		Usage:       "Forward local ports to ports of a machine over SSH",
+		Description: "Arguments are a machine name and one or more forwards as [BIND:]PORT:[HOST:]HOSTPORT, e.g. 8080:80.",
+		Action:      runCommand(cmdPortForward),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "daemon, d",
+				Usage: "Forward the ports in the background, reconnecting whenever the connection is lost",
+			},
+			cli.BoolFlag{
+				Name:  "stop",
+				Usage: "Stop forwarding the ports of the machine in the background",
+			},
+		},
+	},
+	{
+		Name:  "profile",
+		Usage: "Manage the profiles storing the machines of each account separately",
+		Subcommands: []cli.Command{
+			{
+				Name:        "add",
+				Usage:       "Add a profile",
+				Description: "Argument is a profile name.",
+				Action:      runCommand(cmdProfileAdd),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "storage-path",
+						Usage: "Storage path of the machines of the profile (default: <storage path>/profiles/<name>)",
+					},
+					cli.StringFlag{
+						Name:  "storage-uri",
+						Usage: "Shared backend storing the machines of the profile, e.g. s3://bucket/prefix",
+					},
+					cli.StringFlag{
+						Name:  "driver, d",
+						Usage: "Driver to create the machines of the profile with",
+					},
+					cli.StringSliceFlag{
+						Name:  "driver-opt",
+						Usage: "Default of a driver flag, e.g. amazonec2-region=eu-west-1",
+						Value: &cli.StringSlice{},
+					},
+				},
+			},
+			{
+				Name:    "list",
+				Aliases: []string{"ls"},
+				Usage:   "List the profiles",
+				Action:  runCommand(cmdProfileList),
+			},
+			{
+				Name:        "use",
+				Usage:       "Use a profile when --profile isn't given",
+				Description: "Argument is a profile name.",
+				Action:      runCommand(cmdProfileUse),
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "unset, u",
+						Usage: "Stop using a profile",
+					},
+				},
+			},
+			{
+				Name:        "rm",
+				Usage:       "Remove a profile, leaving its machines in its storage path",
+				Description: "Argument is a profile name.",
+				Action:      runCommand(cmdProfileRm),
+			},
+		},
+	},
 	{
 		Name:   "provision",
 		Usage:  "Re-provision existing machines",
 		Action: runCommand(cmdProvision),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "phase",
+				Usage: "Only run these phases of the provisioning, among hostname, engine, certs and swarm, e.g. engine,certs",
+			},
+			cli.BoolFlag{
+				Name:  "force, f",
+				Usage: "Run the phases given with --phase even if their result is already in place",
+			},
+		},
 	},
 	{
 		Name:        "regenerate-certs",
@@ -314,13 +852,52 @@ var Commands = []cli.Command{
 				Name:  "force, f",
 				Usage: "Force rebuild and do not prompt",
 			},
+			cli.StringFlag{
+				Name:  "rotate-interval",
+				Usage: "Rotate the server certificates older than this interval (e.g. 720h), or expiring within 30 days, and keep rotating them with watch",
+			},
+		},
+	},
+	{
+		Name:        "rename",
+		Usage:       "Rename a machine",
+		Description: "Arguments are the name of the machine and its new name.",
+		Action:      runCommand(cmdRename),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "keep-resource-names",
+				Usage: "Keep the names of the resources of the machine at the provider, e.g. the name tag of an instance",
+			},
+		},
+	},
+	{
+		Name:        "resize",
+		Usage:       "Change the size of a machine",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdResize),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "size",
+				Usage: "New size of the machine, as named by its provider (e.g. s-4vcpu-8gb, t3.large)",
+			},
 		},
 	},
 	{
 		Name:        "restart",
 		Usage:       "Restart a machine",
-		Description: "Argument(s) are one or more machine names.",
+		Description: "Argument(s) are one or more machine names, patterns, e.g. 'ci-*', or groups, e.g. @ci.",
 		Action:      runCommand(cmdRestart),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Restart all the machines, or the ones matching --filter",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter the machines based on conditions provided, like ls",
+				Value: &cli.StringSlice{},
+			},
+		},
 	},
 	{
 		Flags: []cli.Flag{
@@ -332,12 +909,83 @@ var Commands = []cli.Command{
 				Name:  "y",
 				Usage: "Assumes automatic yes to proceed with remove, without prompting further user confirmation",
 			},
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Remove all the machines, or the ones matching --filter",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter the machines based on conditions provided, like ls",
+				Value: &cli.StringSlice{},
+			},
 		},
 		Name:        "rm",
 		Usage:       "Remove a machine",
-		Description: "Argument(s) are one or more machine names.",
+		Description: "Argument(s) are one or more machine names, patterns, e.g. 'ci-*', or groups, e.g. @ci.",
 		Action:      runCommand(cmdRm),
 	},
+	{
+		Name:        "serve",
+		Usage:       "Serve the machines over an HTTP API",
+		Description: "The requests are authenticated by a token given as 'Authorization: Bearer <token>'.",
+		Action:      runCommand(cmdServe),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "listen",
+				Usage: "Address to listen on",
+				Value: defaultServeAddress,
+			},
+			cli.StringFlag{
+				Name:   "token",
+				Usage:  "Token authenticating the requests (default: a random one saved in <storage path>/serve-token)",
+				EnvVar: "MACHINE_SERVE_TOKEN",
+			},
+			cli.StringFlag{
+				Name:  "tls-cert",
+				Usage: "Certificate to serve HTTPS with",
+			},
+			cli.StringFlag{
+				Name:  "tls-key",
+				Usage: "Private key of the certificate to serve HTTPS with",
+			},
+			cli.StringFlag{
+				Name:  "metrics",
+				Usage: "Address to serve the Prometheus metrics of the machines on, e.g. :9100",
+			},
+		},
+	},
+	{
+		Name:  "snapshot",
+		Usage: "Manage the snapshots of a machine",
+		Subcommands: []cli.Command{
+			{
+				Name:        "create",
+				Usage:       "Take a snapshot of a machine",
+				Description: "Arguments are a machine name and an optional snapshot name.",
+				Action:      runCommand(cmdSnapshotCreate),
+			},
+			{
+				Name:        "list",
+				Aliases:     []string{"ls"},
+				Usage:       "List the snapshots of a machine",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSnapshotList),
+			},
+			{
+				Name:        "restore",
+				Usage:       "Restore a machine to a snapshot",
+				Description: "Arguments are a machine name and a snapshot name or ID.",
+				Action:      runCommand(cmdSnapshotRestore),
+			},
+			{
+				Name:        "delete",
+				Aliases:     []string{"rm"},
+				Usage:       "Delete a snapshot of a machine",
+				Description: "Arguments are a machine name and a snapshot name or ID.",
+				Action:      runCommand(cmdSnapshotDelete),
+			},
+		},
+	},
 	{
 		Name:            "ssh",
 		Usage:           "Log into or run a command on a machine with SSH.",
@@ -355,31 +1003,102 @@ var Commands = []cli.Command{
 				Name:  "recursive, r",
 				Usage: "Copy files recursively (required to copy directories)",
 			},
+			cli.BoolFlag{
+				Name:  "resume",
+				Usage: "Copy the rest of the files which a previous copy left incomplete",
+			},
+			cli.BoolFlag{
+				Name:  "quiet, q",
+				Usage: "Don't show the progress of the copy",
+			},
 		},
 	},
 	{
 		Name:        "start",
 		Usage:       "Start a machine",
-		Description: "Argument(s) are one or more machine names.",
+		Description: "Argument(s) are one or more machine names, patterns, e.g. 'ci-*', or groups, e.g. @ci.",
 		Action:      runCommand(cmdStart),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Start all the machines, or the ones matching --filter",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter the machines based on conditions provided, like ls",
+				Value: &cli.StringSlice{},
+			},
+		},
 	},
 	{
 		Name:        "status",
-		Usage:       "Get the status of a machine",
-		Description: "Argument is a machine name.",
+		Usage:       "Get the status of machines, or wait until they're in a state",
+		Description: "Argument(s) are one or more machine names, patterns, e.g. 'ci-*', or groups, e.g. @ci.",
 		Action:      runCommand(cmdStatus),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "Format the output using the given go template",
+			},
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Get the status of all the machines, or the ones matching --filter",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter the machines based on conditions provided, like ls",
+				Value: &cli.StringSlice{},
+			},
+			cli.StringFlag{
+				Name:  "wait-for",
+				Usage: "Wait until the machines are in the given state, e.g. Running, exiting with 2 on timeout, 3 if one is in the Error state, 4 if one isn't found",
+			},
+			cli.StringFlag{
+				Name:  "timeout",
+				Usage: "How long to wait with --wait-for, e.g. 30s or 10m (default 10m)",
+			},
+		},
 	},
 	{
 		Name:        "stop",
 		Usage:       "Stop a machine",
-		Description: "Argument(s) are one or more machine names.",
+		Description: "Argument(s) are one or more machine names, patterns, e.g. 'ci-*', or groups, e.g. @ci.",
 		Action:      runCommand(cmdStop),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Stop all the machines, or the ones matching --filter",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter the machines based on conditions provided, like ls",
+				Value: &cli.StringSlice{},
+			},
+		},
+	},
+	{
+		Name:        "support-bundle",
+		Usage:       "Collect the logs and the configuration of the engine of a machine to an archive",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdSupportBundle),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "File of the archive (default: <name>-support.tar.gz)",
+			},
+		},
 	},
 	{
 		Name:        "upgrade",
 		Usage:       "Upgrade a machine to the latest version of Docker",
-		Description: "Argument(s) are one or more machine names.",
+		Description: "Argument(s) are one or more machine names, patterns, e.g. 'ci-*', or groups, e.g. @ci.",
 		Action:      runCommand(cmdUpgrade),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "engine-version",
+				Usage: "Install the given version of the engine instead of the latest one, e.g. 24.0.7",
+			},
+		},
 	},
 	{
 		Name:        "url",
@@ -387,6 +1106,31 @@ var Commands = []cli.Command{
 		Description: "Argument is a machine name.",
 		Action:      runCommand(cmdURL),
 	},
+	{
+		Name:        "watch",
+		Usage:       "Watch machines and repair their engine when it stops answering",
+		Description: "Argument(s) are zero or more machine names, all the machines are watched by default.",
+		Action:      runCommand(cmdWatch),
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "interval",
+				Usage: "Seconds between two checks of the machines",
+				Value: 30,
+			},
+			cli.StringFlag{
+				Name:  "hook",
+				Usage: "Script to run on each event, e.g. a failed check or a repair",
+			},
+			cli.BoolFlag{
+				Name:  "no-repair",
+				Usage: "Only report the failed checks, without restarting the engine or regenerating the certificates",
+			},
+			cli.BoolFlag{
+				Name:  "once",
+				Usage: "Check the machines once and exit",
+			},
+		},
+	},
 	{
 		Name:   "version",
 		Usage:  "Show the Docker Machine version or a machine docker version",
@@ -407,51 +1151,101 @@ func printIP(h *host.Host) func() error {
 	}
 }
 
+func printPrivateIP(h *host.Host) func() error {
+	return func() error {
+		ip, err := drivers.GetPrivateIP(h.Driver)
+		if err != nil {
+			return fmt.Errorf("Error getting private IP address: %s", err)
+		}
+
+		fmt.Println(ip)
+
+		return nil
+	}
+}
+
 // machineCommand maps the command name to the corresponding machine command.
 // We run commands concurrently and communicate back an error if there was one.
-func machineCommand(actionName string, host *host.Host, errorChan chan<- error) {
+func machineCommand(actionName string, host *host.Host, resultChan chan<- actionResult) {
 	// TODO: These actions should have their own type.
 	commands := map[string](func() error){
 		"configureAuth": host.ConfigureAuth,
+		"rotateCerts":   host.RotateCerts,
 		"start":         host.Start,
 		"stop":          host.Stop,
 		"restart":       host.Restart,
 		"kill":          host.Kill,
 		"upgrade":       host.Upgrade,
 		"ip":            printIP(host),
+		"privateIP":     printPrivateIP(host),
 		"provision":     host.Provision,
 	}
 
 	log.Debugf("command=%s machine=%s", actionName, host.Name)
 
-	errorChan <- commands[actionName]()
+	err := commands[actionName]()
+	if err == nil {
+		fireActionHooks(actionName, host)
+	}
+
+	resultChan <- actionResult{name: host.Name, err: err}
+}
+
+// fireActionHooks runs the hooks of the event of an action which succeeded
+// on a machine, if any.
+func fireActionHooks(actionName string, h *host.Host) {
+	switch actionName {
+	case "start", "restart":
+		hooks.Notify(hooks.Event{Type: hooks.StateChange, Machine: h.Name, Driver: h.DriverName, State: state.Running.String()})
+	case "stop", "kill":
+		hooks.Notify(hooks.Event{Type: hooks.StateChange, Machine: h.Name, Driver: h.DriverName, State: state.Stopped.String()})
+	case "provision", "configureAuth":
+		hooks.Notify(hooks.Event{Type: hooks.PostProvision, Machine: h.Name, Driver: h.DriverName, State: state.Running.String()})
+	}
+}
+
+// actionResult is the outcome of an action on a machine.
+type actionResult struct {
+	name string
+	err  error
 }
 
 // runActionForeachMachine will run the command across multiple machines
 func runActionForeachMachine(actionName string, machines []*host.Host) []error {
+	errs := []error{}
+	for _, result := range runActionForeachMachineResults(actionName, machines) {
+		if result.err != nil {
+			errs = append(errs, result.err)
+		}
+	}
+
+	return errs
+}
+
+// runActionForeachMachineResults runs the command across multiple machines
+// concurrently, and returns the outcome on each of them.
+func runActionForeachMachineResults(actionName string, machines []*host.Host) []actionResult {
 	var (
 		numConcurrentActions = 0
-		errorChan            = make(chan error)
-		errs                 = []error{}
+		resultChan           = make(chan actionResult)
+		results              = []actionResult{}
 	)
 
 	for _, machine := range machines {
 		numConcurrentActions++
-		go machineCommand(actionName, machine, errorChan)
+		go machineCommand(actionName, machine, resultChan)
 	}
 
 	// TODO: We should probably only do 5-10 of these
 	// at a time, since otherwise cloud providers might
 	// rate limit us.
 	for i := 0; i < numConcurrentActions; i++ {
-		if err := <-errorChan; err != nil {
-			errs = append(errs, err)
-		}
+		results = append(results, <-resultChan)
 	}
 
-	close(errorChan)
+	close(resultChan)
 
-	return errs
+	return results
 }
 
 func consolidateErrs(errs []error) error {
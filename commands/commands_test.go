@@ -9,6 +9,7 @@ import (
 	"github.com/docker/machine/commands/commandstest"
 	"github.com/docker/machine/drivers/fakedriver"
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/crashreport"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/hosttest"
@@ -223,6 +224,36 @@ func TestReturnExitCode3onErrorDuringPreCreate(t *testing.T) {
 	assert.Equal(t, 3, exitCode)
 }
 
+func TestReturnExitCode3ForDryRunCheck(t *testing.T) {
+	command := func(commandLine CommandLine, api libmachine.API) error {
+		return mcnerror.ErrDuringPreCreate{Cause: errors.New("quota reached")}
+	}
+
+	exitCode := checkErrorCodeForCommand(command)
+
+	assert.Equal(t, 3, exitCode)
+}
+
+func TestRunCommandExitStatus(t *testing.T) {
+	command := func(commandLine CommandLine, api libmachine.API) error {
+		return errExitStatus(3)
+	}
+
+	exitCode := checkErrorCodeForCommand(command)
+
+	assert.Equal(t, 3, exitCode)
+}
+
+func TestReturnExitCodeOfStatusWait(t *testing.T) {
+	command := func(commandLine CommandLine, api libmachine.API) error {
+		return errStatusWait{status: statusExitTimeout, err: errors.New("Error: timed out")}
+	}
+
+	exitCode := checkErrorCodeForCommand(command)
+
+	assert.Equal(t, statusExitTimeout, exitCode)
+}
+
 func checkErrorCodeForCommand(command func(commandLine CommandLine, api libmachine.API) error) int {
 	var setExitCode int
 
@@ -241,3 +272,67 @@ func checkErrorCodeForCommand(command func(commandLine CommandLine, api libmachi
 
 	return setExitCode
 }
+
+func TestConfigureCertGenerator(t *testing.T) {
+	err := configureCertGenerator(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"tls-ca-backend": "local"},
+		},
+	})
+	assert.NoError(t, err)
+
+	err = configureCertGenerator(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"tls-ca-backend": "acme"},
+		},
+	})
+	assert.EqualError(t, err, `Invalid TLS CA backend "acme", expected local or vault`)
+
+	err = configureCertGenerator(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"tls-ca-backend": "vault", "vault-role": "machine"},
+		},
+	})
+	assert.EqualError(t, err, "The address of Vault is required to sign the certificates with Vault, set it with --vault-addr or VAULT_ADDR")
+}
+
+func TestConfigureSSHSigner(t *testing.T) {
+	defer cert.SetSSHSigner(nil, cert.DefaultSSHCertificateTTL)
+
+	err := configureSSHSigner(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"ssh-ca-backend": "none"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, cert.SSHCertificatesEnabled())
+
+	err = configureSSHSigner(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"ssh-ca-backend": "acme"},
+		},
+	})
+	assert.EqualError(t, err, `Invalid SSH CA backend "acme", expected none, local or vault`)
+
+	err = configureSSHSigner(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"ssh-ca-backend": "local", "ssh-cert-ttl": "forever"},
+		},
+	})
+	assert.EqualError(t, err, `Invalid SSH certificate TTL "forever", expected a duration such as 1h`)
+
+	err = configureSSHSigner(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"ssh-ca-backend": "vault", "vault-addr": "https://vault:8200"},
+		},
+	})
+	assert.EqualError(t, err, "The role of the SSH secrets engine is required to sign the SSH keys with Vault, set it with --vault-ssh-role")
+
+	err = configureSSHSigner(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"ssh-ca-backend": "local", "ssh-cert-ttl": "30m"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, cert.SSHCertificatesEnabled())
+}
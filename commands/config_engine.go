@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errNoEngineSettings = errors.New("Error: Expected at least one of --engine-registry-mirror and --engine-insecure-registry, an empty value removes the setting")
+)
+
+func cmdConfigEngine(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	if !c.IsSet("engine-registry-mirror") && !c.IsSet("engine-insecure-registry") {
+		return errNoEngineSettings
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	if h.HostOptions == nil || h.HostOptions.EngineOptions == nil {
+		return fmt.Errorf("Error: %s has no engine options to configure", h.Name)
+	}
+
+	setEngineRegistries(c, h.HostOptions.EngineOptions)
+
+	if err := h.ConfigureEngineOptions(); err != nil {
+		return err
+	}
+
+	if err := api.Save(h); err != nil {
+		return err
+	}
+
+	log.Infof("The engine of %q was configured.", h.Name)
+
+	return nil
+}
+
+// setEngineRegistries replaces the registry settings given on the command
+// line, the others are kept.  The flags are repeated to give several values,
+// a single empty one removes them all.
+func setEngineRegistries(c CommandLine, options *engine.Options) {
+	if c.IsSet("engine-registry-mirror") {
+		options.RegistryMirror = nonEmpty(c.StringSlice("engine-registry-mirror"))
+	}
+	if c.IsSet("engine-insecure-registry") {
+		options.InsecureRegistry = nonEmpty(c.StringSlice("engine-insecure-registry"))
+	}
+}
+
+func nonEmpty(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
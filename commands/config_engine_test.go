@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdConfigEngineRequiresSettings(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs:    []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}
+
+	assert.Equal(t, errNoEngineSettings, cmdConfigEngine(commandLine, &libmachinetest.FakeAPI{}))
+}
+
+func TestSetEngineRegistries(t *testing.T) {
+	options := &engine.Options{
+		RegistryMirror:   []string{"https://old.example.com"},
+		InsecureRegistry: []string{"old.local:5000"},
+	}
+
+	setEngineRegistries(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"engine-registry-mirror": []string{"https://mirror.example.com", "https://other.example.com"},
+		}},
+	}, options)
+
+	assert.Equal(t, []string{"https://mirror.example.com", "https://other.example.com"}, options.RegistryMirror)
+	assert.Equal(t, []string{"old.local:5000"}, options.InsecureRegistry)
+
+	setEngineRegistries(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"engine-insecure-registry": []string{""},
+		}},
+	}, options)
+
+	assert.Equal(t, []string{}, options.InsecureRegistry)
+}
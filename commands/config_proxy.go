@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errNoProxySettings = errors.New("Error: Expected at least one of --http-proxy, --https-proxy and --no-proxy, an empty value removes the setting")
+)
+
+func cmdConfigProxy(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	if !c.IsSet("http-proxy") && !c.IsSet("https-proxy") && !c.IsSet("no-proxy") {
+		return errNoProxySettings
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	if h.HostOptions == nil || h.HostOptions.EngineOptions == nil {
+		return fmt.Errorf("Error: %s has no engine options to configure", h.Name)
+	}
+
+	setEngineProxy(c, h.HostOptions.EngineOptions)
+
+	if err := h.ConfigureEngineProxy(); err != nil {
+		return err
+	}
+
+	if err := api.Save(h); err != nil {
+		return err
+	}
+
+	log.Infof("The proxy of the engine of %q was configured.", h.Name)
+
+	return nil
+}
+
+// setEngineProxy replaces the proxy settings given on the command line, the
+// others are kept.
+func setEngineProxy(c CommandLine, options *engine.Options) {
+	if c.IsSet("http-proxy") {
+		options.HTTPProxy = c.String("http-proxy")
+	}
+	if c.IsSet("https-proxy") {
+		options.HTTPSProxy = c.String("https-proxy")
+	}
+	if c.IsSet("no-proxy") {
+		options.NoProxy = c.String("no-proxy")
+	}
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdConfigProxyRequiresSettings(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs:    []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}
+
+	assert.Equal(t, errNoProxySettings, cmdConfigProxy(commandLine, &libmachinetest.FakeAPI{}))
+}
+
+func TestSetEngineProxy(t *testing.T) {
+	options := &engine.Options{
+		HTTPProxy:  "http://old:3128",
+		HTTPSProxy: "http://old:3128",
+		NoProxy:    "localhost",
+	}
+
+	setEngineProxy(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"http-proxy": "http://proxy:3128",
+			"no-proxy":   "",
+		}},
+	}, options)
+
+	assert.Equal(t, "http://proxy:3128", options.HTTPProxy)
+	assert.Equal(t, "http://old:3128", options.HTTPSProxy)
+	assert.Equal(t, "", options.NoProxy)
+}
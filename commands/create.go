@@ -1,14 +1,18 @@
 package commands
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 
 	"errors"
 
@@ -18,21 +22,94 @@ import (
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/autoupdates"
+	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/crashreport"
+	"github.com/docker/machine/libmachine/diskcrypt"
+	"github.com/docker/machine/libmachine/dnshook"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/drivers/rpc"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/engine/validate"
+	"github.com/docker/machine/libmachine/firewall"
 	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/imagefilter"
+	"github.com/docker/machine/libmachine/k3s"
+	"github.com/docker/machine/libmachine/kubernetes"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/secrets"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/sshuser"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/swarmmode"
+	"github.com/docker/machine/libmachine/winrm"
 )
 
 var (
-	errNoMachineName = errors.New("Error: No machine name specified")
+	errNoMachineName                  = errors.New("Error: No machine name specified")
+	errExposePortWithoutFirewall      = errors.New("Error: --expose-port requires --provision-firewall")
+	errRebootWindowWithoutAutoUpdates = errors.New("Error: --provision-auto-updates-reboot-window requires --provision-auto-updates")
+	errK8sJoinWithoutKubernetes       = errors.New("Error: --k8s-join requires --kubernetes")
+
+	// sshProxyJumpFlags maps the drivers which can reach their machines
+	// through a bastion to their flag of the bastion.
+	sshProxyJumpFlags = map[string]string{
+		"amazonec2": "amazonec2-ssh-proxy-jump",
+		"generic":   "generic-ssh-proxy-jump",
+		"google":    "google-ssh-proxy-jump",
+		"openstack": "openstack-ssh-proxy-jump",
+	}
+
+	// networkFlags maps the drivers which can attach their machines to
+	// private networks to their flags of --private-network and --network.
+	networkFlags = map[string]networkFlag{
+		"amazonec2":    {network: "amazonec2-additional-subnet-ids"},
+		"digitalocean": {private: "digitalocean-private-networking", network: "digitalocean-vpc-uuid"},
+		"google":       {network: "google-additional-networks"},
+		"hetzner":      {network: "hetzner-networks", networkRequired: true},
+		"linode":       {private: "linode-private-ip"},
+		"oci":          {network: "oci-subnet-id"},
+		"openstack":    {network: "openstack-additional-networks"},
+	}
+
+	// autoSizeDrivers are the local drivers which size their machines from
+	// the resources of the host with --auto-size.
+	autoSizeDrivers = map[string]bool{
+		"hyperv":       true,
+		"kvm":          true,
+		"virtualbox":   true,
+		"vmwarefusion": true,
+	}
+
+	// imageFilterDrivers are the drivers which resolve the image of their
+	// machines with --image-filter.
+	imageFilterDrivers = map[string]bool{
+		"amazonec2": true,
+		"azure":     true,
+		"google":    true,
+		"openstack": true,
+	}
 )
 
+// networkFlag is how a driver attaches its machines to private networks.
+type networkFlag struct {
+	// private is the flag enabling the private network, empty if the
+	// machines are always on one, e.g. in a VPC
+	private string
+
+	// network is the flag of the networks attached to the machine
+	network string
+
+	// networkRequired is set if there's no private network unless one is
+	// given with --network
+	networkRequired bool
+}
+
 var (
 	SharedCreateFlags = []cli.Flag{
 		cli.StringFlag{
@@ -49,11 +126,21 @@ var (
 			Value:  drivers.DefaultEngineInstallURL,
 			EnvVar: "MACHINE_DOCKER_INSTALL_URL",
 		},
+		cli.StringFlag{
+			Name:   "engine-install-bundle",
+			Usage:  "Path or URL of a tarball of engine packages or static binaries to upload and install, for machines without internet access",
+			EnvVar: "MACHINE_ENGINE_INSTALL_BUNDLE",
+		},
 		cli.StringSliceFlag{
 			Name:  "engine-opt",
 			Usage: "Specify arbitrary flags to include with the created engine in the form flag=value",
 			Value: &cli.StringSlice{},
 		},
+		cli.StringFlag{
+			Name:   "engine-opt-file",
+			Usage:  "Specify a daemon.json to merge into the configuration of the created engine",
+			EnvVar: "MACHINE_ENGINE_OPT_FILE",
+		},
 		cli.StringSliceFlag{
 			Name:  "engine-insecure-registry",
 			Usage: "Specify insecure registries to allow with the created engine",
@@ -70,18 +157,46 @@ var (
 			Usage: "Specify labels for the created engine",
 			Value: &cli.StringSlice{},
 		},
+		cli.StringSliceFlag{
+			Name:  "machine-label",
+			Usage: "Label of the machine as key=value, selecting it with the label filter of ls and the other commands",
+			Value: &cli.StringSlice{},
+		},
 		cli.StringFlag{
 			Name:  "engine-storage-driver",
 			Usage: "Specify a storage driver to use with the engine",
 		},
 		cli.StringSliceFlag{
 			Name:  "engine-env",
-			Usage: "Specify environment variables to set in the engine",
+			Usage: "Specify environment variables to set in the engine, whose values may reference secrets with file://, vault:// or aws-sm://",
 			Value: &cli.StringSlice{},
 		},
+		cli.StringFlag{
+			Name:  "engine-http-proxy",
+			Usage: "Specify the HTTP proxy of the engine",
+		},
+		cli.StringFlag{
+			Name:  "engine-https-proxy",
+			Usage: "Specify the HTTPS proxy of the engine",
+		},
+		cli.StringFlag{
+			Name:  "engine-no-proxy",
+			Usage: "Specify the hosts the engine reaches without the proxy",
+		},
+		cli.StringFlag{
+			Name:   "container-runtime",
+			Usage:  "Container runtime to install on the machine: [docker, podman, containerd]",
+			Value:  engine.RuntimeDocker,
+			EnvVar: "MACHINE_CONTAINER_RUNTIME",
+		},
+		cli.BoolFlag{
+			Name:   "engine-rootless",
+			Usage:  "Run the engine as an unprivileged user (rootless mode)",
+			EnvVar: "MACHINE_ENGINE_ROOTLESS",
+		},
 		cli.BoolFlag{
 			Name:  "swarm",
-			Usage: "Configure Machine to join a Swarm cluster",
+			Usage: "Configure Machine to join a Swarm cluster (deprecated, use --swarm-mode-worker)",
 		},
 		cli.StringFlag{
 			Name:   "swarm-image",
@@ -91,7 +206,7 @@ var (
 		},
 		cli.BoolFlag{
 			Name:  "swarm-master",
-			Usage: "Configure Machine to be a Swarm master",
+			Usage: "Configure Machine to be a Swarm master (deprecated, use --swarm-mode-manager)",
 		},
 		cli.StringFlag{
 			Name:  "swarm-discovery",
@@ -127,6 +242,202 @@ var (
 			Name:  "swarm-experimental",
 			Usage: "Enable Swarm experimental features",
 		},
+		cli.BoolFlag{
+			Name:  "swarm-mode-manager",
+			Usage: "Make the engine a manager of a swarm in swarm mode, initialized unless --swarm-mode-join is given",
+		},
+		cli.BoolFlag{
+			Name:  "swarm-mode-worker",
+			Usage: "Make the engine a worker of the swarm of the manager given with --swarm-mode-join",
+		},
+		cli.StringFlag{
+			Name:  "swarm-mode-join",
+			Usage: "Name of the manager machine whose swarm is joined",
+		},
+		cli.BoolFlag{
+			Name:  "k3s-server",
+			Usage: "Install a k3s server on the machine",
+		},
+		cli.BoolFlag{
+			Name:  "k3s-agent",
+			Usage: "Install a k3s agent joining the server given with --k3s-url",
+		},
+		cli.StringFlag{
+			Name:  "k3s-url",
+			Usage: "URL of the k3s server joined by the agent, e.g. https://192.168.99.100:6443",
+		},
+		cli.StringFlag{
+			Name:   "k3s-token",
+			Usage:  "Token joining the k3s server (default: the token of the server if it was created with Machine)",
+			EnvVar: "K3S_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "k3s-install-url",
+			Usage:  "Custom URL to use for the k3s installation script",
+			Value:  k3s.DefaultInstallURL,
+			EnvVar: "MACHINE_K3S_INSTALL_URL",
+		},
+		cli.BoolFlag{
+			Name:  "kubernetes",
+			Usage: "Bootstrap a Kubernetes cluster with kubeadm, installing kubeadm, kubelet and containerd",
+		},
+		cli.StringFlag{
+			Name:  "k8s-role",
+			Usage: "Role of the machine in the Kubernetes cluster: control-plane or worker",
+			Value: kubernetes.RoleControlPlane,
+		},
+		cli.StringFlag{
+			Name:  "k8s-join",
+			Usage: "Name of the control plane machine whose Kubernetes cluster the worker joins",
+		},
+		cli.StringFlag{
+			Name:   "k8s-version",
+			Usage:  "Minor version of Kubernetes to install",
+			Value:  kubernetes.DefaultVersion,
+			EnvVar: "MACHINE_K8S_VERSION",
+		},
+		cli.StringFlag{
+			Name:  "k8s-cni-manifest",
+			Usage: "Manifest of the pod network applied by the control plane, empty for none",
+			Value: kubernetes.DefaultCNIManifest,
+		},
+		cli.StringFlag{
+			Name:  "provision-encrypt-data-disk",
+			Usage: "Block device encrypted with LUKS and mounted on /var/lib/docker, e.g. /dev/sdb",
+		},
+		cli.BoolFlag{
+			Name:  "provision-encrypt-data-disk-force",
+			Usage: "Encrypt the data disk even if it has a filesystem or a partition table, erasing its data",
+		},
+		cli.StringFlag{
+			Name:  "provision-encryption-key-source",
+			Usage: "Source of the key of the data disk: store (in the machine directory), prompt or kms",
+			Value: diskcrypt.KeySourceStore,
+		},
+		cli.StringFlag{
+			Name:   "provision-encryption-kms-command",
+			Usage:  "Local command printing the key of the data disk, e.g. a KMS or Vault client",
+			EnvVar: "MACHINE_ENCRYPTION_KMS_COMMAND",
+		},
+		cli.StringFlag{
+			Name:   "provision-gpu",
+			Usage:  "Install the driver and container runtime of the GPUs of the machine, and make it the default runtime: nvidia",
+			EnvVar: "MACHINE_PROVISION_GPU",
+		},
+		cli.StringFlag{
+			Name:   "provision-create-user",
+			Usage:  "Create a user with the SSH keys of the machine and passwordless sudo, and access the machine as this user",
+			EnvVar: "MACHINE_PROVISION_CREATE_USER",
+		},
+		cli.BoolFlag{
+			Name:   "provision-disable-root",
+			Usage:  "Disable the root login and the password authentication over SSH",
+			EnvVar: "MACHINE_PROVISION_DISABLE_ROOT",
+		},
+		cli.StringFlag{
+			Name:   "provision-firewall",
+			Usage:  "Allow only SSH, the engine and the ports of --expose-port in the firewall of the machine: auto, ufw, firewalld or nftables",
+			EnvVar: "MACHINE_PROVISION_FIREWALL",
+		},
+		cli.StringSliceFlag{
+			Name:  "expose-port",
+			Usage: "Port allowed by the firewall of --provision-firewall, e.g. 80, 8000-8100/tcp or 53/udp, repeated to allow several ports",
+			Value: &cli.StringSlice{},
+		},
+		cli.BoolFlag{
+			Name:   "provision-auto-updates",
+			Usage:  "Apply the security updates of the operating system automatically, with unattended-upgrades, dnf-automatic or apk",
+			EnvVar: "MACHINE_PROVISION_AUTO_UPDATES",
+		},
+		cli.StringFlag{
+			Name:   "provision-auto-updates-reboot-window",
+			Usage:  "Reboot the machine when its updates require it, every day at a time, e.g. 03:00, or on a day of the week, e.g. \"Sun 03:00\"",
+			EnvVar: "MACHINE_PROVISION_AUTO_UPDATES_REBOOT_WINDOW",
+		},
+		cli.StringFlag{
+			Name:   "provision-with",
+			Usage:  "Provision the machine with this registered provisioner instead of detecting it from its /etc/os-release, e.g. Debian",
+			EnvVar: "MACHINE_PROVISION_WITH",
+		},
+		cli.StringFlag{
+			Name:   "user-data",
+			Usage:  "cloud-init user data passed to the instance by the driver, e.g. file://cloud-init.yml",
+			EnvVar: "MACHINE_USER_DATA",
+		},
+		cli.StringFlag{
+			Name:   "ssh-proxy-jump",
+			Usage:  "Bastion host to connect to the machine through over SSH, as [user@]host[:port]",
+			EnvVar: "MACHINE_SSH_PROXY_JUMP",
+		},
+		cli.BoolFlag{
+			Name:   "private-network",
+			Usage:  "Attach the machine to a private network of the provider, whose address is printed by ip --private",
+			EnvVar: "MACHINE_PRIVATE_NETWORK",
+		},
+		cli.BoolFlag{
+			Name:   "use-ipv6",
+			Usage:  "Reach the machine over IPv6 when the driver gives it both an IPv4 and an IPv6 address",
+			EnvVar: "MACHINE_USE_IPV6",
+		},
+		cli.BoolFlag{
+			Name:   "auto-size",
+			Usage:  "Size the CPUs, memory and disk of the machines of the local drivers from the free resources of the host",
+			EnvVar: "MACHINE_AUTO_SIZE",
+		},
+		cli.StringFlag{
+			Name:   "image-filter",
+			Usage:  "Create the machine from the newest image matching the filter, e.g. \"name=ubuntu-22.04*,owner=099720109477\", instead of the image of the driver",
+			EnvVar: "MACHINE_IMAGE_FILTER",
+		},
+		cli.StringSliceFlag{
+			Name:  "network",
+			Usage: "Private network or subnet id to attach the machine to, repeated to attach several ones",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringFlag{
+			Name:   "winrm-user",
+			Usage:  "Provision a Windows Server machine over WinRM with this user",
+			EnvVar: "MACHINE_WINRM_USER",
+		},
+		cli.StringFlag{
+			Name:   "winrm-password",
+			Usage:  "Password of the WinRM user",
+			EnvVar: "MACHINE_WINRM_PASSWORD",
+		},
+		cli.IntFlag{
+			Name:  "winrm-port",
+			Usage: "Port of the WinRM service (default: 5986, or 5985 with --winrm-http)",
+		},
+		cli.BoolFlag{
+			Name:  "winrm-http",
+			Usage: "Connect to the WinRM service over HTTP instead of HTTPS",
+		},
+		cli.BoolFlag{
+			Name:  "winrm-insecure",
+			Usage: "Skip the verification of the certificate of the WinRM service",
+		},
+		cli.StringFlag{
+			Name:  "dns-provider",
+			Usage: "Register the machine in DNS once it has an IP address, with route53, cloudflare or google",
+		},
+		cli.StringFlag{
+			Name:  "dns-zone",
+			Usage: "DNS zone the machine is registered in as <machine>.<zone>, e.g. example.com",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Check the configuration against the driver and print the steps of the creation, without creating anything",
+		},
+		cli.IntFlag{
+			Name:  "count",
+			Usage: "Number of machines to create, the machine name can contain %d to number them",
+			Value: 1,
+		},
+		cli.IntFlag{
+			Name:  "parallel",
+			Usage: "Maximum number of machines created at the same time when using --count",
+			Value: createDefaultParallel,
+		},
 		cli.StringSliceFlag{
 			Name:  "tls-san",
 			Usage: "Support extra SANs for TLS certs",
@@ -146,6 +457,13 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		return errNoMachineName
 	}
 
+	if c.Int("count") > 1 || strings.Contains(name, "%d") {
+		if c.Bool("dry-run") {
+			return errors.New("Error: --dry-run can only plan the creation of one machine")
+		}
+		return cmdCreateMany(c, name)
+	}
+
 	validName := host.ValidateHostName(name)
 	if !validName {
 		return fmt.Errorf("Error creating machine: %s", mcnerror.ErrInvalidHostname)
@@ -155,17 +473,206 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		return fmt.Errorf("Error parsing swarm discovery: %s", err)
 	}
 
+	labels, err := host.ParseLabels(c.StringSlice("machine-label"))
+	if err != nil {
+		return err
+	}
+
+	dnsConfig, err := createDNSConfig(c.String("dns-provider"), c.String("dns-zone"))
+	if err != nil {
+		return err
+	}
+
+	if err := validateContainerRuntime(c.String("container-runtime"), c.Bool("swarm") || c.Bool("swarm-master")); err != nil {
+		return err
+	}
+
+	if c.Bool("engine-rootless") {
+		if err := validateRootless(c.String("container-runtime"), c.Bool("swarm") || c.Bool("swarm-master")); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("swarm") || c.Bool("swarm-master") {
+		log.Warn("The Swarm containers are deprecated, use --swarm-mode-manager or --swarm-mode-worker instead")
+	}
+
+	swarmModeOptions := &swarmmode.Options{
+		Manager: c.Bool("swarm-mode-manager"),
+		Worker:  c.Bool("swarm-mode-worker"),
+		Join:    c.String("swarm-mode-join"),
+	}
+
+	if swarmModeOptions.IsSwarmMode() {
+		if err := validateSwarmMode(swarmModeOptions, c.String("container-runtime"), c.Bool("engine-rootless"), c.Bool("swarm") || c.Bool("swarm-master")); err != nil {
+			return err
+		}
+
+		if swarmModeOptions.Join != "" {
+			if err := setSwarmModeJoin(api, swarmModeOptions); err != nil {
+				return err
+			}
+		}
+	}
+
+	k3sOptions := &k3s.Options{
+		Server:     c.Bool("k3s-server"),
+		Agent:      c.Bool("k3s-agent"),
+		URL:        c.String("k3s-url"),
+		Token:      c.String("k3s-token"),
+		InstallURL: c.String("k3s-install-url"),
+	}
+
+	if k3sOptions.IsK3s() {
+		if err := validateK3s(k3sOptions, c.Bool("swarm") || c.Bool("swarm-master") || swarmModeOptions.IsSwarmMode()); err != nil {
+			return err
+		}
+
+		if k3sOptions.Agent && k3sOptions.Token == "" {
+			if err := setK3sJoin(api, k3sOptions); err != nil {
+				return err
+			}
+		}
+	}
+
+	var daemonConfig json.RawMessage
+	if c.String("engine-opt-file") != "" {
+		content, err := readDaemonConfig(c.String("engine-opt-file"))
+		if err != nil {
+			return err
+		}
+		daemonConfig = content
+	}
+
+	var winrmOptions *winrm.Options
+	if c.String("winrm-user") != "" {
+		if err := validateWinRM(c.String("container-runtime"), c.Bool("engine-rootless"), c.Bool("swarm") || c.Bool("swarm-master"), k3sOptions.IsK3s()); err != nil {
+			return err
+		}
+
+		winrmOptions = &winrm.Options{
+			User:     c.String("winrm-user"),
+			Password: c.String("winrm-password"),
+			Port:     c.Int("winrm-port"),
+			HTTP:     c.Bool("winrm-http"),
+			Insecure: c.Bool("winrm-insecure"),
+		}
+	}
+
+	var dataDiskOptions *diskcrypt.Options
+	if c.String("provision-encrypt-data-disk") != "" {
+		dataDiskOptions = &diskcrypt.Options{
+			Device:     c.String("provision-encrypt-data-disk"),
+			KeySource:  c.String("provision-encryption-key-source"),
+			KMSCommand: c.String("provision-encryption-kms-command"),
+			Force:      c.Bool("provision-encrypt-data-disk-force"),
+		}
+
+		if err := validateDataDisk(dataDiskOptions, c.String("container-runtime"), c.Bool("engine-rootless"), winrmOptions != nil); err != nil {
+			return err
+		}
+	}
+
+	if c.String("provision-gpu") != "" {
+		if err := validateGPU(c.String("provision-gpu"), c.String("container-runtime"), c.Bool("engine-rootless"), winrmOptions != nil); err != nil {
+			return err
+		}
+	}
+
+	if err := validateEngineEnv(c.StringSlice("engine-env"), c.String("container-runtime"), c.Bool("engine-rootless"), winrmOptions != nil); err != nil {
+		return err
+	}
+
+	var sshUserOptions *sshuser.Options
+	if c.String("provision-create-user") != "" || c.Bool("provision-disable-root") {
+		sshUserOptions = &sshuser.Options{
+			User:        c.String("provision-create-user"),
+			DisableRoot: c.Bool("provision-disable-root"),
+		}
+
+		if err := validateSSHUser(sshUserOptions, c.Bool("engine-rootless"), winrmOptions != nil); err != nil {
+			return err
+		}
+	}
+
+	var firewallOptions *firewall.Options
+	if c.String("provision-firewall") != "" {
+		firewallOptions = &firewall.Options{
+			Backend: c.String("provision-firewall"),
+			Ports:   c.StringSlice("expose-port"),
+		}
+
+		if err := validateFirewall(firewallOptions, winrmOptions != nil); err != nil {
+			return err
+		}
+	} else if len(c.StringSlice("expose-port")) > 0 {
+		return errExposePortWithoutFirewall
+	}
+
+	var autoUpdatesOptions *autoupdates.Options
+	if c.Bool("provision-auto-updates") {
+		autoUpdatesOptions = &autoupdates.Options{
+			RebootWindow: c.String("provision-auto-updates-reboot-window"),
+		}
+
+		if err := validateAutoUpdates(autoUpdatesOptions, winrmOptions != nil); err != nil {
+			return err
+		}
+	} else if c.String("provision-auto-updates-reboot-window") != "" {
+		return errRebootWindowWithoutAutoUpdates
+	}
+
+	var kubernetesOptions *kubernetes.Options
+	if c.Bool("kubernetes") {
+		kubernetesOptions = &kubernetes.Options{
+			Role:        c.String("k8s-role"),
+			Join:        c.String("k8s-join"),
+			Version:     c.String("k8s-version"),
+			CNIManifest: c.String("k8s-cni-manifest"),
+		}
+
+		if err := validateKubernetes(kubernetesOptions, c.Bool("swarm") || c.Bool("swarm-master"), k3sOptions.IsK3s(), winrmOptions != nil); err != nil {
+			return err
+		}
+
+		if kubernetesOptions.Join != "" {
+			if err := setKubernetesJoin(api, kubernetesOptions); err != nil {
+				return err
+			}
+		}
+	} else if c.String("k8s-join") != "" {
+		return errK8sJoinWithoutKubernetes
+	}
+
+	if err := validateProvisionWith(c.String("provision-with"), winrmOptions != nil); err != nil {
+		return err
+	}
+
+	installBundle := c.String("engine-install-bundle")
+	if installBundle != "" {
+		bundle, err := validateInstallBundle(installBundle, c.String("container-runtime"), c.Bool("engine-rootless"), winrmOptions != nil)
+		if err != nil {
+			return err
+		}
+		installBundle = bundle
+	}
+
+	id, err := storeID(api)
+	if err != nil {
+		return err
+	}
+
 	// TODO: Fix hacky JSON solution
 	rawDriver, err := json.Marshal(&drivers.BaseDriver{
 		MachineName: name,
-		StorePath:   c.GlobalString("storage-path"),
+		StorePath:   mcndirs.GetBaseDir(),
+		StoreID:     id,
 	})
 	if err != nil {
 		return fmt.Errorf("Error attempting to marshal bare driver data: %s", err)
 	}
 
-	driverName := c.String("driver")
-	h, err := api.NewHost(driverName, rawDriver)
+	h, err := api.NewHost(driverName(c), rawDriver)
 	if err != nil {
 		return fmt.Errorf("Error getting new host: %s", err)
 	}
@@ -191,6 +698,14 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 			StorageDriver:    c.String("engine-storage-driver"),
 			TLSVerify:        true,
 			InstallURL:       c.String("engine-install-url"),
+			InstallBundle:    installBundle,
+			ContainerRuntime: c.String("container-runtime"),
+			Rootless:         c.Bool("engine-rootless"),
+			DaemonConfig:     daemonConfig,
+			HTTPProxy:        c.String("engine-http-proxy"),
+			HTTPSProxy:       c.String("engine-https-proxy"),
+			NoProxy:          c.String("engine-no-proxy"),
+			GPU:              c.String("provision-gpu"),
 		},
 		SwarmOptions: &swarm.Options{
 			IsSwarm:            c.Bool("swarm") || c.Bool("swarm-master"),
@@ -205,8 +720,31 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 			ArbitraryJoinFlags: c.StringSlice("swarm-join-opt"),
 			IsExperimental:     c.Bool("swarm-experimental"),
 		},
+		K3sOptions:         k3sOptions,
+		SwarmModeOptions:   swarmModeOptions,
+		KubernetesOptions:  kubernetesOptions,
+		WinRMOptions:       winrmOptions,
+		DataDiskOptions:    dataDiskOptions,
+		SSHUserOptions:     sshUserOptions,
+		FirewallOptions:    firewallOptions,
+		AutoUpdatesOptions: autoUpdatesOptions,
+		Provisioner:        c.String("provision-with"),
 	}
 
+	// The version of the engine isn't known before it's installed, the
+	// options depending on it are checked once it is.
+	if winrmOptions == nil {
+		if err := validate.Options(h.HostOptions.EngineOptions, validate.Target{}); err != nil {
+			return err
+		}
+	}
+
+	if h.HostOptions.EngineOptions.IsContainerd() {
+		h.EndpointType = host.EndpointContainerd
+	}
+
+	h.Labels = labels
+
 	exists, err := api.Exists(h.Name)
 	if err != nil {
 		return fmt.Errorf("Error checking if host exists: %s", err)
@@ -223,11 +761,56 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	mcnFlags := h.Driver.GetCreateFlags()
 	driverOpts := getDriverOpts(c, mcnFlags)
 
+	if err := checkDriverCapabilities(c, h); err != nil {
+		return err
+	}
+
+	if c.String("user-data") != "" {
+		if err := setUserData(h, driverOpts, c.String("user-data")); err != nil {
+			return err
+		}
+	}
+
+	if c.String("ssh-proxy-jump") != "" {
+		if err := setSSHProxyJump(h, driverOpts, c.String("ssh-proxy-jump")); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("private-network") || len(c.StringSlice("network")) > 0 {
+		if err := setNetworks(h, driverOpts, mcnFlags, c.Bool("private-network"), c.StringSlice("network")); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("auto-size") && !autoSizeDrivers[h.DriverName] {
+		return fmt.Errorf("Error: the %s driver doesn't support --auto-size", h.DriverName)
+	}
+
+	if c.String("image-filter") != "" {
+		if !imageFilterDrivers[h.DriverName] {
+			return fmt.Errorf("Error: the %s driver doesn't support --image-filter", h.DriverName)
+		}
+		if _, err := imagefilter.Parse(c.String("image-filter")); err != nil {
+			return err
+		}
+	}
+
 	if err := h.Driver.SetConfigFromFlags(driverOpts); err != nil {
 		return fmt.Errorf("Error setting machine configuration from flags provided: %s", err)
 	}
 
-	if err := api.Create(h); err != nil {
+	if c.Bool("dry-run") {
+		return printCreatePlan(c, h)
+	}
+
+	ctx, stopCancelOnInterrupt := cancelOnInterrupt()
+	err = api.CreateContext(ctx, h)
+	if canceled := stopCancelOnInterrupt(); canceled && err != nil {
+		return fmt.Errorf("The creation of %s was canceled: %s", name, err)
+	}
+
+	if err != nil {
 		// Wait for all the logs to reach the client
 		time.Sleep(2 * time.Second)
 
@@ -245,34 +828,172 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		}
 	}
 
+	if dnsConfig != nil {
+		registerDNS(h, dnsConfig)
+	}
+
+	if c.Bool("use-ipv6") && !c.Bool("private-network") {
+		if ip, err := h.Driver.GetIP(); err == nil && !mcnutils.IsIPv6(ip) {
+			log.Warnf("The machine has no IPv6 address, it's reached at %s", ip)
+		}
+	}
+
 	if err := api.Save(h); err != nil {
 		return fmt.Errorf("Error attempting to save store: %s", err)
 	}
 
+	syncInventories(api)
+
+	if isJSONOutput(c) {
+		return printCreatedJSON(h)
+	}
+
 	log.Infof("To see how to connect your Docker Client to the Docker Engine running on this virtual machine, run: %s env %s", os.Args[0], name)
 
 	return nil
 }
 
-// The following function is needed because the CLI acrobatics that we're doing
-// (with having an "outer" and "inner" function each with their own custom
-// settings and flag parsing needs) are not well supported by codegangsta/cli.
-//
-// Instead of trying to make a convoluted series of flag parsing and relying on
-// codegangsta/cli internals work well, we simply read the flags we're
-// interested in from the outer function into module-level variables, and then
-// use them from the "inner" function.
-//
-// I'm not very pleased about this, but it seems to be the only decent
-// compromise without drastically modifying codegangsta/cli internals or our
-// own CLI.
-func flagHackLookup(flagName string) string {
-	// e.g. "-d" for "--driver"
-	flagPrefix := flagName[1:3]
+// createDNSConfig returns where the machine is registered in DNS: the
+// provider and the zone of the flags override the ones of the DNS
+// configuration file.  It returns nil when the machine isn't registered.
+func createDNSConfig(provider, zone string) (*dnshook.Config, error) {
+	config := dnshook.Default()
+	if provider == "" && zone == "" {
+		return config, nil
+	}
 
-	// TODO: Should we support -flag-name (single hyphen) syntax as well?
-	for i, arg := range os.Args {
-		if strings.Contains(arg, flagPrefix) {
+	merged := &dnshook.Config{}
+	if config != nil {
+		*merged = *config
+	}
+	if provider != "" && provider != merged.Provider {
+		// The credentials of the file are for another provider
+		merged = &dnshook.Config{Provider: provider, Zone: merged.Zone}
+	}
+	if zone != "" {
+		merged.Zone = zone
+	}
+
+	if err := merged.Validate(); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// registerDNS registers the machine in DNS.  The machine was created, so a
+// failure is only reported.
+func registerDNS(h *host.Host, config *dnshook.Config) {
+	ip, err := h.Driver.GetIP()
+	if err != nil {
+		log.Warnf("Error registering %s in DNS, its IP address isn't known: %s", h.Name, err)
+		return
+	}
+
+	record, err := dnshook.Register(config, h.Name, ip)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+
+	log.Infof("Registered %s as %s in DNS", h.Name, record.Name)
+	h.DNSRecord = record
+}
+
+// printCreatePlan prints the steps the creation of the machine would run,
+// once the driver checked that it can be created.
+func printCreatePlan(c CommandLine, h *host.Host) error {
+	plan, err := libmachine.PlanCreate(h)
+	if err != nil {
+		return err
+	}
+
+	if isJSONOutput(c) {
+		return printJSON(PlanItem{
+			Name:       h.Name,
+			DriverName: h.DriverName,
+			Steps:      plan,
+		})
+	}
+
+	fmt.Printf("Creating %s with the %s driver would:\n", h.Name, h.DriverName)
+	for _, step := range plan {
+		fmt.Printf("  %-11s %s\n", step.Phase, step.Description)
+	}
+
+	log.Info("Nothing was created, this was a dry run.")
+
+	return nil
+}
+
+// cancelOnInterrupt returns a context canceled when the command is
+// interrupted, so that the creation of the machine stops and removes what was
+// already created instead of leaving it behind.  It exits on a second
+// interrupt.  The returned function stops handling the interrupts and returns
+// whether the context was canceled.
+func cancelOnInterrupt() (context.Context, func() bool) {
+	var (
+		interrupts  = make(chan os.Signal, 1)
+		done        = make(chan bool)
+		lock        = &sync.Mutex{}
+		canceled    = false
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	signal.Notify(interrupts, os.Interrupt)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-interrupts:
+			}
+
+			lock.Lock()
+			alreadyCanceled := canceled
+			canceled = true
+			lock.Unlock()
+
+			if alreadyCanceled {
+				os.Exit(130)
+			}
+
+			log.Info("Canceling the creation of the machine, interrupt again to exit now...")
+			cancel()
+		}
+	}()
+
+	return ctx, func() bool {
+		signal.Stop(interrupts)
+		close(done)
+		cancel()
+
+		lock.Lock()
+		defer lock.Unlock()
+		return canceled
+	}
+}
+
+// The following function is needed because the CLI acrobatics that we're doing
+// (with having an "outer" and "inner" function each with their own custom
+// settings and flag parsing needs) are not well supported by codegangsta/cli.
+//
+// Instead of trying to make a convoluted series of flag parsing and relying on
+// codegangsta/cli internals work well, we simply read the flags we're
+// interested in from the outer function into module-level variables, and then
+// use them from the "inner" function.
+//
+// I'm not very pleased about this, but it seems to be the only decent
+// compromise without drastically modifying codegangsta/cli internals or our
+// own CLI.
+func flagHackLookup(flagName string) string {
+	// e.g. "-d" for "--driver"
+	flagPrefix := flagName[1:3]
+
+	// TODO: Should we support -flag-name (single hyphen) syntax as well?
+	for i, arg := range os.Args {
+		if strings.Contains(arg, flagPrefix) {
 			// format '--driver foo' or '-d foo'
 			if arg == flagPrefix || arg == flagName {
 				if i+1 < len(os.Args) {
@@ -290,7 +1011,24 @@ func flagHackLookup(flagName string) string {
 	return ""
 }
 
+// storeID returns the identity of the store, which the drivers tag the
+// resources of the machines with, or "" for a store which has none.
+func storeID(api libmachine.API) (string, error) {
+	store, ok := api.(persist.IdentifiedStore)
+	if !ok {
+		return "", nil
+	}
+	return store.StoreID()
+}
+
 func cmdCreateOuter(c CommandLine, api libmachine.API) error {
+	return runWithDriverFlags(c, api, "create", SharedCreateFlags, cmdCreateInner)
+}
+
+// runWithDriverFlags runs the command again with the flags of the driver given
+// by --driver on top of its own flags, for the commands which talk to the
+// provider without a machine, e.g. create and gc.
+func runWithDriverFlags(c CommandLine, api libmachine.API, name string, flags []cli.Flag, inner func(CommandLine, libmachine.API) error) error {
 	const (
 		flagLookupMachineName = "flag-lookup"
 	)
@@ -300,6 +1038,9 @@ func cmdCreateOuter(c CommandLine, api libmachine.API) error {
 	if driverName == "" {
 		//TODO: Check Environment have to include flagHackLookup function.
 		driverName = os.Getenv("MACHINE_DRIVER")
+		if driverName == "" && activeProfile != nil {
+			driverName = activeProfile.Driver
+		}
 		if driverName == "" {
 			c.ShowHelp()
 			return nil // ?
@@ -335,8 +1076,8 @@ func cmdCreateOuter(c CommandLine, api libmachine.API) error {
 
 	for i := range c.Application().Commands {
 		cmd := &c.Application().Commands[i]
-		if cmd.HasName("create") {
-			cmd = addDriverFlagsToCommand(cliFlags, cmd)
+		if cmd.HasName(name) {
+			cmd = addDriverFlagsToCommand(cliFlags, cmd, flags, inner)
 		}
 	}
 
@@ -350,18 +1091,7 @@ func getDriverOpts(c CommandLine, mcnflags []mcnflag.Flag) drivers.DriverOptions
 	// But, we need it so that we can actually send the flags for creating
 	// a machine over the wire (cli.Context is a no go since there is so
 	// much stuff in it).
-	driverOpts := rpcdriver.RPCFlags{
-		Values: make(map[string]interface{}),
-	}
-
-	for _, f := range mcnflags {
-		driverOpts.Values[f.String()] = f.Default()
-
-		// Hardcoded logic for boolean... :(
-		if f.Default() == nil {
-			driverOpts.Values[f.String()] = false
-		}
-	}
+	driverOpts := defaultDriverOpts(mcnflags)
 
 	for _, name := range c.FlagNames() {
 		getter, ok := c.Generic(name).(flag.Getter)
@@ -377,6 +1107,27 @@ func getDriverOpts(c CommandLine, mcnflags []mcnflag.Flag) drivers.DriverOptions
 		}
 	}
 
+	setProfileDriverOpts(c, mcnflags, driverOpts)
+
+	return driverOpts
+}
+
+// defaultDriverOpts returns the driver options with the default values of
+// the flags of the driver.
+func defaultDriverOpts(mcnflags []mcnflag.Flag) rpcdriver.RPCFlags {
+	driverOpts := rpcdriver.RPCFlags{
+		Values: make(map[string]interface{}),
+	}
+
+	for _, f := range mcnflags {
+		driverOpts.Values[f.String()] = f.Default()
+
+		// Hardcoded logic for boolean... :(
+		if f.Default() == nil {
+			driverOpts.Values[f.String()] = false
+		}
+	}
+
 	return driverOpts
 }
 
@@ -429,10 +1180,10 @@ func convertMcnFlagsToCliFlags(mcnFlags []mcnflag.Flag) ([]cli.Flag, error) {
 	return cliFlags, nil
 }
 
-func addDriverFlagsToCommand(cliFlags []cli.Flag, cmd *cli.Command) *cli.Command {
-	cmd.Flags = append(SharedCreateFlags, cliFlags...)
+func addDriverFlagsToCommand(cliFlags []cli.Flag, cmd *cli.Command, flags []cli.Flag, inner func(CommandLine, libmachine.API) error) *cli.Command {
+	cmd.Flags = append(flags, cliFlags...)
 	cmd.SkipFlagParsing = false
-	cmd.Action = runCommand(cmdCreateInner)
+	cmd.Action = runCommand(inner)
 	sort.Sort(ByFlagName(cmd.Flags))
 
 	return cmd
@@ -455,6 +1206,463 @@ func validateSwarmDiscovery(discovery string) error {
 	return fmt.Errorf("Swarm Discovery URL was in the wrong format: %s", discovery)
 }
 
+func validateContainerRuntime(runtime string, isSwarm bool) error {
+	switch runtime {
+	case "", engine.RuntimeDocker:
+		return nil
+	case engine.RuntimePodman, engine.RuntimeContainerd:
+		if isSwarm {
+			return fmt.Errorf("Error: Swarm is not supported with the %s container runtime", runtime)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Error: Unknown container runtime %q, expected one of: [%s, %s, %s]", runtime, engine.RuntimeDocker, engine.RuntimePodman, engine.RuntimeContainerd)
+}
+
+// checkDriverCapabilities fails before anything is created if the machine
+// is created with features its driver doesn't support.
+func checkDriverCapabilities(c CommandLine, h *host.Host) error {
+	capabilities := drivers.GetCapabilities(h.Driver)
+
+	unsupported := []string{}
+	if c.String("user-data") != "" && !capabilities.UserData {
+		unsupported = append(unsupported, "--user-data")
+	}
+	if (c.Bool("private-network") || len(c.StringSlice("network")) > 0) && !capabilities.PrivateNetworking {
+		unsupported = append(unsupported, "--private-network and --network")
+	}
+	if c.Bool("use-ipv6") && !capabilities.IPv6 {
+		unsupported = append(unsupported, "--use-ipv6")
+	}
+
+	if len(unsupported) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Error: the %s driver doesn't support %s, see its features with: %s driver-info %s", h.DriverName, strings.Join(unsupported, ", "), os.Args[0], h.DriverName)
+}
+
+// setUserData renders the file given with --user-data into the directory of
+// the machine, and gives it to the driver with its own user data flag.
+func setUserData(h *host.Host, driverOpts drivers.DriverOptions, userData string) error {
+	flagName, ok := host.UserDataFlag(h.DriverName)
+	if !ok {
+		return fmt.Errorf("Error: the %s driver doesn't support --user-data", h.DriverName)
+	}
+
+	if driverOpts.String(flagName) != "" {
+		return fmt.Errorf("Error: --user-data and --%s can't be used together", flagName)
+	}
+
+	content, err := ioutil.ReadFile(strings.TrimPrefix(userData, "file://"))
+	if err != nil {
+		return fmt.Errorf("Error reading the user data: %s", err)
+	}
+
+	// The CA certificate is a variable of the template
+	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
+		return fmt.Errorf("Error generating certificates: %s", err)
+	}
+
+	content, err = h.RenderUserData(content)
+	if err != nil {
+		return err
+	}
+
+	storePath := h.AuthOptions().StorePath
+	if err := os.MkdirAll(storePath, 0700); err != nil {
+		return err
+	}
+
+	userDataPath := filepath.Join(storePath, "user-data")
+	if err := ioutil.WriteFile(userDataPath, content, 0600); err != nil {
+		return err
+	}
+
+	driverOpts.(rpcdriver.RPCFlags).Values[flagName] = userDataPath
+
+	return nil
+}
+
+// setSSHProxyJump passes the bastion given with --ssh-proxy-jump to the
+// driver as its own flag.
+func setSSHProxyJump(h *host.Host, driverOpts drivers.DriverOptions, proxyJump string) error {
+	flagName, ok := sshProxyJumpFlags[h.DriverName]
+	if !ok {
+		return fmt.Errorf("Error: the %s driver doesn't support --ssh-proxy-jump", h.DriverName)
+	}
+
+	if driverOpts.String(flagName) != "" {
+		return fmt.Errorf("Error: --ssh-proxy-jump and --%s can't be used together", flagName)
+	}
+
+	if _, err := ssh.ParseProxyJump(proxyJump, drivers.DefaultSSHUser); err != nil {
+		return err
+	}
+
+	driverOpts.(rpcdriver.RPCFlags).Values[flagName] = proxyJump
+
+	return nil
+}
+
+// setNetworks sets the flags of the driver attaching the machine to the
+// private networks given with --private-network and --network.
+func setNetworks(h *host.Host, driverOpts drivers.DriverOptions, mcnFlags []mcnflag.Flag, private bool, networks []string) error {
+	flags, ok := networkFlags[h.DriverName]
+	if !ok {
+		return fmt.Errorf("Error: the %s driver doesn't support --private-network and --network", h.DriverName)
+	}
+
+	values := driverOpts.(rpcdriver.RPCFlags).Values
+
+	if private && flags.networkRequired && len(networks) == 0 {
+		return fmt.Errorf("Error: the %s driver has no default private network, give one with --network", h.DriverName)
+	}
+	if private && flags.private != "" {
+		values[flags.private] = true
+	}
+
+	if len(networks) == 0 {
+		return nil
+	}
+
+	switch value := values[flags.network].(type) {
+	case string:
+		if value != "" {
+			return fmt.Errorf("Error: --network and --%s can't be used together", flags.network)
+		}
+	case []string:
+		if len(value) > 0 {
+			return fmt.Errorf("Error: --network and --%s can't be used together", flags.network)
+		}
+	}
+
+	for _, f := range mcnFlags {
+		if f.String() != flags.network {
+			continue
+		}
+
+		if _, ok := f.(mcnflag.StringSliceFlag); ok {
+			values[flags.network] = networks
+			return nil
+		}
+
+		if len(networks) > 1 {
+			return fmt.Errorf("Error: the %s driver attaches its machines to a single private network", h.DriverName)
+		}
+		values[flags.network] = networks[0]
+		return nil
+	}
+
+	return fmt.Errorf("Error: the %s driver doesn't support --network", h.DriverName)
+}
+
+// readDaemonConfig reads the daemon.json given with --engine-opt-file.
+func readDaemonConfig(path string) (json.RawMessage, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the engine configuration file: %s", err)
+	}
+
+	if err := provision.ValidateDaemonConfig(content); err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(content), nil
+}
+
+// validateRootless checks that a rootless engine can be used with the other
+// options of the machine.
+func validateRootless(runtime string, isSwarm bool) error {
+	if runtime == engine.RuntimePodman || runtime == engine.RuntimeContainerd {
+		return errors.New("Error: --engine-rootless only applies to the docker container runtime")
+	}
+
+	if isSwarm {
+		return errors.New("Error: Swarm is not supported with a rootless engine")
+	}
+
+	return nil
+}
+
+// validateK3s checks the k3s options given on the command line.
+func validateK3s(options *k3s.Options, isSwarm bool) error {
+	if isSwarm {
+		return errors.New("Error: Swarm and k3s are mutually exclusive")
+	}
+
+	return options.Validate()
+}
+
+// validateKubernetes checks the Kubernetes options given on the command
+// line: kubeadm runs on the Linux machines which run neither k3s nor the
+// Swarm containers.
+func validateKubernetes(options *kubernetes.Options, isSwarm, isK3s, isWinRM bool) error {
+	if isSwarm {
+		return errors.New("Error: Swarm and Kubernetes are mutually exclusive")
+	}
+
+	if isK3s {
+		return errors.New("Error: k3s and Kubernetes are mutually exclusive")
+	}
+
+	if isWinRM {
+		return errors.New("Error: Kubernetes is not supported on Windows machines")
+	}
+
+	return options.Validate()
+}
+
+// validateSwarmMode checks the swarm mode options given on the command line:
+// only the Docker engine running as root can be a node.
+func validateSwarmMode(options *swarmmode.Options, runtime string, rootless, isSwarm bool) error {
+	if isSwarm {
+		return errors.New("Error: The Swarm containers and swarm mode are mutually exclusive")
+	}
+
+	if runtime == engine.RuntimePodman || runtime == engine.RuntimeContainerd {
+		return fmt.Errorf("Error: Swarm mode is not supported with the %s container runtime", runtime)
+	}
+
+	if rootless {
+		return errors.New("Error: Swarm mode is not supported with a rootless engine")
+	}
+
+	return options.Validate()
+}
+
+// validateWinRM checks that a Windows machine can be used with the other
+// options of the machine: only the Docker engine is installed on Windows.
+func validateWinRM(runtime string, rootless, isSwarm, isK3s bool) error {
+	if runtime == engine.RuntimePodman || runtime == engine.RuntimeContainerd {
+		return fmt.Errorf("Error: The %s container runtime is not supported on Windows machines", runtime)
+	}
+
+	if rootless {
+		return errors.New("Error: --engine-rootless is not supported on Windows machines")
+	}
+
+	if isSwarm {
+		return errors.New("Error: Swarm is not supported on Windows machines")
+	}
+
+	if isK3s {
+		return errors.New("Error: k3s is not supported on Windows machines")
+	}
+
+	return nil
+}
+
+// validateDataDisk checks that the data disk can be encrypted with the other
+// options of the machine: only the /var/lib/docker directory of the docker
+// service is moved to the disk.
+func validateDataDisk(options *diskcrypt.Options, runtime string, rootless, isWinRM bool) error {
+	if runtime == engine.RuntimePodman || runtime == engine.RuntimeContainerd {
+		return fmt.Errorf("Error: The data disk can't be encrypted with the %s container runtime", runtime)
+	}
+
+	if rootless {
+		return errors.New("Error: The data disk can't be encrypted with a rootless engine")
+	}
+
+	if isWinRM {
+		return errors.New("Error: The data disk can't be encrypted on Windows machines")
+	}
+
+	return options.Validate()
+}
+
+// validateGPU checks that the driver of the GPUs can be installed with the
+// other options of the machine: the NVIDIA runtime is only made the default
+// one of the docker service.
+func validateGPU(gpu, runtime string, rootless, isWinRM bool) error {
+	if gpu != engine.GPUNvidia {
+		return fmt.Errorf("Error: Unsupported GPU %q, the only one supported is %s", gpu, engine.GPUNvidia)
+	}
+
+	if runtime == engine.RuntimePodman || runtime == engine.RuntimeContainerd {
+		return fmt.Errorf("Error: --provision-gpu is not supported with the %s container runtime", runtime)
+	}
+
+	if rootless {
+		return errors.New("Error: --provision-gpu is not supported with a rootless engine")
+	}
+
+	if isWinRM {
+		return errors.New("Error: --provision-gpu is not supported on Windows machines")
+	}
+
+	return nil
+}
+
+// validateEngineEnv checks the references to secrets of the engine
+// environment, which are written to a file of the docker service.
+func validateEngineEnv(env []string, runtime string, rootless, isWinRM bool) error {
+	secretEnv := engine.Options{Env: env}.SecretEnv()
+	if len(secretEnv) == 0 {
+		return nil
+	}
+
+	for _, e := range secretEnv {
+		if _, err := secrets.Parse(strings.SplitN(e, "=", 2)[1]); err != nil {
+			return fmt.Errorf("Error: %s", err)
+		}
+	}
+
+	if runtime == engine.RuntimePodman || runtime == engine.RuntimeContainerd {
+		return fmt.Errorf("Error: secrets in --engine-env are not supported with the %s container runtime", runtime)
+	}
+
+	if rootless {
+		return errors.New("Error: secrets in --engine-env are not supported with a rootless engine")
+	}
+
+	if isWinRM {
+		return errors.New("Error: secrets in --engine-env are not supported on Windows machines")
+	}
+
+	return nil
+}
+
+// validateSSHUser checks that the SSH access of the machine can be
+// configured with the other options.
+func validateSSHUser(options *sshuser.Options, rootless, isWinRM bool) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	// The rootless engine runs as the user of the image
+	if rootless && options.User != "" {
+		return errors.New("Error: --provision-create-user is not supported with a rootless engine")
+	}
+
+	if isWinRM {
+		return errors.New("Error: the SSH access can't be configured on Windows machines")
+	}
+
+	return nil
+}
+
+// validateFirewall checks that the firewall of the machine can be
+// configured with the other options.
+func validateFirewall(options *firewall.Options, isWinRM bool) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	if isWinRM {
+		return errors.New("Error: --provision-firewall is not supported on Windows machines")
+	}
+
+	return nil
+}
+
+// validateAutoUpdates checks that the security updates of the machine can
+// be applied automatically with the other options.
+func validateAutoUpdates(options *autoupdates.Options, isWinRM bool) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	if isWinRM {
+		return errors.New("Error: --provision-auto-updates is not supported on Windows machines")
+	}
+
+	return nil
+}
+
+// validateProvisionWith checks that the provisioner forced with
+// --provision-with is registered.
+func validateProvisionWith(name string, isWinRM bool) error {
+	if name == "" {
+		return nil
+	}
+
+	if isWinRM {
+		return errors.New("Error: --provision-with is not supported on Windows machines")
+	}
+
+	if !provision.IsRegistered(name) {
+		return fmt.Errorf("Error: unknown provisioner %q given to --provision-with, expected one of: %s", name, strings.Join(provision.ProvisionerNames(), ", "))
+	}
+
+	return nil
+}
+
+// validateInstallBundle checks that the engine can be installed from the
+// bundle and returns its absolute path, kept to provision the machine
+// again, unless it's a URL.
+func validateInstallBundle(bundle, runtime string, rootless, isWinRM bool) (string, error) {
+	if runtime == engine.RuntimePodman || runtime == engine.RuntimeContainerd {
+		return "", fmt.Errorf("Error: --engine-install-bundle is not supported with the %s container runtime", runtime)
+	}
+
+	if rootless {
+		return "", errors.New("Error: --engine-install-bundle is not supported with a rootless engine")
+	}
+
+	if isWinRM {
+		return "", errors.New("Error: --engine-install-bundle is not supported on Windows machines")
+	}
+
+	if strings.HasPrefix(bundle, "http://") || strings.HasPrefix(bundle, "https://") {
+		return bundle, nil
+	}
+
+	if _, err := os.Stat(bundle); err != nil {
+		return "", fmt.Errorf("Error reading the engine install bundle: %s", err)
+	}
+
+	return filepath.Abs(bundle)
+}
+
+// setK3sJoin reads the join token on the k3s server with the URL joined by
+// the agent, among the machines of the store.
+func setK3sJoin(api libmachine.API, options *k3s.Options) error {
+	names, err := api.List()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		h, err := api.Load(name)
+		if err != nil {
+			log.Debugf("error loading %s while looking for the k3s server: %s", name, err)
+			continue
+		}
+
+		server := h.HostOptions.K3sOptions
+		if server.IsK3s() && server.Server && server.URL == options.URL {
+			return options.SetJoin(server, h.RunSSHCommand)
+		}
+	}
+
+	return k3s.ErrNoJoinToken
+}
+
+// setSwarmModeJoin sets the address and the join token of the manager machine
+// whose swarm is joined.
+func setSwarmModeJoin(api libmachine.API, options *swarmmode.Options) error {
+	manager, err := api.Load(options.Join)
+	if err != nil {
+		return fmt.Errorf("Error loading the swarm mode manager %s: %s", options.Join, err)
+	}
+
+	return options.SetJoin(manager.HostOptions.SwarmModeOptions)
+}
+
+// setKubernetesJoin creates a join token on the control plane machine joined
+// by the worker.
+func setKubernetesJoin(api libmachine.API, options *kubernetes.Options) error {
+	controlPlane, err := api.Load(options.Join)
+	if err != nil {
+		return fmt.Errorf("Error loading the Kubernetes control plane %s: %s", options.Join, err)
+	}
+
+	return options.SetJoin(controlPlane.HostOptions.KubernetesOptions, controlPlane.RunSSHCommand)
+}
+
 func tlsPath(c CommandLine, flag string, defaultName string) string {
 	path := c.GlobalString(flag)
 	if path != "" {
@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/pkg/term"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+const (
+	createDefaultParallel = 5
+)
+
+var (
+	errInvalidCount = errors.New("Error: --count must be greater than zero")
+)
+
+// expandMachineNames returns the names of the machines to create when a
+// count is given. A name containing "%d" is used as a pattern, otherwise the
+// index is appended to the name.
+func expandMachineNames(pattern string, count int) ([]string, error) {
+	if count <= 0 {
+		return nil, errInvalidCount
+	}
+
+	names := []string{}
+	for i := 1; i <= count; i++ {
+		var name string
+		if strings.Contains(pattern, "%d") {
+			name = strings.Replace(pattern, "%d", fmt.Sprint(i), -1)
+		} else {
+			name = fmt.Sprintf("%s-%d", pattern, i)
+		}
+
+		if !host.ValidateHostName(name) {
+			return nil, fmt.Errorf("Error creating machine %q: %s", name, mcnerror.ErrInvalidHostname)
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// childCreateArgs rewrites the arguments of the current invocation into the
+// ones used to create a single machine: the fleet flags are removed and the
+// machine name (the last positional argument) is replaced.
+func childCreateArgs(args []string, pattern, name string) []string {
+	childArgs := []string{}
+	nameIndex := -1
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--count" || arg == "-count" || arg == "--parallel" || arg == "-parallel":
+			i++
+			continue
+		case strings.HasPrefix(arg, "--count=") || strings.HasPrefix(arg, "-count=") ||
+			strings.HasPrefix(arg, "--parallel=") || strings.HasPrefix(arg, "-parallel="):
+			continue
+		case arg == pattern:
+			nameIndex = len(childArgs)
+		}
+		childArgs = append(childArgs, arg)
+	}
+
+	if nameIndex >= 0 {
+		childArgs[nameIndex] = name
+	}
+
+	return childArgs
+}
+
+// childEnv returns the environment of the current process without the
+// variables which would make the child start as a driver plugin server.
+func childEnv() []string {
+	env := []string{}
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, localbinary.PluginEnvKey+"=") || strings.HasPrefix(e, localbinary.PluginEnvDriverName+"=") {
+			continue
+		}
+		env = append(env, e)
+	}
+	return env
+}
+
+// prefixWriter serializes the output of the concurrent creations and
+// prefixes each line with the name of the machine it belongs to.
+type prefixWriter struct {
+	lock *sync.Mutex
+	out  io.Writer
+}
+
+func (pw *prefixWriter) copyLines(name string, r io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		pw.lock.Lock()
+		fmt.Fprintf(pw.out, "(%s) %s\n", name, scanner.Text())
+		pw.lock.Unlock()
+	}
+}
+
+// lineCopier copies the output of the creation of a machine.
+type lineCopier interface {
+	copyLines(name string, r io.Reader, wg *sync.WaitGroup)
+}
+
+func createOne(name string, args []string, copier lineCopier) error {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = childEnv()
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go copier.copyLines(name, stdout, wg)
+	go copier.copyLines(name, stderr, wg)
+	wg.Wait()
+
+	return cmd.Wait()
+}
+
+// cmdCreateMany creates several machines concurrently by running one
+// "create" per machine, at most parallel at a time.
+func cmdCreateMany(c CommandLine, pattern string) error {
+	names, err := expandMachineNames(pattern, c.Int("count"))
+	if err != nil {
+		return err
+	}
+
+	parallel := c.Int("parallel")
+	if parallel <= 0 {
+		parallel = createDefaultParallel
+	}
+
+	log.Infof("Creating %d machines, %d at a time...", len(names), parallel)
+
+	// The output of the children is only informative when printing JSON,
+	// stdout is kept for the report.
+	out := io.Writer(os.Stdout)
+	if isJSONOutput(c) {
+		out = os.Stderr
+	}
+
+	// On a terminal, the progress of the machines is shown as a table
+	// updated in place, from the JSON entries logged by the children.
+	var (
+		copier    lineCopier = &prefixWriter{lock: &sync.Mutex{}, out: out}
+		board     *progressBoard
+		childArgs = os.Args[1:]
+	)
+	if !isJSONOutput(c) && c.GlobalString("log-format") != log.FormatJSON && term.IsTerminal(os.Stdout.Fd()) {
+		board = newProgressBoard(out, names)
+		copier = board
+		childArgs = append([]string{"--log-format", log.FormatJSON}, childArgs...)
+	}
+
+	var (
+		nameCh   = make(chan string)
+		errsLock = &sync.Mutex{}
+		errs     = map[string]error{}
+		wg       = &sync.WaitGroup{}
+	)
+
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range nameCh {
+				err := createOne(name, childCreateArgs(childArgs, pattern, name), copier)
+				if board != nil {
+					board.finish(name, err)
+				}
+				if err != nil {
+					errsLock.Lock()
+					errs[name] = err
+					errsLock.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, name := range names {
+		nameCh <- name
+	}
+	close(nameCh)
+	wg.Wait()
+
+	if isJSONOutput(c) {
+		return createManyJSONReport(names, errs)
+	}
+
+	return createManyReport(names, errs)
+}
+
+func createManyJSONReport(names []string, errs map[string]error) error {
+	items := []CreateItem{}
+	for _, name := range names {
+		item := CreateItem{
+			Name: name,
+		}
+		if err, ok := errs[name]; ok {
+			jsonErr := newJSONError(err)
+			item.Error = &jsonErr
+		}
+		items = append(items, item)
+	}
+
+	if err := printJSON(items); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errJSONReported
+	}
+
+	return nil
+}
+
+func createManyReport(names []string, errs map[string]error) error {
+	if len(errs) == 0 {
+		log.Infof("All %d machines were created.", len(names))
+		return nil
+	}
+
+	failures := []error{}
+	for _, name := range names {
+		if err, ok := errs[name]; ok {
+			failures = append(failures, fmt.Errorf("%s: %s", name, err))
+		}
+	}
+
+	log.Infof("%d of %d machines were created.", len(names)-len(failures), len(names))
+
+	return fmt.Errorf("Error creating %d machine(s):\n%s", len(failures), consolidateErrs(failures))
+}
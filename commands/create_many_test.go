@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandMachineNamesWithPattern(t *testing.T) {
+	names, err := expandMachineNames("node-%d-dev", 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-1-dev", "node-2-dev", "node-3-dev"}, names)
+}
+
+func TestExpandMachineNamesWithoutPattern(t *testing.T) {
+	names, err := expandMachineNames("node", 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-1", "node-2"}, names)
+}
+
+func TestExpandMachineNamesInvalid(t *testing.T) {
+	_, err := expandMachineNames("node", 0)
+	assert.Equal(t, errInvalidCount, err)
+
+	_, err = expandMachineNames("no_de", 2)
+	assert.Error(t, err)
+}
+
+func TestChildCreateArgs(t *testing.T) {
+	args := []string{"create", "-d", "virtualbox", "--count", "3", "--parallel=2", "--virtualbox-memory", "2048", "node-%d"}
+
+	childArgs := childCreateArgs(args, "node-%d", "node-2")
+
+	assert.Equal(t, []string{"create", "-d", "virtualbox", "--virtualbox-memory", "2048", "node-2"}, childArgs)
+}
+
+func TestCreateManyReport(t *testing.T) {
+	names := []string{"node-1", "node-2", "node-3"}
+
+	assert.NoError(t, createManyReport(names, map[string]error{}))
+
+	err := createManyReport(names, map[string]error{
+		"node-3": errors.New("boom"),
+		"node-1": errors.New("bang"),
+	})
+
+	assert.EqualError(t, err, "Error creating 2 machine(s):\nnode-1: bang\nnode-3: boom")
+}
+
+func TestEntryStatus(t *testing.T) {
+	status, ok := entryStatus(`{"level":"info","msg":"Creating machine...","machine":"node-1"}`)
+	assert.True(t, ok)
+	assert.Equal(t, "Creating machine...", status)
+
+	status, ok = entryStatus(`{"level":"info","msg":"Downloading (50%)","progress":"Downloading","percent":50}`)
+	assert.True(t, ok)
+	assert.Equal(t, "Downloading [##########          ]  50%", status)
+
+	status, ok = entryStatus(`{"level":"info","msg":"Booting...","progress":"Booting","percent":-1}`)
+	assert.True(t, ok)
+	assert.Equal(t, "Booting...", status)
+
+	status, ok = entryStatus(`{"level":"error","msg":"boom"}`)
+	assert.True(t, ok)
+	assert.Equal(t, "Error: boom", status)
+
+	_, ok = entryStatus(`{"level":"debug","msg":"ssh output"}`)
+	assert.False(t, ok)
+
+	status, ok = entryStatus("plain output")
+	assert.True(t, ok)
+	assert.Equal(t, "plain output", status)
+}
+
+func TestProgressBoardRedraws(t *testing.T) {
+	out := &bytes.Buffer{}
+	board := newProgressBoard(out, []string{"node-1", "node-2"})
+
+	board.update("node-2", "Creating machine...")
+	board.finish("node-1", nil)
+
+	assert.Equal(t, "\x1b[2Knode-1   Waiting...\n"+
+		"\x1b[2Knode-2   Creating machine...\n"+
+		"\x1b[2A"+
+		"\x1b[2Knode-1   Created\n"+
+		"\x1b[2Knode-2   Creating machine...\n", out.String())
+}
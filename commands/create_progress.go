@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// progressStatusWidth is the width the status of a machine is truncated to.
+const progressStatusWidth = 72
+
+// progressBoard renders the progress of the concurrent creations as a table
+// with a line per machine, redrawn in place on a terminal each time one of
+// them moves on.  The children creating the machines log JSON entries, which
+// the board turns into the status of their machine.
+type progressBoard struct {
+	lock     *sync.Mutex
+	out      io.Writer
+	names    []string
+	statuses map[string]string
+	drawn    int
+}
+
+func newProgressBoard(out io.Writer, names []string) *progressBoard {
+	statuses := map[string]string{}
+	for _, name := range names {
+		statuses[name] = "Waiting..."
+	}
+
+	return &progressBoard{
+		lock:     &sync.Mutex{},
+		out:      out,
+		names:    names,
+		statuses: statuses,
+	}
+}
+
+// entryStatus returns the status of a machine given by a log entry of the
+// child creating it, and whether the entry changes it.
+func entryStatus(line string) (string, bool) {
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		// Not an entry, e.g. the output of a driver
+		return line, strings.TrimSpace(line) != ""
+	}
+
+	if entry["level"] == log.DebugLevel.String() {
+		return "", false
+	}
+
+	message, _ := entry["msg"].(string)
+	if entry["level"] == log.ErrorLevel.String() {
+		return "Error: " + message, true
+	}
+
+	phase, ok := entry[libmachine.ProgressField].(string)
+	if !ok {
+		return message, message != ""
+	}
+
+	if percent, ok := entry[libmachine.PercentField].(float64); ok && percent >= 0 {
+		return fmt.Sprintf("%s %s", phase, progressBar(int(percent))), true
+	}
+
+	return phase + "...", true
+}
+
+// progressBar renders a completion percentage, e.g. [#####     ]  50%.
+func progressBar(percent int) string {
+	if percent > 100 {
+		percent = 100
+	}
+
+	const width = 20
+	filled := percent * width / 100
+
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat(" ", width-filled), percent)
+}
+
+func (b *progressBoard) copyLines(name string, r io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if status, ok := entryStatus(scanner.Text()); ok {
+			b.update(name, status)
+		}
+	}
+}
+
+func (b *progressBoard) update(name, status string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// A line wrapped by the terminal would shift the board
+	if len(status) > progressStatusWidth {
+		status = status[:progressStatusWidth-3] + "..."
+	}
+
+	b.statuses[name] = status
+	b.draw()
+}
+
+// finish sets the final status of a machine.
+func (b *progressBoard) finish(name string, err error) {
+	if err != nil {
+		b.update(name, fmt.Sprintf("Failed: %s", err))
+		return
+	}
+
+	b.update(name, "Created")
+}
+
+// draw moves the cursor back to the first line of the board, and writes it
+// again over the previous one.
+func (b *progressBoard) draw() {
+	if b.drawn > 0 {
+		fmt.Fprintf(b.out, "\x1b[%dA", b.drawn)
+	}
+
+	w := tabwriter.NewWriter(b.out, 5, 1, 3, ' ', 0)
+	for _, name := range b.names {
+		fmt.Fprintf(w, "\x1b[2K%s\t%s\n", name, b.statuses[name])
+	}
+	w.Flush()
+
+	b.drawn = len(b.names)
+}
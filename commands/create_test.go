@@ -1,11 +1,34 @@
 package commands
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"flag"
 	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/autoupdates"
+	"github.com/docker/machine/libmachine/diskcrypt"
+	"github.com/docker/machine/libmachine/dnshook"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/firewall"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/k3s"
+	"github.com/docker/machine/libmachine/kubernetes"
+	"github.com/docker/machine/libmachine/libmachinetest"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/ssh/sshtest"
+	"github.com/docker/machine/libmachine/sshuser"
+	"github.com/docker/machine/libmachine/swarmmode"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +47,231 @@ func TestValidateSwarmDiscoveryAcceptsValidFormat(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestValidateContainerRuntime(t *testing.T) {
+	assert.NoError(t, validateContainerRuntime("", false))
+	assert.NoError(t, validateContainerRuntime("docker", true))
+	assert.NoError(t, validateContainerRuntime("podman", false))
+	assert.Error(t, validateContainerRuntime("podman", true))
+	assert.NoError(t, validateContainerRuntime("containerd", false))
+	assert.Error(t, validateContainerRuntime("containerd", true))
+	assert.Error(t, validateContainerRuntime("rkt", false))
+}
+
+func TestValidateRootless(t *testing.T) {
+	assert.NoError(t, validateRootless("docker", false))
+	assert.Error(t, validateRootless("docker", true))
+	assert.Error(t, validateRootless("podman", false))
+	assert.Error(t, validateRootless("containerd", false))
+}
+
+func TestValidateK3s(t *testing.T) {
+	assert.NoError(t, validateK3s(&k3s.Options{Server: true}, false))
+	assert.Error(t, validateK3s(&k3s.Options{Server: true}, true))
+	assert.Equal(t, k3s.ErrNoServerURL, validateK3s(&k3s.Options{Agent: true}, false))
+}
+
+func TestValidateDataDisk(t *testing.T) {
+	options := &diskcrypt.Options{Device: "/dev/sdb", KeySource: diskcrypt.KeySourceStore}
+
+	assert.NoError(t, validateDataDisk(options, "docker", false, false))
+	assert.Error(t, validateDataDisk(options, "podman", false, false))
+	assert.Error(t, validateDataDisk(options, "docker", true, false))
+	assert.Error(t, validateDataDisk(options, "docker", false, true))
+	assert.Equal(t, diskcrypt.ErrNoKMSCommand, validateDataDisk(&diskcrypt.Options{Device: "/dev/sdb", KeySource: diskcrypt.KeySourceKMS}, "docker", false, false))
+}
+
+func TestValidateGPU(t *testing.T) {
+	assert.NoError(t, validateGPU("nvidia", "docker", false, false))
+	assert.EqualError(t, validateGPU("amd", "docker", false, false), `Error: Unsupported GPU "amd", the only one supported is nvidia`)
+	assert.Error(t, validateGPU("nvidia", "podman", false, false))
+	assert.Error(t, validateGPU("nvidia", "docker", true, false))
+	assert.Error(t, validateGPU("nvidia", "docker", false, true))
+}
+
+func TestValidateEngineEnv(t *testing.T) {
+	assert.NoError(t, validateEngineEnv([]string{"A=b"}, "podman", true, true))
+	assert.NoError(t, validateEngineEnv([]string{"A=b", "PASSWORD=vault://secret/data/registry#password"}, "docker", false, false))
+	assert.EqualError(t, validateEngineEnv([]string{"PASSWORD=aws-sm://"}, "docker", false, false), `Error: The reference to a secret "aws-sm://" has no path`)
+	assert.Error(t, validateEngineEnv([]string{"PASSWORD=file:///run/password"}, "containerd", false, false))
+	assert.Error(t, validateEngineEnv([]string{"PASSWORD=file:///run/password"}, "docker", true, false))
+	assert.Error(t, validateEngineEnv([]string{"PASSWORD=file:///run/password"}, "docker", false, true))
+}
+
+func TestValidateFirewall(t *testing.T) {
+	assert.NoError(t, validateFirewall(&firewall.Options{Backend: "auto", Ports: []string{"80", "53/udp"}}, false))
+	assert.Equal(t, firewall.ErrUnknownBackend, validateFirewall(&firewall.Options{Backend: "iptables"}, false))
+	assert.Error(t, validateFirewall(&firewall.Options{Backend: "ufw", Ports: []string{"http"}}, false))
+	assert.Error(t, validateFirewall(&firewall.Options{Backend: "ufw"}, true))
+}
+
+func TestValidateAutoUpdates(t *testing.T) {
+	assert.NoError(t, validateAutoUpdates(&autoupdates.Options{}, false))
+	assert.NoError(t, validateAutoUpdates(&autoupdates.Options{RebootWindow: "Sun 03:00"}, false))
+	assert.Equal(t, autoupdates.ErrInvalidRebootWindow, validateAutoUpdates(&autoupdates.Options{RebootWindow: "Sunday"}, false))
+	assert.Error(t, validateAutoUpdates(&autoupdates.Options{}, true))
+}
+
+func TestValidateProvisionWith(t *testing.T) {
+	assert.NoError(t, validateProvisionWith("", false))
+	assert.NoError(t, validateProvisionWith("debian", false))
+	assert.Error(t, validateProvisionWith("Debian", true))
+	assert.EqualError(t, validateProvisionWith("plan9", false), `Error: unknown provisioner "plan9" given to --provision-with, expected one of: `+strings.Join(provision.ProvisionerNames(), ", "))
+}
+
+func TestValidateSSHUser(t *testing.T) {
+	assert.NoError(t, validateSSHUser(&sshuser.Options{User: "machine", DisableRoot: true}, false, false))
+	assert.NoError(t, validateSSHUser(&sshuser.Options{DisableRoot: true}, true, false))
+	assert.Equal(t, sshuser.ErrInvalidUser, validateSSHUser(&sshuser.Options{User: "root"}, false, false))
+	assert.Error(t, validateSSHUser(&sshuser.Options{User: "machine"}, true, false))
+	assert.Error(t, validateSSHUser(&sshuser.Options{User: "machine"}, false, true))
+}
+
+func TestValidateInstallBundle(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	bundle := filepath.Join(tmpDir, "docker.tgz")
+	assert.NoError(t, ioutil.WriteFile(bundle, []byte("tarball"), 0644))
+
+	path, err := validateInstallBundle(bundle, "docker", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, path)
+
+	path, err = validateInstallBundle("https://mirror.local/docker.tgz", "docker", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://mirror.local/docker.tgz", path)
+
+	_, err = validateInstallBundle(filepath.Join(tmpDir, "missing.tgz"), "docker", false, false)
+	assert.Error(t, err)
+
+	_, err = validateInstallBundle(bundle, "containerd", false, false)
+	assert.EqualError(t, err, "Error: --engine-install-bundle is not supported with the containerd container runtime")
+
+	_, err = validateInstallBundle(bundle, "docker", true, false)
+	assert.Error(t, err)
+
+	_, err = validateInstallBundle(bundle, "docker", false, true)
+	assert.Error(t, err)
+}
+
+func TestValidateWinRM(t *testing.T) {
+	assert.NoError(t, validateWinRM("docker", false, false, false))
+	assert.Error(t, validateWinRM("podman", false, false, false))
+	assert.Error(t, validateWinRM("containerd", false, false, false))
+	assert.Error(t, validateWinRM("docker", true, false, false))
+	assert.Error(t, validateWinRM("docker", false, true, false))
+	assert.Error(t, validateWinRM("docker", false, false, true))
+}
+
+// sshClientCreator creates the same SSH client for every machine.
+type sshClientCreator struct {
+	client ssh.Client
+}
+
+func (c *sshClientCreator) CreateSSHClient(d drivers.Driver) (ssh.Client, error) {
+	return c.client, nil
+}
+
+func TestSetK3sJoin(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:        "swarm",
+				HostOptions: &host.Options{},
+			},
+			{
+				Name: "server",
+				HostOptions: &host.Options{
+					K3sOptions: &k3s.Options{Server: true, URL: "https://10.0.0.1:6443"},
+				},
+				SSHClientCreator: &sshClientCreator{&sshtest.FakeClient{
+					Outputs: map[string]sshtest.CmdResult{
+						"sudo cat /var/lib/rancher/k3s/server/node-token": {Out: "K10::server:secret\n"},
+					},
+				}},
+			},
+		},
+	}
+
+	options := &k3s.Options{Agent: true, URL: "https://10.0.0.1:6443"}
+	assert.NoError(t, setK3sJoin(api, options))
+	assert.Equal(t, "K10::server:secret", options.Token)
+
+	assert.Equal(t, k3s.ErrNoJoinToken, setK3sJoin(api, &k3s.Options{Agent: true, URL: "https://10.0.0.2:6443"}))
+}
+
+func TestValidateSwarmMode(t *testing.T) {
+	assert.NoError(t, validateSwarmMode(&swarmmode.Options{Manager: true}, "docker", false, false))
+	assert.NoError(t, validateSwarmMode(&swarmmode.Options{Worker: true, Join: "manager"}, "docker", false, false))
+	assert.Equal(t, swarmmode.ErrNoJoin, validateSwarmMode(&swarmmode.Options{Worker: true}, "docker", false, false))
+	assert.Error(t, validateSwarmMode(&swarmmode.Options{Manager: true}, "docker", false, true))
+	assert.Error(t, validateSwarmMode(&swarmmode.Options{Manager: true}, "podman", false, false))
+	assert.Error(t, validateSwarmMode(&swarmmode.Options{Manager: true}, "docker", true, false))
+}
+
+func TestSetSwarmModeJoin(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:        "standalone",
+				HostOptions: &host.Options{},
+			},
+			{
+				Name: "manager",
+				HostOptions: &host.Options{
+					SwarmModeOptions: &swarmmode.Options{Manager: true, Addr: "10.0.0.1:2377", WorkerToken: "SWMTKN-worker", ManagerToken: "SWMTKN-manager"},
+				},
+			},
+		},
+	}
+
+	worker := &swarmmode.Options{Worker: true, Join: "manager"}
+	assert.NoError(t, setSwarmModeJoin(api, worker))
+	assert.Equal(t, "10.0.0.1:2377", worker.RemoteAddr)
+	assert.Equal(t, "SWMTKN-worker", worker.JoinToken)
+
+	manager := &swarmmode.Options{Manager: true, Join: "manager"}
+	assert.NoError(t, setSwarmModeJoin(api, manager))
+	assert.Equal(t, "SWMTKN-manager", manager.JoinToken)
+
+	assert.Equal(t, swarmmode.ErrNoJoinToken, setSwarmModeJoin(api, &swarmmode.Options{Worker: true, Join: "standalone"}))
+	assert.Error(t, setSwarmModeJoin(api, &swarmmode.Options{Worker: true, Join: "unknown"}))
+}
+
+func TestValidateKubernetes(t *testing.T) {
+	controlPlane := &kubernetes.Options{Role: kubernetes.RoleControlPlane, Version: "v1.30"}
+
+	assert.NoError(t, validateKubernetes(controlPlane, false, false, false))
+	assert.Equal(t, kubernetes.ErrNoJoin, validateKubernetes(&kubernetes.Options{Role: kubernetes.RoleWorker, Version: "v1.30"}, false, false, false))
+	assert.EqualError(t, validateKubernetes(controlPlane, true, false, false), "Error: Swarm and Kubernetes are mutually exclusive")
+	assert.EqualError(t, validateKubernetes(controlPlane, false, true, false), "Error: k3s and Kubernetes are mutually exclusive")
+	assert.EqualError(t, validateKubernetes(controlPlane, false, false, true), "Error: Kubernetes is not supported on Windows machines")
+}
+
+func TestSetKubernetesJoin(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:        "standalone",
+				HostOptions: &host.Options{},
+			},
+			{
+				Name: "cp",
+				HostOptions: &host.Options{
+					KubernetesOptions: &kubernetes.Options{Role: kubernetes.RoleWorker, Join: "cp0"},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, kubernetes.ErrNotControlPlane, setKubernetesJoin(api, &kubernetes.Options{Role: kubernetes.RoleWorker, Join: "standalone"}))
+	assert.Equal(t, kubernetes.ErrNotControlPlane, setKubernetesJoin(api, &kubernetes.Options{Role: kubernetes.RoleWorker, Join: "cp"}))
+	assert.Error(t, setKubernetesJoin(api, &kubernetes.Options{Role: kubernetes.RoleWorker, Join: "unknown"}))
+}
+
 type fakeFlagGetter struct {
 	flag.Value
 	value interface{}
@@ -117,3 +365,242 @@ func TestGetDriverOpts(t *testing.T) {
 		assert.Equal(t, tt.expected["stringslice_defaulted"], driverOpts.StringSlice("stringslice_defaulted"))
 	}
 }
+
+func TestReadDaemonConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	valid := filepath.Join(dir, "daemon.json")
+	assert.NoError(t, ioutil.WriteFile(valid, []byte(`{"features": {"buildkit": true}}`), 0644))
+	content, err := readDaemonConfig(valid)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"features": {"buildkit": true}}`, string(content))
+
+	reserved := filepath.Join(dir, "hosts.json")
+	assert.NoError(t, ioutil.WriteFile(reserved, []byte(`{"hosts": ["tcp://0.0.0.0:2375"]}`), 0644))
+	_, err = readDaemonConfig(reserved)
+	assert.Error(t, err)
+
+	_, err = readDaemonConfig(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+// ipv6Driver reaches its machines over IPv6, like digitalocean.
+type ipv6Driver struct {
+	*fakedriver.Driver
+}
+
+func (d *ipv6Driver) Capabilities() drivers.Capabilities {
+	return drivers.Capabilities{IPv6: true}
+}
+
+func TestCheckDriverCapabilities(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"user-data":       "cloud-init.yml",
+				"private-network": false,
+				"network":         []string{"vpc"},
+				"use-ipv6":        true,
+			},
+		},
+	}
+
+	h := &host.Host{Name: "dev", DriverName: "virtualbox", Driver: &fakedriver.Driver{}}
+
+	err := checkDriverCapabilities(commandLine, h)
+
+	assert.EqualError(t, err, "Error: the virtualbox driver doesn't support --user-data, --private-network and --network, --use-ipv6, see its features with: "+os.Args[0]+" driver-info virtualbox")
+
+	commandLine.LocalFlags = &commandstest.FakeFlagger{Data: map[string]interface{}{"use-ipv6": true}}
+	h = &host.Host{Name: "dev", DriverName: "vultr", Driver: &ipv6Driver{&fakedriver.Driver{}}}
+
+	assert.NoError(t, checkDriverCapabilities(commandLine, h))
+}
+
+func TestSetUserDataUnsupportedDriver(t *testing.T) {
+	h := &host.Host{Name: "dev", DriverName: "virtualbox"}
+	driverOpts := rpcdriver.RPCFlags{Values: map[string]interface{}{}}
+
+	err := setUserData(h, driverOpts, "file://cloud-init.yml")
+
+	assert.EqualError(t, err, "Error: the virtualbox driver doesn't support --user-data")
+}
+
+func TestSetUserDataConflictingFlag(t *testing.T) {
+	h := &host.Host{Name: "dev", DriverName: "digitalocean"}
+	driverOpts := rpcdriver.RPCFlags{Values: map[string]interface{}{"digitalocean-userdata": "cloud-init.yml"}}
+
+	err := setUserData(h, driverOpts, "file://cloud-init.yml")
+
+	assert.EqualError(t, err, "Error: --user-data and --digitalocean-userdata can't be used together")
+}
+
+func TestSetSSHProxyJump(t *testing.T) {
+	h := &host.Host{Name: "dev", DriverName: "amazonec2"}
+	driverOpts := rpcdriver.RPCFlags{Values: map[string]interface{}{}}
+
+	err := setSSHProxyJump(h, driverOpts, "ec2-user@bastion.example.com:2222")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ec2-user@bastion.example.com:2222", driverOpts.String("amazonec2-ssh-proxy-jump"))
+}
+
+func TestSetSSHProxyJumpUnsupportedDriver(t *testing.T) {
+	h := &host.Host{Name: "dev", DriverName: "virtualbox"}
+	driverOpts := rpcdriver.RPCFlags{Values: map[string]interface{}{}}
+
+	err := setSSHProxyJump(h, driverOpts, "bastion.example.com")
+
+	assert.EqualError(t, err, "Error: the virtualbox driver doesn't support --ssh-proxy-jump")
+}
+
+func TestSetNetworks(t *testing.T) {
+	mcnFlags := []mcnflag.Flag{
+		mcnflag.StringSliceFlag{Name: "amazonec2-additional-subnet-ids"},
+		mcnflag.BoolFlag{Name: "digitalocean-private-networking"},
+		mcnflag.StringFlag{Name: "digitalocean-vpc-uuid"},
+	}
+
+	h := &host.Host{Name: "dev", DriverName: "amazonec2"}
+	driverOpts := rpcdriver.RPCFlags{Values: map[string]interface{}{}}
+
+	err := setNetworks(h, driverOpts, mcnFlags, true, []string{"subnet-a", "subnet-b"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"subnet-a", "subnet-b"}, driverOpts.StringSlice("amazonec2-additional-subnet-ids"))
+
+	h = &host.Host{Name: "dev", DriverName: "digitalocean"}
+	driverOpts = rpcdriver.RPCFlags{Values: map[string]interface{}{}}
+
+	err = setNetworks(h, driverOpts, mcnFlags, true, []string{"vpc"})
+
+	assert.NoError(t, err)
+	assert.True(t, driverOpts.Bool("digitalocean-private-networking"))
+	assert.Equal(t, "vpc", driverOpts.String("digitalocean-vpc-uuid"))
+}
+
+func TestSetNetworksErrors(t *testing.T) {
+	mcnFlags := []mcnflag.Flag{
+		mcnflag.StringSliceFlag{Name: "amazonec2-additional-subnet-ids"},
+		mcnflag.StringFlag{Name: "digitalocean-vpc-uuid"},
+	}
+
+	tests := []struct {
+		driverName string
+		values     map[string]interface{}
+		private    bool
+		networks   []string
+		err        string
+	}{
+		{"virtualbox", map[string]interface{}{}, true, nil, "Error: the virtualbox driver doesn't support --private-network and --network"},
+		{"hetzner", map[string]interface{}{}, true, nil, "Error: the hetzner driver has no default private network, give one with --network"},
+		{"digitalocean", map[string]interface{}{}, false, []string{"vpc-a", "vpc-b"}, "Error: the digitalocean driver attaches its machines to a single private network"},
+		{"amazonec2", map[string]interface{}{"amazonec2-additional-subnet-ids": []string{"subnet-a"}}, false, []string{"subnet-b"}, "Error: --network and --amazonec2-additional-subnet-ids can't be used together"},
+	}
+
+	for _, test := range tests {
+		h := &host.Host{Name: "dev", DriverName: test.driverName}
+
+		err := setNetworks(h, rpcdriver.RPCFlags{Values: test.values}, mcnFlags, test.private, test.networks)
+
+		assert.EqualError(t, err, test.err)
+	}
+}
+
+func TestCancelOnInterrupt(t *testing.T) {
+	ctx, stop := cancelOnInterrupt()
+
+	p, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	if err := p.Signal(os.Interrupt); err != nil {
+		stop()
+		t.Skipf("Unable to interrupt the test: %s", err)
+	}
+
+	<-ctx.Done()
+	assert.True(t, stop())
+}
+
+func TestCancelOnInterruptNotInterrupted(t *testing.T) {
+	ctx, stop := cancelOnInterrupt()
+
+	assert.NoError(t, ctx.Err())
+	assert.False(t, stop())
+}
+
+func TestCreateDNSConfig(t *testing.T) {
+	defer dnshook.SetDefault(nil)
+
+	config, err := createDNSConfig("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	config, err = createDNSConfig("route53", "example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, &dnshook.Config{Provider: "route53", Zone: "example.com"}, config)
+
+	_, err = createDNSConfig("route53", "")
+	assert.Error(t, err)
+
+	_, err = createDNSConfig("bind", "example.com")
+	assert.Error(t, err)
+
+	dnshook.SetDefault(&dnshook.Config{Provider: "cloudflare", Zone: "example.com", Token: "secret", TTL: 60})
+
+	config, err = createDNSConfig("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", config.Zone)
+
+	config, err = createDNSConfig("", "dev.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, &dnshook.Config{Provider: "cloudflare", Zone: "dev.example.com", Token: "secret", TTL: 60}, config)
+
+	config, err = createDNSConfig("google", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &dnshook.Config{Provider: "google", Zone: "example.com"}, config)
+}
+
+// newHostRecorder records the configuration of the driver of the machine
+// being created, and stops the creation there.
+type newHostRecorder struct {
+	*libmachinetest.FakeAPI
+	rawDriver []byte
+}
+
+func (api *newHostRecorder) NewHost(driverName string, rawDriver []byte) (*host.Host, error) {
+	api.rawDriver = rawDriver
+	return nil, errors.New("not created")
+}
+
+func TestCreateUnderProfile(t *testing.T) {
+	defer withProfilesPath(t)()
+	defer func(original string) { mcndirs.BaseDir = original }(mcndirs.BaseDir)
+
+	p := &profiles{Profiles: []*Profile{{Name: "work", StoragePath: "/tmp/work"}}}
+	assert.NoError(t, p.save())
+
+	globalFlags := &commandstest.FakeFlagger{
+		Data: map[string]interface{}{"profile": "work", "storage-path": mcndirs.GetDefaultBaseDir()},
+	}
+
+	// As the commands do before running
+	storagePath, _, err := selectProfile(&commandstest.FakeCommandLine{GlobalFlags: globalFlags})
+	assert.NoError(t, err)
+	mcndirs.BaseDir = storagePath
+
+	api := &newHostRecorder{FakeAPI: &libmachinetest.FakeAPI{}}
+	err = cmdCreateInner(&commandstest.FakeCommandLine{
+		CliArgs:     []string{"dev"},
+		LocalFlags:  &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+		GlobalFlags: globalFlags,
+	}, api)
+
+	assert.EqualError(t, err, "Error getting new host: not created")
+
+	driver := &drivers.BaseDriver{}
+	assert.NoError(t, json.Unmarshal(api.rawDriver, driver))
+	assert.Equal(t, "dev", driver.MachineName)
+	assert.Equal(t, "/tmp/work", driver.StorePath)
+}
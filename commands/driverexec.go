@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// cmdDriverExec runs an operation of the provider of a machine its driver
+// exposes, given as driver-exec <name> -- <operation> [json-args], or lists
+// them without an operation.
+func cmdDriverExec(c CommandLine, api libmachine.API) error {
+	args := []string(c.Args())
+	if len(args) > 1 && args[1] == "--" {
+		args = append(args[:1:1], args[2:]...)
+	}
+	if len(args) > 3 {
+		return ErrTooManyArguments
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	if !drivers.GetCapabilities(h.Driver).RawOperations {
+		return fmt.Errorf("Error: the %s driver has no operations of its provider to run, see its features with: %s driver-info %s", h.DriverName, os.Args[0], h.DriverName)
+	}
+
+	if len(args) < 2 {
+		operations, err := drivers.RawOperations(h.Driver)
+		if err != nil {
+			return err
+		}
+		return printRawOperations(c, operations)
+	}
+
+	name := args[1]
+	rawArgs := []byte("{}")
+	if len(args) == 3 {
+		rawArgs = []byte(args[2])
+		if !json.Valid(rawArgs) {
+			return fmt.Errorf("Error: the arguments of %s must be JSON, e.g. '{\"VolumeId\": \"vol-0123\"}'", name)
+		}
+	}
+
+	result, err := drivers.RunRawOperation(h.Driver, name, rawArgs)
+	if err != nil {
+		return fmt.Errorf("Error running %s on %s: %s", name, h.Name, err)
+	}
+
+	// The driver keeps what the operation changed, e.g. the security groups
+	// of the instance, in its configuration
+	if err := api.Save(h); err != nil {
+		return err
+	}
+
+	log.Infof("Ran %s on %q.", name, h.Name)
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, result, "", "    "); err != nil {
+		return fmt.Errorf("Error reading the result of %s: %s", name, err)
+	}
+	fmt.Println(out.String())
+
+	return nil
+}
+
+func printRawOperations(c CommandLine, operations []drivers.RawOperation) error {
+	if isJSONOutput(c) {
+		return printJSON(operations)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "OPERATION\tUSAGE")
+	for _, operation := range operations {
+		fmt.Fprintf(w, "%s\t%s\n", operation.Name, operation.Usage)
+	}
+	return nil
+}
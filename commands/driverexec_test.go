@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRawDriver struct {
+	fakedriver.Driver
+	ran  string
+	args string
+}
+
+func (d *fakeRawDriver) RawOperations() []drivers.RawOperation {
+	return []drivers.RawOperation{{Name: "attach-volume", Usage: "Attach a volume"}}
+}
+
+func (d *fakeRawDriver) RunRawOperation(name string, args []byte) ([]byte, error) {
+	if name != "attach-volume" {
+		return nil, errors.New("unknown operation")
+	}
+	d.ran, d.args = name, string(args)
+	return []byte(`{"State":"attaching"}`), nil
+}
+
+func newRawDriverAPI(driver drivers.Driver) *libmachinetest.FakeAPI {
+	return &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "myhost",
+				DriverName: "amazonec2",
+				Driver:     driver,
+			},
+		},
+	}
+}
+
+func TestCmdDriverExec(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	driver := &fakeRawDriver{}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"myhost", "--", "attach-volume", `{"VolumeId": "vol-1"}`},
+	}
+
+	err := cmdDriverExec(commandLine, newRawDriverAPI(driver))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "attach-volume", driver.ran)
+	assert.Equal(t, `{"VolumeId": "vol-1"}`, driver.args)
+	assert.Equal(t, "{\n    \"State\": \"attaching\"\n}\n", stdoutGetter.Output())
+}
+
+func TestCmdDriverExecListsOperations(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	err := cmdDriverExec(&commandstest.FakeCommandLine{CliArgs: []string{"myhost"}}, newRawDriverAPI(&fakeRawDriver{}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, `OPERATION       USAGE
+attach-volume   Attach a volume
+`, stdoutGetter.Output())
+}
+
+func TestCmdDriverExecErrors(t *testing.T) {
+	driver := &fakeRawDriver{}
+
+	err := cmdDriverExec(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "attach-volume", "vol-1"}}, newRawDriverAPI(driver))
+	assert.EqualError(t, err, `Error: the arguments of attach-volume must be JSON, e.g. '{"VolumeId": "vol-0123"}'`)
+
+	err = cmdDriverExec(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "resize-volume"}}, newRawDriverAPI(driver))
+	assert.EqualError(t, err, "Error running resize-volume on myhost: unknown operation")
+
+	err = cmdDriverExec(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "--", "attach-volume", "{}", "extra"}}, newRawDriverAPI(driver))
+	assert.Equal(t, ErrTooManyArguments, err)
+
+	err = cmdDriverExec(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "attach-volume"}}, newRawDriverAPI(&fakedriver.Driver{}))
+	assert.Contains(t, err.Error(), "Error: the amazonec2 driver has no operations of its provider to run")
+	assert.Empty(t, driver.ran)
+}
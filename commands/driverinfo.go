@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// driverInfoMachineName names the machine of the driver, which is never
+// created.
+const driverInfoMachineName = "driver-info"
+
+var errNoDriverName = errors.New("Error: Expected a driver name, e.g. virtualbox")
+
+// DriverInfo is the information of a driver printed with --output json.
+type DriverInfo struct {
+	Name         string
+	Capabilities drivers.Capabilities
+}
+
+func cmdDriverInfo(c CommandLine, api libmachine.API) error {
+	switch len(c.Args()) {
+	case 0:
+		return errNoDriverName
+	case 1:
+	default:
+		return ErrTooManyArguments
+	}
+
+	rawDriver, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: driverInfoMachineName,
+	})
+	if err != nil {
+		return fmt.Errorf("Error attempting to marshal bare driver data: %s", err)
+	}
+
+	h, err := api.NewHost(c.Args().First(), rawDriver)
+	if err != nil {
+		return err
+	}
+
+	return printDriverInfo(c, DriverInfo{
+		Name:         h.DriverName,
+		Capabilities: drivers.GetCapabilities(h.Driver),
+	})
+}
+
+func printDriverInfo(c CommandLine, info DriverInfo) error {
+	if isJSONOutput(c) {
+		return printJSON(info)
+	}
+
+	capabilities := info.Capabilities
+	features := []struct {
+		name      string
+		supported bool
+	}{
+		{"snapshots", capabilities.Snapshots},
+		{"resize", capabilities.Resize},
+		{"private-networking", capabilities.PrivateNetworking},
+		{"spot", capabilities.Spot},
+		{"ipv6", capabilities.IPv6},
+		{"user-data", capabilities.UserData},
+		{"raw-operations", capabilities.RawOperations},
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "FEATURE\tSUPPORTED")
+	for _, feature := range features {
+		supported := "no"
+		if feature.supported {
+			supported = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", feature.name, supported)
+	}
+	return nil
+}
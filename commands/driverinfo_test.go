@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdDriverInfoArguments(t *testing.T) {
+	err := cmdDriverInfo(&commandstest.FakeCommandLine{}, &libmachinetest.FakeAPI{})
+	assert.Equal(t, errNoDriverName, err)
+
+	err = cmdDriverInfo(&commandstest.FakeCommandLine{CliArgs: []string{"virtualbox", "google"}}, &libmachinetest.FakeAPI{})
+	assert.Equal(t, ErrTooManyArguments, err)
+}
+
+func TestPrintDriverInfo(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	info := DriverInfo{
+		Name:         "digitalocean",
+		Capabilities: drivers.Capabilities{Snapshots: true, IPv6: true, UserData: true},
+	}
+
+	err := printDriverInfo(&commandstest.FakeCommandLine{}, info)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `FEATURE              SUPPORTED
+snapshots            yes
+resize               no
+private-networking   no
+spot                 no
+ipv6                 yes
+user-data            yes
+raw-operations       no
+`, stdoutGetter.Output())
+}
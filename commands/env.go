@@ -3,6 +3,8 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,16 +13,20 @@ import (
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/shell"
 )
 
 const (
-	envTmpl = `{{ .Prefix }}DOCKER_TLS_VERIFY{{ .Delimiter }}{{ .DockerTLSVerify }}{{ .Suffix }}{{ .Prefix }}DOCKER_HOST{{ .Delimiter }}{{ .DockerHost }}{{ .Suffix }}{{ .Prefix }}DOCKER_CERT_PATH{{ .Delimiter }}{{ .DockerCertPath }}{{ .Suffix }}{{ .Prefix }}DOCKER_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ if .NoProxyVar }}{{ .Prefix }}{{ .NoProxyVar }}{{ .Delimiter }}{{ .NoProxyValue }}{{ .Suffix }}{{end}}{{ .UsageHint }}`
+	envTmpl = `{{ if .ContainerdAddress }}{{ .Prefix }}CONTAINERD_ADDRESS{{ .Delimiter }}{{ .ContainerdAddress }}{{ .Suffix }}{{ else }}{{ .Prefix }}DOCKER_TLS_VERIFY{{ .Delimiter }}{{ .DockerTLSVerify }}{{ .Suffix }}{{ .Prefix }}DOCKER_HOST{{ .Delimiter }}{{ .DockerHost }}{{ .Suffix }}{{ end }}{{ .Prefix }}DOCKER_CERT_PATH{{ .Delimiter }}{{ .DockerCertPath }}{{ .Suffix }}{{ .Prefix }}DOCKER_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ if .NoProxyVar }}{{ .Prefix }}{{ .NoProxyVar }}{{ .Delimiter }}{{ .NoProxyValue }}{{ .Suffix }}{{end}}{{ .UsageHint }}`
 )
 
 var (
 	errImproperUnsetEnvArgs = errors.New("Error: Expected no machine name when the -u flag is present")
+	errSwarmProxyJump       = errors.New("Error: The Swarm config isn't available for a machine reached through a bastion")
+	errContainerdContext    = errors.New("Error: A Docker context can't be created for a machine running containerd")
 	defaultUsageHinter      UsageHintGenerator
 )
 
@@ -39,6 +45,10 @@ type ShellConfig struct {
 	MachineName     string
 	NoProxyVar      string
 	NoProxyValue    string
+
+	// ContainerdAddress is the address of the containerd API of a machine
+	// running containerd, exported instead of the Docker host.
+	ContainerdAddress string
 }
 
 func cmdEnv(c CommandLine, api libmachine.API) error {
@@ -52,6 +62,10 @@ func cmdEnv(c CommandLine, api libmachine.API) error {
 	log.SetOutWriter(os.Stderr)
 
 	if c.Bool("unset") {
+		if c.Bool("context") {
+			return errImproperUnsetContextArgs
+		}
+
 		shellCfg, err = shellCfgUnset(c, api)
 		if err != nil {
 			return err
@@ -63,9 +77,57 @@ func cmdEnv(c CommandLine, api libmachine.API) error {
 		}
 	}
 
+	if c.Bool("context") {
+		if shellCfg.ContainerdAddress != "" {
+			return errContainerdContext
+		}
+
+		if err := writeDockerContext(shellCfg); err != nil {
+			return err
+		}
+
+		fmt.Printf("Docker context %q was updated, switch to it with: docker context use %s\n", shellCfg.MachineName, shellCfg.MachineName)
+		return nil
+	}
+
+	if isJSONOutput(c) {
+		return printJSON(envVars(shellCfg))
+	}
+
 	return executeTemplateStdout(shellCfg)
 }
 
+// envVars returns the variables set by the shell configuration; they are
+// null when they should be unset.
+func envVars(shellCfg *ShellConfig) map[string]interface{} {
+	vars := map[string]interface{}{
+		"DOCKER_TLS_VERIFY":   nil,
+		"DOCKER_HOST":         nil,
+		"DOCKER_CERT_PATH":    nil,
+		"DOCKER_MACHINE_NAME": nil,
+	}
+
+	if shellCfg.MachineName != "" {
+		if shellCfg.ContainerdAddress != "" {
+			delete(vars, "DOCKER_TLS_VERIFY")
+			delete(vars, "DOCKER_HOST")
+			vars["CONTAINERD_ADDRESS"] = shellCfg.ContainerdAddress
+		} else {
+			vars["DOCKER_TLS_VERIFY"] = shellCfg.DockerTLSVerify
+			vars["DOCKER_HOST"] = shellCfg.DockerHost
+		}
+		vars["DOCKER_CERT_PATH"] = shellCfg.DockerCertPath
+		vars["DOCKER_MACHINE_NAME"] = shellCfg.MachineName
+		if shellCfg.NoProxyVar != "" {
+			vars[shellCfg.NoProxyVar] = shellCfg.NoProxyValue
+		}
+	} else if shellCfg.NoProxyVar != "" {
+		vars[shellCfg.NoProxyVar] = nil
+	}
+
+	return vars
+}
+
 func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 	if len(c.Args()) > 1 {
 		return nil, ErrExpectedOneMachine
@@ -81,12 +143,29 @@ func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 		return nil, err
 	}
 
-	dockerHost, _, err := check.DefaultConnChecker.Check(host, c.Bool("swarm"))
+	proxyJump, err := drivers.GetSSHProxyJump(host.Driver)
 	if err != nil {
-		return nil, fmt.Errorf("Error checking TLS connection: %s", err)
+		return nil, err
 	}
 
-	userShell, err := getShell(c.String("shell"))
+	var dockerHost string
+	if proxyJump != nil {
+		if c.Bool("swarm") {
+			return nil, errSwarmProxyJump
+		}
+
+		dockerHost, err = tunnelDockerHost(host, c.Int("tunnel-port"))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		dockerHost, _, err = check.DefaultConnChecker.Check(host, c.Bool("swarm"))
+		if err != nil {
+			return nil, fmt.Errorf("Error checking TLS connection: %s", err)
+		}
+	}
+
+	userShell, err := envShell(c)
 	if err != nil {
 		return nil, err
 	}
@@ -99,6 +178,12 @@ func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 		MachineName:     host.Name,
 	}
 
+	if host.IsContainerdEndpoint() {
+		shellCfg.ContainerdAddress = shellCfg.DockerHost
+		shellCfg.DockerHost = ""
+		shellCfg.DockerTLSVerify = ""
+	}
+
 	if c.Bool("no-proxy") {
 		ip, err := host.Driver.GetIP()
 		if err != nil {
@@ -151,12 +236,43 @@ func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 	return shellCfg, nil
 }
 
+// tunnelDockerHost returns the Docker host of a machine reached through a
+// bastion: its engine isn't reachable directly and is forwarded over SSH to a
+// local port, the engine port unless localPort is given.  The server
+// certificate of the machine is valid for localhost.
+func tunnelDockerHost(h *host.Host, localPort int) (string, error) {
+	engineURL, err := h.URL()
+	if err != nil {
+		return "", fmt.Errorf("Error getting the URL of the engine: %s", err)
+	}
+
+	u, err := url.Parse(engineURL)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing the URL of the engine %q: %s", engineURL, err)
+	}
+
+	_, enginePort, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing the URL of the engine %q: %s", engineURL, err)
+	}
+
+	tunnelPort := enginePort
+	if localPort != 0 {
+		tunnelPort = fmt.Sprint(localPort)
+	}
+
+	log.Infof("The engine of %s is reached through a bastion, forward it to a local port with:", h.Name)
+	log.Infof("%s port-forward --daemon %s %s:%s", os.Args[0], h.Name, tunnelPort, enginePort)
+
+	return "tcp://" + net.JoinHostPort("localhost", tunnelPort), nil
+}
+
 func shellCfgUnset(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 	if len(c.Args()) != 0 {
 		return nil, errImproperUnsetEnvArgs
 	}
 
-	userShell, err := getShell(c.String("shell"))
+	userShell, err := envShell(c)
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +325,15 @@ func executeTemplateStdout(shellCfg *ShellConfig) error {
 	return tmpl.Execute(os.Stdout, shellCfg)
 }
 
+// envShell returns the shell to format the environment for.  The shell is
+// irrelevant, and not detected, when printing JSON.
+func envShell(c CommandLine) (string, error) {
+	if isJSONOutput(c) {
+		return "", nil
+	}
+	return getShell(c.String("shell"))
+}
+
 func getShell(userShell string) (string, error) {
 	if userShell != "" {
 		return userShell, nil
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+var errImproperUnsetContextArgs = errors.New("Error: The --context and -u flags can't be used together")
+
+// dockerContextMeta is the metadata of a context of the Docker CLI, stored in
+// contexts/meta/<id>/meta.json.
+type dockerContextMeta struct {
+	Name      string
+	Metadata  dockerContextMetadata
+	Endpoints map[string]dockerContextEndpoint
+}
+
+type dockerContextMetadata struct {
+	Description string `json:",omitempty"`
+}
+
+type dockerContextEndpoint struct {
+	Host          string
+	SkipTLSVerify bool
+}
+
+// dockerConfigDir returns the configuration directory of the Docker CLI.
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+
+	return filepath.Join(mcnutils.GetHomeDir(), ".docker")
+}
+
+// dockerContextID returns the directory name of a context, which the Docker
+// CLI derives from its name.
+func dockerContextID(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeDockerContext creates or updates the Docker CLI context of a machine,
+// named after it, with its Docker host and TLS material.
+func writeDockerContext(shellCfg *ShellConfig) error {
+	contextsDir := filepath.Join(dockerConfigDir(), "contexts")
+	id := dockerContextID(shellCfg.MachineName)
+
+	tlsDir := filepath.Join(contextsDir, "tls", id, "docker")
+	if err := os.MkdirAll(tlsDir, 0700); err != nil {
+		return fmt.Errorf("Error creating the TLS directory of the context: %s", err)
+	}
+
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		content, err := ioutil.ReadFile(filepath.Join(shellCfg.DockerCertPath, name))
+		if err != nil {
+			return fmt.Errorf("Error reading the TLS material of the machine: %s", err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(tlsDir, name), content, 0600); err != nil {
+			return fmt.Errorf("Error writing the TLS material of the context: %s", err)
+		}
+	}
+
+	meta, err := json.Marshal(&dockerContextMeta{
+		Name: shellCfg.MachineName,
+		Metadata: dockerContextMetadata{
+			Description: fmt.Sprintf("Docker Machine %s", shellCfg.MachineName),
+		},
+		Endpoints: map[string]dockerContextEndpoint{
+			"docker": {
+				Host:          shellCfg.DockerHost,
+				SkipTLSVerify: false,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	metaDir := filepath.Join(contextsDir, "meta", id)
+	if err := os.MkdirAll(metaDir, 0700); err != nil {
+		return fmt.Errorf("Error creating the metadata directory of the context: %s", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(metaDir, "meta.json"), meta, 0644); err != nil {
+		return fmt.Errorf("Error writing the metadata of the context: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerContextID(t *testing.T) {
+	assert.Equal(t, "37a8eec1ce19687d132fe29051dca629d164e2c4958ba141d5f4133a33f0688f", dockerContextID("default"))
+}
+
+func TestWriteDockerContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(config string) { os.Setenv("DOCKER_CONFIG", config) }(os.Getenv("DOCKER_CONFIG"))
+	os.Setenv("DOCKER_CONFIG", filepath.Join(dir, "docker"))
+
+	certPath := filepath.Join(dir, "machines", "dev")
+	assert.NoError(t, os.MkdirAll(certPath, 0700))
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(certPath, name), []byte(name), 0600))
+	}
+
+	err = writeDockerContext(&ShellConfig{
+		DockerCertPath: certPath,
+		DockerHost:     "tcp://1.2.3.4:2376",
+		MachineName:    "dev",
+	})
+	assert.NoError(t, err)
+
+	id := dockerContextID("dev")
+	content, err := ioutil.ReadFile(filepath.Join(dir, "docker", "contexts", "meta", id, "meta.json"))
+	assert.NoError(t, err)
+
+	var meta dockerContextMeta
+	assert.NoError(t, json.Unmarshal(content, &meta))
+	assert.Equal(t, "dev", meta.Name)
+	assert.Equal(t, "tcp://1.2.3.4:2376", meta.Endpoints["docker"].Host)
+
+	key, err := ioutil.ReadFile(filepath.Join(dir, "docker", "contexts", "tls", id, "docker", "key.pem"))
+	assert.NoError(t, err)
+	assert.Equal(t, "key.pem", string(key))
+}
+
+func TestWriteDockerContextMissingCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(config string) { os.Setenv("DOCKER_CONFIG", config) }(os.Getenv("DOCKER_CONFIG"))
+	os.Setenv("DOCKER_CONFIG", dir)
+
+	err = writeDockerContext(&ShellConfig{
+		DockerCertPath: filepath.Join(dir, "missing"),
+		MachineName:    "dev",
+	})
+
+	assert.Error(t, err)
+}
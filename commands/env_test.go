@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -11,6 +12,7 @@ import (
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/libmachinetest"
 	"github.com/docker/machine/libmachine/state"
@@ -162,6 +164,42 @@ func TestShellCfgSet(t *testing.T) {
 			},
 			expectedErr: nil,
 		},
+		{
+			description: "bash shell set happy path with a containerd machine",
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"quux"},
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"shell":    "bash",
+						"swarm":    false,
+						"no-proxy": false,
+					},
+				},
+			},
+			api: &libmachinetest.FakeAPI{
+				Hosts: []*host.Host{
+					{
+						Name:         "quux",
+						EndpointType: host.EndpointContainerd,
+					},
+				},
+			},
+			connChecker: &FakeConnChecker{
+				DockerHost:  "tcp://1.2.3.4:2376",
+				AuthOptions: nil,
+				Err:         nil,
+			},
+			expectedShellCfg: &ShellConfig{
+				Prefix:            "export ",
+				Delimiter:         "=\"",
+				Suffix:            "\"\n",
+				DockerCertPath:    filepath.Join(mcndirs.GetMachineDir(), "quux"),
+				UsageHint:         usageHint,
+				MachineName:       "quux",
+				ContainerdAddress: "tcp://1.2.3.4:2376",
+			},
+			expectedErr: nil,
+		},
 		{
 			description: "bash shell set happy path with 'default' vm",
 			commandLine: &commandstest.FakeCommandLine{
@@ -359,8 +397,9 @@ func TestShellCfgSet(t *testing.T) {
 					{
 						Name: "quux",
 						Driver: &fakedriver.Driver{
-							MockState: state.Running,
-							MockIP:    "1.2.3.4",
+							BaseDriver: &drivers.BaseDriver{},
+							MockState:  state.Running,
+							MockIP:     "1.2.3.4",
 						},
 					},
 				},
@@ -403,8 +442,9 @@ func TestShellCfgSet(t *testing.T) {
 					{
 						Name: "quux",
 						Driver: &fakedriver.Driver{
-							MockState: state.Running,
-							MockIP:    "1.2.3.4",
+							BaseDriver: &drivers.BaseDriver{},
+							MockState:  state.Running,
+							MockIP:     "1.2.3.4",
 						},
 					},
 				},
@@ -430,6 +470,48 @@ func TestShellCfgSet(t *testing.T) {
 			noProxyValue: "192.168.59.1",
 			expectedErr:  nil,
 		},
+		{
+			description: "bash shell set happy path with a machine reached through a bastion",
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"quux"},
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"shell":       "bash",
+						"swarm":       false,
+						"no-proxy":    false,
+						"tunnel-port": 12376,
+					},
+				},
+			},
+			api: &libmachinetest.FakeAPI{
+				Hosts: []*host.Host{
+					{
+						Name: "quux",
+						Driver: &fakedriver.Driver{
+							BaseDriver: &drivers.BaseDriver{
+								SSHProxyJump: "ubuntu@bastion.example.com",
+							},
+							MockState: state.Running,
+							MockIP:    "10.0.0.5",
+						},
+					},
+				},
+			},
+			connChecker: &FakeConnChecker{
+				Err: errors.New("The engine can't be reached directly"),
+			},
+			expectedShellCfg: &ShellConfig{
+				Prefix:          "export ",
+				Delimiter:       "=\"",
+				Suffix:          "\"\n",
+				DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), "quux"),
+				DockerHost:      "tcp://localhost:12376",
+				DockerTLSVerify: "1",
+				UsageHint:       usageHint,
+				MachineName:     "quux",
+			},
+			expectedErr: nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+)
+
+func cmdEvents(c CommandLine, api libmachine.API) error {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+
+	stop := make(chan struct{})
+	go func() {
+		if _, ok := <-interrupts; ok {
+			close(stop)
+		}
+	}()
+
+	return streamEvents(c, api, os.Stdout, stop)
+}
+
+// streamEvents prints the state changes of the machines given as arguments,
+// or of all the machines, until stop is closed.
+func streamEvents(c CommandLine, api libmachine.API, out io.Writer, stop <-chan struct{}) error {
+	names := []string(c.Args())
+	if len(names) == 0 {
+		var err error
+		if names, err = api.List(); err != nil {
+			return err
+		}
+	}
+
+	if len(names) == 0 {
+		return ErrNoMachineSpecified
+	}
+
+	changes := make(chan libmachine.StateChange)
+	var wg sync.WaitGroup
+
+	for _, name := range names {
+		watch, err := api.Watch(name, stop)
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func(watch <-chan libmachine.StateChange) {
+			defer wg.Done()
+			for change := range watch {
+				changes <- change
+			}
+		}(watch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(changes)
+	}()
+
+	jsonOutput := isJSONOutput(c)
+	for change := range changes {
+		if jsonOutput {
+			line, err := json.Marshal(change)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, string(line))
+			continue
+		}
+
+		fmt.Fprintln(out, formatStateChange(change))
+	}
+
+	return nil
+}
+
+// formatStateChange formats a state change as one line, e.g.
+// 2016-01-02T15:04:05Z dev Stopped -> Running.
+func formatStateChange(change libmachine.StateChange) string {
+	line := fmt.Sprintf("%s %s ", change.Time.UTC().Format(time.RFC3339), change.Machine)
+	if change.Previous.String() != "" {
+		line += change.Previous.String() + " -> "
+	}
+	line += change.State.String()
+
+	if change.Error != "" {
+		line += ": " + change.Error
+	}
+
+	return line
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// sequenceDriver returns the states of its sequence, then stays in the last
+// one.
+type sequenceDriver struct {
+	*fakedriver.Driver
+	sync.Mutex
+	states []state.State
+}
+
+func (d *sequenceDriver) GetState() (state.State, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	current := d.states[0]
+	if len(d.states) > 1 {
+		d.states = d.states[1:]
+	}
+
+	if current == state.Error {
+		return current, errors.New("unreachable")
+	}
+	return current, nil
+}
+
+type syncBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *syncBuffer) lines() []string {
+	b.Lock()
+	defer b.Unlock()
+	return strings.Split(strings.TrimSpace(b.String()), "\n")
+}
+
+// runEvents streams the events until count lines were printed.
+func runEvents(t *testing.T, commandLine CommandLine, api libmachine.API, count int) []string {
+	out := &syncBuffer{}
+	stop := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- streamEvents(commandLine, api, out, stop)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for len(out.lines()) < count || out.lines()[0] == "" {
+		select {
+		case <-deadline:
+			t.Fatalf("Expected %d events, got %q", count, out.lines())
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	close(stop)
+	assert.NoError(t, <-done)
+
+	return out.lines()[:count]
+}
+
+func TestStreamEvents(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "dev",
+				Driver: &sequenceDriver{
+					Driver: &fakedriver.Driver{},
+					states: []state.State{state.Stopped, state.Stopped, state.Starting, state.Running, state.Error},
+				},
+			},
+			{
+				Name:   "ci",
+				Driver: &fakedriver.Driver{MockState: state.Running},
+			},
+		},
+	}
+
+	lines := runEvents(t, &commandstest.FakeCommandLine{CliArgs: []string{"dev"}}, api, 4)
+
+	var events []string
+	for _, line := range lines {
+		events = append(events, strings.SplitN(line, " ", 2)[1])
+	}
+	assert.Equal(t, []string{
+		"dev Stopped",
+		"dev Stopped -> Starting",
+		"dev Starting -> Running",
+		"dev Running -> Error: unreachable",
+	}, events)
+
+	lines = runEvents(t, &commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{"output": "json"}},
+		CliArgs:     []string{"ci"},
+	}, api, 1)
+	assert.Contains(t, lines[0], `"Machine":"ci","Previous":"","State":"Running"`)
+}
+
+func TestStreamEventsUnknownMachine(t *testing.T) {
+	err := streamEvents(&commandstest.FakeCommandLine{CliArgs: []string{"unknown"}}, &libmachinetest.FakeAPI{}, &bytes.Buffer{}, make(chan struct{}))
+
+	assert.Error(t, err)
+}
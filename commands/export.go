@@ -0,0 +1,372 @@
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+// An export is a gzipped tarball holding the files of the machine directory
+// under the name of the machine, and a manifest telling where they were
+// exported from, so that the absolute paths of the configuration are
+// rewritten when imported.
+const exportManifestFile = "machine-export.json"
+
+var (
+	errNoExportName       = errors.New("Error: Expected the name of the machine to export as argument")
+	errNoImportFile       = errors.New("Error: Expected the file of the exported machine as argument")
+	errInvalidExport      = errors.New("Error: The file is not a machine exported with docker-machine export")
+	exportedDiskImageExts = []string{".iso", ".vmdk", ".vdi", ".vhd", ".vhdx", ".qcow2", ".img", ".raw"}
+)
+
+type exportManifest struct {
+	Name      string
+	StorePath string
+}
+
+// exportFiles returns the paths of the files of a machine to export by their
+// name in its directory.  The disk images of the local VMs are left out, and
+// the CA and the client certificate of the machine are added when missing.
+// The private key of the CA, which signs the certificates of every machine
+// of the store, is only exported with includeCAKey.
+func exportFiles(h *host.Host, machineDir string, includeCAKey bool) (map[string]string, error) {
+	entries, err := ioutil.ReadDir(machineDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || isDiskImage(entry.Name()) || (entry.Name() == "ca-key.pem" && !includeCAKey) {
+			continue
+		}
+		files[entry.Name()] = filepath.Join(machineDir, entry.Name())
+	}
+
+	if authOptions := h.AuthOptions(); authOptions != nil {
+		certs := map[string]string{
+			"ca.pem":   authOptions.CaCertPath,
+			"cert.pem": authOptions.ClientCertPath,
+			"key.pem":  authOptions.ClientKeyPath,
+		}
+		if includeCAKey {
+			certs["ca-key.pem"] = authOptions.CaPrivateKeyPath
+		}
+		for name, certPath := range certs {
+			if _, ok := files[name]; ok || certPath == "" {
+				continue
+			}
+			if _, err := os.Stat(certPath); err == nil {
+				files[name] = certPath
+			}
+		}
+	}
+
+	return files, nil
+}
+
+func isDiskImage(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, diskExt := range exportedDiskImageExts {
+		if ext == diskExt {
+			return true
+		}
+	}
+	return false
+}
+
+func addTarFile(tw *tar.Writer, name string, mode int64, content []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: mode,
+		Size: int64(len(content)),
+	}); err != nil {
+		return err
+	}
+
+	_, err := tw.Write(content)
+	return err
+}
+
+// writeExport writes the archive of a machine.
+func writeExport(w io.Writer, h *host.Host, files map[string]string, storePath string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	manifest, err := json.MarshalIndent(exportManifest{Name: h.Name, StorePath: storePath}, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := addTarFile(tw, exportManifestFile, 0600, manifest); err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		content, err := ioutil.ReadFile(files[name])
+		if err != nil {
+			return err
+		}
+
+		info, err := os.Stat(files[name])
+		if err != nil {
+			return err
+		}
+
+		if err := addTarFile(tw, path.Join(h.Name, name), int64(info.Mode().Perm()), content); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func cmdExport(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrTooManyArguments
+	}
+
+	name := c.Args().First()
+	if name == "" {
+		return errNoExportName
+	}
+
+	h, err := api.Load(name)
+	if err != nil {
+		return err
+	}
+
+	files, err := exportFiles(h, filepath.Join(api.GetMachinesDir(), name), c.Bool("include-ca-key"))
+	if err != nil {
+		return fmt.Errorf("Error reading the files of %s: %s", name, err)
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = name + ".tar.gz"
+	}
+
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := writeExport(f, h, files, mcndirs.GetBaseDir()); err != nil {
+		return fmt.Errorf("Error exporting %s: %s", name, err)
+	}
+
+	log.Infof("Exported %q to %s, it holds the private keys of the machine.", name, output)
+
+	return nil
+}
+
+// readExport returns the manifest and the files of the machine of an archive.
+func readExport(r io.Reader) (*exportManifest, map[string]tarFile, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, errInvalidExport
+	}
+	defer gr.Close()
+
+	var manifest *exportManifest
+	files := map[string]tarFile{}
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if header.Name == exportManifestFile {
+			manifest = &exportManifest{}
+			if err := json.Unmarshal(content, manifest); err != nil {
+				return nil, nil, errInvalidExport
+			}
+			continue
+		}
+
+		files[header.Name] = tarFile{mode: os.FileMode(header.Mode).Perm(), content: content}
+	}
+
+	if manifest == nil || !host.ValidateHostName(manifest.Name) {
+		return nil, nil, errInvalidExport
+	}
+
+	machineFiles := map[string]tarFile{}
+	for name, file := range files {
+		dir, base := path.Split(name)
+		if dir != manifest.Name+"/" || base == "" || base == "." || base == ".." {
+			return nil, nil, fmt.Errorf("Error: Unexpected file %q in the exported machine", name)
+		}
+		machineFiles[base] = file
+	}
+
+	if _, ok := machineFiles["config.json"]; !ok {
+		return nil, nil, errInvalidExport
+	}
+
+	return manifest, machineFiles, nil
+}
+
+type tarFile struct {
+	mode    os.FileMode
+	content []byte
+}
+
+// rewritePaths replaces the prefix of the paths found in the strings of a
+// decoded JSON document.
+func rewritePaths(v interface{}, oldPrefix, newPrefix string) interface{} {
+	switch value := v.(type) {
+	case string:
+		if value == oldPrefix {
+			return newPrefix
+		}
+		if strings.HasPrefix(value, oldPrefix+"/") || strings.HasPrefix(value, oldPrefix+"\\") {
+			return filepath.Join(newPrefix, filepath.FromSlash(strings.Replace(value[len(oldPrefix)+1:], "\\", "/", -1)))
+		}
+		return value
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = rewritePaths(item, oldPrefix, newPrefix)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = rewritePaths(item, oldPrefix, newPrefix)
+		}
+		return value
+	}
+
+	return v
+}
+
+// importedConfig rewrites the configuration of an exported machine for the
+// store it's imported in: the paths of its directory and of the store are
+// replaced, and its certificates are the ones exported along with it.  The
+// private key of the CA is looked up in the machine directory even when it
+// wasn't exported, so that the machine never gets certificates signed by the
+// CA of the store it's imported in.
+func importedConfig(config []byte, manifest *exportManifest, files map[string]tarFile, storePath string) (*host.Host, error) {
+	document := map[string]interface{}{}
+	if err := json.Unmarshal(config, &document); err != nil {
+		return nil, fmt.Errorf("Error reading the configuration of %s: %s", manifest.Name, err)
+	}
+
+	machineDir := filepath.Join(storePath, "machines", manifest.Name)
+	rewritten := rewritePaths(document, manifest.StorePath, storePath)
+
+	data, err := json.Marshal(rewritten)
+	if err != nil {
+		return nil, err
+	}
+
+	h, _, err := host.MigrateHost(&host.Host{Name: manifest.Name}, data)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the configuration of %s: %s", manifest.Name, err)
+	}
+	h.Name = manifest.Name
+
+	if authOptions := h.AuthOptions(); authOptions != nil {
+		authOptions.CertDir = machineDir
+		authOptions.StorePath = machineDir
+		authOptions.CaCertPath = filepath.Join(machineDir, "ca.pem")
+		authOptions.ClientCertPath = filepath.Join(machineDir, "cert.pem")
+		authOptions.ClientKeyPath = filepath.Join(machineDir, "key.pem")
+		authOptions.CaPrivateKeyPath = filepath.Join(machineDir, "ca-key.pem")
+	}
+
+	return h, nil
+}
+
+func cmdImport(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrTooManyArguments
+	}
+
+	file := c.Args().First()
+	if file == "" {
+		return errNoImportFile
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, files, err := readExport(f)
+	if err != nil {
+		return err
+	}
+
+	exists, err := api.Exists(manifest.Name)
+	if err != nil {
+		return fmt.Errorf("Error checking if host exists: %s", err)
+	}
+	if exists {
+		return mcnerror.ErrHostAlreadyExists{
+			Name: manifest.Name,
+		}
+	}
+
+	h, err := importedConfig(files["config.json"].content, manifest, files, mcndirs.GetBaseDir())
+	if err != nil {
+		return err
+	}
+
+	machineDir := filepath.Join(api.GetMachinesDir(), manifest.Name)
+	if err := os.MkdirAll(machineDir, 0700); err != nil {
+		return err
+	}
+
+	for name, file := range files {
+		if name == "config.json" {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(machineDir, name), file.content, file.mode); err != nil {
+			return fmt.Errorf("Error importing %s: %s", manifest.Name, err)
+		}
+	}
+
+	if err := api.Save(h); err != nil {
+		return fmt.Errorf("Error saving %s to the store: %s", manifest.Name, err)
+	}
+
+	log.Infof("Imported %q, run '%s env %s' to connect to it.", manifest.Name, os.Args[0], manifest.Name)
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	oldStore, err := ioutil.TempDir("", "machine-export-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(oldStore)
+
+	machineDir := filepath.Join(oldStore, "machines", "dev")
+	certsDir := filepath.Join(oldStore, "certs")
+	assert.NoError(t, os.MkdirAll(machineDir, 0700))
+	assert.NoError(t, os.MkdirAll(certsDir, 0700))
+
+	config := `{"ConfigVersion":3,"Driver":{"MachineName":"dev","StorePath":"` + oldStore + `","SSHKeyPath":"` + filepath.Join(machineDir, "id_rsa") + `"},` +
+		`"DriverName":"generic","HostOptions":{"AuthOptions":{"CaCertPath":"` + filepath.Join(certsDir, "ca.pem") + `",` +
+		`"CaPrivateKeyPath":"` + filepath.Join(certsDir, "ca-key.pem") + `","ServerCertPath":"` + filepath.Join(machineDir, "server.pem") + `",` +
+		`"StorePath":"` + machineDir + `"}},"Name":"dev"}`
+	for name, content := range map[string]string{
+		"config.json":     config,
+		"id_rsa":          "KEY",
+		"server.pem":      "SERVER",
+		"boot2docker.iso": "ISO",
+		"ca.pem":          "CA",
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(machineDir, name), []byte(content), 0600))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(certsDir, "ca-key.pem"), []byte("CAKEY"), 0600))
+
+	h := &host.Host{
+		Name: "dev",
+		HostOptions: &host.Options{
+			AuthOptions: &auth.Options{
+				CaCertPath:       filepath.Join(certsDir, "ca.pem"),
+				CaPrivateKeyPath: filepath.Join(certsDir, "ca-key.pem"),
+			},
+		},
+	}
+
+	files, err := exportFiles(h, machineDir, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ca-key.pem", "ca.pem", "config.json", "id_rsa", "server.pem"}, sortedKeys(files))
+
+	archive := &bytes.Buffer{}
+	assert.NoError(t, writeExport(archive, h, files, oldStore))
+
+	manifest, imported, err := readExport(archive)
+	assert.NoError(t, err)
+	assert.Equal(t, "dev", manifest.Name)
+	assert.Equal(t, "CAKEY", string(imported["ca-key.pem"].content))
+	assert.Equal(t, os.FileMode(0600), imported["id_rsa"].mode)
+
+	newStore := filepath.Join(string(filepath.Separator), "home", "other", ".docker", "machine")
+	newMachineDir := filepath.Join(newStore, "machines", "dev")
+
+	importedHost, err := importedConfig(imported["config.json"].content, manifest, imported, newStore)
+	assert.NoError(t, err)
+	assert.Equal(t, "dev", importedHost.Name)
+	assert.Equal(t, filepath.Join(newMachineDir, "server.pem"), importedHost.HostOptions.AuthOptions.ServerCertPath)
+	assert.Equal(t, filepath.Join(newMachineDir, "ca.pem"), importedHost.HostOptions.AuthOptions.CaCertPath)
+	assert.Equal(t, filepath.Join(newMachineDir, "ca-key.pem"), importedHost.HostOptions.AuthOptions.CaPrivateKeyPath)
+	assert.Equal(t, newMachineDir, importedHost.HostOptions.AuthOptions.StorePath)
+
+	driverConfig := map[string]string{}
+	assert.NoError(t, json.Unmarshal(importedHost.Driver.(*host.RawDataDriver).Data, &driverConfig))
+	assert.Equal(t, newStore, driverConfig["StorePath"])
+	assert.Equal(t, filepath.Join(newMachineDir, "id_rsa"), driverConfig["SSHKeyPath"])
+}
+
+func TestExportLeavesOutCAKey(t *testing.T) {
+	oldStore, err := ioutil.TempDir("", "machine-export-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(oldStore)
+
+	machineDir := filepath.Join(oldStore, "machines", "dev")
+	certsDir := filepath.Join(oldStore, "certs")
+	assert.NoError(t, os.MkdirAll(machineDir, 0700))
+	assert.NoError(t, os.MkdirAll(certsDir, 0700))
+
+	config := `{"ConfigVersion":3,"Driver":{"MachineName":"dev","StorePath":"` + oldStore + `"},` +
+		`"DriverName":"generic","HostOptions":{"AuthOptions":{"CaCertPath":"` + filepath.Join(certsDir, "ca.pem") + `",` +
+		`"CaPrivateKeyPath":"` + filepath.Join(certsDir, "ca-key.pem") + `"}},"Name":"dev"}`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(machineDir, "config.json"), []byte(config), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(certsDir, "ca.pem"), []byte("CA"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(certsDir, "ca-key.pem"), []byte("CAKEY"), 0600))
+
+	h := &host.Host{
+		Name: "dev",
+		HostOptions: &host.Options{
+			AuthOptions: &auth.Options{
+				CaCertPath:       filepath.Join(certsDir, "ca.pem"),
+				CaPrivateKeyPath: filepath.Join(certsDir, "ca-key.pem"),
+			},
+		},
+	}
+
+	files, err := exportFiles(h, machineDir, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ca.pem", "config.json"}, sortedKeys(files))
+
+	archive := &bytes.Buffer{}
+	assert.NoError(t, writeExport(archive, h, files, oldStore))
+
+	manifest, imported, err := readExport(archive)
+	assert.NoError(t, err)
+
+	newStore := filepath.Join(string(filepath.Separator), "home", "other", ".docker", "machine")
+
+	importedHost, err := importedConfig(imported["config.json"].content, manifest, imported, newStore)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(newStore, "machines", "dev", "ca-key.pem"), importedHost.HostOptions.AuthOptions.CaPrivateKeyPath)
+}
+
+func TestReadExportRejectsOtherPaths(t *testing.T) {
+	archive := &bytes.Buffer{}
+	gw := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gw)
+	assert.NoError(t, addTarFile(tw, exportManifestFile, 0600, []byte(`{"Name":"dev"}`)))
+	assert.NoError(t, addTarFile(tw, "dev/config.json", 0600, []byte(`{}`)))
+	assert.NoError(t, addTarFile(tw, "dev/../../.bashrc", 0600, []byte("evil")))
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+
+	_, _, err := readExport(archive)
+
+	assert.EqualError(t, err, `Error: Unexpected file "dev/../../.bashrc" in the exported machine`)
+}
+
+func TestReadExportInvalid(t *testing.T) {
+	_, _, err := readExport(bytes.NewBufferString("not an archive"))
+
+	assert.Equal(t, errInvalidExport, err)
+}
+
+func sortedKeys(files map[string]string) []string {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+var (
+	// GCFlags are the flags of gc, on top of which the flags of the driver
+	// are added.
+	GCFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "driver, d",
+			Usage:  "Driver whose resources are collected",
+			EnvVar: "MACHINE_DRIVER",
+		},
+		cli.BoolFlag{
+			Name:  "delete",
+			Usage: "Delete the orphaned resources instead of only listing them",
+		},
+		cli.BoolFlag{
+			Name:  "y",
+			Usage: "Assumes automatic yes to proceed with the deletion, without prompting further user confirmation",
+		},
+	}
+)
+
+// GCItem is an orphaned resource as printed with --output json.
+type GCItem struct {
+	Type        string
+	ID          string
+	Machine     string
+	Description string     `json:",omitempty"`
+	Deleted     bool       `json:",omitempty"`
+	Error       *JSONError `json:",omitempty"`
+}
+
+func cmdGCOuter(c CommandLine, api libmachine.API) error {
+	return runWithDriverFlags(c, api, "gc", GCFlags, cmdGCInner)
+}
+
+func cmdGCInner(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 0 {
+		return ErrTooManyArguments
+	}
+
+	// Only the resources tagged with the identity of the store are collected,
+	// not the ones of the machines of another store in the same account.
+	id, err := storeID(api)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return persist.ErrStoreIDNotSupported
+	}
+
+	rawDriver, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: "gc",
+		StorePath:   mcndirs.GetBaseDir(),
+		StoreID:     id,
+	})
+	if err != nil {
+		return fmt.Errorf("Error attempting to marshal bare driver data: %s", err)
+	}
+
+	h, err := api.NewHost(driverName(c), rawDriver)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Driver.SetConfigFromFlags(getDriverOpts(c, h.Driver.GetCreateFlags())); err != nil {
+		return fmt.Errorf("Error setting machine configuration from flags provided: %s", err)
+	}
+
+	resources, err := drivers.ListManagedResources(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	names, err := api.List()
+	if err != nil {
+		return err
+	}
+
+	orphaned := orphanedResources(resources, names)
+	if !c.Bool("delete") {
+		return printOrphanedResources(c, orphaned)
+	}
+
+	if len(orphaned) == 0 {
+		log.Info("No orphaned resources to delete.")
+		return nil
+	}
+
+	if !isJSONOutput(c) {
+		if err := printOrphanedResources(c, orphaned); err != nil {
+			return err
+		}
+	}
+	if !userConfirm(c.Bool("y"), false) {
+		return nil
+	}
+
+	items, errs := deleteResources(h.Driver, orphaned)
+	if isJSONOutput(c) {
+		if err := printJSON(items); err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			return errJSONReported
+		}
+		return nil
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// orphanedResources returns the resources created for the machines which are
+// not in the store anymore.
+func orphanedResources(resources []drivers.ManagedResource, names []string) []drivers.ManagedResource {
+	stored := map[string]bool{}
+	for _, name := range names {
+		stored[name] = true
+	}
+
+	orphaned := []drivers.ManagedResource{}
+	for _, resource := range resources {
+		if resource.Machine != "" && !stored[resource.Machine] {
+			orphaned = append(orphaned, resource)
+		}
+	}
+
+	return orphaned
+}
+
+func printOrphanedResources(c CommandLine, resources []drivers.ManagedResource) error {
+	if isJSONOutput(c) {
+		items := []GCItem{}
+		for _, resource := range resources {
+			items = append(items, newGCItem(resource))
+		}
+		return printJSON(items)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "TYPE\tID\tMACHINE\tDESCRIPTION")
+	for _, resource := range resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", resource.Type, resource.ID, resource.Machine, resource.Description)
+	}
+
+	return nil
+}
+
+// deleteResources deletes the resources in the order they're listed in, going
+// on after the failures so that running gc again deletes what's left.
+func deleteResources(d drivers.Driver, resources []drivers.ManagedResource) ([]GCItem, []string) {
+	items := []GCItem{}
+	errs := []string{}
+
+	for _, resource := range resources {
+		item := newGCItem(resource)
+
+		if err := drivers.DeleteManagedResource(d, resource); err != nil {
+			message := fmt.Sprintf("Error deleting the %s %s of %q: %s", resource.Type, resource.ID, resource.Machine, err)
+			errs = append(errs, message)
+			jsonErr := newJSONError(errors.New(message))
+			item.Error = &jsonErr
+		} else {
+			log.Infof("Deleted the %s %s of %q", resource.Type, resource.ID, resource.Machine)
+			item.Deleted = true
+		}
+
+		items = append(items, item)
+	}
+
+	return items, errs
+}
+
+func newGCItem(resource drivers.ManagedResource) GCItem {
+	return GCItem{
+		Type:        resource.Type,
+		ID:          resource.ID,
+		Machine:     resource.Machine,
+		Description: resource.Description,
+	}
+}
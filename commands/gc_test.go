@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/stretchr/testify/assert"
+)
+
+type resourceDriver struct {
+	*fakedriver.Driver
+	deleted []string
+}
+
+func (d *resourceDriver) ListManagedResources() ([]drivers.ManagedResource, error) {
+	return nil, nil
+}
+
+func (d *resourceDriver) DeleteManagedResource(resource drivers.ManagedResource) error {
+	if resource.ID == "sg-1" {
+		return errors.New("DependencyViolation")
+	}
+	d.deleted = append(d.deleted, resource.ID)
+	return nil
+}
+
+func TestOrphanedResources(t *testing.T) {
+	resources := []drivers.ManagedResource{
+		{Type: "instance", ID: "i-1", Machine: "kept"},
+		{Type: "instance", ID: "i-2", Machine: "removed"},
+		{Type: "key-pair", ID: "removed", Machine: "removed"},
+		{Type: "volume", ID: "vol-1"},
+	}
+
+	orphaned := orphanedResources(resources, []string{"kept", "other"})
+
+	assert.Equal(t, []drivers.ManagedResource{
+		{Type: "instance", ID: "i-2", Machine: "removed"},
+		{Type: "key-pair", ID: "removed", Machine: "removed"},
+	}, orphaned)
+}
+
+func TestDeleteResourcesGoesOnAfterFailures(t *testing.T) {
+	driver := &resourceDriver{Driver: &fakedriver.Driver{}}
+
+	items, errs := deleteResources(driver, []drivers.ManagedResource{
+		{Type: "security-group", ID: "sg-1", Machine: "removed"},
+		{Type: "key-pair", ID: "removed", Machine: "removed"},
+	})
+
+	assert.Equal(t, []string{"removed"}, driver.deleted)
+	assert.Equal(t, []string{`Error deleting the security-group sg-1 of "removed": DependencyViolation`}, errs)
+	assert.NotNil(t, items[0].Error)
+	assert.False(t, items[0].Deleted)
+	assert.True(t, items[1].Deleted)
+}
+
+func TestDeleteResourcesNotSupported(t *testing.T) {
+	_, errs := deleteResources(&fakedriver.Driver{}, []drivers.ManagedResource{{Type: "instance", ID: "i-1", Machine: "removed"}})
+
+	assert.Equal(t, []string{`Error deleting the instance i-1 of "removed": ` + drivers.ErrManagedResourcesNotSupported.Error()}, errs)
+}
+
+func TestGCNeedsStoreID(t *testing.T) {
+	err := cmdGCInner(&commandstest.FakeCommandLine{}, &libmachinetest.FakeAPI{})
+
+	assert.Equal(t, persist.ErrStoreIDNotSupported, err)
+}
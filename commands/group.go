@@ -0,0 +1,255 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+// groupPrefix starts the arguments naming a group of machines, e.g. @web,
+// in place of the machines of the group.
+const groupPrefix = "@"
+
+var errNoGroupName = errors.New("Error: Expected a group name as argument")
+
+// GroupItem is a group of machines as printed with --output json.
+type GroupItem struct {
+	Name     string
+	Machines []string
+}
+
+// groupStore returns the store of the groups of machines.
+func groupStore(api libmachine.API) (persist.GroupStore, error) {
+	store, ok := api.(persist.GroupStore)
+	if !ok {
+		return nil, persist.ErrGroupsNotSupported
+	}
+	return store, nil
+}
+
+// isGroupName tells whether a machine name given as argument names a group,
+// since the names of the machines can't start with @.
+func isGroupName(arg string) bool {
+	return strings.HasPrefix(arg, groupPrefix)
+}
+
+// expandGroups replaces the groups given as arguments with their machines.
+func expandGroups(args []string, api libmachine.API) ([]string, error) {
+	hasGroup := false
+	for _, arg := range args {
+		if isGroupName(arg) {
+			hasGroup = true
+		}
+	}
+
+	if !hasGroup {
+		return args, nil
+	}
+
+	store, err := groupStore(api)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := store.LoadGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := []string{}
+	for _, arg := range args {
+		if !isGroupName(arg) {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		name := strings.TrimPrefix(arg, groupPrefix)
+		members, ok := groups[name]
+		if !ok {
+			return nil, fmt.Errorf("Error: Group %q does not exist", name)
+		}
+		expanded = append(expanded, members...)
+	}
+
+	if len(expanded) == 0 {
+		return nil, errNoMachineMatches
+	}
+
+	return expanded, nil
+}
+
+// loadGroups returns the groups of machines and the group given as first
+// argument, which must exist unless it's created.
+func loadGroups(c CommandLine, api libmachine.API, create bool) (persist.GroupStore, persist.Groups, string, error) {
+	if len(c.Args()) == 0 {
+		return nil, nil, "", errNoGroupName
+	}
+	name := strings.TrimPrefix(c.Args().First(), groupPrefix)
+
+	store, err := groupStore(api)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	groups, err := store.LoadGroups()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	_, exists := groups[name]
+	switch {
+	case create && exists:
+		return nil, nil, "", fmt.Errorf("Error: Group %q already exists", name)
+	case !create && !exists:
+		return nil, nil, "", fmt.Errorf("Error: Group %q does not exist", name)
+	case create && !host.ValidateHostName(name):
+		return nil, nil, "", fmt.Errorf("Error: Invalid group name %q, the names of the groups follow the rules of the names of the machines", name)
+	}
+
+	return store, groups, name, nil
+}
+
+// checkMachinesExist checks that the machines to add to a group exist.
+func checkMachinesExist(names []string, api libmachine.API) error {
+	for _, name := range names {
+		exists, err := api.Exists(name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return mcnerror.ErrHostDoesNotExist{Name: name}
+		}
+	}
+	return nil
+}
+
+func cmdGroupCreate(c CommandLine, api libmachine.API) error {
+	store, groups, name, err := loadGroups(c, api, true)
+	if err != nil {
+		return err
+	}
+
+	machines := c.Args().Tail()
+	if err := checkMachinesExist(machines, api); err != nil {
+		return err
+	}
+
+	groups.Add(name, machines...)
+	if err := store.SaveGroups(groups); err != nil {
+		return err
+	}
+
+	log.Infof("Group %q created with %d machines", name, len(groups[name]))
+	return nil
+}
+
+func cmdGroupAdd(c CommandLine, api libmachine.API) error {
+	store, groups, name, err := loadGroups(c, api, false)
+	if err != nil {
+		return err
+	}
+
+	machines := c.Args().Tail()
+	if len(machines) == 0 {
+		return ErrNoMachineSpecified
+	}
+	if err := checkMachinesExist(machines, api); err != nil {
+		return err
+	}
+
+	groups.Add(name, machines...)
+	return store.SaveGroups(groups)
+}
+
+// cmdGroupRemove removes machines from a group, or the group itself without
+// machines as arguments.  The machines themselves are kept.
+func cmdGroupRemove(c CommandLine, api libmachine.API) error {
+	store, groups, name, err := loadGroups(c, api, false)
+	if err != nil {
+		return err
+	}
+
+	machines := c.Args().Tail()
+	if len(machines) == 0 {
+		delete(groups, name)
+		if err := store.SaveGroups(groups); err != nil {
+			return err
+		}
+
+		log.Infof("Group %q removed", name)
+		return nil
+	}
+
+	for _, machine := range machines {
+		if !groups.Has(name, machine) {
+			return fmt.Errorf("Error: %s is not in the group %q", machine, name)
+		}
+		groups.Remove(name, machine)
+	}
+
+	return store.SaveGroups(groups)
+}
+
+func cmdGroupLs(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 0 {
+		return ErrTooManyArguments
+	}
+
+	store, err := groupStore(api)
+	if err != nil {
+		return err
+	}
+
+	groups, err := store.LoadGroups()
+	if err != nil {
+		return err
+	}
+
+	if isJSONOutput(c) {
+		items := []GroupItem{}
+		for _, name := range groups.Names() {
+			items = append(items, GroupItem{Name: name, Machines: groups[name]})
+		}
+		return printJSON(items)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NAME\tMACHINES")
+	for _, name := range groups.Names() {
+		fmt.Fprintf(w, "%s\t%s\n", name, strings.Join(groups[name], ", "))
+	}
+
+	return nil
+}
+
+// updateGroups applies a change to the groups of machines, e.g. once a
+// machine is removed or renamed, and saves them if it returns true.  A store
+// without groups is left alone.
+func updateGroups(api libmachine.API, change func(persist.Groups) bool) {
+	store, err := groupStore(api)
+	if err != nil {
+		return
+	}
+
+	groups, err := store.LoadGroups()
+	if err != nil {
+		log.Warnf("Error updating the groups of machines: %s", err)
+		return
+	}
+
+	if change(groups) {
+		if err := store.SaveGroups(groups); err != nil {
+			log.Warnf("Error updating the groups of machines: %s", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdGroupCreateAndAdd(t *testing.T) {
+	api := newBatchTestAPI()
+
+	assert.NoError(t, cmdGroupCreate(&commandstest.FakeCommandLine{CliArgs: []string{"ci", "ci-1"}}, api))
+	assert.NoError(t, cmdGroupAdd(&commandstest.FakeCommandLine{CliArgs: []string{"@ci", "ci-2", "ci-1"}}, api))
+	assert.Equal(t, persist.Groups{"ci": {"ci-1", "ci-2"}}, api.Groups)
+
+	assert.EqualError(t, cmdGroupCreate(&commandstest.FakeCommandLine{CliArgs: []string{"ci"}}, api), `Error: Group "ci" already exists`)
+	assert.EqualError(t, cmdGroupCreate(&commandstest.FakeCommandLine{CliArgs: []string{"-ci"}}, api), `Error: Invalid group name "-ci", the names of the groups follow the rules of the names of the machines`)
+	assert.EqualError(t, cmdGroupAdd(&commandstest.FakeCommandLine{CliArgs: []string{"web", "dev"}}, api), `Error: Group "web" does not exist`)
+	assert.EqualError(t, cmdGroupAdd(&commandstest.FakeCommandLine{CliArgs: []string{"ci", "missing"}}, api), `Host does not exist: "missing"`)
+	assert.Equal(t, errNoGroupName, cmdGroupAdd(&commandstest.FakeCommandLine{}, api))
+}
+
+func TestCmdGroupRemove(t *testing.T) {
+	api := newBatchTestAPI()
+	api.Groups = persist.Groups{"ci": {"ci-1", "ci-2"}, "all": {"ci-1", "ci-2", "dev"}}
+
+	assert.NoError(t, cmdGroupRemove(&commandstest.FakeCommandLine{CliArgs: []string{"all", "dev"}}, api))
+	assert.EqualError(t, cmdGroupRemove(&commandstest.FakeCommandLine{CliArgs: []string{"all", "dev"}}, api), `Error: dev is not in the group "all"`)
+	assert.NoError(t, cmdGroupRemove(&commandstest.FakeCommandLine{CliArgs: []string{"ci"}}, api))
+	assert.Equal(t, persist.Groups{"all": {"ci-1", "ci-2"}}, api.Groups)
+	assert.Len(t, api.Hosts, 3)
+}
+
+func TestCmdGroupLs(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	api := newBatchTestAPI()
+	api.Groups = persist.Groups{"ci": {"ci-1", "ci-2"}, "empty": {}}
+
+	err := cmdGroupLs(&commandstest.FakeCommandLine{}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME    MACHINES\nci      ci-1, ci-2\nempty   \n", stdoutGetter.Output())
+}
+
+func TestBatchTargetNamesWithGroups(t *testing.T) {
+	api := newBatchTestAPI()
+	api.Groups = persist.Groups{"ci": {"ci-1", "ci-2"}, "running": {"ci-1", "dev"}, "empty": {}}
+
+	names, err := batchTargetNames(&commandstest.FakeCommandLine{CliArgs: []string{"@ci", "@running"}}, api)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ci-1", "ci-2", "dev"}, names)
+
+	_, err = batchTargetNames(&commandstest.FakeCommandLine{CliArgs: []string{"@web"}}, api)
+	assert.EqualError(t, err, `Error: Group "web" does not exist`)
+
+	_, err = batchTargetNames(&commandstest.FakeCommandLine{CliArgs: []string{"@empty"}}, api)
+	assert.Equal(t, errNoMachineMatches, err)
+}
+
+func TestCmdStopGroupPartialFailure(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	api := newBatchTestAPI()
+	api.Groups = persist.Groups{"ci": {"ci-1", "ci-2"}}
+
+	err := cmdStop(&commandstest.FakeCommandLine{CliArgs: []string{"@ci"}}, api)
+
+	assert.EqualError(t, err, `Machine "ci-2" is already stopped.`)
+	assert.Equal(t, state.Stopped, libmachinetest.State(api, "ci-1"))
+	assert.Equal(t, state.Running, libmachinetest.State(api, "dev"))
+	output := stdoutGetter.Output()
+	assert.Contains(t, output, "ci-1   Done")
+	assert.Contains(t, output, "ci-2   Failed")
+}
+
+func TestCmdRmRemovesFromGroups(t *testing.T) {
+	api := newBatchTestAPI()
+	api.Groups = persist.Groups{"ci": {"ci-1", "ci-2"}}
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"ci-1"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"y": true},
+		},
+	}
+
+	assert.NoError(t, cmdRm(commandLine, api))
+	assert.Equal(t, persist.Groups{"ci": {"ci-2"}}, api.Groups)
+}
@@ -4,22 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"text/template"
 
 	"github.com/docker/machine/libmachine"
 )
 
-var funcMap = template.FuncMap{
-	"json": func(v interface{}) string {
-		a, _ := json.Marshal(v)
-		return string(a)
-	},
-	"prettyjson": func(v interface{}) string {
-		a, _ := json.MarshalIndent(v, "", "    ")
-		return string(a)
-	},
-}
-
 func cmdInspect(c CommandLine, api libmachine.API) error {
 	if len(c.Args()) > 1 {
 		c.ShowHelp()
@@ -38,10 +26,9 @@ func cmdInspect(c CommandLine, api libmachine.API) error {
 
 	tmplString := c.String("format")
 	if tmplString != "" {
-		var tmpl *template.Template
-		var err error
-		if tmpl, err = template.New("").Funcs(funcMap).Parse(tmplString); err != nil {
-			return fmt.Errorf("Template parsing error: %v\n", err)
+		tmpl, err := parseTemplate(tmplString, hostFuncMap(host))
+		if err != nil {
+			return err
 		}
 
 		jsonHost, err := json.Marshal(host)
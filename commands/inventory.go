@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/inventory"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+var (
+	errSyncNeedsOutput = errors.New("Error: --sync and --stop-sync require the file of the inventory with --output")
+)
+
+// inventorySyncActions are the actions after which the synced inventories
+// are rewritten, as they change the state or the address of the machines.
+var inventorySyncActions = map[string]bool{
+	"start":         true,
+	"stop":          true,
+	"restart":       true,
+	"kill":          true,
+	"provision":     true,
+	"configureAuth": true,
+}
+
+func cmdExportInventory(c CommandLine, api libmachine.API) error {
+	format := c.String("format")
+	if err := inventory.ValidateFormat(format); err != nil {
+		return err
+	}
+
+	filters := c.StringSlice("filter")
+	if _, err := parseFilters(filters); err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	if output == "" {
+		if c.Bool("sync") || c.Bool("stop-sync") {
+			return errSyncNeedsOutput
+		}
+
+		hosts, err := inventoryHosts(api, filters)
+		if err != nil {
+			return err
+		}
+		return inventory.Render(os.Stdout, format, hosts)
+	}
+
+	output, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+
+	configPath := inventoryConfigPath()
+	config, err := inventory.Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("stop-sync") {
+		if !config.Remove(output) {
+			return fmt.Errorf("Error: %s isn't kept in sync with the machines", output)
+		}
+		log.Infof("%s isn't kept in sync with the machines anymore", output)
+		return config.Save(configPath)
+	}
+
+	hosts, err := inventoryHosts(api, filters)
+	if err != nil {
+		return err
+	}
+
+	if err := inventory.WriteFile(output, format, hosts); err != nil {
+		return err
+	}
+
+	if c.Bool("sync") {
+		config.Add(inventory.Export{Format: format, Path: output, Filters: filters})
+		if err := config.Save(configPath); err != nil {
+			return err
+		}
+		log.Infof("%s is kept in sync with the machines", output)
+	}
+
+	return nil
+}
+
+func inventoryConfigPath() string {
+	return filepath.Join(mcndirs.GetBaseDir(), inventory.ConfigFileName)
+}
+
+// inventoryHosts returns how the running machines matching the filters are
+// reached over SSH.  The stopped machines are left out, having no address.
+func inventoryHosts(api libmachine.API, filters []string) ([]inventory.Host, error) {
+	options, err := parseFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	hostList, hostsInError, err := persist.LoadAllHosts(api)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, err := range hostsInError {
+		log.Warnf("Error loading %s, it's left out of the inventory: %s", name, err)
+	}
+
+	hostList = filterHosts(hostList, options)
+
+	type result struct {
+		host inventory.Host
+		err  error
+	}
+	results := make(chan result, len(hostList))
+
+	for _, h := range hostList {
+		go func(h *host.Host) {
+			inventoryHost, err := newInventoryHost(h)
+			results <- result{host: inventoryHost, err: err}
+		}(h)
+	}
+
+	hosts := []inventory.Host{}
+	for range hostList {
+		r := <-results
+		if r.err != nil {
+			log.Debugf("%s is left out of the inventory: %s", r.host.Name, r.err)
+			continue
+		}
+		hosts = append(hosts, r.host)
+	}
+
+	return hosts, nil
+}
+
+func newInventoryHost(h *host.Host) (inventory.Host, error) {
+	inventoryHost := inventory.Host{
+		Name:   h.Name,
+		Driver: h.DriverName,
+		User:   h.Driver.GetSSHUsername(),
+		Labels: h.Labels,
+	}
+
+	s, err := h.Driver.GetState()
+	if err != nil {
+		return inventoryHost, err
+	}
+	if s != state.Running {
+		return inventoryHost, fmt.Errorf("the machine is %s", s)
+	}
+
+	if inventoryHost.Address, err = h.Driver.GetSSHHostname(); err != nil {
+		return inventoryHost, err
+	}
+
+	if inventoryHost.Port, err = h.Driver.GetSSHPort(); err != nil {
+		return inventoryHost, err
+	}
+
+	if keyPath := h.Driver.GetSSHKeyPath(); keyPath != "" {
+		inventoryHost.KeyPath = keyPath
+		if _, err := os.Stat(ssh.CertificatePath(keyPath)); err == nil {
+			inventoryHost.CertificatePath = ssh.CertificatePath(keyPath)
+		}
+	}
+
+	proxyJump, err := drivers.GetSSHProxyJump(h.Driver)
+	if err != nil {
+		return inventoryHost, err
+	}
+	if proxyJump != nil {
+		inventoryHost.ProxyJump = proxyJump.String()
+	}
+
+	return inventoryHost, nil
+}
+
+// syncInventories rewrites the inventories exported with --sync.  It's run
+// once the machines were created, removed or changed state, and a failure
+// only logs a warning.
+func syncInventories(api libmachine.API) {
+	config, err := inventory.Load(inventoryConfigPath())
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+
+	for _, export := range config.Exports {
+		hosts, err := inventoryHosts(api, export.Filters)
+		if err == nil {
+			err = inventory.WriteFile(export.Path, export.Format, hosts)
+		}
+		if err != nil {
+			log.Warnf("Error syncing the inventory %s: %s", export.Path, err)
+			continue
+		}
+		log.Debugf("synced the inventory %s", export.Path)
+	}
+}
+
+// syncInventoriesAfter rewrites the synced inventories after an action which
+// changes the state or the address of the machines.
+func syncInventoriesAfter(actionName string, api libmachine.API) {
+	if inventorySyncActions[actionName] {
+		syncInventories(api)
+	}
+}
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/inventory"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// sshFakeDriver is a fake driver reachable over SSH.
+type sshFakeDriver struct {
+	fakedriver.Driver
+}
+
+func (d *sshFakeDriver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *sshFakeDriver) GetSSHPort() (int, error) {
+	return 22, nil
+}
+
+func (d *sshFakeDriver) GetSSHUsername() string {
+	return "docker"
+}
+
+func (d *sshFakeDriver) GetSSHKeyPath() string {
+	return "/store/machines/" + d.MockName + "/id_rsa"
+}
+
+// jumpFakeDriver is a fake driver reached through a bastion.
+type jumpFakeDriver struct {
+	sshFakeDriver
+	proxyJump string
+}
+
+func (d *jumpFakeDriver) GetSSHProxyJump() string {
+	return d.proxyJump
+}
+
+func newInventoryTestAPI() *libmachinetest.FakeAPI {
+	newHost := func(name, driverName, ip string, s state.State) *host.Host {
+		return &host.Host{
+			Name:       name,
+			DriverName: driverName,
+			Driver:     &sshFakeDriver{fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}, MockName: name, MockIP: ip, MockState: s}},
+		}
+	}
+
+	return &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newHost("web", "amazonec2", "203.0.113.10", state.Running),
+			newHost("ci-1", "kvm", "192.168.122.5", state.Running),
+			newHost("ci-2", "kvm", "192.168.122.6", state.Stopped),
+		},
+	}
+}
+
+func withTempBaseDir(t *testing.T) (string, func()) {
+	storePath, err := ioutil.TempDir("", "inventory")
+	assert.NoError(t, err)
+
+	baseDir := mcndirs.BaseDir
+	mcndirs.BaseDir = storePath
+
+	return storePath, func() {
+		mcndirs.BaseDir = baseDir
+		os.RemoveAll(storePath)
+	}
+}
+
+func TestCmdExportInventory(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"format": "ssh-config",
+				"filter": []string{"driver=kvm"},
+			},
+		},
+	}
+
+	err := cmdExportInventory(commandLine, newInventoryTestAPI())
+
+	assert.NoError(t, err)
+	output := stdoutGetter.Output()
+	assert.Contains(t, output, "Host ci-1\n    HostName 192.168.122.5\n    Port 22\n    User docker\n    IdentityFile /store/machines/ci-1/id_rsa\n")
+	assert.NotContains(t, output, "Host ci-2")
+	assert.NotContains(t, output, "Host web")
+}
+
+func TestNewInventoryHostProxyJump(t *testing.T) {
+	newHost := func(proxyJump string) *host.Host {
+		return &host.Host{
+			Name:   "private",
+			Driver: &jumpFakeDriver{sshFakeDriver{fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}, MockName: "private", MockIP: "10.0.1.5", MockState: state.Running}}, proxyJump},
+		}
+	}
+
+	inventoryHost, err := newInventoryHost(newHost("admin@bastion.example.com"))
+	assert.NoError(t, err)
+	assert.Equal(t, "admin@bastion.example.com:22", inventoryHost.ProxyJump)
+
+	inventoryHost, err = newInventoryHost(newHost("[2001:db8::1]:2222"))
+	assert.NoError(t, err)
+	assert.Equal(t, "docker@[2001:db8::1]:2222", inventoryHost.ProxyJump)
+}
+
+func TestCmdExportInventoryInvalidFormat(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"format": "ini",
+			},
+		},
+	}
+
+	err := cmdExportInventory(commandLine, newInventoryTestAPI())
+
+	assert.EqualError(t, err, `Invalid inventory format "ini", expected ansible or ssh-config`)
+}
+
+func TestCmdExportInventorySyncNeedsOutput(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"format": "ansible",
+				"sync":   true,
+			},
+		},
+	}
+
+	err := cmdExportInventory(commandLine, newInventoryTestAPI())
+
+	assert.Equal(t, errSyncNeedsOutput, err)
+}
+
+func TestCmdExportInventorySync(t *testing.T) {
+	storePath, cleanup := withTempBaseDir(t)
+	defer cleanup()
+
+	output := filepath.Join(storePath, "hosts.yml")
+	api := newInventoryTestAPI()
+
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"format": "ansible",
+				"output": output,
+				"sync":   true,
+			},
+		},
+	}
+
+	assert.NoError(t, cmdExportInventory(commandLine, api))
+
+	content, err := ioutil.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `    "web":`)
+	assert.NotContains(t, string(content), `    "ci-2":`)
+
+	config, err := inventory.Load(filepath.Join(storePath, inventory.ConfigFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, []inventory.Export{{Format: "ansible", Path: output}}, config.Exports)
+
+	// web stops
+	assert.NoError(t, runActionOnHosts("stop", api.Hosts[:1], api))
+
+	content, err = ioutil.ReadFile(output)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), `    "web":`)
+	assert.Contains(t, string(content), `    "ci-1":`)
+
+	commandLine.LocalFlags.Data["sync"] = false
+	commandLine.LocalFlags.Data["stop-sync"] = true
+	assert.NoError(t, cmdExportInventory(commandLine, api))
+
+	config, err = inventory.Load(filepath.Join(storePath, inventory.ConfigFileName))
+	assert.NoError(t, err)
+	assert.Empty(t, config.Exports)
+}
@@ -1,7 +1,71 @@
 package commands
 
-import "github.com/docker/machine/libmachine"
+import (
+	"os"
+	"text/template"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// IPItem is the IP address of a machine as printed with --output json, and
+// given to the template of --format.
+type IPItem struct {
+	Name  string
+	IP    string
+	Error string
+}
 
 func cmdIP(c CommandLine, api libmachine.API) error {
-	return runAction("ip", c, api)
+	format := c.String("format")
+	if !isJSONOutput(c) && format == "" {
+		if c.Bool("private") {
+			return runAction("privateIP", c, api)
+		}
+		return runAction("ip", c, api)
+	}
+
+	hosts, err := loadTargetHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	var tmpl *template.Template
+	if !isJSONOutput(c) {
+		if tmpl, err = parseTemplate(format+"\n", funcMap); err != nil {
+			return err
+		}
+	}
+
+	items := []IPItem{}
+	for _, h := range hosts {
+		item := IPItem{
+			Name: h.Name,
+		}
+
+		var ip string
+		if c.Bool("private") {
+			ip, err = drivers.GetPrivateIP(h.Driver)
+		} else {
+			ip, err = h.Driver.GetIP()
+		}
+		if err != nil {
+			item.Error = err.Error()
+		}
+		item.IP = ip
+
+		items = append(items, item)
+	}
+
+	if tmpl == nil {
+		return printJSON(items)
+	}
+
+	for _, item := range items {
+		if err := tmpl.Execute(os.Stdout, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -64,6 +64,59 @@ func TestCmdIP(t *testing.T) {
 			expectedErr: nil,
 			expectedOut: "1.2.3.4\n",
 		},
+		{
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"machine"},
+				GlobalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"output": "json",
+					},
+				},
+			},
+			api: &libmachinetest.FakeAPI{
+				Hosts: []*host.Host{
+					{
+						Name: "machine",
+						Driver: &fakedriver.Driver{
+							MockState: state.Running,
+							MockIP:    "1.2.3.4",
+						},
+					},
+				},
+			},
+			expectedErr: nil,
+			expectedOut: `[
+    {
+        "Name": "machine",
+        "IP": "1.2.3.4",
+        "Error": ""
+    }
+]
+`,
+		},
+		{
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"machine"},
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"format": "{{.Name}}={{.IP}}",
+					},
+				},
+			},
+			api: &libmachinetest.FakeAPI{
+				Hosts: []*host.Host{
+					{
+						Name: "machine",
+						Driver: &fakedriver.Driver{
+							MockState: state.Running,
+							MockIP:    "1.2.3.4",
+						},
+					},
+				},
+			},
+			expectedErr: nil,
+			expectedOut: "machine=1.2.3.4\n",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -77,3 +130,65 @@ func TestCmdIP(t *testing.T) {
 		stdoutGetter.Stop()
 	}
 }
+
+type privateNetworkDriver struct {
+	*fakedriver.Driver
+	privateIP string
+}
+
+func (d *privateNetworkDriver) GetPrivateIP() (string, error) {
+	return d.privateIP, nil
+}
+
+func TestCmdIPPrivate(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "private",
+				Driver: &privateNetworkDriver{
+					Driver:    &fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"},
+					privateIP: "10.0.0.2",
+				},
+			},
+			{
+				Name:   "public",
+				Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.5"},
+			},
+		},
+	}
+
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	err := cmdIP(&commandstest.FakeCommandLine{
+		CliArgs:    []string{"private"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{"private": true}},
+	}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2\n", stdoutGetter.Output())
+
+	stdoutGetter = commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	err = cmdIP(&commandstest.FakeCommandLine{
+		CliArgs:     []string{"private", "public"},
+		LocalFlags:  &commandstest.FakeFlagger{Data: map[string]interface{}{"private": true}},
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{"output": "json"}},
+	}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `[
+    {
+        "Name": "private",
+        "IP": "10.0.0.2",
+        "Error": ""
+    },
+    {
+        "Name": "public",
+        "IP": "",
+        "Error": "The driver does not support private networks"
+    }
+]
+`, stdoutGetter.Output())
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/k3s"
+	"github.com/docker/machine/libmachine/kubernetes"
+)
+
+func cmdKubeconfig(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	path, err := kubeconfigPath(h)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("path") {
+		fmt.Println(path)
+		return nil
+	}
+
+	kubeconfig, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading the kubeconfig of %s: %s", h.Name, err)
+	}
+
+	fmt.Print(string(kubeconfig))
+
+	return nil
+}
+
+// kubeconfigPath returns the path of the admin kubeconfig of a Kubernetes
+// control plane or a k3s server, stored in its machine directory.
+func kubeconfigPath(h *host.Host) (string, error) {
+	options := h.HostOptions
+
+	var name string
+	switch {
+	case options.KubernetesOptions.IsControlPlane():
+		name = kubernetes.KubeconfigFile
+	case options.K3sOptions.IsK3s() && options.K3sOptions.Server:
+		name = k3s.KubeconfigFile
+	default:
+		return "", fmt.Errorf("Error: %s is not a Kubernetes control plane or a k3s server", h.Name)
+	}
+
+	return filepath.Join(options.AuthOptions.StorePath, name), nil
+}
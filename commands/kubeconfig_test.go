@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/k3s"
+	"github.com/docker/machine/libmachine/kubernetes"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdKubeconfig(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "kubeconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(storePath, kubernetes.KubeconfigFile), []byte("server: https://10.0.0.1:6443\n"), 0600))
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "cp",
+				Driver: &fakedriver.Driver{},
+				HostOptions: &host.Options{
+					AuthOptions:       &auth.Options{StorePath: storePath},
+					KubernetesOptions: &kubernetes.Options{Role: kubernetes.RoleControlPlane},
+				},
+			},
+		},
+	}
+
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	err = cmdKubeconfig(&commandstest.FakeCommandLine{CliArgs: []string{"cp"}}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "server: https://10.0.0.1:6443\n", stdoutGetter.Output())
+}
+
+func TestKubeconfigPath(t *testing.T) {
+	h := &host.Host{
+		Name: "server",
+		HostOptions: &host.Options{
+			AuthOptions: &auth.Options{StorePath: "/machines/server"},
+			K3sOptions:  &k3s.Options{Server: true},
+		},
+	}
+
+	path, err := kubeconfigPath(h)
+
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/machines/server", k3s.KubeconfigFile), path)
+
+	h.Name = "worker"
+	h.HostOptions.K3sOptions = nil
+	h.HostOptions.KubernetesOptions = &kubernetes.Options{Role: kubernetes.RoleWorker}
+
+	_, err = kubeconfigPath(h)
+
+	assert.EqualError(t, err, "Error: worker is not a Kubernetes control plane or a k3s server")
+}
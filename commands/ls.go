@@ -7,13 +7,16 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"text/template"
 	"time"
 
 	"io"
 
+	"github.com/docker/go-units"
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/host"
@@ -26,9 +29,11 @@ import (
 )
 
 const (
-	lsDefaultTimeout = 10
-	tableFormatKey   = "table"
-	lsDefaultFormat  = "table {{ .Name }}\t{{ .Active }}\t{{ .DriverName}}\t{{ .State }}\t{{ .URL }}\t{{ .Swarm }}\t{{ .DockerVersion }}\t{{ .Error}}"
+	lsDefaultTimeout  = 10
+	lsDefaultParallel = 10
+	tableFormatKey    = "table"
+	lsDefaultFormat   = "table {{ .Name }}\t{{ .Active }}\t{{ .DriverName}}\t{{ .State }}\t{{ .URL }}\t{{ .Swarm }}\t{{ .DockerVersion }}\t{{ .CertExpires }}\t{{ .Labels }}\t{{ .Error}}"
+	lsStatsFormat     = "table {{ .Name }}\t{{ .Active }}\t{{ .DriverName}}\t{{ .State }}\t{{ .Containers }}\t{{ .ImagesSize }}\t{{ .Memory }}\t{{ .DockerVersion }}\t{{ .Error}}"
 )
 
 var (
@@ -45,8 +50,15 @@ var (
 		"EngineOptions": "ENGINE_OPTIONS",
 		"Error":         "ERRORS",
 		"DockerVersion": "DOCKER",
+		"CertExpires":   "CERT_EXPIRES",
 		"ResponseTime":  "RESPONSE",
+		"Labels":        "LABELS",
+		"Containers":    "CONTAINERS",
+		"ImagesSize":    "IMAGES",
+		"Memory":        "MEMORY",
 	}
+
+	errStatsWithCached = errors.New("Error: --stats can't be combined with --cached")
 )
 
 type HostListItem struct {
@@ -62,7 +74,37 @@ type HostListItem struct {
 	EngineOptions *engine.Options
 	Error         string
 	DockerVersion string
+	CertExpires   string
 	ResponseTime  time.Duration
+	Labels        string
+
+	// Stats is the resource usage of the engine, listed with --stats.
+	Stats *mcndockerclient.EngineStats `json:",omitempty"`
+}
+
+// Containers returns the running and total containers of the engine, e.g.
+// 2/5.
+func (item HostListItem) Containers() string {
+	if item.Stats == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", item.Stats.ContainersRunning, item.Stats.Containers)
+}
+
+// ImagesSize returns the disk usage of the images of the engine.
+func (item HostListItem) ImagesSize() string {
+	if item.Stats == nil {
+		return ""
+	}
+	return units.HumanSize(float64(item.Stats.ImagesSize))
+}
+
+// Memory returns the memory of the machine, as reported by the engine.
+func (item HostListItem) Memory() string {
+	if item.Stats == nil {
+		return ""
+	}
+	return units.BytesSize(float64(item.Stats.MemTotal))
 }
 
 // FilterOptions -
@@ -72,6 +114,10 @@ type FilterOptions struct {
 	State      []string
 	Name       []string
 	Labels     []string
+
+	// Cached matches the state filter against the state cached by the
+	// previous listing instead of querying the driver.
+	Cached bool
 }
 
 func cmdLs(c CommandLine, api libmachine.API) error {
@@ -79,6 +125,11 @@ func cmdLs(c CommandLine, api libmachine.API) error {
 	if err != nil {
 		return err
 	}
+	filters.Cached = c.Bool("cached")
+
+	if c.Bool("stats") && c.Bool("cached") {
+		return errStatsWithCached
+	}
 
 	hostList, hostInError, err := persist.LoadAllHosts(api)
 	if err != nil {
@@ -89,13 +140,32 @@ func cmdLs(c CommandLine, api libmachine.API) error {
 
 	// Just print out the names if we're being quiet
 	if c.Bool("quiet") {
+		if isJSONOutput(c) {
+			names := []string{}
+			for _, host := range hostList {
+				names = append(names, host.Name)
+			}
+			return printJSON(names)
+		}
+
 		for _, host := range hostList {
 			fmt.Println(host.Name)
 		}
 		return nil
 	}
 
-	template, table, err := parseFormat(c.String("format"))
+	if isJSONOutput(c) {
+		items := listHosts(c, api, hostList, hostInError)
+		setSwarmColumns(hostList, items)
+		return printJSON(items)
+	}
+
+	format := c.String("format")
+	if format == "" && c.Bool("stats") {
+		format = lsStatsFormat
+	}
+
+	template, table, err := parseFormat(format)
 	if err != nil {
 		return err
 	}
@@ -114,9 +184,153 @@ func cmdLs(c CommandLine, api libmachine.API) error {
 		w = os.Stdout
 	}
 
+	items := listHosts(c, api, hostList, hostInError)
+	setSwarmColumns(hostList, items)
+
+	for _, item := range items {
+		if err := template.Execute(w, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listHosts returns the items listing the hosts, either by polling them or
+// from the state cached in the store by the previous listing.
+func listHosts(c CommandLine, store persist.Store, hostList []*host.Host, hostsInError map[string]error) []HostListItem {
+	if c.Bool("cached") {
+		return getCachedHostListItems(hostList, hostsInError)
+	}
+
 	timeout := time.Duration(c.Int("timeout")) * time.Second
-	items := getHostListItems(hostList, hostInError, timeout)
+	items := getHostListItems(hostList, hostsInError, timeout, c.Int("parallel"))
+	saveLastKnown(store, hostList, items)
+
+	if c.Bool("stats") {
+		setEngineStats(hostList, items, timeout, c.Int("parallel"))
+	}
+
+	return items
+}
+
+// setEngineStats queries the resource usage of the engines of the running
+// hosts over their API, at most parallel at a time, each one for at most
+// timeout.
+func setEngineStats(hostList []*host.Host, items []HostListItem, timeout time.Duration, parallel int) {
+	if parallel <= 0 {
+		parallel = lsDefaultParallel
+	}
+
+	hostsByName := make(map[string]*host.Host, len(hostList))
+	for _, h := range hostList {
+		hostsByName[h.Name] = h
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+
+	for i := range items {
+		item := &items[i]
+		h, ok := hostsByName[item.Name]
+		if !ok || item.State != state.Running || item.URL == "" || h.IsContainerdEndpoint() {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			stats, err := getEngineStats(h, item.URL, timeout)
+			if err != nil {
+				if item.Error == "" {
+					item.Error = err.Error()
+				}
+				return
+			}
+			item.Stats = stats
+		}()
+	}
+
+	wg.Wait()
+}
+
+// getEngineStats queries the resource usage of the engine of a host, giving
+// up after timeout.
+func getEngineStats(h *host.Host, url string, timeout time.Duration) (*mcndockerclient.EngineStats, error) {
+	type result struct {
+		stats *mcndockerclient.EngineStats
+		err   error
+	}
+
+	// Buffered so that a query giving up after the timeout doesn't block
+	// forever
+	results := make(chan result, 1)
+	go func() {
+		stats, err := mcndockerclient.GetEngineStats(&mcndockerclient.RemoteDocker{
+			HostURL:    url,
+			AuthOption: h.AuthOptions(),
+		})
+		results <- result{stats, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.stats, r.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("Timeout querying the resource usage of the engine after %s", timeout)
+	}
+}
+
+// saveLastKnown caches the state of the listed hosts in the store. The hosts
+// which couldn't be queried keep the state they were last listed in.  The
+// configuration of each host is loaded again before saving it, so that the
+// changes made by other commands while the hosts were queried are kept.
+func saveLastKnown(store persist.Store, hostList []*host.Host, items []HostListItem) {
+	itemsByName := make(map[string]HostListItem, len(items))
+	for _, item := range items {
+		itemsByName[item.Name] = item
+	}
+
+	for _, h := range hostList {
+		item, ok := itemsByName[h.Name]
+		if !ok || item.Error != "" || item.State == state.Timeout || item.State == state.Error {
+			continue
+		}
+
+		if h.LastKnown != nil && h.LastKnown.State == item.State && h.LastKnown.URL == item.URL && h.LastKnown.DockerVersion == item.DockerVersion {
+			continue
+		}
+
+		h.LastKnown = &host.LastKnown{
+			State:         item.State,
+			URL:           item.URL,
+			DockerVersion: item.DockerVersion,
+			Since:         time.Now(),
+		}
+		if err := saveHostLastKnown(store, h.Name, h.LastKnown); err != nil {
+			log.Debugf("Error caching the state of %s: %s", h.Name, err)
+		}
+	}
+}
+
+// saveHostLastKnown sets the cached state of a host in its current
+// configuration in the store.
+func saveHostLastKnown(store persist.Store, name string, lastKnown *host.LastKnown) error {
+	current, err := store.Load(name)
+	if err != nil {
+		return err
+	}
 
+	current.LastKnown = lastKnown
+	return store.Save(current)
+}
+
+// setSwarmColumns fills the Swarm column of the items with the name of their
+// Swarm master.
+func setSwarmColumns(hostList []*host.Host, items []HostListItem) {
 	swarmMasters := make(map[string]string)
 	swarmInfo := make(map[string]string)
 
@@ -133,7 +347,7 @@ func cmdLs(c CommandLine, api libmachine.API) error {
 		}
 	}
 
-	for _, item := range items {
+	for i, item := range items {
 		swarmColumn := ""
 		if item.SwarmOptions != nil && item.SwarmOptions.Discovery != "" {
 			swarmColumn = swarmMasters[item.SwarmOptions.Discovery]
@@ -141,14 +355,8 @@ func cmdLs(c CommandLine, api libmachine.API) error {
 				swarmColumn = fmt.Sprintf("%s (master)", swarmColumn)
 			}
 		}
-		item.Swarm = swarmColumn
-
-		if err := template.Execute(w, item); err != nil {
-			return err
-		}
+		items[i].Swarm = swarmColumn
 	}
-
-	return nil
 }
 
 func parseFormat(format string) (*template.Template, bool, error) {
@@ -165,10 +373,8 @@ func parseFormat(format string) (*template.Template, bool, error) {
 	}
 
 	finalFormat = strings.Trim(finalFormat, " ")
-	r := strings.NewReplacer(`\t`, "\t", `\n`, "\n")
-	finalFormat = r.Replace(finalFormat)
 
-	template, err := template.New("").Parse(finalFormat + "\n")
+	template, err := parseTemplate(finalFormat+"\n", funcMap)
 	if err != nil {
 		return nil, false, err
 	}
@@ -239,7 +445,7 @@ func getSwarmMasters(hosts []*host.Host) map[string]string {
 func filterHost(host *host.Host, filters FilterOptions, swarmMasters map[string]string) bool {
 	swarmMatches := matchesSwarmName(host, filters.SwarmName, swarmMasters)
 	driverMatches := matchesDriverName(host, filters.DriverName)
-	stateMatches := matchesState(host, filters.State)
+	stateMatches := matchesState(host, filters.State, filters.Cached)
 	nameMatches := matchesName(host, filters.Name)
 	labelMatches := matchesLabel(host, filters.Labels)
 
@@ -272,14 +478,21 @@ func matchesDriverName(host *host.Host, driverNames []string) bool {
 	return false
 }
 
-func matchesState(host *host.Host, states []string) bool {
+func matchesState(host *host.Host, states []string, cached bool) bool {
 	if len(states) == 0 {
 		return true
 	}
 	for _, n := range states {
-		s, err := host.Driver.GetState()
-		if err != nil {
-			log.Warn(err)
+		var s state.State
+		if cached {
+			if host.LastKnown != nil {
+				s = host.LastKnown.State
+			}
+		} else {
+			var err error
+			if s, err = host.Driver.GetState(); err != nil {
+				log.Warn(err)
+			}
 		}
 		if strings.EqualFold(n, s.String()) {
 			return true
@@ -305,23 +518,32 @@ func matchesName(host *host.Host, names []string) bool {
 	return false
 }
 
+// matchesLabel returns true if the machine has one of the labels, given as
+// key or key=value, either as a label of the machine or of its engine.
 func matchesLabel(host *host.Host, labels []string) bool {
 	if len(labels) == 0 {
 		return true
 	}
 
-	var englabels = make(map[string]string, len(host.HostOptions.EngineOptions.Labels))
-
-	if host.HostOptions != nil && host.HostOptions.EngineOptions.Labels != nil {
+	allLabels := map[string]string{}
+	if host.HostOptions != nil && host.HostOptions.EngineOptions != nil {
 		for _, s := range host.HostOptions.EngineOptions.Labels {
 			kv := strings.SplitN(s, "=", 2)
-			englabels[kv[0]] = kv[1]
+			if len(kv) == 2 {
+				allLabels[kv[0]] = kv[1]
+			} else {
+				allLabels[kv[0]] = ""
+			}
 		}
 	}
+	for key, value := range host.Labels {
+		allLabels[key] = value
+	}
 
 	for _, l := range labels {
 		kv := strings.SplitN(l, "=", 2)
-		if val, exists := englabels[kv[0]]; exists && strings.EqualFold(val, kv[1]) {
+		val, exists := allLabels[kv[0]]
+		if exists && (len(kv) == 1 || strings.EqualFold(val, kv[1])) {
 			return true
 		}
 	}
@@ -352,7 +574,11 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 		currentState, _ = h.Driver.GetState()
 	}
 
-	if err == nil && url != "" {
+	if err == nil && url != "" && h.IsContainerdEndpoint() {
+		// The engine URL serves the containerd API, which has no Docker
+		// version to report.
+		dockerVersion = host.EndpointContainerd
+	} else if err == nil && url != "" {
 		// PERFORMANCE: Reuse the url instead of asking the host again.
 		// This reduces the number of calls to the drivers
 		dockerHost := &mcndockerclient.RemoteDocker{
@@ -375,6 +601,14 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 		hostError = ""
 	}
 
+	item := newHostListItem(h, currentState, url, dockerVersion, hostError)
+	item.ResponseTime = time.Now().Round(time.Millisecond).Sub(requestBeginning.Round(time.Millisecond))
+
+	stateQueryChan <- item
+}
+
+// newHostListItem returns the item listing a host in the given state.
+func newHostListItem(h *host.Host, currentState state.State, url, dockerVersion, hostError string) HostListItem {
 	var swarmOptions *swarm.Options
 	var engineOptions *engine.Options
 	if h.HostOptions != nil {
@@ -382,6 +616,13 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 		engineOptions = h.HostOptions.EngineOptions
 	}
 
+	certExpires := ""
+	if authOptions := h.AuthOptions(); authOptions != nil && authOptions.ServerCertPath != "" {
+		if _, notAfter, err := cert.ReadCertificateValidity(authOptions.ServerCertPath); err == nil {
+			certExpires = notAfter.Local().Format("2006-01-02")
+		}
+	}
+
 	isMaster := false
 	swarmHost := ""
 	if swarmOptions != nil {
@@ -399,7 +640,7 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 		active = "* (swarm)"
 	}
 
-	stateQueryChan <- HostListItem{
+	return HostListItem{
 		Name:          h.Name,
 		Active:        active,
 		ActiveHost:    activeHost,
@@ -410,15 +651,30 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 		SwarmOptions:  swarmOptions,
 		EngineOptions: engineOptions,
 		DockerVersion: dockerVersion,
+		CertExpires:   certExpires,
 		Error:         hostError,
-		ResponseTime:  time.Now().Round(time.Millisecond).Sub(requestBeginning.Round(time.Millisecond)),
+		Labels:        formatLabels(h.Labels),
+	}
+}
+
+// formatLabels returns the labels of a machine as key=value pairs sorted by
+// key, separated by commas.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
 	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
 }
 
 func getHostState(h *host.Host, hostListItemsChan chan<- HostListItem, timeout time.Duration) {
 	// This channel is used to communicate the properties we are querying
 	// about the host in the case of a successful read.
-	stateQueryChan := make(chan HostListItem)
+	// It's buffered so that a query giving up after the timeout doesn't
+	// block forever.
+	stateQueryChan := make(chan HostListItem, 1)
 
 	go attemptGetHostState(h, stateQueryChan)
 
@@ -435,20 +691,38 @@ func getHostState(h *host.Host, hostListItemsChan chan<- HostListItem, timeout t
 			DriverName:   h.Driver.DriverName(),
 			State:        state.Timeout,
 			ResponseTime: timeout,
+			Labels:       formatLabels(h.Labels),
 		}
 	}
 }
 
-func getHostListItems(hostList []*host.Host, hostsInError map[string]error, timeout time.Duration) []HostListItem {
-	log.Debugf("timeout set to %s", timeout)
+// getHostListItems queries the hosts, at most parallel at a time, each one
+// for at most timeout.
+func getHostListItems(hostList []*host.Host, hostsInError map[string]error, timeout time.Duration, parallel int) []HostListItem {
+	if parallel <= 0 {
+		parallel = lsDefaultParallel
+	}
+	log.Debugf("timeout set to %s, querying %d hosts at a time", timeout, parallel)
 
 	hostListItems := []HostListItem{}
 	hostListItemsChan := make(chan HostListItem)
+	hostsChan := make(chan *host.Host)
 
-	for _, h := range hostList {
-		go getHostState(h, hostListItemsChan, timeout)
+	for i := 0; i < parallel; i++ {
+		go func() {
+			for h := range hostsChan {
+				getHostState(h, hostListItemsChan, timeout)
+			}
+		}()
 	}
 
+	go func() {
+		for _, h := range hostList {
+			hostsChan <- h
+		}
+		close(hostsChan)
+	}()
+
 	for range hostList {
 		hostListItems = append(hostListItems, <-hostListItemsChan)
 	}
@@ -463,6 +737,28 @@ func getHostListItems(hostList []*host.Host, hostsInError map[string]error, time
 	return hostListItems
 }
 
+// getCachedHostListItems lists the hosts in the state cached by the previous
+// listing, without querying them.
+func getCachedHostListItems(hostList []*host.Host, hostsInError map[string]error) []HostListItem {
+	hostListItems := []HostListItem{}
+
+	for _, h := range hostList {
+		if h.LastKnown == nil {
+			hostListItems = append(hostListItems, newHostListItem(h, state.None, "", "Unknown", "No cached state"))
+			continue
+		}
+
+		hostListItems = append(hostListItems, newHostListItem(h, h.LastKnown.State, h.LastKnown.URL, h.LastKnown.DockerVersion, ""))
+	}
+
+	for name, err := range hostsInError {
+		hostListItems = append(hostListItems, newHostListItemInError(name, err))
+	}
+
+	sortHostListItemsByName(hostListItems)
+	return hostListItems
+}
+
 func newHostListItemInError(name string, err error) HostListItem {
 	return HostListItem{
 		Name:       name,
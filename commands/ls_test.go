@@ -1,17 +1,25 @@
 package commands
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"time"
 
 	"errors"
 
+	"github.com/docker/machine/commands/commandstest"
 	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
 	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/persist"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
 	"github.com/stretchr/testify/assert"
@@ -111,6 +119,27 @@ func TestFilterHostsReturnSetLabel(t *testing.T) {
 	assert.EqualValues(t, actual, hosts)
 }
 
+func TestFilterHostsByMachineLabel(t *testing.T) {
+	web := &host.Host{Name: "web", Labels: map[string]string{"env": "ci", "team": "web"}}
+	db := &host.Host{Name: "db", Labels: map[string]string{"env": "prod"}}
+	engineHost := &host.Host{
+		Name: "engine",
+		HostOptions: &host.Options{
+			EngineOptions: &engine.Options{Labels: []string{"team"}},
+		},
+	}
+	hosts := []*host.Host{web, db, engineHost}
+
+	assert.Equal(t, []*host.Host{web}, filterHosts(hosts, FilterOptions{Labels: []string{"env=ci"}}))
+	assert.Equal(t, []*host.Host{web, engineHost}, filterHosts(hosts, FilterOptions{Labels: []string{"team"}}))
+	assert.Equal(t, []*host.Host{web, db}, filterHosts(hosts, FilterOptions{Labels: []string{"env=ci", "env=prod"}}))
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "env=ci,team=web", formatLabels(map[string]string{"team": "web", "env": "ci"}))
+	assert.Equal(t, "", formatLabels(nil))
+}
+
 func TestFilterHostsReturnsEmptyGivenEmptyHosts(t *testing.T) {
 	opts := FilterOptions{
 		SwarmName: []string{"foo"},
@@ -345,7 +374,7 @@ func TestGetHostListItems(t *testing.T) {
 		{"foo", state.Running, true, "v1.9", ""},
 	}
 
-	items := getHostListItems(hosts, map[string]error{}, 10*time.Second)
+	items := getHostListItems(hosts, map[string]error{}, 10*time.Second, lsDefaultParallel)
 
 	for i := range expected {
 		assert.Equal(t, expected[i].name, items[i].Name)
@@ -394,7 +423,7 @@ func TestGetHostListItemsEnvDockerHostUnset(t *testing.T) {
 		"baz": {state.Saved, false},
 	}
 
-	items := getHostListItems(hosts, map[string]error{}, 10*time.Second)
+	items := getHostListItems(hosts, map[string]error{}, 10*time.Second, lsDefaultParallel)
 
 	for _, item := range items {
 		expected := expected[item.Name]
@@ -466,7 +495,7 @@ func TestGetHostStateTimeout(t *testing.T) {
 		},
 	}
 
-	hostItem := getHostListItems(hosts, nil, time.Millisecond)[0]
+	hostItem := getHostListItems(hosts, nil, time.Millisecond, lsDefaultParallel)[0]
 
 	assert.Equal(t, "foo", hostItem.Name)
 	assert.Equal(t, state.Timeout, hostItem.State)
@@ -484,7 +513,7 @@ func TestGetHostStateError(t *testing.T) {
 		},
 	}
 
-	hostItem := getHostListItems(hosts, nil, 10*time.Second)[0]
+	hostItem := getHostListItems(hosts, nil, 10*time.Second, lsDefaultParallel)[0]
 
 	assert.Equal(t, "foo", hostItem.Name)
 	assert.Equal(t, state.Error, hostItem.State)
@@ -494,6 +523,33 @@ func TestGetHostStateError(t *testing.T) {
 	assert.Nil(t, hostItem.SwarmOptions)
 }
 
+func TestGetHostCertExpires(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath := filepath.Join(dir, "server.pem")
+	assert.NoError(t, cert.GenerateCACertificate(certPath, filepath.Join(dir, "server-key.pem"), "test-org", 2048))
+	_, notAfter, err := cert.ReadCertificateValidity(certPath)
+	assert.NoError(t, err)
+
+	hosts := []*host.Host{
+		{
+			Name: "foo",
+			Driver: &fakedriver.Driver{
+				MockState: state.Stopped,
+			},
+			HostOptions: &host.Options{
+				AuthOptions: &auth.Options{ServerCertPath: certPath},
+			},
+		},
+	}
+
+	hostItem := getHostListItems(hosts, nil, 10*time.Second, lsDefaultParallel)[0]
+
+	assert.Equal(t, notAfter.Local().Format("2006-01-02"), hostItem.CertExpires)
+}
+
 func TestGetSomeHostInError(t *testing.T) {
 	defer func(versioner mcndockerclient.DockerVersioner) { mcndockerclient.CurrentDockerVersioner = versioner }(mcndockerclient.CurrentDockerVersioner)
 	mcndockerclient.CurrentDockerVersioner = &mcndockerclient.FakeDockerVersioner{Version: "1.9"}
@@ -510,7 +566,7 @@ func TestGetSomeHostInError(t *testing.T) {
 		"bar": errors.New("invalid memory address or nil pointer dereference"),
 	}
 
-	hostItems := getHostListItems(hosts, hostsInError, 10*time.Second)
+	hostItems := getHostListItems(hosts, hostsInError, 10*time.Second, lsDefaultParallel)
 	assert.Equal(t, 2, len(hostItems))
 
 	hostItem := hostItems[0]
@@ -533,3 +589,174 @@ func TestOnErrorWithMultilineComment(t *testing.T) {
 
 	assert.Equal(t, itemInError.Error, "MissingParameter: The request must contain the parameter InstanceId 	status code: 400, request id:")
 }
+
+func TestGetHostListItemsQueriesAllHostsWithBoundedParallelism(t *testing.T) {
+	hosts := []*host.Host{}
+	for i := 0; i < 20; i++ {
+		hosts = append(hosts, &host.Host{
+			Name: fmt.Sprintf("foo%d", i),
+			Driver: &fakedriver.Driver{
+				MockState: state.Stopped,
+			},
+		})
+	}
+
+	items := getHostListItems(hosts, nil, 10*time.Second, 3)
+
+	assert.Equal(t, 20, len(items))
+	for _, item := range items {
+		assert.Equal(t, state.Stopped, item.State)
+	}
+}
+
+func TestSaveLastKnown(t *testing.T) {
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	hosts := []*host.Host{
+		{Name: "running"},
+		{Name: "unchanged", LastKnown: &host.LastKnown{State: state.Stopped, DockerVersion: "Unknown", Since: since}},
+		{Name: "timeout", LastKnown: &host.LastKnown{State: state.Running, URL: "tcp://1.2.3.4:2376", Since: since}},
+		{Name: "error"},
+	}
+	items := []HostListItem{
+		{Name: "running", State: state.Running, URL: "tcp://5.6.7.8:2376", DockerVersion: "v1.9"},
+		{Name: "unchanged", State: state.Stopped, DockerVersion: "Unknown"},
+		{Name: "timeout", State: state.Timeout},
+		{Name: "error", State: state.Error, Error: "Unable to get ip"},
+	}
+
+	saveLastKnown(&libmachinetest.FakeAPI{}, hosts, items)
+
+	assert.Equal(t, state.Running, hosts[0].LastKnown.State)
+	assert.Equal(t, "tcp://5.6.7.8:2376", hosts[0].LastKnown.URL)
+	assert.Equal(t, "v1.9", hosts[0].LastKnown.DockerVersion)
+	assert.False(t, hosts[0].LastKnown.Since.IsZero())
+	assert.Equal(t, since, hosts[1].LastKnown.Since)
+	assert.Equal(t, state.Running, hosts[2].LastKnown.State)
+	assert.Nil(t, hosts[3].LastKnown)
+}
+
+func TestSaveLastKnownKeepsConfig(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "machine-ls-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	store := persist.NewFilestore(storePath, "", "")
+	assert.NoError(t, store.Save(&host.Host{
+		ConfigVersion: 3,
+		Name:          "dev",
+		DriverName:    "fakedriver",
+		Driver:        &fakedriver.Driver{MockName: "dev"},
+		HostOptions:   &host.Options{},
+		Labels:        map[string]string{"env": "prod"},
+	}))
+
+	// The host as loaded by ls, before another command labelled it
+	listed := &host.Host{Name: "dev", DriverName: "fakedriver", Driver: &fakedriver.Driver{MockName: "dev"}, HostOptions: &host.Options{}}
+
+	saveLastKnown(store, []*host.Host{listed}, []HostListItem{{Name: "dev", State: state.Running, URL: "tcp://5.6.7.8:2376"}})
+
+	saved, err := store.Load("dev")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod"}, saved.Labels)
+	assert.Equal(t, state.Running, saved.LastKnown.State)
+	assert.Equal(t, "tcp://5.6.7.8:2376", saved.LastKnown.URL)
+}
+
+func TestGetCachedHostListItems(t *testing.T) {
+	defer func(host string) { os.Setenv("DOCKER_HOST", host) }(os.Getenv("DOCKER_HOST"))
+	os.Setenv("DOCKER_HOST", "tcp://1.2.3.4:2376")
+
+	hosts := []*host.Host{
+		{
+			Name:      "foo",
+			Driver:    &fakedriver.Driver{MockState: state.Stopped},
+			LastKnown: &host.LastKnown{State: state.Running, URL: "tcp://1.2.3.4:2376", DockerVersion: "v1.9"},
+		},
+		{
+			Name:   "bar",
+			Driver: &fakedriver.Driver{MockState: state.Running},
+		},
+	}
+
+	items := getCachedHostListItems(hosts, nil)
+
+	assert.Equal(t, "bar", items[0].Name)
+	assert.Equal(t, state.None, items[0].State)
+	assert.Equal(t, "No cached state", items[0].Error)
+
+	assert.Equal(t, "foo", items[1].Name)
+	assert.Equal(t, state.Running, items[1].State)
+	assert.Equal(t, "tcp://1.2.3.4:2376", items[1].URL)
+	assert.Equal(t, "v1.9", items[1].DockerVersion)
+	assert.True(t, items[1].ActiveHost)
+}
+
+func TestFilterHostsByCachedState(t *testing.T) {
+	opts := FilterOptions{
+		State:  []string{"Running"},
+		Cached: true,
+	}
+	hosts := []*host.Host{
+		{
+			Name:      "foo",
+			Driver:    &fakedriver.Driver{MockState: state.Stopped},
+			LastKnown: &host.LastKnown{State: state.Running},
+		},
+		{
+			Name:   "bar",
+			Driver: &fakedriver.Driver{MockState: state.Running},
+		},
+	}
+
+	assert.Equal(t, []*host.Host{hosts[0]}, filterHosts(hosts, opts))
+}
+
+func TestSetEngineStats(t *testing.T) {
+	defer func(statser mcndockerclient.EngineStatser) { mcndockerclient.CurrentEngineStatser = statser }(mcndockerclient.CurrentEngineStatser)
+	mcndockerclient.CurrentEngineStatser = &mcndockerclient.FakeEngineStatser{
+		Stats: &mcndockerclient.EngineStats{Containers: 5, ContainersRunning: 2, ImagesSize: 1500000000, MemTotal: 2147483648},
+	}
+
+	hosts := []*host.Host{
+		{Name: "foo", Driver: &fakedriver.Driver{}},
+		{Name: "bar", Driver: &fakedriver.Driver{}},
+	}
+	items := []HostListItem{
+		{Name: "bar", State: state.Stopped},
+		{Name: "foo", State: state.Running, URL: "tcp://1.2.3.4:2376"},
+	}
+
+	setEngineStats(hosts, items, 10*time.Second, lsDefaultParallel)
+
+	assert.Nil(t, items[0].Stats)
+	assert.Equal(t, "", items[0].Containers())
+	assert.Equal(t, "2/5", items[1].Containers())
+	assert.Equal(t, "1.5 GB", items[1].ImagesSize())
+	assert.Equal(t, "2 GiB", items[1].Memory())
+}
+
+func TestSetEngineStatsError(t *testing.T) {
+	defer func(statser mcndockerclient.EngineStatser) { mcndockerclient.CurrentEngineStatser = statser }(mcndockerclient.CurrentEngineStatser)
+	mcndockerclient.CurrentEngineStatser = &mcndockerclient.FakeEngineStatser{Err: errors.New("Unable to query docker system df: 404 Not Found")}
+
+	hosts := []*host.Host{{Name: "foo", Driver: &fakedriver.Driver{}}}
+	items := []HostListItem{{Name: "foo", State: state.Running, URL: "tcp://1.2.3.4:2376"}}
+
+	setEngineStats(hosts, items, 10*time.Second, lsDefaultParallel)
+
+	assert.Nil(t, items[0].Stats)
+	assert.Equal(t, "Unable to query docker system df: 404 Not Found", items[0].Error)
+}
+
+func TestCmdLsStatsWithCached(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"stats":  true,
+				"cached": true,
+			},
+		},
+	}
+
+	assert.Equal(t, errStatsWithCached, cmdLs(commandLine, &libmachinetest.FakeAPI{}))
+}
@@ -13,11 +13,16 @@ var (
 
 func GetBaseDir() string {
 	if BaseDir == "" {
-		BaseDir = filepath.Join(mcnutils.GetHomeDir(), ".docker", "machine")
+		BaseDir = GetDefaultBaseDir()
 	}
 	return BaseDir
 }
 
+// GetDefaultBaseDir returns the storage path used when none is given.
+func GetDefaultBaseDir() string {
+	return filepath.Join(mcnutils.GetHomeDir(), ".docker", "machine")
+}
+
 func GetMachineDir() string {
 	return filepath.Join(GetBaseDir(), "machines")
 }
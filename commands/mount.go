@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	machinesync "github.com/docker/machine/libmachine/sync"
+)
+
+var (
+	errNoMountRemote = errors.New("Error: Expected the directory of the machine as machine:path")
+	errSyncUnmount   = errors.New("Error: --unmount doesn't apply to --sync, stop the sync with Ctrl-C")
+)
+
+func cmdMount(c CommandLine, api libmachine.API) error {
+	args := c.Args()
+	if len(args) != 2 {
+		c.ShowHelp()
+		return errWrongNumberArguments
+	}
+
+	if c.Bool("sync") && c.Bool("unmount") {
+		return errSyncUnmount
+	}
+
+	remote, err := getMountRemote(args[0], api)
+	if err != nil {
+		return err
+	}
+
+	local := args[1]
+
+	if c.Bool("sync") {
+		return runMountSync(local, remote, c.Int("sync-interval"))
+	}
+
+	cmd, err := getMountCmd(remote, local, c.Bool("unmount"))
+	if err != nil {
+		return err
+	}
+
+	return runCmdWithStdIo(*cmd)
+}
+
+// getMountRemote returns the directory of the machine given as
+// machine:path.
+func getMountRemote(machineAndPath string, api libmachine.API) (*machinesync.Remote, error) {
+	parts := strings.SplitN(machineAndPath, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errNoMountRemote
+	}
+
+	h, err := api.Load(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := h.Driver.GetSSHHostname()
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := h.Driver.GetSSHPort()
+	if err != nil {
+		return nil, err
+	}
+
+	remote := &machinesync.Remote{
+		User:    h.Driver.GetSSHUsername(),
+		Host:    hostname,
+		Port:    port,
+		KeyPath: h.Driver.GetSSHKeyPath(),
+		Path:    parts[1],
+	}
+
+	proxyJump, err := drivers.GetSSHProxyJump(h.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if proxyJump != nil {
+		remote.ProxyJump = proxyJump.String()
+	}
+
+	return remote, nil
+}
+
+// getMountCmd returns the sshfs command mounting the directory of the
+// machine on the local directory, or the command unmounting it.
+func getMountCmd(remote *machinesync.Remote, local string, unmount bool) (*exec.Cmd, error) {
+	name, args := mountCmdArgs(remote, local, unmount, runtime.GOOS)
+
+	cmdPath, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("Error: You must have a copy of the %s binary locally to use the mount feature.", name)
+	}
+
+	cmd := exec.Command(cmdPath, args...)
+	log.Debug(*cmd)
+	return cmd, nil
+}
+
+func mountCmdArgs(remote *machinesync.Remote, local string, unmount bool, goos string) (string, []string) {
+	if unmount {
+		if goos == "linux" {
+			return "fusermount", []string{"-u", local}
+		}
+		return "umount", []string{local}
+	}
+
+	args := []string{remote.Location(), local}
+	for _, option := range remote.SSHOptions() {
+		args = append(args, "-o", option)
+	}
+
+	return "sshfs", args
+}
+
+// runMountSync syncs the local directory with the directory of the machine
+// until interrupted.
+func runMountSync(local string, remote *machinesync.Remote, interval int) error {
+	syncer, err := machinesync.NewSyncer(local, *remote)
+	if err != nil {
+		return err
+	}
+
+	if interval > 0 {
+		syncer.RemoteInterval = time.Duration(interval) * time.Second
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	stop := make(chan struct{})
+	go func() {
+		if _, ok := <-interrupts; ok {
+			close(stop)
+		}
+	}()
+
+	return syncer.Run(stop)
+}
+
+func runCmdWithStdIo(cmd exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/generic"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	machinesync "github.com/docker/machine/libmachine/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdMountArguments(t *testing.T) {
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdMount(&commandstest.FakeCommandLine{CliArgs: []string{"dev:/src"}}, api)
+	assert.Equal(t, errWrongNumberArguments, err)
+
+	err = cmdMount(&commandstest.FakeCommandLine{
+		CliArgs: []string{"dev:/src", "src"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"sync":    true,
+			"unmount": true,
+		}},
+	}, api)
+	assert.Equal(t, errSyncUnmount, err)
+
+	err = cmdMount(&commandstest.FakeCommandLine{
+		CliArgs:    []string{"/src", "src"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}, api)
+	assert.Equal(t, errNoMountRemote, err)
+}
+
+func TestGetMountRemote(t *testing.T) {
+	driver := generic.NewDriver("dev", "/store").(*generic.Driver)
+	driver.IPAddress = "1.2.3.4"
+	driver.SSHUser = "ubuntu"
+	driver.SSHPort = 2222
+	driver.SSHKeyPath = "/keys/id_rsa"
+	driver.SSHProxyJump = "admin@bastion"
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{{Name: "dev", Driver: driver}},
+	}
+
+	remote, err := getMountRemote("dev:/home/ubuntu/src", api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &machinesync.Remote{
+		User:      "ubuntu",
+		Host:      "1.2.3.4",
+		Port:      2222,
+		KeyPath:   "/keys/id_rsa",
+		ProxyJump: "admin@bastion:22",
+		Path:      "/home/ubuntu/src",
+	}, remote)
+}
+
+func TestMountCmdArgs(t *testing.T) {
+	remote := &machinesync.Remote{User: "docker", Host: "1.2.3.4", Port: 22, Path: "/src"}
+
+	name, args := mountCmdArgs(remote, "src", false, "linux")
+	assert.Equal(t, "sshfs", name)
+	assert.Equal(t, []string{
+		"docker@1.2.3.4:/src", "src",
+		"-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=quiet",
+		"-o", "Port=22",
+	}, args)
+
+	name, args = mountCmdArgs(remote, "src", true, "linux")
+	assert.Equal(t, "fusermount", name)
+	assert.Equal(t, []string{"-u", "src"}, args)
+
+	name, args = mountCmdArgs(remote, "src", true, "darwin")
+	assert.Equal(t, "umount", name)
+	assert.Equal(t, []string{"src"}, args)
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/crashreport"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// errJSONReported is returned by commands which failed but already printed
+// their errors as part of their JSON output.
+var errJSONReported = errors.New("Error: see the JSON output for details")
+
+// JSONError is how an error is reported with --output json.
+type JSONError struct {
+	Code    string
+	Message string
+}
+
+// CreateItem is the outcome of the creation of a machine as printed with
+// --output json.
+type CreateItem struct {
+	Name       string
+	DriverName string     `json:",omitempty"`
+	URL        string     `json:",omitempty"`
+	Error      *JSONError `json:",omitempty"`
+}
+
+// BatchItem is the outcome of an action, e.g. start or stop, on one of several
+// machines as printed with --output json.
+type BatchItem struct {
+	Name  string
+	Error *JSONError `json:",omitempty"`
+}
+
+// PlanItem is the plan of the creation of a machine as printed with
+// --dry-run --output json.
+type PlanItem struct {
+	Name       string
+	DriverName string
+	Steps      []libmachine.PlanStep
+}
+
+func validateOutput(output string) error {
+	switch output {
+	case "", outputText, outputJSON:
+		return nil
+	}
+
+	return fmt.Errorf("Error: Unknown output format %q, expected one of: [%s, %s]", output, outputText, outputJSON)
+}
+
+// isJSONOutput returns true if the command should print JSON instead of text.
+func isJSONOutput(c CommandLine) bool {
+	return c.GlobalString("output") == outputJSON
+}
+
+func printJSON(v interface{}) error {
+	prettyJSON, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(prettyJSON))
+
+	return nil
+}
+
+func printJSONError(err error) error {
+	return printJSON(struct {
+		Error JSONError
+	}{newJSONError(err)})
+}
+
+func newJSONError(err error) JSONError {
+	return JSONError{
+		Code:    errorCode(err),
+		Message: err.Error(),
+	}
+}
+
+// errorCode returns a stable identifier for the errors automation is likely
+// to act upon.
+func errorCode(err error) string {
+	switch e := err.(type) {
+	case crashreport.CrashError:
+		return errorCode(e.Cause)
+	case mcnerror.ErrHostDoesNotExist:
+		return "HostDoesNotExist"
+	case mcnerror.ErrHostAlreadyExists:
+		return "HostAlreadyExists"
+	case mcnerror.ErrHostAlreadyInState:
+		return "HostAlreadyInState"
+	case mcnerror.ErrDuringPreCreate:
+		return "PreCreateCheckFailed"
+	}
+
+	switch err {
+	case mcnerror.ErrInvalidHostname:
+		return "InvalidHostname"
+	case ErrNoDefault:
+		return "NoDefaultMachine"
+	case ErrHostLoad:
+		return "HostLoadFailed"
+	case ErrNoMachineSpecified, ErrExpectedOneMachine, ErrTooManyArguments, errNoMachineName, errNoSnapshotName:
+		return "InvalidArguments"
+	case drivers.ErrSnapshotsNotSupported:
+		return "SnapshotsNotSupported"
+	}
+
+	return "Error"
+}
+
+func printCreatedJSON(h *host.Host) error {
+	url, err := h.URL()
+	if err != nil {
+		return err
+	}
+
+	return printJSON(CreateItem{
+		Name:       h.Name,
+		DriverName: h.DriverName,
+		URL:        url,
+	})
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/crashreport"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOutput(t *testing.T) {
+	assert.NoError(t, validateOutput(""))
+	assert.NoError(t, validateOutput("text"))
+	assert.NoError(t, validateOutput("json"))
+	assert.Error(t, validateOutput("yaml"))
+}
+
+func TestErrorCode(t *testing.T) {
+	testCases := []struct {
+		err          error
+		expectedCode string
+	}{
+		{mcnerror.ErrHostDoesNotExist{Name: "foo"}, "HostDoesNotExist"},
+		{mcnerror.ErrHostAlreadyExists{Name: "foo"}, "HostAlreadyExists"},
+		{mcnerror.ErrInvalidHostname, "InvalidHostname"},
+		{ErrNoDefault, "NoDefaultMachine"},
+		{ErrExpectedOneMachine, "InvalidArguments"},
+		{crashreport.CrashError{Cause: mcnerror.ErrDuringPreCreate{Cause: errors.New("bad")}}, "PreCreateCheckFailed"},
+		{errors.New("something else"), "Error"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expectedCode, errorCode(tc.err))
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	vars := envVars(&ShellConfig{
+		DockerCertPath:  "/path",
+		DockerHost:      "tcp://1.2.3.4:2376",
+		DockerTLSVerify: "1",
+		MachineName:     "machine",
+		NoProxyVar:      "NO_PROXY",
+		NoProxyValue:    "1.2.3.4",
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"DOCKER_TLS_VERIFY":   "1",
+		"DOCKER_HOST":         "tcp://1.2.3.4:2376",
+		"DOCKER_CERT_PATH":    "/path",
+		"DOCKER_MACHINE_NAME": "machine",
+		"NO_PROXY":            "1.2.3.4",
+	}, vars)
+}
+
+func TestEnvVarsContainerd(t *testing.T) {
+	vars := envVars(&ShellConfig{
+		DockerCertPath:    "/path",
+		MachineName:       "machine",
+		ContainerdAddress: "tcp://1.2.3.4:2376",
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"CONTAINERD_ADDRESS":  "tcp://1.2.3.4:2376",
+		"DOCKER_CERT_PATH":    "/path",
+		"DOCKER_MACHINE_NAME": "machine",
+	}, vars)
+}
+
+func TestEnvVarsUnset(t *testing.T) {
+	vars := envVars(&ShellConfig{
+		NoProxyVar: "no_proxy",
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"DOCKER_TLS_VERIFY":   nil,
+		"DOCKER_HOST":         nil,
+		"DOCKER_CERT_PATH":    nil,
+		"DOCKER_MACHINE_NAME": nil,
+		"no_proxy":            nil,
+	}, vars)
+}
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+const (
+	portForwardPidFile   = "port-forward.pid"
+	portForwardLogFile   = "port-forward.log"
+	portForwardDaemonEnv = "MACHINE_PORT_FORWARD_DAEMON"
+)
+
+var portForwardStartTimeout = 30 * time.Second
+
+func cmdPortForward(c CommandLine, api libmachine.API) error {
+	args := c.Args()
+
+	if c.Bool("stop") {
+		if len(args) != 1 {
+			c.ShowHelp()
+			return errWrongNumberArguments
+		}
+		return stopPortForward(args[0])
+	}
+
+	if len(args) < 2 {
+		c.ShowHelp()
+		return errWrongNumberArguments
+	}
+
+	forwards := []ssh.Forward{}
+	for _, arg := range args[1:] {
+		forward, err := ssh.ParseForward(arg)
+		if err != nil {
+			return err
+		}
+		forwards = append(forwards, forward)
+	}
+
+	h, err := api.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	if pid, running := portForwardPid(h.Name); running {
+		return fmt.Errorf("Error: The ports of %s are already forwarded in the background (pid %d), stop it with: %s port-forward --stop %s", h.Name, pid, os.Args[0], h.Name)
+	}
+
+	if c.Bool("daemon") {
+		return startPortForwardDaemon(h.Name, childPortForwardArgs(os.Args[1:]))
+	}
+
+	return runPortForward(h, forwards)
+}
+
+// runPortForward forwards the ports until interrupted.  In the background,
+// the process also records its pid for --stop.
+func runPortForward(h *host.Host, forwards []ssh.Forward) error {
+	tunnel, err := newPortForwardTunnel(h, forwards)
+	if err != nil {
+		return err
+	}
+
+	if err := tunnel.Start(); err != nil {
+		return err
+	}
+	for _, forward := range forwards {
+		log.Infof("Forwarding %s on %s", forward, h.Name)
+	}
+
+	if os.Getenv(portForwardDaemonEnv) != "" {
+		// The terminal the daemon was started from may be closed.
+		signal.Ignore(syscall.SIGHUP)
+
+		pidFile := portForwardPath(h.Name, portForwardPidFile)
+		if err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+			return err
+		}
+		defer os.Remove(pidFile)
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+
+	stop := make(chan struct{})
+	go func() {
+		if _, ok := <-interrupts; ok {
+			close(stop)
+		}
+	}()
+
+	tunnel.Run(stop)
+	return nil
+}
+
+func newPortForwardTunnel(h *host.Host, forwards []ssh.Forward) (*ssh.Tunnel, error) {
+	hostname, err := h.Driver.GetSSHHostname()
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := h.Driver.GetSSHPort()
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &ssh.Auth{}
+	if h.Driver.GetSSHKeyPath() != "" {
+		auth.Keys = []string{h.Driver.GetSSHKeyPath()}
+	}
+
+	proxyJump, err := drivers.GetSSHProxyJump(h.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewTunnel(h.Driver.GetSSHUsername(), hostname, port, auth, proxyJump, forwards)
+}
+
+// startPortForwardDaemon runs the port forwarding of the machine in another
+// process, logging to the directory of the machine, and returns once the
+// ports are forwarded.
+func startPortForwardDaemon(name string, args []string) error {
+	logPath := portForwardPath(name, portForwardLogFile)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(childEnv(), portForwardDaemonEnv+"=1")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	timeout := time.After(portForwardStartTimeout)
+	for {
+		if pid, running := portForwardPid(name); running && pid == cmd.Process.Pid {
+			log.Infof("Forwarding the ports of %s in the background (pid %d), logging to %s", name, pid, logPath)
+			return nil
+		}
+
+		select {
+		case <-exited:
+			return fmt.Errorf("Error forwarding the ports of %s, see %s", name, logPath)
+		case <-timeout:
+			cmd.Process.Kill()
+			return fmt.Errorf("Error forwarding the ports of %s: timed out, see %s", name, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// stopPortForward stops the port forwarding of the machine running in the
+// background.
+func stopPortForward(name string) error {
+	pidFile := portForwardPath(name, portForwardPidFile)
+
+	pid, running := portForwardPid(name)
+	if !running {
+		os.Remove(pidFile)
+		return fmt.Errorf("Error: The ports of %s aren't forwarded in the background", name)
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := process.Signal(os.Interrupt); err != nil {
+		if err := process.Kill(); err != nil {
+			return err
+		}
+		os.Remove(pidFile)
+	}
+
+	log.Infof("Stopped forwarding the ports of %s", name)
+	return nil
+}
+
+// portForwardPid returns the pid of the port forwarding of the machine
+// running in the background, if any.
+func portForwardPid(name string) (int, bool) {
+	content, err := ioutil.ReadFile(portForwardPath(name, portForwardPidFile))
+	if err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return 0, false
+	}
+
+	// FindProcess only finds running processes on Windows, and always
+	// succeeds elsewhere.
+	if runtime.GOOS != "windows" && process.Signal(syscall.Signal(0)) != nil {
+		return 0, false
+	}
+
+	return pid, true
+}
+
+func portForwardPath(name, file string) string {
+	return filepath.Join(mcndirs.GetMachineDir(), name, file)
+}
+
+// childPortForwardArgs rewrites the arguments of the current invocation into
+// the ones of the process forwarding the ports in the background.
+func childPortForwardArgs(args []string) []string {
+	childArgs := []string{}
+	for _, arg := range args {
+		switch strings.TrimLeft(arg, "-") {
+		case "daemon", "d", "daemon=true", "d=true":
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+		}
+		childArgs = append(childArgs, arg)
+	}
+	return childArgs
+}
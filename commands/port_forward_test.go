@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdPortForwardArguments(t *testing.T) {
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdPortForward(&commandstest.FakeCommandLine{
+		CliArgs:    []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}, api)
+	assert.Equal(t, errWrongNumberArguments, err)
+
+	err = cmdPortForward(&commandstest.FakeCommandLine{
+		CliArgs: []string{"dev", "8080:80"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{
+			"stop": true,
+		}},
+	}, api)
+	assert.Equal(t, errWrongNumberArguments, err)
+
+	err = cmdPortForward(&commandstest.FakeCommandLine{
+		CliArgs:    []string{"dev", "8080"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}, api)
+	assert.EqualError(t, err, `Invalid forward "8080", expected [BIND:]PORT:[HOST:]HOSTPORT`)
+}
+
+func TestChildPortForwardArgs(t *testing.T) {
+	args := childPortForwardArgs([]string{"--debug", "port-forward", "dev", "8080:80", "--daemon", "-d", "--daemon=true", "d"})
+
+	assert.Equal(t, []string{"--debug", "port-forward", "dev", "8080:80", "d"}, args)
+}
+
+func TestPortForwardPid(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "machine-port-forward-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	defer func(baseDir string) { mcndirs.BaseDir = baseDir }(mcndirs.BaseDir)
+	mcndirs.BaseDir = storePath
+
+	machineDir := filepath.Join(mcndirs.GetMachineDir(), "dev")
+	assert.NoError(t, os.MkdirAll(machineDir, 0700))
+
+	_, running := portForwardPid("dev")
+	assert.False(t, running)
+
+	pidFile := filepath.Join(machineDir, portForwardPidFile)
+	assert.NoError(t, ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0600))
+
+	pid, running := portForwardPid("dev")
+	assert.True(t, running)
+	assert.Equal(t, os.Getpid(), pid)
+
+	assert.NoError(t, ioutil.WriteFile(pidFile, []byte("not a pid"), 0600))
+
+	_, running = portForwardPid("dev")
+	assert.False(t, running)
+
+	err = stopPortForward("dev")
+	assert.EqualError(t, err, "Error: The ports of dev aren't forwarded in the background")
+	_, err = os.Stat(pidFile)
+	assert.True(t, os.IsNotExist(err))
+}
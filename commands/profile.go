@@ -0,0 +1,341 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+)
+
+var (
+	errNoProfileName = errors.New("Error: Expected a profile name as argument")
+
+	// profilesPath is where the profiles are saved, in the default storage
+	// path whatever the storage path of the profile in use.
+	profilesPath = func() string {
+		return filepath.Join(mcndirs.GetDefaultBaseDir(), "profiles.json")
+	}
+
+	// activeProfile is the profile the command runs with, if any.
+	activeProfile *Profile
+)
+
+// Profile is a named storage for the machines, with the defaults of the
+// driver flags to create them with, e.g. for the machines of each account of
+// a cloud provider.
+type Profile struct {
+	Name        string
+	StoragePath string
+	StorageURI  string            `json:",omitempty"`
+	Driver      string            `json:",omitempty"`
+	DriverOpts  map[string]string `json:",omitempty"`
+}
+
+// ProfileItem is a profile as printed with --output json.
+type ProfileItem struct {
+	Profile
+	Active bool
+}
+
+// profiles are the profiles and the one selected with profile use.
+type profiles struct {
+	Current  string     `json:",omitempty"`
+	Profiles []*Profile `json:",omitempty"`
+}
+
+func loadProfiles() (*profiles, error) {
+	data, err := ioutil.ReadFile(profilesPath())
+	if os.IsNotExist(err) {
+		return &profiles{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &profiles{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("Error reading the profiles from %s: %s", profilesPath(), err)
+	}
+
+	return p, nil
+}
+
+func (p *profiles) save() error {
+	sort.Slice(p.Profiles, func(i, j int) bool {
+		return p.Profiles[i].Name < p.Profiles[j].Name
+	})
+
+	data, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(profilesPath()), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(profilesPath(), data, 0600)
+}
+
+func (p *profiles) find(name string) *Profile {
+	for _, profile := range p.Profiles {
+		if profile.Name == name {
+			return profile
+		}
+	}
+	return nil
+}
+
+// selectProfile loads the profile given by --profile, or else the one selected
+// with profile use, and returns the storage the command runs with.  The
+// storage path and the storage URI given by their flags or their environment
+// variables take precedence over the ones of the profile.
+func selectProfile(c CommandLine) (string, string, error) {
+	activeProfile = nil
+	storagePath := c.GlobalString("storage-path")
+	storageURI := c.GlobalString("storage-uri")
+
+	p, err := loadProfiles()
+	if err != nil {
+		return storagePath, storageURI, err
+	}
+
+	name := c.GlobalString("profile")
+	if name == "" {
+		name = p.Current
+	}
+	if name == "" {
+		return storagePath, storageURI, nil
+	}
+
+	profile := p.find(name)
+	if profile == nil {
+		return storagePath, storageURI, fmt.Errorf("Profile %q does not exist, see '%s profile list'", name, os.Args[0])
+	}
+	activeProfile = profile
+
+	if storagePath == "" || storagePath == mcndirs.GetDefaultBaseDir() {
+		storagePath = profile.StoragePath
+	}
+	if storageURI == "" {
+		storageURI = profile.StorageURI
+	}
+
+	return storagePath, storageURI, nil
+}
+
+// driverName returns the driver given by --driver, or else the one of the
+// active profile.
+func driverName(c CommandLine) string {
+	if !c.IsSet("driver") && os.Getenv("MACHINE_DRIVER") == "" && activeProfile != nil && activeProfile.Driver != "" {
+		return activeProfile.Driver
+	}
+	return c.String("driver")
+}
+
+// setProfileDriverOpts sets the driver flags which aren't given on the command
+// line to their value in the active profile.
+func setProfileDriverOpts(c CommandLine, mcnflags []mcnflag.Flag, driverOpts rpcdriver.RPCFlags) {
+	if activeProfile == nil {
+		return
+	}
+
+	for _, f := range mcnflags {
+		name := f.String()
+		value, ok := activeProfile.DriverOpts[name]
+		if !ok || c.IsSet(name) {
+			continue
+		}
+
+		switch f.(type) {
+		case mcnflag.IntFlag, *mcnflag.IntFlag:
+			i, err := strconv.Atoi(value)
+			if err != nil {
+				log.Warnf("Ignoring the option %s=%s of the profile %q: %s", name, value, activeProfile.Name, err)
+				continue
+			}
+			driverOpts.Values[name] = i
+		case mcnflag.BoolFlag, *mcnflag.BoolFlag:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Warnf("Ignoring the option %s=%s of the profile %q: %s", name, value, activeProfile.Name, err)
+				continue
+			}
+			driverOpts.Values[name] = b
+		case mcnflag.StringSliceFlag, *mcnflag.StringSliceFlag:
+			driverOpts.Values[name] = strings.Split(value, ",")
+		default:
+			driverOpts.Values[name] = value
+		}
+	}
+}
+
+func cmdProfileAdd(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return errNoProfileName
+	}
+	name := c.Args()[0]
+
+	p, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	if p.find(name) != nil {
+		return fmt.Errorf("Profile %q already exists", name)
+	}
+
+	profile := &Profile{
+		Name:        name,
+		StoragePath: c.String("storage-path"),
+		StorageURI:  c.String("storage-uri"),
+		Driver:      c.String("driver"),
+	}
+	if profile.StoragePath == "" {
+		profile.StoragePath = filepath.Join(mcndirs.GetDefaultBaseDir(), "profiles", name)
+	}
+	if profile.StoragePath, err = filepath.Abs(profile.StoragePath); err != nil {
+		return err
+	}
+
+	for _, opt := range c.StringSlice("driver-opt") {
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("Invalid driver option %q, expected flag=value", opt)
+		}
+		if profile.DriverOpts == nil {
+			profile.DriverOpts = map[string]string{}
+		}
+		profile.DriverOpts[strings.TrimLeft(parts[0], "-")] = parts[1]
+	}
+
+	p.Profiles = append(p.Profiles, profile)
+	if err := p.save(); err != nil {
+		return err
+	}
+
+	log.Infof("Profile %q stores its machines in %s", name, profile.StoragePath)
+	return nil
+}
+
+func cmdProfileList(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 0 {
+		return ErrTooManyArguments
+	}
+
+	p, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	if isJSONOutput(c) {
+		items := []ProfileItem{}
+		for _, profile := range p.Profiles {
+			items = append(items, ProfileItem{*profile, activeProfile != nil && activeProfile.Name == profile.Name})
+		}
+		return printJSON(items)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NAME\tACTIVE\tDRIVER\tSTORAGE")
+	for _, profile := range p.Profiles {
+		active := "-"
+		if activeProfile != nil && activeProfile.Name == profile.Name {
+			active = "*"
+		}
+
+		storage := profile.StoragePath
+		if profile.StorageURI != "" {
+			storage = profile.StorageURI
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", profile.Name, active, profile.Driver, storage)
+	}
+
+	return nil
+}
+
+func cmdProfileUse(c CommandLine, api libmachine.API) error {
+	p, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("unset") {
+		if len(c.Args()) > 0 {
+			return ErrTooManyArguments
+		}
+		p.Current = ""
+		if err := p.save(); err != nil {
+			return err
+		}
+
+		log.Info("No profile is used anymore")
+		return nil
+	}
+
+	if len(c.Args()) != 1 {
+		return errNoProfileName
+	}
+	name := c.Args()[0]
+
+	if p.find(name) == nil {
+		return fmt.Errorf("Profile %q does not exist", name)
+	}
+
+	p.Current = name
+	if err := p.save(); err != nil {
+		return err
+	}
+
+	log.Infof("Using the profile %q", name)
+	return nil
+}
+
+func cmdProfileRm(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return errNoProfileName
+	}
+	name := c.Args()[0]
+
+	p, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	removed := p.find(name)
+	if removed == nil {
+		return fmt.Errorf("Profile %q does not exist", name)
+	}
+
+	kept := []*Profile{}
+	for _, profile := range p.Profiles {
+		if profile != removed {
+			kept = append(kept, profile)
+		}
+	}
+
+	p.Profiles = kept
+	if p.Current == name {
+		p.Current = ""
+	}
+	if err := p.save(); err != nil {
+		return err
+	}
+
+	log.Infof("Removed the profile %q, its machines are left in %s", name, removed.StoragePath)
+	return nil
+}
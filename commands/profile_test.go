@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func withProfilesPath(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "machine-profiles")
+	assert.NoError(t, err)
+
+	profilesPath = func() string {
+		return filepath.Join(dir, "profiles.json")
+	}
+
+	return func() {
+		os.RemoveAll(dir)
+		profilesPath = func() string {
+			return filepath.Join(mcndirs.GetDefaultBaseDir(), "profiles.json")
+		}
+		activeProfile = nil
+	}
+}
+
+func TestProfileAddAndUse(t *testing.T) {
+	defer withProfilesPath(t)()
+
+	err := cmdProfileAdd(&commandstest.FakeCommandLine{
+		CliArgs: []string{"work"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"storage-path": "/tmp/work",
+				"driver":       "amazonec2",
+				"driver-opt":   []string{"--amazonec2-region=eu-west-1", "amazonec2-root-size=32"},
+			},
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	err = cmdProfileAdd(&commandstest.FakeCommandLine{CliArgs: []string{"work"}, LocalFlags: &commandstest.FakeFlagger{}}, nil)
+	assert.EqualError(t, err, `Profile "work" already exists`)
+
+	err = cmdProfileUse(&commandstest.FakeCommandLine{CliArgs: []string{"work"}, LocalFlags: &commandstest.FakeFlagger{}}, nil)
+	assert.NoError(t, err)
+
+	storagePath, storageURI, err := selectProfile(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"storage-path": mcndirs.GetDefaultBaseDir()},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/work", storagePath)
+	assert.Equal(t, "", storageURI)
+	assert.Equal(t, &Profile{
+		Name:        "work",
+		StoragePath: "/tmp/work",
+		Driver:      "amazonec2",
+		DriverOpts:  map[string]string{"amazonec2-region": "eu-west-1", "amazonec2-root-size": "32"},
+	}, activeProfile)
+}
+
+func TestSelectProfileExplicitStoragePath(t *testing.T) {
+	defer withProfilesPath(t)()
+
+	p := &profiles{Profiles: []*Profile{{Name: "work", StoragePath: "/tmp/work", StorageURI: "s3://bucket/work"}}}
+	assert.NoError(t, p.save())
+
+	storagePath, storageURI, err := selectProfile(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"profile": "work", "storage-path": "/tmp/other"},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/other", storagePath)
+	assert.Equal(t, "s3://bucket/work", storageURI)
+}
+
+func TestSelectProfileUnknown(t *testing.T) {
+	defer withProfilesPath(t)()
+
+	_, _, err := selectProfile(&commandstest.FakeCommandLine{
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"profile": "home"},
+		},
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, activeProfile)
+}
+
+func TestSetProfileDriverOpts(t *testing.T) {
+	defer withProfilesPath(t)()
+
+	activeProfile = &Profile{
+		Name: "work",
+		DriverOpts: map[string]string{
+			"amazonec2-region":      "eu-west-1",
+			"amazonec2-root-size":   "32",
+			"amazonec2-use-private": "true",
+			"amazonec2-subnets":     "subnet-a,subnet-b",
+			"amazonec2-zone":        "b",
+		},
+	}
+	flags := []mcnflag.Flag{
+		mcnflag.StringFlag{Name: "amazonec2-region"},
+		mcnflag.IntFlag{Name: "amazonec2-root-size"},
+		mcnflag.BoolFlag{Name: "amazonec2-use-private"},
+		mcnflag.StringSliceFlag{Name: "amazonec2-subnets"},
+		mcnflag.StringFlag{Name: "amazonec2-zone"},
+	}
+	driverOpts := rpcdriver.RPCFlags{Values: map[string]interface{}{}}
+
+	setProfileDriverOpts(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"amazonec2-zone": "c"},
+		},
+	}, flags, driverOpts)
+
+	assert.Equal(t, map[string]interface{}{
+		"amazonec2-region":      "eu-west-1",
+		"amazonec2-root-size":   32,
+		"amazonec2-use-private": true,
+		"amazonec2-subnets":     []string{"subnet-a", "subnet-b"},
+	}, driverOpts.Values)
+}
+
+func TestProfileRm(t *testing.T) {
+	defer withProfilesPath(t)()
+
+	p := &profiles{Current: "work", Profiles: []*Profile{{Name: "home"}, {Name: "work"}}}
+	assert.NoError(t, p.save())
+
+	assert.NoError(t, cmdProfileRm(&commandstest.FakeCommandLine{CliArgs: []string{"work"}}, nil))
+	assert.EqualError(t, cmdProfileRm(&commandstest.FakeCommandLine{CliArgs: []string{"work"}}, nil), `Profile "work" does not exist`)
+
+	p, err := loadProfiles()
+	assert.NoError(t, err)
+	assert.Equal(t, &profiles{Profiles: []*Profile{{Name: "home"}}}, p)
+}
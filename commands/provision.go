@@ -1,7 +1,73 @@
 package commands
 
-import "github.com/docker/machine/libmachine"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/provision"
+)
+
+var errForceWithoutPhase = errors.New("Error: --force only applies to the phases given with --phase, the whole provisioning always runs")
 
 func cmdProvision(c CommandLine, api libmachine.API) error {
-	return runAction("provision", c, api)
+	if c.String("phase") == "" {
+		if c.Bool("force") {
+			return errForceWithoutPhase
+		}
+		return runAction("provision", c, api)
+	}
+
+	phases, err := provision.ParsePhases(c.String("phase"))
+	if err != nil {
+		return err
+	}
+
+	hosts, err := loadTargetHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	return provisionPhases(hosts, api, phases, c.Bool("force"))
+}
+
+// provisionPhases runs the phases of the provisioning on the machines
+// concurrently, and saves the ones provisioned.
+func provisionPhases(hosts []*host.Host, api libmachine.API, phases []provision.Phase, force bool) error {
+	resultChan := make(chan actionResult)
+	for _, h := range hosts {
+		go func(h *host.Host) {
+			err := h.ProvisionPhases(phases, force)
+			if err == nil {
+				fireActionHooks("provision", h)
+			}
+			resultChan <- actionResult{name: h.Name, err: err}
+		}(h)
+	}
+
+	results := map[string]error{}
+	for range hosts {
+		result := <-resultChan
+		results[result.name] = result.err
+	}
+	close(resultChan)
+
+	errs := []error{}
+	for _, h := range hosts {
+		if err := results[h.Name]; err != nil {
+			errs = append(errs, fmt.Errorf("Error provisioning %s: %s", h.Name, err))
+			continue
+		}
+
+		if err := api.Save(h); err != nil {
+			errs = append(errs, fmt.Errorf("Error saving host to store: %s", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
 }
@@ -65,3 +65,25 @@ func TestCmdProvision(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, cmdProvision(tc.commandLine, tc.api))
 	}
 }
+
+func TestCmdProvisionPhaseErrors(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{{Name: "foo", Driver: &fakedriver.Driver{}}},
+	}
+
+	err := cmdProvision(&commandstest.FakeCommandLine{
+		CliArgs: []string{"foo"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"force": true},
+		},
+	}, api)
+	assert.Equal(t, errForceWithoutPhase, err)
+
+	err = cmdProvision(&commandstest.FakeCommandLine{
+		CliArgs: []string{"foo"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"phase": "engine,network"},
+		},
+	}, api)
+	assert.EqualError(t, err, `Error: unknown provisioning phase "network", expected some of: hostname, engine, certs, swarm`)
+}
@@ -1,11 +1,20 @@
 package commands
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
 )
 
 func cmdRegenerateCerts(c CommandLine, api libmachine.API) error {
+	if c.String("rotate-interval") != "" {
+		return rotateCerts(c, api)
+	}
+
 	if !c.Bool("force") {
 		ok, err := confirmInput("Regenerate TLS machine certs?  Warning: this is irreversible.")
 		if err != nil {
@@ -21,3 +30,51 @@ func cmdRegenerateCerts(c CommandLine, api libmachine.API) error {
 
 	return runAction("configureAuth", c, api)
 }
+
+// rotateCerts sets the rotation interval of the machines, and rotates the
+// server certificates which are due.  It doesn't ask for confirmation so that
+// it can be scheduled, e.g. with cron.  The certificates are then also
+// rotated by watch.
+func rotateCerts(c CommandLine, api libmachine.API) error {
+	interval, err := time.ParseDuration(c.String("rotate-interval"))
+	if err != nil || interval < 0 {
+		return fmt.Errorf("Invalid rotation interval %q, expected a duration such as 720h", c.String("rotate-interval"))
+	}
+
+	hosts, err := loadTargetHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	due := []*host.Host{}
+	for _, h := range hosts {
+		authOptions := h.AuthOptions()
+		if authOptions == nil {
+			continue
+		}
+		authOptions.RotateInterval = interval
+
+		needsRotation, err := cert.NeedsRotation(authOptions.ServerCertPath, interval, time.Now())
+		if err != nil {
+			return fmt.Errorf("Error reading the server certificate of %s: %s", h.Name, err)
+		}
+
+		if needsRotation {
+			due = append(due, h)
+			continue
+		}
+
+		log.Infof("The certificates of %s aren't due for rotation", h.Name)
+		if err := api.Save(h); err != nil {
+			return fmt.Errorf("Error saving host to store: %s", err)
+		}
+	}
+
+	if len(due) == 0 {
+		return nil
+	}
+
+	log.Infof("Rotating TLS certificates")
+
+	return runActionOnHosts("rotateCerts", due, api)
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdRegenerateCertsInvalidRotateInterval(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"rotate-interval": "monthly"},
+		},
+	}
+
+	err := cmdRegenerateCerts(commandLine, &libmachinetest.FakeAPI{})
+	assert.EqualError(t, err, `Invalid rotation interval "monthly", expected a duration such as 720h`)
+}
+
+func TestCmdRegenerateCertsRotateIntervalNotDue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath := filepath.Join(dir, "server.pem")
+	assert.NoError(t, cert.GenerateCACertificate(certPath, filepath.Join(dir, "server-key.pem"), "test-org", 2048))
+
+	h := &host.Host{
+		Name:   "dev",
+		Driver: &fakedriver.Driver{},
+		HostOptions: &host.Options{
+			AuthOptions: &auth.Options{ServerCertPath: certPath},
+		},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"rotate-interval": "720h"},
+		},
+	}
+
+	err = cmdRegenerateCerts(commandLine, &libmachinetest.FakeAPI{Hosts: []*host.Host{h}})
+	assert.NoError(t, err)
+	assert.Equal(t, 720*time.Hour, h.HostOptions.AuthOptions.RotateInterval)
+}
@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/state"
+)
+
+var (
+	errNoRenameNames = errors.New("Error: Expected the name of the machine and its new name as arguments")
+)
+
+func cmdRename(c CommandLine, api libmachine.API) error {
+	switch {
+	case len(c.Args()) > 2:
+		return ErrTooManyArguments
+	case len(c.Args()) < 2:
+		return errNoRenameNames
+	}
+
+	oldName, name := c.Args()[0], c.Args()[1]
+
+	if !host.ValidateHostName(name) {
+		return fmt.Errorf("Error renaming machine: %s", mcnerror.ErrInvalidHostname)
+	}
+
+	exists, err := api.Exists(name)
+	if err != nil {
+		return fmt.Errorf("Error checking if host exists: %s", err)
+	}
+	if exists {
+		return mcnerror.ErrHostAlreadyExists{
+			Name: name,
+		}
+	}
+
+	h, err := api.Load(oldName)
+	if err != nil {
+		return err
+	}
+
+	if !drivers.SupportsRename(h.Driver) {
+		return fmt.Errorf("Error renaming %s: %s", oldName, drivers.ErrRenameNotSupported)
+	}
+	renamer := h.Driver.(drivers.Renamer)
+
+	machineState, err := h.Driver.GetState()
+	if err != nil {
+		return err
+	}
+
+	oldDir := filepath.Join(api.GetMachinesDir(), oldName)
+	newDir := filepath.Join(api.GetMachinesDir(), name)
+	if _, err := os.Stat(newDir); err == nil {
+		return fmt.Errorf("Error renaming %s: %s already exists", oldName, newDir)
+	}
+
+	renamed, err := renameHost(h, name, oldDir, newDir)
+	if err != nil {
+		return err
+	}
+
+	renameResources := !c.Bool("keep-resource-names")
+	if renameResources {
+		log.Infof("Renaming the resources of %q at the provider...", oldName)
+		if err := renamer.Rename(name); err != nil {
+			return fmt.Errorf("Error renaming the resources of %s: %s", oldName, err)
+		}
+	}
+
+	// undo puts the resources back under their name if the machine can't
+	// be renamed in the store
+	undo := func(err error) error {
+		if renameResources {
+			if undoErr := renamer.Rename(oldName); undoErr != nil {
+				log.Warnf("Error renaming the resources of %s back: %s", name, undoErr)
+			}
+		}
+		return err
+	}
+
+	rawDriver, err := renameDriverConfig(h.Driver, oldName, name)
+	if err != nil {
+		return undo(err)
+	}
+
+	// The files of the machine, e.g. its SSH key or its disk, are only in
+	// its local directory, which the store saves along with the machine
+	if err := os.Rename(oldDir, newDir); err != nil {
+		return undo(fmt.Errorf("Error moving the directory of %s: %s", oldName, err))
+	}
+
+	newHost, err := api.NewHost(h.DriverName, rawDriver)
+	if err == nil {
+		renamed.Driver = newHost.Driver
+		renamed.RawDriver = newHost.RawDriver
+		err = api.Save(renamed)
+	}
+	if err != nil {
+		if moveErr := os.Rename(newDir, oldDir); moveErr != nil {
+			log.Warnf("Error moving the directory of %s back: %s", name, moveErr)
+		}
+		if saveErr := api.Save(h); saveErr != nil {
+			log.Warnf("Error saving %s back: %s", oldName, saveErr)
+		}
+		return undo(fmt.Errorf("Error saving the renamed machine: %s", err))
+	}
+
+	if err := api.Remove(oldName); err != nil {
+		log.Warnf("Error removing %s from the store: %s", oldName, err)
+	}
+
+	updateGroups(api, func(groups persist.Groups) bool {
+		return groups.RenameMachine(oldName, name)
+	})
+
+	if machineState == state.Running {
+		if err := renamed.ConfigureName(); err != nil {
+			return fmt.Errorf("Machine %q was renamed to %q, but its hostname and certificates weren't updated: %s. Run \"%s provision %s\" to retry", oldName, name, err, os.Args[0], name)
+		}
+	} else {
+		log.Infof("The hostname of %q will be updated the next time it's provisioned", name)
+	}
+
+	syncInventories(api)
+
+	log.Infof("Machine %q was renamed to %q.", oldName, name)
+
+	return nil
+}
+
+// renamePath moves a path of the directory of a machine to its new
+// directory.  The other paths are kept.
+func renamePath(p, oldDir, newDir string) string {
+	if p == oldDir {
+		return newDir
+	}
+	if strings.HasPrefix(p, oldDir+string(filepath.Separator)) {
+		return newDir + strings.TrimPrefix(p, oldDir)
+	}
+	return p
+}
+
+// renameDriverConfig returns the configuration of the driver of a machine
+// with its new name, and its files, e.g. the SSH key or the disk, in its new
+// directory.
+func renameDriverConfig(d drivers.Driver, oldName, name string) ([]byte, error) {
+	rawDriver, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the driver configuration of %s: %s", oldName, err)
+	}
+
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(rawDriver, &config); err != nil {
+		return nil, fmt.Errorf("Error reading the driver configuration of %s: %s", oldName, err)
+	}
+
+	storePath, _ := config["StorePath"].(string)
+	oldDir := filepath.Join(storePath, "machines", oldName)
+	newDir := filepath.Join(storePath, "machines", name)
+
+	for key, value := range config {
+		if s, ok := value.(string); ok {
+			config[key] = renamePath(s, oldDir, newDir)
+		}
+	}
+	config["MachineName"] = name
+
+	return json.Marshal(config)
+}
+
+// renameHost copies a machine, but its driver, with its new name and the
+// paths of its server certificates in its new directory.  Everything else,
+// e.g. its labels or its endpoint type, is kept.
+func renameHost(h *host.Host, name, oldDir, newDir string) (*host.Host, error) {
+	content, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+
+	// The driver is created again from its renamed configuration
+	delete(config, "Driver")
+
+	content, err = json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := &host.Host{}
+	if err := json.Unmarshal(content, renamed); err != nil {
+		return nil, err
+	}
+	renamed.Name = name
+
+	if renamed.HostOptions != nil && renamed.HostOptions.AuthOptions != nil {
+		authOptions := renamed.HostOptions.AuthOptions
+		authOptions.ServerCertPath = renamePath(authOptions.ServerCertPath, oldDir, newDir)
+		authOptions.ServerKeyPath = renamePath(authOptions.ServerKeyPath, oldDir, newDir)
+		authOptions.StorePath = renamePath(authOptions.StorePath, oldDir, newDir)
+	}
+
+	return renamed, nil
+}
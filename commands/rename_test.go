@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// renameDriver is a fake driver whose machines can be renamed.
+type renameDriver struct {
+	*fakedriver.Driver
+	renamedTo []string
+}
+
+func (d *renameDriver) Rename(name string) error {
+	d.renamedTo = append(d.renamedTo, name)
+	return nil
+}
+
+// storeAPI is a fake API saving the machines in its hosts, whose directories
+// are in machinesDir.
+type storeAPI struct {
+	*libmachinetest.FakeAPI
+	machinesDir string
+}
+
+func (api *storeAPI) NewHost(driverName string, rawDriver []byte) (*host.Host, error) {
+	d := &renameDriver{Driver: &fakedriver.Driver{}}
+	if err := json.Unmarshal(rawDriver, d); err != nil {
+		return nil, err
+	}
+	return &host.Host{DriverName: driverName, Driver: d, RawDriver: rawDriver}, nil
+}
+
+func (api *storeAPI) Save(h *host.Host) error {
+	api.FakeAPI.Remove(h.Name)
+	api.Hosts = append(api.Hosts, h)
+	return nil
+}
+
+func (api *storeAPI) GetMachinesDir() string {
+	return api.machinesDir
+}
+
+func TestCmdRenameRequiresTwoNames(t *testing.T) {
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdRename(&commandstest.FakeCommandLine{CliArgs: []string{"dev"}}, api)
+	assert.Equal(t, errNoRenameNames, err)
+
+	err = cmdRename(&commandstest.FakeCommandLine{CliArgs: []string{"dev", "dev2", "dev3"}}, api)
+	assert.Equal(t, ErrTooManyArguments, err)
+}
+
+func TestCmdRenameExistingMachine(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{{Name: "dev"}, {Name: "dev2"}},
+	}
+
+	err := cmdRename(&commandstest.FakeCommandLine{CliArgs: []string{"dev", "dev2"}}, api)
+	assert.EqualError(t, err, `Host already exists: "dev2"`)
+}
+
+func TestCmdRenameInvalidName(t *testing.T) {
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdRename(&commandstest.FakeCommandLine{CliArgs: []string{"dev", "dev_2"}}, api)
+	assert.Error(t, err)
+}
+
+func TestCmdRenameNotSupported(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{{Name: "dev", Driver: &fakedriver.Driver{}}},
+	}
+
+	err := cmdRename(&commandstest.FakeCommandLine{CliArgs: []string{"dev", "dev2"}}, api)
+	assert.EqualError(t, err, "Error renaming dev: "+drivers.ErrRenameNotSupported.Error())
+}
+
+func TestCmdRename(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "rename")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	machinesDir := filepath.Join(storePath, "machines")
+	oldDir := filepath.Join(machinesDir, "dev")
+	assert.NoError(t, os.MkdirAll(oldDir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(oldDir, "id_rsa"), []byte("key"), 0600))
+
+	d := &renameDriver{Driver: &fakedriver.Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: "dev",
+			StorePath:   storePath,
+			SSHKeyPath:  filepath.Join(oldDir, "id_rsa"),
+		},
+		MockState: state.Stopped,
+	}}
+	api := &storeAPI{
+		FakeAPI: &libmachinetest.FakeAPI{
+			Hosts: []*host.Host{{Name: "dev", DriverName: "fakedriver", Driver: d}},
+		},
+		machinesDir: machinesDir,
+	}
+
+	err = cmdRename(&commandstest.FakeCommandLine{CliArgs: []string{"dev", "dev2"}, LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}}}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev2"}, d.renamedTo)
+	assert.False(t, libmachinetest.Exists(api, "dev"))
+
+	renamed, err := api.Load("dev2")
+	assert.NoError(t, err)
+	renamedDriver := renamed.Driver.(*renameDriver)
+	assert.Equal(t, "dev2", renamedDriver.MachineName)
+	assert.Equal(t, filepath.Join(machinesDir, "dev2", "id_rsa"), renamedDriver.SSHKeyPath)
+
+	_, err = os.Stat(filepath.Join(machinesDir, "dev2", "id_rsa"))
+	assert.NoError(t, err)
+	_, err = os.Stat(oldDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRenamePath(t *testing.T) {
+	oldDir, newDir := filepath.Join("store", "machines", "dev"), filepath.Join("store", "machines", "dev2")
+
+	assert.Equal(t, newDir, renamePath(oldDir, oldDir, newDir))
+	assert.Equal(t, filepath.Join(newDir, "id_rsa"), renamePath(filepath.Join(oldDir, "id_rsa"), oldDir, newDir))
+	assert.Equal(t, filepath.Join("store", "machines", "dev-other", "id_rsa"), renamePath(filepath.Join("store", "machines", "dev-other", "id_rsa"), oldDir, newDir))
+}
+
+func TestRenameDriverConfig(t *testing.T) {
+	d := &fakedriver.Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: "dev",
+			StorePath:   "store",
+			SSHKeyPath:  filepath.Join("store", "machines", "dev", "id_rsa"),
+			IPAddress:   "1.2.3.4",
+		},
+	}
+
+	rawDriver, err := renameDriverConfig(d, "dev", "dev2")
+	assert.NoError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(rawDriver, &config))
+	assert.Equal(t, "dev2", config["MachineName"])
+	assert.Equal(t, filepath.Join("store", "machines", "dev2", "id_rsa"), config["SSHKeyPath"])
+	assert.Equal(t, "1.2.3.4", config["IPAddress"])
+	assert.Equal(t, "store", config["StorePath"])
+}
+
+func TestRenameHost(t *testing.T) {
+	oldDir, newDir := filepath.Join("store", "machines", "dev"), filepath.Join("store", "machines", "dev2")
+	h := &host.Host{
+		ConfigVersion: 3,
+		Name:          "dev",
+		DriverName:    "fakedriver",
+		Driver:        &fakedriver.Driver{},
+		HostOptions: &host.Options{
+			AuthOptions: &auth.Options{
+				CaCertPath:     filepath.Join("store", "certs", "ca.pem"),
+				ServerCertPath: filepath.Join(oldDir, "server.pem"),
+				ServerKeyPath:  filepath.Join(oldDir, "server-key.pem"),
+				StorePath:      oldDir,
+			},
+			EngineOptions: &engine.Options{StorageDriver: "overlay2"},
+		},
+		EndpointType: host.EndpointContainerd,
+		Labels:       map[string]string{"env": "prod"},
+		AutoStop:     &host.AutoStop{},
+	}
+
+	renamed, err := renameHost(h, "dev2", oldDir, newDir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "dev2", renamed.Name)
+	assert.Equal(t, "fakedriver", renamed.DriverName)
+	assert.Equal(t, 3, renamed.ConfigVersion)
+	assert.Nil(t, renamed.Driver)
+	assert.Equal(t, host.EndpointContainerd, renamed.EndpointType)
+	assert.Equal(t, map[string]string{"env": "prod"}, renamed.Labels)
+	assert.NotNil(t, renamed.AutoStop)
+	assert.Equal(t, filepath.Join("store", "certs", "ca.pem"), renamed.HostOptions.AuthOptions.CaCertPath)
+	assert.Equal(t, filepath.Join(newDir, "server.pem"), renamed.HostOptions.AuthOptions.ServerCertPath)
+	assert.Equal(t, filepath.Join(newDir, "server-key.pem"), renamed.HostOptions.AuthOptions.ServerKeyPath)
+	assert.Equal(t, newDir, renamed.HostOptions.AuthOptions.StorePath)
+	assert.Equal(t, "overlay2", renamed.HostOptions.EngineOptions.StorageDriver)
+	assert.Equal(t, filepath.Join(oldDir, "server.pem"), h.HostOptions.AuthOptions.ServerCertPath)
+}
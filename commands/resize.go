@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errNoResizeSize = errors.New("Error: The new size of the machine is required, use --size")
+)
+
+func cmdResize(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	size := c.String("size")
+	if size == "" {
+		return errNoResizeSize
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Resize(size); err != nil {
+		return err
+	}
+
+	// The size is saved with the driver, and restarting the machine may
+	// change its IP address
+	if err := api.Save(h); err != nil {
+		return err
+	}
+
+	log.Infof("Machine %q was resized to %s.", h.Name, size)
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResizeDriver struct {
+	fakedriver.Driver
+	size string
+}
+
+func (d *fakeResizeDriver) Resize(size string) error {
+	d.size = size
+	return nil
+}
+
+func TestCmdResize(t *testing.T) {
+	driver := &fakeResizeDriver{Driver: fakedriver.Driver{MockState: state.Stopped}}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "myhost",
+				Driver: driver,
+			},
+		},
+	}
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"myhost"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"size": "s-4vcpu-8gb",
+			},
+		},
+	}
+
+	assert.NoError(t, cmdResize(commandLine, api))
+	assert.Equal(t, "s-4vcpu-8gb", driver.size)
+}
+
+func TestCmdResizeRequiresSize(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"myhost"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"size": "",
+			},
+		},
+	}
+
+	assert.Equal(t, errNoResizeSize, cmdResize(commandLine, &libmachinetest.FakeAPI{}))
+}
+
+func TestCmdResizeNotSupported(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "myhost",
+				Driver: &fakedriver.Driver{MockState: state.Running},
+			},
+		},
+	}
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"myhost"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"size": "t3.large",
+			},
+		},
+	}
+
+	assert.Equal(t, drivers.ErrResizeNotSupported, cmdResize(commandLine, api))
+	assert.Equal(t, state.Running, libmachinetest.State(api, "myhost"))
+}
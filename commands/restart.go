@@ -6,7 +6,7 @@ import (
 )
 
 func cmdRestart(c CommandLine, api libmachine.API) error {
-	if err := runAction("restart", c, api); err != nil {
+	if err := runBatchAction("restart", c, api); err != nil {
 		return err
 	}
 
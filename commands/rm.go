@@ -9,15 +9,21 @@ import (
 
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
 )
 
 func cmdRm(c CommandLine, api libmachine.API) error {
-	if len(c.Args()) == 0 {
+	if len(c.Args()) == 0 && len(c.StringSlice("filter")) == 0 && !c.Bool("all") {
 		c.ShowHelp()
 		return ErrNoMachineSpecified
 	}
 
-	log.Info(fmt.Sprintf("About to remove %s", strings.Join(c.Args(), ", ")))
+	hostNames, err := batchTargetNames(c, api)
+	if err != nil {
+		return err
+	}
+
+	log.Info(fmt.Sprintf("About to remove %s", strings.Join(hostNames, ", ")))
 
 	force := c.Bool("force")
 	confirm := c.Bool("y")
@@ -27,20 +33,52 @@ func cmdRm(c CommandLine, api libmachine.API) error {
 		return nil
 	}
 
-	for _, hostName := range c.Args() {
-		err := removeRemoteMachine(hostName, api)
+	remoteErrs := removeRemoteMachines(hostNames, api)
+	results := []actionResult{}
+
+	for _, hostName := range hostNames {
+		result := actionResult{name: hostName}
+
+		err := remoteErrs[hostName]
 		if err != nil {
-			errorOccured = collectError(fmt.Sprintf("Error removing host %q: %s", hostName, err), force, errorOccured)
+			message := fmt.Sprintf("Error removing host %q: %s", hostName, err)
+			errorOccured = collectError(message, force, errorOccured)
+			result.err = errors.New(message)
 		}
 
 		if err == nil || force {
 			removeErr := removeLocalMachine(hostName, api)
 			if removeErr != nil {
-				errorOccured = collectError(fmt.Sprintf("Can't remove \"%s\"", hostName), force, errorOccured)
+				message := fmt.Sprintf("Can't remove \"%s\"", hostName)
+				errorOccured = collectError(message, force, errorOccured)
+				if result.err == nil {
+					result.err = errors.New(message)
+				}
 			} else {
+				updateGroups(api, func(groups persist.Groups) bool {
+					return groups.RemoveMachine(hostName)
+				})
 				log.Infof("Successfully removed %s", hostName)
 			}
 		}
+
+		results = append(results, result)
+	}
+
+	syncInventories(api)
+
+	if len(hostNames) > 1 {
+		if isJSONOutput(c) {
+			if err := printJSON(batchItems(results)); err != nil {
+				return err
+			}
+			if len(errorOccured) > 0 && !force {
+				return errJSONReported
+			}
+			return nil
+		}
+
+		printBatchSummary(results)
 	}
 
 	if len(errorOccured) > 0 && !force {
@@ -69,7 +107,29 @@ func removeRemoteMachine(hostName string, api libmachine.API) error {
 		return loaderr
 	}
 
-	return currentHost.Driver.Remove()
+	return currentHost.Remove()
+}
+
+// removeRemoteMachines removes the machines concurrently, and returns the
+// error of each of them.
+func removeRemoteMachines(hostNames []string, api libmachine.API) map[string]error {
+	resultChan := make(chan actionResult)
+
+	for _, hostName := range hostNames {
+		go func(hostName string) {
+			resultChan <- actionResult{name: hostName, err: removeRemoteMachine(hostName, api)}
+		}(hostName)
+	}
+
+	errs := map[string]error{}
+	for range hostNames {
+		result := <-resultChan
+		errs[result.name] = result.err
+	}
+
+	close(resultChan)
+
+	return errs
 }
 
 func removeLocalMachine(hostName string, api libmachine.API) error {
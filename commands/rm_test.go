@@ -7,6 +7,7 @@ import (
 
 	"github.com/docker/machine/commands/commandstest"
 	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/hooks"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/libmachinetest"
 	"github.com/stretchr/testify/assert"
@@ -231,3 +232,32 @@ func TestDontRemoveMachineIsRemovalFailsAndNotForced(t *testing.T) {
 
 	assert.True(t, libmachinetest.Exists(api, "machineToRemove1"))
 }
+
+func TestDontRemoveMachineIfPreRemoveHookFails(t *testing.T) {
+	defer hooks.SetDefault(&hooks.Hooks{})
+	hooks.SetDefault(&hooks.Hooks{Hooks: []hooks.Hook{
+		{Events: []hooks.EventType{hooks.PreRemove}, Command: "false"},
+	}})
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machineToRemove"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"y": true,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machineToRemove",
+				Driver: &fakedriver.Driver{},
+			},
+		},
+	}
+
+	err := cmdRm(commandLine, api)
+	assert.Error(t, err)
+
+	assert.True(t, libmachinetest.Exists(api, "machineToRemove"))
+}
@@ -3,32 +3,33 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/docker/docker/pkg/term"
+	"github.com/docker/go-units"
 	"github.com/docker/machine/libmachine"
-	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/ssh"
 )
 
-var (
-	errWrongNumberArguments = errors.New("Improper number of arguments")
-
-	// TODO: possibly move this to ssh package
-	baseSSHArgs = []string{
-		"-o", "IdentitiesOnly=yes",
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "LogLevel=quiet", // suppress "Warning: Permanently added '[localhost]:2022' (ECDSA) to the list of known hosts."
-	}
+const (
+	scpProgressNameWidth = 30
+	scpProgressInterval  = 100 * time.Millisecond
 )
 
+var errWrongNumberArguments = errors.New("Improper number of arguments")
+
 // HostInfo gives the mandatory information to connect to a host.
 type HostInfo interface {
 	GetMachineName() string
 
-	GetIP() (string, error)
+	GetSSHHostname() (string, error)
+
+	GetSSHPort() (int, error)
 
 	GetSSHUsername() string
 
@@ -60,111 +61,177 @@ func cmdScp(c CommandLine, api libmachine.API) error {
 		return errWrongNumberArguments
 	}
 
-	src := args[0]
-	dest := args[1]
-
 	hostInfoLoader := &storeHostInfoLoader{api}
 
-	cmd, err := getScpCmd(src, dest, c.Bool("recursive"), hostInfoLoader)
+	srcHost, srcPath, err := getInfoForScpArg(args[0], hostInfoLoader)
 	if err != nil {
 		return err
 	}
 
-	return runCmdWithStdIo(*cmd)
-}
-
-func getScpCmd(src, dest string, recursive bool, hostInfoLoader HostInfoLoader) (*exec.Cmd, error) {
-	cmdPath, err := exec.LookPath("scp")
+	destHost, destPath, err := getInfoForScpArg(args[1], hostInfoLoader)
 	if err != nil {
-		return nil, errors.New("Error: You must have a copy of the scp binary locally to use the scp feature.")
+		return err
 	}
 
-	srcHost, srcPath, srcOpts, err := getInfoForScpArg(src, hostInfoLoader)
+	src, err := openScpFileSystem(srcHost)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer closeScpFileSystem(src)
 
-	destHost, destPath, destOpts, err := getInfoForScpArg(dest, hostInfoLoader)
+	dest, err := openScpFileSystem(destHost)
 	if err != nil {
-		return nil, err
-	}
-
-	// TODO: Check that "-3" flag is available in user's version of scp.
-	// It is on every system I've checked, but the manual mentioned it's "newer"
-	sshArgs := baseSSHArgs
-	sshArgs = append(sshArgs, "-3")
-	if recursive {
-		sshArgs = append(sshArgs, "-r")
+		return err
 	}
+	defer closeScpFileSystem(dest)
 
-	// Append needed -i / private key flags to command.
-	sshArgs = append(sshArgs, srcOpts...)
-	sshArgs = append(sshArgs, destOpts...)
-
-	// Append actual arguments for the scp command (i.e. docker@<ip>:/path)
-	locationArg, err := generateLocationArg(srcHost, srcPath)
-	if err != nil {
-		return nil, err
+	opts := ssh.TransferOptions{
+		Recursive: c.Bool("recursive"),
+		Resume:    c.Bool("resume"),
 	}
-
-	sshArgs = append(sshArgs, locationArg)
-	locationArg, err = generateLocationArg(destHost, destPath)
-	if err != nil {
-		return nil, err
+	if !c.Bool("quiet") && term.IsTerminal(os.Stderr.Fd()) {
+		opts.Progress = &scpProgress{out: os.Stderr}
 	}
-	sshArgs = append(sshArgs, locationArg)
 
-	cmd := exec.Command(cmdPath, sshArgs...)
-	log.Debug(*cmd)
-	return cmd, nil
+	return ssh.Copy(src, srcPath, dest, destPath, opts)
 }
 
-func getInfoForScpArg(hostAndPath string, hostInfoLoader HostInfoLoader) (HostInfo, string, []string, error) {
+func getInfoForScpArg(hostAndPath string, hostInfoLoader HostInfoLoader) (HostInfo, string, error) {
 	// Local path.  e.g. "/tmp/foo"
 	if !strings.Contains(hostAndPath, ":") {
-		return nil, hostAndPath, nil, nil
+		return nil, hostAndPath, nil
 	}
 
 	// Path with hostname.  e.g. "hostname:/usr/bin/cmatrix"
 	parts := strings.SplitN(hostAndPath, ":", 2)
 	hostName := parts[0]
-	path := parts[1]
+	path := scpPath(parts[1])
 	if hostName == "localhost" {
-		return nil, path, nil, nil
+		return nil, path, nil
 	}
 
 	// Remote path
 	hostInfo, err := hostInfoLoader.load(hostName)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("Error loading host: %s", err)
+		return nil, "", fmt.Errorf("Error loading host: %s", err)
 	}
 
-	args := []string{}
-	if hostInfo.GetSSHKeyPath() != "" {
-		args = append(args, "-i", hostInfo.GetSSHKeyPath())
-	}
+	return hostInfo, path, nil
+}
 
-	return hostInfo, path, args, nil
+// scpPath returns the path given after the name of a machine, relative to
+// the home directory like with scp.  The SFTP server doesn't expand ~.
+func scpPath(path string) string {
+	switch {
+	case path == "" || path == "~":
+		return "."
+	case strings.HasPrefix(path, "~/"):
+		return strings.TrimPrefix(path, "~/")
+	}
+	return path
 }
 
-func generateLocationArg(hostInfo HostInfo, path string) (string, error) {
+// openScpFileSystem returns the file system of the host, or the local one
+// if the host is nil.
+func openScpFileSystem(hostInfo HostInfo) (ssh.FileSystem, error) {
 	if hostInfo == nil {
-		return path, nil
+		return ssh.LocalFileSystem{}, nil
 	}
 
-	ip, err := hostInfo.GetIP()
+	hostname, err := hostInfo.GetSSHHostname()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	location := fmt.Sprintf("%s@%s:%s", hostInfo.GetSSHUsername(), ip, path)
-	return location, nil
+	port, err := hostInfo.GetSSHPort()
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &ssh.Auth{}
+	if hostInfo.GetSSHKeyPath() != "" {
+		auth.Keys = []string{hostInfo.GetSSHKeyPath()}
+	}
+
+	proxyJump, err := getScpProxyJump(hostInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewSFTPClient(hostInfo.GetSSHUsername(), hostname, port, auth, proxyJump)
+}
+
+// getScpProxyJump returns the bastion of the host, nil if there's none.
+func getScpProxyJump(hostInfo HostInfo) (*ssh.ProxyJump, error) {
+	jumper, ok := hostInfo.(drivers.SSHProxyJumper)
+	if !ok || jumper.GetSSHProxyJump() == "" {
+		return nil, nil
+	}
+
+	return ssh.ParseProxyJump(jumper.GetSSHProxyJump(), hostInfo.GetSSHUsername())
+}
+
+func closeScpFileSystem(fs ssh.FileSystem) {
+	if closer, ok := fs.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// scpProgress draws the progress of the copy of each file on a line of the
+// terminal.
+type scpProgress struct {
+	out     io.Writer
+	name    string
+	size    int64
+	offset  int64
+	copied  int64
+	started time.Time
+	drawn   time.Time
 }
 
-func runCmdWithStdIo(cmd exec.Cmd) error {
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func (p *scpProgress) Start(name string, size, offset int64) {
+	p.name = name
+	p.size = size
+	p.offset = offset
+	p.copied = offset
+	p.started = time.Now()
+	p.draw()
+}
+
+func (p *scpProgress) Add(n int) {
+	p.copied += int64(n)
+	if time.Since(p.drawn) >= scpProgressInterval {
+		p.draw()
+	}
+}
+
+func (p *scpProgress) Done() {
+	p.draw()
+	fmt.Fprintln(p.out)
+}
+
+func (p *scpProgress) draw() {
+	p.drawn = time.Now()
+	fmt.Fprintf(p.out, "\r%s", p.line(p.drawn))
+}
+
+// line returns the line of the progress of the file: its name, the bar, the
+// bytes copied and the rate of the copy.
+func (p *scpProgress) line(now time.Time) string {
+	percent := 100
+	if p.size > 0 {
+		percent = int(p.copied * 100 / p.size)
+	}
+
+	var rate float64
+	if elapsed := now.Sub(p.started).Seconds(); elapsed > 0 {
+		rate = float64(p.copied-p.offset) / elapsed
+	}
+
+	name := p.name
+	if len(name) > scpProgressNameWidth {
+		name = name[:scpProgressNameWidth-3] + "..."
+	}
 
-	return cmd.Run()
+	return fmt.Sprintf("%-*s %s %10s %10s/s", scpProgressNameWidth, name, progressBar(percent), units.HumanSize(float64(p.copied)), units.HumanSize(rate))
 }
@@ -1,25 +1,36 @@
 package commands
 
 import (
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/libmachinetest"
 	"github.com/stretchr/testify/assert"
 )
 
 type MockHostInfo struct {
 	name        string
-	ip          string
+	hostname    string
+	port        int
 	sshUsername string
 	sshKeyPath  string
+	proxyJump   string
 }
 
 func (h *MockHostInfo) GetMachineName() string {
 	return h.name
 }
 
-func (h *MockHostInfo) GetIP() (string, error) {
-	return h.ip, nil
+func (h *MockHostInfo) GetSSHHostname() (string, error) {
+	return h.hostname, nil
+}
+
+func (h *MockHostInfo) GetSSHPort() (int, error) {
+	return h.port, nil
 }
 
 func (h *MockHostInfo) GetSSHUsername() string {
@@ -30,6 +41,10 @@ func (h *MockHostInfo) GetSSHKeyPath() string {
 	return h.sshKeyPath
 }
 
+func (h *MockHostInfo) GetSSHProxyJump() string {
+	return h.proxyJump
+}
+
 type MockHostInfoLoader struct {
 	hostInfo MockHostInfo
 }
@@ -41,16 +56,14 @@ func (l *MockHostInfoLoader) load(name string) (HostInfo, error) {
 }
 
 func TestGetInfoForLocalScpArg(t *testing.T) {
-	host, path, opts, err := getInfoForScpArg("/tmp/foo", nil)
+	host, path, err := getInfoForScpArg("/tmp/foo", nil)
 	assert.Nil(t, host)
 	assert.Equal(t, "/tmp/foo", path)
-	assert.Nil(t, opts)
 	assert.NoError(t, err)
 
-	host, path, opts, err = getInfoForScpArg("localhost:C:\\path", nil)
+	host, path, err = getInfoForScpArg("localhost:C:\\path", nil)
 	assert.Nil(t, host)
 	assert.Equal(t, "C:\\path", path)
-	assert.Nil(t, opts)
 	assert.NoError(t, err)
 }
 
@@ -59,78 +72,95 @@ func TestGetInfoForRemoteScpArg(t *testing.T) {
 		sshKeyPath: "/fake/keypath/id_rsa",
 	}}
 
-	host, path, opts, err := getInfoForScpArg("myfunhost:/home/docker/foo", &hostInfoLoader)
+	host, path, err := getInfoForScpArg("myfunhost:/home/docker/foo", &hostInfoLoader)
 	assert.Equal(t, "myfunhost", host.GetMachineName())
 	assert.Equal(t, "/home/docker/foo", path)
-	assert.Equal(t, []string{"-i", "/fake/keypath/id_rsa"}, opts)
 	assert.NoError(t, err)
 
-	host, path, opts, err = getInfoForScpArg("myfunhost:C:\\path", &hostInfoLoader)
+	host, path, err = getInfoForScpArg("myfunhost:C:\\path", &hostInfoLoader)
 	assert.Equal(t, "myfunhost", host.GetMachineName())
 	assert.Equal(t, "C:\\path", path)
 	assert.NoError(t, err)
 }
 
-func TestHostLocation(t *testing.T) {
-	arg, err := generateLocationArg(nil, "/home/docker/foo")
+func TestGetInfoForRemoteScpArgInHome(t *testing.T) {
+	hostInfoLoader := MockHostInfoLoader{MockHostInfo{}}
 
-	assert.Equal(t, "/home/docker/foo", arg)
+	_, path, err := getInfoForScpArg("myfunhost:", &hostInfoLoader)
+	assert.Equal(t, ".", path)
+	assert.NoError(t, err)
+
+	_, path, err = getInfoForScpArg("myfunhost:~/foo", &hostInfoLoader)
+	assert.Equal(t, "foo", path)
 	assert.NoError(t, err)
 }
 
-func TestRemoteLocation(t *testing.T) {
-	hostInfo := MockHostInfo{
-		ip:          "12.34.56.78",
-		sshUsername: "root",
+func TestGetScpProxyJump(t *testing.T) {
+	hostInfo := &MockHostInfo{
+		sshUsername: "ubuntu",
+		proxyJump:   "bastion.example.com",
 	}
 
-	arg, err := generateLocationArg(&hostInfo, "/home/docker/foo")
+	jump, err := getScpProxyJump(hostInfo)
 
-	assert.Equal(t, "root@12.34.56.78:/home/docker/foo", arg)
+	assert.Equal(t, "ubuntu@bastion.example.com:22", jump.String())
+	assert.NoError(t, err)
+
+	hostInfo.proxyJump = ""
+	jump, err = getScpProxyJump(hostInfo)
+
+	assert.Nil(t, jump)
 	assert.NoError(t, err)
 }
 
-func TestGetScpCmd(t *testing.T) {
-	hostInfoLoader := MockHostInfoLoader{MockHostInfo{
-		ip:          "12.34.56.78",
-		sshUsername: "root",
-		sshKeyPath:  "/fake/keypath/id_rsa",
-	}}
+func TestCmdScpLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
 
-	cmd, err := getScpCmd("/tmp/foo", "myfunhost:/home/docker/foo", true, &hostInfoLoader)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "conf"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "src", "conf", "app.yml"), []byte("debug: true\n"), 0600))
 
-	expectedArgs := append(
-		baseSSHArgs,
-		"-3",
-		"-r",
-		"-i",
-		"/fake/keypath/id_rsa",
-		"/tmp/foo",
-		"root@12.34.56.78:/home/docker/foo",
-	)
-	expectedCmd := exec.Command("/usr/bin/scp", expectedArgs...)
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs:    []string{filepath.Join(dir, "src"), "localhost:" + filepath.Join(dir, "dst")},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{"recursive": true}},
+	}
 
-	assert.Equal(t, expectedCmd, cmd)
+	err = cmdScp(commandLine, &libmachinetest.FakeAPI{})
 	assert.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(dir, "dst", "conf", "app.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode())
 }
 
-func TestGetScpCmdWithoutSshKey(t *testing.T) {
-	hostInfoLoader := MockHostInfoLoader{MockHostInfo{
-		ip:          "1.2.3.4",
-		sshUsername: "user",
-	}}
+func TestCmdScpWrongNumberArguments(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"default:/tmp/foo"},
+	}
+
+	err := cmdScp(commandLine, &libmachinetest.FakeAPI{})
 
-	cmd, err := getScpCmd("/tmp/foo", "myfunhost:/home/docker/foo", true, &hostInfoLoader)
+	assert.Equal(t, errWrongNumberArguments, err)
+}
 
-	expectedArgs := append(
-		baseSSHArgs,
-		"-3",
-		"-r",
-		"/tmp/foo",
-		"user@1.2.3.4:/home/docker/foo",
-	)
-	expectedCmd := exec.Command("/usr/bin/scp", expectedArgs...)
+func TestScpProgressLine(t *testing.T) {
+	started := time.Now()
+	p := &scpProgress{
+		name:    "boot2docker.iso",
+		size:    4000,
+		offset:  1000,
+		copied:  2000,
+		started: started,
+	}
 
-	assert.Equal(t, expectedCmd, cmd)
-	assert.NoError(t, err)
+	line := p.line(started.Add(time.Second))
+
+	assert.Equal(t, "boot2docker.iso                [##########          ]  50%       2 kB       1 kB/s", line)
+
+	p.name = "a-file-whose-name-is-longer-than-the-column.tar.gz"
+	p.copied = 4000
+	line = p.line(started.Add(time.Second))
+
+	assert.Equal(t, "a-file-whose-name-is-longer... [####################] 100%       4 kB       3 kB/s", line)
 }
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/server"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+const defaultServeAddress = "127.0.0.1:2377"
+
+// serveListenAndServe is replaced by the tests.
+var serveListenAndServe = func(srv *http.Server, certFile, keyFile string) error {
+	if certFile != "" {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	}
+	return srv.ListenAndServe()
+}
+
+func cmdServe(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 0 {
+		return ErrTooManyArguments
+	}
+
+	if (c.String("tls-cert") == "") != (c.String("tls-key") == "") {
+		return fmt.Errorf("Error: --tls-cert and --tls-key must be given together")
+	}
+
+	token, err := serveToken(c)
+	if err != nil {
+		return err
+	}
+
+	newAPI := func() libmachine.API {
+		return api
+	}
+	if client, ok := api.(*libmachine.Client); ok {
+		// Each request loads the drivers in its own session, closed once
+		// it's served.  Closing a session closes the SSH connections,
+		// so they can't be shared between the requests.
+		ssh.SetMultiplexing(false)
+		newAPI = func() libmachine.API {
+			return client.Session()
+		}
+	}
+
+	address := c.String("listen")
+	if address == "" {
+		address = defaultServeAddress
+	}
+
+	servers := []*http.Server{{
+		Addr:    address,
+		Handler: server.NewServer(newAPI, token),
+	}}
+	log.Infof("Serving the API on %s", address)
+
+	if metricsAddress := c.String("metrics"); metricsAddress != "" {
+		servers = append(servers, &http.Server{
+			Addr:    metricsAddress,
+			Handler: server.NewMetricsHandler(collectMetrics(newAPI)),
+		})
+		log.Infof("Serving the metrics on %s/metrics", metricsAddress)
+	}
+
+	// The command stops once any of the servers does
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			errs <- serveListenAndServe(srv, c.String("tls-cert"), c.String("tls-key"))
+		}(srv)
+	}
+
+	return <-errs
+}
+
+// collectMetrics returns the function collecting the metrics of the
+// machines on every scrape, which queries them like ls does.
+func collectMetrics(newAPI func() libmachine.API) func() ([]server.MachineMetrics, error) {
+	return func() ([]server.MachineMetrics, error) {
+		api := newAPI()
+		defer api.Close()
+
+		hostList, hostsInError, err := persist.LoadAllHosts(api)
+		if err != nil {
+			return nil, err
+		}
+
+		hosts := map[string]*host.Host{}
+		for _, h := range hostList {
+			hosts[h.Name] = h
+		}
+
+		metrics := []server.MachineMetrics{}
+		for _, item := range getHostListItems(hostList, hostsInError, lsDefaultTimeout*time.Second, lsDefaultParallel) {
+			metrics = append(metrics, newMachineMetrics(hosts[item.Name], item))
+		}
+
+		return metrics, nil
+	}
+}
+
+// newMachineMetrics returns the metrics of a machine listed like with ls,
+// along with when its certificates expire and when it was last provisioned.
+func newMachineMetrics(h *host.Host, item HostListItem) server.MachineMetrics {
+	metrics := server.MachineMetrics{
+		Name:        item.Name,
+		DriverName:  item.DriverName,
+		State:       item.State,
+		CertsExpiry: map[string]time.Time{},
+		Error:       item.Error,
+	}
+
+	// The version is listed as v24.0.7, or Unknown
+	if strings.HasPrefix(item.DockerVersion, "v") {
+		metrics.EngineVersion = strings.TrimPrefix(item.DockerVersion, "v")
+	}
+
+	if h == nil {
+		return metrics
+	}
+
+	if h.LastProvisioned != nil {
+		metrics.LastProvisioned = *h.LastProvisioned
+	}
+
+	if authOptions := h.AuthOptions(); authOptions != nil {
+		for name, path := range map[string]string{
+			"ca":     authOptions.CaCertPath,
+			"server": authOptions.ServerCertPath,
+			"client": authOptions.ClientCertPath,
+		} {
+			if path == "" {
+				continue
+			}
+			if _, notAfter, err := cert.ReadCertificateValidity(path); err == nil {
+				metrics.CertsExpiry[name] = notAfter
+			}
+		}
+	}
+
+	return metrics
+}
+
+// serveToken returns the token given by --token, or else generates one and
+// saves it in the storage path for the clients to read.
+func serveToken(c CommandLine) (string, error) {
+	if c.String("token") != "" {
+		return c.String("token"), nil
+	}
+
+	token, err := server.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(mcndirs.GetBaseDir(), "serve-token")
+	if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("Error saving the token: %s", err)
+	}
+
+	log.Infof("The token authenticating the requests is in %s", path)
+
+	return token, nil
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdServe(t *testing.T) {
+	defer func(orig func(*http.Server, string, string) error) { serveListenAndServe = orig }(serveListenAndServe)
+
+	var served *http.Server
+	serveListenAndServe = func(srv *http.Server, certFile, keyFile string) error {
+		served = srv
+		return nil
+	}
+
+	err := cmdServe(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"listen": "127.0.0.1:9999", "token": "secret"},
+		},
+	}, &libmachinetest.FakeAPI{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:9999", served.Addr)
+}
+
+func TestCmdServeTLSFlagsTogether(t *testing.T) {
+	err := cmdServe(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"tls-cert": "cert.pem", "token": "secret"},
+		},
+	}, &libmachinetest.FakeAPI{})
+
+	assert.EqualError(t, err, "Error: --tls-cert and --tls-key must be given together")
+}
+
+func TestCmdServeMetrics(t *testing.T) {
+	defer func(orig func(*http.Server, string, string) error) { serveListenAndServe = orig }(serveListenAndServe)
+
+	var (
+		mutex  sync.Mutex
+		served = map[string]http.Handler{}
+		done   = make(chan struct{})
+	)
+	serveListenAndServe = func(srv *http.Server, certFile, keyFile string) error {
+		mutex.Lock()
+		served[srv.Addr] = srv.Handler
+		complete := len(served) == 2
+		mutex.Unlock()
+
+		if complete {
+			close(done)
+		}
+		<-done
+		return nil
+	}
+
+	err := cmdServe(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"listen": "127.0.0.1:9999", "token": "secret", "metrics": ":9100"},
+		},
+	}, &libmachinetest.FakeAPI{})
+
+	assert.NoError(t, err)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Len(t, served, 2)
+	assert.NotNil(t, served[":9100"])
+}
+
+func TestCollectMetrics(t *testing.T) {
+	defer func(versioner mcndockerclient.DockerVersioner) { mcndockerclient.CurrentDockerVersioner = versioner }(mcndockerclient.CurrentDockerVersioner)
+	mcndockerclient.CurrentDockerVersioner = &mcndockerclient.FakeDockerVersioner{Version: "24.0.7"}
+
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath := filepath.Join(dir, "server.pem")
+	assert.NoError(t, cert.GenerateCACertificate(certPath, filepath.Join(dir, "server-key.pem"), "test-org", 2048))
+	_, notAfter, err := cert.ReadCertificateValidity(certPath)
+	assert.NoError(t, err)
+
+	provisioned := time.Unix(1700000000, 0)
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "dev",
+				DriverName: "fakedriver",
+				Driver:     &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"},
+				HostOptions: &host.Options{
+					AuthOptions: &auth.Options{ServerCertPath: certPath},
+				},
+				LastProvisioned: &provisioned,
+			},
+			{
+				Name:       "web",
+				DriverName: "fakedriver",
+				Driver:     &fakedriver.Driver{MockState: state.Stopped},
+			},
+		},
+	}
+
+	metrics, err := collectMetrics(func() libmachine.API { return api })()
+
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+
+	assert.Equal(t, "dev", metrics[0].Name)
+	assert.Equal(t, state.Running, metrics[0].State)
+	assert.Equal(t, "24.0.7", metrics[0].EngineVersion)
+	assert.Equal(t, provisioned, metrics[0].LastProvisioned)
+	assert.Equal(t, notAfter, metrics[0].CertsExpiry["server"])
+	assert.Len(t, metrics[0].CertsExpiry, 1)
+
+	assert.Equal(t, "web", metrics[1].Name)
+	assert.Equal(t, state.Stopped, metrics[1].State)
+	assert.Empty(t, metrics[1].EngineVersion)
+	assert.True(t, metrics[1].LastProvisioned.IsZero())
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errNoSnapshotName = errors.New("Error: Expected a machine name and a snapshot name as arguments")
+)
+
+// SnapshotItem is a snapshot of a machine as printed with --output json.
+type SnapshotItem struct {
+	ID      string
+	Name    string
+	Created *time.Time `json:",omitempty"`
+}
+
+// loadSnapshotter loads the machine given as first argument, checking that
+// its driver supports snapshots.
+func loadSnapshotter(c CommandLine, api libmachine.API) (*host.Host, drivers.Snapshotter, error) {
+	target, err := targetHost(c, api)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapshotter, ok := h.Driver.(drivers.Snapshotter)
+	if !ok || !drivers.GetCapabilities(h.Driver).Snapshots {
+		return nil, nil, drivers.ErrSnapshotsNotSupported
+	}
+
+	return h, snapshotter, nil
+}
+
+// snapshotName returns the snapshot name given after the machine name.
+func snapshotName(c CommandLine) (string, error) {
+	switch len(c.Args()) {
+	case 2:
+		return c.Args()[1], nil
+	case 0, 1:
+		return "", errNoSnapshotName
+	default:
+		return "", ErrTooManyArguments
+	}
+}
+
+func cmdSnapshotCreate(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 2 {
+		return ErrTooManyArguments
+	}
+
+	h, snapshotter, err := loadSnapshotter(c, api)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s", h.Name, time.Now().UTC().Format("20060102-150405"))
+	if len(c.Args()) == 2 {
+		name = c.Args()[1]
+	}
+
+	log.Infof("Taking snapshot %q of %q...", name, h.Name)
+	snapshot, err := snapshotter.CreateSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	if isJSONOutput(c) {
+		return printJSON(newSnapshotItem(*snapshot))
+	}
+
+	fmt.Println(snapshot.Name)
+
+	return nil
+}
+
+func cmdSnapshotList(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	_, snapshotter, err := loadSnapshotter(c, api)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := snapshotter.ListSnapshots()
+	if err != nil {
+		return err
+	}
+
+	if isJSONOutput(c) {
+		items := []SnapshotItem{}
+		for _, snapshot := range snapshots {
+			items = append(items, newSnapshotItem(snapshot))
+		}
+		return printJSON(items)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NAME\tID\tCREATED")
+	for _, snapshot := range snapshots {
+		created := ""
+		if !snapshot.Created.IsZero() {
+			created = snapshot.Created.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", snapshot.Name, snapshot.ID, created)
+	}
+
+	return nil
+}
+
+func cmdSnapshotRestore(c CommandLine, api libmachine.API) error {
+	name, err := snapshotName(c)
+	if err != nil {
+		return err
+	}
+
+	h, snapshotter, err := loadSnapshotter(c, api)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Restoring %q to snapshot %q...", h.Name, name)
+	if err := snapshotter.RestoreSnapshot(name); err != nil {
+		return err
+	}
+
+	// Restarting the machine may change its IP address
+	return api.Save(h)
+}
+
+func cmdSnapshotDelete(c CommandLine, api libmachine.API) error {
+	name, err := snapshotName(c)
+	if err != nil {
+		return err
+	}
+
+	h, snapshotter, err := loadSnapshotter(c, api)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Deleting snapshot %q of %q...", name, h.Name)
+	return snapshotter.DeleteSnapshot(name)
+}
+
+func newSnapshotItem(snapshot drivers.Snapshot) SnapshotItem {
+	item := SnapshotItem{
+		ID:   snapshot.ID,
+		Name: snapshot.Name,
+	}
+
+	if !snapshot.Created.IsZero() {
+		item.Created = &snapshot.Created
+	}
+
+	return item
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSnapshotDriver struct {
+	fakedriver.Driver
+	snapshots []drivers.Snapshot
+	restored  string
+}
+
+func (d *fakeSnapshotDriver) CreateSnapshot(name string) (*drivers.Snapshot, error) {
+	d.snapshots = append(d.snapshots, drivers.Snapshot{ID: "id-" + name, Name: name})
+	return &d.snapshots[len(d.snapshots)-1], nil
+}
+
+func (d *fakeSnapshotDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	return d.snapshots, nil
+}
+
+func (d *fakeSnapshotDriver) RestoreSnapshot(name string) error {
+	snapshot, err := drivers.FindSnapshot(d.snapshots, name)
+	if err != nil {
+		return err
+	}
+	d.restored = snapshot.Name
+	return nil
+}
+
+func (d *fakeSnapshotDriver) DeleteSnapshot(name string) error {
+	for i, snapshot := range d.snapshots {
+		if snapshot.Name == name || snapshot.ID == name {
+			d.snapshots = append(d.snapshots[:i], d.snapshots[i+1:]...)
+			return nil
+		}
+	}
+	_, err := drivers.FindSnapshot(nil, name)
+	return err
+}
+
+func TestCmdSnapshot(t *testing.T) {
+	driver := &fakeSnapshotDriver{}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "myhost",
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdSnapshotCreate(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "clean"}}, api)
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{{ID: "id-clean", Name: "clean"}}, driver.snapshots)
+
+	err = cmdSnapshotRestore(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "id-clean"}}, api)
+	assert.NoError(t, err)
+	assert.Equal(t, "clean", driver.restored)
+
+	err = cmdSnapshotDelete(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "clean"}}, api)
+	assert.NoError(t, err)
+	assert.Empty(t, driver.snapshots)
+
+	err = cmdSnapshotDelete(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "clean"}}, api)
+	assert.EqualError(t, err, `Snapshot "clean" does not exist`)
+}
+
+func TestCmdSnapshotList(t *testing.T) {
+	stdoutGetter := commandstest.NewStdoutGetter()
+	defer stdoutGetter.Stop()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "myhost",
+				Driver: &fakeSnapshotDriver{
+					snapshots: []drivers.Snapshot{{ID: "1", Name: "clean"}},
+				},
+			},
+		},
+	}
+
+	err := cmdSnapshotList(&commandstest.FakeCommandLine{CliArgs: []string{"myhost"}}, api)
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME    ID   CREATED\nclean   1    \n", stdoutGetter.Output())
+}
+
+func TestCmdSnapshotArguments(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "myhost",
+				Driver: &fakeSnapshotDriver{},
+			},
+		},
+	}
+
+	err := cmdSnapshotRestore(&commandstest.FakeCommandLine{CliArgs: []string{"myhost"}}, api)
+	assert.Equal(t, errNoSnapshotName, err)
+
+	err = cmdSnapshotDelete(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "a", "b"}}, api)
+	assert.Equal(t, ErrTooManyArguments, err)
+}
+
+func TestCmdSnapshotNotSupported(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "myhost",
+				Driver: &fakedriver.Driver{},
+			},
+		},
+	}
+
+	err := cmdSnapshotCreate(&commandstest.FakeCommandLine{CliArgs: []string{"myhost"}}, api)
+	assert.Equal(t, drivers.ErrSnapshotsNotSupported, err)
+}
+
+// pluginSnapshotDriver implements the snapshots like the plugins, whose
+// driver may not support them.
+type pluginSnapshotDriver struct {
+	*fakeSnapshotDriver
+}
+
+func (d *pluginSnapshotDriver) Capabilities() drivers.Capabilities {
+	return drivers.Capabilities{}
+}
+
+func TestCmdSnapshotNotSupportedByPluginDriver(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "myhost",
+				Driver: &pluginSnapshotDriver{&fakeSnapshotDriver{}},
+			},
+		},
+	}
+
+	err := cmdSnapshotCreate(&commandstest.FakeCommandLine{CliArgs: []string{"myhost", "clean"}}, api)
+	assert.Equal(t, drivers.ErrSnapshotsNotSupported, err)
+}
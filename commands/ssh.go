@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 )
 
@@ -15,6 +16,14 @@ func (e errStateInvalidForSSH) Error() string {
 	return fmt.Sprintf("Error: Cannot run SSH command: Host %q is not running", e.HostName)
 }
 
+// errExitStatus makes docker-machine exit with the status of the command
+// run on the machine, without reporting an error.
+type errExitStatus int
+
+func (e errExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
 func cmdSSH(c CommandLine, api libmachine.API) error {
 	// Check for help flag -- Needed due to SkipFlagParsing
 	firstArg := c.Args().First()
@@ -47,5 +56,10 @@ func cmdSSH(c CommandLine, api libmachine.API) error {
 		return err
 	}
 
-	return client.Shell(c.Args().Tail()...)
+	err = client.Shell(c.Args().Tail()...)
+	if status, ok := ssh.ExitStatus(err); ok {
+		return errExitStatus(status)
+	}
+
+	return err
 }
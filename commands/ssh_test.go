@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"os/exec"
+	"runtime"
 	"testing"
 
 	"github.com/docker/machine/commands/commandstest"
@@ -109,3 +111,39 @@ func TestCmdSSH(t *testing.T) {
 		}
 	}
 }
+
+type exitingSSHClient struct {
+	sshtest.FakeClient
+	err error
+}
+
+func (c *exitingSSHClient) Shell(args ...string) error {
+	return c.err
+}
+
+func TestCmdSSHExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"default", "false"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "default",
+				Driver: &fakedriver.Driver{
+					MockState: state.Running,
+				},
+			},
+		},
+	}
+
+	host.SetSSHClientCreator(&FakeSSHClientCreator{client: &exitingSSHClient{err: exec.Command("sh", "-c", "exit 3").Run()}})
+	defer host.SetSSHClientCreator(&host.StandardSSHClientCreator{})
+
+	err := cmdSSH(commandLine, api)
+
+	assert.Equal(t, errExitStatus(3), err)
+}
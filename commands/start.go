@@ -6,7 +6,7 @@ import (
 )
 
 func cmdStart(c CommandLine, api libmachine.API) error {
-	if err := runAction("start", c, api); err != nil {
+	if err := runBatchAction("start", c, api); err != nil {
 		return err
 	}
 
@@ -1,26 +1,151 @@
 package commands
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// The exit statuses of status --wait-for, which CI pipelines gate on.  The
+// other failures, e.g. invalid flags, exit with 1.
+const (
+	statusExitTimeout      = 2
+	statusExitMachineError = 3
+	statusExitNotFound     = 4
+)
+
+const defaultStatusTimeout = 10 * time.Minute
+
+var (
+	errStatusTimeoutWithoutWait = errors.New("Error: --timeout only applies to --wait-for")
+	errStatusInvalidTimeout     = errors.New("Error: --timeout must be a positive duration, e.g. 30s or 10m")
+
+	// statusWaitInterval is the delay between two polls of the state of a
+	// machine.
+	statusWaitInterval = 2 * time.Second
 )
 
+// StatusItem is the status of a machine as printed with --output json, and
+// given to the template of --format.
+type StatusItem struct {
+	Name  string
+	State state.State
+	Error string
+}
+
+// errStatusWait is a failure of status --wait-for, which exits with its own
+// status.
+type errStatusWait struct {
+	status int
+	err    error
+}
+
+func (e errStatusWait) Error() string {
+	return e.err.Error()
+}
+
 func cmdStatus(c CommandLine, api libmachine.API) error {
-	if len(c.Args()) > 1 {
-		return ErrExpectedOneMachine
+	waitFor := c.String("wait-for")
+	if waitFor == "" {
+		if c.String("timeout") != "" {
+			return errStatusTimeoutWithoutWait
+		}
+		return printStatus(c, api)
 	}
 
-	target, err := targetHost(c, api)
+	desired, err := parseWaitState(waitFor)
 	if err != nil {
 		return err
 	}
 
-	host, err := api.Load(target)
+	timeout := defaultStatusTimeout
+	if c.String("timeout") != "" {
+		if timeout, err = time.ParseDuration(c.String("timeout")); err != nil || timeout <= 0 {
+			return errStatusInvalidTimeout
+		}
+	}
+
+	hosts, err := loadStatusHosts(c, api)
+	switch err {
+	case nil:
+	case errAllWithNames:
+		return err
+	default:
+		return errStatusWait{status: statusExitNotFound, err: err}
+	}
+
+	items, err := waitForState(hosts, desired, timeout)
+	if isJSONOutput(c) {
+		if printErr := printJSON(items); printErr != nil {
+			return printErr
+		}
+		if waitErr, ok := err.(errStatusWait); ok {
+			return errStatusWait{status: waitErr.status, err: errJSONReported}
+		}
+		return err
+	}
+
+	if printErr := printStatusItems(c, hosts, items); printErr != nil {
+		return printErr
+	}
+	return err
+}
+
+// printStatus prints the current status of the machines.
+func printStatus(c CommandLine, api libmachine.API) error {
+	hosts, err := loadStatusHosts(c, api)
 	if err != nil {
 		return err
 	}
 
+	if len(hosts) == 1 && !c.Bool("all") {
+		return printHostStatus(c, hosts[0])
+	}
+
+	items := make([]StatusItem, len(hosts))
+	for i, h := range hosts {
+		items[i] = hostStatusItem(h)
+	}
+
+	if isJSONOutput(c) {
+		return printJSON(items)
+	}
+	return printStatusItems(c, hosts, items)
+}
+
+func printHostStatus(c CommandLine, host *host.Host) error {
 	currentState, err := host.Driver.GetState()
+
+	if isJSONOutput(c) || c.String("format") != "" {
+		item := StatusItem{
+			Name:  host.Name,
+			State: currentState,
+		}
+		if err != nil {
+			item.Error = err.Error()
+		}
+
+		if isJSONOutput(c) {
+			return printJSON(item)
+		}
+
+		tmpl, err := parseTemplate(c.String("format")+"\n", hostFuncMap(host))
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(os.Stdout, item)
+	}
+
 	if err != nil {
 		log.Errorf("error getting state for host %s: %s", host.Name, err)
 	}
@@ -29,3 +154,183 @@ func cmdStatus(c CommandLine, api libmachine.API) error {
 
 	return nil
 }
+
+// printStatusItems prints the status of several machines as a table, or
+// each with the template of --format.
+func printStatusItems(c CommandLine, hosts []*host.Host, items []StatusItem) error {
+	if c.String("format") != "" {
+		for i, item := range items {
+			tmpl, err := parseTemplate(c.String("format")+"\n", hostFuncMap(hosts[i]))
+			if err != nil {
+				return err
+			}
+
+			if err := tmpl.Execute(os.Stdout, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NAME\tSTATE\tERROR")
+	for _, item := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", item.Name, item.State, item.Error)
+	}
+	return nil
+}
+
+func hostStatusItem(h *host.Host) StatusItem {
+	currentState, err := h.Driver.GetState()
+
+	item := StatusItem{
+		Name:  h.Name,
+		State: currentState,
+	}
+	if err != nil {
+		item.Error = err.Error()
+	}
+	return item
+}
+
+// loadStatusHosts loads all the machines matching the --filter expressions
+// with --all, or else the machines given as arguments like stop.
+func loadStatusHosts(c CommandLine, api libmachine.API) ([]*host.Host, error) {
+	if !c.Bool("all") {
+		return loadTargetHosts(c, api)
+	}
+
+	if len(c.Args()) > 0 {
+		return nil, errAllWithNames
+	}
+
+	filters, err := parseFilters(c.StringSlice("filter"))
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, _, err := persist.LoadAllHosts(api)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts = filterHosts(hosts, filters)
+	if len(hosts) == 0 {
+		return nil, errNoMachineMatches
+	}
+	return hosts, nil
+}
+
+// parseWaitState parses the state given to --wait-for, whatever its case.
+func parseWaitState(name string) (state.State, error) {
+	names := []string{}
+	for s := state.Running; s <= state.Timeout; s++ {
+		if strings.EqualFold(s.String(), name) {
+			return s, nil
+		}
+		names = append(names, s.String())
+	}
+
+	return state.None, fmt.Errorf("Error: unknown state %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// waitForState polls the machines concurrently until they're all in the
+// desired state, one of them is in the Error state, or the timeout expires.
+func waitForState(hosts []*host.Host, desired state.State, timeout time.Duration) ([]StatusItem, error) {
+	log.Infof("Waiting up to %s for %s to be %s...", timeout, hostNames(hosts), desired)
+
+	deadline := time.Now().Add(timeout)
+	items := make([]StatusItem, len(hosts))
+
+	// A machine in the Error state stops the wait for the others
+	failure := make(chan struct{})
+	var failureOnce sync.Once
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h *host.Host) {
+			defer wg.Done()
+			items[i] = waitForHostState(h, desired, deadline, failure)
+			if items[i].State == state.Error && items[i].Error == "" && desired != state.Error {
+				failureOnce.Do(func() { close(failure) })
+			}
+		}(i, h)
+	}
+	wg.Wait()
+
+	failed, late := []string{}, []string{}
+	lateItems := []*StatusItem{}
+	for i, item := range items {
+		switch {
+		case item.State == desired && item.Error == "":
+		case item.State == state.Error && item.Error == "":
+			failed = append(failed, item.Name)
+			items[i].Error = "The machine is in the Error state"
+		default:
+			late = append(late, item.Name)
+			lateItems = append(lateItems, &items[i])
+		}
+	}
+
+	for _, item := range lateItems {
+		switch {
+		case item.Error != "":
+		case len(failed) > 0:
+			item.Error = fmt.Sprintf("Not %s when the wait stopped", desired)
+		default:
+			item.Error = fmt.Sprintf("Timed out waiting for %s", desired)
+		}
+	}
+
+	if len(failed) > 0 {
+		return items, errStatusWait{
+			status: statusExitMachineError,
+			err:    fmt.Errorf("Error: machines in the Error state: %s", strings.Join(failed, ", ")),
+		}
+	}
+
+	if len(late) > 0 {
+		return items, errStatusWait{
+			status: statusExitTimeout,
+			err:    fmt.Errorf("Error: timed out after %s waiting for %s to be %s", timeout, strings.Join(late, ", "), desired),
+		}
+	}
+
+	return items, nil
+}
+
+// waitForHostState polls a machine until it's in the desired state or the
+// Error state, the deadline passes or the wait fails for another machine.
+// The errors getting the state, e.g. while the machine boots, are retried.
+func waitForHostState(h *host.Host, desired state.State, deadline time.Time, failure <-chan struct{}) StatusItem {
+	for {
+		item := hostStatusItem(h)
+		if item.Error == "" && (item.State == desired || item.State == state.Error) {
+			if item.State == desired {
+				log.Infof("%s is %s", h.Name, desired)
+			}
+			return item
+		}
+
+		if time.Now().Add(statusWaitInterval).After(deadline) {
+			return item
+		}
+
+		select {
+		case <-failure:
+			return item
+		case <-time.After(statusWaitInterval):
+		}
+	}
+}
+
+func hostNames(hosts []*host.Host) string {
+	names := []string{}
+	for _, h := range hosts {
+		names = append(names, h.Name)
+	}
+	return strings.Join(names, ", ")
+}
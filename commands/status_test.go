@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStatusAPI() *libmachinetest.FakeAPI {
+	return &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "ci-1",
+				Driver: &fakedriver.Driver{MockState: state.Running},
+			},
+			{
+				Name:   "ci-2",
+				Driver: &fakedriver.Driver{MockState: state.Stopped},
+			},
+			{
+				Name:   "broken",
+				Driver: &fakedriver.Driver{MockState: state.Error},
+			},
+		},
+	}
+}
+
+func newStatusCommandLine(args []string, flags map[string]interface{}) *commandstest.FakeCommandLine {
+	return &commandstest.FakeCommandLine{
+		CliArgs:    args,
+		LocalFlags: &commandstest.FakeFlagger{Data: flags},
+	}
+}
+
+func TestParseWaitState(t *testing.T) {
+	desired, err := parseWaitState("running")
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, desired)
+
+	_, err = parseWaitState("up")
+	assert.EqualError(t, err, `Error: unknown state "up", expected one of Running, Paused, Saved, Stopped, Stopping, Starting, Error, Timeout`)
+}
+
+func TestCmdStatusWaitFor(t *testing.T) {
+	defer func(interval time.Duration) { statusWaitInterval = interval }(statusWaitInterval)
+	statusWaitInterval = 10 * time.Millisecond
+
+	commandLine := newStatusCommandLine([]string{"ci-*"}, map[string]interface{}{"wait-for": "Running", "timeout": "100ms"})
+
+	err := cmdStatus(commandLine, newStatusAPI())
+
+	assert.EqualError(t, err, "Error: timed out after 100ms waiting for ci-2 to be Running")
+	assert.Equal(t, statusExitTimeout, err.(errStatusWait).status)
+
+	commandLine = newStatusCommandLine([]string{"ci-1"}, map[string]interface{}{"wait-for": "Running"})
+
+	assert.NoError(t, cmdStatus(commandLine, newStatusAPI()))
+}
+
+func TestCmdStatusWaitForMachineError(t *testing.T) {
+	defer func(interval time.Duration) { statusWaitInterval = interval }(statusWaitInterval)
+	statusWaitInterval = 10 * time.Millisecond
+
+	commandLine := newStatusCommandLine(nil, map[string]interface{}{"all": true, "wait-for": "Running"})
+
+	start := time.Now()
+	err := cmdStatus(commandLine, newStatusAPI())
+
+	assert.EqualError(t, err, "Error: machines in the Error state: broken")
+	assert.Equal(t, statusExitMachineError, err.(errStatusWait).status)
+	assert.True(t, time.Since(start) < defaultStatusTimeout)
+}
+
+func TestCmdStatusWaitForNotFound(t *testing.T) {
+	commandLine := newStatusCommandLine([]string{"missing"}, map[string]interface{}{"wait-for": "Running"})
+
+	err := cmdStatus(commandLine, newStatusAPI())
+
+	assert.Equal(t, statusExitNotFound, err.(errStatusWait).status)
+}
+
+func TestCmdStatusFlagErrors(t *testing.T) {
+	commandLine := newStatusCommandLine(nil, map[string]interface{}{"timeout": "10m"})
+	assert.Equal(t, errStatusTimeoutWithoutWait, cmdStatus(commandLine, newStatusAPI()))
+
+	commandLine = newStatusCommandLine(nil, map[string]interface{}{"wait-for": "Running", "timeout": "soon"})
+	assert.Equal(t, errStatusInvalidTimeout, cmdStatus(commandLine, newStatusAPI()))
+
+	commandLine = newStatusCommandLine([]string{"ci-1"}, map[string]interface{}{"all": true})
+	assert.Equal(t, errAllWithNames, cmdStatus(commandLine, newStatusAPI()))
+}
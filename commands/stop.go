@@ -3,5 +3,5 @@ package commands
 import "github.com/docker/machine/libmachine"
 
 func cmdStop(c CommandLine, api libmachine.API) error {
-	return runAction("stop", c, api)
+	return runBatchAction("stop", c, api)
 }
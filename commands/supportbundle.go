@@ -0,0 +1,216 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/state"
+)
+
+var (
+	errNoSupportBundleName = errors.New("Error: Expected the name of the machine to collect as argument")
+
+	// supportBundleSSH and supportBundleDetect are replaced by the tests.
+	supportBundleSSH = func(h *host.Host, command string) (string, error) {
+		return h.RunSSHCommand(command)
+	}
+	supportBundleDetect = func(h *host.Host) (provision.Provisioner, error) {
+		return h.DetectProvisioner()
+	}
+)
+
+// supportBundleCommand is a command run on the machine, whose output is
+// saved to a file of the bundle.
+type supportBundleCommand struct {
+	file    string
+	command string
+}
+
+var supportBundleCommands = []supportBundleCommand{
+	{"uname.txt", "uname -a"},
+	{"os-release.txt", "cat /etc/os-release"},
+	{"docker-version.txt", "sudo docker version"},
+	{"docker-info.txt", "sudo docker info"},
+	{"dockerd.log", "if type journalctl >/dev/null 2>&1; then sudo journalctl -u docker --no-pager -n 5000; else sudo tail -n 5000 /var/log/docker.log; fi"},
+	{"df.txt", "df -h"},
+}
+
+// supportBundle is the content of a bundle by file name, in the order the
+// files were added.
+type supportBundle struct {
+	names []string
+	files map[string][]byte
+}
+
+func (b *supportBundle) add(name, content string) {
+	if b.files == nil {
+		b.files = map[string][]byte{}
+	}
+	if _, ok := b.files[name]; !ok {
+		b.names = append(b.names, name)
+	}
+	b.files[name] = []byte(content)
+}
+
+// addCommand runs a command on the machine and adds its output to the
+// bundle, or the error it failed with: the bundles are mostly collected
+// from broken machines.
+func (b *supportBundle) addCommand(h *host.Host, name, command string) {
+	output, err := supportBundleSSH(h, command)
+	if err != nil {
+		log.Debugf("Error running %q on %s: %s", command, h.Name, err)
+		output = fmt.Sprintf("%s\nError running %q: %s\n", output, command, err)
+	}
+	b.add(name, output)
+}
+
+// collectSupportBundle gathers the state of a machine and of its engine.
+// The configuration of the machine is left out, since it holds the
+// credentials of the driver.
+func collectSupportBundle(h *host.Host) *supportBundle {
+	b := &supportBundle{}
+
+	var machine bytes.Buffer
+	fmt.Fprintf(&machine, "Name: %s\nDriver: %s\n", h.Name, h.DriverName)
+
+	s, err := h.Driver.GetState()
+	if err != nil {
+		fmt.Fprintf(&machine, "State: error: %s\n", err)
+	} else {
+		fmt.Fprintf(&machine, "State: %s\n", s)
+	}
+
+	if url, err := h.URL(); err != nil {
+		fmt.Fprintf(&machine, "URL: error: %s\n", err)
+	} else {
+		fmt.Fprintf(&machine, "URL: %s\n", url)
+	}
+	b.add("machine.txt", machine.String())
+
+	b.add("certificates.txt", certificatesExpiry(h))
+
+	if s != state.Running {
+		log.Warnf("%s is not running, only its local state is collected", h.Name)
+		return b
+	}
+
+	provisioner, err := supportBundleDetect(h)
+	if err != nil {
+		b.add("provisioner.txt", fmt.Sprintf("Error detecting the provisioner: %s\n", err))
+	} else {
+		b.add("provisioner.txt", fmt.Sprintf("Provisioner: %s\n", provisioner.String()))
+	}
+
+	for _, c := range supportBundleCommands {
+		log.Debugf("collecting %s", c.file)
+		b.addCommand(h, c.file, c.command)
+	}
+
+	daemonConfig := "/etc/docker/daemon.json"
+	if provisioner != nil && provisioner.GetDockerOptionsDir() != "" {
+		daemonConfig = path.Join(provisioner.GetDockerOptionsDir(), "daemon.json")
+	}
+	b.addCommand(h, "daemon.json", fmt.Sprintf("sudo cat %s", daemonConfig))
+
+	return b
+}
+
+// certificatesExpiry tells when the certificates of a machine expire.
+func certificatesExpiry(h *host.Host) string {
+	authOptions := h.AuthOptions()
+	if authOptions == nil {
+		return "No certificates\n"
+	}
+
+	var buf bytes.Buffer
+	for _, c := range []struct {
+		name string
+		path string
+	}{
+		{"CA", authOptions.CaCertPath},
+		{"Server", authOptions.ServerCertPath},
+		{"Client", authOptions.ClientCertPath},
+	} {
+		if c.path == "" {
+			continue
+		}
+
+		_, notAfter, err := cert.ReadCertificateValidity(c.path)
+		if err != nil {
+			fmt.Fprintf(&buf, "%s certificate %s: error: %s\n", c.name, c.path, err)
+			continue
+		}
+		fmt.Fprintf(&buf, "%s certificate %s: expires %s\n", c.name, c.path, notAfter.Format(time.RFC3339))
+	}
+
+	return buf.String()
+}
+
+// write writes the bundle as a gzipped tarball whose files are in the dir
+// directory.
+func (b *supportBundle) write(w io.Writer, dir string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, name := range b.names {
+		if err := addTarFile(tw, path.Join(dir, name), 0600, b.files[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func cmdSupportBundle(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrTooManyArguments
+	}
+
+	name := c.Args().First()
+	if name == "" {
+		return errNoSupportBundleName
+	}
+
+	h, err := api.Load(name)
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = name + "-support.tar.gz"
+	}
+
+	log.Infof("Collecting the support bundle of %s...", name)
+	bundle := collectSupportBundle(h)
+
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := bundle.write(f, name+"-support"); err != nil {
+		return fmt.Errorf("Error writing the support bundle of %s: %s", name, err)
+	}
+
+	log.Infof("Wrote the support bundle of %q to %s, review it before sharing it.", name, output)
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeSupportBundleSSH(responses map[string]string) func() {
+	supportBundleSSH = func(h *host.Host, command string) (string, error) {
+		output, ok := responses[command]
+		if !ok {
+			return "", errors.New("exit status 1")
+		}
+		return output, nil
+	}
+	supportBundleDetect = func(h *host.Host) (provision.Provisioner, error) {
+		return &provision.FakeProvisioner{}, nil
+	}
+
+	return func() {
+		supportBundleSSH = func(h *host.Host, command string) (string, error) {
+			return h.RunSSHCommand(command)
+		}
+		supportBundleDetect = func(h *host.Host) (provision.Provisioner, error) {
+			return h.DetectProvisioner()
+		}
+	}
+}
+
+func readSupportBundle(t *testing.T, path string) map[string]string {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+
+	files := map[string]string{}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+
+		var content bytes.Buffer
+		io.Copy(&content, tr)
+		files[header.Name] = content.String()
+	}
+
+	return files
+}
+
+func TestCmdSupportBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-support-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer fakeSupportBundleSSH(map[string]string{
+		"uname -a":                         "Linux dev 6.1.0 x86_64 GNU/Linux\n",
+		"sudo docker info":                 "Server Version: 24.0.7\n",
+		"sudo cat /etc/docker/daemon.json": `{"log-level":"debug"}`,
+	})()
+
+	output := filepath.Join(dir, "bundle.tar.gz")
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"output": output},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "dev",
+				DriverName: "fakedriver",
+				Driver:     &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"},
+			},
+		},
+	}
+
+	assert.NoError(t, cmdSupportBundle(commandLine, api))
+
+	files := readSupportBundle(t, output)
+	assert.Equal(t, "Name: dev\nDriver: fakedriver\nState: Running\nURL: tcp://10.0.0.1:2376\n", files["dev-support/machine.txt"])
+	assert.Equal(t, "Provisioner: fakeprovisioner\n", files["dev-support/provisioner.txt"])
+	assert.Equal(t, "Server Version: 24.0.7\n", files["dev-support/docker-info.txt"])
+	assert.Equal(t, `{"log-level":"debug"}`, files["dev-support/daemon.json"])
+	assert.Contains(t, files["dev-support/docker-version.txt"], `Error running "sudo docker version": exit status 1`)
+	assert.Equal(t, "No certificates\n", files["dev-support/certificates.txt"])
+}
+
+func TestCmdSupportBundleNotRunning(t *testing.T) {
+	h := &host.Host{
+		Name:       "dev",
+		DriverName: "fakedriver",
+		Driver:     &fakedriver.Driver{MockState: state.Stopped},
+	}
+
+	bundle := collectSupportBundle(h)
+
+	assert.Equal(t, []string{"machine.txt", "certificates.txt"}, bundle.names)
+}
+
+func TestCmdSupportBundleNoName(t *testing.T) {
+	err := cmdSupportBundle(&commandstest.FakeCommandLine{}, &libmachinetest.FakeAPI{})
+
+	assert.Equal(t, errNoSupportBundleName, err)
+}
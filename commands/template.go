@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+)
+
+// funcMap holds the functions available to the templates of --format, named
+// after their sprig counterparts so that scripts don't need jq anymore.
+var funcMap = template.FuncMap{
+	"json": func(v interface{}) string {
+		a, _ := json.Marshal(v)
+		return string(a)
+	},
+	"prettyjson": func(v interface{}) string {
+		a, _ := json.MarshalIndent(v, "", "    ")
+		return string(a)
+	},
+	"get":        templateGet,
+	"default":    templateDefault,
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      strings.Title,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       templateJoin,
+	"quote":      func(v interface{}) string { return fmt.Sprintf("%q", fmt.Sprint(v)) },
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+	},
+}
+
+// hostFuncMap adds to funcMap the functions querying the driver of a machine,
+// so that its live state is only fetched when the template uses it.
+func hostFuncMap(h *host.Host) template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, f := range funcMap {
+		funcs[name] = f
+	}
+
+	funcs["state"] = func() (string, error) {
+		s, err := h.Driver.GetState()
+		return s.String(), err
+	}
+	funcs["ip"] = h.Driver.GetIP
+	funcs["privateIP"] = func() (string, error) {
+		return drivers.GetPrivateIP(h.Driver)
+	}
+	funcs["url"] = h.URL
+	funcs["dockerVersion"] = func() (string, error) {
+		return mcndockerclient.DockerVersion(h)
+	}
+
+	return funcs
+}
+
+// parseTemplate parses the template given to --format, in which \t and \n
+// are a tab and a newline.
+func parseTemplate(format string, funcs template.FuncMap) (*template.Template, error) {
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+
+	tmpl, err := template.New("").Funcs(funcs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("Template parsing error: %v", err)
+	}
+
+	return tmpl, nil
+}
+
+// templateGet returns the value at a dotted path of a value, e.g.
+// "Driver.IPAddress", or nil if there's none.
+func templateGet(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+
+		value := reflect.ValueOf(v)
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return nil
+			}
+			value = value.Elem()
+		}
+
+		switch value.Kind() {
+		case reflect.Map:
+			value = value.MapIndex(reflect.ValueOf(key))
+		case reflect.Struct:
+			value = value.FieldByName(key)
+		default:
+			return nil
+		}
+
+		if !value.IsValid() {
+			return nil
+		}
+		v = value.Interface()
+	}
+
+	return v
+}
+
+// templateDefault returns a value, or the default when the value is empty.
+func templateDefault(def, v interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if value.Len() == 0 {
+			return def
+		}
+	case reflect.Bool:
+		if !value.Bool() {
+			return def
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() == 0 {
+			return def
+		}
+	case reflect.Float32, reflect.Float64:
+		if value.Float() == 0 {
+			return def
+		}
+	}
+
+	return v
+}
+
+// templateJoin joins the items of a list, whatever their type.
+func templateJoin(sep string, v interface{}) string {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return fmt.Sprint(v)
+	}
+
+	items := make([]string, value.Len())
+	for i := range items {
+		items[i] = fmt.Sprint(value.Index(i).Interface())
+	}
+
+	return strings.Join(items, sep)
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func executeTemplate(t *testing.T, format string, data interface{}) string {
+	tmpl, err := parseTemplate(format, funcMap)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, tmpl.Execute(&out, data))
+
+	return out.String()
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"Name": "dev",
+		"Driver": map[string]interface{}{
+			"IPAddress": "192.168.99.100",
+			"Labels":    []interface{}{"a", "b"},
+		},
+		"Empty": "",
+	}
+
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{`{{upper .Name}}`, "DEV"},
+		{`{{.Name | title}}`, "Dev"},
+		{`{{get . "Driver.IPAddress"}}`, "192.168.99.100"},
+		{`{{json (get . "Driver.Labels")}}`, `["a","b"]`},
+		{`{{get . "Driver.Missing" | default "none"}}`, "none"},
+		{`{{.Empty | default "none"}}`, "none"},
+		{`{{join "," .Driver.Labels}}`, "a,b"},
+		{`{{replace "." "-" .Driver.IPAddress}}`, "192-168-99-100"},
+		{`{{if hasPrefix "192." .Driver.IPAddress}}private{{end}}`, "private"},
+		{`{{index (split "." .Driver.IPAddress) 3}}`, "100"},
+		{`{{quote .Name}}`, `"dev"`},
+		{`{{.Name}}\t{{.Name}}`, "dev\tdev"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, executeTemplate(t, tc.format, data), tc.format)
+	}
+}
+
+func TestTemplateGetStruct(t *testing.T) {
+	item := &IPItem{Name: "dev", IP: "1.2.3.4"}
+
+	assert.Equal(t, "1.2.3.4", templateGet(item, "IP"))
+	assert.Nil(t, templateGet(item, "IP.Missing"))
+	assert.Nil(t, templateGet(nil, "IP"))
+}
+
+func TestParseTemplateError(t *testing.T) {
+	_, err := parseTemplate("{{.Name", funcMap)
+
+	assert.Error(t, err)
+}
+
+func TestHostFuncMap(t *testing.T) {
+	h := &host.Host{
+		Name: "dev",
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+			MockIP:    "1.2.3.4",
+		},
+	}
+
+	tmpl, err := parseTemplate(`{{state}} {{ip}}`, hostFuncMap(h))
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, tmpl.Execute(&out, nil))
+	assert.Equal(t, "Running 1.2.3.4", out.String())
+}
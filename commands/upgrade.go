@@ -1,7 +1,43 @@
 package commands
 
-import "github.com/docker/machine/libmachine"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/engine/validate"
+	"github.com/docker/machine/libmachine/provision"
+)
 
 func cmdUpgrade(c CommandLine, api libmachine.API) error {
-	return runAction("upgrade", c, api)
+	version := c.String("engine-version")
+	if version != "" {
+		if err := provision.ValidateEngineVersion(version); err != nil {
+			return err
+		}
+	}
+
+	hosts, err := loadTargetHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	// The version is saved with the machine, an upgrade without
+	// --engine-version unpins it.  The options of the engine are checked
+	// against the version before any machine is upgraded.
+	for _, h := range hosts {
+		if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+			if err := validate.Options(h.HostOptions.EngineOptions, validate.Target{EngineVersion: version}); err != nil {
+				return fmt.Errorf("Error upgrading %s: %s", h.Name, strings.TrimPrefix(err.Error(), "Error: "))
+			}
+		}
+	}
+
+	for _, h := range hosts {
+		if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+			h.HostOptions.EngineOptions.Version = version
+		}
+	}
+
+	return runBatchActionOnHosts("upgrade", c, hosts, api)
 }
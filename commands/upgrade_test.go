@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdUpgradeInvalidEngineVersion(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"engine-version": "24.0.7; reboot",
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdUpgrade(commandLine, api)
+
+	assert.EqualError(t, err, `Error: invalid engine version "24.0.7; reboot", expected e.g. 24.0.7`)
+}
+
+func TestCmdUpgradePinsEngineVersion(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"engine-version": "24.0.7",
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "machine",
+				Driver: &fakedriver.Driver{
+					MockState: state.Stopped,
+				},
+				HostOptions: &host.Options{
+					EngineOptions: &engine.Options{},
+				},
+			},
+		},
+	}
+
+	err := cmdUpgrade(commandLine, api)
+
+	assert.EqualError(t, err, "Error: machine must be running to upgrade.")
+	assert.Equal(t, "24.0.7", api.Hosts[0].HostOptions.EngineOptions.Version)
+}
+
+func TestCmdUpgradeRemovedEngineOption(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"engine-version": "24.0.7",
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "machine",
+				Driver: &fakedriver.Driver{
+					MockState: state.Running,
+				},
+				HostOptions: &host.Options{
+					EngineOptions: &engine.Options{
+						ArbitraryFlags: []string{"graph=/data/docker"},
+						Version:        "20.10.24",
+					},
+				},
+			},
+		},
+	}
+
+	err := cmdUpgrade(commandLine, api)
+
+	assert.EqualError(t, err, `Error upgrading machine: the engine can't start with its options (Docker 24.0.7):
+  --engine-opt graph was removed in Docker 23.0, use --engine-opt data-root=<dir> instead`)
+	assert.Equal(t, "20.10.24", api.Hosts[0].HostOptions.EngineOptions.Version)
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/monitor"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+func cmdWatch(c CommandLine, api libmachine.API) error {
+	var hook monitor.Hook
+	if c.String("hook") != "" {
+		hook = &monitor.ScriptHook{Path: c.String("hook")}
+	}
+
+	m := monitor.NewMonitor(hook, !c.Bool("no-repair"))
+
+	load := func() ([]*host.Host, error) {
+		return loadWatchedHosts(c, api)
+	}
+
+	if c.Bool("once") {
+		hosts, err := load()
+		if err != nil {
+			return err
+		}
+
+		m.Poll(hosts)
+		return nil
+	}
+
+	interval := monitor.DefaultInterval
+	if c.Int("interval") > 0 {
+		interval = time.Duration(c.Int("interval")) * time.Second
+	}
+
+	return m.Run(load, interval, nil)
+}
+
+// loadWatchedHosts loads the machines given as arguments, or all the
+// machines.  The machines which can't be loaded, e.g. because they were
+// removed, are skipped.
+func loadWatchedHosts(c CommandLine, api libmachine.API) ([]*host.Host, error) {
+	var (
+		hosts        []*host.Host
+		hostsInError map[string]error
+		err          error
+	)
+
+	if len(c.Args()) == 0 {
+		hosts, hostsInError, err = persist.LoadAllHosts(api)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		hosts, hostsInError = persist.LoadHosts(api, c.Args())
+	}
+
+	for name, err := range hostsInError {
+		log.Warnf("Error loading %s: %s", name, err)
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadWatchedHosts(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "dev",
+				Driver: &fakedriver.Driver{MockState: state.Running},
+			},
+			{
+				Name:   "ci",
+				Driver: &fakedriver.Driver{MockState: state.Stopped},
+			},
+		},
+	}
+
+	hosts, err := loadWatchedHosts(&commandstest.FakeCommandLine{}, api)
+	assert.NoError(t, err)
+	assert.Len(t, hosts, 2)
+
+	hosts, err = loadWatchedHosts(&commandstest.FakeCommandLine{CliArgs: []string{"ci", "removed"}}, api)
+	assert.NoError(t, err)
+	assert.Len(t, hosts, 1)
+	assert.Equal(t, "ci", hosts[0].Name)
+}
+
+func TestCmdWatchOnce(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"once": true,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "ci",
+				Driver: &fakedriver.Driver{MockState: state.Stopped},
+			},
+		},
+	}
+
+	assert.NoError(t, cmdWatch(commandLine, api))
+}
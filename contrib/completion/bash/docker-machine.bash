@@ -136,7 +136,7 @@ _docker_machine_ssh() {
 
 _docker_machine_scp() {
     if [[ "${cur}" == -* ]]; then
-        COMPREPLY=($(compgen -W "--help --recursive" -- "${cur}"))
+        COMPREPLY=($(compgen -W "--help --quiet -q --recursive -r --resume" -- "${cur}"))
     else
         _filedir
         # It would be really nice to ssh to the machine and ls to complete
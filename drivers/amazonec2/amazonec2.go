@@ -1,17 +1,19 @@
 package amazonec2
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -21,6 +23,7 @@ import (
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/retry"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 )
@@ -51,6 +54,9 @@ var (
 	errorMissingAccessKeyOption = errors.New("amazonec2 driver requires the --amazonec2-access-key option or proper credentials in ~/.aws/credentials")
 	errorMissingSecretKeyOption = errors.New("amazonec2 driver requires the --amazonec2-secret-key option or proper credentials in ~/.aws/credentials")
 	errorNoVPCIdFound           = errors.New("amazonec2 driver requires either the --amazonec2-subnet-id or --amazonec2-vpc-id option or an AWS Account with a default vpc-id")
+	errorSpotOptionsWithoutSpot = errors.New("amazonec2 driver requires the --amazonec2-request-spot-instance option with the --amazonec2-spot-* options")
+	errorSpotZonesWithSubnet    = errors.New("amazonec2 driver can't retry in the --amazonec2-spot-zones with the --amazonec2-subnet-id option, the subnet is in a single zone")
+	errorSpotZonesWithNetworks  = errors.New("amazonec2 driver can't retry in the --amazonec2-spot-zones with the --amazonec2-additional-subnet-ids option, the subnets are in a single zone")
 )
 
 type Driver struct {
@@ -85,16 +91,28 @@ type Driver struct {
 	IamInstanceProfile      string
 	VpcId                   string
 	SubnetId                string
+	AdditionalSubnetIds     []string
 	Zone                    string
 	keyPath                 string
 	RequestSpotInstance     bool
 	SpotPrice               string
+	SpotInstanceTypes       []string
+	SpotZones               []string
+	SpotAllocationStrategy  string
+	SpotPersistent          bool
+	SpotInstanceRequestId   string
 	PrivateIPOnly           bool
 	UsePrivateIP            bool
 	UseEbsOptimizedInstance bool
 	Monitoring              bool
 	SSHPrivateKeyPath       string
 	RetryCount              int
+	UserDataFile            string
+	Architecture            string
+
+	// ImageFilter selects the AMI, resolved to the newest matching one
+	// when the machine is created
+	ImageFilter string
 }
 
 type clientFactory interface {
@@ -145,6 +163,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "AWS VPC subnet id",
 			EnvVar: "AWS_SUBNET_ID",
 		},
+		mcnflag.StringSliceFlag{
+			Name:   "amazonec2-additional-subnet-ids",
+			Usage:  "AWS VPC subnet ids of other network interfaces of the instance, in its zone",
+			EnvVar: "AWS_ADDITIONAL_SUBNET_IDS",
+		},
 		mcnflag.StringSliceFlag{
 			Name:   "amazonec2-security-group",
 			Usage:  "AWS VPC security group",
@@ -200,6 +223,25 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage: "AWS spot instance bid price (in dollar)",
 			Value: defaultSpotPrice,
 		},
+		mcnflag.StringSliceFlag{
+			Name:  "amazonec2-spot-instance-types",
+			Usage: "AWS instance types to fall back to, in order, when there's no spot capacity for the instance type",
+			Value: []string{},
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "amazonec2-spot-zones",
+			Usage: "AWS zones to retry the spot request in when there's no capacity in the zone",
+			Value: []string{},
+		},
+		mcnflag.StringFlag{
+			Name:  "amazonec2-spot-allocation-strategy",
+			Usage: "Order the spot instance types and zones are tried in: prioritized (as given) or lowest-price",
+			Value: defaultSpotAllocationStrategy,
+		},
+		mcnflag.BoolFlag{
+			Name:  "amazonec2-spot-persistent",
+			Usage: "Request a persistent spot instance, which can be stopped and is launched again when interrupted",
+		},
 		mcnflag.BoolFlag{
 			Name:  "amazonec2-private-address-only",
 			Usage: "Only use a private IP address",
@@ -208,6 +250,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:  "amazonec2-use-private-address",
 			Usage: "Force the usage of private IP address",
 		},
+		mcnflag.StringFlag{
+			Name:   "amazonec2-ssh-proxy-jump",
+			Usage:  "Bastion host to connect to the instance through, as [user@]host[:port]",
+			EnvVar: "AWS_SSH_PROXY_JUMP",
+		},
 		mcnflag.BoolFlag{
 			Name:  "amazonec2-monitoring",
 			Usage: "Set this flag to enable CloudWatch monitoring",
@@ -226,20 +273,26 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage: "Set retry count for recoverable failures (use -1 to disable)",
 			Value: 5,
 		},
+		mcnflag.StringFlag{
+			Name:   "amazonec2-userdata",
+			Usage:  "path to file with cloud-init user data",
+			EnvVar: "AWS_USERDATA",
+		},
 	}
 }
 
 func NewDriver(hostName, storePath string) *Driver {
 	id := generateId()
 	driver := &Driver{
-		Id:                 id,
-		AMI:                defaultAmiId,
-		Region:             defaultRegion,
-		InstanceType:       defaultInstanceType,
-		RootSize:           defaultRootSize,
-		Zone:               defaultZone,
-		SecurityGroupNames: []string{defaultSecurityGroup},
-		SpotPrice:          defaultSpotPrice,
+		Id:                     id,
+		AMI:                    defaultAmiId,
+		Region:                 defaultRegion,
+		InstanceType:           defaultInstanceType,
+		RootSize:               defaultRootSize,
+		Zone:                   defaultZone,
+		SecurityGroupNames:     []string{defaultSecurityGroup},
+		SpotPrice:              defaultSpotPrice,
+		SpotAllocationStrategy: defaultSpotAllocationStrategy,
 		BaseDriver: &drivers.BaseDriver{
 			SSHUser:     defaultSSHUser,
 			MachineName: hostName,
@@ -275,7 +328,13 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	}
 
 	image := flags.String("amazonec2-ami")
-	if len(image) == 0 {
+	imageFilter := flags.String("image-filter")
+	if len(image) > 0 && len(imageFilter) > 0 {
+		return errors.New("--amazonec2-ami and --image-filter can't be used together")
+	}
+	if len(image) == 0 && len(imageFilter) == 0 && instanceTypeArchitecture(flags.String("amazonec2-instance-type")) == mcnutils.ArchAMD64 {
+		// The AMI of the Graviton instance types is looked up before
+		// the creation
 		image = regionDetails[region].AmiId
 	}
 
@@ -284,11 +343,17 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SessionToken = flags.String("amazonec2-session-token")
 	d.Region = region
 	d.AMI = image
+	d.ImageFilter = imageFilter
 	d.RequestSpotInstance = flags.Bool("amazonec2-request-spot-instance")
 	d.SpotPrice = flags.String("amazonec2-spot-price")
+	d.SpotInstanceTypes = flags.StringSlice("amazonec2-spot-instance-types")
+	d.SpotZones = flags.StringSlice("amazonec2-spot-zones")
+	d.SpotAllocationStrategy = flags.String("amazonec2-spot-allocation-strategy")
+	d.SpotPersistent = flags.Bool("amazonec2-spot-persistent")
 	d.InstanceType = flags.String("amazonec2-instance-type")
 	d.VpcId = flags.String("amazonec2-vpc-id")
 	d.SubnetId = flags.String("amazonec2-subnet-id")
+	d.AdditionalSubnetIds = flags.StringSlice("amazonec2-additional-subnet-ids")
 	d.SecurityGroupNames = flags.StringSlice("amazonec2-security-group")
 	d.Tags = flags.String("amazonec2-tags")
 	zone := flags.String("amazonec2-zone")
@@ -301,11 +366,17 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SSHPort = 22
 	d.PrivateIPOnly = flags.Bool("amazonec2-private-address-only")
 	d.UsePrivateIP = flags.Bool("amazonec2-use-private-address")
+	d.SSHProxyJump = flags.String("amazonec2-ssh-proxy-jump")
 	d.Monitoring = flags.Bool("amazonec2-monitoring")
 	d.UseEbsOptimizedInstance = flags.Bool("amazonec2-use-ebs-optimized-instance")
 	d.SSHPrivateKeyPath = flags.String("amazonec2-ssh-keypath")
 	d.SetSwarmConfigFromFlags(flags)
 	d.RetryCount = flags.Int("amazonec2-retries")
+	d.UserDataFile = flags.String("amazonec2-userdata")
+
+	if err := d.validateSpotOptions(); err != nil {
+		return err
+	}
 
 	if d.AccessKey == "" && d.SecretKey == "" {
 		credentials, err := d.awsCredentials.NewSharedCredentials("", "").Get()
@@ -364,8 +435,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 			return fmt.Errorf("error parsing swarm host: %s", err)
 		}
 
-		parts := strings.Split(u.Host, ":")
-		port, err := strconv.Atoi(parts[1])
+		port, err := strconv.Atoi(u.Port())
 		if err != nil {
 			return err
 		}
@@ -376,11 +446,41 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	return nil
 }
 
+func (d *Driver) validateSpotOptions() error {
+	if d.SpotAllocationStrategy == "" {
+		d.SpotAllocationStrategy = defaultSpotAllocationStrategy
+	}
+	if err := validateSpotAllocationStrategy(d.SpotAllocationStrategy); err != nil {
+		return err
+	}
+
+	if !d.RequestSpotInstance && (len(d.SpotInstanceTypes) > 0 || len(d.SpotZones) > 0 || d.SpotPersistent || d.SpotAllocationStrategy != defaultSpotAllocationStrategy) {
+		return errorSpotOptionsWithoutSpot
+	}
+
+	if d.SubnetId != "" && len(d.SpotZones) > 0 {
+		return errorSpotZonesWithSubnet
+	}
+	if len(d.AdditionalSubnetIds) > 0 && len(d.SpotZones) > 0 {
+		return errorSpotZonesWithNetworks
+	}
+
+	return nil
+}
+
 // DriverName returns the name of the driver
 func (d *Driver) DriverName() string {
 	return driverName
 }
 
+// Capabilities returns the features of the driver, including spot instances and user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.Spot = true
+	capabilities.UserData = true
+	return capabilities
+}
+
 func (d *Driver) checkPrereqs() error {
 	// check for existing keypair
 	key, err := d.getClient().DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
@@ -398,61 +498,88 @@ func (d *Driver) checkPrereqs() error {
 		return fmt.Errorf("There is already a keypair with the name %s.  Please either remove that keypair or use a different machine name.", d.MachineName)
 	}
 
-	regionZone := d.Region + d.Zone
 	if d.SubnetId == "" {
-		filters := []*ec2.Filter{
-			{
-				Name:   aws.String("availability-zone"),
-				Values: []*string{&regionZone},
-			},
-			{
-				Name:   aws.String("vpc-id"),
-				Values: []*string{&d.VpcId},
-			},
-		}
-
-		subnets, err := d.getClient().DescribeSubnets(&ec2.DescribeSubnetsInput{
-			Filters: filters,
-		})
+		subnetId, err := d.subnetInZone(d.Region + d.Zone)
 		if err != nil {
 			return err
 		}
+		d.SubnetId = subnetId
+	}
 
-		if len(subnets.Subnets) == 0 {
-			return fmt.Errorf("unable to find a subnet in the zone: %s", regionZone)
-		}
+	return nil
+}
 
-		d.SubnetId = *subnets.Subnets[0].SubnetId
+// subnetInZone returns the subnet of the VPC in a zone, the default one of
+// the zone when there are several.
+func (d *Driver) subnetInZone(regionZone string) (string, error) {
+	filters := []*ec2.Filter{
+		{
+			Name:   aws.String("availability-zone"),
+			Values: []*string{&regionZone},
+		},
+		{
+			Name:   aws.String("vpc-id"),
+			Values: []*string{&d.VpcId},
+		},
+	}
+
+	subnets, err := d.getClient().DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: filters,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(subnets.Subnets) == 0 {
+		return "", fmt.Errorf("unable to find a subnet in the zone: %s", regionZone)
+	}
 
-		// try to find default
-		if len(subnets.Subnets) > 1 {
-			for _, subnet := range subnets.Subnets {
-				if *subnet.DefaultForAz {
-					d.SubnetId = *subnet.SubnetId
-					break
-				}
+	subnetId := *subnets.Subnets[0].SubnetId
+
+	// try to find default
+	if len(subnets.Subnets) > 1 {
+		for _, subnet := range subnets.Subnets {
+			if *subnet.DefaultForAz {
+				subnetId = *subnet.SubnetId
+				break
 			}
 		}
 	}
 
-	return nil
+	return subnetId, nil
 }
 
 func (d *Driver) PreCreateCheck() error {
-	return d.checkPrereqs()
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
+	if err := d.checkPrereqs(); err != nil {
+		return err
+	}
+
+	if d.ImageFilter != "" {
+		if err := d.resolveImageFilter(); err != nil {
+			return err
+		}
+	}
+
+	return d.checkArchitecture()
 }
 
-func (d *Driver) instanceIpAvailable() bool {
+func (d *Driver) instanceIpAvailable() (bool, error) {
 	ip, err := d.GetIP()
 	if err != nil {
-		log.Debug(err)
+		return false, err
 	}
 	if ip != "" {
 		d.IPAddress = ip
 		log.Debugf("Got the IP Address, it's %q", d.IPAddress)
-		return true
+		return true, nil
 	}
-	return false
+	return false, nil
 }
 
 func makePointerSlice(stackSlice []string) []*string {
@@ -481,11 +608,29 @@ func (d *Driver) securityGroupIds() (ids []string) {
 }
 
 func (d *Driver) Create() error {
+	return d.CreateContext(context.Background(), func(message string) {
+		log.Info(message)
+	})
+}
+
+// CreateContext creates the instance like Create.  If ctx is canceled before
+// the instance is running, the key pair, the spot request and the instance
+// already created are removed.
+func (d *Driver) CreateContext(ctx context.Context, progress func(string)) error {
 	if err := d.checkPrereqs(); err != nil {
 		return err
 	}
 
-	log.Infof("Launching instance...")
+	var userdata *string
+	if d.UserDataFile != "" {
+		buf, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		userdata = aws.String(base64.StdEncoding.EncodeToString(buf))
+	}
+
+	progress("Launching instance...")
 
 	if err := d.createKeyPair(); err != nil {
 		return fmt.Errorf("unable to create key pair: %s", err)
@@ -495,6 +640,10 @@ func (d *Driver) Create() error {
 		return err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return d.cancelCreate(err)
+	}
+
 	bdm := &ec2.BlockDeviceMapping{
 		DeviceName: aws.String(d.DeviceName),
 		Ebs: &ec2.EbsBlockDevice{
@@ -516,69 +665,21 @@ func (d *Driver) Create() error {
 	var instance *ec2.Instance
 
 	if d.RequestSpotInstance {
-		spotInstanceRequest, err := d.getClient().RequestSpotInstances(&ec2.RequestSpotInstancesInput{
-			LaunchSpecification: &ec2.RequestSpotLaunchSpecification{
-				ImageId: &d.AMI,
-				Placement: &ec2.SpotPlacement{
-					AvailabilityZone: &regionZone,
-				},
-				KeyName:           &d.KeyName,
-				InstanceType:      &d.InstanceType,
-				NetworkInterfaces: netSpecs,
-				Monitoring:        &ec2.RunInstancesMonitoringEnabled{Enabled: aws.Bool(d.Monitoring)},
-				IamInstanceProfile: &ec2.IamInstanceProfileSpecification{
-					Name: &d.IamInstanceProfile,
-				},
-				EbsOptimized:        &d.UseEbsOptimizedInstance,
-				BlockDeviceMappings: []*ec2.BlockDeviceMapping{bdm},
+		var err error
+		instance, err = d.requestSpotInstance(ec2.RequestSpotLaunchSpecification{
+			ImageId:           &d.AMI,
+			KeyName:           &d.KeyName,
+			NetworkInterfaces: netSpecs,
+			Monitoring:        &ec2.RunInstancesMonitoringEnabled{Enabled: aws.Bool(d.Monitoring)},
+			IamInstanceProfile: &ec2.IamInstanceProfileSpecification{
+				Name: &d.IamInstanceProfile,
 			},
-			InstanceCount: aws.Int64(1),
-			SpotPrice:     &d.SpotPrice,
-		})
-		if err != nil {
-			return fmt.Errorf("Error request spot instance: %s", err)
-		}
-
-		log.Info("Waiting for spot instance...")
-		err = d.getClient().WaitUntilSpotInstanceRequestFulfilled(&ec2.DescribeSpotInstanceRequestsInput{
-			SpotInstanceRequestIds: []*string{spotInstanceRequest.SpotInstanceRequests[0].SpotInstanceRequestId},
+			EbsOptimized:        &d.UseEbsOptimizedInstance,
+			BlockDeviceMappings: []*ec2.BlockDeviceMapping{bdm},
+			UserData:            userdata,
 		})
 		if err != nil {
-			return fmt.Errorf("Error fulfilling spot request: %v", err)
-		}
-		log.Info("Created spot instance request %v", *spotInstanceRequest.SpotInstanceRequests[0].SpotInstanceRequestId)
-		// resolve instance id
-		for i := 0; i < 3; i++ {
-			// Even though the waiter succeeded, eventual consistency means we could
-			// get a describe output that does not include this information. Try a
-			// few times just in case
-			var resolvedSpotInstance *ec2.DescribeSpotInstanceRequestsOutput
-			resolvedSpotInstance, err = d.getClient().DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
-				SpotInstanceRequestIds: []*string{spotInstanceRequest.SpotInstanceRequests[0].SpotInstanceRequestId},
-			})
-			if err != nil {
-				// Unexpected; no need to retry
-				return fmt.Errorf("Error describing previously made spot instance request: %v", err)
-			}
-			maybeInstanceId := resolvedSpotInstance.SpotInstanceRequests[0].InstanceId
-			if maybeInstanceId != nil {
-				var instances *ec2.DescribeInstancesOutput
-				instances, err = d.getClient().DescribeInstances(&ec2.DescribeInstancesInput{
-					InstanceIds: []*string{maybeInstanceId},
-				})
-				if err != nil {
-					// Retry if we get an id from spot instance but EC2 doesn't recognize it yet; see above, eventual consistency possible
-					continue
-				}
-				instance = instances.Reservations[0].Instances[0]
-				err = nil
-				break
-			}
-			time.Sleep(5 * time.Second)
-		}
-
-		if err != nil {
-			return fmt.Errorf("Error resolving spot instance to real instance: %v", err)
+			return err
 		}
 	} else {
 		inst, err := d.getClient().RunInstances(&ec2.RunInstancesInput{
@@ -597,6 +698,7 @@ func (d *Driver) Create() error {
 			},
 			EbsOptimized:        &d.UseEbsOptimizedInstance,
 			BlockDeviceMappings: []*ec2.BlockDeviceMapping{bdm},
+			UserData:            userdata,
 		})
 
 		if err != nil {
@@ -607,8 +709,11 @@ func (d *Driver) Create() error {
 
 	d.InstanceId = *instance.InstanceId
 
-	log.Debug("waiting for ip address to become available")
-	if err := mcnutils.WaitFor(d.instanceIpAvailable); err != nil {
+	progress("Waiting for the instance to be running...")
+	if err := retry.For(retry.Instance).UntilContext(ctx, d.instanceIpAvailable); err != nil {
+		if ctx.Err() != nil {
+			return d.cancelCreate(err)
+		}
 		return err
 	}
 
@@ -616,7 +721,16 @@ func (d *Driver) Create() error {
 		d.PrivateIPAddress = *instance.PrivateIpAddress
 	}
 
-	d.waitForInstance()
+	if err := retry.For(retry.Instance).UntilContext(ctx, d.instanceIsRunning); err != nil {
+		if ctx.Err() != nil {
+			return d.cancelCreate(err)
+		}
+		return err
+	}
+
+	if err := d.attachNetworkInterfaces(); err != nil {
+		return err
+	}
 
 	log.Debugf("created instance ID %s, IP address %s, Private IP address %s",
 		d.InstanceId,
@@ -732,6 +846,37 @@ func (d *Driver) Stop() error {
 	return err
 }
 
+// Resize changes the instance type of the stopped instance.
+func (d *Driver) Resize(size string) error {
+	arch, _ := d.GetArchitecture()
+	if sizeArch := instanceTypeArchitecture(size); sizeArch != arch {
+		return fmt.Errorf("the instance runs %s but the instance type %s runs %s", arch, size, sizeArch)
+	}
+
+	_, err := d.getClient().ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId:   &d.InstanceId,
+		InstanceType: &ec2.AttributeValue{Value: &size},
+	})
+	if err != nil {
+		return err
+	}
+
+	d.InstanceType = size
+	return nil
+}
+
+// Rename changes the Name tag of the instance, which is found by its ID.
+func (d *Driver) Rename(name string) error {
+	_, err := d.getClient().CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{&d.InstanceId},
+		Tags: []*ec2.Tag{{
+			Key:   aws.String("Name"),
+			Value: &name,
+		}},
+	})
+	return err
+}
+
 func (d *Driver) Restart() error {
 	_, err := d.getClient().RebootInstances(&ec2.RebootInstancesInput{
 		InstanceIds: []*string{&d.InstanceId},
@@ -747,11 +892,34 @@ func (d *Driver) Kill() error {
 	return err
 }
 
+// cancelCreate removes what the canceled creation already created and returns
+// the error of the cancellation.
+func (d *Driver) cancelCreate(err error) error {
+	log.Info("Creation canceled, removing the key pair and the instance...")
+
+	if d.InstanceId == "" && d.SpotInstanceRequestId == "" {
+		if removeErr := d.deleteKeyPair(); removeErr != nil {
+			log.Warnf("Error removing the key pair of the canceled instance: %s", removeErr)
+		}
+		return err
+	}
+
+	if removeErr := d.Remove(); removeErr != nil {
+		log.Warnf("Error removing the canceled instance: %s", removeErr)
+	}
+
+	return err
+}
+
 func (d *Driver) Remove() error {
 	multierr := mcnutils.MultiError{
 		Errs: []error{},
 	}
 
+	if err := d.cancelSpotRequest(); err != nil {
+		multierr.Errs = append(multierr.Errs, err)
+	}
+
 	if err := d.terminate(); err != nil {
 		multierr.Errs = append(multierr.Errs, err)
 	}
@@ -768,6 +936,8 @@ func (d *Driver) Remove() error {
 }
 
 func (d *Driver) getInstance() (*ec2.Instance, error) {
+	d.refreshSpotInstanceId()
+
 	instances, err := d.getClient().DescribeInstances(&ec2.DescribeInstancesInput{
 		InstanceIds: []*string{&d.InstanceId},
 	})
@@ -777,23 +947,16 @@ func (d *Driver) getInstance() (*ec2.Instance, error) {
 	return instances.Reservations[0].Instances[0], nil
 }
 
-func (d *Driver) instanceIsRunning() bool {
+func (d *Driver) instanceIsRunning() (bool, error) {
 	st, err := d.GetState()
 	if err != nil {
-		log.Debug(err)
+		return false, err
 	}
-	if st == state.Running {
-		return true
-	}
-	return false
+	return st == state.Running, nil
 }
 
 func (d *Driver) waitForInstance() error {
-	if err := mcnutils.WaitFor(d.instanceIsRunning); err != nil {
-		return err
-	}
-
-	return nil
+	return retry.For(retry.Instance).Until(d.instanceIsRunning)
 }
 
 func (d *Driver) createKeyPair() error {
@@ -855,26 +1018,27 @@ func (d *Driver) isSwarmMaster() bool {
 	return d.SwarmMaster
 }
 
-func (d *Driver) securityGroupAvailableFunc(id string) func() bool {
-	return func() bool {
-
+func (d *Driver) securityGroupAvailableFunc(id string) func() (bool, error) {
+	return func() (bool, error) {
 		securityGroup, err := d.getClient().DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
 			GroupIds: []*string{&id},
 		})
-		if err == nil && len(securityGroup.SecurityGroups) > 0 {
-			return true
-		} else if err == nil {
+		if err != nil {
+			return false, err
+		}
+		if len(securityGroup.SecurityGroups) == 0 {
 			log.Debugf("No security group with id %v found", id)
-			return false
+			return false, nil
 		}
-		log.Debug(err)
-		return false
+		return true, nil
 	}
 }
 
+// configureTags tags the instance, its volumes and its network interfaces with
+// the name of the machine and the tags given by the user.
 func (d *Driver) configureTags(tagGroups string) error {
 
-	tags := []*ec2.Tag{}
+	tags := d.managedTags()
 	tags = append(tags, &ec2.Tag{
 		Key:   aws.String("Name"),
 		Value: &d.MachineName,
@@ -893,8 +1057,23 @@ func (d *Driver) configureTags(tagGroups string) error {
 		}
 	}
 
-	_, err := d.getClient().CreateTags(&ec2.CreateTagsInput{
-		Resources: []*string{&d.InstanceId},
+	resources := []*string{&d.InstanceId}
+
+	instance, err := d.getInstance()
+	if err != nil {
+		return err
+	}
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs != nil && mapping.Ebs.VolumeId != nil {
+			resources = append(resources, mapping.Ebs.VolumeId)
+		}
+	}
+	for _, eni := range instance.NetworkInterfaces {
+		resources = append(resources, eni.NetworkInterfaceId)
+	}
+
+	_, err = d.getClient().CreateTags(&ec2.CreateTagsInput{
+		Resources: resources,
 		Tags:      tags,
 	})
 
@@ -959,7 +1138,13 @@ func (d *Driver) configureSecurityGroups(groupNames []string) error {
 			}
 			// wait until created (dat eventual consistency)
 			log.Debugf("waiting for group (%s) to become available", *group.GroupId)
-			if err := mcnutils.WaitFor(d.securityGroupAvailableFunc(*group.GroupId)); err != nil {
+			if err := retry.For(retry.Instance).Until(d.securityGroupAvailableFunc(*group.GroupId)); err != nil {
+				return err
+			}
+			if _, err := d.getClient().CreateTags(&ec2.CreateTagsInput{
+				Resources: []*string{group.GroupId},
+				Tags:      d.managedTags(),
+			}); err != nil {
 				return err
 			}
 		}
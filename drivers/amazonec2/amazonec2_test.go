@@ -393,6 +393,13 @@ func TestConfigureSecurityGroupsMixed(t *testing.T) {
 		&ec2.DescribeSecurityGroupsInput{GroupIds: []*string{aws.String("newGroupId")}}).Return(
 		&postCreateLookupResult, nil)
 
+	// The new security group is tagged with the machine.
+	recorder.On("CreateTags", &ec2.CreateTagsInput{
+		Resources: []*string{aws.String("newGroupId")},
+		Tags:      []*ec2.Tag{{Key: aws.String("docker-machine"), Value: aws.String("machineFoo")}},
+	}).Return(
+		&ec2.CreateTagsOutput{}, nil)
+
 	// Permissions are added to the new security group.
 	recorder.On("AuthorizeSecurityGroupIngress", &ec2.AuthorizeSecurityGroupIngressInput{
 		GroupId:       aws.String("newGroupId"),
@@ -421,3 +428,156 @@ func TestConfigureSecurityGroupsErrLookupExist(t *testing.T) {
 	assert.Exactly(t, lookupExistErr, err)
 	recorder.AssertExpectations(t)
 }
+
+func TestResize(t *testing.T) {
+	client := &fakeEC2WithModifyInstanceAttribute{}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceId = "i-1234"
+
+	err := driver.Resize("t3.large")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-1234", *client.input.InstanceId)
+	assert.Equal(t, "t3.large", *client.input.InstanceType.Value)
+	assert.Equal(t, "t3.large", driver.InstanceType)
+}
+
+func TestResizeToOtherArchitecture(t *testing.T) {
+	driver := NewCustomTestDriver(&fakeEC2WithModifyInstanceAttribute{})
+	driver.InstanceType = "t3.large"
+
+	err := driver.Resize("t4g.large")
+
+	assert.EqualError(t, err, "the instance runs amd64 but the instance type t4g.large runs arm64")
+	assert.Equal(t, "t3.large", driver.InstanceType)
+}
+
+func TestInstanceTypeArchitecture(t *testing.T) {
+	for _, instanceType := range []string{"a1.large", "t4g.micro", "c6gn.xlarge", "x2gd.medium", "is4gen.large", "g5g.xlarge"} {
+		assert.Equal(t, "arm64", instanceTypeArchitecture(instanceType), instanceType)
+	}
+	for _, instanceType := range []string{"t2.micro", "m5a.large", "g4dn.xlarge", "c7i.large"} {
+		assert.Equal(t, "amd64", instanceTypeArchitecture(instanceType), instanceType)
+	}
+}
+
+func TestCheckArchitectureSelectsGravitonAMI(t *testing.T) {
+	client := &fakeEC2WithImages{
+		images: []*ec2.Image{
+			{ImageId: aws.String("ami-old"), Architecture: aws.String("arm64"), CreationDate: aws.String("2023-01-10T00:00:00.000Z")},
+			{ImageId: aws.String("ami-new"), Architecture: aws.String("arm64"), CreationDate: aws.String("2024-03-01T00:00:00.000Z")},
+		},
+	}
+	driver := NewCustomTestDriver(client)
+	driver.AMI = ""
+	driver.InstanceType = "t4g.small"
+
+	err := driver.checkArchitecture()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ami-new", driver.AMI)
+	assert.Equal(t, "arm64", driver.Architecture)
+	assert.Equal(t, canonicalOwnerID, *client.inputs[0].Owners[0])
+
+	arch, _ := driver.GetArchitecture()
+	assert.Equal(t, "arm64", arch)
+}
+
+func TestCheckArchitectureMismatch(t *testing.T) {
+	driver := NewCustomTestDriver(&fakeEC2WithImages{
+		images: []*ec2.Image{
+			{ImageId: aws.String("ami-x86"), Architecture: aws.String("x86_64")},
+		},
+	})
+	driver.AMI = "ami-x86"
+	driver.InstanceType = "m6g.large"
+
+	err := driver.checkArchitecture()
+
+	assert.EqualError(t, err, "the AMI ami-x86 is built for amd64 but the instance type m6g.large runs arm64, use an AMI built for arm64 with --amazonec2-ami")
+}
+
+func TestResolveImageFilter(t *testing.T) {
+	client := &fakeEC2WithImages{
+		images: []*ec2.Image{
+			{ImageId: aws.String("ami-old"), Name: aws.String("ubuntu/images/ubuntu-jammy-22.04-amd64-server-20230110"), CreationDate: aws.String("2023-01-10T00:00:00.000Z")},
+			{ImageId: aws.String("ami-new"), Name: aws.String("ubuntu/images/ubuntu-jammy-22.04-amd64-server-20240301"), CreationDate: aws.String("2024-03-01T00:00:00.000Z")},
+			{ImageId: aws.String("ami-other"), Name: aws.String("debian-12-amd64-20240601"), CreationDate: aws.String("2024-06-01T00:00:00.000Z")},
+		},
+	}
+	driver := NewCustomTestDriver(client)
+	driver.AMI = ""
+	driver.InstanceType = "t3.small"
+	driver.ImageFilter = "name=ubuntu/images/ubuntu-jammy-22.04-*,owner=099720109477"
+
+	err := driver.resolveImageFilter()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ami-new", driver.AMI)
+	assert.Equal(t, "099720109477", *client.inputs[0].Owners[0])
+	assert.Equal(t, "x86_64", *client.inputs[0].Filters[1].Values[0])
+}
+
+func TestResolveImageFilterWithoutMatch(t *testing.T) {
+	client := &fakeEC2WithImages{}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceType = "t4g.small"
+	driver.ImageFilter = "name=my-image-*"
+
+	err := driver.resolveImageFilter()
+
+	assert.EqualError(t, err, `Error: no image matches the filter "name=my-image-*"`)
+	assert.Equal(t, "self", *client.inputs[0].Owners[0])
+	assert.Equal(t, "arm64", *client.inputs[0].Filters[1].Values[0])
+}
+
+func TestSetConfigFromFlagsImageFilterWithAMI(t *testing.T) {
+	driver := NewTestDriver()
+
+	options := &commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			"amazonec2-access-key": "foo",
+			"amazonec2-secret-key": "bar",
+			"amazonec2-region":     "us-east-1",
+			"amazonec2-ami":        "ami-1234",
+			"image-filter":         "name=ubuntu-*",
+		},
+	}
+
+	err := driver.SetConfigFromFlags(options)
+
+	assert.EqualError(t, err, "--amazonec2-ami and --image-filter can't be used together")
+}
+
+func TestAttachNetworkInterfaces(t *testing.T) {
+	client := &fakeEC2WithNetworkInterfaces{}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceId = "i-1234"
+	driver.SecurityGroupIds = []string{"sg-1"}
+	driver.AdditionalSubnetIds = []string{"subnet-a", "subnet-b"}
+
+	err := driver.attachNetworkInterfaces()
+
+	assert.NoError(t, err)
+	assert.Len(t, client.created, 2)
+	assert.Equal(t, []*string{aws.String("sg-1")}, client.created[0].Groups)
+	assert.Len(t, client.attached, 2)
+	assert.Equal(t, "eni-subnet-b", *client.attached[1].NetworkInterfaceId)
+	assert.Equal(t, int64(2), *client.attached[1].DeviceIndex)
+	assert.Equal(t, "attach-eni-subnet-a", *client.modified[0].Attachment.AttachmentId)
+	assert.True(t, *client.modified[0].Attachment.DeleteOnTermination)
+	assert.Empty(t, client.deleted)
+}
+
+func TestAttachNetworkInterfacesDeletesUnattached(t *testing.T) {
+	client := &fakeEC2WithNetworkInterfaces{attachErr: errors.New("limit exceeded")}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceId = "i-1234"
+	driver.AdditionalSubnetIds = []string{"subnet-a"}
+
+	err := driver.attachNetworkInterfaces()
+
+	assert.EqualError(t, err, "Error attaching the network interface eni-subnet-a: limit exceeded")
+	assert.Len(t, client.deleted, 1)
+	assert.Equal(t, "eni-subnet-a", *client.deleted[0].NetworkInterfaceId)
+}
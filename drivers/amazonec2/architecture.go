@@ -0,0 +1,111 @@
+package amazonec2
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const (
+	// canonicalOwnerID is the account publishing the Ubuntu AMIs
+	canonicalOwnerID  = "099720109477"
+	defaultArm64Image = "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-arm64-server-*"
+)
+
+// gravitonFamily matches the instance families of the Graviton processors:
+// a1 and the families with a g after their generation, e.g. t4g, c6gn or
+// x2gd, but not g4dn.
+var gravitonFamily = regexp.MustCompile(`^(a1|[a-z]+[0-9]+[a-z]*g[a-z]*)$`)
+
+// instanceTypeArchitecture returns the architecture the instances of a type
+// run.
+func instanceTypeArchitecture(instanceType string) string {
+	family := strings.SplitN(instanceType, ".", 2)[0]
+	if gravitonFamily.MatchString(family) {
+		return mcnutils.ArchARM64
+	}
+	return mcnutils.ArchAMD64
+}
+
+// GetArchitecture returns the architecture of the AMI of the instance, or of
+// its instance type for the machines created before it was looked up.
+func (d *Driver) GetArchitecture() (string, error) {
+	if d.Architecture != "" {
+		return d.Architecture, nil
+	}
+	return instanceTypeArchitecture(d.InstanceType), nil
+}
+
+// checkArchitecture looks up the architecture of the AMI, and checks that
+// the instance types can run it.  Without an AMI, it selects the latest
+// Ubuntu AMI for Graviton.
+func (d *Driver) checkArchitecture() error {
+	if d.AMI == "" {
+		ami, err := d.latestArm64AMI()
+		if err != nil {
+			return err
+		}
+		log.Infof("Using the AMI %s for the Graviton instance type %s", ami, d.InstanceType)
+		d.AMI = ami
+	}
+
+	images, err := d.getClient().DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{&d.AMI},
+	})
+	if err != nil {
+		return err
+	}
+	if len(images.Images) == 0 {
+		return fmt.Errorf("the AMI %s doesn't exist in the region %s", d.AMI, d.Region)
+	}
+
+	arch := mcnutils.NormalizeArchitecture(aws.StringValue(images.Images[0].Architecture))
+	for _, instanceType := range append([]string{d.InstanceType}, d.SpotInstanceTypes...) {
+		if typeArch := instanceTypeArchitecture(instanceType); typeArch != arch {
+			return fmt.Errorf("the AMI %s is built for %s but the instance type %s runs %s, use an AMI built for %s with --amazonec2-ami", d.AMI, arch, instanceType, typeArch, typeArch)
+		}
+	}
+
+	d.Architecture = arch
+	return nil
+}
+
+// latestArm64AMI returns the most recent Ubuntu AMI for Graviton in the
+// region.
+func (d *Driver) latestArm64AMI() (string, error) {
+	images, err := d.getClient().DescribeImages(&ec2.DescribeImagesInput{
+		Owners: []*string{aws.String(canonicalOwnerID)},
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("name"),
+				Values: []*string{aws.String(defaultArm64Image)},
+			},
+			{
+				Name:   aws.String("architecture"),
+				Values: []*string{aws.String("arm64")},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: []*string{aws.String("available")},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(images.Images) == 0 {
+		return "", fmt.Errorf("unable to find an Ubuntu AMI for Graviton in the region %s, specify one with --amazonec2-ami", d.Region)
+	}
+
+	sort.Slice(images.Images, func(i, j int) bool {
+		return aws.StringValue(images.Images[i].CreationDate) > aws.StringValue(images.Images[j].CreationDate)
+	})
+
+	return aws.StringValue(images.Images[0].ImageId), nil
+}
@@ -0,0 +1,76 @@
+package amazonec2
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/machine/libmachine/imagefilter"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// imageArchitectures maps the architectures of the instance types to the
+// ones of the AMIs.
+var imageArchitectures = map[string]string{
+	mcnutils.ArchAMD64: "x86_64",
+	mcnutils.ArchARM64: "arm64",
+}
+
+// resolveImageFilter selects the newest available AMI matching the image
+// filter which the instance type can run.  Without an owner, the AMIs of the
+// account are searched.
+func (d *Driver) resolveImageFilter() error {
+	filter, err := imagefilter.Parse(d.ImageFilter)
+	if err != nil {
+		return err
+	}
+
+	owner := filter.Owner
+	if owner == "" {
+		owner = "self"
+	}
+
+	output, err := d.getClient().DescribeImages(&ec2.DescribeImagesInput{
+		Owners: []*string{aws.String(owner)},
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("name"),
+				Values: []*string{aws.String(filter.Name)},
+			},
+			{
+				Name:   aws.String("architecture"),
+				Values: []*string{aws.String(imageArchitectures[instanceTypeArchitecture(d.InstanceType)])},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: []*string{aws.String("available")},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	image, err := filter.Select(ec2Images(output.Images))
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Using the AMI %s (%s) matching the filter %q", image.ID, image.Name, filter)
+	d.AMI = image.ID
+	return nil
+}
+
+func ec2Images(images []*ec2.Image) []imagefilter.Image {
+	result := []imagefilter.Image{}
+	for _, image := range images {
+		created, _ := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+		result = append(result, imagefilter.Image{
+			ID:      aws.StringValue(image.ImageId),
+			Name:    aws.StringValue(image.Name),
+			Created: created,
+		})
+	}
+	return result
+}
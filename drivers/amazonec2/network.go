@@ -0,0 +1,70 @@
+package amazonec2
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// attachNetworkInterfaces attaches a network interface in each of the
+// additional subnets to the instance, deleted with it.  They're attached once
+// the instance runs since EC2 doesn't give a public address to instances
+// launched with several interfaces.
+func (d *Driver) attachNetworkInterfaces() error {
+	for i, subnetID := range d.AdditionalSubnetIds {
+		log.Infof("Attaching a network interface in the subnet %s...", subnetID)
+
+		eni, err := d.getClient().CreateNetworkInterface(&ec2.CreateNetworkInterfaceInput{
+			SubnetId:    aws.String(subnetID),
+			Groups:      makePointerSlice(d.securityGroupIds()),
+			Description: aws.String(d.MachineName),
+		})
+		if err != nil {
+			return fmt.Errorf("Error creating a network interface in the subnet %s: %s", subnetID, err)
+		}
+		eniID := eni.NetworkInterface.NetworkInterfaceId
+
+		attachment, err := d.getClient().AttachNetworkInterface(&ec2.AttachNetworkInterfaceInput{
+			InstanceId:         aws.String(d.InstanceId),
+			NetworkInterfaceId: eniID,
+			DeviceIndex:        aws.Int64(int64(i + 1)),
+		})
+		if err != nil {
+			d.getClient().DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
+				NetworkInterfaceId: eniID,
+			})
+			return fmt.Errorf("Error attaching the network interface %s: %s", *eniID, err)
+		}
+
+		_, err = d.getClient().ModifyNetworkInterfaceAttribute(&ec2.ModifyNetworkInterfaceAttributeInput{
+			NetworkInterfaceId: eniID,
+			Attachment: &ec2.NetworkInterfaceAttachmentChanges{
+				AttachmentId:        attachment.AttachmentId,
+				DeleteOnTermination: aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("Error deleting the network interface %s with the instance: %s", *eniID, err)
+		}
+	}
+
+	return nil
+}
+
+// GetPrivateIP returns the private address of the first network interface
+// of the instance.
+func (d *Driver) GetPrivateIP() (string, error) {
+	inst, err := d.getInstance()
+	if err != nil {
+		return "", err
+	}
+
+	if inst.PrivateIpAddress == nil {
+		return "", fmt.Errorf("No private IP for instance %v", *inst.InstanceId)
+	}
+
+	d.PrivateIPAddress = *inst.PrivateIpAddress
+	return d.PrivateIPAddress, nil
+}
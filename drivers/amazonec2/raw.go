@@ -0,0 +1,139 @@
+package amazonec2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+const (
+	operationAttachVolume      = "attach-volume"
+	operationDetachVolume      = "detach-volume"
+	operationSetSecurityGroups = "set-security-groups"
+)
+
+var rawOperations = []drivers.RawOperation{
+	{
+		Name:  operationAttachVolume,
+		Usage: `Attach an EBS volume to the instance: {"VolumeId": "vol-0123", "Device": "/dev/sdf"}`,
+	},
+	{
+		Name:  operationDetachVolume,
+		Usage: `Detach an EBS volume from the instance: {"VolumeId": "vol-0123", "Force": false}`,
+	},
+	{
+		Name:  operationSetSecurityGroups,
+		Usage: `Replace the security groups of the instance: {"GroupIds": ["sg-0123"]}`,
+	},
+}
+
+type volumeArgs struct {
+	VolumeId string
+	Device   string
+	Force    bool
+}
+
+type securityGroupsArgs struct {
+	GroupIds []string
+}
+
+// RawOperations returns the operations of EC2 the driver runs on the
+// instance with driver-exec.
+func (d *Driver) RawOperations() []drivers.RawOperation {
+	return rawOperations
+}
+
+// RunRawOperation runs an operation of EC2 on the instance, and returns the
+// attachment of the volume for the volume operations.  The security groups
+// set are kept as the ones of the machine.
+func (d *Driver) RunRawOperation(name string, args []byte) ([]byte, error) {
+	switch name {
+	case operationAttachVolume:
+		return d.attachVolume(args)
+	case operationDetachVolume:
+		return d.detachVolume(args)
+	case operationSetSecurityGroups:
+		return nil, d.setSecurityGroups(args)
+	}
+
+	return nil, drivers.UnknownRawOperation(name, rawOperations)
+}
+
+func (d *Driver) attachVolume(args []byte) ([]byte, error) {
+	var volume volumeArgs
+	if err := unmarshalRawArgs(operationAttachVolume, args, &volume); err != nil {
+		return nil, err
+	}
+	if volume.VolumeId == "" || volume.Device == "" {
+		return nil, errors.New("the VolumeId of the volume and the Device it's attached as are required, e.g. /dev/sdf")
+	}
+
+	attachment, err := d.getClient().AttachVolume(&ec2.AttachVolumeInput{
+		InstanceId: &d.InstanceId,
+		VolumeId:   &volume.VolumeId,
+		Device:     &volume.Device,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(attachment)
+}
+
+func (d *Driver) detachVolume(args []byte) ([]byte, error) {
+	var volume volumeArgs
+	if err := unmarshalRawArgs(operationDetachVolume, args, &volume); err != nil {
+		return nil, err
+	}
+	if volume.VolumeId == "" {
+		return nil, errors.New("the VolumeId of the volume is required")
+	}
+
+	attachment, err := d.getClient().DetachVolume(&ec2.DetachVolumeInput{
+		InstanceId: &d.InstanceId,
+		VolumeId:   &volume.VolumeId,
+		Force:      aws.Bool(volume.Force),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(attachment)
+}
+
+func (d *Driver) setSecurityGroups(args []byte) error {
+	var groups securityGroupsArgs
+	if err := unmarshalRawArgs(operationSetSecurityGroups, args, &groups); err != nil {
+		return err
+	}
+	if len(groups.GroupIds) == 0 {
+		return errors.New("the GroupIds of the security groups are required")
+	}
+
+	_, err := d.getClient().ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId: &d.InstanceId,
+		Groups:     makePointerSlice(groups.GroupIds),
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SecurityGroupId = ""
+	d.SecurityGroupIds = groups.GroupIds
+	return nil
+}
+
+func unmarshalRawArgs(name string, args []byte, v interface{}) error {
+	if len(args) == 0 {
+		args = []byte("{}")
+	}
+
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments of %s: %s", name, err)
+	}
+	return nil
+}
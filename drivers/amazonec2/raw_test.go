@@ -0,0 +1,68 @@
+package amazonec2
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunRawOperationAttachVolume(t *testing.T) {
+	client := &fakeEC2WithVolumes{}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceId = "i-1234"
+
+	result, err := driver.RunRawOperation("attach-volume", []byte(`{"VolumeId": "vol-1", "Device": "/dev/sdf"}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-1234", *client.attached[0].InstanceId)
+	assert.Equal(t, "vol-1", *client.attached[0].VolumeId)
+	assert.Equal(t, "/dev/sdf", *client.attached[0].Device)
+	assert.JSONEq(t, `{"AttachTime": null, "DeleteOnTermination": null, "Device": "/dev/sdf", "InstanceId": "i-1234", "State": "attaching", "VolumeId": "vol-1"}`, string(result))
+
+	_, err = driver.RunRawOperation("attach-volume", []byte(`{"VolumeId": "vol-1"}`))
+
+	assert.EqualError(t, err, "the VolumeId of the volume and the Device it's attached as are required, e.g. /dev/sdf")
+	assert.Len(t, client.attached, 1)
+}
+
+func TestRunRawOperationDetachVolume(t *testing.T) {
+	client := &fakeEC2WithVolumes{}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceId = "i-1234"
+
+	_, err := driver.RunRawOperation("detach-volume", []byte(`{"VolumeId": "vol-1", "Force": true}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "vol-1", *client.detached[0].VolumeId)
+	assert.True(t, *client.detached[0].Force)
+
+	_, err = driver.RunRawOperation("detach-volume", []byte(`["vol-1"]`))
+
+	assert.EqualError(t, err, "invalid arguments of detach-volume: json: cannot unmarshal array into Go value of type amazonec2.volumeArgs")
+}
+
+func TestRunRawOperationSetSecurityGroups(t *testing.T) {
+	client := &fakeEC2WithModifyInstanceAttribute{}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceId = "i-1234"
+	driver.SecurityGroupId = "sg-old"
+	driver.SecurityGroupIds = []string{"sg-old"}
+
+	result, err := driver.RunRawOperation("set-security-groups", []byte(`{"GroupIds": ["sg-1", "sg-2"]}`))
+
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "i-1234", *client.input.InstanceId)
+	assert.Equal(t, []*string{&driver.SecurityGroupIds[0], &driver.SecurityGroupIds[1]}, client.input.Groups)
+	assert.Equal(t, []string{"sg-1", "sg-2"}, driver.securityGroupIds())
+}
+
+func TestRunRawOperationUnknown(t *testing.T) {
+	driver := NewTestDriver()
+
+	_, err := driver.RunRawOperation("resize-volume", nil)
+
+	assert.EqualError(t, err, `unknown operation "resize-volume", expected one of attach-volume, detach-volume, set-security-groups`)
+	assert.True(t, drivers.GetCapabilities(driver).RawOperations)
+}
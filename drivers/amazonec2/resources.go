@@ -0,0 +1,312 @@
+package amazonec2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+const (
+	// managedTag is the tag of the resources created by the driver, whose
+	// value is the name of their machine.
+	managedTag = "docker-machine"
+
+	// storeTag is the tag of the resources created by the driver, whose
+	// value is the identity of the store of their machine.
+	storeTag = "docker-machine-store"
+)
+
+const (
+	resourceInstance         = "instance"
+	resourceNetworkInterface = "network-interface"
+	resourceVolume           = "volume"
+	resourceSecurityGroup    = "security-group"
+	resourceKeyPair          = "key-pair"
+)
+
+var errNoStoreID = errors.New("Error: the resources can't be listed without the identity of the store")
+
+// managedFilter selects the resources created by the driver for the machines
+// of its store.
+func (d *Driver) managedFilter() *ec2.Filter {
+	return &ec2.Filter{
+		Name:   aws.String("tag:" + storeTag),
+		Values: []*string{aws.String(d.StoreID)},
+	}
+}
+
+// ListManagedResources returns the instances, network interfaces, volumes
+// and security groups tagged by the driver in its region for the machines of
+// its store, in the order they can be deleted in.  The volumes and the network
+// interfaces still attached to an instance are deleted with it, and the
+// security groups used by another instance are kept.  Key pairs can't be
+// tagged: the ones of the instances are returned last.
+func (d *Driver) ListManagedResources() ([]drivers.ManagedResource, error) {
+	if d.StoreID == "" {
+		return nil, errNoStoreID
+	}
+
+	resources := []drivers.ManagedResource{}
+
+	instances, keyNames, err := d.listManagedInstances()
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, instances...)
+
+	interfaces, err := d.getClient().DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{d.managedFilter(), {
+			Name:   aws.String("status"),
+			Values: []*string{aws.String("available")},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the network interfaces: %s", err)
+	}
+	for _, eni := range interfaces.NetworkInterfaces {
+		resources = append(resources, drivers.ManagedResource{
+			Type:        resourceNetworkInterface,
+			ID:          aws.StringValue(eni.NetworkInterfaceId),
+			Machine:     tagValue(eni.TagSet, managedTag),
+			Description: aws.StringValue(eni.PrivateIpAddress),
+		})
+	}
+
+	volumes, err := d.listManagedVolumes()
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, volumes...)
+
+	groups, err := d.listManagedSecurityGroups()
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, groups...)
+
+	keyPairs, err := d.listManagedKeyPairs(keyNames)
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, keyPairs...)
+
+	return resources, nil
+}
+
+// listManagedInstances returns the instances created by the driver, and the
+// machines of their key pairs by name.
+func (d *Driver) listManagedInstances() ([]drivers.ManagedResource, map[string]string, error) {
+	resources := []drivers.ManagedResource{}
+	keyNames := map[string]string{}
+
+	input := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{d.managedFilter(), {
+			Name:   aws.String("instance-state-name"),
+			Values: makePointerSlice([]string{"pending", "running", "stopping", "stopped"}),
+		}},
+	}
+	for {
+		output, err := d.getClient().DescribeInstances(input)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error listing the instances: %s", err)
+		}
+
+		for _, reservation := range output.Reservations {
+			for _, instance := range reservation.Instances {
+				machine := tagValue(instance.Tags, managedTag)
+				resources = append(resources, drivers.ManagedResource{
+					Type:        resourceInstance,
+					ID:          aws.StringValue(instance.InstanceId),
+					Machine:     machine,
+					Description: aws.StringValue(instance.State.Name),
+				})
+				if keyName := aws.StringValue(instance.KeyName); keyName != "" {
+					keyNames[keyName] = machine
+				}
+			}
+		}
+
+		if aws.StringValue(output.NextToken) == "" {
+			return resources, keyNames, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// listManagedKeyPairs returns the key pairs of the instances created by the
+// driver, which it imported for their machine.  Their names are those of the
+// machines, which other stores may have as well, so the key pairs are only
+// selected through the instances tagged with the identity of the store.
+func (d *Driver) listManagedKeyPairs(keyNames map[string]string) ([]drivers.ManagedResource, error) {
+	if len(keyNames) == 0 {
+		return nil, nil
+	}
+
+	// All the key pairs are listed, since listing one which doesn't exist
+	// anymore fails
+	keyPairs, err := d.getClient().DescribeKeyPairs(&ec2.DescribeKeyPairsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the key pairs: %s", err)
+	}
+
+	resources := []drivers.ManagedResource{}
+	for _, keyPair := range keyPairs.KeyPairs {
+		name := aws.StringValue(keyPair.KeyName)
+		machine, ok := keyNames[name]
+		if !ok {
+			continue
+		}
+
+		resources = append(resources, drivers.ManagedResource{
+			Type:        resourceKeyPair,
+			ID:          name,
+			Machine:     machine,
+			Description: aws.StringValue(keyPair.KeyFingerprint),
+		})
+	}
+
+	return resources, nil
+}
+
+func (d *Driver) listManagedVolumes() ([]drivers.ManagedResource, error) {
+	resources := []drivers.ManagedResource{}
+
+	input := &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{d.managedFilter(), {
+			Name:   aws.String("status"),
+			Values: []*string{aws.String("available")},
+		}},
+	}
+	for {
+		output, err := d.getClient().DescribeVolumes(input)
+		if err != nil {
+			return nil, fmt.Errorf("Error listing the volumes: %s", err)
+		}
+
+		for _, volume := range output.Volumes {
+			resources = append(resources, drivers.ManagedResource{
+				Type:        resourceVolume,
+				ID:          aws.StringValue(volume.VolumeId),
+				Machine:     tagValue(volume.Tags, managedTag),
+				Description: fmt.Sprintf("%d GiB", aws.Int64Value(volume.Size)),
+			})
+		}
+
+		if aws.StringValue(output.NextToken) == "" {
+			return resources, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// listManagedSecurityGroups returns the security groups created by the
+// driver which no network interface uses anymore.
+func (d *Driver) listManagedSecurityGroups() ([]drivers.ManagedResource, error) {
+	groups, err := d.getClient().DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{d.managedFilter()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the security groups: %s", err)
+	}
+	if len(groups.SecurityGroups) == 0 {
+		return nil, nil
+	}
+
+	groupIds := []*string{}
+	for _, group := range groups.SecurityGroups {
+		groupIds = append(groupIds, group.GroupId)
+	}
+
+	interfaces, err := d.getClient().DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("group-id"),
+			Values: groupIds,
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the network interfaces of the security groups: %s", err)
+	}
+
+	used := map[string]bool{}
+	for _, eni := range interfaces.NetworkInterfaces {
+		for _, group := range eni.Groups {
+			used[aws.StringValue(group.GroupId)] = true
+		}
+	}
+
+	resources := []drivers.ManagedResource{}
+	for _, group := range groups.SecurityGroups {
+		if used[aws.StringValue(group.GroupId)] {
+			continue
+		}
+
+		resources = append(resources, drivers.ManagedResource{
+			Type:        resourceSecurityGroup,
+			ID:          aws.StringValue(group.GroupId),
+			Machine:     tagValue(group.Tags, managedTag),
+			Description: aws.StringValue(group.GroupName),
+		})
+	}
+
+	return resources, nil
+}
+
+// DeleteManagedResource deletes a resource returned by ListManagedResources.
+func (d *Driver) DeleteManagedResource(resource drivers.ManagedResource) error {
+	var err error
+
+	switch resource.Type {
+	case resourceInstance:
+		_, err = d.getClient().TerminateInstances(&ec2.TerminateInstancesInput{
+			InstanceIds: []*string{aws.String(resource.ID)},
+		})
+	case resourceNetworkInterface:
+		_, err = d.getClient().DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
+			NetworkInterfaceId: aws.String(resource.ID),
+		})
+	case resourceVolume:
+		_, err = d.getClient().DeleteVolume(&ec2.DeleteVolumeInput{
+			VolumeId: aws.String(resource.ID),
+		})
+	case resourceSecurityGroup:
+		_, err = d.getClient().DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+			GroupId: aws.String(resource.ID),
+		})
+	case resourceKeyPair:
+		_, err = d.getClient().DeleteKeyPair(&ec2.DeleteKeyPairInput{
+			KeyName: aws.String(resource.ID),
+		})
+	default:
+		return fmt.Errorf("Unknown resource type %q", resource.Type)
+	}
+
+	return err
+}
+
+// managedTags returns the tags marking the resources created for the machine,
+// with its name and the identity of its store.
+func (d *Driver) managedTags() []*ec2.Tag {
+	tags := []*ec2.Tag{{
+		Key:   aws.String(managedTag),
+		Value: aws.String(d.MachineName),
+	}}
+	if d.StoreID != "" {
+		tags = append(tags, &ec2.Tag{
+			Key:   aws.String(storeTag),
+			Value: aws.String(d.StoreID),
+		})
+	}
+	return tags
+}
+
+func tagValue(tags []*ec2.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package amazonec2
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListManagedResources(t *testing.T) {
+	client := &fakeEC2WithManagedResources{}
+	driver := NewCustomTestDriver(client)
+	driver.StoreID = "store-1"
+
+	resources, err := driver.ListManagedResources()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.ManagedResource{
+		{Type: "instance", ID: "i-1", Machine: "gone", Description: "running"},
+		{Type: "volume", ID: "vol-1", Machine: "older", Description: "16 GiB"},
+		{Type: "security-group", ID: "sg-unused", Machine: "gone", Description: "gone-group"},
+		{Type: "key-pair", ID: "gone", Machine: "gone", Description: "aa:bb"},
+	}, resources)
+
+	// Only the resources of the store are listed
+	for _, filter := range client.filters {
+		assert.Equal(t, "tag:docker-machine-store=store-1", filter)
+	}
+	assert.Len(t, client.filters, 4)
+}
+
+func TestListManagedResourcesWithoutStoreID(t *testing.T) {
+	driver := NewCustomTestDriver(&fakeEC2WithManagedResources{})
+
+	_, err := driver.ListManagedResources()
+
+	assert.Equal(t, errNoStoreID, err)
+}
+
+func TestManagedTags(t *testing.T) {
+	driver := NewCustomTestDriver(&fakeEC2WithManagedResources{})
+	driver.StoreID = "store-1"
+
+	assert.Equal(t, []*ec2.Tag{
+		{Key: aws.String("docker-machine"), Value: aws.String("machineFoo")},
+		{Key: aws.String("docker-machine-store"), Value: aws.String("store-1")},
+	}, driver.managedTags())
+}
+
+func TestDeleteManagedResource(t *testing.T) {
+	client := &fakeEC2WithManagedResources{}
+	driver := NewCustomTestDriver(client)
+
+	assert.NoError(t, driver.DeleteManagedResource(drivers.ManagedResource{Type: "instance", ID: "i-1"}))
+	assert.NoError(t, driver.DeleteManagedResource(drivers.ManagedResource{Type: "key-pair", ID: "gone"}))
+	assert.EqualError(t, driver.DeleteManagedResource(drivers.ManagedResource{Type: "bucket", ID: "b"}), `Unknown resource type "bucket"`)
+
+	assert.Equal(t, []string{"i-1"}, client.terminated)
+	assert.Equal(t, []string{"gone"}, client.deleted)
+}
@@ -0,0 +1,347 @@
+package amazonec2
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	spotAllocationPrioritized     = "prioritized"
+	spotAllocationLowestPrice     = "lowest-price"
+	defaultSpotAllocationStrategy = spotAllocationPrioritized
+)
+
+var (
+	spotPollInterval = 15 * time.Second
+	spotTimeout      = 10 * time.Minute
+
+	// The statuses of a spot request, and the errors of the requests, telling
+	// that no instance of the type can be had in the zone at the bid price,
+	// in which case the next candidate is tried.
+	spotCapacityStatusCodes = []string{"capacity-not-available", "capacity-oversubscribed", "price-too-low"}
+	spotCapacityErrorCodes  = []string{"InsufficientInstanceCapacity", "Unsupported"}
+
+	spotFailureStatusCodes = []string{"schedule-expired", "canceled-before-fulfillment", "bad-parameters", "system-error"}
+
+	spotProductDescriptions = []string{"Linux/UNIX", "Linux/UNIX (Amazon VPC)"}
+
+	errSpotCapacity = errors.New("no spot capacity")
+)
+
+// spotCandidate is an instance type in a zone a spot instance is requested
+// for.
+type spotCandidate struct {
+	InstanceType string
+	Zone         string
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupe(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		if value != "" && !contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func validateSpotAllocationStrategy(strategy string) error {
+	switch strategy {
+	case spotAllocationPrioritized, spotAllocationLowestPrice:
+		return nil
+	}
+
+	return fmt.Errorf("unknown spot allocation strategy %q, expected one of: [%s, %s]", strategy, spotAllocationPrioritized, spotAllocationLowestPrice)
+}
+
+// spotCandidates returns the instance types and zones to request the spot
+// instance for, in the order they are tried: each instance type in all the
+// zones before the next one, or from the cheapest to the most expensive with
+// the lowest-price strategy.
+func (d *Driver) spotCandidates() ([]spotCandidate, error) {
+	candidates := []spotCandidate{}
+	for _, instanceType := range dedupe(append([]string{d.InstanceType}, d.SpotInstanceTypes...)) {
+		for _, zone := range dedupe(append([]string{d.Zone}, d.SpotZones...)) {
+			candidates = append(candidates, spotCandidate{InstanceType: instanceType, Zone: zone})
+		}
+	}
+
+	if d.SpotAllocationStrategy != spotAllocationLowestPrice || len(candidates) == 1 {
+		return candidates, nil
+	}
+
+	prices, err := d.spotPrices(candidates)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting the spot prices: %s", err)
+	}
+
+	// The candidates without a price are kept last, in their order
+	sort.SliceStable(candidates, func(i, j int) bool {
+		pi, iok := prices[candidates[i]]
+		pj, jok := prices[candidates[j]]
+		if iok && jok {
+			return pi < pj
+		}
+		return iok && !jok
+	})
+
+	return candidates, nil
+}
+
+// spotPrices returns the current spot price of the candidates.
+func (d *Driver) spotPrices(candidates []spotCandidate) (map[spotCandidate]float64, error) {
+	instanceTypes := []string{}
+	for _, candidate := range candidates {
+		instanceTypes = append(instanceTypes, candidate.InstanceType)
+	}
+
+	history, err := d.getClient().DescribeSpotPriceHistory(&ec2.DescribeSpotPriceHistoryInput{
+		InstanceTypes:       makePointerSlice(dedupe(instanceTypes)),
+		ProductDescriptions: makePointerSlice(spotProductDescriptions),
+		StartTime:           aws.Time(time.Now()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prices := map[spotCandidate]float64{}
+	for _, spotPrice := range history.SpotPriceHistory {
+		price, err := strconv.ParseFloat(aws.StringValue(spotPrice.SpotPrice), 64)
+		if err != nil {
+			continue
+		}
+
+		zone := aws.StringValue(spotPrice.AvailabilityZone)
+		if len(zone) <= len(d.Region) {
+			continue
+		}
+
+		candidate := spotCandidate{
+			InstanceType: aws.StringValue(spotPrice.InstanceType),
+			Zone:         zone[len(d.Region):],
+		}
+		if current, ok := prices[candidate]; !ok || price < current {
+			prices[candidate] = price
+		}
+	}
+
+	return prices, nil
+}
+
+func isSpotCapacityError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && contains(spotCapacityErrorCodes, awsErr.Code())
+}
+
+// requestSpotInstance requests a spot instance for each candidate in turn,
+// until one is fulfilled.  The specification is the one of the instance, its
+// type, zone and subnet are the ones of the candidate.
+func (d *Driver) requestSpotInstance(spec ec2.RequestSpotLaunchSpecification) (*ec2.Instance, error) {
+	candidates, err := d.spotCandidates()
+	if err != nil {
+		return nil, err
+	}
+
+	requestType := ec2.SpotInstanceTypeOneTime
+	if d.SpotPersistent {
+		requestType = ec2.SpotInstanceTypePersistent
+	}
+
+	for i, candidate := range candidates {
+		last := i == len(candidates)-1
+
+		subnetId := d.SubnetId
+		if candidate.Zone != d.Zone {
+			if subnetId, err = d.subnetInZone(d.Region + candidate.Zone); err != nil {
+				log.Infof("Skipping %s: %s", d.Region+candidate.Zone, err)
+				continue
+			}
+		}
+
+		networkInterface := *spec.NetworkInterfaces[0]
+		networkInterface.SubnetId = aws.String(subnetId)
+
+		candidateSpec := spec
+		candidateSpec.InstanceType = aws.String(candidate.InstanceType)
+		candidateSpec.Placement = &ec2.SpotPlacement{
+			AvailabilityZone: aws.String(d.Region + candidate.Zone),
+		}
+		candidateSpec.NetworkInterfaces = []*ec2.InstanceNetworkInterfaceSpecification{&networkInterface}
+
+		log.Infof("Requesting a spot instance %s in %s...", candidate.InstanceType, d.Region+candidate.Zone)
+		spotInstanceRequest, err := d.getClient().RequestSpotInstances(&ec2.RequestSpotInstancesInput{
+			LaunchSpecification: &candidateSpec,
+			InstanceCount:       aws.Int64(1),
+			SpotPrice:           &d.SpotPrice,
+			Type:                aws.String(requestType),
+		})
+		if err != nil {
+			if !last && isSpotCapacityError(err) {
+				log.Infof("No capacity for %s in %s: %s", candidate.InstanceType, d.Region+candidate.Zone, err)
+				continue
+			}
+			return nil, fmt.Errorf("Error request spot instance: %s", err)
+		}
+
+		requestId := *spotInstanceRequest.SpotInstanceRequests[0].SpotInstanceRequestId
+		log.Infof("Waiting for spot instance request %s...", requestId)
+
+		instanceId, err := d.waitForSpotRequest(requestId, !last)
+		if err == errSpotCapacity {
+			log.Infof("No capacity for %s in %s, trying the next instance type or zone", candidate.InstanceType, d.Region+candidate.Zone)
+			if err := d.abandonSpotRequest(requestId); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error fulfilling spot request: %v", err)
+		}
+
+		d.InstanceType = candidate.InstanceType
+		d.Zone = candidate.Zone
+		d.SubnetId = subnetId
+		d.SpotInstanceRequestId = requestId
+
+		return d.describeSpotInstance(instanceId)
+	}
+
+	return nil, fmt.Errorf("Error request spot instance: no capacity for any of the instance types in the zones")
+}
+
+// waitForSpotRequest waits for a spot request to be fulfilled and returns the
+// id of its instance.  It returns errSpotCapacity when there's no capacity
+// for it and it can be given up for another candidate.
+func (d *Driver) waitForSpotRequest(requestId string, canGiveUp bool) (string, error) {
+	deadline := time.Now().Add(spotTimeout)
+	status := ""
+
+	for time.Now().Before(deadline) {
+		requests, err := d.getClient().DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
+			SpotInstanceRequestIds: []*string{&requestId},
+		})
+		if err != nil {
+			// The request may not be known yet right after being made
+			if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "InvalidSpotInstanceRequestID.NotFound" {
+				return "", err
+			}
+		} else if len(requests.SpotInstanceRequests) != 0 {
+			request := requests.SpotInstanceRequests[0]
+			if request.Status != nil {
+				status = aws.StringValue(request.Status.Code)
+			}
+
+			switch {
+			case status == "fulfilled" && request.InstanceId != nil:
+				return *request.InstanceId, nil
+			case canGiveUp && contains(spotCapacityStatusCodes, status):
+				return "", errSpotCapacity
+			case contains(spotFailureStatusCodes, status):
+				return "", fmt.Errorf("spot request %s failed: %s", requestId, aws.StringValue(request.Status.Message))
+			}
+		}
+
+		time.Sleep(spotPollInterval)
+	}
+
+	return "", fmt.Errorf("timed out waiting for spot request %s, last status %q", requestId, status)
+}
+
+// abandonSpotRequest cancels a spot request, and terminates its instance if
+// it was fulfilled in the meantime.
+func (d *Driver) abandonSpotRequest(requestId string) error {
+	if _, err := d.getClient().CancelSpotInstanceRequests(&ec2.CancelSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{&requestId},
+	}); err != nil {
+		return fmt.Errorf("Error canceling spot request %s: %s", requestId, err)
+	}
+
+	requests, err := d.getClient().DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{&requestId},
+	})
+	if err != nil || len(requests.SpotInstanceRequests) == 0 || requests.SpotInstanceRequests[0].InstanceId == nil {
+		return nil
+	}
+
+	if _, err := d.getClient().TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: []*string{requests.SpotInstanceRequests[0].InstanceId},
+	}); err != nil {
+		return fmt.Errorf("Error terminating the instance of canceled spot request %s: %s", requestId, err)
+	}
+
+	return nil
+}
+
+// describeSpotInstance returns the instance of a fulfilled spot request.
+func (d *Driver) describeSpotInstance(instanceId string) (*ec2.Instance, error) {
+	var err error
+	for i := 0; i < 3; i++ {
+		// Even though the request is fulfilled, eventual consistency means
+		// EC2 may not recognize the instance yet. Try a few times just in case
+		var instances *ec2.DescribeInstancesOutput
+		instances, err = d.getClient().DescribeInstances(&ec2.DescribeInstancesInput{
+			InstanceIds: []*string{&instanceId},
+		})
+		if err == nil && len(instances.Reservations) != 0 && len(instances.Reservations[0].Instances) != 0 {
+			return instances.Reservations[0].Instances[0], nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return nil, fmt.Errorf("Error resolving spot instance to real instance: %v", err)
+}
+
+// refreshSpotInstanceId follows the persistent spot request of the machine
+// to the instance it launched again after an interruption.
+func (d *Driver) refreshSpotInstanceId() {
+	if !d.SpotPersistent || d.SpotInstanceRequestId == "" {
+		return
+	}
+
+	requests, err := d.getClient().DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{&d.SpotInstanceRequestId},
+	})
+	if err != nil || len(requests.SpotInstanceRequests) == 0 {
+		return
+	}
+
+	instanceId := aws.StringValue(requests.SpotInstanceRequests[0].InstanceId)
+	if instanceId != "" && instanceId != d.InstanceId {
+		log.Debugf("spot request %s launched instance %s in place of %s", d.SpotInstanceRequestId, instanceId, d.InstanceId)
+		d.InstanceId = instanceId
+	}
+}
+
+// cancelSpotRequest cancels the persistent spot request of the instance, so
+// that it isn't launched again once terminated.
+func (d *Driver) cancelSpotRequest() error {
+	if !d.SpotPersistent || d.SpotInstanceRequestId == "" {
+		return nil
+	}
+
+	log.Debugf("canceling spot request: %s", d.SpotInstanceRequestId)
+	if _, err := d.getClient().CancelSpotInstanceRequests(&ec2.CancelSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{&d.SpotInstanceRequestId},
+	}); err != nil {
+		return fmt.Errorf("unable to cancel spot request: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package amazonec2
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpotCandidatesPrioritized(t *testing.T) {
+	driver := NewTestDriver()
+	driver.InstanceType = "m5.large"
+	driver.Zone = "a"
+	driver.SpotInstanceTypes = []string{"m5a.large", "m5.large"}
+	driver.SpotZones = []string{"b"}
+
+	candidates, err := driver.spotCandidates()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []spotCandidate{
+		{InstanceType: "m5.large", Zone: "a"},
+		{InstanceType: "m5.large", Zone: "b"},
+		{InstanceType: "m5a.large", Zone: "a"},
+		{InstanceType: "m5a.large", Zone: "b"},
+	}, candidates)
+}
+
+func TestSpotCandidatesLowestPrice(t *testing.T) {
+	client := &fakeEC2WithSpot{
+		prices: []*ec2.SpotPrice{
+			{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("us-east-1a"), SpotPrice: aws.String("0.040")},
+			{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("us-east-1b"), SpotPrice: aws.String("0.035")},
+			{InstanceType: aws.String("m5a.large"), AvailabilityZone: aws.String("us-east-1a"), SpotPrice: aws.String("0.030")},
+		},
+	}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceType = "m5.large"
+	driver.Zone = "a"
+	driver.SpotInstanceTypes = []string{"m5a.large"}
+	driver.SpotZones = []string{"b"}
+	driver.SpotAllocationStrategy = spotAllocationLowestPrice
+
+	candidates, err := driver.spotCandidates()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []spotCandidate{
+		{InstanceType: "m5a.large", Zone: "a"},
+		{InstanceType: "m5.large", Zone: "b"},
+		{InstanceType: "m5.large", Zone: "a"},
+		{InstanceType: "m5a.large", Zone: "b"},
+	}, candidates)
+}
+
+func TestRequestSpotInstanceRetriesInAnotherZone(t *testing.T) {
+	interval := spotPollInterval
+	defer func() { spotPollInterval = interval }()
+	spotPollInterval = 0
+
+	client := &fakeEC2WithSpot{
+		statuses:  map[string]string{"sir-1": "capacity-not-available", "sir-2": "fulfilled"},
+		subnetIds: map[string]string{"us-east-1b": "subnet-b"},
+	}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceType = "m5.large"
+	driver.Zone = "a"
+	driver.SubnetId = "subnet-a"
+	driver.SpotZones = []string{"b"}
+	driver.SpotPersistent = true
+
+	instance, err := driver.requestSpotInstance(ec2.RequestSpotLaunchSpecification{
+		NetworkInterfaces: []*ec2.InstanceNetworkInterfaceSpecification{{SubnetId: aws.String("subnet-a")}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-sir-2", *instance.InstanceId)
+	assert.Equal(t, []string{"sir-1"}, client.canceled)
+	assert.Len(t, client.requests, 2)
+	assert.Equal(t, "us-east-1b", *client.requests[1].LaunchSpecification.Placement.AvailabilityZone)
+	assert.Equal(t, "subnet-b", *client.requests[1].LaunchSpecification.NetworkInterfaces[0].SubnetId)
+	assert.Equal(t, ec2.SpotInstanceTypePersistent, *client.requests[1].Type)
+	assert.Equal(t, "b", driver.Zone)
+	assert.Equal(t, "subnet-b", driver.SubnetId)
+	assert.Equal(t, "sir-2", driver.SpotInstanceRequestId)
+}
+
+func TestSpotOptionsRequireSpotInstance(t *testing.T) {
+	driver := NewTestDriver()
+	options := &commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			"name":                      "test",
+			"amazonec2-region":          "us-east-1",
+			"amazonec2-spot-persistent": true,
+		},
+	}
+
+	err := driver.SetConfigFromFlags(options)
+
+	assert.Equal(t, errorSpotOptionsWithoutSpot, err)
+}
+
+func TestSpotZonesWithSubnet(t *testing.T) {
+	driver := NewTestDriver()
+	options := &commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			"name":                            "test",
+			"amazonec2-region":                "us-east-1",
+			"amazonec2-subnet-id":             "subnet-a",
+			"amazonec2-request-spot-instance": true,
+			"amazonec2-spot-zones":            []string{"b"},
+		},
+	}
+
+	err := driver.SetConfigFromFlags(options)
+
+	assert.Equal(t, errorSpotZonesWithSubnet, err)
+}
+
+func TestUnknownSpotAllocationStrategy(t *testing.T) {
+	driver := NewTestDriver()
+	options := &commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			"name":                               "test",
+			"amazonec2-region":                   "us-east-1",
+			"amazonec2-request-spot-instance":    true,
+			"amazonec2-spot-allocation-strategy": "cheapest",
+		},
+	}
+
+	err := driver.SetConfigFromFlags(options)
+
+	assert.EqualError(t, err, `unknown spot allocation strategy "cheapest", expected one of: [prioritized, lowest-price]`)
+}
+
+func TestRefreshSpotInstanceId(t *testing.T) {
+	client := &fakeEC2WithSpot{
+		statuses: map[string]string{"sir-1": "fulfilled"},
+	}
+	driver := NewCustomTestDriver(client)
+	driver.InstanceId = "i-interrupted"
+	driver.SpotInstanceRequestId = "sir-1"
+	driver.SpotPersistent = true
+
+	instance, err := driver.getInstance()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-sir-1", *instance.InstanceId)
+	assert.Equal(t, "i-sir-1", driver.InstanceId)
+}
+
+func TestSpotZonesWithAdditionalSubnets(t *testing.T) {
+	driver := NewTestDriver()
+	options := &commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			"name":                            "test",
+			"amazonec2-region":                "us-east-1",
+			"amazonec2-additional-subnet-ids": []string{"subnet-b"},
+			"amazonec2-request-spot-instance": true,
+			"amazonec2-spot-zones":            []string{"b"},
+		},
+	}
+
+	err := driver.SetConfigFromFlags(options)
+
+	assert.Equal(t, errorSpotZonesWithNetworks, err)
+}
@@ -2,7 +2,9 @@ package amazonec2
 
 import (
 	"errors"
+	"fmt"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/ec2"
 
@@ -116,6 +118,16 @@ func (f *fakeEC2SecurityGroupTestRecorder) CreateSecurityGroup(input *ec2.Create
 	return value, err
 }
 
+func (f *fakeEC2SecurityGroupTestRecorder) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	result := f.Called(input)
+	err := result.Error(1)
+	value, ok := result.Get(0).(*ec2.CreateTagsOutput)
+	if !ok && err == nil {
+		return nil, errors.New("Type assertion to CreateTagsOutput failed")
+	}
+	return value, err
+}
+
 func (f *fakeEC2SecurityGroupTestRecorder) AuthorizeSecurityGroupIngress(input *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
 	result := f.Called(input)
 	err := result.Error(1)
@@ -126,6 +138,63 @@ func (f *fakeEC2SecurityGroupTestRecorder) AuthorizeSecurityGroupIngress(input *
 	return value, err
 }
 
+type fakeEC2WithModifyInstanceAttribute struct {
+	*fakeEC2
+	input *ec2.ModifyInstanceAttributeInput
+}
+
+func (f *fakeEC2WithModifyInstanceAttribute) ModifyInstanceAttribute(input *ec2.ModifyInstanceAttributeInput) (*ec2.ModifyInstanceAttributeOutput, error) {
+	f.input = input
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
+}
+
+type fakeEC2WithVolumes struct {
+	*fakeEC2
+	attached []*ec2.AttachVolumeInput
+	detached []*ec2.DetachVolumeInput
+}
+
+func (f *fakeEC2WithVolumes) AttachVolume(input *ec2.AttachVolumeInput) (*ec2.VolumeAttachment, error) {
+	f.attached = append(f.attached, input)
+	return &ec2.VolumeAttachment{
+		InstanceId: input.InstanceId,
+		VolumeId:   input.VolumeId,
+		Device:     input.Device,
+		State:      aws.String("attaching"),
+	}, nil
+}
+
+func (f *fakeEC2WithVolumes) DetachVolume(input *ec2.DetachVolumeInput) (*ec2.VolumeAttachment, error) {
+	f.detached = append(f.detached, input)
+	return &ec2.VolumeAttachment{
+		InstanceId: input.InstanceId,
+		VolumeId:   input.VolumeId,
+		State:      aws.String("detaching"),
+	}, nil
+}
+
+type fakeEC2WithImages struct {
+	*fakeEC2
+	images []*ec2.Image
+	inputs []*ec2.DescribeImagesInput
+}
+
+func (f *fakeEC2WithImages) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	f.inputs = append(f.inputs, input)
+
+	if len(input.ImageIds) == 0 {
+		return &ec2.DescribeImagesOutput{Images: f.images}, nil
+	}
+
+	images := []*ec2.Image{}
+	for _, image := range f.images {
+		if *image.ImageId == *input.ImageIds[0] {
+			images = append(images, image)
+		}
+	}
+	return &ec2.DescribeImagesOutput{Images: images}, nil
+}
+
 func NewTestDriver() *Driver {
 	driver := NewDriver("machineFoo", "path")
 	driver.clientFactory = func() Ec2Client { return &fakeEC2{} }
@@ -137,3 +206,179 @@ func NewCustomTestDriver(ec2Client Ec2Client) *Driver {
 	driver.clientFactory = func() Ec2Client { return ec2Client }
 	return driver
 }
+
+type fakeEC2WithSpot struct {
+	*fakeEC2
+	requests  []*ec2.RequestSpotInstancesInput
+	statuses  map[string]string
+	canceled  []string
+	prices    []*ec2.SpotPrice
+	subnetIds map[string]string
+}
+
+func (f *fakeEC2WithSpot) RequestSpotInstances(input *ec2.RequestSpotInstancesInput) (*ec2.RequestSpotInstancesOutput, error) {
+	f.requests = append(f.requests, input)
+	requestId := fmt.Sprintf("sir-%d", len(f.requests))
+
+	return &ec2.RequestSpotInstancesOutput{
+		SpotInstanceRequests: []*ec2.SpotInstanceRequest{{SpotInstanceRequestId: &requestId}},
+	}, nil
+}
+
+func (f *fakeEC2WithSpot) DescribeSpotInstanceRequests(input *ec2.DescribeSpotInstanceRequestsInput) (*ec2.DescribeSpotInstanceRequestsOutput, error) {
+	requestId := *input.SpotInstanceRequestIds[0]
+	request := &ec2.SpotInstanceRequest{
+		SpotInstanceRequestId: &requestId,
+		Status:                &ec2.SpotInstanceStatus{Code: aws.String(f.statuses[requestId])},
+	}
+	if f.statuses[requestId] == "fulfilled" {
+		request.InstanceId = aws.String("i-" + requestId)
+	}
+
+	return &ec2.DescribeSpotInstanceRequestsOutput{SpotInstanceRequests: []*ec2.SpotInstanceRequest{request}}, nil
+}
+
+func (f *fakeEC2WithSpot) CancelSpotInstanceRequests(input *ec2.CancelSpotInstanceRequestsInput) (*ec2.CancelSpotInstanceRequestsOutput, error) {
+	f.canceled = append(f.canceled, *input.SpotInstanceRequestIds[0])
+	return &ec2.CancelSpotInstanceRequestsOutput{}, nil
+}
+
+func (f *fakeEC2WithSpot) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{{InstanceId: input.InstanceIds[0]}}}},
+	}, nil
+}
+
+func (f *fakeEC2WithSpot) DescribeSpotPriceHistory(input *ec2.DescribeSpotPriceHistoryInput) (*ec2.DescribeSpotPriceHistoryOutput, error) {
+	return &ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: f.prices}, nil
+}
+
+func (f *fakeEC2WithSpot) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	zone := *input.Filters[0].Values[0]
+	return &ec2.DescribeSubnetsOutput{
+		Subnets: []*ec2.Subnet{{SubnetId: aws.String(f.subnetIds[zone]), DefaultForAz: aws.Bool(true)}},
+	}, nil
+}
+
+type fakeEC2WithNetworkInterfaces struct {
+	*fakeEC2
+	created   []*ec2.CreateNetworkInterfaceInput
+	attached  []*ec2.AttachNetworkInterfaceInput
+	modified  []*ec2.ModifyNetworkInterfaceAttributeInput
+	deleted   []*ec2.DeleteNetworkInterfaceInput
+	attachErr error
+}
+
+func (f *fakeEC2WithNetworkInterfaces) CreateNetworkInterface(input *ec2.CreateNetworkInterfaceInput) (*ec2.CreateNetworkInterfaceOutput, error) {
+	f.created = append(f.created, input)
+	return &ec2.CreateNetworkInterfaceOutput{
+		NetworkInterface: &ec2.NetworkInterface{NetworkInterfaceId: aws.String("eni-" + *input.SubnetId)},
+	}, nil
+}
+
+func (f *fakeEC2WithNetworkInterfaces) AttachNetworkInterface(input *ec2.AttachNetworkInterfaceInput) (*ec2.AttachNetworkInterfaceOutput, error) {
+	if f.attachErr != nil {
+		return nil, f.attachErr
+	}
+	f.attached = append(f.attached, input)
+	return &ec2.AttachNetworkInterfaceOutput{AttachmentId: aws.String("attach-" + *input.NetworkInterfaceId)}, nil
+}
+
+func (f *fakeEC2WithNetworkInterfaces) ModifyNetworkInterfaceAttribute(input *ec2.ModifyNetworkInterfaceAttributeInput) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	f.modified = append(f.modified, input)
+	return &ec2.ModifyNetworkInterfaceAttributeOutput{}, nil
+}
+
+func (f *fakeEC2WithNetworkInterfaces) DeleteNetworkInterface(input *ec2.DeleteNetworkInterfaceInput) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	f.deleted = append(f.deleted, input)
+	return &ec2.DeleteNetworkInterfaceOutput{}, nil
+}
+
+// fakeEC2WithManagedResources has the resources of the store "store-1", and
+// records the tag filters the resources are listed with.
+type fakeEC2WithManagedResources struct {
+	*fakeEC2
+	filters    []string
+	terminated []string
+	deleted    []string
+}
+
+func (f *fakeEC2WithManagedResources) recordFilter(filters []*ec2.Filter) {
+	f.filters = append(f.filters, *filters[0].Name+"="+*filters[0].Values[0])
+}
+
+func (f *fakeEC2WithManagedResources) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	f.recordFilter(input.Filters)
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId: aws.String("i-1"),
+				KeyName:    aws.String("gone"),
+				State:      &ec2.InstanceState{Name: aws.String("running")},
+				Tags: []*ec2.Tag{
+					{Key: aws.String("docker-machine"), Value: aws.String("gone")},
+					{Key: aws.String("docker-machine-store"), Value: aws.String("store-1")},
+				},
+			}},
+		}},
+	}, nil
+}
+
+func (f *fakeEC2WithManagedResources) DescribeNetworkInterfaces(input *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	if *input.Filters[0].Name == "group-id" {
+		return &ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{{
+				Groups: []*ec2.GroupIdentifier{{GroupId: aws.String("sg-used")}},
+			}},
+		}, nil
+	}
+
+	f.recordFilter(input.Filters)
+	return &ec2.DescribeNetworkInterfacesOutput{}, nil
+}
+
+func (f *fakeEC2WithManagedResources) DescribeVolumes(input *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	f.recordFilter(input.Filters)
+	return &ec2.DescribeVolumesOutput{
+		Volumes: []*ec2.Volume{{
+			VolumeId: aws.String("vol-1"),
+			Size:     aws.Int64(16),
+			Tags:     []*ec2.Tag{{Key: aws.String("docker-machine"), Value: aws.String("older")}},
+		}},
+	}, nil
+}
+
+func (f *fakeEC2WithManagedResources) DescribeSecurityGroups(input *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	f.recordFilter(input.Filters)
+	return &ec2.DescribeSecurityGroupsOutput{
+		SecurityGroups: []*ec2.SecurityGroup{{
+			GroupId:   aws.String("sg-used"),
+			GroupName: aws.String("docker-machine"),
+			Tags:      []*ec2.Tag{{Key: aws.String("docker-machine"), Value: aws.String("first")}},
+		}, {
+			GroupId:   aws.String("sg-unused"),
+			GroupName: aws.String("gone-group"),
+			Tags:      []*ec2.Tag{{Key: aws.String("docker-machine"), Value: aws.String("gone")}},
+		}},
+	}, nil
+}
+
+func (f *fakeEC2WithManagedResources) DescribeKeyPairs(input *ec2.DescribeKeyPairsInput) (*ec2.DescribeKeyPairsOutput, error) {
+	return &ec2.DescribeKeyPairsOutput{
+		KeyPairs: []*ec2.KeyPairInfo{
+			{KeyName: aws.String("gone"), KeyFingerprint: aws.String("aa:bb")},
+			{KeyName: aws.String("personal"), KeyFingerprint: aws.String("cc:dd")},
+			{KeyName: aws.String("older"), KeyFingerprint: aws.String("ee:ff")},
+		},
+	}, nil
+}
+
+func (f *fakeEC2WithManagedResources) TerminateInstances(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	f.terminated = append(f.terminated, *input.InstanceIds[0])
+	return &ec2.TerminateInstancesOutput{}, nil
+}
+
+func (f *fakeEC2WithManagedResources) DeleteKeyPair(input *ec2.DeleteKeyPairInput) (*ec2.DeleteKeyPairOutput, error) {
+	f.deleted = append(f.deleted, *input.KeyName)
+	return &ec2.DeleteKeyPairOutput{}, nil
+}
@@ -1,13 +1,18 @@
 package azure
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/url"
+	"os"
+	"strconv"
 
 	"github.com/docker/machine/drivers/azure/azureutil"
 	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/imagefilter"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/state"
@@ -28,6 +33,15 @@ const (
 	defaultAzureSubnetPrefix    = "192.168.0.0/16"
 	defaultStorageType          = storage.StandardLRS
 	defaultAzureAvailabilitySet = "docker-machine"
+	defaultAzureAuthMethod      = authMethodDevice
+	defaultAzureSpotMaxPrice    = -1
+)
+
+// Authentication methods
+const (
+	authMethodDevice            = "device"
+	authMethodManagedIdentity   = "managed-identity"
+	authMethodClientCredentials = "client-credentials"
 )
 
 const (
@@ -48,6 +62,15 @@ const (
 	flAzureUsePrivateIP    = "azure-use-private-ip"
 	flAzureStaticPublicIP  = "azure-static-public-ip"
 	flAzureNoPublicIP      = "azure-no-public-ip"
+	flAzureCustomData      = "azure-custom-data"
+	flAzureAuthMethod      = "azure-auth-method"
+	flAzureClientID        = "azure-client-id"
+	flAzureClientSecret    = "azure-client-secret"
+	flAzureEphemeralOSDisk = "azure-ephemeral-os-disk"
+	flAzureSpot            = "azure-spot"
+	flAzureSpotMaxPrice    = "azure-spot-max-price"
+	flAzureAccelNetworking = "azure-accelerated-networking"
+	flAzureZone            = "azure-zone"
 )
 
 const (
@@ -62,11 +85,15 @@ type Driver struct {
 	Environment    string
 	SubscriptionID string
 	ResourceGroup  string
+	AuthMethod     string
+	ClientID       string
+	ClientSecret   string
 
 	DockerPort      int
 	Location        string
 	Size            string
 	Image           string
+	ImageFilter     string `json:",omitempty"`
 	VirtualNetwork  string
 	SubnetName      string
 	SubnetPrefix    string
@@ -77,6 +104,13 @@ type Driver struct {
 	UsePrivateIP   bool
 	NoPublicIP     bool
 	StaticPublicIP bool
+	CustomDataFile string
+
+	EphemeralOSDisk       bool
+	Spot                  bool
+	SpotMaxPrice          float64
+	AcceleratedNetworking bool
+	Zone                  string
 
 	// Ephemeral fields
 	ctx        *azureutil.DeploymentContext
@@ -193,6 +227,49 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:  flAzurePorts,
 			Usage: "Make the specified port number accessible from the Internet",
 		},
+		mcnflag.StringFlag{
+			Name:   flAzureCustomData,
+			Usage:  "Path to file with custom-data, e.g. cloud-init user data",
+			EnvVar: "AZURE_CUSTOM_DATA_FILE",
+		},
+		mcnflag.StringFlag{
+			Name:   flAzureAuthMethod,
+			Usage:  "Azure authentication method: device, managed-identity or client-credentials",
+			EnvVar: "AZURE_AUTH_METHOD",
+			Value:  defaultAzureAuthMethod,
+		},
+		mcnflag.StringFlag{
+			Name:   flAzureClientID,
+			Usage:  "Client ID of the application (client-credentials) or of the user-assigned managed identity (managed-identity)",
+			EnvVar: "AZURE_CLIENT_ID",
+		},
+		mcnflag.StringFlag{
+			Name:   flAzureClientSecret,
+			Usage:  "Client secret of the application (client-credentials)",
+			EnvVar: "AZURE_CLIENT_SECRET",
+		},
+		mcnflag.BoolFlag{
+			Name:  flAzureEphemeralOSDisk,
+			Usage: "Place the OS disk on the local storage of the host, the size must have a large enough cache",
+		},
+		mcnflag.BoolFlag{
+			Name:  flAzureSpot,
+			Usage: "Create a spot virtual machine, which Azure can evict",
+		},
+		mcnflag.StringFlag{
+			Name:  flAzureSpotMaxPrice,
+			Usage: "Maximum price per hour of the spot virtual machine, in US dollars (-1 to pay up to the pay-as-you-go price)",
+			Value: fmt.Sprintf("%d", defaultAzureSpotMaxPrice),
+		},
+		mcnflag.BoolFlag{
+			Name:  flAzureAccelNetworking,
+			Usage: "Enable accelerated networking on the network interface, the size must support it",
+		},
+		mcnflag.StringFlag{
+			Name:   flAzureZone,
+			Usage:  "Availability zone to create the virtual machine in (1, 2 or 3), instead of the availability set",
+			EnvVar: "AZURE_ZONE",
+		},
 	}
 }
 
@@ -233,6 +310,54 @@ func (d *Driver) SetConfigFromFlags(fl drivers.DriverOptions) error {
 	d.NoPublicIP = fl.Bool(flAzureNoPublicIP)
 	d.StaticPublicIP = fl.Bool(flAzureStaticPublicIP)
 	d.DockerPort = fl.Int(flAzureDockerPort)
+	d.CustomDataFile = fl.String(flAzureCustomData)
+	d.AuthMethod = fl.String(flAzureAuthMethod)
+	d.ClientID = fl.String(flAzureClientID)
+	d.ClientSecret = fl.String(flAzureClientSecret)
+	d.EphemeralOSDisk = fl.Bool(flAzureEphemeralOSDisk)
+	d.Spot = fl.Bool(flAzureSpot)
+	d.AcceleratedNetworking = fl.Bool(flAzureAccelNetworking)
+	d.Zone = fl.String(flAzureZone)
+	d.ImageFilter = fl.String("image-filter")
+
+	if d.ImageFilter != "" {
+		filter, err := imagefilter.Parse(d.ImageFilter)
+		if err != nil {
+			return err
+		}
+		if filter.Owner == "" {
+			return errors.New("The image filter of Azure needs the publisher of the images as owner, e.g. owner=Canonical")
+		}
+	}
+
+	switch d.AuthMethod {
+	case "", authMethodDevice, authMethodManagedIdentity:
+	case authMethodClientCredentials:
+		if d.ClientID == "" {
+			return requiredOptionError(flAzureClientID)
+		}
+		if d.ClientSecret == "" {
+			return requiredOptionError(flAzureClientSecret)
+		}
+	default:
+		return fmt.Errorf("Invalid Azure authentication method: %q, expected one of: [%s, %s, %s]",
+			d.AuthMethod, authMethodDevice, authMethodManagedIdentity, authMethodClientCredentials)
+	}
+
+	d.SpotMaxPrice = defaultAzureSpotMaxPrice
+	if maxPrice := fl.String(flAzureSpotMaxPrice); maxPrice != "" {
+		price, err := strconv.ParseFloat(maxPrice, 64)
+		if err != nil || (price != -1 && price <= 0) {
+			return fmt.Errorf("Invalid spot max price: %q, expected a price in US dollars or -1", maxPrice)
+		}
+		d.SpotMaxPrice = price
+	}
+
+	switch d.Zone {
+	case "", "1", "2", "3":
+	default:
+		return fmt.Errorf("Invalid Azure availability zone: %q, expected one of: [1, 2, 3]", d.Zone)
+	}
 
 	// Set flags on the BaseDriver
 	d.BaseDriver.SSHPort = sshPort
@@ -245,6 +370,14 @@ func (d *Driver) SetConfigFromFlags(fl drivers.DriverOptions) error {
 // DriverName returns the name of the driver.
 func (d *Driver) DriverName() string { return driverName }
 
+// Capabilities returns the features of the driver, including spot instances and user data.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.Spot = true
+	capabilities.UserData = true
+	return capabilities
+}
+
 // PreCreateCheck validates if driver values are valid to create the machine.
 func (d *Driver) PreCreateCheck() (err error) {
 	c, err := d.newAzureClient()
@@ -260,6 +393,18 @@ func (d *Driver) PreCreateCheck() (err error) {
 		return err
 	}
 
+	if d.ImageFilter != "" {
+		if err := d.resolveImageFilter(c); err != nil {
+			return err
+		}
+	}
+
+	if d.CustomDataFile != "" {
+		if _, err := os.Stat(d.CustomDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("custom-data file %s could not be found", d.CustomDataFile)
+		}
+	}
+
 	// Validate if firewall rules can be read correctly
 	d.ctx.FirewallRules, err = d.getSecurityRules(d.OpenPorts)
 	if err != nil {
@@ -299,8 +444,13 @@ func (d *Driver) Create() error {
 	if err := c.CreateResourceGroup(d.ResourceGroup, d.Location); err != nil {
 		return err
 	}
-	if err := c.CreateAvailabilitySetIfNotExists(d.ctx, d.ResourceGroup, d.AvailabilitySet, d.Location); err != nil {
-		return err
+	if d.Zone != "" {
+		// A virtual machine is either in a zone or in an availability set
+		log.Infof("Creating the virtual machine in availability zone %s.", d.Zone)
+	} else {
+		if err := c.CreateAvailabilitySetIfNotExists(d.ctx, d.ResourceGroup, d.AvailabilitySet, d.Location); err != nil {
+			return err
+		}
 	}
 	if err := c.CreateNetworkSecurityGroup(d.ctx, d.ResourceGroup, d.naming().NSG(), d.Location, d.ctx.FirewallRules); err != nil {
 		return err
@@ -314,22 +464,33 @@ func (d *Driver) Create() error {
 	if d.NoPublicIP {
 		log.Info("Not creating a public IP address.")
 	} else {
-		if err := c.CreatePublicIPAddress(d.ctx, d.ResourceGroup, d.naming().IP(), d.Location, d.StaticPublicIP); err != nil {
+		if err := c.CreatePublicIPAddress(d.ctx, d.ResourceGroup, d.naming().IP(), d.Location, d.StaticPublicIP, d.Zone); err != nil {
 			return err
 		}
 	}
 	if err := c.CreateNetworkInterface(d.ctx, d.ResourceGroup, d.naming().NIC(), d.Location,
-		d.ctx.PublicIPAddressID, d.ctx.SubnetID, d.ctx.NetworkSecurityGroupID, d.PrivateIPAddr); err != nil {
-		return err
-	}
-	if err := c.CreateStorageAccount(d.ctx, d.ResourceGroup, d.Location, defaultStorageType); err != nil {
+		d.ctx.PublicIPAddressID, d.ctx.SubnetID, d.ctx.NetworkSecurityGroupID, d.PrivateIPAddr, d.AcceleratedNetworking); err != nil {
 		return err
 	}
 	if err := d.generateSSHKey(d.ctx); err != nil {
 		return err
 	}
+	customData := ""
+	if d.CustomDataFile != "" {
+		buf, err := ioutil.ReadFile(d.CustomDataFile)
+		if err != nil {
+			return err
+		}
+		customData = base64.StdEncoding.EncodeToString(buf)
+	}
 	if err := c.CreateVirtualMachine(d.ResourceGroup, d.naming().VM(), d.Location, d.Size, d.ctx.AvailabilitySetID,
-		d.ctx.NetworkInterfaceID, d.BaseDriver.SSHUser, d.ctx.SSHPublicKey, d.Image, d.ctx.StorageAccount); err != nil {
+		d.ctx.NetworkInterfaceID, d.BaseDriver.SSHUser, d.ctx.SSHPublicKey, d.Image, customData, azureutil.VirtualMachineOptions{
+			StorageAccountType: string(defaultStorageType),
+			EphemeralOSDisk:    d.EphemeralOSDisk,
+			Spot:               d.Spot,
+			SpotMaxPrice:       d.SpotMaxPrice,
+			Zone:               d.Zone,
+		}); err != nil {
 		return err
 	}
 	return nil
@@ -487,6 +648,24 @@ func (d *Driver) Restart() error {
 	return c.RestartVirtualMachine(d.ResourceGroup, d.naming().VM())
 }
 
+// Resize changes the size of the virtual machine.
+func (d *Driver) Resize(size string) error {
+	if err := d.checkLegacyDriver(true); err != nil {
+		return err
+	}
+
+	c, err := d.newAzureClient()
+	if err != nil {
+		return err
+	}
+	if err := c.ResizeVirtualMachine(d.ResourceGroup, d.naming().VM(), size); err != nil {
+		return err
+	}
+
+	d.Size = size
+	return nil
+}
+
 // Kill stops the virtual machine role instance.
 func (d *Driver) Kill() error {
 	// NOTE(ahmetalpbalkan) In Azure, there is no kill option for virtual
@@ -0,0 +1,171 @@
+package azureutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/docker/machine/version"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/Azure/azure-sdk-for-go/arm/network"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// NOTE: The vendored SDK predates managed and ephemeral disks, spot virtual
+// machines, availability zones and accelerated networking. The virtual
+// machines, availability sets, network interfaces and public IP addresses are
+// therefore created with the requests below at later API versions, and the SDK
+// clients of these resources make their requests at the same versions, so that
+// they read them back.
+const (
+	computeAPIVersion = "2019-07-01"
+	networkAPIVersion = "2019-11-01"
+)
+
+// withInspectionAt inspects the requests like withInspection, after moving
+// those made at the API version of the SDK to a later one.
+func withInspectionAt(sdkVersion, apiVersion string) autorest.PrepareDecorator {
+	inspect := withInspection()
+	return func(p autorest.Preparer) autorest.Preparer {
+		return inspect(autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+			r, err := p.Prepare(r)
+			if err != nil {
+				return r, err
+			}
+			q := r.URL.Query()
+			if q.Get("api-version") == sdkVersion {
+				q.Set("api-version", apiVersion)
+				r.URL.RawQuery = q.Encode()
+			}
+			return r, nil
+		}))
+	}
+}
+
+func (a AzureClient) armClient() autorest.Client {
+	c := autorest.NewClientWithUserAgent(fmt.Sprintf("docker-machine/%s", version.Version))
+	c.Authorizer = a.auth
+	c.RequestInspector = withInspection()
+	c.ResponseInspector = byInspecting()
+	c.PollingDelay = time.Second * 5
+	return c
+}
+
+// resourcePath returns the path of a resource of the resource group, which is
+// also its ID.
+func (a AzureClient) resourcePath(resourceGroup, resourceType, name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s/%s",
+		url.QueryEscape(a.subscriptionID), url.QueryEscape(resourceGroup), resourceType, url.QueryEscape(name))
+}
+
+// armRequest sends a request for a resource at an API version and waits for
+// the operation it starts to complete.
+func (a AzureClient) armRequest(method, path, apiVersion string, body interface{}) error {
+	c := a.armClient()
+
+	prepare := []autorest.PrepareDecorator{
+		autorest.AsJSON(),
+		autorest.WithMethod(method),
+		autorest.WithBaseURL(a.env.ResourceManagerEndpoint),
+		autorest.WithPath(path),
+		autorest.WithQueryParameters(map[string]interface{}{"api-version": apiVersion}),
+	}
+	if body != nil {
+		prepare = append(prepare, autorest.WithJSON(body))
+	}
+	req, err := autorest.Prepare(&http.Request{}, prepare...)
+	if err != nil {
+		return autorest.NewErrorWithError(err, "azureutil", method, nil, "Failure preparing request")
+	}
+
+	resp, err := autorest.SendWithSender(c, req, azure.DoPollForAsynchronous(c.PollingDelay))
+	if err != nil {
+		return autorest.NewErrorWithError(err, "azureutil", method, resp, "Failure sending request")
+	}
+
+	err = autorest.Respond(resp,
+		c.ByInspecting(),
+		azure.WithErrorUnlessStatusCode(http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent),
+		autorest.ByClosing())
+	if err != nil {
+		return autorest.NewErrorWithError(err, "azureutil", method, resp, "Failure responding to request")
+	}
+	return nil
+}
+
+type sku struct {
+	Name string `json:"name"`
+}
+
+type availabilitySetProperties struct {
+	PlatformFaultDomainCount  int32 `json:"platformFaultDomainCount"`
+	PlatformUpdateDomainCount int32 `json:"platformUpdateDomainCount"`
+}
+
+type availabilitySet struct {
+	Location   string                     `json:"location"`
+	Sku        *sku                       `json:"sku"`
+	Properties *availabilitySetProperties `json:"properties"`
+}
+
+type publicIPAddress struct {
+	Location   string                                   `json:"location"`
+	Sku        *sku                                     `json:"sku,omitempty"`
+	Zones      []string                                 `json:"zones,omitempty"`
+	Properties *network.PublicIPAddressPropertiesFormat `json:"properties"`
+}
+
+type networkInterfaceProperties struct {
+	network.InterfacePropertiesFormat
+	EnableAcceleratedNetworking bool `json:"enableAcceleratedNetworking,omitempty"`
+}
+
+type networkInterface struct {
+	Location   string                      `json:"location"`
+	Properties *networkInterfaceProperties `json:"properties"`
+}
+
+type managedDiskParameters struct {
+	StorageAccountType string `json:"storageAccountType,omitempty"`
+}
+
+type diffDiskSettings struct {
+	Option string `json:"option"`
+}
+
+type osDisk struct {
+	Name             string                        `json:"name"`
+	Caching          compute.CachingTypes          `json:"caching"`
+	CreateOption     compute.DiskCreateOptionTypes `json:"createOption"`
+	ManagedDisk      *managedDiskParameters        `json:"managedDisk,omitempty"`
+	DiffDiskSettings *diffDiskSettings             `json:"diffDiskSettings,omitempty"`
+}
+
+type storageProfile struct {
+	ImageReference *compute.ImageReference `json:"imageReference"`
+	OsDisk         *osDisk                 `json:"osDisk"`
+}
+
+type billingProfile struct {
+	MaxPrice float64 `json:"maxPrice"`
+}
+
+type virtualMachineProperties struct {
+	AvailabilitySet *compute.SubResource     `json:"availabilitySet,omitempty"`
+	HardwareProfile *compute.HardwareProfile `json:"hardwareProfile"`
+	NetworkProfile  *compute.NetworkProfile  `json:"networkProfile"`
+	OsProfile       *compute.OSProfile       `json:"osProfile,omitempty"`
+	StorageProfile  *storageProfile          `json:"storageProfile,omitempty"`
+	Priority        string                   `json:"priority,omitempty"`
+	EvictionPolicy  string                   `json:"evictionPolicy,omitempty"`
+	BillingProfile  *billingProfile          `json:"billingProfile,omitempty"`
+}
+
+type virtualMachine struct {
+	Location   string                    `json:"location"`
+	Zones      []string                  `json:"zones,omitempty"`
+	Properties *virtualMachineProperties `json:"properties"`
+}
@@ -118,6 +118,31 @@ func Authenticate(env azure.Environment, subscriptionID string) (*azure.ServiceP
 	return spt, nil
 }
 
+// AuthenticateClientCredentials obtains a token for the service principal of
+// an application registered by the user in the tenant of the subscription,
+// with the secret of the application.
+func AuthenticateClientCredentials(env azure.Environment, subscriptionID, clientID, clientSecret string) (*azure.ServicePrincipalToken, error) {
+	tenantID, err := loadOrFindTenantID(env, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthCfg, err := env.OAuthConfigForTenant(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to obtain oauth config for azure environment: %v", err)
+	}
+
+	spt, err := azure.NewServicePrincipalToken(*oauthCfg, clientID, clientSecret, env.ResourceManagerEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Error constructing service principal token: %v", err)
+	}
+	if err := spt.Refresh(); err != nil {
+		return nil, fmt.Errorf("Failed to obtain a token for the application %s: %v", clientID, err)
+	}
+	log.Debug("Obtained service principal token.", logutil.Fields{"client": clientID})
+	return spt, nil
+}
+
 // tokenFromFile returns a token from the specified file if it is found, otherwise
 // returns nil. Any error retrieving or creating the token is returned as an error.
 func tokenFromFile(oauthCfg azure.OAuthConfig, tokenPath, clientID, resource string,
@@ -9,12 +9,12 @@ import (
 	"time"
 
 	"github.com/docker/machine/drivers/azure/logutil"
+	"github.com/docker/machine/libmachine/imagefilter"
 	"github.com/docker/machine/libmachine/log"
 
 	"github.com/Azure/azure-sdk-for-go/arm/compute"
 	"github.com/Azure/azure-sdk-for-go/arm/network"
 	"github.com/Azure/azure-sdk-for-go/arm/resources/resources"
-	"github.com/Azure/azure-sdk-for-go/arm/storage"
 	blobstorage "github.com/Azure/azure-sdk-for-go/storage"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure"
@@ -22,10 +22,11 @@ import (
 )
 
 const (
-	storageAccountPrefix     = "vhds"   // do not contaminate to user's existing storage accounts
-	fmtOSDiskContainer       = "vhd-%s" // place vhds of VMs in separate containers for ease of cleanup
-	fmtOSDiskBlobName        = "%s-os-disk.vhd"
+	fmtOSDiskContainer       = "vhd-%s" // containers of the VHDs of the VMs created before managed disks
 	fmtOSDiskResourceName    = "%s-os-disk"
+	availabilitySetSku       = "Aligned" // required by the VMs with managed disks
+	zonalPublicIPAddressSku  = "Standard"
+	spotPriority             = "Spot"
 	defaultStorageAPIVersion = blobstorage.DefaultAPIVersion
 )
 
@@ -142,10 +143,11 @@ func (a AzureClient) DeleteNetworkSecurityGroupIfExists(resourceGroup, name stri
 		func() (autorest.Response, error) { return a.securityGroupsClient().Delete(resourceGroup, name, nil) })
 }
 
-func (a AzureClient) CreatePublicIPAddress(ctx *DeploymentContext, resourceGroup, name, location string, isStatic bool) error {
+func (a AzureClient) CreatePublicIPAddress(ctx *DeploymentContext, resourceGroup, name, location string, isStatic bool, zone string) error {
 	log.Info("Creating public IP address.", logutil.Fields{
 		"name":   name,
-		"static": isStatic})
+		"static": isStatic,
+		"zone":   zone})
 
 	var ipType network.IPAllocationMethod
 	if isStatic {
@@ -154,19 +156,25 @@ func (a AzureClient) CreatePublicIPAddress(ctx *DeploymentContext, resourceGroup
 		ipType = network.Dynamic
 	}
 
-	_, err := a.publicIPAddressClient().CreateOrUpdate(resourceGroup, name,
-		network.PublicIPAddress{
-			Location: to.StringPtr(location),
-			Properties: &network.PublicIPAddressPropertiesFormat{
-				PublicIPAllocationMethod: ipType,
-			},
-		}, nil)
-	if err != nil {
+	ip := publicIPAddress{
+		Location: location,
+		Properties: &network.PublicIPAddressPropertiesFormat{
+			PublicIPAllocationMethod: ipType,
+		},
+	}
+	if zone != "" {
+		// Only the static addresses of the Standard SKU are zonal
+		ip.Sku = &sku{Name: zonalPublicIPAddressSku}
+		ip.Zones = []string{zone}
+		ip.Properties.PublicIPAllocationMethod = network.Static
+	}
+
+	path := a.resourcePath(resourceGroup, "Microsoft.Network/publicIPAddresses", name)
+	if err := a.armRequest("PUT", path, networkAPIVersion, ip); err != nil {
 		return err
 	}
-	ip, err := a.publicIPAddressClient().Get(resourceGroup, name, "")
-	ctx.PublicIPAddressID = to.String(ip.ID)
-	return err
+	ctx.PublicIPAddressID = path
+	return nil
 }
 
 func (a AzureClient) DeletePublicIPAddressIfExists(resourceGroup, name string) error {
@@ -251,12 +259,14 @@ func (a AzureClient) CleanupSubnetIfExists(resourceGroup, virtualNetwork, name s
 	})
 }
 
-func (a AzureClient) CreateNetworkInterface(ctx *DeploymentContext, resourceGroup, name, location, publicIPAddressID, subnetID, nsgID, privateIPAddress string) error {
+func (a AzureClient) CreateNetworkInterface(ctx *DeploymentContext, resourceGroup, name, location, publicIPAddressID, subnetID, nsgID, privateIPAddress string, acceleratedNetworking bool) error {
 	// NOTE(ahmetalpbalkan) This method is expected to fail if the user
 	// specified Azure location is different than location of the virtual
 	// network as Azure does not support cross-region virtual networks. In this
 	// situation, user will get an explanatory API error from Azure.
-	log.Info("Creating network interface.", logutil.Fields{"name": name})
+	log.Info("Creating network interface.", logutil.Fields{
+		"name":                  name,
+		"acceleratedNetworking": acceleratedNetworking})
 
 	var publicIP *network.PublicIPAddress
 	if publicIPAddressID != "" {
@@ -267,33 +277,36 @@ func (a AzureClient) CreateNetworkInterface(ctx *DeploymentContext, resourceGrou
 	if privateIPAddress != "" {
 		privateIPAllocMethod = network.Static
 	}
-	_, err := a.networkInterfacesClient().CreateOrUpdate(resourceGroup, name, network.Interface{
-		Location: to.StringPtr(location),
-		Properties: &network.InterfacePropertiesFormat{
-			NetworkSecurityGroup: &network.SecurityGroup{
-				ID: to.StringPtr(nsgID),
-			},
-			IPConfigurations: &[]network.InterfaceIPConfiguration{
-				{
-					Name: to.StringPtr("ip"),
-					Properties: &network.InterfaceIPConfigurationPropertiesFormat{
-						PrivateIPAddress:          to.StringPtr(privateIPAddress),
-						PrivateIPAllocationMethod: privateIPAllocMethod,
-						PublicIPAddress:           publicIP,
-						Subnet: &network.Subnet{
-							ID: to.StringPtr(subnetID),
+	path := a.resourcePath(resourceGroup, "Microsoft.Network/networkInterfaces", name)
+	err := a.armRequest("PUT", path, networkAPIVersion, networkInterface{
+		Location: location,
+		Properties: &networkInterfaceProperties{
+			InterfacePropertiesFormat: network.InterfacePropertiesFormat{
+				NetworkSecurityGroup: &network.SecurityGroup{
+					ID: to.StringPtr(nsgID),
+				},
+				IPConfigurations: &[]network.InterfaceIPConfiguration{
+					{
+						Name: to.StringPtr("ip"),
+						Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+							PrivateIPAddress:          to.StringPtr(privateIPAddress),
+							PrivateIPAllocationMethod: privateIPAllocMethod,
+							PublicIPAddress:           publicIP,
+							Subnet: &network.Subnet{
+								ID: to.StringPtr(subnetID),
+							},
 						},
 					},
 				},
 			},
+			EnableAcceleratedNetworking: acceleratedNetworking,
 		},
-	}, nil)
+	})
 	if err != nil {
 		return err
 	}
-	nic, err := a.networkInterfacesClient().Get(resourceGroup, name, "")
-	ctx.NetworkInterfaceID = to.String(nic.ID)
-	return err
+	ctx.NetworkInterfaceID = path
+	return nil
 }
 
 func (a AzureClient) DeleteNetworkInterfaceIfExists(resourceGroup, name string) error {
@@ -305,77 +318,54 @@ func (a AzureClient) DeleteNetworkInterfaceIfExists(resourceGroup, name string)
 		func() (autorest.Response, error) { return a.networkInterfacesClient().Delete(resourceGroup, name, nil) })
 }
 
-func (a AzureClient) CreateStorageAccount(ctx *DeploymentContext, resourceGroup, location string, storageType storage.AccountType) error {
-	s, err := a.findOrCreateStorageAccount(resourceGroup, location, storageType)
-	ctx.StorageAccount = s
-	return err
-}
-
-func (a AzureClient) findOrCreateStorageAccount(resourceGroup, location string, storageType storage.AccountType) (*storage.AccountProperties, error) {
-	prefix := storageAccountPrefix
-	if s, err := a.findStorageAccount(resourceGroup, location, prefix, storageType); err != nil {
-		return nil, err
-	} else if s != nil {
-		return s, nil
+// ListImages lists the versions of the images of a publisher in a location
+// whose offer:sku matches the filter, as publisher:offer:sku:version.
+func (a AzureClient) ListImages(location, publisher string, filter *imagefilter.Filter) ([]imagefilter.Image, error) {
+	// The offers are narrowed down by the part of the pattern before the
+	// sku, not to list the skus of all the offers of the publisher.
+	offerFilter := &imagefilter.Filter{Name: "*"}
+	if i := strings.Index(filter.Name, ":"); i >= 0 {
+		offerFilter.Name = filter.Name[:i]
 	}
 
-	log.Debug("No eligible storage account found.", logutil.Fields{
-		"location": location,
-		"type":     storageType})
-	return a.createStorageAccount(resourceGroup, location, storageType)
-}
-
-func (a AzureClient) findStorageAccount(resourceGroup, location, prefix string, storageType storage.AccountType) (*storage.AccountProperties, error) {
-	f := logutil.Fields{
-		"type":     storageType,
-		"prefix":   prefix,
-		"location": location}
-	log.Debug("Querying existing storage accounts.", f)
-	l, err := a.storageAccountsClient().ListByResourceGroup(resourceGroup)
+	log.Debugf("Listing the images of the publisher %q in %s.", publisher, location)
+	offers, err := a.virtualMachineImagesClient().ListOffers(location, publisher)
 	if err != nil {
 		return nil, err
 	}
 
-	if l.Value != nil {
-		for _, v := range *l.Value {
-			log.Debug("Iterating...", logutil.Fields{
-				"name":     to.String(v.Name),
-				"type":     storageType,
-				"location": to.String(v.Location),
-			})
-			if to.String(v.Location) == location && v.Properties.AccountType == storageType && strings.HasPrefix(to.String(v.Name), prefix) {
-				log.Debug("Found eligible storage account.", logutil.Fields{"name": to.String(v.Name)})
-				return v.Properties, nil
-			}
+	images := []imagefilter.Image{}
+	for _, offer := range imageResourceNames(offers) {
+		if !offerFilter.MatchName(offer) {
+			continue
 		}
-	}
-	log.Debug("No account matching the pattern is found.", f)
-	return nil, err
-}
 
-func (a AzureClient) createStorageAccount(resourceGroup, location string, storageType storage.AccountType) (*storage.AccountProperties, error) {
-	name := randomAzureStorageAccountName() // if it's not random enough, then you're unlucky
-	f := logutil.Fields{
-		"name":     name,
-		"location": location}
+		skus, err := a.virtualMachineImagesClient().ListSkus(location, publisher, offer)
+		if err != nil {
+			return nil, err
+		}
 
-	log.Info("Creating storage account.", f)
-	_, err := a.storageAccountsClient().Create(resourceGroup, name,
-		storage.AccountCreateParameters{
-			Location: to.StringPtr(location),
-			Properties: &storage.AccountPropertiesCreateParameters{
-				AccountType: storageType,
-			},
-		}, nil)
-	if err != nil {
-		return nil, err
-	}
+		for _, sku := range imageResourceNames(skus) {
+			name := offer + ":" + sku
+			if !filter.MatchName(name) {
+				continue
+			}
 
-	s, err := a.storageAccountsClient().GetProperties(resourceGroup, name)
-	if err != nil {
-		return nil, err
+			versions, err := a.virtualMachineImagesClient().List(location, publisher, offer, sku, "", nil, "")
+			if err != nil {
+				return nil, err
+			}
+
+			for _, version := range imageResourceNames(versions) {
+				images = append(images, imagefilter.Image{
+					ID:      strings.Join([]string{publisher, offer, sku, version}, ":"),
+					Name:    name,
+					Version: version,
+				})
+			}
+		}
 	}
-	return s.Properties, nil
+	return images, nil
 }
 
 func (a AzureClient) VirtualMachineExists(resourceGroup, name string) (bool, error) {
@@ -396,12 +386,26 @@ func (a AzureClient) DeleteVirtualMachineIfExists(resourceGroup, name string) er
 		return err
 	}
 
-	// Remove disk
-	if vmRef.Properties != nil {
-		vhdURL := to.String(vmRef.Properties.StorageProfile.OsDisk.Vhd.URI)
-		return a.removeOSDiskBlob(resourceGroup, name, vhdURL)
+	// Remove disk, the VHD of the VMs created before managed disks
+	if vmRef.Properties == nil || vmRef.Properties.StorageProfile == nil || vmRef.Properties.StorageProfile.OsDisk == nil {
+		return nil
 	}
-	return nil
+	osDisk := vmRef.Properties.StorageProfile.OsDisk
+	if osDisk.Vhd != nil {
+		return a.removeOSDiskBlob(resourceGroup, name, to.String(osDisk.Vhd.URI))
+	}
+	return a.deleteManagedDiskIfExists(resourceGroup, to.String(osDisk.Name))
+}
+
+// deleteManagedDiskIfExists removes the managed OS disk of a VM, which is
+// kept when the VM is deleted. Ephemeral disks are gone with the VM already.
+func (a AzureClient) deleteManagedDiskIfExists(resourceGroup, name string) error {
+	path := a.resourcePath(resourceGroup, "Microsoft.Compute/disks", name)
+	return deleteResourceIfExists("Disk", name,
+		func() error { return a.armRequest("GET", path, computeAPIVersion, nil) },
+		func() (autorest.Response, error) {
+			return autorest.Response{}, a.armRequest("DELETE", path, computeAPIVersion, nil)
+		})
 }
 
 func (a AzureClient) removeOSDiskBlob(resourceGroup, vmName, vhdURL string) error {
@@ -445,14 +449,27 @@ func (a AzureClient) removeOSDiskBlob(resourceGroup, vmName, vhdURL string) erro
 	return err
 }
 
+// VirtualMachineOptions are the options of a virtual machine beyond its
+// image, size and network interface.
+type VirtualMachineOptions struct {
+	StorageAccountType string  // of its managed OS disk
+	EphemeralOSDisk    bool    // places the OS disk on the local storage of the host
+	Spot               bool    // runs it on spare capacity, until evicted
+	SpotMaxPrice       float64 // per hour, -1 to pay up to the pay-as-you-go price
+	Zone               string  // availability zone, none for a regional VM
+}
+
 func (a AzureClient) CreateVirtualMachine(resourceGroup, name, location, size, availabilitySetID, networkInterfaceID,
-	username, sshPublicKey, imageName string, storageAccount *storage.AccountProperties) error {
+	username, sshPublicKey, imageName, customData string, options VirtualMachineOptions) error {
 	log.Info("Creating virtual machine.", logutil.Fields{
-		"name":     name,
-		"location": location,
-		"size":     size,
-		"username": username,
-		"osImage":  imageName,
+		"name":      name,
+		"location":  location,
+		"size":      size,
+		"username":  username,
+		"osImage":   imageName,
+		"ephemeral": options.EphemeralOSDisk,
+		"spot":      options.Spot,
+		"zone":      options.Zone,
 	})
 
 	img, err := parseImageName(imageName)
@@ -460,64 +477,84 @@ func (a AzureClient) CreateVirtualMachine(resourceGroup, name, location, size, a
 		return err
 	}
 
-	var (
-		osDiskBlobURL = osDiskStorageBlobURL(storageAccount, name)
-		sshKeyPath    = fmt.Sprintf("/home/%s/.ssh/authorized_keys", username)
-	)
-	log.Debugf("OS disk blob will be placed at: %s", osDiskBlobURL)
+	sshKeyPath := fmt.Sprintf("/home/%s/.ssh/authorized_keys", username)
 	log.Debugf("SSH key will be placed at: %s", sshKeyPath)
 
-	_, err = a.virtualMachinesClient().CreateOrUpdate(resourceGroup, name,
-		compute.VirtualMachine{
-			Location: to.StringPtr(location),
-			Properties: &compute.VirtualMachineProperties{
-				AvailabilitySet: &compute.SubResource{
-					ID: to.StringPtr(availabilitySetID),
-				},
-				HardwareProfile: &compute.HardwareProfile{
-					VMSize: compute.VirtualMachineSizeTypes(size),
-				},
-				NetworkProfile: &compute.NetworkProfile{
-					NetworkInterfaces: &[]compute.NetworkInterfaceReference{
-						{
-							ID: to.StringPtr(networkInterfaceID),
-						},
+	osProfile := &compute.OSProfile{
+		ComputerName:  to.StringPtr(name),
+		AdminUsername: to.StringPtr(username),
+		LinuxConfiguration: &compute.LinuxConfiguration{
+			DisablePasswordAuthentication: to.BoolPtr(true),
+			SSH: &compute.SSHConfiguration{
+				PublicKeys: &[]compute.SSHPublicKey{
+					{
+						Path:    to.StringPtr(sshKeyPath),
+						KeyData: to.StringPtr(sshPublicKey),
 					},
 				},
-				OsProfile: &compute.OSProfile{
-					ComputerName:  to.StringPtr(name),
-					AdminUsername: to.StringPtr(username),
-					LinuxConfiguration: &compute.LinuxConfiguration{
-						DisablePasswordAuthentication: to.BoolPtr(true),
-						SSH: &compute.SSHConfiguration{
-							PublicKeys: &[]compute.SSHPublicKey{
-								{
-									Path:    to.StringPtr(sshKeyPath),
-									KeyData: to.StringPtr(sshPublicKey),
-								},
-							},
-						},
+			},
+		},
+	}
+	if customData != "" {
+		osProfile.CustomData = to.StringPtr(customData)
+	}
+
+	disk := &osDisk{
+		Name:         fmt.Sprintf(fmtOSDiskResourceName, name),
+		Caching:      compute.ReadWrite,
+		CreateOption: compute.FromImage,
+		ManagedDisk: &managedDiskParameters{
+			StorageAccountType: options.StorageAccountType,
+		},
+	}
+	if options.EphemeralOSDisk {
+		disk.Caching = compute.ReadOnly
+		disk.DiffDiskSettings = &diffDiskSettings{Option: "Local"}
+	}
+
+	vm := virtualMachine{
+		Location: location,
+		Properties: &virtualMachineProperties{
+			HardwareProfile: &compute.HardwareProfile{
+				VMSize: compute.VirtualMachineSizeTypes(size),
+			},
+			NetworkProfile: &compute.NetworkProfile{
+				NetworkInterfaces: &[]compute.NetworkInterfaceReference{
+					{
+						ID: to.StringPtr(networkInterfaceID),
 					},
 				},
-				StorageProfile: &compute.StorageProfile{
-					ImageReference: &compute.ImageReference{
-						Publisher: to.StringPtr(img.publisher),
-						Offer:     to.StringPtr(img.offer),
-						Sku:       to.StringPtr(img.sku),
-						Version:   to.StringPtr(img.version),
-					},
-					OsDisk: &compute.OSDisk{
-						Name:         to.StringPtr(fmt.Sprintf(fmtOSDiskResourceName, name)),
-						Caching:      compute.ReadWrite,
-						CreateOption: compute.FromImage,
-						Vhd: &compute.VirtualHardDisk{
-							URI: to.StringPtr(osDiskBlobURL),
-						},
-					},
+			},
+			OsProfile: osProfile,
+			StorageProfile: &storageProfile{
+				ImageReference: &compute.ImageReference{
+					Publisher: to.StringPtr(img.publisher),
+					Offer:     to.StringPtr(img.offer),
+					Sku:       to.StringPtr(img.sku),
+					Version:   to.StringPtr(img.version),
 				},
+				OsDisk: disk,
 			},
-		}, nil)
-	return err
+		},
+	}
+	if availabilitySetID != "" {
+		vm.Properties.AvailabilitySet = &compute.SubResource{ID: to.StringPtr(availabilitySetID)}
+	}
+	if options.Zone != "" {
+		vm.Zones = []string{options.Zone}
+	}
+	if options.Spot {
+		// An ephemeral OS disk can't be deallocated, the VM is deleted
+		// instead when evicted.
+		vm.Properties.Priority = spotPriority
+		vm.Properties.EvictionPolicy = "Deallocate"
+		if options.EphemeralOSDisk {
+			vm.Properties.EvictionPolicy = "Delete"
+		}
+		vm.Properties.BillingProfile = &billingProfile{MaxPrice: options.SpotMaxPrice}
+	}
+
+	return a.armRequest("PUT", a.resourcePath(resourceGroup, "Microsoft.Compute/virtualMachines", name), computeAPIVersion, vm)
 }
 
 func (a AzureClient) GetVirtualMachinePowerState(resourceGroup, name string) (VMPowerState, error) {
@@ -537,12 +574,24 @@ func (a AzureClient) GetAvailabilitySet(resourceGroup, name string) (compute.Ava
 func (a AzureClient) CreateAvailabilitySetIfNotExists(ctx *DeploymentContext, resourceGroup, name, location string) error {
 	f := logutil.Fields{"name": name}
 	log.Info("Configuring availability set.", f)
-	as, err := a.availabilitySetsClient().CreateOrUpdate(resourceGroup, name,
-		compute.AvailabilitySet{
-			Location: to.StringPtr(location),
-		})
-	ctx.AvailabilitySetID = to.String(as.ID)
-	return err
+	path := a.resourcePath(resourceGroup, "Microsoft.Compute/availabilitySets", name)
+	ctx.AvailabilitySetID = path
+
+	if exists, err := checkResourceExistsFromError(a.armRequest("GET", path, computeAPIVersion, nil)); err != nil {
+		return err
+	} else if exists {
+		log.Debug("Availability set already exists.", f)
+		return nil
+	}
+
+	return a.armRequest("PUT", path, computeAPIVersion, availabilitySet{
+		Location: location,
+		Sku:      &sku{Name: availabilitySetSku},
+		Properties: &availabilitySetProperties{
+			PlatformFaultDomainCount:  2,
+			PlatformUpdateDomainCount: 5,
+		},
+	})
 }
 
 // CleanupAvailabilitySetIfExists removes an availability set if there are no
@@ -617,6 +666,20 @@ func (a AzureClient) RestartVirtualMachine(resourceGroup, name string) error {
 	return a.waitVMPowerState(resourceGroup, name, Running, waitStartTimeout)
 }
 
+// ResizeVirtualMachine changes the size of the virtual machine, which Azure
+// restarts if it's running.
+func (a AzureClient) ResizeVirtualMachine(resourceGroup, name, size string) error {
+	log.Info("Resizing virtual machine.", logutil.Fields{"vm": name, "size": size})
+	return a.armRequest("PATCH", a.resourcePath(resourceGroup, "Microsoft.Compute/virtualMachines", name), computeAPIVersion,
+		map[string]interface{}{
+			"properties": map[string]interface{}{
+				"hardwareProfile": compute.HardwareProfile{
+					VMSize: compute.VirtualMachineSizeTypes(size),
+				},
+			},
+		})
+}
+
 // deleteResourceIfExists is an utility method to determine if a resource exists
 // from the error returned from its Get response. If so, deletes it. name is
 // used only for logging purposes.
@@ -704,24 +767,10 @@ func checkResourceExistsFromError(err error) (bool, error) {
 	return false, v
 }
 
-// osDiskStorageBlobURL gives the full url of the VHD blob where the OS disk for
-// the given VM should be stored.
-func osDiskStorageBlobURL(account *storage.AccountProperties, vmName string) string {
-	containerURL := osDiskStorageContainerURL(account, vmName) // has trailing slash
-	blobName := fmt.Sprintf(fmtOSDiskBlobName, vmName)
-	return containerURL + blobName
-}
-
 // osDiskStorageContainerName returns the container name the OS disk for the VM
 // should be saved.
 func osDiskStorageContainerName(vm string) string { return fmt.Sprintf(fmtOSDiskContainer, vm) }
 
-// osDiskStorageContainerURL crafts a URL with a trailing slash pointing
-// to the full Azure Blob Container URL for given VM name.
-func osDiskStorageContainerURL(account *storage.AccountProperties, vmName string) string {
-	return fmt.Sprintf("%s%s/", to.String(account.PrimaryEndpoints.Blob), osDiskStorageContainerName(vmName))
-}
-
 // extractStorageAccountFromVHDURL parses a blob URL and extracts the Azure
 // Storage account name from the URL, namely first subdomain of the hostname and
 // the Azure Storage service base URL (e.g. core.windows.net). If it could not
@@ -90,7 +90,7 @@ func (a AzureClient) networkInterfacesClient() network.InterfacesClient {
 	c := network.NewInterfacesClientWithBaseURI(a.env.ResourceManagerEndpoint, a.subscriptionID)
 	c.Authorizer = a.auth
 	c.Client.UserAgent += fmt.Sprintf(";docker-machine/%s", version.Version)
-	c.RequestInspector = withInspection()
+	c.RequestInspector = withInspectionAt(network.APIVersion, networkAPIVersion)
 	c.ResponseInspector = byInspecting()
 	c.PollingDelay = time.Second * 5
 	return c
@@ -100,7 +100,7 @@ func (a AzureClient) publicIPAddressClient() network.PublicIPAddressesClient {
 	c := network.NewPublicIPAddressesClientWithBaseURI(a.env.ResourceManagerEndpoint, a.subscriptionID)
 	c.Authorizer = a.auth
 	c.Client.UserAgent += fmt.Sprintf(";docker-machine/%s", version.Version)
-	c.RequestInspector = withInspection()
+	c.RequestInspector = withInspectionAt(network.APIVersion, networkAPIVersion)
 	c.ResponseInspector = byInspecting()
 	c.PollingDelay = time.Second * 5
 	return c
@@ -120,7 +120,17 @@ func (a AzureClient) virtualMachinesClient() compute.VirtualMachinesClient {
 	c := compute.NewVirtualMachinesClientWithBaseURI(a.env.ResourceManagerEndpoint, a.subscriptionID)
 	c.Authorizer = a.auth
 	c.Client.UserAgent += fmt.Sprintf(";docker-machine/%s", version.Version)
-	c.RequestInspector = withInspection()
+	c.RequestInspector = withInspectionAt(compute.APIVersion, computeAPIVersion)
+	c.ResponseInspector = byInspecting()
+	c.PollingDelay = time.Second * 5
+	return c
+}
+
+func (a AzureClient) virtualMachineImagesClient() compute.VirtualMachineImagesClient {
+	c := compute.NewVirtualMachineImagesClientWithBaseURI(a.env.ResourceManagerEndpoint, a.subscriptionID)
+	c.Authorizer = a.auth
+	c.Client.UserAgent += fmt.Sprintf(";docker-machine/%s", version.Version)
+	c.RequestInspector = withInspectionAt(compute.APIVersion, computeAPIVersion)
 	c.ResponseInspector = byInspecting()
 	c.PollingDelay = time.Second * 5
 	return c
@@ -130,7 +140,7 @@ func (a AzureClient) availabilitySetsClient() compute.AvailabilitySetsClient {
 	c := compute.NewAvailabilitySetsClientWithBaseURI(a.env.ResourceManagerEndpoint, a.subscriptionID)
 	c.Authorizer = a.auth
 	c.Client.UserAgent += fmt.Sprintf(";docker-machine/%s", version.Version)
-	c.RequestInspector = withInspection()
+	c.RequestInspector = withInspectionAt(compute.APIVersion, computeAPIVersion)
 	c.ResponseInspector = byInspecting()
 	c.PollingDelay = time.Second * 5
 	return c
@@ -0,0 +1,107 @@
+package azureutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+const (
+	msiTokenEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	msiAPIVersion    = "2018-02-01"
+	msiRefreshWithin = 5 * time.Minute
+)
+
+var msiClient = &http.Client{Timeout: 10 * time.Second}
+
+// managedIdentityToken authorizes the requests with the tokens of the managed
+// identity of the Azure virtual machine docker-machine runs on, which the
+// Instance Metadata Service of the machine hands out. No credentials are
+// stored, the token is requested again before it expires.
+type managedIdentityToken struct {
+	clientID string
+	resource string
+
+	lock  sync.Mutex
+	token azure.Token
+}
+
+func (m *managedIdentityToken) refresh() error {
+	q := url.Values{}
+	q.Set("api-version", msiAPIVersion)
+	q.Set("resource", m.resource)
+	if m.clientID != "" {
+		q.Set("client_id", m.clientID)
+	}
+
+	req, err := http.NewRequest("GET", msiTokenEndpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := msiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("the Instance Metadata Service is not reachable, managed identities are only available on Azure: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, string(body))
+	}
+
+	var token azure.Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("invalid token: %v", err)
+	}
+	m.token = token
+	log.Debug("Obtained managed identity token.")
+	return nil
+}
+
+func (m *managedIdentityToken) WithAuthorization() autorest.PrepareDecorator {
+	return func(p autorest.Preparer) autorest.Preparer {
+		return autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+			r, err := p.Prepare(r)
+			if err != nil {
+				return r, err
+			}
+
+			m.lock.Lock()
+			defer m.lock.Unlock()
+			if m.token.WillExpireIn(msiRefreshWithin) {
+				if err := m.refresh(); err != nil {
+					return r, fmt.Errorf("Failed to refresh the managed identity token: %v", err)
+				}
+			}
+			return autorest.Prepare(r, autorest.WithBearerAuthorization(m.token.AccessToken))
+		})
+	}
+}
+
+// AuthenticateManagedIdentity returns an authorizer using the managed identity
+// of the Azure virtual machine: the one assigned to it by the system, or the
+// user-assigned identity of the client ID.
+func AuthenticateManagedIdentity(env azure.Environment, clientID string) (autorest.Authorizer, error) {
+	token := &managedIdentityToken{
+		clientID: clientID,
+		resource: env.ResourceManagerEndpoint,
+	}
+	if err := token.refresh(); err != nil {
+		return nil, fmt.Errorf("Failed to obtain managed identity token: %v", err)
+	}
+	return token, nil
+}
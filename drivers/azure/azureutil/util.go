@@ -2,34 +2,13 @@ package azureutil
 
 import (
 	"fmt"
-	"math/rand"
 	"strings"
-	"time"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
 )
 
 /* Utilities */
 
-// randomAzureStorageAccountName generates a valid storage account name prefixed
-// with a predefined string. Availability of the name is not checked. Uses maximum
-// length to maximise randomness.
-func randomAzureStorageAccountName() string {
-	const (
-		maxLen = 24
-		chars  = "0123456789abcdefghijklmnopqrstuvwxyz"
-	)
-	return storageAccountPrefix + randomString(maxLen-len(storageAccountPrefix), chars)
-}
-
-// randomString generates a random string of given length using specified alphabet.
-func randomString(n int, alphabet string) string {
-	r := timeSeed()
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = alphabet[r.Intn(len(alphabet))]
-	}
-	return string(b)
-}
-
 // imageName holds various components of an OS image name identifier
 type imageName struct{ publisher, offer, sku, version string }
 
@@ -42,4 +21,17 @@ func parseImageName(image string) (imageName, error) {
 	return imageName{l[0], l[1], l[2], l[3]}, nil
 }
 
-func timeSeed() *rand.Rand { return rand.New(rand.NewSource(time.Now().UTC().UnixNano())) }
+// imageResourceNames returns the names of the offers, skus or versions
+// listed for the images.
+func imageResourceNames(list compute.ListVirtualMachineImageResource) []string {
+	names := []string{}
+	if list.Value == nil {
+		return names
+	}
+	for _, resource := range *list.Value {
+		if resource.Name != nil {
+			names = append(names, *resource.Name)
+		}
+	}
+	return names
+}
@@ -7,10 +7,12 @@ import (
 	"net/url"
 
 	"github.com/Azure/azure-sdk-for-go/arm/network"
+	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/docker/machine/drivers/azure/azureutil"
 	"github.com/docker/machine/drivers/azure/logutil"
+	"github.com/docker/machine/libmachine/imagefilter"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
@@ -40,11 +42,22 @@ func (d *Driver) newAzureClient() (*azureutil.AzureClient, error) {
 		return nil, fmt.Errorf("Invalid Azure environment: %q", d.Environment)
 	}
 
-	servicePrincipalToken, err := azureutil.Authenticate(env, d.SubscriptionID)
+	var (
+		auth autorest.Authorizer
+		err  error
+	)
+	switch d.AuthMethod {
+	case authMethodManagedIdentity:
+		auth, err = azureutil.AuthenticateManagedIdentity(env, d.ClientID)
+	case authMethodClientCredentials:
+		auth, err = azureutil.AuthenticateClientCredentials(env, d.SubscriptionID, d.ClientID, d.ClientSecret)
+	default:
+		auth, err = azureutil.Authenticate(env, d.SubscriptionID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Error creating Azure client: %v", err)
 	}
-	return azureutil.New(env, d.SubscriptionID, servicePrincipalToken), nil
+	return azureutil.New(env, d.SubscriptionID, auth), nil
 }
 
 // generateSSHKey creates a ssh key pair locally and saves the public key file
@@ -125,6 +138,29 @@ func (d *Driver) getSecurityRules(extraPorts []string) (*[]network.SecurityRule,
 	return &rl, nil
 }
 
+// resolveImageFilter sets the image to the latest version of the images
+// of the publisher matching the image filter in the location.
+func (d *Driver) resolveImageFilter(c *azureutil.AzureClient) error {
+	filter, err := imagefilter.Parse(d.ImageFilter)
+	if err != nil {
+		return err
+	}
+
+	images, err := c.ListImages(d.Location, filter.Owner, filter)
+	if err != nil {
+		return err
+	}
+
+	image, err := filter.Select(images)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Using the image %s matching the filter %q", image.ID, filter)
+	d.Image = image.ID
+	return nil
+}
+
 func (d *Driver) naming() azureutil.ResourceNaming {
 	return azureutil.ResourceNaming(d.BaseDriver.MachineName)
 }
@@ -5,12 +5,13 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
-	"time"
+	"strconv"
 
 	"github.com/digitalocean/godo"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/retry"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 	"golang.org/x/oauth2"
@@ -29,7 +30,15 @@ type Driver struct {
 	IPv6              bool
 	Backups           bool
 	PrivateNetworking bool
+	VPCUUID           string `json:",omitempty"`
+	PrivateIPAddress  string `json:",omitempty"`
 	UserDataFile      string
+	Tags              []string `json:",omitempty"`
+	VolumeSize        int      `json:",omitempty"`
+	VolumeID          string   `json:",omitempty"`
+	VolumeName        string   `json:",omitempty"`
+	ReservedIP        string   `json:",omitempty"`
+	CreateReservedIP  bool     `json:",omitempty"`
 }
 
 const (
@@ -94,11 +103,36 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "digitalocean-private-networking",
 			Usage:  "enable private networking for droplet",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "DIGITALOCEAN_VPC_UUID",
+			Name:   "digitalocean-vpc-uuid",
+			Usage:  "VPC to place the droplet in, instead of the default VPC of the region",
+		},
 		mcnflag.BoolFlag{
 			EnvVar: "DIGITALOCEAN_BACKUPS",
 			Name:   "digitalocean-backups",
 			Usage:  "enable backups for droplet",
 		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "DIGITALOCEAN_TAGS",
+			Name:   "digitalocean-tags",
+			Usage:  "Tags of the droplet and of its volume",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "DIGITALOCEAN_VOLUME_SIZE",
+			Name:   "digitalocean-volume-size",
+			Usage:  "Size in GB of a block storage volume mounted at /var/lib/docker, deleted with the droplet",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "DIGITALOCEAN_RESERVED_IP",
+			Name:   "digitalocean-reserved-ip",
+			Usage:  "Reserved IP to assign to the droplet, which is reached through it",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "DIGITALOCEAN_CREATE_RESERVED_IP",
+			Name:   "digitalocean-create-reserved-ip",
+			Usage:  "Reserve an IP for the droplet, released with it",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "DIGITALOCEAN_USERDATA",
 			Name:   "digitalocean-userdata",
@@ -128,6 +162,14 @@ func (d *Driver) DriverName() string {
 	return "digitalocean"
 }
 
+// Capabilities returns the features of the driver, including IPv6 and user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.IPv6 = true
+	capabilities.UserData = true
+	return capabilities
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AccessToken = flags.String("digitalocean-access-token")
 	d.Image = flags.String("digitalocean-image")
@@ -135,17 +177,40 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.Size = flags.String("digitalocean-size")
 	d.IPv6 = flags.Bool("digitalocean-ipv6")
 	d.PrivateNetworking = flags.Bool("digitalocean-private-networking")
+	d.VPCUUID = flags.String("digitalocean-vpc-uuid")
 	d.Backups = flags.Bool("digitalocean-backups")
 	d.UserDataFile = flags.String("digitalocean-userdata")
+	d.Tags = flags.StringSlice("digitalocean-tags")
+	d.VolumeSize = flags.Int("digitalocean-volume-size")
+	d.ReservedIP = flags.String("digitalocean-reserved-ip")
+	d.CreateReservedIP = flags.Bool("digitalocean-create-reserved-ip")
 	d.SSHUser = flags.String("digitalocean-ssh-user")
 	d.SSHPort = flags.Int("digitalocean-ssh-port")
 	d.SSHKeyFingerprint = flags.String("digitalocean-ssh-key-fingerprint")
 	d.SetSwarmConfigFromFlags(flags)
+	d.SetIPFamilyFromFlags(flags)
+	d.IPv6 = d.IPv6 || d.UseIPv6
 
 	if d.AccessToken == "" {
 		return fmt.Errorf("digitalocean driver requires the --digitalocean-access-token option")
 	}
 
+	if d.ReservedIP != "" && d.CreateReservedIP {
+		return fmt.Errorf("--digitalocean-reserved-ip and --digitalocean-create-reserved-ip can't be combined")
+	}
+
+	if d.ReservedIP != "" && net.ParseIP(d.ReservedIP) == nil {
+		return fmt.Errorf("--digitalocean-reserved-ip must be an IP address, got %q", d.ReservedIP)
+	}
+
+	if d.UseIPv6 && d.usesReservedIP() {
+		return fmt.Errorf("--use-ipv6 can't be combined with a reserved IP, which is an IPv4 address")
+	}
+
+	if d.VolumeSize < 0 {
+		return fmt.Errorf("--digitalocean-volume-size must be a positive number of GB")
+	}
+
 	return nil
 }
 
@@ -189,12 +254,25 @@ func (d *Driver) Create() error {
 
 	d.SSHKeyID = key.ID
 
+	client := d.getClient()
+
+	if d.VolumeSize > 0 {
+		log.Infof("Creating a volume of %dGB...", d.VolumeSize)
+		if err := d.createVolume(client); err != nil {
+			return err
+		}
+	}
+
 	log.Infof("Creating Digital Ocean droplet...")
 
-	client := d.getClient()
+	// The images of snapshots are given by their ID rather than a slug
+	image := godo.DropletCreateImage{Slug: d.Image}
+	if id, err := strconv.Atoi(d.Image); err == nil {
+		image = godo.DropletCreateImage{ID: id}
+	}
 
 	createRequest := &godo.DropletCreateRequest{
-		Image:             godo.DropletCreateImage{Slug: d.Image},
+		Image:             image,
 		Name:              d.MachineName,
 		Region:            d.Region,
 		Size:              d.Size,
@@ -205,7 +283,7 @@ func (d *Driver) Create() error {
 		SSHKeys:           []godo.DropletCreateSSHKey{{ID: d.SSHKeyID}},
 	}
 
-	newDroplet, _, err := client.Droplets.Create(createRequest)
+	newDroplet, err := d.createDroplet(client, createRequest)
 	if err != nil {
 		return err
 	}
@@ -213,31 +291,115 @@ func (d *Driver) Create() error {
 	d.DropletID = newDroplet.ID
 
 	log.Info("Waiting for IP address to be assigned to the Droplet...")
-	for {
+	if err := retry.For(retry.Instance).Until(func() (bool, error) {
 		newDroplet, _, err = client.Droplets.Get(d.DropletID)
 		if err != nil {
-			return err
+			return false, err
 		}
+		ipv4, ipv6 := "", ""
 		for _, network := range newDroplet.Networks.V4 {
 			if network.Type == "public" {
-				d.IPAddress = network.IPAddress
+				ipv4 = network.IPAddress
+			}
+			if network.Type == "private" && d.PrivateIPAddress == "" {
+				d.PrivateIPAddress = network.IPAddress
+			}
+		}
+		for _, network := range newDroplet.Networks.V6 {
+			if network.Type == "public" {
+				ipv6 = network.IPAddress
 			}
 		}
+		d.IPAddress = d.PreferredIP(ipv4, ipv6)
 
-		if d.IPAddress != "" {
-			break
+		if d.UseIPv6 && ipv6 == "" {
+			return false, nil
 		}
 
-		time.Sleep(1 * time.Second)
+		// A reserved IP can only be assigned to an active Droplet
+		return d.IPAddress != "" && (!d.usesReservedIP() || newDroplet.Status == "active"), nil
+	}); err != nil {
+		return err
 	}
 
-	log.Debugf("Created droplet ID %d, IP address %s",
+	if d.usesReservedIP() {
+		if err := d.assignReservedIP(client); err != nil {
+			return err
+		}
+	}
+
+	log.Debugf("Created droplet ID %d, IP address %s, private IP address %s",
 		newDroplet.ID,
-		d.IPAddress)
+		d.IPAddress,
+		d.PrivateIPAddress)
+
+	if d.VolumeID != "" {
+		return d.mountVolume()
+	}
 
 	return nil
 }
 
+// dropletCreateRequest is a droplet creation request placing the droplet in
+// a VPC, tagging it and attaching volumes, which the vendored godo doesn't
+// know.
+type dropletCreateRequest struct {
+	*godo.DropletCreateRequest
+	VPCUUID string   `json:"vpc_uuid,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Volumes []string `json:"volumes,omitempty"`
+}
+
+func (d *Driver) createDroplet(client *godo.Client, createRequest *godo.DropletCreateRequest) (*godo.Droplet, error) {
+	if d.VPCUUID == "" && len(d.Tags) == 0 && d.VolumeID == "" {
+		droplet, _, err := client.Droplets.Create(createRequest)
+		return droplet, err
+	}
+
+	request := &dropletCreateRequest{
+		DropletCreateRequest: createRequest,
+		VPCUUID:              d.VPCUUID,
+		Tags:                 d.Tags,
+	}
+	if d.VolumeID != "" {
+		request.Volumes = []string{d.VolumeID}
+	}
+
+	req, err := client.NewRequest("POST", "v2/droplets", request)
+	if err != nil {
+		return nil, err
+	}
+
+	root := struct {
+		Droplet *godo.Droplet `json:"droplet"`
+	}{}
+	if _, err := client.Do(req, &root); err != nil {
+		return nil, err
+	}
+	return root.Droplet, nil
+}
+
+// GetPrivateIP returns the address of the droplet in its VPC.
+func (d *Driver) GetPrivateIP() (string, error) {
+	if d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+
+	droplet, _, err := d.getClient().Droplets.Get(d.DropletID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, network := range droplet.Networks.V4 {
+		if network.Type == "private" {
+			d.PrivateIPAddress = network.IPAddress
+			return d.PrivateIPAddress, nil
+		}
+	}
+
+	return "", fmt.Errorf("No private IP for droplet %d, enable --digitalocean-private-networking", d.DropletID)
+}
+
 func (d *Driver) createSSHKey() (*godo.Key, error) {
 	if d.SSHKeyFingerprint != "" {
 		key, resp, err := d.getClient().Keys.GetByFingerprint(d.SSHKeyFingerprint)
@@ -318,24 +480,38 @@ func (d *Driver) Kill() error {
 	return err
 }
 
+// Remove deletes the Droplet and its SSH key, and the volume and the
+// reserved IP created for it.  The volume is detached first, so that it can
+// be deleted along with the Droplet.
 func (d *Driver) Remove() error {
 	client := d.getClient()
 	if d.SSHKeyFingerprint == "" {
 		if resp, err := client.Keys.DeleteByID(d.SSHKeyID); err != nil {
-			if resp.StatusCode == 404 {
+			if isNotFound(resp) {
 				log.Infof("Digital Ocean SSH key doesn't exist, assuming it is already deleted")
 			} else {
 				return err
 			}
 		}
 	}
+	if err := d.releaseReservedIP(client); err != nil {
+		return err
+	}
+	if d.VolumeID != "" && d.DropletID != 0 {
+		if err := d.detachVolume(client); err != nil {
+			log.Warnf("Error detaching the volume %s: %s", d.VolumeName, err)
+		}
+	}
 	if resp, err := client.Droplets.Delete(d.DropletID); err != nil {
-		if resp.StatusCode == 404 {
+		if isNotFound(resp) {
 			log.Infof("Digital Ocean droplet doesn't exist, assuming it is already deleted")
 		} else {
 			return err
 		}
 	}
+	if d.VolumeID != "" {
+		return d.deleteVolume(client)
+	}
 	return nil
 }
 
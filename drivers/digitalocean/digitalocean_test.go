@@ -82,3 +82,61 @@ func TestSSHKeyFingerprint(t *testing.T) {
 	assert.Equal(t, "64:51:2b:9b:8b:f0:95:3c:f9:36:4d:8b:80:a8:8f:1e", driver.SSHKeyFingerprint)
 	assert.Equal(t, "", driver.GetSSHKeyPath())
 }
+
+func TestSetConfigFromFlagsVolumeAndReservedIP(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"digitalocean-access-token": "TOKEN",
+			"digitalocean-tags":         []string{"web", "prod"},
+			"digitalocean-volume-size":  100,
+			"digitalocean-reserved-ip":  "203.0.113.10",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+
+	assert.Equal(t, []string{"web", "prod"}, driver.Tags)
+	assert.Equal(t, 100, driver.VolumeSize)
+	assert.Equal(t, "203.0.113.10", driver.ReservedIP)
+	assert.True(t, driver.usesReservedIP())
+}
+
+func TestSetConfigFromFlagsReservedIPConflict(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"digitalocean-access-token":       "TOKEN",
+			"digitalocean-reserved-ip":        "203.0.113.10",
+			"digitalocean-create-reserved-ip": true,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+	assert.EqualError(t, err, "--digitalocean-reserved-ip and --digitalocean-create-reserved-ip can't be combined")
+}
+
+func TestSetConfigFromFlagsUseIPv6(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"digitalocean-access-token": "TOKEN",
+			"use-ipv6":                  true,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.NoError(t, driver.SetConfigFromFlags(checkFlags))
+	assert.True(t, driver.UseIPv6)
+	assert.True(t, driver.IPv6)
+
+	checkFlags.FlagsValues["digitalocean-create-reserved-ip"] = true
+	assert.EqualError(t, driver.SetConfigFromFlags(checkFlags), "--use-ipv6 can't be combined with a reserved IP, which is an IPv4 address")
+}
@@ -0,0 +1,156 @@
+package digitalocean
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/digitalocean/godo"
+)
+
+// PlanCreate checks that the account can create one more Droplet, that the
+// region offers its size and that its image and SSH key exist, without
+// creating anything.
+func (d *Driver) PlanCreate() ([]string, error) {
+	client := d.getClient()
+
+	account, _, err := client.Account.Get()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting the account: %s", err)
+	}
+
+	droplets, err := countDroplets(client)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the Droplets: %s", err)
+	}
+
+	regions, _, err := client.Regions.List(&godo.ListOptions{PerPage: 200})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the regions: %s", err)
+	}
+
+	if err := checkDroplet(account, droplets, regions, d.Region, d.Size); err != nil {
+		return nil, err
+	}
+
+	if id, err := strconv.Atoi(d.Image); err == nil {
+		_, _, err = client.Images.GetByID(id)
+	} else {
+		_, _, err = client.Images.GetBySlug(d.Image)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("The image %s can't be found: %s", d.Image, err)
+	}
+
+	if d.SSHKeyFingerprint != "" {
+		if _, _, err := client.Keys.GetByFingerprint(d.SSHKeyFingerprint); err != nil {
+			return nil, fmt.Errorf("Digital Ocean SSH key with fingerprint %s can't be found: %s", d.SSHKeyFingerprint, err)
+		}
+	}
+
+	return d.createSteps(), nil
+}
+
+// countDroplets counts the Droplets of the account.
+func countDroplets(client *godo.Client) (int, error) {
+	count := 0
+	opt := &godo.ListOptions{PerPage: 200}
+
+	for {
+		droplets, resp, err := client.Droplets.List(opt)
+		if err != nil {
+			return 0, err
+		}
+		count += len(droplets)
+
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			return count, nil
+		}
+
+		page, err := resp.Links.CurrentPage()
+		if err != nil {
+			return 0, err
+		}
+		opt.Page = page + 1
+	}
+}
+
+// checkDroplet checks that the account has room for one more Droplet, and
+// that the region is available and offers the size.
+func checkDroplet(account *godo.Account, droplets int, regions []godo.Region, region, size string) error {
+	if account.DropletLimit > 0 && droplets >= account.DropletLimit {
+		return fmt.Errorf("The account has reached its limit of %d Droplets", account.DropletLimit)
+	}
+
+	for _, r := range regions {
+		if r.Slug != region {
+			continue
+		}
+
+		if !r.Available {
+			return fmt.Errorf("The region %s doesn't accept new Droplets", region)
+		}
+
+		for _, s := range r.Sizes {
+			if s == size {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("The size %s isn't available in the region %s", size, region)
+	}
+
+	return fmt.Errorf("digitalocean requires a valid region")
+}
+
+// createSteps describes what Create does with the configuration of the
+// driver.
+func (d *Driver) createSteps() []string {
+	steps := []string{}
+
+	if d.SSHKeyFingerprint == "" {
+		steps = append(steps, fmt.Sprintf("Generate an SSH key and add it to the account as %s", d.MachineName))
+	} else {
+		steps = append(steps, fmt.Sprintf("Use the SSH key %s of the account", d.SSHKeyFingerprint))
+	}
+
+	droplet := fmt.Sprintf("Create the Droplet %s of size %s from the image %s in %s", d.MachineName, d.Size, d.Image, d.Region)
+	if d.IPv6 {
+		droplet += ", with IPv6"
+	}
+	if d.PrivateNetworking {
+		droplet += ", with private networking"
+	}
+	if d.VPCUUID != "" {
+		droplet += " in the VPC " + d.VPCUUID
+	}
+	if len(d.Tags) > 0 {
+		droplet += ", tagged " + strings.Join(d.Tags, ", ")
+	}
+	if d.Backups {
+		droplet += ", with backups"
+	}
+	if d.UserDataFile != "" {
+		droplet += ", with the user data " + d.UserDataFile
+	}
+
+	if d.VolumeSize > 0 {
+		steps = append(steps, fmt.Sprintf("Create the volume %s of %dGB in %s", volumeName(d.MachineName), d.VolumeSize, d.Region))
+		droplet += fmt.Sprintf(", with the volume %s attached", volumeName(d.MachineName))
+	}
+
+	steps = append(steps, droplet, "Wait for the public IP address of the Droplet")
+
+	switch {
+	case d.CreateReservedIP:
+		steps = append(steps, fmt.Sprintf("Reserve an IP in %s and assign it to the Droplet", d.Region))
+	case d.ReservedIP != "":
+		steps = append(steps, fmt.Sprintf("Assign the reserved IP %s to the Droplet", d.ReservedIP))
+	}
+
+	if d.VolumeSize > 0 {
+		steps = append(steps, fmt.Sprintf("Mount the volume %s at %s", volumeName(d.MachineName), volumeMountPoint))
+	}
+
+	return steps
+}
@@ -0,0 +1,60 @@
+package digitalocean
+
+import (
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDroplet(t *testing.T) {
+	regions := []godo.Region{
+		{Slug: "nyc3", Available: true, Sizes: []string{"s-1vcpu-1gb", "s-2vcpu-2gb"}},
+		{Slug: "sfo1", Available: false, Sizes: []string{"s-1vcpu-1gb"}},
+	}
+	account := &godo.Account{DropletLimit: 10}
+
+	assert.NoError(t, checkDroplet(account, 9, regions, "nyc3", "s-2vcpu-2gb"))
+	assert.NoError(t, checkDroplet(&godo.Account{}, 25, regions, "nyc3", "s-2vcpu-2gb"))
+	assert.EqualError(t, checkDroplet(account, 10, regions, "nyc3", "s-2vcpu-2gb"), "The account has reached its limit of 10 Droplets")
+	assert.EqualError(t, checkDroplet(account, 0, regions, "nyc3", "c-32"), "The size c-32 isn't available in the region nyc3")
+	assert.EqualError(t, checkDroplet(account, 0, regions, "sfo1", "s-1vcpu-1gb"), "The region sfo1 doesn't accept new Droplets")
+	assert.EqualError(t, checkDroplet(account, 0, regions, "mars1", "s-1vcpu-1gb"), "digitalocean requires a valid region")
+}
+
+func TestCreateSteps(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.Image = "ubuntu-22-04-x64"
+	driver.Region = "nyc3"
+	driver.Size = "s-1vcpu-1gb"
+	driver.IPv6 = true
+
+	assert.Equal(t, []string{
+		"Generate an SSH key and add it to the account as default",
+		"Create the Droplet default of size s-1vcpu-1gb from the image ubuntu-22-04-x64 in nyc3, with IPv6",
+		"Wait for the public IP address of the Droplet",
+	}, driver.createSteps())
+
+	driver.SSHKeyFingerprint = "aa:bb"
+	assert.Equal(t, "Use the SSH key aa:bb of the account", driver.createSteps()[0])
+}
+
+func TestCreateStepsVolumeAndReservedIP(t *testing.T) {
+	driver := NewDriver("web", "path")
+	driver.Image = "ubuntu-22-04-x64"
+	driver.Region = "nyc3"
+	driver.Size = "s-1vcpu-1gb"
+	driver.VPCUUID = "5a4981aa"
+	driver.Tags = []string{"prod"}
+	driver.VolumeSize = 100
+	driver.CreateReservedIP = true
+
+	assert.Equal(t, []string{
+		"Generate an SSH key and add it to the account as web",
+		"Create the volume machine-web of 100GB in nyc3",
+		"Create the Droplet web of size s-1vcpu-1gb from the image ubuntu-22-04-x64 in nyc3 in the VPC 5a4981aa, tagged prod, with the volume machine-web attached",
+		"Wait for the public IP address of the Droplet",
+		"Reserve an IP in nyc3 and assign it to the Droplet",
+		"Mount the volume machine-web at /var/lib/docker",
+	}, driver.createSteps())
+}
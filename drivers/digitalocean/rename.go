@@ -0,0 +1,14 @@
+package digitalocean
+
+import "github.com/docker/machine/libmachine/log"
+
+// Rename renames the Droplet, which is found by its ID.
+func (d *Driver) Rename(name string) error {
+	action, _, err := d.getClient().DropletActions.Rename(d.DropletID, name)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Waiting for the Droplet to be renamed...")
+	return d.waitForAction(action)
+}
@@ -0,0 +1,61 @@
+package digitalocean
+
+import (
+	"fmt"
+
+	"github.com/digitalocean/godo"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// The reserved IPs were formerly the floating IPs, whose API the vendored
+// godo calls.  The machine is reached through its reserved IP, which is kept
+// when the Droplet is rebuilt.
+
+// usesReservedIP returns true if a reserved IP is assigned to the Droplet.
+func (d *Driver) usesReservedIP() bool {
+	return d.ReservedIP != "" || d.CreateReservedIP
+}
+
+// assignReservedIP assigns the given reserved IP, or a new one reserved in
+// its region, to the Droplet, which must be active.
+func (d *Driver) assignReservedIP(client *godo.Client) error {
+	if d.CreateReservedIP {
+		log.Infof("Reserving an IP in %s...", d.Region)
+		ip, _, err := client.FloatingIPs.Create(&godo.FloatingIPCreateRequest{Region: d.Region})
+		if err != nil {
+			return fmt.Errorf("Error reserving an IP: %s", err)
+		}
+		d.ReservedIP = ip.IP
+	}
+
+	log.Infof("Assigning the reserved IP %s to the Droplet...", d.ReservedIP)
+	action, _, err := client.FloatingIPActions.Assign(d.ReservedIP, d.DropletID)
+	if err != nil {
+		return fmt.Errorf("Error assigning the reserved IP %s: %s", d.ReservedIP, err)
+	}
+	if err := d.waitForAction(action); err != nil {
+		return err
+	}
+
+	d.IPAddress = d.ReservedIP
+	return nil
+}
+
+// releaseReservedIP deletes the IP reserved for the Droplet.  A given
+// reserved IP is kept, and unassigned along with the deletion of the
+// Droplet.
+func (d *Driver) releaseReservedIP(client *godo.Client) error {
+	if !d.CreateReservedIP || d.ReservedIP == "" {
+		return nil
+	}
+
+	if resp, err := client.FloatingIPs.Delete(d.ReservedIP); err != nil {
+		if isNotFound(resp) {
+			log.Infof("Digital Ocean reserved IP doesn't exist, assuming it is already released")
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
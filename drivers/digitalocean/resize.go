@@ -0,0 +1,21 @@
+package digitalocean
+
+import "github.com/docker/machine/libmachine/log"
+
+// Only the CPUs and the memory of the Droplet are resized, its disk is kept
+// as is so that it can be resized down again.
+
+func (d *Driver) Resize(size string) error {
+	action, _, err := d.getClient().DropletActions.Resize(d.DropletID, size, false)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Waiting for the Droplet to be resized...")
+	if err := d.waitForAction(action); err != nil {
+		return err
+	}
+
+	d.Size = size
+	return nil
+}
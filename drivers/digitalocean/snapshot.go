@@ -0,0 +1,129 @@
+package digitalocean
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// The snapshots of a Droplet are images, restored by rebuilding the Droplet
+// from them.  It keeps its IP address.
+
+func (d *Driver) CreateSnapshot(name string) (*drivers.Snapshot, error) {
+	action, _, err := d.getClient().DropletActions.Snapshot(d.DropletID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Waiting for the snapshot to complete...")
+	if err := d.waitForAction(action); err != nil {
+		return nil, err
+	}
+
+	snapshots, err := d.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	return drivers.FindSnapshot(snapshots, name)
+}
+
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	snapshots := []drivers.Snapshot{}
+
+	opt := &godo.ListOptions{}
+	for {
+		images, resp, err := d.getClient().Droplets.Snapshots(d.DropletID, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, image := range images {
+			snapshots = append(snapshots, imageSnapshot(image))
+		}
+
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			return snapshots, nil
+		}
+
+		page, err := resp.Links.CurrentPage()
+		if err != nil {
+			return nil, err
+		}
+		opt.Page = page + 1
+	}
+}
+
+func imageSnapshot(image godo.Image) drivers.Snapshot {
+	snapshot := drivers.Snapshot{
+		ID:   strconv.Itoa(image.ID),
+		Name: image.Name,
+	}
+
+	if created, err := time.Parse(time.RFC3339, image.Created); err == nil {
+		snapshot.Created = created
+	}
+
+	return snapshot
+}
+
+func (d *Driver) RestoreSnapshot(name string) error {
+	imageID, err := d.findSnapshotImage(name)
+	if err != nil {
+		return err
+	}
+
+	action, _, err := d.getClient().DropletActions.Restore(d.DropletID, imageID)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Waiting for the Droplet to be restored...")
+	return d.waitForAction(action)
+}
+
+func (d *Driver) DeleteSnapshot(name string) error {
+	imageID, err := d.findSnapshotImage(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.getClient().Images.Delete(imageID)
+	return err
+}
+
+func (d *Driver) findSnapshotImage(name string) (int, error) {
+	snapshots, err := d.ListSnapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	snapshot, err := drivers.FindSnapshot(snapshots, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(snapshot.ID)
+}
+
+func (d *Driver) waitForAction(action *godo.Action) error {
+	for action.Status == godo.ActionInProgress {
+		time.Sleep(5 * time.Second)
+
+		var err error
+		action, _, err = d.getClient().Actions.Get(action.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if action.Status != godo.ActionCompleted {
+		return fmt.Errorf("The %s action of the Droplet %d ended as %s", action.Type, d.DropletID, action.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package digitalocean
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/digitalocean/godo"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/retry"
+)
+
+// The block storage volume of a Droplet holds /var/lib/docker, so that the
+// images and the containers don't fill the disk of the Droplet.  The
+// vendored godo doesn't know the volumes, whose API is called directly.
+
+const (
+	volumeMountPoint = "/var/lib/docker"
+	volumeFilesystem = "ext4"
+)
+
+type volume struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type volumeCreateRequest struct {
+	Name           string   `json:"name"`
+	Region         string   `json:"region"`
+	SizeGigaBytes  int      `json:"size_gigabytes"`
+	FilesystemType string   `json:"filesystem_type"`
+	Description    string   `json:"description"`
+	Tags           []string `json:"tags,omitempty"`
+}
+
+// volumeName returns the name of the volume of a machine, made of lowercase
+// letters, digits and dashes, and starting with a letter as required by the
+// API.
+func volumeName(machineName string) string {
+	name := "machine-" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, machineName)
+
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// volumeDevice returns the device of an attached volume.
+func volumeDevice(name string) string {
+	return "/dev/disk/by-id/scsi-0DO_Volume_" + name
+}
+
+// createVolume creates the formatted volume of the Droplet, attached when
+// the Droplet is created.
+func (d *Driver) createVolume(client *godo.Client) error {
+	req, err := client.NewRequest("POST", "v2/volumes", &volumeCreateRequest{
+		Name:           volumeName(d.MachineName),
+		Region:         d.Region,
+		SizeGigaBytes:  d.VolumeSize,
+		FilesystemType: volumeFilesystem,
+		Description:    fmt.Sprintf("%s of the machine %s", volumeMountPoint, d.MachineName),
+		Tags:           d.Tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	root := struct {
+		Volume *volume `json:"volume"`
+	}{}
+	if _, err := client.Do(req, &root); err != nil {
+		return fmt.Errorf("Error creating the volume: %s", err)
+	}
+
+	d.VolumeID = root.Volume.ID
+	d.VolumeName = root.Volume.Name
+	return nil
+}
+
+// mountVolumeCommand waits for the device of the volume, then mounts it at
+// /var/lib/docker in place of the mount point the image may have given it
+// under /mnt.
+func mountVolumeCommand(name string) string {
+	device := volumeDevice(name)
+	fstab := fmt.Sprintf("%s %s %s defaults,nofail,discard,noatime 0 2", device, volumeMountPoint, volumeFilesystem)
+
+	return strings.Join([]string{
+		fmt.Sprintf("for i in $(seq 30); do [ -e %s ] && break; sleep 2; done", device),
+		fmt.Sprintf("(! mountpoint -q /mnt/%[1]s || sudo umount /mnt/%[1]s)", name),
+		fmt.Sprintf("sudo sed -i '\\|/mnt/%s|d' /etc/fstab", name),
+		fmt.Sprintf("sudo mkdir -p %s", volumeMountPoint),
+		fmt.Sprintf("(grep -qF ' %s ' /etc/fstab || echo '%s' | sudo tee -a /etc/fstab >/dev/null)", volumeMountPoint, fstab),
+		fmt.Sprintf("(mountpoint -q %[1]s || sudo mount %[1]s)", volumeMountPoint),
+	}, " && ")
+}
+
+// mountVolume mounts the volume before the engine is installed.
+func (d *Driver) mountVolume() error {
+	if err := drivers.WaitForSSH(d); err != nil {
+		return err
+	}
+
+	log.Infof("Mounting the volume %s at %s...", d.VolumeName, volumeMountPoint)
+	if _, err := drivers.RunSSHCommandFromDriver(d, mountVolumeCommand(d.VolumeName)); err != nil {
+		return fmt.Errorf("Error mounting the volume %s: %s", d.VolumeName, err)
+	}
+
+	return nil
+}
+
+// detachVolume detaches the volume from the Droplet so that it can be
+// deleted once the Droplet is.
+func (d *Driver) detachVolume(client *godo.Client) error {
+	req, err := client.NewRequest("POST", fmt.Sprintf("v2/volumes/%s/actions", d.VolumeID), &godo.ActionRequest{
+		"type":       "detach",
+		"droplet_id": d.DropletID,
+		"region":     d.Region,
+	})
+	if err != nil {
+		return err
+	}
+
+	root := struct {
+		Action *godo.Action `json:"action"`
+	}{}
+	resp, err := client.Do(req, &root)
+	if err != nil {
+		if isNotFound(resp) {
+			return nil
+		}
+		return err
+	}
+
+	log.Info("Waiting for the volume to be detached...")
+	return d.waitForAction(root.Action)
+}
+
+// deleteVolume deletes the volume, unless it's already deleted.  It's
+// retried while the Droplet it's still attached to is being destroyed.
+func (d *Driver) deleteVolume(client *godo.Client) error {
+	return retry.For(retry.Instance).Do(func() error {
+		req, err := client.NewRequest("DELETE", fmt.Sprintf("v2/volumes/%s", d.VolumeID), nil)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+
+		if resp, err := client.Do(req, nil); err != nil {
+			if isNotFound(resp) {
+				log.Infof("Digital Ocean volume doesn't exist, assuming it is already deleted")
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+func isNotFound(resp *godo.Response) bool {
+	return resp != nil && resp.StatusCode == 404
+}
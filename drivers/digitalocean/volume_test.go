@@ -0,0 +1,22 @@
+package digitalocean
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeName(t *testing.T) {
+	assert.Equal(t, "machine-web-1", volumeName("web-1"))
+	assert.Equal(t, "machine-web-example-com", volumeName("Web.example.com"))
+	assert.Len(t, volumeName(string(make([]byte, 100))), 64)
+}
+
+func TestMountVolumeCommand(t *testing.T) {
+	command := mountVolumeCommand("machine-web")
+
+	assert.Contains(t, command, "[ -e /dev/disk/by-id/scsi-0DO_Volume_machine-web ]")
+	assert.Contains(t, command, "sudo umount /mnt/machine-web")
+	assert.Contains(t, command, "echo '/dev/disk/by-id/scsi-0DO_Volume_machine-web /var/lib/docker ext4 defaults,nofail,discard,noatime 0 2' | sudo tee -a /etc/fstab")
+	assert.Contains(t, command, "(mountpoint -q /var/lib/docker || sudo mount /var/lib/docker)")
+}
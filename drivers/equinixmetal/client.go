@@ -0,0 +1,196 @@
+package equinixmetal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAPIEndpoint = "https://api.equinix.com/metal/v1"
+)
+
+// Client is a minimal client for the Equinix Metal API, covering what the
+// driver needs to manage a device.
+type Client struct {
+	AuthToken string
+	Endpoint  string
+	http      *http.Client
+}
+
+type APIError struct {
+	StatusCode int
+	Errors     []string `json:"errors"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("equinix metal API error (%d): %s", e.StatusCode, strings.Join(e.Errors, ", "))
+}
+
+type IPAddress struct {
+	Address       string `json:"address"`
+	AddressFamily int    `json:"address_family"`
+	Public        bool   `json:"public"`
+	Management    bool   `json:"management"`
+}
+
+type Port struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type Device struct {
+	ID                     string      `json:"id"`
+	Hostname               string      `json:"hostname"`
+	State                  string      `json:"state"`
+	ProvisioningPercentage float64     `json:"provisioning_percentage"`
+	IPAddresses            []IPAddress `json:"ip_addresses"`
+	NetworkPorts           []Port      `json:"network_ports"`
+}
+
+type DeviceCreateRequest struct {
+	Hostname              string   `json:"hostname"`
+	Metro                 string   `json:"metro"`
+	Plan                  string   `json:"plan"`
+	OperatingSystem       string   `json:"operating_system"`
+	BillingCycle          string   `json:"billing_cycle"`
+	HardwareReservationID string   `json:"hardware_reservation_id,omitempty"`
+	SpotInstance          bool     `json:"spot_instance,omitempty"`
+	SpotPriceMax          float64  `json:"spot_price_max,omitempty"`
+	IPXEScriptURL         string   `json:"ipxe_script_url,omitempty"`
+	AlwaysPXE             bool     `json:"always_pxe,omitempty"`
+	ProjectSSHKeys        []string `json:"project_ssh_keys,omitempty"`
+	Tags                  []string `json:"tags,omitempty"`
+	UserData              string   `json:"userdata,omitempty"`
+}
+
+type SSHKey struct {
+	ID    string `json:"id,omitempty"`
+	Label string `json:"label"`
+	Key   string `json:"key"`
+}
+
+type Metro struct {
+	Code string `json:"code"`
+}
+
+func NewClient(authToken string) *Client {
+	return &Client{
+		AuthToken: authToken,
+		Endpoint:  defaultAPIEndpoint,
+		http:      &http.Client{},
+	}
+}
+
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.Endpoint+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", c.AuthToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.Unmarshal(respBody, apiErr)
+		return apiErr
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// IsNotFound returns true if err is an API error for a missing resource.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) CreateDevice(projectID string, createRequest *DeviceCreateRequest) (*Device, error) {
+	device := &Device{}
+	if err := c.do("POST", fmt.Sprintf("/projects/%s/devices", projectID), createRequest, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func (c *Client) GetDevice(id string) (*Device, error) {
+	device := &Device{}
+	if err := c.do("GET", "/devices/"+id, nil, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func (c *Client) DeleteDevice(id string) error {
+	return c.do("DELETE", "/devices/"+id, nil, nil)
+}
+
+// DeviceAction runs an action such as power_on, power_off or reboot on a
+// device.
+func (c *Client) DeviceAction(id, action string) error {
+	body := map[string]string{"type": action}
+	return c.do("POST", fmt.Sprintf("/devices/%s/actions", id), body, nil)
+}
+
+// AssignIP assigns an elastic IP address of the project, in CIDR notation, to
+// a device.
+func (c *Client) AssignIP(deviceID, address string) error {
+	body := map[string]string{"address": address}
+	return c.do("POST", fmt.Sprintf("/devices/%s/ips", deviceID), body, nil)
+}
+
+// AssignVLAN attaches a VLAN of the project to a port of a device.
+func (c *Client) AssignVLAN(portID, vlanID string) error {
+	body := map[string]string{"vnid": vlanID}
+	return c.do("POST", fmt.Sprintf("/ports/%s/assign", portID), body, nil)
+}
+
+func (c *Client) CreateProjectSSHKey(projectID, label, publicKey string) (*SSHKey, error) {
+	key := &SSHKey{}
+	if err := c.do("POST", fmt.Sprintf("/projects/%s/ssh-keys", projectID), &SSHKey{Label: label, Key: publicKey}, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (c *Client) DeleteSSHKey(id string) error {
+	return c.do("DELETE", "/ssh-keys/"+id, nil, nil)
+}
+
+// ListMetros returns the metros devices can be created in.
+func (c *Client) ListMetros() ([]Metro, error) {
+	var resp struct {
+		Metros []Metro `json:"metros"`
+	}
+	if err := c.do("GET", "/locations/metros", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Metros, nil
+}
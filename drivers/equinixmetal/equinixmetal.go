@@ -0,0 +1,553 @@
+package equinixmetal
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	AuthToken             string
+	ProjectID             string
+	DeviceID              string
+	Metro                 string
+	Plan                  string
+	OperatingSystem       string
+	BillingCycle          string
+	HardwareReservationID string
+	SpotInstance          bool
+	SpotPriceMax          float64
+	IPXEScriptURL         string
+	AlwaysPXE             bool
+	VLANIDs               []string
+	ElasticIP             string
+	ProjectSSHKeyIDs      []string
+	UsePrivateAddress     bool
+	PrivateIPAddress      string
+	Tags                  []string
+	SSHKeyID              string
+	UserDataFile          string
+	client                *Client
+}
+
+const (
+	defaultSSHPort         = 22
+	defaultSSHUser         = "root"
+	defaultMetro           = "sv"
+	defaultPlan            = "c3.small.x86"
+	defaultOperatingSystem = "ubuntu_22_04"
+	defaultBillingCycle    = "hourly"
+	// customIPXE is the operating system of the devices booted from an
+	// iPXE script
+	customIPXE = "custom_ipxe"
+	// bondPort is the port of the devices the VLANs are attached to
+	bondPort = "bond0"
+)
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "METAL_AUTH_TOKEN",
+			Name:   "equinixmetal-auth-token",
+			Usage:  "Equinix Metal API token",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_PROJECT_ID",
+			Name:   "equinixmetal-project-id",
+			Usage:  "Equinix Metal project to create the device in",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_METRO",
+			Name:   "equinixmetal-metro",
+			Usage:  "Equinix Metal metro",
+			Value:  defaultMetro,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_PLAN",
+			Name:   "equinixmetal-plan",
+			Usage:  "Equinix Metal plan",
+			Value:  defaultPlan,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_OS",
+			Name:   "equinixmetal-os",
+			Usage:  "Equinix Metal operating system",
+			Value:  defaultOperatingSystem,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_BILLING_CYCLE",
+			Name:   "equinixmetal-billing-cycle",
+			Usage:  "Equinix Metal billing cycle, hourly or monthly",
+			Value:  defaultBillingCycle,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_HARDWARE_RESERVATION_ID",
+			Name:   "equinixmetal-hardware-reservation-id",
+			Usage:  "Reserved hardware to create the device on, or next-available",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "METAL_SPOT_INSTANCE",
+			Name:   "equinixmetal-spot-instance",
+			Usage:  "Create the device on the spot market",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_SPOT_PRICE_MAX",
+			Name:   "equinixmetal-spot-price-max",
+			Usage:  "Maximum hourly price to bid for a spot device, in USD",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_IPXE_SCRIPT_URL",
+			Name:   "equinixmetal-ipxe-script-url",
+			Usage:  "URL of the iPXE script to boot the device with, instead of an operating system",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "METAL_ALWAYS_PXE",
+			Name:   "equinixmetal-always-pxe",
+			Usage:  "Boot the device with the iPXE script on every boot",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "METAL_VLAN_IDS",
+			Name:   "equinixmetal-vlan-ids",
+			Usage:  "VLANs to attach the device to",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_ELASTIC_IP",
+			Name:   "equinixmetal-elastic-ip",
+			Usage:  "Elastic IP address of the project to assign to the device, in CIDR notation",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "METAL_PROJECT_SSH_KEY_IDS",
+			Name:   "equinixmetal-project-ssh-key-ids",
+			Usage:  "Project SSH keys to authorize on the device besides the one of the machine",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "METAL_USE_PRIVATE_ADDRESS",
+			Name:   "equinixmetal-use-private-address",
+			Usage:  "Use the private IP address to communicate with the device",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "METAL_TAGS",
+			Name:   "equinixmetal-tags",
+			Usage:  "Tags of the device",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_USER_DATA",
+			Name:   "equinixmetal-user-data",
+			Usage:  "path to file with cloud-init user-data",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_SSH_USER",
+			Name:   "equinixmetal-ssh-user",
+			Usage:  "SSH username",
+			Value:  defaultSSHUser,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "METAL_SSH_PORT",
+			Name:   "equinixmetal-ssh-port",
+			Usage:  "SSH port",
+			Value:  defaultSSHPort,
+		},
+	}
+}
+
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Metro:           defaultMetro,
+		Plan:            defaultPlan,
+		OperatingSystem: defaultOperatingSystem,
+		BillingCycle:    defaultBillingCycle,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "equinixmetal"
+}
+
+// Capabilities returns the features of the driver, including spot instances and user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.Spot = true
+	capabilities.UserData = true
+	return capabilities
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.AuthToken = flags.String("equinixmetal-auth-token")
+	d.ProjectID = flags.String("equinixmetal-project-id")
+	d.Metro = flags.String("equinixmetal-metro")
+	d.Plan = flags.String("equinixmetal-plan")
+	d.OperatingSystem = flags.String("equinixmetal-os")
+	d.BillingCycle = flags.String("equinixmetal-billing-cycle")
+	d.HardwareReservationID = flags.String("equinixmetal-hardware-reservation-id")
+	d.SpotInstance = flags.Bool("equinixmetal-spot-instance")
+	d.IPXEScriptURL = flags.String("equinixmetal-ipxe-script-url")
+	d.AlwaysPXE = flags.Bool("equinixmetal-always-pxe")
+	d.VLANIDs = flags.StringSlice("equinixmetal-vlan-ids")
+	d.ElasticIP = flags.String("equinixmetal-elastic-ip")
+	d.ProjectSSHKeyIDs = flags.StringSlice("equinixmetal-project-ssh-key-ids")
+	d.UsePrivateAddress = flags.Bool("equinixmetal-use-private-address")
+	d.Tags = flags.StringSlice("equinixmetal-tags")
+	d.UserDataFile = flags.String("equinixmetal-user-data")
+	d.SSHUser = flags.String("equinixmetal-ssh-user")
+	d.SSHPort = flags.Int("equinixmetal-ssh-port")
+	d.SetSwarmConfigFromFlags(flags)
+
+	if d.AuthToken == "" {
+		return fmt.Errorf("equinixmetal driver requires the --equinixmetal-auth-token option")
+	}
+
+	if d.ProjectID == "" {
+		return fmt.Errorf("equinixmetal driver requires the --equinixmetal-project-id option")
+	}
+
+	if spotPriceMax := flags.String("equinixmetal-spot-price-max"); spotPriceMax != "" {
+		if !d.SpotInstance {
+			return fmt.Errorf("equinixmetal driver requires --equinixmetal-spot-instance to bid a maximum price")
+		}
+
+		price, err := strconv.ParseFloat(spotPriceMax, 64)
+		if err != nil || price <= 0 {
+			return fmt.Errorf("equinixmetal spot price %q is not a positive price", spotPriceMax)
+		}
+		d.SpotPriceMax = price
+	}
+
+	if d.SpotInstance && d.HardwareReservationID != "" {
+		return fmt.Errorf("equinixmetal driver can't create a spot device on reserved hardware")
+	}
+
+	if d.IPXEScriptURL != "" {
+		d.OperatingSystem = customIPXE
+	} else if d.OperatingSystem == customIPXE {
+		return fmt.Errorf("equinixmetal driver requires --equinixmetal-ipxe-script-url for the %s operating system", customIPXE)
+	} else if d.AlwaysPXE {
+		return fmt.Errorf("equinixmetal driver requires --equinixmetal-ipxe-script-url to always boot with iPXE")
+	}
+
+	if d.ElasticIP != "" {
+		if _, _, err := net.ParseCIDR(d.ElasticIP); err != nil {
+			return fmt.Errorf("equinixmetal elastic IP %q is not in CIDR notation, e.g. 147.75.1.2/32", d.ElasticIP)
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
+	metros, err := d.getClient().ListMetros()
+	if err != nil {
+		return err
+	}
+
+	for _, metro := range metros {
+		if metro.Code == d.Metro {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("equinixmetal metro %q is not valid", d.Metro)
+}
+
+func (d *Driver) Create() error {
+	return d.CreateContext(context.Background(), func(message string) {
+		log.Info(message)
+	})
+}
+
+// CreateContext creates the device like Create.  If ctx is canceled before
+// the device is active, what was already created is removed.
+func (d *Driver) CreateContext(ctx context.Context, progress func(string)) error {
+	var userdata string
+	if d.UserDataFile != "" {
+		buf, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		userdata = string(buf)
+	}
+
+	progress("Creating SSH key...")
+
+	if err := d.createSSHKey(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return d.cancelCreate(err)
+	}
+
+	progress("Creating Equinix Metal device...")
+
+	createRequest := &DeviceCreateRequest{
+		Hostname:              d.MachineName,
+		Metro:                 d.Metro,
+		Plan:                  d.Plan,
+		OperatingSystem:       d.OperatingSystem,
+		BillingCycle:          d.BillingCycle,
+		HardwareReservationID: d.HardwareReservationID,
+		SpotInstance:          d.SpotInstance,
+		SpotPriceMax:          d.SpotPriceMax,
+		IPXEScriptURL:         d.IPXEScriptURL,
+		AlwaysPXE:             d.AlwaysPXE,
+		ProjectSSHKeys:        append([]string{d.SSHKeyID}, d.ProjectSSHKeyIDs...),
+		Tags:                  d.Tags,
+		UserData:              userdata,
+	}
+
+	device, err := d.getClient().CreateDevice(d.ProjectID, createRequest)
+	if err != nil {
+		return err
+	}
+
+	d.DeviceID = device.ID
+
+	// bare metal takes several minutes to be provisioned
+	progress("Waiting for the device to be provisioned...")
+	if err := mcnutils.WaitForSpecificOrError(func() (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		return d.deviceIsActive(ctx)
+	}, 180, 10*time.Second); err != nil {
+		if ctx.Err() != nil {
+			return d.cancelCreate(err)
+		}
+		return fmt.Errorf("equinixmetal device %s is not active: %s", d.DeviceID, err)
+	}
+
+	if d.ElasticIP != "" {
+		progress("Assigning the elastic IP address to the device...")
+		if err := d.getClient().AssignIP(d.DeviceID, d.ElasticIP); err != nil {
+			return err
+		}
+	}
+
+	if len(d.VLANIDs) > 0 {
+		progress("Attaching the device to the VLANs...")
+		if err := d.attachVLANs(); err != nil {
+			return err
+		}
+	}
+
+	log.Debugf("Created device ID %s, IP address %s, private IP address %s",
+		d.DeviceID,
+		d.IPAddress,
+		d.PrivateIPAddress)
+
+	return nil
+}
+
+// cancelCreate removes what the canceled creation already created and returns
+// the error of the cancellation.
+func (d *Driver) cancelCreate(err error) error {
+	log.Info("Creation canceled, removing the SSH key and the device...")
+	if removeErr := d.Remove(); removeErr != nil {
+		log.Warnf("Error removing the canceled device: %s", removeErr)
+	}
+
+	return err
+}
+
+// deviceIsActive refreshes the addresses of the device, reports the progress of
+// its provisioning, and returns whether it finished provisioning.
+func (d *Driver) deviceIsActive(ctx context.Context) (bool, error) {
+	device, err := d.getClient().GetDevice(d.DeviceID)
+	if err != nil {
+		log.Debugf("Error getting device %s: %s", d.DeviceID, err)
+		return false, nil
+	}
+
+	if device.State == "failed" {
+		return false, fmt.Errorf("equinixmetal device %s failed to provision", d.DeviceID)
+	}
+
+	drivers.ReportProgress(ctx, "Provisioning the device", int(device.ProvisioningPercentage))
+
+	d.setAddresses(device)
+
+	return device.State == "active" && d.IPAddress != "", nil
+}
+
+// setAddresses keeps the public and private IPv4 management addresses of the
+// device.
+func (d *Driver) setAddresses(device *Device) {
+	for _, address := range device.IPAddresses {
+		if address.AddressFamily != 4 || !address.Management {
+			continue
+		}
+
+		if address.Public {
+			d.IPAddress = address.Address
+		} else {
+			d.PrivateIPAddress = address.Address
+		}
+	}
+}
+
+// attachVLANs attaches the VLANs to the bonded port of the device, which keeps
+// its layer 3 addresses.
+func (d *Driver) attachVLANs() error {
+	device, err := d.getClient().GetDevice(d.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	portID := ""
+	for _, port := range device.NetworkPorts {
+		if port.Name == bondPort {
+			portID = port.ID
+		}
+	}
+
+	if portID == "" {
+		return fmt.Errorf("equinixmetal device %s has no %s port to attach the VLANs to", d.DeviceID, bondPort)
+	}
+
+	for _, vlanID := range d.VLANIDs {
+		if err := d.getClient().AssignVLAN(portID, vlanID); err != nil {
+			return fmt.Errorf("Error attaching the device to VLAN %s: %s", vlanID, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) createSSHKey() error {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	key, err := d.getClient().CreateProjectSSHKey(d.ProjectID, d.MachineName, string(publicKey))
+	if err != nil {
+		return err
+	}
+
+	d.SSHKeyID = key.ID
+
+	return nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	if d.UsePrivateAddress && d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+	return d.BaseDriver.GetIP()
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	device, err := d.getClient().GetDevice(d.DeviceID)
+	if err != nil {
+		return state.Error, err
+	}
+	switch device.State {
+	case "queued", "provisioning", "powering_on", "reinstalling":
+		return state.Starting, nil
+	case "active":
+		return state.Running, nil
+	case "powering_off":
+		return state.Stopping, nil
+	case "inactive":
+		return state.Stopped, nil
+	case "failed":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) Start() error {
+	return d.getClient().DeviceAction(d.DeviceID, "power_on")
+}
+
+func (d *Driver) Stop() error {
+	return d.getClient().DeviceAction(d.DeviceID, "power_off")
+}
+
+func (d *Driver) Restart() error {
+	return d.getClient().DeviceAction(d.DeviceID, "reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.getClient().DeviceAction(d.DeviceID, "power_off")
+}
+
+func (d *Driver) Remove() error {
+	client := d.getClient()
+	if d.SSHKeyID != "" {
+		if err := client.DeleteSSHKey(d.SSHKeyID); err != nil {
+			if IsNotFound(err) {
+				log.Infof("Equinix Metal SSH key doesn't exist, assuming it is already deleted")
+			} else {
+				return err
+			}
+		}
+	}
+	if d.DeviceID == "" {
+		return nil
+	}
+	if err := client.DeleteDevice(d.DeviceID); err != nil {
+		if IsNotFound(err) {
+			log.Infof("Equinix Metal device doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) getClient() *Client {
+	if d.client == nil {
+		d.client = NewClient(d.AuthToken)
+	}
+	return d.client
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
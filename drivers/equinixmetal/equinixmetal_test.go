@@ -0,0 +1,190 @@
+package equinixmetal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"equinixmetal-auth-token":     "TOKEN",
+			"equinixmetal-project-id":     "proj-1",
+			"equinixmetal-spot-instance":  true,
+			"equinixmetal-spot-price-max": "0.45",
+			"equinixmetal-vlan-ids":       []string{"vlan-1"},
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, 0.45, driver.SpotPriceMax)
+	assert.Equal(t, []string{"vlan-1"}, driver.VLANIDs)
+	assert.Equal(t, defaultPlan, driver.Plan)
+	assert.Equal(t, defaultOperatingSystem, driver.OperatingSystem)
+	assert.Equal(t, driver.ResolveStorePath("id_rsa"), driver.GetSSHKeyPath())
+}
+
+func TestSetConfigFromFlagsRequiresProject(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"equinixmetal-auth-token": "TOKEN",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.EqualError(t, driver.SetConfigFromFlags(checkFlags), "equinixmetal driver requires the --equinixmetal-project-id option")
+}
+
+func TestSpotPriceRequiresSpotInstance(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"equinixmetal-auth-token":     "TOKEN",
+			"equinixmetal-project-id":     "proj-1",
+			"equinixmetal-spot-price-max": "0.45",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestIPXEScriptSetsCustomOS(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"equinixmetal-auth-token":      "TOKEN",
+			"equinixmetal-project-id":      "proj-1",
+			"equinixmetal-ipxe-script-url": "https://boot.example.com/ipxe",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.NoError(t, driver.SetConfigFromFlags(checkFlags))
+	assert.Equal(t, customIPXE, driver.OperatingSystem)
+}
+
+func TestCreate(t *testing.T) {
+	var createRequest map[string]interface{}
+	var vlanRequest, ipRequest map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "TOKEN", r.Header.Get("X-Auth-Token"))
+		switch r.URL.Path {
+		case "/projects/proj-1/ssh-keys":
+			w.Write([]byte(`{"id":"key-1"}`))
+		case "/projects/proj-1/devices":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&createRequest))
+			w.Write([]byte(`{"id":"dev-1","state":"queued"}`))
+		case "/devices/dev-1":
+			w.Write([]byte(`{"id":"dev-1","state":"active","ip_addresses":[` +
+				`{"address":"147.75.1.2","address_family":4,"public":true,"management":true},` +
+				`{"address":"2604:1380::1","address_family":6,"public":true,"management":true},` +
+				`{"address":"10.67.1.2","address_family":4,"public":false,"management":true}],` +
+				`"network_ports":[{"id":"port-eth0","name":"eth0"},{"id":"port-bond0","name":"bond0"}]}`))
+		case "/devices/dev-1/ips":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&ipRequest))
+		case "/ports/port-bond0/assign":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&vlanRequest))
+		}
+	}))
+	defer server.Close()
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.AuthToken = "TOKEN"
+	driver.ProjectID = "proj-1"
+	driver.HardwareReservationID = "next-available"
+	driver.ProjectSSHKeyIDs = []string{"key-ops"}
+	driver.ElasticIP = "147.75.9.9/32"
+	driver.VLANIDs = []string{"vlan-1"}
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Create())
+	assert.Equal(t, "dev-1", driver.DeviceID)
+	assert.Equal(t, "147.75.1.2", driver.IPAddress)
+	assert.Equal(t, "10.67.1.2", driver.PrivateIPAddress)
+	assert.Equal(t, "next-available", createRequest["hardware_reservation_id"])
+	assert.Equal(t, []interface{}{"key-1", "key-ops"}, createRequest["project_ssh_keys"])
+	assert.Nil(t, createRequest["spot_instance"])
+	assert.Equal(t, map[string]string{"address": "147.75.9.9/32"}, ipRequest)
+	assert.Equal(t, map[string]string{"vnid": "vlan-1"}, vlanRequest)
+}
+
+func TestCreateFailedDevice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/proj-1/ssh-keys":
+			w.Write([]byte(`{"id":"key-1"}`))
+		case "/projects/proj-1/devices":
+			w.Write([]byte(`{"id":"dev-1","state":"queued"}`))
+		case "/devices/dev-1":
+			w.Write([]byte(`{"id":"dev-1","state":"failed"}`))
+		}
+	}))
+	defer server.Close()
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.ProjectID = "proj-1"
+	driver.getClient().Endpoint = server.URL
+
+	assert.EqualError(t, driver.Create(), "equinixmetal device dev-1 is not active: equinixmetal device dev-1 failed to provision")
+}
+
+func TestGetState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"dev-1","state":"inactive"}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.DeviceID = "dev-1"
+	driver.getClient().Endpoint = server.URL
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+}
+
+func TestRemoveIgnoresMissingDevice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":["Not found"]}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.DeviceID = "dev-1"
+	driver.SSHKeyID = "key-1"
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Remove())
+}
@@ -133,6 +133,13 @@ func (d *Driver) DriverName() string {
 	return "exoscale"
 }
 
+// Capabilities returns the features of the driver, including user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.UserData = true
+	return capabilities
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.URL = flags.String("exoscale-url")
 	d.APIKey = flags.String("exoscale-api-key")
@@ -0,0 +1,173 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	ErrFirecrackerNotFound = errors.New("firecracker not found. Install it from https://github.com/firecracker-microvm/firecracker/releases or give its path with --firecracker-path")
+)
+
+// startFirecracker starts firecracker in the background and returns its
+// PID.  It's replaced by the tests.
+var startFirecracker = func(path string, args []string, logPath string) (int, error) {
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer logFile.Close()
+
+	// the serial console of the guest is the output of firecracker
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+
+	log.Debugf("COMMAND: %s %s", path, strings.Join(args, " "))
+	if err := cmd.Start(); err != nil {
+		if ee, ok := err.(*exec.Error); ok && ee.Err == exec.ErrNotFound {
+			return 0, ErrFirecrackerNotFound
+		}
+		return 0, err
+	}
+
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+// vmConfig is the configuration firecracker boots the microVM with, in the
+// format of its --config-file.
+type vmConfig struct {
+	BootSource        bootSource         `json:"boot-source"`
+	Drives            []drive            `json:"drives"`
+	MachineConfig     machineConfig      `json:"machine-config"`
+	NetworkInterfaces []networkInterface `json:"network-interfaces"`
+}
+
+type bootSource struct {
+	KernelImagePath string `json:"kernel_image_path"`
+	BootArgs        string `json:"boot_args"`
+}
+
+type drive struct {
+	DriveID      string `json:"drive_id"`
+	PathOnHost   string `json:"path_on_host"`
+	IsRootDevice bool   `json:"is_root_device"`
+	IsReadOnly   bool   `json:"is_read_only"`
+}
+
+type machineConfig struct {
+	VCPUCount  int `json:"vcpu_count"`
+	MemSizeMiB int `json:"mem_size_mib"`
+}
+
+type networkInterface struct {
+	IfaceID     string `json:"iface_id"`
+	GuestMAC    string `json:"guest_mac"`
+	HostDevName string `json:"host_dev_name"`
+}
+
+// instanceInfo is the description of the microVM returned by the API.
+type instanceInfo struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+}
+
+// apiClient talks to the API firecracker serves on a unix socket.
+type apiClient struct {
+	socket string
+	http   *http.Client
+}
+
+func newAPIClient(socket string) *apiClient {
+	return &apiClient{
+		socket: socket,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// isRunning returns false if firecracker doesn't listen on the socket, i.e.
+// the microVM is stopped.
+func (c *apiClient) isRunning() bool {
+	conn, err := net.Dial("unix", c.socket)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// getState returns the state of the microVM, e.g. Running.
+func (c *apiClient) getState() (string, error) {
+	resp, err := c.http.Get("http://firecracker/")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", apiError(resp)
+	}
+
+	info := &instanceInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return "", err
+	}
+	return info.State, nil
+}
+
+// sendAction sends an action to the microVM, e.g. SendCtrlAltDel.
+func (c *apiClient) sendAction(action string) error {
+	buf, err := json.Marshal(map[string]string{"action_type": action})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", "http://firecracker/actions", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return apiError(resp)
+	}
+	return nil
+}
+
+func apiError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	var fault struct {
+		Message string `json:"fault_message"`
+	}
+	if json.Unmarshal(body, &fault) == nil && fault.Message != "" {
+		return fmt.Errorf("firecracker answered %s: %s", resp.Status, fault.Message)
+	}
+	return fmt.Errorf("firecracker answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
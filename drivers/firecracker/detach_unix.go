@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package firecracker
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts firecracker in its own session, so that it outlives the
+// command which started the microVM and doesn't get its signals.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,498 @@
+package firecracker
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	FirecrackerPath string
+	KernelURL       string
+	RootfsURL       string
+	DiskSize        int
+	Memory          int
+	CPU             int
+	Bridge          string
+	Subnet          string
+	DNS             string
+	MACAddress      string
+}
+
+const (
+	defaultFirecrackerPath = "firecracker"
+	defaultDiskSize        = 20000
+	defaultMemory          = 1024
+	defaultCPU             = 1
+	defaultBridge          = "fcbr0"
+	defaultSubnet          = "172.30.128.0/24"
+	defaultDNS             = "8.8.8.8"
+)
+
+// NewDriver creates a new Firecracker driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		FirecrackerPath: defaultFirecrackerPath,
+		KernelURL:       defaultKernelURL,
+		RootfsURL:       defaultRootfsURL,
+		DiskSize:        defaultDiskSize,
+		Memory:          defaultMemory,
+		CPU:             defaultCPU,
+		Bridge:          defaultBridge,
+		Subnet:          defaultSubnet,
+		DNS:             defaultDNS,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+			SSHUser:     "root",
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			Name:   "firecracker-path",
+			Usage:  "Path of the firecracker command",
+			Value:  defaultFirecrackerPath,
+			EnvVar: "FIRECRACKER_PATH",
+		},
+		mcnflag.StringFlag{
+			Name:   "firecracker-kernel-url",
+			Usage:  "URL of the uncompressed Linux kernel the microVM boots",
+			Value:  defaultKernelURL,
+			EnvVar: "FIRECRACKER_KERNEL_URL",
+		},
+		mcnflag.StringFlag{
+			Name:   "firecracker-rootfs-url",
+			Usage:  "URL of the ext4 image of the root file system of the microVM, with an SSH server",
+			Value:  defaultRootfsURL,
+			EnvVar: "FIRECRACKER_ROOTFS_URL",
+		},
+		mcnflag.IntFlag{
+			Name:   "firecracker-disk-size",
+			Usage:  "Size of the root file system of the host in MB.",
+			Value:  defaultDiskSize,
+			EnvVar: "FIRECRACKER_DISK_SIZE",
+		},
+		mcnflag.IntFlag{
+			Name:   "firecracker-memory",
+			Usage:  "Memory size for host in MB.",
+			Value:  defaultMemory,
+			EnvVar: "FIRECRACKER_MEMORY",
+		},
+		mcnflag.IntFlag{
+			Name:   "firecracker-cpu-count",
+			Usage:  "number of CPUs for the machine",
+			Value:  defaultCPU,
+			EnvVar: "FIRECRACKER_CPU_COUNT",
+		},
+		mcnflag.StringFlag{
+			Name:   "firecracker-bridge",
+			Usage:  "Bridge of the host the tap devices of the microVMs are attached to, created unless it exists",
+			Value:  defaultBridge,
+			EnvVar: "FIRECRACKER_BRIDGE",
+		},
+		mcnflag.StringFlag{
+			Name:   "firecracker-subnet",
+			Usage:  "Subnet of the bridge, whose first address is the host, the microVMs getting the next ones",
+			Value:  defaultSubnet,
+			EnvVar: "FIRECRACKER_SUBNET",
+		},
+		mcnflag.StringFlag{
+			Name:   "firecracker-dns",
+			Usage:  "DNS server of the microVM",
+			Value:  defaultDNS,
+			EnvVar: "FIRECRACKER_DNS",
+		},
+	}
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.FirecrackerPath = flags.String("firecracker-path")
+	d.KernelURL = flags.String("firecracker-kernel-url")
+	d.RootfsURL = flags.String("firecracker-rootfs-url")
+	d.DiskSize = flags.Int("firecracker-disk-size")
+	d.Memory = flags.Int("firecracker-memory")
+	d.CPU = flags.Int("firecracker-cpu-count")
+	d.Bridge = flags.String("firecracker-bridge")
+	d.Subnet = flags.String("firecracker-subnet")
+	d.DNS = flags.String("firecracker-dns")
+	d.SSHUser = "root"
+	d.SetSwarmConfigFromFlags(flags)
+
+	if d.KernelURL == "" || d.RootfsURL == "" {
+		return errors.New("firecracker driver requires the --firecracker-kernel-url and --firecracker-rootfs-url options")
+	}
+
+	if len(d.Bridge) > 15 {
+		return fmt.Errorf("Invalid bridge %q, the name of a Linux interface has at most 15 characters", d.Bridge)
+	}
+
+	if _, err := parseSubnet(d.Subnet); err != nil {
+		return err
+	}
+
+	if net.ParseIP(d.DNS) == nil {
+		return fmt.Errorf("Invalid DNS server %q, expected an IP address", d.DNS)
+	}
+
+	return nil
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "firecracker"
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	if ip == "" {
+		return "", nil
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	client := d.getClient()
+	if !client.isRunning() {
+		return state.Stopped, nil
+	}
+
+	s, err := client.getState()
+	if err != nil {
+		return state.Error, err
+	}
+
+	switch s {
+	case "Running":
+		return state.Running, nil
+	case "Paused":
+		return state.Paused, nil
+	case "Not started":
+		return state.Starting, nil
+	}
+	return state.None, nil
+}
+
+// PreCreateCheck checks that the host is a Linux with KVM, firecracker and
+// the e2fsprogs, and downloads the kernel and the root file system.
+func (d *Driver) PreCreateCheck() error {
+	log.Warn("The firecracker driver is experimental")
+
+	if runtime.GOOS != "linux" {
+		return errors.New("The firecracker driver requires Linux with KVM")
+	}
+
+	kvm, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("The firecracker driver requires read and write access to /dev/kvm, e.g. by being in the kvm group: %s", err)
+	}
+	kvm.Close()
+
+	if _, err := exec.LookPath(d.FirecrackerPath); err != nil {
+		return ErrFirecrackerNotFound
+	}
+
+	for _, command := range []string{"debugfs", "e2fsck", "resize2fs"} {
+		if _, err := exec.LookPath(command); err != nil {
+			return fmt.Errorf("%s not found. Make sure e2fsprogs is installed and %s is in the path", command, command)
+		}
+	}
+
+	// Downloading the images to cache should be done here to make sure
+	// that a download failure will not leave a machine half created.
+	for _, url := range []string{d.KernelURL, d.RootfsURL} {
+		if err := d.updateCache(url); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	log.Infof("Creating SSH key...")
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	log.Infof("Creating the root file system...")
+
+	if err := mcnutils.CopyFile(d.cachePath(d.KernelURL), d.kernelPath()); err != nil {
+		return err
+	}
+
+	if err := mcnutils.CopyFile(d.cachePath(d.RootfsURL), d.rootfsPath()); err != nil {
+		return err
+	}
+
+	if err := d.resizeRootfs(); err != nil {
+		return err
+	}
+
+	if err := d.personalizeRootfs(); err != nil {
+		return err
+	}
+
+	macAddress, err := generateMACAddress()
+	if err != nil {
+		return err
+	}
+	d.MACAddress = macAddress
+
+	if d.IPAddress, err = d.leaseAddress(); err != nil {
+		return err
+	}
+
+	log.Infof("Starting microVM...")
+	return d.Start()
+}
+
+// config returns the configuration of the microVM.  Its address is given on
+// the command line of the kernel, there's no DHCP on the bridge.
+func (d *Driver) config() (*vmConfig, error) {
+	network, err := parseSubnet(d.Subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	bootArgs := fmt.Sprintf("console=ttyS0 reboot=k panic=1 pci=off ip=%s::%s:%s:%s:eth0:off",
+		d.IPAddress, network.gateway, network.mask(), d.MachineName)
+
+	return &vmConfig{
+		BootSource: bootSource{
+			KernelImagePath: d.kernelPath(),
+			BootArgs:        bootArgs,
+		},
+		Drives: []drive{{
+			DriveID:      "rootfs",
+			PathOnHost:   d.rootfsPath(),
+			IsRootDevice: true,
+		}},
+		MachineConfig: machineConfig{
+			VCPUCount:  d.CPU,
+			MemSizeMiB: d.Memory,
+		},
+		NetworkInterfaces: []networkInterface{{
+			IfaceID:     "eth0",
+			GuestMAC:    d.MACAddress,
+			HostDevName: d.tapName(),
+		}},
+	}, nil
+}
+
+// waitRunning waits until the microVM runs, which takes a fraction of a
+// second.
+func (d *Driver) waitRunning() error {
+	log.Infof("Waiting for host to start...")
+
+	return mcnutils.WaitForSpecific(func() bool {
+		s, err := d.GetState()
+		return err == nil && s == state.Running
+	}, 100, 100*time.Millisecond)
+}
+
+// waitStopped waits until the host is stopped
+func (d *Driver) waitStopped() error {
+	log.Infof("Waiting for host to stop...")
+
+	return mcnutils.WaitForSpecific(func() bool {
+		s, err := d.GetState()
+		return err == nil && s == state.Stopped
+	}, 60, time.Second)
+}
+
+// Start starts an host
+func (d *Driver) Start() error {
+	if err := d.ensureBridge(); err != nil {
+		return err
+	}
+
+	if err := d.ensureTap(); err != nil {
+		return err
+	}
+
+	config, err := d.config()
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	configPath := d.ResolveStorePath("firecracker.json")
+	if err := ioutil.WriteFile(configPath, buf, 0600); err != nil {
+		return err
+	}
+
+	// a socket left by a crashed firecracker would be in the way
+	os.Remove(d.socketPath())
+
+	pid, err := startFirecracker(d.FirecrackerPath, []string{"--api-sock", d.socketPath(), "--config-file", configPath}, d.ResolveStorePath("firecracker.log"))
+	if err != nil {
+		return err
+	}
+	log.Debugf("firecracker runs with PID %d", pid)
+
+	if err := ioutil.WriteFile(d.pidPath(), []byte(strconv.Itoa(pid)+"\n"), 0600); err != nil {
+		return err
+	}
+
+	return d.waitRunning()
+}
+
+// Stop shuts an host down.  firecracker exits when the guest reboots, which
+// is how Ctrl+Alt+Del is handled, and Ctrl+Alt+Del is only emulated on
+// x86_64: the guest is rebooted through SSH on the other architectures.
+func (d *Driver) Stop() error {
+	if err := d.getClient().sendAction("SendCtrlAltDel"); err != nil {
+		log.Debugf("Error sending Ctrl+Alt+Del, rebooting through SSH: %s", err)
+		if _, err := drivers.RunSSHCommandFromDriver(d, "reboot"); err != nil {
+			return err
+		}
+	}
+
+	return d.waitStopped()
+}
+
+// Restart stops and starts an host
+func (d *Driver) Restart() error {
+	if err := d.Stop(); err != nil {
+		return err
+	}
+
+	return d.Start()
+}
+
+// Kill force stops an host
+func (d *Driver) Kill() error {
+	// the PID may be another process once firecracker exited
+	if !d.getClient().isRunning() {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(d.pidPath())
+	if err != nil {
+		return err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return fmt.Errorf("Invalid PID file %s: %s", d.pidPath(), err)
+	}
+
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Kill(); err != nil && err != os.ErrProcessDone {
+		return err
+	}
+
+	os.Remove(d.socketPath())
+
+	return nil
+}
+
+// Remove kills the host and removes its tap device and address, its files
+// being removed with its directory.
+func (d *Driver) Remove() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s != state.Stopped {
+		if err := d.Kill(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.removeTap(); err != nil {
+		return err
+	}
+
+	return d.releaseAddress()
+}
+
+// GetIP returns the address of the host on the bridge, given when it was
+// created.
+func (d *Driver) GetIP() (string, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return "", err
+	}
+	if s != state.Running {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	return d.IPAddress, nil
+}
+
+func (d *Driver) getClient() *apiClient {
+	return newAPIClient(d.socketPath())
+}
+
+// socketPath returns the path of the socket of the API of firecracker.
+func (d *Driver) socketPath() string {
+	return d.ResolveStorePath("firecracker.sock")
+}
+
+func (d *Driver) pidPath() string {
+	return d.ResolveStorePath("firecracker.pid")
+}
+
+func (d *Driver) kernelPath() string {
+	return d.ResolveStorePath("vmlinux")
+}
+
+func (d *Driver) rootfsPath() string {
+	return d.ResolveStorePath("rootfs.ext4")
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
+
+// generateMACAddress returns a random locally administered MAC address.
+func generateMACAddress() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	buf[0] = (buf[0] | 0x02) & 0xfe
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", buf[0], buf[1], buf[2], buf[3], buf[4], buf[5]), nil
+}
@@ -0,0 +1,270 @@
+package firecracker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFirecracker serves the API of firecracker on the socket of a driver,
+// recording the actions.
+type fakeFirecracker struct {
+	state   string
+	actions []string
+
+	// shutdown is called after Ctrl+Alt+Del
+	shutdown func()
+}
+
+func (f *fakeFirecracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" && r.URL.Path == "/actions" {
+		action := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&action)
+		f.actions = append(f.actions, action["action_type"])
+		if f.shutdown != nil {
+			go f.shutdown()
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(&instanceInfo{ID: "anonymous-instance", State: f.state})
+}
+
+func newTestDriver(t *testing.T) (*Driver, func()) {
+	storePath, err := ioutil.TempDir("", "firecracker-")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.Bridge = "fctest0"
+
+	return driver, func() { os.RemoveAll(storePath) }
+}
+
+func serveFirecracker(t *testing.T, driver *Driver, firecracker *fakeFirecracker) func() {
+	l, err := net.Listen("unix", driver.socketPath())
+	assert.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(firecracker)
+	server.Listener = l
+	server.Start()
+
+	return server.Close
+}
+
+// recordCommands replaces the commands configuring the network of the host
+// by a recorder.
+func recordCommands() (*[]string, func()) {
+	commands := []string{}
+	orig := runCmd
+	runCmd = func(name string, args ...string) (string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return "", nil
+	}
+	return &commands, func() { runCmd = orig }
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"firecracker-cpu-count": 2,
+			"firecracker-subnet":    "10.20.0.0/16",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, 2, driver.CPU)
+	assert.Equal(t, "10.20.0.0/16", driver.Subnet)
+	assert.Equal(t, "fcbr0", driver.Bridge)
+	assert.Equal(t, defaultRootfsURL, driver.RootfsURL)
+	assert.Equal(t, "root", driver.GetSSHUsername())
+}
+
+func TestSetConfigFromFlagsInvalid(t *testing.T) {
+	for _, values := range []map[string]interface{}{
+		{"firecracker-subnet": "10.20.0.0/30"},
+		{"firecracker-subnet": "fd00::/64"},
+		{"firecracker-bridge": "a-much-too-long-bridge"},
+		{"firecracker-dns": "dns.example.com"},
+		{"firecracker-kernel-url": ""},
+	} {
+		driver := NewDriver("default", "path")
+		checkFlags := &drivers.CheckDriverOptions{
+			FlagsValues: values,
+			CreateFlags: driver.GetCreateFlags(),
+		}
+
+		assert.Error(t, driver.SetConfigFromFlags(checkFlags), "%v", values)
+	}
+}
+
+func TestLeaseAddress(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+	driver.Subnet = "10.20.0.0/29"
+
+	other := NewDriver("other", driver.StorePath)
+	other.Subnet = driver.Subnet
+
+	ip, err := driver.leaseAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.20.0.2", ip)
+	driver.IPAddress = ip
+
+	for _, expected := range []string{"10.20.0.3", "10.20.0.4", "10.20.0.5", "10.20.0.6"} {
+		ip, err := other.leaseAddress()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, ip)
+	}
+
+	_, err = other.leaseAddress()
+	assert.EqualError(t, err, "no free address in the subnet 10.20.0.0/29")
+
+	assert.NoError(t, driver.releaseAddress())
+	ip, err = other.leaseAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.20.0.2", ip)
+
+	// the address is another machine's now
+	assert.NoError(t, driver.releaseAddress())
+	_, err = os.Stat(filepath.Join(driver.leasesDir(), "10.20.0.2"))
+	assert.NoError(t, err)
+}
+
+func TestConfig(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	driver.IPAddress = "172.30.128.2"
+	driver.MACAddress = "06:00:ac:1e:80:02"
+	driver.Memory = 512
+
+	config, err := driver.config()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "console=ttyS0 reboot=k panic=1 pci=off ip=172.30.128.2::172.30.128.1:255.255.255.0:default:eth0:off", config.BootSource.BootArgs)
+	assert.Equal(t, driver.ResolveStorePath("vmlinux"), config.BootSource.KernelImagePath)
+	assert.Equal(t, []drive{{DriveID: "rootfs", PathOnHost: driver.ResolveStorePath("rootfs.ext4"), IsRootDevice: true}}, config.Drives)
+	assert.Equal(t, machineConfig{VCPUCount: 1, MemSizeMiB: 512}, config.MachineConfig)
+	assert.Equal(t, "06:00:ac:1e:80:02", config.NetworkInterfaces[0].GuestMAC)
+	assert.Equal(t, driver.tapName(), config.NetworkInterfaces[0].HostDevName)
+}
+
+func TestTapName(t *testing.T) {
+	driver := NewDriver("a-machine-with-a-long-name", "path")
+	other := NewDriver("a-machine-with-a-long-name", "other")
+
+	assert.True(t, len(driver.tapName()) <= 15)
+	assert.NotEqual(t, driver.tapName(), other.tapName())
+}
+
+func TestStart(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	driver.IPAddress = "172.30.128.2"
+	commands, restore := recordCommands()
+	defer restore()
+
+	var args []string
+	var closeServer func()
+	orig := startFirecracker
+	startFirecracker = func(path string, a []string, logPath string) (int, error) {
+		args = a
+		closeServer = serveFirecracker(t, driver, &fakeFirecracker{state: "Running"})
+		return 4242, nil
+	}
+	defer func() { startFirecracker = orig }()
+
+	assert.NoError(t, driver.Start())
+	defer closeServer()
+
+	assert.Equal(t, []string{"--api-sock", driver.socketPath(), "--config-file", driver.ResolveStorePath("firecracker.json")}, args)
+	assert.Equal(t, "ip link add name fctest0 type bridge", (*commands)[0])
+	assert.Equal(t, "ip addr add 172.30.128.1/24 dev fctest0", (*commands)[1])
+	assert.Contains(t, *commands, "iptables -t nat -A POSTROUTING -s 172.30.128.0/24 ! -o fctest0 -j MASQUERADE")
+	assert.Contains(t, *commands, "ip link set "+driver.tapName()+" master fctest0")
+
+	pid, err := ioutil.ReadFile(driver.ResolveStorePath("firecracker.pid"))
+	assert.NoError(t, err)
+	assert.Equal(t, "4242\n", string(pid))
+
+	ip, err := driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "172.30.128.2", ip)
+}
+
+func TestGetState(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+
+	_, err = driver.GetIP()
+	assert.Equal(t, drivers.ErrHostIsNotRunning, err)
+
+	defer serveFirecracker(t, driver, &fakeFirecracker{state: "Paused"})()
+
+	s, err = driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Paused, s)
+}
+
+func TestStop(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	firecracker := &fakeFirecracker{state: "Running"}
+	firecracker.shutdown = serveFirecracker(t, driver, firecracker)
+
+	assert.NoError(t, driver.Stop())
+	assert.Equal(t, []string{"SendCtrlAltDel"}, firecracker.actions)
+}
+
+func TestPersonalizeRootfs(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	var commands string
+	orig := e2fsCmd
+	e2fsCmd = func(name string, args ...string) error {
+		assert.Equal(t, "debugfs", name)
+		assert.Equal(t, driver.rootfsPath(), args[len(args)-1])
+		content, err := ioutil.ReadFile(args[2])
+		assert.NoError(t, err)
+		commands = string(content)
+		return nil
+	}
+	defer func() { e2fsCmd = orig }()
+
+	assert.NoError(t, driver.personalizeRootfs())
+
+	assert.Contains(t, commands, "write "+driver.GetSSHKeyPath()+".pub /root/.ssh/authorized_keys\n")
+	assert.Contains(t, commands, "sif /root/.ssh/authorized_keys uid 0\n")
+	assert.Contains(t, commands, "write "+driver.ResolveStorePath("resolv.conf")+" /etc/resolv.conf\n")
+}
+
+func TestCachePath(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	assert.True(t, strings.HasSuffix(driver.cachePath(defaultRootfsURL), "-ubuntu-22.04.ext4"))
+	assert.NotEqual(t, driver.cachePath(defaultRootfsURL), driver.cachePath("https://example.com/ubuntu-22.04.ext4"))
+}
@@ -0,0 +1,156 @@
+package firecracker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const imagesURL = "https://s3.amazonaws.com/spec.ccfc.min/firecracker-ci/v1.5/%s/%s"
+
+// defaultKernelURL and defaultRootfsURL are the kernel and the Ubuntu root
+// filesystem the CI of Firecracker boots, built for the host.
+var (
+	defaultKernelURL = fmt.Sprintf(imagesURL, guestArch(), "vmlinux-5.10.186")
+	defaultRootfsURL = fmt.Sprintf(imagesURL, guestArch(), "ubuntu-22.04.ext4")
+)
+
+func guestArch() string {
+	if runtime.GOARCH == "arm64" {
+		return "aarch64"
+	}
+	return "x86_64"
+}
+
+// cachePath returns where an image is cached, named after its URL so that
+// another URL is downloaded again.
+func (d *Driver) cachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(d.StorePath, "cache", "firecracker", hex.EncodeToString(sum[:])[:12]+"-"+path.Base(url))
+}
+
+// updateCache downloads an image to the cache, unless it's there.
+func (d *Driver) updateCache(url string) error {
+	dest := d.cachePath(url)
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+
+	log.Infof("Downloading %s...", url)
+
+	f, err := ioutil.TempFile(filepath.Dir(dest), filepath.Base(dest)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = download(url, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Error downloading %s: %s", url, err)
+	}
+
+	return os.Rename(f.Name(), dest)
+}
+
+func download(url string, w io.Writer) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// e2fsCmd runs one of the e2fsprogs, which work on the image of a file
+// system without mounting it.  It's replaced by the tests.
+var e2fsCmd = func(name string, args ...string) error {
+	log.Debugf("COMMAND: %s %s", name, strings.Join(args, " "))
+	if output, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s failed: %s\n%s", name, strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// resizeRootfs grows the file system of the machine to the disk size.
+func (d *Driver) resizeRootfs() error {
+	rootfs := d.rootfsPath()
+
+	fi, err := os.Stat(rootfs)
+	if err != nil {
+		return err
+	}
+
+	size := int64(d.DiskSize) << 20
+	if fi.Size() >= size {
+		return nil
+	}
+
+	if err := os.Truncate(rootfs, size); err != nil {
+		return err
+	}
+
+	// resize2fs refuses to resize a file system which wasn't checked
+	if err := e2fsCmd("e2fsck", "-f", "-p", rootfs); err != nil {
+		return err
+	}
+
+	return e2fsCmd("resize2fs", rootfs)
+}
+
+// personalizeRootfs writes the SSH key of root and the DNS server to the file
+// system of the machine with debugfs.
+func (d *Driver) personalizeRootfs() error {
+	resolvPath := d.ResolveStorePath("resolv.conf")
+	if err := ioutil.WriteFile(resolvPath, []byte("nameserver "+d.DNS+"\n"), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(resolvPath)
+
+	// debugfs goes on after a failing command, e.g. a mkdir of an
+	// existing directory, and writes the files with the owner of the
+	// local ones
+	commands := []string{
+		"mkdir /root/.ssh",
+		"sif /root/.ssh mode 040700",
+		"rm /root/.ssh/authorized_keys",
+		"write " + d.publicSSHKeyPath() + " /root/.ssh/authorized_keys",
+		"rm /etc/resolv.conf",
+		"write " + resolvPath + " /etc/resolv.conf",
+	}
+	for _, file := range []string{"/root/.ssh", "/root/.ssh/authorized_keys", "/etc/resolv.conf"} {
+		commands = append(commands, "sif "+file+" uid 0", "sif "+file+" gid 0")
+	}
+	commands = append(commands, "sif /root/.ssh/authorized_keys mode 0100600", "sif /etc/resolv.conf mode 0100644")
+
+	commandsPath := d.ResolveStorePath("debugfs.cmd")
+	if err := ioutil.WriteFile(commandsPath, []byte(strings.Join(commands, "\n")+"\n"), 0600); err != nil {
+		return err
+	}
+	defer os.Remove(commandsPath)
+
+	return e2fsCmd("debugfs", "-w", "-f", commandsPath, d.rootfsPath())
+}
@@ -0,0 +1,237 @@
+package firecracker
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// runCmd runs a command configuring the network of the host, through sudo
+// unless docker-machine runs as root.  It's replaced by the tests.
+var runCmd = func(name string, args ...string) (string, error) {
+	if os.Geteuid() != 0 {
+		args = append([]string{"-n", name}, args...)
+		name = "sudo"
+	}
+
+	cmd := exec.Command(name, args...)
+	log.Debugf("COMMAND: %s %s", name, strings.Join(args, " "))
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%s %s failed:\n%s", name, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// subnet is the network of the bridge, whose first address is the gateway on
+// the host.
+type subnet struct {
+	ipNet   *net.IPNet
+	gateway net.IP
+}
+
+func parseSubnet(s string) (*subnet, error) {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %q: %s", s, err)
+	}
+
+	ip := ipNet.IP.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid subnet %q: not an IPv4 subnet", s)
+	}
+
+	if ones, _ := ipNet.Mask.Size(); ones > 29 {
+		return nil, fmt.Errorf("invalid subnet %q: too small", s)
+	}
+
+	return &subnet{ipNet: ipNet, gateway: nextIP(ip)}, nil
+}
+
+func (s *subnet) prefixLength() int {
+	ones, _ := s.ipNet.Mask.Size()
+	return ones
+}
+
+func (s *subnet) mask() string {
+	return net.IP(s.ipNet.Mask).String()
+}
+
+// addresses returns the addresses of the subnet the microVMs can have.
+func (s *subnet) addresses() []net.IP {
+	broadcast := make(net.IP, len(s.ipNet.IP.To4()))
+	for i, b := range s.ipNet.IP.To4() {
+		broadcast[i] = b | ^s.ipNet.Mask[i]
+	}
+
+	addresses := []net.IP{}
+	for ip := nextIP(s.gateway); s.ipNet.Contains(ip) && !ip.Equal(broadcast); ip = nextIP(ip) {
+		addresses = append(addresses, ip)
+	}
+	return addresses
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip.To4()))
+	copy(next, ip.To4())
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// leasesDir holds a file per address given to a microVM, named after the
+// address and holding the name of the machine.  Creating it exclusively
+// reserves the address, even when machines are created concurrently.
+func (d *Driver) leasesDir() string {
+	return filepath.Join(d.StorePath, "firecracker", "leases")
+}
+
+// leaseAddress reserves a free address of the subnet for the machine.
+func (d *Driver) leaseAddress() (string, error) {
+	network, err := parseSubnet(d.Subnet)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(d.leasesDir(), 0700); err != nil {
+		return "", err
+	}
+
+	for _, ip := range network.addresses() {
+		f, err := os.OpenFile(filepath.Join(d.leasesDir(), ip.String()), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		_, err = f.WriteString(d.MachineName + "\n")
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return ip.String(), nil
+	}
+
+	return "", fmt.Errorf("no free address in the subnet %s", d.Subnet)
+}
+
+// releaseAddress frees the address of the machine, unless another machine
+// holds it.
+func (d *Driver) releaseAddress() error {
+	if d.IPAddress == "" {
+		return nil
+	}
+
+	leasePath := filepath.Join(d.leasesDir(), d.IPAddress)
+	content, err := ioutil.ReadFile(leasePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(string(content)) != d.MachineName {
+		return nil
+	}
+
+	return os.Remove(leasePath)
+}
+
+// tapName returns the name of the tap device of the machine, which has to fit
+// in the 15 characters of a Linux interface name.
+func (d *Driver) tapName() string {
+	sum := sha1.Sum([]byte(d.ResolveStorePath(".")))
+	return "fc-" + hex.EncodeToString(sum[:])[:12]
+}
+
+func linkExists(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name))
+	return err == nil
+}
+
+// ensureBridge creates the bridge with the gateway address and masquerades
+// the traffic of the subnet, unless the bridge exists.
+func (d *Driver) ensureBridge() error {
+	if linkExists(d.Bridge) {
+		return nil
+	}
+
+	network, err := parseSubnet(d.Subnet)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating the bridge %q on %s...", d.Bridge, network.ipNet)
+
+	for _, args := range [][]string{
+		{"ip", "link", "add", "name", d.Bridge, "type", "bridge"},
+		{"ip", "addr", "add", fmt.Sprintf("%s/%d", network.gateway, network.prefixLength()), "dev", d.Bridge},
+		{"ip", "link", "set", d.Bridge, "up"},
+		{"sysctl", "-w", "net.ipv4.ip_forward=1"},
+		{"iptables", "-t", "nat", "-A", "POSTROUTING", "-s", network.ipNet.String(), "!", "-o", d.Bridge, "-j", "MASQUERADE"},
+	} {
+		if _, err := runCmd(args[0], args[1:]...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureTap creates the tap device of the machine on the bridge, owned by the
+// user so that firecracker doesn't need to run as root.
+func (d *Driver) ensureTap() error {
+	tap := d.tapName()
+	if linkExists(tap) {
+		return nil
+	}
+
+	for _, args := range [][]string{
+		{"ip", "tuntap", "add", "dev", tap, "mode", "tap", "user", strconv.Itoa(os.Getuid())},
+		{"ip", "link", "set", tap, "master", d.Bridge},
+		{"ip", "link", "set", tap, "up"},
+	} {
+		if _, err := runCmd(args[0], args[1:]...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeTap removes the tap device of the machine.  The bridge is shared
+// with the other machines and kept.
+func (d *Driver) removeTap() error {
+	tap := d.tapName()
+	if !linkExists(tap) {
+		return nil
+	}
+
+	_, err := runCmd("ip", "link", "del", tap)
+	return err
+}
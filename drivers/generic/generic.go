@@ -19,8 +19,9 @@ import (
 
 type Driver struct {
 	*drivers.BaseDriver
-	EnginePort int
-	SSHKey     string
+	EnginePort   int
+	SSHKey       string
+	Architecture string
 }
 
 const (
@@ -60,6 +61,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  drivers.DefaultSSHPort,
 			EnvVar: "GENERIC_SSH_PORT",
 		},
+		mcnflag.StringFlag{
+			Name:   "generic-ssh-proxy-jump",
+			Usage:  "Bastion host to connect to the machine through, as [user@]host[:port]",
+			EnvVar: "GENERIC_SSH_PROXY_JUMP",
+		},
 	}
 }
 
@@ -97,6 +103,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SSHUser = flags.String("generic-ssh-user")
 	d.SSHKey = flags.String("generic-ssh-key")
 	d.SSHPort = flags.Int("generic-ssh-port")
+	d.SSHProxyJump = flags.String("generic-ssh-proxy-jump")
 
 	if d.IPAddress == "" {
 		return errors.New("generic driver requires the --generic-ip-address option")
@@ -162,6 +169,22 @@ func (d *Driver) GetState() (state.State, error) {
 	return state.Running, nil
 }
 
+// GetArchitecture returns the architecture the machine reports with
+// `uname -m`, e.g. arm for a Raspberry Pi running a 32-bit system.
+func (d *Driver) GetArchitecture() (string, error) {
+	if d.Architecture != "" {
+		return d.Architecture, nil
+	}
+
+	output, err := drivers.RunSSHCommandFromDriver(d, "uname -m")
+	if err != nil {
+		return "", err
+	}
+
+	d.Architecture = mcnutils.NormalizeArchitecture(output)
+	return d.Architecture, nil
+}
+
 func (d *Driver) Start() error {
 	return errors.New("generic driver does not support start")
 }
@@ -183,6 +206,11 @@ func (d *Driver) Remove() error {
 	return nil
 }
 
+// Rename has nothing to rename, the host is found by its address.
+func (d *Driver) Rename(name string) error {
+	return nil
+}
+
 func copySSHKey(src, dst string) error {
 	if err := mcnutils.CopyFile(src, dst); err != nil {
 		return fmt.Errorf("unable to copy ssh key: %s", err)
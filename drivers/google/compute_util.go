@@ -1,13 +1,17 @@
 package google
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/docker/machine/libmachine/imagefilter"
 	"github.com/docker/machine/libmachine/log"
 	raw "google.golang.org/api/compute/v1"
 
@@ -27,9 +31,11 @@ type ComputeUtil struct {
 	diskTypeURL       string
 	address           string
 	preemptible       bool
+	localSSD          bool
 	useInternalIP     bool
 	useInternalIPOnly bool
 	service           *raw.Service
+	client            *http.Client
 	zoneURL           string
 	globalURL         string
 	SwarmMaster       bool
@@ -43,8 +49,28 @@ const (
 	firewallTargetTag  = "docker-machine"
 	dockerStartCommand = "sudo service docker start"
 	dockerStopCommand  = "sudo service docker stop"
+	localSSDDevice     = "/dev/disk/by-id/google-local-nvme-ssd-0"
 )
 
+// localSSDStartupScript formats the local SSD, blank after each stop, and
+// mounts it on /var/lib/docker on every boot. Docker may already be running
+// by then, it is restarted on the local SSD.
+const localSSDStartupScript = `#!/bin/sh
+dev=` + localSSDDevice + `
+while [ ! -e $dev ]; do sleep 1; done
+blkid $dev >/dev/null || mkfs.ext4 -q -F $dev
+mountpoint -q /var/lib/docker && exit 0
+running=
+if service docker status >/dev/null 2>&1; then
+	service docker stop
+	running=1
+fi
+mkdir -p /var/lib/docker
+mount -o discard,defaults $dev /var/lib/docker
+[ -n "$running" ] && service docker start
+exit 0
+`
+
 // NewComputeUtil creates and initializes a ComputeUtil.
 func newComputeUtil(driver *Driver) (*ComputeUtil, error) {
 	client, err := google.DefaultClient(oauth2.NoContext, raw.ComputeScope)
@@ -65,9 +91,11 @@ func newComputeUtil(driver *Driver) (*ComputeUtil, error) {
 		diskTypeURL:       driver.DiskType,
 		address:           driver.Address,
 		preemptible:       driver.Preemptible,
+		localSSD:          driver.LocalSSD,
 		useInternalIP:     driver.UseInternalIP,
 		useInternalIPOnly: driver.UseInternalIPOnly,
 		service:           service,
+		client:            client,
 		zoneURL:           apiURL + driver.Project + "/zones/" + driver.Zone,
 		globalURL:         apiURL + driver.Project + "/global",
 		SwarmMaster:       driver.SwarmMaster,
@@ -162,7 +190,7 @@ func (c *ComputeUtil) portsUsed() ([]string, error) {
 			return nil, fmt.Errorf("error authorizing port for swarm: %s", err)
 		}
 
-		swarmPort := strings.Split(u.Host, ":")[1]
+		swarmPort := u.Port()
 		ports = append(ports, swarmPort)
 	}
 
@@ -215,6 +243,63 @@ func (c *ComputeUtil) openFirewallPorts() error {
 }
 
 // instance retrieves the instance.
+// resolveImageFilter returns the URL of the newest image matching the
+// filter among the ready images of its owner, a project such as
+// ubuntu-os-cloud, or else of the project of the machine.
+func (c *ComputeUtil) resolveImageFilter(expr string) (string, error) {
+	filter, err := imagefilter.Parse(expr)
+	if err != nil {
+		return "", err
+	}
+
+	project := filter.Owner
+	if project == "" {
+		project = c.project
+	}
+
+	images := []*raw.Image{}
+	pageToken := ""
+	for {
+		list, err := c.service.Images.List(project).PageToken(pageToken).Do()
+		if err != nil {
+			return "", unwrapGoogleError(err)
+		}
+
+		images = append(images, list.Items...)
+		if list.NextPageToken == "" {
+			break
+		}
+		pageToken = list.NextPageToken
+	}
+
+	image, err := filter.Select(filterImages(images))
+	if err != nil {
+		return "", err
+	}
+
+	log.Infof("Using the image %s matching the filter %q", image.Name, filter)
+	return image.ID, nil
+}
+
+// filterImages returns the ready images which aren't deprecated, as the
+// image filter selects them.
+func filterImages(images []*raw.Image) []imagefilter.Image {
+	result := []imagefilter.Image{}
+	for _, image := range images {
+		if image.Status != "READY" || image.Deprecated != nil && image.Deprecated.State != "" {
+			continue
+		}
+
+		created, _ := time.Parse(time.RFC3339, image.CreationTimestamp)
+		result = append(result, imagefilter.Image{
+			ID:      image.SelfLink,
+			Name:    image.Name,
+			Created: created,
+		})
+	}
+	return result
+}
+
 func (c *ComputeUtil) instance() (*raw.Instance, error) {
 	return c.service.Instances.Get(c.project, c.zone, c.instanceName).Do()
 }
@@ -254,6 +339,35 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 		},
 	}
 
+	instance.Metadata = &raw.Metadata{}
+	if d.UserDataFile != "" {
+		buf, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		userdata := string(buf)
+		instance.Metadata.Items = append(instance.Metadata.Items, &raw.MetadataItems{
+			Key:   "user-data",
+			Value: &userdata,
+		})
+	}
+
+	if d.LocalSSD {
+		instance.Disks = append(instance.Disks, &raw.AttachedDisk{
+			Type:       "SCRATCH",
+			AutoDelete: true,
+			Interface:  "NVME",
+			InitializeParams: &raw.AttachedDiskInitializeParams{
+				DiskType: c.zoneURL + "/diskTypes/local-ssd",
+			},
+		})
+		script := localSSDStartupScript
+		instance.Metadata.Items = append(instance.Metadata.Items, &raw.MetadataItems{
+			Key:   "startup-script",
+			Value: &script,
+		})
+	}
+
 	if !c.useInternalIPOnly {
 		cfg := &raw.AccessConfig{
 			Type: "ONE_TO_ONE_NAT",
@@ -282,8 +396,13 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 	} else {
 		instance.Disks[0].Source = c.zoneURL + "/disks/" + c.instanceName + "-disk"
 	}
-	op, err := c.service.Instances.Insert(c.project, c.zone, instance).Do()
 
+	body, err := instanceBody(instance, d)
+	if err != nil {
+		return err
+	}
+
+	op, err := c.post(c.zoneURL+"/instances", body)
 	if err != nil {
 		return err
 	}
@@ -301,6 +420,74 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 	return c.uploadSSHKey(instance, d.GetSSHKeyPath())
 }
 
+// instanceBody returns the body of the request creating the instance, with the
+// options the vendored API client predates: Spot provisioning, GPUs, Shielded
+// VM and Confidential VM.
+func instanceBody(instance *raw.Instance, d *Driver) (map[string]interface{}, error) {
+	buf, err := json.Marshal(instance)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(buf, &body); err != nil {
+		return nil, err
+	}
+
+	scheduling, _ := body["scheduling"].(map[string]interface{})
+	if scheduling == nil {
+		scheduling = map[string]interface{}{}
+	}
+	if d.Spot {
+		scheduling["provisioningModel"] = "SPOT"
+		scheduling["instanceTerminationAction"] = "STOP"
+		scheduling["automaticRestart"] = false
+		scheduling["onHostMaintenance"] = "TERMINATE"
+	}
+	if d.AcceleratorType != "" {
+		// Instances with GPUs can't be live migrated.
+		scheduling["onHostMaintenance"] = "TERMINATE"
+		body["guestAccelerators"] = []interface{}{
+			map[string]interface{}{
+				"acceleratorType":  apiURL + d.Project + "/zones/" + d.Zone + "/acceleratorTypes/" + d.AcceleratorType,
+				"acceleratorCount": d.AcceleratorCount,
+			},
+		}
+	}
+	if d.ConfidentialCompute {
+		// Confidential VMs can't be live migrated.
+		scheduling["onHostMaintenance"] = "TERMINATE"
+		body["confidentialInstanceConfig"] = map[string]interface{}{
+			"enableConfidentialCompute": true,
+		}
+	}
+	body["scheduling"] = scheduling
+
+	// The vendored API doesn't know subnetworks.
+	if len(d.AdditionalNetworks) > 0 {
+		interfaces, _ := body["networkInterfaces"].([]interface{})
+		for _, network := range d.AdditionalNetworks {
+			nic := map[string]interface{}{}
+			parts := strings.SplitN(network, "/", 2)
+			nic["network"] = apiURL + d.Project + "/global/networks/" + parts[0]
+			if len(parts) == 2 {
+				nic["subnetwork"] = apiURL + d.Project + "/regions/" + zoneRegion(d.Zone) + "/subnetworks/" + parts[1]
+			}
+			interfaces = append(interfaces, nic)
+		}
+		body["networkInterfaces"] = interfaces
+	}
+
+	if d.ShieldedSecureBoot || d.ShieldedVtpm || d.ShieldedIntegrityMonitoring {
+		body["shieldedInstanceConfig"] = map[string]interface{}{
+			"enableSecureBoot":          d.ShieldedSecureBoot,
+			"enableVtpm":                d.ShieldedVtpm,
+			"enableIntegrityMonitoring": d.ShieldedIntegrityMonitoring,
+		}
+	}
+
+	return body, nil
+}
+
 // configureInstance configures an existing instance for use with Docker Machine.
 func (c *ComputeUtil) configureInstance(d *Driver) error {
 	log.Infof("Configuring instance")
@@ -349,14 +536,22 @@ func (c *ComputeUtil) uploadSSHKey(instance *raw.Instance, sshKeyPath string) er
 
 	metaDataValue := fmt.Sprintf("%s:%s %s\n", c.userName, strings.TrimSpace(string(sshKey)), c.userName)
 
+	// Keep the other items, e.g. the user data
+	items := []*raw.MetadataItems{
+		{
+			Key:   "sshKeys",
+			Value: &metaDataValue,
+		},
+	}
+	for _, item := range instance.Metadata.Items {
+		if item.Key != "sshKeys" {
+			items = append(items, item)
+		}
+	}
+
 	op, err := c.service.Instances.SetMetadata(c.project, c.zone, c.instanceName, &raw.Metadata{
 		Fingerprint: instance.Metadata.Fingerprint,
-		Items: []*raw.MetadataItems{
-			{
-				Key:   "sshKeys",
-				Value: &metaDataValue,
-			},
-		},
+		Items:       items,
 	}).Do()
 
 	return c.waitForRegionalOp(op.Name)
@@ -385,9 +580,18 @@ func (c *ComputeUtil) deleteInstance() error {
 	return c.waitForRegionalOp(op.Name)
 }
 
-// stopInstance stops the instance.
+// stopInstance stops the instance. Compute Engine only stops the instances
+// with a local SSD when told to discard its data.
 func (c *ComputeUtil) stopInstance() error {
-	op, err := c.service.Instances.Stop(c.project, c.zone, c.instanceName).Do()
+	var (
+		op  *raw.Operation
+		err error
+	)
+	if c.localSSD {
+		op, err = c.post(c.zoneURL+"/instances/"+c.instanceName+"/stop?discardLocalSsd=true", nil)
+	} else {
+		op, err = c.service.Instances.Stop(c.project, c.zone, c.instanceName).Do()
+	}
 	if err != nil {
 		return err
 	}
@@ -407,6 +611,48 @@ func (c *ComputeUtil) startInstance() error {
 	return c.waitForRegionalOp(op.Name)
 }
 
+// setMachineType changes the machine type of the stopped instance.  The
+// vendored API client predates setMachineType, so it's called directly.
+func (c *ComputeUtil) setMachineType(machineType string) error {
+	op, err := c.post(c.zoneURL+"/instances/"+c.instanceName+"/setMachineType", map[string]string{
+		"machineType": c.zoneURL + "/machineTypes/" + machineType,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Waiting for instance to be resized.")
+	return c.waitForRegionalOp(op.Name)
+}
+
+// post sends a request to the API directly, for the methods and fields the
+// vendored API client lacks, and returns the operation it starts.
+func (c *ComputeUtil) post(url string, body interface{}) (*raw.Operation, error) {
+	buf := []byte{}
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.client.Post(url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+
+	op := &raw.Operation{}
+	if err := json.NewDecoder(resp.Body).Decode(op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
 // waitForOp waits for the operation to finish.
 func (c *ComputeUtil) waitForOp(opGetter func() (*raw.Operation, error)) error {
 	for {
@@ -455,6 +701,25 @@ func (c *ComputeUtil) ip() (string, error) {
 	return nic.AccessConfigs[0].NatIP, nil
 }
 
+// privateIP retrieves and returns the internal IP address of the instance.
+func (c *ComputeUtil) privateIP() (string, error) {
+	instance, err := c.service.Instances.Get(c.project, c.zone, c.instanceName).Do()
+	if err != nil {
+		return "", unwrapGoogleError(err)
+	}
+
+	return instance.NetworkInterfaces[0].NetworkIP, nil
+}
+
+// zoneRegion returns the region of a zone, e.g. us-central1 for
+// us-central1-a.
+func zoneRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
 func unwrapGoogleError(err error) error {
 	if googleErr, ok := err.(*googleapi.Error); ok {
 		return errors.New(googleErr.Message)
@@ -68,3 +68,96 @@ func TestMissingOpenedPorts(t *testing.T) {
 		assert.Equal(t, test.expectedMissing, missingPorts, test.description)
 	}
 }
+
+func TestInstanceBody(t *testing.T) {
+	instance := &raw.Instance{
+		Name: "vm",
+		Scheduling: &raw.Scheduling{
+			Preemptible: false,
+		},
+	}
+
+	body, err := instanceBody(instance, &Driver{})
+	assert.NoError(t, err)
+	assert.Equal(t, "vm", body["name"])
+	assert.Equal(t, map[string]interface{}{}, body["scheduling"])
+	assert.NotContains(t, body, "shieldedInstanceConfig")
+	assert.NotContains(t, body, "confidentialInstanceConfig")
+
+	body, err = instanceBody(instance, &Driver{
+		Spot:                true,
+		ShieldedSecureBoot:  true,
+		ConfidentialCompute: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"provisioningModel":         "SPOT",
+		"instanceTerminationAction": "STOP",
+		"automaticRestart":          false,
+		"onHostMaintenance":         "TERMINATE",
+	}, body["scheduling"])
+	assert.Equal(t, map[string]interface{}{
+		"enableSecureBoot":          true,
+		"enableVtpm":                false,
+		"enableIntegrityMonitoring": false,
+	}, body["shieldedInstanceConfig"])
+	assert.Equal(t, map[string]interface{}{"enableConfidentialCompute": true}, body["confidentialInstanceConfig"])
+}
+
+func TestInstanceBodyAdditionalNetworks(t *testing.T) {
+	instance := &raw.Instance{
+		Name: "vm",
+		NetworkInterfaces: []*raw.NetworkInterface{
+			{Network: apiURL + "project/global/networks/default"},
+		},
+	}
+
+	body, err := instanceBody(instance, &Driver{
+		Project:            "project",
+		Zone:               "europe-west1-b",
+		AdditionalNetworks: []string{"backend", "storage/storage-eu"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"network": apiURL + "project/global/networks/default"},
+		map[string]interface{}{"network": apiURL + "project/global/networks/backend"},
+		map[string]interface{}{
+			"network":    apiURL + "project/global/networks/storage",
+			"subnetwork": apiURL + "project/regions/europe-west1/subnetworks/storage-eu",
+		},
+	}, body["networkInterfaces"])
+}
+
+func TestInstanceBodyAccelerator(t *testing.T) {
+	instance := &raw.Instance{Name: "vm"}
+
+	body, err := instanceBody(instance, &Driver{
+		Project:          "project",
+		Zone:             "us-central1-a",
+		AcceleratorType:  "nvidia-tesla-t4",
+		AcceleratorCount: 1,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"onHostMaintenance": "TERMINATE"}, body["scheduling"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"acceleratorType":  apiURL + "project/zones/us-central1-a/acceleratorTypes/nvidia-tesla-t4",
+			"acceleratorCount": 1,
+		},
+	}, body["guestAccelerators"])
+}
+
+func TestFilterImages(t *testing.T) {
+	images := filterImages([]*raw.Image{
+		{Name: "ubuntu-2204-jammy-v20240110", SelfLink: "https://example.com/v20240110", Status: "READY", CreationTimestamp: "2024-01-10T12:00:00.000-08:00"},
+		{Name: "ubuntu-2204-jammy-v20231201", SelfLink: "https://example.com/v20231201", Status: "READY", CreationTimestamp: "2023-12-01T12:00:00.000-08:00", Deprecated: &raw.DeprecationStatus{State: "DEPRECATED"}},
+		{Name: "ubuntu-2204-jammy-v20240201", SelfLink: "https://example.com/v20240201", Status: "PENDING", CreationTimestamp: "2024-02-01T12:00:00.000-08:00"},
+	})
+
+	assert.Len(t, images, 1)
+	assert.Equal(t, "https://example.com/v20240110", images[0].ID)
+	assert.Equal(t, "ubuntu-2204-jammy-v20240110", images[0].Name)
+	assert.Equal(t, 2024, images[0].Created.Year())
+}
@@ -3,6 +3,9 @@ package google
 import (
 	"fmt"
 	"net"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -28,6 +31,20 @@ type Driver struct {
 	Project           string
 	Tags              string
 	UseExisting       bool
+	UserDataFile      string
+	ImageFilter       string `json:",omitempty"`
+
+	Spot                        bool
+	ShieldedSecureBoot          bool
+	ShieldedVtpm                bool
+	ShieldedIntegrityMonitoring bool
+	ConfidentialCompute         bool
+	LocalSSD                    bool
+	AcceleratorType             string `json:",omitempty"`
+	AcceleratorCount            int    `json:",omitempty"`
+
+	AdditionalNetworks []string `json:",omitempty"`
+	PrivateIPAddress   string   `json:",omitempty"`
 }
 
 const (
@@ -40,6 +57,10 @@ const (
 	defaultDiskSize    = 10
 )
 
+// customMachineTypeRegexp matches the custom machine types, e.g. custom-4-8192
+// or n2-custom-8-16384-ext, whose memory is in MB.
+var customMachineTypeRegexp = regexp.MustCompile(`^(?:[a-z][a-z0-9]*-)?custom-(\d+)-(\d+)(?:-ext)?$`)
+
 // GetCreateFlags registers the flags this driver adds to
 // "docker hosts create"
 func (d *Driver) GetCreateFlags() []mcnflag.Flag {
@@ -101,6 +122,52 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "GCE Instance Preemptibility",
 			EnvVar: "GOOGLE_PREEMPTIBLE",
 		},
+		mcnflag.BoolFlag{
+			Name:   "google-spot",
+			Usage:  "Create a GCE Spot instance, stopped when Compute Engine needs the capacity back",
+			EnvVar: "GOOGLE_SPOT",
+		},
+		mcnflag.BoolFlag{
+			Name:   "google-shielded-secure-boot",
+			Usage:  "Enable Secure Boot on the GCE Shielded VM",
+			EnvVar: "GOOGLE_SHIELDED_SECURE_BOOT",
+		},
+		mcnflag.BoolFlag{
+			Name:   "google-shielded-vtpm",
+			Usage:  "Enable the virtual Trusted Platform Module of the GCE Shielded VM",
+			EnvVar: "GOOGLE_SHIELDED_VTPM",
+		},
+		mcnflag.BoolFlag{
+			Name:   "google-shielded-integrity-monitoring",
+			Usage:  "Enable the integrity monitoring of the GCE Shielded VM",
+			EnvVar: "GOOGLE_SHIELDED_INTEGRITY_MONITORING",
+		},
+		mcnflag.BoolFlag{
+			Name:   "google-confidential-compute",
+			Usage:  "Create a GCE Confidential VM, the machine type must support it (e.g. n2d-standard-2)",
+			EnvVar: "GOOGLE_CONFIDENTIAL_COMPUTE",
+		},
+		mcnflag.BoolFlag{
+			Name:   "google-local-ssd",
+			Usage:  "Attach a local SSD and mount it on /var/lib/docker, its data is lost when the instance stops",
+			EnvVar: "GOOGLE_LOCAL_SSD",
+		},
+		mcnflag.StringFlag{
+			Name:   "google-accelerator-type",
+			Usage:  "Type of the GPUs attached to the instance, e.g. nvidia-tesla-t4, available in the zone",
+			EnvVar: "GOOGLE_ACCELERATOR_TYPE",
+		},
+		mcnflag.IntFlag{
+			Name:   "google-accelerator-count",
+			Usage:  "Number of GPUs of --google-accelerator-type attached to the instance",
+			Value:  1,
+			EnvVar: "GOOGLE_ACCELERATOR_COUNT",
+		},
+		mcnflag.StringSliceFlag{
+			Name:   "google-additional-networks",
+			Usage:  "VPC networks of other network interfaces of the instance, as NETWORK[/SUBNETWORK] with a subnetwork of the region",
+			EnvVar: "GOOGLE_ADDITIONAL_NETWORKS",
+		},
 		mcnflag.StringFlag{
 			Name:   "google-tags",
 			Usage:  "GCE Instance Tags (comma-separated)",
@@ -117,11 +184,21 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Configure GCE instance to not have an external IP address",
 			EnvVar: "GOOGLE_USE_INTERNAL_IP_ONLY",
 		},
+		mcnflag.StringFlag{
+			Name:   "google-ssh-proxy-jump",
+			Usage:  "Bastion host to connect to the instance through, as [user@]host[:port]",
+			EnvVar: "GOOGLE_SSH_PROXY_JUMP",
+		},
 		mcnflag.BoolFlag{
 			Name:   "google-use-existing",
 			Usage:  "Don't create a new VM, use an existing one",
 			EnvVar: "GOOGLE_USE_EXISTING",
 		},
+		mcnflag.StringFlag{
+			Name:   "google-userdata",
+			Usage:  "path to file with cloud-init user data",
+			EnvVar: "GOOGLE_USERDATA",
+		},
 	}
 }
 
@@ -160,6 +237,14 @@ func (d *Driver) DriverName() string {
 	return "google"
 }
 
+// Capabilities returns the features of the driver, including spot instances and user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.Spot = true
+	capabilities.UserData = true
+	return capabilities
+}
+
 // SetConfigFromFlags initializes the driver based on the command line flags.
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.Project = flags.String("google-project")
@@ -172,6 +257,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	if !d.UseExisting {
 		d.MachineType = flags.String("google-machine-type")
 		d.MachineImage = flags.String("google-machine-image")
+		d.ImageFilter = flags.String("image-filter")
 		d.DiskSize = flags.Int("google-disk-size")
 		d.DiskType = flags.String("google-disk-type")
 		d.Address = flags.String("google-address")
@@ -180,9 +266,37 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		d.UseInternalIPOnly = flags.Bool("google-use-internal-ip-only")
 		d.Scopes = flags.String("google-scopes")
 		d.Tags = flags.String("google-tags")
+		d.UserDataFile = flags.String("google-userdata")
+		d.Spot = flags.Bool("google-spot")
+		d.ShieldedSecureBoot = flags.Bool("google-shielded-secure-boot")
+		d.ShieldedVtpm = flags.Bool("google-shielded-vtpm")
+		d.ShieldedIntegrityMonitoring = flags.Bool("google-shielded-integrity-monitoring")
+		d.ConfidentialCompute = flags.Bool("google-confidential-compute")
+		d.LocalSSD = flags.Bool("google-local-ssd")
+		d.AdditionalNetworks = flags.StringSlice("google-additional-networks")
+		d.AcceleratorType = flags.String("google-accelerator-type")
+		if d.AcceleratorType != "" {
+			d.AcceleratorCount = flags.Int("google-accelerator-count")
+		}
+
+		if d.Spot && d.Preemptible {
+			return fmt.Errorf("--google-spot and --google-preemptible are mutually exclusive, Spot instances replace preemptible ones")
+		}
+		if err := validateMachineType(d.MachineType); err != nil {
+			return err
+		}
+		if d.AcceleratorType != "" && d.AcceleratorCount < 1 {
+			return fmt.Errorf("Invalid accelerator count %d, at least one GPU must be attached", d.AcceleratorCount)
+		}
+		for _, network := range d.AdditionalNetworks {
+			if parts := strings.Split(network, "/"); len(parts) > 2 || parts[0] == "" || parts[len(parts)-1] == "" {
+				return fmt.Errorf("Invalid network %q, expected NETWORK[/SUBNETWORK]", network)
+			}
+		}
 	}
 	d.SSHUser = flags.String("google-username")
 	d.SSHPort = 22
+	d.SSHProxyJump = flags.String("google-ssh-proxy-jump")
 	d.SetSwarmConfigFromFlags(flags)
 
 	return nil
@@ -195,6 +309,12 @@ func (d *Driver) PreCreateCheck() error {
 		return err
 	}
 
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
 	// Check that the project exists. It will also check the credentials
 	// at the same time.
 	log.Infof("Check that the project exists")
@@ -218,6 +338,14 @@ func (d *Driver) PreCreateCheck() error {
 		}
 	}
 
+	if !d.UseExisting && d.ImageFilter != "" {
+		image, err := c.resolveImageFilter(d.ImageFilter)
+		if err != nil {
+			return err
+		}
+		d.MachineImage = image
+	}
+
 	return nil
 }
 
@@ -243,7 +371,12 @@ func (d *Driver) Create() error {
 	if d.UseExisting {
 		return c.configureInstance(d)
 	}
-	return c.createInstance(d)
+	if err := c.createInstance(d); err != nil {
+		return err
+	}
+
+	d.PrivateIPAddress, err = c.privateIP()
+	return err
 }
 
 // GetURL returns the URL of the remote docker daemon.
@@ -274,6 +407,24 @@ func (d *Driver) GetIP() (string, error) {
 	return ip, nil
 }
 
+// GetPrivateIP returns the internal IP of the first network interface of the
+// instance.
+func (d *Driver) GetPrivateIP() (string, error) {
+	if d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+
+	c, err := newComputeUtil(d)
+	if err != nil {
+		return "", err
+	}
+
+	if d.PrivateIPAddress, err = c.privateIP(); err != nil {
+		return "", err
+	}
+	return d.PrivateIPAddress, nil
+}
+
 // GetState returns a docker.hosts.state.State value representing the current state of the host.
 func (d *Driver) GetState() (state.State, error) {
 	c, err := newComputeUtil(d)
@@ -346,6 +497,25 @@ func (d *Driver) Stop() error {
 	return nil
 }
 
+// Resize changes the machine type of the stopped instance.
+func (d *Driver) Resize(size string) error {
+	if err := validateMachineType(size); err != nil {
+		return err
+	}
+
+	c, err := newComputeUtil(d)
+	if err != nil {
+		return err
+	}
+
+	if err := c.setMachineType(size); err != nil {
+		return err
+	}
+
+	d.MachineType = size
+	return nil
+}
+
 // Restart restarts a machine which is known to be running.
 func (d *Driver) Restart() error {
 	if err := d.Stop(); err != nil {
@@ -373,3 +543,26 @@ func (d *Driver) Remove() error {
 
 	return c.deleteDisk()
 }
+
+// validateMachineType checks the number of vCPUs and the memory of the custom
+// machine types, which Compute Engine would otherwise only reject once asked
+// to create the instance.
+func validateMachineType(machineType string) error {
+	m := customMachineTypeRegexp.FindStringSubmatch(machineType)
+	if m == nil {
+		if strings.Contains(machineType, "custom") {
+			return fmt.Errorf("Invalid custom machine type %q, expected [FAMILY-]custom-CPUS-MEMORY[-ext], e.g. custom-4-8192", machineType)
+		}
+		return nil
+	}
+
+	cpus, _ := strconv.Atoi(m[1])
+	memory, _ := strconv.Atoi(m[2])
+	if cpus < 1 {
+		return fmt.Errorf("Invalid custom machine type %q, it needs at least one vCPU", machineType)
+	}
+	if memory < 256 || memory%256 != 0 {
+		return fmt.Errorf("Invalid custom machine type %q, the memory must be a multiple of 256 MB", machineType)
+	}
+	return nil
+}
@@ -22,3 +22,66 @@ func TestSetConfigFromFlags(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Empty(t, checkFlags.InvalidFlags)
 }
+
+func TestSetConfigFromFlagsSpotAndPreemptible(t *testing.T) {
+	driver := NewDriver("", "")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"google-project":     "PROJECT",
+			"google-spot":        true,
+			"google-preemptible": true,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.Error(t, err)
+}
+
+func TestSetConfigFromFlagsAccelerator(t *testing.T) {
+	driver := NewDriver("", "")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"google-project":           "PROJECT",
+			"google-accelerator-type":  "nvidia-tesla-t4",
+			"google-accelerator-count": 2,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "nvidia-tesla-t4", driver.AcceleratorType)
+	assert.Equal(t, 2, driver.AcceleratorCount)
+
+	checkFlags.FlagsValues["google-accelerator-count"] = 0
+
+	err = driver.SetConfigFromFlags(checkFlags)
+
+	assert.EqualError(t, err, "Invalid accelerator count 0, at least one GPU must be attached")
+}
+
+func TestValidateMachineType(t *testing.T) {
+	var tests = []struct {
+		machineType string
+		valid       bool
+	}{
+		{"n1-standard-1", true},
+		{"custom-4-8192", true},
+		{"n2-custom-8-16384", true},
+		{"n2-custom-2-32768-ext", true},
+		{"custom-0-1024", false},
+		{"custom-4-1000", false},
+		{"custom-4", false},
+	}
+
+	for _, test := range tests {
+		err := validateMachineType(test.machineType)
+
+		assert.Equal(t, test.valid, err == nil, test.machineType)
+	}
+}
@@ -0,0 +1,303 @@
+package hetzner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	defaultAPIEndpoint = "https://api.hetzner.cloud/v1"
+)
+
+// Client is a minimal client for the Hetzner Cloud API, covering what the
+// driver needs to manage a server.
+type Client struct {
+	Token    string
+	Endpoint string
+	http     *http.Client
+}
+
+type APIError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("hetzner API error (%d %s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+type Server struct {
+	ID         int                `json:"id"`
+	Name       string             `json:"name"`
+	Status     string             `json:"status"`
+	PublicNet  ServerPublicNet    `json:"public_net"`
+	PrivateNet []ServerPrivateNet `json:"private_net"`
+}
+
+type ServerPublicNet struct {
+	IPv4 struct {
+		IP string `json:"ip"`
+	} `json:"ipv4"`
+	// IPv6 is the /64 network of the server, e.g. 2001:db8:1:2::/64
+	IPv6 struct {
+		IP string `json:"ip"`
+	} `json:"ipv6"`
+}
+
+type ServerPrivateNet struct {
+	Network int    `json:"network"`
+	IP      string `json:"ip"`
+}
+
+type ServerCreateRequest struct {
+	Name             string `json:"name"`
+	ServerType       string `json:"server_type"`
+	Image            string `json:"image"`
+	Location         string `json:"location,omitempty"`
+	SSHKeys          []int  `json:"ssh_keys,omitempty"`
+	Networks         []int  `json:"networks,omitempty"`
+	UserData         string `json:"user_data,omitempty"`
+	StartAfterCreate bool   `json:"start_after_create"`
+}
+
+type SSHKey struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+}
+
+// Image is a snapshot of a server, described by its description.
+type Image struct {
+	ID          int    `json:"id"`
+	Description string `json:"description"`
+	Created     string `json:"created"`
+	CreatedFrom *struct {
+		ID int `json:"id"`
+	} `json:"created_from"`
+}
+
+type Action struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type namedResource struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func NewClient(token string) *Client {
+	return &Client{
+		Token:    token,
+		Endpoint: defaultAPIEndpoint,
+		http:     &http.Client{},
+	}
+}
+
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.Endpoint+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := struct {
+			Error *APIError `json:"error"`
+		}{&APIError{StatusCode: resp.StatusCode}}
+		json.Unmarshal(respBody, &apiErr)
+		return apiErr.Error
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// IsNotFound returns true if err is an API error for a missing resource.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) CreateServer(createRequest *ServerCreateRequest) (*Server, error) {
+	var resp struct {
+		Server *Server `json:"server"`
+	}
+	if err := c.do("POST", "/servers", createRequest, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Server, nil
+}
+
+func (c *Client) GetServer(id int) (*Server, error) {
+	var resp struct {
+		Server *Server `json:"server"`
+	}
+	if err := c.do("GET", "/servers/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Server, nil
+}
+
+func (c *Client) DeleteServer(id int) error {
+	return c.do("DELETE", "/servers/"+strconv.Itoa(id), nil, nil)
+}
+
+// ServerAction runs an action such as poweron, poweroff, shutdown or reboot
+// on a server.
+func (c *Client) ServerAction(id int, action string) error {
+	return c.do("POST", fmt.Sprintf("/servers/%d/actions/%s", id, action), nil, nil)
+}
+
+// CreateSnapshotImage starts the creation of a snapshot image of a server.
+func (c *Client) CreateSnapshotImage(id int, description string) (*Image, *Action, error) {
+	var resp struct {
+		Image  *Image  `json:"image"`
+		Action *Action `json:"action"`
+	}
+	body := map[string]string{"type": "snapshot", "description": description}
+	if err := c.do("POST", fmt.Sprintf("/servers/%d/actions/create_image", id), body, &resp); err != nil {
+		return nil, nil, err
+	}
+	return resp.Image, resp.Action, nil
+}
+
+// ListSnapshotImages returns the snapshot images created from a server.
+func (c *Client) ListSnapshotImages(id int) ([]Image, error) {
+	images := []Image{}
+
+	for page := 1; page != 0; {
+		var resp struct {
+			Images []Image `json:"images"`
+			Meta   struct {
+				Pagination struct {
+					NextPage int `json:"next_page"`
+				} `json:"pagination"`
+			} `json:"meta"`
+		}
+		if err := c.do("GET", fmt.Sprintf("/images?type=snapshot&page=%d", page), nil, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, image := range resp.Images {
+			if image.CreatedFrom != nil && image.CreatedFrom.ID == id {
+				images = append(images, image)
+			}
+		}
+
+		page = resp.Meta.Pagination.NextPage
+	}
+
+	return images, nil
+}
+
+func (c *Client) DeleteImage(id int) error {
+	return c.do("DELETE", "/images/"+strconv.Itoa(id), nil, nil)
+}
+
+// RebuildServer starts replacing the disk of a server with an image.
+func (c *Client) RebuildServer(id, imageID int) (*Action, error) {
+	var resp struct {
+		Action *Action `json:"action"`
+	}
+	body := map[string]string{"image": strconv.Itoa(imageID)}
+	if err := c.do("POST", fmt.Sprintf("/servers/%d/actions/rebuild", id), body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Action, nil
+}
+
+func (c *Client) GetAction(id int) (*Action, error) {
+	var resp struct {
+		Action *Action `json:"action"`
+	}
+	if err := c.do("GET", "/actions/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Action, nil
+}
+
+func (c *Client) CreateSSHKey(name, publicKey string) (*SSHKey, error) {
+	var resp struct {
+		SSHKey *SSHKey `json:"ssh_key"`
+	}
+	if err := c.do("POST", "/ssh_keys", &SSHKey{Name: name, PublicKey: publicKey}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.SSHKey, nil
+}
+
+func (c *Client) DeleteSSHKey(id int) error {
+	return c.do("DELETE", "/ssh_keys/"+strconv.Itoa(id), nil, nil)
+}
+
+// lookupByName returns the ID of the resource of the given collection, e.g.
+// "locations", with the given name, or 0 if there is none.
+func (c *Client) lookupByName(collection, name string) (int, error) {
+	resp := map[string]json.RawMessage{}
+	if err := c.do("GET", fmt.Sprintf("/%s?name=%s", collection, url.QueryEscape(name)), nil, &resp); err != nil {
+		return 0, err
+	}
+
+	resources := []namedResource{}
+	if raw, ok := resp[collection]; ok {
+		if err := json.Unmarshal(raw, &resources); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, r := range resources {
+		if r.Name == name {
+			return r.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// GetNetworkID resolves a private network given by name or by ID.
+func (c *Client) GetNetworkID(nameOrID string) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+
+	id, err := c.lookupByName("networks", nameOrID)
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, fmt.Errorf("hetzner network %q could not be found", nameOrID)
+	}
+	return id, nil
+}
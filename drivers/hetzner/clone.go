@@ -0,0 +1,18 @@
+package hetzner
+
+// A server is cloned from a snapshot image of the source server, which is kept
+// in the project once the clone is created.
+
+func (d *Driver) CloneDisk(name string) (string, error) {
+	snapshot, err := d.CreateSnapshot(name)
+	if err != nil {
+		return "", err
+	}
+
+	return snapshot.ID, nil
+}
+
+func (d *Driver) SetClonedDisk(disk string) error {
+	d.Image = disk
+	return nil
+}
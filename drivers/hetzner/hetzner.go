@@ -0,0 +1,395 @@
+package hetzner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	APIToken          string
+	ServerID          int
+	ServerType        string
+	Image             string
+	Location          string
+	SSHKeyID          int
+	Networks          []string
+	NetworkIDs        []int
+	UsePrivateNetwork bool
+	PrivateIPAddress  string
+	UserDataFile      string
+	client            *Client
+}
+
+const (
+	defaultSSHPort    = 22
+	defaultSSHUser    = "root"
+	defaultImage      = "ubuntu-22.04"
+	defaultServerType = "cx22"
+	defaultLocation   = "nbg1"
+)
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_API_TOKEN",
+			Name:   "hetzner-api-token",
+			Usage:  "Hetzner Cloud API token",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_IMAGE",
+			Name:   "hetzner-image",
+			Usage:  "Hetzner Cloud image",
+			Value:  defaultImage,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_SERVER_TYPE",
+			Name:   "hetzner-server-type",
+			Usage:  "Hetzner Cloud server type",
+			Value:  defaultServerType,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_LOCATION",
+			Name:   "hetzner-location",
+			Usage:  "Hetzner Cloud location",
+			Value:  defaultLocation,
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "HETZNER_NETWORKS",
+			Name:   "hetzner-networks",
+			Usage:  "Private networks (name or ID) to attach the server to",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_USE_PRIVATE_NETWORK",
+			Name:   "hetzner-use-private-network",
+			Usage:  "Use the private network IP to communicate with the server",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_USER_DATA",
+			Name:   "hetzner-user-data",
+			Usage:  "path to file with cloud-init user-data",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_SSH_USER",
+			Name:   "hetzner-ssh-user",
+			Usage:  "SSH username",
+			Value:  defaultSSHUser,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_SSH_PORT",
+			Name:   "hetzner-ssh-port",
+			Usage:  "SSH port",
+			Value:  defaultSSHPort,
+		},
+	}
+}
+
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Image:      defaultImage,
+		ServerType: defaultServerType,
+		Location:   defaultLocation,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "hetzner"
+}
+
+// Capabilities returns the features of the driver, including IPv6 and user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.IPv6 = true
+	capabilities.UserData = true
+	return capabilities
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.APIToken = flags.String("hetzner-api-token")
+	d.Image = flags.String("hetzner-image")
+	d.ServerType = flags.String("hetzner-server-type")
+	d.Location = flags.String("hetzner-location")
+	d.Networks = flags.StringSlice("hetzner-networks")
+	d.UsePrivateNetwork = flags.Bool("hetzner-use-private-network")
+	d.UserDataFile = flags.String("hetzner-user-data")
+	d.SSHUser = flags.String("hetzner-ssh-user")
+	d.SSHPort = flags.Int("hetzner-ssh-port")
+	d.SetSwarmConfigFromFlags(flags)
+	d.SetIPFamilyFromFlags(flags)
+
+	if d.APIToken == "" {
+		return fmt.Errorf("hetzner driver requires the --hetzner-api-token option")
+	}
+
+	if d.UsePrivateNetwork && len(d.Networks) == 0 {
+		return fmt.Errorf("hetzner driver requires --hetzner-networks to use the private network")
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
+	client := d.getClient()
+
+	if id, err := client.lookupByName("locations", d.Location); err != nil {
+		return err
+	} else if id == 0 {
+		return fmt.Errorf("hetzner location %q is not valid", d.Location)
+	}
+
+	if id, err := client.lookupByName("server_types", d.ServerType); err != nil {
+		return err
+	} else if id == 0 {
+		return fmt.Errorf("hetzner server type %q is not valid", d.ServerType)
+	}
+
+	d.NetworkIDs = []int{}
+	for _, network := range d.Networks {
+		id, err := client.GetNetworkID(network)
+		if err != nil {
+			return err
+		}
+		d.NetworkIDs = append(d.NetworkIDs, id)
+	}
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	var userdata string
+	if d.UserDataFile != "" {
+		buf, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		userdata = string(buf)
+	}
+
+	log.Infof("Creating SSH key...")
+
+	if err := d.createSSHKey(); err != nil {
+		return err
+	}
+
+	log.Infof("Creating Hetzner server...")
+
+	client := d.getClient()
+
+	server, err := client.CreateServer(&ServerCreateRequest{
+		Name:             d.MachineName,
+		ServerType:       d.ServerType,
+		Image:            d.Image,
+		Location:         d.Location,
+		SSHKeys:          []int{d.SSHKeyID},
+		Networks:         d.NetworkIDs,
+		UserData:         userdata,
+		StartAfterCreate: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.ServerID = server.ID
+
+	log.Info("Waiting for IP address to be assigned to the server...")
+	if err := mcnutils.WaitForSpecific(d.serverHasAddress, 60, 2*time.Second); err != nil {
+		return fmt.Errorf("hetzner server %d has no IP address: %s", d.ServerID, err)
+	}
+
+	log.Debugf("Created server ID %d, IP address %s, private IP address %s",
+		d.ServerID,
+		d.IPAddress,
+		d.PrivateIPAddress)
+
+	return nil
+}
+
+// serverHasAddress refreshes the addresses of the server and reports whether
+// the one used to reach it is known yet.
+func (d *Driver) serverHasAddress() bool {
+	server, err := d.getClient().GetServer(d.ServerID)
+	if err != nil {
+		log.Debugf("Error getting server %d: %s", d.ServerID, err)
+		return false
+	}
+
+	ipv6 := serverIPv6(server.PublicNet.IPv6.IP)
+	d.IPAddress = d.PreferredIP(server.PublicNet.IPv4.IP, ipv6)
+	if len(server.PrivateNet) > 0 {
+		d.PrivateIPAddress = server.PrivateNet[0].IP
+	}
+
+	if d.UsePrivateNetwork {
+		return d.PrivateIPAddress != ""
+	}
+
+	if d.UseIPv6 && ipv6 == "" {
+		return false
+	}
+	return d.IPAddress != ""
+}
+
+func (d *Driver) createSSHKey() error {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	key, err := d.getClient().CreateSSHKey(d.MachineName, string(publicKey))
+	if err != nil {
+		return err
+	}
+
+	d.SSHKeyID = key.ID
+
+	return nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	if d.UsePrivateNetwork && d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+	return d.BaseDriver.GetIP()
+}
+
+// GetPrivateIP returns the address of the server on its first private
+// network.
+func (d *Driver) GetPrivateIP() (string, error) {
+	if d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+
+	server, err := d.getClient().GetServer(d.ServerID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(server.PrivateNet) == 0 {
+		return "", fmt.Errorf("The server %d isn't attached to a private network, attach one with --hetzner-networks", d.ServerID)
+	}
+
+	d.PrivateIPAddress = server.PrivateNet[0].IP
+	return d.PrivateIPAddress, nil
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	server, err := d.getClient().GetServer(d.ServerID)
+	if err != nil {
+		return state.Error, err
+	}
+	switch server.Status {
+	case "initializing", "starting":
+		return state.Starting, nil
+	case "running":
+		return state.Running, nil
+	case "stopping":
+		return state.Stopping, nil
+	case "off":
+		return state.Stopped, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) Start() error {
+	return d.getClient().ServerAction(d.ServerID, "poweron")
+}
+
+func (d *Driver) Stop() error {
+	return d.getClient().ServerAction(d.ServerID, "shutdown")
+}
+
+func (d *Driver) Restart() error {
+	return d.getClient().ServerAction(d.ServerID, "reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.getClient().ServerAction(d.ServerID, "poweroff")
+}
+
+func (d *Driver) Remove() error {
+	client := d.getClient()
+	if d.SSHKeyID != 0 {
+		if err := client.DeleteSSHKey(d.SSHKeyID); err != nil {
+			if IsNotFound(err) {
+				log.Infof("Hetzner SSH key doesn't exist, assuming it is already deleted")
+			} else {
+				return err
+			}
+		}
+	}
+	if err := client.DeleteServer(d.ServerID); err != nil {
+		if IsNotFound(err) {
+			log.Infof("Hetzner server doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) getClient() *Client {
+	if d.client == nil {
+		d.client = NewClient(d.APIToken)
+	}
+	return d.client
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
+
+// serverIPv6 returns the address of a server in its IPv6 network, the
+// first one of the network.
+func serverIPv6(network string) string {
+	ip, _, err := net.ParseCIDR(network)
+	if err != nil || ip.To4() != nil {
+		return ""
+	}
+
+	ip[len(ip)-1] = 1
+	return ip.String()
+}
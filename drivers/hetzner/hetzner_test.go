@@ -0,0 +1,134 @@
+package hetzner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"hetzner-api-token": "TOKEN",
+			"hetzner-networks":  []string{"backend"},
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, []string{"backend"}, driver.Networks)
+	assert.Equal(t, defaultServerType, driver.ServerType)
+	assert.Equal(t, driver.ResolveStorePath("id_rsa"), driver.GetSSHKeyPath())
+}
+
+func TestSetConfigFromFlagsRequiresToken(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestPrivateNetworkRequiresNetworks(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"hetzner-api-token":           "TOKEN",
+			"hetzner-use-private-network": true,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestGetStateAndPrivateIP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer TOKEN", r.Header.Get("Authorization"))
+		assert.Equal(t, "/servers/42", r.URL.Path)
+		w.Write([]byte(`{"server":{"id":42,"status":"running","public_net":{"ipv4":{"ip":"1.2.3.4"}},"private_net":[{"network":7,"ip":"10.0.0.2"}]}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.APIToken = "TOKEN"
+	driver.ServerID = 42
+	driver.UsePrivateNetwork = true
+	driver.getClient().Endpoint = server.URL
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+
+	assert.True(t, driver.serverHasAddress())
+	ip, err := driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", ip)
+}
+
+func TestRemoveIgnoresMissingServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"code":"not_found","message":"server not found"}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.ServerID = 42
+	driver.SSHKeyID = 3
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Remove())
+}
+
+func TestListSnapshots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/images", r.URL.Path)
+		assert.Equal(t, "snapshot", r.URL.Query().Get("type"))
+		w.Write([]byte(`{"images":[{"id":1,"description":"clean","created":"2026-10-16T08:30:00+00:00","created_from":{"id":42}},{"id":2,"description":"other","created_from":{"id":43}}],"meta":{"pagination":{"next_page":null}}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.ServerID = 42
+	driver.getClient().Endpoint = server.URL
+
+	snapshots, err := driver.ListSnapshots()
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{
+		{ID: "1", Name: "clean", Created: time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC)},
+	}, snapshots)
+}
+
+func TestServerHasIPv6Address(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"server":{"id":42,"status":"running","public_net":{"ipv4":{"ip":"1.2.3.4"},"ipv6":{"ip":"2001:db8:1:2::/64"}}}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.ServerID = 42
+	driver.getClient().Endpoint = server.URL
+
+	assert.True(t, driver.serverHasAddress())
+	assert.Equal(t, "1.2.3.4", driver.IPAddress)
+
+	driver.UseIPv6 = true
+	assert.True(t, driver.serverHasAddress())
+	assert.Equal(t, "2001:db8:1:2::1", driver.IPAddress)
+}
@@ -0,0 +1,117 @@
+package hetzner
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// actionPollInterval is the delay between two checks of a running action.
+var actionPollInterval = 5 * time.Second
+
+// The snapshots of a server are images named by their description, restored
+// by rebuilding the server from them.
+
+func (d *Driver) CreateSnapshot(name string) (*drivers.Snapshot, error) {
+	image, action, err := d.getClient().CreateSnapshotImage(d.ServerID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Waiting for the snapshot to complete...")
+	if err := d.waitForAction(action); err != nil {
+		return nil, err
+	}
+
+	snapshot := imageSnapshot(*image)
+	return &snapshot, nil
+}
+
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	images, err := d.getClient().ListSnapshotImages(d.ServerID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []drivers.Snapshot{}
+	for _, image := range images {
+		snapshots = append(snapshots, imageSnapshot(image))
+	}
+
+	return snapshots, nil
+}
+
+func imageSnapshot(image Image) drivers.Snapshot {
+	snapshot := drivers.Snapshot{
+		ID:   strconv.Itoa(image.ID),
+		Name: image.Description,
+	}
+
+	if created, err := time.Parse(time.RFC3339, image.Created); err == nil {
+		snapshot.Created = created.UTC()
+	}
+
+	return snapshot
+}
+
+func (d *Driver) RestoreSnapshot(name string) error {
+	imageID, err := d.findSnapshotImage(name)
+	if err != nil {
+		return err
+	}
+
+	action, err := d.getClient().RebuildServer(d.ServerID, imageID)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Waiting for the server to be rebuilt...")
+	return d.waitForAction(action)
+}
+
+func (d *Driver) DeleteSnapshot(name string) error {
+	imageID, err := d.findSnapshotImage(name)
+	if err != nil {
+		return err
+	}
+
+	return d.getClient().DeleteImage(imageID)
+}
+
+func (d *Driver) findSnapshotImage(name string) (int, error) {
+	snapshots, err := d.ListSnapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	snapshot, err := drivers.FindSnapshot(snapshots, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(snapshot.ID)
+}
+
+func (d *Driver) waitForAction(action *Action) error {
+	for action.Status == "running" {
+		time.Sleep(actionPollInterval)
+
+		var err error
+		if action, err = d.getClient().GetAction(action.ID); err != nil {
+			return err
+		}
+	}
+
+	if action.Status == "error" && action.Error != nil {
+		return fmt.Errorf("hetzner action %d failed: %s", action.ID, action.Error.Message)
+	}
+
+	if action.Status != "success" {
+		return fmt.Errorf("hetzner action %d ended as %s", action.ID, action.Status)
+	}
+
+	return nil
+}
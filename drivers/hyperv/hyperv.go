@@ -8,10 +8,12 @@ import (
 
 	"errors"
 
+	"github.com/docker/machine/libmachine/autosize"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/osimage"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 )
@@ -19,27 +21,41 @@ import (
 type Driver struct {
 	*drivers.BaseDriver
 	Boot2DockerURL string
+	OSImage        string
 	VSwitch        string
 	DiskSize       int
 	MemSize        int
 	CPU            int
 	MacAddr        string
 	VLanID         int
+	Generation     int
+	SecureBoot     bool
+	NestedVirt     bool
+	MemoryMin      int
+	MemoryMax      int
+	NAT            bool
+	NATSubnet      string
+	NATDNS         string
+	NATAddress     string
 }
 
 const (
-	defaultDiskSize = 20000
-	defaultMemory   = 1024
-	defaultCPU      = 1
-	defaultVLanID   = 0
+	defaultDiskSize   = 20000
+	defaultMemory     = 1024
+	defaultCPU        = 1
+	defaultVLanID     = 0
+	defaultGeneration = 1
 )
 
 // NewDriver creates a new Hyper-v driver with default settings.
 func NewDriver(hostName, storePath string) *Driver {
 	return &Driver{
-		DiskSize: defaultDiskSize,
-		MemSize:  defaultMemory,
-		CPU:      defaultCPU,
+		DiskSize:   defaultDiskSize,
+		MemSize:    defaultMemory,
+		CPU:        defaultCPU,
+		Generation: defaultGeneration,
+		NATSubnet:  defaultNATSubnet,
+		NATDNS:     defaultNATDNS,
 		BaseDriver: &drivers.BaseDriver{
 			MachineName: hostName,
 			StorePath:   storePath,
@@ -56,6 +72,12 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "URL of the boot2docker ISO. Defaults to the latest available version.",
 			EnvVar: "HYPERV_BOOT2DOCKER_URL",
 		},
+		mcnflag.StringFlag{
+			Name:   "hyperv-os-image",
+			Usage:  "The OS image the machine boots from: boot2docker, alpine or fcos",
+			Value:  osimage.Boot2Docker,
+			EnvVar: "HYPERV_OS_IMAGE",
+		},
 		mcnflag.StringFlag{
 			Name:   "hyperv-virtual-switch",
 			Usage:  "Virtual switch name. Defaults to first found.",
@@ -90,6 +112,49 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  defaultVLanID,
 			EnvVar: "HYPERV_VLAN_ID",
 		},
+		mcnflag.BoolFlag{
+			Name:   "hyperv-nat",
+			Usage:  "Create a virtual switch with NAT, unless it exists, and give the machine a static address on it",
+			EnvVar: "HYPERV_NAT",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperv-nat-subnet",
+			Usage:  "Subnet of the NAT virtual switch, whose first address is the gateway on the host",
+			Value:  defaultNATSubnet,
+			EnvVar: "HYPERV_NAT_SUBNET",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperv-nat-dns",
+			Usage:  "DNS server of the machines on the NAT virtual switch",
+			Value:  defaultNATDNS,
+			EnvVar: "HYPERV_NAT_DNS",
+		},
+		mcnflag.BoolFlag{
+			Name:   "hyperv-nested-virt",
+			Usage:  "Expose the virtualization extensions of the CPU to run VMs in the machine",
+			EnvVar: "HYPERV_NESTED_VIRT",
+		},
+		mcnflag.IntFlag{
+			Name:   "hyperv-memory-min",
+			Usage:  "Minimum memory of the machine in MB, enabling dynamic memory",
+			EnvVar: "HYPERV_MEMORY_MIN",
+		},
+		mcnflag.IntFlag{
+			Name:   "hyperv-memory-max",
+			Usage:  "Maximum memory of the machine in MB, enabling dynamic memory",
+			EnvVar: "HYPERV_MEMORY_MAX",
+		},
+		mcnflag.IntFlag{
+			Name:   "hyperv-generation",
+			Usage:  "Generation of the VM: 1 (BIOS) or 2 (UEFI)",
+			Value:  defaultGeneration,
+			EnvVar: "HYPERV_GENERATION",
+		},
+		mcnflag.BoolFlag{
+			Name:   "hyperv-secure-boot",
+			Usage:  "Enable secure boot with the Microsoft UEFI CA template for Linux, on a generation 2 VM",
+			EnvVar: "HYPERV_SECURE_BOOT",
+		},
 	}
 }
 
@@ -105,11 +170,91 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.MacAddr = flags.String("hyperv-static-macaddress")
 	d.VLanID = flags.Int("hyperv-vlan-id")
 	d.SSHUser = "docker"
+	d.OSImage = flags.String("hyperv-os-image")
+	if !osimage.IsBoot2Docker(d.OSImage) {
+		if err := osimage.ValidateForDriver(d.DriverName(), d.OSImage); err != nil {
+			return err
+		}
+		if d.Boot2DockerURL != "" {
+			return errors.New("--hyperv-boot2docker-url can only be used with the boot2docker OS image")
+		}
+		d.SSHUser = osimage.SSHUser(d.OSImage)
+	}
+	d.NAT = flags.Bool("hyperv-nat")
+	d.NATSubnet = flags.String("hyperv-nat-subnet")
+	d.NATDNS = flags.String("hyperv-nat-dns")
+	d.NestedVirt = flags.Bool("hyperv-nested-virt")
+	d.MemoryMin = flags.Int("hyperv-memory-min")
+	d.MemoryMax = flags.Int("hyperv-memory-max")
+	d.Generation = flags.Int("hyperv-generation")
+	d.SecureBoot = flags.Bool("hyperv-secure-boot")
 	d.SetSwarmConfigFromFlags(flags)
 
+	if flags.Bool("auto-size") {
+		if err := d.autoSize(); err != nil {
+			return err
+		}
+	}
+
+	if d.NAT {
+		if d.VSwitch == "" {
+			d.VSwitch = defaultNATSwitch
+		}
+		if _, err := parseNATSubnet(d.NATSubnet); err != nil {
+			return err
+		}
+	}
+
+	if d.Generation != 1 && d.Generation != 2 {
+		return fmt.Errorf("invalid generation %d, must be 1 or 2", d.Generation)
+	}
+
+	if d.Generation == 2 && osimage.IsBoot2Docker(d.OSImage) {
+		return errors.New("boot2docker doesn't boot with UEFI, use --hyperv-os-image with --hyperv-generation 2")
+	}
+
+	if d.SecureBoot && d.Generation != 2 {
+		return errors.New("--hyperv-secure-boot requires --hyperv-generation 2")
+	}
+
+	if d.dynamicMemory() {
+		if d.NestedVirt {
+			return errors.New("--hyperv-nested-virt can't be used with dynamic memory")
+		}
+		if d.MemoryMin > d.MemSize || (d.MemoryMax > 0 && d.MemoryMax < d.MemSize) {
+			return fmt.Errorf("the memory of %dMB must be between --hyperv-memory-min and --hyperv-memory-max", d.MemSize)
+		}
+	}
+
 	return nil
 }
 
+// autoSize sizes the machine from the resources of the host, keeping the
+// sizes given explicitly.  The memory stays within the bounds of the dynamic
+// memory.
+func (d *Driver) autoSize() error {
+	host, err := autosize.Detect(d.StorePath)
+	if err != nil {
+		return err
+	}
+
+	defaults := autosize.Sizes{CPU: defaultCPU, Memory: defaultMemory, DiskSize: defaultDiskSize}
+	sizes := autosize.Sizes{CPU: d.CPU, Memory: d.MemSize, DiskSize: d.DiskSize}.Fill(defaults, host.Sizes())
+	if d.MemoryMax > 0 && sizes.Memory > d.MemoryMax {
+		sizes.Memory = d.MemoryMax
+	}
+	d.CPU, d.MemSize, d.DiskSize = sizes.CPU, sizes.Memory, sizes.DiskSize
+
+	log.Infof("Sizing the machine for the host: %s", sizes)
+	return nil
+}
+
+// dynamicMemory returns true if Hyper-V balances the memory of the machine
+// between bounds.
+func (d *Driver) dynamicMemory() bool {
+	return d.MemoryMin > 0 || d.MemoryMax > 0
+}
+
 func (d *Driver) GetSSHHostname() (string, error) {
 	return d.GetIP()
 }
@@ -174,15 +319,22 @@ func (d *Driver) PreCreateCheck() error {
 		return ErrNotAdministrator
 	}
 
-	// Check that there is a virtual switch already configured
-	if _, err := d.chooseVirtualSwitch(); err != nil {
-		return err
+	// Check that there is a virtual switch already configured, unless the
+	// NAT switch is created
+	if !d.NAT {
+		if _, err := d.chooseVirtualSwitch(); err != nil {
+			return err
+		}
 	}
 
 	// Downloading boot2docker to cache should be done here to make sure
 	// that a download failure will not leave a machine half created.
-	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.UpdateISOCache(d.Boot2DockerURL); err != nil {
+	if osimage.IsBoot2Docker(d.OSImage) {
+		b2dutils := mcnutils.NewB2dUtils(d.StorePath)
+		if err := b2dutils.UpdateISOCache(d.Boot2DockerURL); err != nil {
+			return err
+		}
+	} else if err := osimage.UpdateCache(d.StorePath, d.OSImage); err != nil {
 		return err
 	}
 
@@ -190,8 +342,12 @@ func (d *Driver) PreCreateCheck() error {
 }
 
 func (d *Driver) Create() error {
-	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+	if osimage.IsBoot2Docker(d.OSImage) {
+		b2dutils := mcnutils.NewB2dUtils(d.StorePath)
+		if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+			return err
+		}
+	} else if err := osimage.CopyToMachineDir(d.StorePath, d.OSImage, d.MachineName); err != nil {
 		return err
 	}
 
@@ -200,10 +356,27 @@ func (d *Driver) Create() error {
 		return err
 	}
 
+	if !osimage.IsBoot2Docker(d.OSImage) {
+		if err := osimage.Personalize(d.OSImage, d.ResolveStorePath("boot2docker.iso"), d.publicSSHKeyPath()); err != nil {
+			return err
+		}
+	}
+
 	log.Infof("Creating VM...")
-	virtualSwitch, err := d.chooseVirtualSwitch()
-	if err != nil {
-		return err
+	var virtualSwitch string
+	if d.NAT {
+		if err := d.ensureNATSwitch(); err != nil {
+			return err
+		}
+		if err := d.chooseNATAddress(); err != nil {
+			return err
+		}
+		virtualSwitch = d.VSwitch
+	} else {
+		var err error
+		if virtualSwitch, err = d.chooseVirtualSwitch(); err != nil {
+			return err
+		}
 	}
 
 	log.Infof("Using switch %q", virtualSwitch)
@@ -217,10 +390,39 @@ func (d *Driver) Create() error {
 		d.MachineName,
 		"-Path", fmt.Sprintf("'%s'", d.ResolveStorePath(".")),
 		"-SwitchName", quote(virtualSwitch),
+		"-Generation", fmt.Sprintf("%d", d.Generation),
 		"-MemoryStartupBytes", toMb(d.MemSize)); err != nil {
 		return err
 	}
 
+	if d.dynamicMemory() {
+		args := []string{"-VMName", d.MachineName, "-DynamicMemoryEnabled", "$true"}
+		if d.MemoryMin > 0 {
+			args = append(args, "-MinimumBytes", toMb(d.MemoryMin))
+		}
+		if d.MemoryMax > 0 {
+			args = append(args, "-MaximumBytes", toMb(d.MemoryMax))
+		}
+		if err := cmd(append([]string{"Set-VMMemory"}, args...)...); err != nil {
+			return err
+		}
+	}
+
+	if d.NestedVirt {
+		if err := cmd("Set-VMProcessor",
+			d.MachineName,
+			"-ExposeVirtualizationExtensions", "$true"); err != nil {
+			return err
+		}
+
+		// The VMs nested in the machine send packets from their own MAC
+		if err := cmd("Set-VMNetworkAdapter",
+			"-VMName", d.MachineName,
+			"-MacAddressSpoofing", "On"); err != nil {
+			return err
+		}
+	}
+
 	if d.CPU > 1 {
 		if err := cmd("Set-VMProcessor",
 			d.MachineName,
@@ -246,7 +448,25 @@ func (d *Driver) Create() error {
 		}
 	}
 
-	if err := cmd("Set-VMDvdDrive",
+	if d.Generation == 2 {
+		// The generation 2 VMs have no DVD drive, nor IDE controller
+		if err := cmd("Add-VMDvdDrive",
+			"-VMName", d.MachineName,
+			"-Path", quote(d.ResolveStorePath("boot2docker.iso"))); err != nil {
+			return err
+		}
+
+		secureBoot := []string{"-EnableSecureBoot", "Off"}
+		if d.SecureBoot {
+			// The Windows template only trusts the Microsoft Windows CA
+			secureBoot = []string{"-EnableSecureBoot", "On", "-SecureBootTemplate", "MicrosoftUEFICertificateAuthority"}
+		}
+		if err := cmd(append([]string{"Set-VMFirmware",
+			"-VMName", d.MachineName,
+			"-FirstBootDevice", "(Get-VMDvdDrive", "-VMName", d.MachineName + ")"}, secureBoot...)...); err != nil {
+			return err
+		}
+	} else if err := cmd("Set-VMDvdDrive",
 		"-VMName", d.MachineName,
 		"-Path", quote(d.ResolveStorePath("boot2docker.iso"))); err != nil {
 		return err
@@ -303,6 +523,13 @@ func (d *Driver) waitForIP() (string, error) {
 			return ip, nil
 		}
 
+		// The address is applied once the KVP daemon of the guest runs
+		if d.NAT {
+			if err := d.setGuestAddress(); err != nil {
+				log.Debugf("Error setting the address of the guest: %s", err)
+			}
+		}
+
 		time.Sleep(1 * time.Second)
 	}
 }
@@ -369,7 +596,15 @@ func (d *Driver) Remove() error {
 		}
 	}
 
-	return cmd("Remove-VM", d.MachineName, "-Force")
+	if err := cmd("Remove-VM", d.MachineName, "-Force"); err != nil {
+		return err
+	}
+
+	if d.NAT {
+		return d.removeNATSwitch()
+	}
+
+	return nil
 }
 
 // Restart stops and starts an host
@@ -426,6 +661,10 @@ func (d *Driver) publicSSHKeyPath() string {
 // generateDiskImage creates a small fixed vhd, put the tar in, convert to dynamic, then resize
 func (d *Driver) generateDiskImage() (string, error) {
 	diskImage := d.ResolveStorePath("disk.vhd")
+	if d.Generation == 2 {
+		// The generation 2 VMs only boot from VHDX
+		diskImage = d.ResolveStorePath("disk.vhdx")
+	}
 	fixed := d.ResolveStorePath("fixed.vhd")
 
 	// Resizing vhds requires administrator priviledges
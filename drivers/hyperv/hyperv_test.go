@@ -2,6 +2,7 @@ package hyperv
 
 import (
 	"testing"
+	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/stretchr/testify/assert"
@@ -66,3 +67,117 @@ func TestSetConfigFromCustomFlags(t *testing.T) {
 	assert.Equal(t, 2, driver.VLanID)
 	assert.Equal(t, "docker", driver.GetSSHUsername())
 }
+
+func TestParseSnapshots(t *testing.T) {
+	snapshots, err := parseSnapshots("3c7b1e2a-0000-0000-0000-000000000001|2026-10-16T08:30:00.0000000Z|clean|base\r\n\r\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(snapshots))
+	assert.Equal(t, "3c7b1e2a-0000-0000-0000-000000000001", snapshots[0].ID)
+	assert.Equal(t, "clean|base", snapshots[0].Name)
+	assert.Equal(t, time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC), snapshots[0].Created)
+}
+
+func TestSetConfigFromOSImageFlag(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"hyperv-os-image": "fcos",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+	assert.NoError(t, err)
+	assert.Equal(t, "fcos", driver.OSImage)
+	assert.Equal(t, "core", driver.GetSSHUsername())
+
+	checkFlags.FlagsValues["hyperv-boot2docker-url"] = "B2D_URL"
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestSetConfigFromNATFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"hyperv-nat": true,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+	assert.NoError(t, err)
+	assert.True(t, driver.NAT)
+	assert.Equal(t, defaultNATSwitch, driver.VSwitch)
+	assert.Equal(t, defaultNATSubnet, driver.NATSubnet)
+
+	checkFlags.FlagsValues["hyperv-nat-subnet"] = "10.0.0.0/30"
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestSetConfigFromGenerationFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"hyperv-generation":  2,
+			"hyperv-secure-boot": true,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.EqualError(t, driver.SetConfigFromFlags(checkFlags), "boot2docker doesn't boot with UEFI, use --hyperv-os-image with --hyperv-generation 2")
+
+	checkFlags.FlagsValues["hyperv-os-image"] = "fcos"
+	assert.NoError(t, driver.SetConfigFromFlags(checkFlags))
+	assert.Equal(t, 2, driver.Generation)
+	assert.True(t, driver.SecureBoot)
+
+	checkFlags.FlagsValues["hyperv-generation"] = 1
+	assert.EqualError(t, driver.SetConfigFromFlags(checkFlags), "--hyperv-secure-boot requires --hyperv-generation 2")
+}
+
+func TestSetConfigFromDynamicMemoryFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"hyperv-memory":     2048,
+			"hyperv-memory-min": 512,
+			"hyperv-memory-max": 4096,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.NoError(t, driver.SetConfigFromFlags(checkFlags))
+	assert.True(t, driver.dynamicMemory())
+
+	checkFlags.FlagsValues["hyperv-memory-max"] = 1024
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+
+	checkFlags.FlagsValues["hyperv-memory-max"] = 4096
+	checkFlags.FlagsValues["hyperv-nested-virt"] = true
+	assert.EqualError(t, driver.SetConfigFromFlags(checkFlags), "--hyperv-nested-virt can't be used with dynamic memory")
+}
+
+func TestNATNetwork(t *testing.T) {
+	network, err := parseNATSubnet("172.29.128.0/24")
+	assert.NoError(t, err)
+	assert.Equal(t, "172.29.128.1", network.gateway.String())
+	assert.Equal(t, 24, network.prefixLength())
+	assert.Equal(t, "255.255.255.0", network.mask())
+
+	ip, err := network.freeAddress([]string{"172.29.128.2", "fe80::1", "172.29.128.4"})
+	assert.NoError(t, err)
+	assert.Equal(t, "172.29.128.3", ip)
+
+	network, err = parseNATSubnet("10.0.0.0/29")
+	assert.NoError(t, err)
+	_, err = network.freeAddress([]string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"})
+	assert.EqualError(t, err, "no free address in the NAT subnet 10.0.0.0/29")
+
+	_, err = parseNATSubnet("fd00::/64")
+	assert.Error(t, err)
+}
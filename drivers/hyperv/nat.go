@@ -0,0 +1,204 @@
+package hyperv
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	defaultNATSwitch = "Docker Machine NAT"
+	defaultNATSubnet = "172.29.128.0/24"
+	defaultNATDNS    = "8.8.8.8"
+)
+
+// Hyper-V NAT doesn't serve DHCP: the address of the machine is set through
+// the KVP integration service, applied by hv_kvp_daemon in the guest.
+const setGuestAddressScript = `$vm = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_ComputerSystem -Filter "ElementName='%s'"; ` +
+	`$settings = $vm.GetRelated('Msvm_VirtualSystemSettingData') | Where-Object { $_.VirtualSystemType -eq 'Microsoft:Hyper-V:System:Realized' }; ` +
+	`$port = $settings.GetRelated('Msvm_SyntheticEthernetPortSettingData') | Select-Object -First 1; ` +
+	`$config = $port.GetRelated('Msvm_GuestNetworkAdapterConfiguration') | Select-Object -First 1; ` +
+	`$config.DHCPEnabled = $false; ` +
+	`$config.IPAddresses = @('%s'); ` +
+	`$config.Subnets = @('%s'); ` +
+	`$config.DefaultGateways = @('%s'); ` +
+	`$config.DNSServers = @('%s'); ` +
+	`$service = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_VirtualSystemManagementService; ` +
+	`$result = $service.SetGuestNetworkAdapterConfiguration($vm, @($config.GetText(1))); ` +
+	`if ($result.ReturnValue -ne 0 -and $result.ReturnValue -ne 4096) { throw "SetGuestNetworkAdapterConfiguration returned $($result.ReturnValue)" }`
+
+// natNetwork is the subnet of the NAT switch, whose first address is the
+// gateway on the host.
+type natNetwork struct {
+	subnet  *net.IPNet
+	gateway net.IP
+}
+
+func parseNATSubnet(subnet string) (*natNetwork, error) {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NAT subnet %q: %s", subnet, err)
+	}
+
+	ip := ipNet.IP.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid NAT subnet %q: not an IPv4 subnet", subnet)
+	}
+
+	if ones, _ := ipNet.Mask.Size(); ones > 29 {
+		return nil, fmt.Errorf("invalid NAT subnet %q: too small", subnet)
+	}
+
+	return &natNetwork{subnet: ipNet, gateway: nextIP(ip)}, nil
+}
+
+func (n *natNetwork) prefixLength() int {
+	ones, _ := n.subnet.Mask.Size()
+	return ones
+}
+
+func (n *natNetwork) mask() string {
+	return net.IP(n.subnet.Mask).String()
+}
+
+// freeAddress returns the first address of the subnet after the gateway
+// which isn't used.
+func (n *natNetwork) freeAddress(used []string) (string, error) {
+	inUse := map[string]bool{}
+	for _, ip := range used {
+		inUse[ip] = true
+	}
+
+	broadcast := make(net.IP, len(n.subnet.IP.To4()))
+	for i, b := range n.subnet.IP.To4() {
+		broadcast[i] = b | ^n.subnet.Mask[i]
+	}
+
+	for ip := nextIP(n.gateway); n.subnet.Contains(ip) && !ip.Equal(broadcast); ip = nextIP(ip) {
+		if !inUse[ip.String()] {
+			return ip.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no free address in the NAT subnet %s", n.subnet)
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip.To4()))
+	copy(next, ip.To4())
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// ensureNATSwitch creates the internal switch, the address of the host on
+// it and the NAT, unless they exist.
+func (d *Driver) ensureNATSwitch() error {
+	network, err := parseNATSubnet(d.NATSubnet)
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmdOut("(Get-VMSwitch", "-Name", quote(d.VSwitch), "-ErrorAction", "SilentlyContinue).Name")
+	if err != nil {
+		return err
+	}
+
+	if len(parseLines(stdout)) == 0 {
+		log.Infof("Creating the NAT virtual switch %q on %s...", d.VSwitch, network.subnet)
+
+		if err := cmd("New-VMSwitch", "-Name", quote(d.VSwitch), "-SwitchType", "Internal"); err != nil {
+			return err
+		}
+
+		if err := cmd("New-NetIPAddress",
+			"-IPAddress", network.gateway.String(),
+			"-PrefixLength", strconv.Itoa(network.prefixLength()),
+			"-InterfaceAlias", quote(fmt.Sprintf("vEthernet (%s)", d.VSwitch))); err != nil {
+			return err
+		}
+	}
+
+	stdout, err = cmdOut("(Get-NetNat", "-Name", quote(d.VSwitch), "-ErrorAction", "SilentlyContinue).Name")
+	if err != nil {
+		return err
+	}
+
+	if len(parseLines(stdout)) == 0 {
+		if err := cmd("New-NetNat", "-Name", quote(d.VSwitch), "-InternalIPInterfaceAddressPrefix", network.subnet.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// switchAddresses returns the addresses of the machines on the switch.
+func (d *Driver) switchAddresses() ([]string, error) {
+	stdout, err := cmdOut("Get-VMNetworkAdapter", "-All", "|", "Where-Object", "{", "$_.SwitchName", "-eq", quote(d.VSwitch), "}", "|", "ForEach-Object", "{", "$_.IPAddresses", "}")
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{}
+	for _, line := range parseLines(stdout) {
+		if line = strings.TrimSpace(line); line != "" {
+			addresses = append(addresses, line)
+		}
+	}
+	return addresses, nil
+}
+
+// chooseNATAddress picks the address of the machine on the NAT switch.
+func (d *Driver) chooseNATAddress() error {
+	network, err := parseNATSubnet(d.NATSubnet)
+	if err != nil {
+		return err
+	}
+
+	used, err := d.switchAddresses()
+	if err != nil {
+		return err
+	}
+
+	d.NATAddress, err = network.freeAddress(used)
+	return err
+}
+
+// setGuestAddress sends the address of the machine on the NAT switch to the
+// guest.
+func (d *Driver) setGuestAddress() error {
+	network, err := parseNATSubnet(d.NATSubnet)
+	if err != nil {
+		return err
+	}
+
+	return cmd(fmt.Sprintf(setGuestAddressScript, d.MachineName, d.NATAddress, network.mask(), network.gateway, d.NATDNS))
+}
+
+// removeNATSwitch removes the NAT switch once no machine is connected to it.
+func (d *Driver) removeNATSwitch() error {
+	stdout, err := cmdOut("(Get-VMNetworkAdapter", "-All", "|", "Where-Object", "{", "$_.SwitchName", "-eq", quote(d.VSwitch), "}).Count")
+	if err != nil {
+		return err
+	}
+
+	if resp := parseLines(stdout); len(resp) > 0 && strings.TrimSpace(resp[0]) != "0" {
+		return nil
+	}
+
+	log.Infof("Removing the NAT virtual switch %q...", d.VSwitch)
+
+	if err := cmd("Remove-NetNat", "-Name", quote(d.VSwitch), "-Confirm:$false", "-ErrorAction", "SilentlyContinue"); err != nil {
+		return err
+	}
+
+	return cmd("Remove-VMSwitch", "-Name", quote(d.VSwitch), "-Force")
+}
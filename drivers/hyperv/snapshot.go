@@ -0,0 +1,115 @@
+package hyperv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// Hyper-V calls the snapshots checkpoints.  They are listed one per line as
+// id|creation time|name, the name coming last since it may contain a |.
+const listSnapshotsFormat = `ForEach-Object { $_.Id.Guid + '|' + $_.CreationTime.ToUniversalTime().ToString('o') + '|' + $_.Name }`
+
+func (d *Driver) CreateSnapshot(name string) (*drivers.Snapshot, error) {
+	if err := cmd("Checkpoint-VM", "-Name", d.MachineName, "-SnapshotName", quote(name)); err != nil {
+		return nil, err
+	}
+
+	snapshots, err := d.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	return drivers.FindSnapshot(snapshots, name)
+}
+
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	stdout, err := cmdOut("Get-VMSnapshot", "-VMName", d.MachineName, "|", listSnapshotsFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSnapshots(stdout)
+}
+
+func parseSnapshots(stdout string) ([]drivers.Snapshot, error) {
+	snapshots := []drivers.Snapshot{}
+
+	for _, line := range parseLines(stdout) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "|", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("Unexpected checkpoint description: %q", line)
+		}
+
+		created, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, drivers.Snapshot{
+			ID:      fields[0],
+			Name:    fields[2],
+			Created: created,
+		})
+	}
+
+	return snapshots, nil
+}
+
+// RestoreSnapshot applies a checkpoint.  Production checkpoints don't include
+// the memory of the VM, so it's started again if it's left off.
+func (d *Driver) RestoreSnapshot(name string) error {
+	snapshot, err := d.findSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd("Get-VMSnapshot", "-VMName", d.MachineName, "|", "Where-Object", "{", "$_.Id", "-eq", quote(snapshot.ID), "}", "|", "Restore-VMSnapshot", "-Confirm:$false"); err != nil {
+		return err
+	}
+
+	if s != state.Running {
+		return nil
+	}
+
+	s, err = d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Stopped {
+		return d.Start()
+	}
+
+	return nil
+}
+
+func (d *Driver) DeleteSnapshot(name string) error {
+	snapshot, err := d.findSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	return cmd("Get-VMSnapshot", "-VMName", d.MachineName, "|", "Where-Object", "{", "$_.Id", "-eq", quote(snapshot.ID), "}", "|", "Remove-VMSnapshot", "-Confirm:$false")
+}
+
+func (d *Driver) findSnapshot(name string) (*drivers.Snapshot, error) {
+	snapshots, err := d.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	return drivers.FindSnapshot(snapshots, name)
+}
@@ -0,0 +1,97 @@
+package kvm
+
+import (
+	"bytes"
+	"text/template"
+)
+
+const domainTemplate = `<domain type='kvm'{{if eq .NetworkMode "user"}} xmlns:qemu='http://libvirt.org/schemas/domain/qemu/1.0'{{end}}>
+  <name>{{html .Name}}</name>
+  <memory unit='MiB'>{{.Memory}}</memory>
+  <vcpu>{{.CPU}}</vcpu>
+  <os>
+    <type>hvm</type>
+    <boot dev='cdrom'/>
+    <boot dev='hd'/>
+    <bootmenu enable='no'/>
+  </os>
+  <features>
+    <acpi/>
+    <apic/>
+    <pae/>
+  </features>
+  <cpu mode='host-passthrough'/>
+  <devices>
+    <disk type='file' device='cdrom'>
+      <source file='{{html .ISOPath}}'/>
+      <target dev='hdc' bus='ide'/>
+      <readonly/>
+    </disk>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='raw' cache='none' io='threads'/>
+      <source file='{{html .DiskPath}}'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+{{- if eq .NetworkMode "network"}}
+    <interface type='network'>
+      <mac address='{{.MACAddress}}'/>
+      <source network='{{html .Network}}'/>
+      <model type='virtio'/>
+    </interface>
+{{- else if eq .NetworkMode "bridge"}}
+    <interface type='bridge'>
+      <mac address='{{.MACAddress}}'/>
+      <source bridge='{{html .Bridge}}'/>
+      <model type='virtio'/>
+    </interface>
+{{- end}}
+    <serial type='pty'>
+      <target port='0'/>
+    </serial>
+    <console type='pty'>
+      <target type='serial' port='0'/>
+    </console>
+    <rng model='virtio'>
+      <backend model='random'>/dev/urandom</backend>
+    </rng>
+  </devices>
+{{- if eq .NetworkMode "user"}}
+  <qemu:commandline>
+    <qemu:arg value='-netdev'/>
+    <qemu:arg value='user,id=usernet,hostfwd=tcp:127.0.0.1:{{.SSHPort}}-:22,hostfwd=tcp:127.0.0.1:{{.EnginePort}}-:2376'/>
+    <qemu:arg value='-device'/>
+    <qemu:arg value='virtio-net-pci,netdev=usernet,mac={{.MACAddress}}'/>
+  </qemu:commandline>
+{{- end}}
+</domain>
+`
+
+// domainConfig is given to the template of the domain.
+type domainConfig struct {
+	Name        string
+	Memory      int
+	CPU         int
+	ISOPath     string
+	DiskPath    string
+	NetworkMode string
+	Network     string
+	Bridge      string
+	MACAddress  string
+	SSHPort     int
+	EnginePort  int
+}
+
+// domainXML returns the libvirt definition of the domain of a machine.
+func domainXML(config *domainConfig) (string, error) {
+	t, err := template.New("domain").Parse(domainTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, config); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,586 @@
+package kvm
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/autosize"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	ConnectionURI  string
+	Boot2DockerURL string
+	DiskSize       int
+	Memory         int
+	CPU            int
+	StoragePool    string
+	NetworkMode    string
+	Network        string
+	Bridge         string
+	MACAddress     string
+	EnginePort     int
+	virsh          Virsh
+}
+
+const (
+	defaultConnectionURI = "qemu:///system"
+	defaultDiskSize      = 20000
+	defaultMemory        = 1024
+	defaultCPU           = 1
+	defaultStoragePool   = "default"
+	defaultNetworkMode   = "network"
+	defaultNetwork       = "default"
+	defaultEnginePort    = 2376
+	localhost            = "127.0.0.1"
+)
+
+// NewDriver creates a new KVM driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		ConnectionURI: defaultConnectionURI,
+		DiskSize:      defaultDiskSize,
+		Memory:        defaultMemory,
+		CPU:           defaultCPU,
+		StoragePool:   defaultStoragePool,
+		NetworkMode:   defaultNetworkMode,
+		Network:       defaultNetwork,
+		EnginePort:    defaultEnginePort,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+			SSHUser:     "docker",
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			Name:   "kvm-connection-uri",
+			Usage:  "URI of the libvirt daemon, e.g. qemu:///system or qemu:///session",
+			Value:  defaultConnectionURI,
+			EnvVar: "KVM_CONNECTION_URI",
+		},
+		mcnflag.StringFlag{
+			Name:   "kvm-boot2docker-url",
+			Usage:  "URL of the boot2docker ISO. Defaults to the latest available version.",
+			EnvVar: "KVM_BOOT2DOCKER_URL",
+		},
+		mcnflag.IntFlag{
+			Name:   "kvm-disk-size",
+			Usage:  "Size of the disk for host in MB.",
+			Value:  defaultDiskSize,
+			EnvVar: "KVM_DISK_SIZE",
+		},
+		mcnflag.IntFlag{
+			Name:   "kvm-memory",
+			Usage:  "Memory size for host in MB.",
+			Value:  defaultMemory,
+			EnvVar: "KVM_MEMORY",
+		},
+		mcnflag.IntFlag{
+			Name:   "kvm-cpu-count",
+			Usage:  "number of CPUs for the machine",
+			Value:  defaultCPU,
+			EnvVar: "KVM_CPU_COUNT",
+		},
+		mcnflag.StringFlag{
+			Name:   "kvm-storage-pool",
+			Usage:  "libvirt storage pool the volumes of the machine are created in",
+			Value:  defaultStoragePool,
+			EnvVar: "KVM_STORAGE_POOL",
+		},
+		mcnflag.StringFlag{
+			Name:   "kvm-network-mode",
+			Usage:  "Networking of the machine: network (a libvirt network), bridge (a bridge of the host) or user (user-mode networking with forwarded ports)",
+			Value:  defaultNetworkMode,
+			EnvVar: "KVM_NETWORK_MODE",
+		},
+		mcnflag.StringFlag{
+			Name:   "kvm-network",
+			Usage:  "libvirt network of the machine in network mode",
+			Value:  defaultNetwork,
+			EnvVar: "KVM_NETWORK",
+		},
+		mcnflag.StringFlag{
+			Name:   "kvm-bridge",
+			Usage:  "Bridge of the host the machine is attached to in bridge mode, e.g. br0",
+			EnvVar: "KVM_BRIDGE",
+		},
+	}
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	if drivers.EngineInstallURLFlagSet(flags) {
+		return errors.New("--engine-install-url cannot be used with the kvm driver, use --kvm-boot2docker-url instead")
+	}
+	d.ConnectionURI = flags.String("kvm-connection-uri")
+	d.Boot2DockerURL = flags.String("kvm-boot2docker-url")
+	d.DiskSize = flags.Int("kvm-disk-size")
+	d.Memory = flags.Int("kvm-memory")
+	d.CPU = flags.Int("kvm-cpu-count")
+	d.StoragePool = flags.String("kvm-storage-pool")
+	d.NetworkMode = flags.String("kvm-network-mode")
+	d.Network = flags.String("kvm-network")
+	d.Bridge = flags.String("kvm-bridge")
+	d.SSHUser = "docker"
+	d.SetSwarmConfigFromFlags(flags)
+
+	switch d.NetworkMode {
+	case "network", "user":
+	case "bridge":
+		if d.Bridge == "" {
+			return errors.New("kvm driver requires the --kvm-bridge option in bridge mode")
+		}
+	default:
+		return fmt.Errorf("Invalid KVM network mode %q, expected network, bridge or user", d.NetworkMode)
+	}
+
+	if flags.Bool("auto-size") {
+		return d.autoSize()
+	}
+
+	return nil
+}
+
+// autoSize sizes the machine from the resources of the host, keeping the
+// sizes given explicitly.  The disk is sized from the free space of the
+// storage pool, which holds the volume of the machine.
+func (d *Driver) autoSize() error {
+	if !strings.HasPrefix(d.ConnectionURI, "qemu:///") {
+		return fmt.Errorf("--auto-size requires a local libvirt daemon, not %s", d.ConnectionURI)
+	}
+
+	host, err := autosize.Detect(d.StorePath)
+	if err != nil {
+		return err
+	}
+
+	available, err := d.poolAvailable()
+	if err != nil {
+		return err
+	}
+	host.FreeDisk = int(available >> 20)
+
+	defaults := autosize.Sizes{CPU: defaultCPU, Memory: defaultMemory, DiskSize: defaultDiskSize}
+	sizes := autosize.Sizes{CPU: d.CPU, Memory: d.Memory, DiskSize: d.DiskSize}.Fill(defaults, host.Sizes())
+	d.CPU, d.Memory, d.DiskSize = sizes.CPU, sizes.Memory, sizes.DiskSize
+
+	log.Infof("Sizing the machine for the host: %s", sizes)
+	return nil
+}
+
+// poolAvailable returns the free space of the storage pool, in bytes.
+func (d *Driver) poolAvailable() (uint64, error) {
+	stdout, err := d.getVirsh().virshOut("pool-info", "--bytes", d.StoragePool)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(parseKeyValues(stdout)["Available"])
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("Error reading the free space of the storage pool %s", d.StoragePool)
+	}
+
+	return strconv.ParseUint(fields[0], 10, 64)
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "kvm"
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	if ip == "" {
+		return "", nil
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, strconv.Itoa(d.EnginePort))), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	stdout, err := d.getVirsh().virshOut("domstate", d.MachineName)
+	if err != nil {
+		return state.Error, err
+	}
+
+	switch strings.TrimSpace(stdout) {
+	case "running", "idle", "blocked":
+		return state.Running, nil
+	case "paused", "pmsuspended":
+		return state.Paused, nil
+	case "in shutdown":
+		return state.Stopping, nil
+	case "shut off":
+		return state.Stopped, nil
+	case "crashed":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+// PreCreateCheck checks that the storage pool, and the network in network
+// mode, are active.
+func (d *Driver) PreCreateCheck() error {
+	virsh := d.getVirsh()
+
+	stdout, err := virsh.virshOut("pool-info", d.StoragePool)
+	if err != nil {
+		return err
+	}
+	if parseKeyValues(stdout)["State"] != "running" {
+		return fmt.Errorf("The libvirt storage pool %q isn't active, start it with \"virsh pool-start %s\"", d.StoragePool, d.StoragePool)
+	}
+
+	if d.NetworkMode == "network" {
+		stdout, err := virsh.virshOut("net-info", d.Network)
+		if err != nil {
+			return err
+		}
+		if parseKeyValues(stdout)["Active"] != "yes" {
+			return fmt.Errorf("The libvirt network %q isn't active, start it with \"virsh net-start %s\"", d.Network, d.Network)
+		}
+	}
+
+	// Downloading boot2docker to cache should be done here to make sure
+	// that a download failure will not leave a machine half created.
+	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
+	return b2dutils.UpdateISOCache(d.Boot2DockerURL)
+}
+
+func (d *Driver) Create() error {
+	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
+	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+		return err
+	}
+
+	log.Infof("Creating SSH key...")
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	macAddress, err := generateMACAddress()
+	if err != nil {
+		return err
+	}
+	d.MACAddress = macAddress
+
+	if d.NetworkMode == "user" {
+		if d.SSHPort, err = getAvailableTCPPort(); err != nil {
+			return err
+		}
+		if d.EnginePort, err = getAvailableTCPPort(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Creating volumes in storage pool %q...", d.StoragePool)
+
+	isoPath, err := d.uploadVolume(d.isoVolumeName(), d.ResolveStorePath("boot2docker.iso"), 0)
+	if err != nil {
+		return err
+	}
+
+	diskPath, err := d.createDiskVolume()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating VM...")
+
+	xml, err := domainXML(&domainConfig{
+		Name:        d.MachineName,
+		Memory:      d.Memory,
+		CPU:         d.CPU,
+		ISOPath:     isoPath,
+		DiskPath:    diskPath,
+		NetworkMode: d.NetworkMode,
+		Network:     d.Network,
+		Bridge:      d.Bridge,
+		MACAddress:  d.MACAddress,
+		SSHPort:     d.SSHPort,
+		EnginePort:  d.EnginePort,
+	})
+	if err != nil {
+		return err
+	}
+
+	xmlPath := d.ResolveStorePath("domain.xml")
+	if err := ioutil.WriteFile(xmlPath, []byte(xml), 0644); err != nil {
+		return err
+	}
+
+	if err := d.getVirsh().virsh("define", xmlPath); err != nil {
+		return err
+	}
+
+	log.Infof("Starting VM...")
+	return d.Start()
+}
+
+// createDiskVolume creates the disk of the machine, holding the boot2docker
+// tar with the SSH key which is formatted on the first boot.
+func (d *Driver) createDiskVolume() (string, error) {
+	tarBuf, err := mcnutils.MakeDiskImage(d.publicSSHKeyPath())
+	if err != nil {
+		return "", err
+	}
+
+	tarPath := d.ResolveStorePath("disk.tar")
+	if err := ioutil.WriteFile(tarPath, tarBuf.Bytes(), 0600); err != nil {
+		return "", err
+	}
+	defer os.Remove(tarPath)
+
+	return d.uploadVolume(d.diskVolumeName(), tarPath, int64(d.DiskSize)<<20)
+}
+
+// uploadVolume creates a raw volume of the given size in bytes, or of the
+// size of the file, in the storage pool and uploads the file to its start.
+// It returns the path of the volume.
+func (d *Driver) uploadVolume(name, file string, size int64) (string, error) {
+	if size == 0 {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		size = fi.Size()
+	}
+
+	virsh := d.getVirsh()
+
+	if err := virsh.virsh("vol-create-as", d.StoragePool, name, strconv.FormatInt(size, 10), "--format", "raw"); err != nil {
+		return "", err
+	}
+
+	if err := virsh.virsh("vol-upload", "--pool", d.StoragePool, name, file); err != nil {
+		return "", err
+	}
+
+	stdout, err := virsh.virshOut("vol-path", "--pool", d.StoragePool, name)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+// waitForIP waits until the host has a valid IP
+func (d *Driver) waitForIP() (string, error) {
+	log.Infof("Waiting for host to start...")
+
+	var ip string
+	err := mcnutils.WaitForSpecific(func() bool {
+		ip, _ = d.GetIP()
+		return ip != ""
+	}, 90, 2*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("KVM machine %s has no IP address: %s", d.MachineName, err)
+	}
+
+	return ip, nil
+}
+
+// waitStopped waits until the host is stopped
+func (d *Driver) waitStopped() error {
+	log.Infof("Waiting for host to stop...")
+
+	return mcnutils.WaitForSpecific(func() bool {
+		s, err := d.GetState()
+		return err == nil && s == state.Stopped
+	}, 60, time.Second)
+}
+
+// Start starts an host
+func (d *Driver) Start() error {
+	if err := d.getVirsh().virsh("start", d.MachineName); err != nil {
+		return err
+	}
+
+	ip, err := d.waitForIP()
+	if err != nil {
+		return err
+	}
+
+	d.IPAddress = ip
+
+	return nil
+}
+
+// Stop shuts an host down
+func (d *Driver) Stop() error {
+	if err := d.getVirsh().virsh("shutdown", d.MachineName); err != nil {
+		return err
+	}
+
+	if err := d.waitStopped(); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+// Restart stops and starts an host
+func (d *Driver) Restart() error {
+	if err := d.Stop(); err != nil {
+		return err
+	}
+
+	return d.Start()
+}
+
+// Kill force stops an host
+func (d *Driver) Kill() error {
+	if err := d.getVirsh().virsh("destroy", d.MachineName); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+// Remove removes an host and its volumes
+func (d *Driver) Remove() error {
+	s, err := d.GetState()
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	virsh := d.getVirsh()
+
+	if err == nil {
+		if s != state.Stopped {
+			if err := d.Kill(); err != nil {
+				return err
+			}
+		}
+
+		if err := virsh.virsh("undefine", d.MachineName); err != nil {
+			return err
+		}
+	} else {
+		log.Infof("KVM domain doesn't exist, assuming it is already deleted")
+	}
+
+	for _, name := range []string{d.diskVolumeName(), d.isoVolumeName()} {
+		if err := virsh.virsh("vol-delete", "--pool", d.StoragePool, name); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetIP returns the address of a running host: the forwarded ports are on
+// localhost in user mode, and the address is looked up by the MAC address of
+// the host in the DHCP leases of the libvirt network, or in the ARP table of
+// the host for a bridge.
+func (d *Driver) GetIP() (string, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return "", err
+	}
+	if s != state.Running {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	if d.NetworkMode == "user" {
+		return localhost, nil
+	}
+
+	source := "lease"
+	if d.NetworkMode == "bridge" {
+		source = "arp"
+	}
+
+	stdout, err := d.getVirsh().virshOut("domifaddr", d.MachineName, "--source", source)
+	if err != nil {
+		return "", err
+	}
+
+	return parseDomIfAddr(stdout, d.MACAddress), nil
+}
+
+// parseDomIfAddr returns the IPv4 address of the interface with the given
+// MAC address in the table printed by virsh domifaddr.
+func parseDomIfAddr(stdout, macAddress string) string {
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.EqualFold(fields[1], macAddress) || fields[2] != "ipv4" {
+			continue
+		}
+
+		return strings.SplitN(fields[3], "/", 2)[0]
+	}
+
+	return ""
+}
+
+func (d *Driver) getVirsh() Virsh {
+	if d.virsh == nil {
+		d.virsh = NewVirsh(d.ConnectionURI)
+	}
+	return d.virsh
+}
+
+func (d *Driver) diskVolumeName() string {
+	return d.MachineName + ".img"
+}
+
+func (d *Driver) isoVolumeName() string {
+	return d.MachineName + "-boot2docker.iso"
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
+
+// generateMACAddress returns a random MAC address with the prefix of QEMU.
+func generateMACAddress() (string, error) {
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", buf[0], buf[1], buf[2]), nil
+}
+
+// getAvailableTCPPort returns a free port of localhost to forward a port of
+// the host to in user mode.
+func getAvailableTCPPort() (int, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort(localhost, "0"))
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
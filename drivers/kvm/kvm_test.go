@@ -0,0 +1,194 @@
+package kvm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// VirshMock answers the virsh commands given by their arguments, and records
+// the commands it runs.
+type VirshMock struct {
+	stdOut   map[string]string
+	err      map[string]error
+	commands []string
+}
+
+func (v *VirshMock) virsh(args ...string) error {
+	_, err := v.virshOut(args...)
+	return err
+}
+
+func (v *VirshMock) virshOut(args ...string) (string, error) {
+	command := strings.Join(args, " ")
+	v.commands = append(v.commands, command)
+	return v.stdOut[command], v.err[command]
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"kvm-network-mode": "bridge",
+			"kvm-bridge":       "br0",
+			"kvm-memory":       2048,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, "bridge", driver.NetworkMode)
+	assert.Equal(t, 2048, driver.Memory)
+	assert.Equal(t, defaultConnectionURI, driver.ConnectionURI)
+	assert.Equal(t, "docker", driver.GetSSHUsername())
+}
+
+func TestSetConfigFromFlagsInvalidNetworkMode(t *testing.T) {
+	for _, values := range []map[string]interface{}{
+		{"kvm-network-mode": "bridge"},
+		{"kvm-network-mode": "macvtap"},
+	} {
+		driver := NewDriver("default", "path")
+		checkFlags := &drivers.CheckDriverOptions{
+			FlagsValues: values,
+			CreateFlags: driver.GetCreateFlags(),
+		}
+
+		assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+	}
+}
+
+func TestDomainXML(t *testing.T) {
+	xml, err := domainXML(&domainConfig{
+		Name:        "default",
+		Memory:      1024,
+		CPU:         2,
+		ISOPath:     "/var/lib/libvirt/images/default-boot2docker.iso",
+		DiskPath:    "/var/lib/libvirt/images/default.img",
+		NetworkMode: "network",
+		Network:     "default",
+		MACAddress:  "52:54:00:12:34:56",
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, xml, "<name>default</name>")
+	assert.Contains(t, xml, "<vcpu>2</vcpu>")
+	assert.Contains(t, xml, "<source network='default'/>")
+	assert.Contains(t, xml, "<target dev='vda' bus='virtio'/>")
+	assert.NotContains(t, xml, "qemu:commandline")
+}
+
+func TestDomainXMLUserMode(t *testing.T) {
+	xml, err := domainXML(&domainConfig{
+		Name:        "default",
+		NetworkMode: "user",
+		MACAddress:  "52:54:00:12:34:56",
+		SSHPort:     40022,
+		EnginePort:  42376,
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, xml, "hostfwd=tcp:127.0.0.1:40022-:22,hostfwd=tcp:127.0.0.1:42376-:2376")
+	assert.NotContains(t, xml, "<interface")
+}
+
+func TestGetState(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.virsh = &VirshMock{
+		stdOut: map[string]string{"domstate default": "shut off\n\n"},
+	}
+
+	s, err := driver.GetState()
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+}
+
+func TestGetIP(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.MACAddress = "52:54:00:12:34:56"
+	driver.virsh = &VirshMock{
+		stdOut: map[string]string{
+			"domstate default": "running\n",
+			"domifaddr default --source lease": ` Name       MAC address          Protocol     Address
+-------------------------------------------------------------------------------
+ vnet0      52:54:00:ab:cd:ef    ipv4         192.168.122.10/24
+ vnet1      52:54:00:12:34:56    ipv4         192.168.122.45/24
+`,
+		},
+	}
+
+	ip, err := driver.GetIP()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.122.45", ip)
+}
+
+func TestGetURLUserMode(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.NetworkMode = "user"
+	driver.EnginePort = 42376
+	driver.virsh = &VirshMock{
+		stdOut: map[string]string{"domstate default": "running\n"},
+	}
+
+	url, err := driver.GetURL()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:42376", url)
+}
+
+func TestRemoveMissingDomain(t *testing.T) {
+	virsh := &VirshMock{
+		err: map[string]error{
+			"domstate default": errors.New("error: failed to get domain 'default'"),
+		},
+	}
+	driver := NewDriver("default", "path")
+	driver.virsh = virsh
+
+	assert.NoError(t, driver.Remove())
+	assert.Equal(t, []string{
+		"domstate default",
+		"vol-delete --pool default default.img",
+		"vol-delete --pool default default-boot2docker.iso",
+	}, virsh.commands)
+}
+
+func TestPreCreateCheckInactivePool(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.virsh = &VirshMock{
+		stdOut: map[string]string{"pool-info default": "Name:           default\nState:          inactive\n"},
+	}
+
+	assert.EqualError(t, driver.PreCreateCheck(), `The libvirt storage pool "default" isn't active, start it with "virsh pool-start default"`)
+}
+
+func TestAutoSizeRemoteLibvirt(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.ConnectionURI = "qemu+ssh://root@203.0.113.10/system"
+
+	assert.EqualError(t, driver.autoSize(), "--auto-size requires a local libvirt daemon, not qemu+ssh://root@203.0.113.10/system")
+}
+
+func TestPoolAvailable(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.virsh = &VirshMock{
+		stdOut: map[string]string{
+			"pool-info --bytes default": "Name:           default\nState:          running\nCapacity:       502921060352\nAllocation:     42949672960\nAvailable:      459971387392\n",
+		},
+	}
+
+	available, err := driver.poolAvailable()
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(459971387392), available)
+}
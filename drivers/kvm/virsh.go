@@ -0,0 +1,97 @@
+package kvm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	ErrVirshNotFound = errors.New("virsh not found. Make sure libvirt is installed and virsh is in the path")
+)
+
+// Virsh defines the interface to communicate with libvirt.
+type Virsh interface {
+	virsh(args ...string) error
+
+	virshOut(args ...string) (string, error)
+}
+
+// VirshCmd communicates with libvirt through the commandline using `virsh`,
+// connected to the daemon at URI.
+type VirshCmd struct {
+	URI    string
+	runCmd func(cmd *exec.Cmd) error
+}
+
+// NewVirsh creates a Virsh instance connected to uri.
+func NewVirsh(uri string) *VirshCmd {
+	return &VirshCmd{
+		URI:    uri,
+		runCmd: func(cmd *exec.Cmd) error { return cmd.Run() },
+	}
+}
+
+func (v *VirshCmd) virsh(args ...string) error {
+	_, err := v.virshOut(args...)
+	return err
+}
+
+func (v *VirshCmd) virshOut(args ...string) (string, error) {
+	args = append([]string{"--connect", v.URI, "--quiet"}, args...)
+	cmd := exec.Command("virsh", args...)
+	log.Debugf("COMMAND: virsh %v", strings.Join(args, " "))
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := v.runCmd(cmd)
+	log.Debugf("STDOUT:\n{\n%v}", stdout.String())
+	log.Debugf("STDERR:\n{\n%v}", stderr.String())
+
+	if err != nil {
+		if ee, ok := err.(*exec.Error); ok && ee.Err == exec.ErrNotFound {
+			return "", ErrVirshNotFound
+		}
+		return stdout.String(), fmt.Errorf("virsh %v failed:\n%v", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// parseKeyValues parses the "Key: value" lines printed by the info commands
+// of virsh.
+func parseKeyValues(stdout string) map[string]string {
+	values := map[string]string{}
+
+	s := bufio.NewScanner(strings.NewReader(stdout))
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), ":", 2)
+		if len(parts) == 2 {
+			values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	return values
+}
+
+// isNotFound returns true if err is the error of virsh for a missing domain,
+// volume, pool or network.
+func isNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "failed to get domain") ||
+		strings.Contains(msg, "failed to get vol") ||
+		strings.Contains(msg, "Storage volume not found") ||
+		strings.Contains(msg, "Domain not found") ||
+		strings.Contains(msg, "failed to get pool") ||
+		strings.Contains(msg, "failed to get network")
+}
@@ -0,0 +1,223 @@
+package linode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	defaultAPIEndpoint = "https://api.linode.com/v4"
+)
+
+// Client is a minimal client for the Linode API v4, covering what the driver
+// needs to manage an instance.
+type Client struct {
+	Token    string
+	Endpoint string
+	http     *http.Client
+}
+
+type APIError struct {
+	StatusCode int
+	Errors     []struct {
+		Field  string `json:"field"`
+		Reason string `json:"reason"`
+	} `json:"errors"`
+}
+
+func (e *APIError) Error() string {
+	reasons := []string{}
+	for _, apiErr := range e.Errors {
+		if apiErr.Field != "" {
+			reasons = append(reasons, apiErr.Field+": "+apiErr.Reason)
+		} else {
+			reasons = append(reasons, apiErr.Reason)
+		}
+	}
+	return fmt.Sprintf("linode API error (%d): %s", e.StatusCode, strings.Join(reasons, ", "))
+}
+
+type Instance struct {
+	ID     int      `json:"id"`
+	Label  string   `json:"label"`
+	Region string   `json:"region"`
+	Type   string   `json:"type"`
+	Status string   `json:"status"`
+	IPv4   []string `json:"ipv4"`
+}
+
+type InstanceCreateRequest struct {
+	Region          string            `json:"region"`
+	Type            string            `json:"type"`
+	Image           string            `json:"image"`
+	Label           string            `json:"label"`
+	RootPass        string            `json:"root_pass"`
+	AuthorizedKeys  []string          `json:"authorized_keys"`
+	StackScriptID   int               `json:"stackscript_id,omitempty"`
+	StackScriptData map[string]string `json:"stackscript_data,omitempty"`
+	PrivateIP       bool              `json:"private_ip"`
+	BackupsEnabled  bool              `json:"backups_enabled"`
+	Tags            []string          `json:"tags,omitempty"`
+	Metadata        *Metadata         `json:"metadata,omitempty"`
+}
+
+// Metadata is served to the instance by the metadata service.
+type Metadata struct {
+	UserData string `json:"user_data"`
+}
+
+type Address struct {
+	Address string `json:"address"`
+}
+
+// InstanceIPs are the addresses of an instance by network.
+type InstanceIPs struct {
+	IPv4 struct {
+		Public  []Address `json:"public"`
+		Private []Address `json:"private"`
+	} `json:"ipv4"`
+	IPv6 struct {
+		SLAAC *Address `json:"slaac"`
+	} `json:"ipv6"`
+}
+
+type Region struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// RegionAvailability is the availability of a type in a region.
+type RegionAvailability struct {
+	Plan      string `json:"plan"`
+	Available bool   `json:"available"`
+}
+
+func NewClient(token string) *Client {
+	return &Client{
+		Token:    token,
+		Endpoint: defaultAPIEndpoint,
+		http:     &http.Client{},
+	}
+}
+
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.Endpoint+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.Unmarshal(respBody, apiErr)
+		return apiErr
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// IsNotFound returns true if err is an API error for a missing resource.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) CreateInstance(createRequest *InstanceCreateRequest) (*Instance, error) {
+	instance := &Instance{}
+	if err := c.do("POST", "/linode/instances", createRequest, instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func (c *Client) GetInstance(id int) (*Instance, error) {
+	instance := &Instance{}
+	if err := c.do("GET", fmt.Sprintf("/linode/instances/%d", id), nil, instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func (c *Client) DeleteInstance(id int) error {
+	return c.do("DELETE", fmt.Sprintf("/linode/instances/%d", id), nil, nil)
+}
+
+// InstanceAction runs an action such as boot, shutdown or reboot on an
+// instance.
+func (c *Client) InstanceAction(id int, action string) error {
+	return c.do("POST", fmt.Sprintf("/linode/instances/%d/%s", id, action), nil, nil)
+}
+
+// GetInstanceIPs returns the addresses of an instance.
+func (c *Client) GetInstanceIPs(id int) (*InstanceIPs, error) {
+	ips := &InstanceIPs{}
+	if err := c.do("GET", fmt.Sprintf("/linode/instances/%d/ips", id), nil, ips); err != nil {
+		return nil, err
+	}
+	return ips, nil
+}
+
+func (c *Client) GetRegion(id string) (*Region, error) {
+	region := &Region{}
+	if err := c.do("GET", "/regions/"+url.PathEscape(id), nil, region); err != nil {
+		return nil, err
+	}
+	return region, nil
+}
+
+// ListRegionAvailability returns the availability of the types in a region.
+func (c *Client) ListRegionAvailability(region string) ([]RegionAvailability, error) {
+	availability := []RegionAvailability{}
+	if err := c.do("GET", fmt.Sprintf("/regions/%s/availability", url.PathEscape(region)), nil, &availability); err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+// TypeExists returns true if the instance type exists.
+func (c *Client) TypeExists(id string) (bool, error) {
+	err := c.do("GET", "/linode/types/"+url.PathEscape(id), nil, nil)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ImageExists returns true if the image exists and can be used by the
+// account.
+func (c *Client) ImageExists(id string) (bool, error) {
+	err := c.do("GET", "/images/"+id, nil, nil)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
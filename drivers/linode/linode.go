@@ -0,0 +1,473 @@
+package linode
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	APIToken         string
+	LinodeID         int
+	Region           string
+	InstanceType     string
+	Image            string
+	RootPassword     string
+	StackScriptID    int
+	StackScriptData  map[string]string
+	PrivateIP        bool
+	PrivateIPAddress string
+	Backups          bool
+	Tags             []string
+	UserDataFile     string
+	client           *Client
+}
+
+const (
+	defaultSSHPort      = 22
+	defaultSSHUser      = "root"
+	defaultRegion       = "us-east"
+	defaultInstanceType = "g6-standard-1"
+	defaultImage        = "linode/ubuntu22.04"
+)
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "LINODE_TOKEN",
+			Name:   "linode-token",
+			Usage:  "Linode API token",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LINODE_REGION",
+			Name:   "linode-region",
+			Usage:  "Linode region",
+			Value:  defaultRegion,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LINODE_INSTANCE_TYPE",
+			Name:   "linode-instance-type",
+			Usage:  "Linode instance type",
+			Value:  defaultInstanceType,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LINODE_IMAGE",
+			Name:   "linode-image",
+			Usage:  "Linode image",
+			Value:  defaultImage,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LINODE_ROOT_PASS",
+			Name:   "linode-root-pass",
+			Usage:  "Root password of the instance, generated if not given",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "LINODE_STACKSCRIPT_ID",
+			Name:   "linode-stackscript-id",
+			Usage:  "Linode StackScript to deploy the instance with",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LINODE_STACKSCRIPT_DATA",
+			Name:   "linode-stackscript-data",
+			Usage:  "JSON object of the values of the StackScript fields",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "LINODE_PRIVATE_IP",
+			Name:   "linode-private-ip",
+			Usage:  "Assign a private IPv4 address to the instance",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "LINODE_BACKUPS",
+			Name:   "linode-backups",
+			Usage:  "Enroll the instance in the Linode backup service",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "LINODE_TAGS",
+			Name:   "linode-tags",
+			Usage:  "Tags of the instance",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LINODE_USER_DATA",
+			Name:   "linode-user-data",
+			Usage:  "path to file with cloud-init user-data",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LINODE_SSH_USER",
+			Name:   "linode-ssh-user",
+			Usage:  "SSH username",
+			Value:  defaultSSHUser,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "LINODE_SSH_PORT",
+			Name:   "linode-ssh-port",
+			Usage:  "SSH port",
+			Value:  defaultSSHPort,
+		},
+	}
+}
+
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Region:       defaultRegion,
+		InstanceType: defaultInstanceType,
+		Image:        defaultImage,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "linode"
+}
+
+// Capabilities returns the features of the driver, including IPv6 and user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.IPv6 = true
+	capabilities.UserData = true
+	return capabilities
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.APIToken = flags.String("linode-token")
+	d.Region = flags.String("linode-region")
+	d.InstanceType = flags.String("linode-instance-type")
+	d.Image = flags.String("linode-image")
+	d.RootPassword = flags.String("linode-root-pass")
+	d.StackScriptID = flags.Int("linode-stackscript-id")
+	d.PrivateIP = flags.Bool("linode-private-ip")
+	d.Backups = flags.Bool("linode-backups")
+	d.Tags = flags.StringSlice("linode-tags")
+	d.UserDataFile = flags.String("linode-user-data")
+	d.SSHUser = flags.String("linode-ssh-user")
+	d.SSHPort = flags.Int("linode-ssh-port")
+	d.SetSwarmConfigFromFlags(flags)
+	d.SetIPFamilyFromFlags(flags)
+
+	if d.APIToken == "" {
+		return fmt.Errorf("linode driver requires the --linode-token option")
+	}
+
+	if data := flags.String("linode-stackscript-data"); data != "" {
+		if d.StackScriptID == 0 {
+			return fmt.Errorf("linode driver requires --linode-stackscript-id to use --linode-stackscript-data")
+		}
+		if err := json.Unmarshal([]byte(data), &d.StackScriptData); err != nil {
+			return fmt.Errorf("--linode-stackscript-data must be a JSON object of strings: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
+	client := d.getClient()
+
+	region, err := client.GetRegion(d.Region)
+	if IsNotFound(err) {
+		return fmt.Errorf("linode region %q is not valid", d.Region)
+	}
+	if err != nil {
+		return err
+	}
+	if region.Status != "ok" {
+		return fmt.Errorf("linode region %q is not available: %s", d.Region, region.Status)
+	}
+
+	exists, err := client.TypeExists(d.InstanceType)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("linode instance type %q is not valid", d.InstanceType)
+	}
+
+	availability, err := client.ListRegionAvailability(d.Region)
+	if err != nil {
+		return err
+	}
+	for _, plan := range availability {
+		if plan.Plan == d.InstanceType && !plan.Available {
+			return fmt.Errorf("linode instance type %q is not available in the region %q", d.InstanceType, d.Region)
+		}
+	}
+
+	if exists, err = client.ImageExists(d.Image); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("linode image %q is not valid", d.Image)
+	}
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	return d.CreateContext(context.Background(), func(message string) {
+		log.Info(message)
+	})
+}
+
+// CreateContext creates the instance like Create.  If ctx is canceled before
+// the instance is running, it is removed.
+func (d *Driver) CreateContext(ctx context.Context, progress func(string)) error {
+	var metadata *Metadata
+	if d.UserDataFile != "" {
+		buf, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		metadata = &Metadata{UserData: base64.StdEncoding.EncodeToString(buf)}
+	}
+
+	progress("Creating SSH key...")
+
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	rootPassword := d.RootPassword
+	if rootPassword == "" {
+		if rootPassword, err = generatePassword(); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	progress("Creating Linode instance...")
+
+	instance, err := d.getClient().CreateInstance(&InstanceCreateRequest{
+		Region:          d.Region,
+		Type:            d.InstanceType,
+		Image:           d.Image,
+		Label:           d.MachineName,
+		RootPass:        rootPassword,
+		AuthorizedKeys:  []string{strings.TrimSpace(string(publicKey))},
+		StackScriptID:   d.StackScriptID,
+		StackScriptData: d.StackScriptData,
+		PrivateIP:       d.PrivateIP,
+		BackupsEnabled:  d.Backups,
+		Tags:            d.Tags,
+		Metadata:        metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.LinodeID = instance.ID
+
+	progress("Waiting for the instance to be running...")
+	if err := mcnutils.WaitForSpecificOrError(func() (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		return d.instanceIsRunning(), nil
+	}, 90, 2*time.Second); err != nil {
+		if ctx.Err() != nil {
+			return d.cancelCreate(err)
+		}
+		return fmt.Errorf("linode instance %d isn't running: %s", d.LinodeID, err)
+	}
+
+	if err := d.refreshAddresses(); err != nil {
+		return err
+	}
+
+	log.Debugf("Created instance ID %d, IP address %s, private IP address %s",
+		d.LinodeID,
+		d.IPAddress,
+		d.PrivateIPAddress)
+
+	return nil
+}
+
+// cancelCreate removes the instance of the canceled creation and returns the
+// error of the cancellation.
+func (d *Driver) cancelCreate(err error) error {
+	log.Info("Creation canceled, removing the instance...")
+	if removeErr := d.Remove(); removeErr != nil {
+		log.Warnf("Error removing the canceled instance: %s", removeErr)
+	}
+
+	return err
+}
+
+// instanceIsRunning reports whether the instance has booted after being
+// provisioned.
+func (d *Driver) instanceIsRunning() bool {
+	instance, err := d.getClient().GetInstance(d.LinodeID)
+	if err != nil {
+		log.Debugf("Error getting instance %d: %s", d.LinodeID, err)
+		return false
+	}
+
+	return instance.Status == "running"
+}
+
+// refreshAddresses reads the public address of the instance, IPv4 unless
+// --use-ipv6 is given, and its private IPv4 address.
+func (d *Driver) refreshAddresses() error {
+	ips, err := d.getClient().GetInstanceIPs(d.LinodeID)
+	if err != nil {
+		return err
+	}
+
+	ipv4, ipv6 := "", ""
+	if len(ips.IPv4.Public) > 0 {
+		ipv4 = ips.IPv4.Public[0].Address
+	}
+	if ips.IPv6.SLAAC != nil {
+		ipv6 = ips.IPv6.SLAAC.Address
+	}
+
+	d.IPAddress = d.PreferredIP(ipv4, ipv6)
+	if d.IPAddress == "" {
+		return fmt.Errorf("linode instance %d has no public IP address", d.LinodeID)
+	}
+
+	if len(ips.IPv4.Private) > 0 {
+		d.PrivateIPAddress = ips.IPv4.Private[0].Address
+	}
+
+	return nil
+}
+
+// generatePassword returns a random root password, which Linode requires to
+// deploy an image although the machine is only reached with its SSH key.
+func generatePassword() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+// GetPrivateIP returns the private IPv4 address of the instance.
+func (d *Driver) GetPrivateIP() (string, error) {
+	if d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+
+	if err := d.refreshAddresses(); err != nil {
+		return "", err
+	}
+
+	if d.PrivateIPAddress == "" {
+		return "", fmt.Errorf("The instance %d has no private IP address, create it with --linode-private-ip", d.LinodeID)
+	}
+
+	return d.PrivateIPAddress, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	instance, err := d.getClient().GetInstance(d.LinodeID)
+	if err != nil {
+		return state.Error, err
+	}
+	switch instance.Status {
+	case "provisioning", "booting", "rebooting", "rebuilding", "cloning", "restoring", "migrating":
+		return state.Starting, nil
+	case "running":
+		return state.Running, nil
+	case "shutting_down":
+		return state.Stopping, nil
+	case "offline", "stopped":
+		return state.Stopped, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) Start() error {
+	return d.getClient().InstanceAction(d.LinodeID, "boot")
+}
+
+// Stop shuts the instance down, which Linode does with an ACPI shutdown
+// signal.
+func (d *Driver) Stop() error {
+	return d.getClient().InstanceAction(d.LinodeID, "shutdown")
+}
+
+func (d *Driver) Restart() error {
+	return d.getClient().InstanceAction(d.LinodeID, "reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.getClient().InstanceAction(d.LinodeID, "shutdown")
+}
+
+func (d *Driver) Remove() error {
+	if d.LinodeID == 0 {
+		return nil
+	}
+	if err := d.getClient().DeleteInstance(d.LinodeID); err != nil {
+		if IsNotFound(err) {
+			log.Infof("Linode instance doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) getClient() *Client {
+	if d.client == nil {
+		d.client = NewClient(d.APIToken)
+	}
+	return d.client
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
@@ -0,0 +1,239 @@
+package linode
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"linode-token":            "TOKEN",
+			"linode-stackscript-id":   1234,
+			"linode-stackscript-data": `{"hostname":"default"}`,
+			"linode-tags":             []string{"ci"},
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, map[string]string{"hostname": "default"}, driver.StackScriptData)
+	assert.Equal(t, defaultInstanceType, driver.InstanceType)
+	assert.Equal(t, defaultImage, driver.Image)
+	assert.Equal(t, driver.ResolveStorePath("id_rsa"), driver.GetSSHKeyPath())
+}
+
+func TestSetConfigFromFlagsRequiresToken(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestStackScriptDataRequiresStackScript(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"linode-token":            "TOKEN",
+			"linode-stackscript-data": `{"hostname":"default"}`,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestPreCreateCheckUnavailableType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/regions/eu-west":
+			w.Write([]byte(`{"id":"eu-west","status":"ok"}`))
+		case "/linode/types/g6-dedicated-2":
+			w.Write([]byte(`{"id":"g6-dedicated-2"}`))
+		case "/regions/eu-west/availability":
+			w.Write([]byte(`[{"plan":"g6-standard-1","available":true},{"plan":"g6-dedicated-2","available":false}]`))
+		}
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.Region = "eu-west"
+	driver.InstanceType = "g6-dedicated-2"
+	driver.getClient().Endpoint = server.URL
+
+	assert.EqualError(t, driver.PreCreateCheck(), `linode instance type "g6-dedicated-2" is not available in the region "eu-west"`)
+}
+
+func TestPreCreateCheckInvalidRegion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[{"reason":"Not found"}]}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.Region = "mars-1"
+	driver.getClient().Endpoint = server.URL
+
+	assert.EqualError(t, driver.PreCreateCheck(), `linode region "mars-1" is not valid`)
+}
+
+func TestCreate(t *testing.T) {
+	var createRequest map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer TOKEN", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/linode/instances":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&createRequest))
+			w.Write([]byte(`{"id":123,"status":"provisioning","ipv4":["1.2.3.4","192.168.128.4"]}`))
+		case "/linode/instances/123":
+			w.Write([]byte(`{"id":123,"status":"running"}`))
+		case "/linode/instances/123/ips":
+			w.Write([]byte(`{"ipv4":{"public":[{"address":"1.2.3.4"}],"private":[{"address":"192.168.128.4"}]}}`))
+		}
+	}))
+	defer server.Close()
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.APIToken = "TOKEN"
+	driver.StackScriptID = 1234
+	driver.StackScriptData = map[string]string{"hostname": "default"}
+	driver.PrivateIP = true
+	driver.Backups = true
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Create())
+	assert.Equal(t, 123, driver.LinodeID)
+	assert.Equal(t, "1.2.3.4", driver.IPAddress)
+	assert.Empty(t, driver.RootPassword)
+	assert.NotEmpty(t, createRequest["root_pass"])
+	assert.Equal(t, float64(1234), createRequest["stackscript_id"])
+	assert.Equal(t, map[string]interface{}{"hostname": "default"}, createRequest["stackscript_data"])
+	assert.Equal(t, true, createRequest["private_ip"])
+	assert.Equal(t, true, createRequest["backups_enabled"])
+	assert.Len(t, createRequest["authorized_keys"], 1)
+
+	ip, err := driver.GetPrivateIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.128.4", ip)
+}
+
+func TestGetState(t *testing.T) {
+	status := "shutting_down"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":123,"status":"` + status + `"}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.LinodeID = 123
+	driver.getClient().Endpoint = server.URL
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopping, s)
+
+	status = "offline"
+	s, err = driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+}
+
+func TestGetPrivateIPWithoutPrivateIP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ipv4":{"public":[{"address":"1.2.3.4"}],"private":[]}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.LinodeID = 123
+	driver.getClient().Endpoint = server.URL
+
+	_, err := driver.GetPrivateIP()
+	assert.EqualError(t, err, "The instance 123 has no private IP address, create it with --linode-private-ip")
+}
+
+func TestRemoveIgnoresMissingInstance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[{"reason":"Not found"}]}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.LinodeID = 123
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Remove())
+}
+
+func TestCreateContextCanceledRemovesInstance(t *testing.T) {
+	deleted := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, r.URL.Path)
+			return
+		}
+		w.Write([]byte(`{"id":123,"status":"provisioning"}`))
+	}))
+	defer server.Close()
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.APIToken = "TOKEN"
+	driver.getClient().Endpoint = server.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = driver.CreateContext(ctx, func(message string) {
+		if message == "Waiting for the instance to be running..." {
+			cancel()
+		}
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"/linode/instances/123"}, deleted)
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":[{"field":"label","reason":"Label must be unique"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("TOKEN")
+	client.Endpoint = server.URL
+
+	_, err := client.CreateInstance(&InstanceCreateRequest{})
+	assert.EqualError(t, err, "linode API error (400): label: Label must be unique")
+}
@@ -0,0 +1,347 @@
+package lxd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	unixSocketScheme = "unix://"
+
+	// operationTimeout is the time in seconds given to the background
+	// operations, such as downloading an image, to complete.
+	operationTimeout = 600
+)
+
+// defaultUnixSockets are the sockets of a local LXD daemon, installed with
+// snap or with the distribution packages.
+var defaultUnixSockets = []string{
+	"/var/snap/lxd/common/lxd/unix.socket",
+	"/var/lib/lxd/unix.socket",
+}
+
+// Client is a minimal client for the LXD REST API, covering what the driver
+// needs to manage an instance.  It talks to a local daemon over its unix
+// socket, or to a remote one over HTTPS with a trusted client certificate.
+type Client struct {
+	Endpoint string
+	http     *http.Client
+}
+
+type APIError struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("LXD API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// response is the envelope of all the responses of the API.  Metadata is the
+// resource for a synchronous response, and the operation for an asynchronous
+// one.
+type response struct {
+	Type       string          `json:"type"`
+	StatusCode int             `json:"status_code"`
+	Error      string          `json:"error"`
+	ErrorCode  int             `json:"error_code"`
+	Operation  string          `json:"operation"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// Operation is a background operation of the daemon, e.g. the creation of an
+// instance.
+type Operation struct {
+	ID       string                 `json:"id"`
+	Status   string                 `json:"status"`
+	Err      string                 `json:"err"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type Instance struct {
+	Name   string            `json:"name"`
+	Status string            `json:"status"`
+	Config map[string]string `json:"config"`
+}
+
+type InstanceSource struct {
+	Type        string `json:"type"`
+	Mode        string `json:"mode,omitempty"`
+	Server      string `json:"server,omitempty"`
+	Protocol    string `json:"protocol,omitempty"`
+	Alias       string `json:"alias,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+type InstanceCreateRequest struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Source   InstanceSource    `json:"source"`
+	Profiles []string          `json:"profiles,omitempty"`
+	Config   map[string]string `json:"config,omitempty"`
+}
+
+type InstanceState struct {
+	Status  string                          `json:"status"`
+	Network map[string]InstanceNetworkState `json:"network"`
+}
+
+type InstanceNetworkState struct {
+	Addresses []InstanceAddress `json:"addresses"`
+	Type      string            `json:"type"`
+}
+
+type InstanceAddress struct {
+	Family  string `json:"family"`
+	Address string `json:"address"`
+	Scope   string `json:"scope"`
+}
+
+// Server is the environment of the daemon.
+type Server struct {
+	Auth string `json:"auth"`
+}
+
+// NewClient returns a client for the daemon at endpoint, either an HTTPS URL
+// or unix:// followed by the path of a socket.  An empty endpoint is the local
+// daemon.  The client certificate and key authenticate to a remote daemon,
+// whose certificate is verified against serverCertPath if it is given.
+func NewClient(endpoint, clientCertPath, clientKeyPath, serverCertPath string) (*Client, error) {
+	if endpoint == "" {
+		endpoint = unixSocketScheme + localUnixSocket()
+	}
+
+	if strings.HasPrefix(endpoint, unixSocketScheme) {
+		socket := strings.TrimPrefix(endpoint, unixSocketScheme)
+		return &Client{
+			Endpoint: "http://lxd",
+			http: &http.Client{
+				Transport: &http.Transport{
+					Dial: func(network, addr string) (net.Conn, error) {
+						return net.Dial("unix", socket)
+					},
+				},
+			},
+		}, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" {
+		return nil, fmt.Errorf("Invalid LXD endpoint %q, expected https://host:port or unix:///path/to/socket", endpoint)
+	}
+
+	tlsConfig := &tls.Config{}
+	if clientCertPath != "" || clientKeyPath != "" {
+		pair, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the LXD client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	if serverCertPath != "" {
+		serverCert, err := ioutil.ReadFile(serverCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the LXD server certificate: %s", err)
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(serverCert) {
+			return nil, fmt.Errorf("Error reading the LXD server certificate: no certificate found in %s", serverCertPath)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	return &Client{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		http: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// localUnixSocket returns the socket of the local daemon.
+func localUnixSocket() string {
+	for _, socket := range defaultUnixSockets {
+		if _, err := os.Stat(socket); err == nil {
+			return socket
+		}
+	}
+	return defaultUnixSockets[0]
+}
+
+func (c *Client) do(method, path string, header http.Header, body []byte) (*response, error) {
+	req, err := http.NewRequest(method, c.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	lxdResp := &response{}
+	if err := json.Unmarshal(respBody, lxdResp); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("Error reading the response of LXD: %s", err)
+	}
+
+	if resp.StatusCode >= 300 || lxdResp.Type == "error" {
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: lxdResp.Error}
+	}
+
+	return lxdResp, nil
+}
+
+func (c *Client) doJSON(method, path string, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.do(method, path, nil, reqBody)
+	if err != nil {
+		return err
+	}
+
+	if resp.Type == "async" {
+		return c.wait(resp.Operation, out)
+	}
+
+	if out == nil || len(resp.Metadata) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(resp.Metadata, out)
+}
+
+// wait waits for a background operation to complete, and reads its metadata
+// into out.
+func (c *Client) wait(operation string, out interface{}) error {
+	resp, err := c.do("GET", fmt.Sprintf("%s/wait?timeout=%d", operation, operationTimeout), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	op := &Operation{}
+	if err := json.Unmarshal(resp.Metadata, op); err != nil {
+		return fmt.Errorf("Error reading the LXD operation %s: %s", operation, err)
+	}
+
+	if op.Status != "Success" {
+		return fmt.Errorf("LXD operation %s failed: %s", operation, op.Err)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	content, err := json.Marshal(op.Metadata)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, out)
+}
+
+// IsNotFound returns true if err is an API error for a missing resource.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) CreateInstance(createRequest *InstanceCreateRequest) error {
+	return c.doJSON("POST", "/1.0/instances", createRequest, nil)
+}
+
+func (c *Client) GetInstance(name string) (*Instance, error) {
+	instance := &Instance{}
+	if err := c.doJSON("GET", "/1.0/instances/"+url.PathEscape(name), nil, instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func (c *Client) GetInstanceState(name string) (*InstanceState, error) {
+	instanceState := &InstanceState{}
+	if err := c.doJSON("GET", "/1.0/instances/"+url.PathEscape(name)+"/state", nil, instanceState); err != nil {
+		return nil, err
+	}
+	return instanceState, nil
+}
+
+func (c *Client) DeleteInstance(name string) error {
+	return c.doJSON("DELETE", "/1.0/instances/"+url.PathEscape(name), nil, nil)
+}
+
+// UpdateInstanceState runs an action such as start, stop or restart on an
+// instance.  Force kills the instance instead of shutting it down cleanly.
+func (c *Client) UpdateInstanceState(name, action string, force bool) error {
+	body := map[string]interface{}{
+		"action":  action,
+		"timeout": 30,
+		"force":   force,
+	}
+	return c.doJSON("PUT", "/1.0/instances/"+url.PathEscape(name)+"/state", body, nil)
+}
+
+// Exec runs a command in an instance and returns its exit code.
+func (c *Client) Exec(name string, command []string) (int, error) {
+	body := map[string]interface{}{
+		"command":            command,
+		"wait-for-websocket": false,
+		"interactive":        false,
+		"record-output":      true,
+	}
+
+	var metadata struct {
+		Return int `json:"return"`
+	}
+	if err := c.doJSON("POST", "/1.0/instances/"+url.PathEscape(name)+"/exec", body, &metadata); err != nil {
+		return -1, err
+	}
+
+	return metadata.Return, nil
+}
+
+// PushFile writes a file owned by root in an instance.
+func (c *Client) PushFile(name, path string, content []byte, mode os.FileMode) error {
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("X-LXD-type", "file")
+	header.Set("X-LXD-uid", "0")
+	header.Set("X-LXD-gid", "0")
+	header.Set("X-LXD-mode", fmt.Sprintf("%04o", mode))
+
+	_, err := c.do("POST", "/1.0/instances/"+url.PathEscape(name)+"/files?path="+url.QueryEscape(path), header, content)
+	return err
+}
+
+func (c *Client) GetServer() (*Server, error) {
+	server := &Server{}
+	if err := c.doJSON("GET", "/1.0", nil, server); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
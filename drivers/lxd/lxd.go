@@ -0,0 +1,468 @@
+package lxd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	Endpoint       string
+	ClientCertPath string
+	ClientKeyPath  string
+	ServerCertPath string
+	Image          string
+	Profiles       []string
+	CPUs           int
+	Memory         int
+	UserDataFile   string
+	client         *Client
+}
+
+const (
+	defaultSSHPort = 22
+	defaultSSHUser = "root"
+	defaultImage   = "ubuntu:22.04"
+)
+
+// imageServers are the image servers known by the lxc client as remotes,
+// which can prefix the images given to the driver.
+var imageServers = map[string]string{
+	"ubuntu":       "https://cloud-images.ubuntu.com/releases",
+	"ubuntu-daily": "https://cloud-images.ubuntu.com/daily",
+	"images":       "https://images.linuxcontainers.org",
+}
+
+// sshdScript makes sure an SSH server is running in the container, since
+// some images don't have one installed.
+const sshdScript = `command -v sshd >/dev/null && exit 0
+if command -v apt-get >/dev/null; then
+	DEBIAN_FRONTEND=noninteractive apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y openssh-server
+elif command -v apk >/dev/null; then
+	apk add --no-cache openssh && rc-update add sshd default && service sshd start
+elif command -v dnf >/dev/null; then
+	dnf install -y openssh-server && systemctl enable --now sshd
+else
+	exit 1
+fi`
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "LXD_ENDPOINT",
+			Name:   "lxd-endpoint",
+			Usage:  "LXD daemon to create the container on, https://host:8443 or unix:///path/to/unix.socket (default: the local daemon)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_CLIENT_CERT",
+			Name:   "lxd-client-cert",
+			Usage:  "Path to the client certificate trusted by a remote LXD daemon",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_CLIENT_KEY",
+			Name:   "lxd-client-key",
+			Usage:  "Path to the key of the client certificate",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_SERVER_CERT",
+			Name:   "lxd-server-cert",
+			Usage:  "Path to the certificate of a remote LXD daemon (default: verified against the system roots)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_IMAGE",
+			Name:   "lxd-image",
+			Usage:  "LXD image, e.g. ubuntu:22.04, images:debian/12/cloud, or the alias or fingerprint of a local image",
+			Value:  defaultImage,
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "LXD_PROFILES",
+			Name:   "lxd-profiles",
+			Usage:  "Profiles applied to the container (default: default)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_USER_DATA",
+			Name:   "lxd-user-data",
+			Usage:  "path to file with cloud-init user-data, run by the cloud variants of the images",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "LXD_CPUS",
+			Name:   "lxd-cpus",
+			Usage:  "Number of CPUs the container can use (default: no limit)",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "LXD_MEMORY",
+			Name:   "lxd-memory",
+			Usage:  "Memory the container can use in MB (default: no limit)",
+		},
+	}
+}
+
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Image: defaultImage,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+			SSHUser:     defaultSSHUser,
+			SSHPort:     defaultSSHPort,
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "lxd"
+}
+
+// Capabilities returns the features of the driver, including user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.UserData = true
+	return capabilities
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.Endpoint = flags.String("lxd-endpoint")
+	d.ClientCertPath = flags.String("lxd-client-cert")
+	d.ClientKeyPath = flags.String("lxd-client-key")
+	d.ServerCertPath = flags.String("lxd-server-cert")
+	d.Image = flags.String("lxd-image")
+	d.Profiles = flags.StringSlice("lxd-profiles")
+	d.CPUs = flags.Int("lxd-cpus")
+	d.Memory = flags.Int("lxd-memory")
+	d.UserDataFile = flags.String("lxd-user-data")
+	d.SSHUser = defaultSSHUser
+	d.SSHPort = defaultSSHPort
+	d.SetSwarmConfigFromFlags(flags)
+
+	if strings.HasPrefix(d.Endpoint, "https://") && (d.ClientCertPath == "" || d.ClientKeyPath == "") {
+		return fmt.Errorf("lxd driver requires the --lxd-client-cert and --lxd-client-key options with a remote endpoint")
+	}
+
+	if d.CPUs < 0 || d.Memory < 0 {
+		return fmt.Errorf("lxd CPUs and memory must be positive")
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
+	client, err := d.getClient()
+	if err != nil {
+		return err
+	}
+
+	server, err := client.GetServer()
+	if err != nil {
+		return fmt.Errorf("Error connecting to LXD: %s", err)
+	}
+
+	if server.Auth != "trusted" {
+		return fmt.Errorf("The LXD client certificate isn't trusted by %s, add it with \"lxc config trust add\"", d.Endpoint)
+	}
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	log.Infof("Creating SSH key...")
+
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	client, err := d.getClient()
+	if err != nil {
+		return err
+	}
+
+	createRequest := d.createRequest()
+
+	if d.UserDataFile != "" {
+		userdata, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		createRequest.Config["user.user-data"] = string(userdata)
+	}
+
+	log.Infof("Creating LXD container...")
+
+	if err := client.CreateInstance(createRequest); err != nil {
+		return err
+	}
+
+	if err := client.UpdateInstanceState(d.MachineName, "start", false); err != nil {
+		return err
+	}
+
+	log.Info("Waiting for IP address to be assigned to the container...")
+	if err := mcnutils.WaitForSpecific(d.instanceHasAddress, 60, 2*time.Second); err != nil {
+		return fmt.Errorf("LXD container %s has no IP address: %s", d.MachineName, err)
+	}
+
+	log.Infof("Authorizing the SSH key in the container...")
+
+	if code, err := client.Exec(d.MachineName, []string{"mkdir", "-p", "-m", "700", "/root/.ssh"}); err != nil || code != 0 {
+		return fmt.Errorf("Error creating /root/.ssh in LXD container %s: %v (exit code %d)", d.MachineName, err, code)
+	}
+
+	if err := client.PushFile(d.MachineName, "/root/.ssh/authorized_keys", publicKey, 0600); err != nil {
+		return err
+	}
+
+	if code, err := client.Exec(d.MachineName, []string{"sh", "-c", sshdScript}); err != nil || code != 0 {
+		return fmt.Errorf("Error installing an SSH server in LXD container %s: %v (exit code %d)", d.MachineName, err, code)
+	}
+
+	log.Debugf("Created container %s, IP address %s", d.MachineName, d.IPAddress)
+
+	return nil
+}
+
+// createRequest returns the request creating the container.  Docker needs
+// nesting to run in a container, and the interception of some syscalls to
+// run in an unprivileged one.
+func (d *Driver) createRequest() *InstanceCreateRequest {
+	config := map[string]string{
+		"security.nesting":                     "true",
+		"security.syscalls.intercept.mknod":    "true",
+		"security.syscalls.intercept.setxattr": "true",
+	}
+
+	if d.CPUs > 0 {
+		config["limits.cpu"] = strconv.Itoa(d.CPUs)
+	}
+
+	if d.Memory > 0 {
+		config["limits.memory"] = fmt.Sprintf("%dMB", d.Memory)
+	}
+
+	return &InstanceCreateRequest{
+		Name:     d.MachineName,
+		Type:     "container",
+		Source:   imageSource(d.Image),
+		Profiles: d.Profiles,
+		Config:   config,
+	}
+}
+
+// imageSource returns the source of the container for an image given as
+// the lxc client does: an alias prefixed by the name of an image server, or
+// the alias or fingerprint of an image of the daemon.
+func imageSource(image string) InstanceSource {
+	if i := strings.Index(image, ":"); i > 0 {
+		if server, ok := imageServers[image[:i]]; ok {
+			return InstanceSource{
+				Type:     "image",
+				Mode:     "pull",
+				Server:   server,
+				Protocol: "simplestreams",
+				Alias:    image[i+1:],
+			}
+		}
+	}
+
+	if isFingerprint(image) {
+		return InstanceSource{Type: "image", Fingerprint: image}
+	}
+
+	return InstanceSource{Type: "image", Alias: image}
+}
+
+// isFingerprint returns true if s has the form of a, possibly shortened,
+// fingerprint of an image.
+func isFingerprint(s string) bool {
+	if len(s) < 12 || len(s) > 64 {
+		return false
+	}
+
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// instanceHasAddress refreshes the address of the container and reports
+// whether it's known yet.
+func (d *Driver) instanceHasAddress() bool {
+	client, err := d.getClient()
+	if err != nil {
+		return false
+	}
+
+	instanceState, err := client.GetInstanceState(d.MachineName)
+	if err != nil {
+		log.Debugf("Error getting the state of container %s: %s", d.MachineName, err)
+		return false
+	}
+
+	d.IPAddress = instanceAddress(instanceState)
+
+	return d.IPAddress != ""
+}
+
+// instanceAddress returns the first global IPv4 address of the network
+// interfaces of a container.
+func instanceAddress(instanceState *InstanceState) string {
+	names := []string{}
+	for name, network := range instanceState.Network {
+		if network.Type != "loopback" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, address := range instanceState.Network[name].Addresses {
+			if address.Family == "inet" && address.Scope == "global" {
+				return address.Address
+			}
+		}
+	}
+
+	return ""
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	client, err := d.getClient()
+	if err != nil {
+		return state.Error, err
+	}
+
+	instance, err := client.GetInstance(d.MachineName)
+	if err != nil {
+		return state.Error, err
+	}
+	switch instance.Status {
+	case "Starting":
+		return state.Starting, nil
+	case "Running":
+		return state.Running, nil
+	case "Stopping":
+		return state.Stopping, nil
+	case "Stopped":
+		return state.Stopped, nil
+	case "Frozen", "Freezing":
+		return state.Paused, nil
+	case "Error", "Aborting":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+// Start starts the container and refreshes its address, which its DHCP
+// server may have changed.
+func (d *Driver) Start() error {
+	if err := d.updateState("start", false); err != nil {
+		return err
+	}
+
+	return mcnutils.WaitForSpecific(d.instanceHasAddress, 60, 2*time.Second)
+}
+
+func (d *Driver) Stop() error {
+	return d.updateState("stop", false)
+}
+
+func (d *Driver) Restart() error {
+	return d.updateState("restart", false)
+}
+
+func (d *Driver) Kill() error {
+	return d.updateState("stop", true)
+}
+
+func (d *Driver) Remove() error {
+	client, err := d.getClient()
+	if err != nil {
+		return err
+	}
+
+	instance, err := client.GetInstance(d.MachineName)
+	if err != nil {
+		if IsNotFound(err) {
+			log.Infof("LXD container doesn't exist, assuming it is already deleted")
+			return nil
+		}
+		return err
+	}
+
+	if instance.Status != "Stopped" {
+		if err := client.UpdateInstanceState(d.MachineName, "stop", true); err != nil {
+			return err
+		}
+	}
+
+	return client.DeleteInstance(d.MachineName)
+}
+
+func (d *Driver) updateState(action string, force bool) error {
+	client, err := d.getClient()
+	if err != nil {
+		return err
+	}
+
+	return client.UpdateInstanceState(d.MachineName, action, force)
+}
+
+func (d *Driver) getClient() (*Client, error) {
+	if d.client == nil {
+		client, err := NewClient(d.Endpoint, d.ClientCertPath, d.ClientKeyPath, d.ServerCertPath)
+		if err != nil {
+			return nil, err
+		}
+		d.client = client
+	}
+	return d.client, nil
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
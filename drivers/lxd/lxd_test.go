@@ -0,0 +1,177 @@
+package lxd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(server *httptest.Server) *Client {
+	return &Client{
+		Endpoint: server.URL,
+		http:     &http.Client{},
+	}
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"lxd-image":  "images:debian/12/cloud",
+			"lxd-cpus":   2,
+			"lxd-memory": 2048,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, "images:debian/12/cloud", driver.Image)
+	assert.Equal(t, 2, driver.CPUs)
+	assert.Equal(t, "root", driver.GetSSHUsername())
+	assert.Equal(t, driver.ResolveStorePath("id_rsa"), driver.GetSSHKeyPath())
+}
+
+func TestSetConfigFromFlagsRequiresClientCertForRemote(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"lxd-endpoint": "https://lxd.example.com:8443",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestNewClientInvalidEndpoint(t *testing.T) {
+	_, err := NewClient("tcp://lxd.example.com:8443", "", "", "")
+	assert.Error(t, err)
+}
+
+func TestImageSource(t *testing.T) {
+	assert.Equal(t, InstanceSource{
+		Type:     "image",
+		Mode:     "pull",
+		Server:   "https://cloud-images.ubuntu.com/releases",
+		Protocol: "simplestreams",
+		Alias:    "22.04",
+	}, imageSource("ubuntu:22.04"))
+	assert.Equal(t, "https://images.linuxcontainers.org", imageSource("images:alpine/3.19").Server)
+	assert.Equal(t, InstanceSource{Type: "image", Alias: "docker-base"}, imageSource("docker-base"))
+	assert.Equal(t, InstanceSource{Type: "image", Fingerprint: "8a1bce6f25e5"}, imageSource("8a1bce6f25e5"))
+}
+
+func TestCreateRequest(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.Memory = 1024
+
+	createRequest := driver.createRequest()
+
+	assert.Equal(t, "default", createRequest.Name)
+	assert.Equal(t, "container", createRequest.Type)
+	assert.Equal(t, "true", createRequest.Config["security.nesting"])
+	assert.Equal(t, "1024MB", createRequest.Config["limits.memory"])
+	assert.NotContains(t, createRequest.Config, "limits.cpu")
+}
+
+func TestInstanceAddress(t *testing.T) {
+	instanceState := &InstanceState{
+		Network: map[string]InstanceNetworkState{
+			"lo": {
+				Type:      "loopback",
+				Addresses: []InstanceAddress{{Family: "inet", Address: "127.0.0.1", Scope: "global"}},
+			},
+			"eth0": {
+				Type: "broadcast",
+				Addresses: []InstanceAddress{
+					{Family: "inet6", Address: "fe80::1", Scope: "link"},
+					{Family: "inet", Address: "10.158.0.12", Scope: "global"},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, "10.158.0.12", instanceAddress(instanceState))
+	assert.Equal(t, "", instanceAddress(&InstanceState{}))
+}
+
+func TestAsyncOperation(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1.0/instances/default/exec":
+			content, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(content, &body)
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"type":"async","status_code":100,"operation":"/1.0/operations/42"}`))
+		case "/1.0/operations/42/wait":
+			w.Write([]byte(`{"type":"sync","status_code":200,"metadata":{"id":"42","status":"Success","metadata":{"return":3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	code, err := newTestClient(server).Exec("default", []string{"false"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, code)
+	assert.Equal(t, false, body["wait-for-websocket"])
+}
+
+func TestFailedAsyncOperation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1.0/instances":
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"type":"async","status_code":100,"operation":"/1.0/operations/42"}`))
+		case "/1.0/operations/42/wait":
+			w.Write([]byte(`{"type":"sync","status_code":200,"metadata":{"id":"42","status":"Failure","err":"Image not found"}}`))
+		}
+	}))
+	defer server.Close()
+
+	err := newTestClient(server).CreateInstance(&InstanceCreateRequest{Name: "default"})
+
+	assert.EqualError(t, err, "LXD operation /1.0/operations/42 failed: Image not found")
+}
+
+func TestGetState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/1.0/instances/default", r.URL.Path)
+		w.Write([]byte(`{"type":"sync","status_code":200,"metadata":{"name":"default","status":"Frozen"}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.client = newTestClient(server)
+
+	st, err := driver.GetState()
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.Paused, st)
+}
+
+func TestRemoveMissingContainer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"error","error":"Instance not found","error_code":404}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.client = newTestClient(server)
+
+	assert.NoError(t, driver.Remove())
+}
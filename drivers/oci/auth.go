@@ -0,0 +1,448 @@
+package oci
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/go-ini/ini"
+)
+
+const (
+	defaultProfile = "DEFAULT"
+	// metadataEndpoint is the instance metadata service, which gives the
+	// certificates of the instance principal
+	metadataEndpoint = "http://169.254.169.254/opc/v2"
+)
+
+// keyProvider gives the key the requests to the API are signed with.
+type keyProvider interface {
+	KeyID() (string, error)
+	PrivateKey() (*rsa.PrivateKey, error)
+}
+
+// apiKeyProvider signs the requests with the API key of a user, as
+// configured in the profile of an OCI CLI configuration file.
+type apiKeyProvider struct {
+	TenancyID   string
+	UserID      string
+	Fingerprint string
+	Region      string
+	key         *rsa.PrivateKey
+}
+
+func defaultConfigFile() string {
+	return filepath.Join(mcnutils.GetHomeDir(), ".oci", "config")
+}
+
+// loadAPIKeyProvider reads a profile of an OCI CLI configuration file.  The
+// other profiles inherit the values of the DEFAULT one.
+func loadAPIKeyProvider(configFile, profile string) (*apiKeyProvider, error) {
+	cfg, err := ini.Load(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the OCI configuration %s: %s", configFile, err)
+	}
+
+	section, err := cfg.GetSection(profile)
+	if err != nil {
+		return nil, fmt.Errorf("The OCI configuration %s has no profile %s", configFile, profile)
+	}
+	defaults, _ := cfg.GetSection(defaultProfile)
+
+	value := func(key string) string {
+		if section.Haskey(key) {
+			return section.Key(key).String()
+		}
+		if defaults != nil && defaults.Haskey(key) {
+			return defaults.Key(key).String()
+		}
+		return ""
+	}
+
+	p := &apiKeyProvider{
+		TenancyID:   value("tenancy"),
+		UserID:      value("user"),
+		Fingerprint: value("fingerprint"),
+		Region:      value("region"),
+	}
+	if p.TenancyID == "" || p.UserID == "" || p.Fingerprint == "" || value("key_file") == "" {
+		return nil, fmt.Errorf("The profile %s of the OCI configuration %s requires tenancy, user, fingerprint and key_file", profile, configFile)
+	}
+
+	keyFile := value("key_file")
+	if strings.HasPrefix(keyFile, "~/") {
+		keyFile = filepath.Join(mcnutils.GetHomeDir(), keyFile[2:])
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the OCI API key: %s", err)
+	}
+
+	if p.key, err = parsePrivateKey(keyPEM, value("pass_phrase")); err != nil {
+		return nil, fmt.Errorf("Error reading the OCI API key %s: %s", keyFile, err)
+	}
+
+	return p, nil
+}
+
+func (p *apiKeyProvider) KeyID() (string, error) {
+	return p.TenancyID + "/" + p.UserID + "/" + p.Fingerprint, nil
+}
+
+func (p *apiKeyProvider) PrivateKey() (*rsa.PrivateKey, error) {
+	return p.key, nil
+}
+
+// parsePrivateKey parses a PKCS#1 or PKCS#8 RSA key, encrypted with the
+// passphrase if it's not empty.
+func parsePrivateKey(keyPEM []byte, passphrase string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM key found")
+	}
+
+	der := block.Bytes
+	if x509.IsEncryptedPEMBlock(block) {
+		var err error
+		if der, err = x509.DecryptPEMBlock(block, []byte(passphrase)); err != nil {
+			return nil, err
+		}
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+// instancePrincipalProvider signs the requests as the instance Machine runs
+// on, with a security token the auth service issues for the certificate of
+// the instance.  The token is renewed when it expires.
+type instancePrincipalProvider struct {
+	MetadataEndpoint string
+	AuthEndpoint     string
+	http             *http.Client
+
+	mu         sync.Mutex
+	token      string
+	expiration time.Time
+	sessionKey *rsa.PrivateKey
+}
+
+func newInstancePrincipalProvider() *instancePrincipalProvider {
+	return &instancePrincipalProvider{
+		MetadataEndpoint: metadataEndpoint,
+		http:             &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Region returns the region of the instance.
+func (p *instancePrincipalProvider) Region() (string, error) {
+	region, err := p.getMetadata("/instance/canonicalRegionName")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(region)), nil
+}
+
+// TenancyID returns the tenancy of the instance, found in the subject of its
+// certificate.
+func (p *instancePrincipalProvider) TenancyID() (string, error) {
+	certPEM, err := p.getMetadata("/identity/cert.pem")
+	if err != nil {
+		return "", err
+	}
+
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return "", err
+	}
+
+	return tenancyOf(cert)
+}
+
+func (p *instancePrincipalProvider) KeyID() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.refresh(); err != nil {
+		return "", err
+	}
+
+	return "ST$" + p.token, nil
+}
+
+func (p *instancePrincipalProvider) PrivateKey() (*rsa.PrivateKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+
+	return p.sessionKey, nil
+}
+
+// refresh gets a new security token for a new session key, unless the
+// current one is valid for at least another minute.
+func (p *instancePrincipalProvider) refresh() error {
+	if p.token != "" && time.Now().Add(time.Minute).Before(p.expiration) {
+		return nil
+	}
+
+	certPEM, err := p.getMetadata("/identity/cert.pem")
+	if err != nil {
+		return err
+	}
+	keyPEM, err := p.getMetadata("/identity/key.pem")
+	if err != nil {
+		return err
+	}
+	intermediatePEM, err := p.getMetadata("/identity/intermediate.pem")
+	if err != nil {
+		return err
+	}
+
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+	tenancyID, err := tenancyOf(cert)
+	if err != nil {
+		return err
+	}
+	certKey, err := parsePrivateKey(keyPEM, "")
+	if err != nil {
+		return fmt.Errorf("Error reading the key of the instance: %s", err)
+	}
+
+	sessionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&sessionKey.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"certificate":              pemBody(certPEM),
+		"publicKey":                base64.StdEncoding.EncodeToString(publicKey),
+		"intermediateCertificates": []string{pemBody(intermediatePEM)},
+		"purpose":                  "DEFAULT",
+		"fingerprintAlgorithm":     "SHA256",
+	})
+	if err != nil {
+		return err
+	}
+
+	authEndpoint := p.AuthEndpoint
+	if authEndpoint == "" {
+		region, err := p.Region()
+		if err != nil {
+			return err
+		}
+		authEndpoint = fmt.Sprintf("https://auth.%s.oraclecloud.com", region)
+	}
+
+	req, err := http.NewRequest("POST", authEndpoint+"/v1/x509", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	keyID := tenancyID + "/fed-x509/" + fingerprint(cert)
+	if err := signRequest(req, body, keyID, certKey); err != nil {
+		return err
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Error getting a security token for the instance principal (%d): %s", resp.StatusCode, respBody)
+	}
+
+	var token struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(respBody, &token); err != nil {
+		return err
+	}
+
+	expiration, err := tokenExpiration(token.Token)
+	if err != nil {
+		return err
+	}
+
+	p.token = token.Token
+	p.expiration = expiration
+	p.sessionKey = sessionKey
+
+	return nil
+}
+
+func (p *instancePrincipalProvider) getMetadata(path string) ([]byte, error) {
+	req, err := http.NewRequest("GET", p.MetadataEndpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer Oracle")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error reaching the instance metadata service, instance principals can only be used on an OCI instance: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error getting %s from the instance metadata service: %s", path, resp.Status)
+	}
+
+	return body, nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("no PEM certificate found")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// tenancyOf returns the tenancy of the subject of an instance certificate.
+func tenancyOf(cert *x509.Certificate) (string, error) {
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if strings.HasPrefix(ou, "opc-tenant:") {
+			return strings.TrimPrefix(ou, "opc-tenant:"), nil
+		}
+	}
+	for _, o := range cert.Subject.Organization {
+		if strings.HasPrefix(o, "opc-identity:") {
+			return strings.TrimPrefix(o, "opc-identity:"), nil
+		}
+	}
+
+	return "", errors.New("the certificate of the instance has no tenancy")
+}
+
+// fingerprint returns the SHA-1 fingerprint of a certificate, as hex bytes
+// separated by colons.
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+	return strings.Replace(fmt.Sprintf("% x", sum), " ", ":", -1)
+}
+
+// pemBody returns the base64 body of a PEM block, without its header and
+// line breaks.
+func pemBody(data []byte) string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "-----") {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "")
+}
+
+// tokenExpiration reads the expiration of a JWT security token.
+func tokenExpiration(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("the security token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// signRequest signs a request with the draft-cavage HTTP signatures the API
+// requires.  The requests with a body also sign its digest and length.
+func signRequest(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+	headers := []string{"date", "(request-target)", "host"}
+	if req.Method == "POST" || req.Method == "PUT" || req.Method == "PATCH" {
+		digest := sha256.Sum256(body)
+		req.Header.Set("X-Content-Sha256", base64.StdEncoding.EncodeToString(digest[:]))
+		req.Header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+		headers = append(headers, "x-content-sha256", "content-type", "content-length")
+	}
+
+	lines := []string{}
+	for _, header := range headers {
+		switch header {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI()))
+		case "host":
+			lines = append(lines, "host: "+req.URL.Host)
+		default:
+			lines = append(lines, header+": "+req.Header.Get(header))
+		}
+	}
+
+	hashed := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(`Signature version="1",keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+
+	return nil
+}
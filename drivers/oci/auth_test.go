@@ -0,0 +1,182 @@
+package oci
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	return key
+}
+
+func encodeKey(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// verifySignature checks the signature of a request against the public key,
+// and returns the key ID and the signed headers.
+func verifySignature(t *testing.T, r *http.Request, key *rsa.PublicKey) (string, string) {
+	fields := map[string]string{}
+	for _, match := range regexp.MustCompile(`(\w+)="([^"]*)"`).FindAllStringSubmatch(r.Header.Get("Authorization"), -1) {
+		fields[match[1]] = match[2]
+	}
+
+	lines := []string{}
+	for _, header := range strings.Split(fields["headers"], " ") {
+		switch header {
+		case "(request-target)":
+			lines = append(lines, "(request-target): "+strings.ToLower(r.Method)+" "+r.URL.RequestURI())
+		case "host":
+			lines = append(lines, "host: "+r.Host)
+		default:
+			lines = append(lines, header+": "+r.Header.Get(header))
+		}
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(fields["signature"])
+	assert.NoError(t, err)
+
+	hashed := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	assert.NoError(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature))
+
+	return fields["keyId"], fields["headers"]
+}
+
+func TestSignRequest(t *testing.T) {
+	key := newTestKey(t)
+	body := []byte(`{"displayName":"default"}`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyID, headers := verifySignature(t, r, &key.PublicKey)
+		assert.Equal(t, "tenancy/user/fingerprint", keyID)
+		assert.Equal(t, "date (request-target) host x-content-sha256 content-type content-length", headers)
+
+		received, _ := ioutil.ReadAll(r.Body)
+		digest := sha256.Sum256(received)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(digest[:]), r.Header.Get("X-Content-Sha256"))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest("POST", server.URL+"/20160918/instances?action=START", bytes.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	assert.NoError(t, signRequest(req, body, "tenancy/user/fingerprint", key))
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestLoadAPIKeyProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := newTestKey(t)
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(keyFile, encodeKey(key), 0600))
+
+	configFile := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte(`[DEFAULT]
+tenancy=ocid1.tenancy.oc1..aaa
+region=eu-frankfurt-1
+key_file=`+keyFile+`
+
+[ci]
+user=ocid1.user.oc1..bbb
+fingerprint=12:34
+`), 0600))
+
+	provider, err := loadAPIKeyProvider(configFile, "ci")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-frankfurt-1", provider.Region)
+	keyID, _ := provider.KeyID()
+	assert.Equal(t, "ocid1.tenancy.oc1..aaa/ocid1.user.oc1..bbb/12:34", keyID)
+	privateKey, _ := provider.PrivateKey()
+	assert.Equal(t, key.D, privateKey.D)
+
+	_, err = loadAPIKeyProvider(configFile, "missing")
+	assert.Error(t, err)
+}
+
+func TestInstancePrincipalProvider(t *testing.T) {
+	certKey := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName:         "ocid1.instance.oc1..ccc",
+			OrganizationalUnit: []string{"opc-instance:ocid1.instance.oc1..ccc", "opc-tenant:ocid1.tenancy.oc1..aaa"},
+		},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &certKey.PublicKey, certKey)
+	assert.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	cert, _ := x509.ParseCertificate(der)
+
+	claims, _ := json.Marshal(map[string]int64{"exp": time.Now().Add(20 * time.Minute).Unix()})
+	token := "header." + base64.RawURLEncoding.EncodeToString(claims) + ".signature"
+
+	federations := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/identity/cert.pem", "/identity/intermediate.pem":
+			assert.Equal(t, "Bearer Oracle", r.Header.Get("Authorization"))
+			w.Write(certPEM)
+		case "/identity/key.pem":
+			w.Write(encodeKey(certKey))
+		case "/v1/x509":
+			federations++
+			keyID, _ := verifySignature(t, r, &certKey.PublicKey)
+			assert.Equal(t, "ocid1.tenancy.oc1..aaa/fed-x509/"+fingerprint(cert), keyID)
+
+			var request map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, base64.StdEncoding.EncodeToString(der), request["certificate"])
+
+			w.Write([]byte(`{"token":"` + token + `"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := newInstancePrincipalProvider()
+	provider.MetadataEndpoint = server.URL
+	provider.AuthEndpoint = server.URL
+
+	tenancyID, err := provider.TenancyID()
+	assert.NoError(t, err)
+	assert.Equal(t, "ocid1.tenancy.oc1..aaa", tenancyID)
+
+	keyID, err := provider.KeyID()
+	assert.NoError(t, err)
+	assert.Equal(t, "ST$"+token, keyID)
+
+	_, err = provider.PrivateKey()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, federations)
+}
@@ -0,0 +1,276 @@
+package oci
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const apiVersion = "/20160918"
+
+// Client is a minimal client for the OCI Core Services and Identity APIs,
+// covering what the driver needs to manage an instance.
+type Client struct {
+	IaaSEndpoint     string
+	IdentityEndpoint string
+	keys             keyProvider
+	http             *http.Client
+}
+
+type APIError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("oci API error (%d %s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+type Instance struct {
+	ID                 string `json:"id"`
+	DisplayName        string `json:"displayName"`
+	LifecycleState     string `json:"lifecycleState"`
+	AvailabilityDomain string `json:"availabilityDomain"`
+	Shape              string `json:"shape"`
+}
+
+type LaunchInstanceRequest struct {
+	AvailabilityDomain string            `json:"availabilityDomain"`
+	CompartmentID      string            `json:"compartmentId"`
+	DisplayName        string            `json:"displayName"`
+	Shape              string            `json:"shape"`
+	ShapeConfig        *ShapeConfig      `json:"shapeConfig,omitempty"`
+	SourceDetails      SourceDetails     `json:"sourceDetails"`
+	CreateVnicDetails  CreateVnicDetails `json:"createVnicDetails"`
+	Metadata           map[string]string `json:"metadata"`
+	FreeformTags       map[string]string `json:"freeformTags,omitempty"`
+}
+
+// ShapeConfig sizes the instances of flexible shapes.
+type ShapeConfig struct {
+	OCPUs       float64 `json:"ocpus"`
+	MemoryInGBs float64 `json:"memoryInGBs"`
+}
+
+type SourceDetails struct {
+	SourceType          string `json:"sourceType"`
+	ImageID             string `json:"imageId"`
+	BootVolumeSizeInGBs int    `json:"bootVolumeSizeInGBs,omitempty"`
+}
+
+type CreateVnicDetails struct {
+	SubnetID       string `json:"subnetId"`
+	AssignPublicIP bool   `json:"assignPublicIp"`
+}
+
+type VnicAttachment struct {
+	VnicID         string `json:"vnicId"`
+	LifecycleState string `json:"lifecycleState"`
+}
+
+type Vnic struct {
+	ID        string `json:"id"`
+	PublicIP  string `json:"publicIp"`
+	PrivateIP string `json:"privateIp"`
+	IsPrimary bool   `json:"isPrimary"`
+}
+
+type Subnet struct {
+	ID                     string `json:"id"`
+	DisplayName            string `json:"displayName"`
+	AvailabilityDomain     string `json:"availabilityDomain"`
+	ProhibitPublicIPOnVnic bool   `json:"prohibitPublicIpOnVnic"`
+	LifecycleState         string `json:"lifecycleState"`
+}
+
+type Image struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type AvailabilityDomain struct {
+	Name string `json:"name"`
+}
+
+type Shape struct {
+	Shape string `json:"shape"`
+}
+
+func NewClient(region string, keys keyProvider) *Client {
+	return &Client{
+		IaaSEndpoint:     fmt.Sprintf("https://iaas.%s.oraclecloud.com", region),
+		IdentityEndpoint: fmt.Sprintf("https://identity.%s.oraclecloud.com", region),
+		keys:             keys,
+		http:             &http.Client{},
+	}
+}
+
+func (c *Client) do(method, endpoint, path string, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, endpoint+apiVersion+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	keyID, err := c.keys.KeyID()
+	if err != nil {
+		return err
+	}
+	key, err := c.keys.PrivateKey()
+	if err != nil {
+		return err
+	}
+	if err := signRequest(req, reqBody, keyID, key); err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.Unmarshal(respBody, apiErr)
+		return apiErr
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// IsNotFound returns true if err is an API error for a missing resource,
+// which OCI doesn't tell apart from a resource the user can't access.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) LaunchInstance(launchRequest *LaunchInstanceRequest) (*Instance, error) {
+	instance := &Instance{}
+	if err := c.do("POST", c.IaaSEndpoint, "/instances", launchRequest, instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func (c *Client) GetInstance(id string) (*Instance, error) {
+	instance := &Instance{}
+	if err := c.do("GET", c.IaaSEndpoint, "/instances/"+id, nil, instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+// TerminateInstance terminates an instance and deletes its boot volume.
+func (c *Client) TerminateInstance(id string) error {
+	return c.do("DELETE", c.IaaSEndpoint, "/instances/"+id+"?preserveBootVolume=false", nil, nil)
+}
+
+// InstanceAction runs a power action such as START, SOFTSTOP, STOP or
+// SOFTRESET on an instance.
+func (c *Client) InstanceAction(id, action string) error {
+	return c.do("POST", c.IaaSEndpoint, fmt.Sprintf("/instances/%s?action=%s", id, action), nil, nil)
+}
+
+// GetPrimaryVnic returns the primary VNIC of an instance.
+func (c *Client) GetPrimaryVnic(compartmentID, instanceID string) (*Vnic, error) {
+	attachments := []VnicAttachment{}
+	query := url.Values{"compartmentId": {compartmentID}, "instanceId": {instanceID}}
+	if err := c.do("GET", c.IaaSEndpoint, "/vnicAttachments?"+query.Encode(), nil, &attachments); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range attachments {
+		if attachment.LifecycleState != "ATTACHED" {
+			continue
+		}
+
+		vnic := &Vnic{}
+		if err := c.do("GET", c.IaaSEndpoint, "/vnics/"+attachment.VnicID, nil, vnic); err != nil {
+			return nil, err
+		}
+		if vnic.IsPrimary {
+			return vnic, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the instance %s has no attached VNIC", instanceID)
+}
+
+func (c *Client) GetSubnet(id string) (*Subnet, error) {
+	subnet := &Subnet{}
+	if err := c.do("GET", c.IaaSEndpoint, "/subnets/"+id, nil, subnet); err != nil {
+		return nil, err
+	}
+	return subnet, nil
+}
+
+// ListSubnets returns the subnets of a VCN.
+func (c *Client) ListSubnets(compartmentID, vcnID string) ([]Subnet, error) {
+	subnets := []Subnet{}
+	query := url.Values{"compartmentId": {compartmentID}, "vcnId": {vcnID}}
+	if err := c.do("GET", c.IaaSEndpoint, "/subnets?"+query.Encode(), nil, &subnets); err != nil {
+		return nil, err
+	}
+	return subnets, nil
+}
+
+// ListImages returns the most recent images of an operating system which can
+// run on a shape, newest first.
+func (c *Client) ListImages(compartmentID, operatingSystem, version, shape string) ([]Image, error) {
+	images := []Image{}
+	query := url.Values{
+		"compartmentId":          {compartmentID},
+		"operatingSystem":        {operatingSystem},
+		"operatingSystemVersion": {version},
+		"shape":                  {shape},
+		"sortBy":                 {"TIMECREATED"},
+		"sortOrder":              {"DESC"},
+	}
+	if err := c.do("GET", c.IaaSEndpoint, "/images?"+query.Encode(), nil, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// ListShapes returns the shapes available in an availability domain.
+func (c *Client) ListShapes(compartmentID, availabilityDomain string) ([]Shape, error) {
+	shapes := []Shape{}
+	query := url.Values{"compartmentId": {compartmentID}, "availabilityDomain": {availabilityDomain}}
+	if err := c.do("GET", c.IaaSEndpoint, "/shapes?"+query.Encode(), nil, &shapes); err != nil {
+		return nil, err
+	}
+	return shapes, nil
+}
+
+// ListAvailabilityDomains returns the availability domains of the region.
+func (c *Client) ListAvailabilityDomains(tenancyID string) ([]AvailabilityDomain, error) {
+	domains := []AvailabilityDomain{}
+	query := url.Values{"compartmentId": {tenancyID}}
+	if err := c.do("GET", c.IdentityEndpoint, "/availabilityDomains?"+query.Encode(), nil, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
@@ -0,0 +1,648 @@
+package oci
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	ConfigFile         string
+	Profile            string
+	InstancePrincipal  bool
+	Region             string
+	TenancyID          string
+	CompartmentID      string
+	AvailabilityDomain string
+	Shape              string
+	OCPUs              float64
+	MemoryInGBs        int
+	ImageID            string
+	ImageOS            string
+	ImageOSVersion     string
+	BootVolumeSize     int
+	VCNID              string
+	SubnetID           string
+	InstanceID         string
+	PrivateIPAddress   string
+	Tags               []string
+	UserDataFile       string
+	client             *Client
+}
+
+const (
+	defaultSSHPort        = 22
+	defaultSSHUser        = "ubuntu"
+	defaultShape          = "VM.Standard.A1.Flex"
+	defaultOCPUs          = "1"
+	defaultMemoryInGBs    = 6
+	defaultImageOS        = "Canonical Ubuntu"
+	defaultImageOSVersion = "22.04"
+	// minBootVolumeSize is the smallest boot volume OCI creates, in GB
+	minBootVolumeSize = 50
+)
+
+// ampereShape matches the shapes of Ampere Arm processors, e.g.
+// VM.Standard.A1.Flex or BM.Standard.A1.160.
+var ampereShape = regexp.MustCompile(`^(VM|BM)\.[A-Za-z]+\.A[0-9]+\.`)
+
+// openEnginePort is the user data of the instances created without one.  The
+// images of OCI only accept SSH connections, their firewall has to let the
+// engine be reached too.
+const openEnginePort = `#!/bin/sh
+if command -v firewall-cmd >/dev/null 2>&1; then
+  firewall-cmd --permanent --add-port=2376/tcp
+  firewall-cmd --reload
+elif [ -f /etc/iptables/rules.v4 ]; then
+  iptables -I INPUT -p tcp --dport 2376 -j ACCEPT
+  netfilter-persistent save
+fi
+`
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "OCI_CONFIG_FILE",
+			Name:   "oci-config-file",
+			Usage:  "OCI CLI configuration file with the API key, defaults to ~/.oci/config",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_PROFILE",
+			Name:   "oci-profile",
+			Usage:  "Profile of the OCI CLI configuration file",
+			Value:  defaultProfile,
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "OCI_INSTANCE_PRINCIPAL",
+			Name:   "oci-instance-principal",
+			Usage:  "Authenticate as the OCI instance Machine runs on instead of with an API key",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_REGION",
+			Name:   "oci-region",
+			Usage:  "OCI region, defaults to the one of the profile or of the instance",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_COMPARTMENT_ID",
+			Name:   "oci-compartment-id",
+			Usage:  "OCI compartment to create the instance in, defaults to the tenancy",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_AVAILABILITY_DOMAIN",
+			Name:   "oci-availability-domain",
+			Usage:  "OCI availability domain, defaults to the one of the subnet or the first one",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_SHAPE",
+			Name:   "oci-shape",
+			Usage:  "OCI shape of the instance",
+			Value:  defaultShape,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_OCPUS",
+			Name:   "oci-ocpus",
+			Usage:  "Number of OCPUs of an instance of a flexible shape",
+			Value:  defaultOCPUs,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "OCI_MEMORY",
+			Name:   "oci-memory",
+			Usage:  "Memory of an instance of a flexible shape, in GB",
+			Value:  defaultMemoryInGBs,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_IMAGE_ID",
+			Name:   "oci-image-id",
+			Usage:  "OCI image of the instance, instead of the latest image of the operating system",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_IMAGE_OS",
+			Name:   "oci-image-os",
+			Usage:  "Operating system of the image of the instance",
+			Value:  defaultImageOS,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_IMAGE_OS_VERSION",
+			Name:   "oci-image-os-version",
+			Usage:  "Version of the operating system of the image of the instance",
+			Value:  defaultImageOSVersion,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "OCI_BOOT_VOLUME_SIZE",
+			Name:   "oci-boot-volume-size",
+			Usage:  "Size of the boot volume in GB, defaults to the size of the image",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_VCN_ID",
+			Name:   "oci-vcn-id",
+			Usage:  "OCI VCN whose first public subnet the instance is attached to",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_SUBNET_ID",
+			Name:   "oci-subnet-id",
+			Usage:  "OCI subnet the instance is attached to",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "OCI_TAGS",
+			Name:   "oci-tags",
+			Usage:  "Freeform tags of the instance, as key=value",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_USER_DATA",
+			Name:   "oci-user-data",
+			Usage:  "path to file with cloud-init user-data",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OCI_SSH_USER",
+			Name:   "oci-ssh-user",
+			Usage:  "SSH username",
+			Value:  defaultSSHUser,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "OCI_SSH_PORT",
+			Name:   "oci-ssh-port",
+			Usage:  "SSH port",
+			Value:  defaultSSHPort,
+		},
+	}
+}
+
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Profile:        defaultProfile,
+		Shape:          defaultShape,
+		OCPUs:          1,
+		MemoryInGBs:    defaultMemoryInGBs,
+		ImageOS:        defaultImageOS,
+		ImageOSVersion: defaultImageOSVersion,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "oci"
+}
+
+// Capabilities returns the features of the driver, including user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.UserData = true
+	return capabilities
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.ConfigFile = flags.String("oci-config-file")
+	d.Profile = flags.String("oci-profile")
+	d.InstancePrincipal = flags.Bool("oci-instance-principal")
+	d.Region = flags.String("oci-region")
+	d.CompartmentID = flags.String("oci-compartment-id")
+	d.AvailabilityDomain = flags.String("oci-availability-domain")
+	d.Shape = flags.String("oci-shape")
+	d.MemoryInGBs = flags.Int("oci-memory")
+	d.ImageID = flags.String("oci-image-id")
+	d.ImageOS = flags.String("oci-image-os")
+	d.ImageOSVersion = flags.String("oci-image-os-version")
+	d.BootVolumeSize = flags.Int("oci-boot-volume-size")
+	d.VCNID = flags.String("oci-vcn-id")
+	d.SubnetID = flags.String("oci-subnet-id")
+	d.Tags = flags.StringSlice("oci-tags")
+	d.UserDataFile = flags.String("oci-user-data")
+	d.SSHUser = flags.String("oci-ssh-user")
+	d.SSHPort = flags.Int("oci-ssh-port")
+	d.SetSwarmConfigFromFlags(flags)
+
+	if d.ConfigFile == "" {
+		d.ConfigFile = defaultConfigFile()
+	}
+
+	ocpus, err := strconv.ParseFloat(flags.String("oci-ocpus"), 64)
+	if err != nil || ocpus <= 0 {
+		return fmt.Errorf("oci OCPUs %q is not a positive number", flags.String("oci-ocpus"))
+	}
+	d.OCPUs = ocpus
+
+	if d.VCNID == "" && d.SubnetID == "" {
+		return fmt.Errorf("oci driver requires the --oci-subnet-id or --oci-vcn-id option")
+	}
+
+	if d.BootVolumeSize != 0 && d.BootVolumeSize < minBootVolumeSize {
+		return fmt.Errorf("oci boot volumes are at least %d GB", minBootVolumeSize)
+	}
+
+	for _, tag := range d.Tags {
+		if !strings.Contains(tag, "=") {
+			return fmt.Errorf("oci tag %q is not a key=value pair", tag)
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
+	client, err := d.getClient()
+	if err != nil {
+		return err
+	}
+
+	if d.CompartmentID == "" {
+		d.CompartmentID = d.TenancyID
+	}
+
+	if err := d.selectSubnet(client); err != nil {
+		return err
+	}
+
+	if d.AvailabilityDomain == "" {
+		domains, err := client.ListAvailabilityDomains(d.TenancyID)
+		if err != nil {
+			return err
+		}
+		if len(domains) == 0 {
+			return fmt.Errorf("oci region %s has no availability domain", d.Region)
+		}
+		d.AvailabilityDomain = domains[0].Name
+	}
+
+	shapes, err := client.ListShapes(d.CompartmentID, d.AvailabilityDomain)
+	if err != nil {
+		return err
+	}
+	if !hasShape(shapes, d.Shape) {
+		return fmt.Errorf("oci shape %q is not available in %s", d.Shape, d.AvailabilityDomain)
+	}
+
+	if d.ImageID == "" {
+		images, err := client.ListImages(d.CompartmentID, d.ImageOS, d.ImageOSVersion, d.Shape)
+		if err != nil {
+			return err
+		}
+		if len(images) == 0 {
+			return fmt.Errorf("oci has no %s %s image for the shape %s", d.ImageOS, d.ImageOSVersion, d.Shape)
+		}
+		d.ImageID = images[0].ID
+		log.Debugf("Using the image %s", images[0].DisplayName)
+	}
+
+	return nil
+}
+
+// selectSubnet checks the subnet of the instance, or selects the first public
+// one of the VCN, in the availability domain if one is given.  The
+// instance is created in the availability domain of an AD-specific subnet.
+func (d *Driver) selectSubnet(client *Client) error {
+	if d.SubnetID == "" {
+		subnets, err := client.ListSubnets(d.CompartmentID, d.VCNID)
+		if err != nil {
+			return err
+		}
+
+		for _, subnet := range subnets {
+			if subnet.LifecycleState != "AVAILABLE" || subnet.ProhibitPublicIPOnVnic {
+				continue
+			}
+			if subnet.AvailabilityDomain != "" && d.AvailabilityDomain != "" && subnet.AvailabilityDomain != d.AvailabilityDomain {
+				continue
+			}
+			d.SubnetID = subnet.ID
+			break
+		}
+
+		if d.SubnetID == "" {
+			return fmt.Errorf("oci VCN %s has no public subnet in the compartment", d.VCNID)
+		}
+	}
+
+	subnet, err := client.GetSubnet(d.SubnetID)
+	if err != nil {
+		return err
+	}
+
+	if subnet.ProhibitPublicIPOnVnic {
+		return fmt.Errorf("oci subnet %s is private, Machine needs a public IP address to reach the instance", d.SubnetID)
+	}
+
+	if subnet.AvailabilityDomain != "" {
+		if d.AvailabilityDomain != "" && d.AvailabilityDomain != subnet.AvailabilityDomain {
+			return fmt.Errorf("oci subnet %s is in the availability domain %s", d.SubnetID, subnet.AvailabilityDomain)
+		}
+		d.AvailabilityDomain = subnet.AvailabilityDomain
+	}
+
+	return nil
+}
+
+func hasShape(shapes []Shape, name string) bool {
+	for _, shape := range shapes {
+		if shape.Shape == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Driver) Create() error {
+	userdata := []byte(openEnginePort)
+	if d.UserDataFile != "" {
+		buf, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		userdata = buf
+	}
+
+	log.Info("Creating SSH key...")
+
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	client, err := d.getClient()
+	if err != nil {
+		return err
+	}
+
+	launchRequest := &LaunchInstanceRequest{
+		AvailabilityDomain: d.AvailabilityDomain,
+		CompartmentID:      d.CompartmentID,
+		DisplayName:        d.MachineName,
+		Shape:              d.Shape,
+		SourceDetails: SourceDetails{
+			SourceType:          "image",
+			ImageID:             d.ImageID,
+			BootVolumeSizeInGBs: d.BootVolumeSize,
+		},
+		CreateVnicDetails: CreateVnicDetails{
+			SubnetID:       d.SubnetID,
+			AssignPublicIP: true,
+		},
+		Metadata: map[string]string{
+			"ssh_authorized_keys": strings.TrimSpace(string(publicKey)),
+			"user_data":           base64.StdEncoding.EncodeToString(userdata),
+		},
+		FreeformTags: d.freeformTags(),
+	}
+	if isFlexible(d.Shape) {
+		launchRequest.ShapeConfig = &ShapeConfig{
+			OCPUs:       d.OCPUs,
+			MemoryInGBs: float64(d.MemoryInGBs),
+		}
+	}
+
+	log.Info("Creating OCI instance...")
+
+	instance, err := client.LaunchInstance(launchRequest)
+	if err != nil {
+		return err
+	}
+
+	d.InstanceID = instance.ID
+
+	log.Info("Waiting for the instance to be running...")
+	if err := mcnutils.WaitForSpecificOrError(func() (bool, error) {
+		instance, err := client.GetInstance(d.InstanceID)
+		if err != nil {
+			return false, err
+		}
+		if instance.LifecycleState == "TERMINATED" {
+			return false, fmt.Errorf("oci instance %s was terminated", d.InstanceID)
+		}
+		return instance.LifecycleState == "RUNNING", nil
+	}, 90, 5*time.Second); err != nil {
+		return err
+	}
+
+	vnic, err := client.GetPrimaryVnic(d.CompartmentID, d.InstanceID)
+	if err != nil {
+		return err
+	}
+
+	d.IPAddress = vnic.PublicIP
+	d.PrivateIPAddress = vnic.PrivateIP
+
+	log.Debugf("Created instance ID %s, IP address %s, private IP address %s",
+		d.InstanceID,
+		d.IPAddress,
+		d.PrivateIPAddress)
+
+	return nil
+}
+
+// isFlexible returns true if the instances of the shape are sized by their
+// shape configuration.
+func isFlexible(shape string) bool {
+	return strings.HasSuffix(shape, ".Flex")
+}
+
+// GetArchitecture returns the architecture of the processors of the shape,
+// arm64 for the Ampere shapes such as VM.Standard.A1.Flex.
+func (d *Driver) GetArchitecture() (string, error) {
+	return shapeArchitecture(d.Shape), nil
+}
+
+func shapeArchitecture(shape string) string {
+	if ampereShape.MatchString(shape) {
+		return mcnutils.ArchARM64
+	}
+	return mcnutils.ArchAMD64
+}
+
+func (d *Driver) freeformTags() map[string]string {
+	if len(d.Tags) == 0 {
+		return nil
+	}
+
+	tags := map[string]string{}
+	for _, tag := range d.Tags {
+		parts := strings.SplitN(tag, "=", 2)
+		tags[parts[0]] = parts[1]
+	}
+
+	return tags
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+// GetPrivateIP returns the private IP address of the primary VNIC of the
+// instance.
+func (d *Driver) GetPrivateIP() (string, error) {
+	if d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+
+	client, err := d.getClient()
+	if err != nil {
+		return "", err
+	}
+
+	vnic, err := client.GetPrimaryVnic(d.CompartmentID, d.InstanceID)
+	if err != nil {
+		return "", err
+	}
+
+	d.PrivateIPAddress = vnic.PrivateIP
+	return d.PrivateIPAddress, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	client, err := d.getClient()
+	if err != nil {
+		return state.Error, err
+	}
+
+	instance, err := client.GetInstance(d.InstanceID)
+	if err != nil {
+		return state.Error, err
+	}
+	switch instance.LifecycleState {
+	case "PROVISIONING", "STARTING":
+		return state.Starting, nil
+	case "RUNNING", "CREATING_IMAGE":
+		return state.Running, nil
+	case "STOPPING":
+		return state.Stopping, nil
+	case "STOPPED":
+		return state.Stopped, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) Start() error {
+	return d.instanceAction("START")
+}
+
+// Stop sends an ACPI shutdown to the instance.
+func (d *Driver) Stop() error {
+	return d.instanceAction("SOFTSTOP")
+}
+
+func (d *Driver) Restart() error {
+	return d.instanceAction("SOFTRESET")
+}
+
+func (d *Driver) Kill() error {
+	return d.instanceAction("STOP")
+}
+
+func (d *Driver) instanceAction(action string) error {
+	client, err := d.getClient()
+	if err != nil {
+		return err
+	}
+
+	return client.InstanceAction(d.InstanceID, action)
+}
+
+// Remove terminates the instance and deletes its boot volume.
+func (d *Driver) Remove() error {
+	if d.InstanceID == "" {
+		return nil
+	}
+
+	client, err := d.getClient()
+	if err != nil {
+		return err
+	}
+
+	if err := client.TerminateInstance(d.InstanceID); err != nil {
+		if IsNotFound(err) {
+			log.Infof("OCI instance doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+// getClient authenticates with the instance principal or the API key of the
+// profile, which also give the tenancy and the default region.
+func (d *Driver) getClient() (*Client, error) {
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	var keys keyProvider
+	if d.InstancePrincipal {
+		provider := newInstancePrincipalProvider()
+
+		tenancyID, err := provider.TenancyID()
+		if err != nil {
+			return nil, err
+		}
+		d.TenancyID = tenancyID
+
+		if d.Region == "" {
+			if d.Region, err = provider.Region(); err != nil {
+				return nil, err
+			}
+		}
+		keys = provider
+	} else {
+		provider, err := loadAPIKeyProvider(d.ConfigFile, d.Profile)
+		if err != nil {
+			return nil, err
+		}
+		d.TenancyID = provider.TenancyID
+
+		if d.Region == "" {
+			d.Region = provider.Region
+		}
+		keys = provider
+	}
+
+	if d.Region == "" {
+		return nil, fmt.Errorf("oci driver requires the --oci-region option, the profile %s has no region", d.Profile)
+	}
+
+	d.client = NewClient(d.Region, keys)
+	return d.client, nil
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
@@ -0,0 +1,231 @@
+package oci
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// staticKeys signs the requests with a fixed key.
+type staticKeys struct {
+	key *rsa.PrivateKey
+}
+
+func (k *staticKeys) KeyID() (string, error) {
+	return "tenancy/user/fingerprint", nil
+}
+
+func (k *staticKeys) PrivateKey() (*rsa.PrivateKey, error) {
+	return k.key, nil
+}
+
+func newTestDriver(t *testing.T, storePath string, handler http.HandlerFunc) (*Driver, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	driver := NewDriver("default", storePath)
+	driver.Region = "eu-frankfurt-1"
+	driver.TenancyID = "ocid1.tenancy"
+	driver.client = NewClient(driver.Region, &staticKeys{newTestKey(t)})
+	driver.client.IaaSEndpoint = server.URL
+	driver.client.IdentityEndpoint = server.URL
+
+	return driver, server
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"oci-subnet-id": "ocid1.subnet",
+			"oci-ocpus":     "2",
+			"oci-tags":      []string{"team=ci"},
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, float64(2), driver.OCPUs)
+	assert.Equal(t, defaultShape, driver.Shape)
+	assert.Equal(t, defaultProfile, driver.Profile)
+	assert.Equal(t, map[string]string{"team": "ci"}, driver.freeformTags())
+	assert.Equal(t, driver.ResolveStorePath("id_rsa"), driver.GetSSHKeyPath())
+}
+
+func TestSetConfigFromFlagsRequiresNetwork(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.EqualError(t, driver.SetConfigFromFlags(checkFlags), "oci driver requires the --oci-subnet-id or --oci-vcn-id option")
+}
+
+func TestSetConfigFromFlagsBootVolumeSize(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"oci-subnet-id":        "ocid1.subnet",
+			"oci-boot-volume-size": 20,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.EqualError(t, driver.SetConfigFromFlags(checkFlags), "oci boot volumes are at least 50 GB")
+}
+
+func TestPreCreateCheckSelectsSubnetAndImage(t *testing.T) {
+	driver, server := newTestDriver(t, "path", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/20160918/subnets":
+			assert.Equal(t, "ocid1.vcn", r.URL.Query().Get("vcnId"))
+			w.Write([]byte(`[{"id":"ocid1.subnet.private","lifecycleState":"AVAILABLE","prohibitPublicIpOnVnic":true},{"id":"ocid1.subnet.public","lifecycleState":"AVAILABLE"}]`))
+		case "/20160918/subnets/ocid1.subnet.public":
+			w.Write([]byte(`{"id":"ocid1.subnet.public","lifecycleState":"AVAILABLE"}`))
+		case "/20160918/availabilityDomains":
+			w.Write([]byte(`[{"name":"kIdk:EU-FRANKFURT-1-AD-1"},{"name":"kIdk:EU-FRANKFURT-1-AD-2"}]`))
+		case "/20160918/shapes":
+			w.Write([]byte(`[{"shape":"VM.Standard.E4.Flex"},{"shape":"VM.Standard.A1.Flex"}]`))
+		case "/20160918/images":
+			assert.Equal(t, "Canonical Ubuntu", r.URL.Query().Get("operatingSystem"))
+			assert.Equal(t, "VM.Standard.A1.Flex", r.URL.Query().Get("shape"))
+			w.Write([]byte(`[{"id":"ocid1.image.aarch64","displayName":"Canonical-Ubuntu-22.04-aarch64"}]`))
+		}
+	})
+	defer server.Close()
+	driver.VCNID = "ocid1.vcn"
+
+	assert.NoError(t, driver.PreCreateCheck())
+	assert.Equal(t, "ocid1.tenancy", driver.CompartmentID)
+	assert.Equal(t, "ocid1.subnet.public", driver.SubnetID)
+	assert.Equal(t, "kIdk:EU-FRANKFURT-1-AD-1", driver.AvailabilityDomain)
+	assert.Equal(t, "ocid1.image.aarch64", driver.ImageID)
+}
+
+func TestPreCreateCheckUnavailableShape(t *testing.T) {
+	driver, server := newTestDriver(t, "path", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/20160918/subnets/ocid1.subnet":
+			w.Write([]byte(`{"id":"ocid1.subnet","availabilityDomain":"kIdk:EU-FRANKFURT-1-AD-2"}`))
+		case "/20160918/shapes":
+			assert.Equal(t, "kIdk:EU-FRANKFURT-1-AD-2", r.URL.Query().Get("availabilityDomain"))
+			w.Write([]byte(`[{"shape":"VM.Standard.E4.Flex"}]`))
+		}
+	})
+	defer server.Close()
+	driver.SubnetID = "ocid1.subnet"
+
+	assert.EqualError(t, driver.PreCreateCheck(), `oci shape "VM.Standard.A1.Flex" is not available in kIdk:EU-FRANKFURT-1-AD-2`)
+}
+
+func TestCreate(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	var launchRequest map[string]interface{}
+	driver, server := newTestDriver(t, storePath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/20160918/instances":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&launchRequest))
+			w.Write([]byte(`{"id":"ocid1.instance","lifecycleState":"PROVISIONING"}`))
+		case "/20160918/instances/ocid1.instance":
+			w.Write([]byte(`{"id":"ocid1.instance","lifecycleState":"RUNNING"}`))
+		case "/20160918/vnicAttachments":
+			w.Write([]byte(`[{"vnicId":"ocid1.vnic","lifecycleState":"ATTACHED"}]`))
+		case "/20160918/vnics/ocid1.vnic":
+			w.Write([]byte(`{"id":"ocid1.vnic","publicIp":"130.61.0.10","privateIp":"10.0.0.12","isPrimary":true}`))
+		}
+	})
+	defer server.Close()
+	driver.CompartmentID = "ocid1.compartment"
+	driver.AvailabilityDomain = "kIdk:EU-FRANKFURT-1-AD-1"
+	driver.SubnetID = "ocid1.subnet"
+	driver.ImageID = "ocid1.image"
+	driver.BootVolumeSize = 100
+
+	assert.NoError(t, driver.Create())
+	assert.Equal(t, "ocid1.instance", driver.InstanceID)
+	assert.Equal(t, "130.61.0.10", driver.IPAddress)
+	assert.Equal(t, "10.0.0.12", driver.PrivateIPAddress)
+
+	assert.Equal(t, map[string]interface{}{"ocpus": float64(1), "memoryInGBs": float64(6)}, launchRequest["shapeConfig"])
+	assert.Equal(t, map[string]interface{}{"sourceType": "image", "imageId": "ocid1.image", "bootVolumeSizeInGBs": float64(100)}, launchRequest["sourceDetails"])
+	metadata := launchRequest["metadata"].(map[string]interface{})
+	assert.NotEmpty(t, metadata["ssh_authorized_keys"])
+	assert.NotEmpty(t, metadata["user_data"])
+}
+
+func TestIsFlexible(t *testing.T) {
+	assert.False(t, isFlexible("VM.Standard2.1"))
+	assert.True(t, isFlexible(defaultShape))
+}
+
+func TestGetArchitecture(t *testing.T) {
+	assert.Equal(t, "arm64", shapeArchitecture(defaultShape))
+	assert.Equal(t, "arm64", shapeArchitecture("BM.Standard.A1.160"))
+	assert.Equal(t, "amd64", shapeArchitecture("VM.Standard.E4.Flex"))
+	assert.Equal(t, "amd64", shapeArchitecture("VM.Standard2.1"))
+}
+
+func TestGetState(t *testing.T) {
+	lifecycleState := "STOPPING"
+	driver, server := newTestDriver(t, "path", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"ocid1.instance","lifecycleState":"` + lifecycleState + `"}`))
+	})
+	defer server.Close()
+	driver.InstanceID = "ocid1.instance"
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopping, s)
+
+	lifecycleState = "STOPPED"
+	s, err = driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+}
+
+func TestStopIsSoft(t *testing.T) {
+	actions := []string{}
+	driver, server := newTestDriver(t, "path", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		actions = append(actions, r.URL.Query().Get("action"))
+		w.Write([]byte(`{"id":"ocid1.instance"}`))
+	})
+	defer server.Close()
+	driver.InstanceID = "ocid1.instance"
+
+	assert.NoError(t, driver.Stop())
+	assert.NoError(t, driver.Kill())
+	assert.Equal(t, []string{"SOFTSTOP", "STOP"}, actions)
+}
+
+func TestRemoveIgnoresMissingInstance(t *testing.T) {
+	driver, server := newTestDriver(t, "path", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		assert.Equal(t, "false", r.URL.Query().Get("preserveBootVolume"))
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":"NotAuthorizedOrNotFound","message":"Authorization failed or requested resource not found."}`))
+	})
+	defer server.Close()
+	driver.InstanceID = "ocid1.instance"
+
+	assert.NoError(t, driver.Remove())
+}
@@ -4,11 +4,14 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/docker/machine/libmachine/imagefilter"
 	"github.com/docker/machine/libmachine/log"
-	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/retry"
 	"github.com/docker/machine/libmachine/version"
+	"github.com/mitchellh/mapstructure"
 	"github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/openstack"
 	compute_ips "github.com/rackspace/gophercloud/openstack/compute/v2/extensions/floatingip"
@@ -18,6 +21,7 @@ import (
 	"github.com/rackspace/gophercloud/openstack/compute/v2/images"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/servers"
 	"github.com/rackspace/gophercloud/openstack/identity/v2/tenants"
+	tokens3 "github.com/rackspace/gophercloud/openstack/identity/v3/tokens"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/networks"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/ports"
@@ -42,13 +46,19 @@ type Client interface {
 	CreateKeyPair(d *Driver, name string, publicKey string) error
 	DeleteKeyPair(d *Driver, name string) error
 	GetNetworkID(d *Driver) (string, error)
+	GetNetworkIDByName(d *Driver, name string) (string, error)
 	GetFlavorID(d *Driver) (string, error)
 	GetImageID(d *Driver) (string, error)
+	ListImages(d *Driver) ([]imagefilter.Image, error)
 	AssignFloatingIP(d *Driver, floatingIP *FloatingIP) error
 	GetFloatingIPs(d *Driver) ([]FloatingIP, error)
 	GetFloatingIPPoolID(d *Driver) (string, error)
 	GetInstancePortID(d *Driver) (string, error)
 	GetTenantID(d *Driver) (string, error)
+	GetServerGroupID(d *Driver) (string, error)
+	CreateServerGroup(d *Driver) (string, error)
+	CreatePort(d *Driver) (string, error)
+	DeletePort(d *Driver) error
 }
 
 type GenericClient struct {
@@ -67,20 +77,81 @@ func (c *GenericClient) CreateInstance(d *Driver) (string, error) {
 		SecurityGroups:   d.SecurityGroups,
 		AvailabilityZone: d.AvailabilityZone,
 	}
-	if d.NetworkId != "" {
+	if d.PortId != "" {
+		serverOpts.Networks = []servers.Network{
+			{
+				Port: d.PortId,
+			},
+		}
+	} else if d.NetworkId != "" {
 		serverOpts.Networks = []servers.Network{
 			{
 				UUID: d.NetworkId,
 			},
 		}
 	}
+	for _, networkID := range d.AdditionalNetworkIds {
+		serverOpts.Networks = append(serverOpts.Networks, servers.Network{
+			UUID: networkID,
+		})
+	}
+
+	body, err := keypairs.CreateOptsExt{
+		CreateOptsBuilder: serverOpts,
+		KeyName:           d.KeyPairName,
+	}.ToServerCreateMap()
+	if err != nil {
+		return "", err
+	}
+
+	// The vendored gophercloud knows neither block device mappings nor
+	// scheduler hints, which are added to the request it builds.
+	headers := map[string]string{}
+	if d.BootFromVolume {
+		volumeSize := d.VolumeSize
+		if volumeSize == 0 {
+			flavor, err := flavors.Get(c.Compute, d.FlavorId).Extract()
+			if err != nil {
+				return "", err
+			}
+			if flavor.Disk == 0 {
+				return "", fmt.Errorf("The flavor %s has no disk, the volume size must be specified with --openstack-volume-size", d.FlavorId)
+			}
+			volumeSize = flavor.Disk
+		}
+
+		blockDevice := map[string]interface{}{
+			"boot_index":            0,
+			"uuid":                  d.ImageId,
+			"source_type":           "image",
+			"destination_type":      "volume",
+			"volume_size":           volumeSize,
+			"delete_on_termination": true,
+		}
+		if d.VolumeType != "" {
+			// Volume types of block device mappings need microversion 2.67.
+			blockDevice["volume_type"] = d.VolumeType
+			headers["X-OpenStack-Nova-API-Version"] = "2.67"
+		}
+
+		server := body["server"].(map[string]interface{})
+		server["imageRef"] = ""
+		server["block_device_mapping_v2"] = []map[string]interface{}{blockDevice}
+	}
+	if d.ServerGroupId != "" {
+		body["os:scheduler_hints"] = map[string]interface{}{
+			"group": d.ServerGroupId,
+		}
+	}
 
 	log.Info("Creating machine...")
 
-	server, err := servers.Create(c.Compute, keypairs.CreateOptsExt{
-		serverOpts,
-		d.KeyPairName,
-	}).Extract()
+	var result servers.CreateResult
+	_, result.Err = c.Compute.Post(c.Compute.ServiceURL("servers"), body, &result.Body, &gophercloud.RequestOpts{
+		OkCodes:     []int{202},
+		MoreHeaders: headers,
+	})
+	server, err := result.Extract()
 	if err != nil {
 		return "", err
 	}
@@ -146,22 +217,19 @@ func (c *GenericClient) DeleteInstance(d *Driver) error {
 }
 
 func (c *GenericClient) WaitForInstanceStatus(d *Driver, status string) error {
-	return mcnutils.WaitForSpecificOrError(func() (bool, error) {
+	budget := time.Duration(d.ActiveTimeout) * time.Second
+	return retry.For(retry.Instance).WithBudget(budget).Until(func() (bool, error) {
 		current, err := servers.Get(c.Compute, d.MachineId).Extract()
 		if err != nil {
-			return true, err
+			return false, err
 		}
 
 		if current.Status == "ERROR" {
-			return true, fmt.Errorf("Instance creation failed. Instance is in ERROR state")
-		}
-
-		if current.Status == status {
-			return true, nil
+			return true, retry.Permanent(fmt.Errorf("Instance creation failed. Instance is in ERROR state"))
 		}
 
-		return false, nil
-	}, (d.ActiveTimeout / 4), 4*time.Second)
+		return current.Status == status, nil
+	})
 }
 
 func (c *GenericClient) GetInstanceIPAddresses(d *Driver) ([]IPAddress, error) {
@@ -204,6 +272,10 @@ func (c *GenericClient) GetNetworkID(d *Driver) (string, error) {
 	return c.getNetworkID(d, d.NetworkName)
 }
 
+func (c *GenericClient) GetNetworkIDByName(d *Driver, name string) (string, error) {
+	return c.getNetworkID(d, name)
+}
+
 func (c *GenericClient) GetFloatingIPPoolID(d *Driver) (string, error) {
 	return c.getNetworkID(d, d.FloatingIpPool)
 }
@@ -279,6 +351,35 @@ func (c *GenericClient) GetImageID(d *Driver) (string, error) {
 	return imageID, err
 }
 
+// ListImages lists the active images, for the image filter to select one.
+func (c *GenericClient) ListImages(d *Driver) ([]imagefilter.Image, error) {
+	pager := images.ListDetail(c.Compute, images.ListOpts{})
+	result := []imagefilter.Image{}
+
+	err := pager.EachPage(func(page pagination.Page) (bool, error) {
+		imageList, err := images.ExtractImages(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, i := range imageList {
+			if i.Status != "ACTIVE" {
+				continue
+			}
+			created, _ := time.Parse(time.RFC3339, i.Created)
+			result = append(result, imagefilter.Image{
+				ID:      i.ID,
+				Name:    i.Name,
+				Created: created,
+			})
+		}
+
+		return true, nil
+	})
+
+	return result, err
+}
+
 func (c *GenericClient) GetTenantID(d *Driver) (string, error) {
 	pager := tenants.List(c.Identity, nil)
 	tenantId := ""
@@ -302,6 +403,91 @@ func (c *GenericClient) GetTenantID(d *Driver) (string, error) {
 	return tenantId, err
 }
 
+func (c *GenericClient) GetServerGroupID(d *Driver) (string, error) {
+	var body interface{}
+	if _, err := c.Compute.Get(c.Compute.ServiceURL("os-server-groups"), &body, nil); err != nil {
+		return "", err
+	}
+
+	var response struct {
+		ServerGroups []struct {
+			ID   string `mapstructure:"id"`
+			Name string `mapstructure:"name"`
+		} `mapstructure:"server_groups"`
+	}
+	if err := mapstructure.Decode(body, &response); err != nil {
+		return "", err
+	}
+
+	for _, g := range response.ServerGroups {
+		if g.ID == d.ServerGroup || g.Name == d.ServerGroup {
+			return g.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *GenericClient) CreateServerGroup(d *Driver) (string, error) {
+	opts := &gophercloud.RequestOpts{}
+	if strings.HasPrefix(d.ServerGroupPolicy, "soft-") {
+		// Soft policies need microversion 2.15.
+		opts.MoreHeaders = map[string]string{"X-OpenStack-Nova-API-Version": "2.15"}
+	}
+
+	var body interface{}
+	_, err := c.Compute.Post(c.Compute.ServiceURL("os-server-groups"), map[string]interface{}{
+		"server_group": map[string]interface{}{
+			"name":     d.ServerGroup,
+			"policies": []string{d.ServerGroupPolicy},
+		},
+	}, &body, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		ServerGroup struct {
+			ID string `mapstructure:"id"`
+		} `mapstructure:"server_group"`
+	}
+	if err := mapstructure.Decode(body, &response); err != nil {
+		return "", err
+	}
+	return response.ServerGroup.ID, nil
+}
+
+func (c *GenericClient) CreatePort(d *Driver) (string, error) {
+	// The vendored gophercloud doesn't know the port security extension.
+	var body interface{}
+	_, err := c.Network.Post(c.Network.ServiceURL("ports"), map[string]interface{}{
+		"port": map[string]interface{}{
+			"name":                  d.MachineName,
+			"network_id":            d.NetworkId,
+			"port_security_enabled": false,
+		},
+	}, &body, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Port struct {
+			ID string `mapstructure:"id"`
+		} `mapstructure:"port"`
+	}
+	if err := mapstructure.Decode(body, &response); err != nil {
+		return "", err
+	}
+	return response.Port.ID, nil
+}
+
+func (c *GenericClient) DeletePort(d *Driver) error {
+	if result := ports.Delete(c.Network, d.PortId); result.Err != nil {
+		return result.Err
+	}
+	return nil
+}
+
 func (c *GenericClient) GetPublicKey(keyPairName string) ([]byte, error) {
 	kp, err := keypairs.Get(c.Compute, keyPairName).Extract()
 	if err != nil {
@@ -530,13 +716,15 @@ func (c *GenericClient) Authenticate(d *Driver) error {
 	}
 
 	log.Debug("Authenticating...", map[string]interface{}{
-		"AuthUrl":    d.AuthUrl,
-		"Insecure":   d.Insecure,
-		"DomainID":   d.DomainID,
-		"DomainName": d.DomainName,
-		"Username":   d.Username,
-		"TenantName": d.TenantName,
-		"TenantID":   d.TenantId,
+		"AuthUrl":                   d.AuthUrl,
+		"Insecure":                  d.Insecure,
+		"DomainID":                  d.DomainID,
+		"DomainName":                d.DomainName,
+		"Username":                  d.Username,
+		"TenantName":                d.TenantName,
+		"TenantID":                  d.TenantId,
+		"ApplicationCredentialID":   d.ApplicationCredentialID,
+		"ApplicationCredentialName": d.ApplicationCredentialName,
 	})
 
 	opts := gophercloud.AuthOptions{
@@ -564,7 +752,11 @@ func (c *GenericClient) Authenticate(d *Driver) error {
 		provider.HTTPClient.Transport = transport
 	}
 
-	err = openstack.Authenticate(provider, opts)
+	if d.ApplicationCredentialSecret != "" {
+		err = c.authenticateApplicationCredential(provider, d)
+	} else {
+		err = openstack.Authenticate(provider, opts)
+	}
 	if err != nil {
 		return err
 	}
@@ -573,3 +765,72 @@ func (c *GenericClient) Authenticate(d *Driver) error {
 
 	return nil
 }
+
+// authenticateApplicationCredential gets a token of the identity v3 API with
+// an application credential, which the vendored gophercloud doesn't know.
+func (c *GenericClient) authenticateApplicationCredential(provider *gophercloud.ProviderClient, d *Driver) error {
+	credential := map[string]interface{}{
+		"secret": d.ApplicationCredentialSecret,
+	}
+	if d.ApplicationCredentialID != "" {
+		credential["id"] = d.ApplicationCredentialID
+	} else {
+		user := map[string]interface{}{
+			"name": d.Username,
+		}
+		if d.DomainID != "" {
+			user["domain"] = map[string]interface{}{"id": d.DomainID}
+		} else if d.DomainName != "" {
+			user["domain"] = map[string]interface{}{"name": d.DomainName}
+		}
+		credential["name"] = d.ApplicationCredentialName
+		credential["user"] = user
+	}
+
+	body := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods":                []string{"application_credential"},
+				"application_credential": credential,
+			},
+		},
+	}
+
+	var result tokens3.CreateResult
+	resp, err := provider.Request("POST", identityV3URL(provider)+"auth/tokens", gophercloud.RequestOpts{
+		JSONBody:     body,
+		JSONResponse: &result.Body,
+		OkCodes:      []int{201},
+	})
+	if err != nil {
+		return err
+	}
+	result.Header = resp.Header
+
+	catalog, err := result.ExtractServiceCatalog()
+	if err != nil {
+		return err
+	}
+
+	provider.TokenID = resp.Header.Get("X-Subject-Token")
+	provider.EndpointLocator = func(opts gophercloud.EndpointOpts) (string, error) {
+		return openstack.V3EndpointURL(catalog, opts)
+	}
+	provider.ReauthFunc = func() error {
+		// Failing to get a new token mustn't trigger another one.
+		provider.TokenID = ""
+		provider.ReauthFunc = nil
+		return c.authenticateApplicationCredential(provider, d)
+	}
+
+	return nil
+}
+
+// identityV3URL returns the identity v3 endpoint, from the authentication URL
+// when it's a v3 one or from its root otherwise.
+func identityV3URL(provider *gophercloud.ProviderClient) string {
+	if strings.HasSuffix(provider.IdentityEndpoint, "/v3/") {
+		return provider.IdentityEndpoint
+	}
+	return provider.IdentityBase + "v3/"
+}
@@ -0,0 +1,164 @@
+package openstack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const errorUnknownCloud string = "Unable to find cloud named %s in %s"
+
+// cloudsFiles returns the clouds.yaml files looked up by the OpenStack
+// client tools, in order of precedence.
+func cloudsFiles() []string {
+	if path := os.Getenv("OS_CLIENT_CONFIG_FILE"); path != "" {
+		return []string{path}
+	}
+
+	return []string{
+		"clouds.yaml",
+		filepath.Join(mcnutils.GetHomeDir(), ".config", "openstack", "clouds.yaml"),
+		"/etc/openstack/clouds.yaml",
+	}
+}
+
+// loadCloud completes the options that weren't given with the ones of the
+// cloud in clouds.yaml.
+func (d *Driver) loadCloud() error {
+	var (
+		path string
+		data []byte
+	)
+	for _, file := range cloudsFiles() {
+		content, err := ioutil.ReadFile(file)
+		if err == nil {
+			path, data = file, content
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if data == nil {
+		return fmt.Errorf("Unable to find a clouds.yaml file for the cloud %s", d.Cloud)
+	}
+
+	clouds, _ := parseYAML(data)["clouds"].(map[string]interface{})
+	cloud, ok := clouds[d.Cloud].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf(errorUnknownCloud, d.Cloud, path)
+	}
+	log.Debugf("Using the cloud %s of %s", d.Cloud, path)
+
+	auth, _ := cloud["auth"].(map[string]interface{})
+	value := func(values map[string]interface{}, keys ...string) string {
+		for _, key := range keys {
+			if s, ok := values[key].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+	fill := func(field *string, s string) {
+		if *field == "" {
+			*field = s
+		}
+	}
+
+	fill(&d.AuthUrl, value(auth, "auth_url"))
+	fill(&d.Username, value(auth, "username"))
+	fill(&d.Password, value(auth, "password"))
+	fill(&d.TenantName, value(auth, "project_name", "tenant_name"))
+	fill(&d.TenantId, value(auth, "project_id", "tenant_id"))
+	fill(&d.DomainName, value(auth, "user_domain_name", "domain_name"))
+	fill(&d.DomainID, value(auth, "user_domain_id", "domain_id"))
+	fill(&d.ApplicationCredentialID, value(auth, "application_credential_id"))
+	fill(&d.ApplicationCredentialName, value(auth, "application_credential_name"))
+	fill(&d.ApplicationCredentialSecret, value(auth, "application_credential_secret"))
+	fill(&d.Region, value(cloud, "region_name"))
+
+	if d.EndpointType == "" {
+		switch value(cloud, "interface", "endpoint_type") {
+		case "public", "publicURL":
+			d.EndpointType = "publicURL"
+		case "internal", "internalURL":
+			d.EndpointType = "internalURL"
+		case "admin", "adminURL":
+			d.EndpointType = "adminURL"
+		}
+	}
+	if value(cloud, "verify") == "false" {
+		d.Insecure = true
+	}
+
+	return nil
+}
+
+type yamlLine struct {
+	indent int
+	key    string
+	value  string
+}
+
+// parseYAML parses the nested mappings of scalars clouds.yaml files are
+// made of.  Lists aren't needed to configure a cloud and are skipped.
+func parseYAML(data []byte) map[string]interface{} {
+	lines := []yamlLine{}
+	for _, raw := range strings.Split(string(data), "\n") {
+		content := strings.TrimLeft(raw, " ")
+		if content == "" || strings.HasPrefix(content, "#") || content == "---" {
+			continue
+		}
+		line := yamlLine{indent: len(raw) - len(content)}
+
+		if strings.HasPrefix(content, "-") {
+			line.key = "-"
+		} else if i := strings.Index(content, ":"); i > 0 {
+			line.key = unquoteYAML(strings.TrimSpace(content[:i]))
+			line.value = unquoteYAML(content[i+1:])
+		} else {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	values, _ := parseYAMLMapping(lines, 0, 0)
+	return values
+}
+
+func parseYAMLMapping(lines []yamlLine, i, indent int) (map[string]interface{}, int) {
+	values := map[string]interface{}{}
+	for i < len(lines) && lines[i].indent >= indent {
+		line := lines[i]
+		i++
+		if line.indent > indent || line.key == "-" {
+			continue
+		}
+
+		if line.value == "" && i < len(lines) && lines[i].indent > indent && lines[i].key != "-" {
+			values[line.key], i = parseYAMLMapping(lines, i, lines[i].indent)
+			continue
+		}
+		values[line.key] = line.value
+	}
+	return values, i
+}
+
+// unquoteYAML returns a scalar without its quotes or trailing comment.
+func unquoteYAML(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') {
+		if end := strings.IndexByte(s[1:], s[0]); end >= 0 {
+			return s[1 : end+1]
+		}
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
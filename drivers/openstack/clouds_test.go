@@ -0,0 +1,85 @@
+package openstack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCloudsYAML = `# clouds.yaml
+clouds:
+  devstack:
+    auth:
+      auth_url: "https://keystone.example.com:5000/v3"
+      username: user # not the admin
+      password: 'pwd'
+      project_name: project
+      user_domain_name: Default
+    regions:
+      - RegionOne
+      - RegionTwo
+    region_name: RegionOne
+    interface: internal
+    verify: false
+  appcred:
+    auth_type: v3applicationcredential
+    auth:
+      auth_url: https://keystone.example.com:5000/v3
+      application_credential_id: ID
+      application_credential_secret: secret
+`
+
+func TestParseYAML(t *testing.T) {
+	values := parseYAML([]byte(testCloudsYAML))
+
+	clouds := values["clouds"].(map[string]interface{})
+	devstack := clouds["devstack"].(map[string]interface{})
+	auth := devstack["auth"].(map[string]interface{})
+
+	assert.Equal(t, "https://keystone.example.com:5000/v3", auth["auth_url"])
+	assert.Equal(t, "user", auth["username"])
+	assert.Equal(t, "pwd", auth["password"])
+	assert.Equal(t, "RegionOne", devstack["region_name"])
+	assert.Equal(t, "", devstack["regions"])
+	assert.Equal(t, "v3applicationcredential", clouds["appcred"].(map[string]interface{})["auth_type"])
+}
+
+func TestLoadCloud(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openstack")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "clouds.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testCloudsYAML), 0600))
+
+	defer os.Setenv("OS_CLIENT_CONFIG_FILE", os.Getenv("OS_CLIENT_CONFIG_FILE"))
+	os.Setenv("OS_CLIENT_CONFIG_FILE", path)
+
+	d := NewDerivedDriver("default", "path")
+	d.Cloud = "devstack"
+	d.Region = "RegionTwo"
+
+	assert.NoError(t, d.loadCloud())
+	assert.Equal(t, "https://keystone.example.com:5000/v3", d.AuthUrl)
+	assert.Equal(t, "user", d.Username)
+	assert.Equal(t, "pwd", d.Password)
+	assert.Equal(t, "project", d.TenantName)
+	assert.Equal(t, "Default", d.DomainName)
+	assert.Equal(t, "RegionTwo", d.Region)
+	assert.Equal(t, "internalURL", d.EndpointType)
+	assert.True(t, d.Insecure)
+
+	d = NewDerivedDriver("default", "path")
+	d.Cloud = "appcred"
+
+	assert.NoError(t, d.loadCloud())
+	assert.Equal(t, "ID", d.ApplicationCredentialID)
+	assert.Equal(t, "secret", d.ApplicationCredentialSecret)
+
+	d.Cloud = "unknown"
+
+	assert.EqualError(t, d.loadCloud(), "Unable to find cloud named unknown in "+path)
+}
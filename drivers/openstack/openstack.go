@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/imagefilter"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/mcnutils"
@@ -17,42 +18,61 @@ import (
 
 type Driver struct {
 	*drivers.BaseDriver
-	AuthUrl          string
-	ActiveTimeout    int
-	Insecure         bool
-	DomainID         string
-	DomainName       string
-	Username         string
-	Password         string
-	TenantName       string
-	TenantId         string
-	Region           string
-	AvailabilityZone string
-	EndpointType     string
-	MachineId        string
-	FlavorName       string
-	FlavorId         string
-	ImageName        string
-	ImageId          string
-	KeyPairName      string
-	NetworkName      string
-	NetworkId        string
-	UserData         []byte
-	PrivateKeyFile   string
-	SecurityGroups   []string
-	FloatingIpPool   string
-	ComputeNetwork   bool
-	FloatingIpPoolId string
-	IpVersion        int
-	client           Client
+	AuthUrl                     string
+	ActiveTimeout               int
+	Insecure                    bool
+	Cloud                       string
+	DomainID                    string
+	DomainName                  string
+	Username                    string
+	Password                    string
+	ApplicationCredentialID     string
+	ApplicationCredentialName   string
+	ApplicationCredentialSecret string
+	TenantName                  string
+	TenantId                    string
+	Region                      string
+	AvailabilityZone            string
+	EndpointType                string
+	MachineId                   string
+	FlavorName                  string
+	FlavorId                    string
+	ImageName                   string
+	ImageFilter                 string `json:",omitempty"`
+	ImageId                     string
+	BootFromVolume              bool
+	VolumeSize                  int
+	VolumeType                  string
+	ServerGroup                 string
+	ServerGroupPolicy           string
+	ServerGroupId               string
+	KeyPairName                 string
+	NetworkName                 string
+	NetworkId                   string
+	AdditionalNetworks          []string `json:",omitempty"`
+	AdditionalNetworkIds        []string `json:",omitempty"`
+	PrivateIPAddress            string   `json:",omitempty"`
+	DisablePortSecurity         bool
+	PortId                      string
+	UserData                    []byte
+	PrivateKeyFile              string
+	SecurityGroups              []string
+	FloatingIpPool              string
+	ComputeNetwork              bool
+	FloatingIpPoolId            string
+	IpVersion                   int
+	client                      Client
 }
 
 const (
-	defaultSSHUser       = "root"
-	defaultSSHPort       = 22
-	defaultActiveTimeout = 200
+	defaultSSHUser           = "root"
+	defaultSSHPort           = 22
+	defaultActiveTimeout     = 200
+	defaultServerGroupPolicy = "anti-affinity"
 )
 
+var serverGroupPolicies = []string{"affinity", "anti-affinity", "soft-affinity", "soft-anti-affinity"}
+
 func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 	return []mcnflag.Flag{
 		mcnflag.StringFlag{
@@ -66,6 +86,12 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "openstack-insecure",
 			Usage:  "Disable TLS credential checking.",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_CLOUD",
+			Name:   "openstack-cloud",
+			Usage:  "OpenStack cloud of clouds.yaml to take the options that aren't given from",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_DOMAIN_ID",
 			Name:   "openstack-domain-id",
@@ -90,6 +116,24 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "OpenStack password",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_APPLICATION_CREDENTIAL_ID",
+			Name:   "openstack-application-credential-id",
+			Usage:  "OpenStack application credential id (identity v3 only)",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_APPLICATION_CREDENTIAL_NAME",
+			Name:   "openstack-application-credential-name",
+			Usage:  "OpenStack application credential name, of the user given by --openstack-username (identity v3 only)",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_APPLICATION_CREDENTIAL_SECRET",
+			Name:   "openstack-application-credential-secret",
+			Usage:  "OpenStack application credential secret, authenticating instead of the password",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_TENANT_NAME",
 			Name:   "openstack-tenant-name",
@@ -144,6 +188,35 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "OpenStack image name to use for the instance",
 			Value:  "",
 		},
+		mcnflag.BoolFlag{
+			EnvVar: "OS_BOOT_FROM_VOLUME",
+			Name:   "openstack-boot-from-volume",
+			Usage:  "Boot the instance from a volume created from the image, deleted with the instance",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "OS_VOLUME_SIZE",
+			Name:   "openstack-volume-size",
+			Usage:  "OpenStack size of the boot volume in GB, the disk of the flavor by default",
+			Value:  0,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_VOLUME_TYPE",
+			Name:   "openstack-volume-type",
+			Usage:  "OpenStack volume type of the boot volume",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_SERVER_GROUP",
+			Name:   "openstack-server-group",
+			Usage:  "OpenStack server group name or id to schedule the instance in, created if it doesn't exist",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_SERVER_GROUP_POLICY",
+			Name:   "openstack-server-group-policy",
+			Usage:  "OpenStack policy of the server group created (affinity, anti-affinity, soft-affinity or soft-anti-affinity)",
+			Value:  defaultServerGroupPolicy,
+		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_KEYPAIR_NAME",
 			Name:   "openstack-keypair-name",
@@ -180,6 +253,16 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "OpenStack comma separated security groups for the machine",
 			Value:  "",
 		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "OS_ADDITIONAL_NETWORKS",
+			Name:   "openstack-additional-networks",
+			Usage:  "OpenStack networks (name or id) of other ports of the machine",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "OS_DISABLE_PORT_SECURITY",
+			Name:   "openstack-disable-port-security",
+			Usage:  "Disable the port security of the port of the instance, e.g. to route other addresses through it",
+		},
 		mcnflag.BoolFlag{
 			EnvVar: "OS_NOVA_NETWORK",
 			Name:   "openstack-nova-network",
@@ -209,6 +292,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "OpenStack SSH port",
 			Value:  defaultSSHPort,
 		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_SSH_PROXY_JUMP",
+			Name:   "openstack-ssh-proxy-jump",
+			Usage:  "Bastion host to connect to the instance through, as [user@]host[:port]",
+		},
 		mcnflag.IntFlag{
 			EnvVar: "OS_ACTIVE_TIMEOUT",
 			Name:   "openstack-active-timeout",
@@ -248,14 +336,25 @@ func (d *Driver) DriverName() string {
 	return "openstack"
 }
 
+// Capabilities returns the features of the driver, including user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.UserData = true
+	return capabilities
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AuthUrl = flags.String("openstack-auth-url")
 	d.ActiveTimeout = flags.Int("openstack-active-timeout")
 	d.Insecure = flags.Bool("openstack-insecure")
+	d.Cloud = flags.String("openstack-cloud")
 	d.DomainID = flags.String("openstack-domain-id")
 	d.DomainName = flags.String("openstack-domain-name")
 	d.Username = flags.String("openstack-username")
 	d.Password = flags.String("openstack-password")
+	d.ApplicationCredentialID = flags.String("openstack-application-credential-id")
+	d.ApplicationCredentialName = flags.String("openstack-application-credential-name")
+	d.ApplicationCredentialSecret = flags.String("openstack-application-credential-secret")
 	d.TenantName = flags.String("openstack-tenant-name")
 	d.TenantId = flags.String("openstack-tenant-id")
 	d.Region = flags.String("openstack-region")
@@ -265,8 +364,16 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.FlavorName = flags.String("openstack-flavor-name")
 	d.ImageId = flags.String("openstack-image-id")
 	d.ImageName = flags.String("openstack-image-name")
+	d.ImageFilter = flags.String("image-filter")
+	d.BootFromVolume = flags.Bool("openstack-boot-from-volume")
+	d.VolumeSize = flags.Int("openstack-volume-size")
+	d.VolumeType = flags.String("openstack-volume-type")
+	d.ServerGroup = flags.String("openstack-server-group")
+	d.ServerGroupPolicy = flags.String("openstack-server-group-policy")
 	d.NetworkId = flags.String("openstack-net-id")
 	d.NetworkName = flags.String("openstack-net-name")
+	d.AdditionalNetworks = flags.StringSlice("openstack-additional-networks")
+	d.DisablePortSecurity = flags.Bool("openstack-disable-port-security")
 	if flags.String("openstack-sec-groups") != "" {
 		d.SecurityGroups = strings.Split(flags.String("openstack-sec-groups"), ",")
 	}
@@ -275,6 +382,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.ComputeNetwork = flags.Bool("openstack-nova-network")
 	d.SSHUser = flags.String("openstack-ssh-user")
 	d.SSHPort = flags.Int("openstack-ssh-port")
+	d.SSHProxyJump = flags.String("openstack-ssh-proxy-jump")
 	d.KeyPairName = flags.String("openstack-keypair-name")
 	d.PrivateKeyFile = flags.String("openstack-private-key-file")
 
@@ -289,6 +397,12 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 
 	d.SetSwarmConfigFromFlags(flags)
 
+	if d.Cloud != "" {
+		if err := d.loadCloud(); err != nil {
+			return err
+		}
+	}
+
 	return d.checkConfig()
 }
 
@@ -387,6 +501,11 @@ func (d *Driver) Create() error {
 			return err
 		}
 	}
+	if d.DisablePortSecurity {
+		if err := d.createPort(); err != nil {
+			return err
+		}
+	}
 	if err := d.createMachine(); err != nil {
 		return err
 	}
@@ -446,6 +565,15 @@ func (d *Driver) Remove() error {
 	if err := d.client.DeleteKeyPair(d, d.KeyPairName); err != nil {
 		return err
 	}
+	if d.PortId != "" {
+		log.Debug("deleting port...", map[string]string{"PortId": d.PortId})
+		if err := d.initNetwork(); err != nil {
+			return err
+		}
+		if err := d.client.DeletePort(d); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -458,22 +586,51 @@ const (
 	errorWrongEndpointType       string = "Endpoint type must be 'publicURL', 'adminURL' or 'internalURL'"
 	errorUnknownFlavorName       string = "Unable to find flavor named %s"
 	errorUnknownImageName        string = "Unable to find image named %s"
+	errorImageFilterOwner        string = "The image filter of OpenStack doesn't support owner, the images of the project and the public ones are searched"
 	errorUnknownNetworkName      string = "Unable to find network named %s"
 	errorUnknownTenantName       string = "Unable to find tenant named %s"
+	errorBootFromVolumeOptions   string = "The volume size and type can only be specified with --openstack-boot-from-volume"
+	errorInvalidVolumeSize       string = "Invalid volume size %d"
+	errorWrongServerGroupPolicy  string = "Server group policy %q must be 'affinity', 'anti-affinity', 'soft-affinity' or 'soft-anti-affinity'"
+	errorPortSecurityNovaNetwork string = "Port security can only be disabled with neutron networking"
+	errorPortSecurityGroups      string = "Security groups can't be specified with the port security disabled"
+	errorNetworksNovaNetwork     string = "Additional networks can only be attached with neutron networking"
 )
 
+func isServerGroupPolicy(policy string) bool {
+	for _, p := range serverGroupPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *Driver) checkConfig() error {
 	if d.AuthUrl == "" {
 		return fmt.Errorf(errorMandatoryEnvOrOption, "Authentication URL", "OS_AUTH_URL", "--openstack-auth-url")
 	}
-	if d.Username == "" {
-		return fmt.Errorf(errorMandatoryEnvOrOption, "Username", "OS_USERNAME", "--openstack-username")
-	}
-	if d.Password == "" {
-		return fmt.Errorf(errorMandatoryEnvOrOption, "Password", "OS_PASSWORD", "--openstack-password")
-	}
-	if d.TenantName == "" && d.TenantId == "" {
-		return fmt.Errorf(errorMandatoryTenantNameOrID)
+	if d.ApplicationCredentialSecret != "" {
+		// The application credential is scoped to its project.
+		if d.ApplicationCredentialID != "" && d.ApplicationCredentialName != "" {
+			return fmt.Errorf(errorExclusiveOptions, "Application credential id", "Application credential name")
+		}
+		if d.ApplicationCredentialID == "" && d.ApplicationCredentialName == "" {
+			return fmt.Errorf(errorMandatoryEnvOrOption, "Application credential id or name", "OS_APPLICATION_CREDENTIAL_ID or OS_APPLICATION_CREDENTIAL_NAME", "--openstack-application-credential-id or --openstack-application-credential-name")
+		}
+		if d.ApplicationCredentialName != "" && d.Username == "" {
+			return fmt.Errorf(errorMandatoryEnvOrOption, "Username", "OS_USERNAME", "--openstack-username")
+		}
+	} else {
+		if d.Username == "" {
+			return fmt.Errorf(errorMandatoryEnvOrOption, "Username", "OS_USERNAME", "--openstack-username")
+		}
+		if d.Password == "" {
+			return fmt.Errorf(errorMandatoryEnvOrOption, "Password", "OS_PASSWORD", "--openstack-password")
+		}
+		if d.TenantName == "" && d.TenantId == "" {
+			return fmt.Errorf(errorMandatoryTenantNameOrID)
+		}
 	}
 
 	if d.FlavorName == "" && d.FlavorId == "" {
@@ -483,16 +640,54 @@ func (d *Driver) checkConfig() error {
 		return fmt.Errorf(errorExclusiveOptions, "Flavor name", "Flavor id")
 	}
 
-	if d.ImageName == "" && d.ImageId == "" {
-		return fmt.Errorf(errorMandatoryOption, "Image name or Image id", "--openstack-image-name or --openstack-image-id")
+	if d.ImageFilter != "" {
+		if d.ImageName != "" || d.ImageId != "" {
+			return fmt.Errorf(errorExclusiveOptions, "Image filter", "Image name or id")
+		}
+		filter, err := imagefilter.Parse(d.ImageFilter)
+		if err != nil {
+			return err
+		}
+		if filter.Owner != "" {
+			return fmt.Errorf(errorImageFilterOwner)
+		}
+	} else {
+		if d.ImageName == "" && d.ImageId == "" {
+			return fmt.Errorf(errorMandatoryOption, "Image name or Image id", "--openstack-image-name or --openstack-image-id")
+		}
+		if d.ImageName != "" && d.ImageId != "" {
+			return fmt.Errorf(errorExclusiveOptions, "Image name", "Image id")
+		}
+	}
+
+	if !d.BootFromVolume && (d.VolumeSize != 0 || d.VolumeType != "") {
+		return fmt.Errorf(errorBootFromVolumeOptions)
 	}
-	if d.ImageName != "" && d.ImageId != "" {
-		return fmt.Errorf(errorExclusiveOptions, "Image name", "Image id")
+	if d.VolumeSize < 0 {
+		return fmt.Errorf(errorInvalidVolumeSize, d.VolumeSize)
+	}
+
+	if d.ServerGroup != "" && !isServerGroupPolicy(d.ServerGroupPolicy) {
+		return fmt.Errorf(errorWrongServerGroupPolicy, d.ServerGroupPolicy)
 	}
 
 	if d.NetworkName != "" && d.NetworkId != "" {
 		return fmt.Errorf(errorExclusiveOptions, "Network name", "Network id")
 	}
+	if len(d.AdditionalNetworks) > 0 && d.ComputeNetwork {
+		return fmt.Errorf(errorNetworksNovaNetwork)
+	}
+	if d.DisablePortSecurity {
+		if d.ComputeNetwork {
+			return fmt.Errorf(errorPortSecurityNovaNetwork)
+		}
+		if d.NetworkName == "" && d.NetworkId == "" {
+			return fmt.Errorf(errorMandatoryOption, "Network name or Network id", "--openstack-net-name or --openstack-net-id")
+		}
+		if len(d.SecurityGroups) > 0 {
+			return fmt.Errorf(errorPortSecurityGroups)
+		}
+	}
 	if d.EndpointType != "" && (d.EndpointType != "publicURL" && d.EndpointType != "adminURL" && d.EndpointType != "internalURL") {
 		return fmt.Errorf(errorWrongEndpointType)
 	}
@@ -525,6 +720,26 @@ func (d *Driver) resolveIds() error {
 		})
 	}
 
+	if len(d.AdditionalNetworks) > 0 && !d.ComputeNetwork {
+		if err := d.initNetwork(); err != nil {
+			return err
+		}
+
+		d.AdditionalNetworkIds = []string{}
+		for _, network := range d.AdditionalNetworks {
+			networkID, err := d.client.GetNetworkIDByName(d, network)
+			if err != nil {
+				return err
+			}
+
+			// Networks not found by name are given by id.
+			if networkID == "" {
+				networkID = network
+			}
+			d.AdditionalNetworkIds = append(d.AdditionalNetworkIds, networkID)
+		}
+	}
+
 	if d.FlavorName != "" {
 		if err := d.initCompute(); err != nil {
 			return err
@@ -567,6 +782,28 @@ func (d *Driver) resolveIds() error {
 		})
 	}
 
+	if d.ImageFilter != "" {
+		if err := d.initCompute(); err != nil {
+			return err
+		}
+		filter, err := imagefilter.Parse(d.ImageFilter)
+		if err != nil {
+			return err
+		}
+		images, err := d.client.ListImages(d)
+		if err != nil {
+			return err
+		}
+
+		image, err := filter.Select(images)
+		if err != nil {
+			return err
+		}
+
+		d.ImageId = image.ID
+		log.Infof("Using the image %s matching the filter %q", image.Name, filter)
+	}
+
 	if d.FloatingIpPool != "" && !d.ComputeNetwork {
 		if err := d.initNetwork(); err != nil {
 			return err
@@ -588,7 +825,33 @@ func (d *Driver) resolveIds() error {
 		})
 	}
 
-	if d.TenantName != "" && d.TenantId == "" {
+	if d.ServerGroup != "" {
+		if err := d.initCompute(); err != nil {
+			return err
+		}
+		serverGroupID, err := d.client.GetServerGroupID(d)
+
+		if err != nil {
+			return err
+		}
+
+		if serverGroupID == "" {
+			log.Infof("Creating %s server group %s...", d.ServerGroupPolicy, d.ServerGroup)
+			if serverGroupID, err = d.client.CreateServerGroup(d); err != nil {
+				return err
+			}
+		}
+
+		d.ServerGroupId = serverGroupID
+		log.Debug("Found server group id using its name", map[string]string{
+			"Name": d.ServerGroup,
+			"ID":   d.ServerGroupId,
+		})
+	}
+
+	// The project of application credentials is implied, and can't be listed
+	// through the identity v2 API anyway.
+	if d.TenantName != "" && d.TenantId == "" && d.ApplicationCredentialSecret == "" {
 		if err := d.initIdentity(); err != nil {
 			return err
 		}
@@ -686,6 +949,20 @@ func (d *Driver) createSSHKey() error {
 	return nil
 }
 
+func (d *Driver) createPort() error {
+	log.Debug("Creating port without port security...", map[string]string{"NetworkId": d.NetworkId})
+
+	if err := d.initNetwork(); err != nil {
+		return err
+	}
+	portID, err := d.client.CreatePort(d)
+	if err != nil {
+		return err
+	}
+	d.PortId = portID
+	return nil
+}
+
 func (d *Driver) createMachine() error {
 	log.Debug("Creating OpenStack instance...", map[string]string{
 		"FlavorId": d.FlavorId,
@@ -771,9 +1048,58 @@ func (d *Driver) lookForIPAddress() error {
 		"IP":        ip,
 		"MachineId": d.MachineId,
 	})
+
+	if d.PrivateIPAddress, err = d.privateIP(); err != nil {
+		log.Debugf("No private IP address found: %s", err)
+	}
 	return nil
 }
 
+// GetPrivateIP returns the fixed address of the machine on its network.
+func (d *Driver) GetPrivateIP() (string, error) {
+	if d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+
+	ip, err := d.privateIP()
+	if err != nil {
+		return "", err
+	}
+	d.PrivateIPAddress = ip
+	return ip, nil
+}
+
+// privateIP returns the fixed address of the machine, on its network if it
+// was given by name.
+func (d *Driver) privateIP() (string, error) {
+	if err := d.initCompute(); err != nil {
+		return "", err
+	}
+
+	addresses, err := d.client.GetInstanceIPAddresses(d)
+	if err != nil {
+		return "", err
+	}
+
+	ip := ""
+	for _, a := range addresses {
+		if a.AddressType != Fixed || a.Version != d.IpVersion {
+			continue
+		}
+		if d.NetworkName != "" && a.Network == d.NetworkName {
+			return a.Address, nil
+		}
+		if ip == "" {
+			ip = a.Address
+		}
+	}
+
+	if ip == "" {
+		return "", fmt.Errorf("No fixed IP found for the machine")
+	}
+	return ip, nil
+}
+
 func (d *Driver) privateSSHKeyPath() string {
 	return d.GetSSHKeyPath()
 }
@@ -1,9 +1,13 @@
 package openstack
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/docker/machine/libmachine/drivers"
+	"github.com/rackspace/gophercloud"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,3 +31,218 @@ func TestSetConfigFromFlags(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Empty(t, checkFlags.InvalidFlags)
 }
+
+func TestSetConfigFromFlagsApplicationCredential(t *testing.T) {
+	driver := NewDerivedDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"openstack-auth-url":                      "http://url",
+			"openstack-application-credential-id":     "ID",
+			"openstack-application-credential-secret": "secret",
+			"openstack-flavor-id":                     "ID",
+			"openstack-image-id":                      "ID",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, "ID", driver.ApplicationCredentialID)
+	assert.Equal(t, "secret", driver.ApplicationCredentialSecret)
+}
+
+func TestCheckConfig(t *testing.T) {
+	valid := func() *Driver {
+		d := NewDerivedDriver("default", "path")
+		d.AuthUrl = "http://url"
+		d.Username = "user"
+		d.Password = "pwd"
+		d.TenantId = "ID"
+		d.FlavorId = "ID"
+		d.ImageId = "ID"
+		d.ServerGroupPolicy = defaultServerGroupPolicy
+		return d
+	}
+
+	tests := []struct {
+		description string
+		configure   func(d *Driver)
+		err         string
+	}{
+		{"password", func(d *Driver) {}, ""},
+		{"application credential name", func(d *Driver) {
+			d.Password, d.TenantId = "", ""
+			d.ApplicationCredentialName, d.ApplicationCredentialSecret = "name", "secret"
+		}, ""},
+		{"application credential name without user", func(d *Driver) {
+			d.Username, d.Password, d.TenantId = "", "", ""
+			d.ApplicationCredentialName, d.ApplicationCredentialSecret = "name", "secret"
+		}, "Username must be specified either using the environment variable OS_USERNAME or the CLI option --openstack-username"},
+		{"application credential id and name", func(d *Driver) {
+			d.ApplicationCredentialID, d.ApplicationCredentialName, d.ApplicationCredentialSecret = "ID", "name", "secret"
+		}, "Either Application credential id or Application credential name must be specified, not both"},
+		{"boot from volume", func(d *Driver) {
+			d.BootFromVolume, d.VolumeSize, d.VolumeType = true, 40, "ssd"
+		}, ""},
+		{"volume size without boot from volume", func(d *Driver) {
+			d.VolumeSize = 40
+		}, errorBootFromVolumeOptions},
+		{"negative volume size", func(d *Driver) {
+			d.BootFromVolume, d.VolumeSize = true, -1
+		}, "Invalid volume size -1"},
+		{"server group", func(d *Driver) {
+			d.ServerGroup, d.ServerGroupPolicy = "group", "soft-anti-affinity"
+		}, ""},
+		{"server group policy", func(d *Driver) {
+			d.ServerGroup, d.ServerGroupPolicy = "group", "spread"
+		}, `Server group policy "spread" must be 'affinity', 'anti-affinity', 'soft-affinity' or 'soft-anti-affinity'`},
+		{"port security", func(d *Driver) {
+			d.DisablePortSecurity, d.NetworkName = true, "net"
+		}, ""},
+		{"port security without network", func(d *Driver) {
+			d.DisablePortSecurity = true
+		}, "Network name or Network id must be specified using the CLI option --openstack-net-name or --openstack-net-id"},
+		{"port security with security groups", func(d *Driver) {
+			d.DisablePortSecurity, d.NetworkId, d.SecurityGroups = true, "ID", []string{"default"}
+		}, errorPortSecurityGroups},
+		{"port security with nova network", func(d *Driver) {
+			d.DisablePortSecurity, d.NetworkId, d.ComputeNetwork = true, "ID", true
+		}, errorPortSecurityNovaNetwork},
+		{"additional networks", func(d *Driver) {
+			d.AdditionalNetworks = []string{"backend"}
+		}, ""},
+		{"additional networks with nova network", func(d *Driver) {
+			d.AdditionalNetworks, d.ComputeNetwork = []string{"backend"}, true
+		}, errorNetworksNovaNetwork},
+		{"image filter", func(d *Driver) {
+			d.ImageId, d.ImageFilter = "", "name=ubuntu-22.04-*"
+		}, ""},
+		{"image filter and image id", func(d *Driver) {
+			d.ImageFilter = "name=ubuntu-22.04-*"
+		}, "Either Image filter or Image name or id must be specified, not both"},
+		{"image filter with owner", func(d *Driver) {
+			d.ImageId, d.ImageFilter = "", "name=ubuntu-22.04-*,owner=canonical"
+		}, errorImageFilterOwner},
+	}
+
+	for _, test := range tests {
+		d := valid()
+		test.configure(d)
+
+		err := d.checkConfig()
+
+		if test.err == "" {
+			assert.NoError(t, err, test.description)
+		} else {
+			assert.EqualError(t, err, test.err, test.description)
+		}
+	}
+}
+
+func TestCreateInstanceFromVolume(t *testing.T) {
+	var request map[string]interface{}
+	var microversion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/servers", r.URL.Path)
+		microversion = r.Header.Get("X-OpenStack-Nova-API-Version")
+		json.NewDecoder(r.Body).Decode(&request)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"server": {"id": "server-id"}}`))
+	}))
+	defer server.Close()
+
+	client := &GenericClient{
+		Compute: &gophercloud.ServiceClient{
+			ProviderClient: &gophercloud.ProviderClient{},
+			Endpoint:       server.URL + "/",
+		},
+	}
+
+	d := NewDerivedDriver("default", "path")
+	d.FlavorId = "flavor-id"
+	d.ImageId = "image-id"
+	d.KeyPairName = "key"
+	d.PortId = "port-id"
+	d.BootFromVolume = true
+	d.VolumeSize = 40
+	d.VolumeType = "ssd"
+	d.ServerGroupId = "group-id"
+	d.AdditionalNetworkIds = []string{"backend-id"}
+
+	id, err := client.CreateInstance(d)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "server-id", id)
+	assert.Equal(t, "2.67", microversion)
+	assert.Equal(t, map[string]interface{}{"group": "group-id"}, request["os:scheduler_hints"])
+
+	s := request["server"].(map[string]interface{})
+	assert.Equal(t, "", s["imageRef"])
+	assert.Equal(t, "key", s["key_name"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"port": "port-id"},
+		map[string]interface{}{"uuid": "backend-id"},
+	}, s["networks"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"boot_index":            float64(0),
+		"uuid":                  "image-id",
+		"source_type":           "image",
+		"destination_type":      "volume",
+		"volume_size":           float64(40),
+		"volume_type":           "ssd",
+		"delete_on_termination": true,
+	}}, s["block_device_mapping_v2"])
+}
+
+func TestAuthenticateApplicationCredential(t *testing.T) {
+	var request map[string]interface{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/auth/tokens", r.URL.Path)
+		json.NewDecoder(r.Body).Decode(&request)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Subject-Token", "token")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token": {"catalog": [{"type": "compute", "endpoints": [{"interface": "public", "region": "RegionOne", "url": "` + server.URL + `/compute"}]}]}}`))
+	}))
+	defer server.Close()
+
+	d := NewDerivedDriver("default", "path")
+	d.AuthUrl = server.URL + "/v3"
+	d.Username = "user"
+	d.DomainName = "Default"
+	d.ApplicationCredentialName = "name"
+	d.ApplicationCredentialSecret = "secret"
+	client := &GenericClient{}
+
+	err := client.Authenticate(d)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "token", client.Provider.TokenID)
+	assert.Equal(t, map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []interface{}{"application_credential"},
+				"application_credential": map[string]interface{}{
+					"name":   "name",
+					"secret": "secret",
+					"user": map[string]interface{}{
+						"name":   "user",
+						"domain": map[string]interface{}{"name": "Default"},
+					},
+				},
+			},
+		},
+	}, request)
+
+	err = client.InitComputeClient(d)
+
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/compute/", client.Compute.Endpoint)
+}
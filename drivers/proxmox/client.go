@@ -0,0 +1,323 @@
+package proxmox
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// taskPollInterval is how often the status of the tasks is polled,
+	// shortened by the tests.
+	taskPollInterval = 2 * time.Second
+
+	// taskTimeout bounds the time waited for a task, e.g. the full clone
+	// of a large disk.
+	taskTimeout = 15 * time.Minute
+)
+
+// Client is a minimal client for the Proxmox VE API, covering what the
+// driver needs to manage a VM.  It authenticates with an API token, or with
+// the ticket of a user logged in with a password.
+type Client struct {
+	// Endpoint is the URL of the API, e.g. https://pve:8006/api2/json.
+	Endpoint string
+
+	// TokenID is the ID of an API token, e.g. root@pam!machine, and
+	// TokenSecret its secret.
+	TokenID     string
+	TokenSecret string
+
+	// Username and Password log in when no token is given.
+	Username string
+	Password string
+
+	ticket    string
+	csrfToken string
+	http      *http.Client
+}
+
+type APIError struct {
+	StatusCode int
+	Status     string
+	Errors     map[string]string
+}
+
+func (e *APIError) Error() string {
+	reasons := []string{}
+	for field, reason := range e.Errors {
+		reasons = append(reasons, field+": "+strings.TrimSpace(reason))
+	}
+	sort.Strings(reasons)
+
+	if len(reasons) == 0 {
+		return fmt.Sprintf("proxmox API error (%d): %s", e.StatusCode, e.Status)
+	}
+	return fmt.Sprintf("proxmox API error (%d): %s", e.StatusCode, strings.Join(reasons, ", "))
+}
+
+// IsNotFound returns true if err is an API error for a missing VM.  The API
+// answers 500 with a message for the VMs which don't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || strings.Contains(apiErr.Status, "does not exist")
+}
+
+// Resource is a VM of the cluster, as listed by /cluster/resources.
+type Resource struct {
+	VMID     int    `json:"vmid"`
+	Name     string `json:"name"`
+	Node     string `json:"node"`
+	Template int    `json:"template"`
+}
+
+// VMStatus is the current status of a VM.
+type VMStatus struct {
+	Status    string `json:"status"`
+	QMPStatus string `json:"qmpstatus"`
+}
+
+// TaskStatus is the status of an asynchronous task.
+type TaskStatus struct {
+	Status     string `json:"status"`
+	ExitStatus string `json:"exitstatus"`
+}
+
+// NetworkInterface is a network interface reported by the guest agent.
+type NetworkInterface struct {
+	Name        string `json:"name"`
+	IPAddresses []struct {
+		Type    string `json:"ip-address-type"`
+		Address string `json:"ip-address"`
+	} `json:"ip-addresses"`
+}
+
+func NewClient(endpoint string, insecure bool) *Client {
+	transport := &http.Transport{}
+	if insecure {
+		// Proxmox VE installs a self-signed certificate by default
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Client{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		http:     &http.Client{Transport: transport},
+	}
+}
+
+// login gets a ticket for the user, unless an API token is used.
+func (c *Client) login() error {
+	if c.TokenID != "" || c.ticket != "" {
+		return nil
+	}
+
+	var ticket struct {
+		Ticket              string `json:"ticket"`
+		CSRFPreventionToken string `json:"CSRFPreventionToken"`
+	}
+	if err := c.request("POST", "/access/ticket", url.Values{"username": {c.Username}, "password": {c.Password}}, &ticket); err != nil {
+		return err
+	}
+
+	c.ticket = ticket.Ticket
+	c.csrfToken = ticket.CSRFPreventionToken
+
+	return nil
+}
+
+func (c *Client) do(method, path string, params url.Values, out interface{}) error {
+	if err := c.login(); err != nil {
+		return err
+	}
+
+	return c.request(method, path, params, out)
+}
+
+// request calls the API, with the parameters form encoded, and decodes the
+// data of the response in out.
+func (c *Client) request(method, path string, params url.Values, out interface{}) error {
+	endpoint := c.Endpoint + path
+
+	var body *strings.Reader
+	if method == "GET" || method == "DELETE" {
+		if len(params) > 0 {
+			endpoint += "?" + params.Encode()
+		}
+		body = strings.NewReader("")
+	} else {
+		body = strings.NewReader(params.Encode())
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	switch {
+	case c.TokenID != "":
+		req.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s=%s", c.TokenID, c.TokenSecret))
+	case c.ticket != "":
+		req.AddCookie(&http.Cookie{Name: "PVEAuthCookie", Value: c.ticket})
+		if method != "GET" {
+			req.Header.Set("CSRFPreventionToken", c.csrfToken)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Data   json.RawMessage   `json:"data"`
+		Errors map[string]string `json:"errors"`
+	}
+	json.Unmarshal(respBody, &response)
+
+	if resp.StatusCode >= 300 {
+		// the message of the error is the reason of the status line
+		status := strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode)))
+		return &APIError{StatusCode: resp.StatusCode, Status: status, Errors: response.Errors}
+	}
+
+	if out == nil || len(response.Data) == 0 || string(response.Data) == "null" {
+		return nil
+	}
+
+	return json.Unmarshal(response.Data, out)
+}
+
+// vmPath returns the path of a VM of a node.
+func vmPath(node string, vmid int) string {
+	return fmt.Sprintf("/nodes/%s/qemu/%d", url.PathEscape(node), vmid)
+}
+
+// ListVMs returns the VMs and the templates of the cluster.
+func (c *Client) ListVMs() ([]Resource, error) {
+	resources := []Resource{}
+	if err := c.do("GET", "/cluster/resources", url.Values{"type": {"vm"}}, &resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// NextID returns a free VM ID.
+func (c *Client) NextID() (int, error) {
+	var id json.Number
+	if err := c.do("GET", "/cluster/nextid", nil, &id); err != nil {
+		return 0, err
+	}
+
+	next, err := id.Int64()
+	return int(next), err
+}
+
+// CloneVM clones a template and returns the ID of the task.
+func (c *Client) CloneVM(node string, vmid int, params url.Values) (string, error) {
+	var upid string
+	if err := c.do("POST", vmPath(node, vmid)+"/clone", params, &upid); err != nil {
+		return "", err
+	}
+	return upid, nil
+}
+
+// GetVMConfig returns the configuration of a VM.
+func (c *Client) GetVMConfig(node string, vmid int) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	if err := c.do("GET", vmPath(node, vmid)+"/config", nil, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SetVMConfig updates the configuration of a VM.
+func (c *Client) SetVMConfig(node string, vmid int, params url.Values) error {
+	return c.do("PUT", vmPath(node, vmid)+"/config", params, nil)
+}
+
+// ResizeDisk grows a disk of a VM to a size, e.g. 20G.
+func (c *Client) ResizeDisk(node string, vmid int, disk, size string) error {
+	return c.do("PUT", vmPath(node, vmid)+"/resize", url.Values{"disk": {disk}, "size": {size}}, nil)
+}
+
+// GetVMStatus returns the current status of a VM.
+func (c *Client) GetVMStatus(node string, vmid int) (*VMStatus, error) {
+	status := &VMStatus{}
+	if err := c.do("GET", vmPath(node, vmid)+"/status/current", nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// VMAction runs an action such as start, shutdown, stop or reboot on a VM
+// and returns the ID of its task.
+func (c *Client) VMAction(node string, vmid int, action string) (string, error) {
+	var upid string
+	if err := c.do("POST", vmPath(node, vmid)+"/status/"+action, nil, &upid); err != nil {
+		return "", err
+	}
+	return upid, nil
+}
+
+// DeleteVM deletes a VM and its disks, and returns the ID of the task.
+func (c *Client) DeleteVM(node string, vmid int) (string, error) {
+	var upid string
+	if err := c.do("DELETE", vmPath(node, vmid), url.Values{"purge": {"1"}}, &upid); err != nil {
+		return "", err
+	}
+	return upid, nil
+}
+
+// GetNetworkInterfaces returns the network interfaces reported by the guest
+// agent of a VM.
+func (c *Client) GetNetworkInterfaces(node string, vmid int) ([]NetworkInterface, error) {
+	var result struct {
+		Result []NetworkInterface `json:"result"`
+	}
+	if err := c.do("GET", vmPath(node, vmid)+"/agent/network-get-interfaces", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Result, nil
+}
+
+// WaitForTask waits until a task of a node is done, and returns its error if
+// it failed.
+func (c *Client) WaitForTask(node, upid string) error {
+	deadline := time.Now().Add(taskTimeout)
+
+	for {
+		status := &TaskStatus{}
+		if err := c.do("GET", fmt.Sprintf("/nodes/%s/tasks/%s/status", url.PathEscape(node), url.PathEscape(upid)), nil, status); err != nil {
+			return err
+		}
+
+		if status.Status == "stopped" {
+			if status.ExitStatus != "OK" {
+				return fmt.Errorf("proxmox task %s failed: %s", upid, status.ExitStatus)
+			}
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("proxmox task %s is still running after %s", upid, taskTimeout)
+		}
+
+		time.Sleep(taskPollInterval)
+	}
+}
@@ -0,0 +1,585 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/retry"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	URL              string
+	TokenID          string
+	TokenSecret      string
+	Username         string
+	Password         string
+	Insecure         bool
+	Node             string
+	Template         string
+	VMID             int
+	LinkedClone      bool
+	Storage          string
+	CloudInitStorage string
+	Pool             string
+	Cores            int
+	Memory           int
+	DiskSize         string
+	Bridge           string
+	VLANTag          int
+	IPConfig         string
+	client           *Client
+}
+
+const (
+	defaultSSHPort          = 22
+	defaultSSHUser          = "docker"
+	defaultCores            = 2
+	defaultMemory           = 2048
+	defaultBridge           = "vmbr0"
+	defaultCloudInitStorage = "local-lvm"
+	defaultIPConfig         = "ip=dhcp"
+)
+
+// bootDisks are the disks the templates boot from, in the order they are
+// looked for to be resized.
+var bootDisks = []string{"scsi0", "virtio0", "sata0", "ide0"}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_URL",
+			Name:   "proxmox-url",
+			Usage:  "URL of the Proxmox VE API, e.g. https://pve.example.com:8006/api2/json",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_TOKEN_ID",
+			Name:   "proxmox-token-id",
+			Usage:  "ID of the API token, e.g. root@pam!machine",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_TOKEN_SECRET",
+			Name:   "proxmox-token-secret",
+			Usage:  "Secret of the API token",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_USERNAME",
+			Name:   "proxmox-username",
+			Usage:  "User logging in when no API token is given, e.g. root@pam",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_PASSWORD",
+			Name:   "proxmox-password",
+			Usage:  "Password of the user",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "PROXMOX_INSECURE",
+			Name:   "proxmox-insecure",
+			Usage:  "Don't verify the certificate of the API, self-signed by default",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_NODE",
+			Name:   "proxmox-node",
+			Usage:  "Node to create the VM on (default: the node of the template)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_TEMPLATE",
+			Name:   "proxmox-template",
+			Usage:  "Name or ID of the cloud-init enabled template the VM is cloned from",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "PROXMOX_LINKED_CLONE",
+			Name:   "proxmox-linked-clone",
+			Usage:  "Create a linked clone of the template instead of a full clone",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_STORAGE",
+			Name:   "proxmox-storage",
+			Usage:  "Storage of the disks of a full clone (default: the storage of the template)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_CLOUDINIT_STORAGE",
+			Name:   "proxmox-cloudinit-storage",
+			Usage:  "Storage of the cloud-init drive, added when the template has none",
+			Value:  defaultCloudInitStorage,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_POOL",
+			Name:   "proxmox-pool",
+			Usage:  "Resource pool the VM is added to",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_CPU_CORES",
+			Name:   "proxmox-cpu-cores",
+			Usage:  "Number of CPU cores of the VM",
+			Value:  defaultCores,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_MEMORY",
+			Name:   "proxmox-memory",
+			Usage:  "Memory of the VM in MB",
+			Value:  defaultMemory,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_DISK_SIZE",
+			Name:   "proxmox-disk-size",
+			Usage:  "Size the boot disk is grown to, e.g. 40G (default: the size of the template)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_NETWORK_BRIDGE",
+			Name:   "proxmox-network-bridge",
+			Usage:  "Bridge of the network interface of the VM",
+			Value:  defaultBridge,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_VLAN_TAG",
+			Name:   "proxmox-vlan-tag",
+			Usage:  "VLAN tag of the network interface of the VM",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_IP_CONFIG",
+			Name:   "proxmox-ip-config",
+			Usage:  "cloud-init IP configuration, e.g. ip=192.168.1.50/24,gw=192.168.1.1",
+			Value:  defaultIPConfig,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_SSH_USER",
+			Name:   "proxmox-ssh-user",
+			Usage:  "SSH username, created by cloud-init",
+			Value:  defaultSSHUser,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_SSH_PORT",
+			Name:   "proxmox-ssh-port",
+			Usage:  "SSH port",
+			Value:  defaultSSHPort,
+		},
+	}
+}
+
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		CloudInitStorage: defaultCloudInitStorage,
+		Cores:            defaultCores,
+		Memory:           defaultMemory,
+		Bridge:           defaultBridge,
+		IPConfig:         defaultIPConfig,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "proxmox"
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.URL = flags.String("proxmox-url")
+	d.TokenID = flags.String("proxmox-token-id")
+	d.TokenSecret = flags.String("proxmox-token-secret")
+	d.Username = flags.String("proxmox-username")
+	d.Password = flags.String("proxmox-password")
+	d.Insecure = flags.Bool("proxmox-insecure")
+	d.Node = flags.String("proxmox-node")
+	d.Template = flags.String("proxmox-template")
+	d.LinkedClone = flags.Bool("proxmox-linked-clone")
+	d.Storage = flags.String("proxmox-storage")
+	d.CloudInitStorage = flags.String("proxmox-cloudinit-storage")
+	d.Pool = flags.String("proxmox-pool")
+	d.Cores = flags.Int("proxmox-cpu-cores")
+	d.Memory = flags.Int("proxmox-memory")
+	d.DiskSize = flags.String("proxmox-disk-size")
+	d.Bridge = flags.String("proxmox-network-bridge")
+	d.VLANTag = flags.Int("proxmox-vlan-tag")
+	d.IPConfig = flags.String("proxmox-ip-config")
+	d.SSHUser = flags.String("proxmox-ssh-user")
+	d.SSHPort = flags.Int("proxmox-ssh-port")
+	d.SetSwarmConfigFromFlags(flags)
+
+	if d.URL == "" {
+		return fmt.Errorf("proxmox driver requires the --proxmox-url option")
+	}
+
+	if d.TokenID == "" && d.Username == "" {
+		return fmt.Errorf("proxmox driver requires an API token with --proxmox-token-id and --proxmox-token-secret, or a user with --proxmox-username and --proxmox-password")
+	}
+
+	if d.Template == "" {
+		return fmt.Errorf("proxmox driver requires the --proxmox-template option")
+	}
+
+	if d.LinkedClone && d.Storage != "" {
+		return fmt.Errorf("--proxmox-storage can't be used with --proxmox-linked-clone, the disks of a linked clone stay on the storage of the template")
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	_, err := d.findTemplate()
+	return err
+}
+
+// findTemplate returns the template given by its name or its ID.
+func (d *Driver) findTemplate() (*Resource, error) {
+	vms, err := d.getClient().ListVMs()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, vm := range vms {
+		if vm.Name != d.Template && strconv.Itoa(vm.VMID) != d.Template {
+			continue
+		}
+
+		if vm.Template != 1 {
+			return nil, fmt.Errorf("proxmox VM %q is not a template", d.Template)
+		}
+		return &vms[i], nil
+	}
+
+	return nil, fmt.Errorf("proxmox template %q not found", d.Template)
+}
+
+func (d *Driver) Create() error {
+	return d.CreateContext(context.Background(), func(message string) {
+		log.Info(message)
+	})
+}
+
+// CreateContext creates the VM like Create.  If ctx is canceled before the VM
+// has an IP address, it is removed.
+func (d *Driver) CreateContext(ctx context.Context, progress func(string)) error {
+	progress("Creating SSH key...")
+
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	template, err := d.findTemplate()
+	if err != nil {
+		return err
+	}
+
+	client := d.getClient()
+
+	vmid, err := client.NextID()
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	progress(fmt.Sprintf("Cloning the template %s...", template.Name))
+
+	params := url.Values{
+		"newid": {strconv.Itoa(vmid)},
+		"name":  {d.MachineName},
+		"full":  {"1"},
+	}
+	if d.LinkedClone {
+		params.Set("full", "0")
+	}
+	if d.Node != "" && d.Node != template.Node {
+		params.Set("target", d.Node)
+	}
+	if d.Storage != "" {
+		params.Set("storage", d.Storage)
+	}
+	if d.Pool != "" {
+		params.Set("pool", d.Pool)
+	}
+
+	upid, err := client.CloneVM(template.Node, template.VMID, params)
+	if err != nil {
+		return err
+	}
+
+	d.VMID = vmid
+	if d.Node == "" {
+		d.Node = template.Node
+	}
+
+	if err := client.WaitForTask(template.Node, upid); err != nil {
+		return d.cancelCreate(err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return d.cancelCreate(err)
+	}
+
+	progress("Configuring the VM...")
+
+	if err := d.configureVM(strings.TrimSpace(string(publicKey))); err != nil {
+		return d.cancelCreate(err)
+	}
+
+	progress("Starting the VM...")
+
+	if err := d.Start(); err != nil {
+		return d.cancelCreate(err)
+	}
+
+	progress("Waiting for the IP address of the VM...")
+
+	if err := retry.For(retry.Instance).UntilContext(ctx, d.ipAddressAvailable); err != nil {
+		if ctx.Err() != nil {
+			return d.cancelCreate(err)
+		}
+		return fmt.Errorf("proxmox VM %d has no IP address, is the QEMU guest agent installed in the template? %s", d.VMID, err)
+	}
+
+	log.Debugf("Created VM ID %d on the node %s, IP address %s", d.VMID, d.Node, d.IPAddress)
+
+	return nil
+}
+
+// configureVM sizes the clone, connects it to the network and configures
+// cloud-init to authorize the SSH key.
+func (d *Driver) configureVM(publicKey string) error {
+	client := d.getClient()
+
+	config, err := client.GetVMConfig(d.Node, d.VMID)
+	if err != nil {
+		return err
+	}
+
+	net0 := fmt.Sprintf("virtio,bridge=%s", d.Bridge)
+	if d.VLANTag > 0 {
+		net0 = fmt.Sprintf("%s,tag=%d", net0, d.VLANTag)
+	}
+
+	params := url.Values{
+		"cores":     {strconv.Itoa(d.Cores)},
+		"memory":    {strconv.Itoa(d.Memory)},
+		"net0":      {net0},
+		"agent":     {"1"},
+		"ciuser":    {d.GetSSHUsername()},
+		"sshkeys":   {encodeSSHKeys(publicKey)},
+		"ipconfig0": {d.IPConfig},
+	}
+
+	if !hasCloudInitDrive(config) {
+		log.Debugf("adding a cloud-init drive on %s", d.CloudInitStorage)
+		params.Set("ide2", d.CloudInitStorage+":cloudinit")
+	}
+
+	if err := client.SetVMConfig(d.Node, d.VMID, params); err != nil {
+		return err
+	}
+
+	if d.DiskSize == "" {
+		return nil
+	}
+
+	for _, disk := range bootDisks {
+		if _, ok := config[disk]; ok {
+			log.Debugf("resizing the disk %s to %s", disk, d.DiskSize)
+			return client.ResizeDisk(d.Node, d.VMID, disk, d.DiskSize)
+		}
+	}
+
+	return fmt.Errorf("proxmox VM %d has no disk to resize", d.VMID)
+}
+
+// hasCloudInitDrive returns true if a VM has a cloud-init drive.
+func hasCloudInitDrive(config map[string]interface{}) bool {
+	for _, value := range config {
+		if s, ok := value.(string); ok && strings.Contains(s, "cloudinit") {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeSSHKeys encodes the SSH keys as the API expects them: URL encoded,
+// with the spaces as %20.
+func encodeSSHKeys(keys string) string {
+	return strings.Replace(url.QueryEscape(keys), "+", "%20", -1)
+}
+
+// ipAddressAvailable reads the IP address of the VM, from the IP
+// configuration when it's static, or else from the guest agent.
+func (d *Driver) ipAddressAvailable() (bool, error) {
+	if ip := staticIP(d.IPConfig); ip != "" {
+		d.IPAddress = ip
+		return true, nil
+	}
+
+	interfaces, err := d.getClient().GetNetworkInterfaces(d.Node, d.VMID)
+	if err != nil {
+		// the agent answers once the guest has booted
+		log.Debugf("Error getting the network interfaces of the VM %d: %s", d.VMID, err)
+		return false, nil
+	}
+
+	for _, iface := range interfaces {
+		if iface.Name == "lo" {
+			continue
+		}
+		for _, address := range iface.IPAddresses {
+			if address.Type == "ipv4" && !strings.HasPrefix(address.Address, "127.") {
+				d.IPAddress = address.Address
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// staticIP returns the address of a static cloud-init IP configuration,
+// e.g. 192.168.1.50 for ip=192.168.1.50/24,gw=192.168.1.1.
+func staticIP(ipConfig string) string {
+	for _, option := range strings.Split(ipConfig, ",") {
+		if !strings.HasPrefix(option, "ip=") {
+			continue
+		}
+
+		ip, _, err := net.ParseCIDR(strings.TrimPrefix(option, "ip="))
+		if err != nil {
+			return ""
+		}
+		return ip.String()
+	}
+
+	return ""
+}
+
+// cancelCreate removes the VM of the failed or canceled creation and returns
+// the error.
+func (d *Driver) cancelCreate(err error) error {
+	log.Info("Creation failed, removing the VM...")
+	if removeErr := d.Remove(); removeErr != nil {
+		log.Warnf("Error removing the VM: %s", removeErr)
+	}
+
+	return err
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	status, err := d.getClient().GetVMStatus(d.Node, d.VMID)
+	if err != nil {
+		return state.Error, err
+	}
+
+	switch status.Status {
+	case "running":
+		if status.QMPStatus == "paused" {
+			return state.Paused, nil
+		}
+		return state.Running, nil
+	case "stopped":
+		return state.Stopped, nil
+	}
+	return state.None, nil
+}
+
+// action runs an action on the VM and waits for its task.
+func (d *Driver) action(action string) error {
+	upid, err := d.getClient().VMAction(d.Node, d.VMID, action)
+	if err != nil {
+		return err
+	}
+
+	return d.getClient().WaitForTask(d.Node, upid)
+}
+
+func (d *Driver) Start() error {
+	return d.action("start")
+}
+
+// Stop shuts the VM down with an ACPI signal, or the guest agent.
+func (d *Driver) Stop() error {
+	return d.action("shutdown")
+}
+
+func (d *Driver) Restart() error {
+	return d.action("reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.action("stop")
+}
+
+func (d *Driver) Remove() error {
+	if d.VMID == 0 {
+		return nil
+	}
+
+	s, err := d.GetState()
+	if IsNotFound(err) {
+		log.Infof("Proxmox VM doesn't exist, assuming it is already deleted")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if s != state.Stopped {
+		if err := d.Kill(); err != nil {
+			return err
+		}
+	}
+
+	upid, err := d.getClient().DeleteVM(d.Node, d.VMID)
+	if err != nil {
+		return err
+	}
+
+	return d.getClient().WaitForTask(d.Node, upid)
+}
+
+func (d *Driver) getClient() *Client {
+	if d.client == nil {
+		d.client = NewClient(d.URL, d.Insecure)
+		d.client.TokenID = d.TokenID
+		d.client.TokenSecret = d.TokenSecret
+		d.client.Username = d.Username
+		d.client.Password = d.Password
+	}
+	return d.client
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
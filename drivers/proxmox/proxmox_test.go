@@ -0,0 +1,315 @@
+package proxmox
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const tasksPath = "/nodes/pve1/tasks/"
+
+// fakeProxmox serves the API of a node pve1 holding the template 9000,
+// recording the requests changing the VMs.
+type fakeProxmox struct {
+	config   string
+	status   string
+	requests []string
+	params   map[string]url.Values
+}
+
+func (f *fakeProxmox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if f.params == nil {
+		f.params = map[string]url.Values{}
+	}
+
+	if r.Method != "GET" {
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+		f.params[r.URL.Path] = r.Form
+	}
+
+	switch {
+	case r.URL.Path == "/cluster/resources":
+		w.Write([]byte(`{"data":[{"vmid":100,"name":"web","node":"pve1","template":0},{"vmid":9000,"name":"ubuntu-2204","node":"pve1","template":1}]}`))
+	case r.URL.Path == "/cluster/nextid":
+		w.Write([]byte(`{"data":"101"}`))
+	case strings.HasPrefix(r.URL.Path, tasksPath):
+		w.Write([]byte(`{"data":{"status":"stopped","exitstatus":"OK"}}`))
+	case r.URL.Path == "/nodes/pve1/qemu/101/config" && r.Method == "GET":
+		w.Write([]byte(`{"data":` + f.config + `}`))
+	case r.URL.Path == "/nodes/pve1/qemu/101/status/current":
+		w.Write([]byte(`{"data":{"status":"` + f.status + `","qmpstatus":"` + f.status + `"}}`))
+	case r.URL.Path == "/nodes/pve1/qemu/101/agent/network-get-interfaces":
+		w.Write([]byte(`{"data":{"result":[{"name":"lo","ip-addresses":[{"ip-address-type":"ipv4","ip-address":"127.0.0.1"}]},{"name":"eth0","ip-addresses":[{"ip-address-type":"ipv6","ip-address":"fe80::1"},{"ip-address-type":"ipv4","ip-address":"192.168.1.42"}]}]}}`))
+	default:
+		w.Write([]byte(`{"data":"UPID:pve1:0001:task:"}`))
+	}
+}
+
+func newTestDriver(t *testing.T, server *httptest.Server) (*Driver, func()) {
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.TokenID = "root@pam!machine"
+	driver.TokenSecret = "SECRET"
+	driver.Template = "ubuntu-2204"
+	driver.SSHUser = defaultSSHUser
+	driver.getClient().Endpoint = server.URL
+
+	return driver, func() {
+		server.Close()
+		os.RemoveAll(storePath)
+	}
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"proxmox-url":          "https://pve:8006/api2/json",
+			"proxmox-token-id":     "root@pam!machine",
+			"proxmox-token-secret": "SECRET",
+			"proxmox-template":     "9000",
+			"proxmox-vlan-tag":     20,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, 20, driver.VLANTag)
+	assert.Equal(t, defaultBridge, driver.Bridge)
+	assert.Equal(t, defaultSSHUser, driver.GetSSHUsername())
+}
+
+func TestSetConfigFromFlagsRequiresCredentials(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"proxmox-url":      "https://pve:8006/api2/json",
+			"proxmox-template": "9000",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestSetConfigFromFlagsStorageOfLinkedClone(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"proxmox-url":          "https://pve:8006/api2/json",
+			"proxmox-username":     "root@pam",
+			"proxmox-password":     "PASSWORD",
+			"proxmox-template":     "9000",
+			"proxmox-linked-clone": true,
+			"proxmox-storage":      "ceph",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestPreCreateCheck(t *testing.T) {
+	driver, cleanup := newTestDriver(t, httptest.NewServer(&fakeProxmox{}))
+	defer cleanup()
+
+	assert.NoError(t, driver.PreCreateCheck())
+
+	driver.Template = "web"
+	assert.EqualError(t, driver.PreCreateCheck(), `proxmox VM "web" is not a template`)
+
+	driver.Template = "debian"
+	assert.EqualError(t, driver.PreCreateCheck(), `proxmox template "debian" not found`)
+}
+
+func TestCreate(t *testing.T) {
+	fake := &fakeProxmox{config: `{"scsi0":"local-lvm:base-9000-disk-0,size=2G"}`, status: "running"}
+	driver, cleanup := newTestDriver(t, httptest.NewServer(fake))
+	defer cleanup()
+
+	driver.Storage = "ceph"
+	driver.DiskSize = "40G"
+	driver.VLANTag = 20
+	driver.IPConfig = "ip=192.168.20.50/24,gw=192.168.20.1"
+
+	assert.NoError(t, driver.Create())
+	assert.Equal(t, 101, driver.VMID)
+	assert.Equal(t, "pve1", driver.Node)
+	assert.Equal(t, "192.168.20.50", driver.IPAddress)
+
+	assert.Equal(t, []string{
+		"POST /nodes/pve1/qemu/9000/clone",
+		"PUT /nodes/pve1/qemu/101/config",
+		"PUT /nodes/pve1/qemu/101/resize",
+		"POST /nodes/pve1/qemu/101/status/start",
+	}, fake.requests)
+
+	clone := fake.params["/nodes/pve1/qemu/9000/clone"]
+	assert.Equal(t, "101", clone.Get("newid"))
+	assert.Equal(t, "default", clone.Get("name"))
+	assert.Equal(t, "1", clone.Get("full"))
+	assert.Equal(t, "ceph", clone.Get("storage"))
+
+	config := fake.params["/nodes/pve1/qemu/101/config"]
+	assert.Equal(t, "virtio,bridge=vmbr0,tag=20", config.Get("net0"))
+	assert.Equal(t, "local-lvm:cloudinit", config.Get("ide2"))
+	assert.Equal(t, "docker", config.Get("ciuser"))
+	assert.Equal(t, "ip=192.168.20.50/24,gw=192.168.20.1", config.Get("ipconfig0"))
+	assert.True(t, strings.HasPrefix(config.Get("sshkeys"), "ssh-rsa%20"))
+
+	resize := fake.params["/nodes/pve1/qemu/101/resize"]
+	assert.Equal(t, "scsi0", resize.Get("disk"))
+	assert.Equal(t, "40G", resize.Get("size"))
+}
+
+func TestIPAddressFromGuestAgent(t *testing.T) {
+	driver, cleanup := newTestDriver(t, httptest.NewServer(&fakeProxmox{}))
+	defer cleanup()
+
+	driver.Node = "pve1"
+	driver.VMID = 101
+
+	available, err := driver.ipAddressAvailable()
+	assert.NoError(t, err)
+	assert.True(t, available)
+	assert.Equal(t, "192.168.1.42", driver.IPAddress)
+}
+
+func TestHasCloudInitDrive(t *testing.T) {
+	assert.True(t, hasCloudInitDrive(map[string]interface{}{"ide2": "local-lvm:vm-101-cloudinit,media=cdrom", "cores": float64(2)}))
+	assert.False(t, hasCloudInitDrive(map[string]interface{}{"ide2": "none,media=cdrom"}))
+}
+
+func TestStaticIP(t *testing.T) {
+	assert.Equal(t, "192.168.1.50", staticIP("ip=192.168.1.50/24,gw=192.168.1.1"))
+	assert.Equal(t, "", staticIP("ip=dhcp"))
+	assert.Equal(t, "", staticIP("ip6=auto"))
+}
+
+func TestGetState(t *testing.T) {
+	fake := &fakeProxmox{status: "running"}
+	driver, cleanup := newTestDriver(t, httptest.NewServer(fake))
+	defer cleanup()
+
+	driver.Node = "pve1"
+	driver.VMID = 101
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+
+	fake.status = "stopped"
+	s, err = driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+}
+
+func TestRemove(t *testing.T) {
+	fake := &fakeProxmox{status: "running"}
+	driver, cleanup := newTestDriver(t, httptest.NewServer(fake))
+	defer cleanup()
+
+	driver.Node = "pve1"
+	driver.VMID = 101
+
+	assert.NoError(t, driver.Remove())
+	assert.Equal(t, []string{
+		"POST /nodes/pve1/qemu/101/status/stop",
+		"DELETE /nodes/pve1/qemu/101",
+	}, fake.requests)
+	assert.Equal(t, "1", fake.params["/nodes/pve1/qemu/101"].Get("purge"))
+}
+
+func TestRemoveIgnoresMissingVM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		// the API gives the reason in the status line
+		conn, _, err := w.(http.Hijacker).Hijack()
+		assert.NoError(t, err)
+		defer conn.Close()
+		conn.Write([]byte("HTTP/1.1 500 Configuration file 'nodes/pve1/qemu-server/101.conf' does not exist\r\nContent-Length: 13\r\n\r\n{\"data\":null}"))
+	}))
+	driver, cleanup := newTestDriver(t, server)
+	defer cleanup()
+
+	driver.Node = "pve1"
+	driver.VMID = 101
+
+	assert.NoError(t, driver.Remove())
+}
+
+func TestCreateContextCanceledRemovesVM(t *testing.T) {
+	fake := &fakeProxmox{status: "stopped"}
+	driver, cleanup := newTestDriver(t, httptest.NewServer(fake))
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := driver.CreateContext(ctx, func(message string) {
+		if strings.HasPrefix(message, "Cloning") {
+			cancel()
+		}
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{
+		"POST /nodes/pve1/qemu/9000/clone",
+		"DELETE /nodes/pve1/qemu/101",
+	}, fake.requests)
+}
+
+func TestLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/access/ticket" {
+			r.ParseForm()
+			assert.Equal(t, "root@pam", r.Form.Get("username"))
+			w.Write([]byte(`{"data":{"ticket":"PVE:root@pam:TICKET","CSRFPreventionToken":"CSRF"}}`))
+			return
+		}
+
+		cookie, err := r.Cookie("PVEAuthCookie")
+		assert.NoError(t, err)
+		assert.Equal(t, "PVE:root@pam:TICKET", cookie.Value)
+		assert.Equal(t, "CSRF", r.Header.Get("CSRFPreventionToken"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, false)
+	client.Username = "root@pam"
+	client.Password = "PASSWORD"
+
+	assert.NoError(t, client.SetVMConfig("pve1", 101, url.Values{"cores": {"2"}}))
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"data":null,"errors":{"memory":"value must have a minimum value of 16"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, false)
+	client.TokenID = "root@pam!machine"
+
+	err := client.SetVMConfig("pve1", 101, url.Values{"memory": {"1"}})
+	assert.EqualError(t, err, "proxmox API error (400): memory: value must have a minimum value of 16")
+}
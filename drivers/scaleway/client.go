@@ -0,0 +1,230 @@
+package scaleway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const (
+	defaultAPIEndpoint = "https://api.scaleway.com"
+)
+
+// Client is a minimal client for the Scaleway Instance API, covering what
+// the driver needs to manage a server in a zone.
+type Client struct {
+	SecretKey string
+	Zone      string
+	Endpoint  string
+	http      *http.Client
+}
+
+type APIError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("scaleway API error (%d %s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+type Server struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	State     string            `json:"state"`
+	PublicIP  *ServerIP         `json:"public_ip"`
+	PublicIPs []ServerIP        `json:"public_ips"`
+	IPv6      *ServerIP         `json:"ipv6"`
+	PrivateIP string            `json:"private_ip"`
+	Volumes   map[string]Volume `json:"volumes"`
+}
+
+type ServerIP struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Family  string `json:"family"`
+}
+
+type Volume struct {
+	ID         string `json:"id,omitempty"`
+	Size       uint64 `json:"size,omitempty"`
+	VolumeType string `json:"volume_type,omitempty"`
+}
+
+type ServerCreateRequest struct {
+	Name              string            `json:"name"`
+	Project           string            `json:"project"`
+	CommercialType    string            `json:"commercial_type"`
+	Image             string            `json:"image"`
+	DynamicIPRequired bool              `json:"dynamic_ip_required"`
+	EnableIPv6        bool              `json:"enable_ipv6"`
+	BootType          string            `json:"boot_type,omitempty"`
+	Bootscript        string            `json:"bootscript,omitempty"`
+	Volumes           map[string]Volume `json:"volumes,omitempty"`
+	Tags              []string          `json:"tags,omitempty"`
+}
+
+// LocalImage is an image of the marketplace available in a zone.
+type LocalImage struct {
+	ID                        string   `json:"id"`
+	CompatibleCommercialTypes []string `json:"compatible_commercial_types"`
+}
+
+func NewClient(secretKey, zone string) *Client {
+	return &Client{
+		SecretKey: secretKey,
+		Zone:      zone,
+		Endpoint:  defaultAPIEndpoint,
+		http:      &http.Client{},
+	}
+}
+
+func (c *Client) do(method, path, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, c.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", c.SecretKey)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.Unmarshal(respBody, apiErr)
+		return apiErr
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+func (c *Client) doJSON(method, path string, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	return c.do(method, path, "application/json", reqBody, out)
+}
+
+// instancePath returns the path of a resource of the Instance API in the zone
+// of the client.
+func (c *Client) instancePath(format string, args ...interface{}) string {
+	return fmt.Sprintf("/instance/v1/zones/%s", c.Zone) + fmt.Sprintf(format, args...)
+}
+
+// IsNotFound returns true if err is an API error for a missing resource.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) CreateServer(createRequest *ServerCreateRequest) (*Server, error) {
+	var resp struct {
+		Server *Server `json:"server"`
+	}
+	if err := c.doJSON("POST", c.instancePath("/servers"), createRequest, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Server, nil
+}
+
+func (c *Client) GetServer(id string) (*Server, error) {
+	var resp struct {
+		Server *Server `json:"server"`
+	}
+	if err := c.doJSON("GET", c.instancePath("/servers/%s", id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Server, nil
+}
+
+func (c *Client) DeleteServer(id string) error {
+	return c.doJSON("DELETE", c.instancePath("/servers/%s", id), nil, nil)
+}
+
+func (c *Client) DeleteVolume(id string) error {
+	return c.doJSON("DELETE", c.instancePath("/volumes/%s", id), nil, nil)
+}
+
+// ServerAction runs an action such as poweron, poweroff, reboot or terminate
+// on a server.
+func (c *Client) ServerAction(id, action string) error {
+	body := map[string]string{"action": action}
+	return c.doJSON("POST", c.instancePath("/servers/%s/action", id), body, nil)
+}
+
+// SetCloudInit sets the cloud-init user data of a server, read on its first
+// boot.
+func (c *Client) SetCloudInit(id string, userData []byte) error {
+	return c.do("PATCH", c.instancePath("/servers/%s/user_data/cloud-init", id), "text/plain", userData, nil)
+}
+
+// GetImageID resolves an image given by its marketplace label, e.g.
+// "ubuntu_jammy", to the ID of the image compatible with the commercial type
+// in the zone of the client.  An ID is returned as is.
+func (c *Client) GetImageID(image, commercialType string) (string, error) {
+	if isUUID(image) {
+		return image, nil
+	}
+
+	var resp struct {
+		LocalImages []LocalImage `json:"local_images"`
+	}
+	path := fmt.Sprintf("/marketplace/v2/local-images?image_label=%s&zone=%s", url.QueryEscape(image), url.QueryEscape(c.Zone))
+	if err := c.doJSON("GET", path, nil, &resp); err != nil {
+		return "", err
+	}
+
+	for _, localImage := range resp.LocalImages {
+		for _, compatibleType := range localImage.CompatibleCommercialTypes {
+			if compatibleType == commercialType {
+				return localImage.ID, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("scaleway image %q could not be found for %s in %s", image, commercialType, c.Zone)
+}
+
+// isUUID returns true if s has the form of the IDs of the Scaleway resources.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
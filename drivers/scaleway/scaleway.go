@@ -0,0 +1,428 @@
+package scaleway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	SecretKey      string
+	ProjectID      string
+	Zone           string
+	CommercialType string
+	Image          string
+	ImageID        string
+	Bootscript     string
+	UserDataFile   string
+	IPv6           bool
+	IPv6Only       bool
+	VolumeSize     int
+	VolumeType     string
+	Tags           []string
+	ServerID       string
+	client         *Client
+}
+
+const (
+	defaultSSHPort        = 22
+	defaultSSHUser        = "root"
+	defaultImage          = "ubuntu_jammy"
+	defaultCommercialType = "DEV1-S"
+	defaultZone           = "fr-par-1"
+)
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "SCW_SECRET_KEY",
+			Name:   "scaleway-secret-key",
+			Usage:  "Scaleway API secret key",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCW_DEFAULT_PROJECT_ID",
+			Name:   "scaleway-project-id",
+			Usage:  "Scaleway project ID",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCW_DEFAULT_ZONE",
+			Name:   "scaleway-zone",
+			Usage:  "Scaleway zone",
+			Value:  defaultZone,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_COMMERCIAL_TYPE",
+			Name:   "scaleway-commercial-type",
+			Usage:  "Scaleway instance type",
+			Value:  defaultCommercialType,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_IMAGE",
+			Name:   "scaleway-image",
+			Usage:  "Scaleway image label or ID",
+			Value:  defaultImage,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_BOOTSCRIPT",
+			Name:   "scaleway-bootscript",
+			Usage:  "Scaleway bootscript ID to boot the instance with",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_USER_DATA",
+			Name:   "scaleway-user-data",
+			Usage:  "path to file with cloud-init user-data",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "SCALEWAY_IPV6",
+			Name:   "scaleway-ipv6",
+			Usage:  "Enable IPv6 on the instance",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "SCALEWAY_IPV6_ONLY",
+			Name:   "scaleway-ipv6-only",
+			Usage:  "Create the instance without an IPv4 address, and communicate with it over IPv6",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "SCALEWAY_VOLUME_SIZE",
+			Name:   "scaleway-volume-size",
+			Usage:  "Size of the root volume in GB (default: the size of the image)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_VOLUME_TYPE",
+			Name:   "scaleway-volume-type",
+			Usage:  "Type of the root volume, e.g. l_ssd or sbs_volume",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "SCALEWAY_TAGS",
+			Name:   "scaleway-tags",
+			Usage:  "Tags of the instance",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_SSH_USER",
+			Name:   "scaleway-ssh-user",
+			Usage:  "SSH username",
+			Value:  defaultSSHUser,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "SCALEWAY_SSH_PORT",
+			Name:   "scaleway-ssh-port",
+			Usage:  "SSH port",
+			Value:  defaultSSHPort,
+		},
+	}
+}
+
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Image:          defaultImage,
+		CommercialType: defaultCommercialType,
+		Zone:           defaultZone,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "scaleway"
+}
+
+// Capabilities returns the features of the driver, including IPv6 and user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.IPv6 = true
+	capabilities.UserData = true
+	return capabilities
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.SecretKey = flags.String("scaleway-secret-key")
+	d.ProjectID = flags.String("scaleway-project-id")
+	d.Zone = flags.String("scaleway-zone")
+	d.CommercialType = flags.String("scaleway-commercial-type")
+	d.Image = flags.String("scaleway-image")
+	d.Bootscript = flags.String("scaleway-bootscript")
+	d.UserDataFile = flags.String("scaleway-user-data")
+	d.IPv6Only = flags.Bool("scaleway-ipv6-only")
+	d.IPv6 = flags.Bool("scaleway-ipv6") || d.IPv6Only
+	d.VolumeSize = flags.Int("scaleway-volume-size")
+	d.VolumeType = flags.String("scaleway-volume-type")
+	d.Tags = flags.StringSlice("scaleway-tags")
+	d.SSHUser = flags.String("scaleway-ssh-user")
+	d.SSHPort = flags.Int("scaleway-ssh-port")
+	d.SetSwarmConfigFromFlags(flags)
+	d.SetIPFamilyFromFlags(flags)
+	d.IPv6 = d.IPv6 || d.UseIPv6
+
+	if d.SecretKey == "" {
+		return fmt.Errorf("scaleway driver requires the --scaleway-secret-key option")
+	}
+
+	if d.ProjectID == "" {
+		return fmt.Errorf("scaleway driver requires the --scaleway-project-id option")
+	}
+
+	if d.VolumeSize < 0 {
+		return fmt.Errorf("scaleway volume size must be positive")
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
+	imageID, err := d.getClient().GetImageID(d.Image, d.CommercialType)
+	if err != nil {
+		return err
+	}
+	d.ImageID = imageID
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	var userdata []byte
+	if d.UserDataFile != "" {
+		buf, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		userdata = buf
+	}
+
+	log.Infof("Creating SSH key...")
+
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating Scaleway instance...")
+
+	client := d.getClient()
+
+	createRequest := &ServerCreateRequest{
+		Name:              d.MachineName,
+		Project:           d.ProjectID,
+		CommercialType:    d.CommercialType,
+		Image:             d.ImageID,
+		DynamicIPRequired: !d.IPv6Only,
+		EnableIPv6:        d.IPv6,
+		Tags:              append(d.Tags, authorizedKeyTag(publicKey)),
+	}
+
+	if d.Bootscript != "" {
+		createRequest.BootType = "bootscript"
+		createRequest.Bootscript = d.Bootscript
+	}
+
+	if d.VolumeSize > 0 || d.VolumeType != "" {
+		createRequest.Volumes = map[string]Volume{
+			"0": {
+				Size:       uint64(d.VolumeSize) * 1000 * 1000 * 1000,
+				VolumeType: d.VolumeType,
+			},
+		}
+	}
+
+	server, err := client.CreateServer(createRequest)
+	if err != nil {
+		return err
+	}
+
+	d.ServerID = server.ID
+
+	if userdata != nil {
+		log.Debugf("Setting the cloud-init user data of instance %s", d.ServerID)
+		if err := client.SetCloudInit(d.ServerID, userdata); err != nil {
+			return err
+		}
+	}
+
+	// The instances are created stopped
+	if err := client.ServerAction(d.ServerID, "poweron"); err != nil {
+		return err
+	}
+
+	log.Info("Waiting for IP address to be assigned to the instance...")
+	if err := mcnutils.WaitForSpecific(d.serverHasAddress, 60, 2*time.Second); err != nil {
+		return fmt.Errorf("scaleway instance %s has no IP address: %s", d.ServerID, err)
+	}
+
+	log.Debugf("Created instance ID %s, IP address %s", d.ServerID, d.IPAddress)
+
+	return nil
+}
+
+// authorizedKeyTag returns the tag authorizing an SSH key on the instance,
+// whose spaces are replaced by underscores.
+func authorizedKeyTag(publicKey []byte) string {
+	return "AUTHORIZED_KEY=" + strings.Replace(strings.TrimSpace(string(publicKey)), " ", "_", -1)
+}
+
+// serverHasAddress refreshes the address of the instance and reports whether
+// it's known yet.
+func (d *Driver) serverHasAddress() bool {
+	server, err := d.getClient().GetServer(d.ServerID)
+	if err != nil {
+		log.Debugf("Error getting instance %s: %s", d.ServerID, err)
+		return false
+	}
+
+	ipv4 := ""
+	if !d.IPv6Only {
+		ipv4 = serverAddress(server, false)
+	}
+	ipv6 := serverAddress(server, true)
+	d.IPAddress = d.PreferredIP(ipv4, ipv6)
+
+	if d.UseIPv6 || d.IPv6Only {
+		return ipv6 != ""
+	}
+	return ipv4 != ""
+}
+
+// serverAddress returns the public IPv4 address of an instance, or its IPv6
+// one for an IPv6-only instance.
+func serverAddress(server *Server, ipv6 bool) string {
+	family := "inet"
+	if ipv6 {
+		family = "inet6"
+	}
+
+	for _, ip := range server.PublicIPs {
+		if ip.Family == family && ip.Address != "" {
+			return ip.Address
+		}
+	}
+
+	if ipv6 && server.IPv6 != nil {
+		return server.IPv6.Address
+	}
+
+	if !ipv6 && server.PublicIP != nil {
+		return server.PublicIP.Address
+	}
+
+	return ""
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	server, err := d.getClient().GetServer(d.ServerID)
+	if err != nil {
+		return state.Error, err
+	}
+	switch server.State {
+	case "starting":
+		return state.Starting, nil
+	case "running":
+		return state.Running, nil
+	case "stopping":
+		return state.Stopping, nil
+	case "stopped", "stopped in place":
+		return state.Stopped, nil
+	case "locked":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) Start() error {
+	return d.getClient().ServerAction(d.ServerID, "poweron")
+}
+
+func (d *Driver) Stop() error {
+	return d.getClient().ServerAction(d.ServerID, "poweroff")
+}
+
+func (d *Driver) Restart() error {
+	return d.getClient().ServerAction(d.ServerID, "reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.getClient().ServerAction(d.ServerID, "poweroff")
+}
+
+// Remove terminates a running instance, which deletes its volumes and
+// releases its IP address.  A stopped instance can't be terminated: it's
+// deleted, then its volumes.
+func (d *Driver) Remove() error {
+	client := d.getClient()
+
+	server, err := client.GetServer(d.ServerID)
+	if err != nil {
+		if IsNotFound(err) {
+			log.Infof("Scaleway instance doesn't exist, assuming it is already deleted")
+			return nil
+		}
+		return err
+	}
+
+	if server.State == "running" {
+		return client.ServerAction(d.ServerID, "terminate")
+	}
+
+	if err := client.DeleteServer(d.ServerID); err != nil {
+		return err
+	}
+
+	for _, volume := range server.Volumes {
+		if err := client.DeleteVolume(volume.ID); err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) getClient() *Client {
+	if d.client == nil {
+		d.client = NewClient(d.SecretKey, d.Zone)
+	}
+	return d.client
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
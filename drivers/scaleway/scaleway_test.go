@@ -0,0 +1,164 @@
+package scaleway
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"scaleway-secret-key":  "SECRET",
+			"scaleway-project-id":  "PROJECT",
+			"scaleway-ipv6-only":   true,
+			"scaleway-volume-size": 40,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.True(t, driver.IPv6)
+	assert.Equal(t, 40, driver.VolumeSize)
+	assert.Equal(t, defaultCommercialType, driver.CommercialType)
+	assert.Equal(t, defaultZone, driver.Zone)
+	assert.Equal(t, driver.ResolveStorePath("id_rsa"), driver.GetSSHKeyPath())
+}
+
+func TestSetConfigFromFlagsRequiresCredentials(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"scaleway-secret-key": "SECRET",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestGetImageID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "SECRET", r.Header.Get("X-Auth-Token"))
+		assert.Equal(t, "/marketplace/v2/local-images", r.URL.Path)
+		assert.Equal(t, "ubuntu_jammy", r.URL.Query().Get("image_label"))
+		assert.Equal(t, "nl-ams-1", r.URL.Query().Get("zone"))
+		w.Write([]byte(`{"local_images":[{"id":"arm","compatible_commercial_types":["AMP2-C1"]},{"id":"x86","compatible_commercial_types":["DEV1-S","DEV1-M"]}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("SECRET", "nl-ams-1")
+	client.Endpoint = server.URL
+
+	id, err := client.GetImageID("ubuntu_jammy", "DEV1-M")
+	assert.NoError(t, err)
+	assert.Equal(t, "x86", id)
+
+	id, err = client.GetImageID("a3e7fbf1-4b3c-4a36-9c1b-0c3d6e8e2b7f", "DEV1-M")
+	assert.NoError(t, err)
+	assert.Equal(t, "a3e7fbf1-4b3c-4a36-9c1b-0c3d6e8e2b7f", id)
+
+	_, err = client.GetImageID("ubuntu_jammy", "GPU-3070-S")
+	assert.Error(t, err)
+}
+
+func TestCreateServerRequest(t *testing.T) {
+	var createRequest map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/instance/v1/zones/fr-par-2/servers", r.URL.Path)
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &createRequest)
+		w.Write([]byte(`{"server":{"id":"42","state":"stopped"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("SECRET", "fr-par-2")
+	client.Endpoint = server.URL
+
+	s, err := client.CreateServer(&ServerCreateRequest{
+		Name:           "default",
+		CommercialType: "DEV1-S",
+		Volumes:        map[string]Volume{"0": {Size: 40000000000, VolumeType: "l_ssd"}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "42", s.ID)
+	assert.Equal(t, false, createRequest["dynamic_ip_required"])
+	assert.Equal(t, map[string]interface{}{"0": map[string]interface{}{"size": 4e10, "volume_type": "l_ssd"}}, createRequest["volumes"])
+}
+
+func TestGetStateAndIPv6Address(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/instance/v1/zones/fr-par-1/servers/42", r.URL.Path)
+		w.Write([]byte(`{"server":{"id":"42","state":"running","public_ip":null,"public_ips":[{"id":"1","address":"2001:bc8::1","family":"inet6"}]}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.ServerID = "42"
+	driver.IPv6Only = true
+	driver.getClient().Endpoint = server.URL
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+
+	assert.True(t, driver.serverHasAddress())
+	url, err := driver.GetURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://[2001:bc8::1]:2376", url)
+}
+
+func TestRemoveStoppedServer(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == "GET" {
+			w.Write([]byte(`{"server":{"id":"42","state":"stopped","volumes":{"0":{"id":"vol"}}}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.ServerID = "42"
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Remove())
+	assert.Equal(t, []string{
+		"GET /instance/v1/zones/fr-par-1/servers/42",
+		"DELETE /instance/v1/zones/fr-par-1/servers/42",
+		"DELETE /instance/v1/zones/fr-par-1/volumes/vol",
+	}, requests)
+}
+
+func TestRemoveIgnoresMissingServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"unknown_resource","message":"\"instance_server\" not found"}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.ServerID = "42"
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Remove())
+}
+
+func TestAuthorizedKeyTag(t *testing.T) {
+	assert.Equal(t, "AUTHORIZED_KEY=ssh-rsa_AAAAB3_machine", authorizedKeyTag([]byte("ssh-rsa AAAAB3 machine\n")))
+}
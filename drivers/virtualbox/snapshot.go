@@ -0,0 +1,104 @@
+package virtualbox
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+)
+
+var (
+	reSnapshotKey = regexp.MustCompile(`^Snapshot(Name|UUID)((-\d+)*)$`)
+	reNoSnapshots = regexp.MustCompile(`does not have any snapshots`)
+)
+
+func (d *Driver) CreateSnapshot(name string) (*drivers.Snapshot, error) {
+	if err := d.vbm("snapshot", d.MachineName, "take", name); err != nil {
+		return nil, err
+	}
+
+	snapshots, err := d.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	return drivers.FindSnapshot(snapshots, name)
+}
+
+// ListSnapshots returns the snapshots of the VM.  VirtualBox organizes them
+// as a tree, which is flattened in the order VBoxManage lists it.
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	stdout, stderr, err := d.vbmOutErr("snapshot", d.MachineName, "list", "--machinereadable")
+	if err != nil {
+		if reNoSnapshots.MatchString(stdout) || reNoSnapshots.MatchString(stderr) {
+			return []drivers.Snapshot{}, nil
+		}
+		return nil, err
+	}
+
+	snapshots := []drivers.Snapshot{}
+	indexes := map[string]int{}
+
+	err = parseKeyValues(stdout, reEqualLine, func(key, val string) error {
+		groups := reSnapshotKey.FindStringSubmatch(key)
+		if groups == nil {
+			return nil
+		}
+
+		node := groups[2]
+		i, ok := indexes[node]
+		if !ok {
+			i = len(snapshots)
+			indexes[node] = i
+			snapshots = append(snapshots, drivers.Snapshot{})
+		}
+
+		val = strings.Trim(val, `"`)
+		if groups[1] == "Name" {
+			snapshots[i].Name = val
+		} else {
+			snapshots[i].ID = val
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// RestoreSnapshot reverts the VM to a snapshot.  VirtualBox only restores
+// the snapshots of VMs which are not running, so a running VM is powered off
+// and started again.
+func (d *Driver) RestoreSnapshot(name string) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	running := s == state.Running || s == state.Paused
+	if running {
+		log.Infof("Powering off %q to restore the snapshot...", d.MachineName)
+		if err := d.Kill(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.vbm("snapshot", d.MachineName, "restore", name); err != nil {
+		return err
+	}
+
+	if running {
+		return d.Start()
+	}
+
+	return nil
+}
+
+func (d *Driver) DeleteSnapshot(name string) error {
+	return d.vbm("snapshot", d.MachineName, "delete", name)
+}
@@ -0,0 +1,68 @@
+package virtualbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSnapshots(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm snapshot default list --machinereadable", `SnapshotName="clean"
+SnapshotUUID="1d9a4c5e-0000-0000-0000-000000000001"
+SnapshotName-1="configured"
+SnapshotUUID-1="1d9a4c5e-0000-0000-0000-000000000002"
+CurrentSnapshotName="configured"
+CurrentSnapshotUUID="1d9a4c5e-0000-0000-0000-000000000002"
+CurrentSnapshotNode="SnapshotName-1"`, nil},
+	})
+
+	snapshots, err := driver.ListSnapshots()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{
+		{ID: "1d9a4c5e-0000-0000-0000-000000000001", Name: "clean"},
+		{ID: "1d9a4c5e-0000-0000-0000-000000000002", Name: "configured"},
+	}, snapshots)
+}
+
+func TestListNoSnapshots(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm snapshot default list --machinereadable", `This machine does not have any snapshots`, errors.New("exit status 1")},
+	})
+
+	snapshots, err := driver.ListSnapshots()
+
+	assert.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func TestRestoreSnapshotOfRunningVM(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="running"`, nil},
+		{"vbm controlvm default poweroff", "", nil},
+		{"vbm snapshot default restore clean", "", nil},
+		{"IGNORE CALL", "", errors.New("start failed")},
+	})
+
+	err := driver.RestoreSnapshot("clean")
+
+	assert.EqualError(t, err, "start failed")
+}
+
+func TestRestoreSnapshotOfStoppedVM(t *testing.T) {
+	driver := newTestDriver("default")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+		{"vbm snapshot default restore clean", "", nil},
+	})
+
+	err := driver.RestoreSnapshot("clean")
+
+	assert.NoError(t, err)
+}
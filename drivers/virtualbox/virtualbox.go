@@ -13,10 +13,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/docker/machine/libmachine/autosize"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/osimage"
 	"github.com/docker/machine/libmachine/state"
 )
 
@@ -59,6 +61,7 @@ type Driver struct {
 	NatNicType          string
 	Boot2DockerURL      string
 	Boot2DockerImportVM string
+	OSImage             string
 	HostDNSResolver     bool
 	HostOnlyCIDR        string
 	HostOnlyNicType     string
@@ -132,6 +135,12 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  defaultBoot2DockerImportVM,
 			EnvVar: "VIRTUALBOX_BOOT2DOCKER_IMPORT_VM",
 		},
+		mcnflag.StringFlag{
+			Name:   "virtualbox-os-image",
+			Usage:  "The OS image the machine boots from: boot2docker, alpine or fcos",
+			Value:  osimage.Boot2Docker,
+			EnvVar: "VIRTUALBOX_OS_IMAGE",
+		},
 		mcnflag.BoolFlag{
 			Name:   "virtualbox-host-dns-resolver",
 			Usage:  "Use the host DNS resolver",
@@ -210,7 +219,7 @@ func (d *Driver) GetURL() (string, error) {
 	if ip == "" {
 		return "", nil
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
 }
 
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
@@ -224,6 +233,16 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SetSwarmConfigFromFlags(flags)
 	d.SSHUser = "docker"
 	d.Boot2DockerImportVM = flags.String("virtualbox-import-boot2docker-vm")
+	d.OSImage = flags.String("virtualbox-os-image")
+	if !osimage.IsBoot2Docker(d.OSImage) {
+		if err := osimage.ValidateForDriver(d.DriverName(), d.OSImage); err != nil {
+			return err
+		}
+		if d.Boot2DockerURL != "" || d.Boot2DockerImportVM != "" {
+			return errors.New("--virtualbox-boot2docker-url and --virtualbox-import-boot2docker-vm can only be used with the boot2docker OS image")
+		}
+		d.SSHUser = osimage.SSHUser(d.OSImage)
+	}
 	d.HostDNSResolver = flags.Bool("virtualbox-host-dns-resolver")
 	d.NatNicType = flags.String("virtualbox-nat-nictype")
 	d.HostOnlyCIDR = flags.String("virtualbox-hostonly-cidr")
@@ -234,6 +253,26 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.DNSProxy = !flags.Bool("virtualbox-no-dns-proxy")
 	d.NoVTXCheck = flags.Bool("virtualbox-no-vtx-check")
 
+	if flags.Bool("auto-size") {
+		return d.autoSize()
+	}
+
+	return nil
+}
+
+// autoSize sizes the machine from the resources of the host, keeping the
+// sizes given explicitly.
+func (d *Driver) autoSize() error {
+	host, err := autosize.Detect(d.StorePath)
+	if err != nil {
+		return err
+	}
+
+	defaults := autosize.Sizes{CPU: defaultCPU, Memory: defaultMemory, DiskSize: defaultDiskSize}
+	sizes := autosize.Sizes{CPU: d.CPU, Memory: d.Memory, DiskSize: d.DiskSize}.Fill(defaults, host.Sizes())
+	d.CPU, d.Memory, d.DiskSize = sizes.CPU, sizes.Memory, sizes.DiskSize
+
+	log.Infof("Sizing the machine for the host: %s", sizes)
 	return nil
 }
 
@@ -262,7 +301,11 @@ func (d *Driver) PreCreateCheck() error {
 
 	// Downloading boot2docker to cache should be done here to make sure
 	// that a download failure will not leave a machine half created.
-	if err := d.b2dUpdater.UpdateISOCache(d.StorePath, d.Boot2DockerURL); err != nil {
+	if osimage.IsBoot2Docker(d.OSImage) {
+		if err := d.b2dUpdater.UpdateISOCache(d.StorePath, d.Boot2DockerURL); err != nil {
+			return err
+		}
+	} else if err := osimage.UpdateCache(d.StorePath, d.OSImage); err != nil {
 		return err
 	}
 
@@ -284,7 +327,11 @@ func (d *Driver) Create() error {
 }
 
 func (d *Driver) CreateVM() error {
-	if err := d.b2dUpdater.CopyIsoToMachineDir(d.StorePath, d.MachineName, d.Boot2DockerURL); err != nil {
+	if osimage.IsBoot2Docker(d.OSImage) {
+		if err := d.b2dUpdater.CopyIsoToMachineDir(d.StorePath, d.MachineName, d.Boot2DockerURL); err != nil {
+			return err
+		}
+	} else if err := osimage.CopyToMachineDir(d.StorePath, d.OSImage, d.MachineName); err != nil {
 		return err
 	}
 
@@ -330,6 +377,12 @@ func (d *Driver) CreateVM() error {
 			return err
 		}
 
+		if !osimage.IsBoot2Docker(d.OSImage) {
+			if err := osimage.Personalize(d.OSImage, d.ResolveStorePath("boot2docker.iso"), d.publicSSHKeyPath()); err != nil {
+				return err
+			}
+		}
+
 		log.Debugf("Creating disk image...")
 		if err := d.diskCreator.Create(d.DiskSize, d.publicSSHKeyPath(), d.diskPath()); err != nil {
 			return err
@@ -323,6 +323,26 @@ func TestSetConfigFromFlags(t *testing.T) {
 	assert.Empty(t, checkFlags.InvalidFlags)
 }
 
+func TestSetConfigFromOSImageFlag(t *testing.T) {
+	driver := newTestDriver("default")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"virtualbox-os-image": "alpine",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "alpine", driver.OSImage)
+	assert.Equal(t, "docker", driver.GetSSHUsername())
+
+	checkFlags.FlagsValues["virtualbox-os-image"] = "ubuntu"
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
 type MockCreateOperations struct {
 	test          *testing.T
 	expectedCalls []Call
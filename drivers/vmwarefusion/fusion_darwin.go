@@ -14,16 +14,19 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
 	"errors"
 
+	"github.com/docker/machine/libmachine/autosize"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/osimage"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 	cryptossh "golang.org/x/crypto/ssh"
@@ -44,6 +47,7 @@ type Driver struct {
 	CPU            int
 	ISO            string
 	Boot2DockerURL string
+	OSImage        string
 
 	SSHPassword    string
 	ConfigDriveISO string
@@ -69,6 +73,12 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Fusion URL for boot2docker image",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "FUSION_OS_IMAGE",
+			Name:   "vmwarefusion-os-image",
+			Usage:  "The OS image the machine boots from: boot2docker, alpine or fcos",
+			Value:  osimage.Boot2Docker,
+		},
 		mcnflag.StringFlag{
 			EnvVar: "FUSION_CONFIGDRIVE_URL",
 			Name:   "vmwarefusion-configdrive-url",
@@ -160,6 +170,22 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SSHPassword = flags.String("vmwarefusion-ssh-password")
 	d.SSHPort = 22
 	d.NoShare = flags.Bool("vmwarefusion-no-share")
+	d.OSImage = flags.String("vmwarefusion-os-image")
+	if !osimage.IsBoot2Docker(d.OSImage) {
+		if err := osimage.ValidateForDriver(d.DriverName(), d.OSImage); err != nil {
+			return err
+		}
+		if d.Boot2DockerURL != "" || d.ConfigDriveURL != "" {
+			return errors.New("--vmwarefusion-boot2docker-url and --vmwarefusion-configdrive-url can only be used with the boot2docker OS image")
+		}
+		d.SSHUser = osimage.SSHUser(d.OSImage)
+	}
+
+	if flags.Bool("auto-size") {
+		if err := d.autoSize(); err != nil {
+			return err
+		}
+	}
 
 	// We support a maximum of 16 cpu to be consistent with Virtual Hardware 10
 	// specs.
@@ -173,6 +199,22 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	return nil
 }
 
+// autoSize sizes the machine from the resources of the host, keeping the
+// sizes given explicitly.
+func (d *Driver) autoSize() error {
+	host, err := autosize.Detect(d.StorePath)
+	if err != nil {
+		return err
+	}
+
+	defaults := autosize.Sizes{CPU: defaultCPU, Memory: defaultMemory, DiskSize: defaultDiskSize}
+	sizes := autosize.Sizes{CPU: d.CPU, Memory: d.Memory, DiskSize: d.DiskSize}.Fill(defaults, host.Sizes())
+	d.CPU, d.Memory, d.DiskSize = sizes.CPU, sizes.Memory, sizes.DiskSize
+
+	log.Infof("Sizing the machine for the host: %s", sizes)
+	return nil
+}
+
 func (d *Driver) GetURL() (string, error) {
 	ip, err := d.GetIP()
 	if err != nil {
@@ -229,8 +271,12 @@ func (d *Driver) GetState() (state.State, error) {
 func (d *Driver) PreCreateCheck() error {
 	// Downloading boot2docker to cache should be done here to make sure
 	// that a download failure will not leave a machine half created.
-	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.UpdateISOCache(d.Boot2DockerURL); err != nil {
+	if osimage.IsBoot2Docker(d.OSImage) {
+		b2dutils := mcnutils.NewB2dUtils(d.StorePath)
+		if err := b2dutils.UpdateISOCache(d.Boot2DockerURL); err != nil {
+			return err
+		}
+	} else if err := osimage.UpdateCache(d.StorePath, d.OSImage); err != nil {
 		return err
 	}
 
@@ -239,7 +285,11 @@ func (d *Driver) PreCreateCheck() error {
 
 func (d *Driver) Create() error {
 	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+	if osimage.IsBoot2Docker(d.OSImage) {
+		if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+			return err
+		}
+	} else if err := osimage.CopyToMachineDir(d.StorePath, d.OSImage, d.MachineName); err != nil {
 		return err
 	}
 
@@ -255,6 +305,12 @@ func (d *Driver) Create() error {
 		return err
 	}
 
+	if !osimage.IsBoot2Docker(d.OSImage) {
+		if err := osimage.Personalize(d.OSImage, d.ISO, d.publicSSHKeyPath()); err != nil {
+			return err
+		}
+	}
+
 	log.Infof("Creating VM...")
 	if err := os.MkdirAll(d.ResolveStorePath("."), 0755); err != nil {
 		return err
@@ -300,7 +356,7 @@ func (d *Driver) Create() error {
 
 		if ip != "" {
 			log.Debugf("Got an ip: %s", ip)
-			conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ip, 22), time.Duration(2*time.Second))
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, "22"), time.Duration(2*time.Second))
 			if err != nil {
 				log.Debugf("SSH Daemon not responding yet: %s", err)
 				time.Sleep(2 * time.Second)
@@ -356,6 +412,13 @@ func (d *Driver) Create() error {
 		return nil
 	}
 
+	// The images configured from their ISO already accept the SSH key, and
+	// don't share the guest credentials of boot2docker
+	if osimage.ConfiguredFromISO(d.OSImage) {
+		log.Debugf("Leaving create sequence early, %s is configured from its ISO", d.OSImage)
+		return nil
+	}
+
 	// Generate a tar keys bundle
 	if err := d.generateKeyBundle(); err != nil {
 		return err
@@ -403,6 +466,11 @@ func (d *Driver) Start() error {
 		return nil
 	}
 
+	if osimage.ConfiguredFromISO(d.OSImage) {
+		log.Debugf("Leaving start sequence early, %s is configured from its ISO", d.OSImage)
+		return nil
+	}
+
 	log.Debugf("Mounting Shared Folders...")
 	var shareName, shareDir string // TODO configurable at some point
 	switch runtime.GOOS {
@@ -748,7 +816,7 @@ func executeSSHCommand(command string, d *Driver) error {
 		},
 	}
 
-	client, err := cryptossh.Dial("tcp", fmt.Sprintf("%s:%d", d.IPAddress, d.SSHPort), config)
+	client, err := cryptossh.Dial("tcp", net.JoinHostPort(d.IPAddress, strconv.Itoa(d.SSHPort)), config)
 	if err != nil {
 		log.Debugf("Failed to dial:", err)
 		return err
@@ -0,0 +1,59 @@
+package vmwarefusion
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// vmrun identifies the snapshots by name, which is used as their ID.
+
+func (d *Driver) CreateSnapshot(name string) (*drivers.Snapshot, error) {
+	if _, _, err := vmrun("snapshot", d.vmxPath(), name); err != nil {
+		return nil, err
+	}
+
+	return &drivers.Snapshot{ID: name, Name: name}, nil
+}
+
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	stdout, _, err := vmrun("listSnapshots", d.vmxPath())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSnapshots(stdout)
+}
+
+// parseSnapshots reads the output of vmrun listSnapshots, a count followed by
+// a name per line.
+func parseSnapshots(stdout string) ([]drivers.Snapshot, error) {
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if !strings.HasPrefix(lines[0], "Total snapshots:") {
+		return nil, fmt.Errorf("Unexpected output of vmrun listSnapshots: %q", stdout)
+	}
+
+	snapshots := []drivers.Snapshot{}
+	for _, line := range lines[1:] {
+		name := strings.TrimSpace(line)
+		if name == "" {
+			continue
+		}
+		snapshots = append(snapshots, drivers.Snapshot{ID: name, Name: name})
+	}
+
+	return snapshots, nil
+}
+
+// RestoreSnapshot reverts to a snapshot, which leaves the VM powered off if
+// the snapshot was taken while it was.
+func (d *Driver) RestoreSnapshot(name string) error {
+	_, _, err := vmrun("revertToSnapshot", d.vmxPath(), name)
+	return err
+}
+
+func (d *Driver) DeleteSnapshot(name string) error {
+	_, _, err := vmrun("deleteSnapshot", d.vmxPath(), name)
+	return err
+}
@@ -0,0 +1,257 @@
+package vmwarevsphere
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// restClient is a minimal client for the vCenter REST API, covering the
+// content library and the tags, which the SOAP API doesn't expose.
+type restClient struct {
+	// Endpoint is the URL of the API, e.g. https://vcenter/api.
+	Endpoint string
+
+	session string
+	http    *http.Client
+}
+
+type restError struct {
+	StatusCode int
+	Type       string
+	Messages   []string
+}
+
+func (e *restError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("vSphere API error (%d): %s", e.StatusCode, e.Type)
+	}
+	return fmt.Sprintf("vSphere API error (%d): %s", e.StatusCode, strings.Join(e.Messages, ", "))
+}
+
+// libraryItemTarget is where an OVF template of a content library is
+// deployed.  The host is chosen by DRS if not given.
+type libraryItemTarget struct {
+	ResourcePoolID string `json:"resource_pool_id"`
+	HostID         string `json:"host_id,omitempty"`
+	FolderID       string `json:"folder_id,omitempty"`
+}
+
+type libraryItemDeploymentSpec struct {
+	Name               string `json:"name"`
+	AcceptAllEULA      bool   `json:"accept_all_EULA"`
+	DefaultDatastoreID string `json:"default_datastore_id,omitempty"`
+}
+
+type tag struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	CategoryID string `json:"category_id"`
+}
+
+type tagCategory struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func newRESTClient(host string, port int) *restClient {
+	return &restClient{
+		Endpoint: fmt.Sprintf("https://%s/api", net.JoinHostPort(host, strconv.Itoa(port))),
+		// the SOAP client doesn't verify the certificate of vCenter either
+		http: &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}},
+	}
+}
+
+// Login creates a session of the user.
+func (c *restClient) Login(username, password string) error {
+	req, err := http.NewRequest("POST", c.Endpoint+"/session", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(username, password)
+
+	return c.send(req, &c.session)
+}
+
+// Logout deletes the session.
+func (c *restClient) Logout() error {
+	return c.do("DELETE", "/session", nil, nil)
+}
+
+func (c *restClient) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.Endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("vmware-api-session-id", c.session)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.send(req, out)
+}
+
+func (c *restClient) send(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Type     string `json:"error_type"`
+			Messages []struct {
+				DefaultMessage string `json:"default_message"`
+			} `json:"messages"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+
+		err := &restError{StatusCode: resp.StatusCode, Type: apiErr.Type}
+		for _, m := range apiErr.Messages {
+			err.Messages = append(err.Messages, m.DefaultMessage)
+		}
+		return err
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// FindLibraryItem returns the ID of an item of a content library, both given
+// by name.
+func (c *restClient) FindLibraryItem(library, item string) (string, error) {
+	libraries := []string{}
+	if err := c.do("POST", "/content/library?action=find", map[string]string{"name": library}, &libraries); err != nil {
+		return "", err
+	}
+	if len(libraries) == 0 {
+		return "", fmt.Errorf("content library %q not found", library)
+	}
+
+	items := []string{}
+	if err := c.do("POST", "/content/library/item?action=find", map[string]string{"library_id": libraries[0], "name": item}, &items); err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "", fmt.Errorf("template %q not found in the content library %q", item, library)
+	}
+
+	return items[0], nil
+}
+
+// DeployLibraryItem deploys an OVF template of a content library and returns
+// the ID of the new VM, e.g. vm-42.
+func (c *restClient) DeployLibraryItem(id string, target libraryItemTarget, spec libraryItemDeploymentSpec) (string, error) {
+	request := map[string]interface{}{
+		"target":          target,
+		"deployment_spec": spec,
+	}
+
+	var result struct {
+		Succeeded  bool `json:"succeeded"`
+		ResourceID struct {
+			ID string `json:"id"`
+		} `json:"resource_id"`
+		Error struct {
+			Errors []struct {
+				Error struct {
+					Messages []struct {
+						DefaultMessage string `json:"default_message"`
+					} `json:"messages"`
+				} `json:"error"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if err := c.do("POST", fmt.Sprintf("/vcenter/ovf/library-item/%s?action=deploy", url.PathEscape(id)), request, &result); err != nil {
+		return "", err
+	}
+
+	if !result.Succeeded {
+		messages := []string{}
+		for _, e := range result.Error.Errors {
+			for _, m := range e.Error.Messages {
+				messages = append(messages, m.DefaultMessage)
+			}
+		}
+		return "", fmt.Errorf("Error deploying the template: %s", strings.Join(messages, ", "))
+	}
+
+	return result.ResourceID.ID, nil
+}
+
+// FindTag returns the ID of a tag given as name or category/name.
+func (c *restClient) FindTag(name string) (string, error) {
+	category := ""
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		category, name = name[:i], name[i+1:]
+	}
+
+	ids := []string{}
+	if err := c.do("GET", "/cis/tagging/tag", nil, &ids); err != nil {
+		return "", err
+	}
+
+	found := []tag{}
+	for _, id := range ids {
+		t := tag{}
+		if err := c.do("GET", "/cis/tagging/tag/"+url.PathEscape(id), nil, &t); err != nil {
+			return "", err
+		}
+		if t.Name != name {
+			continue
+		}
+
+		if category != "" {
+			tc := tagCategory{}
+			if err := c.do("GET", "/cis/tagging/category/"+url.PathEscape(t.CategoryID), nil, &tc); err != nil {
+				return "", err
+			}
+			if tc.Name != category {
+				continue
+			}
+		}
+		found = append(found, t)
+	}
+
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("tag %q not found", name)
+	case 1:
+		return found[0].ID, nil
+	}
+	return "", fmt.Errorf("tag %q exists in several categories, give it as <category>/%s", name, name)
+}
+
+// AttachTag attaches a tag to a VM.
+func (c *restClient) AttachTag(tagID, vmID string) error {
+	request := map[string]interface{}{
+		"object_id": map[string]string{"type": "VirtualMachine", "id": vmID},
+	}
+	return c.do("POST", fmt.Sprintf("/cis/tagging/tag-association/%s?action=attach", url.PathEscape(tagID)), request, nil)
+}
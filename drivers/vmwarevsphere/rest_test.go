@@ -0,0 +1,138 @@
+package vmwarevsphere
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVCenter serves the content library and the tags of the REST API,
+// recording the requests to deploy and to attach.
+type fakeVCenter struct {
+	deploy map[string]interface{}
+	attach []string
+}
+
+func (v *fakeVCenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/session" {
+		username, password, _ := r.BasicAuth()
+		if username != "user" || password != "SECRET" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error_type":"UNAUTHENTICATED","messages":[{"default_message":"Authentication required."}]}`))
+			return
+		}
+		w.Write([]byte(`"SESSION"`))
+		return
+	}
+
+	if r.Header.Get("vmware-api-session-id") != "SESSION" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/content/library":
+		w.Write([]byte(`["lib-1"]`))
+	case "/content/library/item":
+		var find map[string]string
+		json.NewDecoder(r.Body).Decode(&find)
+		if find["library_id"] == "lib-1" && find["name"] == "ubuntu-2204" {
+			w.Write([]byte(`["item-1"]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	case "/vcenter/ovf/library-item/item-1":
+		json.NewDecoder(r.Body).Decode(&v.deploy)
+		w.Write([]byte(`{"succeeded":true,"resource_id":{"type":"VirtualMachine","id":"vm-42"}}`))
+	case "/cis/tagging/tag":
+		w.Write([]byte(`["tag-1","tag-2","tag-3"]`))
+	case "/cis/tagging/tag/tag-1":
+		w.Write([]byte(`{"id":"tag-1","name":"docker","category_id":"cat-1"}`))
+	case "/cis/tagging/tag/tag-2":
+		w.Write([]byte(`{"id":"tag-2","name":"prod","category_id":"cat-1"}`))
+	case "/cis/tagging/tag/tag-3":
+		w.Write([]byte(`{"id":"tag-3","name":"prod","category_id":"cat-2"}`))
+	case "/cis/tagging/category/cat-1":
+		w.Write([]byte(`{"id":"cat-1","name":"env"}`))
+	case "/cis/tagging/category/cat-2":
+		w.Write([]byte(`{"id":"cat-2","name":"tier"}`))
+	case "/cis/tagging/tag-association/tag-1":
+		v.attach = append(v.attach, r.URL.RawQuery)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestRESTClient(t *testing.T, vcenter *fakeVCenter) (*restClient, func()) {
+	server := httptest.NewServer(vcenter)
+
+	rc := newRESTClient("vcenter", 443)
+	rc.Endpoint = server.URL
+	assert.NoError(t, rc.Login("user", "SECRET"))
+
+	return rc, server.Close
+}
+
+func TestRESTLoginFailure(t *testing.T) {
+	server := httptest.NewServer(&fakeVCenter{})
+	defer server.Close()
+
+	rc := newRESTClient("vcenter", 443)
+	rc.Endpoint = server.URL
+
+	assert.EqualError(t, rc.Login("user", "WRONG"), "vSphere API error (401): Authentication required.")
+}
+
+func TestFindLibraryItem(t *testing.T) {
+	rc, cleanup := newTestRESTClient(t, &fakeVCenter{})
+	defer cleanup()
+
+	id, err := rc.FindLibraryItem("templates", "ubuntu-2204")
+	assert.NoError(t, err)
+	assert.Equal(t, "item-1", id)
+
+	_, err = rc.FindLibraryItem("templates", "debian-12")
+	assert.EqualError(t, err, `template "debian-12" not found in the content library "templates"`)
+}
+
+func TestDeployLibraryItem(t *testing.T) {
+	vcenter := &fakeVCenter{}
+	rc, cleanup := newTestRESTClient(t, vcenter)
+	defer cleanup()
+
+	id, err := rc.DeployLibraryItem("item-1", libraryItemTarget{ResourcePoolID: "resgroup-8", FolderID: "group-v3"}, libraryItemDeploymentSpec{Name: "default", AcceptAllEULA: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "vm-42", id)
+	assert.Equal(t, map[string]interface{}{"resource_pool_id": "resgroup-8", "folder_id": "group-v3"}, vcenter.deploy["target"])
+}
+
+func TestFindTag(t *testing.T) {
+	rc, cleanup := newTestRESTClient(t, &fakeVCenter{})
+	defer cleanup()
+
+	id, err := rc.FindTag("docker")
+	assert.NoError(t, err)
+	assert.Equal(t, "tag-1", id)
+
+	id, err = rc.FindTag("tier/prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "tag-3", id)
+
+	_, err = rc.FindTag("prod")
+	assert.EqualError(t, err, `tag "prod" exists in several categories, give it as <category>/prod`)
+
+	_, err = rc.FindTag("staging")
+	assert.EqualError(t, err, `tag "staging" not found`)
+}
+
+func TestAttachTag(t *testing.T) {
+	vcenter := &fakeVCenter{}
+	rc, cleanup := newTestRESTClient(t, vcenter)
+	defer cleanup()
+
+	assert.NoError(t, rc.AttachTag("tag-1", "vm-42"))
+	assert.Equal(t, []string{"action=attach"}, vcenter.attach)
+}
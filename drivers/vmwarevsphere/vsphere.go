@@ -6,11 +6,13 @@ package vmwarevsphere
 
 import (
 	"archive/tar"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -59,6 +61,12 @@ type Driver struct {
 	Datacenter string
 	Pool       string
 	HostSystem string
+	Cluster    string
+
+	ContentLibrary    string
+	Template          string
+	CustomizationSpec string
+	Tags              []string
 
 	SSHPassword string
 }
@@ -145,6 +153,31 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "vmwarevsphere-hostsystem",
 			Usage:  "vSphere compute resource where the docker VM will be instantiated (use <cluster>/* or <cluster>/<host> if using a cluster)",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "VSPHERE_CLUSTER",
+			Name:   "vmwarevsphere-cluster",
+			Usage:  "vSphere cluster where DRS places the docker VM",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VSPHERE_CONTENT_LIBRARY",
+			Name:   "vmwarevsphere-content-library",
+			Usage:  "vSphere content library holding the OVF template of the docker VM",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VSPHERE_TEMPLATE",
+			Name:   "vmwarevsphere-template",
+			Usage:  "vSphere OVF template of the content library the docker VM is deployed from, instead of the boot2docker ISO",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VSPHERE_CUSTOMIZATION_SPEC",
+			Name:   "vmwarevsphere-customization-spec",
+			Usage:  "vSphere guest customization specification configuring the network of the docker VM deployed from a template",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "VSPHERE_TAGS",
+			Name:   "vmwarevsphere-tag",
+			Usage:  "vSphere tag of the docker VM, given as <name> or <category>/<name>",
+		},
 	}
 }
 
@@ -181,7 +214,8 @@ func (d *Driver) DriverName() string {
 }
 
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
-	if drivers.EngineInstallURLFlagSet(flags) {
+	d.Template = flags.String("vmwarevsphere-template")
+	if d.Template == "" && drivers.EngineInstallURLFlagSet(flags) {
 		return errors.New("--engine-install-url cannot be used with the vmwarevsphere driver, use --vmwarevsphere-boot2docker-url instead")
 	}
 	d.SSHUser = "docker"
@@ -199,10 +233,26 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.Datacenter = flags.String("vmwarevsphere-datacenter")
 	d.Pool = flags.String("vmwarevsphere-pool")
 	d.HostSystem = flags.String("vmwarevsphere-hostsystem")
+	d.Cluster = flags.String("vmwarevsphere-cluster")
+	d.ContentLibrary = flags.String("vmwarevsphere-content-library")
+	d.CustomizationSpec = flags.String("vmwarevsphere-customization-spec")
+	d.Tags = flags.StringSlice("vmwarevsphere-tag")
 	d.SetSwarmConfigFromFlags(flags)
 
 	d.ISO = d.ResolveStorePath(isoFilename)
 
+	if d.Cluster != "" && d.HostSystem != "" {
+		return errors.New("--vmwarevsphere-cluster and --vmwarevsphere-hostsystem are mutually exclusive")
+	}
+
+	if d.Template != "" && d.ContentLibrary == "" {
+		return errors.New("--vmwarevsphere-template requires --vmwarevsphere-content-library")
+	}
+
+	if d.Template == "" && d.CustomizationSpec != "" {
+		return errors.New("--vmwarevsphere-customization-spec requires --vmwarevsphere-template, boot2docker can't be customized")
+	}
+
 	return nil
 }
 
@@ -326,20 +376,34 @@ func (d *Driver) PreCreateCheck() error {
 		return err
 	}
 
-	hs, err := f.HostSystemOrDefault(ctx, d.HostSystem)
+	if _, _, err := d.placement(ctx, f); err != nil {
+		return err
+	}
+
+	if d.CustomizationSpec != "" {
+		if _, err := object.NewCustomizationSpecManager(c.Client).GetCustomizationSpec(ctx, d.CustomizationSpec); err != nil {
+			return err
+		}
+	}
+
+	if d.Template == "" && len(d.Tags) == 0 {
+		return nil
+	}
+
+	rc, err := d.restLogin()
 	if err != nil {
 		return err
 	}
+	defer rc.Logout()
 
-	// ResourcePool
-	if d.Pool != "" {
-		// Find specified Resource Pool
-		if _, err := f.ResourcePool(ctx, d.Pool); err != nil {
+	if d.Template != "" {
+		if _, err := rc.FindLibraryItem(d.ContentLibrary, d.Template); err != nil {
 			return err
 		}
-	} else {
-		// Pick default Resource Pool for Host System
-		if _, err := hs.ResourcePool(ctx); err != nil {
+	}
+
+	for _, t := range d.Tags {
+		if _, err := rc.FindTag(t); err != nil {
 			return err
 		}
 	}
@@ -347,15 +411,55 @@ func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
+// placement returns the resource pool the VM is created in and its host.
+// In a cluster, the host is nil and DRS places the VM.
+func (d *Driver) placement(ctx context.Context, f *find.Finder) (*object.ResourcePool, *object.HostSystem, error) {
+	if d.Cluster != "" {
+		cluster, err := f.ClusterComputeResource(ctx, d.Cluster)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if d.Pool != "" {
+			rp, err := f.ResourcePool(ctx, d.Pool)
+			return rp, nil, err
+		}
+
+		rp, err := cluster.ResourcePool(ctx)
+		return rp, nil, err
+	}
+
+	hs, err := f.HostSystemOrDefault(ctx, d.HostSystem)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if d.Pool != "" {
+		// Find specified Resource Pool
+		rp, err := f.ResourcePool(ctx, d.Pool)
+		return rp, hs, err
+	}
+
+	// Pick default Resource Pool for Host System
+	rp, err := hs.ResourcePool(ctx)
+	return rp, hs, err
+}
+
 // Create has the following implementation:
 // 1. check whether the docker directory contains the boot2docker ISO
 // 2. generate an SSH keypair and bundle it in a tar.
 // 3. create a virtual machine with the boot2docker ISO mounted;
 // 4. reconfigure the virtual machine network and disk size;
+//
+// With a template, the VM is deployed from the content library instead, see
+// createFromTemplate.
 func (d *Driver) Create() error {
-	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
-		return err
+	if d.Template == "" {
+		// The ESXi hosts run x86_64 virtual machines whatever Machine runs on
+		b2dutils := mcnutils.NewB2dUtilsForArch(d.StorePath, mcnutils.ArchAMD64)
+		if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+			return err
+		}
 	}
 
 	log.Infof("Generating SSH Keypair...")
@@ -393,24 +497,13 @@ func (d *Driver) Create() error {
 		return err
 	}
 
-	hs, err := f.HostSystemOrDefault(ctx, d.HostSystem)
+	rp, hs, err := d.placement(ctx, f)
 	if err != nil {
 		return err
 	}
 
-	var rp *object.ResourcePool
-	if d.Pool != "" {
-		// Find specified Resource Pool
-		rp, err = f.ResourcePool(ctx, d.Pool)
-		if err != nil {
-			return err
-		}
-	} else {
-		// Pick default Resource Pool for Host System
-		rp, err = hs.ResourcePool(ctx)
-		if err != nil {
-			return err
-		}
+	if d.Template != "" {
+		return d.createFromTemplate(ctx, c, dc, dss, net, rp, hs)
 	}
 
 	spec := types.VirtualMachineConfigSpec{
@@ -503,6 +596,10 @@ func (d *Driver) Create() error {
 		return err
 	}
 
+	if err := d.attachTags(nil, vm.Reference().Value); err != nil {
+		return err
+	}
+
 	if err := d.Start(); err != nil {
 		return err
 	}
@@ -729,35 +826,222 @@ func (d *Driver) Remove() error {
 		return err
 	}
 
-	// Remove B2D Iso from VM folder
-	m := object.NewFileManager(c.Client)
-	task, err := m.DeleteDatastoreFile(ctx, dss.Path(fmt.Sprintf("%s/%s", d.MachineName, isoFilename)), dc)
+	// The VMs deployed from a template have no ISO
+	if d.Template == "" {
+		// Remove B2D Iso from VM folder
+		m := object.NewFileManager(c.Client)
+		task, err := m.DeleteDatastoreFile(ctx, dss.Path(fmt.Sprintf("%s/%s", d.MachineName, isoFilename)), dc)
+		if err != nil {
+			return err
+		}
+
+		err = task.Wait(ctx)
+		if err != nil {
+			if types.IsFileNotFound(err) {
+				// Ignore error
+				return nil
+			}
+		}
+	}
+
+	vm, err := d.fetchVM(c, ctx, d.MachineName)
+	if err != nil {
+		return err
+	}
+
+	task, err := vm.Destroy(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = task.Wait(ctx)
+	_, err = task.WaitForResult(ctx, nil)
 	if err != nil {
-		if types.IsFileNotFound(err) {
-			// Ignore error
-			return nil
-		}
+		return err
 	}
+	return nil
+}
 
-	vm, err := d.fetchVM(c, ctx, d.MachineName)
+// createFromTemplate deploys the VM from an OVF template of the content
+// library, sized and connected to the network like the VMs booted from the
+// ISO.  cloud-init authorizes the SSH key, read from the guestinfo of the VM,
+// and the network is configured by the guest customization specification if
+// any.
+func (d *Driver) createFromTemplate(ctx context.Context, c *govmomi.Client, dc *object.Datacenter, dss *object.Datastore, network object.NetworkReference, rp *object.ResourcePool, hs *object.HostSystem) error {
+	rc, err := d.restLogin()
 	if err != nil {
 		return err
 	}
+	defer rc.Logout()
 
-	task, err = vm.Destroy(ctx)
+	item, err := rc.FindLibraryItem(d.ContentLibrary, d.Template)
 	if err != nil {
 		return err
 	}
 
-	_, err = task.WaitForResult(ctx, nil)
+	folders, err := dc.Folders(ctx)
+	if err != nil {
+		return err
+	}
+
+	target := libraryItemTarget{
+		ResourcePoolID: rp.Reference().Value,
+		FolderID:       folders.VmFolder.Reference().Value,
+	}
+	if hs != nil {
+		target.HostID = hs.Reference().Value
+	}
+
+	log.Infof("Deploying the template %s of the content library %s...", d.Template, d.ContentLibrary)
+	vmID, err := rc.DeployLibraryItem(item, target, libraryItemDeploymentSpec{
+		Name:               d.MachineName,
+		AcceptAllEULA:      true,
+		DefaultDatastoreID: dss.Reference().Value,
+	})
+	if err != nil {
+		return err
+	}
+
+	vm := object.NewVirtualMachine(c.Client, types.ManagedObjectReference{Type: "VirtualMachine", Value: vmID})
+
+	log.Infof("Reconfiguring VM...")
+	spec, err := d.templateConfigSpec(ctx, vm, network)
+	if err != nil {
+		return err
+	}
+
+	task, err := vm.Reconfigure(ctx, *spec)
 	if err != nil {
 		return err
 	}
+	if err := task.Wait(ctx); err != nil {
+		return err
+	}
+
+	if d.CustomizationSpec != "" {
+		log.Infof("Customizing the VM with %s...", d.CustomizationSpec)
+		item, err := object.NewCustomizationSpecManager(c.Client).GetCustomizationSpec(ctx, d.CustomizationSpec)
+		if err != nil {
+			return err
+		}
+
+		task, err := vm.Customize(ctx, item.Spec)
+		if err != nil {
+			return err
+		}
+		if err := task.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := d.attachTags(rc, vmID); err != nil {
+		return err
+	}
+
+	return d.Start()
+}
+
+// templateConfigSpec returns the reconfiguration of a VM deployed from a
+// template: its size, its network, its disk grown to the disk size and the
+// cloud-init user data authorizing the SSH key.
+func (d *Driver) templateConfigSpec(ctx context.Context, vm *object.VirtualMachine, network object.NetworkReference) (*types.VirtualMachineConfigSpec, error) {
+	pubKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &types.VirtualMachineConfigSpec{
+		NumCPUs:  int32(d.CPU),
+		MemoryMB: int64(d.Memory),
+		ExtraConfig: []types.BaseOptionValue{
+			&types.OptionValue{Key: "guestinfo.userdata", Value: base64.StdEncoding.EncodeToString([]byte(d.cloudConfig(string(pubKey))))},
+			&types.OptionValue{Key: "guestinfo.userdata.encoding", Value: "base64"},
+		},
+	}
+
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	backing, err := network.EthernetCardBackingInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if cards := devices.SelectByType((*types.VirtualEthernetCard)(nil)); len(cards) > 0 {
+		card := cards[0].(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+		card.Backing = backing
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+			Device:    cards[0],
+		})
+	} else {
+		netdev, err := object.EthernetCardTypes().CreateEthernetCard("vmxnet3", backing)
+		if err != nil {
+			return nil, err
+		}
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			Device:    netdev,
+		})
+	}
+
+	// Convert MB to KB
+	capacity := int64(d.DiskSize) * 1024
+	if disks := devices.SelectByType((*types.VirtualDisk)(nil)); len(disks) > 0 {
+		disk := disks[0].(*types.VirtualDisk)
+		if disk.CapacityInKB < capacity {
+			disk.CapacityInKB = capacity
+			spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationEdit,
+				Device:    disk,
+			})
+		}
+	}
+
+	return spec, nil
+}
+
+// cloudConfig returns the cloud-init user data creating the SSH user of a VM
+// deployed from a template.
+func (d *Driver) cloudConfig(pubKey string) string {
+	return fmt.Sprintf(`#cloud-config
+users:
+  - name: %s
+    sudo: ALL=(ALL) NOPASSWD:ALL
+    shell: /bin/bash
+    ssh_authorized_keys:
+      - %s
+`, d.GetSSHUsername(), strings.TrimSpace(pubKey))
+}
+
+// attachTags attaches the tags to a VM, logging in to the REST API if rc is
+// nil.
+func (d *Driver) attachTags(rc *restClient, vmID string) error {
+	if len(d.Tags) == 0 {
+		return nil
+	}
+
+	if rc == nil {
+		var err error
+		if rc, err = d.restLogin(); err != nil {
+			return err
+		}
+		defer rc.Logout()
+	}
+
+	for _, t := range d.Tags {
+		id, err := rc.FindTag(t)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("attaching the tag %s to %s", t, vmID)
+		if err := rc.AttachTag(id, vmID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -827,7 +1111,7 @@ func (d *Driver) generateKeyBundle() error {
 func (d *Driver) vsphereLogin(ctx context.Context) (*govmomi.Client, error) {
 
 	// Parse URL from string
-	u, err := url.Parse(fmt.Sprintf("https://%s:%d/sdk", d.IP, d.Port))
+	u, err := url.Parse(fmt.Sprintf("https://%s/sdk", net.JoinHostPort(d.IP, strconv.Itoa(d.Port))))
 	if err != nil {
 		return nil, err
 	}
@@ -843,6 +1127,15 @@ func (d *Driver) vsphereLogin(ctx context.Context) (*govmomi.Client, error) {
 	return c, nil
 }
 
+// restLogin logs in to the REST API of vCenter.
+func (d *Driver) restLogin() (*restClient, error) {
+	rc := newRESTClient(d.IP, d.Port)
+	if err := rc.Login(d.Username, d.Password); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
 func (d *Driver) fetchVM(c *govmomi.Client, ctx context.Context, vmname string) (*object.VirtualMachine, error) {
 
 	// Create a new finder
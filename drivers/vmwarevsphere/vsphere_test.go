@@ -20,3 +20,56 @@ func TestSetConfigFromFlags(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Empty(t, checkFlags.InvalidFlags)
 }
+
+func TestSetConfigFromFlagsTemplateRequiresContentLibrary(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"vmwarevsphere-template": "ubuntu-2204",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.EqualError(t, driver.SetConfigFromFlags(checkFlags), "--vmwarevsphere-template requires --vmwarevsphere-content-library")
+}
+
+func TestSetConfigFromFlagsCustomizationSpecRequiresTemplate(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"vmwarevsphere-customization-spec": "static-ip",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestSetConfigFromFlagsClusterAndHostSystem(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"vmwarevsphere-cluster":    "prod",
+			"vmwarevsphere-hostsystem": "prod/esx1",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestCloudConfig(t *testing.T) {
+	driver := NewDriver("default", "path").(*Driver)
+
+	assert.Equal(t, `#cloud-config
+users:
+  - name: docker
+    sudo: ALL=(ALL) NOPASSWD:ALL
+    shell: /bin/bash
+    ssh_authorized_keys:
+      - ssh-rsa AAAA
+`, driver.cloudConfig("ssh-rsa AAAA\n"))
+}
@@ -0,0 +1,270 @@
+package vultr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const (
+	defaultAPIEndpoint = "https://api.vultr.com/v2"
+)
+
+// Client is a minimal client for the Vultr API v2, covering what the driver
+// needs to manage an instance.
+type Client struct {
+	APIKey   string
+	Endpoint string
+	http     *http.Client
+}
+
+type APIError struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("vultr API error (%d): %s", e.StatusCode, e.Message)
+}
+
+type Instance struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	MainIP      string `json:"main_ip"`
+	V6MainIP    string `json:"v6_main_ip"`
+	InternalIP  string `json:"internal_ip"`
+	Status      string `json:"status"`
+	PowerStatus string `json:"power_status"`
+}
+
+type InstanceCreateRequest struct {
+	Region       string   `json:"region"`
+	Plan         string   `json:"plan"`
+	OSID         int      `json:"os_id,omitempty"`
+	SnapshotID   string   `json:"snapshot_id,omitempty"`
+	Label        string   `json:"label"`
+	Hostname     string   `json:"hostname"`
+	SSHKeyIDs    []string `json:"sshkey_id,omitempty"`
+	ScriptID     string   `json:"script_id,omitempty"`
+	ReservedIPv4 string   `json:"reserved_ipv4,omitempty"`
+	AttachVPC    []string `json:"attach_vpc,omitempty"`
+	EnableIPv6   bool     `json:"enable_ipv6"`
+	Tags         []string `json:"tags,omitempty"`
+	UserData     string   `json:"user_data,omitempty"`
+}
+
+type SSHKey struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	SSHKey string `json:"ssh_key"`
+}
+
+type Snapshot struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	DateCreated string `json:"date_created"`
+	Status      string `json:"status"`
+}
+
+// InstanceVPC is the attachment of an instance to a VPC.
+type InstanceVPC struct {
+	ID        string `json:"id"`
+	IPAddress string `json:"ip_address"`
+}
+
+type Region struct {
+	ID string `json:"id"`
+}
+
+func NewClient(apiKey string) *Client {
+	return &Client{
+		APIKey:   apiKey,
+		Endpoint: defaultAPIEndpoint,
+		http:     &http.Client{},
+	}
+}
+
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.Endpoint+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.Unmarshal(respBody, apiErr)
+		return apiErr
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// IsNotFound returns true if err is an API error for a missing resource.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) CreateInstance(createRequest *InstanceCreateRequest) (*Instance, error) {
+	var resp struct {
+		Instance *Instance `json:"instance"`
+	}
+	if err := c.do("POST", "/instances", createRequest, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Instance, nil
+}
+
+func (c *Client) GetInstance(id string) (*Instance, error) {
+	var resp struct {
+		Instance *Instance `json:"instance"`
+	}
+	if err := c.do("GET", "/instances/"+id, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Instance, nil
+}
+
+func (c *Client) DeleteInstance(id string) error {
+	return c.do("DELETE", "/instances/"+id, nil, nil)
+}
+
+// InstanceAction runs an action such as start, halt or reboot on an
+// instance.
+func (c *Client) InstanceAction(id, action string) error {
+	return c.do("POST", fmt.Sprintf("/instances/%s/%s", id, action), nil, nil)
+}
+
+// ListInstanceVPCs returns the VPCs an instance is attached to.
+func (c *Client) ListInstanceVPCs(id string) ([]InstanceVPC, error) {
+	var resp struct {
+		VPCs []InstanceVPC `json:"vpcs"`
+	}
+	if err := c.do("GET", fmt.Sprintf("/instances/%s/vpcs", id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.VPCs, nil
+}
+
+// RestoreInstance starts replacing the disk of an instance with a snapshot.
+func (c *Client) RestoreInstance(id, snapshotID string) error {
+	body := map[string]string{"snapshot_id": snapshotID}
+	return c.do("POST", fmt.Sprintf("/instances/%s/restore", id), body, nil)
+}
+
+func (c *Client) CreateSSHKey(name, publicKey string) (*SSHKey, error) {
+	var resp struct {
+		SSHKey *SSHKey `json:"ssh_key"`
+	}
+	if err := c.do("POST", "/ssh-keys", &SSHKey{Name: name, SSHKey: publicKey}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.SSHKey, nil
+}
+
+func (c *Client) DeleteSSHKey(id string) error {
+	return c.do("DELETE", "/ssh-keys/"+id, nil, nil)
+}
+
+// CreateSnapshot starts the creation of a snapshot of an instance.
+func (c *Client) CreateSnapshot(instanceID, description string) (*Snapshot, error) {
+	var resp struct {
+		Snapshot *Snapshot `json:"snapshot"`
+	}
+	body := map[string]string{"instance_id": instanceID, "description": description}
+	if err := c.do("POST", "/snapshots", body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Snapshot, nil
+}
+
+func (c *Client) GetSnapshot(id string) (*Snapshot, error) {
+	var resp struct {
+		Snapshot *Snapshot `json:"snapshot"`
+	}
+	if err := c.do("GET", "/snapshots/"+id, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Snapshot, nil
+}
+
+// ListSnapshots returns the snapshots of the account.
+func (c *Client) ListSnapshots() ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+
+	cursor := ""
+	for {
+		var resp struct {
+			Snapshots []Snapshot `json:"snapshots"`
+			Meta      struct {
+				Links struct {
+					Next string `json:"next"`
+				} `json:"links"`
+			} `json:"meta"`
+		}
+		if err := c.do("GET", "/snapshots?per_page=100&cursor="+url.QueryEscape(cursor), nil, &resp); err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, resp.Snapshots...)
+
+		if resp.Meta.Links.Next == "" {
+			return snapshots, nil
+		}
+		cursor = resp.Meta.Links.Next
+	}
+}
+
+func (c *Client) DeleteSnapshot(id string) error {
+	return c.do("DELETE", "/snapshots/"+id, nil, nil)
+}
+
+// ListRegions returns the regions instances can be created in.
+func (c *Client) ListRegions() ([]Region, error) {
+	var resp struct {
+		Regions []Region `json:"regions"`
+	}
+	if err := c.do("GET", "/regions?per_page=500", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Regions, nil
+}
+
+// ListRegionPlans returns the IDs of the plans available in a region.
+func (c *Client) ListRegionPlans(region string) ([]string, error) {
+	var resp struct {
+		AvailablePlans []string `json:"available_plans"`
+	}
+	if err := c.do("GET", fmt.Sprintf("/regions/%s/availability", url.PathEscape(region)), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.AvailablePlans, nil
+}
@@ -0,0 +1,18 @@
+package vultr
+
+// An instance is cloned from a snapshot of the source instance, which is kept
+// in the account once the clone is created.
+
+func (d *Driver) CloneDisk(name string) (string, error) {
+	snapshot, err := d.CreateSnapshot(name)
+	if err != nil {
+		return "", err
+	}
+
+	return snapshot.ID, nil
+}
+
+func (d *Driver) SetClonedDisk(disk string) error {
+	d.SnapshotID = disk
+	return nil
+}
@@ -0,0 +1,114 @@
+package vultr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// snapshotPollInterval is the delay between two checks of a pending
+// snapshot.
+var snapshotPollInterval = 5 * time.Second
+
+// The snapshots of the account don't record the instance they were taken
+// from, so the snapshots of a machine are the ones whose description is
+// prefixed with its name.  They are restored by restoring the instance from
+// them.
+
+func (d *Driver) snapshotDescription(name string) string {
+	return d.MachineName + ": " + name
+}
+
+func (d *Driver) CreateSnapshot(name string) (*drivers.Snapshot, error) {
+	client := d.getClient()
+
+	snapshot, err := client.CreateSnapshot(d.InstanceID, d.snapshotDescription(name))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Waiting for the snapshot to complete...")
+	for snapshot.Status == "pending" {
+		time.Sleep(snapshotPollInterval)
+
+		if snapshot, err = client.GetSnapshot(snapshot.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if snapshot.Status != "complete" {
+		return nil, fmt.Errorf("vultr snapshot %s ended as %s", snapshot.ID, snapshot.Status)
+	}
+
+	machineSnapshot, _ := d.machineSnapshot(*snapshot)
+	return &machineSnapshot, nil
+}
+
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	snapshots, err := d.getClient().ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	machineSnapshots := []drivers.Snapshot{}
+	for _, snapshot := range snapshots {
+		if machineSnapshot, ok := d.machineSnapshot(snapshot); ok {
+			machineSnapshots = append(machineSnapshots, machineSnapshot)
+		}
+	}
+
+	return machineSnapshots, nil
+}
+
+// machineSnapshot returns a snapshot of the account as a snapshot of the
+// machine, and whether it's one of its snapshots.
+func (d *Driver) machineSnapshot(snapshot Snapshot) (drivers.Snapshot, bool) {
+	prefix := d.snapshotDescription("")
+
+	machineSnapshot := drivers.Snapshot{
+		ID:   snapshot.ID,
+		Name: strings.TrimPrefix(snapshot.Description, prefix),
+	}
+
+	if created, err := time.Parse(time.RFC3339, snapshot.DateCreated); err == nil {
+		machineSnapshot.Created = created.UTC()
+	}
+
+	return machineSnapshot, strings.HasPrefix(snapshot.Description, prefix)
+}
+
+func (d *Driver) RestoreSnapshot(name string) error {
+	snapshotID, err := d.findSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Restoring the instance...")
+	return d.getClient().RestoreInstance(d.InstanceID, snapshotID)
+}
+
+func (d *Driver) DeleteSnapshot(name string) error {
+	snapshotID, err := d.findSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	return d.getClient().DeleteSnapshot(snapshotID)
+}
+
+func (d *Driver) findSnapshot(name string) (string, error) {
+	snapshots, err := d.ListSnapshots()
+	if err != nil {
+		return "", err
+	}
+
+	snapshot, err := drivers.FindSnapshot(snapshots, name)
+	if err != nil {
+		return "", err
+	}
+
+	return snapshot.ID, nil
+}
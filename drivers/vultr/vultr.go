@@ -0,0 +1,475 @@
+package vultr
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	APIKey            string
+	InstanceID        string
+	Region            string
+	Plan              string
+	OSID              int
+	SnapshotID        string
+	StartupScriptID   string
+	ReservedIPID      string
+	VPCIDs            []string
+	UsePrivateAddress bool
+	PrivateIPAddress  string
+	IPv6              bool
+	Tags              []string
+	SSHKeyID          string
+	UserDataFile      string
+	client            *Client
+}
+
+const (
+	defaultSSHPort = 22
+	defaultSSHUser = "root"
+	defaultRegion  = "ewr"
+	defaultPlan    = "vc2-1c-1gb"
+	// Ubuntu 22.04 LTS x64
+	defaultOSID = 1743
+	// Vultr reports this address until the instance gets one
+	unassignedIP = "0.0.0.0"
+)
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_API_KEY",
+			Name:   "vultr-api-key",
+			Usage:  "Vultr API key",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_REGION",
+			Name:   "vultr-region",
+			Usage:  "Vultr region",
+			Value:  defaultRegion,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_PLAN",
+			Name:   "vultr-plan",
+			Usage:  "Vultr plan",
+			Value:  defaultPlan,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "VULTR_OS_ID",
+			Name:   "vultr-os-id",
+			Usage:  "Vultr operating system ID",
+			Value:  defaultOSID,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_SNAPSHOT_ID",
+			Name:   "vultr-snapshot-id",
+			Usage:  "Vultr snapshot to create the instance from instead of an operating system",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_STARTUP_SCRIPT_ID",
+			Name:   "vultr-startup-script-id",
+			Usage:  "Vultr startup script to run on the instance",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_RESERVED_IP_ID",
+			Name:   "vultr-reserved-ip-id",
+			Usage:  "Vultr reserved IPv4 to assign to the instance",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "VULTR_VPC_IDS",
+			Name:   "vultr-vpc-ids",
+			Usage:  "VPCs to attach the instance to",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "VULTR_USE_PRIVATE_ADDRESS",
+			Name:   "vultr-use-private-address",
+			Usage:  "Use the VPC IP address to communicate with the instance",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "VULTR_IPV6",
+			Name:   "vultr-ipv6",
+			Usage:  "Enable IPv6 for the instance",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "VULTR_TAGS",
+			Name:   "vultr-tags",
+			Usage:  "Tags of the instance",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_USER_DATA",
+			Name:   "vultr-user-data",
+			Usage:  "path to file with cloud-init user-data",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_SSH_USER",
+			Name:   "vultr-ssh-user",
+			Usage:  "SSH username",
+			Value:  defaultSSHUser,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "VULTR_SSH_PORT",
+			Name:   "vultr-ssh-port",
+			Usage:  "SSH port",
+			Value:  defaultSSHPort,
+		},
+	}
+}
+
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Region: defaultRegion,
+		Plan:   defaultPlan,
+		OSID:   defaultOSID,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "vultr"
+}
+
+// Capabilities returns the features of the driver, including IPv6 and user data
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.IPv6 = true
+	capabilities.UserData = true
+	return capabilities
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.APIKey = flags.String("vultr-api-key")
+	d.Region = flags.String("vultr-region")
+	d.Plan = flags.String("vultr-plan")
+	d.OSID = flags.Int("vultr-os-id")
+	d.SnapshotID = flags.String("vultr-snapshot-id")
+	d.StartupScriptID = flags.String("vultr-startup-script-id")
+	d.ReservedIPID = flags.String("vultr-reserved-ip-id")
+	d.VPCIDs = flags.StringSlice("vultr-vpc-ids")
+	d.UsePrivateAddress = flags.Bool("vultr-use-private-address")
+	d.IPv6 = flags.Bool("vultr-ipv6")
+	d.Tags = flags.StringSlice("vultr-tags")
+	d.UserDataFile = flags.String("vultr-user-data")
+	d.SSHUser = flags.String("vultr-ssh-user")
+	d.SSHPort = flags.Int("vultr-ssh-port")
+	d.SetSwarmConfigFromFlags(flags)
+	d.SetIPFamilyFromFlags(flags)
+
+	// The instance only has an IPv6 address once it's enabled
+	d.IPv6 = d.IPv6 || d.UseIPv6
+
+	if d.APIKey == "" {
+		return fmt.Errorf("vultr driver requires the --vultr-api-key option")
+	}
+
+	if d.UsePrivateAddress && len(d.VPCIDs) == 0 {
+		return fmt.Errorf("vultr driver requires --vultr-vpc-ids to use the private address")
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.UserDataFile != "" {
+		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
+			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
+		}
+	}
+
+	client := d.getClient()
+
+	regions, err := client.ListRegions()
+	if err != nil {
+		return err
+	}
+
+	if !hasRegion(regions, d.Region) {
+		return fmt.Errorf("vultr region %q is not valid", d.Region)
+	}
+
+	plans, err := client.ListRegionPlans(d.Region)
+	if err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		if plan == d.Plan {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("vultr plan %q is not available in the region %q", d.Plan, d.Region)
+}
+
+func hasRegion(regions []Region, id string) bool {
+	for _, region := range regions {
+		if region.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Driver) Create() error {
+	return d.CreateContext(context.Background(), func(message string) {
+		log.Info(message)
+	})
+}
+
+// CreateContext creates the instance like Create.  If ctx is canceled before
+// the instance has an IP address, what was already created is removed.
+func (d *Driver) CreateContext(ctx context.Context, progress func(string)) error {
+	var userdata string
+	if d.UserDataFile != "" {
+		buf, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		userdata = base64.StdEncoding.EncodeToString(buf)
+	}
+
+	progress("Creating SSH key...")
+
+	if err := d.createSSHKey(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return d.cancelCreate(err)
+	}
+
+	progress("Creating Vultr instance...")
+
+	createRequest := &InstanceCreateRequest{
+		Region:       d.Region,
+		Plan:         d.Plan,
+		Label:        d.MachineName,
+		Hostname:     d.MachineName,
+		SSHKeyIDs:    []string{d.SSHKeyID},
+		ScriptID:     d.StartupScriptID,
+		ReservedIPv4: d.ReservedIPID,
+		AttachVPC:    d.VPCIDs,
+		EnableIPv6:   d.IPv6,
+		Tags:         d.Tags,
+		UserData:     userdata,
+	}
+	if d.SnapshotID != "" {
+		createRequest.SnapshotID = d.SnapshotID
+	} else {
+		createRequest.OSID = d.OSID
+	}
+
+	instance, err := d.getClient().CreateInstance(createRequest)
+	if err != nil {
+		return err
+	}
+
+	d.InstanceID = instance.ID
+
+	progress("Waiting for IP address to be assigned to the instance...")
+	if err := mcnutils.WaitForSpecificOrError(func() (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		return d.instanceHasAddress(), nil
+	}, 90, 2*time.Second); err != nil {
+		if ctx.Err() != nil {
+			return d.cancelCreate(err)
+		}
+		return fmt.Errorf("vultr instance %s has no IP address: %s", d.InstanceID, err)
+	}
+
+	log.Debugf("Created instance ID %s, IP address %s, private IP address %s",
+		d.InstanceID,
+		d.IPAddress,
+		d.PrivateIPAddress)
+
+	return nil
+}
+
+// cancelCreate removes what the canceled creation already created and returns
+// the error of the cancellation.
+func (d *Driver) cancelCreate(err error) error {
+	log.Info("Creation canceled, removing the SSH key and the instance...")
+	if removeErr := d.Remove(); removeErr != nil {
+		log.Warnf("Error removing the canceled instance: %s", removeErr)
+	}
+
+	return err
+}
+
+// instanceHasAddress refreshes the addresses of the instance and reports
+// whether the one used to reach it is known yet.
+func (d *Driver) instanceHasAddress() bool {
+	client := d.getClient()
+
+	instance, err := client.GetInstance(d.InstanceID)
+	if err != nil {
+		log.Debugf("Error getting instance %s: %s", d.InstanceID, err)
+		return false
+	}
+
+	ipv4 := ""
+	if instance.MainIP != unassignedIP {
+		ipv4 = instance.MainIP
+	}
+
+	// The IPv6 address may be assigned after the IPv4 one
+	if (d.UseIPv6 && instance.V6MainIP == "") || (!d.UseIPv6 && ipv4 == "") {
+		return false
+	}
+	d.IPAddress = d.PreferredIP(ipv4, instance.V6MainIP)
+
+	if !d.UsePrivateAddress {
+		return true
+	}
+
+	vpcs, err := client.ListInstanceVPCs(d.InstanceID)
+	if err != nil {
+		log.Debugf("Error getting the VPCs of instance %s: %s", d.InstanceID, err)
+		return false
+	}
+
+	for _, vpc := range vpcs {
+		if vpc.ID == d.VPCIDs[0] && vpc.IPAddress != "" && vpc.IPAddress != unassignedIP {
+			d.PrivateIPAddress = vpc.IPAddress
+		}
+	}
+
+	return d.PrivateIPAddress != ""
+}
+
+func (d *Driver) createSSHKey() error {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	key, err := d.getClient().CreateSSHKey(d.MachineName, string(publicKey))
+	if err != nil {
+		return err
+	}
+
+	d.SSHKeyID = key.ID
+
+	return nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	if d.UsePrivateAddress && d.PrivateIPAddress != "" {
+		return d.PrivateIPAddress, nil
+	}
+	return d.BaseDriver.GetIP()
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	instance, err := d.getClient().GetInstance(d.InstanceID)
+	if err != nil {
+		return state.Error, err
+	}
+	switch instance.Status {
+	case "pending":
+		return state.Starting, nil
+	case "suspended":
+		return state.Stopped, nil
+	case "active", "resizing":
+		switch instance.PowerStatus {
+		case "running":
+			return state.Running, nil
+		case "stopped":
+			return state.Stopped, nil
+		}
+	}
+	return state.None, nil
+}
+
+func (d *Driver) Start() error {
+	return d.getClient().InstanceAction(d.InstanceID, "start")
+}
+
+// Stop halts the instance, the Vultr API having no graceful shutdown.
+func (d *Driver) Stop() error {
+	return d.getClient().InstanceAction(d.InstanceID, "halt")
+}
+
+func (d *Driver) Restart() error {
+	return d.getClient().InstanceAction(d.InstanceID, "reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.getClient().InstanceAction(d.InstanceID, "halt")
+}
+
+func (d *Driver) Remove() error {
+	client := d.getClient()
+	if d.SSHKeyID != "" {
+		if err := client.DeleteSSHKey(d.SSHKeyID); err != nil {
+			if IsNotFound(err) {
+				log.Infof("Vultr SSH key doesn't exist, assuming it is already deleted")
+			} else {
+				return err
+			}
+		}
+	}
+	if d.InstanceID == "" {
+		return nil
+	}
+	if err := client.DeleteInstance(d.InstanceID); err != nil {
+		if IsNotFound(err) {
+			log.Infof("Vultr instance doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) getClient() *Client {
+	if d.client == nil {
+		d.client = NewClient(d.APIKey)
+	}
+	return d.client
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
@@ -0,0 +1,242 @@
+package vultr
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"vultr-api-key": "KEY",
+			"vultr-vpc-ids": []string{"vpc-1"},
+			"vultr-tags":    []string{"ci"},
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, []string{"vpc-1"}, driver.VPCIDs)
+	assert.Equal(t, defaultPlan, driver.Plan)
+	assert.Equal(t, defaultOSID, driver.OSID)
+	assert.Equal(t, driver.ResolveStorePath("id_rsa"), driver.GetSSHKeyPath())
+}
+
+func TestSetConfigFromFlagsRequiresAPIKey(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestPrivateAddressRequiresVPCs(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"vultr-api-key":             "KEY",
+			"vultr-use-private-address": true,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.Error(t, driver.SetConfigFromFlags(checkFlags))
+}
+
+func TestPreCreateCheckUnavailablePlan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/regions":
+			w.Write([]byte(`{"regions":[{"id":"ewr"},{"id":"fra"}]}`))
+		case "/regions/fra/availability":
+			w.Write([]byte(`{"available_plans":["vc2-1c-1gb"]}`))
+		}
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.Region = "fra"
+	driver.Plan = "vc2-2c-4gb"
+	driver.getClient().Endpoint = server.URL
+
+	assert.EqualError(t, driver.PreCreateCheck(), `vultr plan "vc2-2c-4gb" is not available in the region "fra"`)
+}
+
+func TestCreateFromSnapshot(t *testing.T) {
+	var createRequest map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ssh-keys":
+			w.Write([]byte(`{"ssh_key":{"id":"key-1"}}`))
+		case "/instances":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&createRequest))
+			w.Write([]byte(`{"instance":{"id":"inst-1","main_ip":"0.0.0.0","status":"pending"}}`))
+		case "/instances/inst-1":
+			w.Write([]byte(`{"instance":{"id":"inst-1","main_ip":"1.2.3.4","status":"active","power_status":"running"}}`))
+		}
+	}))
+	defer server.Close()
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.APIKey = "KEY"
+	driver.SnapshotID = "snap-1"
+	driver.StartupScriptID = "script-1"
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Create())
+	assert.Equal(t, "inst-1", driver.InstanceID)
+	assert.Equal(t, "1.2.3.4", driver.IPAddress)
+	assert.Equal(t, "snap-1", createRequest["snapshot_id"])
+	assert.Equal(t, "script-1", createRequest["script_id"])
+	assert.Nil(t, createRequest["os_id"])
+	assert.Equal(t, []interface{}{"key-1"}, createRequest["sshkey_id"])
+}
+
+func TestGetStateAndPrivateIP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer KEY", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/instances/inst-1":
+			w.Write([]byte(`{"instance":{"id":"inst-1","main_ip":"1.2.3.4","status":"active","power_status":"stopped"}}`))
+		case "/instances/inst-1/vpcs":
+			w.Write([]byte(`{"vpcs":[{"id":"vpc-1","ip_address":"10.1.96.3"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.APIKey = "KEY"
+	driver.InstanceID = "inst-1"
+	driver.UsePrivateAddress = true
+	driver.VPCIDs = []string{"vpc-1"}
+	driver.getClient().Endpoint = server.URL
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+
+	assert.True(t, driver.instanceHasAddress())
+	ip, err := driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.1.96.3", ip)
+}
+
+func TestInstanceHasIPv6Address(t *testing.T) {
+	v6 := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"instance":{"id":"inst-1","main_ip":"1.2.3.4","v6_main_ip":"` + v6 + `","status":"active"}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.InstanceID = "inst-1"
+	driver.UseIPv6 = true
+	driver.getClient().Endpoint = server.URL
+
+	assert.False(t, driver.instanceHasAddress())
+
+	v6 = "2001:19f0::1"
+	assert.True(t, driver.instanceHasAddress())
+	assert.Equal(t, "2001:19f0::1", driver.IPAddress)
+}
+
+func TestRemoveIgnoresMissingInstance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"Invalid instance-id.","status":404}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.InstanceID = "inst-1"
+	driver.SSHKeyID = "key-1"
+	driver.getClient().Endpoint = server.URL
+
+	assert.NoError(t, driver.Remove())
+}
+
+func TestListSnapshots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/snapshots", r.URL.Path)
+		w.Write([]byte(`{"snapshots":[{"id":"snap-1","description":"default: clean","date_created":"2026-10-16T08:30:00+00:00","status":"complete"},{"id":"snap-2","description":"other: clean"}],"meta":{"links":{"next":""}}}`))
+	}))
+	defer server.Close()
+
+	driver := NewDriver("default", "path")
+	driver.InstanceID = "inst-1"
+	driver.getClient().Endpoint = server.URL
+
+	snapshots, err := driver.ListSnapshots()
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{
+		{ID: "snap-1", Name: "clean", Created: time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC)},
+	}, snapshots)
+}
+
+func TestCreateContextCanceledRemovesInstance(t *testing.T) {
+	deleted := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		switch r.URL.Path {
+		case "/ssh-keys":
+			w.Write([]byte(`{"ssh_key":{"id":"key-1"}}`))
+		case "/instances":
+			w.Write([]byte(`{"instance":{"id":"inst-1","main_ip":"0.0.0.0","status":"pending"}}`))
+		case "/instances/inst-1":
+			w.Write([]byte(`{"instance":{"id":"inst-1","main_ip":"0.0.0.0","status":"pending"}}`))
+		}
+	}))
+	defer server.Close()
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.APIKey = "KEY"
+	driver.getClient().Endpoint = server.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := []string{}
+	err = driver.CreateContext(ctx, func(message string) {
+		progress = append(progress, message)
+		if message == "Waiting for IP address to be assigned to the instance..." {
+			cancel()
+		}
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"Creating SSH key...", "Creating Vultr instance...", "Waiting for IP address to be assigned to the instance..."}, progress)
+	assert.Equal(t, []string{"/ssh-keys/key-1", "/instances/inst-1"}, deleted)
+}
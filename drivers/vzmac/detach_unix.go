@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package vzmac
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts vfkit in its own session, so that it outlives the command
+// which started the VM and doesn't get its signals.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
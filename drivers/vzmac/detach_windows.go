@@ -0,0 +1,5 @@
+package vzmac
+
+import "os/exec"
+
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,73 @@
+package vzmac
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// dhcpdLeasesPath is where the DHCP server of the vmnet shared network, the
+// NAT of Virtualization.framework, records its leases.
+var dhcpdLeasesPath = "/var/db/dhcpd_leases"
+
+// findLeasedIP returns the address leased to a MAC address, the most recent
+// lease coming first in the file:
+//
+//	{
+//		name=machine
+//		ip_address=192.168.64.3
+//		hw_address=1,52:54:0:ab:c:1
+//		...
+//	}
+func findLeasedIP(r io.Reader, macAddress string) string {
+	mac := normalizeMAC(macAddress)
+
+	var ip, hw string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "{":
+			ip, hw = "", ""
+		case strings.HasPrefix(line, "ip_address="):
+			ip = strings.TrimPrefix(line, "ip_address=")
+		case strings.HasPrefix(line, "hw_address="):
+			hw = strings.TrimPrefix(line, "hw_address=")
+			// the hardware type comes first
+			if i := strings.Index(hw, ","); i >= 0 {
+				hw = hw[i+1:]
+			}
+		case line == "}":
+			if normalizeMAC(hw) == mac {
+				return ip
+			}
+		}
+	}
+
+	return ""
+}
+
+// normalizeMAC strips the leading zeros of the bytes of a MAC address, like
+// the leases do.
+func normalizeMAC(mac string) string {
+	parts := strings.Split(strings.ToLower(mac), ":")
+	for i, part := range parts {
+		if trimmed := strings.TrimLeft(part, "0"); trimmed != "" {
+			parts[i] = trimmed
+		} else {
+			parts[i] = "0"
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+func leasedIP(macAddress string) (string, error) {
+	f, err := os.Open(dhcpdLeasesPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return findLeasedIP(f, macAddress), nil
+}
@@ -0,0 +1,123 @@
+package vzmac
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	ErrVFKitNotFound = errors.New("vfkit not found. Install it with \"brew install vfkit\" or give its path with --vzmac-vfkit-path")
+)
+
+// startVFKit starts vfkit in the background and returns its PID.  It's
+// replaced by the tests.
+var startVFKit = func(path string, args []string, logPath string) (int, error) {
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+
+	log.Debugf("COMMAND: %s %s", path, strings.Join(args, " "))
+	if err := cmd.Start(); err != nil {
+		if ee, ok := err.(*exec.Error); ok && ee.Err == exec.ErrNotFound {
+			return 0, ErrVFKitNotFound
+		}
+		return 0, err
+	}
+
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+// vmState is the state of the VM reported by the REST API of vfkit.
+type vmState struct {
+	State string `json:"state"`
+}
+
+// vfkitClient talks to the REST API vfkit serves on a unix socket.
+type vfkitClient struct {
+	socket string
+	http   *http.Client
+}
+
+func newVFKitClient(socket string) *vfkitClient {
+	return &vfkitClient{
+		socket: socket,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// isRunning returns false if vfkit doesn't listen on the socket, i.e. the
+// VM is stopped.
+func (c *vfkitClient) isRunning() bool {
+	conn, err := net.Dial("unix", c.socket)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// getState returns the state of the VM, e.g. VirtualMachineStateRunning.
+func (c *vfkitClient) getState() (string, error) {
+	resp, err := c.http.Get("http://vfkit/vm/state")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vfkit answered %s", resp.Status)
+	}
+
+	s := &vmState{}
+	if err := json.NewDecoder(resp.Body).Decode(s); err != nil {
+		return "", err
+	}
+	return s.State, nil
+}
+
+// setState asks for a state change: Stop for a shutdown of the guest,
+// HardStop to power the VM off.
+func (c *vfkitClient) setState(state string) error {
+	buf, err := json.Marshal(&vmState{State: state})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Post("http://vfkit/vm/state", "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("vfkit refused the state %s: %s %s", state, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
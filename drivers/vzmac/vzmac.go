@@ -0,0 +1,464 @@
+package vzmac
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/osimage"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	VFKitPath  string
+	OSImage    string
+	DiskSize   int
+	Memory     int
+	CPU        int
+	Shares     []string
+	NoShare    bool
+	MACAddress string
+}
+
+const (
+	defaultVFKitPath = "vfkit"
+	defaultDiskSize  = 20000
+	defaultMemory    = 2048
+	defaultCPU       = 2
+	defaultShare     = "/Users:/Users"
+)
+
+// NewDriver creates a new Virtualization.framework driver with default
+// settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		VFKitPath: defaultVFKitPath,
+		OSImage:   osimage.Alpine,
+		DiskSize:  defaultDiskSize,
+		Memory:    defaultMemory,
+		CPU:       defaultCPU,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+			SSHUser:     "docker",
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			Name:   "vzmac-vfkit-path",
+			Usage:  "Path of vfkit, the helper running the VM with Virtualization.framework",
+			Value:  defaultVFKitPath,
+			EnvVar: "VZMAC_VFKIT_PATH",
+		},
+		mcnflag.StringFlag{
+			Name:   "vzmac-os-image",
+			Usage:  "The OS image the machine boots from: alpine or fcos",
+			Value:  osimage.Alpine,
+			EnvVar: "VZMAC_OS_IMAGE",
+		},
+		mcnflag.IntFlag{
+			Name:   "vzmac-disk-size",
+			Usage:  "Size of the disk for host in MB.",
+			Value:  defaultDiskSize,
+			EnvVar: "VZMAC_DISK_SIZE",
+		},
+		mcnflag.IntFlag{
+			Name:   "vzmac-memory",
+			Usage:  "Memory size for host in MB.",
+			Value:  defaultMemory,
+			EnvVar: "VZMAC_MEMORY",
+		},
+		mcnflag.IntFlag{
+			Name:   "vzmac-cpu-count",
+			Usage:  "number of CPUs for the machine",
+			Value:  defaultCPU,
+			EnvVar: "VZMAC_CPU_COUNT",
+		},
+		mcnflag.StringSliceFlag{
+			Name:   "vzmac-share",
+			Usage:  "Directory of the Mac shared with the machine with virtio-fs, as <host dir>:<guest dir> (default: /Users:/Users)",
+			EnvVar: "VZMAC_SHARES",
+		},
+		mcnflag.BoolFlag{
+			Name:   "vzmac-no-share",
+			Usage:  "Don't share any directory with the machine",
+			EnvVar: "VZMAC_NO_SHARE",
+		},
+	}
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	if drivers.EngineInstallURLFlagSet(flags) {
+		return errors.New("--engine-install-url cannot be used with the vzmac driver")
+	}
+	d.VFKitPath = flags.String("vzmac-vfkit-path")
+	d.OSImage = flags.String("vzmac-os-image")
+	d.DiskSize = flags.Int("vzmac-disk-size")
+	d.Memory = flags.Int("vzmac-memory")
+	d.CPU = flags.Int("vzmac-cpu-count")
+	d.Shares = flags.StringSlice("vzmac-share")
+	d.NoShare = flags.Bool("vzmac-no-share")
+	d.SetSwarmConfigFromFlags(flags)
+
+	if err := osimage.ValidateForDriver(d.DriverName(), d.OSImage); err != nil {
+		return err
+	}
+	d.SSHUser = osimage.SSHUser(d.OSImage)
+
+	if d.NoShare {
+		if len(d.Shares) > 0 {
+			return errors.New("--vzmac-share and --vzmac-no-share are mutually exclusive")
+		}
+	} else if len(d.Shares) == 0 {
+		d.Shares = []string{defaultShare}
+	}
+
+	for _, share := range d.Shares {
+		if _, _, err := parseShare(share); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseShare splits a share in the directories of the host and of the
+// guest, the same path if the latter isn't given.
+func parseShare(share string) (string, string, error) {
+	parts := strings.SplitN(share, ":", 2)
+	host, guest := parts[0], parts[0]
+	if len(parts) == 2 {
+		guest = parts[1]
+	}
+
+	if !filepath.IsAbs(host) || !strings.HasPrefix(guest, "/") {
+		return "", "", fmt.Errorf("invalid share %q, expected <absolute host dir>:<absolute guest dir>", share)
+	}
+
+	return host, guest, nil
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "vzmac"
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	if ip == "" {
+		return "", nil
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	client := d.getClient()
+	if !client.isRunning() {
+		return state.Stopped, nil
+	}
+
+	s, err := client.getState()
+	if err != nil {
+		return state.Error, err
+	}
+
+	switch s {
+	case "VirtualMachineStateRunning":
+		return state.Running, nil
+	case "VirtualMachineStateStarting":
+		return state.Starting, nil
+	case "VirtualMachineStatePaused", "VirtualMachineStatePausing", "VirtualMachineStateResuming":
+		return state.Paused, nil
+	case "VirtualMachineStateStopping":
+		return state.Stopping, nil
+	case "VirtualMachineStateStopped":
+		return state.Stopped, nil
+	case "VirtualMachineStateError":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+// PreCreateCheck checks that the host is a Mac with vfkit, and downloads the
+// OS image.
+func (d *Driver) PreCreateCheck() error {
+	if runtime.GOOS != "darwin" {
+		return errors.New("The vzmac driver requires macOS, Virtualization.framework is only available on Macs")
+	}
+
+	if _, err := exec.LookPath(d.VFKitPath); err != nil {
+		return ErrVFKitNotFound
+	}
+
+	// Downloading the image to cache should be done here to make sure
+	// that a download failure will not leave a machine half created.
+	return osimage.UpdateCache(d.StorePath, d.OSImage)
+}
+
+func (d *Driver) Create() error {
+	if err := osimage.CopyToMachineDir(d.StorePath, d.OSImage, d.MachineName); err != nil {
+		return err
+	}
+
+	log.Infof("Creating SSH key...")
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	if err := osimage.Personalize(d.OSImage, d.ResolveStorePath("boot2docker.iso"), d.publicSSHKeyPath()); err != nil {
+		return err
+	}
+
+	macAddress, err := generateMACAddress()
+	if err != nil {
+		return err
+	}
+	d.MACAddress = macAddress
+
+	log.Infof("Creating disk image...")
+	if err := d.createDisk(); err != nil {
+		return err
+	}
+
+	log.Infof("Starting VM...")
+	return d.Start()
+}
+
+// createDisk creates the sparse raw disk of the machine, holding the tar with
+// the SSH key which is formatted on the first boot.
+func (d *Driver) createDisk() error {
+	tarBuf, err := mcnutils.MakeDiskImage(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	diskPath := d.ResolveStorePath("disk.img")
+	if err := ioutil.WriteFile(diskPath, tarBuf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	return os.Truncate(diskPath, int64(d.DiskSize)<<20)
+}
+
+// vfkitArgs returns the arguments vfkit runs the VM with: it boots the ISO
+// with EFI, has the disk, a NAT network interface and the shares.
+func (d *Driver) vfkitArgs() []string {
+	variableStore := d.ResolveStorePath("efi-variable-store")
+	bootloader := "efi,variable-store=" + variableStore
+	if _, err := os.Stat(variableStore); os.IsNotExist(err) {
+		bootloader += ",create"
+	}
+
+	args := []string{
+		"--cpus", fmt.Sprintf("%d", d.CPU),
+		"--memory", fmt.Sprintf("%d", d.Memory),
+		"--bootloader", bootloader,
+		"--device", "virtio-blk,path=" + d.ResolveStorePath("disk.img"),
+		"--device", "usb-mass-storage,path=" + d.ResolveStorePath("boot2docker.iso") + ",readonly",
+		"--device", "virtio-net,nat,mac=" + d.MACAddress,
+		"--device", "virtio-rng",
+		"--device", "virtio-serial,logFilePath=" + d.ResolveStorePath("console.log"),
+		"--restful-uri", "unix://" + d.socketPath(),
+	}
+
+	for i, share := range d.Shares {
+		host, _, _ := parseShare(share)
+		args = append(args, "--device", fmt.Sprintf("virtio-fs,sharedDir=%s,mountTag=%s", host, shareTag(i)))
+	}
+
+	return args
+}
+
+func shareTag(i int) string {
+	return fmt.Sprintf("share%d", i)
+}
+
+// waitForIP waits until the host has a valid IP
+func (d *Driver) waitForIP() (string, error) {
+	log.Infof("Waiting for host to start...")
+
+	var ip string
+	err := mcnutils.WaitForSpecific(func() bool {
+		ip, _ = d.GetIP()
+		return ip != ""
+	}, 90, 2*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("vzmac machine %s has no IP address: %s", d.MachineName, err)
+	}
+
+	return ip, nil
+}
+
+// waitStopped waits until the host is stopped
+func (d *Driver) waitStopped() error {
+	log.Infof("Waiting for host to stop...")
+
+	return mcnutils.WaitForSpecific(func() bool {
+		s, err := d.GetState()
+		return err == nil && s == state.Stopped
+	}, 60, time.Second)
+}
+
+// Start starts an host
+func (d *Driver) Start() error {
+	// a socket left by a crashed vfkit would be in the way
+	os.Remove(d.socketPath())
+
+	pid, err := startVFKit(d.VFKitPath, d.vfkitArgs(), d.ResolveStorePath("vfkit.log"))
+	if err != nil {
+		return err
+	}
+	log.Debugf("vfkit runs with PID %d", pid)
+
+	ip, err := d.waitForIP()
+	if err != nil {
+		return err
+	}
+
+	d.IPAddress = ip
+
+	return d.mountShares()
+}
+
+// mountShares mounts the shares in the guest, which has no fstab entry for
+// them.
+func (d *Driver) mountShares() error {
+	if len(d.Shares) == 0 {
+		return nil
+	}
+
+	if err := drivers.WaitForSSH(d); err != nil {
+		return err
+	}
+
+	for i, share := range d.Shares {
+		_, guest, _ := parseShare(share)
+
+		log.Debugf("Mounting %s on %s", shareTag(i), guest)
+		command := fmt.Sprintf("sudo mkdir -p %s && sudo mount -t virtiofs %s %s", guest, shareTag(i), guest)
+		if _, err := drivers.RunSSHCommandFromDriver(d, command); err != nil {
+			return fmt.Errorf("Error mounting the share %s: %s", share, err)
+		}
+	}
+
+	return nil
+}
+
+// Stop shuts an host down
+func (d *Driver) Stop() error {
+	if err := d.getClient().setState("Stop"); err != nil {
+		return err
+	}
+
+	if err := d.waitStopped(); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+// Restart stops and starts an host
+func (d *Driver) Restart() error {
+	if err := d.Stop(); err != nil {
+		return err
+	}
+
+	return d.Start()
+}
+
+// Kill force stops an host
+func (d *Driver) Kill() error {
+	if err := d.getClient().setState("HardStop"); err != nil {
+		return err
+	}
+
+	if err := d.waitStopped(); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+// Remove stops the host, whose files are removed with its directory.
+func (d *Driver) Remove() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s != state.Stopped {
+		return d.Kill()
+	}
+
+	return nil
+}
+
+// GetIP returns the address leased to the host by the DHCP server of the NAT
+// network.
+func (d *Driver) GetIP() (string, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return "", err
+	}
+	if s != state.Running {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	return leasedIP(d.MACAddress)
+}
+
+func (d *Driver) getClient() *vfkitClient {
+	return newVFKitClient(d.socketPath())
+}
+
+// socketPath returns the path of the socket of the REST API of vfkit.
+func (d *Driver) socketPath() string {
+	return d.ResolveStorePath("vfkit.sock")
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
+
+// generateMACAddress returns a random locally administered MAC address.
+func generateMACAddress() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	buf[0] = (buf[0] | 0x02) & 0xfe
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", buf[0], buf[1], buf[2], buf[3], buf[4], buf[5]), nil
+}
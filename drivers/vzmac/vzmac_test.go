@@ -0,0 +1,196 @@
+package vzmac
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const leases = `{
+	name=other
+	ip_address=192.168.64.2
+	hw_address=1,52:54:0:12:34:56
+	identifier=1,52:54:0:12:34:56
+	lease=0x65a1b2c3
+}
+{
+	name=default
+	ip_address=192.168.64.3
+	hw_address=1,a6:5:c:0:1:ff
+	identifier=1,a6:5:c:0:1:ff
+	lease=0x65a1b2c4
+}
+`
+
+// fakeVFKit serves the REST API of vfkit on the socket of a driver,
+// recording the state changes.
+type fakeVFKit struct {
+	state   string
+	changes []string
+}
+
+func (f *fakeVFKit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		s := &vmState{}
+		json.NewDecoder(r.Body).Decode(s)
+		f.changes = append(f.changes, s.State)
+		f.state = "VirtualMachineStateStopped"
+		return
+	}
+	json.NewEncoder(w).Encode(&vmState{State: f.state})
+}
+
+func newTestDriver(t *testing.T) (*Driver, func()) {
+	storePath, err := ioutil.TempDir("", "vzmac-")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700))
+
+	driver := NewDriver("default", storePath)
+	driver.Shares = []string{defaultShare}
+
+	return driver, func() { os.RemoveAll(storePath) }
+}
+
+func serveVFKit(t *testing.T, driver *Driver, vfkit *fakeVFKit) func() {
+	l, err := net.Listen("unix", driver.socketPath())
+	assert.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(vfkit)
+	server.Listener = l
+	server.Start()
+
+	return server.Close
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"vzmac-memory": 4096,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, 4096, driver.Memory)
+	assert.Equal(t, "alpine", driver.OSImage)
+	assert.Equal(t, []string{"/Users:/Users"}, driver.Shares)
+	assert.Equal(t, "docker", driver.GetSSHUsername())
+}
+
+func TestSetConfigFromFlagsInvalid(t *testing.T) {
+	for _, values := range []map[string]interface{}{
+		{"vzmac-os-image": "boot2docker"},
+		{"vzmac-share": []string{"src:/src"}},
+		{"vzmac-share": []string{"/src"}, "vzmac-no-share": true},
+	} {
+		driver := NewDriver("default", "path")
+		checkFlags := &drivers.CheckDriverOptions{
+			FlagsValues: values,
+			CreateFlags: driver.GetCreateFlags(),
+		}
+
+		assert.Error(t, driver.SetConfigFromFlags(checkFlags), "%v", values)
+	}
+}
+
+func TestParseShare(t *testing.T) {
+	host, guest, err := parseShare("/Users/me/src:/src")
+	assert.NoError(t, err)
+	assert.Equal(t, "/Users/me/src", host)
+	assert.Equal(t, "/src", guest)
+
+	host, guest, err = parseShare("/Volumes/data")
+	assert.NoError(t, err)
+	assert.Equal(t, "/Volumes/data", host)
+	assert.Equal(t, "/Volumes/data", guest)
+}
+
+func TestVFKitArgs(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	driver.MACAddress = "a6:05:0c:00:01:ff"
+
+	args := strings.Join(driver.vfkitArgs(), " ")
+	assert.Contains(t, args, "--cpus 2 --memory 2048")
+	assert.Contains(t, args, "--bootloader efi,variable-store="+driver.ResolveStorePath("efi-variable-store")+",create")
+	assert.Contains(t, args, "--device virtio-net,nat,mac=a6:05:0c:00:01:ff")
+	assert.Contains(t, args, "--device virtio-fs,sharedDir=/Users,mountTag=share0")
+	assert.Contains(t, args, "--restful-uri unix://"+driver.socketPath())
+
+	assert.NoError(t, ioutil.WriteFile(driver.ResolveStorePath("efi-variable-store"), nil, 0600))
+	assert.NotContains(t, strings.Join(driver.vfkitArgs(), " "), ",create")
+}
+
+func TestFindLeasedIP(t *testing.T) {
+	assert.Equal(t, "192.168.64.3", findLeasedIP(strings.NewReader(leases), "a6:05:0c:00:01:ff"))
+	assert.Equal(t, "192.168.64.2", findLeasedIP(strings.NewReader(leases), "52:54:00:12:34:56"))
+	assert.Equal(t, "", findLeasedIP(strings.NewReader(leases), "52:54:00:12:34:57"))
+}
+
+func TestGetState(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	s, err := driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+
+	defer serveVFKit(t, driver, &fakeVFKit{state: "VirtualMachineStateRunning"})()
+
+	s, err = driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+}
+
+func TestGetIP(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	leasesPath := driver.ResolveStorePath("dhcpd_leases")
+	assert.NoError(t, ioutil.WriteFile(leasesPath, []byte(leases), 0644))
+	dhcpdLeasesPath = leasesPath
+	defer func() { dhcpdLeasesPath = "/var/db/dhcpd_leases" }()
+
+	driver.MACAddress = "a6:05:0c:00:01:ff"
+	defer serveVFKit(t, driver, &fakeVFKit{state: "VirtualMachineStateRunning"})()
+
+	ip, err := driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.64.3", ip)
+}
+
+func TestKill(t *testing.T) {
+	driver, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	vfkit := &fakeVFKit{state: "VirtualMachineStateRunning"}
+	defer serveVFKit(t, driver, vfkit)()
+
+	assert.NoError(t, driver.Kill())
+	assert.Equal(t, []string{"HardStop"}, vfkit.changes)
+}
+
+func TestGenerateMACAddress(t *testing.T) {
+	mac, err := generateMACAddress()
+	assert.NoError(t, err)
+
+	hw, err := net.ParseMAC(mac)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x02), hw[0]&0x03)
+}
@@ -1,5 +1,7 @@
 package auth
 
+import "time"
+
 type Options struct {
 	CertDir              string
 	CaCertPath           string
@@ -12,6 +14,10 @@ type Options struct {
 	ServerKeyRemotePath  string
 	ClientCertPath       string
 	ServerCertSANs       []string
+	// RotateInterval is how often the server certificate is rotated, set
+	// with regenerate-certs --rotate-interval.  The certificate is also
+	// rotated before it expires.
+	RotateInterval time.Duration `json:",omitempty"`
 	// StorePath is left in for historical reasons, but not really meant to
 	// be used directly.
 	StorePath string
@@ -0,0 +1,94 @@
+// Package autosize picks the CPU count, memory and disk size of the machines
+// of the local drivers from the resources of the host, so that they aren't
+// left with the defaults of 1 CPU and 1 GB of memory.
+package autosize
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// The machine is given half of the CPUs and of the available memory of the
+// host, and a quarter of its free disk, within the caps below.  It never
+// gets less than the defaults of the drivers.
+const (
+	minCPU      = 1
+	maxCPU      = 8
+	minMemory   = 1024
+	maxMemory   = 16384
+	memoryStep  = 512
+	minDiskSize = 20000
+	maxDiskSize = 100000
+)
+
+// Host is the resources of the host, the memory and the disk in MB.
+type Host struct {
+	CPUs       int
+	FreeMemory int
+	FreeDisk   int
+}
+
+// Sizes are the CPU count, memory and disk size of a machine, in MB.
+type Sizes struct {
+	CPU      int
+	Memory   int
+	DiskSize int
+}
+
+func (s Sizes) String() string {
+	return fmt.Sprintf("%d CPUs, %d MB of memory and %d MB of disk", s.CPU, s.Memory, s.DiskSize)
+}
+
+// Sizes returns the sizes picked for a machine of the host.
+func (h Host) Sizes() Sizes {
+	return Sizes{
+		CPU:      clamp(h.CPUs/2, minCPU, maxCPU),
+		Memory:   clamp(h.FreeMemory/2/memoryStep*memoryStep, minMemory, maxMemory),
+		DiskSize: clamp(h.FreeDisk/4/1000*1000, minDiskSize, maxDiskSize),
+	}
+}
+
+// Fill returns the sizes with the ones left at their defaults replaced by
+// the picked ones, so that the sizes given explicitly are kept.
+func (s Sizes) Fill(defaults, picked Sizes) Sizes {
+	if s.CPU == defaults.CPU {
+		s.CPU = picked.CPU
+	}
+	if s.Memory == defaults.Memory {
+		s.Memory = picked.Memory
+	}
+	if s.DiskSize == defaults.DiskSize {
+		s.DiskSize = picked.DiskSize
+	}
+	return s
+}
+
+// Detect returns the resources of the host, with the free disk of the
+// filesystem of the given directory.
+func Detect(dir string) (Host, error) {
+	freeMemory, err := freeMemory()
+	if err != nil {
+		return Host{}, fmt.Errorf("Error reading the free memory of the host: %s", err)
+	}
+
+	freeDisk, err := freeDisk(dir)
+	if err != nil {
+		return Host{}, fmt.Errorf("Error reading the free disk of %s: %s", dir, err)
+	}
+
+	return Host{
+		CPUs:       runtime.NumCPU(),
+		FreeMemory: int(freeMemory >> 20),
+		FreeDisk:   int(freeDisk >> 20),
+	}, nil
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
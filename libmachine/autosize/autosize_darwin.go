@@ -0,0 +1,49 @@
+package autosize
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"syscall"
+)
+
+var (
+	vmStatPageSizeRegexp = regexp.MustCompile(`page size of (\d+) bytes`)
+	vmStatPagesRegexp    = regexp.MustCompile(`Pages (free|inactive|speculative|purgeable):\s+(\d+)`)
+)
+
+// freeMemory returns the memory that can be given to new processes, in
+// bytes: the free, inactive, speculative and purgeable pages of vm_stat.
+func freeMemory() (uint64, error) {
+	output, err := exec.Command("vm_stat").Output()
+	if err != nil {
+		return 0, err
+	}
+
+	return parseVMStat(string(output))
+}
+
+func parseVMStat(output string) (uint64, error) {
+	match := vmStatPageSizeRegexp.FindStringSubmatch(output)
+	if match == nil {
+		return 0, errors.New("page size not found in the output of vm_stat")
+	}
+	pageSize, _ := strconv.ParseUint(match[1], 10, 64)
+
+	pages := uint64(0)
+	for _, match := range vmStatPagesRegexp.FindAllStringSubmatch(output, -1) {
+		n, _ := strconv.ParseUint(match[2], 10, 64)
+		pages += n
+	}
+
+	return pages * pageSize, nil
+}
+
+func freeDisk(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
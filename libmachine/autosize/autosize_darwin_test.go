@@ -0,0 +1,22 @@
+package autosize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVMStat(t *testing.T) {
+	free, err := parseVMStat(`Mach Virtual Memory Statistics: (page size of 16384 bytes)
+Pages free:                               10000.
+Pages active:                            500000.
+Pages inactive:                           20000.
+Pages speculative:                         3000.
+Pages throttled:                              0.
+Pages wired down:                        100000.
+Pages purgeable:                           1000.
+`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(34000*16384), free)
+}
@@ -0,0 +1,18 @@
+package autosize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMeminfo(t *testing.T) {
+	free, err := parseMeminfo(strings.NewReader("MemTotal:       32768000 kB\nMemFree:         1024000 kB\nMemAvailable:   16384000 kB\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(16384000)<<10, free)
+
+	_, err = parseMeminfo(strings.NewReader("MemTotal:       32768000 kB\n"))
+	assert.Error(t, err)
+}
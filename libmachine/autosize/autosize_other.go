@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package autosize
+
+import "errors"
+
+var errNotSupported = errors.New("not supported on this platform")
+
+func freeMemory() (uint64, error) {
+	return 0, errNotSupported
+}
+
+func freeDisk(dir string) (uint64, error) {
+	return 0, errNotSupported
+}
@@ -0,0 +1,21 @@
+package autosize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizes(t *testing.T) {
+	assert.Equal(t, Sizes{CPU: 4, Memory: 7680, DiskSize: 60000}, Host{CPUs: 8, FreeMemory: 15600, FreeDisk: 241000}.Sizes())
+	assert.Equal(t, Sizes{CPU: 1, Memory: 1024, DiskSize: 20000}, Host{CPUs: 1, FreeMemory: 1500, FreeDisk: 30000}.Sizes())
+	assert.Equal(t, Sizes{CPU: 8, Memory: 16384, DiskSize: 100000}, Host{CPUs: 64, FreeMemory: 250000, FreeDisk: 2000000}.Sizes())
+}
+
+func TestFill(t *testing.T) {
+	defaults := Sizes{CPU: 1, Memory: 1024, DiskSize: 20000}
+	picked := Sizes{CPU: 4, Memory: 8192, DiskSize: 60000}
+
+	assert.Equal(t, picked, defaults.Fill(defaults, picked))
+	assert.Equal(t, Sizes{CPU: 2, Memory: 8192, DiskSize: 40000}, Sizes{CPU: 2, Memory: 1024, DiskSize: 40000}.Fill(defaults, picked))
+}
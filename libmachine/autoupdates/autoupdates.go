@@ -0,0 +1,315 @@
+package autoupdates
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+// The security updates are applied by the tool of the distribution:
+// unattended-upgrades, dnf-automatic, or a periodic job of apk on Alpine,
+// whose stable branches only receive security and bug fixes.  The packages
+// of the engine are excluded, so that the engine and its containers aren't
+// restarted at random times: they're upgraded with docker-machine upgrade.
+// The machine reboots in its reboot window when the updates require it,
+// once the engine is stopped.
+
+const (
+	familyDebian = "debian"
+	familyRedHat = "redhat"
+	familyAlpine = "alpine"
+
+	aptConfFile       = "/etc/apt/apt.conf.d/52docker-machine-auto-upgrades"
+	dnfAutomaticFile  = "/etc/dnf/automatic.conf"
+	apkPeriodicScript = "/etc/periodic/daily/docker-machine-upgrades"
+	apkCrontab        = "/etc/crontabs/root"
+	rebootScript      = "/usr/local/sbin/docker-machine-reboot"
+	rebootUnit        = "docker-machine-reboot"
+	rebootCronFile    = "/etc/cron.d/docker-machine-reboot"
+)
+
+// enginePackages are the packages of the engine and its runtime, excluded
+// from the automatic updates.
+var enginePackages = []string{
+	"docker-ce",
+	"docker-ce-cli",
+	"docker-ce-rootless-extras",
+	"docker-buildx-plugin",
+	"docker-compose-plugin",
+	"docker.io",
+	"containerd.io",
+	"containerd",
+	"runc",
+}
+
+// The configuration is read after 50unattended-upgrades, whose origins are
+// cleared so that only the security archives are upgraded.
+const aptConfTemplate = `APT::Periodic::Update-Package-Lists "1";
+APT::Periodic::Unattended-Upgrade "1";
+#clear Unattended-Upgrade::Allowed-Origins;
+#clear Unattended-Upgrade::Origins-Pattern;
+Unattended-Upgrade::Origins-Pattern {
+	"origin=${distro_id},archive=${distro_codename}-security";
+	"origin=Debian,codename=${distro_codename}-security,label=Debian-Security";
+};
+Unattended-Upgrade::Package-Blacklist {
+%s};
+Unattended-Upgrade::Automatic-Reboot "false";
+`
+
+const dnfAutomaticTemplate = `[commands]
+upgrade_type = security
+random_sleep = 0
+download_updates = yes
+apply_updates = yes
+
+[emitters]
+emit_via = stdio
+
+[base]
+exclude = %s
+`
+
+const apkPeriodicTemplate = `#!/bin/sh
+# Upgrades the packages of the machine but the ones of the engine
+apk update -q || exit 1
+packages=$(apk version -q -l "<" | sed -e "s/-[0-9].*//" | grep -vE "^(docker|containerd|runc)")
+[ -n "$packages" ] || exit 0
+apk add -q -u $packages || exit 1
+echo "$packages" | grep -q "^linux-" && touch /run/reboot-required
+exit 0
+`
+
+// The engine is stopped before the reboot, so that the containers are
+// given their stop timeout.
+const rebootScriptContent = `#!/bin/sh
+# Reboots the machine when its updates require it
+if [ -e /run/reboot-required ] || { command -v needs-restarting >/dev/null && ! needs-restarting -r >/dev/null; }; then
+	systemctl stop docker 2>/dev/null || rc-service docker stop
+	reboot
+fi
+`
+
+const rebootServiceTemplate = `[Unit]
+Description=Reboot of Docker Machine after the security updates
+
+[Service]
+Type=oneshot
+ExecStart=` + rebootScript + `
+`
+
+const rebootTimerTemplate = `[Unit]
+Description=Reboot window of Docker Machine
+
+[Timer]
+OnCalendar=%s
+
+[Install]
+WantedBy=timers.target
+`
+
+var (
+	ErrInvalidRebootWindow = errors.New(`Error: the reboot window must be a time of the day, e.g. "03:00", or of a day of the week, e.g. "Sun 03:00"`)
+	ErrNotSupported        = errors.New("Error: the automatic updates can only be configured on Debian, Ubuntu, Red Hat Enterprise Linux, Fedora and Alpine machines")
+
+	rebootWindowRegexp = regexp.MustCompile(`^(?:(Mon|Tue|Wed|Thu|Fri|Sat|Sun) )?([01]?[0-9]|2[0-3]):([0-5][0-9])$`)
+	cronDays           = map[string]int{"Sun": 0, "Mon": 1, "Tue": 2, "Wed": 3, "Thu": 4, "Fri": 5, "Sat": 6}
+)
+
+type Options struct {
+	// RebootWindow is when the machine reboots if its updates require it,
+	// every day, e.g. 03:00, or on a day of the week, e.g. Sun 03:00.  No
+	// reboot is scheduled without a window.
+	RebootWindow string `json:",omitempty"`
+}
+
+// IsEnabled returns true if the security updates of the machine are applied
+// automatically.
+func (o *Options) IsEnabled() bool {
+	return o != nil
+}
+
+// Validate checks the options given on the command line.
+func (o *Options) Validate() error {
+	if o.RebootWindow == "" {
+		return nil
+	}
+
+	_, _, _, err := parseRebootWindow(o.RebootWindow)
+	return err
+}
+
+// parseRebootWindow returns the day of the week of the window, if any, and
+// its time.
+func parseRebootWindow(window string) (string, int, int, error) {
+	match := rebootWindowRegexp.FindStringSubmatch(window)
+	if match == nil {
+		return "", 0, 0, ErrInvalidRebootWindow
+	}
+
+	hour, _ := strconv.Atoi(match[2])
+	minute, _ := strconv.Atoi(match[3])
+	return match[1], hour, minute, nil
+}
+
+// calendar returns the window as a calendar event of systemd.
+func calendar(day string, hour, minute int) string {
+	if day == "" {
+		return fmt.Sprintf("*-*-* %02d:%02d:00", hour, minute)
+	}
+	return fmt.Sprintf("%s *-*-* %02d:%02d:00", day, hour, minute)
+}
+
+// cronSchedule returns the window as the schedule of a cron job.
+func cronSchedule(day string, hour, minute int) string {
+	if day == "" {
+		return fmt.Sprintf("%d %d * * *", minute, hour)
+	}
+	return fmt.Sprintf("%d %d * * %d", minute, hour, cronDays[day])
+}
+
+// family returns the family of the distribution of the machine, whose tool
+// applies the updates.
+func family(info *provision.OsRelease) string {
+	if info == nil {
+		return ""
+	}
+
+	ids := append([]string{info.ID}, strings.Fields(info.IDLike)...)
+	for _, id := range ids {
+		switch id {
+		case "debian", "ubuntu":
+			return familyDebian
+		case "rhel", "centos", "fedora", "rocky", "almalinux", "ol":
+			return familyRedHat
+		case "alpine":
+			return familyAlpine
+		}
+	}
+	return ""
+}
+
+// Configure makes the machine apply its security updates automatically, and
+// reboot in its reboot window when they require it.
+func Configure(p provision.Provisioner, options *Options) error {
+	if !options.IsEnabled() {
+		return nil
+	}
+
+	info, err := p.GetOsReleaseInfo()
+	if err != nil {
+		return err
+	}
+
+	var commands []string
+	switch family(info) {
+	case familyDebian:
+		if err := install(p, "unattended-upgrades"); err != nil {
+			return err
+		}
+		commands = debianCommands()
+	case familyRedHat:
+		if err := install(p, "dnf-automatic"); err != nil {
+			return err
+		}
+		if options.RebootWindow != "" {
+			// needs-restarting tells if the updates require a reboot
+			if err := install(p, "dnf-utils"); err != nil {
+				return err
+			}
+		}
+		commands = redHatCommands()
+	case familyAlpine:
+		commands = alpineCommands()
+	default:
+		return ErrNotSupported
+	}
+
+	if options.RebootWindow != "" {
+		day, hour, minute, err := parseRebootWindow(options.RebootWindow)
+		if err != nil {
+			return err
+		}
+
+		_, err = p.SSHCommand("test -d /run/systemd/system")
+		commands = append(commands, rebootCommands(day, hour, minute, err == nil, family(info) == familyAlpine)...)
+	}
+
+	log.Infof("Configuring the automatic security updates...")
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error configuring the automatic updates: %s\n%s", err, output)
+		}
+	}
+
+	return nil
+}
+
+func install(p provision.Provisioner, pkg string) error {
+	log.Infof("Installing %s...", pkg)
+	if err := p.Package(pkg, pkgaction.Install); err != nil {
+		return fmt.Errorf("Error installing %s: %s", pkg, err)
+	}
+	return nil
+}
+
+// writeFile returns the command writing a file, with no single quote.
+func writeFile(content, path string) string {
+	return fmt.Sprintf("printf %%s '%s' | sudo tee %s >/dev/null", content, path)
+}
+
+func debianCommands() []string {
+	blacklist := ""
+	for _, pkg := range enginePackages {
+		blacklist += fmt.Sprintf("\t\"^%s$\";\n", pkg)
+	}
+
+	return []string{
+		writeFile(fmt.Sprintf(aptConfTemplate, blacklist), aptConfFile),
+		"sudo systemctl enable --now apt-daily.timer apt-daily-upgrade.timer",
+	}
+}
+
+func redHatCommands() []string {
+	return []string{
+		writeFile(fmt.Sprintf(dnfAutomaticTemplate, strings.Join(enginePackages, " ")), dnfAutomaticFile),
+		"sudo systemctl enable --now dnf-automatic.timer",
+	}
+}
+
+func alpineCommands() []string {
+	return []string{
+		"sudo mkdir -p /etc/periodic/daily && " + writeFile(apkPeriodicTemplate, apkPeriodicScript) + " && sudo chmod 755 " + apkPeriodicScript,
+		"sudo rc-update add crond default && (sudo rc-service crond status >/dev/null || sudo rc-service crond start)",
+	}
+}
+
+// rebootCommands schedule the reboot in the window, with a timer of systemd
+// or else a cron job.
+func rebootCommands(day string, hour, minute int, systemd, alpine bool) []string {
+	commands := []string{
+		"sudo mkdir -p /usr/local/sbin && " + writeFile(rebootScriptContent, rebootScript) + " && sudo chmod 755 " + rebootScript,
+	}
+
+	switch {
+	case systemd:
+		commands = append(commands,
+			writeFile(rebootServiceTemplate, "/etc/systemd/system/"+rebootUnit+".service"),
+			writeFile(fmt.Sprintf(rebootTimerTemplate, calendar(day, hour, minute)), "/etc/systemd/system/"+rebootUnit+".timer"),
+			fmt.Sprintf("sudo systemctl daemon-reload && sudo systemctl enable %[1]s.timer && sudo systemctl restart %[1]s.timer", rebootUnit),
+		)
+	case alpine:
+		commands = append(commands, fmt.Sprintf("sudo sed -i '/%s/d' %s && echo '%s %s' | sudo tee -a %[2]s >/dev/null",
+			rebootUnit, apkCrontab, cronSchedule(day, hour, minute), rebootScript))
+	default:
+		commands = append(commands, writeFile(fmt.Sprintf("%s root %s\n", cronSchedule(day, hour, minute), rebootScript), rebootCronFile))
+	}
+
+	return commands
+}
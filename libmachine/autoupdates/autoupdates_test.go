@@ -0,0 +1,130 @@
+package autoupdates
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvisioner records the commands and the installed packages of a
+// machine, with or without systemd.
+type fakeProvisioner struct {
+	provision.FakeProvisioner
+	info      *provision.OsRelease
+	noSystemd bool
+	commands  []string
+	packages  []string
+}
+
+func (p *fakeProvisioner) GetOsReleaseInfo() (*provision.OsRelease, error) {
+	return p.info, nil
+}
+
+func (p *fakeProvisioner) SSHCommand(args string) (string, error) {
+	if args == "test -d /run/systemd/system" {
+		if p.noSystemd {
+			return "", errors.New("exit status 1")
+		}
+		return "", nil
+	}
+
+	p.commands = append(p.commands, args)
+	return "", nil
+}
+
+func (p *fakeProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	p.packages = append(p.packages, name)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Options{}).Validate())
+	assert.NoError(t, (&Options{RebootWindow: "03:00"}).Validate())
+	assert.NoError(t, (&Options{RebootWindow: "Sun 23:30"}).Validate())
+	assert.Equal(t, ErrInvalidRebootWindow, (&Options{RebootWindow: "24:00"}).Validate())
+	assert.Equal(t, ErrInvalidRebootWindow, (&Options{RebootWindow: "Sunday 03:00"}).Validate())
+	assert.Equal(t, ErrInvalidRebootWindow, (&Options{RebootWindow: "3am"}).Validate())
+}
+
+func TestSchedules(t *testing.T) {
+	day, hour, minute, err := parseRebootWindow("Sun 3:05")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Sun *-*-* 03:05:00", calendar(day, hour, minute))
+	assert.Equal(t, "5 3 * * 0", cronSchedule(day, hour, minute))
+	assert.Equal(t, "*-*-* 03:05:00", calendar("", hour, minute))
+	assert.Equal(t, "5 3 * * *", cronSchedule("", hour, minute))
+}
+
+func TestFamily(t *testing.T) {
+	assert.Equal(t, familyDebian, family(&provision.OsRelease{ID: "ubuntu"}))
+	assert.Equal(t, familyDebian, family(&provision.OsRelease{ID: "raspbian", IDLike: "debian"}))
+	assert.Equal(t, familyRedHat, family(&provision.OsRelease{ID: "rocky", IDLike: "rhel centos fedora"}))
+	assert.Equal(t, familyAlpine, family(&provision.OsRelease{ID: "alpine"}))
+	assert.Equal(t, "", family(&provision.OsRelease{ID: "arch"}))
+	assert.Equal(t, "", family(nil))
+}
+
+func TestConfigureDebian(t *testing.T) {
+	p := &fakeProvisioner{info: &provision.OsRelease{ID: "ubuntu"}}
+
+	assert.NoError(t, Configure(p, &Options{RebootWindow: "Sun 03:00"}))
+	assert.Equal(t, []string{"unattended-upgrades"}, p.packages)
+	assert.Contains(t, p.commands[0], `"origin=${distro_id},archive=${distro_codename}-security";`)
+	assert.Contains(t, p.commands[0], "\t\"^docker-ce$\";\n")
+	assert.Contains(t, p.commands[0], "sudo tee /etc/apt/apt.conf.d/52docker-machine-auto-upgrades")
+	assert.Contains(t, p.commands[4], "OnCalendar=Sun *-*-* 03:00:00")
+	assert.Equal(t, "sudo systemctl daemon-reload && sudo systemctl enable docker-machine-reboot.timer && sudo systemctl restart docker-machine-reboot.timer", p.commands[5])
+}
+
+func TestContentsWithoutQuotes(t *testing.T) {
+	for _, content := range []string{aptConfTemplate, dnfAutomaticTemplate, apkPeriodicTemplate, rebootScriptContent, rebootServiceTemplate, rebootTimerTemplate} {
+		assert.NotContains(t, content, "'")
+	}
+}
+
+func TestConfigureRedHat(t *testing.T) {
+	p := &fakeProvisioner{info: &provision.OsRelease{ID: "almalinux", IDLike: "rhel centos fedora"}}
+
+	assert.NoError(t, Configure(p, &Options{}))
+	assert.Equal(t, []string{"dnf-automatic"}, p.packages)
+	assert.Contains(t, p.commands[0], "upgrade_type = security\n")
+	assert.Contains(t, p.commands[0], "exclude = docker-ce docker-ce-cli")
+	assert.Equal(t, "sudo systemctl enable --now dnf-automatic.timer", p.commands[1])
+	assert.Len(t, p.commands, 2)
+}
+
+func TestConfigureRedHatRebootWithoutSystemd(t *testing.T) {
+	p := &fakeProvisioner{info: &provision.OsRelease{ID: "centos"}, noSystemd: true}
+
+	assert.NoError(t, Configure(p, &Options{RebootWindow: "04:30"}))
+	assert.Equal(t, []string{"dnf-automatic", "dnf-utils"}, p.packages)
+	assert.Contains(t, p.commands[3], "30 4 * * * root /usr/local/sbin/docker-machine-reboot\n")
+	assert.Contains(t, p.commands[3], "sudo tee /etc/cron.d/docker-machine-reboot")
+}
+
+func TestConfigureAlpine(t *testing.T) {
+	p := &fakeProvisioner{info: &provision.OsRelease{ID: "alpine"}, noSystemd: true}
+
+	assert.NoError(t, Configure(p, &Options{RebootWindow: "Sat 02:00"}))
+	assert.Empty(t, p.packages)
+	assert.Contains(t, p.commands[0], "sudo tee /etc/periodic/daily/docker-machine-upgrades")
+	assert.Equal(t, "sudo sed -i '/docker-machine-reboot/d' /etc/crontabs/root && echo '0 2 * * 6 /usr/local/sbin/docker-machine-reboot' | sudo tee -a /etc/crontabs/root >/dev/null", p.commands[3])
+}
+
+func TestConfigureNotSupported(t *testing.T) {
+	p := &fakeProvisioner{info: &provision.OsRelease{ID: "arch"}}
+
+	assert.Equal(t, ErrNotSupported, Configure(p, &Options{}))
+	assert.Empty(t, p.commands)
+}
+
+func TestConfigureDisabled(t *testing.T) {
+	p := &fakeProvisioner{}
+
+	assert.NoError(t, Configure(p, nil))
+	assert.Empty(t, p.commands)
+}
@@ -163,7 +163,7 @@ func (xcg *X509CertGenerator) GenerateCert(opts *Options) error {
 		return err
 	}
 	// client
-	if len(opts.Hosts) == 1 && opts.Hosts[0] == "" {
+	if isClientCert(opts) {
 		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
 		template.KeyUsage = x509.KeyUsageDigitalSignature
 	} else { // server
@@ -174,13 +174,7 @@ func (xcg *X509CertGenerator) GenerateCert(opts *Options) error {
 			// nodes as a client.
 			template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
 		}
-		for _, h := range opts.Hosts {
-			if ip := net.ParseIP(h); ip != nil {
-				template.IPAddresses = append(template.IPAddresses, ip)
-			} else {
-				template.DNSNames = append(template.DNSNames, h)
-			}
-		}
+		template.IPAddresses, template.DNSNames = splitHosts(opts.Hosts)
 	}
 
 	tlsCert, err := tls.LoadX509KeyPair(opts.CAFile, opts.CAKeyFile)
@@ -0,0 +1,65 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"time"
+)
+
+// RotationWindow is how long before they expire the server certificates are
+// rotated.
+const RotationWindow = 30 * 24 * time.Hour
+
+// ReadCertificateValidity returns the period a PEM encoded certificate is
+// valid for.
+func ReadCertificateValidity(certFile string) (notBefore, notAfter time.Time, err error) {
+	cert, err := readCertificate(certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return cert.NotBefore, cert.NotAfter, nil
+}
+
+// CertificateCoversHost tells whether a PEM encoded certificate is valid for
+// the IP address or name of a host.
+func CertificateCoversHost(certFile, host string) (bool, error) {
+	cert, err := readCertificate(certFile)
+	if err != nil {
+		return false, err
+	}
+
+	return cert.VerifyHostname(host) == nil, nil
+}
+
+func readCertificate(certFile string) (*x509.Certificate, error) {
+	content, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("No certificate found in " + certFile)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// NeedsRotation tells whether a certificate expires within the
+// RotationWindow, or was issued more than interval ago if interval isn't
+// zero.
+func NeedsRotation(certFile string, interval time.Duration, now time.Time) (bool, error) {
+	notBefore, notAfter, err := ReadCertificateValidity(certFile)
+	if err != nil {
+		return false, err
+	}
+
+	if now.Add(RotationWindow).After(notAfter) {
+		return true, nil
+	}
+
+	return interval > 0 && now.Sub(notBefore) >= interval, nil
+}
@@ -0,0 +1,82 @@
+package cert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeedsRotation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	certPath := filepath.Join(tmpDir, "ca.pem")
+	if err := GenerateCACertificate(certPath, filepath.Join(tmpDir, "ca-key.pem"), "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	notBefore, notAfter, err := ReadCertificateValidity(certPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1080*24*time.Hour, notAfter.Sub(notBefore))
+
+	due, err := NeedsRotation(certPath, 0, notBefore.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, due)
+
+	due, err = NeedsRotation(certPath, 0, notAfter.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, due)
+
+	due, err = NeedsRotation(certPath, 720*time.Hour, notBefore.Add(719*time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, due)
+
+	due, err = NeedsRotation(certPath, 720*time.Hour, notBefore.Add(720*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, due)
+
+	_, err = NeedsRotation(filepath.Join(tmpDir, "missing.pem"), 0, time.Now())
+	assert.Error(t, err)
+}
+
+func TestCertificateCoversHost(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "ca-key.pem")
+	if err := GenerateCACertificate(caCertPath, caKeyPath, "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(tmpDir, "server.pem")
+	if err := GenerateCert(&Options{
+		Hosts:     []string{"192.168.99.100", "default", "localhost"},
+		CertFile:  certPath,
+		KeyFile:   filepath.Join(tmpDir, "server-key.pem"),
+		CAFile:    caCertPath,
+		CAKeyFile: caKeyPath,
+		Org:       "test-org",
+		Bits:      2048,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	covered, err := CertificateCoversHost(certPath, "192.168.99.100")
+	assert.NoError(t, err)
+	assert.True(t, covered)
+
+	covered, err = CertificateCoversHost(certPath, "192.168.99.101")
+	assert.NoError(t, err)
+	assert.False(t, covered)
+}
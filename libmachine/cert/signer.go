@@ -0,0 +1,124 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// Signer signs the certificates of Machine with an external certificate
+// authority, e.g. the PKI secrets engine of Vault, instead of the CA
+// generated in the certificates directory.
+type Signer interface {
+	// CACertificate returns the PEM encoded certificate of the CA
+	CACertificate() ([]byte, error)
+
+	// Sign signs a PEM encoded certificate request and returns the PEM
+	// encoded certificate
+	Sign(csr []byte, req *SignRequest) ([]byte, error)
+}
+
+// SignRequest describes the certificate a Signer is asked to sign.
+type SignRequest struct {
+	CommonName  string
+	DNSNames    []string
+	IPAddresses []net.IP
+	Client      bool
+}
+
+// SignerCertGenerator generates the private keys of the certificates locally
+// and has the certificates signed by a Signer.  The private key of the CA
+// never leaves the signer, so it isn't written to the certificates directory.
+type SignerCertGenerator struct {
+	X509CertGenerator
+	Signer Signer
+}
+
+func NewSignerCertGenerator(signer Signer) Generator {
+	return &SignerCertGenerator{
+		Signer: signer,
+	}
+}
+
+// GenerateCACertificate writes the certificate of the CA of the signer.
+func (scg *SignerCertGenerator) GenerateCACertificate(certFile, keyFile, org string, bits int) error {
+	caCert, err := scg.Signer.CACertificate()
+	if err != nil {
+		return fmt.Errorf("Error getting the CA certificate: %s", err)
+	}
+
+	return ioutil.WriteFile(certFile, caCert, 0644)
+}
+
+// GenerateCert generates a private key and has its certificate signed by the
+// signer.
+func (scg *SignerCertGenerator) GenerateCert(opts *Options) error {
+	priv, err := rsa.GenerateKey(rand.Reader, opts.Bits)
+	if err != nil {
+		return err
+	}
+
+	req := &SignRequest{
+		CommonName: opts.Org,
+		Client:     isClientCert(opts),
+	}
+	if !req.Client {
+		req.IPAddresses, req.DNSNames = splitHosts(opts.Hosts)
+		if len(req.DNSNames) > 0 {
+			req.CommonName = req.DNSNames[0]
+		}
+	}
+
+	derBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   req.CommonName,
+			Organization: []string{opts.Org},
+		},
+		DNSNames:    req.DNSNames,
+		IPAddresses: req.IPAddresses,
+	}, priv)
+	if err != nil {
+		return err
+	}
+
+	cert, err := scg.Signer.Sign(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: derBytes}), req)
+	if err != nil {
+		return fmt.Errorf("Error signing the certificate: %s", err)
+	}
+
+	if err := ioutil.WriteFile(opts.CertFile, cert, 0644); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(opts.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}), 0600)
+}
+
+// isClientCert tells whether the options are the ones of a client
+// certificate, which are given a single empty host.
+func isClientCert(opts *Options) bool {
+	return len(opts.Hosts) == 1 && opts.Hosts[0] == ""
+}
+
+// splitHosts splits the hosts of a server certificate between IP addresses
+// and DNS names.
+func splitHosts(hosts []string) ([]net.IP, []string) {
+	var (
+		ips      []net.IP
+		dnsNames []string
+	)
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			dnsNames = append(dnsNames, h)
+		}
+	}
+
+	return ips, dnsNames
+}
@@ -0,0 +1,305 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	DefaultVaultSSHPath = "ssh"
+
+	// DefaultSSHCertificateTTL is how long the SSH certificates are valid.
+	DefaultSSHCertificateTTL = time.Hour
+
+	sshCAKeyFileName = "ssh-ca-key"
+
+	// sshCertificateRenewMargin is how long before they expire the SSH
+	// certificates are renewed.
+	sshCertificateRenewMargin = time.Minute
+)
+
+var (
+	errNoVaultSSHRole = errors.New("The role of the SSH secrets engine is required to sign the SSH keys with Vault, set it with --vault-ssh-role")
+
+	defaultSSHSigner SSHSigner
+	defaultSSHTTL    = DefaultSSHCertificateTTL
+)
+
+// SSHSigner signs the SSH keys Machine connects to the machines with, whose
+// SSH server trusts the CA of the signer instead of each key.
+type SSHSigner interface {
+	// CAPublicKey returns the public key of the CA in the authorized_keys
+	// format
+	CAPublicKey() ([]byte, error)
+
+	// SignSSHKey signs a public key in the authorized_keys format for a user
+	// and returns the certificate in the same format
+	SignSSHKey(publicKey []byte, principal string, ttl time.Duration) ([]byte, error)
+}
+
+// SetSSHSigner makes Machine access the machines it provisions with SSH
+// certificates valid for ttl, signed by signer.
+func SetSSHSigner(signer SSHSigner, ttl time.Duration) {
+	defaultSSHSigner = signer
+	defaultSSHTTL = ttl
+}
+
+// SSHCertificatesEnabled returns true if an SSH signer was set.
+func SSHCertificatesEnabled() bool {
+	return defaultSSHSigner != nil
+}
+
+// SSHCAPublicKey returns the public key of the CA of the SSH signer.
+func SSHCAPublicKey() ([]byte, error) {
+	if defaultSSHSigner == nil {
+		return nil, errors.New("No SSH certificate authority is configured, select one with --ssh-ca-backend")
+	}
+
+	return defaultSSHSigner.CAPublicKey()
+}
+
+// IssueSSHCertificate has the public key of the private key at keyPath signed
+// for the user, unless its certificate is still valid.  The certificate is
+// written next to the key, where the SSH clients look it up.
+func IssueSSHCertificate(keyPath, principal string) error {
+	if defaultSSHSigner == nil {
+		return nil
+	}
+
+	certPath := ssh.CertificatePath(keyPath)
+	if sshCertificateValid(certPath, principal) {
+		return nil
+	}
+
+	publicKey, err := ioutil.ReadFile(keyPath + ".pub")
+	if err != nil {
+		return fmt.Errorf("Error reading the public SSH key: %s", err)
+	}
+
+	log.Debugf("Signing the SSH key %s for %s", keyPath, principal)
+	certificate, err := defaultSSHSigner.SignSSHKey(publicKey, principal, defaultSSHTTL)
+	if err != nil {
+		return fmt.Errorf("Error signing the SSH key: %s", err)
+	}
+
+	return ioutil.WriteFile(certPath, certificate, 0644)
+}
+
+// sshCertificateValid tells whether the certificate at certPath is valid for
+// the user for a while longer.
+func sshCertificateValid(certPath, principal string) bool {
+	content, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return false
+	}
+
+	key, _, _, _, err := gossh.ParseAuthorizedKey(content)
+	if err != nil {
+		return false
+	}
+
+	certificate, ok := key.(*gossh.Certificate)
+	if !ok {
+		return false
+	}
+
+	if time.Now().Add(sshCertificateRenewMargin).Unix() >= int64(certificate.ValidBefore) {
+		return false
+	}
+
+	for _, p := range certificate.ValidPrincipals {
+		if p == principal {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LocalSSHSigner signs the SSH keys with a CA key of the certificates
+// directory, generated the first time it's needed.
+type LocalSSHSigner struct {
+	KeyPath string
+}
+
+func NewLocalSSHSigner(certDir string) *LocalSSHSigner {
+	return &LocalSSHSigner{
+		KeyPath: filepath.Join(certDir, sshCAKeyFileName),
+	}
+}
+
+func (ls *LocalSSHSigner) CAPublicKey() ([]byte, error) {
+	if err := ls.generateKey(); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(ls.KeyPath + ".pub")
+}
+
+func (ls *LocalSSHSigner) SignSSHKey(publicKey []byte, principal string, ttl time.Duration) ([]byte, error) {
+	if err := ls.generateKey(); err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(ls.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	caSigner, err := gossh.ParsePrivateKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the SSH CA key: %s", err)
+	}
+
+	key, _, _, _, err := gossh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the public SSH key: %s", err)
+	}
+
+	// The certificate is valid from a few minutes ago, in case the clock of
+	// the machine is late.
+	now := time.Now()
+	certificate := &gossh.Certificate{
+		Key:             key,
+		CertType:        gossh.UserCert,
+		KeyId:           principal,
+		ValidPrincipals: []string{principal},
+		ValidAfter:      uint64(now.Add(-5 * time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: gossh.Permissions{
+			Extensions: map[string]string{
+				"permit-port-forwarding": "",
+				"permit-pty":             "",
+			},
+		},
+	}
+
+	if err := certificate.SignCert(rand.Reader, caSigner); err != nil {
+		return nil, err
+	}
+
+	return gossh.MarshalAuthorizedKey(certificate), nil
+}
+
+// generateKey generates the CA key unless it exists.  It's an ECDSA key
+// since OpenSSH no longer accepts certificates signed with SHA-1, the only
+// hash the SSH library signs RSA keys with.
+func (ls *LocalSSHSigner) generateKey() error {
+	if _, err := os.Stat(ls.KeyPath); err == nil {
+		return nil
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := gossh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(ls.KeyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(ls.KeyPath+".pub", gossh.MarshalAuthorizedKey(publicKey), 0644)
+}
+
+// VaultSSHSigner signs the SSH keys with a role of the SSH secrets engine of
+// HashiCorp Vault, configured to sign user certificates.  The role must allow
+// the SSH users of the machines, e.g. with allowed_users set to *.
+type VaultSSHSigner struct {
+	Addr  string
+	Token string
+	Path  string
+	Role  string
+
+	http *http.Client
+}
+
+// NewVaultSSHSigner returns a signer using the SSH secrets engine mounted at
+// path.
+func NewVaultSSHSigner(addr, token, path, role, caCertPath string) (*VaultSSHSigner, error) {
+	if addr == "" {
+		return nil, errNoVaultAddr
+	}
+
+	if role == "" {
+		return nil, errNoVaultSSHRole
+	}
+
+	if path == "" {
+		path = DefaultVaultSSHPath
+	}
+
+	client, err := newVaultHTTPClient(caCertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VaultSSHSigner{
+		Addr:  strings.TrimSuffix(addr, "/"),
+		Token: token,
+		Path:  strings.Trim(path, "/"),
+		Role:  role,
+		http:  client,
+	}, nil
+}
+
+func (vs *VaultSSHSigner) CAPublicKey() ([]byte, error) {
+	return vaultRequest(vs.http, vs.Addr, vs.Token, "GET", vs.Path+"/public_key", nil)
+}
+
+func (vs *VaultSSHSigner) SignSSHKey(publicKey []byte, principal string, ttl time.Duration) ([]byte, error) {
+	content, err := json.Marshal(map[string]string{
+		"public_key":       string(publicKey),
+		"valid_principals": principal,
+		"cert_type":        "user",
+		"ttl":              ttl.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := vaultRequest(vs.http, vs.Addr, vs.Token, "POST", vs.Path+"/sign/"+vs.Role, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data struct {
+			SignedKey string `json:"signed_key"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("Error reading the response of Vault: %s", err)
+	}
+
+	if resp.Data.SignedKey == "" {
+		return nil, errors.New("Vault returned no signed key")
+	}
+
+	return []byte(strings.TrimSpace(resp.Data.SignedKey) + "\n"), nil
+}
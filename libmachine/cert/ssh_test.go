@@ -0,0 +1,189 @@
+package cert
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func parseSSHCertificate(t *testing.T, content []byte) *gossh.Certificate {
+	key, _, _, _, err := gossh.ParseAuthorizedKey(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, ok := key.(*gossh.Certificate)
+	if !ok {
+		t.Fatalf("expected an SSH certificate, got %s", key.Type())
+	}
+
+	return certificate
+}
+
+func TestLocalSSHSigner(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keyPath := filepath.Join(tmpDir, "id_rsa")
+	assert.NoError(t, ssh.GenerateSSHKey(keyPath))
+	publicKey, err := ioutil.ReadFile(keyPath + ".pub")
+	assert.NoError(t, err)
+
+	signer := NewLocalSSHSigner(tmpDir)
+	caPublicKey, err := signer.CAPublicKey()
+	assert.NoError(t, err)
+
+	content, err := signer.SignSSHKey(publicKey, "ubuntu", time.Hour)
+	assert.NoError(t, err)
+
+	certificate := parseSSHCertificate(t, content)
+	assert.Equal(t, uint32(gossh.UserCert), certificate.CertType)
+	assert.Equal(t, []string{"ubuntu"}, certificate.ValidPrincipals)
+	assert.Equal(t, caPublicKey, gossh.MarshalAuthorizedKey(certificate.SignatureKey))
+
+	checker := &gossh.CertChecker{
+		IsAuthority: func(auth gossh.PublicKey) bool {
+			return string(gossh.MarshalAuthorizedKey(auth)) == string(caPublicKey)
+		},
+	}
+	assert.NoError(t, checker.CheckCert("ubuntu", certificate))
+	assert.Error(t, checker.CheckCert("root", certificate))
+
+	// The CA key is generated once
+	again, err := NewLocalSSHSigner(tmpDir).CAPublicKey()
+	assert.NoError(t, err)
+	assert.Equal(t, caPublicKey, again)
+}
+
+func TestIssueSSHCertificate(t *testing.T) {
+	defer SetSSHSigner(nil, DefaultSSHCertificateTTL)
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keyPath := filepath.Join(tmpDir, "id_rsa")
+	assert.NoError(t, ssh.GenerateSSHKey(keyPath))
+
+	assert.NoError(t, IssueSSHCertificate(keyPath, "ubuntu"))
+	_, err = os.Stat(ssh.CertificatePath(keyPath))
+	assert.True(t, os.IsNotExist(err))
+
+	SetSSHSigner(NewLocalSSHSigner(tmpDir), time.Hour)
+	assert.True(t, SSHCertificatesEnabled())
+
+	assert.NoError(t, IssueSSHCertificate(keyPath, "ubuntu"))
+	issued, err := ioutil.ReadFile(ssh.CertificatePath(keyPath))
+	assert.NoError(t, err)
+
+	// A valid certificate is kept
+	assert.NoError(t, IssueSSHCertificate(keyPath, "ubuntu"))
+	kept, err := ioutil.ReadFile(ssh.CertificatePath(keyPath))
+	assert.NoError(t, err)
+	assert.Equal(t, issued, kept)
+
+	// A certificate for another user is renewed
+	assert.NoError(t, IssueSSHCertificate(keyPath, "root"))
+	renewed, err := ioutil.ReadFile(ssh.CertificatePath(keyPath))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"root"}, parseSSHCertificate(t, renewed).ValidPrincipals)
+
+	// So is a certificate about to expire
+	SetSSHSigner(NewLocalSSHSigner(tmpDir), 30*time.Second)
+	assert.NoError(t, IssueSSHCertificate(keyPath, "docker"))
+	expiring, err := ioutil.ReadFile(ssh.CertificatePath(keyPath))
+	assert.NoError(t, err)
+	assert.False(t, sshCertificateValid(ssh.CertificatePath(keyPath), "docker"))
+
+	assert.NoError(t, IssueSSHCertificate(keyPath, "docker"))
+	renewed, err = ioutil.ReadFile(ssh.CertificatePath(keyPath))
+	assert.NoError(t, err)
+	assert.NotEqual(t, expiring, renewed)
+}
+
+func TestNewVaultSSHSignerRequiresAddrAndRole(t *testing.T) {
+	_, err := NewVaultSSHSigner("", "s.token", "", "machine", "")
+	assert.Equal(t, errNoVaultAddr, err)
+
+	_, err = NewVaultSSHSigner("https://vault:8200", "s.token", "", "", "")
+	assert.Equal(t, errNoVaultSSHRole, err)
+
+	signer, err := NewVaultSSHSigner("https://vault:8200/", "s.token", "", "machine", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://vault:8200", signer.Addr)
+	assert.Equal(t, DefaultVaultSSHPath, signer.Path)
+}
+
+func TestVaultSSHSigner(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ca := NewLocalSSHSigner(tmpDir)
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/ssh-client/public_key":
+			caPublicKey, _ := ca.CAPublicKey()
+			w.Write(caPublicKey)
+		case "/v1/ssh-client/sign/machine":
+			if r.Header.Get("X-Vault-Token") != "s.token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+			ttl, _ := time.ParseDuration(body["ttl"])
+			signed, _ := ca.SignSSHKey([]byte(body["public_key"]), body["valid_principals"], ttl)
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{
+					"signed_key": string(signed),
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	keyPath := filepath.Join(tmpDir, "id_rsa")
+	assert.NoError(t, ssh.GenerateSSHKey(keyPath))
+	publicKey, err := ioutil.ReadFile(keyPath + ".pub")
+	assert.NoError(t, err)
+
+	signer, err := NewVaultSSHSigner(vault.URL, "s.token", "/ssh-client/", "machine", "")
+	assert.NoError(t, err)
+
+	caPublicKey, err := signer.CAPublicKey()
+	assert.NoError(t, err)
+	expected, _ := ca.CAPublicKey()
+	assert.Equal(t, expected, caPublicKey)
+
+	content, err := signer.SignSSHKey(publicKey, "docker", 10*time.Minute)
+	assert.NoError(t, err)
+	certificate := parseSSHCertificate(t, content)
+	assert.Equal(t, []string{"docker"}, certificate.ValidPrincipals)
+	assert.Equal(t, expected, gossh.MarshalAuthorizedKey(certificate.SignatureKey))
+
+	signer.Token = "wrong"
+	_, err = signer.SignSSHKey(publicKey, "docker", 10*time.Minute)
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const DefaultVaultPKIPath = "pki"
+
+var (
+	errNoVaultAddr = errors.New("The address of Vault is required to sign the certificates with Vault, set it with --vault-addr or VAULT_ADDR")
+	errNoVaultRole = errors.New("The role of the PKI secrets engine is required to sign the certificates with Vault, set it with --vault-role")
+)
+
+// VaultSigner signs the certificates with a role of the PKI secrets engine of
+// HashiCorp Vault.  The role must allow the names of Machine's certificates,
+// e.g. with allow_any_name, as well as IP SANs and client certificates.
+type VaultSigner struct {
+	Addr    string
+	Token   string
+	PKIPath string
+	Role    string
+
+	http *http.Client
+}
+
+// NewVaultSigner returns a signer using the PKI secrets engine mounted at
+// pkiPath.  The certificate of Vault is verified against caCertPath if it is
+// given, and against the system roots otherwise.
+func NewVaultSigner(addr, token, pkiPath, role, caCertPath string) (*VaultSigner, error) {
+	if addr == "" {
+		return nil, errNoVaultAddr
+	}
+
+	if role == "" {
+		return nil, errNoVaultRole
+	}
+
+	if pkiPath == "" {
+		pkiPath = DefaultVaultPKIPath
+	}
+
+	client, err := newVaultHTTPClient(caCertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VaultSigner{
+		Addr:    strings.TrimSuffix(addr, "/"),
+		Token:   token,
+		PKIPath: strings.Trim(pkiPath, "/"),
+		Role:    role,
+		http:    client,
+	}, nil
+}
+
+func (vs *VaultSigner) CACertificate() ([]byte, error) {
+	return vs.do("GET", "/ca/pem", nil)
+}
+
+func (vs *VaultSigner) Sign(csr []byte, req *SignRequest) ([]byte, error) {
+	body := map[string]string{
+		"csr":         string(csr),
+		"common_name": req.CommonName,
+		"format":      "pem",
+	}
+
+	if len(req.DNSNames) > 0 {
+		body["alt_names"] = strings.Join(req.DNSNames, ",")
+	}
+
+	if len(req.IPAddresses) > 0 {
+		ips := []string{}
+		for _, ip := range req.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+		body["ip_sans"] = strings.Join(ips, ",")
+	}
+
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := vs.do("POST", "/sign/"+vs.Role, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data struct {
+			Certificate string `json:"certificate"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("Error reading the response of Vault: %s", err)
+	}
+
+	if resp.Data.Certificate == "" {
+		return nil, errors.New("Vault returned no certificate")
+	}
+
+	return []byte(resp.Data.Certificate + "\n"), nil
+}
+
+func (vs *VaultSigner) do(method, path string, body []byte) ([]byte, error) {
+	return vaultRequest(vs.http, vs.Addr, vs.Token, method, vs.PKIPath+path, body)
+}
+
+// newVaultHTTPClient returns the client of the Vault API, verifying the
+// certificate of Vault against caCertPath if it is given, and against the
+// system roots otherwise.
+func newVaultHTTPClient(caCertPath string) (*http.Client, error) {
+	client := &http.Client{}
+	if caCertPath == "" {
+		return client, nil
+	}
+
+	caCert, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the CA certificate of Vault: %s", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("Error reading the CA certificate of Vault: no certificate found in %s", caCertPath)
+	}
+
+	client.Transport = &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: certPool},
+	}
+
+	return client, nil
+}
+
+// vaultRequest calls the path of the Vault API, relative to /v1, and returns
+// the body of the response.
+func vaultRequest(client *http.Client, addr, token, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/v1/%s", addr, path), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(respBody, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return nil, fmt.Errorf("Vault returned %d: %s", resp.StatusCode, strings.Join(vaultErr.Errors, ", "))
+		}
+		return nil, fmt.Errorf("Vault returned %d", resp.StatusCode)
+	}
+
+	return respBody, nil
+}
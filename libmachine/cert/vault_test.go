@@ -0,0 +1,144 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeVault serves the PKI secrets engine of Vault, signing the requests
+// with a CA generated in dir.
+func newFakeVault(t *testing.T, dir string) *httptest.Server {
+	caCertPath := filepath.Join(dir, "vault-ca.pem")
+	caKeyPath := filepath.Join(dir, "vault-ca-key.pem")
+	if err := NewX509CertGenerator().GenerateCACertificate(caCertPath, caKeyPath, "vault", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/pki/ca/pem":
+			caCert, _ := ioutil.ReadFile(caCertPath)
+			w.Write(caCert)
+		case "/v1/pki/sign/machine":
+			if r.Header.Get("X-Vault-Token") != "s.token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+			block, _ := pem.Decode([]byte(body["csr"]))
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			caPair, _ := tls.LoadX509KeyPair(caCertPath, caKeyPath)
+			caCert, _ := x509.ParseCertificate(caPair.Certificate[0])
+			template, _ := NewX509CertGenerator().(*X509CertGenerator).newCertificate(body["common_name"])
+			template.DNSNames = csr.DNSNames
+			template.IPAddresses = csr.IPAddresses
+			template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+			derBytes, _ := x509.CreateCertificate(rand.Reader, template, caCert, csr.PublicKey, caPair.PrivateKey)
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{
+					"certificate": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})),
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestNewVaultSignerRequiresAddrAndRole(t *testing.T) {
+	_, err := NewVaultSigner("", "s.token", "", "machine", "")
+	assert.Equal(t, errNoVaultAddr, err)
+
+	_, err = NewVaultSigner("https://vault:8200", "s.token", "", "", "")
+	assert.Equal(t, errNoVaultRole, err)
+
+	signer, err := NewVaultSigner("https://vault:8200/", "s.token", "", "machine", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://vault:8200", signer.Addr)
+	assert.Equal(t, DefaultVaultPKIPath, signer.PKIPath)
+}
+
+func TestSignerCertGeneratorWithVault(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	vault := newFakeVault(t, tmpDir)
+	defer vault.Close()
+
+	signer, err := NewVaultSigner(vault.URL, "s.token", "pki", "machine", "")
+	assert.NoError(t, err)
+	generator := NewSignerCertGenerator(signer)
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "ca-key.pem")
+	assert.NoError(t, generator.GenerateCACertificate(caCertPath, caKeyPath, "test-org", 2048))
+
+	_, err = os.Stat(caKeyPath)
+	assert.True(t, os.IsNotExist(err))
+
+	certPath := filepath.Join(tmpDir, "server.pem")
+	keyPath := filepath.Join(tmpDir, "server-key.pem")
+	assert.NoError(t, generator.GenerateCert(&Options{
+		Hosts:    []string{"dev.example.com", "10.0.0.1", "localhost"},
+		CertFile: certPath,
+		KeyFile:  keyPath,
+		CAFile:   caCertPath,
+		Org:      "user.dev",
+		Bits:     2048,
+	}))
+
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev.example.com", "localhost"}, cert.DNSNames)
+	assert.Equal(t, "10.0.0.1", cert.IPAddresses[0].String())
+
+	caCert, err := ioutil.ReadFile(caCertPath)
+	assert.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caCert)
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "dev.example.com", Roots: roots})
+	assert.NoError(t, err)
+}
+
+func TestVaultSignerError(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	vault := newFakeVault(t, tmpDir)
+	defer vault.Close()
+
+	signer, err := NewVaultSigner(vault.URL, "wrong", "pki", "machine", "")
+	assert.NoError(t, err)
+
+	_, err = signer.Sign([]byte("csr"), &SignRequest{CommonName: "dev"})
+	assert.EqualError(t, err, "Vault returned 403: permission denied")
+}
@@ -3,8 +3,8 @@ package check
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
-	"strings"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
@@ -100,8 +100,7 @@ func parseSwarm(hostURL string, h *host.Host) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("There was an error parsing the url: %s", err)
 	}
-	parts := strings.Split(u.Host, ":")
-	swarmPort := parts[1]
+	swarmPort := u.Port()
 
 	// get IP of machine to replace in case swarm host is 0.0.0.0
 	mURL, err := url.Parse(hostURL)
@@ -109,10 +108,9 @@ func parseSwarm(hostURL string, h *host.Host) (string, error) {
 		return "", fmt.Errorf("There was an error parsing the url: %s", err)
 	}
 
-	mParts := strings.Split(mURL.Host, ":")
-	machineIP := mParts[0]
+	machineIP := mURL.Hostname()
 
-	hostURL = fmt.Sprintf("tcp://%s:%s", machineIP, swarmPort)
+	hostURL = fmt.Sprintf("tcp://%s", net.JoinHostPort(machineIP, swarmPort))
 
 	return hostURL, nil
 }
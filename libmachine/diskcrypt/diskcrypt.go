@@ -0,0 +1,298 @@
+package diskcrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	KeySourceStore  = "store"
+	KeySourcePrompt = "prompt"
+	KeySourceKMS    = "kms"
+
+	// KeyFile is the name of the key of the data disk in the machine
+	// directory, when the key is kept in the store.
+	KeyFile = "data-disk.key"
+
+	mapperName    = "docker-data"
+	mapperDevice  = "/dev/mapper/" + mapperName
+	dataDir       = "/var/lib/docker"
+	migrationDir  = "/mnt/docker-data"
+	dropInDir     = "/etc/systemd/system/docker.service.d"
+	dropInFile    = dropInDir + "/data-disk.conf"
+	fstabEntry    = mapperDevice + " " + dataDir + " ext4 noauto,nofail 0 2"
+	storedKeySize = 32
+)
+
+// The engine isn't started on the root disk while the data disk is locked,
+// e.g. after a reboot, it's started once the disk is unlocked.
+const dropIn = `[Unit]
+ConditionPathIsMountPoint=` + dataDir + `
+`
+
+var (
+	ErrUnknownKeySource = errors.New("Error: the key source of the data disk must be store, prompt or kms")
+	ErrNoKMSCommand     = errors.New("Error: --provision-encryption-key-source=kms requires --provision-encryption-kms-command")
+	ErrNotSupported     = errors.New("Error: the data disk can only be encrypted on systemd machines running docker")
+	ErrEmptyKey         = errors.New("Error: the key of the data disk is empty")
+	ErrKeyMismatch      = errors.New("Error: the passphrases of the data disk don't match")
+)
+
+// readPassword reads a passphrase from the terminal, without echoing it.
+var readPassword = func(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	return terminal.ReadPassword(int(os.Stdin.Fd()))
+}
+
+// runKMSCommand runs the command printing the key on its standard output.
+var runKMSCommand = func(command string) ([]byte, error) {
+	args := strings.Fields(command)
+	output, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("Error running the KMS command of the data disk: %s", err)
+	}
+
+	return []byte(strings.TrimSpace(string(output))), nil
+}
+
+type Options struct {
+	// Device is the block device encrypted with LUKS and mounted on
+	// /var/lib/docker, e.g. /dev/sdb.
+	Device string
+
+	// KeySource tells where the key comes from: a random key kept in the
+	// machine directory, a passphrase prompted on each unlock, or the
+	// output of KMSCommand.
+	KeySource  string
+	KMSCommand string
+
+	// Force formats the device even if it has a filesystem or a partition
+	// table, whose data is then lost.
+	Force bool
+}
+
+// IsEncrypted returns true if the data disk of the machine is encrypted.
+func (o *Options) IsEncrypted() bool {
+	return o != nil && o.Device != ""
+}
+
+// Validate checks the options given on the command line.
+func (o *Options) Validate() error {
+	switch o.KeySource {
+	case "", KeySourceStore, KeySourcePrompt:
+	case KeySourceKMS:
+		if strings.TrimSpace(o.KMSCommand) == "" {
+			return ErrNoKMSCommand
+		}
+	default:
+		return ErrUnknownKeySource
+	}
+
+	return nil
+}
+
+// key returns the key of the data disk.  A key kept in the store is
+// generated the first time, when the disk is formatted, and a prompted
+// passphrase is then asked twice.
+func (o *Options) key(storePath string, format bool) ([]byte, error) {
+	var (
+		key []byte
+		err error
+	)
+
+	switch o.KeySource {
+	case KeySourcePrompt:
+		key, err = readPassword("Passphrase of the data disk: ")
+		if err == nil && format {
+			var confirmation []byte
+			if confirmation, err = readPassword("Confirm the passphrase: "); err == nil && string(confirmation) != string(key) {
+				return nil, ErrKeyMismatch
+			}
+		}
+	case KeySourceKMS:
+		key, err = runKMSCommand(o.KMSCommand)
+	default:
+		key, err = storedKey(filepath.Join(storePath, KeyFile), format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) == 0 {
+		return nil, ErrEmptyKey
+	}
+
+	return key, nil
+}
+
+func storedKey(path string, generate bool) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err == nil || !os.IsNotExist(err) || !generate {
+		return key, err
+	}
+
+	random := make([]byte, storedKeySize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	key = []byte(hex.EncodeToString(random))
+	log.Debugf("writing the key of the data disk to %s", path)
+
+	return key, ioutil.WriteFile(path, key, 0600)
+}
+
+// runWithKey runs a command of cryptsetup given the key on its standard
+// input, so that the key is never part of a command line, which is logged
+// and visible in the processes of the machine.  It's a variable so that the
+// tests can record the commands.
+var runWithKey = func(p provision.Provisioner, command string, key []byte) (string, error) {
+	return drivers.RunSSHCommandWithInputFromDriver(p.GetDriver(), command, bytes.NewReader(key))
+}
+
+// deviceContent returns the types of the filesystems and partition tables
+// found on the device, empty if there's none.
+func deviceContent(p provision.Provisioner, device string) []string {
+	output, _ := p.SSHCommand(fmt.Sprintf("sudo blkid -p -o export %s 2>/dev/null; true", device))
+
+	types := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 && (parts[0] == "TYPE" || parts[0] == "PTTYPE") {
+			types = append(types, parts[1])
+		}
+	}
+	return types
+}
+
+// checkSupported checks that only the /var/lib/docker directory of the
+// docker service, which podman, containerd, rootless and Windows machines
+// don't have, is moved to the data disk.
+func checkSupported(p provision.Provisioner, engineOptions engine.Options) error {
+	if !provision.ManagesDockerd(p, engineOptions) {
+		return ErrNotSupported
+	}
+
+	if _, err := p.SSHCommand("test -d /run/systemd/system"); err != nil {
+		return ErrNotSupported
+	}
+
+	return nil
+}
+
+// Configure encrypts the data disk with LUKS, moves the content of
+// /var/lib/docker to it and mounts it there.  A disk already encrypted,
+// e.g. when the machine is provisioned again, is only unlocked.  The key is
+// written to the machine directory storePath when it's kept in the store.
+func Configure(p provision.Provisioner, options *Options, engineOptions engine.Options, storePath string) error {
+	if !options.IsEncrypted() {
+		return nil
+	}
+
+	if err := checkSupported(p, engineOptions); err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand("command -v cryptsetup"); err != nil {
+		if err := p.Package("cryptsetup", pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo cryptsetup isLuks %s", options.Device)); err == nil {
+		return Unlock(p, options, storePath)
+	}
+
+	if content := deviceContent(p, options.Device); len(content) > 0 && !options.Force {
+		return fmt.Errorf("Error: %s has data (%s) which encrypting it would erase, use --provision-encrypt-data-disk-force to encrypt it anyway", options.Device, strings.Join(content, ", "))
+	}
+
+	key, err := options.key(storePath, true)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Encrypting the data disk %s...", options.Device)
+	if err := p.Service("docker", serviceaction.Stop); err != nil {
+		return err
+	}
+
+	keyCommands := []string{
+		fmt.Sprintf("sudo cryptsetup luksFormat --type luks2 --batch-mode --key-file=- %s", options.Device),
+		fmt.Sprintf("sudo cryptsetup open --key-file=- %s %s", options.Device, mapperName),
+	}
+	for _, command := range keyCommands {
+		if output, err := runWithKey(p, command, key); err != nil {
+			return fmt.Errorf("Error encrypting the data disk: %s\n%s", err, output)
+		}
+	}
+
+	commands := []string{
+		fmt.Sprintf("sudo mkfs.ext4 -q %s", mapperDevice),
+		fmt.Sprintf("sudo mkdir -p %[1]s %[2]s && sudo mount %[3]s %[1]s && sudo cp -a %[2]s/. %[1]s/ && sudo umount %[1]s", migrationDir, dataDir, mapperDevice),
+		fmt.Sprintf("sudo mount %s %s", mapperDevice, dataDir),
+		fmt.Sprintf("grep -q '^%[1]s ' /etc/fstab || echo '%[2]s' | sudo tee -a /etc/fstab", mapperDevice, fstabEntry),
+		fmt.Sprintf("sudo mkdir -p %s && printf %%s '%s' | sudo tee %s", dropInDir, dropIn, dropInFile),
+	}
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error encrypting the data disk: %s\n%s", err, output)
+		}
+	}
+
+	return p.Service("docker", serviceaction.Start)
+}
+
+// Unlock opens the encrypted data disk, mounts it on /var/lib/docker and
+// starts the engine, which isn't started while the disk is locked.  It does
+// nothing when the disk is already mounted or not encrypted yet.
+func Unlock(p provision.Provisioner, options *Options, storePath string) error {
+	if !options.IsEncrypted() {
+		return nil
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("mountpoint -q %s", dataDir)); err == nil {
+		return nil
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo cryptsetup isLuks %s", options.Device)); err != nil {
+		log.Debugf("the data disk %s isn't encrypted yet", options.Device)
+		return nil
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("test -e %s", mapperDevice)); err != nil {
+		key, err := options.key(storePath, false)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Unlocking the data disk %s...", options.Device)
+		if output, err := runWithKey(p, fmt.Sprintf("sudo cryptsetup open --key-file=- %s %s", options.Device, mapperName), key); err != nil {
+			return fmt.Errorf("Error unlocking the data disk: %s\n%s", err, output)
+		}
+	}
+
+	if output, err := p.SSHCommand(fmt.Sprintf("sudo mount %s %s", mapperDevice, dataDir)); err != nil {
+		return fmt.Errorf("Error mounting the data disk: %s\n%s", err, output)
+	}
+
+	return p.Service("docker", serviceaction.Start)
+}
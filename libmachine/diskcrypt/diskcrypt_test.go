@@ -0,0 +1,195 @@
+package diskcrypt
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProvisioner struct {
+	provision.FakeProvisioner
+	commander *provisiontest.FakeSSHCommander
+}
+
+func (p *fakeProvisioner) SSHCommand(args string) (string, error) {
+	return p.commander.SSHCommand(args)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Options{Device: "/dev/sdb"}).Validate())
+	assert.NoError(t, (&Options{Device: "/dev/sdb", KeySource: KeySourcePrompt}).Validate())
+	assert.NoError(t, (&Options{Device: "/dev/sdb", KeySource: KeySourceKMS, KMSCommand: "vault read -field=key secret/m1"}).Validate())
+	assert.Equal(t, ErrNoKMSCommand, (&Options{Device: "/dev/sdb", KeySource: KeySourceKMS}).Validate())
+	assert.Equal(t, ErrUnknownKeySource, (&Options{Device: "/dev/sdb", KeySource: "tpm"}).Validate())
+}
+
+func TestStoredKey(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "diskcrypt-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	options := &Options{Device: "/dev/sdb"}
+
+	_, err = options.key(storePath, false)
+	assert.True(t, os.IsNotExist(err))
+
+	key, err := options.key(storePath, true)
+	assert.NoError(t, err)
+	assert.Len(t, key, 2*storedKeySize)
+
+	stored, err := ioutil.ReadFile(filepath.Join(storePath, KeyFile))
+	assert.NoError(t, err)
+	assert.Equal(t, key, stored)
+
+	again, err := options.key(storePath, false)
+	assert.NoError(t, err)
+	assert.Equal(t, key, again)
+}
+
+func TestPromptedKeyMismatch(t *testing.T) {
+	defer func(original func(string) ([]byte, error)) { readPassword = original }(readPassword)
+
+	passphrases := []string{"secret", "typo"}
+	readPassword = func(prompt string) ([]byte, error) {
+		passphrase := passphrases[0]
+		passphrases = passphrases[1:]
+		return []byte(passphrase), nil
+	}
+
+	_, err := (&Options{Device: "/dev/sdb", KeySource: KeySourcePrompt}).key("", true)
+
+	assert.Equal(t, ErrKeyMismatch, err)
+}
+
+func TestKMSKey(t *testing.T) {
+	defer func(original func(string) ([]byte, error)) { runKMSCommand = original }(runKMSCommand)
+
+	runKMSCommand = func(command string) ([]byte, error) {
+		assert.Equal(t, "vault read -field=key secret/m1", command)
+		return nil, errors.New("permission denied")
+	}
+
+	_, err := (&Options{Device: "/dev/sdb", KeySource: KeySourceKMS, KMSCommand: "vault read -field=key secret/m1"}).key("", false)
+
+	assert.EqualError(t, err, "permission denied")
+}
+
+// recordKeyCommands records the commands given the key on their standard
+// input, until the returned function restores runWithKey.
+func recordKeyCommands(commands *[]string) func() {
+	original := runWithKey
+	runWithKey = func(p provision.Provisioner, command string, key []byte) (string, error) {
+		*commands = append(*commands, command+" < "+string(key))
+		return "", nil
+	}
+	return func() { runWithKey = original }
+}
+
+func TestConfigureEncrypts(t *testing.T) {
+	defer func(original func(string) ([]byte, error)) { readPassword = original }(readPassword)
+
+	readPassword = func(prompt string) ([]byte, error) {
+		return []byte("secret"), nil
+	}
+
+	keyCommands := []string{}
+	defer recordKeyCommands(&keyCommands)()
+
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"test -d /run/systemd/system":                        "",
+			"command -v cryptsetup":                              "/sbin/cryptsetup",
+			"sudo blkid -p -o export /dev/sdb 2>/dev/null; true": "",
+			"sudo mkfs.ext4 -q /dev/mapper/docker-data":          "",
+			"sudo mkdir -p /mnt/docker-data /var/lib/docker && sudo mount /dev/mapper/docker-data /mnt/docker-data && sudo cp -a /var/lib/docker/. /mnt/docker-data/ && sudo umount /mnt/docker-data": "",
+			"sudo mount /dev/mapper/docker-data /var/lib/docker": "",
+			"grep -q '^/dev/mapper/docker-data ' /etc/fstab || echo '/dev/mapper/docker-data /var/lib/docker ext4 noauto,nofail 0 2' | sudo tee -a /etc/fstab":                                     "",
+			"sudo mkdir -p /etc/systemd/system/docker.service.d && printf %s '[Unit]\nConditionPathIsMountPoint=/var/lib/docker\n' | sudo tee /etc/systemd/system/docker.service.d/data-disk.conf": "",
+		},
+	}
+	p := &fakeProvisioner{commander: commander}
+
+	assert.NoError(t, Configure(p, &Options{Device: "/dev/sdb", KeySource: KeySourcePrompt}, engine.Options{}, ""))
+	assert.Equal(t, []string{
+		"sudo cryptsetup luksFormat --type luks2 --batch-mode --key-file=- /dev/sdb < secret",
+		"sudo cryptsetup open --key-file=- /dev/sdb docker-data < secret",
+	}, keyCommands)
+}
+
+func TestConfigureRefusesDeviceWithData(t *testing.T) {
+	keyCommands := []string{}
+	defer recordKeyCommands(&keyCommands)()
+
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"test -d /run/systemd/system":                        "",
+			"command -v cryptsetup":                              "/sbin/cryptsetup",
+			"sudo blkid -p -o export /dev/sdb 2>/dev/null; true": "DEVNAME=/dev/sdb\nPTUUID=1234\nPTTYPE=gpt\n",
+		},
+	}
+	p := &fakeProvisioner{commander: commander}
+
+	err := Configure(p, &Options{Device: "/dev/sdb", KeySource: KeySourcePrompt}, engine.Options{}, "")
+
+	assert.EqualError(t, err, "Error: /dev/sdb has data (gpt) which encrypting it would erase, use --provision-encrypt-data-disk-force to encrypt it anyway")
+	assert.Empty(t, keyCommands)
+}
+
+func TestConfigureNotSupported(t *testing.T) {
+	p := &fakeProvisioner{commander: &provisiontest.FakeSSHCommander{}}
+
+	assert.Equal(t, ErrNotSupported, Configure(p, &Options{Device: "/dev/sdb"}, engine.Options{}, ""))
+}
+
+func TestConfigureNotSupportedEngine(t *testing.T) {
+	p := &fakeProvisioner{commander: &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"test -d /run/systemd/system": "",
+		},
+	}}
+
+	assert.Equal(t, ErrNotSupported, Configure(p, &Options{Device: "/dev/sdb"}, engine.Options{ContainerRuntime: engine.RuntimePodman}, ""))
+	assert.Equal(t, ErrNotSupported, Configure(p, &Options{Device: "/dev/sdb"}, engine.Options{Rootless: true}, ""))
+}
+
+func TestUnlock(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "diskcrypt-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(storePath, KeyFile), []byte("secret"), 0600))
+
+	keyCommands := []string{}
+	defer recordKeyCommands(&keyCommands)()
+
+	p := &fakeProvisioner{
+		commander: &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"sudo cryptsetup isLuks /dev/sdb":                    "",
+				"sudo mount /dev/mapper/docker-data /var/lib/docker": "",
+			},
+		},
+	}
+
+	assert.NoError(t, Unlock(p, &Options{Device: "/dev/sdb"}, storePath))
+	assert.Equal(t, []string{"sudo cryptsetup open --key-file=- /dev/sdb docker-data < secret"}, keyCommands)
+}
+
+func TestUnlockMounted(t *testing.T) {
+	p := &fakeProvisioner{
+		commander: &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"mountpoint -q /var/lib/docker": "",
+			},
+		},
+	}
+
+	assert.NoError(t, Unlock(p, &Options{Device: "/dev/sdb", KeySource: KeySourcePrompt}, ""))
+}
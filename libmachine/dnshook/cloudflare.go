@@ -0,0 +1,152 @@
+package dnshook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// cloudflareURL is the base URL of the Cloudflare API.
+var cloudflareURL = "https://api.cloudflare.com/client/v4"
+
+// cloudflare calls the Cloudflare API with an API token allowed to edit the
+// DNS records of the zone.
+type cloudflare struct {
+	token string
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+func newCloudflare(c *Config) (Provider, error) {
+	token := c.token()
+	if token == "" {
+		return nil, errors.New("The Cloudflare DNS provider needs an API token, set CLOUDFLARE_API_TOKEN or the token of the DNS configuration")
+	}
+
+	return &cloudflare{token: token}, nil
+}
+
+func (cf *cloudflare) call(method, path string, body interface{}, result interface{}) error {
+	var content []byte
+	if body != nil {
+		var err error
+		if content, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, cloudflareURL+path, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cf.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	response := &cloudflareResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	if !response.Success {
+		messages := []string{}
+		for _, e := range response.Errors {
+			messages = append(messages, fmt.Sprintf("%s (%d)", e.Message, e.Code))
+		}
+		return fmt.Errorf("%s %s: %s", method, path, strings.Join(messages, ", "))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
+
+func (cf *cloudflare) zoneID(zone string) (string, error) {
+	zones := []struct {
+		ID string `json:"id"`
+	}{}
+	if err := cf.call("GET", "/zones?name="+url.QueryEscape(zone), nil, &zones); err != nil {
+		return "", err
+	}
+
+	if len(zones) == 0 {
+		return "", fmt.Errorf("Cloudflare has no zone %s", zone)
+	}
+
+	return zones[0].ID, nil
+}
+
+func (cf *cloudflare) records(zoneID string, r *Record) ([]cloudflareRecord, error) {
+	records := []cloudflareRecord{}
+	path := fmt.Sprintf("/zones/%s/dns_records?type=%s&name=%s", zoneID, r.Type, url.QueryEscape(r.Name))
+	if err := cf.call("GET", path, nil, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (cf *cloudflare) Upsert(r *Record) error {
+	zoneID, err := cf.zoneID(r.Zone)
+	if err != nil {
+		return err
+	}
+
+	existing, err := cf.records(zoneID, r)
+	if err != nil {
+		return err
+	}
+
+	record := cloudflareRecord{Type: r.Type, Name: r.Name, Content: r.Value, TTL: r.TTL}
+	if len(existing) > 0 {
+		return cf.call("PUT", fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, existing[0].ID), record, nil)
+	}
+
+	return cf.call("POST", fmt.Sprintf("/zones/%s/dns_records", zoneID), record, nil)
+}
+
+func (cf *cloudflare) Delete(r *Record) error {
+	zoneID, err := cf.zoneID(r.Zone)
+	if err != nil {
+		return err
+	}
+
+	existing, err := cf.records(zoneID, r)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range existing {
+		if err := cf.call("DELETE", fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, record.ID), nil, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
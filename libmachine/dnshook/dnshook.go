@@ -0,0 +1,206 @@
+// Package dnshook registers the machines in a DNS zone once they have an IP
+// address, as <machine>.<zone> A or AAAA records, and removes the records
+// with the machines.
+package dnshook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+)
+
+// ConfigFileName is the name of the DNS configuration file, in the storage
+// path.
+const ConfigFileName = "dns.json"
+
+const defaultTTL = 300
+
+const (
+	ProviderRoute53    = "route53"
+	ProviderCloudflare = "cloudflare"
+	ProviderGoogle     = "google"
+)
+
+// Config is where the machines are registered.
+type Config struct {
+	// Provider is the DNS service: route53, cloudflare or google.
+	Provider string `json:"provider"`
+
+	// Zone is the domain the machines are registered in, e.g. example.com.
+	Zone string `json:"zone"`
+
+	// TTL is the TTL of the records in seconds, 300 by default.
+	TTL int `json:"ttl,omitempty"`
+
+	// Project is the Google Cloud project of the zone, GOOGLE_PROJECT by
+	// default.
+	Project string `json:"project,omitempty"`
+
+	// Token is the Cloudflare API token, CLOUDFLARE_API_TOKEN by default.
+	Token string `json:"token,omitempty"`
+}
+
+// Validate checks that the provider is known and that the zone is set.
+func (c *Config) Validate() error {
+	if _, ok := providers[c.Provider]; !ok {
+		return fmt.Errorf("Unknown DNS provider %q, expected route53, cloudflare or google", c.Provider)
+	}
+
+	if c.Zone == "" {
+		return fmt.Errorf("The DNS zone the machines are registered in is required with the %s DNS provider", c.Provider)
+	}
+
+	return nil
+}
+
+func (c *Config) ttl() int {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	return defaultTTL
+}
+
+func (c *Config) project() string {
+	if c.Project != "" {
+		return c.Project
+	}
+	return os.Getenv("GOOGLE_PROJECT")
+}
+
+func (c *Config) token() string {
+	if c.Token != "" {
+		return c.Token
+	}
+	return os.Getenv("CLOUDFLARE_API_TOKEN")
+}
+
+// Load reads the DNS configuration file.  A missing file is no configuration,
+// and nil is returned.
+func Load(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("Error reading the DNS configuration of %s: %s", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("Error reading the DNS configuration of %s: %s", path, err)
+	}
+
+	return config, nil
+}
+
+var defaultConfig *Config
+
+// SetDefault sets the configuration of the DNS configuration file, nil when
+// the machines aren't registered by default.
+func SetDefault(c *Config) {
+	defaultConfig = c
+}
+
+// Default returns the configuration of the DNS configuration file, nil when
+// there is none.
+func Default() *Config {
+	return defaultConfig
+}
+
+// Record is the DNS record of a machine.
+type Record struct {
+	Provider string
+	Zone     string
+	Name     string
+	Type     string
+	Value    string
+	TTL      int
+}
+
+// Provider creates and deletes the records of a DNS service.
+type Provider interface {
+	// Upsert creates the record, or replaces the record of the same name
+	// and type.
+	Upsert(r *Record) error
+
+	// Delete removes the record, if it still exists.
+	Delete(r *Record) error
+}
+
+var providers = map[string]func(c *Config) (Provider, error){
+	ProviderRoute53:    newRoute53,
+	ProviderCloudflare: newCloudflare,
+	ProviderGoogle:     newGoogleDNS,
+}
+
+// Register creates the record of a machine in the zone of the configuration.
+func Register(c *Config, machineName, ip string) (*Record, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("Can't register %s in DNS: %q isn't an IP address", machineName, ip)
+	}
+
+	recordType := "A"
+	if parsed.To4() == nil {
+		recordType = "AAAA"
+	}
+
+	zone := strings.TrimSuffix(c.Zone, ".")
+	record := &Record{
+		Provider: c.Provider,
+		Zone:     zone,
+		Name:     machineName + "." + zone,
+		Type:     recordType,
+		Value:    ip,
+		TTL:      c.ttl(),
+	}
+
+	provider, err := providers[c.Provider](c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := provider.Upsert(record); err != nil {
+		return nil, fmt.Errorf("Error registering %s in DNS: %s", record.Name, err)
+	}
+
+	return record, nil
+}
+
+// Unregister deletes the record of a machine.  The credentials are the ones
+// of the configuration when it's for the same provider, else the ones of the
+// environment.
+func Unregister(c *Config, r *Record) error {
+	credentials := &Config{Provider: r.Provider, Zone: r.Zone}
+	if c != nil && c.Provider == r.Provider {
+		credentials.Project = c.Project
+		credentials.Token = c.Token
+	}
+
+	newProvider, ok := providers[r.Provider]
+	if !ok {
+		return fmt.Errorf("Unknown DNS provider %q", r.Provider)
+	}
+
+	provider, err := newProvider(credentials)
+	if err != nil {
+		return err
+	}
+
+	if err := provider.Delete(r); err != nil {
+		return fmt.Errorf("Error removing %s from DNS: %s", r.Name, err)
+	}
+
+	return nil
+}
+
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
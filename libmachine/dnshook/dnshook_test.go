@@ -0,0 +1,254 @@
+package dnshook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProvider struct {
+	upserted []*Record
+	deleted  []*Record
+	config   *Config
+}
+
+func (fp *fakeProvider) Upsert(r *Record) error {
+	fp.upserted = append(fp.upserted, r)
+	return nil
+}
+
+func (fp *fakeProvider) Delete(r *Record) error {
+	fp.deleted = append(fp.deleted, r)
+	return nil
+}
+
+func withFakeProvider() (*fakeProvider, func()) {
+	fake := &fakeProvider{}
+	saved := providers[ProviderCloudflare]
+	providers[ProviderCloudflare] = func(c *Config) (Provider, error) {
+		fake.config = c
+		return fake, nil
+	}
+
+	return fake, func() { providers[ProviderCloudflare] = saved }
+}
+
+func TestLoad(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dnshook")
+	defer os.RemoveAll(dir)
+
+	config, err := Load(filepath.Join(dir, ConfigFileName))
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	path := filepath.Join(dir, ConfigFileName)
+	ioutil.WriteFile(path, []byte(`{"provider": "route53", "zone": "example.com", "ttl": 60}`), 0600)
+	config, err = Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{Provider: ProviderRoute53, Zone: "example.com", TTL: 60}, config)
+
+	ioutil.WriteFile(path, []byte(`{"provider": "bind", "zone": "example.com"}`), 0600)
+	_, err = Load(path)
+	assert.Error(t, err)
+
+	ioutil.WriteFile(path, []byte(`{"provider": "google"}`), 0600)
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestRegister(t *testing.T) {
+	fake, restore := withFakeProvider()
+	defer restore()
+
+	record, err := Register(&Config{Provider: ProviderCloudflare, Zone: "example.com."}, "dev", "1.2.3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, &Record{Provider: ProviderCloudflare, Zone: "example.com", Name: "dev.example.com", Type: "A", Value: "1.2.3.4", TTL: defaultTTL}, record)
+	assert.Equal(t, []*Record{record}, fake.upserted)
+
+	record, err = Register(&Config{Provider: ProviderCloudflare, Zone: "example.com", TTL: 60}, "dev", "2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, "AAAA", record.Type)
+	assert.Equal(t, 60, record.TTL)
+
+	_, err = Register(&Config{Provider: ProviderCloudflare, Zone: "example.com"}, "dev", "dev.local")
+	assert.Error(t, err)
+}
+
+func TestUnregister(t *testing.T) {
+	fake, restore := withFakeProvider()
+	defer restore()
+
+	record := &Record{Provider: ProviderCloudflare, Zone: "example.com", Name: "dev.example.com", Type: "A", Value: "1.2.3.4"}
+
+	assert.NoError(t, Unregister(&Config{Provider: ProviderCloudflare, Zone: "other.com", Token: "secret"}, record))
+	assert.Equal(t, []*Record{record}, fake.deleted)
+	assert.Equal(t, "secret", fake.config.Token)
+	assert.Equal(t, "example.com", fake.config.Zone)
+
+	assert.NoError(t, Unregister(&Config{Provider: ProviderGoogle, Zone: "example.com", Token: "other"}, record))
+	assert.Empty(t, fake.config.Token)
+
+	assert.NoError(t, Unregister(nil, record))
+}
+
+func TestCloudflare(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		calls = append(calls, r.Method+" "+r.URL.RequestURI())
+
+		result := "[]"
+		switch {
+		case r.URL.Path == "/zones":
+			result = `[{"id": "zone1"}]`
+		case r.Method == "GET" && r.URL.Query().Get("type") == "AAAA":
+			result = `[{"id": "rec1"}]`
+		}
+		w.Write([]byte(`{"success": true, "errors": [], "result": ` + result + `}`))
+	}))
+	defer server.Close()
+
+	savedURL := cloudflareURL
+	cloudflareURL = server.URL
+	defer func() { cloudflareURL = savedURL }()
+
+	provider, err := newCloudflare(&Config{Token: "secret"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, provider.Upsert(&Record{Zone: "example.com", Name: "dev.example.com", Type: "A", Value: "1.2.3.4", TTL: 300}))
+	assert.NoError(t, provider.Upsert(&Record{Zone: "example.com", Name: "dev.example.com", Type: "AAAA", Value: "2001:db8::1", TTL: 300}))
+	assert.NoError(t, provider.Delete(&Record{Zone: "example.com", Name: "dev.example.com", Type: "AAAA"}))
+
+	assert.Equal(t, []string{
+		"GET /zones?name=example.com",
+		"GET /zones/zone1/dns_records?type=A&name=dev.example.com",
+		"POST /zones/zone1/dns_records",
+		"GET /zones?name=example.com",
+		"GET /zones/zone1/dns_records?type=AAAA&name=dev.example.com",
+		"PUT /zones/zone1/dns_records/rec1",
+		"GET /zones?name=example.com",
+		"GET /zones/zone1/dns_records?type=AAAA&name=dev.example.com",
+		"DELETE /zones/zone1/dns_records/rec1",
+	}, calls)
+}
+
+func TestCloudflareError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"success": false, "errors": [{"code": 9109, "message": "Invalid access token"}]}`))
+	}))
+	defer server.Close()
+
+	savedURL := cloudflareURL
+	cloudflareURL = server.URL
+	defer func() { cloudflareURL = savedURL }()
+
+	provider, _ := newCloudflare(&Config{Token: "secret"})
+	err := provider.Upsert(&Record{Zone: "example.com", Name: "dev.example.com", Type: "A", Value: "1.2.3.4"})
+
+	assert.EqualError(t, err, "GET /zones?name=example.com: Invalid access token (9109)")
+}
+
+func TestCloudflareNeedsToken(t *testing.T) {
+	savedToken := os.Getenv("CLOUDFLARE_API_TOKEN")
+	os.Unsetenv("CLOUDFLARE_API_TOKEN")
+	defer os.Setenv("CLOUDFLARE_API_TOKEN", savedToken)
+
+	_, err := newCloudflare(&Config{})
+	assert.Error(t, err)
+}
+
+func TestGoogleDNS(t *testing.T) {
+	var changes []googleChange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/projects/proj/managedZones":
+			assert.Equal(t, "example.com.", r.URL.Query().Get("dnsName"))
+			w.Write([]byte(`{"managedZones": [{"name": "example"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/rrsets"):
+			w.Write([]byte(`{"rrsets": [{"name": "dev.example.com.", "type": "A", "ttl": 300, "rrdatas": ["5.6.7.8"]}]}`))
+		case r.URL.Path == "/projects/proj/managedZones/example/changes":
+			change := googleChange{}
+			json.NewDecoder(r.Body).Decode(&change)
+			changes = append(changes, change)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	savedURL, savedClient := googleDNSURL, googleHTTPClient
+	googleDNSURL = server.URL
+	googleHTTPClient = func() (*http.Client, error) { return http.DefaultClient, nil }
+	defer func() { googleDNSURL, googleHTTPClient = savedURL, savedClient }()
+
+	provider, err := newGoogleDNS(&Config{Project: "proj"})
+	assert.NoError(t, err)
+
+	record := &Record{Zone: "example.com", Name: "dev.example.com", Type: "A", Value: "1.2.3.4", TTL: 300}
+	assert.NoError(t, provider.Upsert(record))
+	assert.NoError(t, provider.Delete(record))
+
+	old := googleRecordSet{Name: "dev.example.com.", Type: "A", TTL: 300, RRDatas: []string{"5.6.7.8"}}
+	assert.Equal(t, []googleChange{
+		{
+			Additions: []googleRecordSet{{Name: "dev.example.com.", Type: "A", TTL: 300, RRDatas: []string{"1.2.3.4"}}},
+			Deletions: []googleRecordSet{old},
+		},
+		{Deletions: []googleRecordSet{old}},
+	}, changes)
+}
+
+func TestRoute53(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/")
+
+		switch {
+		case r.URL.Path == "/2013-04-01/hostedzonesbyname":
+			w.Write([]byte(`<ListHostedZonesByNameResponse><HostedZones><HostedZone><Id>/hostedzone/Z123</Id><Name>example.com.</Name></HostedZone></HostedZones></ListHostedZonesByNameResponse>`))
+		case r.Method == "GET" && r.URL.Path == "/2013-04-01/hostedzone/Z123/rrset":
+			w.Write([]byte(`<ListResourceRecordSetsResponse><ResourceRecordSets><ResourceRecordSet><Name>dev.example.com.</Name><Type>A</Type><TTL>60</TTL><ResourceRecords><ResourceRecord><Value>5.6.7.8</Value></ResourceRecord></ResourceRecords></ResourceRecordSet></ResourceRecordSets></ListResourceRecordSetsResponse>`))
+		case r.Method == "POST" && r.URL.Path == "/2013-04-01/hostedzone/Z123/rrset":
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			w.Write([]byte(`<ChangeResourceRecordSetsResponse/>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<ErrorResponse><Error><Code>NoSuchHostedZone</Code><Message>not found</Message></Error></ErrorResponse>`))
+		}
+	}))
+	defer server.Close()
+
+	for key, value := range map[string]string{"AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "SECRET"} {
+		saved := os.Getenv(key)
+		os.Setenv(key, value)
+		defer os.Setenv(key, saved)
+	}
+
+	savedEndpoint := route53Endpoint
+	route53Endpoint = server.URL
+	defer func() { route53Endpoint = savedEndpoint }()
+
+	provider, err := newRoute53(&Config{})
+	assert.NoError(t, err)
+
+	record := &Record{Zone: "example.com", Name: "dev.example.com", Type: "A", Value: "1.2.3.4", TTL: 300}
+	assert.NoError(t, provider.Upsert(record))
+	assert.NoError(t, provider.Delete(record))
+
+	assert.Len(t, bodies, 2)
+	assert.Contains(t, bodies[0], "<Action>UPSERT</Action><ResourceRecordSet><Name>dev.example.com.</Name><Type>A</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>1.2.3.4</Value>")
+	assert.Contains(t, bodies[1], "<Action>DELETE</Action><ResourceRecordSet><Name>dev.example.com.</Name><Type>A</Type><TTL>60</TTL><ResourceRecords><ResourceRecord><Value>5.6.7.8</Value>")
+
+	err = provider.Upsert(&Record{Zone: "other.com", Name: "dev.other.com", Type: "A", Value: "1.2.3.4", TTL: 300})
+	assert.EqualError(t, err, "Route 53 has no hosted zone other.com")
+}
@@ -0,0 +1,157 @@
+package dnshook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const googleDNSScope = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
+
+// googleDNSURL is the base URL of the Cloud DNS API.
+var googleDNSURL = "https://dns.googleapis.com/dns/v1"
+
+// googleHTTPClient returns the client authenticated with the application
+// default credentials, as the google driver does.
+var googleHTTPClient = func() (*http.Client, error) {
+	return google.DefaultClient(oauth2.NoContext, googleDNSScope)
+}
+
+// googleDNS calls the Cloud DNS API of a project.
+type googleDNS struct {
+	client  *http.Client
+	project string
+}
+
+type googleRecordSet struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl"`
+	RRDatas []string `json:"rrdatas"`
+}
+
+type googleChange struct {
+	Additions []googleRecordSet `json:"additions,omitempty"`
+	Deletions []googleRecordSet `json:"deletions,omitempty"`
+}
+
+func newGoogleDNS(c *Config) (Provider, error) {
+	project := c.project()
+	if project == "" {
+		return nil, errors.New("The google DNS provider needs the project of the zone, set GOOGLE_PROJECT or the project of the DNS configuration")
+	}
+
+	client, err := googleHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &googleDNS{client: client, project: project}, nil
+}
+
+func (g *googleDNS) call(method, path string, body interface{}, result interface{}) error {
+	var content []byte
+	if body != nil {
+		var err error
+		if content, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/projects/%s%s", googleDNSURL, g.project, path), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %s\n%s", method, path, resp.Status, message)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// managedZone returns the name of the managed zone of a domain.
+func (g *googleDNS) managedZone(zone string) (string, error) {
+	zones := struct {
+		ManagedZones []struct {
+			Name string `json:"name"`
+		} `json:"managedZones"`
+	}{}
+	if err := g.call("GET", "/managedZones?dnsName="+url.QueryEscape(fqdn(zone)), nil, &zones); err != nil {
+		return "", err
+	}
+
+	if len(zones.ManagedZones) == 0 {
+		return "", fmt.Errorf("Project %s has no managed zone for %s", g.project, zone)
+	}
+
+	return zones.ManagedZones[0].Name, nil
+}
+
+func (g *googleDNS) recordSets(managedZone string, r *Record) ([]googleRecordSet, error) {
+	sets := struct {
+		RRSets []googleRecordSet `json:"rrsets"`
+	}{}
+	path := fmt.Sprintf("/managedZones/%s/rrsets?name=%s&type=%s", managedZone, url.QueryEscape(fqdn(r.Name)), r.Type)
+	if err := g.call("GET", path, nil, &sets); err != nil {
+		return nil, err
+	}
+
+	return sets.RRSets, nil
+}
+
+func (g *googleDNS) Upsert(r *Record) error {
+	managedZone, err := g.managedZone(r.Zone)
+	if err != nil {
+		return err
+	}
+
+	existing, err := g.recordSets(managedZone, r)
+	if err != nil {
+		return err
+	}
+
+	change := googleChange{
+		Additions: []googleRecordSet{{Name: fqdn(r.Name), Type: r.Type, TTL: r.TTL, RRDatas: []string{r.Value}}},
+		Deletions: existing,
+	}
+
+	return g.call("POST", fmt.Sprintf("/managedZones/%s/changes", managedZone), change, nil)
+}
+
+func (g *googleDNS) Delete(r *Record) error {
+	managedZone, err := g.managedZone(r.Zone)
+	if err != nil {
+		return err
+	}
+
+	existing, err := g.recordSets(managedZone, r)
+	if err != nil {
+		return err
+	}
+
+	if len(existing) == 0 {
+		return nil
+	}
+
+	return g.call("POST", fmt.Sprintf("/managedZones/%s/changes", managedZone), googleChange{Deletions: existing}, nil)
+}
@@ -0,0 +1,204 @@
+package dnshook
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/signer/v4"
+)
+
+const route53APIVersion = "2013-04-01"
+
+// route53Endpoint replaces the endpoint of Route 53, for the tests.
+var route53Endpoint = ""
+
+// route53 calls the REST API of Route 53 with the credentials of the AWS
+// environment variables or shared credentials file.
+type route53 struct {
+	*client.Client
+}
+
+func newRoute53(c *Config) (Provider, error) {
+	config := aws.NewConfig().WithRegion("us-east-1")
+	if route53Endpoint != "" {
+		config = config.WithEndpoint(route53Endpoint)
+	}
+
+	clientConfig := session.New(config).ClientConfig("route53")
+	svc := client.New(
+		*clientConfig.Config,
+		metadata.ClientInfo{
+			ServiceName:   "route53",
+			SigningRegion: clientConfig.SigningRegion,
+			Endpoint:      clientConfig.Endpoint,
+			APIVersion:    route53APIVersion,
+		},
+		clientConfig.Handlers,
+	)
+
+	svc.Handlers.Sign.PushBack(v4.Sign)
+	svc.Handlers.Build.PushBack(buildRoute53)
+	svc.Handlers.Unmarshal.PushBack(unmarshalRoute53)
+	svc.Handlers.UnmarshalError.PushBack(unmarshalRoute53Error)
+
+	return &route53{svc}, nil
+}
+
+func buildRoute53(r *request.Request) {
+	if r.Params == nil {
+		return
+	}
+
+	body, err := xml.Marshal(r.Params)
+	if err != nil {
+		r.Error = awserr.New("SerializationError", "failed encoding the Route 53 request", err)
+		return
+	}
+
+	r.HTTPRequest.Header.Set("Content-Type", "application/xml")
+	r.SetBufferBody(append([]byte(xml.Header), body...))
+}
+
+func unmarshalRoute53(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+
+	if r.Data == nil {
+		return
+	}
+
+	if err := xml.NewDecoder(r.HTTPResponse.Body).Decode(r.Data); err != nil {
+		r.Error = awserr.New("SerializationError", "failed decoding the Route 53 response", err)
+	}
+}
+
+func unmarshalRoute53Error(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+
+	body, _ := ioutil.ReadAll(r.HTTPResponse.Body)
+
+	var resp struct {
+		Code    string `xml:"Error>Code"`
+		Message string `xml:"Error>Message"`
+	}
+	if err := xml.NewDecoder(bytes.NewReader(body)).Decode(&resp); err != nil || resp.Code == "" {
+		resp.Code = r.HTTPResponse.Status
+		resp.Message = strings.TrimSpace(string(body))
+	}
+
+	r.Error = awserr.NewRequestFailure(awserr.New(resp.Code, resp.Message, nil), r.HTTPResponse.StatusCode, r.RequestID)
+}
+
+type route53ResourceRecordSet struct {
+	Name   string   `xml:"Name"`
+	Type   string   `xml:"Type"`
+	TTL    int      `xml:"TTL"`
+	Values []string `xml:"ResourceRecords>ResourceRecord>Value"`
+}
+
+type route53Change struct {
+	Action            string                   `xml:"Action"`
+	ResourceRecordSet route53ResourceRecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsRequest"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53HostedZones struct {
+	HostedZones []struct {
+		ID   string `xml:"Id"`
+		Name string `xml:"Name"`
+	} `xml:"HostedZones>HostedZone"`
+}
+
+type route53RecordSets struct {
+	RecordSets []route53ResourceRecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+}
+
+// zoneID returns the ID of the hosted zone of a domain, e.g. Z123 for the
+// /hostedzone/Z123 zone.
+func (r *route53) zoneID(zone string) (string, error) {
+	zones := &route53HostedZones{}
+	req := r.NewRequest(&request.Operation{
+		Name:       "ListHostedZonesByName",
+		HTTPMethod: "GET",
+		HTTPPath:   fmt.Sprintf("/%s/hostedzonesbyname?dnsname=%s&maxitems=1", route53APIVersion, url.QueryEscape(fqdn(zone))),
+	}, nil, zones)
+	if err := req.Send(); err != nil {
+		return "", err
+	}
+
+	for _, z := range zones.HostedZones {
+		if z.Name == fqdn(zone) {
+			return strings.TrimPrefix(z.ID, "/hostedzone/"), nil
+		}
+	}
+
+	return "", fmt.Errorf("Route 53 has no hosted zone %s", zone)
+}
+
+func (r *route53) change(zoneID string, change route53Change) error {
+	req := r.NewRequest(&request.Operation{
+		Name:       "ChangeResourceRecordSets",
+		HTTPMethod: "POST",
+		HTTPPath:   fmt.Sprintf("/%s/hostedzone/%s/rrset", route53APIVersion, zoneID),
+	}, &route53ChangeRequest{Changes: []route53Change{change}}, nil)
+
+	return req.Send()
+}
+
+func (r *route53) Upsert(record *Record) error {
+	zoneID, err := r.zoneID(record.Zone)
+	if err != nil {
+		return err
+	}
+
+	return r.change(zoneID, route53Change{
+		Action: "UPSERT",
+		ResourceRecordSet: route53ResourceRecordSet{
+			Name:   fqdn(record.Name),
+			Type:   record.Type,
+			TTL:    record.TTL,
+			Values: []string{record.Value},
+		},
+	})
+}
+
+// Delete removes the record set as it currently is, since Route 53 only
+// deletes a record set given with its exact TTL and values.
+func (r *route53) Delete(record *Record) error {
+	zoneID, err := r.zoneID(record.Zone)
+	if err != nil {
+		return err
+	}
+
+	sets := &route53RecordSets{}
+	req := r.NewRequest(&request.Operation{
+		Name:       "ListResourceRecordSets",
+		HTTPMethod: "GET",
+		HTTPPath: fmt.Sprintf("/%s/hostedzone/%s/rrset?name=%s&type=%s&maxitems=1", route53APIVersion, zoneID,
+			url.QueryEscape(fqdn(record.Name)), record.Type),
+	}, nil, sets)
+	if err := req.Send(); err != nil {
+		return err
+	}
+
+	for _, set := range sets.RecordSets {
+		if set.Name == fqdn(record.Name) && set.Type == record.Type {
+			return r.change(zoneID, route53Change{Action: "DELETE", ResourceRecordSet: set})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package drivers
+
+import "errors"
+
+var ErrArchitectureNotSupported = errors.New("The driver can't report the architecture of its machines")
+
+// ArchitectureReporter is implemented by the drivers which know the
+// architecture of their machines from the provider or the machine itself,
+// e.g. from the image or the instance type.
+type ArchitectureReporter interface {
+	// GetArchitecture returns the architecture of the machine, named like
+	// GOARCH, e.g. amd64 or arm64
+	GetArchitecture() (string, error)
+}
+
+// GetArchitecture returns the architecture of the machine of a driver.
+func GetArchitecture(d Driver) (string, error) {
+	reporter, ok := d.(ArchitectureReporter)
+	if !ok {
+		return "", ErrArchitectureNotSupported
+	}
+
+	return reporter.GetArchitecture()
+}
@@ -19,10 +19,13 @@ type BaseDriver struct {
 	SSHUser        string
 	SSHPort        int
 	SSHKeyPath     string
+	SSHProxyJump   string `json:",omitempty"`
 	StorePath      string
+	StoreID        string `json:",omitempty"`
 	SwarmMaster    bool
 	SwarmHost      string
 	SwarmDiscovery string
+	UseIPv6        bool `json:",omitempty"`
 }
 
 // DriverName returns the name of the driver
@@ -68,6 +71,11 @@ func (d *BaseDriver) GetSSHUsername() string {
 	return d.SSHUser
 }
 
+// GetSSHProxyJump returns the bastion the SSH connections go through, if any
+func (d *BaseDriver) GetSSHProxyJump() string {
+	return d.SSHProxyJump
+}
+
 // PreCreateCheck is called to enforce pre-creation steps
 func (d *BaseDriver) PreCreateCheck() error {
 	return nil
@@ -85,6 +93,22 @@ func (d *BaseDriver) SetSwarmConfigFromFlags(flags DriverOptions) {
 	d.SwarmDiscovery = flags.String("swarm-discovery")
 }
 
+// SetIPFamilyFromFlags configures the driver to reach the machine over IPv6
+// with --use-ipv6, when the machine has both an IPv4 and an IPv6 address.
+func (d *BaseDriver) SetIPFamilyFromFlags(flags DriverOptions) {
+	d.UseIPv6 = flags.Bool("use-ipv6")
+}
+
+// PreferredIP returns the address the machine is reached through: its IPv6
+// address with --use-ipv6 and its IPv4 address otherwise, or the only one it
+// has, e.g. on IPv6-only instances.
+func (d *BaseDriver) PreferredIP(ipv4, ipv6 string) string {
+	if (d.UseIPv6 && ipv6 != "") || ipv4 == "" {
+		return ipv6
+	}
+	return ipv4
+}
+
 func EngineInstallURLFlagSet(flags DriverOptions) bool {
 	engineInstallURLFlag := flags.String("engine-install-url")
 	return engineInstallURLFlag != DefaultEngineInstallURL && engineInstallURLFlag != ""
@@ -28,6 +28,25 @@ func TestIP(t *testing.T) {
 	}
 }
 
+func TestPreferredIP(t *testing.T) {
+	cases := []struct {
+		useIPv6    bool
+		ipv4, ipv6 string
+		expectedIP string
+	}{
+		{false, "192.168.0.1", "2001:db8::1", "192.168.0.1"},
+		{true, "192.168.0.1", "2001:db8::1", "2001:db8::1"},
+		{true, "192.168.0.1", "", "192.168.0.1"},
+		{false, "", "2001:db8::1", "2001:db8::1"},
+		{false, "", "", ""},
+	}
+
+	for _, c := range cases {
+		d := &BaseDriver{UseIPv6: c.useIPv6}
+		assert.Equal(t, c.expectedIP, d.PreferredIP(c.ipv4, c.ipv6))
+	}
+}
+
 func TestEngineInstallUrlFlagEmpty(t *testing.T) {
 	assert.False(t, EngineInstallURLFlagSet(&CheckDriverOptions{}))
 }
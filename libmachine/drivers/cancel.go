@@ -0,0 +1,35 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrCreateCancelNotSupported = errors.New("The driver does not support canceling the creation of its machines")
+
+// ContextCreator is implemented by the drivers which report the progress of
+// the creation of a machine and stop it when asked to.
+type ContextCreator interface {
+	// CreateContext creates the machine like Create, calling progress with
+	// a message at each step.  It returns ctx.Err() if ctx is canceled
+	// before the machine is created, after removing what was already
+	// created.
+	CreateContext(ctx context.Context, progress func(string)) error
+}
+
+// CreateCanceler is implemented by the drivers whose running Create can be
+// canceled from another goroutine, e.g. the plugin clients.
+type CreateCanceler interface {
+	CancelCreate() error
+}
+
+// CancelCreate cancels the creation of the machine of a driver, running in
+// Create.
+func CancelCreate(d Driver) error {
+	canceler, ok := d.(CreateCanceler)
+	if !ok {
+		return ErrCreateCancelNotSupported
+	}
+
+	return canceler.CancelCreate()
+}
@@ -0,0 +1,63 @@
+package drivers
+
+// Capabilities are the features a driver supports, which the CLI checks
+// before it's asked for them, e.g. with create --user-data.
+type Capabilities struct {
+	// Snapshots is the driver implementing Snapshotter
+	Snapshots bool
+
+	// Resize is the driver implementing Resizer
+	Resize bool
+
+	// PrivateNetworking is the driver attaching its machines to private
+	// networks, implementing PrivateNetworker
+	PrivateNetworking bool
+
+	// Spot is the driver creating its machines on spot or preemptible
+	// instances, with flags of its own
+	Spot bool
+
+	// IPv6 is the driver reaching its machines over IPv6 with --use-ipv6
+	IPv6 bool
+
+	// UserData is the driver passing cloud-init user data to its machines
+	// with --user-data
+	UserData bool
+
+	// RawOperations is the driver running operations of its provider with
+	// driver-exec, implementing RawClient
+	RawOperations bool
+}
+
+// CapabilityReporter is implemented by the drivers which support features
+// Machine can't find from the interfaces they implement, e.g. spot
+// instances, and by the drivers wrapping another driver.  The capabilities
+// they report are all of theirs, usually ImplementedCapabilities plus their
+// own.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// GetCapabilities returns the features the driver supports.
+func GetCapabilities(d Driver) Capabilities {
+	if reporter, ok := d.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+
+	return ImplementedCapabilities(d)
+}
+
+// ImplementedCapabilities returns the features of a driver given by the
+// interfaces it implements.
+func ImplementedCapabilities(d Driver) Capabilities {
+	_, snapshots := d.(Snapshotter)
+	_, privateNetworking := d.(PrivateNetworker)
+	_, rawOperations := d.(RawClient)
+
+	return Capabilities{
+		Snapshots:         snapshots,
+		Resize:            SupportsResize(d),
+		PrivateNetworking: privateNetworking,
+		RawOperations:     rawOperations,
+	}
+}
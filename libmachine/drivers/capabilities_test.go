@@ -0,0 +1,42 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MockPrivateNetworkDriver struct {
+	*MockDriver
+}
+
+func (d *MockPrivateNetworkDriver) GetPrivateIP() (string, error) {
+	return "10.0.0.2", nil
+}
+
+type MockSpotDriver struct {
+	*MockPrivateNetworkDriver
+}
+
+func (d *MockSpotDriver) Capabilities() Capabilities {
+	capabilities := ImplementedCapabilities(d)
+	capabilities.Spot = true
+	return capabilities
+}
+
+func TestGetCapabilities(t *testing.T) {
+	assert.Equal(t, Capabilities{}, GetCapabilities(&MockDriver{}))
+
+	assert.Equal(t, Capabilities{PrivateNetworking: true}, GetCapabilities(&MockPrivateNetworkDriver{&MockDriver{}}))
+
+	assert.Equal(t, Capabilities{PrivateNetworking: true, Spot: true}, GetCapabilities(&MockSpotDriver{&MockPrivateNetworkDriver{&MockDriver{}}}))
+}
+
+func TestSerialDriverCapabilities(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockSpotDriver{&MockPrivateNetworkDriver{&MockDriver{calls: callRecorder}}}, &MockLocker{calls: callRecorder})
+
+	assert.Equal(t, Capabilities{PrivateNetworking: true, Spot: true}, GetCapabilities(driver))
+	assert.Equal(t, []string{"Lock", "Unlock"}, callRecorder.calls)
+}
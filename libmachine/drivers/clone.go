@@ -0,0 +1,17 @@
+package drivers
+
+import "errors"
+
+var ErrCloneNotSupported = errors.New("The driver does not support cloning the disk of its machines")
+
+// Cloner is implemented by the drivers which can create a machine from a copy
+// of the disk of another machine, e.g. from an image of a cloud provider.
+type Cloner interface {
+	// CloneDisk copies the disk of the machine and returns the identifier
+	// of the copy, e.g. the ID of an image
+	CloneDisk(name string) (string, error)
+
+	// SetClonedDisk makes Create create the machine from a copy of a disk
+	// returned by CloneDisk instead of its usual image
+	SetClonedDisk(disk string) error
+}
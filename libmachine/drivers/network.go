@@ -0,0 +1,25 @@
+package drivers
+
+import "errors"
+
+var ErrPrivateIPNotSupported = errors.New("The driver does not support private networks")
+
+// PrivateNetworker is implemented by the drivers whose machines can be
+// attached to private networks, where they have another address than the one
+// Machine reaches them with.
+type PrivateNetworker interface {
+	// GetPrivateIP returns the address of the machine on its first private
+	// network
+	GetPrivateIP() (string, error)
+}
+
+// GetPrivateIP returns the address of the machine of a driver on its private
+// network.
+func GetPrivateIP(d Driver) (string, error) {
+	networker, ok := d.(PrivateNetworker)
+	if !ok {
+		return "", ErrPrivateIPNotSupported
+	}
+
+	return networker.GetPrivateIP()
+}
@@ -0,0 +1,24 @@
+package drivers
+
+import "errors"
+
+var ErrPlanNotSupported = errors.New("The driver can't plan the creation of its machines")
+
+// Planner is implemented by the drivers which can check a creation against
+// the provider without creating anything, e.g. that the image exists, that
+// the size is available in the region and that the quota of the account
+// allows one more machine.
+type Planner interface {
+	// PlanCreate returns the steps Create would run, or why it would fail
+	PlanCreate() ([]string, error)
+}
+
+// PlanCreate returns the steps the driver would run to create its machine.
+func PlanCreate(d Driver) ([]string, error) {
+	planner, ok := d.(Planner)
+	if !ok {
+		return nil, ErrPlanNotSupported
+	}
+
+	return planner.PlanCreate()
+}
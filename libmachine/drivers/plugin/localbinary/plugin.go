@@ -18,9 +18,9 @@ var (
 	defaultTimeout               = 10 * time.Second
 	CurrentBinaryIsDockerMachine = false
 	CoreDrivers                  = [...]string{"amazonec2", "azure", "digitalocean",
-		"exoscale", "generic", "google", "hyperv", "none", "openstack",
-		"rackspace", "softlayer", "virtualbox", "vmwarefusion",
-		"vmwarevcloudair", "vmwarevsphere"}
+		"equinixmetal", "exoscale", "firecracker", "generic", "google", "hetzner", "hyperv", "kvm", "linode", "lxd",
+		"none", "oci", "openstack", "proxmox", "rackspace", "scaleway", "softlayer", "virtualbox",
+		"vmwarefusion", "vmwarevcloudair", "vmwarevsphere", "vultr", "vzmac"}
 )
 
 const (
@@ -29,6 +29,11 @@ const (
 	PluginEnvKey        = "MACHINE_PLUGIN_TOKEN"
 	PluginEnvVal        = "42"
 	PluginEnvDriverName = "MACHINE_PLUGIN_DRIVER_NAME"
+
+	// PluginEnvProtocol asks the plugins to serve the protocol over gRPC.
+	// The plugins built before it was used ignore it and serve net/rpc.
+	PluginEnvProtocol  = "MACHINE_PLUGIN_PROTOCOL"
+	PluginProtocolGRPC = "grpc"
 )
 
 type PluginStreamer interface {
@@ -151,6 +156,7 @@ func (lbe *Executor) Start() (*bufio.Scanner, *bufio.Scanner, error) {
 
 	os.Setenv(PluginEnvKey, PluginEnvVal)
 	os.Setenv(PluginEnvDriverName, lbe.DriverName)
+	os.Setenv(PluginEnvProtocol, PluginProtocolGRPC)
 
 	if err := lbe.cmd.Start(); err != nil {
 		return nil, nil, fmt.Errorf("Error starting plugin binary: %s", err)
@@ -202,12 +208,14 @@ func (lbp *Plugin) execServer() error {
 	stdOutCh := lbp.AttachStream(outScanner)
 	stdErrCh := lbp.AttachStream(errScanner)
 
+	pluginLog := log.WithFields(log.Fields{"machine": lbp.MachineName})
+
 	for {
 		select {
 		case out := <-stdOutCh:
-			log.Infof(pluginOut, lbp.MachineName, out)
+			pluginLog.Infof(pluginOut, lbp.MachineName, out)
 		case err := <-stdErrCh:
-			log.Debugf(pluginErr, lbp.MachineName, err)
+			pluginLog.Debugf(pluginErr, lbp.MachineName, err)
 		case <-lbp.stopCh:
 			if err := lbp.Executor.Close(); err != nil {
 				return fmt.Errorf("Error closing local plugin binary: %s", err)
@@ -33,9 +33,6 @@ Please use this plugin through the main 'docker-machine' binary.
 	os.Setenv("MACHINE_DEBUG", "1")
 
 	rpcd := rpcdriver.NewRPCServerDriver(d)
-	rpc.RegisterName(rpcdriver.RPCServiceNameV0, rpcd)
-	rpc.RegisterName(rpcdriver.RPCServiceNameV1, rpcd)
-	rpc.HandleHTTP()
 
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -44,9 +41,20 @@ Please use this plugin through the main 'docker-machine' binary.
 	}
 	defer listener.Close()
 
-	fmt.Println(listener.Addr())
+	// The versions of Machine which don't ask for gRPC only speak net/rpc
+	if os.Getenv(localbinary.PluginEnvProtocol) == localbinary.PluginProtocolGRPC {
+		fmt.Println(rpcdriver.GRPCAddressPrefix + listener.Addr().String())
 
-	go http.Serve(listener, nil)
+		go rpcdriver.NewGRPCServer(rpcd).Serve(listener)
+	} else {
+		rpc.RegisterName(rpcdriver.RPCServiceNameV0, rpcd)
+		rpc.RegisterName(rpcdriver.RPCServiceNameV1, rpcd)
+		rpc.HandleHTTP()
+
+		fmt.Println(listener.Addr())
+
+		go http.Serve(listener, nil)
+	}
 
 	for {
 		select {
@@ -0,0 +1,51 @@
+package drivers
+
+import "context"
+
+// Progress is the step a long-running operation of a driver is at, e.g. the
+// creation of a machine.
+type Progress struct {
+	// Phase describes the step, e.g. "Downloading the ISO"
+	Phase string
+
+	// Percent is the completion of the step from 0 to 100, or -1 when the
+	// driver can't tell.
+	Percent int
+}
+
+// ProgressFunc is called with the progress of an operation.
+type ProgressFunc func(progress Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context carrying the function the progress of the
+// operation running with it is reported to, with ReportProgress.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress reports the progress of the operation running with ctx, for
+// the drivers implementing ContextCreator.  It does nothing when nobody
+// listens to it.
+func ReportProgress(ctx context.Context, phase string, percent int) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(Progress{Phase: phase, Percent: percent})
+	}
+}
+
+// ProgressNotifier is implemented by the drivers which notify the progress
+// reported during Create to a function, e.g. the plugin clients.
+type ProgressNotifier interface {
+	NotifyProgress(fn ProgressFunc)
+}
+
+// NotifyProgress has the progress of the creation of the machine of a driver
+// notified to fn, and returns whether the driver supports it.
+func NotifyProgress(d Driver, fn ProgressFunc) bool {
+	notifier, ok := d.(ProgressNotifier)
+	if ok {
+		notifier.NotifyProgress(fn)
+	}
+
+	return ok
+}
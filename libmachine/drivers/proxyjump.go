@@ -0,0 +1,28 @@
+package drivers
+
+import "github.com/docker/machine/libmachine/ssh"
+
+// SSHProxyJumper is implemented by the drivers whose machines can be reached
+// over SSH through a bastion host, e.g. on a private subnet.
+type SSHProxyJumper interface {
+	// GetSSHProxyJump returns the bastion as [user@]host[:port], or an
+	// empty string if the machine is reached directly
+	GetSSHProxyJump() string
+}
+
+// GetSSHProxyJump returns the bastion the SSH connections to the machine of a
+// driver go through, or nil if there's none.  The user of the bastion
+// defaults to the SSH user of the machine.
+func GetSSHProxyJump(d Driver) (*ssh.ProxyJump, error) {
+	jumper, ok := d.(SSHProxyJumper)
+	if !ok {
+		return nil, nil
+	}
+
+	proxyJump := jumper.GetSSHProxyJump()
+	if proxyJump == "" {
+		return nil, nil
+	}
+
+	return ssh.ParseProxyJump(proxyJump, d.GetSSHUsername())
+}
@@ -0,0 +1,65 @@
+package drivers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrRawClientNotSupported = errors.New("The driver has no operations of its provider to run")
+
+// RawOperation is an operation of the provider of a driver which Machine has
+// no command for, run with driver-exec.
+type RawOperation struct {
+	// Name is the name given to driver-exec, e.g. "attach-volume"
+	Name string
+
+	// Usage describes what the operation does and its JSON arguments
+	Usage string
+}
+
+// RawClient is implemented by the drivers giving access to operations of
+// their provider API which Machine has no command for, e.g. attaching a
+// volume or changing the security groups of an instance.  The arguments and
+// the result of an operation are JSON, so that the plugins run them too.  The
+// driver updates its configuration with what an operation changes, e.g. the
+// security groups, which is then saved with the machine.
+type RawClient interface {
+	// RawOperations returns the operations the driver runs
+	RawOperations() []RawOperation
+
+	// RunRawOperation runs an operation on the machine, given its JSON
+	// arguments, and returns its JSON result, if any
+	RunRawOperation(name string, args []byte) ([]byte, error)
+}
+
+// RawOperations returns the operations of the provider a driver runs.
+func RawOperations(d Driver) ([]RawOperation, error) {
+	client, ok := d.(RawClient)
+	if !ok {
+		return nil, ErrRawClientNotSupported
+	}
+
+	return client.RawOperations(), nil
+}
+
+// RunRawOperation runs an operation of the provider of a driver.
+func RunRawOperation(d Driver, name string, args []byte) ([]byte, error) {
+	client, ok := d.(RawClient)
+	if !ok {
+		return nil, ErrRawClientNotSupported
+	}
+
+	return client.RunRawOperation(name, args)
+}
+
+// UnknownRawOperation returns the error of the drivers given an operation
+// they don't run.
+func UnknownRawOperation(name string, operations []RawOperation) error {
+	names := []string{}
+	for _, operation := range operations {
+		names = append(names, operation.Name)
+	}
+
+	return fmt.Errorf("unknown operation %q, expected one of %s", name, strings.Join(names, ", "))
+}
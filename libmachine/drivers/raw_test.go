@@ -0,0 +1,54 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MockRawDriver struct {
+	*MockDriver
+	ran []string
+}
+
+func (d *MockRawDriver) RawOperations() []RawOperation {
+	return []RawOperation{{Name: "attach-volume"}, {Name: "detach-volume"}}
+}
+
+func (d *MockRawDriver) RunRawOperation(name string, args []byte) ([]byte, error) {
+	d.ran = append(d.ran, name+" "+string(args))
+	return []byte(`{}`), nil
+}
+
+func TestRunRawOperation(t *testing.T) {
+	driver := &MockRawDriver{MockDriver: &MockDriver{}}
+
+	result, err := RunRawOperation(driver, "attach-volume", []byte(`{"VolumeId":"vol-1"}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(result))
+	assert.Equal(t, []string{`attach-volume {"VolumeId":"vol-1"}`}, driver.ran)
+	assert.True(t, GetCapabilities(driver).RawOperations)
+
+	_, err = RunRawOperation(&MockDriver{}, "attach-volume", nil)
+
+	assert.Equal(t, ErrRawClientNotSupported, err)
+}
+
+func TestSerialDriverRawOperations(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockRawDriver{MockDriver: &MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+
+	operations, err := RawOperations(driver)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []RawOperation{{Name: "attach-volume"}, {Name: "detach-volume"}}, operations)
+	assert.Equal(t, []string{"Lock", "Unlock"}, callRecorder.calls)
+}
+
+func TestUnknownRawOperation(t *testing.T) {
+	err := UnknownRawOperation("resize-volume", []RawOperation{{Name: "attach-volume"}, {Name: "detach-volume"}})
+
+	assert.EqualError(t, err, `unknown operation "resize-volume", expected one of attach-volume, detach-volume`)
+}
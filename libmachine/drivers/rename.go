@@ -0,0 +1,31 @@
+package drivers
+
+import "errors"
+
+var ErrRenameNotSupported = errors.New("The driver does not support renaming its machines")
+
+// Renamer is implemented by the drivers whose machines can be renamed: once
+// renamed, the driver is given the new name of the machine and the new
+// directory of its files, and must still find the resources of the machine,
+// whether Rename renamed them or not.
+type Renamer interface {
+	// Rename renames the resources named after the machine at the
+	// provider, e.g. the name tag of an instance, if there are any
+	Rename(name string) error
+}
+
+// RenameSupporter is implemented by the drivers wrapping another driver,
+// which implement Renamer whether the wrapped driver does or not.
+type RenameSupporter interface {
+	SupportsRename() bool
+}
+
+// SupportsRename returns whether the machines of a driver can be renamed.
+func SupportsRename(d Driver) bool {
+	if supporter, ok := d.(RenameSupporter); ok {
+		return supporter.SupportsRename()
+	}
+
+	_, ok := d.(Renamer)
+	return ok
+}
@@ -0,0 +1,29 @@
+package drivers
+
+import "errors"
+
+var ErrResizeNotSupported = errors.New("The driver does not support resizing its machines")
+
+// Resizer is implemented by the drivers which can change the size of their
+// machines, e.g. the instance type of a cloud provider.
+type Resizer interface {
+	// Resize changes the size of the stopped machine, given as the
+	// provider names it, e.g. a Droplet size slug or an EC2 instance type
+	Resize(size string) error
+}
+
+// ResizeSupporter is implemented by the drivers wrapping another driver,
+// which implement Resizer whether the wrapped driver does or not.
+type ResizeSupporter interface {
+	SupportsResize() bool
+}
+
+// SupportsResize returns whether the machines of a driver can be resized.
+func SupportsResize(d Driver) bool {
+	if supporter, ok := d.(ResizeSupporter); ok {
+		return supporter.SupportsResize()
+	}
+
+	_, ok := d.(Resizer)
+	return ok
+}
@@ -0,0 +1,57 @@
+package drivers
+
+import "errors"
+
+var ErrManagedResourcesNotSupported = errors.New("The driver can't list the resources it created")
+
+// ManagedResource is a resource of the provider created by the driver for a
+// machine, e.g. an instance, a key pair or a security group.
+type ManagedResource struct {
+	// Type is the kind of resource, e.g. "instance" or "key-pair"
+	Type string
+
+	// ID identifies the resource for the provider
+	ID string
+
+	// Machine is the name of the machine the resource was created for
+	Machine string
+
+	// Description tells the resource apart for the user, e.g. its name
+	// or its state
+	Description string
+}
+
+// ResourceManager is implemented by the drivers which tag the resources they
+// create with the name of their machine and the StoreID of its store, so that
+// the ones left behind by the machines removed from the store can be found
+// and deleted.
+type ResourceManager interface {
+	// ListManagedResources returns the resources created by the driver
+	// with the credentials and in the region it's configured with for the
+	// machines of its StoreID, whatever their machine
+	ListManagedResources() ([]ManagedResource, error)
+
+	// DeleteManagedResource deletes a resource returned by
+	// ListManagedResources
+	DeleteManagedResource(resource ManagedResource) error
+}
+
+// ListManagedResources returns the resources created by a driver.
+func ListManagedResources(d Driver) ([]ManagedResource, error) {
+	manager, ok := d.(ResourceManager)
+	if !ok {
+		return nil, ErrManagedResourcesNotSupported
+	}
+
+	return manager.ListManagedResources()
+}
+
+// DeleteManagedResource deletes a resource created by a driver.
+func DeleteManagedResource(d Driver, resource ManagedResource) error {
+	manager, ok := d.(ResourceManager)
+	if !ok {
+		return ErrManagedResourcesNotSupported
+	}
+
+	return manager.DeleteManagedResource(resource)
+}
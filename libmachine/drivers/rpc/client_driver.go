@@ -1,8 +1,11 @@
 package rpcdriver
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/rpc"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/version"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -41,6 +45,11 @@ type RPCClientDriver struct {
 	plugin          localbinary.DriverPlugin
 	heartbeatDoneCh chan bool
 	Client          *InternalClient
+	capabilities    []string
+	progressFunc    drivers.ProgressFunc
+
+	createLock   sync.Mutex
+	createStream grpc.ClientStream
 }
 
 type RPCCall struct {
@@ -49,9 +58,12 @@ type RPCCall struct {
 	Reply         interface{}
 }
 
+// InternalClient calls the methods of a plugin over gRPC, with GRPCConn, or
+// over net/rpc, with RPCClient, for the plugins built before gRPC was used.
 type InternalClient struct {
 	MachineName    string
 	RPCClient      *rpc.Client
+	GRPCConn       *grpc.ClientConn
 	rpcServiceName string
 }
 
@@ -59,39 +71,67 @@ const (
 	RPCServiceNameV0 = `RpcServerDriver`
 	RPCServiceNameV1 = `RPCServerDriver`
 
-	HeartbeatMethod          = `.Heartbeat`
-	GetVersionMethod         = `.GetVersion`
-	CloseMethod              = `.Close`
-	GetCreateFlagsMethod     = `.GetCreateFlags`
-	SetConfigRawMethod       = `.SetConfigRaw`
-	GetConfigRawMethod       = `.GetConfigRaw`
-	DriverNameMethod         = `.DriverName`
-	SetConfigFromFlagsMethod = `.SetConfigFromFlags`
-	GetURLMethod             = `.GetURL`
-	GetMachineNameMethod     = `.GetMachineName`
-	GetIPMethod              = `.GetIP`
-	GetSSHHostnameMethod     = `.GetSSHHostname`
-	GetSSHKeyPathMethod      = `.GetSSHKeyPath`
-	GetSSHPortMethod         = `.GetSSHPort`
-	GetSSHUsernameMethod     = `.GetSSHUsername`
-	GetStateMethod           = `.GetState`
-	PreCreateCheckMethod     = `.PreCreateCheck`
-	CreateMethod             = `.Create`
-	RemoveMethod             = `.Remove`
-	StartMethod              = `.Start`
-	StopMethod               = `.Stop`
-	RestartMethod            = `.Restart`
-	KillMethod               = `.Kill`
-	UpgradeMethod            = `.Upgrade`
+	HeartbeatMethod             = `.Heartbeat`
+	GetVersionMethod            = `.GetVersion`
+	CloseMethod                 = `.Close`
+	GetCreateFlagsMethod        = `.GetCreateFlags`
+	SetConfigRawMethod          = `.SetConfigRaw`
+	GetConfigRawMethod          = `.GetConfigRaw`
+	DriverNameMethod            = `.DriverName`
+	SetConfigFromFlagsMethod    = `.SetConfigFromFlags`
+	GetURLMethod                = `.GetURL`
+	GetMachineNameMethod        = `.GetMachineName`
+	GetIPMethod                 = `.GetIP`
+	GetSSHHostnameMethod        = `.GetSSHHostname`
+	GetSSHKeyPathMethod         = `.GetSSHKeyPath`
+	GetSSHPortMethod            = `.GetSSHPort`
+	GetSSHUsernameMethod        = `.GetSSHUsername`
+	GetStateMethod              = `.GetState`
+	PreCreateCheckMethod        = `.PreCreateCheck`
+	CreateMethod                = `.Create`
+	RemoveMethod                = `.Remove`
+	StartMethod                 = `.Start`
+	StopMethod                  = `.Stop`
+	RestartMethod               = `.Restart`
+	KillMethod                  = `.Kill`
+	UpgradeMethod               = `.Upgrade`
+	CreateSnapshotMethod        = `.CreateSnapshot`
+	ListSnapshotsMethod         = `.ListSnapshots`
+	RestoreSnapshotMethod       = `.RestoreSnapshot`
+	DeleteSnapshotMethod        = `.DeleteSnapshot`
+	CloneDiskMethod             = `.CloneDisk`
+	SetClonedDiskMethod         = `.SetClonedDisk`
+	GetSSHProxyJumpMethod       = `.GetSSHProxyJump`
+	GetCapabilitiesMethod       = `.GetCapabilities`
+	ResizeMethod                = `.Resize`
+	RenameMethod                = `.Rename`
+	GetPrivateIPMethod          = `.GetPrivateIP`
+	PlanCreateMethod            = `.PlanCreate`
+	GetArchitectureMethod       = `.GetArchitecture`
+	ListManagedResourcesMethod  = `.ListManagedResources`
+	DeleteManagedResourceMethod = `.DeleteManagedResource`
+	GetDriverCapabilitiesMethod = `.GetDriverCapabilities`
+	RawOperationsMethod         = `.RawOperations`
+	RunRawOperationMethod       = `.RunRawOperation`
 )
 
 func (ic *InternalClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
 	if serviceMethod != HeartbeatMethod {
 		log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
 	}
+	if ic.GRPCConn != nil {
+		return grpcCallError(ic.GRPCConn.Invoke(context.Background(), grpcMethod(serviceMethod), args, reply))
+	}
 	return ic.RPCClient.Call(ic.rpcServiceName+serviceMethod, args, reply)
 }
 
+// newCreateStream starts the creation of the machine, which streams its
+// progress, over gRPC.
+func (ic *InternalClient) newCreateStream(ctx context.Context) (grpc.ClientStream, error) {
+	log.Debugf("(%s) Calling %s as a stream", ic.MachineName, createStreamName)
+	return ic.GRPCConn.NewStream(ctx, &createStreamDesc, grpcMethod(createStreamName))
+}
+
 func (ic *InternalClient) switchToV0() {
 	ic.rpcServiceName = RPCServiceNameV0
 }
@@ -103,6 +143,13 @@ func NewInternalClient(rpcclient *rpc.Client) *InternalClient {
 	}
 }
 
+// NewGRPCInternalClient returns the client of a plugin serving gRPC.
+func NewGRPCInternalClient(conn *grpc.ClientConn) *InternalClient {
+	return &InternalClient{
+		GRPCConn: conn,
+	}
+}
+
 func (f *DefaultRPCClientDriverFactory) Close() error {
 	f.openedDriversLock.Lock()
 	defer f.openedDriversLock.Unlock()
@@ -139,22 +186,36 @@ func (f *DefaultRPCClientDriverFactory) NewRPCClientDriver(driverName string, ra
 		return nil, fmt.Errorf("Error attempting to get plugin server address for RPC: %s", err)
 	}
 
-	rpcclient, err := rpc.DialHTTP("tcp", addr)
-	if err != nil {
-		return nil, err
-	}
-
 	c := &RPCClientDriver{
-		Client:          NewInternalClient(rpcclient),
 		heartbeatDoneCh: make(chan bool),
 	}
 
+	// The plugins built before gRPC was used ignore the protocol asked by
+	// localbinary and serve net/rpc
+	if strings.HasPrefix(addr, GRPCAddressPrefix) {
+		conn, err := dialGRPC(strings.TrimPrefix(addr, GRPCAddressPrefix))
+		if err != nil {
+			return nil, err
+		}
+		c.Client = NewGRPCInternalClient(conn)
+	} else {
+		rpcclient, err := rpc.DialHTTP("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		c.Client = NewInternalClient(rpcclient)
+	}
+
 	f.openedDriversLock.Lock()
 	f.openedDrivers = append(f.openedDrivers, c)
 	f.openedDriversLock.Unlock()
 
 	var serverVersion int
 	if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
+		if c.Client.GRPCConn != nil {
+			return nil, err
+		}
+
 		// this is the first call we make to the server. We try to play nice with old pre 0.5.1 client,
 		// by gracefully trying old RPCServiceName, we do this only once, and keep the result for future calls.
 		log.Debugf(err.Error())
@@ -170,6 +231,13 @@ func (f *DefaultRPCClientDriverFactory) NewRPCClientDriver(driverName string, ra
 	}
 	log.Debug("Using API Version ", serverVersion)
 
+	if err := c.Client.Call(GetCapabilitiesMethod, struct{}{}, &c.capabilities); err != nil {
+		// The plugins built before capabilities existed have none.
+		log.Debugf("Error getting the plugin capabilities: %s", err)
+		c.capabilities = nil
+	}
+	log.Debugf("Using plugin capabilities %v", c.capabilities)
+
 	go func(c *RPCClientDriver) {
 		for {
 			select {
@@ -217,6 +285,11 @@ func (c *RPCClientDriver) close() error {
 	}
 
 	log.Debug("Successfully made call to close driver server")
+
+	if c.Client.GRPCConn != nil {
+		c.Client.GRPCConn.Close()
+	}
+
 	log.Debug("Making call to close connection to plugin binary")
 
 	return c.plugin.Close()
@@ -323,6 +396,17 @@ func (c *RPCClientDriver) GetSSHUsername() string {
 	return username
 }
 
+// GetSSHProxyJump returns the bastion of the machine.  The plugins built
+// before bastions were supported don't have one.
+func (c *RPCClientDriver) GetSSHProxyJump() string {
+	proxyJump, err := c.rpcStringCall(GetSSHProxyJumpMethod)
+	if err != nil && !strings.HasPrefix(err.Error(), "rpc: can't find method") {
+		log.Warnf("Error attempting call to get SSH proxy jump: %s", err)
+	}
+
+	return proxyJump
+}
+
 func (c *RPCClientDriver) GetState() (state.State, error) {
 	var s state.State
 
@@ -337,8 +421,89 @@ func (c *RPCClientDriver) PreCreateCheck() error {
 	return c.Client.Call(PreCreateCheckMethod, struct{}{}, nil)
 }
 
+// HasCapability returns whether the plugin supports a feature of the plugin
+// protocol, e.g. CapabilityCreateCancel.
+func (c *RPCClientDriver) HasCapability(capability string) bool {
+	for _, supported := range c.capabilities {
+		if supported == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Create creates the machine.  Over gRPC, the creation runs in the
+// background of the plugin and its progress is logged until it's done.
 func (c *RPCClientDriver) Create() error {
-	return c.Client.Call(CreateMethod, struct{}{}, nil)
+	if c.Client.GRPCConn == nil {
+		return c.Client.Call(CreateMethod, struct{}{}, nil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.Client.newCreateStream(ctx)
+	if err != nil {
+		return grpcCallError(err)
+	}
+
+	c.setCreateStream(stream)
+	defer c.setCreateStream(nil)
+
+	var last *drivers.Progress
+	for {
+		var progress CreateProgress
+		if err := stream.RecvMsg(&progress); err != nil {
+			return grpcCallError(err)
+		}
+
+		for _, message := range progress.Messages {
+			log.Info(message)
+		}
+
+		if progress.Progress != nil && c.progressFunc != nil && (last == nil || *progress.Progress != *last) {
+			c.progressFunc(*progress.Progress)
+			last = progress.Progress
+		}
+
+		if progress.Done {
+			if progress.Error != "" {
+				return errors.New(progress.Error)
+			}
+			return nil
+		}
+	}
+}
+
+func (c *RPCClientDriver) setCreateStream(stream grpc.ClientStream) {
+	c.createLock.Lock()
+	defer c.createLock.Unlock()
+
+	c.createStream = stream
+}
+
+// NotifyProgress has the progress reported by the driver of the plugin during
+// Create notified to fn.
+func (c *RPCClientDriver) NotifyProgress(fn drivers.ProgressFunc) {
+	c.progressFunc = fn
+}
+
+// CancelCreate cancels the creation running in Create, if the plugin supports
+// it.  The creation goes on until the driver has removed what was already
+// created.
+func (c *RPCClientDriver) CancelCreate() error {
+	if c.Client.GRPCConn == nil || !c.HasCapability(CapabilityCreateCancel) {
+		return drivers.ErrCreateCancelNotSupported
+	}
+
+	c.createLock.Lock()
+	defer c.createLock.Unlock()
+
+	if c.createStream == nil {
+		return errCreateNotStarted
+	}
+
+	return c.createStream.CloseSend()
 }
 
 func (c *RPCClientDriver) Remove() error {
@@ -364,3 +529,199 @@ func (c *RPCClientDriver) Kill() error {
 func (c *RPCClientDriver) Upgrade() error {
 	return c.Client.Call(UpgradeMethod, struct{}{}, nil)
 }
+
+// snapshotCallError restores the error of the drivers without snapshots,
+// which plugins built before snapshots existed report as a missing method.
+func snapshotCallError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if err.Error() == drivers.ErrSnapshotsNotSupported.Error() || strings.HasPrefix(err.Error(), "rpc: can't find method") {
+		return drivers.ErrSnapshotsNotSupported
+	}
+
+	return err
+}
+
+func (c *RPCClientDriver) CreateSnapshot(name string) (*drivers.Snapshot, error) {
+	var snapshot drivers.Snapshot
+
+	if err := c.Client.Call(CreateSnapshotMethod, name, &snapshot); err != nil {
+		return nil, snapshotCallError(err)
+	}
+
+	return &snapshot, nil
+}
+
+func (c *RPCClientDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	var snapshots []drivers.Snapshot
+
+	if err := c.Client.Call(ListSnapshotsMethod, struct{}{}, &snapshots); err != nil {
+		return nil, snapshotCallError(err)
+	}
+
+	return snapshots, nil
+}
+
+func (c *RPCClientDriver) RestoreSnapshot(name string) error {
+	return snapshotCallError(c.Client.Call(RestoreSnapshotMethod, name, nil))
+}
+
+func (c *RPCClientDriver) DeleteSnapshot(name string) error {
+	return snapshotCallError(c.Client.Call(DeleteSnapshotMethod, name, nil))
+}
+
+// cloneCallError restores the error of the drivers which can't clone disks,
+// which plugins built before cloning existed report as a missing method.
+func cloneCallError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if err.Error() == drivers.ErrCloneNotSupported.Error() || strings.HasPrefix(err.Error(), "rpc: can't find method") {
+		return drivers.ErrCloneNotSupported
+	}
+
+	return err
+}
+
+func (c *RPCClientDriver) CloneDisk(name string) (string, error) {
+	var disk string
+
+	if err := c.Client.Call(CloneDiskMethod, name, &disk); err != nil {
+		return "", cloneCallError(err)
+	}
+
+	return disk, nil
+}
+
+func (c *RPCClientDriver) SetClonedDisk(disk string) error {
+	return cloneCallError(c.Client.Call(SetClonedDiskMethod, disk, nil))
+}
+
+// SupportsResize returns whether the driver of the plugin can resize the
+// machine.
+func (c *RPCClientDriver) SupportsResize() bool {
+	return c.HasCapability(CapabilityResize)
+}
+
+func (c *RPCClientDriver) Resize(size string) error {
+	if !c.SupportsResize() {
+		return drivers.ErrResizeNotSupported
+	}
+
+	return c.Client.Call(ResizeMethod, size, nil)
+}
+
+// SupportsRename returns whether the driver of the plugin can rename the
+// machine.
+func (c *RPCClientDriver) SupportsRename() bool {
+	return c.HasCapability(CapabilityRename)
+}
+
+func (c *RPCClientDriver) Rename(name string) error {
+	if !c.SupportsRename() {
+		return drivers.ErrRenameNotSupported
+	}
+
+	return c.Client.Call(RenameMethod, name, nil)
+}
+
+func (c *RPCClientDriver) GetPrivateIP() (string, error) {
+	if !c.HasCapability(CapabilityPrivateIP) {
+		return "", drivers.ErrPrivateIPNotSupported
+	}
+
+	return c.rpcStringCall(GetPrivateIPMethod)
+}
+
+func (c *RPCClientDriver) PlanCreate() ([]string, error) {
+	if !c.HasCapability(CapabilityPlan) {
+		return nil, drivers.ErrPlanNotSupported
+	}
+
+	var steps []string
+	if err := c.Client.Call(PlanCreateMethod, struct{}{}, &steps); err != nil {
+		return nil, err
+	}
+
+	return steps, nil
+}
+
+func (c *RPCClientDriver) GetArchitecture() (string, error) {
+	if !c.HasCapability(CapabilityArchitecture) {
+		return "", drivers.ErrArchitectureNotSupported
+	}
+
+	return c.rpcStringCall(GetArchitectureMethod)
+}
+
+func (c *RPCClientDriver) ListManagedResources() ([]drivers.ManagedResource, error) {
+	if !c.HasCapability(CapabilityManagedResources) {
+		return nil, drivers.ErrManagedResourcesNotSupported
+	}
+
+	var resources []drivers.ManagedResource
+	if err := c.Client.Call(ListManagedResourcesMethod, struct{}{}, &resources); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+func (c *RPCClientDriver) DeleteManagedResource(resource drivers.ManagedResource) error {
+	if !c.HasCapability(CapabilityManagedResources) {
+		return drivers.ErrManagedResourcesNotSupported
+	}
+
+	return c.Client.Call(DeleteManagedResourceMethod, resource, nil)
+}
+
+func (c *RPCClientDriver) RawOperations() []drivers.RawOperation {
+	if !c.HasCapability(CapabilityRawClient) {
+		return nil
+	}
+
+	var operations []drivers.RawOperation
+	if err := c.Client.Call(RawOperationsMethod, struct{}{}, &operations); err != nil {
+		log.Debugf("Error getting the operations of the driver: %s", err)
+		return nil
+	}
+
+	return operations
+}
+
+func (c *RPCClientDriver) RunRawOperation(name string, args []byte) ([]byte, error) {
+	if !c.HasCapability(CapabilityRawClient) {
+		return nil, drivers.ErrRawClientNotSupported
+	}
+
+	var result []byte
+	if err := c.Client.Call(RunRawOperationMethod, RawOperationArgs{Name: name, Args: args}, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Capabilities returns the features the driver of the plugin supports.  The
+// plugins built before they were reported only give those of the plugin
+// protocol, and fail the snapshot calls if their driver doesn't support them.
+func (c *RPCClientDriver) Capabilities() drivers.Capabilities {
+	if c.HasCapability(CapabilityDriverCapabilities) {
+		var capabilities drivers.Capabilities
+		err := c.Client.Call(GetDriverCapabilitiesMethod, struct{}{}, &capabilities)
+		if err == nil {
+			return capabilities
+		}
+		log.Debugf("Error getting the capabilities of the driver: %s", err)
+	}
+
+	return drivers.Capabilities{
+		Snapshots:         true,
+		Resize:            c.HasCapability(CapabilityResize),
+		PrivateNetworking: c.HasCapability(CapabilityPrivateIP),
+		RawOperations:     c.HasCapability(CapabilityRawClient),
+	}
+}
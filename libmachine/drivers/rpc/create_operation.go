@@ -0,0 +1,125 @@
+package rpcdriver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// Over gRPC, the plugins run the creation of the machine in the background
+// and stream its progress until it's done.  With the create-cancel
+// capability, the client can also cancel it in the meantime.  Over net/rpc,
+// Create blocks until the machine is created.
+
+const (
+	// CapabilityCreateCancel is the creation stopping when canceled, for the
+	// drivers implementing drivers.ContextCreator
+	CapabilityCreateCancel = "create-cancel"
+
+	// CapabilityResize is the driver implementing drivers.Resizer
+	CapabilityResize = "resize"
+
+	// CapabilityRename is the driver implementing drivers.Renamer
+	CapabilityRename = "rename"
+
+	// CapabilityPrivateIP is the driver implementing drivers.PrivateNetworker
+	CapabilityPrivateIP = "private-ip"
+
+	// CapabilityPlan is the driver implementing drivers.Planner
+	CapabilityPlan = "plan"
+
+	// CapabilityArchitecture is the driver implementing
+	// drivers.ArchitectureReporter
+	CapabilityArchitecture = "architecture"
+
+	// CapabilityManagedResources is the driver implementing
+	// drivers.ResourceManager
+	CapabilityManagedResources = "managed-resources"
+
+	// CapabilityDriverCapabilities is the plugin reporting the
+	// drivers.Capabilities of its driver
+	CapabilityDriverCapabilities = "driver-capabilities"
+
+	// CapabilityRawClient is the driver implementing drivers.RawClient
+	CapabilityRawClient = "raw-client"
+)
+
+// CreateProgress is what happened in the creation since the last message of
+// the stream.
+type CreateProgress struct {
+	Messages []string
+	Done     bool
+	Error    string
+
+	// Progress is the last progress reported by the driver with
+	// drivers.ReportProgress, if any.
+	Progress *drivers.Progress
+}
+
+// createOperation is the creation of the machine running in the background
+// of the plugin.
+type createOperation struct {
+	lock     sync.Mutex
+	messages []string
+	latest   *drivers.Progress
+	done     bool
+	err      error
+	cancel   context.CancelFunc
+
+	// changed is closed, and replaced, each time the operation changes.
+	changed chan struct{}
+}
+
+func newCreateOperation(cancel context.CancelFunc) *createOperation {
+	return &createOperation{
+		cancel:  cancel,
+		changed: make(chan struct{}),
+	}
+}
+
+func (o *createOperation) update(change func()) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	change()
+
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+func (o *createOperation) report(message string) {
+	o.update(func() {
+		o.messages = append(o.messages, message)
+	})
+}
+
+func (o *createOperation) reportProgress(progress drivers.Progress) {
+	o.update(func() {
+		o.latest = &progress
+	})
+}
+
+func (o *createOperation) finish(err error) {
+	o.update(func() {
+		o.done = true
+		o.err = err
+	})
+}
+
+// progress returns what happened since the first since messages, and a
+// channel closed on the next change.
+func (o *createOperation) progress(since int) (CreateProgress, <-chan struct{}) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	progress := CreateProgress{Done: o.done, Progress: o.latest}
+	if since < len(o.messages) {
+		progress.Messages = append([]string{}, o.messages[since:]...)
+	}
+	if o.err != nil {
+		progress.Error = o.err.Error()
+	}
+
+	return progress, o.changed
+}
@@ -0,0 +1,145 @@
+package rpcdriver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+type contextDriver struct {
+	*fakedriver.Driver
+	started chan bool
+}
+
+func (d *contextDriver) CreateContext(ctx context.Context, progress func(string)) error {
+	progress("Creating instance...")
+	d.started <- true
+
+	<-ctx.Done()
+	progress("Removing instance...")
+	return ctx.Err()
+}
+
+func newTestClientDriver(t *testing.T, d drivers.Driver) *RPCClientDriver {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go NewGRPCServer(NewRPCServerDriver(d)).Serve(listener)
+
+	conn, err := dialGRPC(listener.Addr().String())
+	assert.NoError(t, err)
+
+	c := &RPCClientDriver{Client: NewGRPCInternalClient(conn)}
+	assert.NoError(t, c.Client.Call(GetCapabilitiesMethod, struct{}{}, &c.capabilities))
+
+	return c
+}
+
+// newNetRPCTestClientDriver returns the client of a plugin served over
+// net/rpc, like to the versions of Machine built before gRPC was used.
+func newNetRPCTestClientDriver(t *testing.T, d drivers.Driver) *RPCClientDriver {
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName(RPCServiceNameV1, NewRPCServerDriver(d)))
+
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	c := &RPCClientDriver{Client: NewInternalClient(rpc.NewClient(clientConn))}
+	assert.NoError(t, c.Client.Call(GetCapabilitiesMethod, struct{}{}, &c.capabilities))
+
+	return c
+}
+
+func TestGetCapabilities(t *testing.T) {
+	c := newTestClientDriver(t, &fakedriver.Driver{})
+
+	assert.True(t, c.HasCapability(CapabilityDriverCapabilities))
+	assert.False(t, c.HasCapability(CapabilityCreateCancel))
+	assert.Equal(t, drivers.ErrCreateCancelNotSupported, c.CancelCreate())
+
+	c = newTestClientDriver(t, &contextDriver{Driver: &fakedriver.Driver{}})
+
+	assert.True(t, c.HasCapability(CapabilityCreateCancel))
+	assert.False(t, c.HasCapability(CapabilityPrivateIP))
+
+	_, err := c.GetPrivateIP()
+
+	assert.Equal(t, drivers.ErrPrivateIPNotSupported, err)
+}
+
+func TestCreateWithProgressReturnsDriverError(t *testing.T) {
+	c := newTestClientDriver(t, &panicDriver{returnErr: errors.New("API not available")})
+
+	assert.EqualError(t, c.Create(), "API not available")
+}
+
+func TestCreateOverNetRPC(t *testing.T) {
+	c := newNetRPCTestClientDriver(t, &panicDriver{returnErr: errors.New("API not available")})
+
+	assert.EqualError(t, c.Create(), "API not available")
+
+	c = newNetRPCTestClientDriver(t, &contextDriver{Driver: &fakedriver.Driver{}})
+
+	assert.True(t, c.HasCapability(CapabilityCreateCancel))
+	assert.Equal(t, drivers.ErrCreateCancelNotSupported, c.CancelCreate())
+}
+
+func TestCancelCreate(t *testing.T) {
+	d := &contextDriver{Driver: &fakedriver.Driver{}, started: make(chan bool)}
+	c := newTestClientDriver(t, d)
+
+	createErr := make(chan error)
+	go func() {
+		createErr <- c.Create()
+	}()
+
+	<-d.started
+	assert.NoError(t, c.CancelCreate())
+	assert.EqualError(t, <-createErr, context.Canceled.Error())
+	assert.Equal(t, errCreateNotStarted, c.CancelCreate())
+}
+
+func TestCreateOperationProgress(t *testing.T) {
+	operation := newCreateOperation(func() {})
+	operation.report("Creating instance...")
+	operation.report("Waiting for IP address...")
+
+	progress, changed := operation.progress(1)
+
+	assert.Equal(t, CreateProgress{Messages: []string{"Waiting for IP address..."}}, progress)
+
+	operation.finish(errors.New("timeout"))
+	<-changed
+	progress, _ = operation.progress(2)
+
+	assert.Equal(t, CreateProgress{Done: true, Error: "timeout"}, progress)
+}
+
+type progressDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *progressDriver) CreateContext(ctx context.Context, progress func(string)) error {
+	drivers.ReportProgress(ctx, "Downloading the image", 50)
+	drivers.ReportProgress(ctx, "Downloading the image", 100)
+	return nil
+}
+
+func TestCreateNotifiesProgress(t *testing.T) {
+	c := newTestClientDriver(t, &progressDriver{Driver: &fakedriver.Driver{}})
+
+	notified := []drivers.Progress{}
+	assert.True(t, drivers.NotifyProgress(c, func(progress drivers.Progress) {
+		notified = append(notified, progress)
+	}))
+
+	assert.NoError(t, c.Create())
+	assert.NotEmpty(t, notified)
+	assert.Equal(t, drivers.Progress{Phase: "Downloading the image", Percent: 100}, notified[len(notified)-1])
+}
@@ -0,0 +1,191 @@
+package rpcdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// The plugins started by this version of Machine serve the version 2 of the
+// plugin protocol over gRPC, and the ones started by older versions the
+// version 1 over net/rpc.  Both have the same methods, whose arguments and
+// replies are encoded with gob, apart from Create: over gRPC, it's a stream
+// of the progress of the creation, which the client half-closes to cancel
+// the creation.
+
+const (
+	// GRPCServiceName is the name of the gRPC service of the plugins
+	GRPCServiceName = "machine.plugin.v2.Driver"
+
+	// GRPCAddressPrefix prefixes the address printed by the plugins serving
+	// gRPC
+	GRPCAddressPrefix = "grpc://"
+
+	createStreamName = "Create"
+)
+
+var (
+	errCreateNotStarted = errors.New("The creation of the machine hasn't been started")
+
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+	createStreamDesc = grpc.StreamDesc{
+		StreamName:    createStreamName,
+		Handler:       createStreamHandler,
+		ServerStreams: true,
+		ClientStreams: true,
+	}
+)
+
+// gobCodec encodes the messages of the gRPC service with gob, like net/rpc,
+// so that the flags and the driver options keep their types.
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+// NewGRPCServer returns the gRPC server of the plugin protocol for the
+// driver served by rpcd.
+func NewGRPCServer(rpcd *RPCServerDriver) *grpc.Server {
+	server := grpc.NewServer(grpc.ForceServerCodec(gobCodec{}))
+	server.RegisterService(grpcServiceDesc(rpcd), rpcd)
+	return server
+}
+
+// grpcServiceDesc describes the methods of rpcd which net/rpc serves as the
+// unary methods of the gRPC service, and Create as a stream.
+func grpcServiceDesc(rpcd *RPCServerDriver) *grpc.ServiceDesc {
+	desc := &grpc.ServiceDesc{
+		ServiceName: GRPCServiceName,
+		HandlerType: (*interface{})(nil),
+		Streams:     []grpc.StreamDesc{createStreamDesc},
+	}
+
+	value := reflect.ValueOf(rpcd)
+	for i := 0; i < value.NumMethod(); i++ {
+		name := value.Type().Method(i).Name
+		method := value.Method(i)
+		if name == createStreamName || !isRPCMethod(method.Type()) {
+			continue
+		}
+
+		desc.Methods = append(desc.Methods, grpcMethodDesc(name, method))
+	}
+
+	return desc
+}
+
+// isRPCMethod returns whether a method has the signature of the methods
+// served by net/rpc, i.e. func(args T, reply *R) error.
+func isRPCMethod(t reflect.Type) bool {
+	return t.NumIn() == 2 && t.In(1).Kind() == reflect.Ptr && t.NumOut() == 1 && t.Out(0) == errorType
+}
+
+func grpcMethodDesc(name string, method reflect.Value) grpc.MethodDesc {
+	argsType := method.Type().In(0)
+	replyType := method.Type().In(1).Elem()
+
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			args := reflect.New(argsType)
+			if err := dec(args.Interface()); err != nil {
+				return nil, err
+			}
+
+			reply := reflect.New(replyType)
+			if err, _ := method.Call([]reflect.Value{args.Elem(), reply})[0].Interface().(error); err != nil {
+				return nil, status.Error(codes.Unknown, err.Error())
+			}
+
+			return reply.Interface(), nil
+		},
+	}
+}
+
+func createStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(*RPCServerDriver).createStream(stream)
+}
+
+// dialGRPC connects to the gRPC server of a plugin.
+func dialGRPC(addr string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(gobCodec{})))
+}
+
+// grpcMethod returns the full name of the gRPC method of a method of the
+// plugin protocol, e.g. GetIPMethod.
+func grpcMethod(serviceMethod string) string {
+	return "/" + GRPCServiceName + "/" + strings.TrimPrefix(serviceMethod, ".")
+}
+
+// grpcCallError restores the errors of the driver, which are sent as their
+// message, like net/rpc does.
+func grpcCallError(err error) error {
+	if s, ok := status.FromError(err); ok && s.Code() == codes.Unknown {
+		return errors.New(s.Message())
+	}
+	return err
+}
+
+// createStream creates the machine, sending its progress on the stream until
+// it's done.  The client half-closes the stream to cancel the creation, which
+// then goes on reporting the removal of what was already created.
+func (r *RPCServerDriver) createStream(stream grpc.ServerStream) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	operation := newCreateOperation(cancel)
+
+	go func() {
+		// Returns when the client half-closes the stream or goes away
+		stream.RecvMsg(&struct{}{})
+		operation.cancel()
+	}()
+
+	go func() {
+		operation.finish(r.createContext(drivers.WithProgress(ctx, operation.reportProgress), operation.report))
+	}()
+
+	since := 0
+	var last *drivers.Progress
+	for {
+		progress, changed := operation.progress(since)
+		if len(progress.Messages) > 0 || progress.Progress != last || progress.Done {
+			if err := stream.SendMsg(&progress); err != nil {
+				return err
+			}
+		}
+		since += len(progress.Messages)
+		last = progress.Progress
+
+		if progress.Done {
+			return nil
+		}
+
+		<-changed
+	}
+}
@@ -0,0 +1,60 @@
+package rpcdriver
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+type flagsDriver struct {
+	*fakedriver.Driver
+	region string
+	zones  []string
+	size   int
+}
+
+func (d *flagsDriver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{Name: "flags-region", Value: "us-east-1"},
+		mcnflag.IntFlag{Name: "flags-size", Value: 20},
+	}
+}
+
+func (d *flagsDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.region = flags.String("flags-region")
+	d.zones = flags.StringSlice("flags-zones")
+	d.size = flags.Int("flags-size")
+	return nil
+}
+
+func TestGRPCFlags(t *testing.T) {
+	d := &flagsDriver{Driver: &fakedriver.Driver{}}
+	c := newTestClientDriver(t, d)
+
+	assert.Equal(t, []mcnflag.Flag{
+		&mcnflag.StringFlag{Name: "flags-region", Value: "us-east-1"},
+		&mcnflag.IntFlag{Name: "flags-size", Value: 20},
+	}, c.GetCreateFlags())
+
+	err := c.SetConfigFromFlags(RPCFlags{Values: map[string]interface{}{
+		"flags-region": "eu-west-1",
+		"flags-zones":  []string{"a", "b"},
+		"flags-size":   40,
+	}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", d.region)
+	assert.Equal(t, []string{"a", "b"}, d.zones)
+	assert.Equal(t, 40, d.size)
+}
+
+func TestGRPCDriverError(t *testing.T) {
+	c := newTestClientDriver(t, &fakedriver.Driver{})
+
+	_, err := c.ListSnapshots()
+
+	assert.Equal(t, drivers.ErrSnapshotsNotSupported, err)
+}
@@ -1,6 +1,7 @@
 package rpcdriver
 
 import (
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
@@ -104,6 +105,39 @@ func (r *RPCServerDriver) GetVersion(_ *struct{}, reply *int) error {
 	return nil
 }
 
+// GetCapabilities returns the features of the plugin protocol the driver
+// supports on top of the API version.
+func (r *RPCServerDriver) GetCapabilities(_ *struct{}, reply *[]string) error {
+	capabilities := []string{CapabilityDriverCapabilities}
+	if _, ok := r.ActualDriver.(drivers.ContextCreator); ok {
+		capabilities = append(capabilities, CapabilityCreateCancel)
+	}
+	if _, ok := r.ActualDriver.(drivers.Resizer); ok {
+		capabilities = append(capabilities, CapabilityResize)
+	}
+	if _, ok := r.ActualDriver.(drivers.Renamer); ok {
+		capabilities = append(capabilities, CapabilityRename)
+	}
+	if _, ok := r.ActualDriver.(drivers.PrivateNetworker); ok {
+		capabilities = append(capabilities, CapabilityPrivateIP)
+	}
+	if _, ok := r.ActualDriver.(drivers.Planner); ok {
+		capabilities = append(capabilities, CapabilityPlan)
+	}
+	if _, ok := r.ActualDriver.(drivers.ArchitectureReporter); ok {
+		capabilities = append(capabilities, CapabilityArchitecture)
+	}
+	if _, ok := r.ActualDriver.(drivers.ResourceManager); ok {
+		capabilities = append(capabilities, CapabilityManagedResources)
+	}
+	if _, ok := r.ActualDriver.(drivers.RawClient); ok {
+		capabilities = append(capabilities, CapabilityRawClient)
+	}
+
+	*reply = capabilities
+	return nil
+}
+
 func (r *RPCServerDriver) GetConfigRaw(_ *struct{}, reply *[]byte) error {
 	driverData, err := json.Marshal(r.ActualDriver)
 	if err != nil {
@@ -142,6 +176,16 @@ func (r *RPCServerDriver) Create(_, _ *struct{}) (err error) {
 	return err
 }
 
+func (r *RPCServerDriver) createContext(ctx context.Context, progress func(string)) (err error) {
+	defer trapPanic(&err)
+
+	if creator, ok := r.ActualDriver.(drivers.ContextCreator); ok {
+		return creator.CreateContext(ctx, progress)
+	}
+
+	return r.ActualDriver.Create()
+}
+
 func (r *RPCServerDriver) DriverName(_ *struct{}, reply *string) error {
 	*reply = r.ActualDriver.DriverName()
 	return nil
@@ -181,6 +225,13 @@ func (r *RPCServerDriver) GetSSHUsername(_ *struct{}, reply *string) error {
 	return nil
 }
 
+func (r *RPCServerDriver) GetSSHProxyJump(_ *struct{}, reply *string) error {
+	if jumper, ok := r.ActualDriver.(drivers.SSHProxyJumper); ok {
+		*reply = jumper.GetSSHProxyJump()
+	}
+	return nil
+}
+
 func (r *RPCServerDriver) GetURL(_ *struct{}, reply *string) error {
 	info, err := r.ActualDriver.GetURL()
 	*reply = info
@@ -221,6 +272,156 @@ func (r *RPCServerDriver) Stop(_ *struct{}, _ *struct{}) error {
 	return r.ActualDriver.Stop()
 }
 
+func (r *RPCServerDriver) snapshotter() (drivers.Snapshotter, error) {
+	snapshotter, ok := r.ActualDriver.(drivers.Snapshotter)
+	if !ok {
+		return nil, drivers.ErrSnapshotsNotSupported
+	}
+	return snapshotter, nil
+}
+
+func (r *RPCServerDriver) CreateSnapshot(name string, reply *drivers.Snapshot) error {
+	snapshotter, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := snapshotter.CreateSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	*reply = *snapshot
+
+	return nil
+}
+
+func (r *RPCServerDriver) ListSnapshots(_ *struct{}, reply *[]drivers.Snapshot) error {
+	snapshotter, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := snapshotter.ListSnapshots()
+	*reply = snapshots
+	return err
+}
+
+func (r *RPCServerDriver) RestoreSnapshot(name string, _ *struct{}) error {
+	snapshotter, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	return snapshotter.RestoreSnapshot(name)
+}
+
+func (r *RPCServerDriver) DeleteSnapshot(name string, _ *struct{}) error {
+	snapshotter, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	return snapshotter.DeleteSnapshot(name)
+}
+
+func (r *RPCServerDriver) cloner() (drivers.Cloner, error) {
+	cloner, ok := r.ActualDriver.(drivers.Cloner)
+	if !ok {
+		return nil, drivers.ErrCloneNotSupported
+	}
+	return cloner, nil
+}
+
+func (r *RPCServerDriver) CloneDisk(name string, reply *string) error {
+	cloner, err := r.cloner()
+	if err != nil {
+		return err
+	}
+
+	disk, err := cloner.CloneDisk(name)
+	*reply = disk
+	return err
+}
+
+func (r *RPCServerDriver) SetClonedDisk(disk string, _ *struct{}) error {
+	cloner, err := r.cloner()
+	if err != nil {
+		return err
+	}
+
+	return cloner.SetClonedDisk(disk)
+}
+
+func (r *RPCServerDriver) GetPrivateIP(_ *struct{}, reply *string) error {
+	ip, err := drivers.GetPrivateIP(r.ActualDriver)
+	*reply = ip
+	return err
+}
+
+func (r *RPCServerDriver) Resize(size string, _ *struct{}) error {
+	resizer, ok := r.ActualDriver.(drivers.Resizer)
+	if !ok {
+		return drivers.ErrResizeNotSupported
+	}
+
+	return resizer.Resize(size)
+}
+
+func (r *RPCServerDriver) Rename(name string, _ *struct{}) error {
+	renamer, ok := r.ActualDriver.(drivers.Renamer)
+	if !ok {
+		return drivers.ErrRenameNotSupported
+	}
+
+	return renamer.Rename(name)
+}
+
+func (r *RPCServerDriver) PlanCreate(_ *struct{}, reply *[]string) error {
+	steps, err := drivers.PlanCreate(r.ActualDriver)
+	*reply = steps
+	return err
+}
+
+func (r *RPCServerDriver) GetArchitecture(_ *struct{}, reply *string) error {
+	arch, err := drivers.GetArchitecture(r.ActualDriver)
+	*reply = arch
+	return err
+}
+
+func (r *RPCServerDriver) ListManagedResources(_ *struct{}, reply *[]drivers.ManagedResource) error {
+	resources, err := drivers.ListManagedResources(r.ActualDriver)
+	*reply = resources
+	return err
+}
+
+func (r *RPCServerDriver) DeleteManagedResource(resource drivers.ManagedResource, _ *struct{}) error {
+	return drivers.DeleteManagedResource(r.ActualDriver, resource)
+}
+
+// RawOperationArgs are the arguments of RunRawOperation.
+type RawOperationArgs struct {
+	Name string
+	Args []byte
+}
+
+func (r *RPCServerDriver) RawOperations(_ *struct{}, reply *[]drivers.RawOperation) error {
+	operations, err := drivers.RawOperations(r.ActualDriver)
+	*reply = operations
+	return err
+}
+
+func (r *RPCServerDriver) RunRawOperation(args RawOperationArgs, reply *[]byte) error {
+	result, err := drivers.RunRawOperation(r.ActualDriver, args.Name, args.Args)
+	*reply = result
+	return err
+}
+
+func (r *RPCServerDriver) GetDriverCapabilities(_ *struct{}, reply *drivers.Capabilities) error {
+	*reply = drivers.GetCapabilities(r.ActualDriver)
+	return nil
+}
+
 func (r *RPCServerDriver) Heartbeat(_ *struct{}, _ *struct{}) error {
 	r.HeartbeatCh <- true
 	return nil
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -73,3 +74,189 @@ func TestRPCServerDriverCreate(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, tc.serverDriver.Create(nil, nil))
 	}
 }
+
+type privateNetworkDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *privateNetworkDriver) GetPrivateIP() (string, error) {
+	return "10.0.0.2", nil
+}
+
+func TestGetPrivateIP(t *testing.T) {
+	c := newTestClientDriver(t, &privateNetworkDriver{Driver: &fakedriver.Driver{}})
+
+	assert.True(t, c.HasCapability(CapabilityPrivateIP))
+
+	ip, err := c.GetPrivateIP()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", ip)
+}
+
+type renameDriver struct {
+	*fakedriver.Driver
+	renamed string
+}
+
+func (d *renameDriver) Rename(name string) error {
+	d.renamed = name
+	return nil
+}
+
+func TestRename(t *testing.T) {
+	driver := &renameDriver{Driver: &fakedriver.Driver{}}
+	c := newTestClientDriver(t, driver)
+
+	assert.True(t, drivers.SupportsRename(c))
+	assert.NoError(t, c.Rename("dev2"))
+	assert.Equal(t, "dev2", driver.renamed)
+
+	c = newTestClientDriver(t, &fakedriver.Driver{})
+
+	assert.False(t, drivers.SupportsRename(c))
+	assert.Equal(t, drivers.ErrRenameNotSupported, c.Rename("dev2"))
+}
+
+type planDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *planDriver) PlanCreate() ([]string, error) {
+	return []string{"Create the instance"}, nil
+}
+
+func TestPlanCreate(t *testing.T) {
+	c := newTestClientDriver(t, &planDriver{Driver: &fakedriver.Driver{}})
+
+	assert.True(t, c.HasCapability(CapabilityPlan))
+
+	steps, err := c.PlanCreate()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Create the instance"}, steps)
+
+	c = newTestClientDriver(t, &fakedriver.Driver{})
+	_, err = c.PlanCreate()
+
+	assert.Equal(t, drivers.ErrPlanNotSupported, err)
+}
+
+type archDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *archDriver) GetArchitecture() (string, error) {
+	return "arm64", nil
+}
+
+func TestGetArchitecture(t *testing.T) {
+	c := newTestClientDriver(t, &archDriver{Driver: &fakedriver.Driver{}})
+
+	assert.True(t, c.HasCapability(CapabilityArchitecture))
+
+	arch, err := c.GetArchitecture()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "arm64", arch)
+
+	c = newTestClientDriver(t, &fakedriver.Driver{})
+	_, err = c.GetArchitecture()
+
+	assert.Equal(t, drivers.ErrArchitectureNotSupported, err)
+}
+
+type resourceDriver struct {
+	*fakedriver.Driver
+	deleted []drivers.ManagedResource
+}
+
+func (d *resourceDriver) ListManagedResources() ([]drivers.ManagedResource, error) {
+	return []drivers.ManagedResource{{Type: "instance", ID: "i-1", Machine: "gone"}}, nil
+}
+
+func (d *resourceDriver) DeleteManagedResource(resource drivers.ManagedResource) error {
+	d.deleted = append(d.deleted, resource)
+	return nil
+}
+
+func TestManagedResources(t *testing.T) {
+	driver := &resourceDriver{Driver: &fakedriver.Driver{}}
+	c := newTestClientDriver(t, driver)
+
+	assert.True(t, c.HasCapability(CapabilityManagedResources))
+
+	resources, err := c.ListManagedResources()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.ManagedResource{{Type: "instance", ID: "i-1", Machine: "gone"}}, resources)
+	assert.NoError(t, c.DeleteManagedResource(resources[0]))
+	assert.Equal(t, resources, driver.deleted)
+
+	c = newTestClientDriver(t, &fakedriver.Driver{})
+	_, err = c.ListManagedResources()
+
+	assert.Equal(t, drivers.ErrManagedResourcesNotSupported, err)
+}
+
+type rawDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *rawDriver) RawOperations() []drivers.RawOperation {
+	return []drivers.RawOperation{{Name: "attach-volume", Usage: "Attach a volume"}}
+}
+
+func (d *rawDriver) RunRawOperation(name string, args []byte) ([]byte, error) {
+	if name != "attach-volume" {
+		return nil, drivers.UnknownRawOperation(name, d.RawOperations())
+	}
+	return append([]byte(`{"State":"attaching","Args":`), append(args, '}')...), nil
+}
+
+func TestRawOperations(t *testing.T) {
+	c := newTestClientDriver(t, &rawDriver{Driver: &fakedriver.Driver{}})
+
+	assert.True(t, c.HasCapability(CapabilityRawClient))
+	assert.Equal(t, []drivers.RawOperation{{Name: "attach-volume", Usage: "Attach a volume"}}, c.RawOperations())
+
+	result, err := c.RunRawOperation("attach-volume", []byte(`{"VolumeId":"vol-1"}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"State":"attaching","Args":{"VolumeId":"vol-1"}}`, string(result))
+
+	_, err = c.RunRawOperation("detach-volume", nil)
+
+	assert.EqualError(t, err, `unknown operation "detach-volume", expected one of attach-volume`)
+
+	c = newTestClientDriver(t, &fakedriver.Driver{})
+
+	assert.Empty(t, c.RawOperations())
+	_, err = c.RunRawOperation("attach-volume", nil)
+	assert.Equal(t, drivers.ErrRawClientNotSupported, err)
+}
+
+type spotDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *spotDriver) GetPrivateIP() (string, error) {
+	return "10.0.0.2", nil
+}
+
+func (d *spotDriver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ImplementedCapabilities(d)
+	capabilities.Spot = true
+	return capabilities
+}
+
+func TestDriverCapabilities(t *testing.T) {
+	c := newTestClientDriver(t, &spotDriver{Driver: &fakedriver.Driver{}})
+
+	assert.True(t, c.HasCapability(CapabilityDriverCapabilities))
+	assert.Equal(t, drivers.Capabilities{PrivateNetworking: true, Spot: true}, drivers.GetCapabilities(c))
+
+	c.capabilities = []string{CapabilityResize}
+
+	assert.Equal(t, drivers.Capabilities{Snapshots: true, Resize: true}, drivers.GetCapabilities(c))
+}
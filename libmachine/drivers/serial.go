@@ -167,6 +167,160 @@ func (d *SerialDriver) Stop() error {
 	return d.Driver.Stop()
 }
 
+// CreateSnapshot takes a snapshot of the machine if the driver supports it
+func (d *SerialDriver) CreateSnapshot(name string) (*Snapshot, error) {
+	snapshotter, ok := d.Driver.(Snapshotter)
+	if !ok {
+		return nil, ErrSnapshotsNotSupported
+	}
+	d.Lock()
+	defer d.Unlock()
+	return snapshotter.CreateSnapshot(name)
+}
+
+// ListSnapshots returns the snapshots of the machine if the driver supports
+// them
+func (d *SerialDriver) ListSnapshots() ([]Snapshot, error) {
+	snapshotter, ok := d.Driver.(Snapshotter)
+	if !ok {
+		return nil, ErrSnapshotsNotSupported
+	}
+	d.Lock()
+	defer d.Unlock()
+	return snapshotter.ListSnapshots()
+}
+
+// RestoreSnapshot reverts the machine to a snapshot if the driver supports it
+func (d *SerialDriver) RestoreSnapshot(name string) error {
+	snapshotter, ok := d.Driver.(Snapshotter)
+	if !ok {
+		return ErrSnapshotsNotSupported
+	}
+	d.Lock()
+	defer d.Unlock()
+	return snapshotter.RestoreSnapshot(name)
+}
+
+// DeleteSnapshot removes a snapshot if the driver supports it
+func (d *SerialDriver) DeleteSnapshot(name string) error {
+	snapshotter, ok := d.Driver.(Snapshotter)
+	if !ok {
+		return ErrSnapshotsNotSupported
+	}
+	d.Lock()
+	defer d.Unlock()
+	return snapshotter.DeleteSnapshot(name)
+}
+
+// CloneDisk copies the disk of the machine if the driver supports it
+func (d *SerialDriver) CloneDisk(name string) (string, error) {
+	cloner, ok := d.Driver.(Cloner)
+	if !ok {
+		return "", ErrCloneNotSupported
+	}
+	d.Lock()
+	defer d.Unlock()
+	return cloner.CloneDisk(name)
+}
+
+// SetClonedDisk makes Create use a copy of a disk if the driver supports it
+func (d *SerialDriver) SetClonedDisk(disk string) error {
+	cloner, ok := d.Driver.(Cloner)
+	if !ok {
+		return ErrCloneNotSupported
+	}
+	d.Lock()
+	defer d.Unlock()
+	return cloner.SetClonedDisk(disk)
+}
+
+// GetSSHProxyJump returns the bastion the SSH connections go through if the
+// driver supports one
+func (d *SerialDriver) GetSSHProxyJump() string {
+	jumper, ok := d.Driver.(SSHProxyJumper)
+	if !ok {
+		return ""
+	}
+	d.Lock()
+	defer d.Unlock()
+	return jumper.GetSSHProxyJump()
+}
+
+// Resize changes the size of the machine if the driver supports it
+func (d *SerialDriver) Resize(size string) error {
+	resizer, ok := d.Driver.(Resizer)
+	if !ok {
+		return ErrResizeNotSupported
+	}
+	d.Lock()
+	defer d.Unlock()
+	return resizer.Resize(size)
+}
+
+// SupportsResize returns whether the driver can resize the machine
+func (d *SerialDriver) SupportsResize() bool {
+	return SupportsResize(d.Driver)
+}
+
+// Capabilities returns the features the driver supports
+func (d *SerialDriver) Capabilities() Capabilities {
+	d.Lock()
+	defer d.Unlock()
+	return GetCapabilities(d.Driver)
+}
+
+// RawOperations returns the operations of the provider the driver runs
+func (d *SerialDriver) RawOperations() []RawOperation {
+	d.Lock()
+	defer d.Unlock()
+	operations, _ := RawOperations(d.Driver)
+	return operations
+}
+
+// RunRawOperation runs an operation of the provider if the driver supports it
+func (d *SerialDriver) RunRawOperation(name string, args []byte) ([]byte, error) {
+	d.Lock()
+	defer d.Unlock()
+	return RunRawOperation(d.Driver, name, args)
+}
+
+// PlanCreate returns the steps Create would run if the driver supports it
+func (d *SerialDriver) PlanCreate() ([]string, error) {
+	d.Lock()
+	defer d.Unlock()
+	return PlanCreate(d.Driver)
+}
+
+// GetArchitecture returns the architecture of the machine if the driver
+// reports it
+func (d *SerialDriver) GetArchitecture() (string, error) {
+	d.Lock()
+	defer d.Unlock()
+	return GetArchitecture(d.Driver)
+}
+
+// ListManagedResources returns the resources created by the driver if it
+// tags them
+func (d *SerialDriver) ListManagedResources() ([]ManagedResource, error) {
+	d.Lock()
+	defer d.Unlock()
+	return ListManagedResources(d.Driver)
+}
+
+// DeleteManagedResource deletes a resource created by the driver if it tags
+// them
+func (d *SerialDriver) DeleteManagedResource(resource ManagedResource) error {
+	d.Lock()
+	defer d.Unlock()
+	return DeleteManagedResource(d.Driver, resource)
+}
+
+// CancelCreate cancels the running Create if the driver supports it.  It
+// doesn't take the lock, which Create holds until it returns.
+func (d *SerialDriver) CancelCreate() error {
+	return CancelCreate(d.Driver)
+}
+
 func (d *SerialDriver) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.Driver)
 }
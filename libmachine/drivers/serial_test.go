@@ -294,3 +294,30 @@ func TestSerialDriverStop(t *testing.T) {
 
 	assert.Equal(t, []string{"Lock", "Stop", "Unlock"}, callRecorder.calls)
 }
+
+type MockCancelableDriver struct {
+	*MockDriver
+}
+
+func (d *MockCancelableDriver) CancelCreate() error {
+	d.calls.record("CancelCreate")
+	return nil
+}
+
+func TestSerialDriverCancelCreateDoesNotLock(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockCancelableDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+
+	assert.NoError(t, CancelCreate(driver))
+	assert.Equal(t, []string{"CancelCreate"}, callRecorder.calls)
+}
+
+func TestSerialDriverCancelCreateNotSupported(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockDriver{calls: callRecorder}, &MockLocker{calls: callRecorder})
+
+	assert.Equal(t, ErrCreateCancelNotSupported, CancelCreate(driver))
+	assert.Empty(t, callRecorder.calls)
+}
@@ -0,0 +1,49 @@
+package drivers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrSnapshotsNotSupported = errors.New("The driver does not support snapshots")
+
+// Snapshot is a point-in-time copy of a machine which it can be restored to.
+type Snapshot struct {
+	// ID identifies the snapshot for the provider
+	ID string
+
+	// Name is given by the user and identifies the snapshot for Machine
+	Name string
+
+	// Created is the creation time, zero if the provider doesn't report it
+	Created time.Time
+}
+
+// Snapshotter is implemented by the drivers which can take snapshots of
+// their machines, e.g. with the snapshots of a hypervisor or the images of a
+// cloud provider.
+type Snapshotter interface {
+	// CreateSnapshot takes a snapshot of the machine
+	CreateSnapshot(name string) (*Snapshot, error)
+
+	// ListSnapshots returns the snapshots of the machine
+	ListSnapshots() ([]Snapshot, error)
+
+	// RestoreSnapshot reverts the machine to a snapshot
+	RestoreSnapshot(name string) error
+
+	// DeleteSnapshot removes a snapshot
+	DeleteSnapshot(name string) error
+}
+
+// FindSnapshot returns the snapshot of a list with the given name or ID.
+func FindSnapshot(snapshots []Snapshot, name string) (*Snapshot, error) {
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name || snapshot.ID == name {
+			return &snapshot, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Snapshot %q does not exist", name)
+}
@@ -2,9 +2,10 @@ package drivers
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/docker/machine/libmachine/log"
-	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/retry"
 	"github.com/docker/machine/libmachine/ssh"
 )
 
@@ -28,7 +29,12 @@ func GetSSHClientFromDriver(d Driver) (ssh.Client, error) {
 		}
 	}
 
-	client, err := ssh.NewClient(d.GetSSHUsername(), address, port, auth)
+	proxyJump, err := GetSSHProxyJump(d)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ssh.NewProxyJumpClient(d.GetSSHUsername(), address, port, auth, proxyJump)
 	return client, err
 
 }
@@ -39,9 +45,17 @@ func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
 		return "", err
 	}
 
+	return RunSSHCommand(client, command)
+}
+
+// RunSSHCommand runs a command with a client, the error including its output.
+// The command is run again when it fails to reach the machine.
+func RunSSHCommand(client ssh.Client, command string) (string, error) {
 	log.Debugf("About to run SSH command:\n%s", command)
 
-	output, err := client.Output(command)
+	output, err := ssh.RetryOutput(func() (string, error) {
+		return client.Output(command)
+	})
 	log.Debugf("SSH cmd err, output: %v: %s", err, output)
 	if err != nil {
 		return "", fmt.Errorf(`Something went wrong running an SSH command!
@@ -54,20 +68,47 @@ output  : %s
 	return output, nil
 }
 
-func sshAvailableFunc(d Driver) func() bool {
-	return func() bool {
+// RunSSHCommandWithInputFromDriver runs a command on the machine of the
+// driver, fed with input on its standard input.
+func RunSSHCommandWithInputFromDriver(d Driver, command string, input io.Reader) (string, error) {
+	client, err := GetSSHClientFromDriver(d)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debugf("About to run SSH command with input:\n%s", command)
+
+	output, err := client.OutputWithInput(command, input)
+	log.Debugf("SSH cmd err, output: %v: %s", err, output)
+	if err != nil {
+		return "", fmt.Errorf(`Something went wrong running an SSH command!
+command : %s
+err     : %v
+output  : %s
+`, command, err, output)
+	}
+
+	return output, nil
+}
+
+func sshAvailableFunc(d Driver) func() error {
+	return func() error {
 		log.Debug("Getting to WaitForSSH function...")
-		if _, err := RunSSHCommandFromDriver(d, "exit 0"); err != nil {
+		client, err := GetSSHClientFromDriver(d)
+		if err == nil {
+			_, err = client.Output("exit 0")
+		}
+		if err != nil {
 			log.Debugf("Error getting ssh command 'exit 0' : %s", err)
-			return false
+			return err
 		}
-		return true
+		return nil
 	}
 }
 
 func WaitForSSH(d Driver) error {
-	// Try to dial SSH for 30 seconds before timing out.
-	if err := mcnutils.WaitFor(sshAvailableFunc(d)); err != nil {
+	// Try to dial SSH within the budget of the ssh phase before timing out.
+	if err := retry.For(retry.SSH).Do(sshAvailableFunc(d)); err != nil {
 		return fmt.Errorf("Too many retries waiting for SSH to be available.  Last error: %s", err)
 	}
 	return nil
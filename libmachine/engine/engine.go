@@ -1,14 +1,41 @@
 package engine
 
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/docker/machine/libmachine/secrets"
+)
+
 const (
 	DefaultPort = 2376
+
+	// RuntimeDocker installs and configures the Docker engine (dockerd).
+	RuntimeDocker = "docker"
+
+	// RuntimePodman installs Podman and exposes its Docker-compatible API
+	// in place of dockerd.
+	RuntimePodman = "podman"
+
+	// RuntimeContainerd installs containerd and nerdctl without dockerd and
+	// exposes the containerd API.
+	RuntimeContainerd = "containerd"
+
+	// GPUNvidia installs the NVIDIA driver and container toolkit, and makes
+	// the NVIDIA runtime the default one of the engine.
+	GPUNvidia = "nvidia"
 )
 
 type Options struct {
-	ArbitraryFlags   []string
-	DNS              []string `json:"Dns"`
-	GraphDir         string
-	Env              []string
+	ArbitraryFlags []string
+	DNS            []string `json:"Dns"`
+	GraphDir       string
+
+	// Env are the environment variables of the engine, whose values may
+	// reference secrets, e.g. vault://secret/data/registry#password,
+	// resolved when the machine is provisioned.
+	Env []string
+
 	Ipv6             bool
 	InsecureRegistry []string
 	Labels           []string
@@ -18,4 +45,86 @@ type Options struct {
 	TLSVerify        bool `json:"TlsVerify"`
 	RegistryMirror   []string
 	InstallURL       string
+
+	// InstallBundle is the path or URL of the tarball of engine packages
+	// or static binaries uploaded to the machine with
+	// --engine-install-bundle, installed in place of running InstallURL.
+	InstallBundle string `json:",omitempty"`
+
+	ContainerRuntime string
+	Rootless         bool
+
+	// Version is the version of the engine pinned with upgrade
+	// --engine-version, empty to follow the latest one.
+	Version string `json:",omitempty"`
+
+	// DaemonConfig is the daemon.json given with --engine-opt-file, merged
+	// into the one generated from the other options.
+	DaemonConfig json.RawMessage `json:",omitempty"`
+
+	// HTTPProxy, HTTPSProxy and NoProxy are the proxy settings of the
+	// engine, set apart from Env so that they can be changed later.
+	HTTPProxy  string `json:",omitempty"`
+	HTTPSProxy string `json:",omitempty"`
+	NoProxy    string `json:",omitempty"`
+
+	// GPU is the vendor of the GPUs whose driver and container runtime are
+	// installed with --provision-gpu, empty for none.
+	GPU string `json:",omitempty"`
+}
+
+// IsPodman returns true if the machine runs Podman instead of dockerd.
+func (o *Options) IsPodman() bool {
+	return o.ContainerRuntime == RuntimePodman
+}
+
+// IsContainerd returns true if the machine runs containerd alone, without
+// dockerd.
+func (o *Options) IsContainerd() bool {
+	return o.ContainerRuntime == RuntimeContainerd
+}
+
+// ProxyEnv returns the proxy settings of the engine as environment
+// variables.
+func (o *Options) ProxyEnv() []string {
+	env := []string{}
+	if o.HTTPProxy != "" {
+		env = append(env, "HTTP_PROXY="+o.HTTPProxy)
+	}
+	if o.HTTPSProxy != "" {
+		env = append(env, "HTTPS_PROXY="+o.HTTPSProxy)
+	}
+	if o.NoProxy != "" {
+		env = append(env, "NO_PROXY="+o.NoProxy)
+	}
+	return env
+}
+
+// PlainEnv returns the environment variables of the engine whose values
+// don't reference secrets.
+func (o Options) PlainEnv() []string {
+	env := []string{}
+	for _, e := range o.Env {
+		if !isSecretEnv(e) {
+			env = append(env, e)
+		}
+	}
+	return env
+}
+
+// SecretEnv returns the environment variables of the engine whose values
+// reference secrets.
+func (o Options) SecretEnv() []string {
+	env := []string{}
+	for _, e := range o.Env {
+		if isSecretEnv(e) {
+			env = append(env, e)
+		}
+	}
+	return env
+}
+
+func isSecretEnv(env string) bool {
+	parts := strings.SplitN(env, "=", 2)
+	return len(parts) == 2 && secrets.IsReference(parts[1])
 }
@@ -0,0 +1,222 @@
+// Package validate checks the options of the engine against the version of
+// the engine and the kernel of the machine, so that the options the engine
+// doesn't support fail the provisioning instead of leaving dockerd
+// crash-looping once it's restarted.
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+)
+
+// Target is the engine and the kernel the options are validated against.
+type Target struct {
+	// EngineVersion is the version of dockerd, e.g. 24.0.7, empty when
+	// it's unknown, e.g. before the engine is installed.
+	EngineVersion string
+
+	// Kernel is the release of the kernel of the machine, e.g.
+	// 5.15.0-91-generic, empty when it's unknown.
+	Kernel string
+
+	// Filesystems tell if the kernel supports the filesystems they have a
+	// key for, the others being unknown.
+	Filesystems map[string]bool
+}
+
+// removedOption is a daemon option, as a flag and in daemon.json, removed
+// from the engine in a version.
+type removedOption struct {
+	names   []string
+	removed string
+	fix     string
+}
+
+var removedOptions = []removedOption{
+	{[]string{"graph", "g"}, "23.0", "use --engine-opt data-root=<dir> instead"},
+	{[]string{"cluster-store", "cluster-advertise", "cluster-store-opt", "cluster-store-opts"}, "23.0", "use swarm mode with --swarm-mode-manager instead"},
+	{[]string{"disable-legacy-registry"}, "19.03", "remove it, the engine only supports the v2 registries"},
+	{[]string{"oom-score-adjust"}, "25.0", "remove it, the engine no longer adjusts its OOM score"},
+}
+
+// storageDriver is a storage driver of the engine, with the version
+// removing it, if any, and the filesystem the kernel must support.
+type storageDriver struct {
+	removed    string
+	filesystem string
+}
+
+var storageDrivers = map[string]storageDriver{
+	"overlay2":       {filesystem: "overlay"},
+	"fuse-overlayfs": {filesystem: "fuse"},
+	"btrfs":          {filesystem: "btrfs"},
+	"zfs":            {filesystem: "zfs"},
+	"vfs":            {},
+	"aufs":           {removed: "24.0", filesystem: "aufs"},
+	"overlay":        {removed: "24.0", filesystem: "overlay"},
+	"devicemapper":   {removed: "25.0"},
+}
+
+var engineVersionRegexp = regexp.MustCompile(`(?i)version v?(\d+\.\d+[^\s,]*)`)
+
+// EngineVersion returns the version of the engine from the output of
+// dockerd --version, or an empty string.
+func EngineVersion(output string) string {
+	match := engineVersionRegexp.FindStringSubmatch(output)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// Filesystem returns the filesystem the kernel must support for the given
+// storage driver, or an empty string.
+func Filesystem(driver string) string {
+	return storageDrivers[driver].filesystem
+}
+
+// Options checks the options of the engine against the target, and returns
+// an error listing every option the engine would fail to start with, along
+// with how to fix it.  The options depending on what's unknown of the
+// target are left unchecked.
+func Options(options *engine.Options, target Target) error {
+	if options == nil || options.IsPodman() || options.IsContainerd() {
+		return nil
+	}
+
+	problems := []string{}
+
+	for _, flag := range options.ArbitraryFlags {
+		name := strings.SplitN(strings.TrimLeft(flag, "-"), "=", 2)[0]
+		if problem := checkRemovedOption(name, "--engine-opt "+name, target); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	config := map[string]interface{}{}
+	if len(options.DaemonConfig) > 0 {
+		// An invalid file is reported when the daemon.json is generated
+		json.Unmarshal(options.DaemonConfig, &config)
+	}
+
+	keys := []string{}
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if problem := checkRemovedOption(key, fmt.Sprintf("%q in --engine-opt-file", key), target); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	driver := options.StorageDriver
+	if value, ok := config["storage-driver"].(string); ok {
+		driver = value
+	}
+	if problem := checkStorageDriver(driver, target); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Error: the engine can't start with its options%s:\n  %s", describe(target), strings.Join(problems, "\n  "))
+}
+
+func checkRemovedOption(name, source string, target Target) string {
+	for _, option := range removedOptions {
+		for _, removedName := range option.names {
+			if name == removedName && atLeast(target.EngineVersion, option.removed) {
+				return fmt.Sprintf("%s was removed in Docker %s, %s", source, option.removed, option.fix)
+			}
+		}
+	}
+	return ""
+}
+
+func checkStorageDriver(name string, target Target) string {
+	if name == "" {
+		return ""
+	}
+
+	driver, ok := storageDrivers[name]
+	if !ok {
+		return fmt.Sprintf("unknown storage driver %q, expected one of: %s", name, strings.Join(supportedStorageDrivers(target), ", "))
+	}
+
+	if driver.removed != "" && atLeast(target.EngineVersion, driver.removed) {
+		return fmt.Sprintf("the %s storage driver was removed in Docker %s, use --engine-storage-driver overlay2 instead", name, driver.removed)
+	}
+
+	if supported, known := target.Filesystems[driver.filesystem]; known && !supported {
+		return fmt.Sprintf("the kernel doesn't support the %s filesystem of the %s storage driver, choose another one with --engine-storage-driver", driver.filesystem, name)
+	}
+
+	return ""
+}
+
+// supportedStorageDrivers returns the storage drivers the engine of the
+// target supports.
+func supportedStorageDrivers(target Target) []string {
+	names := []string{}
+	for name, driver := range storageDrivers {
+		if driver.removed == "" || !atLeast(target.EngineVersion, driver.removed) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func describe(target Target) string {
+	switch {
+	case target.EngineVersion != "" && target.Kernel != "":
+		return fmt.Sprintf(" (Docker %s, kernel %s)", target.EngineVersion, target.Kernel)
+	case target.EngineVersion != "":
+		return fmt.Sprintf(" (Docker %s)", target.EngineVersion)
+	case target.Kernel != "":
+		return fmt.Sprintf(" (kernel %s)", target.Kernel)
+	}
+	return ""
+}
+
+// atLeast returns true if the version is known and is the given major and
+// minor version or a later one.  The versions of the engine before 17.03
+// are numbered 1.x.
+func atLeast(version, min string) bool {
+	major, minor, ok := majorMinor(version)
+	if !ok {
+		return false
+	}
+	minMajor, minMinor, _ := majorMinor(min)
+
+	return major > minMajor || major == minMajor && minor >= minMinor
+}
+
+func majorMinor(version string) (int, int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return major, minor, true
+}
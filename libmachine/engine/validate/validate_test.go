@@ -0,0 +1,67 @@
+package validate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineVersion(t *testing.T) {
+	assert.Equal(t, "24.0.7", EngineVersion("Docker version 24.0.7, build 311b9ff\n"))
+	assert.Equal(t, "20.10.24+dfsg1", EngineVersion("Docker version 20.10.24+dfsg1, build 297e128\n"))
+	assert.Equal(t, "", EngineVersion("dockerd: command not found\n"))
+}
+
+func TestAtLeast(t *testing.T) {
+	assert.True(t, atLeast("24.0.7", "23.0"))
+	assert.True(t, atLeast("23.0.0", "23.0"))
+	assert.True(t, atLeast("19.03.15", "19.03"))
+	assert.False(t, atLeast("20.10.24+dfsg1", "23.0"))
+	assert.False(t, atLeast("17.06.2-ce", "19.03"))
+	assert.False(t, atLeast("1.13.1", "19.03"))
+	assert.False(t, atLeast("", "19.03"))
+}
+
+func TestOptions(t *testing.T) {
+	options := &engine.Options{
+		ArbitraryFlags: []string{"--graph=/data/docker", "log-driver=journald", "cluster-store=consul://consul:8500"},
+		StorageDriver:  "aufs",
+	}
+
+	assert.NoError(t, Options(options, Target{}))
+	assert.NoError(t, Options(options, Target{EngineVersion: "20.10.24"}))
+	assert.EqualError(t, Options(options, Target{EngineVersion: "24.0.7", Kernel: "5.15.0-91-generic"}), `Error: the engine can't start with its options (Docker 24.0.7, kernel 5.15.0-91-generic):
+  --engine-opt graph was removed in Docker 23.0, use --engine-opt data-root=<dir> instead
+  --engine-opt cluster-store was removed in Docker 23.0, use swarm mode with --swarm-mode-manager instead
+  the aufs storage driver was removed in Docker 24.0, use --engine-storage-driver overlay2 instead`)
+}
+
+func TestOptionsDaemonConfig(t *testing.T) {
+	options := &engine.Options{
+		StorageDriver: "overlay2",
+		DaemonConfig:  json.RawMessage(`{"oom-score-adjust": -500, "storage-driver": "devicemapper"}`),
+	}
+
+	assert.EqualError(t, Options(options, Target{EngineVersion: "25.0.3"}), `Error: the engine can't start with its options (Docker 25.0.3):
+  "oom-score-adjust" in --engine-opt-file was removed in Docker 25.0, remove it, the engine no longer adjusts its OOM score
+  the devicemapper storage driver was removed in Docker 25.0, use --engine-storage-driver overlay2 instead`)
+}
+
+func TestOptionsStorageDriver(t *testing.T) {
+	assert.EqualError(t, Options(&engine.Options{StorageDriver: "overlay3"}, Target{EngineVersion: "25.0.3"}), `Error: the engine can't start with its options (Docker 25.0.3):
+  unknown storage driver "overlay3", expected one of: btrfs, fuse-overlayfs, overlay2, vfs, zfs`)
+
+	options := &engine.Options{StorageDriver: "zfs"}
+	assert.NoError(t, Options(options, Target{Filesystems: map[string]bool{"overlay": false}}))
+	assert.NoError(t, Options(options, Target{Filesystems: map[string]bool{"zfs": true}}))
+	assert.EqualError(t, Options(options, Target{Kernel: "6.1.0-18-amd64", Filesystems: map[string]bool{"zfs": false}}), `Error: the engine can't start with its options (kernel 6.1.0-18-amd64):
+  the kernel doesn't support the zfs filesystem of the zfs storage driver, choose another one with --engine-storage-driver`)
+}
+
+func TestOptionsOtherRuntimes(t *testing.T) {
+	options := &engine.Options{ContainerRuntime: engine.RuntimePodman, StorageDriver: "aufs"}
+
+	assert.NoError(t, Options(options, Target{EngineVersion: "24.0.7"}))
+}
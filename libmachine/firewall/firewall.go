@@ -0,0 +1,288 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+const (
+	// BackendAuto uses the firewall installed on the machine: ufw,
+	// firewalld or nftables, in that order.
+	BackendAuto      = "auto"
+	BackendUFW       = "ufw"
+	BackendFirewalld = "firewalld"
+	BackendNftables  = "nftables"
+
+	nftablesDir     = "/etc/docker-machine"
+	nftablesFile    = nftablesDir + "/firewall.nft"
+	nftablesService = "docker-machine-firewall"
+	nftablesUnit    = "/etc/systemd/system/" + nftablesService + ".service"
+	nftablesTable   = "docker_machine"
+)
+
+// The nftables rules are kept in a table of their own, loaded by a service
+// before the network is up, so that the rules of docker aren't flushed.  The
+// table is created before being deleted so that loading the file again
+// replaces it.
+const nftablesRulesTemplate = `table inet ` + nftablesTable + `
+delete table inet ` + nftablesTable + `
+table inet ` + nftablesTable + ` {
+	chain input {
+		type filter hook input priority 0; policy drop;
+		ct state established,related accept
+		iif lo accept
+		iifname { "docker*", "br-*" } accept
+		meta l4proto { icmp, ipv6-icmp } accept
+		udp dport 546 accept
+%s	}
+}
+`
+
+const nftablesServiceUnit = `[Unit]
+Description=Firewall of Docker Machine
+Wants=network-pre.target
+Before=network-pre.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/bin/sh -c "nft -f ` + nftablesFile + `"
+
+[Install]
+WantedBy=multi-user.target
+`
+
+var (
+	ErrUnknownBackend = errors.New("Error: the firewall must be auto, ufw, firewalld or nftables")
+	ErrNoFirewall     = errors.New("Error: none of ufw, firewalld and nft is installed on the machine, choose the one to install with --provision-firewall")
+	ErrNotSupported   = errors.New("Error: the nftables firewall can only be configured on systemd machines")
+)
+
+// backendCommands are the commands telling if a firewall is installed, and
+// the packages installing them.
+var backendCommands = []struct {
+	backend string
+	command string
+	pkg     string
+}{
+	{BackendUFW, "ufw", "ufw"},
+	{BackendFirewalld, "firewall-cmd", "firewalld"},
+	{BackendNftables, "nft", "nftables"},
+}
+
+type Options struct {
+	// Backend is the firewall configured on the machine, or auto for the
+	// installed one.
+	Backend string
+
+	// Ports are opened besides SSH, the engine and the ports of swarm mode
+	// and k3s, e.g. 80, 443/tcp, 8000-8100/tcp or 53/udp.
+	Ports []string `json:",omitempty"`
+}
+
+// IsEnabled returns true if the firewall of the machine is configured.
+func (o *Options) IsEnabled() bool {
+	return o != nil && o.Backend != ""
+}
+
+// Validate checks the options given on the command line.
+func (o *Options) Validate() error {
+	switch o.Backend {
+	case BackendAuto, BackendUFW, BackendFirewalld, BackendNftables:
+	default:
+		return ErrUnknownBackend
+	}
+
+	for _, p := range o.Ports {
+		if _, err := parsePort(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// port is a port or a range of ports of a protocol.
+type port struct {
+	from, to int
+	proto    string
+}
+
+// parsePort parses a port given as port[-port][/protocol], tcp by default.
+func parsePort(spec string) (port, error) {
+	invalid := fmt.Errorf("Error: invalid port %q, expected e.g. 80, 443/tcp, 8000-8100/tcp or 53/udp", spec)
+
+	p := port{proto: "tcp"}
+	ports := spec
+	if i := strings.Index(spec, "/"); i >= 0 {
+		ports, p.proto = spec[:i], spec[i+1:]
+	}
+	if p.proto != "tcp" && p.proto != "udp" {
+		return p, invalid
+	}
+
+	bounds := strings.SplitN(ports, "-", 2)
+	var err error
+	if p.from, err = strconv.Atoi(bounds[0]); err != nil || p.from < 1 || p.from > 65535 {
+		return p, invalid
+	}
+	p.to = p.from
+	if len(bounds) == 2 {
+		if p.to, err = strconv.Atoi(bounds[1]); err != nil || p.to < p.from || p.to > 65535 {
+			return p, invalid
+		}
+	}
+
+	return p, nil
+}
+
+// rangeString returns the ports with the separator of the ranges of the
+// firewall.
+func (p port) rangeString(separator string) string {
+	if p.from == p.to {
+		return strconv.Itoa(p.from)
+	}
+	return fmt.Sprintf("%d%s%d", p.from, separator, p.to)
+}
+
+// parsePorts parses the ports, without the duplicates.
+func parsePorts(specs []string) ([]port, error) {
+	ports := []port{}
+	seen := map[port]bool{}
+	for _, spec := range specs {
+		p, err := parsePort(spec)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[p] {
+			seen[p] = true
+			ports = append(ports, p)
+		}
+	}
+	return ports, nil
+}
+
+// Configure makes the firewall of the machine allow only the required ports,
+// e.g. SSH and the engine, and the exposed ones.  SSH is allowed before the
+// firewall is enabled, so that the machine stays reachable.
+func Configure(p provision.Provisioner, options *Options, required []string) error {
+	if !options.IsEnabled() {
+		return nil
+	}
+
+	ports, err := parsePorts(append(required, options.Ports...))
+	if err != nil {
+		return err
+	}
+
+	backend, err := selectBackend(p, options.Backend)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Configuring the %s firewall...", backend)
+
+	var commands []string
+	switch backend {
+	case BackendUFW:
+		commands = ufwCommands(ports)
+	case BackendFirewalld:
+		commands = firewalldCommands(ports)
+	case BackendNftables:
+		if _, err := p.SSHCommand("test -d /run/systemd/system"); err != nil {
+			return ErrNotSupported
+		}
+		commands = nftablesCommands(ports)
+	}
+
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error configuring the %s firewall: %s\n%s", backend, err, output)
+		}
+	}
+
+	return nil
+}
+
+// selectBackend returns the firewall to configure, installed if needed.
+func selectBackend(p provision.Provisioner, backend string) (string, error) {
+	for _, b := range backendCommands {
+		if backend != BackendAuto && backend != b.backend {
+			continue
+		}
+
+		if _, err := p.SSHCommand(fmt.Sprintf("sudo sh -c 'command -v %s'", b.command)); err == nil {
+			return b.backend, nil
+		}
+
+		if backend == b.backend {
+			log.Infof("Installing %s...", b.pkg)
+			if err := p.Package(b.pkg, pkgaction.Install); err != nil {
+				return "", fmt.Errorf("Error installing %s: %s", b.pkg, err)
+			}
+			return b.backend, nil
+		}
+	}
+
+	if backend == BackendAuto {
+		return "", ErrNoFirewall
+	}
+	return "", ErrUnknownBackend
+}
+
+// ufwCommands replaces the rules of ufw, denying the incoming connections
+// by default.
+func ufwCommands(ports []port) []string {
+	commands := []string{
+		"sudo ufw --force reset",
+		"sudo ufw default deny incoming",
+		"sudo ufw default allow outgoing",
+	}
+	for _, p := range ports {
+		commands = append(commands, fmt.Sprintf("sudo ufw allow %s/%s", p.rangeString(":"), p.proto))
+	}
+	return append(commands, "sudo ufw --force enable")
+}
+
+// firewalldCommands replaces the services and the ports of the default zone,
+// whose target rejects the other connections.  The DHCPv6 client is kept.
+func firewalldCommands(ports []port) []string {
+	add := ""
+	for _, p := range ports {
+		add += fmt.Sprintf(" --add-port=%s/%s", p.rangeString("-"), p.proto)
+	}
+
+	return []string{
+		"sudo systemctl enable --now firewalld",
+		"for s in $(sudo firewall-cmd --permanent --list-services); do [ \"$s\" = dhcpv6-client ] || sudo firewall-cmd --permanent --remove-service=\"$s\" >/dev/null; done",
+		"for p in $(sudo firewall-cmd --permanent --list-ports); do sudo firewall-cmd --permanent --remove-port=\"$p\" >/dev/null; done",
+		"sudo firewall-cmd --permanent" + add,
+		"sudo firewall-cmd --reload",
+	}
+}
+
+// nftablesRules renders the table of the rules.  The traffic of the
+// containers is let through.
+func nftablesRules(ports []port) string {
+	rules := ""
+	for _, p := range ports {
+		rules += fmt.Sprintf("\t\t%s dport %s accept\n", p.proto, p.rangeString("-"))
+	}
+	return fmt.Sprintf(nftablesRulesTemplate, rules)
+}
+
+// nftablesCommands writes the rules and the service loading them, then
+// starts it.
+func nftablesCommands(ports []port) []string {
+	return []string{
+		fmt.Sprintf("sudo mkdir -p %s && printf %%s '%s' | sudo tee %s >/dev/null && sudo nft -c -f %[3]s", nftablesDir, nftablesRules(ports), nftablesFile),
+		fmt.Sprintf("printf %%s '%s' | sudo tee %s >/dev/null", nftablesServiceUnit, nftablesUnit),
+		fmt.Sprintf("sudo systemctl daemon-reload && sudo systemctl enable %[1]s && sudo systemctl restart %[1]s", nftablesService),
+	}
+}
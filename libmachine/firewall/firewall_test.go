@@ -0,0 +1,109 @@
+package firewall
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvisioner records the commands and the installed packages, the
+// commands failing when they start with one of the failing prefixes.
+type fakeProvisioner struct {
+	provision.FakeProvisioner
+	commands []string
+	packages []string
+	failing  []string
+}
+
+func (p *fakeProvisioner) SSHCommand(args string) (string, error) {
+	p.commands = append(p.commands, args)
+
+	for _, prefix := range p.failing {
+		if strings.HasPrefix(args, prefix) {
+			return "", errors.New("exit status 1")
+		}
+	}
+
+	return "", nil
+}
+
+func (p *fakeProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	p.packages = append(p.packages, name)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Options{Backend: BackendAuto, Ports: []string{"80", "443/tcp", "8000-8100/tcp", "53/udp"}}).Validate())
+	assert.Equal(t, ErrUnknownBackend, (&Options{Backend: "iptables"}).Validate())
+	assert.EqualError(t, (&Options{Backend: BackendUFW, Ports: []string{"http"}}).Validate(), `Error: invalid port "http", expected e.g. 80, 443/tcp, 8000-8100/tcp or 53/udp`)
+	assert.Error(t, (&Options{Backend: BackendUFW, Ports: []string{"80/sctp"}}).Validate())
+	assert.Error(t, (&Options{Backend: BackendUFW, Ports: []string{"8100-8000"}}).Validate())
+	assert.Error(t, (&Options{Backend: BackendUFW, Ports: []string{"70000"}}).Validate())
+}
+
+func TestParsePorts(t *testing.T) {
+	ports, err := parsePorts([]string{"22/tcp", "80", "22", "8000-8100/udp"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []port{{22, 22, "tcp"}, {80, 80, "tcp"}, {8000, 8100, "udp"}}, ports)
+	assert.Equal(t, "8000:8100", ports[2].rangeString(":"))
+}
+
+func TestConfigureUFW(t *testing.T) {
+	p := &fakeProvisioner{}
+
+	assert.NoError(t, Configure(p, &Options{Backend: BackendAuto, Ports: []string{"80", "8000-8100/udp"}}, []string{"22/tcp", "2376/tcp"}))
+	assert.Equal(t, []string{
+		"sudo sh -c 'command -v ufw'",
+		"sudo ufw --force reset",
+		"sudo ufw default deny incoming",
+		"sudo ufw default allow outgoing",
+		"sudo ufw allow 22/tcp",
+		"sudo ufw allow 2376/tcp",
+		"sudo ufw allow 80/tcp",
+		"sudo ufw allow 8000:8100/udp",
+		"sudo ufw --force enable",
+	}, p.commands)
+}
+
+func TestConfigureFirewalld(t *testing.T) {
+	p := &fakeProvisioner{failing: []string{"sudo sh -c 'command -v ufw'"}}
+
+	assert.NoError(t, Configure(p, &Options{Backend: BackendAuto}, []string{"22/tcp", "2376/tcp"}))
+	assert.Equal(t, "sudo firewall-cmd --permanent --add-port=22/tcp --add-port=2376/tcp", p.commands[5])
+	assert.Equal(t, "sudo firewall-cmd --reload", p.commands[6])
+}
+
+func TestConfigureNftables(t *testing.T) {
+	p := &fakeProvisioner{failing: []string{"sudo sh -c 'command -v"}}
+
+	assert.NoError(t, Configure(p, &Options{Backend: BackendNftables, Ports: []string{"53/udp"}}, []string{"22/tcp"}))
+	assert.Equal(t, []string{"nftables"}, p.packages)
+	assert.Contains(t, p.commands[2], "\t\ttcp dport 22 accept\n\t\tudp dport 53 accept\n")
+	assert.Contains(t, p.commands[2], "sudo nft -c -f /etc/docker-machine/firewall.nft")
+	assert.Equal(t, "sudo systemctl daemon-reload && sudo systemctl enable docker-machine-firewall && sudo systemctl restart docker-machine-firewall", p.commands[4])
+}
+
+func TestConfigureNftablesNotSupported(t *testing.T) {
+	p := &fakeProvisioner{failing: []string{"test -d /run/systemd/system"}}
+
+	assert.Equal(t, ErrNotSupported, Configure(p, &Options{Backend: BackendNftables}, []string{"22/tcp"}))
+}
+
+func TestConfigureNoFirewall(t *testing.T) {
+	p := &fakeProvisioner{failing: []string{"sudo sh -c 'command -v"}}
+
+	assert.Equal(t, ErrNoFirewall, Configure(p, &Options{Backend: BackendAuto}, []string{"22/tcp"}))
+	assert.Empty(t, p.packages)
+}
+
+func TestConfigureDisabled(t *testing.T) {
+	p := &fakeProvisioner{}
+
+	assert.NoError(t, Configure(p, nil, []string{"22/tcp"}))
+	assert.Empty(t, p.commands)
+}
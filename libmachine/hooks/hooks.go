@@ -0,0 +1,208 @@
+// Package hooks runs the scripts and the webhooks the user registered for the
+// lifecycle events of the machines, so that external systems can react to
+// them.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// ConfigFileName is the name of the configuration file of the hooks, in the
+// storage path.
+const ConfigFileName = "hooks.json"
+
+const defaultTimeout = 30 * time.Second
+
+type EventType string
+
+const (
+	// PreCreate is fired when a machine passed its pre-create checks,
+	// before it is created.  A failing hook aborts the creation.
+	PreCreate EventType = "pre-create"
+
+	// PostProvision is fired when a machine was created and provisioned,
+	// or provisioned again.
+	PostProvision EventType = "post-provision"
+
+	// PreRemove is fired before a machine is removed.  A failing hook
+	// aborts the removal, unless it is forced.
+	PreRemove EventType = "pre-remove"
+
+	// StateChange is fired when a machine was started, stopped, restarted
+	// or killed, or when watch finds that its state changed.
+	StateChange EventType = "state-change"
+)
+
+// Event is a lifecycle event of a machine.
+type Event struct {
+	Type    EventType `json:"type"`
+	Machine string    `json:"machine"`
+	Driver  string    `json:"driver,omitempty"`
+	State   string    `json:"state,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Hook is a script run, or a URL the event is posted to, for some events.
+type Hook struct {
+	// Events are the events the hook is run for, all of them when empty.
+	Events []EventType `json:"events,omitempty"`
+
+	// Command is the path of the script to run.  The event is given in
+	// the MACHINE_EVENT, MACHINE_NAME, MACHINE_DRIVER and MACHINE_STATE
+	// environment variables, and as JSON on its standard input.
+	Command string `json:"command,omitempty"`
+
+	// URL is the URL the event is posted to as JSON.
+	URL string `json:"url,omitempty"`
+
+	// Timeout is the number of seconds the hook is given, 30 by default.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+func (hook *Hook) matches(eventType EventType) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+
+	for _, e := range hook.Events {
+		if e == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (hook *Hook) timeout() time.Duration {
+	if hook.Timeout > 0 {
+		return time.Duration(hook.Timeout) * time.Second
+	}
+	return defaultTimeout
+}
+
+func (hook *Hook) run(event Event, content []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout())
+	defer cancel()
+
+	if hook.Command != "" {
+		cmd := exec.CommandContext(ctx, hook.Command)
+		cmd.Env = append(os.Environ(),
+			fmt.Sprintf("MACHINE_EVENT=%s", event.Type),
+			fmt.Sprintf("MACHINE_NAME=%s", event.Machine),
+			fmt.Sprintf("MACHINE_DRIVER=%s", event.Driver),
+			fmt.Sprintf("MACHINE_STATE=%s", event.State),
+		)
+		cmd.Stdin = bytes.NewReader(content)
+
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("Error running hook %s: %s\n%s", hook.Command, err, output)
+		}
+	}
+
+	if hook.URL != "" {
+		req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("Error posting to hook %s: %s", hook.URL, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return fmt.Errorf("Error posting to hook %s: %s\n%s", hook.URL, resp.Status, body)
+		}
+	}
+
+	return nil
+}
+
+// Hooks are the hooks of the configuration file.
+type Hooks struct {
+	Hooks []Hook `json:"hooks"`
+}
+
+// Load reads the configuration file of the hooks.  A missing file is no
+// hooks.
+func Load(path string) (*Hooks, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Hooks{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := &Hooks{}
+	if err := json.Unmarshal(content, hooks); err != nil {
+		return nil, fmt.Errorf("Error reading the hooks of %s: %s", path, err)
+	}
+
+	for _, hook := range hooks.Hooks {
+		if hook.Command == "" && hook.URL == "" {
+			return nil, fmt.Errorf("Error reading the hooks of %s: a hook needs a command or a URL", path)
+		}
+	}
+
+	return hooks, nil
+}
+
+// Fire runs the hooks of the event, one after the other, and returns the
+// first error.
+func (h *Hooks) Fire(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range h.Hooks {
+		if !hook.matches(event.Type) {
+			continue
+		}
+
+		log.Debugf("running the %s hook of %s", event.Type, event.Machine)
+		if err := hook.run(event, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var defaultHooks = &Hooks{}
+
+// SetDefault sets the hooks fired by Fire.
+func SetDefault(h *Hooks) {
+	defaultHooks = h
+}
+
+// Fire runs the default hooks of the event.
+func Fire(event Event) error {
+	return defaultHooks.Fire(event)
+}
+
+// Notify runs the default hooks of an event whose failure doesn't stop the
+// operation, and only logs it.
+func Notify(event Event) {
+	if err := Fire(event); err != nil {
+		log.Warnf("Error running the %s hooks of %s: %s", event.Type, event.Machine, err)
+	}
+}
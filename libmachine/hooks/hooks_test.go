@@ -0,0 +1,91 @@
+package hooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	hooks, err := Load("/nonexistent/hooks.json")
+
+	assert.NoError(t, err)
+	assert.Empty(t, hooks.Hooks)
+}
+
+func TestLoadRequiresCommandOrURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-hooks-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ConfigFileName)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"hooks":[{"events":["pre-create"]}]}`), 0644))
+
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestFireCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-hooks-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+	script := filepath.Join(dir, "hook.sh")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$MACHINE_EVENT $MACHINE_NAME $MACHINE_DRIVER\" > "+output+"\ncat >> "+output+"\n"), 0755))
+
+	hooks := &Hooks{Hooks: []Hook{
+		{Events: []EventType{PreCreate}, Command: script},
+	}}
+
+	assert.NoError(t, hooks.Fire(Event{Type: PreRemove, Machine: "dev"}))
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, hooks.Fire(Event{Type: PreCreate, Machine: "dev", Driver: "digitalocean"}))
+	content, err := ioutil.ReadFile(output)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "pre-create dev digitalocean\n{\"type\":\"pre-create\",\"machine\":\"dev\",\"driver\":\"digitalocean\""))
+}
+
+func TestFireCommandFailure(t *testing.T) {
+	hooks := &Hooks{Hooks: []Hook{{Command: "false"}}}
+
+	assert.Error(t, hooks.Fire(Event{Type: PreRemove, Machine: "dev"}))
+}
+
+func TestFireURL(t *testing.T) {
+	var event Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+	}))
+	defer server.Close()
+
+	hooks := &Hooks{Hooks: []Hook{{Events: []EventType{StateChange}, URL: server.URL}}}
+
+	assert.NoError(t, hooks.Fire(Event{Type: StateChange, Machine: "dev", State: "Stopped"}))
+	assert.Equal(t, StateChange, event.Type)
+	assert.Equal(t, "dev", event.Machine)
+	assert.Equal(t, "Stopped", event.State)
+	assert.False(t, event.Time.IsZero())
+}
+
+func TestFireURLFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	hooks := &Hooks{Hooks: []Hook{{URL: server.URL}}}
+
+	assert.Error(t, hooks.Fire(Event{Type: PreCreate, Machine: "dev"}))
+}
@@ -2,11 +2,22 @@ package host
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"regexp"
+	"time"
 
 	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/autoupdates"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/diskcrypt"
+	"github.com/docker/machine/libmachine/dnshook"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/firewall"
+	"github.com/docker/machine/libmachine/hooks"
+	"github.com/docker/machine/libmachine/k3s"
+	"github.com/docker/machine/libmachine/kubernetes"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnutils"
@@ -14,13 +25,17 @@ import (
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
 	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/sshuser"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/swarmmode"
+	"github.com/docker/machine/libmachine/winrm"
 )
 
 var (
 	validHostNamePattern                               = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-\.]*$`)
 	errMachineMustBeRunningForUpgrade                  = errors.New("Error: machine must be running to upgrade.")
+	errEngineVersionNotSupported                       = errors.New("Error: the engine version can't be pinned on podman, containerd or rootless machines.")
 	stdSSHClientCreator               SSHClientCreator = &StandardSSHClientCreator{}
 )
 
@@ -43,6 +58,63 @@ type Host struct {
 	HostOptions   *Options
 	Name          string
 	RawDriver     []byte `json:"-"`
+
+	// EndpointType is the API the engine URL of the machine serves, empty
+	// for EndpointDocker.
+	EndpointType string `json:",omitempty"`
+
+	// LastKnown is what "ls" last found about the machine, listed by
+	// "ls --cached" without querying the driver.
+	LastKnown *LastKnown `json:",omitempty"`
+
+	// Labels group the machines, which the label filter of "ls" and of the
+	// commands acting on several machines selects.
+	Labels map[string]string `json:",omitempty"`
+
+	// AutoStop is set for the machines "watch" stops when they're idle.
+	AutoStop *AutoStop `json:",omitempty"`
+
+	// DNSRecord is the DNS record the machine was registered with, removed
+	// with the machine.
+	DNSRecord *dnshook.Record `json:",omitempty"`
+
+	// OsRelease is the os-release info read when the provisioner of the
+	// machine was first detected, from which it's detected again without
+	// connecting to the machine.
+	OsRelease *provision.OsRelease `json:",omitempty"`
+
+	// LastProvisioned is when the machine was last provisioned, by create
+	// or provision.
+	LastProvisioned *time.Time `json:",omitempty"`
+
+	// SSHClientCreator and Provisioners are given by the client loading the
+	// host, in place of the default SSH clients and provisioners.
+	SSHClientCreator SSHClientCreator   `json:"-"`
+	Provisioners     provision.Registry `json:"-"`
+}
+
+const (
+	// EndpointDocker is the Docker API, served by dockerd or podman.
+	EndpointDocker = "docker"
+
+	// EndpointContainerd is the containerd gRPC API, for ctr and nerdctl.
+	EndpointContainerd = "containerd"
+)
+
+// AutoStop is when a running machine is stopped for being idle: after its
+// engine ran no container for Idle.
+type AutoStop struct {
+	Idle time.Duration
+}
+
+// LastKnown is the state of a machine at the time it was last listed.
+type LastKnown struct {
+	State         state.State
+	URL           string
+	DockerVersion string
+
+	// Since is when the machine was first listed in this state.
+	Since time.Time
 }
 
 type Options struct {
@@ -52,6 +124,38 @@ type Options struct {
 	EngineOptions *engine.Options
 	SwarmOptions  *swarm.Options
 	AuthOptions   *auth.Options
+	K3sOptions    *k3s.Options
+
+	// SwarmModeOptions are set for the nodes of a swarm in swarm mode.
+	SwarmModeOptions *swarmmode.Options
+
+	// KubernetesOptions are set for the nodes of a Kubernetes cluster
+	// bootstrapped with kubeadm.
+	KubernetesOptions *kubernetes.Options `json:",omitempty"`
+
+	// DataDiskOptions are set for the machines whose data disk, mounted on
+	// /var/lib/docker, is encrypted.
+	DataDiskOptions *diskcrypt.Options
+
+	// WinRMOptions are set for the Windows hosts, which are provisioned
+	// over WinRM instead of SSH.
+	WinRMOptions *winrm.Options
+
+	// SSHUserOptions are set for the machines accessed as a user created
+	// during provisioning, or whose root login is disabled.
+	SSHUserOptions *sshuser.Options
+
+	// FirewallOptions are set for the machines whose firewall only allows
+	// SSH, the engine and the exposed ports.
+	FirewallOptions *firewall.Options
+
+	// AutoUpdatesOptions are set for the machines applying their security
+	// updates automatically.
+	AutoUpdatesOptions *autoupdates.Options
+
+	// Provisioner is the name of the registered provisioner forced by
+	// --provision-with, used whatever the os-release info of the machine.
+	Provisioner string `json:",omitempty"`
 }
 
 type Metadata struct {
@@ -60,15 +164,32 @@ type Metadata struct {
 	HostOptions   Options
 }
 
+// IsContainerdEndpoint returns true if the engine URL of the machine serves
+// the containerd API instead of the Docker API.
+func (h *Host) IsContainerdEndpoint() bool {
+	return h.EndpointType == EndpointContainerd
+}
+
 func ValidateHostName(name string) bool {
 	return validHostNamePattern.MatchString(name)
 }
 
 func (h *Host) RunSSHCommand(command string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(h.Driver, command)
+	if h.SSHClientCreator == nil {
+		return drivers.RunSSHCommandFromDriver(h.Driver, command)
+	}
+
+	client, err := h.CreateSSHClient()
+	if err != nil {
+		return "", err
+	}
+	return drivers.RunSSHCommand(client, command)
 }
 
 func (h *Host) CreateSSHClient() (ssh.Client, error) {
+	if h.SSHClientCreator != nil {
+		return h.SSHClientCreator.CreateSSHClient(h.Driver)
+	}
 	return stdSSHClientCreator.CreateSSHClient(h.Driver)
 }
 
@@ -88,7 +209,12 @@ func (creator *StandardSSHClientCreator) CreateSSHClient(d drivers.Driver) (ssh.
 		auth.Keys = []string{d.GetSSHKeyPath()}
 	}
 
-	return ssh.NewClient(d.GetSSHUsername(), addr, port, auth)
+	proxyJump, err := drivers.GetSSHProxyJump(d)
+	if err != nil {
+		return &ssh.ExternalClient{}, err
+	}
+
+	return ssh.NewProxyJumpClient(d.GetSSHUsername(), addr, port, auth, proxyJump)
 }
 
 func (h *Host) runActionForState(action func() error, desiredState state.State) error {
@@ -106,8 +232,77 @@ func (h *Host) runActionForState(action func() error, desiredState state.State)
 	return mcnutils.WaitFor(drivers.MachineInState(h.Driver, desiredState))
 }
 
+// DetectProvisioner returns the provisioner of the host: the Windows one for
+// the hosts managed over WinRM, the one forced with --provision-with, or the
+// one of its Linux distribution.  The os-release info of the machine is
+// cached in the host, so that only the first detection connects to it.
+func (h *Host) DetectProvisioner() (provision.Provisioner, error) {
+	if h.HostOptions != nil && h.HostOptions.WinRMOptions != nil {
+		return provision.NewWindowsProvisioner(h.Driver, h.HostOptions.WinRMOptions)
+	}
+
+	forced := ""
+	if h.HostOptions != nil {
+		forced = h.HostOptions.Provisioner
+	}
+
+	registry := h.Provisioners
+	if registry == nil {
+		registry = provision.DefaultRegistry()
+	}
+
+	if h.OsRelease != nil {
+		if forced != "" {
+			return registry.New(forced, h.Driver, h.OsRelease)
+		}
+		return registry.Compatible(h.Driver, h.OsRelease)
+	}
+
+	if forced != "" {
+		osReleaseInfo, err := provision.ReadOsRelease(h.Driver)
+		if err != nil {
+			return nil, err
+		}
+
+		provisioner, err := registry.New(forced, h.Driver, osReleaseInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		h.OsRelease = osReleaseInfo
+		return provisioner, nil
+	}
+
+	var (
+		provisioner provision.Provisioner
+		err         error
+	)
+	if h.Provisioners != nil {
+		provisioner, err = h.Provisioners.DetectProvisioner(h.Driver)
+	} else {
+		provisioner, err = provision.DetectProvisioner(h.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A provisioner without os-release info, like the fake one, isn't cached
+	h.OsRelease, _ = provisioner.GetOsReleaseInfo()
+	return provisioner, nil
+}
+
+// Logger returns a logger adding the machine, its driver and the phase of the
+// operation to the log entries.
+func (h *Host) Logger(phase string) log.FieldLogger {
+	return log.WithFields(log.Fields{
+		"machine": h.Name,
+		"driver":  h.DriverName,
+		"phase":   phase,
+	})
+}
+
 func (h *Host) WaitForDocker() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.DetectProvisioner()
 	if err != nil {
 		return err
 	}
@@ -116,38 +311,42 @@ func (h *Host) WaitForDocker() error {
 }
 
 func (h *Host) Start() error {
-	log.Infof("Starting %q...", h.Name)
+	h.Logger("start").Infof("Starting %q...", h.Name)
 	if err := h.runActionForState(h.Driver.Start, state.Running); err != nil {
 		return err
 	}
 
-	log.Infof("Machine %q was started.", h.Name)
+	h.Logger("start").Infof("Machine %q was started.", h.Name)
+
+	if err := h.unlockDataDisk(); err != nil {
+		return err
+	}
 
 	return h.WaitForDocker()
 }
 
 func (h *Host) Stop() error {
-	log.Infof("Stopping %q...", h.Name)
+	h.Logger("stop").Infof("Stopping %q...", h.Name)
 	if err := h.runActionForState(h.Driver.Stop, state.Stopped); err != nil {
 		return err
 	}
 
-	log.Infof("Machine %q was stopped.", h.Name)
+	h.Logger("stop").Infof("Machine %q was stopped.", h.Name)
 	return nil
 }
 
 func (h *Host) Kill() error {
-	log.Infof("Killing %q...", h.Name)
+	h.Logger("kill").Infof("Killing %q...", h.Name)
 	if err := h.runActionForState(h.Driver.Kill, state.Stopped); err != nil {
 		return err
 	}
 
-	log.Infof("Machine %q was killed.", h.Name)
+	h.Logger("kill").Infof("Machine %q was killed.", h.Name)
 	return nil
 }
 
 func (h *Host) Restart() error {
-	log.Infof("Restarting %q...", h.Name)
+	h.Logger("restart").Infof("Restarting %q...", h.Name)
 	if drivers.MachineInState(h.Driver, state.Stopped)() {
 		if err := h.Start(); err != nil {
 			return err
@@ -159,11 +358,82 @@ func (h *Host) Restart() error {
 		if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
 			return err
 		}
+		if err := h.unlockDataDisk(); err != nil {
+			return err
+		}
 	}
 
 	return h.WaitForDocker()
 }
 
+// Remove runs the pre-remove hooks of a machine, removes it at its provider
+// and unregisters its DNS record.  The machine is left in the store, which
+// the caller removes it from once this succeeded.
+func (h *Host) Remove() error {
+	if err := hooks.Fire(hooks.Event{Type: hooks.PreRemove, Machine: h.Name, Driver: h.DriverName}); err != nil {
+		return fmt.Errorf("Error running the pre-remove hooks: %s", err)
+	}
+
+	if err := h.Driver.Remove(); err != nil {
+		return err
+	}
+
+	if h.DNSRecord != nil {
+		if err := dnshook.Unregister(dnshook.Default(), h.DNSRecord); err != nil {
+			log.Warn(err)
+		}
+	}
+
+	return nil
+}
+
+// unlockDataDisk unlocks the encrypted data disk of a machine which was
+// started, so that its engine starts.
+func (h *Host) unlockDataDisk() error {
+	if h.HostOptions == nil || !h.HostOptions.DataDiskOptions.IsEncrypted() {
+		return nil
+	}
+
+	provisioner, err := h.DetectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	return diskcrypt.Unlock(provisioner, h.HostOptions.DataDiskOptions, h.HostOptions.AuthOptions.StorePath)
+}
+
+// Resize changes the size of the machine.  A running machine is stopped to
+// be resized, then started again.
+func (h *Host) Resize(size string) error {
+	if !drivers.SupportsResize(h.Driver) {
+		return drivers.ErrResizeNotSupported
+	}
+	resizer := h.Driver.(drivers.Resizer)
+
+	machineState, err := h.Driver.GetState()
+	if err != nil {
+		return err
+	}
+
+	running := machineState == state.Running
+	if running {
+		if err := h.Stop(); err != nil {
+			return err
+		}
+	}
+
+	h.Logger("resize").Infof("Resizing %q to %s...", h.Name, size)
+	if err := resizer.Resize(size); err != nil {
+		return err
+	}
+
+	if !running {
+		return nil
+	}
+
+	return h.Start()
+}
+
 func (h *Host) Upgrade() error {
 	machineState, err := h.Driver.GetState()
 	if err != nil {
@@ -174,20 +444,113 @@ func (h *Host) Upgrade() error {
 		return errMachineMustBeRunningForUpgrade
 	}
 
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.DetectProvisioner()
 	if err != nil {
 		return err
 	}
 
-	log.Info("Upgrading docker...")
-	if err := provisioner.Package("docker", pkgaction.Upgrade); err != nil {
+	version := ""
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+		version = h.HostOptions.EngineOptions.Version
+	}
+
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.IsPodman() {
+		if version != "" {
+			return errEngineVersionNotSupported
+		}
+
+		h.Logger("upgrade").Info("Upgrading podman...")
+		if err := provisioner.Package("podman", pkgaction.Upgrade); err != nil {
+			return err
+		}
+
+		h.Logger("upgrade").Info("Restarting podman...")
+		return provisioner.Service("podman.socket", serviceaction.Restart)
+	}
+
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.IsContainerd() {
+		if version != "" {
+			return errEngineVersionNotSupported
+		}
+
+		h.Logger("upgrade").Info("Upgrading containerd...")
+		if err := provision.UpgradeContainerd(provisioner); err != nil {
+			return err
+		}
+
+		h.Logger("upgrade").Info("Restarting containerd...")
+		return provisioner.Service("containerd", serviceaction.Restart)
+	}
+
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.Rootless {
+		if version != "" {
+			return errEngineVersionNotSupported
+		}
+
+		h.Logger("upgrade").Info("Upgrading rootless docker...")
+		return provision.UpgradeRootlessDocker(provisioner, h.HostOptions.EngineOptions.InstallURL)
+	}
+
+	if version != "" {
+		h.Logger("upgrade").Infof("Installing docker %s...", version)
+	} else {
+		h.Logger("upgrade").Info("Upgrading docker...")
+	}
+	if err := provision.UpgradeDocker(provisioner, version); err != nil {
 		return err
 	}
 
-	log.Info("Restarting docker...")
+	h.Logger("upgrade").Info("Restarting docker...")
 	return provisioner.Service("docker", serviceaction.Restart)
 }
 
+// RestartEngine restarts the engine of the machine, podman or containerd, and
+// waits for it to answer.
+func (h *Host) RestartEngine() error {
+	provisioner, err := h.DetectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case h.HostOptions != nil && h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.IsPodman():
+		err = provisioner.Service("podman.socket", serviceaction.Restart)
+	case h.HostOptions != nil && h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.IsContainerd():
+		err = provisioner.Service("containerd", serviceaction.Restart)
+	case h.HostOptions != nil && h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.Rootless:
+		err = provision.RestartRootlessDocker(provisioner)
+	default:
+		err = provisioner.Service("docker", serviceaction.Restart)
+	}
+	if err != nil {
+		return err
+	}
+
+	return provision.WaitForDocker(provisioner, engine.DefaultPort)
+}
+
+// ConfigureEngineProxy writes the proxy settings of the engine options of the
+// machine and restarts its engine.
+func (h *Host) ConfigureEngineProxy() error {
+	provisioner, err := h.DetectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	return provision.ConfigureEngineProxy(provisioner, *h.HostOptions.EngineOptions)
+}
+
+// ConfigureEngineOptions writes the options of the engine which are kept in
+// its daemon.json, e.g. its registry mirrors, and restarts it.
+func (h *Host) ConfigureEngineOptions() error {
+	provisioner, err := h.DetectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	return provision.ConfigureEngineOptions(provisioner, *h.HostOptions.EngineOptions)
+}
+
 func (h *Host) URL() (string, error) {
 	return h.Driver.GetURL()
 }
@@ -200,7 +563,7 @@ func (h *Host) AuthOptions() *auth.Options {
 }
 
 func (h *Host) ConfigureAuth() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.DetectProvisioner()
 	if err != nil {
 		return err
 	}
@@ -213,11 +576,234 @@ func (h *Host) ConfigureAuth() error {
 	return provisioner.Provision(swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
 }
 
+// ConfigureSSHCertificates makes the machine trust the SSH certificate
+// authority of Machine, and issues the certificate of the SSH key of the
+// machine.  The key stays in the authorized_keys of the machine, in case the
+// certificate authority isn't selected later.
+func (h *Host) ConfigureSSHCertificates(provisioner provision.Provisioner) error {
+	if h.Driver.GetSSHKeyPath() == "" {
+		return errors.New("Error: SSH certificates require the machine to be accessed with an SSH key")
+	}
+
+	caPublicKey, err := cert.SSHCAPublicKey()
+	if err != nil {
+		return err
+	}
+
+	if err := provision.ConfigureSSHCA(provisioner, caPublicKey); err != nil {
+		return err
+	}
+
+	return cert.IssueSSHCertificate(h.Driver.GetSSHKeyPath(), h.Driver.GetSSHUsername())
+}
+
+// RotateCerts replaces the server certificate of the machine and restarts its
+// engine.  The machines whose certificate can't be replaced alone are
+// provisioned again like with ConfigureAuth.
+func (h *Host) RotateCerts() error {
+	provisioner, err := h.DetectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	return h.rotateCerts(provisioner)
+}
+
+// ConfigureName sets the hostname of the renamed machine to its new name,
+// and replaces its server certificate, issued for the name.
+func (h *Host) ConfigureName() error {
+	provisioner, err := h.DetectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	h.Logger("rename").Infof("Setting the hostname of %q...", h.Name)
+	if err := provisioner.SetHostname(h.Name); err != nil {
+		return err
+	}
+
+	return h.rotateCerts(provisioner)
+}
+
+func (h *Host) rotateCerts(provisioner provision.Provisioner) error {
+	swarmOptions := swarm.Options{}
+	if h.HostOptions.SwarmOptions != nil {
+		swarmOptions = *h.HostOptions.SwarmOptions
+	}
+
+	err := provision.RotateServerCert(provisioner, swarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	if err == provision.ErrCertRotationNotSupported {
+		log.Infof("Provisioning %s to rotate its certificates...", h.Name)
+		return provisioner.Provision(swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	}
+
+	return err
+}
+
 func (h *Host) Provision() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	// The operating system may have changed since the last provisioning
+	h.OsRelease = nil
+
+	provisioner, err := h.DetectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	if err := diskcrypt.Unlock(provisioner, h.HostOptions.DataDiskOptions, h.HostOptions.AuthOptions.StorePath); err != nil {
+		return err
+	}
+
+	if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+		return err
+	}
+
+	if err := diskcrypt.Configure(provisioner, h.HostOptions.DataDiskOptions, *h.HostOptions.EngineOptions, h.HostOptions.AuthOptions.StorePath); err != nil {
+		return err
+	}
+
+	if err := h.ConfigureFirewall(provisioner); err != nil {
+		return err
+	}
+
+	if err := autoupdates.Configure(provisioner, h.HostOptions.AutoUpdatesOptions); err != nil {
+		return err
+	}
+
+	if cert.SSHCertificatesEnabled() {
+		if err := h.ConfigureSSHCertificates(provisioner); err != nil {
+			return err
+		}
+	}
+
+	if err := k3s.Configure(provisioner, h.HostOptions.K3sOptions, h.HostOptions.AuthOptions.StorePath); err != nil {
+		return err
+	}
+
+	if err := kubernetes.Configure(provisioner, h.HostOptions.KubernetesOptions, h.HostOptions.AuthOptions.StorePath); err != nil {
+		return err
+	}
+
+	if err := h.ConfigureSwarmMode(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	h.LastProvisioned = &now
+
+	return nil
+}
+
+// ProvisionPhases runs the given phases of the provisioning again, skipping
+// the ones already in place unless force is set.  The swarm phase also
+// configures swarm mode.
+func (h *Host) ProvisionPhases(phases []provision.Phase, force bool) error {
+	provisioner, err := h.DetectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	swarmOptions := swarm.Options{}
+	if h.HostOptions.SwarmOptions != nil {
+		swarmOptions = *h.HostOptions.SwarmOptions
+	}
+
+	if err := provision.ProvisionPhases(provisioner, phases, force, swarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+		return err
+	}
+
+	for _, phase := range phases {
+		if phase == provision.PhaseSwarm {
+			if err := h.ConfigureSwarmMode(); err != nil {
+				return err
+			}
+		}
+	}
+
+	now := time.Now()
+	h.LastProvisioned = &now
+
+	return nil
+}
+
+// ConfigureFirewall makes the firewall of the machine only allow SSH, the
+// engine, the ports of swarm mode, k3s and Kubernetes, and the exposed
+// ports.
+func (h *Host) ConfigureFirewall(provisioner provision.Provisioner) error {
+	if !h.HostOptions.FirewallOptions.IsEnabled() {
+		return nil
+	}
+
+	ports, err := h.firewallPorts()
+	if err != nil {
+		return err
+	}
+
+	return firewall.Configure(provisioner, h.HostOptions.FirewallOptions, ports)
+}
+
+// firewallPorts returns the ports the machine is managed through.  The SSH
+// port of the driver may be forwarded to the port 22 of the machine.
+func (h *Host) firewallPorts() ([]string, error) {
+	ports := []string{"22/tcp"}
+
+	sshPort, err := h.Driver.GetSSHPort()
+	if err != nil {
+		return nil, err
+	}
+	if sshPort > 0 && sshPort != 22 {
+		ports = append(ports, fmt.Sprintf("%d/tcp", sshPort))
+	}
+
+	engineURL, err := h.Driver.GetURL()
+	if err != nil {
+		return nil, err
+	}
+	dockerPort := fmt.Sprintf("%d", engine.DefaultPort)
+	if u, err := url.Parse(engineURL); err == nil && u.Port() != "" {
+		dockerPort = u.Port()
+	}
+	ports = append(ports, dockerPort+"/tcp")
+
+	if h.HostOptions.SwarmOptions != nil && h.HostOptions.SwarmOptions.Master {
+		if u, err := url.Parse(h.HostOptions.SwarmOptions.Host); err == nil && u.Port() != "" {
+			ports = append(ports, u.Port()+"/tcp")
+		}
+	}
+
+	if h.HostOptions.SwarmModeOptions.IsSwarmMode() {
+		ports = append(ports, fmt.Sprintf("%d/tcp", swarmmode.DefaultPort), "7946/tcp", "7946/udp", "4789/udp")
+	}
+
+	if h.HostOptions.K3sOptions.IsK3s() {
+		if h.HostOptions.K3sOptions.Server {
+			ports = append(ports, fmt.Sprintf("%d/tcp", k3s.DefaultPort))
+		}
+		ports = append(ports, "8472/udp", "10250/tcp")
+	}
+
+	if h.HostOptions.KubernetesOptions.IsKubernetes() {
+		if h.HostOptions.KubernetesOptions.IsControlPlane() {
+			ports = append(ports, fmt.Sprintf("%d/tcp", kubernetes.DefaultPort), "2379-2380/tcp", "10257/tcp", "10259/tcp")
+		} else {
+			ports = append(ports, "30000-32767/tcp")
+		}
+		ports = append(ports, "8472/udp", "10250/tcp")
+	}
+
+	return ports, nil
+}
+
+// ConfigureSwarmMode makes the engine a node of the swarm of the options of
+// the machine, if any.
+func (h *Host) ConfigureSwarmMode() error {
+	if !h.HostOptions.SwarmModeOptions.IsSwarmMode() {
+		return nil
+	}
+
+	ip, err := h.Driver.GetIP()
 	if err != nil {
 		return err
 	}
 
-	return provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	return swarmmode.Configure(h, ip, h.HostOptions.SwarmModeOptions)
 }
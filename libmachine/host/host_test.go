@@ -1,12 +1,17 @@
 package host
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/docker/machine/drivers/fakedriver"
 	_ "github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/k3s"
+	"github.com/docker/machine/libmachine/kubernetes"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarmmode"
 )
 
 func TestValidateHostnameValid(t *testing.T) {
@@ -55,3 +60,142 @@ func TestStart(t *testing.T) {
 		t.Fatalf("Expected no error but got one: %s", err)
 	}
 }
+
+type resizableDriver struct {
+	*fakedriver.Driver
+	resizedInState state.State
+	size           string
+}
+
+func (d *resizableDriver) Resize(size string) error {
+	d.resizedInState = d.MockState
+	d.size = size
+	return nil
+}
+
+func TestResizeRunningMachine(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: provision.NewNetstatProvisioner(),
+	})
+
+	driver := &resizableDriver{Driver: &fakedriver.Driver{MockState: state.Running}}
+	host := &Host{Name: "foo", Driver: driver}
+
+	if err := host.Resize("s-4vcpu-8gb"); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+
+	if driver.size != "s-4vcpu-8gb" || driver.resizedInState != state.Stopped {
+		t.Fatalf("Expected the machine to be resized while stopped, got %q while %s", driver.size, driver.resizedInState)
+	}
+
+	if driver.MockState != state.Running {
+		t.Fatalf("Expected the machine to be started again, got %s", driver.MockState)
+	}
+}
+
+func TestResizeStoppedMachine(t *testing.T) {
+	driver := &resizableDriver{Driver: &fakedriver.Driver{MockState: state.Stopped}}
+	host := &Host{Name: "foo", Driver: driver}
+
+	if err := host.Resize("t3.large"); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+
+	if driver.size != "t3.large" || driver.MockState != state.Stopped {
+		t.Fatalf("Expected the machine to be resized and left stopped, got %q while %s", driver.size, driver.MockState)
+	}
+}
+
+func TestResizeNotSupported(t *testing.T) {
+	host := &Host{Name: "foo", Driver: &fakedriver.Driver{MockState: state.Running}}
+
+	if err := host.Resize("t3.large"); err != drivers.ErrResizeNotSupported {
+		t.Fatalf("Expected %s but got %v", drivers.ErrResizeNotSupported, err)
+	}
+}
+
+func TestFirewallPorts(t *testing.T) {
+	host := &Host{
+		Name:   "foo",
+		Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"},
+		HostOptions: &Options{
+			SwarmModeOptions: &swarmmode.Options{Manager: true},
+			K3sOptions:       &k3s.Options{Agent: true},
+		},
+	}
+
+	ports, err := host.firewallPorts()
+	if err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+
+	expected := []string{"22/tcp", "2376/tcp", "2377/tcp", "7946/tcp", "7946/udp", "4789/udp", "8472/udp", "10250/tcp"}
+	if !reflect.DeepEqual(ports, expected) {
+		t.Fatalf("Expected %v but got %v", expected, ports)
+	}
+}
+
+func TestFirewallPortsKubernetes(t *testing.T) {
+	host := &Host{
+		Name:   "foo",
+		Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"},
+		HostOptions: &Options{
+			KubernetesOptions: &kubernetes.Options{Role: kubernetes.RoleControlPlane},
+		},
+	}
+
+	ports, err := host.firewallPorts()
+	if err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+
+	expected := []string{"22/tcp", "2376/tcp", "6443/tcp", "2379-2380/tcp", "10257/tcp", "10259/tcp", "8472/udp", "10250/tcp"}
+	if !reflect.DeepEqual(ports, expected) {
+		t.Fatalf("Expected %v but got %v", expected, ports)
+	}
+}
+
+func TestDetectProvisionerFromOsRelease(t *testing.T) {
+	host := &Host{
+		Name:        "foo",
+		Driver:      &fakedriver.Driver{},
+		HostOptions: &Options{},
+		OsRelease:   &provision.OsRelease{ID: "debian"},
+	}
+
+	provisioner, err := host.DetectProvisioner()
+	if err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	if provisioner.String() != "debian" {
+		t.Fatalf("Expected the debian provisioner but got %s", provisioner)
+	}
+
+	host.HostOptions.Provisioner = "ubuntu-systemd"
+	provisioner, err = host.DetectProvisioner()
+	if err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	if provisioner.String() != "ubuntu(systemd)" {
+		t.Fatalf("Expected the forced provisioner but got %s", provisioner)
+	}
+}
+
+func TestDetectProvisionerWithRegistry(t *testing.T) {
+	registry := provision.Registry{}
+	registry.Register("Debian", &provision.RegisteredProvisioner{New: provision.NewDebianProvisioner})
+
+	host := &Host{
+		Name:         "foo",
+		Driver:       &fakedriver.Driver{},
+		HostOptions:  &Options{},
+		OsRelease:    &provision.OsRelease{ID: "ubuntu"},
+		Provisioners: registry,
+	}
+
+	if _, err := host.DetectProvisioner(); err != provision.ErrDetectionFailed {
+		t.Fatalf("Expected the detection to fail but got %v", err)
+	}
+}
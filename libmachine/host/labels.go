@@ -0,0 +1,24 @@
+package host
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseLabels returns the labels of a machine given as key=value.
+func ParseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	parsed := make(map[string]string, len(labels))
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("Error: the machine label %q isn't a key=value pair", label)
+		}
+		parsed[kv[0]] = kv[1]
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,27 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"env=ci", "team=web", "empty="})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "ci", "team": "web", "empty": ""}, labels)
+
+	labels, err = ParseLabels(nil)
+
+	assert.NoError(t, err)
+	assert.Nil(t, labels)
+}
+
+func TestParseLabelsInvalid(t *testing.T) {
+	_, err := ParseLabels([]string{"env"})
+	assert.EqualError(t, err, `Error: the machine label "env" isn't a key=value pair`)
+
+	_, err = ParseLabels([]string{"=ci"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+package host
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/auth"
+)
+
+// userDataFlags maps the drivers which pass cloud-init user data to their
+// instances to the create flag of the file they read it from.
+var userDataFlags = map[string]string{
+	"amazonec2":    "amazonec2-userdata",
+	"azure":        "azure-custom-data",
+	"digitalocean": "digitalocean-userdata",
+	"equinixmetal": "equinixmetal-user-data",
+	"exoscale":     "exoscale-userdata",
+	"google":       "google-userdata",
+	"hetzner":      "hetzner-user-data",
+	"linode":       "linode-user-data",
+	"lxd":          "lxd-user-data",
+	"oci":          "oci-user-data",
+	"openstack":    "openstack-user-data-file",
+	"scaleway":     "scaleway-user-data",
+	"vultr":        "vultr-user-data",
+}
+
+// UserDataContext is given to the templates of the user data.
+type UserDataContext struct {
+	MachineName string
+	DriverName  string
+	AuthOptions auth.Options
+	CaCert      string
+}
+
+// UserDataFlag returns the create flag of the driver taking the file of the
+// cloud-init user data.
+func UserDataFlag(driverName string) (string, bool) {
+	flag, ok := userDataFlags[driverName]
+	return flag, ok
+}
+
+// RenderUserData renders the user data given with --user-data, a template
+// of the machine name, the driver name and the auth options.  The Jinja
+// templates of cloud-init, starting with "## template: jinja", are returned
+// as is.
+func (h *Host) RenderUserData(content []byte) ([]byte, error) {
+	if strings.HasPrefix(string(content), "## template: jinja") {
+		return content, nil
+	}
+
+	t, err := template.New("userData").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the user data: %s", err)
+	}
+
+	context := UserDataContext{
+		MachineName: h.Name,
+		DriverName:  h.DriverName,
+	}
+
+	if authOptions := h.AuthOptions(); authOptions != nil {
+		context.AuthOptions = *authOptions
+
+		if authOptions.CaCertPath != "" {
+			caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading the CA certificate: %s", err)
+			}
+			context.CaCert = string(caCert)
+		}
+	}
+
+	var userData bytes.Buffer
+	if err := t.Execute(&userData, context); err != nil {
+		return nil, fmt.Errorf("Error rendering the user data: %s", err)
+	}
+
+	return userData.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package host
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserDataFlag(t *testing.T) {
+	flag, ok := UserDataFlag("amazonec2")
+	assert.True(t, ok)
+	assert.Equal(t, "amazonec2-userdata", flag)
+
+	_, ok = UserDataFlag("virtualbox")
+	assert.False(t, ok)
+}
+
+func TestRenderUserData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caCertPath := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caCertPath, []byte("CERTIFICATE"), 0600))
+
+	h := &Host{
+		Name:       "dev",
+		DriverName: "amazonec2",
+		HostOptions: &Options{
+			AuthOptions: &auth.Options{
+				CaCertPath:       caCertPath,
+				CaCertRemotePath: "/etc/docker/ca.pem",
+			},
+		},
+	}
+
+	userData, err := h.RenderUserData([]byte("#cloud-config\nhostname: {{.MachineName}}\nwrite_files:\n- path: {{.AuthOptions.CaCertRemotePath}}\n  content: {{printf \"%q\" .CaCert}}\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "#cloud-config\nhostname: dev\nwrite_files:\n- path: /etc/docker/ca.pem\n  content: \"CERTIFICATE\"\n", string(userData))
+}
+
+func TestRenderUserDataJinja(t *testing.T) {
+	h := &Host{Name: "dev"}
+	content := []byte("## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n")
+
+	userData, err := h.RenderUserData(content)
+
+	assert.NoError(t, err)
+	assert.Equal(t, content, userData)
+}
+
+func TestRenderUserDataInvalidTemplate(t *testing.T) {
+	h := &Host{Name: "dev"}
+
+	_, err := h.RenderUserData([]byte("hostname: {{.MachineName"))
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,183 @@
+// Package imagefilter resolves the image of a machine from a filter
+// expression, e.g. "name=ubuntu-22.04*,owner=099720109477", to the newest
+// image matching it when the machine is created, so that the image IDs given
+// to the drivers don't go stale.
+package imagefilter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter selects the images of a driver.
+type Filter struct {
+	// Name is a pattern of the names of the images, where * matches any
+	// characters and ? a single one.
+	Name string
+
+	// Owner is who publishes the images: the account on AWS, the project
+	// on GCE, the publisher on Azure.  Empty for the images the driver
+	// lists by default.
+	Owner string
+
+	// Latest selects the newest of the matching images.  Otherwise a
+	// single image must match.
+	Latest bool
+}
+
+// Image is an image of a driver.
+type Image struct {
+	// ID is how the driver is given the image, e.g. an AMI ID.
+	ID string
+
+	// Name is what the pattern of the filter matches.
+	Name string
+
+	// Created is when the image was created, zero if unknown.
+	Created time.Time
+
+	// Version orders the images whose creation time is unknown, e.g. the
+	// versions of the Azure images.
+	Version string
+}
+
+// Parse parses a filter expression, made of comma separated key=value
+// pairs.  It returns nil for an empty expression.
+func Parse(expr string) (*Filter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	f := &Filter{Latest: true}
+	for _, pair := range strings.Split(expr, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Error: invalid image filter %q, expected key=value pairs separated by commas", expr)
+		}
+
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch key {
+		case "name":
+			f.Name = value
+		case "owner":
+			f.Owner = value
+		case "latest":
+			latest, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("Error: invalid image filter %q, latest must be true or false", expr)
+			}
+			f.Latest = latest
+		default:
+			return nil, fmt.Errorf("Error: invalid image filter %q, unknown key %q, expected name, owner or latest", expr, key)
+		}
+	}
+
+	if f.Name == "" {
+		return nil, fmt.Errorf("Error: invalid image filter %q, the name pattern is required", expr)
+	}
+
+	return f, nil
+}
+
+func (f *Filter) String() string {
+	s := "name=" + f.Name
+	if f.Owner != "" {
+		s += ",owner=" + f.Owner
+	}
+	if !f.Latest {
+		s += ",latest=false"
+	}
+	return s
+}
+
+// nameRegexp returns the regular expression of the name pattern.
+func (f *Filter) nameRegexp() *regexp.Regexp {
+	expr := "^"
+	for _, r := range f.Name {
+		switch r {
+		case '*':
+			expr += ".*"
+		case '?':
+			expr += "."
+		default:
+			expr += regexp.QuoteMeta(string(r))
+		}
+	}
+	return regexp.MustCompile(expr + "$")
+}
+
+// MatchName returns true if the name matches the name pattern.
+func (f *Filter) MatchName(name string) bool {
+	return f.nameRegexp().MatchString(name)
+}
+
+// Select returns the image the filter resolves to among the images of the
+// driver: the newest matching one, or else the single matching one.
+func (f *Filter) Select(images []Image) (Image, error) {
+	re := f.nameRegexp()
+
+	matching := []Image{}
+	for _, image := range images {
+		if re.MatchString(image.Name) {
+			matching = append(matching, image)
+		}
+	}
+
+	if len(matching) == 0 {
+		return Image{}, fmt.Errorf("Error: no image matches the filter %q", f.String())
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		return newer(matching[i], matching[j])
+	})
+
+	if len(matching) > 1 && !f.Latest {
+		names := []string{}
+		for i, image := range matching {
+			if i == 5 {
+				names = append(names, "...")
+				break
+			}
+			names = append(names, image.Name)
+		}
+		return Image{}, fmt.Errorf("Error: %d images match the filter %q, narrow it or select the newest with latest=true: %s", len(matching), f.String(), strings.Join(names, ", "))
+	}
+
+	return matching[0], nil
+}
+
+// newer returns true if the image a is newer than b.
+func newer(a, b Image) bool {
+	if !a.Created.Equal(b.Created) {
+		return a.Created.After(b.Created)
+	}
+	return compareVersions(a.Version, b.Version) > 0
+}
+
+// compareVersions compares dotted versions, numerically where both parts
+// are numbers.
+func compareVersions(a, b string) int {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		numberA, errA := strconv.ParseUint(partsA[i], 10, 64)
+		numberB, errB := strconv.ParseUint(partsB[i], 10, 64)
+
+		switch {
+		case errA == nil && errB == nil && numberA != numberB:
+			if numberA > numberB {
+				return 1
+			}
+			return -1
+		case (errA != nil || errB != nil) && partsA[i] != partsB[i]:
+			if partsA[i] > partsB[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	return len(partsA) - len(partsB)
+}
@@ -0,0 +1,110 @@
+package imagefilter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	filter, err := Parse("name=ubuntu-22.04*, owner=099720109477,latest=true")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Filter{Name: "ubuntu-22.04*", Owner: "099720109477", Latest: true}, filter)
+	assert.Equal(t, "name=ubuntu-22.04*,owner=099720109477", filter.String())
+}
+
+func TestParseDefaults(t *testing.T) {
+	filter, err := Parse("name=my-image-*")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Filter{Name: "my-image-*", Latest: true}, filter)
+
+	filter, err = Parse("")
+
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse("ubuntu-22.04*")
+	assert.EqualError(t, err, `Error: invalid image filter "ubuntu-22.04*", expected key=value pairs separated by commas`)
+
+	_, err = Parse("name=ubuntu*,arch=arm64")
+	assert.EqualError(t, err, `Error: invalid image filter "name=ubuntu*,arch=arm64", unknown key "arch", expected name, owner or latest`)
+
+	_, err = Parse("name=ubuntu*,latest=yes")
+	assert.EqualError(t, err, `Error: invalid image filter "name=ubuntu*,latest=yes", latest must be true or false`)
+
+	_, err = Parse("owner=099720109477")
+	assert.EqualError(t, err, `Error: invalid image filter "owner=099720109477", the name pattern is required`)
+}
+
+func TestMatchName(t *testing.T) {
+	filter := &Filter{Name: "ubuntu/images/*-22.04-amd64-server-2024????"}
+
+	assert.True(t, filter.MatchName("ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240301"))
+	assert.False(t, filter.MatchName("ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20231201"))
+	assert.False(t, filter.MatchName("ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240301.1"))
+	assert.False(t, (&Filter{Name: "ubuntu-22.04"}).MatchName("ubuntu-22a04"))
+}
+
+func TestSelectNewest(t *testing.T) {
+	filter := &Filter{Name: "ubuntu-22.04-*", Latest: true}
+
+	image, err := filter.Select([]Image{
+		{ID: "old", Name: "ubuntu-22.04-20231201", Created: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "new", Name: "ubuntu-22.04-20240301", Created: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "other", Name: "debian-12-20240601", Created: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "new", image.ID)
+}
+
+func TestSelectNewestVersion(t *testing.T) {
+	filter := &Filter{Name: "0001-com-ubuntu-server-jammy:22_04-lts*", Latest: true}
+
+	image, err := filter.Select([]Image{
+		{ID: "9", Name: "0001-com-ubuntu-server-jammy:22_04-lts", Version: "22.04.202309080"},
+		{ID: "10", Name: "0001-com-ubuntu-server-jammy:22_04-lts-gen2", Version: "22.04.202401100"},
+		{ID: "latest", Name: "0001-com-ubuntu-server-jammy:22_04-lts", Version: "22.04.202312060"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10", image.ID)
+}
+
+func TestSelectWithoutMatch(t *testing.T) {
+	filter := &Filter{Name: "ubuntu-24.04-*", Latest: true}
+
+	_, err := filter.Select([]Image{{ID: "old", Name: "ubuntu-22.04-20231201"}})
+
+	assert.EqualError(t, err, `Error: no image matches the filter "name=ubuntu-24.04-*"`)
+}
+
+func TestSelectSingle(t *testing.T) {
+	filter := &Filter{Name: "ubuntu-22.04-*"}
+	images := []Image{
+		{ID: "old", Name: "ubuntu-22.04-20231201", Created: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "new", Name: "ubuntu-22.04-20240301", Created: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	_, err := filter.Select(images)
+
+	assert.EqualError(t, err, `Error: 2 images match the filter "name=ubuntu-22.04-*,latest=false", narrow it or select the newest with latest=true: ubuntu-22.04-20240301, ubuntu-22.04-20231201`)
+
+	filter.Name = "ubuntu-22.04-20231201"
+	image, err := filter.Select(images)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "old", image.ID)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.True(t, compareVersions("22.04.202401100", "22.04.202312060") > 0)
+	assert.True(t, compareVersions("1.10", "1.9") > 0)
+	assert.True(t, compareVersions("1.9", "1.9.1") < 0)
+	assert.Equal(t, 0, compareVersions("latest", "latest"))
+}
@@ -0,0 +1,284 @@
+// Package inventory renders the machines as an Ansible inventory or as an
+// OpenSSH configuration, and records the files they were exported to so that
+// they are kept in sync with the lifecycle of the machines.
+package inventory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConfigFileName is the name of the file recording the synced exports, in
+// the storage path.
+const ConfigFileName = "inventories.json"
+
+const (
+	FormatAnsible   = "ansible"
+	FormatSSHConfig = "ssh-config"
+)
+
+const header = "# Generated by docker-machine export-inventory, changes are overwritten."
+
+// Host is how a machine is reached over SSH.
+type Host struct {
+	Name            string
+	Driver          string
+	Address         string
+	Port            int
+	User            string
+	KeyPath         string
+	CertificatePath string
+
+	// ProxyJump is the bastion the machine is reached through, as
+	// user@host:port.
+	ProxyJump string
+
+	Labels map[string]string
+}
+
+// ValidateFormat checks that the format is ansible or ssh-config.
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatAnsible, FormatSSHConfig:
+		return nil
+	}
+	return fmt.Errorf("Invalid inventory format %q, expected %s or %s", format, FormatAnsible, FormatSSHConfig)
+}
+
+// Render writes the hosts in the format, sorted by name.
+func Render(w io.Writer, format string, hosts []Host) error {
+	if err := ValidateFormat(format); err != nil {
+		return err
+	}
+
+	sorted := make([]Host, len(hosts))
+	copy(sorted, hosts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	bw := bufio.NewWriter(w)
+	if format == FormatAnsible {
+		renderAnsible(bw, sorted)
+	} else {
+		renderSSHConfig(bw, sorted)
+	}
+	return bw.Flush()
+}
+
+// quote quotes a string for YAML, JSON strings being valid YAML.
+func quote(s string) string {
+	buf, _ := json.Marshal(s)
+	return string(buf)
+}
+
+var invalidGroupChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// groupName returns a valid Ansible group name, made of letters, digits and
+// underscores.
+func groupName(parts ...string) string {
+	return invalidGroupChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+// sshArgs are the options of OpenSSH the machines need which Ansible has no
+// variable for.
+func sshArgs(h Host) string {
+	args := []string{"-o StrictHostKeyChecking=no", "-o UserKnownHostsFile=/dev/null"}
+	if h.CertificatePath != "" {
+		args = append(args, "-o CertificateFile="+h.CertificatePath)
+	}
+	if h.ProxyJump != "" {
+		args = append(args, "-o ProxyJump="+h.ProxyJump)
+	}
+	return strings.Join(args, " ")
+}
+
+// renderAnsible writes a YAML inventory, the machines being grouped by driver
+// as driver_<driver>, and by label as <key>_<value>.
+func renderAnsible(w io.Writer, hosts []Host) {
+	groups := map[string][]string{}
+	for _, h := range hosts {
+		if h.Driver != "" {
+			group := groupName("driver", h.Driver)
+			groups[group] = append(groups[group], h.Name)
+		}
+		for key, value := range h.Labels {
+			group := groupName(key, value)
+			groups[group] = append(groups[group], h.Name)
+		}
+	}
+
+	fmt.Fprintln(w, header)
+	fmt.Fprintln(w, "all:")
+
+	if len(hosts) == 0 {
+		fmt.Fprintln(w, "  hosts: {}")
+		return
+	}
+
+	fmt.Fprintln(w, "  hosts:")
+	for _, h := range hosts {
+		fmt.Fprintf(w, "    %s:\n", quote(h.Name))
+		fmt.Fprintf(w, "      ansible_host: %s\n", quote(h.Address))
+		fmt.Fprintf(w, "      ansible_port: %d\n", h.Port)
+		fmt.Fprintf(w, "      ansible_user: %s\n", quote(h.User))
+		if h.KeyPath != "" {
+			fmt.Fprintf(w, "      ansible_ssh_private_key_file: %s\n", quote(h.KeyPath))
+		}
+		fmt.Fprintf(w, "      ansible_ssh_common_args: %s\n", quote(sshArgs(h)))
+	}
+
+	if len(groups) == 0 {
+		return
+	}
+
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "  children:")
+	for _, name := range names {
+		fmt.Fprintf(w, "    %s:\n", name)
+		fmt.Fprintln(w, "      hosts:")
+		sort.Strings(groups[name])
+		for _, host := range groups[name] {
+			fmt.Fprintf(w, "        %s: {}\n", quote(host))
+		}
+	}
+}
+
+// sshConfigValue quotes a value of the OpenSSH configuration with spaces.
+func sshConfigValue(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// renderSSHConfig writes a Host section per machine, to be included in
+// ~/.ssh/config with Include.
+func renderSSHConfig(w io.Writer, hosts []Host) {
+	fmt.Fprintln(w, header)
+
+	for _, h := range hosts {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Host %s\n", h.Name)
+		fmt.Fprintf(w, "    HostName %s\n", h.Address)
+		fmt.Fprintf(w, "    Port %d\n", h.Port)
+		fmt.Fprintf(w, "    User %s\n", h.User)
+		if h.KeyPath != "" {
+			fmt.Fprintf(w, "    IdentityFile %s\n", sshConfigValue(h.KeyPath))
+			fmt.Fprintln(w, "    IdentitiesOnly yes")
+		}
+		if h.CertificatePath != "" {
+			fmt.Fprintf(w, "    CertificateFile %s\n", sshConfigValue(h.CertificatePath))
+		}
+		if h.ProxyJump != "" {
+			fmt.Fprintf(w, "    ProxyJump %s\n", h.ProxyJump)
+		}
+		fmt.Fprintln(w, "    StrictHostKeyChecking no")
+		fmt.Fprintln(w, "    UserKnownHostsFile /dev/null")
+	}
+}
+
+// WriteFile renders the hosts to a file, replaced at once so that a reader
+// never sees it half written.
+func WriteFile(path, format string, hosts []Host) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = Render(f, format, hosts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// Windows can't rename in place
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Export is a file the machines were exported to, rewritten when a machine
+// is created, started, stopped, provisioned or removed.
+type Export struct {
+	Format string `json:"format"`
+
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+
+	// Filters are the filters of ls the exported machines match.
+	Filters []string `json:"filters,omitempty"`
+}
+
+// Config is the configuration file of the synced exports.
+type Config struct {
+	Exports []Export `json:"exports"`
+}
+
+// Load reads the configuration file of the synced exports.  A missing file
+// is no exports.
+func Load(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("Error reading the synced inventories of %s: %s", path, err)
+	}
+
+	for _, export := range config.Exports {
+		if err := ValidateFormat(export.Format); err != nil {
+			return nil, fmt.Errorf("Error reading the synced inventories of %s: %s", path, err)
+		}
+	}
+
+	return config, nil
+}
+
+// Save writes the configuration file of the synced exports.
+func (c *Config) Save(path string) error {
+	content, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// Add records an export, replacing the export to the same file.
+func (c *Config) Add(export Export) {
+	c.Remove(export.Path)
+	c.Exports = append(c.Exports, export)
+}
+
+// Remove forgets the export to a file, and returns false if there was none.
+func (c *Config) Remove(path string) bool {
+	for i, export := range c.Exports {
+		if export.Path == path {
+			c.Exports = append(c.Exports[:i], c.Exports[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
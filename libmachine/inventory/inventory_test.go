@@ -0,0 +1,178 @@
+package inventory
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testHosts = []Host{
+	{
+		Name:      "web",
+		Driver:    "amazonec2",
+		Address:   "203.0.113.10",
+		Port:      22,
+		User:      "ubuntu",
+		KeyPath:   "/store/machines/web/id_rsa",
+		ProxyJump: "admin@bastion.example.com:22",
+		Labels:    map[string]string{"env": "prod"},
+	},
+	{
+		Name:            "ci-1",
+		Driver:          "kvm",
+		Address:         "192.168.122.5",
+		Port:            22,
+		User:            "docker",
+		KeyPath:         "/my store/machines/ci-1/id_rsa",
+		CertificatePath: "/my store/machines/ci-1/id_rsa-cert.pub",
+	},
+}
+
+func TestRenderAnsible(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := Render(&buf, FormatAnsible, testHosts)
+
+	assert.NoError(t, err)
+	assert.Equal(t, header+`
+all:
+  hosts:
+    "ci-1":
+      ansible_host: "192.168.122.5"
+      ansible_port: 22
+      ansible_user: "docker"
+      ansible_ssh_private_key_file: "/my store/machines/ci-1/id_rsa"
+      ansible_ssh_common_args: "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o CertificateFile=/my store/machines/ci-1/id_rsa-cert.pub"
+    "web":
+      ansible_host: "203.0.113.10"
+      ansible_port: 22
+      ansible_user: "ubuntu"
+      ansible_ssh_private_key_file: "/store/machines/web/id_rsa"
+      ansible_ssh_common_args: "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o ProxyJump=admin@bastion.example.com:22"
+  children:
+    driver_amazonec2:
+      hosts:
+        "web": {}
+    driver_kvm:
+      hosts:
+        "ci-1": {}
+    env_prod:
+      hosts:
+        "web": {}
+`, buf.String())
+}
+
+func TestRenderAnsibleEmpty(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := Render(&buf, FormatAnsible, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, header+"\nall:\n  hosts: {}\n", buf.String())
+}
+
+func TestRenderSSHConfig(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := Render(&buf, FormatSSHConfig, testHosts)
+
+	assert.NoError(t, err)
+	assert.Equal(t, header+`
+
+Host ci-1
+    HostName 192.168.122.5
+    Port 22
+    User docker
+    IdentityFile "/my store/machines/ci-1/id_rsa"
+    IdentitiesOnly yes
+    CertificateFile "/my store/machines/ci-1/id_rsa-cert.pub"
+    StrictHostKeyChecking no
+    UserKnownHostsFile /dev/null
+
+Host web
+    HostName 203.0.113.10
+    Port 22
+    User ubuntu
+    IdentityFile /store/machines/web/id_rsa
+    IdentitiesOnly yes
+    ProxyJump admin@bastion.example.com:22
+    StrictHostKeyChecking no
+    UserKnownHostsFile /dev/null
+`, buf.String())
+}
+
+func TestRenderInvalidFormat(t *testing.T) {
+	err := Render(ioutil.Discard, "json", testHosts)
+
+	assert.EqualError(t, err, `Invalid inventory format "json", expected ansible or ssh-config`)
+}
+
+func TestGroupName(t *testing.T) {
+	assert.Equal(t, "driver_vmwarevsphere", groupName("driver", "vmwarevsphere"))
+	assert.Equal(t, "team_web_front", groupName("team", "web-front"))
+	assert.Equal(t, "com_example_role_", groupName("com.example.role", ""))
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ssh_config")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("stale"), 0600))
+
+	assert.NoError(t, WriteFile(path, FormatSSHConfig, testHosts[:1]))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "Host web\n")
+
+	entries, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ConfigFileName)
+
+	config, err := Load(path)
+	assert.NoError(t, err)
+	assert.Empty(t, config.Exports)
+
+	config.Add(Export{Format: FormatAnsible, Path: "/tmp/hosts.yml"})
+	config.Add(Export{Format: FormatSSHConfig, Path: "/tmp/ssh_config", Filters: []string{"driver=kvm"}})
+	config.Add(Export{Format: FormatAnsible, Path: "/tmp/hosts.yml", Filters: []string{"label=env=prod"}})
+	assert.NoError(t, config.Save(path))
+
+	config, err = Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []Export{
+		{Format: FormatSSHConfig, Path: "/tmp/ssh_config", Filters: []string{"driver=kvm"}},
+		{Format: FormatAnsible, Path: "/tmp/hosts.yml", Filters: []string{"label=env=prod"}},
+	}, config.Exports)
+
+	assert.True(t, config.Remove("/tmp/ssh_config"))
+	assert.False(t, config.Remove("/tmp/ssh_config"))
+	assert.Len(t, config.Exports, 1)
+}
+
+func TestLoadInvalidFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ConfigFileName)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"exports": [{"format": "ini", "path": "/tmp/hosts"}]}`), 0600))
+
+	_, err = Load(path)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package k3s
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+)
+
+const (
+	DefaultInstallURL = "https://get.k3s.io"
+	DefaultPort       = 6443
+
+	// KubeconfigFile is the name of the kubeconfig of a server in its
+	// machine directory.
+	KubeconfigFile = "kubeconfig"
+
+	remoteTokenPath      = "/var/lib/rancher/k3s/server/node-token"
+	remoteKubeconfigPath = "/etc/rancher/k3s/k3s.yaml"
+
+	// remoteAgentTokenPath is the file, readable by root only, the agent
+	// reads its join token from.
+	remoteAgentTokenPath = "/etc/rancher/k3s/agent-token"
+
+	// remoteAgentUninstallPath is installed along with the agent.
+	remoteAgentUninstallPath = "/usr/local/bin/k3s-agent-uninstall.sh"
+
+	tokenCommand = "sudo cat " + remoteTokenPath
+)
+
+var (
+	ErrServerAndAgent = errors.New("Error: --k3s-server and --k3s-agent are mutually exclusive")
+	ErrNoServerURL    = errors.New("Error: --k3s-agent requires the URL of the server with --k3s-url")
+	ErrNoJoinToken    = errors.New("Error: No join token for the k3s server, use --k3s-token or create the server with Machine")
+	ErrNotServer      = errors.New("Error: --k3s-url must be the URL of a k3s server created with Machine")
+)
+
+type Options struct {
+	Server     bool
+	Agent      bool
+	InstallURL string
+
+	// URL is the URL of the server, the one joined by an agent or the one
+	// advertised by a server.
+	URL string
+
+	// Token is the token joining an agent to the server, given with
+	// --k3s-token or read from the server when the agent joins it.  It's
+	// never saved.
+	Token string `json:"-"`
+}
+
+// IsK3s returns true if the machine is a k3s server or agent.
+func (o *Options) IsK3s() bool {
+	return o != nil && (o.Server || o.Agent)
+}
+
+// Validate checks the options given on the command line.
+func (o *Options) Validate() error {
+	if o.Server && o.Agent {
+		return ErrServerAndAgent
+	}
+
+	if o.Agent && o.URL == "" {
+		return ErrNoServerURL
+	}
+
+	return nil
+}
+
+// SetJoin reads the join token of the server joined, with the function
+// running commands on it.
+func (o *Options) SetJoin(server *Options, runOnServer func(command string) (string, error)) error {
+	if !server.IsK3s() || !server.Server {
+		return ErrNotServer
+	}
+
+	token, err := runOnServer(tokenCommand)
+	if err != nil {
+		return fmt.Errorf("Error reading the k3s join token of %s: %s", o.URL, err)
+	}
+
+	o.Token = strings.TrimSpace(token)
+	return nil
+}
+
+// Configure installs k3s over SSH as a server or as an agent, unless the
+// agent is already installed.  The server records its URL in the options, and
+// its kubeconfig is written to the machine directory storePath.
+func Configure(p provision.Provisioner, options *Options, storePath string) error {
+	if !options.IsK3s() {
+		return nil
+	}
+
+	if options.Server {
+		return configureServer(p, options, storePath)
+	}
+
+	return configureAgent(p, options)
+}
+
+func installCommand(options *Options, env []string, args string) string {
+	installURL := options.InstallURL
+	if installURL == "" {
+		installURL = DefaultInstallURL
+	}
+
+	return fmt.Sprintf("curl -sfL %s | sudo %s sh -s - %s", installURL, strings.Join(env, " "), args)
+}
+
+func configureServer(p provision.Provisioner, options *Options, storePath string) error {
+	ip, err := p.GetDriver().GetIP()
+	if err != nil {
+		return err
+	}
+
+	log.Info("Installing the k3s server...")
+	if output, err := p.SSHCommand(installCommand(options, []string{"INSTALL_K3S_EXEC=server"}, fmt.Sprintf("--tls-san %s", ip))); err != nil {
+		return fmt.Errorf("Error installing the k3s server: %s\n%s", err, output)
+	}
+
+	options.URL = ServerURL(ip)
+
+	kubeconfig, err := p.SSHCommand(fmt.Sprintf("sudo cat %s", remoteKubeconfigPath))
+	if err != nil {
+		return fmt.Errorf("Error retrieving the k3s kubeconfig: %s", err)
+	}
+
+	path := filepath.Join(storePath, KubeconfigFile)
+	log.Debugf("writing the k3s kubeconfig to %s", path)
+
+	return ioutil.WriteFile(path, []byte(RewriteKubeconfig(kubeconfig, options.URL)), 0600)
+}
+
+// writeToken writes the join token of the agent given on the standard input
+// of the command, so that the token is never part of a command line, which is
+// logged and visible in the processes of the machine.  It's a variable so
+// that the tests can record the commands.
+var writeToken = func(p provision.Provisioner, token string) (string, error) {
+	command := fmt.Sprintf("sudo mkdir -p %s && sudo sh -c 'umask 077 && cat > %s'", filepath.Dir(remoteAgentTokenPath), remoteAgentTokenPath)
+	return drivers.RunSSHCommandWithInputFromDriver(p.GetDriver(), command, strings.NewReader(token))
+}
+
+func configureAgent(p provision.Provisioner, options *Options) error {
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo test -f %s", remoteAgentUninstallPath)); err == nil {
+		log.Debug("the k3s agent is already installed")
+		return nil
+	}
+
+	if options.Token == "" {
+		return ErrNoJoinToken
+	}
+
+	if output, err := writeToken(p, options.Token); err != nil {
+		return fmt.Errorf("Error writing the k3s join token: %s\n%s", err, output)
+	}
+
+	log.Infof("Installing the k3s agent joining %s...", options.URL)
+	env := []string{
+		fmt.Sprintf("K3S_URL=%s", options.URL),
+		fmt.Sprintf("K3S_TOKEN_FILE=%s", remoteAgentTokenPath),
+	}
+	if output, err := p.SSHCommand(installCommand(options, env, "agent")); err != nil {
+		return fmt.Errorf("Error installing the k3s agent: %s\n%s", err, output)
+	}
+
+	return nil
+}
+
+// ServerURL returns the URL of a server given its IP address.
+func ServerURL(ip string) string {
+	return fmt.Sprintf("https://%s", net.JoinHostPort(ip, fmt.Sprint(DefaultPort)))
+}
+
+// RewriteKubeconfig points the kubeconfig of a server, written for local
+// use, to the server URL.
+func RewriteKubeconfig(kubeconfig, url string) string {
+	return strings.Replace(kubeconfig, fmt.Sprintf("https://127.0.0.1:%d", DefaultPort), url, -1)
+}
@@ -0,0 +1,134 @@
+package k3s
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProvisioner struct {
+	provision.FakeProvisioner
+	commander *provisiontest.FakeSSHCommander
+	driver    drivers.Driver
+}
+
+func (p *fakeProvisioner) SSHCommand(args string) (string, error) {
+	return p.commander.SSHCommand(args)
+}
+
+func (p *fakeProvisioner) GetDriver() drivers.Driver {
+	return p.driver
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Options{Server: true}).Validate())
+	assert.NoError(t, (&Options{Agent: true, URL: "https://10.0.0.1:6443"}).Validate())
+	assert.Equal(t, ErrServerAndAgent, (&Options{Server: true, Agent: true}).Validate())
+	assert.Equal(t, ErrNoServerURL, (&Options{Agent: true}).Validate())
+}
+
+func TestConfigureServer(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "k3s-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	p := &fakeProvisioner{
+		commander: &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"curl -sfL https://get.k3s.io | sudo INSTALL_K3S_EXEC=server sh -s - --tls-san 10.0.0.1": "",
+				"sudo cat /etc/rancher/k3s/k3s.yaml":                                                     "server: https://127.0.0.1:6443\n",
+			},
+		},
+		driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"},
+	}
+	options := &Options{Server: true}
+
+	assert.NoError(t, Configure(p, options, storePath))
+
+	assert.Empty(t, options.Token)
+	assert.Equal(t, "https://10.0.0.1:6443", options.URL)
+
+	kubeconfig, err := ioutil.ReadFile(filepath.Join(storePath, KubeconfigFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "server: https://10.0.0.1:6443\n", string(kubeconfig))
+}
+
+// recordTokens replaces writeToken with a function recording the tokens.
+func recordTokens() (*[]string, func()) {
+	tokens := []string{}
+	original := writeToken
+	writeToken = func(p provision.Provisioner, token string) (string, error) {
+		tokens = append(tokens, token)
+		return "", nil
+	}
+	return &tokens, func() { writeToken = original }
+}
+
+func TestConfigureAgent(t *testing.T) {
+	tokens, restore := recordTokens()
+	defer restore()
+
+	// The token is written on its own, not given to the install script
+	p := &fakeProvisioner{
+		commander: &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"curl -sfL https://get.k3s.io | sudo K3S_URL=https://10.0.0.1:6443 K3S_TOKEN_FILE=/etc/rancher/k3s/agent-token sh -s - agent": "",
+			},
+		},
+	}
+
+	assert.NoError(t, Configure(p, &Options{Agent: true, URL: "https://10.0.0.1:6443", Token: "secret"}, ""))
+	assert.Equal(t, []string{"secret"}, *tokens)
+
+	assert.Equal(t, ErrNoJoinToken, Configure(p, &Options{Agent: true, URL: "https://10.0.0.1:6443"}, ""))
+}
+
+func TestConfigureAgentAlreadyInstalled(t *testing.T) {
+	tokens, restore := recordTokens()
+	defer restore()
+
+	p := &fakeProvisioner{
+		commander: &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"sudo test -f /usr/local/bin/k3s-agent-uninstall.sh": "",
+			},
+		},
+	}
+
+	assert.NoError(t, Configure(p, &Options{Agent: true, URL: "https://10.0.0.1:6443"}, ""))
+	assert.Empty(t, *tokens)
+}
+
+func TestSetJoin(t *testing.T) {
+	options := &Options{Agent: true, URL: "https://10.0.0.1:6443"}
+	run := func(command string) (string, error) {
+		assert.Equal(t, "sudo cat /var/lib/rancher/k3s/server/node-token", command)
+		return "K10::server:secret\n", nil
+	}
+
+	assert.NoError(t, options.SetJoin(&Options{Server: true, URL: "https://10.0.0.1:6443"}, run))
+	assert.Equal(t, "K10::server:secret", options.Token)
+
+	assert.Equal(t, ErrNotServer, options.SetJoin(&Options{Agent: true}, run))
+	assert.Equal(t, ErrNotServer, options.SetJoin(nil, run))
+}
+
+func TestTokenIsNotSaved(t *testing.T) {
+	data, err := json.Marshal(&Options{Agent: true, URL: "https://10.0.0.1:6443", Token: "secret"})
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+}
+
+func TestConfigureWithoutK3s(t *testing.T) {
+	assert.NoError(t, Configure(&fakeProvisioner{}, nil, ""))
+}
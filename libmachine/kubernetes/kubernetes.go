@@ -0,0 +1,376 @@
+// Package kubernetes bootstraps a Kubernetes cluster with kubeadm on the
+// machines: kubeadm, kubelet and containerd are installed with the package
+// manager of the distribution, then the control plane is initialized with
+// kubeadm init and the workers join it with kubeadm join.
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+const (
+	RoleControlPlane = "control-plane"
+	RoleWorker       = "worker"
+
+	// DefaultVersion is the minor version of Kubernetes whose packages are
+	// installed.
+	DefaultVersion = "v1.30"
+
+	// DefaultPort is the port of the API server of the control plane.
+	DefaultPort = 6443
+
+	// DefaultCNIManifest is the pod network applied by the control plane,
+	// flannel, whose network is PodNetworkCIDR.
+	DefaultCNIManifest = "https://github.com/flannel-io/flannel/releases/latest/download/kube-flannel.yml"
+
+	// PodNetworkCIDR is the network of the pods, the default one of
+	// flannel.
+	PodNetworkCIDR = "10.244.0.0/16"
+
+	// KubeconfigFile is the name of the admin kubeconfig of a control plane
+	// in its machine directory.
+	KubeconfigFile = "kubeconfig"
+
+	// JoinTokenTTL is how long the join token created on the control plane
+	// for a worker is valid.
+	JoinTokenTTL = "24h"
+
+	familyDebian = "debian"
+	familyRedHat = "redhat"
+
+	// criSocket is the socket of containerd, given to kubeadm which would
+	// otherwise find the one of the Docker engine too.
+	criSocket = "unix:///run/containerd/containerd.sock"
+
+	adminKubeconfigPath   = "/etc/kubernetes/admin.conf"
+	kubeletKubeconfigPath = "/etc/kubernetes/kubelet.conf"
+	containerdConfigPath  = "/etc/containerd/config.toml"
+	modulesFile           = "/etc/modules-load.d/kubernetes.conf"
+	sysctlFile            = "/etc/sysctl.d/99-kubernetes.conf"
+	joinTokenCommand      = "sudo kubeadm token create --ttl " + JoinTokenTTL + " --print-join-command"
+	aptKeyringPath        = "/etc/apt/keyrings/kubernetes-apt-keyring.gpg"
+	aptSourcesFile        = "/etc/apt/sources.list.d/kubernetes.list"
+	yumRepoFile           = "/etc/yum.repos.d/kubernetes.repo"
+	packagesURL           = "https://pkgs.k8s.io/core:/stable:/%s"
+)
+
+const yumRepoTemplate = `[kubernetes]
+name=Kubernetes
+baseurl=%[1]s/rpm/
+enabled=1
+gpgcheck=1
+gpgkey=%[1]s/rpm/repodata/repomd.xml.key
+`
+
+var (
+	ErrInvalidRole      = errors.New(`Error: --k8s-role must be "control-plane" or "worker"`)
+	ErrInvalidVersion   = errors.New("Error: --k8s-version must be a minor version of Kubernetes, e.g. v1.30")
+	ErrNoJoin           = errors.New("Error: --k8s-role worker requires the control plane to join with --k8s-join")
+	ErrJoinControlPlane = errors.New("Error: --k8s-join only applies to workers, a cluster has a single control plane")
+	ErrNotControlPlane  = errors.New("Error: --k8s-join must be the control plane of a Kubernetes cluster created with Machine")
+	ErrNoJoinToken      = errors.New("Error: No join token for the Kubernetes cluster, the worker joins it when it's created")
+	ErrNotSupported     = errors.New("Error: Kubernetes can only be installed on Debian, Ubuntu, Red Hat Enterprise Linux and Fedora machines")
+
+	versionRegexp     = regexp.MustCompile(`^v1\.[0-9]+$`)
+	joinCommandRegexp = regexp.MustCompile(`kubeadm join (\S+) --token (\S+) .*--discovery-token-ca-cert-hash (\S+)`)
+	serverRegexp      = regexp.MustCompile(`server: https://\S+`)
+)
+
+type Options struct {
+	// Role is RoleControlPlane or RoleWorker.
+	Role string
+
+	// Join is the name of the control plane machine whose cluster a worker
+	// joins.
+	Join string `json:",omitempty"`
+
+	// Version is the minor version of Kubernetes, e.g. v1.30.
+	Version string
+
+	// CNIManifest is the manifest of the pod network applied by the control
+	// plane, none if empty.
+	CNIManifest string `json:",omitempty"`
+
+	// Endpoint, Token and CACertHash join a worker to the cluster of its
+	// control plane.  The token is created on the control plane, valid for
+	// JoinTokenTTL, each time a worker joins, and is never saved.
+	Endpoint   string `json:",omitempty"`
+	Token      string `json:"-"`
+	CACertHash string `json:",omitempty"`
+}
+
+// IsKubernetes returns true if the machine is a node of a Kubernetes
+// cluster.
+func (o *Options) IsKubernetes() bool {
+	return o != nil
+}
+
+// IsControlPlane returns true if the machine is the control plane of a
+// Kubernetes cluster.
+func (o *Options) IsControlPlane() bool {
+	return o.IsKubernetes() && o.Role == RoleControlPlane
+}
+
+// Validate checks the options given on the command line.
+func (o *Options) Validate() error {
+	if o.Role != RoleControlPlane && o.Role != RoleWorker {
+		return ErrInvalidRole
+	}
+
+	if !versionRegexp.MatchString(o.Version) {
+		return ErrInvalidVersion
+	}
+
+	if o.Role == RoleWorker && o.Join == "" {
+		return ErrNoJoin
+	}
+
+	if o.Role == RoleControlPlane && o.Join != "" {
+		return ErrJoinControlPlane
+	}
+
+	return nil
+}
+
+// SetJoin creates a join token on the control plane joined, with the
+// function running commands on it, and sets the endpoint of its cluster with
+// the token.
+func (o *Options) SetJoin(controlPlane *Options, runOnControlPlane func(command string) (string, error)) error {
+	if !controlPlane.IsControlPlane() {
+		return ErrNotControlPlane
+	}
+
+	joinCommand, err := runOnControlPlane(joinTokenCommand)
+	if err != nil {
+		return fmt.Errorf("Error creating a join token on %s: %s", o.Join, err)
+	}
+
+	return o.setJoinCommand(joinCommand)
+}
+
+// Configure installs kubeadm, kubelet and containerd, then initializes the
+// control plane or joins the worker to its cluster, unless it's already
+// done.  The admin kubeconfig of the control plane is written to the machine
+// directory storePath.
+func Configure(p provision.Provisioner, options *Options, storePath string) error {
+	if !options.IsKubernetes() {
+		return nil
+	}
+
+	info, err := p.GetOsReleaseInfo()
+	if err != nil {
+		return err
+	}
+
+	if err := install(p, family(info), options.Version); err != nil {
+		return err
+	}
+
+	if options.IsControlPlane() {
+		return configureControlPlane(p, options, storePath)
+	}
+
+	return configureWorker(p, options)
+}
+
+// family returns the family of the distribution of the machine, whose
+// package manager installs Kubernetes.
+func family(info *provision.OsRelease) string {
+	if info == nil {
+		return ""
+	}
+
+	ids := append([]string{info.ID}, strings.Fields(info.IDLike)...)
+	for _, id := range ids {
+		switch id {
+		case "debian", "ubuntu":
+			return familyDebian
+		case "rhel", "centos", "fedora", "rocky", "almalinux", "ol":
+			return familyRedHat
+		}
+	}
+	return ""
+}
+
+func install(p provision.Provisioner, family, version string) error {
+	var repositoryCommands []string
+	switch family {
+	case familyDebian:
+		if err := installPackages(p, "curl gpg"); err != nil {
+			return err
+		}
+		repositoryCommands = debianRepositoryCommands(version)
+	case familyRedHat:
+		repositoryCommands = redHatRepositoryCommands(version)
+	default:
+		return ErrNotSupported
+	}
+
+	log.Infof("Installing Kubernetes %s...", version)
+	if err := run(p, "configuring the machine for Kubernetes", append(prerequisiteCommands(), repositoryCommands...)); err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand("command -v containerd"); err != nil {
+		if err := installPackages(p, "containerd"); err != nil {
+			return err
+		}
+	}
+
+	if err := installPackages(p, "kubelet kubeadm kubectl"); err != nil {
+		return err
+	}
+
+	return run(p, "configuring containerd and kubelet", serviceCommands())
+}
+
+func installPackages(p provision.Provisioner, packages string) error {
+	if err := p.Package(packages, pkgaction.Install); err != nil {
+		return fmt.Errorf("Error installing %s: %s", packages, err)
+	}
+	return nil
+}
+
+func run(p provision.Provisioner, action string, commands []string) error {
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error %s: %s\n%s", action, err, output)
+		}
+	}
+	return nil
+}
+
+// writeFile returns the command writing a file, with no single quote.
+func writeFile(content, path string) string {
+	return fmt.Sprintf("printf %%s '%s' | sudo tee %s >/dev/null", content, path)
+}
+
+// prerequisiteCommands disable the swap and let the bridged traffic of the
+// pods through iptables, as kubeadm requires.
+func prerequisiteCommands() []string {
+	return []string{
+		`sudo swapoff -a && sudo sed -i '/\sswap\s/ s/^#*/#/' /etc/fstab`,
+		writeFile("overlay\nbr_netfilter\n", modulesFile) + " && sudo modprobe overlay && sudo modprobe br_netfilter",
+		writeFile("net.bridge.bridge-nf-call-iptables = 1\nnet.bridge.bridge-nf-call-ip6tables = 1\nnet.ipv4.ip_forward = 1\n", sysctlFile) + " && sudo sysctl --system >/dev/null",
+	}
+}
+
+func debianRepositoryCommands(version string) []string {
+	url := fmt.Sprintf(packagesURL, version)
+
+	return []string{
+		fmt.Sprintf("sudo mkdir -p -m 755 /etc/apt/keyrings && curl -fsSL %s/deb/Release.key | sudo gpg --dearmor --yes -o %s", url, aptKeyringPath),
+		writeFile(fmt.Sprintf("deb [signed-by=%s] %s/deb/ /\n", aptKeyringPath, url), aptSourcesFile),
+	}
+}
+
+// redHatRepositoryCommands add the repository of Kubernetes, and make
+// SELinux permissive as kubeadm requires.
+func redHatRepositoryCommands(version string) []string {
+	return []string{
+		writeFile(fmt.Sprintf(yumRepoTemplate, fmt.Sprintf(packagesURL, version)), yumRepoFile),
+		"(sudo setenforce 0 || true) && sudo sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config",
+	}
+}
+
+// serviceCommands enable the CRI plugin of containerd, which the containerd
+// of the Docker packages disables, with the systemd cgroup driver of
+// kubelet.
+func serviceCommands() []string {
+	return []string{
+		fmt.Sprintf("sudo mkdir -p /etc/containerd && containerd config default | sed -e 's/SystemdCgroup = false/SystemdCgroup = true/' | sudo tee %s >/dev/null", containerdConfigPath),
+		"sudo systemctl restart containerd",
+		"sudo systemctl enable --now kubelet",
+	}
+}
+
+func configureControlPlane(p provision.Provisioner, options *Options, storePath string) error {
+	ip, err := p.GetDriver().GetIP()
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo test -f %s", adminKubeconfigPath)); err == nil {
+		log.Debug("the control plane is already initialized")
+	} else {
+		log.Info("Initializing the Kubernetes control plane...")
+		command := fmt.Sprintf("sudo kubeadm init --cri-socket %s --apiserver-cert-extra-sans %s --pod-network-cidr %s", criSocket, ip, PodNetworkCIDR)
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error initializing the control plane: %s\n%s", err, output)
+		}
+	}
+
+	if options.CNIManifest != "" {
+		log.Info("Applying the pod network...")
+		command := fmt.Sprintf("sudo kubectl --kubeconfig %s apply -f %s", adminKubeconfigPath, options.CNIManifest)
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error applying the pod network: %s\n%s", err, output)
+		}
+	}
+
+	kubeconfig, err := p.SSHCommand(fmt.Sprintf("sudo cat %s", adminKubeconfigPath))
+	if err != nil {
+		return fmt.Errorf("Error retrieving the admin kubeconfig: %s", err)
+	}
+
+	path := filepath.Join(storePath, KubeconfigFile)
+	log.Debugf("writing the admin kubeconfig to %s", path)
+
+	return ioutil.WriteFile(path, []byte(RewriteKubeconfig(kubeconfig, ServerURL(ip))), 0600)
+}
+
+// setJoinCommand sets the endpoint and the join token from the join command
+// printed by kubeadm.
+func (o *Options) setJoinCommand(joinCommand string) error {
+	match := joinCommandRegexp.FindStringSubmatch(joinCommand)
+	if match == nil {
+		return fmt.Errorf("Error parsing the join command of kubeadm: %q", strings.TrimSpace(joinCommand))
+	}
+
+	o.Endpoint = match[1]
+	o.Token = match[2]
+	o.CACertHash = match[3]
+
+	return nil
+}
+
+func configureWorker(p provision.Provisioner, options *Options) error {
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo test -f %s", kubeletKubeconfigPath)); err == nil {
+		log.Debug("the worker already joined the cluster")
+		return nil
+	}
+
+	if options.Token == "" {
+		return ErrNoJoinToken
+	}
+
+	log.Infof("Joining the Kubernetes cluster of %s...", options.Join)
+	command := fmt.Sprintf("sudo kubeadm join %s --cri-socket %s --token %s --discovery-token-ca-cert-hash %s", options.Endpoint, criSocket, options.Token, options.CACertHash)
+	if output, err := p.SSHCommand(command); err != nil {
+		return fmt.Errorf("Error joining the cluster: %s\n%s", err, output)
+	}
+
+	return nil
+}
+
+// ServerURL returns the URL of the API server given the IP address of the
+// control plane.
+func ServerURL(ip string) string {
+	return fmt.Sprintf("https://%s", net.JoinHostPort(ip, fmt.Sprint(DefaultPort)))
+}
+
+// RewriteKubeconfig points the admin kubeconfig of the control plane, whose
+// server is its address on the network of the cluster, to the server URL.
+func RewriteKubeconfig(kubeconfig, url string) string {
+	return serverRegexp.ReplaceAllString(kubeconfig, "server: "+url)
+}
@@ -0,0 +1,182 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvisioner records the commands and the installed packages of a
+// machine, answering the commands given responses and failing the others
+// in failing.
+type fakeProvisioner struct {
+	provision.FakeProvisioner
+	info      *provision.OsRelease
+	driver    drivers.Driver
+	responses map[string]string
+	failing   map[string]bool
+	commands  []string
+	packages  []string
+}
+
+func (p *fakeProvisioner) GetOsReleaseInfo() (*provision.OsRelease, error) {
+	return p.info, nil
+}
+
+func (p *fakeProvisioner) GetDriver() drivers.Driver {
+	return p.driver
+}
+
+func (p *fakeProvisioner) SSHCommand(args string) (string, error) {
+	if p.failing[args] {
+		return "", errors.New("exit status 1")
+	}
+
+	p.commands = append(p.commands, args)
+	return p.responses[args], nil
+}
+
+func (p *fakeProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	p.packages = append(p.packages, name)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Options{Role: RoleControlPlane, Version: "v1.30"}).Validate())
+	assert.NoError(t, (&Options{Role: RoleWorker, Version: "v1.30", Join: "cp"}).Validate())
+	assert.Equal(t, ErrInvalidRole, (&Options{Role: "master", Version: "v1.30"}).Validate())
+	assert.Equal(t, ErrInvalidVersion, (&Options{Role: RoleControlPlane, Version: "1.30.2"}).Validate())
+	assert.Equal(t, ErrNoJoin, (&Options{Role: RoleWorker, Version: "v1.30"}).Validate())
+	assert.Equal(t, ErrJoinControlPlane, (&Options{Role: RoleControlPlane, Version: "v1.30", Join: "cp"}).Validate())
+}
+
+func TestSetJoin(t *testing.T) {
+	commands := []string{}
+	runOnControlPlane := func(command string) (string, error) {
+		commands = append(commands, command)
+		return "kubeadm join 10.0.0.1:6443 --token abcdef.0123456789abcdef --discovery-token-ca-cert-hash sha256:1234 \n", nil
+	}
+	worker := &Options{Role: RoleWorker, Join: "cp"}
+
+	assert.NoError(t, worker.SetJoin(&Options{Role: RoleControlPlane}, runOnControlPlane))
+	assert.Equal(t, []string{"sudo kubeadm token create --ttl 24h --print-join-command"}, commands)
+	assert.Equal(t, "10.0.0.1:6443", worker.Endpoint)
+	assert.Equal(t, "abcdef.0123456789abcdef", worker.Token)
+	assert.Equal(t, "sha256:1234", worker.CACertHash)
+
+	assert.Equal(t, ErrNotControlPlane, worker.SetJoin(nil, runOnControlPlane))
+	assert.Equal(t, ErrNotControlPlane, worker.SetJoin(&Options{Role: RoleWorker}, runOnControlPlane))
+
+	err := worker.SetJoin(&Options{Role: RoleControlPlane}, func(command string) (string, error) {
+		return "", errors.New("host is not running")
+	})
+	assert.EqualError(t, err, "Error creating a join token on cp: host is not running")
+}
+
+func TestTokenNotSaved(t *testing.T) {
+	content, err := json.Marshal(&Options{Role: RoleWorker, Join: "cp", Endpoint: "10.0.0.1:6443", Token: "abcdef.0123456789abcdef", CACertHash: "sha256:1234"})
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "abcdef.0123456789abcdef")
+}
+
+func TestConfigureControlPlane(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "kubernetes-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	p := &fakeProvisioner{
+		info:   &provision.OsRelease{ID: "ubuntu", IDLike: "debian"},
+		driver: &fakedriver.Driver{MockState: state.Running, MockIP: "203.0.113.10"},
+		responses: map[string]string{
+			"sudo cat /etc/kubernetes/admin.conf": "    server: https://10.0.0.1:6443\n",
+		},
+		failing: map[string]bool{
+			"sudo test -f /etc/kubernetes/admin.conf": true,
+		},
+	}
+	options := &Options{Role: RoleControlPlane, Version: "v1.30", CNIManifest: "https://example.com/cni.yml"}
+
+	assert.NoError(t, Configure(p, options, storePath))
+
+	assert.Equal(t, []string{"curl gpg", "kubelet kubeadm kubectl"}, p.packages)
+	assert.Contains(t, p.commands, "sudo mkdir -p -m 755 /etc/apt/keyrings && curl -fsSL https://pkgs.k8s.io/core:/stable:/v1.30/deb/Release.key | sudo gpg --dearmor --yes -o /etc/apt/keyrings/kubernetes-apt-keyring.gpg")
+	assert.Contains(t, p.commands, "sudo kubeadm init --cri-socket unix:///run/containerd/containerd.sock --apiserver-cert-extra-sans 203.0.113.10 --pod-network-cidr 10.244.0.0/16")
+	assert.Contains(t, p.commands, "sudo kubectl --kubeconfig /etc/kubernetes/admin.conf apply -f https://example.com/cni.yml")
+
+	for _, command := range p.commands {
+		assert.NotContains(t, command, "kubeadm token create")
+	}
+
+	kubeconfig, err := ioutil.ReadFile(filepath.Join(storePath, KubeconfigFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "    server: https://203.0.113.10:6443\n", string(kubeconfig))
+}
+
+func TestConfigureControlPlaneAlreadyInitialized(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "kubernetes-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	p := &fakeProvisioner{
+		info:   &provision.OsRelease{ID: "fedora"},
+		driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"},
+	}
+	options := &Options{Role: RoleControlPlane, Version: "v1.30"}
+
+	assert.NoError(t, Configure(p, options, storePath))
+
+	assert.Equal(t, []string{"kubelet kubeadm kubectl"}, p.packages)
+	for _, command := range p.commands {
+		assert.NotContains(t, command, "kubeadm init")
+		assert.NotContains(t, command, "kubeadm token create")
+	}
+}
+
+func TestConfigureWorker(t *testing.T) {
+	p := &fakeProvisioner{
+		info: &provision.OsRelease{ID: "rocky", IDLike: "rhel centos fedora"},
+		failing: map[string]bool{
+			"command -v containerd":                     true,
+			"sudo test -f /etc/kubernetes/kubelet.conf": true,
+		},
+	}
+	options := &Options{Role: RoleWorker, Version: "v1.29", Join: "cp", Endpoint: "10.0.0.1:6443", Token: "abcdef.0123456789abcdef", CACertHash: "sha256:1234"}
+
+	assert.NoError(t, Configure(p, options, ""))
+
+	assert.Equal(t, []string{"containerd", "kubelet kubeadm kubectl"}, p.packages)
+	assert.Equal(t, "sudo kubeadm join 10.0.0.1:6443 --cri-socket unix:///run/containerd/containerd.sock --token abcdef.0123456789abcdef --discovery-token-ca-cert-hash sha256:1234", p.commands[len(p.commands)-1])
+
+	options.Token = ""
+	assert.Equal(t, ErrNoJoinToken, Configure(p, options, ""))
+}
+
+func TestConfigureNotSupported(t *testing.T) {
+	p := &fakeProvisioner{info: &provision.OsRelease{ID: "alpine"}}
+
+	assert.Equal(t, ErrNotSupported, Configure(p, &Options{Role: RoleControlPlane, Version: "v1.30"}, ""))
+	assert.Empty(t, p.commands)
+}
+
+func TestConfigureWithoutKubernetes(t *testing.T) {
+	assert.NoError(t, Configure(&fakeProvisioner{}, nil, ""))
+}
+
+func TestSetJoinCommand(t *testing.T) {
+	options := &Options{}
+
+	err := options.setJoinCommand("W1016 warning\n")
+
+	assert.EqualError(t, err, `Error parsing the join command of kubeadm: "W1016 warning"`)
+}
@@ -1,26 +1,35 @@
 package libmachine
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 
 	"io"
+	"time"
 
 	"github.com/docker/machine/drivers/errdriver"
 	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/autoupdates"
 	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/diskcrypt"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/docker/machine/libmachine/drivers/rpc"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/hooks"
 	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/k3s"
+	"github.com/docker/machine/libmachine/kubernetes"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
-	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/persist"
 	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/retry"
 	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/sshuser"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
 	"github.com/docker/machine/libmachine/version"
@@ -30,8 +39,14 @@ type API interface {
 	io.Closer
 	NewHost(driverName string, rawDriver []byte) (*host.Host, error)
 	Create(h *host.Host) error
+
+	// CreateContext is Create, stopping when ctx is canceled and removing
+	// what was already created of the machine.
+	CreateContext(ctx context.Context, h *host.Host) error
+
 	persist.Store
 	GetMachinesDir() string
+	Watch(name string, stop <-chan struct{}) (<-chan StateChange, error)
 }
 
 type Client struct {
@@ -40,17 +55,46 @@ type Client struct {
 	SSHClientType  ssh.ClientType
 	GithubAPIToken string
 	*persist.Filestore
+	// Store persists the machines, it defaults to the Filestore
+	Store               persist.Store
 	clientDriverFactory rpcdriver.RPCClientDriverFactory
+	sshClientCreator    host.SSHClientCreator
+	provisioners        provision.Registry
 }
 
-func NewClient(storePath, certsDir string) *Client {
-	return &Client{
+// NewClient returns a client managing the machines of the store path, whose
+// certificates are in certsDir, configured by the options.
+func NewClient(storePath, certsDir string, opts ...Option) *Client {
+	api := &Client{
 		certsDir:            certsDir,
 		IsDebug:             false,
 		SSHClientType:       ssh.External,
 		Filestore:           persist.NewFilestore(storePath, certsDir, certsDir),
 		clientDriverFactory: rpcdriver.NewRPCClientDriverFactory(),
 	}
+
+	for _, opt := range opts {
+		opt(api)
+	}
+
+	return api
+}
+
+// Session returns a client sharing the configuration and the store of api,
+// with its own driver plugins closed by its Close, e.g. for each request of
+// a long-running server.
+func (api *Client) Session() *Client {
+	return &Client{
+		certsDir:            api.certsDir,
+		IsDebug:             api.IsDebug,
+		SSHClientType:       api.SSHClientType,
+		GithubAPIToken:      api.GithubAPIToken,
+		Filestore:           api.Filestore,
+		Store:               api.Store,
+		clientDriverFactory: rpcdriver.NewRPCClientDriverFactory(),
+		sshClientCreator:    api.sshClientCreator,
+		provisioners:        api.provisioners,
+	}
 }
 
 func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, error) {
@@ -60,10 +104,12 @@ func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, err
 	}
 
 	return &host.Host{
-		ConfigVersion: version.ConfigVersion,
-		Name:          driver.GetMachineName(),
-		Driver:        driver,
-		DriverName:    driver.DriverName(),
+		ConfigVersion:    version.ConfigVersion,
+		Name:             driver.GetMachineName(),
+		Driver:           driver,
+		DriverName:       driver.DriverName(),
+		SSHClientCreator: api.sshClientCreator,
+		Provisioners:     api.provisioners,
 		HostOptions: &host.Options{
 			AuthOptions: &auth.Options{
 				CertDir:          api.certsDir,
@@ -88,12 +134,66 @@ func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, err
 	}, nil
 }
 
+func (api *Client) getStore() persist.Store {
+	if api.Store != nil {
+		return api.Store
+	}
+	return api.Filestore
+}
+
+func (api *Client) Exists(name string) (bool, error) {
+	return api.getStore().Exists(name)
+}
+
+func (api *Client) List() ([]string, error) {
+	return api.getStore().List()
+}
+
+func (api *Client) Remove(name string) error {
+	return api.getStore().Remove(name)
+}
+
+func (api *Client) Save(h *host.Host) error {
+	return api.getStore().Save(h)
+}
+
+// LoadGroups returns the groups of machines of the store.
+func (api *Client) LoadGroups() (persist.Groups, error) {
+	store, ok := api.getStore().(persist.GroupStore)
+	if !ok {
+		return nil, persist.ErrGroupsNotSupported
+	}
+	return store.LoadGroups()
+}
+
+// SaveGroups replaces the groups of machines of the store.
+func (api *Client) SaveGroups(groups persist.Groups) error {
+	store, ok := api.getStore().(persist.GroupStore)
+	if !ok {
+		return persist.ErrGroupsNotSupported
+	}
+	return store.SaveGroups(groups)
+}
+
+// StoreID returns the identity of the store, which the drivers tag the
+// resources they create with.
+func (api *Client) StoreID() (string, error) {
+	store, ok := api.getStore().(persist.IdentifiedStore)
+	if !ok {
+		return "", persist.ErrStoreIDNotSupported
+	}
+	return store.StoreID()
+}
+
 func (api *Client) Load(name string) (*host.Host, error) {
-	h, err := api.Filestore.Load(name)
+	h, err := api.getStore().Load(name)
 	if err != nil {
 		return nil, err
 	}
 
+	h.SSHClientCreator = api.sshClientCreator
+	h.Provisioners = api.provisioners
+
 	d, err := api.clientDriverFactory.NewRPCClientDriver(h.DriverName, h.RawDriver)
 	if err != nil {
 		// Not being able to find a driver binary is a "known error"
@@ -116,11 +216,23 @@ func (api *Client) Load(name string) (*host.Host, error) {
 // Create is the wrapper method which covers all of the boilerplate around
 // actually creating, provisioning, and persisting an instance in the store.
 func (api *Client) Create(h *host.Host) error {
+	return api.CreateContext(context.Background(), h)
+}
+
+// CreateContext creates the machine like Create.  When ctx is canceled, the
+// creation by the driver is canceled if the driver supports it, the
+// provisioning stops at its next step, and the machine is removed, so that
+// nothing is left behind in the cloud nor in the store.
+func (api *Client) CreateContext(ctx context.Context, h *host.Host) error {
 	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
 		return fmt.Errorf("Error generating certificates: %s", err)
 	}
 
-	log.Info("Running pre-create checks...")
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	h.Logger("pre-create").Info("Running pre-create checks...")
 
 	if err := h.Driver.PreCreateCheck(); err != nil {
 		return mcnerror.ErrDuringPreCreate{
@@ -128,23 +240,109 @@ func (api *Client) Create(h *host.Host) error {
 		}
 	}
 
+	if err := hooks.Fire(hooks.Event{Type: hooks.PreCreate, Machine: h.Name, Driver: h.DriverName}); err != nil {
+		return fmt.Errorf("Error running the pre-create hooks: %s", err)
+	}
+
 	if err := api.Save(h); err != nil {
 		return fmt.Errorf("Error saving host to store before attempting creation: %s", err)
 	}
 
-	log.Info("Creating machine...")
+	h.Logger("create").Info("Creating machine...")
 
-	if err := api.performCreate(h); err != nil {
+	if err := api.performCreate(ctx, h); err != nil {
+		if ctx.Err() != nil {
+			api.removeCanceled(h, err != errDriverCanceled)
+			return ctx.Err()
+		}
 		return fmt.Errorf("Error creating machine: %s", err)
 	}
 
-	log.Debug("Reticulating splines...")
+	hooks.Notify(hooks.Event{Type: hooks.PostProvision, Machine: h.Name, Driver: h.DriverName, State: state.Running.String()})
+
+	h.Logger("create").Debug("Reticulating splines...")
 
 	return nil
 }
 
-func (api *Client) performCreate(h *host.Host) error {
-	if err := h.Driver.Create(); err != nil {
+// The log entries reporting the progress of the driver during the creation of
+// a machine carry its phase and completion in these fields, for the
+// applications following it with a log sink.
+const (
+	ProgressField = "progress"
+	PercentField  = "percent"
+)
+
+func logProgress(h *host.Host, progress drivers.Progress) {
+	logger := h.Logger("create").WithFields(log.Fields{
+		ProgressField: progress.Phase,
+		PercentField:  progress.Percent,
+	})
+
+	if progress.Percent < 0 {
+		logger.Infof("%s...", progress.Phase)
+		return
+	}
+
+	logger.Infof("%s (%d%%)", progress.Phase, progress.Percent)
+}
+
+// errDriverCanceled is the creation canceled by the driver, which removed
+// what it had already created.
+var errDriverCanceled = errors.New("The creation was canceled by the driver")
+
+// removeCanceled removes a machine whose creation was canceled from the
+// store, and with its driver unless the driver already did.
+func (api *Client) removeCanceled(h *host.Host, withDriver bool) {
+	h.Logger("create").Info("Removing the machine whose creation was canceled...")
+
+	if withDriver {
+		if err := h.Driver.Remove(); err != nil {
+			h.Logger("create").Warnf("Unable to remove the machine, remove it with rm -f: %s", err)
+			return
+		}
+	}
+
+	if err := api.Remove(h.Name); err != nil {
+		h.Logger("create").Warnf("Unable to remove the machine from the store: %s", err)
+	}
+}
+
+// createWithDriver runs the creation by the driver, canceling it when ctx is
+// canceled, and returns whether the driver canceled it.  The drivers which
+// can't cancel their creation are let finish.
+func createWithDriver(ctx context.Context, h *host.Host) (bool, error) {
+	done := make(chan struct{})
+	canceled := make(chan bool, 1)
+
+	go func() {
+		select {
+		case <-done:
+			canceled <- false
+		case <-ctx.Done():
+			err := drivers.CancelCreate(h.Driver)
+			if err != nil {
+				h.Logger("create").Infof("Waiting for the driver to finish creating the machine to remove it: %s", err)
+			}
+			canceled <- err == nil
+		}
+	}()
+
+	err := h.Driver.Create()
+	close(done)
+
+	return <-canceled, err
+}
+
+func (api *Client) performCreate(ctx context.Context, h *host.Host) error {
+	drivers.NotifyProgress(h.Driver, func(progress drivers.Progress) {
+		logProgress(h, progress)
+	})
+
+	if canceled, err := createWithDriver(ctx, h); err != nil {
+		if canceled {
+			return errDriverCanceled
+		}
 		return fmt.Errorf("Error in driver during machine creation: %s", err)
 	}
 
@@ -157,32 +355,123 @@ func (api *Client) performCreate(h *host.Host) error {
 		return nil
 	}
 
-	log.Info("Waiting for machine to be running, this may take a few minutes...")
-	if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	h.Logger("create").Info("Waiting for machine to be running, this may take a few minutes...")
+	running := drivers.MachineInState(h.Driver, state.Running)
+	if err := retry.For(retry.Instance).UntilContext(ctx, func() (bool, error) { return running(), nil }); err != nil {
 		return fmt.Errorf("Error waiting for machine to be running: %s", err)
 	}
 
-	log.Info("Detecting operating system of created instance...")
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	h.Logger("provision").Info("Detecting operating system of created instance...")
+	provisioner, err := h.DetectProvisioner()
 	if err != nil {
 		return fmt.Errorf("Error detecting OS: %s", err)
 	}
 
-	log.Infof("Provisioning with %s...", provisioner.String())
+	if h.HostOptions.EngineOptions.GPU != "" && !provision.SupportsGPU(provisioner) {
+		return fmt.Errorf("Error running provisioning: %s", provision.ErrGPUNotSupported)
+	}
+
+	if h.HostOptions.EngineOptions.InstallBundle != "" && !provision.SupportsInstallBundle(provisioner) {
+		return fmt.Errorf("Error running provisioning: %s", provision.ErrInstallBundleNotSupported)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	h.Logger("provision").Infof("Provisioning with %s...", provisioner.String())
 	if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
 		return fmt.Errorf("Error running provisioning: %s", err)
 	}
 
+	if len(h.HostOptions.EngineOptions.ProxyEnv()) > 0 {
+		h.Logger("provision").Info("Configuring the proxy of the engine...")
+		if err := provision.ConfigureEngineProxy(provisioner, *h.HostOptions.EngineOptions); err != nil {
+			return fmt.Errorf("Error configuring the proxy of the engine: %s", err)
+		}
+	}
+
+	if h.HostOptions.SSHUserOptions.IsEnabled() {
+		h.Logger("provision").Info("Configuring the SSH access...")
+		if err := sshuser.Configure(provisioner, h.HostOptions.SSHUserOptions); err != nil {
+			return fmt.Errorf("Error configuring the SSH access: %s", err)
+		}
+
+		// The machine may only be reachable as the new user from now on
+		if err := api.Save(h); err != nil {
+			return fmt.Errorf("Error saving host to store after configuring the SSH access: %s", err)
+		}
+	}
+
+	if cert.SSHCertificatesEnabled() {
+		h.Logger("provision").Info("Installing the SSH certificate authority...")
+		if err := h.ConfigureSSHCertificates(provisioner); err != nil {
+			return fmt.Errorf("Error configuring the SSH certificates: %s", err)
+		}
+	}
+
+	if h.HostOptions.DataDiskOptions.IsEncrypted() {
+		if err := diskcrypt.Configure(provisioner, h.HostOptions.DataDiskOptions, *h.HostOptions.EngineOptions, h.HostOptions.AuthOptions.StorePath); err != nil {
+			return fmt.Errorf("Error encrypting the data disk: %s", err)
+		}
+	}
+
+	if h.HostOptions.FirewallOptions.IsEnabled() {
+		h.Logger("provision").Info("Configuring the firewall...")
+		if err := h.ConfigureFirewall(provisioner); err != nil {
+			return fmt.Errorf("Error configuring the firewall: %s", err)
+		}
+	}
+
+	if h.HostOptions.AutoUpdatesOptions.IsEnabled() {
+		if err := autoupdates.Configure(provisioner, h.HostOptions.AutoUpdatesOptions); err != nil {
+			return fmt.Errorf("Error configuring the automatic updates: %s", err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// We should check the connection to docker here
-	log.Info("Checking connection to Docker...")
+	h.Logger("provision").Info("Checking connection to Docker...")
 	if _, _, err = check.DefaultConnChecker.Check(h, false); err != nil {
 		return fmt.Errorf("Error checking the host: %s", err)
 	}
 
-	log.Info("Docker is up and running!")
+	h.Logger("provision").Info("Docker is up and running!")
+
+	if h.HostOptions.K3sOptions.IsK3s() {
+		if err := k3s.Configure(provisioner, h.HostOptions.K3sOptions, h.HostOptions.AuthOptions.StorePath); err != nil {
+			return fmt.Errorf("Error configuring k3s: %s", err)
+		}
+	}
+
+	if h.HostOptions.KubernetesOptions.IsKubernetes() {
+		if err := kubernetes.Configure(provisioner, h.HostOptions.KubernetesOptions, h.HostOptions.AuthOptions.StorePath); err != nil {
+			return fmt.Errorf("Error configuring Kubernetes: %s", err)
+		}
+	}
+
+	if err := h.ConfigureSwarmMode(); err != nil {
+		return fmt.Errorf("Error configuring swarm mode: %s", err)
+	}
+
+	now := time.Now()
+	h.LastProvisioned = &now
+
+	if err := api.Save(h); err != nil {
+		return fmt.Errorf("Error saving host to store after provisioning: %s", err)
+	}
+
 	return nil
 }
 
 func (api *Client) Close() error {
+	ssh.CloseConnections()
 	return api.clientDriverFactory.Close()
 }
@@ -0,0 +1,87 @@
+package libmachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/persist/persisttest"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/stretchr/testify/assert"
+)
+
+// cancelableDriver blocks in Create until its creation is canceled.
+type cancelableDriver struct {
+	*fakedriver.Driver
+	canceled chan struct{}
+}
+
+func (d *cancelableDriver) Create() error {
+	<-d.canceled
+	return context.Canceled
+}
+
+func (d *cancelableDriver) CancelCreate() error {
+	close(d.canceled)
+	return nil
+}
+
+func TestCreateWithDriverCanceled(t *testing.T) {
+	d := &cancelableDriver{Driver: &fakedriver.Driver{}, canceled: make(chan struct{})}
+	h := &host.Host{Name: "dev", Driver: d}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	canceled, err := createWithDriver(ctx, h)
+
+	assert.True(t, canceled)
+	assert.Equal(t, context.Canceled, err)
+}
+
+type failingDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *failingDriver) Create() error {
+	return errors.New("quota exceeded")
+}
+
+func TestCreateWithDriverNotCanceled(t *testing.T) {
+	h := &host.Host{Name: "dev", Driver: &failingDriver{Driver: &fakedriver.Driver{}}}
+
+	canceled, err := createWithDriver(context.Background(), h)
+
+	assert.False(t, canceled)
+	assert.EqualError(t, err, "quota exceeded")
+}
+
+type fakeSSHClientCreator struct{}
+
+func (c *fakeSSHClientCreator) CreateSSHClient(d drivers.Driver) (ssh.Client, error) {
+	return &ssh.ExternalClient{}, nil
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	store := &persisttest.FakeStore{}
+	creator := &fakeSSHClientCreator{}
+	registry := provision.Registry{}
+
+	api := NewClient("/tmp/machine", "/tmp/machine/certs",
+		WithStore(store),
+		WithSSHClientCreator(creator),
+		WithProvisioners(registry),
+		WithGithubAPIToken("TOKEN"))
+
+	assert.Equal(t, store, api.getStore())
+	assert.Equal(t, "TOKEN", api.GithubAPIToken)
+
+	session := api.Session()
+	assert.Equal(t, store, session.getStore())
+	assert.Equal(t, creator, session.sshClientCreator)
+	assert.Equal(t, registry, session.provisioners)
+}
@@ -1,15 +1,20 @@
 package libmachinetest
 
 import (
+	"context"
+	"time"
+
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/persist"
 	"github.com/docker/machine/libmachine/state"
 )
 
 type FakeAPI struct {
-	Hosts []*host.Host
+	Hosts  []*host.Host
+	Groups persist.Groups
 }
 
 func (api *FakeAPI) NewPluginDriver(string, []byte) (drivers.Driver, error) {
@@ -28,6 +33,10 @@ func (api *FakeAPI) Create(h *host.Host) error {
 	return nil
 }
 
+func (api *FakeAPI) CreateContext(ctx context.Context, h *host.Host) error {
+	return ctx.Err()
+}
+
 func (api *FakeAPI) Exists(name string) (bool, error) {
 	for _, host := range api.Hosts {
 		if name == host.Name {
@@ -39,7 +48,11 @@ func (api *FakeAPI) Exists(name string) (bool, error) {
 }
 
 func (api *FakeAPI) List() ([]string, error) {
-	return []string{}, nil
+	names := []string{}
+	for _, host := range api.Hosts {
+		names = append(names, host.Name)
+	}
+	return names, nil
 }
 
 func (api *FakeAPI) Load(name string) (*host.Host, error) {
@@ -72,10 +85,32 @@ func (api *FakeAPI) Save(host *host.Host) error {
 	return nil
 }
 
+func (api *FakeAPI) LoadGroups() (persist.Groups, error) {
+	groups := persist.Groups{}
+	for name, members := range api.Groups {
+		groups[name] = append([]string{}, members...)
+	}
+	return groups, nil
+}
+
+func (api *FakeAPI) SaveGroups(groups persist.Groups) error {
+	api.Groups = groups
+	return nil
+}
+
 func (api FakeAPI) GetMachinesDir() string {
 	return ""
 }
 
+func (api *FakeAPI) Watch(name string, stop <-chan struct{}) (<-chan libmachine.StateChange, error) {
+	h, err := api.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return libmachine.WatchHost(h, time.Millisecond, time.Millisecond, stop), nil
+}
+
 func State(api libmachine.API, name string) state.State {
 	host, _ := api.Load(name)
 	machineState, _ := host.Driver.GetState()
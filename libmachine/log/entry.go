@@ -0,0 +1,96 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warning"
+	case ErrorLevel:
+		return "error"
+	}
+
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Fields give the context of a log message, e.g. the machine, its driver and
+// the phase of the operation it comes from.
+type Fields map[string]interface{}
+
+// merge returns the fields overridden by others.
+func (f Fields) merge(others Fields) Fields {
+	merged := Fields{}
+	for key, value := range f {
+		merged[key] = value
+	}
+	for key, value := range others {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Entry is a log message with its level and fields.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  Fields
+}
+
+// MarshalJSON renders the entry as a flat object, where the fields come along
+// the time, level and msg keys.
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	object := map[string]interface{}{}
+	for key, value := range e.Fields {
+		switch v := value.(type) {
+		case error:
+			object[key] = v.Error()
+		case fmt.Stringer:
+			object[key] = v.String()
+		default:
+			object[key] = v
+		}
+	}
+
+	object["time"] = e.Time.Format(time.RFC3339)
+	object["level"] = e.Level.String()
+	object["msg"] = e.Message
+
+	return json.Marshal(object)
+}
+
+// Sink receives the log entries instead of the writers of the logger, so that
+// an application embedding libmachine forwards them to its own logger.  It
+// receives the debug entries even when debug is disabled.
+type Sink interface {
+	Write(entry *Entry)
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(entry *Entry)
+
+func (f SinkFunc) Write(entry *Entry) {
+	f(entry)
+}
@@ -1,15 +1,20 @@
 package log
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 )
 
 type FmtMachineLogger struct {
 	outWriter io.Writer
 	errWriter io.Writer
 	debug     bool
+	format    string
+	sink      Sink
 	history   *HistoryRecorder
 }
 
@@ -19,6 +24,7 @@ func NewFmtMachineLogger() MachineLogger {
 		outWriter: os.Stdout,
 		errWriter: os.Stderr,
 		debug:     false,
+		format:    FormatText,
 		history:   NewHistoryRecorder(),
 	}
 }
@@ -35,50 +41,155 @@ func (ml *FmtMachineLogger) SetErrWriter(err io.Writer) {
 	ml.errWriter = err
 }
 
-func (ml *FmtMachineLogger) Debug(args ...interface{}) {
-	ml.history.Record(args...)
-	if ml.debug {
-		fmt.Fprintln(ml.errWriter, args...)
+// SetFormat selects how the entries are written: the plain messages of the
+// text format, or a JSON object with the level and fields on each line.
+func (ml *FmtMachineLogger) SetFormat(format string) error {
+	switch format {
+	case "", FormatText:
+		ml.format = FormatText
+	case FormatJSON:
+		ml.format = FormatJSON
+	default:
+		return fmt.Errorf("Error: Unknown log format %q, expected one of: [%s, %s]", format, FormatText, FormatJSON)
 	}
+
+	return nil
 }
 
-func (ml *FmtMachineLogger) Debugf(fmtString string, args ...interface{}) {
-	ml.history.Recordf(fmtString, args...)
-	if ml.debug {
-		fmt.Fprintf(ml.errWriter, fmtString+"\n", args...)
+// SetSink sends the entries to a sink instead of the writers, or to the
+// writers again when the sink is nil.
+func (ml *FmtMachineLogger) SetSink(sink Sink) {
+	ml.sink = sink
+}
+
+func (ml *FmtMachineLogger) write(level Level, fields Fields, message string) {
+	ml.history.Record(message)
+
+	entry := &Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: message,
+		Fields:  fields,
 	}
+
+	if ml.sink != nil {
+		ml.sink.Write(entry)
+		return
+	}
+
+	if level == DebugLevel && !ml.debug {
+		return
+	}
+
+	writer := ml.outWriter
+	if level == DebugLevel || level == ErrorLevel {
+		writer = ml.errWriter
+	}
+
+	if ml.format != FormatJSON {
+		fmt.Fprintln(writer, message)
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintln(writer, message)
+		return
+	}
+
+	fmt.Fprintln(writer, string(line))
+}
+
+func (ml *FmtMachineLogger) WithFields(fields Fields) FieldLogger {
+	return &fieldLogger{
+		logger: ml,
+		fields: Fields{}.merge(fields),
+	}
+}
+
+func (ml *FmtMachineLogger) Debug(args ...interface{}) {
+	ml.write(DebugLevel, nil, sprintln(args...))
+}
+
+func (ml *FmtMachineLogger) Debugf(fmtString string, args ...interface{}) {
+	ml.write(DebugLevel, nil, fmt.Sprintf(fmtString, args...))
 }
 
 func (ml *FmtMachineLogger) Error(args ...interface{}) {
-	ml.history.Record(args...)
-	fmt.Fprintln(ml.errWriter, args...)
+	ml.write(ErrorLevel, nil, sprintln(args...))
 }
 
 func (ml *FmtMachineLogger) Errorf(fmtString string, args ...interface{}) {
-	ml.history.Recordf(fmtString, args...)
-	fmt.Fprintf(ml.errWriter, fmtString+"\n", args...)
+	ml.write(ErrorLevel, nil, fmt.Sprintf(fmtString, args...))
 }
 
 func (ml *FmtMachineLogger) Info(args ...interface{}) {
-	ml.history.Record(args...)
-	fmt.Fprintln(ml.outWriter, args...)
+	ml.write(InfoLevel, nil, sprintln(args...))
 }
 
 func (ml *FmtMachineLogger) Infof(fmtString string, args ...interface{}) {
-	ml.history.Recordf(fmtString, args...)
-	fmt.Fprintf(ml.outWriter, fmtString+"\n", args...)
+	ml.write(InfoLevel, nil, fmt.Sprintf(fmtString, args...))
 }
 
 func (ml *FmtMachineLogger) Warn(args ...interface{}) {
-	ml.history.Record(args...)
-	fmt.Fprintln(ml.outWriter, args...)
+	ml.write(WarnLevel, nil, sprintln(args...))
 }
 
 func (ml *FmtMachineLogger) Warnf(fmtString string, args ...interface{}) {
-	ml.history.Recordf(fmtString, args...)
-	fmt.Fprintf(ml.outWriter, fmtString+"\n", args...)
+	ml.write(WarnLevel, nil, fmt.Sprintf(fmtString, args...))
 }
 
 func (ml *FmtMachineLogger) History() []string {
 	return ml.history.records
 }
+
+// fieldLogger logs the messages with its fields through the logger.
+type fieldLogger struct {
+	logger *FmtMachineLogger
+	fields Fields
+}
+
+func (fl *fieldLogger) WithFields(fields Fields) FieldLogger {
+	return &fieldLogger{
+		logger: fl.logger,
+		fields: fl.fields.merge(fields),
+	}
+}
+
+func (fl *fieldLogger) Debug(args ...interface{}) {
+	fl.logger.write(DebugLevel, fl.fields, sprintln(args...))
+}
+
+func (fl *fieldLogger) Debugf(fmtString string, args ...interface{}) {
+	fl.logger.write(DebugLevel, fl.fields, fmt.Sprintf(fmtString, args...))
+}
+
+func (fl *fieldLogger) Error(args ...interface{}) {
+	fl.logger.write(ErrorLevel, fl.fields, sprintln(args...))
+}
+
+func (fl *fieldLogger) Errorf(fmtString string, args ...interface{}) {
+	fl.logger.write(ErrorLevel, fl.fields, fmt.Sprintf(fmtString, args...))
+}
+
+func (fl *fieldLogger) Info(args ...interface{}) {
+	fl.logger.write(InfoLevel, fl.fields, sprintln(args...))
+}
+
+func (fl *fieldLogger) Infof(fmtString string, args ...interface{}) {
+	fl.logger.write(InfoLevel, fl.fields, fmt.Sprintf(fmtString, args...))
+}
+
+func (fl *fieldLogger) Warn(args ...interface{}) {
+	fl.logger.write(WarnLevel, fl.fields, sprintln(args...))
+}
+
+func (fl *fieldLogger) Warnf(fmtString string, args ...interface{}) {
+	fl.logger.write(WarnLevel, fl.fields, fmt.Sprintf(fmtString, args...))
+}
+
+// sprintln formats the arguments like fmt.Println, which puts spaces between
+// all of them, without the trailing newline.
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
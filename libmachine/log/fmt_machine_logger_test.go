@@ -2,6 +2,7 @@ package log
 
 import (
 	"bufio"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"testing"
@@ -95,3 +96,43 @@ func TestEntriesAreCollected(t *testing.T) {
 	assert.Equal(t, "info", testLogger.History()[1])
 	assert.Equal(t, "error", testLogger.History()[2])
 }
+
+func TestJSONFormat(t *testing.T) {
+	testLogger := NewFmtMachineLogger()
+	assert.NoError(t, testLogger.SetFormat(FormatJSON))
+
+	result := captureOutput(testLogger, func() {
+		testLogger.WithFields(Fields{"machine": "dev", "phase": "start"}).Infof("Starting %q...", "dev")
+	})
+
+	entry := map[string]string{}
+	assert.NoError(t, json.Unmarshal([]byte(result), &entry))
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, `Starting "dev"...`, entry["msg"])
+	assert.Equal(t, "dev", entry["machine"])
+	assert.Equal(t, "start", entry["phase"])
+	assert.NotEmpty(t, entry["time"])
+}
+
+func TestUnknownFormat(t *testing.T) {
+	testLogger := NewFmtMachineLogger()
+
+	assert.EqualError(t, testLogger.SetFormat("xml"), `Error: Unknown log format "xml", expected one of: [text, json]`)
+}
+
+func TestSink(t *testing.T) {
+	testLogger := NewFmtMachineLogger()
+	testLogger.SetOutWriter(ioutil.Discard)
+
+	entries := []*Entry{}
+	testLogger.SetSink(SinkFunc(func(entry *Entry) { entries = append(entries, entry) }))
+
+	testLogger.Debug("debug")
+	testLogger.WithFields(Fields{"machine": "dev"}).WithFields(Fields{"driver": "virtualbox"}).Warn("warn")
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, DebugLevel, entries[0].Level)
+	assert.Equal(t, WarnLevel, entries[1].Level)
+	assert.Equal(t, "warn", entries[1].Message)
+	assert.Equal(t, Fields{"machine": "dev", "driver": "virtualbox"}, entries[1].Fields)
+}
@@ -69,6 +69,25 @@ func SetErrWriter(err io.Writer) {
 	logger.SetErrWriter(err)
 }
 
+// SetFormat selects the format of the log entries, text or json.
+func SetFormat(format string) error {
+	return logger.SetFormat(format)
+}
+
+// SetSink installs the sink receiving the log entries instead of the writers,
+// for the applications embedding libmachine.  A nil sink restores the
+// writers.
+func SetSink(sink Sink) {
+	logger.SetSink(sink)
+}
+
+// WithFields returns a logger adding the fields to its entries, e.g.
+//
+//	log.WithFields(log.Fields{"machine": h.Name, "phase": "create"}).Info("Creating machine...")
+func WithFields(fields Fields) FieldLogger {
+	return logger.WithFields(fields)
+}
+
 func History() []string {
 	return stripSecrets(logger.History())
 }
@@ -2,12 +2,8 @@ package log
 
 import "io"
 
-type MachineLogger interface {
-	SetDebug(debug bool)
-
-	SetOutWriter(io.Writer)
-	SetErrWriter(io.Writer)
-
+// FieldLogger logs messages with the fields it was given.
+type FieldLogger interface {
 	Debug(args ...interface{})
 	Debugf(fmtString string, args ...interface{})
 
@@ -20,5 +16,19 @@ type MachineLogger interface {
 	Warn(args ...interface{})
 	Warnf(fmtString string, args ...interface{})
 
+	WithFields(fields Fields) FieldLogger
+}
+
+type MachineLogger interface {
+	FieldLogger
+
+	SetDebug(debug bool)
+
+	SetOutWriter(io.Writer)
+	SetErrWriter(io.Writer)
+
+	SetFormat(format string) error
+	SetSink(sink Sink)
+
 	History() []string
 }
@@ -22,6 +22,22 @@ func DockerClient(dockerHost DockerHost) (*dockerclient.DockerClient, error) {
 	return dockerclient.NewDockerClient(url, tlsConfig)
 }
 
+// RunningContainers returns the number of containers running on a docker
+// host.
+func RunningContainers(dockerHost DockerHost) (int, error) {
+	docker, err := DockerClient(dockerHost)
+	if err != nil {
+		return 0, err
+	}
+
+	containers, err := docker.ListContainers(false, false, "")
+	if err != nil {
+		return 0, fmt.Errorf("Unable to list the containers: %s", err)
+	}
+
+	return len(containers), nil
+}
+
 // CreateContainer creates a docker container.
 func CreateContainer(dockerHost DockerHost, config *dockerclient.ContainerConfig, name string) error {
 	docker, err := DockerClient(dockerHost)
@@ -0,0 +1,100 @@
+package mcndockerclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/machine/libmachine/cert"
+)
+
+// statsAPIVersion is the first version of the API serving /system/df.
+const statsAPIVersion = "v1.25"
+
+var CurrentEngineStatser EngineStatser = &defaultEngineStatser{}
+
+// EngineStats is the resource usage of an engine, from its docker info and
+// docker system df.
+type EngineStats struct {
+	Containers        int
+	ContainersRunning int
+	Images            int
+	ImagesSize        int64
+	MemTotal          int64
+	ServerVersion     string
+}
+
+type EngineStatser interface {
+	EngineStats(host DockerHost) (*EngineStats, error)
+}
+
+func GetEngineStats(host DockerHost) (*EngineStats, error) {
+	return CurrentEngineStatser.EngineStats(host)
+}
+
+type defaultEngineStatser struct{}
+
+func (es *defaultEngineStatser) EngineStats(host DockerHost) (*EngineStats, error) {
+	url, err := host.URL()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := cert.ReadTLSConfig(url, host.AuthOptions())
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read TLS config: %s", err)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	baseURL := strings.Replace(url, "tcp://", "https://", 1) + "/" + statsAPIVersion
+
+	var info struct {
+		Containers        int
+		ContainersRunning int
+		Images            int
+		MemTotal          int64
+		ServerVersion     string
+	}
+	if err := getJSON(client, baseURL+"/info", &info); err != nil {
+		return nil, fmt.Errorf("Unable to query docker info: %s", err)
+	}
+
+	var df struct {
+		LayersSize int64
+	}
+	if err := getJSON(client, baseURL+"/system/df", &df); err != nil {
+		return nil, fmt.Errorf("Unable to query docker system df: %s", err)
+	}
+
+	return &EngineStats{
+		Containers:        info.Containers,
+		ContainersRunning: info.ContainersRunning,
+		Images:            info.Images,
+		ImagesSize:        df.LayersSize,
+		MemTotal:          info.MemTotal,
+		ServerVersion:     info.ServerVersion,
+	}, nil
+}
+
+func getJSON(client *http.Client, url string, out interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return json.Unmarshal(data, out)
+}
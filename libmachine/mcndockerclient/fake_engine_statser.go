@@ -0,0 +1,14 @@
+package mcndockerclient
+
+type FakeEngineStatser struct {
+	Stats *EngineStats
+	Err   error
+}
+
+func (es *FakeEngineStatser) EngineStats(host DockerHost) (*EngineStats, error) {
+	if es.Err != nil {
+		return nil, es.Err
+	}
+
+	return es.Stats, nil
+}
@@ -0,0 +1,29 @@
+package mcnutils
+
+import "strings"
+
+// The architectures of the machines, named like GOARCH, which is how the
+// engine and its static binaries name them too.
+const (
+	ArchAMD64 = "amd64"
+	ArchARM64 = "arm64"
+	ArchARM   = "arm"
+)
+
+// NormalizeArchitecture returns the GOARCH name of an architecture as
+// printed by `uname -m` or reported by a provider, e.g. aarch64 or x86_64.
+// The architectures it doesn't know are returned lowercased.
+func NormalizeArchitecture(arch string) string {
+	arch = strings.ToLower(strings.TrimSpace(arch))
+
+	switch arch {
+	case "x86_64", "x86-64", "x64", "amd64":
+		return ArchAMD64
+	case "aarch64", "arm64", "armv8", "armv8l":
+		return ArchARM64
+	case "armv7l", "armv7", "armv6l", "armhf", "arm":
+		return ArchARM
+	}
+
+	return arch
+}
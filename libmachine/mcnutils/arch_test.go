@@ -0,0 +1,22 @@
+package mcnutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeArchitecture(t *testing.T) {
+	testCases := map[string]string{
+		"x86_64\n": ArchAMD64,
+		"amd64":    ArchAMD64,
+		"aarch64":  ArchARM64,
+		"arm64":    ArchARM64,
+		"armv7l":   ArchARM,
+		"S390X":    "s390x",
+	}
+
+	for arch, expected := range testCases {
+		assert.Equal(t, expected, NormalizeArchitecture(arch), arch)
+	}
+}
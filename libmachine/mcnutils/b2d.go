@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 
 	"github.com/docker/machine/libmachine/log"
@@ -87,6 +88,7 @@ type releaseGetter interface {
 // b2dReleaseGetter implements the releaseGetter interface for getting the release of Boot2Docker.
 type b2dReleaseGetter struct {
 	isoFilename string
+	arch        string
 }
 
 func (b *b2dReleaseGetter) filename() string {
@@ -156,6 +158,9 @@ func (*b2dReleaseGetter) getReleaseTag(apiURL string) (string, error) {
 // getReleaseURL gets the latest release URL of Boot2Docker.
 func (b *b2dReleaseGetter) getReleaseURL(apiURL string) (string, error) {
 	if apiURL == "" {
+		if b.arch != "" && b.arch != ArchAMD64 {
+			return "", fmt.Errorf("Boot2Docker has no release for %s, specify the URL of an ISO built for it", b.arch)
+		}
 		apiURL = defaultURL
 	}
 
@@ -337,13 +342,24 @@ type B2dUtils struct {
 	imgCachePath string
 }
 
+// NewB2dUtils returns the utils of the Boot2Docker ISO of the architecture
+// Machine runs on.
 func NewB2dUtils(storePath string) *B2dUtils {
+	return NewB2dUtilsForArch(storePath, runtime.GOARCH)
+}
+
+// NewB2dUtilsForArch returns the utils of the Boot2Docker ISO of an
+// architecture.  The ISOs of the architectures other than amd64 are
+// released and cached as boot2docker-<arch>.iso.
+func NewB2dUtilsForArch(storePath, arch string) *B2dUtils {
 	imgCachePath := filepath.Join(storePath, "cache")
+	arch = NormalizeArchitecture(arch)
+	isoFilename := b2dISOFilename(arch)
 
 	return &B2dUtils{
-		releaseGetter: &b2dReleaseGetter{isoFilename: defaultISOFilename},
+		releaseGetter: &b2dReleaseGetter{isoFilename: isoFilename, arch: arch},
 		iso: &b2dISO{
-			commonIsoPath:  filepath.Join(imgCachePath, defaultISOFilename),
+			commonIsoPath:  filepath.Join(imgCachePath, isoFilename),
 			volumeIDOffset: defaultVolumeIDOffset,
 			volumeIDLength: defaultVolumeIDLength,
 		},
@@ -352,6 +368,13 @@ func NewB2dUtils(storePath string) *B2dUtils {
 	}
 }
 
+func b2dISOFilename(arch string) string {
+	if arch == "" || arch == ArchAMD64 {
+		return defaultISOFilename
+	}
+	return fmt.Sprintf("boot2docker-%s.iso", arch)
+}
+
 // DownloadISO downloads boot2docker ISO image for the given tag and save it at dest.
 func (b *B2dUtils) DownloadISO(dir, file, isoURL string) error {
 	log.Infof("Downloading %s from %s...", b.path(), isoURL)
@@ -445,7 +468,8 @@ func (b *B2dUtils) CopyIsoToMachineDir(isoURL, machineName string) error {
 
 	// TODO: This is a bit off-color.
 	machineDir := filepath.Join(b.storePath, "machines", machineName)
-	machineIsoPath := filepath.Join(machineDir, b.filename())
+	// The drivers boot the machines from boot2docker.iso whatever the architecture.
+	machineIsoPath := filepath.Join(machineDir, defaultISOFilename)
 
 	// By default just copy the existing "cached" iso to the machine's directory...
 	if isoURL == "" {
@@ -459,7 +483,7 @@ func (b *B2dUtils) CopyIsoToMachineDir(isoURL, machineName string) error {
 		return err
 	}
 
-	return b.DownloadISO(machineDir, b.filename(), downloadURL)
+	return b.DownloadISO(machineDir, defaultISOFilename, downloadURL)
 }
 
 // isLatest checks the latest release tag and
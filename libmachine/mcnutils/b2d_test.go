@@ -39,7 +39,7 @@ func TestGetReleaseURL(t *testing.T) {
 		// an interface.
 		actualMachineVersion := version.Version
 		version.Version = tt.machineVersion
-		b := NewB2dUtils("/tmp/isos")
+		b := NewB2dUtilsForArch("/tmp/isos", ArchAMD64)
 		isoURL, err := b.getReleaseURL(testServer.URL + tt.apiURL)
 
 		assert.NoError(t, err)
@@ -50,6 +50,22 @@ func TestGetReleaseURL(t *testing.T) {
 	}
 }
 
+func TestGetReleaseURLForArch(t *testing.T) {
+	testServer := newTestServer(`{"tag_name": "v0.1"}`)
+	defer testServer.Close()
+
+	b := NewB2dUtilsForArch("/tmp/isos", "aarch64")
+	isoURL, err := b.getReleaseURL(testServer.URL + "/repos/org/repo/releases/latest")
+
+	assert.NoError(t, err)
+	assert.Equal(t, testServer.URL+"/org/repo/releases/download/v0.1/boot2docker-arm64.iso", isoURL)
+	assert.Equal(t, filepath.Join("/tmp/isos", "cache", "boot2docker-arm64.iso"), b.path())
+
+	_, err = b.getReleaseURL("")
+
+	assert.EqualError(t, err, "Boot2Docker has no release for arm64, specify the URL of an ISO built for it")
+}
+
 func TestGetReleaseURLError(t *testing.T) {
 	// GitHub API error response in case of rate limit
 	ts := newTestServer(`{"message": "API rate limit exceeded for 127.0.0.1.",
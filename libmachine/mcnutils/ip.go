@@ -0,0 +1,22 @@
+package mcnutils
+
+import (
+	"net"
+	"strings"
+)
+
+// IsIPv6 returns true if the address is an IPv6 literal.
+func IsIPv6(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
+
+// BracketHost returns an IPv6 literal between brackets, as expected in the
+// user@host:path locations of scp and rsync.  The other hosts are returned
+// as is.
+func BracketHost(host string) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+	return host
+}
@@ -0,0 +1,21 @@
+package mcnutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIPv6(t *testing.T) {
+	assert.True(t, IsIPv6("2001:db8::1"))
+	assert.False(t, IsIPv6("192.168.99.100"))
+	assert.False(t, IsIPv6("::ffff:192.168.99.100"))
+	assert.False(t, IsIPv6("dev.example.com"))
+}
+
+func TestBracketHost(t *testing.T) {
+	assert.Equal(t, "[2001:db8::1]", BracketHost("2001:db8::1"))
+	assert.Equal(t, "[2001:db8::1]", BracketHost("[2001:db8::1]"))
+	assert.Equal(t, "192.168.99.100", BracketHost("192.168.99.100"))
+	assert.Equal(t, "dev.example.com", BracketHost("dev.example.com"))
+}
@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+type EventType string
+
+const (
+	// EventStateChanged is emitted when the state of a machine changes,
+	// e.g. from Running to Stopped.
+	EventStateChanged EventType = "state-changed"
+
+	// EventCheckFailed is emitted when the engine of a running machine
+	// can't be reached with its TLS certificates.
+	EventCheckFailed EventType = "check-failed"
+
+	// EventEngineRestarted is emitted when the engine was restarted to
+	// repair a machine.
+	EventEngineRestarted EventType = "engine-restarted"
+
+	// EventCertsRegenerated is emitted when the certificates were
+	// regenerated to repair a machine.
+	EventCertsRegenerated EventType = "certs-regenerated"
+
+	// EventCertsRotated is emitted when the server certificate of a
+	// machine was rotated because it was due.
+	EventCertsRotated EventType = "certs-rotated"
+
+	// EventRepairFailed is emitted when a repair, a rotation or an
+	// auto-stop failed, or when the machine still fails its checks after
+	// every repair was attempted.
+	EventRepairFailed EventType = "repair-failed"
+
+	// EventAutoStopped is emitted when a machine was stopped because its
+	// engine ran no container for its auto-stop idle time.
+	EventAutoStopped EventType = "auto-stopped"
+
+	// EventRecovered is emitted when a machine passes its checks again.
+	EventRecovered EventType = "recovered"
+)
+
+// Event is something which happened to a watched machine.
+type Event struct {
+	Type    EventType `json:"type"`
+	Machine string    `json:"machine"`
+	State   string    `json:"state"`
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Hook is notified of the events of the watched machines.
+type Hook interface {
+	Notify(event Event) error
+}
+
+// ScriptHook runs a script for each event.  The event is given in the
+// MACHINE_EVENT, MACHINE_NAME, MACHINE_STATE and MACHINE_EVENT_MESSAGE
+// environment variables, and as JSON on the standard input of the script.
+type ScriptHook struct {
+	Path string
+}
+
+func (hook *ScriptHook) Notify(event Event) error {
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(hook.Path)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("MACHINE_EVENT=%s", event.Type),
+		fmt.Sprintf("MACHINE_NAME=%s", event.Machine),
+		fmt.Sprintf("MACHINE_STATE=%s", event.State),
+		fmt.Sprintf("MACHINE_EVENT_MESSAGE=%s", event.Message),
+	)
+	cmd.Stdin = bytes.NewReader(content)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Error running hook %s: %s\n%s", hook.Path, err, output)
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/hooks"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const DefaultInterval = 30 * time.Second
+
+// Repairer repairs the engine of a machine failing its checks, and rotates
+// its certificates when they are due.
+type Repairer interface {
+	RestartEngine(h *host.Host) error
+	RegenerateCerts(h *host.Host) error
+	RotateCerts(h *host.Host) error
+}
+
+type hostRepairer struct{}
+
+func (r *hostRepairer) RestartEngine(h *host.Host) error {
+	return h.RestartEngine()
+}
+
+func (r *hostRepairer) RegenerateCerts(h *host.Host) error {
+	return h.ConfigureAuth()
+}
+
+func (r *hostRepairer) RotateCerts(h *host.Host) error {
+	return h.RotateCerts()
+}
+
+// Idler tells whether the engine of a machine runs containers, and stops
+// the machines idle for longer than their auto-stop idle time.
+type Idler interface {
+	RunningContainers(h *host.Host) (int, error)
+	Stop(h *host.Host) error
+}
+
+type hostIdler struct{}
+
+func (i *hostIdler) RunningContainers(h *host.Host) (int, error) {
+	return mcndockerclient.RunningContainers(h)
+}
+
+func (i *hostIdler) Stop(h *host.Host) error {
+	return h.Stop()
+}
+
+// Monitor polls the state of machines and the TLS endpoint of their engine.
+// A running machine failing its check is repaired by restarting its engine
+// first, then by regenerating its certificates if it still fails at the
+// next poll.  The server certificates of the healthy machines are rotated when
+// they are due, and the healthy machines with an auto-stop idle time are
+// stopped once their engine has run no container for that long.
+type Monitor struct {
+	Checker  check.ConnChecker
+	Repairer Repairer
+	Idler    Idler
+	Hook     Hook
+	Repair   bool
+
+	machines map[string]*machineStatus
+	now      func() time.Time
+}
+
+type machineStatus struct {
+	state    state.State
+	failures int
+
+	// idleSince is when the engine was first seen running no container,
+	// zero while it runs some.
+	idleSince time.Time
+}
+
+func NewMonitor(hook Hook, repair bool) *Monitor {
+	return &Monitor{
+		Checker:  check.DefaultConnChecker,
+		Repairer: &hostRepairer{},
+		Idler:    &hostIdler{},
+		Hook:     hook,
+		Repair:   repair,
+		machines: map[string]*machineStatus{},
+		now:      time.Now,
+	}
+}
+
+// Run polls the machines returned by load every interval, until stop is
+// closed.
+func (m *Monitor) Run(load func() ([]*host.Host, error), interval time.Duration, stop <-chan struct{}) error {
+	for {
+		hosts, err := load()
+		if err != nil {
+			return err
+		}
+
+		m.Poll(hosts)
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Poll checks each machine once.
+func (m *Monitor) Poll(hosts []*host.Host) {
+	for _, h := range hosts {
+		m.poll(h)
+	}
+}
+
+func (m *Monitor) poll(h *host.Host) {
+	status, known := m.machines[h.Name]
+	if !known {
+		status = &machineStatus{}
+		m.machines[h.Name] = status
+	}
+
+	currentState, err := h.Driver.GetState()
+	if err != nil {
+		log.Debugf("Error getting the state of %s: %s", h.Name, err)
+		currentState = state.Error
+	}
+
+	if known && currentState != status.state {
+		m.emit(EventStateChanged, h.Name, currentState, "")
+		hooks.Notify(hooks.Event{Type: hooks.StateChange, Machine: h.Name, Driver: h.DriverName, State: currentState.String()})
+	}
+	status.state = currentState
+
+	if currentState != state.Running {
+		status.failures = 0
+		status.idleSince = time.Time{}
+		return
+	}
+
+	if _, _, err := m.Checker.Check(h, false); err != nil {
+		status.failures++
+		m.emit(EventCheckFailed, h.Name, currentState, err.Error())
+
+		if m.Repair {
+			m.repair(h, status)
+		}
+		return
+	}
+
+	if status.failures > 0 {
+		m.emit(EventRecovered, h.Name, currentState, "")
+	}
+	status.failures = 0
+
+	if m.Repair {
+		m.rotate(h, currentState)
+	}
+
+	if h.AutoStop != nil && h.AutoStop.Idle > 0 {
+		m.autoStop(h, status)
+	}
+}
+
+// autoStop stops a machine whose engine has run no container for its idle
+// time.  The idle time counts from the first poll finding no container.
+func (m *Monitor) autoStop(h *host.Host, status *machineStatus) {
+	if h.IsContainerdEndpoint() {
+		return
+	}
+
+	running, err := m.Idler.RunningContainers(h)
+	if err != nil {
+		log.Debugf("Error listing the containers of %s: %s", h.Name, err)
+		status.idleSince = time.Time{}
+		return
+	}
+
+	if running > 0 {
+		status.idleSince = time.Time{}
+		return
+	}
+
+	now := m.now()
+	if status.idleSince.IsZero() {
+		status.idleSince = now
+	}
+
+	idle := now.Sub(status.idleSince)
+	if idle < h.AutoStop.Idle {
+		return
+	}
+
+	log.Infof("Stopping %s, which ran no container for %s...", h.Name, idle)
+	if err := m.Idler.Stop(h); err != nil {
+		m.emit(EventRepairFailed, h.Name, status.state, err.Error())
+		return
+	}
+
+	status.idleSince = time.Time{}
+	m.emit(EventAutoStopped, h.Name, status.state, "idle for "+h.AutoStop.Idle.String())
+}
+
+// rotate rotates the server certificate of a machine if it expires soon, or
+// if it's older than the rotation interval of the machine.
+func (m *Monitor) rotate(h *host.Host, currentState state.State) {
+	authOptions := h.AuthOptions()
+	if authOptions == nil || authOptions.ServerCertPath == "" {
+		return
+	}
+
+	due, err := cert.NeedsRotation(authOptions.ServerCertPath, authOptions.RotateInterval, time.Now())
+	if err != nil {
+		log.Debugf("Error reading the server certificate of %s: %s", h.Name, err)
+		return
+	}
+
+	if !due {
+		return
+	}
+
+	log.Infof("Rotating the certificates of %s...", h.Name)
+	if err := m.Repairer.RotateCerts(h); err != nil {
+		m.emit(EventRepairFailed, h.Name, currentState, err.Error())
+		return
+	}
+	m.emit(EventCertsRotated, h.Name, currentState, "")
+}
+
+func (m *Monitor) repair(h *host.Host, status *machineStatus) {
+	switch status.failures {
+	case 1:
+		log.Infof("Restarting the engine of %s...", h.Name)
+		if err := m.Repairer.RestartEngine(h); err != nil {
+			m.emit(EventRepairFailed, h.Name, status.state, err.Error())
+			return
+		}
+		m.emit(EventEngineRestarted, h.Name, status.state, "")
+	case 2:
+		log.Infof("Regenerating the certificates of %s...", h.Name)
+		if err := m.Repairer.RegenerateCerts(h); err != nil {
+			m.emit(EventRepairFailed, h.Name, status.state, err.Error())
+			return
+		}
+		m.emit(EventCertsRegenerated, h.Name, status.state, "")
+	case 3:
+		// Stop there until the machine recovers rather than keep
+		// restarting its engine
+		m.emit(EventRepairFailed, h.Name, status.state, "the machine still fails its checks after restarting its engine and regenerating its certificates")
+	}
+}
+
+func (m *Monitor) emit(eventType EventType, name string, machineState state.State, message string) {
+	event := Event{
+		Type:    eventType,
+		Machine: name,
+		State:   machineState.String(),
+		Message: message,
+		Time:    time.Now(),
+	}
+
+	if message != "" {
+		log.Infof("%s: %s (%s)", name, eventType, message)
+	} else {
+		log.Infof("%s: %s (%s)", name, eventType, event.State)
+	}
+
+	if m.Hook == nil {
+		return
+	}
+
+	if err := m.Hook.Notify(event); err != nil {
+		log.Warnf("%s", err)
+	}
+}
@@ -0,0 +1,286 @@
+package monitor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeChecker struct {
+	err error
+}
+
+func (fc *fakeChecker) Check(_ *host.Host, _ bool) (string, *auth.Options, error) {
+	return "tcp://1.2.3.4:2376", &auth.Options{}, fc.err
+}
+
+type fakeRepairer struct {
+	repairs []string
+	err     error
+}
+
+func (fr *fakeRepairer) RestartEngine(h *host.Host) error {
+	fr.repairs = append(fr.repairs, "restart "+h.Name)
+	return fr.err
+}
+
+func (fr *fakeRepairer) RegenerateCerts(h *host.Host) error {
+	fr.repairs = append(fr.repairs, "certs "+h.Name)
+	return fr.err
+}
+
+func (fr *fakeRepairer) RotateCerts(h *host.Host) error {
+	fr.repairs = append(fr.repairs, "rotate "+h.Name)
+	return fr.err
+}
+
+type recordingHook struct {
+	events []EventType
+}
+
+func (rh *recordingHook) Notify(event Event) error {
+	rh.events = append(rh.events, event.Type)
+	return nil
+}
+
+type fakeIdler struct {
+	running int
+	stopped []string
+}
+
+func (fi *fakeIdler) RunningContainers(_ *host.Host) (int, error) {
+	return fi.running, nil
+}
+
+func (fi *fakeIdler) Stop(h *host.Host) error {
+	fi.stopped = append(fi.stopped, h.Name)
+	return nil
+}
+
+func newTestMonitor(checker *fakeChecker, repairer *fakeRepairer, hook *recordingHook) *Monitor {
+	m := NewMonitor(hook, true)
+	m.Checker = checker
+	m.Repairer = repairer
+	return m
+}
+
+func TestPollHealthyMachine(t *testing.T) {
+	repairer := &fakeRepairer{}
+	hook := &recordingHook{}
+	m := newTestMonitor(&fakeChecker{}, repairer, hook)
+
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running}}
+	m.Poll([]*host.Host{h})
+	m.Poll([]*host.Host{h})
+
+	assert.Empty(t, hook.events)
+	assert.Empty(t, repairer.repairs)
+}
+
+func TestPollStateChanged(t *testing.T) {
+	hook := &recordingHook{}
+	m := newTestMonitor(&fakeChecker{}, &fakeRepairer{}, hook)
+
+	driver := &fakedriver.Driver{MockState: state.Running}
+	h := &host.Host{Name: "dev", Driver: driver}
+	m.Poll([]*host.Host{h})
+
+	driver.MockState = state.Stopped
+	m.Poll([]*host.Host{h})
+
+	assert.Equal(t, []EventType{EventStateChanged}, hook.events)
+}
+
+func TestPollRepairsEscalate(t *testing.T) {
+	checker := &fakeChecker{err: errors.New("connection refused")}
+	repairer := &fakeRepairer{}
+	hook := &recordingHook{}
+	m := newTestMonitor(checker, repairer, hook)
+
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running}}
+	for i := 0; i < 4; i++ {
+		m.Poll([]*host.Host{h})
+	}
+
+	assert.Equal(t, []string{"restart dev", "certs dev"}, repairer.repairs)
+	assert.Equal(t, []EventType{
+		EventCheckFailed, EventEngineRestarted,
+		EventCheckFailed, EventCertsRegenerated,
+		EventCheckFailed, EventRepairFailed,
+		EventCheckFailed,
+	}, hook.events)
+
+	checker.err = nil
+	m.Poll([]*host.Host{h})
+
+	assert.Equal(t, EventRecovered, hook.events[len(hook.events)-1])
+}
+
+func TestPollWithoutRepair(t *testing.T) {
+	repairer := &fakeRepairer{}
+	hook := &recordingHook{}
+	m := newTestMonitor(&fakeChecker{err: errors.New("connection refused")}, repairer, hook)
+	m.Repair = false
+
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running}}
+	m.Poll([]*host.Host{h})
+
+	assert.Equal(t, []EventType{EventCheckFailed}, hook.events)
+	assert.Empty(t, repairer.repairs)
+}
+
+func TestPollRepairFailed(t *testing.T) {
+	hook := &recordingHook{}
+	m := newTestMonitor(&fakeChecker{err: errors.New("connection refused")}, &fakeRepairer{err: errors.New("ssh failed")}, hook)
+
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running}}
+	m.Poll([]*host.Host{h})
+
+	assert.Equal(t, []EventType{EventCheckFailed, EventRepairFailed}, hook.events)
+}
+
+func TestPollRotatesDueCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath := filepath.Join(dir, "server.pem")
+	assert.NoError(t, cert.GenerateCACertificate(certPath, filepath.Join(dir, "server-key.pem"), "test-org", 2048))
+
+	repairer := &fakeRepairer{}
+	hook := &recordingHook{}
+	m := newTestMonitor(&fakeChecker{}, repairer, hook)
+
+	h := &host.Host{
+		Name:   "dev",
+		Driver: &fakedriver.Driver{MockState: state.Running},
+		HostOptions: &host.Options{
+			AuthOptions: &auth.Options{ServerCertPath: certPath},
+		},
+	}
+	m.Poll([]*host.Host{h})
+	assert.Empty(t, repairer.repairs)
+
+	// The certificate was issued a few minutes ago
+	h.HostOptions.AuthOptions.RotateInterval = time.Minute
+	m.Poll([]*host.Host{h})
+	assert.Equal(t, []string{"rotate dev"}, repairer.repairs)
+	assert.Equal(t, []EventType{EventCertsRotated}, hook.events)
+}
+
+func TestPollAutoStopsIdleMachine(t *testing.T) {
+	idler := &fakeIdler{}
+	hook := &recordingHook{}
+	m := newTestMonitor(&fakeChecker{}, &fakeRepairer{}, hook)
+	m.Idler = idler
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	h := &host.Host{
+		Name:     "dev",
+		Driver:   &fakedriver.Driver{MockState: state.Running},
+		AutoStop: &host.AutoStop{Idle: 30 * time.Minute},
+	}
+
+	m.Poll([]*host.Host{h})
+	now = now.Add(20 * time.Minute)
+	m.Poll([]*host.Host{h})
+	assert.Empty(t, idler.stopped)
+
+	now = now.Add(10 * time.Minute)
+	m.Poll([]*host.Host{h})
+	assert.Equal(t, []string{"dev"}, idler.stopped)
+	assert.Equal(t, []EventType{EventAutoStopped}, hook.events)
+}
+
+func TestPollAutoStopResetsOnActivity(t *testing.T) {
+	idler := &fakeIdler{}
+	m := newTestMonitor(&fakeChecker{}, &fakeRepairer{}, &recordingHook{})
+	m.Idler = idler
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	h := &host.Host{
+		Name:     "dev",
+		Driver:   &fakedriver.Driver{MockState: state.Running},
+		AutoStop: &host.AutoStop{Idle: 30 * time.Minute},
+	}
+
+	m.Poll([]*host.Host{h})
+	now = now.Add(20 * time.Minute)
+	idler.running = 1
+	m.Poll([]*host.Host{h})
+
+	idler.running = 0
+	now = now.Add(20 * time.Minute)
+	m.Poll([]*host.Host{h})
+	now = now.Add(20 * time.Minute)
+	m.Poll([]*host.Host{h})
+	assert.Empty(t, idler.stopped)
+}
+
+func TestPollWithoutAutoStop(t *testing.T) {
+	idler := &fakeIdler{}
+	m := newTestMonitor(&fakeChecker{}, &fakeRepairer{}, &recordingHook{})
+	m.Idler = idler
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running}}
+	m.Poll([]*host.Host{h})
+	now = now.Add(24 * time.Hour)
+	m.Poll([]*host.Host{h})
+
+	assert.Empty(t, idler.stopped)
+}
+
+func TestRunStops(t *testing.T) {
+	hook := &recordingHook{}
+	m := newTestMonitor(&fakeChecker{}, &fakeRepairer{}, hook)
+
+	stop := make(chan struct{})
+	close(stop)
+
+	polls := 0
+	err := m.Run(func() ([]*host.Host, error) {
+		polls++
+		return nil, nil
+	}, DefaultInterval, stop)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, polls)
+}
+
+func TestScriptHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+	script := filepath.Join(dir, "hook.sh")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$MACHINE_EVENT $MACHINE_NAME $MACHINE_STATE\" > "+output+"\ncat >> "+output+"\n"), 0755))
+
+	hook := &ScriptHook{Path: script}
+	assert.NoError(t, hook.Notify(Event{Type: EventCheckFailed, Machine: "dev", State: "Running", Message: "connection refused"}))
+
+	content, err := ioutil.ReadFile(output)
+	assert.NoError(t, err)
+
+	lines := strings.SplitN(string(content), "\n", 2)
+	assert.Equal(t, "check-failed dev Running", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], `{"type":"check-failed","machine":"dev","state":"Running","message":"connection refused"`))
+}
@@ -0,0 +1,53 @@
+package libmachine
+
+import (
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/provision"
+)
+
+// Option configures a Client created by NewClient, for the programs
+// embedding libmachine, e.g.
+//
+//	client := libmachine.NewClient(storePath, certsDir,
+//		libmachine.WithStore(store),
+//		libmachine.WithSSHClientCreator(creator))
+//
+// The options only configure the client they're given to.  The SSH client
+// type the provisioning connects with, the certificate generator and the log
+// sink are settings of the whole program, set with ssh.SetDefaultClient,
+// cert.SetCertGenerator and log.SetSink.
+type Option func(*Client)
+
+// WithStore persists the machines in store instead of the files of the
+// store path.
+func WithStore(store persist.Store) Option {
+	return func(api *Client) {
+		api.Store = store
+	}
+}
+
+// WithSSHClientCreator creates the SSH clients of the RunSSHCommand and
+// CreateSSHClient of the machines of the client, e.g. for the SSH sessions a
+// server opens on them.  The provisioning isn't given these clients.
+func WithSSHClientCreator(creator host.SSHClientCreator) Option {
+	return func(api *Client) {
+		api.sshClientCreator = creator
+	}
+}
+
+// WithProvisioners detects the provisioners of the machines of the client
+// among the ones of registry instead of the default registry.
+func WithProvisioners(registry provision.Registry) Option {
+	return func(api *Client) {
+		api.provisioners = registry
+	}
+}
+
+// WithGithubAPIToken authenticates the requests to the API of GitHub, e.g.
+// to find the latest boot2docker release.
+func WithGithubAPIToken(token string) Option {
+	return func(api *Client) {
+		api.GithubAPIToken = token
+	}
+}
@@ -0,0 +1,115 @@
+package osimage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// alpineReleasesURL is the GitHub API URL of the latest release of the Alpine
+// Linux based images.
+var alpineReleasesURL = "https://api.github.com/repos/m-hu/machine-os/releases/latest"
+
+// alpineImage is the Alpine Linux based image.  It keeps the conventions of
+// Boot2Docker, so that its machines are created and provisioned as the
+// Boot2Docker ones: the docker user, the SSH key read from the userdata.tar
+// of the disk, the engine configured by /var/lib/boot2docker/profile.
+type alpineImage struct{}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (i *alpineImage) Name() string {
+	return Alpine
+}
+
+func (i *alpineImage) SSHUser() string {
+	return "docker"
+}
+
+// Latest returns the ISO of the latest release, machine-alpine-<arch>.iso,
+// with its checksum in SHA256SUMS and its signature.
+func (i *alpineImage) Latest(arch string) (*Release, error) {
+	release := &githubRelease{}
+	if err := getJSON(alpineReleasesURL, mcnutils.GithubAPIToken, release); err != nil {
+		return nil, err
+	}
+
+	isoName := fmt.Sprintf("machine-alpine-%s.iso", arch)
+	assets := map[string]string{}
+	for _, asset := range release.Assets {
+		assets[asset.Name] = asset.URL
+	}
+
+	isoURL, ok := assets[isoName]
+	if !ok {
+		return nil, fmt.Errorf("The %s release of the alpine OS image has no ISO for %s", release.TagName, arch)
+	}
+
+	sumsURL, ok := assets["SHA256SUMS"]
+	if !ok {
+		return nil, fmt.Errorf("The %s release of the alpine OS image has no SHA256SUMS", release.TagName)
+	}
+
+	sums := &bytes.Buffer{}
+	if _, err := fetch(sumsURL, sums); err != nil {
+		return nil, err
+	}
+
+	sum := checksumOf(sums.String(), isoName)
+	if sum == "" {
+		return nil, fmt.Errorf("The SHA256SUMS of the %s release of the alpine OS image has no checksum for %s", release.TagName, isoName)
+	}
+
+	return &Release{
+		Version:      release.TagName,
+		URL:          isoURL,
+		SHA256:       sum,
+		SignatureURL: assets[isoName+".asc"],
+	}, nil
+}
+
+// checksumOf returns the checksum of a file in the output of sha256sum.
+func checksumOf(sums, filename string) string {
+	scanner := bufio.NewScanner(strings.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filename {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+func getJSON(url, githubToken string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	if githubToken != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", githubToken))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
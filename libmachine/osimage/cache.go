@@ -0,0 +1,187 @@
+package osimage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// Cache is the cache of the images, next to the Boot2Docker ISO.
+type Cache struct {
+	dir string
+
+	// keysDir holds the OpenPGP keyrings the signatures of the images
+	// are verified with, as <image>.gpg.
+	keysDir string
+
+	storePath string
+}
+
+// NewCache returns the cache of the images of a storage path.
+func NewCache(storePath string) *Cache {
+	return &Cache{
+		dir:       filepath.Join(storePath, "cache"),
+		keysDir:   filepath.Join(storePath, "keys"),
+		storePath: storePath,
+	}
+}
+
+func (c *Cache) isoPath(image Image, arch string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s-%s.iso", image.Name(), arch))
+}
+
+// Update downloads the latest release of an image to the cache, unless it's
+// the cached one, and returns the path of the cached ISO.  The cached ISO is
+// kept when the latest release can't be found.
+func (c *Cache) Update(image Image, arch string) (string, error) {
+	arch = mcnutils.NormalizeArchitecture(arch)
+	isoPath := c.isoPath(image, arch)
+	versionPath := isoPath + ".version"
+
+	cachedVersion := ""
+	if _, err := os.Stat(isoPath); err == nil {
+		if content, err := ioutil.ReadFile(versionPath); err == nil {
+			cachedVersion = strings.TrimSpace(string(content))
+		}
+	}
+
+	release, err := image.Latest(arch)
+	if err != nil {
+		if cachedVersion != "" {
+			log.Warnf("Unable to get the latest release of the %s OS image, using the cached %s: %s", image.Name(), cachedVersion, err)
+			return isoPath, nil
+		}
+		return "", err
+	}
+
+	if cachedVersion == release.Version {
+		return isoPath, nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return "", err
+	}
+
+	log.Infof("Downloading the %s OS image %s from %s...", image.Name(), release.Version, release.URL)
+	if err := c.download(image, release, isoPath); err != nil {
+		return "", fmt.Errorf("Error downloading the %s OS image: %s", image.Name(), err)
+	}
+
+	if err := ioutil.WriteFile(versionPath, []byte(release.Version+"\n"), 0600); err != nil {
+		return "", err
+	}
+
+	return isoPath, nil
+}
+
+// CopyToMachineDir copies the latest release of an image to the directory of
+// a machine, as the ISO the drivers boot from.
+func (c *Cache) CopyToMachineDir(image Image, arch, machineName string) error {
+	isoPath, err := c.Update(image, arch)
+	if err != nil {
+		return err
+	}
+
+	machineISOPath := filepath.Join(c.storePath, "machines", machineName, machineISOFilename)
+	log.Infof("Copying %s to %s...", isoPath, machineISOPath)
+	return mcnutils.CopyFile(isoPath, machineISOPath)
+}
+
+// download downloads the ISO of a release to a temporary file, and renames it
+// once its checksum and signature are verified.
+func (c *Cache) download(image Image, release *Release, dest string) error {
+	if release.SHA256 == "" {
+		return fmt.Errorf("the release %s has no checksum to verify", release.Version)
+	}
+
+	f, err := ioutil.TempFile(c.dir, filepath.Base(dest)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	sum, err := fetch(release.URL, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(sum, release.SHA256) {
+		return fmt.Errorf("the checksum of %s is %s, expected %s", release.URL, sum, release.SHA256)
+	}
+
+	if release.SignatureURL != "" {
+		if err := c.verifySignature(image, release, f.Name()); err != nil {
+			return err
+		}
+	}
+
+	// Windows can't rename in place
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Rename(f.Name(), dest)
+}
+
+// verifySignature verifies the detached signature of an ISO with gpgv and the
+// keyring of the image.  Without the keyring, only the checksum is verified.
+func (c *Cache) verifySignature(image Image, release *Release, isoPath string) error {
+	keyring := filepath.Join(c.keysDir, image.Name()+".gpg")
+	if _, err := os.Stat(keyring); os.IsNotExist(err) {
+		log.Warnf("The signature of the %s OS image isn't verified, add the OpenPGP keyring of its publisher as %s", image.Name(), keyring)
+		return nil
+	}
+
+	sig, err := ioutil.TempFile(c.dir, filepath.Base(isoPath)+".sig")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sig.Name())
+
+	_, err = fetch(release.SignatureURL, sig)
+	if closeErr := sig.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if output, err := exec.Command("gpgv", "--keyring", keyring, sig.Name(), isoPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("the signature of %s doesn't match the keyring %s: %s\n%s", release.URL, keyring, err, output)
+	}
+
+	return nil
+}
+
+// fetch writes the content of a URL, and returns its SHA-256 checksum.
+func fetch(url string, w io.Writer) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
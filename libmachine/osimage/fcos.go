@@ -0,0 +1,92 @@
+package osimage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// fedoraCoreOSStreamURL is the stream metadata of the stable stream of Fedora
+// CoreOS.
+var fedoraCoreOSStreamURL = "https://builds.coreos.fedoraproject.org/streams/stable.json"
+
+// fedoraCoreOSArchitectures are the names of the architectures in the stream
+// metadata.
+var fedoraCoreOSArchitectures = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+}
+
+// fedoraCoreOSImage is the live ISO of Fedora CoreOS.  It runs from memory,
+// with the moby engine it ships, and accepts the SSH key of the machine
+// through the Ignition config embedded into the ISO of each machine.
+type fedoraCoreOSImage struct{}
+
+type fedoraCoreOSStream struct {
+	Architectures map[string]struct {
+		Artifacts struct {
+			Metal struct {
+				Release string `json:"release"`
+				Formats struct {
+					ISO struct {
+						Disk struct {
+							Location  string `json:"location"`
+							Signature string `json:"signature"`
+							SHA256    string `json:"sha256"`
+						} `json:"disk"`
+					} `json:"iso"`
+				} `json:"formats"`
+			} `json:"metal"`
+		} `json:"artifacts"`
+	} `json:"architectures"`
+}
+
+func (i *fedoraCoreOSImage) Name() string {
+	return FedoraCoreOS
+}
+
+func (i *fedoraCoreOSImage) SSHUser() string {
+	return "core"
+}
+
+func (i *fedoraCoreOSImage) Latest(arch string) (*Release, error) {
+	streamArch, ok := fedoraCoreOSArchitectures[arch]
+	if !ok {
+		return nil, fmt.Errorf("Fedora CoreOS has no live ISO for %s", arch)
+	}
+
+	stream := &fedoraCoreOSStream{}
+	if err := getJSON(fedoraCoreOSStreamURL, "", stream); err != nil {
+		return nil, err
+	}
+
+	artifacts, ok := stream.Architectures[streamArch]
+	if !ok || artifacts.Artifacts.Metal.Formats.ISO.Disk.Location == "" {
+		return nil, fmt.Errorf("The Fedora CoreOS stream has no live ISO for %s", arch)
+	}
+
+	metal := artifacts.Artifacts.Metal
+	return &Release{
+		Version:      metal.Release,
+		URL:          metal.Formats.ISO.Disk.Location,
+		SHA256:       metal.Formats.ISO.Disk.SHA256,
+		SignatureURL: metal.Formats.ISO.Disk.Signature,
+	}, nil
+}
+
+// Personalize embeds into the ISO an Ignition config authorizing the SSH key
+// for the core user, and keeping the containers and the images on the disk
+// of the machine.
+func (i *fedoraCoreOSImage) Personalize(isoPath, publicKeyPath string) error {
+	publicKey, err := ioutil.ReadFile(publicKeyPath)
+	if err != nil {
+		return err
+	}
+
+	config, err := ignitionConfig(strings.TrimSpace(string(publicKey)))
+	if err != nil {
+		return err
+	}
+
+	return embedIgnition(isoPath, config)
+}
@@ -0,0 +1,211 @@
+package osimage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	isoSectorSize = 2048
+
+	// ignitionEmbedPath is the file of the Fedora CoreOS live ISO its
+	// Ignition config is embedded into, loaded as an additional initramfs
+	// by the bootloader, as coreos-installer iso ignition embed does.
+	ignitionEmbedPath = "IMAGES/IGNITION.IMG"
+
+	// machineDataLabel is the label of the filesystem Ignition creates on
+	// the disk of the machine.
+	machineDataLabel = "machine-data"
+
+	machineDataMountUnit = `[Unit]
+Description=Docker data of the machine
+Before=docker.service
+
+[Mount]
+What=/dev/disk/by-label/` + machineDataLabel + `
+Where=/var/lib/docker
+Type=ext4
+
+[Install]
+RequiredBy=docker.service
+`
+)
+
+// ignitionConfig returns the Ignition config of a machine booted from the live
+// ISO: it authorizes the SSH key, and keeps the containers and the images on
+// the disk of the machine, formatted at the first boot.
+func ignitionConfig(publicKey string) ([]byte, error) {
+	type unit struct {
+		Name     string `json:"name"`
+		Enabled  bool   `json:"enabled"`
+		Contents string `json:"contents"`
+	}
+
+	config := map[string]interface{}{
+		"ignition": map[string]string{"version": "3.3.0"},
+		"passwd": map[string]interface{}{
+			"users": []map[string]interface{}{
+				{"name": "core", "sshAuthorizedKeys": []string{publicKey}},
+			},
+		},
+		"storage": map[string]interface{}{
+			"filesystems": []map[string]interface{}{
+				{"device": "/dev/sda", "format": "ext4", "label": machineDataLabel, "wipeFilesystem": false},
+			},
+		},
+		"systemd": map[string]interface{}{
+			"units": []unit{{Name: "var-lib-docker.mount", Enabled: true, Contents: machineDataMountUnit}},
+		},
+	}
+
+	return json.Marshal(config)
+}
+
+// embedIgnition writes an Ignition config into the embed area of a live ISO,
+// as a compressed cpio archive holding config.ign, padded with zeros.
+func embedIgnition(isoPath string, config []byte) error {
+	f, err := os.OpenFile(isoPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, length, err := findISOFile(f, ignitionEmbedPath)
+	if err != nil {
+		return err
+	}
+
+	archive, err := ignitionArchive(config)
+	if err != nil {
+		return err
+	}
+
+	if int64(len(archive)) > length {
+		return fmt.Errorf("The Ignition config takes %d bytes, more than the %d bytes of the embed area of %s", len(archive), length, isoPath)
+	}
+
+	area := make([]byte, length)
+	copy(area, archive)
+	if _, err := f.WriteAt(area, offset); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// ignitionArchive returns the gzipped newc cpio archive holding config.ign.
+func ignitionArchive(config []byte) ([]byte, error) {
+	var cpio bytes.Buffer
+	writeCpioEntry(&cpio, "config.ign", 0100644, config)
+	writeCpioEntry(&cpio, "TRAILER!!!", 0, nil)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(cpio.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
+
+func writeCpioEntry(w *bytes.Buffer, name string, mode int, content []byte) {
+	fmt.Fprintf(w, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+		0, mode, 0, 0, 1, 0, len(content), 0, 0, 0, 0, len(name)+1, 0)
+	w.WriteString(name)
+	w.WriteByte(0)
+	cpioPad(w)
+	w.Write(content)
+	cpioPad(w)
+}
+
+func cpioPad(w *bytes.Buffer) {
+	for w.Len()%4 != 0 {
+		w.WriteByte(0)
+	}
+}
+
+// findISOFile returns the offset and the length of a file of an ISO 9660
+// filesystem, given by its path of ISO 9660 names.
+func findISOFile(r io.ReaderAt, path string) (int64, int64, error) {
+	descriptor := make([]byte, isoSectorSize)
+	if _, err := r.ReadAt(descriptor, 16*isoSectorSize); err != nil {
+		return 0, 0, fmt.Errorf("Error reading the ISO 9660 volume descriptor: %s", err)
+	}
+
+	if descriptor[0] != 1 || string(descriptor[1:6]) != "CD001" {
+		return 0, 0, fmt.Errorf("Not an ISO 9660 filesystem")
+	}
+
+	// The record of the root directory is in the primary volume descriptor
+	extent, size, _ := parseISORecord(descriptor[156:])
+	components := strings.Split(path, "/")
+
+	for i, component := range components {
+		entryExtent, entrySize, isDir, err := findISOEntry(r, extent, size, component)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Error finding %s in the ISO: %s", path, err)
+		}
+
+		if last := i == len(components)-1; last == isDir {
+			return 0, 0, fmt.Errorf("Error finding %s in the ISO: %s has an unexpected type", path, component)
+		}
+
+		extent, size = entryExtent, entrySize
+	}
+
+	return extent * isoSectorSize, size, nil
+}
+
+// findISOEntry finds an entry of a directory by its name, without its version.
+func findISOEntry(r io.ReaderAt, extent, size int64, name string) (int64, int64, bool, error) {
+	dir := make([]byte, size)
+	if _, err := r.ReadAt(dir, extent*isoSectorSize); err != nil {
+		return 0, 0, false, err
+	}
+
+	for pos := 0; pos < len(dir); {
+		length := int(dir[pos])
+		if length == 0 {
+			// Records don't span sectors, the rest of the sector is padding
+			pos = (pos/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+
+		if pos+length > len(dir) || length < 34 {
+			break
+		}
+
+		record := dir[pos : pos+length]
+		entryExtent, entrySize, isDir := parseISORecord(record)
+		nameLength := int(record[32])
+		if 33+nameLength <= len(record) {
+			entryName := string(record[33 : 33+nameLength])
+			if i := strings.Index(entryName, ";"); i >= 0 {
+				entryName = entryName[:i]
+			}
+			if strings.EqualFold(strings.TrimSuffix(entryName, "."), name) {
+				return entryExtent, entrySize, isDir, nil
+			}
+		}
+
+		pos += length
+	}
+
+	return 0, 0, false, fmt.Errorf("no %s entry", name)
+}
+
+func parseISORecord(record []byte) (int64, int64, bool) {
+	extent := int64(binary.LittleEndian.Uint32(record[2:6]))
+	size := int64(binary.LittleEndian.Uint32(record[10:14]))
+	isDir := record[25]&2 != 0
+	return extent, size, isDir
+}
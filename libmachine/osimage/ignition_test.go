@@ -0,0 +1,137 @@
+package osimage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// isoRecord returns an ISO 9660 directory record.
+func isoRecord(name string, extent, size uint32, dir bool) []byte {
+	length := 33 + len(name)
+	if length%2 != 0 {
+		length++
+	}
+
+	record := make([]byte, length)
+	record[0] = byte(length)
+	binary.LittleEndian.PutUint32(record[2:6], extent)
+	binary.BigEndian.PutUint32(record[6:10], extent)
+	binary.LittleEndian.PutUint32(record[10:14], size)
+	binary.BigEndian.PutUint32(record[14:18], size)
+	if dir {
+		record[25] = 2
+	}
+	record[32] = byte(len(name))
+	copy(record[33:], name)
+
+	return record
+}
+
+// writeTestISO writes an ISO with the images/ignition.img embed area of the
+// live ISO of Fedora CoreOS, and returns the offset of the area.
+func writeTestISO(t *testing.T, path string, areaSize uint32) int64 {
+	const (
+		rootSector   = 18
+		imagesSector = 19
+		areaSector   = 20
+	)
+
+	iso := make([]byte, (areaSector+1)*isoSectorSize+int(areaSize))
+
+	pvd := iso[16*isoSectorSize:]
+	pvd[0] = 1
+	copy(pvd[1:], "CD001")
+	copy(pvd[156:], isoRecord("\x00", rootSector, isoSectorSize, true))
+
+	root := iso[rootSector*isoSectorSize:]
+	pos := copy(root, isoRecord("\x00", rootSector, isoSectorSize, true))
+	pos += copy(root[pos:], isoRecord("\x01", rootSector, isoSectorSize, true))
+	pos += copy(root[pos:], isoRecord("EFI", 30, isoSectorSize, true))
+	copy(root[pos:], isoRecord("IMAGES", imagesSector, isoSectorSize, true))
+
+	images := iso[imagesSector*isoSectorSize:]
+	pos = copy(images, isoRecord("\x00", imagesSector, isoSectorSize, true))
+	pos += copy(images[pos:], isoRecord("\x01", rootSector, isoSectorSize, true))
+	pos += copy(images[pos:], isoRecord("EFIBOOT.IMG;1", 31, 1024, false))
+	copy(images[pos:], isoRecord("IGNITION.IMG;1", areaSector, areaSize, false))
+
+	assert.NoError(t, ioutil.WriteFile(path, iso, 0600))
+
+	return areaSector * isoSectorSize
+}
+
+func TestPersonalizeFedoraCoreOS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "osimage")
+	defer os.RemoveAll(dir)
+
+	isoPath := filepath.Join(dir, "boot2docker.iso")
+	offset := writeTestISO(t, isoPath, 256*1024)
+
+	keyPath := filepath.Join(dir, "id_rsa.pub")
+	ioutil.WriteFile(keyPath, []byte("ssh-rsa AAAAB3Nza machine\n"), 0600)
+
+	assert.NoError(t, Personalize(FedoraCoreOS, isoPath, keyPath))
+
+	iso, _ := ioutil.ReadFile(isoPath)
+	gz, err := gzip.NewReader(bytes.NewReader(iso[offset:]))
+	assert.NoError(t, err)
+	gz.Multistream(false)
+	cpio, err := ioutil.ReadAll(gz)
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(string(cpio), "070701"))
+	assert.Contains(t, string(cpio), "config.ign\x00")
+	assert.Contains(t, string(cpio), "TRAILER!!!")
+
+	start := strings.Index(string(cpio), "{")
+	end := strings.LastIndex(string(cpio), "}")
+	config := struct {
+		Ignition struct {
+			Version string `json:"version"`
+		} `json:"ignition"`
+		Passwd struct {
+			Users []struct {
+				Name              string   `json:"name"`
+				SSHAuthorizedKeys []string `json:"sshAuthorizedKeys"`
+			} `json:"users"`
+		} `json:"passwd"`
+	}{}
+	assert.NoError(t, json.Unmarshal(cpio[start:end+1], &config))
+	assert.Equal(t, "3.3.0", config.Ignition.Version)
+	assert.Equal(t, "core", config.Passwd.Users[0].Name)
+	assert.Equal(t, []string{"ssh-rsa AAAAB3Nza machine"}, config.Passwd.Users[0].SSHAuthorizedKeys)
+}
+
+func TestPersonalizeAlpine(t *testing.T) {
+	// The alpine image reads the SSH key from the disk, as Boot2Docker
+	assert.NoError(t, Personalize(Alpine, "/nonexistent.iso", "/nonexistent.pub"))
+}
+
+func TestEmbedIgnitionTooLarge(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "osimage")
+	defer os.RemoveAll(dir)
+
+	isoPath := filepath.Join(dir, "boot2docker.iso")
+	writeTestISO(t, isoPath, 16)
+
+	assert.Error(t, embedIgnition(isoPath, []byte(`{"ignition": {"version": "3.3.0"}}`)))
+}
+
+func TestEmbedIgnitionNotLiveISO(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "osimage")
+	defer os.RemoveAll(dir)
+
+	isoPath := filepath.Join(dir, "boot2docker.iso")
+	ioutil.WriteFile(isoPath, make([]byte, 20*isoSectorSize), 0600)
+
+	assert.EqualError(t, embedIgnition(isoPath, []byte("{}")), "Not an ISO 9660 filesystem")
+}
@@ -0,0 +1,176 @@
+// Package osimage provides the OS images the local drivers boot their machines
+// from, other than Boot2Docker: it finds their latest release, caches them once
+// their checksum and signature are verified, and configures the images that
+// are configured from the ISO rather than from the disk of the machine.
+package osimage
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	// Boot2Docker is the image of mcnutils.B2dUtils, the default one.
+	Boot2Docker = "boot2docker"
+
+	// Alpine is the Alpine Linux based image released from
+	// m-hu/machine-os, which keeps the conventions of Boot2Docker.
+	Alpine = "alpine"
+
+	// FedoraCoreOS is the live ISO of Fedora CoreOS, configured with
+	// Ignition.
+	FedoraCoreOS = "fcos"
+)
+
+// machineISOFilename is the name of the ISO in the directory of a machine,
+// the one the drivers boot from whatever the image.
+const machineISOFilename = "boot2docker.iso"
+
+// Release is a release of an image for an architecture.
+type Release struct {
+	Version string
+
+	// URL is where the ISO is downloaded from.
+	URL string
+
+	// SHA256 is the checksum of the ISO, required.
+	SHA256 string
+
+	// SignatureURL is where the detached OpenPGP signature of the ISO is
+	// downloaded from, if the image is signed.
+	SignatureURL string
+}
+
+// Image is an OS image the machines boot from.
+type Image interface {
+	// Name returns the name of the image, as given to the os-image
+	// flags of the drivers.
+	Name() string
+
+	// SSHUser returns the user the machines booted from the image are
+	// accessed with.
+	SSHUser() string
+
+	// Latest returns the latest release of the image for an
+	// architecture.
+	Latest(arch string) (*Release, error)
+}
+
+// Personalizer is implemented by the images configured from the ISO the
+// machine boots from, which is then configured for each machine.
+type Personalizer interface {
+	// Personalize configures the ISO of a machine so that the machine
+	// accepts the SSH key.
+	Personalize(isoPath, publicKeyPath string) error
+}
+
+var images = map[string]Image{}
+
+// Register makes an image available to the drivers.
+func Register(image Image) {
+	images[image.Name()] = image
+}
+
+func init() {
+	Register(&alpineImage{})
+	Register(&fedoraCoreOSImage{})
+}
+
+// Get returns a registered image.  Boot2Docker isn't, it's handled by
+// mcnutils.B2dUtils.
+func Get(name string) (Image, error) {
+	image, ok := images[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown OS image %q", name)
+	}
+	return image, nil
+}
+
+// IsBoot2Docker reports whether the machines are booted from Boot2Docker, the
+// image of the drivers when none is given.
+func IsBoot2Docker(name string) bool {
+	return name == "" || name == Boot2Docker
+}
+
+// driverImages are the images each local driver can boot its machines from.
+var driverImages = map[string][]string{
+	"virtualbox":   {Boot2Docker, Alpine, FedoraCoreOS},
+	"vmwarefusion": {Boot2Docker, Alpine, FedoraCoreOS},
+	"hyperv":       {Boot2Docker, Alpine, FedoraCoreOS},
+	"vzmac":        {Alpine, FedoraCoreOS},
+}
+
+// ValidateForDriver checks that a driver can boot its machines from an image.
+func ValidateForDriver(driverName, name string) error {
+	supported := driverImages[driverName]
+	for _, image := range supported {
+		if image == name {
+			return nil
+		}
+	}
+
+	sorted := append([]string{}, supported...)
+	sort.Strings(sorted)
+	return fmt.Errorf("The %s driver can't boot its machines from the %q OS image, use one of %s", driverName, name, strings.Join(sorted, ", "))
+}
+
+// SSHUser returns the user the machines booted from an image are accessed
+// with.
+func SSHUser(name string) string {
+	if image, err := Get(name); err == nil {
+		return image.SSHUser()
+	}
+	return "docker"
+}
+
+// ConfiguredFromISO reports whether the machines booted from an image are
+// configured from their ISO, rather than as the Boot2Docker ones.
+func ConfiguredFromISO(name string) bool {
+	image, err := Get(name)
+	if err != nil {
+		return false
+	}
+
+	_, ok := image.(Personalizer)
+	return ok
+}
+
+// UpdateCache downloads the latest release of an image to the cache of a
+// storage path, for the architecture of the local machines.
+func UpdateCache(storePath, name string) error {
+	image, err := Get(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = NewCache(storePath).Update(image, runtime.GOARCH)
+	return err
+}
+
+// CopyToMachineDir copies the latest release of an image to the directory of
+// a machine, for the architecture of the local machines.
+func CopyToMachineDir(storePath, name, machineName string) error {
+	image, err := Get(name)
+	if err != nil {
+		return err
+	}
+
+	return NewCache(storePath).CopyToMachineDir(image, runtime.GOARCH, machineName)
+}
+
+// Personalize configures the ISO of a machine for the SSH key, if its image
+// is configured from its ISO.
+func Personalize(name, isoPath, publicKeyPath string) error {
+	image, err := Get(name)
+	if err != nil {
+		return err
+	}
+
+	if personalizer, ok := image.(Personalizer); ok {
+		return personalizer.Personalize(isoPath, publicKeyPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package osimage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeImage struct {
+	release *Release
+	err     error
+}
+
+func (i *fakeImage) Name() string {
+	return "fake"
+}
+
+func (i *fakeImage) SSHUser() string {
+	return "fake"
+}
+
+func (i *fakeImage) Latest(arch string) (*Release, error) {
+	return i.release, i.err
+}
+
+func sha256Of(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestValidateForDriver(t *testing.T) {
+	assert.NoError(t, ValidateForDriver("virtualbox", Alpine))
+	assert.NoError(t, ValidateForDriver("hyperv", FedoraCoreOS))
+	assert.NoError(t, ValidateForDriver("vmwarefusion", Boot2Docker))
+
+	assert.EqualError(t, ValidateForDriver("virtualbox", "ubuntu"), `The virtualbox driver can't boot its machines from the "ubuntu" OS image, use one of alpine, boot2docker, fcos`)
+	assert.Error(t, ValidateForDriver("kvm", Alpine))
+}
+
+func TestSSHUser(t *testing.T) {
+	assert.Equal(t, "docker", SSHUser(Boot2Docker))
+	assert.Equal(t, "docker", SSHUser(Alpine))
+	assert.Equal(t, "core", SSHUser(FedoraCoreOS))
+}
+
+func TestConfiguredFromISO(t *testing.T) {
+	assert.False(t, ConfiguredFromISO(Boot2Docker))
+	assert.False(t, ConfiguredFromISO(Alpine))
+	assert.True(t, ConfiguredFromISO(FedoraCoreOS))
+}
+
+func TestCacheUpdate(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		fmt.Fprint(w, "iso content")
+	}))
+	defer server.Close()
+
+	storePath, _ := ioutil.TempDir("", "osimage")
+	defer os.RemoveAll(storePath)
+
+	image := &fakeImage{release: &Release{Version: "v1", URL: server.URL + "/fake.iso", SHA256: sha256Of("iso content")}}
+	cache := NewCache(storePath)
+
+	isoPath, err := cache.Update(image, "amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(storePath, "cache", "fake-amd64.iso"), isoPath)
+
+	content, _ := ioutil.ReadFile(isoPath)
+	assert.Equal(t, "iso content", string(content))
+
+	// The cached release isn't downloaded again
+	_, err = cache.Update(image, "amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, downloads)
+
+	// The cached release is kept when the latest one can't be found
+	image.err = errors.New("no network")
+	_, err = cache.Update(image, "amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, downloads)
+}
+
+func TestCacheUpdateChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "tampered content")
+	}))
+	defer server.Close()
+
+	storePath, _ := ioutil.TempDir("", "osimage")
+	defer os.RemoveAll(storePath)
+
+	image := &fakeImage{release: &Release{Version: "v1", URL: server.URL + "/fake.iso", SHA256: sha256Of("iso content")}}
+	_, err := NewCache(storePath).Update(image, "amd64")
+
+	assert.Error(t, err)
+	_, statErr := os.Stat(filepath.Join(storePath, "cache", "fake-amd64.iso"))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestCacheUpdateWithoutChecksum(t *testing.T) {
+	storePath, _ := ioutil.TempDir("", "osimage")
+	defer os.RemoveAll(storePath)
+
+	image := &fakeImage{release: &Release{Version: "v1", URL: "http://localhost/fake.iso"}}
+	_, err := NewCache(storePath).Update(image, "amd64")
+
+	assert.EqualError(t, err, "Error downloading the fake OS image: the release v1 has no checksum to verify")
+}
+
+func TestAlpineLatest(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases/latest":
+			fmt.Fprintf(w, `{"tag_name": "v3.20.1", "assets": [
+				{"name": "machine-alpine-amd64.iso", "browser_download_url": "%[1]s/machine-alpine-amd64.iso"},
+				{"name": "machine-alpine-amd64.iso.asc", "browser_download_url": "%[1]s/machine-alpine-amd64.iso.asc"},
+				{"name": "SHA256SUMS", "browser_download_url": "%[1]s/SHA256SUMS"}
+			]}`, server.URL)
+		case "/SHA256SUMS":
+			fmt.Fprint(w, "abc123  machine-alpine-amd64.iso\ndef456  machine-alpine-arm64.iso\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	savedURL := alpineReleasesURL
+	alpineReleasesURL = server.URL + "/releases/latest"
+	defer func() { alpineReleasesURL = savedURL }()
+
+	release, err := (&alpineImage{}).Latest("amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, &Release{
+		Version:      "v3.20.1",
+		URL:          server.URL + "/machine-alpine-amd64.iso",
+		SHA256:       "abc123",
+		SignatureURL: server.URL + "/machine-alpine-amd64.iso.asc",
+	}, release)
+
+	_, err = (&alpineImage{}).Latest("arm64")
+	assert.Error(t, err)
+}
+
+func TestFedoraCoreOSLatest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"architectures": {"x86_64": {"artifacts": {"metal": {"release": "40.20240616.3.0", "formats": {"iso": {"disk": {
+			"location": "https://builds.example.com/fcos-live.x86_64.iso",
+			"signature": "https://builds.example.com/fcos-live.x86_64.iso.sig",
+			"sha256": "abc123"
+		}}}}}}}}`)
+	}))
+	defer server.Close()
+
+	savedURL := fedoraCoreOSStreamURL
+	fedoraCoreOSStreamURL = server.URL
+	defer func() { fedoraCoreOSStreamURL = savedURL }()
+
+	release, err := (&fedoraCoreOSImage{}).Latest("amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, &Release{
+		Version:      "40.20240616.3.0",
+		URL:          "https://builds.example.com/fcos-live.x86_64.iso",
+		SHA256:       "abc123",
+		SignatureURL: "https://builds.example.com/fcos-live.x86_64.iso.sig",
+	}, release)
+
+	_, err = (&fedoraCoreOSImage{}).Latest("arm64")
+	assert.Error(t, err)
+
+	_, err = (&fedoraCoreOSImage{}).Latest("arm")
+	assert.Error(t, err)
+}
+
+func TestChecksumOf(t *testing.T) {
+	sums := "abc123  machine-alpine-amd64.iso\ndef456 *machine-alpine-arm64.iso\n"
+
+	assert.Equal(t, "abc123", checksumOf(sums, "machine-alpine-amd64.iso"))
+	assert.Equal(t, "def456", checksumOf(sums, "machine-alpine-arm64.iso"))
+	assert.Empty(t, checksumOf(sums, "machine-alpine-arm.iso"))
+}
@@ -0,0 +1,108 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+func init() {
+	RegisterStore("consul", newConsulStore)
+}
+
+// consulKV uses the KV store of Consul, e.g.
+// consul://127.0.0.1:8500/docker-machine?tls=true.  The ACL token is read from
+// CONSUL_HTTP_TOKEN like the Consul CLI does.
+type consulKV struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func newConsulStore(u *url.URL, local *Filestore) (Store, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("The Consul storage URI requires a host, e.g. consul://127.0.0.1:8500/docker-machine")
+	}
+
+	scheme := "http"
+	if u.Query().Get("tls") == "true" {
+		scheme = "https"
+	}
+
+	kv := &consulKV{
+		endpoint: fmt.Sprintf("%s://%s/v1/kv/", scheme, u.Host),
+		token:    os.Getenv("CONSUL_HTTP_TOKEN"),
+		client:   &http.Client{},
+	}
+
+	return NewKVStore(kv, u.Path, local), nil
+}
+
+func (kv *consulKV) do(method, key, query string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, kv.endpoint+key+query, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if kv.token != "" {
+		req.Header.Set("X-Consul-Token", kv.token)
+	}
+
+	resp, err := kv.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrKeyNotFound
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Consul error (%d): %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+
+	return respBody, nil
+}
+
+func (kv *consulKV) Get(key string) ([]byte, error) {
+	return kv.do("GET", key, "?raw", nil)
+}
+
+func (kv *consulKV) Put(key string, value []byte) error {
+	_, err := kv.do("PUT", key, "", value)
+	return err
+}
+
+func (kv *consulKV) Keys(prefix string) ([]string, error) {
+	data, err := kv.do("GET", prefix, "?keys", nil)
+	if err == ErrKeyNotFound {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (kv *consulKV) DeleteTree(prefix string) error {
+	_, err := kv.do("DELETE", prefix, "?recurse", nil)
+	if err == ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,6 +13,16 @@ import (
 	"github.com/docker/machine/libmachine/mcnerror"
 )
 
+func init() {
+	// The filestore is the default backend, its path is the storage path
+	RegisterStore("file", func(u *url.URL, local *Filestore) (Store, error) {
+		if u.Path != "" && filepath.Clean(u.Path) != filepath.Clean(local.Path) {
+			return nil, fmt.Errorf("The path of a file storage URI must be the storage path %q, use --storage-path to change it", local.Path)
+		}
+		return local, nil
+	})
+}
+
 type Filestore struct {
 	Path             string
 	CaCertPath       string
@@ -0,0 +1,162 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ErrGroupsNotSupported is returned for a store which can't keep groups of
+// machines.
+var ErrGroupsNotSupported = errors.New("Error: the storage backend doesn't support groups of machines")
+
+// Groups are the names of the machines of each named group.
+type Groups map[string][]string
+
+// GroupStore is implemented by the stores which keep groups of machines
+// along with the machines.
+type GroupStore interface {
+	// LoadGroups returns the groups, none if they were never saved
+	LoadGroups() (Groups, error)
+
+	// SaveGroups replaces the groups
+	SaveGroups(groups Groups) error
+}
+
+// Add adds machines to a group, once each, creating the group if needed.
+func (g Groups) Add(group string, machines ...string) {
+	members := g[group]
+	if members == nil {
+		members = []string{}
+	}
+
+	for _, machine := range machines {
+		if !g.Has(group, machine) {
+			members = append(members, machine)
+			g[group] = members
+		}
+	}
+
+	g[group] = members
+}
+
+// Has returns true if the machine is in the group.
+func (g Groups) Has(group, machine string) bool {
+	for _, member := range g[group] {
+		if member == machine {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes a machine from a group.
+func (g Groups) Remove(group, machine string) {
+	members := []string{}
+	for _, member := range g[group] {
+		if member != machine {
+			members = append(members, member)
+		}
+	}
+	g[group] = members
+}
+
+// RemoveMachine removes a machine from all the groups, and returns true if
+// it was in any.
+func (g Groups) RemoveMachine(machine string) bool {
+	removed := false
+	for group := range g {
+		if g.Has(group, machine) {
+			g.Remove(group, machine)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// RenameMachine renames a machine in all the groups, and returns true if it
+// was in any.
+func (g Groups) RenameMachine(oldName, name string) bool {
+	renamed := false
+	for _, members := range g {
+		for i, member := range members {
+			if member == oldName {
+				members[i] = name
+				renamed = true
+			}
+		}
+	}
+	return renamed
+}
+
+// Names returns the sorted names of the groups.
+func (g Groups) Names() []string {
+	names := []string{}
+	for name := range g {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s Filestore) groupsPath() string {
+	return filepath.Join(s.Path, "groups.json")
+}
+
+func (s Filestore) LoadGroups() (Groups, error) {
+	data, err := ioutil.ReadFile(s.groupsPath())
+	if os.IsNotExist(err) {
+		return Groups{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalGroups(data)
+}
+
+func (s Filestore) SaveGroups(groups Groups) error {
+	data, err := json.MarshalIndent(groups, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return err
+	}
+
+	return s.saveToFile(data, s.groupsPath())
+}
+
+func (s *KVStore) LoadGroups() (Groups, error) {
+	data, err := s.KV.Get(s.key("groups.json"))
+	if err == ErrKeyNotFound {
+		return Groups{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalGroups(data)
+}
+
+func (s *KVStore) SaveGroups(groups Groups) error {
+	data, err := json.MarshalIndent(groups, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return s.KV.Put(s.key("groups.json"), data)
+}
+
+func unmarshalGroups(data []byte) (Groups, error) {
+	groups := Groups{}
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("Error reading the groups of machines: %s", err)
+	}
+	return groups, nil
+}
@@ -0,0 +1,57 @@
+package persist
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroups(t *testing.T) {
+	groups := Groups{}
+
+	groups.Add("web", "web-1", "web-2", "web-1")
+	groups.Add("db", "db-1", "web-2")
+	assert.Equal(t, []string{"web-1", "web-2"}, groups["web"])
+	assert.Equal(t, []string{"db", "web"}, groups.Names())
+
+	groups.Remove("web", "web-1")
+	assert.Equal(t, []string{"web-2"}, groups["web"])
+
+	assert.True(t, groups.RenameMachine("web-2", "web-3"))
+	assert.Equal(t, Groups{"web": {"web-3"}, "db": {"db-1", "web-3"}}, groups)
+
+	assert.True(t, groups.RemoveMachine("web-3"))
+	assert.False(t, groups.RemoveMachine("web-3"))
+	assert.Equal(t, Groups{"web": {}, "db": {"db-1"}}, groups)
+}
+
+func TestFilestoreGroups(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+
+	groups, err := store.LoadGroups()
+	assert.NoError(t, err)
+	assert.Empty(t, groups)
+
+	assert.NoError(t, store.SaveGroups(Groups{"web": {"web-1", "web-2"}}))
+	assert.NoError(t, store.SaveGroups(Groups{"web": {"web-1"}, "empty": {}}))
+
+	groups, err = store.LoadGroups()
+	assert.NoError(t, err)
+	assert.Equal(t, Groups{"web": {"web-1"}, "empty": {}}, groups)
+}
+
+func TestKVStoreGroups(t *testing.T) {
+	kv := &memoryKV{data: map[string][]byte{}}
+
+	store := getTestKVStore(t, kv)
+	defer os.RemoveAll(store.Local.Path)
+
+	assert.NoError(t, store.SaveGroups(Groups{"web": {"web-1"}}))
+	assert.Contains(t, kv.data, "team/groups.json")
+
+	groups, err := store.LoadGroups()
+	assert.NoError(t, err)
+	assert.Equal(t, Groups{"web": {"web-1"}}, groups)
+}
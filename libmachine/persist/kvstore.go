@@ -0,0 +1,264 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+const (
+	// kvMaxFileSize is the size of the largest file of a machine directory
+	// kept in a key/value store, disk images stay local.
+	kvMaxFileSize = 1024 * 1024
+
+	// The storage path differs between the clients sharing a store, so it
+	// is replaced with a placeholder in the stored configurations.
+	storePathPlaceholder = "{{MACHINE_STORAGE_PATH}}"
+)
+
+// ErrKeyNotFound is returned by a KV backend for a key which does not exist.
+var ErrKeyNotFound = errors.New("Key not found")
+
+// KV is a key/value backend the machines can be stored in.
+type KV interface {
+	// Get returns the value of a key, or ErrKeyNotFound
+	Get(key string) ([]byte, error)
+
+	// Put sets the value of a key
+	Put(key string, value []byte) error
+
+	// Keys returns all the keys starting with prefix
+	Keys(prefix string) ([]string, error)
+
+	// DeleteTree removes all the keys starting with prefix
+	DeleteTree(prefix string) error
+}
+
+// KVStore stores the machines in a key/value backend.  The configuration of
+// each machine is stored along with the small files of its directory, such as
+// the SSH keys, and with the certificates.  These files are mirrored in the
+// local filestore since the drivers and the Docker client need them on disk.
+type KVStore struct {
+	KV     KV
+	Prefix string
+	Local  *Filestore
+}
+
+func NewKVStore(kv KV, prefix string, local *Filestore) *KVStore {
+	return &KVStore{
+		KV:     kv,
+		Prefix: strings.Trim(prefix, "/"),
+		Local:  local,
+	}
+}
+
+func (s *KVStore) key(parts ...string) string {
+	return path.Join(append([]string{s.Prefix}, parts...)...)
+}
+
+func (s *KVStore) configKey(name string) string {
+	return s.key("machines", name, "config.json")
+}
+
+func (s *KVStore) certsDir() string {
+	return filepath.Join(s.Local.Path, "certs")
+}
+
+// escapedStorePath returns the local storage path as it appears in JSON.
+func (s *KVStore) escapedStorePath() string {
+	escaped, _ := json.Marshal(s.Local.Path)
+	return strings.Trim(string(escaped), `"`)
+}
+
+func (s *KVStore) Save(host *host.Host) error {
+	if err := s.Local.Save(host); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(host, "", "    ")
+	if err != nil {
+		return err
+	}
+	data = []byte(strings.Replace(string(data), s.escapedStorePath(), storePathPlaceholder, -1))
+
+	if err := s.pushFiles(filepath.Join(s.Local.GetMachinesDir(), host.Name), s.key("machines", host.Name), true); err != nil {
+		return err
+	}
+
+	// The first client to save a machine provides the certificates all the
+	// others have to use.
+	if err := s.pushFiles(s.certsDir(), s.key("certs"), false); err != nil {
+		return err
+	}
+
+	return s.KV.Put(s.configKey(host.Name), data)
+}
+
+func (s *KVStore) Remove(name string) error {
+	if err := s.KV.DeleteTree(s.key("machines", name) + "/"); err != nil {
+		return err
+	}
+
+	return s.Local.Remove(name)
+}
+
+func (s *KVStore) List() ([]string, error) {
+	prefix := s.key("machines") + "/"
+
+	keys, err := s.KV.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	hostNames := []string{}
+
+	for _, key := range keys {
+		parts := strings.Split(strings.TrimPrefix(key, prefix), "/")
+		if len(parts) == 2 && parts[1] == "config.json" {
+			hostNames = append(hostNames, parts[0])
+		}
+	}
+
+	return hostNames, nil
+}
+
+func (s *KVStore) Exists(name string) (bool, error) {
+	_, err := s.KV.Get(s.configKey(name))
+
+	if err == ErrKeyNotFound {
+		return false, nil
+	} else if err == nil {
+		return true, nil
+	}
+
+	return false, err
+}
+
+func (s *KVStore) Load(name string) (*host.Host, error) {
+	data, err := s.KV.Get(s.configKey(name))
+	if err == ErrKeyNotFound {
+		return nil, mcnerror.ErrHostDoesNotExist{
+			Name: name,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	data = []byte(strings.Replace(string(data), storePathPlaceholder, s.escapedStorePath(), -1))
+
+	if err := s.pullFiles(s.key("machines", name), filepath.Join(s.Local.GetMachinesDir(), name)); err != nil {
+		return nil, err
+	}
+
+	if err := s.pullFiles(s.key("certs"), s.certsDir()); err != nil {
+		return nil, err
+	}
+
+	h := &host.Host{
+		Name: name,
+	}
+
+	migratedHost, migrationPerformed, err := host.MigrateHost(h, data)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting migrated host: %s", err)
+	}
+
+	*h = *migratedHost
+
+	h.Name = name
+
+	if migrationPerformed {
+		if err := s.Save(h); err != nil {
+			return nil, fmt.Errorf("Error saving config after migration was performed: %s", err)
+		}
+	} else if err := s.Local.Save(h); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// pushFiles stores the files of a local directory under a prefix.  Existing
+// keys are only replaced if overwrite is true.
+func (s *KVStore) pushFiles(dir, prefix string, overwrite bool) error {
+	return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if info.IsDir() || info.Size() > kvMaxFileSize {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		if rel == "config.json" || strings.HasPrefix(rel, "config.json.") {
+			return nil
+		}
+
+		key := path.Join(prefix, filepath.ToSlash(rel))
+
+		if !overwrite {
+			if _, err := s.KV.Get(key); err == nil {
+				return nil
+			} else if err != ErrKeyNotFound {
+				return err
+			}
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		return s.KV.Put(key, data)
+	})
+}
+
+// pullFiles writes the files stored under a prefix which are missing from a
+// local directory.
+func (s *KVStore) pullFiles(prefix, dir string) error {
+	keys, err := s.KV.Keys(prefix + "/")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		rel := strings.TrimPrefix(key, prefix+"/")
+		if rel == "config.json" {
+			continue
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(rel))
+		if _, err := os.Stat(file); err == nil {
+			continue
+		}
+
+		data, err := s.KV.Get(key)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(file, data, 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
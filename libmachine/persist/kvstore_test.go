@@ -0,0 +1,189 @@
+package persist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryKV struct {
+	data map[string][]byte
+}
+
+func (kv *memoryKV) Get(key string) ([]byte, error) {
+	value, ok := kv.data[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return value, nil
+}
+
+func (kv *memoryKV) Put(key string, value []byte) error {
+	kv.data[key] = value
+	return nil
+}
+
+func (kv *memoryKV) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	for key := range kv.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (kv *memoryKV) DeleteTree(prefix string) error {
+	for key := range kv.data {
+		if strings.HasPrefix(key, prefix) {
+			delete(kv.data, key)
+		}
+	}
+	return nil
+}
+
+func getTestKVStore(t *testing.T, kv *memoryKV) *KVStore {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewKVStore(kv, "/team/", NewFilestore(tmpDir, "", ""))
+}
+
+func TestKVStoreSaveLoad(t *testing.T) {
+	kv := &memoryKV{data: map[string][]byte{}}
+
+	store := getTestKVStore(t, kv)
+	defer os.RemoveAll(store.Local.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.HostOptions.AuthOptions.CaCertPath = filepath.Join(store.Local.Path, "certs", "ca.pem")
+
+	machineDir := filepath.Join(store.Local.GetMachinesDir(), h.Name)
+	assert.NoError(t, os.MkdirAll(machineDir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(machineDir, "id_rsa"), []byte("key"), 0600))
+	assert.NoError(t, os.MkdirAll(filepath.Join(store.Local.Path, "certs"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(store.Local.Path, "certs", "ca.pem"), []byte("ca"), 0600))
+
+	assert.NoError(t, store.Save(h))
+
+	assert.Equal(t, []byte("key"), kv.data["team/machines/test-host/id_rsa"])
+	assert.Equal(t, []byte("ca"), kv.data["team/certs/ca.pem"])
+	assert.Contains(t, string(kv.data["team/machines/test-host/config.json"]), storePathPlaceholder+"/certs/ca.pem")
+	assert.NotContains(t, string(kv.data["team/machines/test-host/config.json"]), store.Local.Path)
+
+	// Another client sharing the store gets the machine files
+	other := getTestKVStore(t, kv)
+	defer os.RemoveAll(other.Local.Path)
+
+	exists, err := other.Exists(h.Name)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	names, err := other.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{h.Name}, names)
+
+	loaded, err := other.Load(h.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, h.Name, loaded.Name)
+	assert.Equal(t, filepath.Join(other.Local.Path, "certs", "ca.pem"), loaded.HostOptions.AuthOptions.CaCertPath)
+
+	key, err := ioutil.ReadFile(filepath.Join(other.Local.GetMachinesDir(), h.Name, "id_rsa"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("key"), key)
+
+	ca, err := ioutil.ReadFile(filepath.Join(other.Local.Path, "certs", "ca.pem"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ca"), ca)
+}
+
+func TestKVStoreKeepsSharedCerts(t *testing.T) {
+	kv := &memoryKV{data: map[string][]byte{
+		"team/certs/ca.pem": []byte("shared"),
+	}}
+
+	store := getTestKVStore(t, kv)
+	defer os.RemoveAll(store.Local.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(store.Local.Path, "certs"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(store.Local.Path, "certs", "ca.pem"), []byte("local"), 0600))
+
+	assert.NoError(t, store.Save(h))
+
+	assert.Equal(t, []byte("shared"), kv.data["team/certs/ca.pem"])
+}
+
+func TestKVStoreRemove(t *testing.T) {
+	kv := &memoryKV{data: map[string][]byte{
+		"team/machines/test-host/config.json":   []byte("{}"),
+		"team/machines/test-host/id_rsa":        []byte("key"),
+		"team/machines/test-host-2/config.json": []byte("{}"),
+	}}
+
+	store := getTestKVStore(t, kv)
+	defer os.RemoveAll(store.Local.Path)
+
+	assert.NoError(t, store.Remove("test-host"))
+
+	names, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test-host-2"}, names)
+}
+
+func TestKVStoreLoadMissing(t *testing.T) {
+	store := getTestKVStore(t, &memoryKV{data: map[string][]byte{}})
+	defer os.RemoveAll(store.Local.Path)
+
+	_, err := store.Load("missing")
+	assert.Equal(t, mcnerror.ErrHostDoesNotExist{Name: "missing"}, err)
+
+	exists, err := store.Exists("missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestNewStore(t *testing.T) {
+	local := NewFilestore("/machine", "", "")
+
+	store, err := NewStore("", local)
+	assert.NoError(t, err)
+	assert.Equal(t, local, store)
+
+	store, err = NewStore("file:///machine", local)
+	assert.NoError(t, err)
+	assert.Equal(t, local, store)
+
+	_, err = NewStore("file:///elsewhere", local)
+	assert.Error(t, err)
+
+	store, err = NewStore("consul://127.0.0.1:8500/docker-machine", local)
+	assert.NoError(t, err)
+	assert.Equal(t, "docker-machine", store.(*KVStore).Prefix)
+
+	_, err = NewStore("zookeeper://127.0.0.1", local)
+	assert.EqualError(t, err, `Unknown storage backend "zookeeper", expected one of: consul, etcd, file, s3`)
+}
+
+func TestPrefixRangeEnd(t *testing.T) {
+	assert.Equal(t, []byte("machinf"), prefixRangeEnd("machine"))
+	assert.Equal(t, []byte("b"), prefixRangeEnd("a\xff"))
+	assert.Equal(t, []byte{0}, prefixRangeEnd("\xff"))
+}
@@ -0,0 +1,185 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/private/signer/v4"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+)
+
+func init() {
+	RegisterStore("s3", newS3Store)
+}
+
+// s3KV uses an S3 bucket, e.g. s3://bucket/docker-machine?region=eu-west-1.
+// An endpoint can be given to use an S3 compatible service such as Minio:
+// s3://bucket/docker-machine?endpoint=http://127.0.0.1:9000.  The credentials
+// are read from the environment or the shared credentials file like the AWS
+// CLI does.
+type s3KV struct {
+	bucketURL   string
+	region      string
+	credentials *credentials.Credentials
+	client      *http.Client
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func newS3Store(u *url.URL, local *Filestore) (Store, error) {
+	bucket := u.Host
+	if bucket == "" {
+		return nil, fmt.Errorf("The S3 storage URI requires a bucket, e.g. s3://bucket/docker-machine")
+	}
+
+	region := u.Query().Get("region")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	// Custom endpoints use path-style URLs, which work without DNS set up
+	// for the buckets.
+	bucketURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		bucketURL = strings.TrimSuffix(endpoint, "/") + "/" + bucket
+	}
+
+	kv := &s3KV{
+		bucketURL: bucketURL,
+		region:    region,
+		credentials: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvProvider{},
+			&credentials.SharedCredentialsProvider{},
+		}),
+		client: &http.Client{},
+	}
+
+	return NewKVStore(kv, u.Path, local), nil
+}
+
+func (kv *s3KV) do(method, key string, query url.Values, body []byte) ([]byte, error) {
+	httpReq, err := http.NewRequest(method, kv.bucketURL+"/"+key, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.URL.RawQuery = query.Encode()
+
+	// Only the signature of the SDK request is used, it is sent as is.
+	req := &request.Request{
+		Config: aws.Config{
+			Credentials: kv.credentials,
+			Region:      aws.String(kv.region),
+		},
+		ClientInfo: metadata.ClientInfo{
+			ServiceName:   "s3",
+			SigningRegion: kv.region,
+		},
+		HTTPRequest: httpReq,
+		Time:        time.Now(),
+	}
+	req.SetBufferBody(body)
+
+	v4.Sign(req)
+	if req.Error != nil {
+		return nil, fmt.Errorf("Error signing S3 request: %s", req.Error)
+	}
+
+	resp, err := kv.client.Do(req.HTTPRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, ErrKeyNotFound
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("S3 error (%d): %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+
+	return respBody, nil
+}
+
+func (kv *s3KV) Get(key string) ([]byte, error) {
+	return kv.do("GET", key, url.Values{}, nil)
+}
+
+func (kv *s3KV) Put(key string, value []byte) error {
+	_, err := kv.do("PUT", key, url.Values{}, value)
+	return err
+}
+
+func (kv *s3KV) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix":    {prefix},
+	}
+
+	for {
+		data, err := kv.do("GET", "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListBucketResult
+		if err := xml.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+
+		if !result.IsTruncated {
+			return keys, nil
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (kv *s3KV) DeleteTree(prefix string) error {
+	keys, err := kv.Keys(prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if _, err := kv.do("DELETE", key, url.Values{}, nil); err != nil && err != ErrKeyNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,11 @@
 package persist
 
 import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
 	"github.com/docker/machine/libmachine/host"
 )
 
@@ -21,6 +26,43 @@ type Store interface {
 	Save(host *host.Host) error
 }
 
+// StoreFactory creates the store for a storage URI.  The local filestore keeps
+// the files, such as the SSH keys, which have to be on disk to use a machine.
+type StoreFactory func(u *url.URL, local *Filestore) (Store, error)
+
+var storeFactories = map[string]StoreFactory{}
+
+// RegisterStore makes a storage backend available for the URIs with the
+// given scheme.
+func RegisterStore(scheme string, factory StoreFactory) {
+	storeFactories[scheme] = factory
+}
+
+// NewStore returns the store selected by a storage URI such as
+// s3://bucket/prefix, or the local filestore if the URI is empty.
+func NewStore(uri string, local *Filestore) (Store, error) {
+	if uri == "" {
+		return local, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing storage URI %q: %s", uri, err)
+	}
+
+	factory, ok := storeFactories[u.Scheme]
+	if !ok {
+		schemes := []string{}
+		for scheme := range storeFactories {
+			schemes = append(schemes, scheme)
+		}
+		sort.Strings(schemes)
+		return nil, fmt.Errorf("Unknown storage backend %q, expected one of: %s", u.Scheme, strings.Join(schemes, ", "))
+	}
+
+	return factory(u, local)
+}
+
 func LoadHosts(s Store, hostNames []string) ([]*host.Host, map[string]error) {
 	loadedHosts := []*host.Host{}
 	errors := map[string]error{}
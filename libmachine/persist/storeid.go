@@ -0,0 +1,73 @@
+package persist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrStoreIDNotSupported is returned for a store which has no identity.
+var ErrStoreIDNotSupported = errors.New("Error: the storage backend has no identity to tell its resources apart")
+
+// IdentifiedStore is implemented by the stores which have an identity.  The
+// drivers tag the resources they create with it, so that the resources of the
+// machines of another store, e.g. of a teammate or of another profile, are
+// told apart from the ones of the machines removed from this store.
+type IdentifiedStore interface {
+	// StoreID returns the identity of the store, created on first use
+	StoreID() (string, error)
+}
+
+func newStoreID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func (s Filestore) storeIDPath() string {
+	return filepath.Join(s.Path, "store-id")
+}
+
+func (s Filestore) StoreID() (string, error) {
+	data, err := ioutil.ReadFile(s.storeIDPath())
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	id, err := newStoreID()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return "", err
+	}
+
+	return id, s.saveToFile([]byte(id+"\n"), s.storeIDPath())
+}
+
+func (s *KVStore) StoreID() (string, error) {
+	data, err := s.KV.Get(s.key("store-id"))
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != ErrKeyNotFound {
+		return "", err
+	}
+
+	id, err := newStoreID()
+	if err != nil {
+		return "", err
+	}
+
+	return id, s.KV.Put(s.key("store-id"), []byte(id+"\n"))
+}
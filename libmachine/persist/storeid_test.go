@@ -0,0 +1,40 @@
+package persist
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilestoreStoreID(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+
+	id, err := store.StoreID()
+	assert.NoError(t, err)
+	assert.Len(t, id, 32)
+
+	again, err := store.StoreID()
+	assert.NoError(t, err)
+	assert.Equal(t, id, again)
+}
+
+func TestKVStoreStoreID(t *testing.T) {
+	kv := &memoryKV{data: map[string][]byte{}}
+
+	store := getTestKVStore(t, kv)
+	defer os.RemoveAll(store.Local.Path)
+
+	id, err := store.StoreID()
+	assert.NoError(t, err)
+	assert.Equal(t, id+"\n", string(kv.data["team/store-id"]))
+
+	// Another client of the shared store has the same identity
+	other := getTestKVStore(t, kv)
+	defer os.RemoveAll(other.Local.Path)
+
+	again, err := other.StoreID()
+	assert.NoError(t, err)
+	assert.Equal(t, id, again)
+}
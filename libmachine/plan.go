@@ -0,0 +1,182 @@
+package libmachine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+// PlanStep is a step the creation of a machine would run, in the phase of
+// the logs of the creation.
+type PlanStep struct {
+	Phase       string
+	Description string
+}
+
+// PlanCreate checks that a machine can be created, without creating
+// anything, and returns the steps its creation would run.  The driver runs
+// its pre-create checks, and plans its own steps if it can check them
+// against the provider.  The provisioning steps depend on the operating
+// system of the machine, which is only known once it's created.
+func PlanCreate(h *host.Host) ([]PlanStep, error) {
+	if err := h.Driver.PreCreateCheck(); err != nil {
+		return nil, mcnerror.ErrDuringPreCreate{
+			Cause: err,
+		}
+	}
+
+	plan := []PlanStep{{"pre-create", fmt.Sprintf("Run the pre-create checks of the %s driver", h.DriverName)}}
+
+	driverSteps, err := drivers.PlanCreate(h.Driver)
+	switch err {
+	case nil:
+	case drivers.ErrPlanNotSupported:
+		driverSteps = []string{fmt.Sprintf("Create the machine with the %s driver", h.DriverName)}
+	default:
+		return nil, mcnerror.ErrDuringPreCreate{
+			Cause: err,
+		}
+	}
+
+	for _, step := range driverSteps {
+		plan = append(plan, PlanStep{"create", step})
+	}
+
+	if h.DriverName == "none" {
+		return plan, nil
+	}
+
+	plan = append(plan, PlanStep{"create", "Wait for the machine to be running"})
+
+	for _, step := range provisionSteps(h.HostOptions) {
+		plan = append(plan, PlanStep{"provision", step})
+	}
+
+	return plan, nil
+}
+
+// provisionSteps describes the provisioning of a machine with the options.
+func provisionSteps(options *host.Options) []string {
+	engineOptions := options.EngineOptions
+
+	if options.WinRMOptions != nil {
+		return []string{
+			"Detect the operating system of the machine over WinRM",
+			"Install the Docker engine and configure its TLS certificates",
+			"Check the connection to the engine",
+		}
+	}
+
+	steps := []string{"Detect the operating system of the machine"}
+	if options.Provisioner != "" {
+		steps = []string{fmt.Sprintf("Provision the machine with the %s provisioner", options.Provisioner)}
+	}
+
+	switch {
+	case engineOptions.IsPodman():
+		steps = append(steps, "Install Podman and expose its API over TLS")
+	case engineOptions.IsContainerd():
+		steps = append(steps, "Install containerd and expose its API over TLS")
+	default:
+		engine := "Install the Docker engine"
+		switch {
+		case engineOptions.InstallBundle != "":
+			engine += " from the bundle " + engineOptions.InstallBundle
+		case engineOptions.InstallURL != "" && engineOptions.InstallURL != drivers.DefaultEngineInstallURL:
+			engine += " with the install script " + engineOptions.InstallURL
+		}
+		if engineOptions.Rootless {
+			engine += ", rootless"
+		}
+		if engineOptions.StorageDriver != "" {
+			engine += ", with the " + engineOptions.StorageDriver + " storage driver"
+		}
+		steps = append(steps, engine)
+	}
+
+	if engineOptions.GPU != "" {
+		steps = append(steps, fmt.Sprintf("Install the %s GPU driver and container runtime", engineOptions.GPU))
+	}
+
+	steps = append(steps, "Generate the server certificate and configure the TLS of the engine")
+
+	if options.SwarmOptions != nil && options.SwarmOptions.IsSwarm {
+		role := "agent"
+		if options.SwarmOptions.Master {
+			role = "master"
+		}
+		steps = append(steps, fmt.Sprintf("Configure the machine as a Swarm %s", role))
+	}
+
+	if len(engineOptions.ProxyEnv()) > 0 {
+		steps = append(steps, "Configure the proxy of the engine")
+	}
+
+	if options.SSHUserOptions.IsEnabled() {
+		if options.SSHUserOptions.User != "" {
+			steps = append(steps, fmt.Sprintf("Create the SSH user %s and access the machine as this user", options.SSHUserOptions.User))
+		}
+		if options.SSHUserOptions.DisableRoot {
+			steps = append(steps, "Disable the root login and the password authentication over SSH")
+		}
+	}
+
+	if cert.SSHCertificatesEnabled() {
+		steps = append(steps, "Install the SSH certificate authority and issue a certificate")
+	}
+
+	if options.DataDiskOptions.IsEncrypted() {
+		steps = append(steps, fmt.Sprintf("Encrypt the data disk %s and mount it on /var/lib/docker", options.DataDiskOptions.Device))
+	}
+
+	if options.FirewallOptions.IsEnabled() {
+		step := fmt.Sprintf("Allow only SSH and the engine in the %s firewall", options.FirewallOptions.Backend)
+		if len(options.FirewallOptions.Ports) > 0 {
+			step = fmt.Sprintf("Allow only SSH, the engine and the ports %s in the %s firewall", strings.Join(options.FirewallOptions.Ports, ", "), options.FirewallOptions.Backend)
+		}
+		steps = append(steps, step)
+	}
+
+	if options.AutoUpdatesOptions.IsEnabled() {
+		step := "Apply the security updates automatically"
+		if options.AutoUpdatesOptions.RebootWindow != "" {
+			step += fmt.Sprintf(", rebooting at %s when they require it", options.AutoUpdatesOptions.RebootWindow)
+		}
+		steps = append(steps, step)
+	}
+
+	steps = append(steps, "Check the connection to the engine")
+
+	if options.K3sOptions.IsK3s() {
+		role := "agent"
+		if options.K3sOptions.Server {
+			role = "server"
+		}
+		steps = append(steps, fmt.Sprintf("Install k3s as a %s", role))
+	}
+
+	if options.KubernetesOptions.IsKubernetes() {
+		if options.KubernetesOptions.IsControlPlane() {
+			steps = append(steps, fmt.Sprintf("Install Kubernetes %s and initialize its control plane with kubeadm", options.KubernetesOptions.Version))
+		} else {
+			steps = append(steps, fmt.Sprintf("Install Kubernetes %s and join the cluster of %s as a worker", options.KubernetesOptions.Version, options.KubernetesOptions.Join))
+		}
+	}
+
+	if options.SwarmModeOptions.IsSwarmMode() {
+		switch {
+		case options.SwarmModeOptions.Join != "" && options.SwarmModeOptions.Manager:
+			steps = append(steps, fmt.Sprintf("Join the swarm of %s as a manager", options.SwarmModeOptions.Join))
+		case options.SwarmModeOptions.Join != "":
+			steps = append(steps, fmt.Sprintf("Join the swarm of %s as a worker", options.SwarmModeOptions.Join))
+		default:
+			steps = append(steps, "Initialize a swarm as its manager")
+		}
+	}
+
+	return steps
+}
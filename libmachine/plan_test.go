@@ -0,0 +1,97 @@
+package libmachine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/autoupdates"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/firewall"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/kubernetes"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/sshuser"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+type planDriver struct {
+	*fakedriver.Driver
+	preCreateErr error
+}
+
+func (d *planDriver) PreCreateCheck() error {
+	return d.preCreateErr
+}
+
+func (d *planDriver) PlanCreate() ([]string, error) {
+	return []string{"Create the instance dev"}, nil
+}
+
+func newPlanHost(d *planDriver) *host.Host {
+	return &host.Host{
+		Name:       "dev",
+		DriverName: "fake",
+		Driver:     d,
+		HostOptions: &host.Options{
+			EngineOptions: &engine.Options{InstallURL: "https://get.docker.com", StorageDriver: "overlay2"},
+			SwarmOptions:  &swarm.Options{},
+			SSHUserOptions: &sshuser.Options{
+				User:        "machine",
+				DisableRoot: true,
+			},
+			FirewallOptions:    &firewall.Options{Backend: firewall.BackendAuto, Ports: []string{"80", "443"}},
+			AutoUpdatesOptions: &autoupdates.Options{RebootWindow: "Sun 03:00"},
+		},
+	}
+}
+
+func TestPlanCreate(t *testing.T) {
+	plan, err := PlanCreate(newPlanHost(&planDriver{Driver: &fakedriver.Driver{}}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []PlanStep{
+		{"pre-create", "Run the pre-create checks of the fake driver"},
+		{"create", "Create the instance dev"},
+		{"create", "Wait for the machine to be running"},
+		{"provision", "Detect the operating system of the machine"},
+		{"provision", "Install the Docker engine, with the overlay2 storage driver"},
+		{"provision", "Generate the server certificate and configure the TLS of the engine"},
+		{"provision", "Create the SSH user machine and access the machine as this user"},
+		{"provision", "Disable the root login and the password authentication over SSH"},
+		{"provision", "Allow only SSH, the engine and the ports 80, 443 in the auto firewall"},
+		{"provision", "Apply the security updates automatically, rebooting at Sun 03:00 when they require it"},
+		{"provision", "Check the connection to the engine"},
+	}, plan)
+}
+
+func TestPlanCreateWithoutDriverPlan(t *testing.T) {
+	h := newPlanHost(nil)
+	h.Driver = &fakedriver.Driver{}
+	h.HostOptions.EngineOptions = &engine.Options{ContainerRuntime: engine.RuntimePodman}
+	h.HostOptions.Provisioner = "Debian"
+
+	plan, err := PlanCreate(h)
+
+	assert.NoError(t, err)
+	assert.Equal(t, PlanStep{"create", "Create the machine with the fake driver"}, plan[1])
+	assert.Equal(t, PlanStep{"provision", "Provision the machine with the Debian provisioner"}, plan[3])
+	assert.Equal(t, PlanStep{"provision", "Install Podman and expose its API over TLS"}, plan[4])
+}
+
+func TestPlanCreateKubernetesWorker(t *testing.T) {
+	h := newPlanHost(&planDriver{Driver: &fakedriver.Driver{}})
+	h.HostOptions.KubernetesOptions = &kubernetes.Options{Role: kubernetes.RoleWorker, Version: "v1.30", Join: "cp"}
+
+	plan, err := PlanCreate(h)
+
+	assert.NoError(t, err)
+	assert.Equal(t, PlanStep{"provision", "Install Kubernetes v1.30 and join the cluster of cp as a worker"}, plan[len(plan)-1])
+}
+
+func TestPlanCreatePreCreateCheckFails(t *testing.T) {
+	_, err := PlanCreate(newPlanHost(&planDriver{Driver: &fakedriver.Driver{}, preCreateErr: errors.New("invalid region")}))
+
+	assert.Equal(t, mcnerror.ErrDuringPreCreate{Cause: errors.New("invalid region")}, err)
+}
@@ -0,0 +1,25 @@
+package provision
+
+import (
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func init() {
+	Register("AlmaLinux", &RegisteredProvisioner{
+		New: NewAlmaLinuxProvisioner,
+	})
+}
+
+func NewAlmaLinuxProvisioner(d drivers.Driver) Provisioner {
+	return &AlmaLinuxProvisioner{
+		NewEnterpriseLinuxProvisioner("almalinux", d),
+	}
+}
+
+type AlmaLinuxProvisioner struct {
+	*EnterpriseLinuxProvisioner
+}
+
+func (provisioner *AlmaLinuxProvisioner) String() string {
+	return "almalinux"
+}
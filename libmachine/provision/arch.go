@@ -92,7 +92,7 @@ func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOp
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
+	swarmOptions.Env = engineOptions.PlainEnv()
 
 	storageDriver, err := decideStorageDriver(provisioner, "overlay", engineOptions.StorageDriver)
 	if err != nil {
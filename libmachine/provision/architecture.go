@@ -0,0 +1,29 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// DetectArchitecture returns the architecture of the machine, named like
+// GOARCH: the one its driver reports if it does, else the one the machine
+// prints with `uname -m`.
+func DetectArchitecture(p Provisioner) (string, error) {
+	arch, err := drivers.GetArchitecture(p.GetDriver())
+	if err == nil && arch != "" {
+		return mcnutils.NormalizeArchitecture(arch), nil
+	}
+	if err != nil && err != drivers.ErrArchitectureNotSupported {
+		log.Debugf("Error getting the architecture from the driver: %s", err)
+	}
+
+	output, err := p.SSHCommand("uname -m")
+	if err != nil {
+		return "", fmt.Errorf("Error detecting the architecture of the machine: %s", err)
+	}
+
+	return mcnutils.NormalizeArchitecture(output), nil
+}
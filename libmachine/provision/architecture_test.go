@@ -0,0 +1,40 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+type archDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *archDriver) GetArchitecture() (string, error) {
+	return "arm64", nil
+}
+
+func TestDetectArchitectureFromDriver(t *testing.T) {
+	commander := provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	p := NewUbuntuSystemdProvisioner(&archDriver{Driver: &fakedriver.Driver{}}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	arch, err := DetectArchitecture(p)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "arm64", arch)
+}
+
+func TestDetectArchitectureFromMachine(t *testing.T) {
+	commander := provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	commander.Responses["uname -m"] = "aarch64\n"
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	arch, err := DetectArchitecture(p)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "arm64", arch)
+}
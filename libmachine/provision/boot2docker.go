@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"path"
+	"strconv"
 	"text/template"
 	"time"
 
@@ -15,12 +16,17 @@ import (
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/osimage"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
 )
 
+// alpineVariantID is the VARIANT_ID of the Alpine Linux based OS image, which
+// keeps the conventions of boot2docker.
+const alpineVariantID = "docker-machine"
+
 func init() {
 	Register("boot2docker", &RegisteredProvisioner{
 		New: NewBoot2DockerProvisioner,
@@ -51,10 +57,15 @@ func (provisioner *Boot2DockerProvisioner) Service(name string, action serviceac
 }
 
 func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
+	arch, err := DetectArchitecture(provisioner)
+	if err != nil {
+		return err
+	}
+
 	// TODO: Ideally, we should not read from mcndirs directory at all.
 	// The driver should be able to communicate how and where to place the
 	// relevant files.
-	b2dutils := mcnutils.NewB2dUtils(mcndirs.GetBaseDir())
+	b2dutils := mcnutils.NewB2dUtilsForArch(mcndirs.GetBaseDir(), arch)
 
 	// Check if the driver has specified a custom b2d url
 	jsonDriver, err := json.Marshal(provisioner.GetDriver())
@@ -63,6 +74,7 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 	}
 	var d struct {
 		Boot2DockerURL string
+		OSImage        string
 	}
 	json.Unmarshal(jsonDriver, &d)
 
@@ -82,8 +94,19 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 
 	// Either download the latest version of the b2d url that was explicitly
 	// specified when creating the VM or copy the (updated) default ISO
-	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, machineName); err != nil {
-		return err
+	if osimage.IsBoot2Docker(d.OSImage) {
+		if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, machineName); err != nil {
+			return err
+		}
+	} else {
+		image, err := osimage.Get(d.OSImage)
+		if err != nil {
+			return err
+		}
+
+		if err := osimage.NewCache(mcndirs.GetBaseDir()).CopyToMachineDir(image, arch, machineName); err != nil {
+			return err
+		}
 	}
 
 	log.Infof("Starting machine back up...")
@@ -132,6 +155,10 @@ func (provisioner *Boot2DockerProvisioner) GetSwarmOptions() swarm.Options {
 	return provisioner.SwarmOptions
 }
 
+func (provisioner *Boot2DockerProvisioner) GetEngineOptions() engine.Options {
+	return provisioner.EngineOptions
+}
+
 func (provisioner *Boot2DockerProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
 	var (
 		engineCfg bytes.Buffer
@@ -142,11 +169,7 @@ func (provisioner *Boot2DockerProvisioner) GenerateDockerOptions(dockerPort int)
 
 	engineConfigTmpl := `
 EXTRA_ARGS='
-{{ range .EngineOptions.Labels }}--label {{.}}
-{{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}}
-{{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}}
-{{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}}
-{{ end }}
+--config-file {{.DaemonConfigPath}}
 '
 CACERT={{.AuthOptions.CaCertRemotePath}}
 DOCKER_HOST='-H tcp://0.0.0.0:{{.DockerPort}}'
@@ -155,7 +178,8 @@ DOCKER_TLS=auto
 SERVERKEY={{.AuthOptions.ServerKeyRemotePath}}
 SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 
-{{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
+{{range .EngineOptions.PlainEnv}}export \"{{ printf "%q" . }}\"
+{{end}}{{if .EngineOptions.SecretEnv}}. /var/lib/boot2docker/machine-secrets.env
 {{end}}
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
@@ -163,10 +187,19 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 		return nil, err
 	}
 
+	// The boot2docker init script passes DOCKER_STORAGE as a flag already
+	daemonEngineOptions := provisioner.EngineOptions
+	daemonEngineOptions.StorageDriver = ""
+	daemonConfig, err := generateDaemonConfig(daemonEngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
-		DockerPort:    dockerPort,
-		AuthOptions:   provisioner.AuthOptions,
-		EngineOptions: provisioner.EngineOptions,
+		DockerPort:       dockerPort,
+		AuthOptions:      provisioner.AuthOptions,
+		EngineOptions:    provisioner.EngineOptions,
+		DaemonConfigPath: daemonConfigPath(provisioner),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -175,11 +208,14 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: daemonOptsDir,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
 	}, nil
 }
 
 func (provisioner *Boot2DockerProvisioner) CompatibleWithHost() bool {
-	return provisioner.OsReleaseInfo.ID == "boot2docker"
+	return provisioner.OsReleaseInfo.ID == "boot2docker" ||
+		(provisioner.OsReleaseInfo.ID == "alpine" && provisioner.OsReleaseInfo.VariantID == alpineVariantID)
 }
 
 func (provisioner *Boot2DockerProvisioner) SetOsReleaseInfo(info *OsRelease) {
@@ -197,7 +233,7 @@ func (provisioner *Boot2DockerProvisioner) AttemptIPContact(dockerPort int) {
 		return
 	}
 
-	if conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ip, dockerPort), 5*time.Second); err != nil {
+	if conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(dockerPort)), 5*time.Second); err != nil {
 		log.Warnf(`
 This machine has been allocated an IP address, but Docker Machine could not
 reach it successfully.
@@ -229,7 +265,7 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options,
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
+	swarmOptions.Env = engineOptions.PlainEnv()
 
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "aufs"
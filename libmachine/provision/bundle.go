@@ -0,0 +1,206 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// An engine install bundle is a tarball uploaded over SSH and installed
+// without reaching the internet: either the distribution packages of the
+// engine (.deb or .rpm files, anywhere in the tarball), or the static
+// binaries of download.docker.com/linux/static, in a docker/ directory,
+// which get a systemd unit of their own.
+
+const (
+	bundleRemotePath = "/tmp/machine-engine-bundle"
+	bundleRemoteDir  = "/tmp/machine-engine-bundle.d"
+	dockerUnitPath   = "/etc/systemd/system/docker.service"
+
+	staticDockerUnit = `[Unit]
+Description=Docker Application Container Engine
+After=network-online.target firewalld.service
+Wants=network-online.target
+
+[Service]
+Type=notify
+ExecStart=/usr/bin/dockerd -H unix:///var/run/docker.sock
+ExecReload=/bin/kill -s HUP $MAINPID
+LimitNOFILE=infinity
+LimitNPROC=infinity
+LimitCORE=infinity
+TasksMax=infinity
+Delegate=yes
+KillMode=process
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`
+)
+
+// bundleInstaller is implemented by the provisioners which can install the
+// engine from a bundle, with --engine-install-bundle.
+type bundleInstaller interface {
+	// bundlePackageInstall returns the extension of the packages of the
+	// distribution and the command installing them.
+	bundlePackageInstall() (string, string)
+}
+
+// SupportsInstallBundle returns true if the provisioner can install the
+// engine from a bundle.
+func SupportsInstallBundle(p Provisioner) bool {
+	_, ok := p.(bundleInstaller)
+	return ok
+}
+
+// uploadFile writes content to a file of the machine.  It's a variable so
+// that the tests can record the uploads.
+var uploadFile = func(p Provisioner, content io.Reader, filePath string) error {
+	_, err := drivers.RunSSHCommandWithInputFromDriver(p.GetDriver(), fmt.Sprintf("cat > %s", filePath), content)
+	return err
+}
+
+// openInstallBundle opens a bundle from a local path or an HTTP(S) URL.  The
+// bundle is downloaded by the host running Machine, since the machine
+// can't reach the internet.
+func openInstallBundle(bundle string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(bundle, "http://") && !strings.HasPrefix(bundle, "https://") {
+		return os.Open(bundle)
+	}
+
+	resp, err := http.Get(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Error downloading %s: %s", bundle, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// basePackages returns the base packages to install before the engine, none
+// when it's installed from a bundle since the machine has no repositories
+// to install them from.
+func basePackages(packages []string, engineOptions engine.Options) []string {
+	if engineOptions.InstallBundle != "" {
+		return nil
+	}
+
+	return packages
+}
+
+// installEngine installs the engine from the bundle of the options if
+// there's one, with the install script otherwise.
+func installEngine(p Provisioner, engineOptions engine.Options) error {
+	if engineOptions.InstallBundle == "" {
+		return installDockerGeneric(p, engineOptions.InstallURL)
+	}
+
+	installer, ok := p.(bundleInstaller)
+	if !ok {
+		return ErrInstallBundleNotSupported
+	}
+
+	return installDockerBundle(p, installer, engineOptions.InstallBundle)
+}
+
+// installDockerBundle uploads the bundle to the machine and installs the
+// engine from it, unless it's already installed.
+func installDockerBundle(p Provisioner, installer bundleInstaller, bundle string) error {
+	if _, err := p.SSHCommand("type docker"); err == nil {
+		log.Debug("docker is installed, skipping the install bundle")
+		return nil
+	}
+
+	content, err := openInstallBundle(bundle)
+	if err != nil {
+		return fmt.Errorf("Error opening the engine install bundle: %s", err)
+	}
+	defer content.Close()
+
+	log.Infof("Uploading the engine install bundle %s...", bundle)
+	if err := uploadFile(p, content, bundleRemotePath); err != nil {
+		return fmt.Errorf("Error uploading the engine install bundle: %s", err)
+	}
+	defer p.SSHCommand(fmt.Sprintf("sudo rm -rf %s %s", bundleRemotePath, bundleRemoteDir))
+
+	if output, err := p.SSHCommand(fmt.Sprintf("sudo rm -rf %[2]s && sudo mkdir -p %[2]s && sudo tar -xf %[1]s -C %[2]s", bundleRemotePath, bundleRemoteDir)); err != nil {
+		return fmt.Errorf("Error extracting the engine install bundle: %s\n%s", err, output)
+	}
+
+	extension, install := installer.bundlePackageInstall()
+	packages, err := p.SSHCommand(fmt.Sprintf("find %s -name '*.%s'", bundleRemoteDir, extension))
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(packages) != "" {
+		log.Debugf("installing the %s packages of the bundle", extension)
+		if output, err := p.SSHCommand(fmt.Sprintf("sudo %s $(find %s -name '*.%s')", install, bundleRemoteDir, extension)); err != nil {
+			return fmt.Errorf("Error installing the packages of the engine install bundle: %s\n%s", err, output)
+		}
+
+		return nil
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("test -x %s/docker/dockerd", bundleRemoteDir)); err != nil {
+		return fmt.Errorf("The engine install bundle contains neither .%s packages nor the static binaries of docker in a docker/ directory", extension)
+	}
+
+	// The static binaries are built for a single architecture, e.g. the
+	// x86_64 ones don't run on an arm64 machine
+	if _, err := p.SSHCommand(fmt.Sprintf("%s/docker/dockerd --version", bundleRemoteDir)); err != nil {
+		arch, archErr := DetectArchitecture(p)
+		if archErr != nil {
+			arch = "unknown"
+		}
+		return fmt.Errorf("The static binaries of the engine install bundle don't run on the machine, download the ones built for its %s architecture", arch)
+	}
+
+	return installStaticDocker(p)
+}
+
+// installStaticDocker installs the static binaries of the bundle and
+// starts the engine with a systemd unit.
+func installStaticDocker(p Provisioner) error {
+	log.Debug("installing the static binaries of the bundle")
+	if output, err := p.SSHCommand(fmt.Sprintf("sudo install -m 0755 %s/docker/* /usr/bin/ && sudo groupadd -f docker", bundleRemoteDir)); err != nil {
+		return fmt.Errorf("Error installing the static binaries of docker: %s\n%s", err, output)
+	}
+
+	if err := writeRemoteFile(p, staticDockerUnit, dockerUnitPath); err != nil {
+		return err
+	}
+
+	if output, err := p.SSHCommand("sudo systemctl daemon-reload && sudo systemctl enable --now docker"); err != nil {
+		return fmt.Errorf("Error starting docker: %s\n%s", err, output)
+	}
+
+	return nil
+}
+
+func (provisioner *UbuntuSystemdProvisioner) bundlePackageInstall() (string, string) {
+	return "deb", "dpkg -i"
+}
+
+func (provisioner *DebianProvisioner) bundlePackageInstall() (string, string) {
+	return "deb", "dpkg -i"
+}
+
+func (provisioner *RedHatProvisioner) bundlePackageInstall() (string, string) {
+	return "rpm", "rpm -Uvh --replacepkgs"
+}
+
+func (provisioner *SUSEProvisioner) bundlePackageInstall() (string, string) {
+	return "rpm", "rpm -Uvh --replacepkgs"
+}
@@ -0,0 +1,129 @@
+package provision
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+// bundleSSHCommander records the commands of a machine without docker,
+// whose bundle contains the given packages, or the static binaries, built
+// for another architecture if foreign.
+type bundleSSHCommander struct {
+	commands []string
+	packages string
+	static   bool
+	foreign  bool
+}
+
+func (c *bundleSSHCommander) SSHCommand(args string) (string, error) {
+	c.commands = append(c.commands, args)
+
+	switch {
+	case args == "type docker":
+		return "", errors.New("docker: not found")
+	case strings.HasPrefix(args, "find "):
+		return c.packages, nil
+	case strings.HasPrefix(args, "test -x ") && !c.static:
+		return "", errors.New("exit status 1")
+	case strings.HasSuffix(args, "dockerd --version") && c.foreign:
+		return "", errors.New("exit status 126")
+	case args == "uname -m":
+		return "aarch64\n", nil
+	}
+
+	return "", nil
+}
+
+func withBundle(t *testing.T) (string, map[string]string, func()) {
+	bundle, err := ioutil.TempFile("", "machine-bundle-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle.WriteString("tarball")
+	bundle.Close()
+
+	uploads := map[string]string{}
+	originalUploadFile := uploadFile
+	uploadFile = func(p Provisioner, content io.Reader, filePath string) error {
+		data, err := ioutil.ReadAll(content)
+		uploads[filePath] = string(data)
+		return err
+	}
+
+	return bundle.Name(), uploads, func() {
+		os.Remove(bundle.Name())
+		uploadFile = originalUploadFile
+	}
+}
+
+func TestInstallEngineBundlePackages(t *testing.T) {
+	bundle, uploads, cleanup := withBundle(t)
+	defer cleanup()
+
+	commander := &bundleSSHCommander{packages: "/tmp/machine-engine-bundle.d/docker-ce.deb\n"}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	assert.NoError(t, installEngine(p, engine.Options{InstallBundle: bundle}))
+	assert.Equal(t, "tarball", uploads[bundleRemotePath])
+	assert.Contains(t, commander.commands, "sudo rm -rf /tmp/machine-engine-bundle.d && sudo mkdir -p /tmp/machine-engine-bundle.d && sudo tar -xf /tmp/machine-engine-bundle -C /tmp/machine-engine-bundle.d")
+	assert.Contains(t, commander.commands, "sudo dpkg -i $(find /tmp/machine-engine-bundle.d -name '*.deb')")
+	assert.Equal(t, "sudo rm -rf /tmp/machine-engine-bundle /tmp/machine-engine-bundle.d", commander.commands[len(commander.commands)-1])
+}
+
+func TestInstallEngineBundleStatic(t *testing.T) {
+	bundle, _, cleanup := withBundle(t)
+	defer cleanup()
+
+	commander := &bundleSSHCommander{static: true}
+	p := NewCentosProvisioner(&fakedriver.Driver{}).(*CentosProvisioner)
+	p.SSHCommander = commander
+
+	assert.NoError(t, installEngine(p, engine.Options{InstallBundle: bundle}))
+	assert.Contains(t, commander.commands, "find /tmp/machine-engine-bundle.d -name '*.rpm'")
+	assert.Contains(t, commander.commands, "sudo install -m 0755 /tmp/machine-engine-bundle.d/docker/* /usr/bin/ && sudo groupadd -f docker")
+	assert.Contains(t, commander.commands, "sudo systemctl daemon-reload && sudo systemctl enable --now docker")
+}
+
+func TestInstallEngineBundleStaticForeign(t *testing.T) {
+	bundle, _, cleanup := withBundle(t)
+	defer cleanup()
+
+	p := NewCentosProvisioner(&fakedriver.Driver{}).(*CentosProvisioner)
+	p.SSHCommander = &bundleSSHCommander{static: true, foreign: true}
+
+	err := installEngine(p, engine.Options{InstallBundle: bundle})
+	assert.EqualError(t, err, "The static binaries of the engine install bundle don't run on the machine, download the ones built for its arm64 architecture")
+}
+
+func TestInstallEngineBundleInvalid(t *testing.T) {
+	bundle, _, cleanup := withBundle(t)
+	defer cleanup()
+
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	p.SSHCommander = &bundleSSHCommander{}
+
+	err := installEngine(p, engine.Options{InstallBundle: bundle})
+	assert.EqualError(t, err, "The engine install bundle contains neither .deb packages nor the static binaries of docker in a docker/ directory")
+}
+
+func TestInstallEngineBundleNotSupported(t *testing.T) {
+	p := NewArchProvisioner(&fakedriver.Driver{})
+
+	assert.False(t, SupportsInstallBundle(p))
+	assert.Equal(t, ErrInstallBundleNotSupported, installEngine(p, engine.Options{InstallBundle: "/tmp/docker.tgz"}))
+	assert.True(t, SupportsInstallBundle(NewSLESProvisioner(&fakedriver.Driver{})))
+}
+
+func TestBasePackages(t *testing.T) {
+	assert.Equal(t, []string{"curl"}, basePackages([]string{"curl"}, engine.Options{}))
+	assert.Empty(t, basePackages([]string{"curl"}, engine.Options{InstallBundle: "/tmp/docker.tgz"}))
+}
@@ -0,0 +1,47 @@
+package provision
+
+import (
+	"path"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// RotateServerCert replaces the server certificate of the engine with a new
+// one and restarts the engine.  Unlike ConfigureAuth, the engine isn't
+// installed nor configured again.  The engines of podman, containerd,
+// rootless and Windows machines, whose certificates are installed
+// differently, return ErrCertRotationNotSupported.
+func RotateServerCert(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	if !ManagesDockerd(p, engineOptions) {
+		return ErrCertRotationNotSupported
+	}
+
+	dockerDir := p.GetDockerOptionsDir()
+	authOptions.CaCertRemotePath = path.Join(dockerDir, "ca.pem")
+	authOptions.ServerCertRemotePath = path.Join(dockerDir, "server.pem")
+	authOptions.ServerKeyRemotePath = path.Join(dockerDir, "server-key.pem")
+
+	if err := writeServerCert(p.GetDriver(), authOptions, swarmOptions); err != nil {
+		return err
+	}
+
+	if err := uploadCerts(p, authOptions); err != nil {
+		return err
+	}
+
+	log.Info("Restarting the engine with its new certificate...")
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
@@ -0,0 +1,71 @@
+package provision
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingSSHCommander records the commands it's given, and answers
+// netstat with the engine listening.
+type recordingSSHCommander struct {
+	commands []string
+}
+
+func (c *recordingSSHCommander) SSHCommand(args string) (string, error) {
+	c.commands = append(c.commands, args)
+	if args == "netstat -tln" {
+		return "tcp6       0      0 :::2376                 :::*                    LISTEN", nil
+	}
+	return "", nil
+}
+
+func TestRotateServerCert(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	authOptions := auth.Options{
+		CertDir:          filepath.Join(tmpDir, "certs"),
+		CaCertPath:       filepath.Join(tmpDir, "certs", "ca.pem"),
+		CaPrivateKeyPath: filepath.Join(tmpDir, "certs", "ca-key.pem"),
+		ClientCertPath:   filepath.Join(tmpDir, "certs", "cert.pem"),
+		ClientKeyPath:    filepath.Join(tmpDir, "certs", "key.pem"),
+		ServerCertPath:   filepath.Join(tmpDir, "server.pem"),
+		ServerKeyPath:    filepath.Join(tmpDir, "server-key.pem"),
+		StorePath:        tmpDir,
+	}
+	assert.NoError(t, cert.BootstrapCertificates(&authOptions))
+
+	commander := &recordingSSHCommander{}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	assert.NoError(t, RotateServerCert(p, swarm.Options{}, authOptions, engine.Options{}))
+
+	_, err = os.Stat(authOptions.ServerCertPath)
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasSuffix(commander.commands[1], "sudo tee /etc/docker/server.pem"))
+	assert.True(t, strings.HasSuffix(commander.commands[2], "sudo tee /etc/docker/server-key.pem"))
+	assert.Contains(t, commander.commands, "sudo systemctl -f restart docker")
+}
+
+func TestRotateServerCertNotSupported(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{})
+
+	assert.Equal(t, ErrCertRotationNotSupported, RotateServerCert(p, swarm.Options{}, auth.Options{}, engine.Options{Rootless: true}))
+	assert.Equal(t, ErrCertRotationNotSupported, RotateServerCert(p, swarm.Options{}, auth.Options{}, engine.Options{ContainerRuntime: "podman"}))
+}
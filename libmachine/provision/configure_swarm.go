@@ -2,6 +2,7 @@ package provision
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
@@ -37,27 +38,25 @@ func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.
 		return err
 	}
 
-	parts := strings.Split(engineURL, ":")
-	if len(parts) == 3 {
-		dPort, err := strconv.Atoi(parts[2])
+	if engineU, err := url.Parse(engineURL); err == nil && engineU.Port() != "" {
+		dPort, err := strconv.Atoi(engineU.Port())
 		if err != nil {
 			return err
 		}
 		enginePort = dPort
 	}
 
-	parts = strings.Split(u.Host, ":")
-	port := parts[1]
+	port := u.Port()
 
 	dockerDir := p.GetDockerOptionsDir()
 	dockerHost := &mcndockerclient.RemoteDocker{
-		HostURL:    fmt.Sprintf("tcp://%s:%d", ip, enginePort),
+		HostURL:    fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, strconv.Itoa(enginePort))),
 		AuthOption: &authOptions,
 	}
-	advertiseInfo := fmt.Sprintf("%s:%d", ip, enginePort)
+	advertiseInfo := net.JoinHostPort(ip, strconv.Itoa(enginePort))
 
 	if swarmOptions.Master {
-		advertiseMasterInfo := fmt.Sprintf("%s:%s", ip, "3376")
+		advertiseMasterInfo := net.JoinHostPort(ip, "3376")
 		cmd := fmt.Sprintf("manage --tlsverify --tlscacert=%s --tlscert=%s --tlskey=%s -H %s --strategy %s --advertise %s",
 			authOptions.CaCertRemotePath,
 			authOptions.ServerCertRemotePath,
@@ -0,0 +1,147 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+)
+
+const (
+	containerdService     = "containerd"
+	containerdTLSService  = "containerd-tls"
+	containerdTLSUnitFile = "/etc/systemd/system/containerd-tls.service"
+
+	// nerdctlVersion is the release of nerdctl installed alongside
+	// containerd, which the distributions don't package.
+	nerdctlVersion = "1.7.7"
+)
+
+var (
+	containerdPackages = []string{"containerd", "socat"}
+
+	// Like for Podman, socat terminates TLS on the engine port, verifying the
+	// clients against the machine CA, and forwards the gRPC connections to
+	// the containerd socket.
+	containerdTLSTemplate = `[Unit]
+Description=TLS endpoint for the containerd API
+Requires=containerd.service
+After=containerd.service network.target
+
+[Service]
+ExecStart=/usr/bin/socat OPENSSL-LISTEN:{{.DockerPort}},reuseaddr,fork,cert={{.AuthOptions.ServerCertRemotePath}},key={{.AuthOptions.ServerKeyRemotePath}},cafile={{.AuthOptions.CaCertRemotePath}},verify=1 UNIX-CONNECT:/run/containerd/containerd.sock
+Restart=always
+
+[Install]
+WantedBy=multi-user.target
+`
+)
+
+// generateContainerdOptions renders the systemd unit which exposes the
+// containerd API over TLS on the given port.
+func generateContainerdOptions(p Provisioner, dockerPort int) (*DockerOptions, error) {
+	var unit bytes.Buffer
+
+	t, err := template.New("containerdConfig").Parse(containerdTLSTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	containerdConfigContext := EngineConfigContext{
+		DockerPort:  dockerPort,
+		AuthOptions: p.GetAuthOptions(),
+	}
+
+	if err := t.Execute(&unit, containerdConfigContext); err != nil {
+		return nil, err
+	}
+
+	return &DockerOptions{
+		EngineOptions:     unit.String(),
+		EngineOptionsPath: containerdTLSUnitFile,
+	}, nil
+}
+
+// installNerdctlCommand returns the command which installs the nerdctl
+// binary of the architecture of the machine in /usr/local/bin.
+func installNerdctlCommand(version string) string {
+	return fmt.Sprintf(`arch=$(uname -m); case $arch in x86_64) arch=amd64;; aarch64) arch=arm64;; esac; curl -fsSL https://github.com/containerd/nerdctl/releases/download/v%[1]s/nerdctl-%[1]s-linux-$arch.tar.gz | sudo tar -xz -C /usr/local/bin nerdctl`, version)
+}
+
+// provisionContainerd installs containerd and nerdctl instead of the Docker
+// engine and publishes the containerd API with the machine's TLS
+// certificates.  The remote auth options must already be set on the
+// provisioner.
+func provisionContainerd(p Provisioner) error {
+	log.Info("Installing containerd...")
+	for _, pkg := range containerdPackages {
+		if err := p.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Installing nerdctl...")
+	if _, err := p.SSHCommand(installNerdctlCommand(nerdctlVersion)); err != nil {
+		return err
+	}
+
+	if err := p.Service(containerdService, serviceaction.Enable); err != nil {
+		return err
+	}
+
+	if err := p.Service(containerdService, serviceaction.Start); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(p); err != nil {
+		return err
+	}
+
+	if err := generateServerCert(p); err != nil {
+		return err
+	}
+
+	if err := copyRemoteCerts(p); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	containerdCfg, err := generateContainerdOptions(p, dockerPort)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Setting containerd API configuration on the remote machine...")
+
+	if _, err := p.SSHCommand(fmt.Sprintf("printf %%s \"%s\" | sudo tee %s", containerdCfg.EngineOptions, containerdCfg.EngineOptionsPath)); err != nil {
+		return err
+	}
+
+	if err := p.Service(containerdTLSService, serviceaction.Enable); err != nil {
+		return err
+	}
+
+	if err := p.Service(containerdTLSService, serviceaction.Restart); err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
+
+// UpgradeContainerd upgrades containerd and nerdctl on a machine provisioned
+// with the containerd container runtime.
+func UpgradeContainerd(p Provisioner) error {
+	if err := p.Package(containerdService, pkgaction.Upgrade); err != nil {
+		return err
+	}
+
+	_, err := p.SSHCommand(installNerdctlCommand(nerdctlVersion))
+	return err
+}
@@ -0,0 +1,34 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateContainerdOptions(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+
+	containerdCfg, err := generateContainerdOptions(p, 2376)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/containerd-tls.service", containerdCfg.EngineOptionsPath)
+	assert.True(t, strings.Contains(containerdCfg.EngineOptions, "OPENSSL-LISTEN:2376"))
+	assert.True(t, strings.Contains(containerdCfg.EngineOptions, "cert=/etc/docker/server.pem,key=/etc/docker/server-key.pem,cafile=/etc/docker/ca.pem,verify=1"))
+	assert.True(t, strings.Contains(containerdCfg.EngineOptions, "UNIX-CONNECT:/run/containerd/containerd.sock"))
+}
+
+func TestInstallNerdctlCommand(t *testing.T) {
+	command := installNerdctlCommand("1.7.7")
+
+	assert.True(t, strings.Contains(command, "https://github.com/containerd/nerdctl/releases/download/v1.7.7/nerdctl-1.7.7-linux-$arch.tar.gz"))
+	assert.True(t, strings.HasSuffix(command, "sudo tar -xz -C /usr/local/bin nerdctl"))
+}
@@ -0,0 +1,305 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+)
+
+// The engine options are written to the daemon.json of the engine, which can
+// express the options the command line flags can't, e.g. features or
+// default-address-pools.  Only the hosts and the TLS settings Machine needs to
+// reach the engine are left to the flags of each distribution.
+
+const daemonConfigFileName = "daemon.json"
+
+var (
+	// reservedDaemonOptions are set by Machine on the command line of the
+	// engine, which refuses to start if they are in its daemon.json too.
+	reservedDaemonOptions = []string{"hosts", "tls", "tlsverify", "tlscacert", "tlscert", "tlskey"}
+
+	// daemonListOptions maps the flags which can be repeated to the array of
+	// the daemon.json they fill.
+	daemonListOptions = map[string]string{
+		"authorization-plugin":             "authorization-plugins",
+		"allow-nondistributable-artifacts": "allow-nondistributable-artifacts",
+		"dns":                              "dns",
+		"dns-opt":                          "dns-opts",
+		"dns-search":                       "dns-search",
+		"exec-opt":                         "exec-opts",
+		"insecure-registry":                "insecure-registries",
+		"label":                            "labels",
+		"node-generic-resource":            "node-generic-resources",
+		"registry-mirror":                  "registry-mirrors",
+		"storage-opt":                      "storage-opts",
+	}
+
+	// daemonMapOptions maps the flags taking key=value pairs to the object
+	// of the daemon.json they fill.
+	daemonMapOptions = map[string]string{
+		"cluster-store-opt": "cluster-store-opts",
+		"log-opt":           "log-opts",
+	}
+
+	// daemonObjectOptions convert the value of the flags describing a
+	// structure, e.g. a ulimit or a runtime, to the daemon.json key and value
+	// they fill.  The value is kept as is if it can't be parsed, so that the
+	// engine reports it.
+	daemonObjectOptions = map[string]func(string) (string, interface{}, bool){
+		"add-runtime":          runtimeOption,
+		"default-address-pool": addressPoolOption,
+		"default-ulimit":       ulimitOption,
+	}
+)
+
+// ValidateDaemonConfig checks a daemon.json given with --engine-opt-file: it
+// must be an object, without the options Machine manages.
+func ValidateDaemonConfig(content []byte) error {
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return fmt.Errorf("Error parsing the engine configuration file: %s", err)
+	}
+
+	for _, option := range reservedDaemonOptions {
+		if _, ok := config[option]; ok {
+			return fmt.Errorf("Error: %q is managed by Machine and can't be set in the engine configuration file", option)
+		}
+	}
+
+	return nil
+}
+
+// generateDaemonConfig renders the daemon.json of the engine from its
+// options: the flags given with --engine-opt are converted to their
+// daemon.json counterpart, then the file given with --engine-opt-file is
+// merged in.
+func generateDaemonConfig(engineOptions engine.Options) (string, error) {
+	config := map[string]interface{}{}
+
+	setDaemonList(config, "labels", engineOptions.Labels)
+	setDaemonList(config, "insecure-registries", engineOptions.InsecureRegistry)
+	setDaemonList(config, "registry-mirrors", engineOptions.RegistryMirror)
+	setDaemonList(config, "dns", engineOptions.DNS)
+
+	if engineOptions.StorageDriver != "" {
+		config["storage-driver"] = engineOptions.StorageDriver
+	}
+
+	if engineOptions.GraphDir != "" {
+		config["data-root"] = engineOptions.GraphDir
+	}
+
+	if engineOptions.LogLevel != "" {
+		config["log-level"] = engineOptions.LogLevel
+	}
+
+	if engineOptions.Ipv6 {
+		config["ipv6"] = true
+	}
+
+	if engineOptions.SelinuxEnabled {
+		config["selinux-enabled"] = true
+	}
+
+	setGPURuntime(config, engineOptions.GPU)
+
+	for _, flag := range engineOptions.ArbitraryFlags {
+		key, value := daemonOption(flag)
+		mergeDaemonConfig(config, map[string]interface{}{key: value})
+	}
+
+	if len(engineOptions.DaemonConfig) > 0 {
+		fileConfig := map[string]interface{}{}
+		if err := json.Unmarshal(engineOptions.DaemonConfig, &fileConfig); err != nil {
+			return "", fmt.Errorf("Error parsing the engine configuration file: %s", err)
+		}
+		mergeDaemonConfig(config, fileConfig)
+	}
+
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// daemonConfigPath returns the path of the daemon.json in the directory of
+// the options of the engine.
+func daemonConfigPath(p interface {
+	GetDockerOptionsDir() string
+}) string {
+	return path.Join(p.GetDockerOptionsDir(), daemonConfigFileName)
+}
+
+func setDaemonList(config map[string]interface{}, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	list := []interface{}{}
+	for _, value := range values {
+		list = append(list, value)
+	}
+	config[key] = list
+}
+
+// daemonOption converts a flag given with --engine-opt, in the form flag or
+// flag=value, to its daemon.json key and value.
+func daemonOption(flag string) (string, interface{}) {
+	parts := strings.SplitN(strings.TrimLeft(flag, "-"), "=", 2)
+	name := parts[0]
+
+	if len(parts) == 1 {
+		return name, true
+	}
+	value := parts[1]
+
+	if key, ok := daemonListOptions[name]; ok {
+		return key, []interface{}{value}
+	}
+
+	if convert, ok := daemonObjectOptions[name]; ok {
+		if key, object, ok := convert(value); ok {
+			return key, object
+		}
+	}
+
+	if key, ok := daemonMapOptions[name]; ok {
+		pair := strings.SplitN(value, "=", 2)
+		if len(pair) == 2 {
+			return key, map[string]interface{}{pair[0]: pair[1]}
+		}
+	}
+
+	if value == "true" || value == "false" {
+		return name, value == "true"
+	}
+
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return name, n
+	}
+
+	return name, value
+}
+
+// runtimeOption converts --add-runtime=name=path to the runtime of the
+// runtimes object.
+func runtimeOption(value string) (string, interface{}, bool) {
+	pair := strings.SplitN(value, "=", 2)
+	if len(pair) != 2 || pair[0] == "" || pair[1] == "" {
+		return "", nil, false
+	}
+
+	return "runtimes", map[string]interface{}{
+		pair[0]: map[string]interface{}{"path": pair[1]},
+	}, true
+}
+
+// ulimitOption converts --default-ulimit=name=soft[:hard] to the ulimit of
+// the default-ulimits object.
+func ulimitOption(value string) (string, interface{}, bool) {
+	pair := strings.SplitN(value, "=", 2)
+	if len(pair) != 2 || pair[0] == "" {
+		return "", nil, false
+	}
+
+	limits := strings.SplitN(pair[1], ":", 2)
+	soft, err := strconv.ParseInt(limits[0], 10, 64)
+	if err != nil {
+		return "", nil, false
+	}
+
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = strconv.ParseInt(limits[1], 10, 64); err != nil {
+			return "", nil, false
+		}
+	}
+
+	return "default-ulimits", map[string]interface{}{
+		pair[0]: map[string]interface{}{"Name": pair[0], "Soft": soft, "Hard": hard},
+	}, true
+}
+
+// addressPoolOption converts --default-address-pool=base=cidr,size=n to
+// the pool of the default-address-pools array.
+func addressPoolOption(value string) (string, interface{}, bool) {
+	pool := map[string]interface{}{}
+	for _, field := range strings.Split(value, ",") {
+		pair := strings.SplitN(field, "=", 2)
+		if len(pair) != 2 {
+			return "", nil, false
+		}
+
+		switch pair[0] {
+		case "base":
+			pool["base"] = pair[1]
+		case "size":
+			size, err := strconv.ParseInt(pair[1], 10, 64)
+			if err != nil {
+				return "", nil, false
+			}
+			pool["size"] = size
+		default:
+			return "", nil, false
+		}
+	}
+
+	if _, ok := pool["base"]; !ok {
+		return "", nil, false
+	}
+
+	return "default-address-pools", []interface{}{pool}, true
+}
+
+// mergeDaemonConfig merges src into dst: objects are merged recursively,
+// arrays are concatenated without duplicates, and the other values of src
+// replace the ones of dst.
+func mergeDaemonConfig(dst, src map[string]interface{}) {
+	for key, value := range src {
+		existing, ok := dst[key]
+		if !ok {
+			dst[key] = value
+			continue
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if existingMap, ok := existing.(map[string]interface{}); ok {
+				mergeDaemonConfig(existingMap, v)
+				continue
+			}
+		case []interface{}:
+			if existingList, ok := existing.([]interface{}); ok {
+				dst[key] = appendUnique(existingList, v)
+				continue
+			}
+		}
+
+		dst[key] = value
+	}
+}
+
+func appendUnique(list, values []interface{}) []interface{} {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if reflect.DeepEqual(existing, value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, value)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,117 @@
+package provision
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateDaemonConfig(t *testing.T) {
+	content, err := generateDaemonConfig(engine.Options{
+		Labels:           []string{"foo=bar"},
+		InsecureRegistry: []string{"registry.local:5000"},
+		StorageDriver:    "overlay2",
+		ArbitraryFlags: []string{
+			"experimental",
+			"label=env=test",
+			"log-opt=max-size=10m",
+			"max-concurrent-downloads=5",
+			"userland-proxy=false",
+			"default-runtime=runc",
+		},
+	})
+	assert.NoError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(content), &config))
+
+	assert.Equal(t, []interface{}{"foo=bar", "env=test"}, config["labels"])
+	assert.Equal(t, []interface{}{"registry.local:5000"}, config["insecure-registries"])
+	assert.Equal(t, "overlay2", config["storage-driver"])
+	assert.Equal(t, true, config["experimental"])
+	assert.Equal(t, map[string]interface{}{"max-size": "10m"}, config["log-opts"])
+	assert.Equal(t, float64(5), config["max-concurrent-downloads"])
+	assert.Equal(t, false, config["userland-proxy"])
+	assert.Equal(t, "runc", config["default-runtime"])
+}
+
+func TestGenerateDaemonConfigStructuredFlags(t *testing.T) {
+	content, err := generateDaemonConfig(engine.Options{
+		ArbitraryFlags: []string{
+			"default-ulimit=nofile=1024:2048",
+			"default-ulimit=nproc=512",
+			"add-runtime=crun=/usr/bin/crun",
+			"cluster-store-opt=kv.cacertfile=/etc/ca.pem",
+			"default-address-pool=base=10.10.0.0/16,size=24",
+			"default-address-pool=base=10.20.0.0/16,size=24",
+		},
+	})
+	assert.NoError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(content), &config))
+
+	assert.Equal(t, map[string]interface{}{
+		"nofile": map[string]interface{}{"Name": "nofile", "Soft": float64(1024), "Hard": float64(2048)},
+		"nproc":  map[string]interface{}{"Name": "nproc", "Soft": float64(512), "Hard": float64(512)},
+	}, config["default-ulimits"])
+	assert.Equal(t, map[string]interface{}{
+		"crun": map[string]interface{}{"path": "/usr/bin/crun"},
+	}, config["runtimes"])
+	assert.Equal(t, map[string]interface{}{"kv.cacertfile": "/etc/ca.pem"}, config["cluster-store-opts"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"base": "10.10.0.0/16", "size": float64(24)},
+		map[string]interface{}{"base": "10.20.0.0/16", "size": float64(24)},
+	}, config["default-address-pools"])
+	assert.NotContains(t, config, "default-ulimit")
+	assert.NotContains(t, config, "add-runtime")
+}
+
+func TestDaemonOptionInvalidStructuredFlag(t *testing.T) {
+	key, value := daemonOption("default-ulimit=nofile=many")
+
+	assert.Equal(t, "default-ulimit", key)
+	assert.Equal(t, "nofile=many", value)
+}
+
+func TestGenerateDaemonConfigEmpty(t *testing.T) {
+	content, err := generateDaemonConfig(engine.Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", content)
+}
+
+func TestGenerateDaemonConfigMergesFile(t *testing.T) {
+	content, err := generateDaemonConfig(engine.Options{
+		Labels:         []string{"foo=bar"},
+		StorageDriver:  "overlay2",
+		ArbitraryFlags: []string{"log-opt=max-size=10m"},
+		DaemonConfig: json.RawMessage(`{
+			"labels": ["foo=bar", "env=test"],
+			"storage-driver": "btrfs",
+			"log-opts": {"max-file": "3"},
+			"features": {"buildkit": true},
+			"default-address-pools": [{"base": "10.10.0.0/16", "size": 24}]
+		}`),
+	})
+	assert.NoError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(content), &config))
+
+	assert.Equal(t, []interface{}{"foo=bar", "env=test"}, config["labels"])
+	assert.Equal(t, "btrfs", config["storage-driver"])
+	assert.Equal(t, map[string]interface{}{"max-size": "10m", "max-file": "3"}, config["log-opts"])
+	assert.Equal(t, map[string]interface{}{"buildkit": true}, config["features"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"base": "10.10.0.0/16", "size": float64(24)}}, config["default-address-pools"])
+}
+
+func TestValidateDaemonConfig(t *testing.T) {
+	assert.NoError(t, ValidateDaemonConfig([]byte(`{"features": {"buildkit": true}}`)))
+	assert.Error(t, ValidateDaemonConfig([]byte(`["features"]`)))
+	assert.Error(t, ValidateDaemonConfig([]byte(`{"features":`)))
+	assert.Error(t, ValidateDaemonConfig([]byte(`{"hosts": ["tcp://0.0.0.0:2375"]}`)))
+	assert.Error(t, ValidateDaemonConfig([]byte(`{"tlsverify": false}`)))
+}
@@ -21,18 +21,61 @@ func init() {
 
 func NewDebianProvisioner(d drivers.Driver) Provisioner {
 	return &DebianProvisioner{
-		NewSystemdProvisioner("debian", d),
+		SystemdProvisioner: NewSystemdProvisioner("debian", d),
 	}
 }
 
 type DebianProvisioner struct {
 	SystemdProvisioner
+
+	// init is the init system of the machine, detected on first use.
+	init initSystem
 }
 
 func (provisioner *DebianProvisioner) String() string {
 	return "debian"
 }
 
+func (provisioner *DebianProvisioner) initSystem() initSystem {
+	if provisioner.init == "" {
+		provisioner.init = detectInitSystem(provisioner)
+		if provisioner.init != initSystemd {
+			log.Debugf("the init system is %s, not systemd", provisioner.init)
+			provisioner.DaemonOptionsFile = provisioner.init.daemonOptionsFile()
+		}
+	}
+
+	return provisioner.init
+}
+
+func (provisioner *DebianProvisioner) Service(name string, action serviceaction.ServiceAction) error {
+	init := provisioner.initSystem()
+	if init == initSystemd {
+		return provisioner.SystemdProvisioner.Service(name, action)
+	}
+
+	command := init.serviceCommand(name, action)
+	if command == "" {
+		return nil
+	}
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *DebianProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	// the init scripts source the DOCKER_OPTS of a shell file instead of
+	// the systemd unit
+	if provisioner.initSystem() != initSystemd {
+		return provisioner.GenericProvisioner.GenerateDockerOptions(dockerPort)
+	}
+
+	return provisioner.SystemdProvisioner.GenerateDockerOptions(dockerPort)
+}
+
 func (provisioner *DebianProvisioner) Package(name string, action pkgaction.PackageAction) error {
 	var packageAction string
 
@@ -85,7 +128,7 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, auth
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
+	swarmOptions.Env = engineOptions.PlainEnv()
 
 	storageDriver, err := decideStorageDriver(provisioner, "aufs", engineOptions.StorageDriver)
 	if err != nil {
@@ -105,14 +148,28 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, auth
 	}
 
 	log.Debug("installing base packages")
-	for _, pkg := range provisioner.Packages {
+	for _, pkg := range basePackages(provisioner.Packages, engineOptions) {
 		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
 			return err
 		}
 	}
 
+	if engineOptions.IsPodman() {
+		provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+		return provisionPodman(provisioner)
+	}
+
+	if engineOptions.IsContainerd() {
+		provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+		return provisionContainerd(provisioner)
+	}
+
+	if engineOptions.Rootless {
+		return provisioner.provisionRootless(provisioner)
+	}
+
 	log.Debug("installing docker")
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+	if err := installEngine(provisioner, engineOptions); err != nil {
 		return err
 	}
 
@@ -121,6 +178,12 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, auth
 		return err
 	}
 
+	if engineOptions.GPU != "" {
+		if err := provisioner.installGPU(); err != nil {
+			return err
+		}
+	}
+
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
 	log.Debug("configuring auth")
@@ -133,8 +196,7 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, auth
 		return err
 	}
 
-	// enable in systemd
-	log.Debug("enabling docker in systemd")
+	log.Debug("enabling docker at boot")
 	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
 		return err
 	}
@@ -7,7 +7,9 @@ import (
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDebianDefaultStorageDriver(t *testing.T) {
@@ -18,3 +20,47 @@ func TestDebianDefaultStorageDriver(t *testing.T) {
 		t.Fatal("Default storage driver should be aufs")
 	}
 }
+
+func TestDebianServiceWithoutSystemd(t *testing.T) {
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			detectInitSystemCommand:            "sysvinit\n",
+			"sudo service docker restart":      "",
+			"sudo update-rc.d docker defaults": "",
+		},
+	}
+
+	assert.NoError(t, p.Service("docker", serviceaction.Restart))
+	assert.NoError(t, p.Service("docker", serviceaction.Enable))
+	assert.NoError(t, p.Service("docker", serviceaction.DaemonReload))
+	assert.Equal(t, "/etc/default/docker", p.DaemonOptionsFile)
+}
+
+func TestDebianGenerateDockerOptionsWithoutSystemd(t *testing.T) {
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			detectInitSystemCommand: "openrc\n",
+		},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/conf.d/docker", dockerCfg.EngineOptionsPath)
+	assert.Contains(t, dockerCfg.EngineOptions, "DOCKER_OPTS='")
+}
+
+func TestDebianServiceWithSystemd(t *testing.T) {
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			detectInitSystemCommand:         "systemd\n",
+			"sudo systemctl -f stop docker": "",
+		},
+	}
+
+	assert.NoError(t, p.Service("docker", serviceaction.Stop))
+	assert.Equal(t, "/etc/systemd/system/docker.service", p.DaemonOptionsFile)
+}
@@ -0,0 +1,115 @@
+package provision
+
+import (
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func init() {
+	Register("Devuan", &RegisteredProvisioner{
+		New: NewDevuanProvisioner,
+	})
+}
+
+func NewDevuanProvisioner(d drivers.Driver) Provisioner {
+	return &DevuanProvisioner{
+		DebianProvisioner{
+			SystemdProvisioner: NewSystemdProvisioner("devuan", d),
+		},
+	}
+}
+
+// DevuanProvisioner provisions Devuan, the Debian without systemd.  The
+// install script of get.docker.com doesn't support it, so the engine comes
+// from the docker.io package of its repositories, managed with its
+// sysvinit or OpenRC script.
+type DevuanProvisioner struct {
+	DebianProvisioner
+}
+
+func (provisioner *DevuanProvisioner) String() string {
+	return "devuan"
+}
+
+func (provisioner *DevuanProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	switch name {
+	case "docker":
+		name = "docker.io"
+	}
+
+	return provisioner.DebianProvisioner.Package(name, action)
+}
+
+func (provisioner *DevuanProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+	swarmOptions.Env = engineOptions.PlainEnv()
+
+	storageDriver, err := decideStorageDriver(provisioner, "overlay2", engineOptions.StorageDriver)
+	if err != nil {
+		return err
+	}
+	provisioner.EngineOptions.StorageDriver = storageDriver
+
+	log.Debug("installing sudo")
+	if _, err := provisioner.SSHCommand("if ! type sudo; then apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y sudo; fi"); err != nil {
+		return err
+	}
+
+	log.Debug("setting hostname")
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	log.Debug("installing base packages")
+	for _, pkg := range provisioner.Packages {
+		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Installing Docker...")
+	if err := provisioner.Package("docker", pkgaction.Install); err != nil {
+		return err
+	}
+
+	log.Debug("starting docker")
+	if err := provisioner.Service("docker", serviceaction.Start); err != nil {
+		return err
+	}
+
+	log.Debug("waiting for docker daemon")
+	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	log.Debug("configuring auth")
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring swarm")
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+		return err
+	}
+
+	log.Debug("enabling docker at boot")
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	return nil
+}
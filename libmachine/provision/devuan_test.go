@@ -0,0 +1,56 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevuanCompatibleWithHost(t *testing.T) {
+	info := &OsRelease{ID: "devuan", IDLike: "debian"}
+	devuan := NewDevuanProvisioner(nil)
+	devuan.SetOsReleaseInfo(info)
+	debian := NewDebianProvisioner(nil)
+	debian.SetOsReleaseInfo(info)
+
+	assert.True(t, devuan.CompatibleWithHost())
+	assert.False(t, debian.CompatibleWithHost())
+}
+
+func TestDevuanPackageDocker(t *testing.T) {
+	p := NewDevuanProvisioner(&fakedriver.Driver{}).(*DevuanProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo apt-get update": "",
+			"DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y  docker.io": "",
+		},
+	}
+
+	assert.NoError(t, p.Package("docker", pkgaction.Install))
+}
+
+func TestInitSystemServiceCommand(t *testing.T) {
+	assert.Equal(t, "sudo service docker start", initSysV.serviceCommand("docker", serviceaction.Start))
+	assert.Equal(t, "sudo update-rc.d -f docker remove", initSysV.serviceCommand("docker", serviceaction.Disable))
+	assert.Equal(t, "sudo rc-service docker restart", initOpenRC.serviceCommand("docker", serviceaction.Restart))
+	assert.Equal(t, "sudo rc-update add docker default", initOpenRC.serviceCommand("docker", serviceaction.Enable))
+	assert.Equal(t, "", initOpenRC.serviceCommand("docker", serviceaction.DaemonReload))
+}
+
+func TestDetectInitSystem(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			detectInitSystemCommand: "openrc\n",
+		},
+	}
+	assert.Equal(t, initOpenRC, detectInitSystem(commander))
+
+	commander.Responses[detectInitSystemCommand] = "unknown\n"
+	assert.Equal(t, initSystemd, detectInitSystem(commander))
+
+	assert.Equal(t, initSystemd, detectInitSystem(&provisiontest.FakeSSHCommander{}))
+}
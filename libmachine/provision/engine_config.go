@@ -0,0 +1,51 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+)
+
+// ConfigureEngineOptions writes the daemon.json rendered from the engine
+// options, e.g. its registry mirrors and insecure registries, and restarts
+// the engine.  Unlike ConfigureAuth, the engine isn't installed nor
+// configured again.  The engines of podman, containerd, rootless and Windows
+// machines, and the ones provisioned before their options moved to
+// daemon.json, return ErrEngineConfigNotSupported.
+func ConfigureEngineOptions(p Provisioner, engineOptions engine.Options) error {
+	if !ManagesDockerd(p, engineOptions) {
+		return ErrEngineConfigNotSupported
+	}
+
+	configPath := daemonConfigPath(p)
+	if _, err := p.SSHCommand("sudo test -f " + configPath); err != nil {
+		return ErrEngineConfigNotSupported
+	}
+
+	// The label the provisioners add when generating the engine options.
+	engineOptions.Labels = append(append([]string{}, engineOptions.Labels...), fmt.Sprintf("provider=%s", p.GetDriver().DriverName()))
+
+	daemonConfig, err := generateDaemonConfig(engineOptions)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Setting Docker configuration on the remote daemon...")
+	if err := writeRemoteFile(p, daemonConfig, configPath); err != nil {
+		return err
+	}
+
+	log.Info("Restarting the engine with its new configuration...")
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
@@ -0,0 +1,70 @@
+package provision
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureEngineOptions(t *testing.T) {
+	commander := &recordingSSHCommander{}
+	driver := &fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"}
+	p := NewUbuntuSystemdProvisioner(driver).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	engineOptions := engine.Options{
+		Labels:           []string{"env=dev"},
+		RegistryMirror:   []string{"https://mirror.example.com"},
+		InsecureRegistry: []string{"registry.local:5000"},
+	}
+
+	assert.NoError(t, ConfigureEngineOptions(p, engineOptions))
+	assert.Equal(t, []string{"env=dev"}, engineOptions.Labels)
+
+	expected, err := generateDaemonConfig(engine.Options{
+		Labels:           []string{"env=dev", "provider=" + driver.DriverName()},
+		RegistryMirror:   []string{"https://mirror.example.com"},
+		InsecureRegistry: []string{"registry.local:5000"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "sudo test -f /etc/docker/daemon.json", commander.commands[0])
+	assert.Contains(t, commander.commands[1], base64.StdEncoding.EncodeToString([]byte(expected)))
+	assert.Contains(t, commander.commands[1], "sudo tee /etc/docker/daemon.json")
+	assert.Contains(t, commander.commands, "sudo systemctl -f restart docker")
+}
+
+// missingFileSSHCommander fails the tests of the existence of files.
+type missingFileSSHCommander struct {
+	recordingSSHCommander
+}
+
+func (c *missingFileSSHCommander) SSHCommand(args string) (string, error) {
+	c.commands = append(c.commands, args)
+	if args == "sudo test -f /etc/docker/daemon.json" {
+		return "", errors.New("exit status 1")
+	}
+	return "", nil
+}
+
+func TestConfigureEngineOptionsNotSupported(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+
+	err := ConfigureEngineOptions(p, engine.Options{ContainerRuntime: engine.RuntimeContainerd})
+	assert.Equal(t, ErrEngineConfigNotSupported, err)
+
+	err = ConfigureEngineOptions(p, engine.Options{Rootless: true})
+	assert.Equal(t, ErrEngineConfigNotSupported, err)
+
+	commander := &missingFileSSHCommander{}
+	p.SSHCommander = commander
+
+	err = ConfigureEngineOptions(p, engine.Options{})
+	assert.Equal(t, ErrEngineConfigNotSupported, err)
+	assert.Len(t, commander.commands, 1)
+}
@@ -0,0 +1,99 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+)
+
+const (
+	proxyDropInDir   = "/etc/systemd/system/docker.service.d"
+	proxyDropInFile  = proxyDropInDir + "/http-proxy.conf"
+	openRCConfigFile = "/etc/conf.d/docker"
+)
+
+// The proxy settings are kept in their own file, a systemd drop-in or lines of
+// the OpenRC configuration of the service, so that they are changed without
+// generating the engine configuration again.
+var proxyDropInTemplate = `[Service]
+Environment={{range .}}{{ printf "%q" . }} {{end}}
+`
+
+// generateProxyDropIn renders the systemd drop-in setting the proxy of the
+// engine.
+func generateProxyDropIn(engineOptions engine.Options) (string, error) {
+	var dropIn bytes.Buffer
+
+	t, err := template.New("proxyDropIn").Parse(proxyDropInTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.Execute(&dropIn, engineOptions.ProxyEnv()); err != nil {
+		return "", err
+	}
+
+	return dropIn.String(), nil
+}
+
+// openRCProxyCommand returns the command replacing the proxy settings of the
+// OpenRC configuration of the engine, whose other settings are kept.
+func openRCProxyCommand(engineOptions engine.Options) string {
+	command := fmt.Sprintf(`sudo touch %[1]s && sudo sed -i '/^export \(HTTP_PROXY\|HTTPS_PROXY\|NO_PROXY\)=/d' %[1]s`, openRCConfigFile)
+	for _, env := range engineOptions.ProxyEnv() {
+		command += fmt.Sprintf(` && echo 'export %s' | sudo tee -a %s`, env, openRCConfigFile)
+	}
+	return command
+}
+
+// ConfigureEngineProxy writes the proxy settings of the engine, or removes
+// them when none is set, and restarts the engine.  The engines of podman,
+// containerd, rootless and Windows machines, which aren't configured by the
+// docker service, return ErrEngineProxyNotSupported.
+func ConfigureEngineProxy(p Provisioner, engineOptions engine.Options) error {
+	if !ManagesDockerd(p, engineOptions) {
+		return ErrEngineProxyNotSupported
+	}
+
+	if _, err := p.SSHCommand("test -d /run/systemd/system"); err == nil {
+		if len(engineOptions.ProxyEnv()) == 0 {
+			log.Debug("removing the engine proxy drop-in")
+			if _, err := p.SSHCommand("sudo rm -f " + proxyDropInFile); err != nil {
+				return err
+			}
+		} else {
+			dropIn, err := generateProxyDropIn(engineOptions)
+			if err != nil {
+				return err
+			}
+
+			log.Debug("writing the engine proxy drop-in")
+			if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s && printf %%s '%s' | sudo tee %s", proxyDropInDir, dropIn, proxyDropInFile)); err != nil {
+				return err
+			}
+		}
+	} else if _, err := p.SSHCommand("test -x /sbin/openrc-run"); err == nil {
+		log.Debug("writing the engine proxy settings to the OpenRC configuration")
+		if _, err := p.SSHCommand(openRCProxyCommand(engineOptions)); err != nil {
+			return err
+		}
+	} else {
+		return ErrEngineProxyNotSupported
+	}
+
+	log.Info("Restarting the engine with its proxy settings...")
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
@@ -0,0 +1,49 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateProxyDropIn(t *testing.T) {
+	dropIn, err := generateProxyDropIn(engine.Options{
+		HTTPProxy: "http://proxy:3128",
+		NoProxy:   "localhost,10.0.0.0/8",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "[Service]\nEnvironment=\"HTTP_PROXY=http://proxy:3128\" \"NO_PROXY=localhost,10.0.0.0/8\" \n", dropIn)
+}
+
+func TestOpenRCProxyCommand(t *testing.T) {
+	command := openRCProxyCommand(engine.Options{HTTPSProxy: "http://proxy:3128"})
+
+	assert.True(t, strings.HasPrefix(command, "sudo touch /etc/conf.d/docker && sudo sed -i"))
+	assert.True(t, strings.HasSuffix(command, " && echo 'export HTTPS_PROXY=http://proxy:3128' | sudo tee -a /etc/conf.d/docker"))
+}
+
+func TestConfigureEngineProxy(t *testing.T) {
+	commander := &recordingSSHCommander{}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	assert.NoError(t, ConfigureEngineProxy(p, engine.Options{HTTPProxy: "http://proxy:3128"}))
+	assert.Contains(t, commander.commands[1], "sudo tee /etc/systemd/system/docker.service.d/http-proxy.conf")
+	assert.Contains(t, commander.commands, "sudo systemctl -f restart docker")
+
+	commander.commands = nil
+	assert.NoError(t, ConfigureEngineProxy(p, engine.Options{}))
+	assert.Equal(t, "sudo rm -f /etc/systemd/system/docker.service.d/http-proxy.conf", commander.commands[1])
+}
+
+func TestConfigureEngineProxyNotSupported(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+
+	err := ConfigureEngineProxy(p, engine.Options{ContainerRuntime: engine.RuntimePodman, HTTPProxy: "http://proxy:3128"})
+	assert.Equal(t, ErrEngineProxyNotSupported, err)
+}
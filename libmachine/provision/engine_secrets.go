@@ -0,0 +1,107 @@
+package provision
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/secrets"
+)
+
+const (
+	secretsEnvFile            = "/etc/docker/machine-secrets.env"
+	secretsDropInFile         = proxyDropInDir + "/machine-secrets.conf"
+	boot2dockerSecretsEnvFile = "/var/lib/boot2docker/machine-secrets.env"
+)
+
+// The secrets are only readable by root, in a file of their own, and are
+// never part of a command line, which is logged.
+var secretsDropIn = fmt.Sprintf("[Service]\nEnvironmentFile=%s\n", secretsEnvFile)
+
+// writeSecretsFile writes content to a file of the machine only readable by
+// root.  It's a variable so that the tests can record the files.
+var writeSecretsFile = func(p Provisioner, content, filePath string) error {
+	command := fmt.Sprintf("sudo sh -c 'umask 077 && mkdir -p %s && cat > %[2]s && chmod 600 %[2]s'", path.Dir(filePath), filePath)
+	_, err := drivers.RunSSHCommandWithInputFromDriver(p.GetDriver(), command, strings.NewReader(content))
+	return err
+}
+
+// systemdEnvFile renders the environment variables as an EnvironmentFile of
+// systemd, whose double quoted values keep their line breaks.
+func systemdEnvFile(env []string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+	var content string
+	for _, e := range env {
+		parts := strings.SplitN(e, "=", 2)
+		content += fmt.Sprintf("%s=\"%s\"\n", parts[0], escaper.Replace(parts[1]))
+	}
+	return content
+}
+
+// shellEnvFile renders the environment variables as a shell script sourced
+// by the init script of the engine.
+func shellEnvFile(env []string) string {
+	var content string
+	for _, e := range env {
+		parts := strings.SplitN(e, "=", 2)
+		content += fmt.Sprintf("export %s='%s'\n", parts[0], strings.Replace(parts[1], "'", `'\''`, -1))
+	}
+	return content
+}
+
+// configureEngineSecrets resolves the references to secrets of the engine
+// environment and writes the secrets to a file read by the docker service.
+// Only the references are kept in the configuration of the machine.
+func configureEngineSecrets(p Provisioner) error {
+	engineOptions := p.GetEngineOptions()
+
+	secretEnv := engineOptions.SecretEnv()
+	if len(secretEnv) == 0 {
+		return nil
+	}
+
+	if !ManagesDockerd(p, engineOptions) {
+		return ErrEngineSecretsNotSupported
+	}
+
+	log.Info("Resolving the secrets of the engine environment...")
+
+	env, err := secrets.ResolveEnv(secretEnv)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := p.(*Boot2DockerProvisioner); ok {
+		// the profile of the engine sources the file
+		return writeSecretsFile(p, shellEnvFile(env), boot2dockerSecretsEnvFile)
+	}
+
+	if _, err := p.SSHCommand("test -d /run/systemd/system"); err == nil {
+		if err := writeSecretsFile(p, systemdEnvFile(env), secretsEnvFile); err != nil {
+			return err
+		}
+
+		log.Debug("writing the engine secrets drop-in")
+		if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s && printf %%s '%s' | sudo tee %s && sudo systemctl daemon-reload", proxyDropInDir, secretsDropIn, secretsDropInFile)); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if _, err := p.SSHCommand("test -x /sbin/openrc-run"); err == nil {
+		if err := writeSecretsFile(p, shellEnvFile(env), secretsEnvFile); err != nil {
+			return err
+		}
+
+		log.Debug("sourcing the engine secrets from the OpenRC configuration")
+		if _, err := p.SSHCommand(fmt.Sprintf("sudo touch %[1]s && (grep -qxF '. %[2]s' %[1]s || echo '. %[2]s' | sudo tee -a %[1]s)", openRCConfigFile, secretsEnvFile)); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	return ErrEngineSecretsNotSupported
+}
@@ -0,0 +1,111 @@
+package provision
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+// openRCSSHCommander records the commands of a machine without systemd.
+type openRCSSHCommander struct {
+	recordingSSHCommander
+}
+
+func (c *openRCSSHCommander) SSHCommand(args string) (string, error) {
+	if args == "test -d /run/systemd/system" {
+		return "", errors.New("exit status 1")
+	}
+	return c.recordingSSHCommander.SSHCommand(args)
+}
+
+func withSecretFile(t *testing.T, content string) (string, map[string]string, func()) {
+	dir, err := ioutil.TempDir("", "machine-secrets-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	originalWriteSecretsFile := writeSecretsFile
+	writeSecretsFile = func(p Provisioner, content, filePath string) error {
+		files[filePath] = content
+		return nil
+	}
+
+	return path, files, func() {
+		os.RemoveAll(dir)
+		writeSecretsFile = originalWriteSecretsFile
+	}
+}
+
+func TestEnvFiles(t *testing.T) {
+	env := []string{"PASSWORD=it's \"$3cret\"", "KEY=line1\nline2"}
+
+	assert.Equal(t, "PASSWORD=\"it's \\\"\\$3cret\\\"\"\nKEY=\"line1\nline2\"\n", systemdEnvFile(env))
+	assert.Equal(t, "export PASSWORD='it'\\''s \"$3cret\"'\nexport KEY='line1\nline2'\n", shellEnvFile(env))
+}
+
+func TestConfigureEngineSecretsSystemd(t *testing.T) {
+	secretFile, files, cleanup := withSecretFile(t, "s3cret\n")
+	defer cleanup()
+
+	commander := &recordingSSHCommander{}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+	p.EngineOptions = engine.Options{Env: []string{"DEBUG=1", "PASSWORD=file://" + secretFile}}
+
+	assert.NoError(t, configureEngineSecrets(p))
+	assert.Equal(t, map[string]string{"/etc/docker/machine-secrets.env": "PASSWORD=\"s3cret\"\n"}, files)
+	assert.Contains(t, commander.commands[1], "sudo tee /etc/systemd/system/docker.service.d/machine-secrets.conf")
+	for _, command := range commander.commands {
+		assert.NotContains(t, command, "s3cret")
+	}
+}
+
+func TestConfigureEngineSecretsOpenRC(t *testing.T) {
+	secretFile, files, cleanup := withSecretFile(t, "s3cret")
+	defer cleanup()
+
+	commander := &openRCSSHCommander{}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+	p.EngineOptions = engine.Options{Env: []string{"PASSWORD=file://" + secretFile}}
+
+	assert.NoError(t, configureEngineSecrets(p))
+	assert.Equal(t, map[string]string{"/etc/docker/machine-secrets.env": "export PASSWORD='s3cret'\n"}, files)
+	assert.Equal(t, "sudo touch /etc/conf.d/docker && (grep -qxF '. /etc/docker/machine-secrets.env' /etc/conf.d/docker || echo '. /etc/docker/machine-secrets.env' | sudo tee -a /etc/conf.d/docker)", commander.commands[1])
+}
+
+func TestConfigureEngineSecretsNone(t *testing.T) {
+	commander := &recordingSSHCommander{}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+	p.EngineOptions = engine.Options{Env: []string{"DEBUG=1"}}
+
+	assert.NoError(t, configureEngineSecrets(p))
+	assert.Empty(t, commander.commands)
+}
+
+func TestConfigureEngineSecretsNotSupported(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.EngineOptions = engine.Options{Env: []string{"PASSWORD=file:///run/password"}, ContainerRuntime: engine.RuntimePodman}
+
+	assert.Equal(t, ErrEngineSecretsNotSupported, configureEngineSecrets(p))
+}
+
+func TestEngineOptionsSecretEnv(t *testing.T) {
+	options := engine.Options{Env: []string{"DEBUG=1", "PASSWORD=vault://secret/data/registry#password", "PROXY=http://proxy:3128"}}
+
+	assert.Equal(t, []string{"DEBUG=1", "PROXY=http://proxy:3128"}, options.PlainEnv())
+	assert.Equal(t, []string{"PASSWORD=vault://secret/data/registry#password"}, options.SecretEnv())
+}
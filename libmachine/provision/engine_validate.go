@@ -0,0 +1,56 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/engine/validate"
+)
+
+// kernelFilesystemCommand prints yes if the kernel supports a filesystem,
+// built in or as a module not loaded yet, and no otherwise.
+const kernelFilesystemCommand = "if grep -qw %[1]s /proc/filesystems || sudo modprobe -n %[1]s 2>/dev/null; then echo yes; else echo no; fi"
+
+// kernelSupports returns whether the kernel of the machine supports the
+// filesystem, and false as second value if it couldn't be told.
+func kernelSupports(p SSHCommander, filesystem string) (bool, bool) {
+	output, err := p.SSHCommand(fmt.Sprintf(kernelFilesystemCommand, filesystem))
+	if err != nil {
+		return false, false
+	}
+	return strings.TrimSpace(output) == "yes", true
+}
+
+// engineTarget queries the version of the installed engine and the kernel
+// of the machine, with the support of the filesystem the storage driver
+// requires.  What can't be queried is left unknown.
+func engineTarget(p SSHCommander, engineOptions engine.Options) validate.Target {
+	target := validate.Target{
+		Filesystems: map[string]bool{},
+	}
+
+	if output, err := p.SSHCommand("dockerd --version"); err == nil {
+		target.EngineVersion = validate.EngineVersion(output)
+	}
+
+	if output, err := p.SSHCommand("uname -r"); err == nil {
+		target.Kernel = strings.TrimSpace(output)
+	}
+
+	if filesystem := validate.Filesystem(engineOptions.StorageDriver); filesystem != "" {
+		if supported, known := kernelSupports(p, filesystem); known {
+			target.Filesystems[filesystem] = supported
+		}
+	}
+
+	return target
+}
+
+// validateEngineOptions checks the options of the engine against the
+// installed engine and the kernel, before the engine is configured and
+// restarted with them.
+func validateEngineOptions(p Provisioner) error {
+	engineOptions := p.GetEngineOptions()
+	return validate.Options(&engineOptions, engineTarget(p, engineOptions))
+}
@@ -0,0 +1,92 @@
+package provision
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+var engineVersionRegexp = regexp.MustCompile(`^[0-9]+\.[0-9]+(\.[0-9]+)?$`)
+
+// engineVersionInstaller is implemented by the provisioners which can
+// install a given version of the engine, upgrading or downgrading it.
+type engineVersionInstaller interface {
+	installEngineVersion(version string) error
+}
+
+// ValidateEngineVersion checks a version given with --engine-version, e.g.
+// 24.0.7.
+func ValidateEngineVersion(version string) error {
+	if !engineVersionRegexp.MatchString(version) {
+		return fmt.Errorf("Error: invalid engine version %q, expected e.g. 24.0.7", version)
+	}
+
+	return nil
+}
+
+// UpgradeDocker upgrades the engine to the latest version of its package, or
+// to the given version, which may be a downgrade.
+func UpgradeDocker(p Provisioner, version string) error {
+	if version == "" {
+		return p.Package("docker", pkgaction.Upgrade)
+	}
+
+	if err := ValidateEngineVersion(version); err != nil {
+		return err
+	}
+
+	installer, ok := p.(engineVersionInstaller)
+	if !ok {
+		return fmt.Errorf("Error: installing a given engine version is not supported on %s", p.String())
+	}
+
+	log.Debugf("installing engine version %s", version)
+
+	return installer.installEngineVersion(version)
+}
+
+// aptEngineVersionCommand installs the docker-ce packages whose version, in
+// the form [epoch:]24.0.7-1~ubuntu.22.04~jammy, matches the engine version.
+func aptEngineVersionCommand(version string) string {
+	pattern := strings.Replace(version, ".", `\.`, -1)
+
+	return fmt.Sprintf("sudo apt-get update && "+
+		"VERSION=$(apt-cache madison docker-ce | awk '{print $3}' | grep -m1 -E '^([0-9]+:)?%s([-~]|$)') && "+
+		"DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y --allow-downgrades docker-ce=$VERSION docker-ce-cli=$VERSION",
+		pattern)
+}
+
+// yumEngineVersionCommand installs the docker-ce packages of the engine
+// version, yum refusing to install an older version than the installed one.
+func yumEngineVersionCommand(version string) string {
+	packages := fmt.Sprintf("docker-ce-%s docker-ce-cli-%s", version, version)
+
+	return fmt.Sprintf("sudo -E yum install -y %s || sudo -E yum downgrade -y %s", packages, packages)
+}
+
+func (provisioner *DebianProvisioner) installEngineVersion(version string) error {
+	_, err := provisioner.SSHCommand(aptEngineVersionCommand(version))
+	return err
+}
+
+func (provisioner *UbuntuSystemdProvisioner) installEngineVersion(version string) error {
+	_, err := provisioner.SSHCommand(aptEngineVersionCommand(version))
+	return err
+}
+
+func (provisioner *UbuntuProvisioner) installEngineVersion(version string) error {
+	_, err := provisioner.SSHCommand(aptEngineVersionCommand(version))
+	return err
+}
+
+func (provisioner *RedHatProvisioner) installEngineVersion(version string) error {
+	_, err := provisioner.SSHCommand(yumEngineVersionCommand(version))
+	return err
+}
+
+func (provisioner *WindowsProvisioner) installEngineVersion(version string) error {
+	return provisioner.installDocker(true, version)
+}
@@ -0,0 +1,46 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEngineVersion(t *testing.T) {
+	assert.NoError(t, ValidateEngineVersion("24.0.7"))
+	assert.NoError(t, ValidateEngineVersion("20.10"))
+	assert.Error(t, ValidateEngineVersion("latest"))
+	assert.Error(t, ValidateEngineVersion("24.0.7 && reboot"))
+}
+
+func TestUpgradeDockerVersionApt(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo apt-get update && " +
+				"VERSION=$(apt-cache madison docker-ce | awk '{print $3}' | grep -m1 -E '^([0-9]+:)?24\\.0\\.7([-~]|$)') && " +
+				"DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y --allow-downgrades docker-ce=$VERSION docker-ce-cli=$VERSION": "",
+		},
+	}
+
+	assert.NoError(t, UpgradeDocker(p, "24.0.7"))
+}
+
+func TestUpgradeDockerVersionYum(t *testing.T) {
+	p := NewCentosProvisioner(&fakedriver.Driver{}).(*CentosProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo -E yum install -y docker-ce-24.0.7 docker-ce-cli-24.0.7 || sudo -E yum downgrade -y docker-ce-24.0.7 docker-ce-cli-24.0.7": "",
+		},
+	}
+
+	assert.NoError(t, UpgradeDocker(p, "24.0.7"))
+}
+
+func TestUpgradeDockerVersionNotSupported(t *testing.T) {
+	p := NewArchProvisioner(&fakedriver.Driver{})
+
+	assert.EqualError(t, UpgradeDocker(p, "24.0.7"), "Error: installing a given engine version is not supported on arch")
+}
@@ -0,0 +1,242 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// The rebuilds of RHEL, such as Rocky Linux and AlmaLinux, aren't supported
+// by the install script of get.docker.com.  The engine is installed from
+// the docker-ce repository of CentOS with dnf instead, the port of the
+// engine is opened in firewalld, and containers are labeled when SELinux
+// is enforcing.
+
+const (
+	dockerCERepoURL = "https://download.docker.com/linux/centos/docker-ce.repo"
+
+	enterpriseLinuxEngineConfigTemplate = `[Unit]
+Description=Docker Application Container Engine
+After=network-online.target firewalld.service containerd.service
+Wants=network-online.target
+
+[Service]
+Type=notify
+ExecStart=/usr/bin/dockerd -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --config-file {{.DaemonConfigPath}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}
+ExecReload=/bin/kill -s HUP $MAINPID
+LimitNOFILE=infinity
+LimitNPROC=infinity
+LimitCORE=infinity
+TimeoutStartSec=0
+Delegate=yes
+KillMode=process
+Environment={{range .EngineOptions.PlainEnv}}{{ printf "%q" . }} {{end}}
+
+[Install]
+WantedBy=multi-user.target
+`
+)
+
+var dockerCEPackages = []string{"docker-ce", "docker-ce-cli", "containerd.io"}
+
+func NewEnterpriseLinuxProvisioner(osReleaseID string, d drivers.Driver) *EnterpriseLinuxProvisioner {
+	return &EnterpriseLinuxProvisioner{
+		NewRedHatProvisioner(osReleaseID, d),
+	}
+}
+
+// EnterpriseLinuxProvisioner provisions the dnf-based rebuilds of RHEL.
+type EnterpriseLinuxProvisioner struct {
+	*RedHatProvisioner
+}
+
+func (provisioner *EnterpriseLinuxProvisioner) String() string {
+	return provisioner.OsReleaseID
+}
+
+func (provisioner *EnterpriseLinuxProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	var packageAction string
+
+	switch action {
+	case pkgaction.Install:
+		packageAction = "install"
+	case pkgaction.Remove:
+		packageAction = "remove"
+	case pkgaction.Upgrade:
+		packageAction = "upgrade"
+	}
+
+	switch name {
+	case "docker":
+		name = strings.Join(dockerCEPackages, " ")
+	}
+
+	command := fmt.Sprintf("sudo -E dnf %s -y %s", packageAction, name)
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *EnterpriseLinuxProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+	swarmOptions.Env = engineOptions.PlainEnv()
+
+	storageDriver, err := decideStorageDriver(provisioner, "overlay2", engineOptions.StorageDriver)
+	if err != nil {
+		return err
+	}
+	provisioner.EngineOptions.StorageDriver = storageDriver
+
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	for _, pkg := range basePackages(provisioner.Packages, engineOptions) {
+		log.Debugf("installing base package: name=%s", pkg)
+		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	if err := openFirewalldPort(provisioner); err != nil {
+		return err
+	}
+
+	if engineOptions.IsPodman() {
+		provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+		return provisionPodman(provisioner)
+	}
+
+	if engineOptions.IsContainerd() {
+		provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+		return provisionContainerd(provisioner)
+	}
+
+	if engineOptions.Rootless {
+		return provisioner.provisionRootless(provisioner)
+	}
+
+	log.Info("Installing Docker...")
+	if err := provisioner.installDockerCE(); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker", serviceaction.Restart); err != nil {
+		return err
+	}
+
+	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+		return err
+	}
+
+	if engineOptions.GPU != "" {
+		if err := provisioner.installGPU(); err != nil {
+			return err
+		}
+	}
+
+	if err := configureSELinux(provisioner, &provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+}
+
+// installDockerCE installs the engine from the docker-ce repository, unless
+// it's already installed or another install script or a bundle was given.
+func (provisioner *EnterpriseLinuxProvisioner) installDockerCE() error {
+	engineOptions := provisioner.EngineOptions
+	if engineOptions.InstallBundle != "" || (engineOptions.InstallURL != "" && engineOptions.InstallURL != drivers.DefaultEngineInstallURL) {
+		return installEngine(provisioner, engineOptions)
+	}
+
+	if _, err := provisioner.SSHCommand("type docker"); err == nil {
+		return nil
+	}
+
+	log.Debug("adding the docker-ce repository")
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo -E dnf install -y dnf-plugins-core && sudo dnf config-manager --add-repo %s", dockerCERepoURL)); err != nil {
+		return fmt.Errorf("Error adding the docker-ce repository: %s", err)
+	}
+
+	return provisioner.Package("docker", pkgaction.Install)
+}
+
+func (provisioner *EnterpriseLinuxProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var engineCfg bytes.Buffer
+
+	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
+	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+
+	t, err := template.New("engineConfig").Parse(enterpriseLinuxEngineConfigTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	daemonConfig, err := generateDaemonConfig(provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	engineConfigContext := EngineConfigContext{
+		DockerPort:       dockerPort,
+		AuthOptions:      provisioner.AuthOptions,
+		EngineOptions:    provisioner.EngineOptions,
+		DockerOptionsDir: provisioner.DockerOptionsDir,
+		DaemonConfigPath: daemonConfigPath(provisioner),
+	}
+
+	t.Execute(&engineCfg, engineConfigContext)
+
+	return &DockerOptions{
+		EngineOptions:     engineCfg.String(),
+		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
+	}, nil
+}
+
+// openFirewalldPort opens the port of the engine in firewalld, if it's
+// running.
+func openFirewalldPort(p Provisioner) error {
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("opening port %d in firewalld", dockerPort)
+	if _, err := p.SSHCommand(fmt.Sprintf("if sudo firewall-cmd --state >/dev/null 2>&1; then sudo firewall-cmd --permanent --add-port=%d/tcp && sudo firewall-cmd --reload; fi", dockerPort)); err != nil {
+		return fmt.Errorf("Error opening the port of the engine in firewalld: %s", err)
+	}
+
+	return nil
+}
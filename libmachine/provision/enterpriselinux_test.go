@@ -0,0 +1,151 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// enterpriseLinuxSSHCommander records the commands of a machine without
+// docker, answering getenforce with selinux.
+type enterpriseLinuxSSHCommander struct {
+	commands []string
+	selinux  string
+}
+
+func (c *enterpriseLinuxSSHCommander) SSHCommand(args string) (string, error) {
+	c.commands = append(c.commands, args)
+
+	switch args {
+	case "type docker":
+		return "", errors.New("docker: not found")
+	case "getenforce 2>/dev/null || echo Disabled":
+		return c.selinux + "\n", nil
+	}
+
+	return "", nil
+}
+
+func newTestRockyLinuxProvisioner(commander SSHCommander) *RockyLinuxProvisioner {
+	p := NewRockyLinuxProvisioner(&fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"}).(*RockyLinuxProvisioner)
+	p.SSHCommander = commander
+	return p
+}
+
+func TestEnterpriseLinuxCompatibleWithHost(t *testing.T) {
+	rocky := NewRockyLinuxProvisioner(nil)
+	rocky.SetOsReleaseInfo(&OsRelease{ID: "rocky", VersionID: "9.3"})
+	assert.True(t, rocky.CompatibleWithHost())
+	assert.Equal(t, "rocky", rocky.String())
+
+	rocky.SetOsReleaseInfo(&OsRelease{ID: "centos", VersionID: "9"})
+	assert.False(t, rocky.CompatibleWithHost())
+
+	alma := NewAlmaLinuxProvisioner(nil)
+	alma.SetOsReleaseInfo(&OsRelease{ID: "almalinux", VersionID: "9.3"})
+	assert.True(t, alma.CompatibleWithHost())
+	assert.Equal(t, "almalinux", alma.String())
+
+	assert.True(t, SupportsGPU(alma))
+	assert.True(t, SupportsInstallBundle(alma))
+}
+
+func TestEnterpriseLinuxPackage(t *testing.T) {
+	commander := &enterpriseLinuxSSHCommander{}
+	p := newTestRockyLinuxProvisioner(commander)
+
+	assert.NoError(t, p.Package("curl", pkgaction.Install))
+	assert.NoError(t, p.Package("docker", pkgaction.Upgrade))
+	assert.Equal(t, []string{
+		"sudo -E dnf install -y curl",
+		"sudo -E dnf upgrade -y docker-ce docker-ce-cli containerd.io",
+	}, commander.commands)
+}
+
+func TestEnterpriseLinuxInstallDockerCE(t *testing.T) {
+	commander := &enterpriseLinuxSSHCommander{}
+	p := newTestRockyLinuxProvisioner(commander)
+	p.EngineOptions = engine.Options{InstallURL: "https://get.docker.com"}
+
+	assert.NoError(t, p.installDockerCE())
+	assert.Equal(t, []string{
+		"type docker",
+		"sudo -E dnf install -y dnf-plugins-core && sudo dnf config-manager --add-repo https://download.docker.com/linux/centos/docker-ce.repo",
+		"sudo -E dnf install -y docker-ce docker-ce-cli containerd.io",
+	}, commander.commands)
+
+	commander.commands = nil
+	p.EngineOptions = engine.Options{InstallURL: "https://mirror.local/install.sh"}
+	assert.NoError(t, p.installDockerCE())
+	assert.Equal(t, []string{"if ! type docker; then curl -sSL https://mirror.local/install.sh | sh -; fi"}, commander.commands)
+}
+
+func TestEnterpriseLinuxConfigureSELinux(t *testing.T) {
+	p := newTestRockyLinuxProvisioner(&enterpriseLinuxSSHCommander{selinux: "Permissive"})
+	assert.NoError(t, configureSELinux(p, &p.EngineOptions))
+	assert.False(t, p.EngineOptions.SelinuxEnabled)
+
+	commander := &enterpriseLinuxSSHCommander{selinux: "Enforcing"}
+	p = newTestRockyLinuxProvisioner(commander)
+	assert.NoError(t, configureSELinux(p, &p.EngineOptions))
+	assert.True(t, p.EngineOptions.SelinuxEnabled)
+	assert.Equal(t, []string{
+		"getenforce 2>/dev/null || echo Disabled",
+		"sudo -E dnf install -y container-selinux",
+	}, commander.commands)
+}
+
+func TestEnterpriseLinuxConfigureSELinuxDataRoot(t *testing.T) {
+	commander := &enterpriseLinuxSSHCommander{selinux: "Enforcing"}
+	p := newTestRockyLinuxProvisioner(commander)
+	p.EngineOptions = engine.Options{ArbitraryFlags: []string{"data-root=/data/docker/"}}
+
+	assert.NoError(t, configureSELinux(p, &p.EngineOptions))
+	assert.Equal(t, []string{
+		"getenforce 2>/dev/null || echo Disabled",
+		"sudo -E dnf install -y container-selinux",
+		"command -v semanage",
+		"sudo mkdir -p /data/docker",
+		"(sudo semanage fcontext -a -e /var/lib/docker /data/docker 2>/dev/null || sudo semanage fcontext -m -e /var/lib/docker /data/docker)",
+		"sudo restorecon -R /data/docker",
+		"stat -f -c %T /data/docker",
+	}, commander.commands)
+}
+
+func TestEnterpriseLinuxConfigureSELinuxBtrfs(t *testing.T) {
+	p := newTestRockyLinuxProvisioner(&enterpriseLinuxSSHCommander{selinux: "Enforcing"})
+	p.EngineOptions = engine.Options{StorageDriver: "btrfs"}
+
+	assert.NoError(t, configureSELinux(p, &p.EngineOptions))
+	assert.False(t, p.EngineOptions.SelinuxEnabled)
+}
+
+func TestOpenFirewalldPort(t *testing.T) {
+	commander := &enterpriseLinuxSSHCommander{}
+	p := newTestRockyLinuxProvisioner(commander)
+
+	assert.NoError(t, openFirewalldPort(p))
+	assert.Equal(t, []string{
+		"if sudo firewall-cmd --state >/dev/null 2>&1; then sudo firewall-cmd --permanent --add-port=2376/tcp && sudo firewall-cmd --reload; fi",
+	}, commander.commands)
+}
+
+func TestEnterpriseLinuxGenerateDockerOptions(t *testing.T) {
+	p := newTestRockyLinuxProvisioner(&enterpriseLinuxSSHCommander{})
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/docker.service", dockerCfg.EngineOptionsPath)
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd -H tcp://0.0.0.0:2376 -H unix:///var/run/docker.sock --config-file /etc/docker/daemon.json --tlsverify --tlscacert /etc/docker/ca.pem")
+}
@@ -6,7 +6,14 @@ import (
 )
 
 var (
-	ErrDetectionFailed = errors.New("OS type not recognized")
+	ErrDetectionFailed           = errors.New("OS type not recognized")
+	ErrCertRotationNotSupported  = errors.New("The server certificate can't be rotated without provisioning the machine")
+	ErrEngineProxyNotSupported   = errors.New("The proxy of the engine can't be configured on this machine")
+	ErrEngineSecretsNotSupported = errors.New("The secrets of the engine environment can't be written on this machine")
+	ErrEngineConfigNotSupported  = errors.New("The engine options can't be changed without provisioning the machine")
+	ErrSSHCANotSupported         = errors.New("The SSH certificate authority can't be installed on Windows machines")
+	ErrGPUNotSupported           = errors.New("The GPU driver can only be installed on Ubuntu, Debian and the distributions of the Red Hat family")
+	ErrInstallBundleNotSupported = errors.New("The engine can only be installed from a bundle on Ubuntu, Debian, SUSE and the distributions of the Red Hat family")
 )
 
 type ErrDaemonAvailable struct {
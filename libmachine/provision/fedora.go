@@ -23,3 +23,8 @@ type FedoraProvisioner struct {
 func (provisioner *FedoraProvisioner) String() string {
 	return "fedora"
 }
+
+func (provisioner *FedoraProvisioner) CompatibleWithHost() bool {
+	// Fedora CoreOS reports the same ID but is handled by its own provisioner
+	return provisioner.OsReleaseInfo.ID == provisioner.OsReleaseID && provisioner.OsReleaseInfo.VariantID != fedoraCoreOSVariantID
+}
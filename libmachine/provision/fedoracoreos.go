@@ -0,0 +1,155 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+const (
+	fedoraCoreOSVariantID = "coreos"
+
+	// Fedora CoreOS ships the moby engine preinstalled.  Since rpm-ostree
+	// systems can't install packages the regular way, the engine is
+	// configured with a systemd drop-in overriding the stock unit instead.
+	fedoraCoreOSEngineDropInDir = "/etc/systemd/system/docker.service.d"
+
+	fedoraCoreOSEngineConfigTemplate = `[Service]
+ExecStart=
+ExecStart=/usr/bin/dockerd --host=fd:// --host=tcp://0.0.0.0:{{.DockerPort}} --exec-opt native.cgroupdriver=systemd --config-file {{.DaemonConfigPath}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}
+Environment={{range .EngineOptions.PlainEnv}}{{ printf "%q" . }} {{end}}
+`
+)
+
+func init() {
+	Register("FedoraCoreOS", &RegisteredProvisioner{
+		New: NewFedoraCoreOSProvisioner,
+	})
+}
+
+func NewFedoraCoreOSProvisioner(d drivers.Driver) Provisioner {
+	systemdProvisioner := NewSystemdProvisioner("fedora", d)
+	systemdProvisioner.DaemonOptionsFile = path.Join(fedoraCoreOSEngineDropInDir, "10-machine.conf")
+	systemdProvisioner.Packages = []string{}
+	return &FedoraCoreOSProvisioner{
+		systemdProvisioner,
+	}
+}
+
+type FedoraCoreOSProvisioner struct {
+	SystemdProvisioner
+}
+
+func (provisioner *FedoraCoreOSProvisioner) String() string {
+	return "fedora-coreos"
+}
+
+func (provisioner *FedoraCoreOSProvisioner) CompatibleWithHost() bool {
+	return provisioner.OsReleaseInfo.ID == provisioner.OsReleaseID && provisioner.OsReleaseInfo.VariantID == fedoraCoreOSVariantID
+}
+
+func (provisioner *FedoraCoreOSProvisioner) SetHostname(hostname string) error {
+	log.Debugf("SetHostname: %s", hostname)
+
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo hostnamectl set-hostname %s", hostname)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *FedoraCoreOSProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	// Packages are layered with rpm-ostree and only available after a
+	// reboot, so the engine shipped with the OS is used as is.
+	log.Debugf("package: ignoring %s of %s on an rpm-ostree system", action.String(), name)
+	return nil
+}
+
+func (provisioner *FedoraCoreOSProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var (
+		engineCfg bytes.Buffer
+	)
+
+	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
+	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+
+	t, err := template.New("engineConfig").Parse(fedoraCoreOSEngineConfigTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	daemonConfig, err := generateDaemonConfig(provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	engineConfigContext := EngineConfigContext{
+		DockerPort:       dockerPort,
+		AuthOptions:      provisioner.AuthOptions,
+		EngineOptions:    provisioner.EngineOptions,
+		DaemonConfigPath: daemonConfigPath(provisioner),
+	}
+
+	t.Execute(&engineCfg, engineConfigContext)
+
+	return &DockerOptions{
+		EngineOptions:     engineCfg.String(),
+		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
+	}, nil
+}
+
+func (provisioner *FedoraCoreOSProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+	swarmOptions.Env = engineOptions.PlainEnv()
+
+	// the stock unit picks the storage driver itself unless one is supplied
+	if engineOptions.StorageDriver == "aufs" {
+		provisioner.EngineOptions.StorageDriver = ""
+	}
+
+	log.Debug("setting hostname")
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("creating the engine drop-in directory")
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", fedoraCoreOSEngineDropInDir)); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	log.Debug("configuring auth")
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring swarm")
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+		return err
+	}
+
+	log.Debug("enabling docker in systemd")
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFedoraCoreOSCompatibleWithHost(t *testing.T) {
+	info := &OsRelease{
+		ID:        "fedora",
+		VariantID: "coreos",
+	}
+	fcos := NewFedoraCoreOSProvisioner(nil)
+	fcos.SetOsReleaseInfo(info)
+	fedora := NewFedoraProvisioner(nil)
+	fedora.SetOsReleaseInfo(info)
+
+	assert.True(t, fcos.CompatibleWithHost())
+	assert.False(t, fedora.CompatibleWithHost())
+
+	info.VariantID = "server"
+
+	assert.False(t, fcos.CompatibleWithHost())
+	assert.True(t, fedora.CompatibleWithHost())
+}
+
+func TestFedoraCoreOSGenerateDockerOptions(t *testing.T) {
+	p := NewFedoraCoreOSProvisioner(&fakedriver.Driver{}).(*FedoraCoreOSProvisioner)
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/docker.service.d/10-machine.conf", dockerCfg.EngineOptionsPath)
+	assert.True(t, strings.HasPrefix(dockerCfg.EngineOptions, "[Service]\nExecStart=\nExecStart=/usr/bin/dockerd --host=fd:// --host=tcp://0.0.0.0:2376"))
+	assert.False(t, strings.Contains(dockerCfg.EngineOptions, "--storage-driver"))
+	assert.True(t, strings.Contains(dockerCfg.EngineOptions, "--config-file /etc/docker/daemon.json"))
+	assert.True(t, strings.Contains(dockerCfg.EngineOptions, "--tlscacert /etc/docker/ca.pem"))
+	assert.Equal(t, "/etc/docker/daemon.json", dockerCfg.DaemonConfigPath)
+}
@@ -0,0 +1,226 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+const (
+	// Flatcar ships dockerd in its read-only /usr, so the engine is
+	// configured with a systemd drop-in overriding the stock unit, and
+	// other versions of it are selected with torcx on the older releases
+	// or with a systemd-sysext image on the newer ones.
+	flatcarEngineDropInDir = "/etc/systemd/system/docker.service.d"
+
+	flatcarEngineConfigTemplate = `[Service]
+ExecStart=
+ExecStart=/usr/bin/dockerd --host=fd:// --host=tcp://0.0.0.0:{{.DockerPort}} --config-file {{.DaemonConfigPath}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} \$DOCKER_OPTS \$DOCKER_OPT_BIP \$DOCKER_OPT_MTU \$DOCKER_OPT_IPMASQ
+Environment={{range .EngineOptions.PlainEnv}}{{ printf "%q" . }} {{end}}
+`
+
+	flatcarTorcxProfile     = "docker-machine"
+	flatcarTorcxProfileFile = "/etc/torcx/profiles/" + flatcarTorcxProfile + ".json"
+	flatcarTorcxNextProfile = "/etc/torcx/next-profile"
+
+	flatcarSysextDir   = "/opt/extensions/docker"
+	flatcarSysextImage = "/etc/extensions/docker.raw"
+	flatcarSysextURL   = "https://github.com/flatcar/sysext-bakery/releases/download/latest/docker-%s-%s.raw"
+)
+
+func init() {
+	Register("Flatcar", &RegisteredProvisioner{
+		New: NewFlatcarProvisioner,
+	})
+}
+
+func NewFlatcarProvisioner(d drivers.Driver) Provisioner {
+	systemdProvisioner := NewSystemdProvisioner("flatcar", d)
+	systemdProvisioner.DaemonOptionsFile = path.Join(flatcarEngineDropInDir, "10-machine.conf")
+	systemdProvisioner.Packages = []string{}
+	return &FlatcarProvisioner{
+		systemdProvisioner,
+	}
+}
+
+// FlatcarProvisioner provisions Flatcar Container Linux, the successor of
+// CoreOS Container Linux.
+type FlatcarProvisioner struct {
+	SystemdProvisioner
+}
+
+func (provisioner *FlatcarProvisioner) String() string {
+	return "flatcar"
+}
+
+func (provisioner *FlatcarProvisioner) SetHostname(hostname string) error {
+	log.Debugf("SetHostname: %s", hostname)
+
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo hostnamectl set-hostname %s", hostname)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *FlatcarProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	// There is no package manager, the engine is updated along with the
+	// OS unless another version of it was selected.
+	log.Debugf("package: ignoring %s of %s on Flatcar", action.String(), name)
+	return nil
+}
+
+func (provisioner *FlatcarProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var (
+		engineCfg bytes.Buffer
+	)
+
+	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
+	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+
+	t, err := template.New("engineConfig").Parse(flatcarEngineConfigTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	daemonConfig, err := generateDaemonConfig(provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	engineConfigContext := EngineConfigContext{
+		DockerPort:       dockerPort,
+		AuthOptions:      provisioner.AuthOptions,
+		EngineOptions:    provisioner.EngineOptions,
+		DaemonConfigPath: daemonConfigPath(provisioner),
+	}
+
+	t.Execute(&engineCfg, engineConfigContext)
+
+	return &DockerOptions{
+		EngineOptions:     engineCfg.String(),
+		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
+	}, nil
+}
+
+// usesTorcx tells whether the engine of the machine is a torcx image, which
+// the releases before 3185 use instead of a systemd-sysext image.
+func (provisioner *FlatcarProvisioner) usesTorcx() bool {
+	_, err := provisioner.SSHCommand("test -d /usr/share/torcx")
+	return err == nil
+}
+
+// torcxProfile returns the torcx profile selecting the docker image of the
+// version, e.g. 20.10.
+func torcxProfile(version string) string {
+	return fmt.Sprintf(`{"kind":"profile-manifest-v0","value":{"images":[{"name":"docker","reference":"%s"}]}}`, version)
+}
+
+// installTorcxEngineVersion selects the docker image of the version from the
+// torcx store of the machine, which is only unpacked on boot.
+func (provisioner *FlatcarProvisioner) installTorcxEngineVersion(version string) error {
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo find /usr/share/torcx/store /var/lib/torcx/store -name 'docker:%s.torcx.tgz' | grep -q .", version)); err != nil {
+		return fmt.Errorf("Error: docker %s isn't in the torcx store of the machine", version)
+	}
+
+	if err := writeRemoteFile(provisioner, torcxProfile(version), flatcarTorcxProfileFile); err != nil {
+		return err
+	}
+
+	if err := writeRemoteFile(provisioner, flatcarTorcxProfile+"\n", flatcarTorcxNextProfile); err != nil {
+		return err
+	}
+
+	log.Info("Rebooting into the torcx profile...")
+
+	return rebootMachine(provisioner)
+}
+
+// sysextEngineVersionCommand returns the command downloading the docker
+// image of the version from the Flatcar sysext bakery and merging it into
+// /usr instead of the image shipped with the OS.
+func sysextEngineVersionCommand(version string) string {
+	image := path.Join(flatcarSysextDir, fmt.Sprintf("docker-%s.raw", version))
+	url := fmt.Sprintf(flatcarSysextURL, version, "$arch")
+
+	return fmt.Sprintf(`arch=$(uname -m); case $arch in x86_64) arch=x86-64;; aarch64) arch=arm64;; esac; `+
+		`sudo mkdir -p %[1]s /etc/extensions && sudo curl -fsSL -o %[2]s %[3]s && `+
+		`sudo ln -sf /dev/null /etc/extensions/docker-flatcar.raw && sudo ln -sf /dev/null /etc/extensions/containerd-flatcar.raw && `+
+		`sudo ln -sf %[2]s %[4]s && sudo systemd-sysext refresh`,
+		flatcarSysextDir, image, url, flatcarSysextImage)
+}
+
+func (provisioner *FlatcarProvisioner) installEngineVersion(version string) error {
+	if provisioner.usesTorcx() {
+		return provisioner.installTorcxEngineVersion(version)
+	}
+
+	if _, err := provisioner.SSHCommand(sysextEngineVersionCommand(version)); err != nil {
+		return err
+	}
+
+	return provisioner.Service("docker", serviceaction.Restart)
+}
+
+func (provisioner *FlatcarProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+	swarmOptions.Env = engineOptions.PlainEnv()
+
+	// the stock unit picks the storage driver itself unless one is supplied
+	if engineOptions.StorageDriver == "aufs" {
+		provisioner.EngineOptions.StorageDriver = ""
+	}
+
+	log.Debug("setting hostname")
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	if engineOptions.Version != "" {
+		log.Infof("Installing docker %s...", engineOptions.Version)
+		if err := provisioner.installEngineVersion(engineOptions.Version); err != nil {
+			return err
+		}
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("creating the engine drop-in directory")
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", flatcarEngineDropInDir)); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	log.Debug("configuring auth")
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring swarm")
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+		return err
+	}
+
+	log.Debug("enabling docker in systemd")
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatcarCompatibleWithHost(t *testing.T) {
+	info := &OsRelease{
+		ID:     "flatcar",
+		IDLike: "coreos",
+	}
+	flatcar := NewFlatcarProvisioner(nil)
+	flatcar.SetOsReleaseInfo(info)
+	coreos := NewCoreOSProvisioner(nil)
+	coreos.SetOsReleaseInfo(info)
+
+	assert.True(t, flatcar.CompatibleWithHost())
+	assert.False(t, coreos.CompatibleWithHost())
+}
+
+func TestFlatcarGenerateDockerOptions(t *testing.T) {
+	p := NewFlatcarProvisioner(&fakedriver.Driver{}).(*FlatcarProvisioner)
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/docker.service.d/10-machine.conf", dockerCfg.EngineOptionsPath)
+	assert.True(t, strings.HasPrefix(dockerCfg.EngineOptions, "[Service]\nExecStart=\nExecStart=/usr/bin/dockerd --host=fd:// --host=tcp://0.0.0.0:2376"))
+	assert.True(t, strings.Contains(dockerCfg.EngineOptions, "--tlscacert /etc/docker/ca.pem"))
+	assert.Equal(t, "/etc/docker/daemon.json", dockerCfg.DaemonConfigPath)
+}
+
+func TestFlatcarInstallEngineVersionSysext(t *testing.T) {
+	p := NewFlatcarProvisioner(&fakedriver.Driver{}).(*FlatcarProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			sysextEngineVersionCommand("24.0.9"): "",
+			"sudo systemctl daemon-reload":       "",
+			"sudo systemctl -f restart docker":   "",
+		},
+	}
+
+	assert.NoError(t, UpgradeDocker(p, "24.0.9"))
+}
+
+func TestSysextEngineVersionCommand(t *testing.T) {
+	command := sysextEngineVersionCommand("24.0.9")
+
+	assert.Contains(t, command, "sudo curl -fsSL -o /opt/extensions/docker/docker-24.0.9.raw https://github.com/flatcar/sysext-bakery/releases/download/latest/docker-24.0.9-$arch.raw")
+	assert.Contains(t, command, "sudo ln -sf /dev/null /etc/extensions/docker-flatcar.raw")
+	assert.Contains(t, command, "sudo ln -sf /opt/extensions/docker/docker-24.0.9.raw /etc/extensions/docker.raw")
+	assert.True(t, strings.HasSuffix(command, "sudo systemd-sysext refresh"))
+}
+
+func TestFlatcarInstallEngineVersionNotInTorcxStore(t *testing.T) {
+	p := NewFlatcarProvisioner(&fakedriver.Driver{}).(*FlatcarProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"test -d /usr/share/torcx": "",
+		},
+	}
+
+	assert.EqualError(t, UpgradeDocker(p, "19.03"), "Error: docker 19.03 isn't in the torcx store of the machine")
+}
+
+func TestTorcxProfile(t *testing.T) {
+	assert.Equal(t, `{"kind":"profile-manifest-v0","value":{"images":[{"name":"docker","reference":"20.10"}]}}`, torcxProfile("20.10"))
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/swarm"
 )
 
@@ -17,6 +18,7 @@ type GenericProvisioner struct {
 	DockerOptionsDir  string
 	DaemonOptionsFile string
 	Packages          []string
+	RootlessPackages  []string
 	OsReleaseInfo     *OsRelease
 	Driver            drivers.Driver
 	AuthOptions       auth.Options
@@ -24,12 +26,33 @@ type GenericProvisioner struct {
 	SwarmOptions      swarm.Options
 }
 
+// GenericSSHCommander runs the commands with the same SSH client, whose
+// connection to the machine is reused.  A new client is created when the SSH
+// user of the driver changes, since the client stays logged in as the user it
+// was created for.
 type GenericSSHCommander struct {
 	Driver drivers.Driver
+	client ssh.Client
+	user   string
 }
 
-func (sshCmder GenericSSHCommander) SSHCommand(args string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(sshCmder.Driver, args)
+func (sshCmder *GenericSSHCommander) SSHCommand(args string) (string, error) {
+	if user := sshCmder.Driver.GetSSHUsername(); sshCmder.client == nil || user != sshCmder.user {
+		client, err := drivers.GetSSHClientFromDriver(sshCmder.Driver)
+		if err != nil {
+			return "", err
+		}
+		sshCmder.client = client
+		sshCmder.user = user
+	}
+
+	output, err := drivers.RunSSHCommand(sshCmder.client, args)
+	if err != nil {
+		// The address of the machine may have changed, e.g. on a restart
+		sshCmder.client = nil
+	}
+
+	return output, err
 }
 
 func (provisioner *GenericProvisioner) Hostname() (string, error) {
@@ -80,6 +103,10 @@ func (provisioner *GenericProvisioner) GetSwarmOptions() swarm.Options {
 	return provisioner.SwarmOptions
 }
 
+func (provisioner *GenericProvisioner) GetEngineOptions() engine.Options {
+	return provisioner.EngineOptions
+}
+
 func (provisioner *GenericProvisioner) SetOsReleaseInfo(info *OsRelease) {
 	provisioner.OsReleaseInfo = info
 }
@@ -100,18 +127,13 @@ func (provisioner *GenericProvisioner) GenerateDockerOptions(dockerPort int) (*D
 DOCKER_OPTS='
 -H tcp://0.0.0.0:{{.DockerPort}}
 -H unix:///var/run/docker.sock
---storage-driver {{.EngineOptions.StorageDriver}}
+--config-file {{.DaemonConfigPath}}
 --tlsverify
 --tlscacert {{.AuthOptions.CaCertRemotePath}}
 --tlscert {{.AuthOptions.ServerCertRemotePath}}
 --tlskey {{.AuthOptions.ServerKeyRemotePath}}
-{{ range .EngineOptions.Labels }}--label {{.}}
-{{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}}
-{{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}}
-{{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}}
-{{ end }}
 '
-{{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
+{{range .EngineOptions.PlainEnv}}export \"{{ printf "%q" . }}\"
 {{end}}
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
@@ -119,10 +141,16 @@ DOCKER_OPTS='
 		return nil, err
 	}
 
+	daemonConfig, err := generateDaemonConfig(provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
-		DockerPort:    dockerPort,
-		AuthOptions:   provisioner.AuthOptions,
-		EngineOptions: provisioner.EngineOptions,
+		DockerPort:       dockerPort,
+		AuthOptions:      provisioner.AuthOptions,
+		EngineOptions:    provisioner.EngineOptions,
+		DaemonConfigPath: daemonConfigPath(provisioner),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -130,6 +158,8 @@ DOCKER_OPTS='
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
 	}, nil
 }
 
@@ -0,0 +1,177 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+// The NVIDIA driver is installed from the CUDA repository of the
+// distribution, which builds it with DKMS for the running kernel, and the
+// container toolkit from the libnvidia-container repository.  The NVIDIA
+// runtime is then made the default one in the daemon.json of the engine.
+
+const (
+	cudaRepoURL            = "https://developer.download.nvidia.com/compute/cuda/repos"
+	nvidiaContainerRepoURL = "https://nvidia.github.io/libnvidia-container"
+	nvidiaContainerKeyring = "/usr/share/keyrings/nvidia-container-toolkit-keyring.gpg"
+	nvidiaRuntimeName      = "nvidia"
+	nvidiaRuntimePath      = "nvidia-container-runtime"
+)
+
+// gpuInstaller is implemented by the provisioners which can install the
+// driver and container runtime of NVIDIA GPUs, the only ones supported.
+type gpuInstaller interface {
+	installGPU() error
+}
+
+// SupportsGPU returns true if the provisioner can install the driver and
+// container runtime of GPUs, with --provision-gpu.
+func SupportsGPU(p Provisioner) bool {
+	_, ok := p.(gpuInstaller)
+	return ok
+}
+
+// setGPURuntime makes the container runtime of the GPU the default runtime
+// of the engine.
+func setGPURuntime(config map[string]interface{}, gpu string) {
+	if gpu != engine.GPUNvidia {
+		return
+	}
+
+	config["default-runtime"] = nvidiaRuntimeName
+	config["runtimes"] = map[string]interface{}{
+		nvidiaRuntimeName: map[string]interface{}{
+			"path": nvidiaRuntimePath,
+			"args": []interface{}{},
+		},
+	}
+}
+
+// cudaRepoDistro returns the name of the CUDA repository of a distribution,
+// e.g. ubuntu2204 or rhel9.
+func cudaRepoDistro(info *OsRelease) (string, error) {
+	if info == nil || info.VersionID == "" {
+		return "", fmt.Errorf("Unable to find the version of the operating system to install the NVIDIA driver")
+	}
+	major := strings.SplitN(info.VersionID, ".", 2)[0]
+
+	switch info.ID {
+	case "ubuntu":
+		return "ubuntu" + strings.Replace(info.VersionID, ".", "", -1), nil
+	case "debian":
+		return "debian" + major, nil
+	case "fedora":
+		return "fedora" + major, nil
+	case "rhel", "centos", "rocky", "almalinux", "ol":
+		return "rhel" + major, nil
+	}
+
+	return "", fmt.Errorf("The NVIDIA driver can't be installed on %s", info.ID)
+}
+
+// cudaRepoArch returns the architecture of the machine as named by the
+// CUDA repositories.
+func cudaRepoArch(p Provisioner) (string, error) {
+	arch, err := DetectArchitecture(p)
+	if err != nil {
+		return "", err
+	}
+
+	switch arch {
+	case mcnutils.ArchAMD64:
+		return "x86_64", nil
+	case mcnutils.ArchARM64:
+		return "sbsa", nil
+	default:
+		return "", fmt.Errorf("The NVIDIA driver can't be installed on the %s architecture", arch)
+	}
+}
+
+// installNvidiaAPT installs the NVIDIA driver and container toolkit on
+// Ubuntu and Debian.
+func installNvidiaAPT(p Provisioner, info *OsRelease) error {
+	distro, err := cudaRepoDistro(info)
+	if err != nil {
+		return err
+	}
+	arch, err := cudaRepoArch(p)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("adding the NVIDIA repositories")
+	commands := []string{
+		fmt.Sprintf("curl -fsSL -o /tmp/cuda-keyring.deb %s/%s/%s/cuda-keyring_1.1-1_all.deb && sudo dpkg -i /tmp/cuda-keyring.deb && rm -f /tmp/cuda-keyring.deb", cudaRepoURL, distro, arch),
+		fmt.Sprintf("curl -fsSL %s/gpgkey | sudo gpg --dearmor --yes -o %s", nvidiaContainerRepoURL, nvidiaContainerKeyring),
+		fmt.Sprintf("curl -fsSL %s/stable/deb/nvidia-container-toolkit.list | sed 's#deb https://#deb [signed-by=%s] https://#g' | sudo tee /etc/apt/sources.list.d/nvidia-container-toolkit.list", nvidiaContainerRepoURL, nvidiaContainerKeyring),
+	}
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error adding the NVIDIA repositories: %s\n%s", err, output)
+		}
+	}
+
+	return installNvidiaPackages(p, "linux-headers-$(uname -r)")
+}
+
+// installNvidiaYum installs the NVIDIA driver and container toolkit on the
+// distributions of the Red Hat family.
+func installNvidiaYum(p Provisioner, info *OsRelease) error {
+	distro, err := cudaRepoDistro(info)
+	if err != nil {
+		return err
+	}
+	arch, err := cudaRepoArch(p)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("adding the NVIDIA repositories")
+	commands := []string{
+		fmt.Sprintf("sudo curl -fsSL -o /etc/yum.repos.d/cuda-%[2]s.repo %[1]s/%[2]s/%[3]s/cuda-%[2]s.repo", cudaRepoURL, distro, arch),
+		fmt.Sprintf("sudo curl -fsSL -o /etc/yum.repos.d/nvidia-container-toolkit.repo %s/stable/rpm/nvidia-container-toolkit.repo", nvidiaContainerRepoURL),
+	}
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error adding the NVIDIA repositories: %s\n%s", err, output)
+		}
+	}
+
+	return installNvidiaPackages(p, "kernel-devel-$(uname -r)")
+}
+
+// installNvidiaPackages installs the kernel headers the driver is built
+// against, the driver and the container toolkit, then loads the driver.
+func installNvidiaPackages(p Provisioner, headers string) error {
+	log.Info("Installing the NVIDIA driver and container toolkit...")
+	for _, pkg := range []string{headers, "cuda-drivers", "nvidia-container-toolkit"} {
+		if err := p.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	// The driver is loaded at the next boot if it can't be now, e.g. when
+	// the kernel was upgraded with it.
+	if _, err := p.SSHCommand("sudo modprobe nvidia"); err != nil {
+		log.Warnf("The NVIDIA driver couldn't be loaded, the machine may have to be restarted: %s", err)
+	}
+
+	return nil
+}
+
+func (provisioner *UbuntuSystemdProvisioner) installGPU() error {
+	return installNvidiaAPT(provisioner, provisioner.OsReleaseInfo)
+}
+
+func (provisioner *DebianProvisioner) installGPU() error {
+	return installNvidiaAPT(provisioner, provisioner.OsReleaseInfo)
+}
+
+func (provisioner *RedHatProvisioner) installGPU() error {
+	return installNvidiaYum(provisioner, provisioner.OsReleaseInfo)
+}
@@ -0,0 +1,61 @@
+package provision
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCudaRepoDistro(t *testing.T) {
+	var tests = []struct {
+		id        string
+		versionID string
+		expected  string
+	}{
+		{"ubuntu", "22.04", "ubuntu2204"},
+		{"debian", "12", "debian12"},
+		{"fedora", "39", "fedora39"},
+		{"rocky", "9.3", "rhel9"},
+		{"ol", "8.9", "rhel8"},
+	}
+
+	for _, test := range tests {
+		distro, err := cudaRepoDistro(&OsRelease{ID: test.id, VersionID: test.versionID})
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, distro)
+	}
+
+	_, err := cudaRepoDistro(&OsRelease{ID: "arch", VersionID: "rolling"})
+	assert.EqualError(t, err, "The NVIDIA driver can't be installed on arch")
+}
+
+func TestGenerateDaemonConfigGPU(t *testing.T) {
+	content, err := generateDaemonConfig(engine.Options{GPU: engine.GPUNvidia})
+	assert.NoError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(content), &config))
+
+	assert.Equal(t, "nvidia", config["default-runtime"])
+	assert.Equal(t, map[string]interface{}{
+		"nvidia": map[string]interface{}{
+			"path": "nvidia-container-runtime",
+			"args": []interface{}{},
+		},
+	}, config["runtimes"])
+
+	content, err = generateDaemonConfig(engine.Options{GPU: engine.GPUNvidia, ArbitraryFlags: []string{"default-runtime=runc"}})
+	assert.NoError(t, err)
+	assert.Contains(t, content, `"default-runtime": "runc"`)
+}
+
+func TestSupportsGPU(t *testing.T) {
+	assert.True(t, SupportsGPU(NewUbuntuSystemdProvisioner(&fakedriver.Driver{})))
+	assert.True(t, SupportsGPU(NewDebianProvisioner(&fakedriver.Driver{})))
+	assert.True(t, SupportsGPU(NewCentosProvisioner(&fakedriver.Driver{})))
+	assert.False(t, SupportsGPU(NewArchProvisioner(&fakedriver.Driver{})))
+}
@@ -0,0 +1,119 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+// The recent distributions, e.g. Ubuntu 22.04 and 24.04, mount the unified
+// cgroup v2 hierarchy and back iptables with nftables.  The engine then runs
+// with the systemd cgroup driver, since systemd expects to be the only one
+// managing the cgroups, and ip6tables uses nftables like iptables, so that
+// the IPv4 and IPv6 rules of the engine end up in the same backend.
+
+const (
+	cgroupMountPoint   = "/sys/fs/cgroup"
+	cgroupV2Filesystem = "cgroup2fs"
+
+	cgroupDriverOption  = "native.cgroupdriver="
+	systemdCgroupDriver = cgroupDriverOption + "systemd"
+
+	iptablesLegacy   = "legacy"
+	iptablesNftables = "nf_tables"
+
+	iptablesVersionCommand = "sudo iptables --version"
+	ip6tablesNftCommand    = "if [ -x /usr/sbin/ip6tables-nft ]; then sudo update-alternatives --set ip6tables /usr/sbin/ip6tables-nft; fi"
+)
+
+// cgroupV2 returns true if the machine mounts the unified cgroup v2
+// hierarchy.
+func cgroupV2(p Provisioner) bool {
+	filesystem, err := getFilesystemType(p, cgroupMountPoint)
+	if err != nil {
+		log.Debugf("Error looking up the cgroup hierarchy, assuming cgroup v1: %s", err)
+		return false
+	}
+	return filesystem == cgroupV2Filesystem
+}
+
+// withSystemdCgroupDriver returns the engine options with the systemd cgroup
+// driver, unless a cgroup driver is given with --engine-opt exec-opt or in
+// --engine-opt-file.
+func withSystemdCgroupDriver(engineOptions engine.Options) engine.Options {
+	if cgroupDriverSet(engineOptions) {
+		return engineOptions
+	}
+
+	engineOptions.ArbitraryFlags = append(append([]string{}, engineOptions.ArbitraryFlags...), "exec-opt="+systemdCgroupDriver)
+	return engineOptions
+}
+
+func cgroupDriverSet(engineOptions engine.Options) bool {
+	content, err := generateDaemonConfig(engineOptions)
+	if err != nil {
+		// The invalid file is reported when the daemon.json is written
+		return false
+	}
+
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(content), &config); err != nil {
+		return false
+	}
+
+	execOpts, _ := config["exec-opts"].([]interface{})
+	for _, option := range execOpts {
+		if value, ok := option.(string); ok && strings.HasPrefix(value, cgroupDriverOption) {
+			return true
+		}
+	}
+	return false
+}
+
+// iptablesBackend returns the backend of the iptables of the machine,
+// nf_tables or legacy, or an empty string if iptables isn't installed.
+func iptablesBackend(p SSHCommander) string {
+	output, err := p.SSHCommand(iptablesVersionCommand)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case strings.Contains(output, "("+iptablesNftables+")"):
+		return iptablesNftables
+	case strings.Contains(output, "("+iptablesLegacy+")"):
+		return iptablesLegacy
+	}
+
+	// iptables before 1.8 only had the legacy backend
+	return iptablesLegacy
+}
+
+// configureIptables installs the iptables the engine writes its rules with
+// on the machines which only have nft, and makes ip6tables use the nftables
+// backend of iptables.
+func configureIptables(p Provisioner) error {
+	backend := iptablesBackend(p)
+	if backend == "" {
+		log.Info("Installing iptables, which the engine needs for its networks...")
+		if err := p.Package("iptables", pkgaction.Install); err != nil {
+			return fmt.Errorf("Error installing iptables: %s", err)
+		}
+		backend = iptablesBackend(p)
+	}
+
+	if backend != iptablesNftables {
+		return nil
+	}
+
+	log.Debug("using the nftables backend for ip6tables")
+	if _, err := p.SSHCommand(ip6tablesNftCommand); err != nil {
+		return fmt.Errorf("Error setting the nftables backend of ip6tables: %s", err)
+	}
+
+	return nil
+}
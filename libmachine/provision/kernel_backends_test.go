@@ -0,0 +1,78 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+// iptablesSSHCommander records the commands of a machine whose iptables
+// reports the given versions in turn, and isn't installed past them.
+type iptablesSSHCommander struct {
+	commands []string
+	versions []string
+}
+
+func (c *iptablesSSHCommander) SSHCommand(args string) (string, error) {
+	c.commands = append(c.commands, args)
+
+	if args == iptablesVersionCommand {
+		if len(c.versions) == 0 {
+			return "", errors.New("sudo: iptables: command not found")
+		}
+		version := c.versions[0]
+		c.versions = c.versions[1:]
+		if version == "" {
+			return "", errors.New("sudo: iptables: command not found")
+		}
+		return version + "\n", nil
+	}
+
+	return "", nil
+}
+
+func TestIptablesBackend(t *testing.T) {
+	var tests = []struct {
+		version  string
+		expected string
+	}{
+		{"iptables v1.8.7 (nf_tables)", iptablesNftables},
+		{"iptables v1.8.4 (legacy)", iptablesLegacy},
+		{"iptables v1.6.1", iptablesLegacy},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		backend := iptablesBackend(&iptablesSSHCommander{versions: []string{test.version}})
+		assert.Equal(t, test.expected, backend, test.version)
+	}
+}
+
+func TestConfigureIptablesInstall(t *testing.T) {
+	commander := &iptablesSSHCommander{versions: []string{"", "iptables v1.8.10 (nf_tables)"}}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	assert.NoError(t, configureIptables(p))
+	assert.Equal(t, []string{
+		iptablesVersionCommand,
+		"sudo apt-get update",
+		"DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y  iptables",
+		iptablesVersionCommand,
+		ip6tablesNftCommand,
+	}, commander.commands)
+}
+
+func TestWithSystemdCgroupDriver(t *testing.T) {
+	options := withSystemdCgroupDriver(engine.Options{ArbitraryFlags: []string{"log-level=debug"}})
+	assert.Equal(t, []string{"log-level=debug", "exec-opt=native.cgroupdriver=systemd"}, options.ArbitraryFlags)
+
+	options = withSystemdCgroupDriver(engine.Options{ArbitraryFlags: []string{"exec-opt=native.cgroupdriver=cgroupfs"}})
+	assert.Equal(t, []string{"exec-opt=native.cgroupdriver=cgroupfs"}, options.ArbitraryFlags)
+
+	options = withSystemdCgroupDriver(engine.Options{DaemonConfig: []byte(`{"exec-opts": ["native.cgroupdriver=systemd"]}`)})
+	assert.Empty(t, options.ArbitraryFlags)
+}
@@ -0,0 +1,165 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+// The Linux security modules confine the engine and its containers:
+// SELinux on the Red Hat family, whose policy only lets the containers use
+// the files labeled like /var/lib/docker, and AppArmor on Ubuntu, whose
+// docker-default profile is loaded by the engine with apparmor_parser.
+// What can't be set up is reported as a warning, since the engine starts
+// anyway but its containers then fail with permission errors.
+
+const (
+	defaultDataRoot = "/var/lib/docker"
+
+	selinuxModeCommand     = "getenforce 2>/dev/null || echo Disabled"
+	apparmorEnabledCommand = "cat /sys/module/apparmor/parameters/enabled 2>/dev/null || echo N"
+	apparmorParserCommand  = "command -v apparmor_parser"
+	semanageCommand        = "command -v semanage"
+)
+
+// apparmorEnabled returns true if AppArmor is enabled in the kernel of the
+// machine.
+func apparmorEnabled(p SSHCommander) bool {
+	output, err := p.SSHCommand(apparmorEnabledCommand)
+	return err == nil && strings.TrimSpace(output) == "Y"
+}
+
+// daemonSettings returns the data root of the engine, given with
+// --engine-opt data-root or in --engine-opt-file, and whether the engine
+// labels its containers for SELinux.
+func daemonSettings(engineOptions engine.Options) (string, bool) {
+	content, err := generateDaemonConfig(engineOptions)
+	if err != nil {
+		// The invalid file is reported when the daemon.json is written
+		return defaultDataRoot, false
+	}
+
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(content), &config); err != nil {
+		return defaultDataRoot, false
+	}
+
+	dataRoot := defaultDataRoot
+	if value, ok := config["data-root"].(string); ok && value != "" {
+		dataRoot = path.Clean(value)
+	}
+
+	selinuxEnabled, _ := config["selinux-enabled"].(bool)
+
+	return dataRoot, selinuxEnabled
+}
+
+// selinuxLabelCommands return the commands labeling a data root like
+// /var/lib/docker, with an equivalence rule of the policy that survives a
+// relabeling of the filesystem.
+func selinuxLabelCommands(dataRoot string) []string {
+	return []string{
+		fmt.Sprintf("sudo mkdir -p %s", dataRoot),
+		fmt.Sprintf("(sudo semanage fcontext -a -e %[2]s %[1]s 2>/dev/null || sudo semanage fcontext -m -e %[2]s %[1]s)", dataRoot, defaultDataRoot),
+		fmt.Sprintf("sudo restorecon -R %s", dataRoot),
+	}
+}
+
+// selinuxBooleans returns the booleans of the policy the containers need
+// to use a data root on the given filesystem.
+func selinuxBooleans(filesystem string) []string {
+	switch filesystem {
+	case "nfs":
+		return []string{"virt_use_nfs"}
+	case "cifs", "smb2":
+		return []string{"virt_use_samba"}
+	}
+	return nil
+}
+
+// configureSELinux installs the SELinux policy of the containers on a
+// machine enforcing it, makes the engine label its containers, and labels
+// a custom data root of the engine.
+func configureSELinux(p Provisioner, engineOptions *engine.Options) error {
+	output, err := p.SSHCommand(selinuxModeCommand)
+	if err != nil {
+		return err
+	}
+
+	dataRoot, selinuxEnabled := daemonSettings(*engineOptions)
+
+	if strings.TrimSpace(output) != "Enforcing" {
+		if selinuxEnabled {
+			log.Warnf("SELinux isn't enforced on %s, the engine won't label its containers despite selinux-enabled", p.GetDriver().GetMachineName())
+		}
+		return nil
+	}
+
+	if engineOptions.StorageDriver == "btrfs" {
+		if selinuxEnabled {
+			log.Warnf("The engine doesn't support SELinux with the btrfs storage driver, its containers won't start: remove selinux-enabled or choose another storage driver with --engine-storage-driver")
+		}
+	} else {
+		log.Debug("SELinux is enforcing, enabling the SELinux support of the engine")
+		engineOptions.SelinuxEnabled = true
+	}
+
+	log.Info("SELinux is enforcing, installing the SELinux policy of the containers...")
+	if err := p.Package("container-selinux", pkgaction.Install); err != nil {
+		log.Warnf("Error installing container-selinux, the containers may be denied access to their files: %s", err)
+	}
+
+	if dataRoot == defaultDataRoot {
+		return nil
+	}
+
+	if _, err := p.SSHCommand(semanageCommand); err != nil {
+		if err := p.Package("policycoreutils-python-utils", pkgaction.Install); err != nil {
+			log.Debugf("Error installing policycoreutils-python-utils: %s", err)
+		}
+	}
+
+	log.Infof("Labeling the data root %s of the engine for SELinux...", dataRoot)
+	for _, command := range selinuxLabelCommands(dataRoot) {
+		if output, err := p.SSHCommand(command); err != nil {
+			log.Warnf("Error labeling %s for SELinux, the containers will be denied access to their files: %s\n%s", dataRoot, err, output)
+			log.Warnf("Label it on the machine with: %s", strings.Join(selinuxLabelCommands(dataRoot), " && "))
+			return nil
+		}
+	}
+
+	filesystem, err := getFilesystemType(p, dataRoot)
+	if err != nil {
+		return nil
+	}
+
+	for _, boolean := range selinuxBooleans(filesystem) {
+		if output, err := p.SSHCommand(fmt.Sprintf("sudo setsebool -P %s on", boolean)); err != nil {
+			log.Warnf("Error setting the SELinux boolean %s for the data root %s on %s: %s\n%s", boolean, dataRoot, filesystem, err, output)
+		}
+	}
+
+	return nil
+}
+
+// configureAppArmor installs apparmor_parser on a machine with AppArmor,
+// which the engine needs to load the profile of its containers.
+func configureAppArmor(p Provisioner) {
+	if !apparmorEnabled(p) {
+		return
+	}
+
+	if _, err := p.SSHCommand(apparmorParserCommand); err == nil {
+		return
+	}
+
+	log.Info("AppArmor is enabled, installing apparmor to load the profile of the containers...")
+	if err := p.Package("apparmor", pkgaction.Install); err != nil {
+		log.Warnf("Error installing apparmor, the containers won't start without apparmor_parser: %s", err)
+	}
+}
@@ -0,0 +1,79 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+// apparmorSSHCommander records the commands of an Ubuntu machine, with or
+// without AppArmor and apparmor_parser.
+type apparmorSSHCommander struct {
+	commands []string
+	enabled  bool
+	parser   bool
+}
+
+func (c *apparmorSSHCommander) SSHCommand(args string) (string, error) {
+	c.commands = append(c.commands, args)
+
+	switch args {
+	case apparmorEnabledCommand:
+		if c.enabled {
+			return "Y\n", nil
+		}
+		return "N\n", nil
+	case apparmorParserCommand:
+		if !c.parser {
+			return "", errors.New("exit status 1")
+		}
+		return "/sbin/apparmor_parser\n", nil
+	}
+
+	return "", nil
+}
+
+func TestDaemonSettings(t *testing.T) {
+	dataRoot, selinuxEnabled := daemonSettings(engine.Options{})
+	assert.Equal(t, "/var/lib/docker", dataRoot)
+	assert.False(t, selinuxEnabled)
+
+	dataRoot, selinuxEnabled = daemonSettings(engine.Options{GraphDir: "/srv/docker", SelinuxEnabled: true})
+	assert.Equal(t, "/srv/docker", dataRoot)
+	assert.True(t, selinuxEnabled)
+
+	dataRoot, _ = daemonSettings(engine.Options{DaemonConfig: []byte(`{"data-root": "/data/docker/"}`)})
+	assert.Equal(t, "/data/docker", dataRoot)
+
+	dataRoot, selinuxEnabled = daemonSettings(engine.Options{ArbitraryFlags: []string{"data-root=/mnt/docker", "selinux-enabled"}})
+	assert.Equal(t, "/mnt/docker", dataRoot)
+	assert.True(t, selinuxEnabled)
+}
+
+func TestSELinuxBooleans(t *testing.T) {
+	assert.Equal(t, []string{"virt_use_nfs"}, selinuxBooleans("nfs"))
+	assert.Equal(t, []string{"virt_use_samba"}, selinuxBooleans("cifs"))
+	assert.Empty(t, selinuxBooleans("xfs"))
+}
+
+func TestConfigureAppArmor(t *testing.T) {
+	var tests = []struct {
+		commander *apparmorSSHCommander
+		expected  []string
+	}{
+		{&apparmorSSHCommander{}, []string{apparmorEnabledCommand}},
+		{&apparmorSSHCommander{enabled: true, parser: true}, []string{apparmorEnabledCommand, apparmorParserCommand}},
+		{&apparmorSSHCommander{enabled: true}, []string{apparmorEnabledCommand, apparmorParserCommand, "sudo apt-get update", "DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y  apparmor"}},
+	}
+
+	for _, test := range tests {
+		p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+		p.SSHCommander = test.commander
+
+		configureAppArmor(p)
+		assert.Equal(t, test.expected, test.commander.commands)
+	}
+}
@@ -0,0 +1,153 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func init() {
+	Register("openSUSE MicroOS", &RegisteredProvisioner{
+		New: NewMicroOSProvisioner,
+	})
+	Register("SLE Micro", &RegisteredProvisioner{
+		New: NewSLEMicroProvisioner,
+	})
+}
+
+func NewMicroOSProvisioner(d drivers.Driver) Provisioner {
+	return newTransactionalProvisioner("opensuse-microos", d)
+}
+
+func NewSLEMicroProvisioner(d drivers.Driver) Provisioner {
+	return newTransactionalProvisioner("sle-micro", d)
+}
+
+func newTransactionalProvisioner(osReleaseID string, d drivers.Driver) *MicroOSProvisioner {
+	return &MicroOSProvisioner{
+		SUSEProvisioner: SUSEProvisioner{
+			GenericProvisioner{
+				SSHCommander:      &GenericSSHCommander{Driver: d},
+				DockerOptionsDir:  "/etc/docker",
+				DaemonOptionsFile: "/etc/sysconfig/docker",
+				OsReleaseID:       osReleaseID,
+				Packages: []string{
+					"curl",
+				},
+				Driver: d,
+			},
+		},
+	}
+}
+
+// MicroOSProvisioner provisions the SUSE systems with a read-only root
+// filesystem.  Packages are installed with transactional-update into a new
+// snapshot, which is only used after a reboot.
+type MicroOSProvisioner struct {
+	SUSEProvisioner
+
+	rebootPending bool
+}
+
+func (provisioner *MicroOSProvisioner) String() string {
+	return "microos"
+}
+
+func (provisioner *MicroOSProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	var packageAction string
+
+	switch action {
+	case pkgaction.Install:
+		if _, err := provisioner.SSHCommand(fmt.Sprintf("rpm -q %s", name)); err == nil {
+			log.Debugf("package: %s is already installed", name)
+			return nil
+		}
+		packageAction = "install"
+	case pkgaction.Remove:
+		packageAction = "remove"
+	case pkgaction.Upgrade:
+		packageAction = "update"
+	}
+
+	// --continue adds the changes to the pending snapshot, if any, instead
+	// of discarding them
+	command := fmt.Sprintf("sudo transactional-update --continue --non-interactive pkg %s %s", packageAction, name)
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	provisioner.rebootPending = true
+
+	return nil
+}
+
+// reboot boots the machine into the snapshot of the pending transactions,
+// and waits until it's back.
+func (provisioner *MicroOSProvisioner) reboot() error {
+	log.Info("Rebooting into the new snapshot...")
+
+	if err := rebootMachine(provisioner); err != nil {
+		return err
+	}
+
+	provisioner.rebootPending = false
+
+	return nil
+}
+
+func (provisioner *MicroOSProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+	swarmOptions.Env = engineOptions.PlainEnv()
+
+	log.Debug("setting hostname")
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	// the engine comes from the repositories of the distribution, since
+	// the install script can't write to the root filesystem
+	log.Info("Installing Docker...")
+	for _, pkg := range append(provisioner.Packages, "docker") {
+		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	if provisioner.rebootPending {
+		if err := provisioner.reboot(); err != nil {
+			return err
+		}
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	log.Debug("configuring auth")
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring swarm")
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+		return err
+	}
+
+	log.Debug("enabling docker in systemd")
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	return nil
+}
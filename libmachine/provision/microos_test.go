@@ -0,0 +1,70 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMicroOSCompatibleWithHost(t *testing.T) {
+	info := &OsRelease{ID: "opensuse-microos"}
+	microOS := NewMicroOSProvisioner(nil)
+	microOS.SetOsReleaseInfo(info)
+	sleMicro := NewSLEMicroProvisioner(nil)
+	sleMicro.SetOsReleaseInfo(info)
+
+	assert.True(t, microOS.CompatibleWithHost())
+	assert.False(t, sleMicro.CompatibleWithHost())
+
+	info.ID = "sle-micro"
+
+	assert.False(t, microOS.CompatibleWithHost())
+	assert.True(t, sleMicro.CompatibleWithHost())
+}
+
+func TestMicroOSPackageInstall(t *testing.T) {
+	p := NewMicroOSProvisioner(&fakedriver.Driver{}).(*MicroOSProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo transactional-update --continue --non-interactive pkg install docker": "",
+		},
+	}
+
+	err := p.Package("docker", pkgaction.Install)
+
+	assert.NoError(t, err)
+	assert.True(t, p.rebootPending)
+}
+
+func TestMicroOSPackageAlreadyInstalled(t *testing.T) {
+	p := NewMicroOSProvisioner(&fakedriver.Driver{}).(*MicroOSProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"rpm -q docker": "docker-24.0.7_ce-1.1.x86_64\n",
+		},
+	}
+
+	err := p.Package("docker", pkgaction.Install)
+
+	assert.NoError(t, err)
+	assert.False(t, p.rebootPending)
+}
+
+func TestMicroOSRebootedSince(t *testing.T) {
+	p := NewMicroOSProvisioner(&fakedriver.Driver{}).(*MicroOSProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"cat /proc/sys/kernel/random/boot_id": "5c1f4e1c-9a52-4bd2-9d53-5dfa0b7b8e02\n",
+		},
+	}
+
+	assert.False(t, rebootedSince(p, "5c1f4e1c-9a52-4bd2-9d53-5dfa0b7b8e02")())
+	assert.True(t, rebootedSince(p, "0d5b7b5e-3b0e-4f0c-8d1a-2a8e1cf0a9d4")())
+
+	p.SSHCommander = &provisiontest.FakeSSHCommander{}
+
+	assert.False(t, rebootedSince(p, "0d5b7b5e-3b0e-4f0c-8d1a-2a8e1cf0a9d4")())
+}
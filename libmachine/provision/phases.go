@@ -0,0 +1,288 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// Phase is a part of the provisioning which can be run again alone, e.g. to
+// fix the configuration of the engine without provisioning the machine
+// again from scratch.
+type Phase string
+
+const (
+	PhaseHostname Phase = "hostname"
+	PhaseEngine   Phase = "engine"
+	PhaseCerts    Phase = "certs"
+	PhaseSwarm    Phase = "swarm"
+)
+
+// phases are the phases in the order they run.
+var phases = []Phase{PhaseHostname, PhaseEngine, PhaseCerts, PhaseSwarm}
+
+var ErrPhasesNotSupported = errors.New("Error: the phases of the provisioning can't be run alone on this machine, provision it without --phase")
+
+// swarmContainers are the containers of the legacy swarm.
+var swarmContainers = []string{"swarm-agent-master", "swarm-agent"}
+
+// optionsSetter is implemented by the provisioners whose phases can be run
+// alone, which are otherwise given their options by Provision.
+type optionsSetter interface {
+	setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options)
+}
+
+func (provisioner *GenericProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+}
+
+// ParsePhases parses a comma separated list of phases, returned in the
+// order they run.
+func ParsePhases(value string) ([]Phase, error) {
+	selected := map[Phase]bool{}
+	for _, name := range strings.Split(value, ",") {
+		phase := Phase(strings.TrimSpace(name))
+		if !isPhase(phase) {
+			return nil, fmt.Errorf("Error: unknown provisioning phase %q, expected some of: %s", phase, phaseNames())
+		}
+		selected[phase] = true
+	}
+
+	result := []Phase{}
+	for _, phase := range phases {
+		if selected[phase] {
+			result = append(result, phase)
+		}
+	}
+	return result, nil
+}
+
+func isPhase(phase Phase) bool {
+	for _, p := range phases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+func phaseNames() string {
+	names := []string{}
+	for _, phase := range phases {
+		names = append(names, string(phase))
+	}
+	return strings.Join(names, ", ")
+}
+
+// ProvisionPhases runs the given phases of the provisioning.  A phase whose
+// result is already in place on the machine is skipped unless force is set,
+// so that running them again only changes what drifted.
+func ProvisionPhases(p Provisioner, selected []Phase, force bool, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	setter, ok := p.(optionsSetter)
+	if !ok || !ManagesDockerd(p, engineOptions) {
+		return ErrPhasesNotSupported
+	}
+
+	if engineOptions.StorageDriver == "" {
+		// The storage driver the engine runs with is kept, not to lose
+		// its images and containers
+		engineOptions.StorageDriver = currentStorageDriver(p)
+	}
+
+	dockerDir := p.GetDockerOptionsDir()
+	authOptions.CaCertRemotePath = path.Join(dockerDir, "ca.pem")
+	authOptions.ServerCertRemotePath = path.Join(dockerDir, "server.pem")
+	authOptions.ServerKeyRemotePath = path.Join(dockerDir, "server-key.pem")
+
+	setter.setOptions(swarmOptions, authOptions, engineOptions)
+
+	for _, phase := range selected {
+		var err error
+		switch phase {
+		case PhaseHostname:
+			err = provisionHostname(p, force)
+		case PhaseEngine:
+			err = provisionEngine(p, engineOptions, force)
+		case PhaseCerts:
+			err = provisionCerts(p, swarmOptions, authOptions, engineOptions, force)
+		case PhaseSwarm:
+			err = provisionSwarm(p, swarmOptions, authOptions, force)
+		}
+		if err != nil {
+			return fmt.Errorf("Error provisioning the %s: %s", phase, err)
+		}
+	}
+
+	return nil
+}
+
+// currentStorageDriver returns the storage driver of the running engine, or
+// overlay2.
+func currentStorageDriver(p Provisioner) string {
+	output, err := p.SSHCommand("sudo docker info --format '{{.Driver}}'")
+	if driver := strings.TrimSpace(output); err == nil && driver != "" {
+		return driver
+	}
+	return "overlay2"
+}
+
+func provisionHostname(p Provisioner, force bool) error {
+	name := p.GetDriver().GetMachineName()
+
+	if !force {
+		if hostname, err := p.Hostname(); err == nil && strings.TrimSpace(hostname) == name {
+			log.Infof("The hostname is already %s", name)
+			return nil
+		}
+	}
+
+	log.Infof("Setting the hostname to %s...", name)
+	return p.SetHostname(name)
+}
+
+// provisionEngine installs the engine if it's missing, and writes its
+// configuration and restarts it if it differs from the one on the machine.
+func provisionEngine(p Provisioner, engineOptions engine.Options, force bool) error {
+	if _, err := p.SSHCommand("command -v dockerd"); err != nil {
+		log.Info("Installing Docker...")
+		if err := installEngine(p, engineOptions); err != nil {
+			return err
+		}
+		force = true
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return err
+	}
+
+	if !force && dockerOptionsUpToDate(p, dkrcfg) {
+		log.Info("The configuration of the engine is up to date")
+		return nil
+	}
+
+	if err := validateEngineOptions(p); err != nil {
+		return err
+	}
+
+	log.Info("Setting Docker configuration on the remote daemon...")
+	if err := writeDockerOptions(p, dkrcfg); err != nil {
+		return err
+	}
+
+	if err := configureEngineSecrets(p); err != nil {
+		return err
+	}
+
+	log.Info("Restarting the engine...")
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
+
+// dockerOptionsUpToDate tells whether the configuration files of the engine
+// on the machine have the given content.
+func dockerOptionsUpToDate(p Provisioner, dkrcfg *DockerOptions) bool {
+	files := map[string]string{dkrcfg.EngineOptionsPath: dkrcfg.EngineOptions}
+	if dkrcfg.DaemonConfigPath != "" {
+		files[dkrcfg.DaemonConfigPath] = dkrcfg.DaemonConfig
+	}
+
+	for filePath, content := range files {
+		output, err := p.SSHCommand("sudo cat " + filePath)
+		if err != nil || strings.TrimSpace(output) != strings.TrimSpace(content) {
+			return false
+		}
+	}
+	return true
+}
+
+// provisionCerts replaces the server certificate unless the one on the
+// machine is the local one, valid for the address of the machine and not
+// about to expire.
+func provisionCerts(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, force bool) error {
+	if !force && serverCertUpToDate(p, authOptions) {
+		log.Info("The server certificate is up to date")
+		return nil
+	}
+
+	log.Info("Replacing the server certificate...")
+	return RotateServerCert(p, swarmOptions, authOptions, engineOptions)
+}
+
+func serverCertUpToDate(p Provisioner, authOptions auth.Options) bool {
+	ip, err := p.GetDriver().GetIP()
+	if err != nil {
+		return false
+	}
+
+	if covered, err := cert.CertificateCoversHost(authOptions.ServerCertPath, ip); err != nil || !covered {
+		return false
+	}
+
+	if due, err := cert.NeedsRotation(authOptions.ServerCertPath, 0, time.Now()); err != nil || due {
+		return false
+	}
+
+	local, err := ioutil.ReadFile(authOptions.ServerCertPath)
+	if err != nil {
+		return false
+	}
+
+	remote, err := p.SSHCommand("sudo cat " + authOptions.ServerCertRemotePath)
+	return err == nil && strings.TrimSpace(remote) == strings.TrimSpace(string(local))
+}
+
+// provisionSwarm starts the containers of the legacy swarm unless they
+// exist, or replaces them if forced.
+func provisionSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options, force bool) error {
+	if !swarmOptions.IsSwarm {
+		return nil
+	}
+
+	output, err := p.SSHCommand("sudo docker ps -a --format '{{.Names}}'")
+	if err != nil {
+		return err
+	}
+
+	existing := []string{}
+	for _, name := range strings.Fields(output) {
+		for _, container := range swarmContainers {
+			if name == container {
+				existing = append(existing, name)
+			}
+		}
+	}
+
+	if len(existing) > 0 {
+		if !force {
+			log.Info("The swarm containers already exist")
+			return nil
+		}
+
+		if _, err := p.SSHCommand("sudo docker rm -f " + strings.Join(existing, " ")); err != nil {
+			return err
+		}
+	}
+
+	return configureSwarm(p, swarmOptions, authOptions)
+}
@@ -0,0 +1,111 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+// phasesSSHCommander records the commands, and answers the ones it has a
+// response for.
+type phasesSSHCommander struct {
+	commands  []string
+	responses map[string]string
+}
+
+func (c *phasesSSHCommander) SSHCommand(args string) (string, error) {
+	c.commands = append(c.commands, args)
+	return c.responses[args], nil
+}
+
+func newPhasesProvisioner(commander *phasesSSHCommander) *UbuntuSystemdProvisioner {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{MockName: "default", MockState: state.Running, MockIP: "192.168.99.100"}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+	return p
+}
+
+func TestParsePhases(t *testing.T) {
+	selected, err := ParsePhases("swarm, certs,engine,certs")
+	assert.NoError(t, err)
+	assert.Equal(t, []Phase{PhaseEngine, PhaseCerts, PhaseSwarm}, selected)
+
+	_, err = ParsePhases("certs,network")
+	assert.EqualError(t, err, `Error: unknown provisioning phase "network", expected some of: hostname, engine, certs, swarm`)
+}
+
+func TestProvisionPhasesHostname(t *testing.T) {
+	commander := &phasesSSHCommander{responses: map[string]string{"hostname": "default\n"}}
+	p := newPhasesProvisioner(commander)
+
+	err := ProvisionPhases(p, []Phase{PhaseHostname}, false, swarm.Options{}, auth.Options{}, engine.Options{StorageDriver: "overlay2"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hostname"}, commander.commands)
+
+	commander.commands = nil
+	err = ProvisionPhases(p, []Phase{PhaseHostname}, true, swarm.Options{}, auth.Options{}, engine.Options{StorageDriver: "overlay2"})
+
+	assert.NoError(t, err)
+	assert.Len(t, commander.commands, 2)
+	assert.Contains(t, commander.commands[0], "sudo hostname default")
+}
+
+func TestProvisionPhasesKeepsStorageDriver(t *testing.T) {
+	commander := &phasesSSHCommander{responses: map[string]string{
+		"sudo docker info --format '{{.Driver}}'": "btrfs\n",
+		"hostname": "default\n",
+	}}
+	p := newPhasesProvisioner(commander)
+
+	err := ProvisionPhases(p, []Phase{PhaseHostname}, false, swarm.Options{}, auth.Options{}, engine.Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "btrfs", p.EngineOptions.StorageDriver)
+	assert.Equal(t, "/etc/docker/server.pem", p.AuthOptions.ServerCertRemotePath)
+}
+
+func TestProvisionPhasesSwarm(t *testing.T) {
+	commander := &phasesSSHCommander{responses: map[string]string{
+		"sudo docker ps -a --format '{{.Names}}'": "web\nswarm-agent\n",
+	}}
+	p := newPhasesProvisioner(commander)
+	swarmOptions := swarm.Options{IsSwarm: true, Agent: true}
+
+	err := ProvisionPhases(p, []Phase{PhaseSwarm}, false, swarmOptions, auth.Options{}, engine.Options{StorageDriver: "overlay2"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sudo docker ps -a --format '{{.Names}}'"}, commander.commands)
+}
+
+func TestProvisionPhasesNotSupported(t *testing.T) {
+	p := newPhasesProvisioner(&phasesSSHCommander{})
+
+	err := ProvisionPhases(p, []Phase{PhaseEngine}, false, swarm.Options{}, auth.Options{}, engine.Options{ContainerRuntime: engine.RuntimePodman})
+	assert.Equal(t, ErrPhasesNotSupported, err)
+
+	err = ProvisionPhases(NewBoot2DockerProvisioner(&fakedriver.Driver{}), []Phase{PhaseEngine}, false, swarm.Options{}, auth.Options{}, engine.Options{})
+	assert.Equal(t, ErrPhasesNotSupported, err)
+}
+
+func TestDockerOptionsUpToDate(t *testing.T) {
+	dkrcfg := &DockerOptions{
+		EngineOptions:     "[Service]\nExecStart=/usr/bin/dockerd\n",
+		EngineOptionsPath: "/etc/systemd/system/docker.service.d/10-machine.conf",
+		DaemonConfig:      `{"log-driver": "journald"}`,
+		DaemonConfigPath:  "/etc/docker/daemon.json",
+	}
+
+	commander := &phasesSSHCommander{responses: map[string]string{
+		"sudo cat /etc/systemd/system/docker.service.d/10-machine.conf": "[Service]\nExecStart=/usr/bin/dockerd\n",
+		"sudo cat /etc/docker/daemon.json":                              `{"log-driver": "journald"}` + "\n",
+	}}
+	assert.True(t, dockerOptionsUpToDate(newPhasesProvisioner(commander), dkrcfg))
+
+	commander.responses["sudo cat /etc/docker/daemon.json"] = `{"log-driver": "json-file"}`
+	assert.False(t, dockerOptionsUpToDate(newPhasesProvisioner(commander), dkrcfg))
+}
@@ -0,0 +1,229 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+const (
+	// VMware Photon OS ships the engine preinstalled but disabled, and its
+	// iptables rules drop the incoming connections other than SSH.  The
+	// rules are saved in photonIptablesRules, which its iptables service
+	// restores at boot.
+	photonIptablesRules = "/etc/systemd/scripts/ip4save"
+
+	photonEngineConfigTemplate = `[Unit]
+Description=Docker Application Container Engine
+After=network-online.target containerd.service
+Wants=network-online.target
+
+[Service]
+Type=notify
+ExecStart=/usr/bin/dockerd -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --config-file {{.DaemonConfigPath}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}
+ExecReload=/bin/kill -s HUP $MAINPID
+LimitNOFILE=infinity
+LimitNPROC=infinity
+LimitCORE=infinity
+TimeoutStartSec=0
+Delegate=yes
+KillMode=process
+Environment={{range .EngineOptions.PlainEnv}}{{ printf "%q" . }} {{end}}
+
+[Install]
+WantedBy=multi-user.target
+`
+)
+
+func init() {
+	Register("Photon", &RegisteredProvisioner{
+		New: NewPhotonProvisioner,
+	})
+}
+
+func NewPhotonProvisioner(d drivers.Driver) Provisioner {
+	return &PhotonProvisioner{
+		NewSystemdProvisioner("photon", d),
+	}
+}
+
+type PhotonProvisioner struct {
+	SystemdProvisioner
+}
+
+func (provisioner *PhotonProvisioner) String() string {
+	return "photon"
+}
+
+func (provisioner *PhotonProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	var packageAction string
+
+	switch action {
+	case pkgaction.Install:
+		if _, err := provisioner.SSHCommand(fmt.Sprintf("rpm -q %s", name)); err == nil {
+			log.Debugf("package: %s is already installed", name)
+			return nil
+		}
+		packageAction = "install"
+	case pkgaction.Remove:
+		packageAction = "erase"
+	case pkgaction.Upgrade:
+		packageAction = "update"
+	}
+
+	command := fmt.Sprintf("sudo tdnf %s -y %s", packageAction, name)
+
+	log.Debugf("package: action=%s name=%s", action.String(), name)
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *PhotonProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var engineCfg bytes.Buffer
+
+	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
+	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+
+	t, err := template.New("engineConfig").Parse(photonEngineConfigTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	daemonConfig, err := generateDaemonConfig(provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	engineConfigContext := EngineConfigContext{
+		DockerPort:       dockerPort,
+		AuthOptions:      provisioner.AuthOptions,
+		EngineOptions:    provisioner.EngineOptions,
+		DaemonConfigPath: daemonConfigPath(provisioner),
+	}
+
+	t.Execute(&engineCfg, engineConfigContext)
+
+	return &DockerOptions{
+		EngineOptions:     engineCfg.String(),
+		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
+	}, nil
+}
+
+func (provisioner *PhotonProvisioner) dockerDaemonResponding() bool {
+	log.Debug("checking docker daemon")
+
+	if out, err := provisioner.SSHCommand("sudo docker version"); err != nil {
+		log.Warnf("Error getting SSH command to check if the daemon is up: %s", err)
+		log.Debugf("'sudo docker version' output:\n%s", out)
+		return false
+	}
+
+	// The daemon is up if the command worked.  Carry on.
+	return true
+}
+
+// openIptablesPort accepts the connections to the engine, in the running
+// rules and in the ones restored at boot.
+func (provisioner *PhotonProvisioner) openIptablesPort() error {
+	dockerPort, err := getDockerPort(provisioner.Driver)
+	if err != nil {
+		return err
+	}
+
+	rule := fmt.Sprintf("INPUT -p tcp -m tcp --dport %d -j ACCEPT", dockerPort)
+
+	log.Debugf("opening port %d in iptables", dockerPort)
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo iptables -C %s 2>/dev/null || sudo iptables -A %s", rule, rule)); err != nil {
+		return fmt.Errorf("Error opening the port of the engine in iptables: %s", err)
+	}
+
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("if [ -f %s ]; then sudo iptables-save | sudo tee %s >/dev/null; fi", photonIptablesRules, photonIptablesRules)); err != nil {
+		return fmt.Errorf("Error saving the iptables rules: %s", err)
+	}
+
+	return nil
+}
+
+func (provisioner *PhotonProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+	swarmOptions.Env = engineOptions.PlainEnv()
+
+	storageDriver, err := decideStorageDriver(provisioner, "overlay2", engineOptions.StorageDriver)
+	if err != nil {
+		return err
+	}
+	provisioner.EngineOptions.StorageDriver = storageDriver
+
+	log.Debug("setting hostname")
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	log.Debug("installing base packages")
+	for _, pkg := range provisioner.Packages {
+		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	// the engine is part of the default install, only the minimal
+	// templates need to install it
+	log.Debug("installing docker")
+	if err := provisioner.Package("docker", pkgaction.Install); err != nil {
+		return err
+	}
+
+	if err := provisioner.openIptablesPort(); err != nil {
+		return err
+	}
+
+	log.Debug("starting systemd docker service")
+	if err := provisioner.Service("docker", serviceaction.Start); err != nil {
+		return err
+	}
+
+	log.Debug("waiting for docker daemon")
+	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	log.Debug("configuring auth")
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring swarm")
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+		return err
+	}
+
+	log.Debug("enabling docker in systemd")
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	return nil
+}
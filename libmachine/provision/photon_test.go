@@ -0,0 +1,74 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhotonCompatibleWithHost(t *testing.T) {
+	info := &OsRelease{ID: "photon"}
+	p := NewPhotonProvisioner(nil)
+	p.SetOsReleaseInfo(info)
+
+	assert.True(t, p.CompatibleWithHost())
+
+	info.ID = "fedora"
+
+	assert.False(t, p.CompatibleWithHost())
+}
+
+func TestPhotonPackageInstall(t *testing.T) {
+	p := NewPhotonProvisioner(&fakedriver.Driver{}).(*PhotonProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo tdnf install -y curl": "",
+		},
+	}
+
+	assert.NoError(t, p.Package("curl", pkgaction.Install))
+}
+
+func TestPhotonPackageAlreadyInstalled(t *testing.T) {
+	p := NewPhotonProvisioner(&fakedriver.Driver{}).(*PhotonProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"rpm -q docker": "docker-24.0.9-1.ph5.x86_64\n",
+		},
+	}
+
+	assert.NoError(t, p.Package("docker", pkgaction.Install))
+}
+
+func TestPhotonPackageRemove(t *testing.T) {
+	p := NewPhotonProvisioner(&fakedriver.Driver{}).(*PhotonProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo tdnf erase -y docker": "",
+		},
+	}
+
+	assert.NoError(t, p.Package("docker", pkgaction.Remove))
+}
+
+func TestPhotonGenerateDockerOptions(t *testing.T) {
+	p := NewPhotonProvisioner(&fakedriver.Driver{}).(*PhotonProvisioner)
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/docker.service", dockerCfg.EngineOptionsPath)
+	assert.True(t, strings.Contains(dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd -H tcp://0.0.0.0:2376 -H unix:///var/run/docker.sock"))
+	assert.True(t, strings.Contains(dockerCfg.EngineOptions, "--tlscacert /etc/docker/ca.pem"))
+	assert.Equal(t, "/etc/docker/daemon.json", dockerCfg.DaemonConfigPath)
+}
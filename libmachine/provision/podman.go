@@ -0,0 +1,121 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+)
+
+const (
+	podmanSocketService = "podman.socket"
+	podmanTLSService    = "podman-tls"
+	podmanTLSUnitFile   = "/etc/systemd/system/podman-tls.service"
+)
+
+var (
+	podmanPackages = []string{"podman", "socat"}
+
+	// Podman only serves its API on a unix socket, so socat terminates TLS
+	// on the engine port and forwards to it.  Clients are verified against
+	// the machine CA exactly like dockerd does with --tlsverify.
+	podmanTLSTemplate = `[Unit]
+Description=TLS endpoint for the Podman API
+Requires=podman.socket
+After=podman.socket network.target
+
+[Service]
+ExecStart=/usr/bin/socat OPENSSL-LISTEN:{{.DockerPort}},reuseaddr,fork,cert={{.AuthOptions.ServerCertRemotePath}},key={{.AuthOptions.ServerKeyRemotePath}},cafile={{.AuthOptions.CaCertRemotePath}},verify=1 UNIX-CONNECT:/run/podman/podman.sock
+Restart=always
+
+[Install]
+WantedBy=multi-user.target
+`
+)
+
+// generatePodmanOptions renders the systemd unit which exposes the Podman
+// API over TLS on the given port.
+func generatePodmanOptions(p Provisioner, dockerPort int) (*DockerOptions, error) {
+	var unit bytes.Buffer
+
+	t, err := template.New("podmanConfig").Parse(podmanTLSTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	podmanConfigContext := EngineConfigContext{
+		DockerPort:  dockerPort,
+		AuthOptions: p.GetAuthOptions(),
+	}
+
+	if err := t.Execute(&unit, podmanConfigContext); err != nil {
+		return nil, err
+	}
+
+	return &DockerOptions{
+		EngineOptions:     unit.String(),
+		EngineOptionsPath: podmanTLSUnitFile,
+	}, nil
+}
+
+// provisionPodman installs Podman instead of the Docker engine and publishes
+// its Docker-compatible API with the machine's TLS certificates.  The remote
+// auth options must already be set on the provisioner.
+func provisionPodman(p Provisioner) error {
+	log.Info("Installing Podman...")
+	for _, pkg := range podmanPackages {
+		if err := p.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	log.Debug("enabling the podman API socket")
+	if err := p.Service(podmanSocketService, serviceaction.Enable); err != nil {
+		return err
+	}
+
+	if err := p.Service(podmanSocketService, serviceaction.Start); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(p); err != nil {
+		return err
+	}
+
+	if err := generateServerCert(p); err != nil {
+		return err
+	}
+
+	if err := copyRemoteCerts(p); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	podmanCfg, err := generatePodmanOptions(p, dockerPort)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Setting Podman API configuration on the remote machine...")
+
+	if _, err := p.SSHCommand(fmt.Sprintf("printf %%s \"%s\" | sudo tee %s", podmanCfg.EngineOptions, podmanCfg.EngineOptionsPath)); err != nil {
+		return err
+	}
+
+	if err := p.Service(podmanTLSService, serviceaction.Enable); err != nil {
+		return err
+	}
+
+	if err := p.Service(podmanTLSService, serviceaction.Restart); err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
@@ -0,0 +1,27 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratePodmanOptions(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+
+	podmanCfg, err := generatePodmanOptions(p, 2376)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/podman-tls.service", podmanCfg.EngineOptionsPath)
+	assert.True(t, strings.Contains(podmanCfg.EngineOptions, "OPENSSL-LISTEN:2376"))
+	assert.True(t, strings.Contains(podmanCfg.EngineOptions, "cert=/etc/docker/server.pem,key=/etc/docker/server-key.pem,cafile=/etc/docker/ca.pem,verify=1"))
+	assert.True(t, strings.Contains(podmanCfg.EngineOptions, "UNIX-CONNECT:/run/podman/podman.sock"))
+}
@@ -13,7 +13,7 @@ import (
 )
 
 var (
-	provisioners          = make(map[string]*RegisteredProvisioner)
+	provisioners          = Registry{}
 	detector     Detector = &StandardDetector{}
 )
 
@@ -49,6 +49,9 @@ type Provisioner interface {
 	// Get the swarm options associated with this host.
 	GetSwarmOptions() swarm.Options
 
+	// Get the engine options associated with this host.
+	GetEngineOptions() engine.Options
+
 	// Run a package action e.g. install
 	Package(name string, action pkgaction.PackageAction) error
 
@@ -89,7 +92,7 @@ type RegisteredProvisioner struct {
 }
 
 func Register(name string, p *RegisteredProvisioner) {
-	provisioners[name] = p
+	provisioners.Register(name, p)
 }
 
 func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
@@ -97,13 +100,17 @@ func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
 }
 
 func (detector StandardDetector) DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+	return provisioners.DetectProvisioner(d)
+}
+
+// ReadOsRelease waits for SSH to be available, then reads /etc/os-release on
+// the machine.
+func ReadOsRelease(d drivers.Driver) (*OsRelease, error) {
 	log.Info("Waiting for SSH to be available...")
 	if err := drivers.WaitForSSH(d); err != nil {
 		return nil, err
 	}
 
-	log.Info("Detecting the provisioner...")
-
 	osReleaseOut, err := drivers.RunSSHCommandFromDriver(d, "cat /etc/os-release")
 	if err != nil {
 		return nil, fmt.Errorf("Error getting SSH command: %s", err)
@@ -114,15 +121,41 @@ func (detector StandardDetector) DetectProvisioner(d drivers.Driver) (Provisione
 		return nil, fmt.Errorf("Error parsing /etc/os-release file: %s", err)
 	}
 
-	for _, p := range provisioners {
-		provisioner := p.New(d)
-		provisioner.SetOsReleaseInfo(osReleaseInfo)
+	return osReleaseInfo, nil
+}
+
+// CompatibleProvisioner returns the registered provisioner compatible with
+// the os-release info of the machine, without connecting to it.
+func CompatibleProvisioner(d drivers.Driver, osReleaseInfo *OsRelease) (Provisioner, error) {
+	return provisioners.Compatible(d, osReleaseInfo)
+}
+
+// NewProvisioner returns the provisioner registered with the given name,
+// ignoring the case, whether or not it's compatible with the os-release info
+// of the machine.
+func NewProvisioner(name string, d drivers.Driver, osReleaseInfo *OsRelease) (Provisioner, error) {
+	return provisioners.New(name, d, osReleaseInfo)
+}
+
+// IsRegistered returns true if a provisioner is registered with the given
+// name, ignoring the case.
+func IsRegistered(name string) bool {
+	return provisioners.Has(name)
+}
+
+// ProvisionerNames returns the sorted names of the registered provisioners.
+func ProvisionerNames() []string {
+	return provisioners.Names()
+}
 
-		if provisioner.CompatibleWithHost() {
-			log.Debugf("found compatible host: %s", osReleaseInfo.ID)
-			return provisioner, nil
-		}
+// ManagesDockerd returns true if the engine of the machine is a dockerd
+// running as root under the docker service, which Machine reconfigures and
+// restarts.  The engines of podman, containerd, rootless and Windows
+// machines are configured differently.
+func ManagesDockerd(p Provisioner, engineOptions engine.Options) bool {
+	if _, ok := p.(*WindowsProvisioner); ok {
+		return false
 	}
 
-	return nil, ErrDetectionFailed
+	return !engineOptions.IsPodman() && !engineOptions.IsContainerd() && !engineOptions.Rootless
 }
@@ -0,0 +1,62 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompatibleProvisioner(t *testing.T) {
+	provisioner, err := CompatibleProvisioner(&fakedriver.Driver{}, &OsRelease{ID: "rocky"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "rocky", provisioner.String())
+
+	_, err = CompatibleProvisioner(&fakedriver.Driver{}, &OsRelease{ID: "plan9"})
+	assert.Equal(t, ErrDetectionFailed, err)
+}
+
+func TestNewProvisioner(t *testing.T) {
+	info := &OsRelease{ID: "pop", IDLike: "ubuntu debian"}
+	provisioner, err := NewProvisioner("debian", &fakedriver.Driver{}, info)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "debian", provisioner.String())
+	actual, _ := provisioner.GetOsReleaseInfo()
+	assert.Equal(t, info, actual)
+
+	_, err = NewProvisioner("plan9", &fakedriver.Driver{}, info)
+	assert.Error(t, err)
+}
+
+func TestIsRegistered(t *testing.T) {
+	assert.True(t, IsRegistered("Ubuntu-SystemD"))
+	assert.True(t, IsRegistered("ubuntu-systemd"))
+	assert.False(t, IsRegistered("ubuntu(systemd)"))
+	assert.Contains(t, ProvisionerNames(), "Debian")
+}
+
+func TestRegistryClone(t *testing.T) {
+	registry := DefaultRegistry().Clone()
+	registry.Register("Appliance", &RegisteredProvisioner{New: NewDebianProvisioner})
+
+	assert.True(t, registry.Has("appliance"))
+	assert.True(t, registry.Has("debian"))
+	assert.False(t, IsRegistered("appliance"))
+
+	provisioner, err := registry.New("appliance", &fakedriver.Driver{}, &OsRelease{ID: "appliance"})
+	assert.NoError(t, err)
+	assert.Equal(t, "debian", provisioner.String())
+}
+
+func TestManagesDockerd(t *testing.T) {
+	p := &FakeProvisioner{}
+
+	assert.True(t, ManagesDockerd(p, engine.Options{}))
+	assert.False(t, ManagesDockerd(p, engine.Options{Rootless: true}))
+	assert.False(t, ManagesDockerd(p, engine.Options{ContainerRuntime: engine.RuntimePodman}))
+	assert.False(t, ManagesDockerd(p, engine.Options{ContainerRuntime: engine.RuntimeContainerd}))
+	assert.False(t, ManagesDockerd(&WindowsProvisioner{}, engine.Options{}))
+}
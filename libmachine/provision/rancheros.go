@@ -39,7 +39,7 @@ func init() {
 func NewRancherProvisioner(d drivers.Driver) Provisioner {
 	return &RancherProvisioner{
 		GenericProvisioner{
-			SSHCommander:      GenericSSHCommander{Driver: d},
+			SSHCommander:      &GenericSSHCommander{Driver: d},
 			DockerOptionsDir:  "/var/lib/rancher/conf",
 			DaemonOptionsFile: "/var/lib/rancher/conf/docker",
 			OsReleaseID:       "rancheros",
@@ -96,7 +96,7 @@ func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, aut
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
+	swarmOptions.Env = engineOptions.PlainEnv()
 
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "overlay"
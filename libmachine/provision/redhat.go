@@ -33,7 +33,7 @@ After=network.target
 
 [Service]
 Type=notify
-ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
+ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --config-file {{.DaemonConfigPath}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}
 ExecReload=/bin/kill -s HUP $MAINPID
 MountFlags=slave
 LimitNOFILE=infinity
@@ -42,7 +42,7 @@ LimitCORE=infinity
 TimeoutStartSec=0
 Delegate=yes
 KillMode=process
-Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
+Environment={{range .EngineOptions.PlainEnv}}{{ printf "%q" . }} {{end}}
 
 [Install]
 WantedBy=multi-user.target
@@ -67,6 +67,10 @@ func init() {
 func NewRedHatProvisioner(osReleaseID string, d drivers.Driver) *RedHatProvisioner {
 	systemdProvisioner := NewSystemdProvisioner(osReleaseID, d)
 	systemdProvisioner.SSHCommander = RedHatSSHCommander{Driver: d}
+	systemdProvisioner.RootlessPackages = []string{
+		"shadow-utils",
+		"fuse-overlayfs",
+	}
 	return &RedHatProvisioner{
 		systemdProvisioner,
 	}
@@ -125,7 +129,7 @@ func (provisioner *RedHatProvisioner) Package(name string, action pkgaction.Pack
 }
 
 func installDocker(provisioner *RedHatProvisioner) error {
-	if err := installDockerGeneric(provisioner, provisioner.EngineOptions.InstallURL); err != nil {
+	if err := installEngine(provisioner, provisioner.EngineOptions); err != nil {
 		return err
 	}
 
@@ -157,7 +161,7 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
+	swarmOptions.Env = engineOptions.PlainEnv()
 
 	// set default storage driver for redhat
 	storageDriver, err := decideStorageDriver(provisioner, "devicemapper", engineOptions.StorageDriver)
@@ -170,7 +174,7 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 		return err
 	}
 
-	for _, pkg := range provisioner.Packages {
+	for _, pkg := range basePackages(provisioner.Packages, engineOptions) {
 		log.Debugf("installing base package: name=%s", pkg)
 		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
 			return err
@@ -178,8 +182,24 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 	}
 
 	// update OS -- this is needed for libdevicemapper and the docker install
-	if _, err := provisioner.SSHCommand("sudo -E yum -y update"); err != nil {
-		return err
+	if engineOptions.InstallBundle == "" {
+		if _, err := provisioner.SSHCommand("sudo -E yum -y update"); err != nil {
+			return err
+		}
+	}
+
+	if engineOptions.IsPodman() {
+		provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+		return provisionPodman(provisioner)
+	}
+
+	if engineOptions.IsContainerd() {
+		provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+		return provisionContainerd(provisioner)
+	}
+
+	if engineOptions.Rootless {
+		return provisioner.provisionRootless(provisioner)
 	}
 
 	// install docker
@@ -191,10 +211,20 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 		return err
 	}
 
+	if engineOptions.GPU != "" {
+		if err := provisioner.installGPU(); err != nil {
+			return err
+		}
+	}
+
 	if err := makeDockerOptionsDir(provisioner); err != nil {
 		return err
 	}
 
+	if err := configureSELinux(provisioner, &provisioner.EngineOptions); err != nil {
+		return err
+	}
+
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
 	if err := ConfigureAuth(provisioner); err != nil {
@@ -224,11 +254,17 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 		return nil, err
 	}
 
+	daemonConfig, err := generateDaemonConfig(provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:       dockerPort,
 		AuthOptions:      provisioner.AuthOptions,
 		EngineOptions:    provisioner.EngineOptions,
 		DockerOptionsDir: provisioner.DockerOptionsDir,
+		DaemonConfigPath: daemonConfigPath(provisioner),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -237,6 +273,8 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: daemonOptsDir,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
 	}, nil
 }
 
@@ -24,15 +24,20 @@ func (sshCmder RedHatSSHCommander) SSHCommand(args string) (string, error) {
 	// external client and add as needed.
 	// Note: CentOS 7.0 needs multiple "-tt" to force tty allocation when ssh has
 	// no local tty.
-	var output string
-	switch c := client.(type) {
-	case *ssh.ExternalClient:
+	if c, ok := client.(*ssh.ExternalClient); ok {
 		c.BaseArgs = append(c.BaseArgs, "-tt")
-		output, err = c.Output(args)
-	case *ssh.NativeClient:
-		output, err = c.OutputWithPty(args)
 	}
 
+	output, err := ssh.RetryOutput(func() (string, error) {
+		switch c := client.(type) {
+		case *ssh.ExternalClient:
+			return c.Output(args)
+		case *ssh.NativeClient:
+			return c.OutputWithPty(args)
+		}
+		return "", nil
+	})
+
 	log.Debugf("SSH cmd err, output: %v: %s", err, output)
 	if err != nil {
 		return "", fmt.Errorf(`Something went wrong running an SSH command!
@@ -0,0 +1,101 @@
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// Registry maps the names of the provisioners to their constructors, and
+// detects the provisioner of a machine among them.  The provisioners of this
+// package register in the default registry, while the programs embedding
+// libmachine can give a registry of their own to its client.
+type Registry map[string]*RegisteredProvisioner
+
+// DefaultRegistry returns the registry the provisioners of this package
+// register in.
+func DefaultRegistry() Registry {
+	return provisioners
+}
+
+// Register adds a provisioner to the registry.
+func (r Registry) Register(name string, p *RegisteredProvisioner) {
+	r[name] = p
+}
+
+// Clone returns a copy of the registry, e.g. to add provisioners to the
+// default ones without registering them in the whole program.
+func (r Registry) Clone() Registry {
+	clone := Registry{}
+	for name, p := range r {
+		clone[name] = p
+	}
+	return clone
+}
+
+// DetectProvisioner reads the os-release info of the machine, and returns
+// the provisioner of the registry compatible with it.
+func (r Registry) DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+	osReleaseInfo, err := ReadOsRelease(d)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Detecting the provisioner...")
+	return r.Compatible(d, osReleaseInfo)
+}
+
+// Compatible returns the provisioner of the registry compatible with the
+// os-release info of the machine, without connecting to it.
+func (r Registry) Compatible(d drivers.Driver, osReleaseInfo *OsRelease) (Provisioner, error) {
+	for _, p := range r {
+		provisioner := p.New(d)
+		provisioner.SetOsReleaseInfo(osReleaseInfo)
+
+		if provisioner.CompatibleWithHost() {
+			log.Debugf("found compatible host: %s", osReleaseInfo.ID)
+			return provisioner, nil
+		}
+	}
+
+	return nil, ErrDetectionFailed
+}
+
+// New returns the provisioner registered with the given name, ignoring the
+// case, whether or not it's compatible with the os-release info of the
+// machine.
+func (r Registry) New(name string, d drivers.Driver, osReleaseInfo *OsRelease) (Provisioner, error) {
+	for registeredName, p := range r {
+		if strings.EqualFold(name, registeredName) {
+			provisioner := p.New(d)
+			provisioner.SetOsReleaseInfo(osReleaseInfo)
+			return provisioner, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown provisioner %q, expected one of: %s", name, strings.Join(r.Names(), ", "))
+}
+
+// Has returns true if a provisioner is registered with the given name,
+// ignoring the case.
+func (r Registry) Has(name string) bool {
+	for registeredName := range r {
+		if strings.EqualFold(name, registeredName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the sorted names of the provisioners of the registry.
+func (r Registry) Names() []string {
+	names := []string{}
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,25 @@
+package provision
+
+import (
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func init() {
+	Register("Rocky", &RegisteredProvisioner{
+		New: NewRockyLinuxProvisioner,
+	})
+}
+
+func NewRockyLinuxProvisioner(d drivers.Driver) Provisioner {
+	return &RockyLinuxProvisioner{
+		NewEnterpriseLinuxProvisioner("rocky", d),
+	}
+}
+
+type RockyLinuxProvisioner struct {
+	*EnterpriseLinuxProvisioner
+}
+
+func (provisioner *RockyLinuxProvisioner) String() string {
+	return "rocky"
+}
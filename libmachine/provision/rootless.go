@@ -0,0 +1,218 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+const (
+	// rootlessUser runs the engine on the machines reached as root, since a
+	// rootless engine can't be run by root.
+	rootlessUser = "rootless"
+
+	// The rootless installer sets up a systemd user service, which is
+	// configured with a drop-in overriding its command line.  rootlesskit
+	// publishes the engine port from the user namespace, %h and %t being the
+	// home and the runtime directories of the user.
+	rootlessEngineConfigTemplate = `[Service]
+Environment=DOCKERD_ROOTLESS_ROOTLESSKIT_FLAGS=--publish=0.0.0.0:{{.DockerPort}}:{{.DockerPort}}/tcp{{ range .EngineOptions.PlainEnv }} {{ printf "%q" . }}{{ end }}
+ExecStart=
+ExecStart=%h/bin/dockerd-rootless.sh --host=unix://%t/docker.sock --host=tcp://0.0.0.0:{{.DockerPort}} --config-file {{.DaemonConfigPath}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}
+`
+)
+
+// rootlessUserName returns the user the rootless engine runs as, creating it
+// if the machine is reached as root.
+func rootlessUserName(p Provisioner) (string, error) {
+	user := p.GetDriver().GetSSHUsername()
+	if user != "root" {
+		return user, nil
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("id -u %s || sudo useradd -m -s /bin/bash %s", rootlessUser, rootlessUser)); err != nil {
+		return "", err
+	}
+
+	return rootlessUser, nil
+}
+
+// runAsRootlessUser runs a command in a login shell of the rootless user,
+// with access to its systemd user manager.
+func runAsRootlessUser(p Provisioner, user, command string) (string, error) {
+	return p.SSHCommand(fmt.Sprintf("sudo -iu %s sh -c 'export XDG_RUNTIME_DIR=/run/user/$(id -u); %s'", user, command))
+}
+
+func rootlessInstallURL(installURL string) string {
+	return strings.TrimSuffix(installURL, "/") + "/rootless"
+}
+
+func installRootlessDocker(p Provisioner, user, installURL string) error {
+	if output, err := runAsRootlessUser(p, user, fmt.Sprintf("curl -fsSL %s | sh", rootlessInstallURL(installURL))); err != nil {
+		return fmt.Errorf("error installing rootless docker: %s\n", output)
+	}
+
+	return nil
+}
+
+// UpgradeRootlessDocker upgrades and restarts the engine of a machine
+// provisioned with --engine-rootless.
+func UpgradeRootlessDocker(p Provisioner, installURL string) error {
+	user, err := rootlessUserName(p)
+	if err != nil {
+		return err
+	}
+
+	if err := installRootlessDocker(p, user, installURL); err != nil {
+		return err
+	}
+
+	_, err = runAsRootlessUser(p, user, "systemctl --user restart docker")
+	return err
+}
+
+// RestartRootlessDocker restarts the engine of a machine provisioned with
+// --engine-rootless, managed by the systemd user manager of its user.
+func RestartRootlessDocker(p Provisioner) error {
+	user, err := rootlessUserName(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = runAsRootlessUser(p, user, "systemctl --user restart docker")
+	return err
+}
+
+func (provisioner *GenericProvisioner) generateRootlessDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var (
+		engineCfg bytes.Buffer
+	)
+
+	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
+	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+
+	t, err := template.New("engineConfig").Parse(rootlessEngineConfigTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	daemonConfig, err := generateDaemonConfig(provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// The daemon.json is next to the certificates, in the configuration
+	// directory of the rootless user
+	engineConfigContext := EngineConfigContext{
+		DockerPort:       dockerPort,
+		AuthOptions:      provisioner.AuthOptions,
+		EngineOptions:    provisioner.EngineOptions,
+		DaemonConfigPath: path.Join(path.Dir(provisioner.AuthOptions.CaCertRemotePath), daemonConfigFileName),
+	}
+
+	t.Execute(&engineCfg, engineConfigContext)
+
+	return &DockerOptions{
+		EngineOptions:    engineCfg.String(),
+		DaemonConfig:     daemonConfig,
+		DaemonConfigPath: engineConfigContext.DaemonConfigPath,
+	}, nil
+}
+
+// provisionRootless installs Docker in rootless mode: the engine runs as an
+// unprivileged user, in a user namespace set up by rootlesskit, and is
+// managed by the systemd user manager of that user.  The certificates are
+// owned by the user, the engine being unable to read root-owned files.  p is
+// the distribution provisioner embedding this one.
+func (provisioner *GenericProvisioner) provisionRootless(p Provisioner) error {
+	log.Info("Installing rootless Docker...")
+	for _, pkg := range provisioner.RootlessPackages {
+		if err := p.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	user, err := rootlessUserName(p)
+	if err != nil {
+		return err
+	}
+
+	home, err := p.SSHCommand(fmt.Sprintf("getent passwd %s | cut -d: -f6", user))
+	if err != nil {
+		return err
+	}
+	home = strings.TrimSpace(home)
+
+	// The user manager has to outlive the SSH sessions to keep the engine
+	// running.
+	log.Debug("enabling lingering for the rootless user")
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo loginctl enable-linger %s && sudo systemctl start user@$(id -u %s).service", user, user)); err != nil {
+		return err
+	}
+
+	if _, err := runAsRootlessUser(p, user, "test -x ~/bin/dockerd-rootless.sh"); err != nil {
+		if err := installRootlessDocker(p, user, provisioner.EngineOptions.InstallURL); err != nil {
+			return err
+		}
+	}
+
+	// the storage driver is picked by the engine unless one is supplied,
+	// aufs is not available in a user namespace
+	if provisioner.EngineOptions.StorageDriver == "aufs" {
+		provisioner.EngineOptions.StorageDriver = ""
+	}
+
+	certsDir := path.Join(home, ".config", "docker")
+	provisioner.AuthOptions.CaCertRemotePath = path.Join(certsDir, "ca.pem")
+	provisioner.AuthOptions.ServerCertRemotePath = path.Join(certsDir, "server.pem")
+	provisioner.AuthOptions.ServerKeyRemotePath = path.Join(certsDir, "server-key.pem")
+
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", certsDir)); err != nil {
+		return err
+	}
+
+	if err := generateServerCert(p); err != nil {
+		return err
+	}
+
+	if err := copyRemoteCerts(p); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	dkrcfg, err := provisioner.generateRootlessDockerOptions(dockerPort)
+	if err != nil {
+		return err
+	}
+
+	dropInDir := path.Join(home, ".config", "systemd", "user", "docker.service.d")
+
+	log.Info("Setting Docker configuration on the remote daemon...")
+
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s && printf %%s \"%s\" | sudo tee %s", dropInDir, strings.Replace(dkrcfg.EngineOptions, `"`, `\"`, -1), path.Join(dropInDir, "10-machine.conf"))); err != nil {
+		return err
+	}
+
+	if err := writeRemoteFile(p, dkrcfg.DaemonConfig, dkrcfg.DaemonConfigPath); err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo chown -R %s: %s && sudo chmod 600 %s", user, path.Join(home, ".config"), provisioner.AuthOptions.ServerKeyRemotePath)); err != nil {
+		return err
+	}
+
+	if _, err := runAsRootlessUser(p, user, "systemctl --user daemon-reload && systemctl --user enable docker && systemctl --user restart docker"); err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
@@ -0,0 +1,41 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateRootlessDockerOptions(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/home/ubuntu/.config/docker/ca.pem",
+		ServerCertRemotePath: "/home/ubuntu/.config/docker/server.pem",
+		ServerKeyRemotePath:  "/home/ubuntu/.config/docker/server-key.pem",
+	}
+	p.EngineOptions = engine.Options{
+		Labels: []string{"foo=bar"},
+	}
+
+	dockerCfg, err := p.generateRootlessDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(dockerCfg.EngineOptions, "DOCKERD_ROOTLESS_ROOTLESSKIT_FLAGS=--publish=0.0.0.0:2376:2376/tcp"))
+	assert.True(t, strings.Contains(dockerCfg.EngineOptions, "ExecStart=%h/bin/dockerd-rootless.sh --host=unix://%t/docker.sock --host=tcp://0.0.0.0:2376 --config-file /home/ubuntu/.config/docker/daemon.json --tlsverify"))
+	assert.True(t, strings.Contains(dockerCfg.EngineOptions, "--tlskey /home/ubuntu/.config/docker/server-key.pem"))
+	assert.Equal(t, "/home/ubuntu/.config/docker/daemon.json", dockerCfg.DaemonConfigPath)
+	assert.Contains(t, dockerCfg.DaemonConfig, `"labels": [
+    "foo=bar",
+    "provider=Driver"
+  ]`)
+	assert.False(t, strings.Contains(dockerCfg.DaemonConfig, "storage-driver"))
+}
+
+func TestRootlessInstallURL(t *testing.T) {
+	assert.Equal(t, "https://get.docker.com/rootless", rootlessInstallURL("https://get.docker.com"))
+	assert.Equal(t, "https://test.docker.com/rootless", rootlessInstallURL("https://test.docker.com/"))
+}
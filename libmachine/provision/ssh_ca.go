@@ -0,0 +1,38 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	sshCAFile         = "/etc/ssh/machine-ca.pub"
+	sshdConfigFile    = "/etc/ssh/sshd_config"
+	trustedUserCAKeys = "TrustedUserCAKeys " + sshCAFile
+)
+
+// ConfigureSSHCA makes the SSH server of the machine trust the certificates
+// signed by the SSH certificate authority of Machine.  The option is added at
+// the top of sshd_config, where it can't end up in a Match block, and the
+// first value of an option is the one the server uses.
+func ConfigureSSHCA(p Provisioner, caPublicKey []byte) error {
+	if _, ok := p.(*WindowsProvisioner); ok {
+		return ErrSSHCANotSupported
+	}
+
+	log.Debug("installing the SSH CA")
+	commands := []string{
+		fmt.Sprintf("printf '%%s\\n' '%s' | sudo tee %s", strings.TrimSpace(string(caPublicKey)), sshCAFile),
+		fmt.Sprintf("sudo grep -q '^%[1]s$' %[2]s || sudo sed -i '1i %[1]s' %[2]s", trustedUserCAKeys, sshdConfigFile),
+		"if command -v systemctl >/dev/null; then sudo systemctl reload sshd || sudo systemctl reload ssh; else sudo kill -HUP $(cat /var/run/sshd.pid); fi",
+	}
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error installing the SSH CA: %s\n%s", err, output)
+		}
+	}
+
+	return nil
+}
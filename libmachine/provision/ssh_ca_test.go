@@ -0,0 +1,27 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureSSHCA(t *testing.T) {
+	commander := &recordingSSHCommander{}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	assert.NoError(t, ConfigureSSHCA(p, []byte("ecdsa-sha2-nistp256 AAAA\n")))
+	assert.Equal(t, []string{
+		"printf '%s\\n' 'ecdsa-sha2-nistp256 AAAA' | sudo tee /etc/ssh/machine-ca.pub",
+		"sudo grep -q '^TrustedUserCAKeys /etc/ssh/machine-ca.pub$' /etc/ssh/sshd_config || sudo sed -i '1i TrustedUserCAKeys /etc/ssh/machine-ca.pub' /etc/ssh/sshd_config",
+		"if command -v systemctl >/dev/null; then sudo systemctl reload sshd || sudo systemctl reload ssh; else sudo kill -HUP $(cat /var/run/sshd.pid); fi",
+	}, commander.commands)
+}
+
+func TestConfigureSSHCANotSupported(t *testing.T) {
+	err := ConfigureSSHCA(&WindowsProvisioner{Driver: &fakedriver.Driver{}}, []byte("ecdsa-sha2-nistp256 AAAA"))
+	assert.Equal(t, ErrSSHCANotSupported, err)
+}
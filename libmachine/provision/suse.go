@@ -30,7 +30,7 @@ func init() {
 func NewOpenSUSEProvisioner(d drivers.Driver) Provisioner {
 	return &SUSEProvisioner{
 		GenericProvisioner{
-			SSHCommander:      GenericSSHCommander{Driver: d},
+			SSHCommander:      &GenericSSHCommander{Driver: d},
 			DockerOptionsDir:  "/etc/docker",
 			DaemonOptionsFile: "/etc/sysconfig/docker",
 			OsReleaseID:       "opensuse",
@@ -45,7 +45,7 @@ func NewOpenSUSEProvisioner(d drivers.Driver) Provisioner {
 func NewSLEDProvisioner(d drivers.Driver) Provisioner {
 	return &SUSEProvisioner{
 		GenericProvisioner{
-			SSHCommander:      GenericSSHCommander{Driver: d},
+			SSHCommander:      &GenericSSHCommander{Driver: d},
 			DockerOptionsDir:  "/etc/docker",
 			DaemonOptionsFile: "/etc/sysconfig/docker",
 			OsReleaseID:       "sled",
@@ -60,7 +60,7 @@ func NewSLEDProvisioner(d drivers.Driver) Provisioner {
 func NewSLESProvisioner(d drivers.Driver) Provisioner {
 	return &SUSEProvisioner{
 		GenericProvisioner{
-			SSHCommander:      GenericSSHCommander{Driver: d},
+			SSHCommander:      &GenericSSHCommander{Driver: d},
 			DockerOptionsDir:  "/etc/docker",
 			DaemonOptionsFile: "/etc/sysconfig/docker",
 			OsReleaseID:       "sles",
@@ -143,27 +143,29 @@ func (provisioner *SUSEProvisioner) Provision(swarmOptions swarm.Options, authOp
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
+	swarmOptions.Env = engineOptions.PlainEnv()
 
 	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
 		return err
 	}
 
-	for _, pkg := range provisioner.Packages {
+	for _, pkg := range basePackages(provisioner.Packages, engineOptions) {
 		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
 			return err
 		}
 	}
 
 	// update OS -- this is needed for libdevicemapper and the docker install
-	if _, err := provisioner.SSHCommand("sudo zypper ref"); err != nil {
-		return err
-	}
-	if _, err := provisioner.SSHCommand("sudo zypper -n update"); err != nil {
-		return err
+	if engineOptions.InstallBundle == "" {
+		if _, err := provisioner.SSHCommand("sudo zypper ref"); err != nil {
+			return err
+		}
+		if _, err := provisioner.SSHCommand("sudo zypper -n update"); err != nil {
+			return err
+		}
 	}
 
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+	if err := installEngine(provisioner, engineOptions); err != nil {
 		return err
 	}
 
@@ -216,18 +218,24 @@ func (provisioner *SUSEProvisioner) GenerateDockerOptions(dockerPort int) (*Dock
 	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
 
 	engineConfigTmpl := `# File automatically generated by docker-machine
-DOCKER_OPTS=' -H tcp://0.0.0.0:{{.DockerPort}} {{ if .EngineOptions.StorageDriver }} --storage-driver {{.EngineOptions.StorageDriver}} {{ end }} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}'
+DOCKER_OPTS=' -H tcp://0.0.0.0:{{.DockerPort}} --config-file {{.DaemonConfigPath}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}'
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
 	if err != nil {
 		return nil, err
 	}
 
+	daemonConfig, err := generateDaemonConfig(provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:       dockerPort,
 		AuthOptions:      provisioner.AuthOptions,
 		EngineOptions:    provisioner.EngineOptions,
 		DockerOptionsDir: provisioner.DockerOptionsDir,
+		DaemonConfigPath: daemonConfigPath(provisioner),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -236,5 +244,7 @@ DOCKER_OPTS=' -H tcp://0.0.0.0:{{.DockerPort}} {{ if .EngineOptions.StorageDrive
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: daemonOptsDir,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
 	}, nil
 }
@@ -9,6 +9,38 @@ import (
 	"github.com/docker/machine/libmachine/provision/serviceaction"
 )
 
+const systemdEngineConfigTmpl = `[Service]
+ExecStart={{.EngineCommand}} -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --config-file {{.DaemonConfigPath}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}
+{{if .MountFlags}}MountFlags={{.MountFlags}}
+{{end}}LimitNOFILE=1048576
+LimitNPROC=1048576
+LimitCORE=infinity
+Environment={{range .EngineOptions.PlainEnv}}{{ printf "%q" . }} {{end}}
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// systemdEngine is what the docker service differs in between the systemd
+// distributions.
+type systemdEngine struct {
+	// Command starts the engine, e.g. /usr/bin/dockerd
+	Command string
+
+	// MountFlags of the service, none if empty
+	MountFlags string
+
+	// SystemdCgroupDriver runs the engine with the systemd cgroup driver,
+	// unless another one is given
+	SystemdCgroupDriver bool
+}
+
+type systemdEngineConfigContext struct {
+	EngineConfigContext
+	EngineCommand string
+	MountFlags    string
+}
+
 type SystemdProvisioner struct {
 	GenericProvisioner
 }
@@ -20,19 +52,32 @@ func (p *SystemdProvisioner) String() string {
 func NewSystemdProvisioner(osReleaseID string, d drivers.Driver) SystemdProvisioner {
 	return SystemdProvisioner{
 		GenericProvisioner{
-			SSHCommander:      GenericSSHCommander{Driver: d},
+			SSHCommander:      &GenericSSHCommander{Driver: d},
 			DockerOptionsDir:  "/etc/docker",
 			DaemonOptionsFile: "/etc/systemd/system/docker.service",
 			OsReleaseID:       osReleaseID,
 			Packages: []string{
 				"curl",
 			},
+			RootlessPackages: []string{
+				"uidmap",
+				"dbus-user-session",
+			},
 			Driver: d,
 		},
 	}
 }
 
 func (p *SystemdProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	return p.generateSystemdDockerOptions(dockerPort, systemdEngine{
+		Command:    "/usr/bin/docker daemon",
+		MountFlags: "slave",
+	})
+}
+
+// generateSystemdDockerOptions renders the docker service starting the
+// engine, and its daemon.json.
+func (p *SystemdProvisioner) generateSystemdDockerOptions(dockerPort int, unit systemdEngine) (*DockerOptions, error) {
 	var (
 		engineCfg bytes.Buffer
 	)
@@ -40,26 +85,30 @@ func (p *SystemdProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptio
 	driverNameLabel := fmt.Sprintf("provider=%s", p.Driver.DriverName())
 	p.EngineOptions.Labels = append(p.EngineOptions.Labels, driverNameLabel)
 
-	engineConfigTmpl := `[Service]
-ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
-MountFlags=slave
-LimitNOFILE=1048576
-LimitNPROC=1048576
-LimitCORE=infinity
-Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
+	t, err := template.New("engineConfig").Parse(systemdEngineConfigTmpl)
+	if err != nil {
+		return nil, err
+	}
 
-[Install]
-WantedBy=multi-user.target
-`
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	engineOptions := p.EngineOptions
+	if unit.SystemdCgroupDriver {
+		engineOptions = withSystemdCgroupDriver(engineOptions)
+	}
+
+	daemonConfig, err := generateDaemonConfig(engineOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	engineConfigContext := EngineConfigContext{
-		DockerPort:    dockerPort,
-		AuthOptions:   p.AuthOptions,
-		EngineOptions: p.EngineOptions,
+	engineConfigContext := systemdEngineConfigContext{
+		EngineConfigContext: EngineConfigContext{
+			DockerPort:       dockerPort,
+			AuthOptions:      p.AuthOptions,
+			EngineOptions:    p.EngineOptions,
+			DaemonConfigPath: daemonConfigPath(p),
+		},
+		EngineCommand: unit.Command,
+		MountFlags:    unit.MountFlags,
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -67,6 +116,8 @@ WantedBy=multi-user.target
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: p.DaemonOptionsFile,
+		DaemonConfig:      daemonConfig,
+		DaemonConfigPath:  engineConfigContext.DaemonConfigPath,
 	}, nil
 }
 
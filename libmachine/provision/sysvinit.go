@@ -0,0 +1,73 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+)
+
+// initSystem is the init system managing the services of a machine.  The
+// Debian family can boot without systemd, e.g. Devuan, in which case the
+// engine is managed with its init script and configured in the file the
+// script sources.
+type initSystem string
+
+const (
+	initSystemd initSystem = "systemd"
+	initOpenRC  initSystem = "openrc"
+	initSysV    initSystem = "sysvinit"
+
+	detectInitSystemCommand = "if [ -d /run/systemd/system ]; then echo systemd; elif type openrc >/dev/null 2>&1; then echo openrc; else echo sysvinit; fi"
+)
+
+// detectInitSystem returns the init system of the machine, systemd when it
+// can't be told.
+func detectInitSystem(p SSHCommander) initSystem {
+	output, err := p.SSHCommand(detectInitSystemCommand)
+	if err != nil {
+		log.Debugf("Error detecting the init system, assuming systemd: %s", err)
+		return initSystemd
+	}
+
+	switch init := initSystem(strings.TrimSpace(output)); init {
+	case initOpenRC, initSysV:
+		return init
+	}
+
+	return initSystemd
+}
+
+// daemonOptionsFile returns the file the init script of the engine sources
+// its options from.
+func (init initSystem) daemonOptionsFile() string {
+	if init == initOpenRC {
+		return "/etc/conf.d/docker"
+	}
+	return "/etc/default/docker"
+}
+
+// serviceCommand returns the command running a service action with an init
+// system other than systemd, or "" when there's nothing to do.
+func (init initSystem) serviceCommand(name string, action serviceaction.ServiceAction) string {
+	switch action {
+	case serviceaction.DaemonReload:
+		return ""
+	case serviceaction.Enable:
+		if init == initOpenRC {
+			return fmt.Sprintf("sudo rc-update add %s default", name)
+		}
+		return fmt.Sprintf("sudo update-rc.d %s defaults", name)
+	case serviceaction.Disable:
+		if init == initOpenRC {
+			return fmt.Sprintf("sudo rc-update del %s default", name)
+		}
+		return fmt.Sprintf("sudo update-rc.d -f %s remove", name)
+	}
+
+	if init == initOpenRC {
+		return fmt.Sprintf("sudo rc-service %s %s", name, action.String())
+	}
+	return fmt.Sprintf("sudo service %s %s", name, action.String())
+}
@@ -97,13 +97,41 @@ func (provisioner *UbuntuSystemdProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
+// GenerateDockerOptions runs dockerd, the docker daemon command being long
+// gone from the engine, with the systemd cgroup driver on cgroup v2.
+func (provisioner *UbuntuSystemdProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	return provisioner.generateSystemdDockerOptions(dockerPort, systemdEngine{
+		Command:             "/usr/bin/dockerd",
+		SystemdCgroupDriver: cgroupV2(provisioner),
+	})
+}
+
+// ubuntuStorageDriver returns the storage driver given to the engine, or
+// else btrfs on a btrfs filesystem and overlay2 on the others, since aufs
+// was removed from the engine in 24.0.
+func ubuntuStorageDriver(p Provisioner, suppliedDriver string) (string, error) {
+	if suppliedDriver != "" {
+		return suppliedDriver, nil
+	}
+
+	filesystem, err := getFilesystemType(p, "/var/lib")
+	if err != nil {
+		return "", err
+	}
+
+	if filesystem == "btrfs" {
+		return "btrfs", nil
+	}
+	return "overlay2", nil
+}
+
 func (provisioner *UbuntuSystemdProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
+	swarmOptions.Env = engineOptions.PlainEnv()
 
-	storageDriver, err := decideStorageDriver(provisioner, "aufs", engineOptions.StorageDriver)
+	storageDriver, err := ubuntuStorageDriver(provisioner, engineOptions.StorageDriver)
 	if err != nil {
 		return err
 	}
@@ -115,14 +143,33 @@ func (provisioner *UbuntuSystemdProvisioner) Provision(swarmOptions swarm.Option
 	}
 
 	log.Debug("installing base packages")
-	for _, pkg := range provisioner.Packages {
+	for _, pkg := range basePackages(provisioner.Packages, engineOptions) {
 		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
 			return err
 		}
 	}
 
+	if engineOptions.IsPodman() {
+		provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+		return provisionPodman(provisioner)
+	}
+
+	if engineOptions.IsContainerd() {
+		provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+		return provisionContainerd(provisioner)
+	}
+
+	if engineOptions.Rootless {
+		return provisioner.provisionRootless(provisioner)
+	}
+
+	log.Debug("configuring iptables")
+	if err := configureIptables(provisioner); err != nil {
+		return err
+	}
+
 	log.Info("Installing Docker...")
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+	if err := installEngine(provisioner, engineOptions); err != nil {
 		return err
 	}
 
@@ -131,6 +178,14 @@ func (provisioner *UbuntuSystemdProvisioner) Provision(swarmOptions swarm.Option
 		return err
 	}
 
+	if engineOptions.GPU != "" {
+		if err := provisioner.installGPU(); err != nil {
+			return err
+		}
+	}
+
+	configureAppArmor(provisioner)
+
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
 	log.Debug("configuring auth")
@@ -8,6 +8,7 @@ import (
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/provision/provisiontest"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestUbuntuSystemdCompatibleWithHost(t *testing.T) {
@@ -38,7 +39,95 @@ func TestUbuntuSystemdDefaultStorageDriver(t *testing.T) {
 	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
 	p.SSHCommander = provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
 	p.Provision(swarm.Options{}, auth.Options{}, engine.Options{})
-	if p.EngineOptions.StorageDriver != "aufs" {
-		t.Fatal("Default storage driver should be aufs")
+	if p.EngineOptions.StorageDriver != "overlay2" {
+		t.Fatal("Default storage driver should be overlay2")
+	}
+}
+
+func TestUbuntuStorageDriver(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{FilesystemType: "btrfs"})
+
+	storageDriver, err := ubuntuStorageDriver(p, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "btrfs", storageDriver)
+
+	storageDriver, err = ubuntuStorageDriver(p, "zfs")
+	assert.NoError(t, err)
+	assert.Equal(t, "zfs", storageDriver)
+}
+
+// The releases of Ubuntu as seen by the provisioner: the filesystem of its
+// cgroup hierarchy and the version of its iptables.
+var ubuntuReleases = []struct {
+	version      string
+	cgroupFs     string
+	iptables     string
+	cgroupDriver bool
+	nftables     bool
+}{
+	{"20.04", "tmpfs", "iptables v1.8.4 (legacy)", false, false},
+	{"22.04", "cgroup2fs", "iptables v1.8.7 (nf_tables)", true, true},
+	{"24.04", "cgroup2fs", "iptables v1.8.10 (nf_tables)", true, true},
+}
+
+func TestUbuntuSystemdGenerateDockerOptions(t *testing.T) {
+	for _, release := range ubuntuReleases {
+		p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+		p.SSHCommander = &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{"stat -f -c %T /sys/fs/cgroup": release.cgroupFs + "\n"},
+		}
+		p.EngineOptions.StorageDriver = "overlay2"
+
+		dockerOptions, err := p.GenerateDockerOptions(2376)
+
+		assert.NoError(t, err)
+		assert.Contains(t, dockerOptions.EngineOptions, "ExecStart=/usr/bin/dockerd -H tcp://0.0.0.0:2376 ", release.version)
+		assert.NotContains(t, dockerOptions.EngineOptions, "MountFlags", release.version)
+		assert.NotContains(t, dockerOptions.DaemonConfig, "aufs", release.version)
+		if release.cgroupDriver {
+			assert.Contains(t, dockerOptions.DaemonConfig, `"native.cgroupdriver=systemd"`, release.version)
+		} else {
+			assert.NotContains(t, dockerOptions.DaemonConfig, "cgroupdriver", release.version)
+		}
+	}
+}
+
+func TestSystemdGenerateDockerOptions(t *testing.T) {
+	p := NewSystemdProvisioner("centos", &fakedriver.Driver{})
+
+	dockerOptions, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerOptions.EngineOptions, "ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:2376 ")
+	assert.Contains(t, dockerOptions.EngineOptions, "\nMountFlags=slave\nLimitNOFILE=1048576\n")
+}
+
+func TestUbuntuSystemdGenerateDockerOptionsCgroupDriverGiven(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{"stat -f -c %T /sys/fs/cgroup": "cgroup2fs\n"},
+	}
+	p.EngineOptions.DaemonConfig = []byte(`{"exec-opts": ["native.cgroupdriver=cgroupfs"]}`)
+
+	dockerOptions, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerOptions.DaemonConfig, `"native.cgroupdriver=cgroupfs"`)
+	assert.NotContains(t, dockerOptions.DaemonConfig, "systemd")
+}
+
+func TestUbuntuSystemdConfigureIptables(t *testing.T) {
+	for _, release := range ubuntuReleases {
+		commander := &iptablesSSHCommander{versions: []string{release.iptables}}
+		p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+		p.SSHCommander = commander
+
+		assert.NoError(t, configureIptables(p), release.version)
+		if release.nftables {
+			assert.Equal(t, []string{iptablesVersionCommand, ip6tablesNftCommand}, commander.commands, release.version)
+		} else {
+			assert.Equal(t, []string{iptablesVersionCommand}, commander.commands, release.version)
+		}
 	}
 }
@@ -1,6 +1,7 @@
 package provision
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -13,15 +14,22 @@ import (
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
 )
 
 type DockerOptions struct {
 	EngineOptions     string
 	EngineOptionsPath string
+
+	// DaemonConfig is the daemon.json of the engine, written to
+	// DaemonConfigPath unless it's empty.
+	DaemonConfig     string
+	DaemonConfigPath string
 }
 
 func installDockerGeneric(p Provisioner, baseURL string) error {
@@ -57,14 +65,75 @@ func setRemoteAuthOptions(p Provisioner) auth.Options {
 }
 
 func ConfigureAuth(p Provisioner) error {
-	var (
-		err error
-	)
+	if err := validateEngineOptions(p); err != nil {
+		return err
+	}
+
+	if err := generateServerCert(p); err != nil {
+		return err
+	}
+
+	if err := p.Service("docker", serviceaction.Stop); err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(`if [ ! -z "$(ip link show docker0)" ]; then sudo ip link delete docker0; fi`); err != nil {
+		return err
+	}
+
+	if err := copyRemoteCerts(p); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Setting Docker configuration on the remote daemon...")
 
-	driver := p.GetDriver()
+	if err := writeDockerOptions(p, dkrcfg); err != nil {
+		return err
+	}
+
+	if err := configureEngineSecrets(p); err != nil {
+		return err
+	}
+
+	if err := p.Service("docker", serviceaction.Start); err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
+
+// writeDockerOptions writes the configuration files of the engine.
+func writeDockerOptions(p Provisioner, dkrcfg *DockerOptions) error {
+	if dkrcfg.DaemonConfigPath != "" {
+		if err := writeRemoteFile(p, dkrcfg.DaemonConfig, dkrcfg.DaemonConfigPath); err != nil {
+			return err
+		}
+	}
+
+	_, err := p.SSHCommand(fmt.Sprintf("printf %%s \"%s\" | sudo tee %s", dkrcfg.EngineOptions, dkrcfg.EngineOptionsPath))
+	return err
+}
+
+// generateServerCert generates the server certificate of the options the
+// provisioner was given.
+func generateServerCert(p Provisioner) error {
+	return writeServerCert(p.GetDriver(), p.GetAuthOptions(), p.GetSwarmOptions())
+}
+
+// writeServerCert copies the client certificates to the local machine
+// directory and generates a server certificate signed by the machine CA.
+func writeServerCert(driver drivers.Driver, authOptions auth.Options, swarmOptions swarm.Options) error {
 	machineName := driver.GetMachineName()
-	authOptions := p.GetAuthOptions()
-	swarmOptions := p.GetSwarmOptions()
 	org := mcnutils.GetUsername() + "." + machineName
 	bits := 2048
 
@@ -87,8 +156,8 @@ func ConfigureAuth(p Provisioner) error {
 		return fmt.Errorf("Copying key.pem to machine dir failed: %s", err)
 	}
 
-	// The Host IP is always added to the certificate's SANs list
-	hosts := append(authOptions.ServerCertSANs, ip, "localhost")
+	// The Host IP and name are always added to the certificate's SANs list
+	hosts := append(authOptions.ServerCertSANs, ip, machineName, "localhost")
 	log.Debugf("generating server cert: %s ca-key=%s private-key=%s org=%s san=%s",
 		authOptions.ServerCertPath,
 		authOptions.CaCertPath,
@@ -114,13 +183,18 @@ func ConfigureAuth(p Provisioner) error {
 		return fmt.Errorf("error generating server cert: %s", err)
 	}
 
-	if err := p.Service("docker", serviceaction.Stop); err != nil {
-		return err
-	}
+	return nil
+}
 
-	if _, err := p.SSHCommand(`if [ ! -z "$(ip link show docker0)" ]; then sudo ip link delete docker0; fi`); err != nil {
-		return err
-	}
+// copyRemoteCerts uploads the CA certificate and the server key pair to
+// their remote paths on the machine.
+func copyRemoteCerts(p Provisioner) error {
+	return uploadCerts(p, p.GetAuthOptions())
+}
+
+// uploadCerts uploads the CA certificate and the server key pair of the
+// given options to their remote paths.
+func uploadCerts(p SSHCommander, authOptions auth.Options) error {
 
 	// upload certs and configure TLS auth
 	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
@@ -156,40 +230,41 @@ func ConfigureAuth(p Provisioner) error {
 		return err
 	}
 
+	return nil
+}
+
+// writeRemoteFile writes a file on the machine, creating its directory.  The
+// content is passed encoded, which spares quoting it for the shell.
+func writeRemoteFile(p SSHCommander, content, filePath string) error {
+	_, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s && echo %s | base64 -d | sudo tee %s > /dev/null",
+		path.Dir(filePath),
+		base64.StdEncoding.EncodeToString([]byte(content)),
+		filePath,
+	))
+
+	return err
+}
+
+// getDockerPort returns the port of the engine URL advertised by the driver.
+func getDockerPort(driver drivers.Driver) (int, error) {
 	dockerURL, err := driver.GetURL()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	u, err := url.Parse(dockerURL)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	dockerPort := engine.DefaultPort
-	parts := strings.Split(u.Host, ":")
-	if len(parts) == 2 {
-		dPort, err := strconv.Atoi(parts[1])
+	if port := u.Port(); port != "" {
+		dPort, err := strconv.Atoi(port)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		dockerPort = dPort
 	}
 
-	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
-	if err != nil {
-		return err
-	}
-
-	log.Info("Setting Docker configuration on the remote daemon...")
-
-	if _, err = p.SSHCommand(fmt.Sprintf("printf %%s \"%s\" | sudo tee %s", dkrcfg.EngineOptions, dkrcfg.EngineOptionsPath)); err != nil {
-		return err
-	}
-
-	if err := p.Service("docker", serviceaction.Start); err != nil {
-		return err
-	}
-
-	return WaitForDocker(p, dockerPort)
+	return dockerPort, nil
 }
 
 func matchNetstatOut(reDaemonListening, netstatOut string) bool {
@@ -232,6 +307,9 @@ func decideStorageDriver(p Provisioner, defaultDriver, suppliedDriver string) (s
 		}
 		if remoteFilesystemType == "btrfs" {
 			bestSuitedDriver = "btrfs"
+		} else if supported, _ := kernelSupports(p, "overlay"); supported {
+			// aufs was removed from the engine in 24.0
+			bestSuitedDriver = "overlay2"
 		} else {
 			bestSuitedDriver = "aufs"
 		}
@@ -265,10 +343,60 @@ func checkDaemonUp(p Provisioner, dockerPort int) func() bool {
 	}
 }
 
+// daemonChecker is implemented by the provisioners of the hosts without
+// netstat.
+type daemonChecker interface {
+	checkDaemonUp(dockerPort int) func() bool
+}
+
 func WaitForDocker(p Provisioner, dockerPort int) error {
-	if err := mcnutils.WaitForSpecific(checkDaemonUp(p, dockerPort), 10, 3*time.Second); err != nil {
+	daemonUp := checkDaemonUp(p, dockerPort)
+	if checker, ok := p.(daemonChecker); ok {
+		daemonUp = checker.checkDaemonUp(dockerPort)
+	}
+
+	if err := mcnutils.WaitForSpecific(daemonUp, 10, 3*time.Second); err != nil {
 		return NewErrDaemonAvailable(err)
 	}
 
 	return nil
 }
+
+// bootID returns the identifier of the current boot of the machine.
+func bootID(p SSHCommander) (string, error) {
+	out, err := p.SSHCommand("cat /proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+func rebootedSince(p SSHCommander, previousBootID string) func() bool {
+	return func() bool {
+		currentBootID, err := bootID(p)
+		if err != nil {
+			log.Debugf("Waiting for the machine to come back: %s", err)
+			return false
+		}
+
+		return currentBootID != "" && currentBootID != previousBootID
+	}
+}
+
+// rebootMachine reboots the machine and waits until it's back.
+func rebootMachine(p SSHCommander) error {
+	previousBootID, err := bootID(p)
+	if err != nil {
+		return err
+	}
+
+	// ignore errors here because the SSH connection will close
+	p.SSHCommand("sudo systemctl reboot")
+
+	if err := mcnutils.WaitFor(rebootedSince(p, previousBootID)); err != nil {
+		return fmt.Errorf("Error waiting for the machine to reboot: %s", err)
+	}
+
+	return nil
+}
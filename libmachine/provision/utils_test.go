@@ -195,6 +195,56 @@ func TestDecideStorageDriver(t *testing.T) {
 	}
 }
 
+func TestDecideStorageDriverOverlay2(t *testing.T) {
+	p := &fakeProvisioner{GenericProvisioner{
+		Driver: &fakedriver.Driver{},
+	}}
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"stat -f -c %T /var/lib":                        "ext4\n",
+			fmt.Sprintf(kernelFilesystemCommand, "overlay"): "yes\n",
+		},
+	}
+
+	storageDriver, err := decideStorageDriver(p, "aufs", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "overlay2", storageDriver)
+}
+
+func TestValidateEngineOptions(t *testing.T) {
+	p := &fakeProvisioner{GenericProvisioner{
+		Driver: &fakedriver.Driver{},
+		EngineOptions: engine.Options{
+			ArbitraryFlags: []string{"graph=/data/docker"},
+			StorageDriver:  "overlay2",
+		},
+	}}
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"dockerd --version": "Docker version 24.0.7, build 311b9ff\n",
+			"uname -r":          "3.10.0-1160.el7.x86_64\n",
+			fmt.Sprintf(kernelFilesystemCommand, "overlay"): "no\n",
+		},
+	}
+
+	assert.EqualError(t, validateEngineOptions(p), `Error: the engine can't start with its options (Docker 24.0.7, kernel 3.10.0-1160.el7.x86_64):
+  --engine-opt graph was removed in Docker 23.0, use --engine-opt data-root=<dir> instead
+  the kernel doesn't support the overlay filesystem of the overlay2 storage driver, choose another one with --engine-storage-driver`)
+}
+
+func TestValidateEngineOptionsUnknownTarget(t *testing.T) {
+	p := &fakeProvisioner{GenericProvisioner{
+		Driver: &fakedriver.Driver{},
+		EngineOptions: engine.Options{
+			ArbitraryFlags: []string{"graph=/data/docker"},
+		},
+	}}
+	p.SSHCommander = &provisiontest.FakeSSHCommander{}
+
+	assert.NoError(t, validateEngineOptions(p))
+}
+
 func TestGetFilesystemType(t *testing.T) {
 	p := &fakeProvisioner{GenericProvisioner{
 		Driver: &fakedriver.Driver{},
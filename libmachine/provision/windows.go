@@ -0,0 +1,412 @@
+package provision
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/winrm"
+)
+
+const (
+	// DefaultWindowsInstallURL installs the Mirantis Container Runtime,
+	// formerly Docker EE, on Windows Server.
+	DefaultWindowsInstallURL = "https://get.mirantis.com/install.ps1"
+
+	windowsDockerDir        = `C:\ProgramData\docker`
+	windowsDockerConfigDir  = windowsDockerDir + `\config`
+	windowsDockerCertsDir   = windowsDockerDir + `\certs.d`
+	windowsDaemonConfigFile = windowsDockerConfigDir + `\daemon.json`
+	windowsFirewallRule     = "Docker Machine"
+)
+
+// WindowsProvisioner provisions Windows Server hosts, which are managed over
+// WinRM instead of SSH.  It isn't registered for the detection: it's picked
+// when the machine is created with WinRM options.
+type WindowsProvisioner struct {
+	SSHCommander
+	Driver        drivers.Driver
+	AuthOptions   auth.Options
+	EngineOptions engine.Options
+	SwarmOptions  swarm.Options
+	OsReleaseInfo *OsRelease
+}
+
+// NewWindowsProvisioner returns the provisioner of a Windows host once its
+// WinRM service answers.
+func NewWindowsProvisioner(d drivers.Driver, options *winrm.Options) (Provisioner, error) {
+	provisioner := &WindowsProvisioner{
+		SSHCommander: &winrm.Commander{Driver: d, Options: options},
+		Driver:       d,
+	}
+
+	log.Info("Waiting for WinRM to be available...")
+	if err := provisioner.waitForWinRM(); err != nil {
+		return nil, err
+	}
+
+	return provisioner, nil
+}
+
+func (provisioner *WindowsProvisioner) String() string {
+	return "windows"
+}
+
+func (provisioner *WindowsProvisioner) waitForWinRM() error {
+	if err := mcnutils.WaitForSpecific(func() bool {
+		_, err := provisioner.SSHCommand("exit 0")
+		if err != nil {
+			log.Debugf("Error waiting for WinRM: %s", err)
+		}
+		return err == nil
+	}, 120, 5*time.Second); err != nil {
+		return fmt.Errorf("Too many retries waiting for WinRM to be available: %s", err)
+	}
+
+	return nil
+}
+
+func (provisioner *WindowsProvisioner) Hostname() (string, error) {
+	output, err := provisioner.SSHCommand("$env:COMPUTERNAME")
+	return strings.TrimSpace(output), err
+}
+
+// SetHostname renames the computer, which takes effect on the next restart.
+func (provisioner *WindowsProvisioner) SetHostname(hostname string) error {
+	_, err := provisioner.SSHCommand(fmt.Sprintf("Rename-Computer -NewName %s -Force -WarningAction SilentlyContinue", psQuote(hostname)))
+	return err
+}
+
+func (provisioner *WindowsProvisioner) GetDockerOptionsDir() string {
+	return windowsDockerConfigDir
+}
+
+func (provisioner *WindowsProvisioner) CompatibleWithHost() bool {
+	return false
+}
+
+func (provisioner *WindowsProvisioner) GetAuthOptions() auth.Options {
+	return provisioner.AuthOptions
+}
+
+func (provisioner *WindowsProvisioner) GetSwarmOptions() swarm.Options {
+	return provisioner.SwarmOptions
+}
+
+func (provisioner *WindowsProvisioner) GetEngineOptions() engine.Options {
+	return provisioner.EngineOptions
+}
+
+func (provisioner *WindowsProvisioner) GetDriver() drivers.Driver {
+	return provisioner.Driver
+}
+
+func (provisioner *WindowsProvisioner) SetOsReleaseInfo(info *OsRelease) {
+	provisioner.OsReleaseInfo = info
+}
+
+func (provisioner *WindowsProvisioner) GetOsReleaseInfo() (*OsRelease, error) {
+	if provisioner.OsReleaseInfo == nil {
+		version, err := provisioner.SSHCommand("(Get-CimInstance Win32_OperatingSystem).Caption")
+		if err != nil {
+			return nil, err
+		}
+
+		provisioner.OsReleaseInfo = &OsRelease{
+			ID:         "windows",
+			Name:       strings.TrimSpace(version),
+			PrettyName: strings.TrimSpace(version),
+		}
+	}
+
+	return provisioner.OsReleaseInfo, nil
+}
+
+// Package installs the engine with its install script, and the other
+// packages as Windows features.
+func (provisioner *WindowsProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if name == "docker" {
+		if action == pkgaction.Remove {
+			return fmt.Errorf("removing the engine isn't supported on Windows")
+		}
+		return provisioner.installDocker(action == pkgaction.Upgrade, "")
+	}
+
+	var command string
+	switch action {
+	case pkgaction.Install, pkgaction.Upgrade:
+		command = "Install-WindowsFeature -Name %s | Out-Null"
+	case pkgaction.Remove:
+		command = "Uninstall-WindowsFeature -Name %s | Out-Null"
+	}
+
+	_, err := provisioner.SSHCommand(fmt.Sprintf(command, psQuote(name)))
+	return err
+}
+
+func (provisioner *WindowsProvisioner) Service(name string, action serviceaction.ServiceAction) error {
+	var command string
+	switch action {
+	case serviceaction.Start:
+		command = "Start-Service -Name %s"
+	case serviceaction.Stop:
+		command = "Stop-Service -Name %s -Force"
+	case serviceaction.Restart:
+		command = "Restart-Service -Name %s -Force"
+	case serviceaction.Enable:
+		command = "Set-Service -Name %s -StartupType Automatic"
+	case serviceaction.Disable:
+		command = "Set-Service -Name %s -StartupType Disabled"
+	case serviceaction.DaemonReload:
+		return nil
+	}
+
+	_, err := provisioner.SSHCommand(fmt.Sprintf(command, psQuote(name)))
+	return err
+}
+
+// installURL returns the URL of the install script: the default one of the
+// Linux hosts can't install the engine on Windows.
+func (provisioner *WindowsProvisioner) installURL() string {
+	installURL := provisioner.EngineOptions.InstallURL
+	if installURL == "" || installURL == drivers.DefaultEngineInstallURL {
+		return DefaultWindowsInstallURL
+	}
+
+	return installURL
+}
+
+func (provisioner *WindowsProvisioner) installDocker(upgrade bool, version string) error {
+	args := ""
+	if version != "" {
+		args = " -DockerVersion " + psQuote(version)
+	}
+
+	script := fmt.Sprintf(`$installer = Join-Path $env:TEMP 'install-docker.ps1'
+Invoke-WebRequest -UseBasicParsing -Uri %s -OutFile $installer
+& $installer%s
+Remove-Item $installer`, psQuote(provisioner.installURL()), args)
+
+	if !upgrade {
+		script = "if (-not (Get-Service -Name docker -ErrorAction SilentlyContinue)) {\r\n" + script + "\r\n}"
+	}
+
+	if _, err := provisioner.SSHCommand(script); err != nil {
+		return fmt.Errorf("error installing docker: %s", err)
+	}
+
+	return nil
+}
+
+// restart restarts the host, and waits for its WinRM service to answer.
+func (provisioner *WindowsProvisioner) restart() error {
+	log.Info("Restarting the machine...")
+
+	// The command doesn't return once the machine goes down
+	provisioner.SSHCommand("Restart-Computer -Force")
+
+	// Let the machine go down before waiting for it
+	time.Sleep(30 * time.Second)
+
+	return provisioner.waitForWinRM()
+}
+
+func (provisioner *WindowsProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+
+	restartNeeded := false
+
+	hostname, err := provisioner.Hostname()
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(hostname, provisioner.Driver.GetMachineName()) {
+		log.Debugf("setting hostname %q", provisioner.Driver.GetMachineName())
+		if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+			return err
+		}
+		restartNeeded = true
+	}
+
+	log.Info("Installing the Containers feature...")
+	output, err := provisioner.SSHCommand("(Install-WindowsFeature -Name Containers).RestartNeeded")
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(output) == "Yes" {
+		restartNeeded = true
+	}
+
+	if restartNeeded {
+		if err := provisioner.restart(); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Installing Docker...")
+	if err := provisioner.Package("docker", pkgaction.Install); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = provisioner.remoteAuthOptions()
+
+	return provisioner.configureAuth()
+}
+
+// remoteAuthOptions sets the paths of the certificates on the host.  The
+// certificates are kept apart from the configuration of the daemon.
+func (provisioner *WindowsProvisioner) remoteAuthOptions() auth.Options {
+	authOptions := provisioner.AuthOptions
+
+	authOptions.CaCertRemotePath = windowsDockerCertsDir + `\ca.pem`
+	authOptions.ServerCertRemotePath = windowsDockerCertsDir + `\server.pem`
+	authOptions.ServerKeyRemotePath = windowsDockerCertsDir + `\server-key.pem`
+
+	return authOptions
+}
+
+// configureAuth is the counterpart of ConfigureAuth for Windows hosts: it
+// uploads the certificates, writes the configuration of the daemon and opens
+// its port in the firewall.
+func (provisioner *WindowsProvisioner) configureAuth() error {
+	if err := generateServerCert(provisioner); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker", serviceaction.Stop); err != nil {
+		return err
+	}
+
+	log.Info("Copying certs to the remote machine...")
+
+	authOptions := provisioner.AuthOptions
+	for local, remote := range map[string]string{
+		authOptions.CaCertPath:     authOptions.CaCertRemotePath,
+		authOptions.ServerCertPath: authOptions.ServerCertRemotePath,
+		authOptions.ServerKeyPath:  authOptions.ServerKeyRemotePath,
+	} {
+		content, err := ioutil.ReadFile(local)
+		if err != nil {
+			return err
+		}
+
+		if err := provisioner.writeFile(remote, content); err != nil {
+			return err
+		}
+	}
+
+	dockerPort, err := getDockerPort(provisioner.Driver)
+	if err != nil {
+		return err
+	}
+
+	dkrcfg, err := provisioner.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Setting Docker configuration on the remote daemon...")
+
+	if err := provisioner.writeFile(dkrcfg.EngineOptionsPath, []byte(dkrcfg.EngineOptions)); err != nil {
+		return err
+	}
+
+	if _, err := provisioner.SSHCommand(fmt.Sprintf(`Remove-NetFirewallRule -DisplayName %s -ErrorAction SilentlyContinue
+New-NetFirewallRule -DisplayName %s -Direction Inbound -Protocol TCP -LocalPort %d -Action Allow | Out-Null`,
+		psQuote(windowsFirewallRule), psQuote(windowsFirewallRule), dockerPort)); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker", serviceaction.Start); err != nil {
+		return err
+	}
+
+	return WaitForDocker(provisioner, dockerPort)
+}
+
+// GenerateDockerOptions renders the daemon.json of the engine, which listens
+// on its named pipe for the local clients and on TCP with TLS for Machine.
+func (provisioner *WindowsProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	authOptions := provisioner.AuthOptions
+
+	engineOptions := provisioner.EngineOptions
+	engineOptions.Labels = append(engineOptions.Labels, fmt.Sprintf("provider=%s", provisioner.Driver.DriverName()))
+
+	daemonConfig, err := generateDaemonConfig(engineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// There's no service file to pass flags in: the hosts and the TLS
+	// settings go to the daemon.json too.
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(daemonConfig), &config); err != nil {
+		return nil, err
+	}
+	config["hosts"] = []string{"npipe://", fmt.Sprintf("tcp://0.0.0.0:%d", dockerPort)}
+	config["tlsverify"] = true
+	config["tlscacert"] = authOptions.CaCertRemotePath
+	config["tlscert"] = authOptions.ServerCertRemotePath
+	config["tlskey"] = authOptions.ServerKeyRemotePath
+
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &DockerOptions{
+		EngineOptions:     string(content),
+		EngineOptionsPath: windowsDaemonConfigFile,
+	}, nil
+}
+
+// writeFile writes a file on the host, creating its directory.  The content
+// is passed encoded, and written without the byte order mark PowerShell
+// would add, which the daemon can't parse.
+func (provisioner *WindowsProvisioner) writeFile(path string, content []byte) error {
+	dir := path[:strings.LastIndex(path, `\`)]
+
+	_, err := provisioner.SSHCommand(fmt.Sprintf(`New-Item -ItemType Directory -Force -Path %s | Out-Null
+[IO.File]::WriteAllBytes(%s, [Convert]::FromBase64String('%s'))`,
+		psQuote(dir), psQuote(path), base64.StdEncoding.EncodeToString(content)))
+
+	return err
+}
+
+// checkDaemonUp checks the engine port with PowerShell, as netstat differs on
+// Windows.
+func (provisioner *WindowsProvisioner) checkDaemonUp(dockerPort int) func() bool {
+	return func() bool {
+		output, err := provisioner.SSHCommand(fmt.Sprintf("@(Get-NetTCPConnection -State Listen -LocalPort %d -ErrorAction SilentlyContinue).Count", dockerPort))
+		if err != nil {
+			log.Warnf("Error running WinRM command: %s", err)
+			return false
+		}
+
+		return strings.TrimSpace(output) != "0"
+	}
+}
+
+// psQuote quotes a string for PowerShell.
+func psQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,90 @@
+package provision
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowsGenerateDockerOptions(t *testing.T) {
+	p := &WindowsProvisioner{
+		Driver: &fakedriver.Driver{},
+		EngineOptions: engine.Options{
+			Labels:           []string{"os=windows"},
+			InsecureRegistry: []string{"registry.local:5000"},
+		},
+	}
+	p.AuthOptions = p.remoteAuthOptions()
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+	assert.NoError(t, err)
+	assert.Equal(t, `C:\ProgramData\docker\config\daemon.json`, dockerCfg.EngineOptionsPath)
+
+	var config struct {
+		Hosts              []string
+		TLSVerify          bool   `json:"tlsverify"`
+		TLSCACert          string `json:"tlscacert"`
+		TLSKey             string `json:"tlskey"`
+		Labels             []string
+		InsecureRegistries []string `json:"insecure-registries"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.EngineOptions), &config))
+
+	assert.Equal(t, []string{"npipe://", "tcp://0.0.0.0:2376"}, config.Hosts)
+	assert.True(t, config.TLSVerify)
+	assert.Equal(t, `C:\ProgramData\docker\certs.d\ca.pem`, config.TLSCACert)
+	assert.Equal(t, `C:\ProgramData\docker\certs.d\server-key.pem`, config.TLSKey)
+	assert.Equal(t, []string{"os=windows", "provider=Driver"}, config.Labels)
+	assert.Equal(t, []string{"registry.local:5000"}, config.InsecureRegistries)
+}
+
+func TestWindowsInstallURL(t *testing.T) {
+	p := &WindowsProvisioner{}
+
+	p.EngineOptions.InstallURL = drivers.DefaultEngineInstallURL
+	assert.Equal(t, DefaultWindowsInstallURL, p.installURL())
+
+	p.EngineOptions.InstallURL = "https://example.com/install.ps1"
+	assert.Equal(t, "https://example.com/install.ps1", p.installURL())
+}
+
+func TestWindowsService(t *testing.T) {
+	p := &WindowsProvisioner{
+		SSHCommander: &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"Restart-Service -Name 'docker' -Force":             "",
+				"Set-Service -Name 'docker' -StartupType Automatic": "",
+			},
+		},
+	}
+
+	assert.NoError(t, p.Service("docker", serviceaction.Restart))
+	assert.NoError(t, p.Service("docker", serviceaction.Enable))
+	assert.Error(t, p.Service("docker", serviceaction.Stop))
+}
+
+func TestWindowsCheckDaemonUp(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"@(Get-NetTCPConnection -State Listen -LocalPort 2376 -ErrorAction SilentlyContinue).Count": "1\r\n",
+		},
+	}
+	p := &WindowsProvisioner{SSHCommander: commander}
+
+	assert.True(t, p.checkDaemonUp(2376)())
+	assert.NoError(t, WaitForDocker(p, 2376))
+
+	commander.Responses["@(Get-NetTCPConnection -State Listen -LocalPort 2376 -ErrorAction SilentlyContinue).Count"] = "0\r\n"
+	assert.False(t, p.checkDaemonUp(2376)())
+}
+
+func TestPSQuote(t *testing.T) {
+	assert.Equal(t, `'C:\ProgramData\docker'`, psQuote(`C:\ProgramData\docker`))
+	assert.Equal(t, `'it''s'`, psQuote("it's"))
+}
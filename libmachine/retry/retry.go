@@ -0,0 +1,239 @@
+// Package retry retries the operations failing on transient errors, e.g. the
+// calls to the APIs of the cloud providers and the SSH connections, with an
+// exponential backoff bounded by the time budget of their phase.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// Phase is a phase of the creation of a machine, with its own budget.
+type Phase string
+
+const (
+	// Instance is waiting for the instance of a cloud provider to run.
+	Instance Phase = "instance"
+
+	// SSH is waiting for the SSH server of a machine to accept
+	// connections.
+	SSH Phase = "ssh"
+
+	// Command is running the SSH commands of the provisioners, retried
+	// when the connection fails.
+	Command Phase = "command"
+)
+
+// BudgetEnvVar overrides the budgets of the phases, as phase=duration pairs
+// separated by commas.  It's inherited by the driver plugins.
+const BudgetEnvVar = "MACHINE_RETRY_BUDGET"
+
+// Policy is how an operation is retried: the interval between the attempts
+// is multiplied after each one, up to its maximum, and randomized by the
+// jitter, until the budget is spent.
+type Policy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+
+	// Jitter is the fraction of the interval it's randomized by, between
+	// 0 and 1.
+	Jitter float64
+
+	// Budget is the time the attempts can take, unbounded when zero.
+	Budget time.Duration
+
+	// MaxAttempts bounds the attempts, unbounded when zero.
+	MaxAttempts int
+}
+
+var (
+	defaultPolicies = map[Phase]Policy{
+		Instance: {InitialInterval: 2 * time.Second, MaxInterval: 15 * time.Second, Multiplier: 1.5, Jitter: 0.2, Budget: 5 * time.Minute},
+		SSH:      {InitialInterval: time.Second, MaxInterval: 10 * time.Second, Multiplier: 1.5, Jitter: 0.2, Budget: 3 * time.Minute},
+		Command:  {InitialInterval: time.Second, MaxInterval: 8 * time.Second, Multiplier: 2, Jitter: 0.2, Budget: 30 * time.Second, MaxAttempts: 4},
+	}
+
+	budgetsLock sync.Mutex
+	budgets     map[Phase]time.Duration
+
+	// sleep and now are replaced by the tests
+	sleep = sleepContext
+	now   = time.Now
+)
+
+// For returns the policy of a phase, with the budget it's configured with.
+func For(phase Phase) Policy {
+	budgetsLock.Lock()
+	defer budgetsLock.Unlock()
+
+	if budgets == nil {
+		budgets, _ = ParseBudgets(os.Getenv(BudgetEnvVar))
+	}
+
+	policy := defaultPolicies[phase]
+	if budget, ok := budgets[phase]; ok {
+		policy.Budget = budget
+	}
+
+	return policy
+}
+
+// SetBudgets overrides the budgets of the phases, and exports them to the
+// driver plugins.
+func SetBudgets(value string) error {
+	parsed, err := ParseBudgets(value)
+	if err != nil {
+		return err
+	}
+
+	budgetsLock.Lock()
+	defer budgetsLock.Unlock()
+
+	budgets = parsed
+	return os.Setenv(BudgetEnvVar, value)
+}
+
+// ParseBudgets parses phase=duration pairs separated by commas, e.g.
+// "instance=10m,ssh=5m".
+func ParseBudgets(value string) (map[Phase]time.Duration, error) {
+	parsed := map[Phase]time.Duration{}
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid retry budget %q, expected phase=duration", pair)
+		}
+
+		phase := Phase(parts[0])
+		if _, ok := defaultPolicies[phase]; !ok {
+			return nil, fmt.Errorf("Unknown retry phase %q, expected one of: [%s, %s, %s]", phase, Instance, SSH, Command)
+		}
+
+		budget, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid retry budget %q: %s", pair, err)
+		}
+
+		parsed[phase] = budget
+	}
+
+	return parsed, nil
+}
+
+// WithBudget returns the policy with another budget.
+func (p Policy) WithBudget(budget time.Duration) Policy {
+	p.Budget = budget
+	return p
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Permanent marks an error as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// Do calls f until it succeeds, it fails with a permanent error, or the
+// budget of the policy is spent.  The error is then the last one returned.
+func (p Policy) Do(f func() error) error {
+	return p.DoContext(context.Background(), f)
+}
+
+// DoContext is Do, giving up with ctx.Err() when ctx is done.
+func (p Policy) DoContext(ctx context.Context, f func() error) error {
+	return p.UntilContext(ctx, func() (bool, error) {
+		if err := f(); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+// Until calls f until it's done, it fails with a permanent error, or the
+// budget of the policy is spent.  The errors which aren't permanent are
+// retried as f not being done yet.
+func (p Policy) Until(f func() (bool, error)) error {
+	return p.UntilContext(context.Background(), f)
+}
+
+// UntilContext is Until, giving up with ctx.Err() when ctx is done.
+func (p Policy) UntilContext(ctx context.Context, f func() (bool, error)) error {
+	start := now()
+	interval := p.InitialInterval
+
+	for attempt := 1; ; attempt++ {
+		done, err := f()
+		if err == nil && done {
+			return nil
+		}
+		if permanent, ok := err.(*permanentError); ok {
+			return permanent.err
+		}
+
+		elapsed := now().Sub(start)
+		wait := p.jitter(interval)
+		if (p.MaxAttempts > 0 && attempt >= p.MaxAttempts) || (p.Budget > 0 && elapsed+wait > p.Budget) {
+			if err != nil {
+				return fmt.Errorf("Giving up after %d attempts in %s: %s", attempt, elapsed.Round(time.Second), err)
+			}
+			return fmt.Errorf("Giving up after %d attempts in %s", attempt, elapsed.Round(time.Second))
+		}
+
+		if err != nil {
+			log.Debugf("Attempt %d failed, retrying in %s: %s", attempt, wait, err)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+
+		interval = time.Duration(float64(interval) * p.Multiplier)
+		if p.MaxInterval > 0 && interval > p.MaxInterval {
+			interval = p.MaxInterval
+		}
+	}
+}
+
+// sleepContext waits for a duration, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jitter randomizes an interval by the jitter of the policy.
+func (p Policy) jitter(interval time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return interval
+	}
+
+	delta := p.Jitter * float64(interval)
+	return time.Duration(float64(interval) - delta + rand.Float64()*2*delta)
+}
@@ -0,0 +1,159 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock makes the sleeps advance the clock instead of waiting.
+func fakeClock() (*[]time.Duration, func()) {
+	current := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	slept := []time.Duration{}
+
+	sleep = func(ctx context.Context, d time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		slept = append(slept, d)
+		current = current.Add(d)
+		return nil
+	}
+	now = func() time.Time {
+		return current
+	}
+
+	return &slept, func() {
+		sleep = sleepContext
+		now = time.Now
+	}
+}
+
+func TestDoBacksOffExponentially(t *testing.T) {
+	slept, restore := fakeClock()
+	defer restore()
+
+	policy := Policy{InitialInterval: time.Second, MaxInterval: 4 * time.Second, Multiplier: 2}
+
+	attempts := 0
+	err := policy.Do(func() error {
+		attempts++
+		if attempts < 5 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, attempts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}, *slept)
+}
+
+func TestDoStopsOnPermanentError(t *testing.T) {
+	_, restore := fakeClock()
+	defer restore()
+
+	attempts := 0
+	err := Policy{InitialInterval: time.Second}.Do(func() error {
+		attempts++
+		return Permanent(errors.New("forbidden"))
+	})
+
+	assert.EqualError(t, err, "forbidden")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestDoGivesUpWhenTheBudgetIsSpent(t *testing.T) {
+	_, restore := fakeClock()
+	defer restore()
+
+	policy := Policy{InitialInterval: 10 * time.Second, Multiplier: 1, Budget: 25 * time.Second}
+
+	attempts := 0
+	err := policy.Do(func() error {
+		attempts++
+		return errors.New("transient")
+	})
+
+	assert.EqualError(t, err, "Giving up after 3 attempts in 20s: transient")
+	assert.Equal(t, 3, attempts)
+}
+
+func TestUntilGivesUpAfterMaxAttempts(t *testing.T) {
+	_, restore := fakeClock()
+	defer restore()
+
+	attempts := 0
+	err := Policy{InitialInterval: time.Second, MaxAttempts: 2}.Until(func() (bool, error) {
+		attempts++
+		return false, nil
+	})
+
+	assert.EqualError(t, err, "Giving up after 2 attempts in 1s")
+	assert.Equal(t, 2, attempts)
+}
+
+func TestUntilContextStopsWhenCanceled(t *testing.T) {
+	_, restore := fakeClock()
+	defer restore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	err := Policy{InitialInterval: time.Second}.UntilContext(ctx, func() (bool, error) {
+		attempts++
+		cancel()
+		return false, nil
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestSleepContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, sleepContext(ctx, time.Hour))
+	assert.NoError(t, sleepContext(context.Background(), time.Millisecond))
+}
+
+func TestJitter(t *testing.T) {
+	policy := Policy{Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		wait := policy.jitter(10 * time.Second)
+		assert.True(t, wait >= 5*time.Second && wait <= 15*time.Second, wait.String())
+	}
+}
+
+func TestParseBudgets(t *testing.T) {
+	budgets, err := ParseBudgets("instance=10m, ssh=90s")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[Phase]time.Duration{Instance: 10 * time.Minute, SSH: 90 * time.Second}, budgets)
+
+	_, err = ParseBudgets("boot=1m")
+	assert.EqualError(t, err, `Unknown retry phase "boot", expected one of: [instance, ssh, command]`)
+
+	_, err = ParseBudgets("ssh")
+	assert.Error(t, err)
+
+	_, err = ParseBudgets("ssh=soon")
+	assert.Error(t, err)
+}
+
+func TestSetBudgets(t *testing.T) {
+	defer os.Unsetenv(BudgetEnvVar)
+	defer func() { budgets = nil }()
+
+	assert.NoError(t, SetBudgets("ssh=1m"))
+
+	assert.Equal(t, time.Minute, For(SSH).Budget)
+	assert.Equal(t, 5*time.Minute, For(Instance).Budget)
+	assert.Equal(t, "ssh=1m", os.Getenv(BudgetEnvVar))
+}
@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/signer/v4"
+)
+
+const secretsManagerAPIVersion = "2017-10-17"
+
+// secretsManagerEndpoint replaces the endpoint of Secrets Manager, for the
+// tests.
+var secretsManagerEndpoint = ""
+
+var errNoAWSRegion = errors.New("the region of the secret is unknown, give its ARN or set AWS_REGION")
+
+// secretsManagerRegion returns the region of an ARN, e.g.
+// arn:aws:secretsmanager:eu-west-1:123456789012:secret:registry, or the one
+// of the AWS environment.
+func secretsManagerRegion(secretID string) string {
+	if parts := strings.Split(secretID, ":"); len(parts) > 3 && parts[0] == "arn" {
+		return parts[3]
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// newSecretsManager returns a client of the JSON API of Secrets Manager with
+// the credentials of the AWS environment variables or shared credentials
+// file.
+func newSecretsManager(region string) *client.Client {
+	config := aws.NewConfig().WithRegion(region)
+	if secretsManagerEndpoint != "" {
+		config = config.WithEndpoint(secretsManagerEndpoint)
+	}
+
+	clientConfig := session.New(config).ClientConfig("secretsmanager")
+	svc := client.New(
+		*clientConfig.Config,
+		metadata.ClientInfo{
+			ServiceName:   "secretsmanager",
+			SigningRegion: clientConfig.SigningRegion,
+			Endpoint:      clientConfig.Endpoint,
+			APIVersion:    secretsManagerAPIVersion,
+			JSONVersion:   "1.1",
+			TargetPrefix:  "secretsmanager",
+		},
+		clientConfig.Handlers,
+	)
+
+	svc.Handlers.Sign.PushBack(v4.Sign)
+	svc.Handlers.Build.PushBack(buildSecretsManager)
+	svc.Handlers.Unmarshal.PushBack(unmarshalSecretsManager)
+	svc.Handlers.UnmarshalError.PushBack(unmarshalSecretsManagerError)
+
+	return svc
+}
+
+func buildSecretsManager(r *request.Request) {
+	body, err := json.Marshal(r.Params)
+	if err != nil {
+		r.Error = awserr.New("SerializationError", "failed encoding the Secrets Manager request", err)
+		return
+	}
+
+	r.HTTPRequest.Header.Set("X-Amz-Target", r.ClientInfo.TargetPrefix+"."+r.Operation.Name)
+	r.HTTPRequest.Header.Set("Content-Type", "application/x-amz-json-"+r.ClientInfo.JSONVersion)
+	r.SetBufferBody(body)
+}
+
+func unmarshalSecretsManager(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+
+	if r.Data == nil {
+		return
+	}
+
+	if err := json.NewDecoder(r.HTTPResponse.Body).Decode(r.Data); err != nil {
+		r.Error = awserr.New("SerializationError", "failed decoding the Secrets Manager response", err)
+	}
+}
+
+func unmarshalSecretsManagerError(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+
+	body, _ := ioutil.ReadAll(r.HTTPResponse.Body)
+
+	var resp struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&resp); err != nil || resp.Type == "" {
+		resp.Type = r.HTTPResponse.Status
+		resp.Message = strings.TrimSpace(string(body))
+	}
+	if resp.Message == "" {
+		resp.Message = resp.MessageUpper
+	}
+
+	// the type may be prefixed with the namespace of the error
+	if i := strings.LastIndex(resp.Type, "#"); i >= 0 {
+		resp.Type = resp.Type[i+1:]
+	}
+
+	r.Error = awserr.NewRequestFailure(awserr.New(resp.Type, resp.Message, nil), r.HTTPResponse.StatusCode, r.RequestID)
+}
+
+type getSecretValueInput struct {
+	SecretID string `json:"SecretId"`
+}
+
+type getSecretValueOutput struct {
+	SecretString string `json:"SecretString"`
+	SecretBinary []byte `json:"SecretBinary"`
+}
+
+// readAWSSecretsManager reads the current version of a secret, or a key of
+// a secret stored as a JSON object.
+func readAWSSecretsManager(ref *Reference) (string, error) {
+	region := secretsManagerRegion(ref.Path)
+	if region == "" {
+		return "", errNoAWSRegion
+	}
+
+	output := &getSecretValueOutput{}
+	req := newSecretsManager(region).NewRequest(&request.Operation{
+		Name:       "GetSecretValue",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}, &getSecretValueInput{SecretID: ref.Path}, output)
+	if err := req.Send(); err != nil {
+		return "", err
+	}
+
+	secret := output.SecretString
+	if secret == "" {
+		secret = string(output.SecretBinary)
+	}
+
+	if ref.Field == "" {
+		return secret, nil
+	}
+
+	data := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(secret), &data); err != nil {
+		return "", errors.New("the secret isn't a JSON object, remove the key after #")
+	}
+	return field(data, ref.Field)
+}
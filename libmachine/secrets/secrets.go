@@ -0,0 +1,158 @@
+// Package secrets resolves the references to secrets given in place of the
+// values of the engine environment, e.g. --engine-env
+// REGISTRY_PASSWORD=vault://secret/data/registry#password, so that the
+// secrets aren't stored in the configuration of the machine.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// SchemeFile reads the secret from a local file, e.g.
+	// file:///home/me/.registry-password.
+	SchemeFile = "file"
+
+	// SchemeVault reads a field of a secret of HashiCorp Vault, e.g.
+	// vault://secret/data/registry#password, with the address and token of
+	// VAULT_ADDR and VAULT_TOKEN.
+	SchemeVault = "vault"
+
+	// SchemeAWSSecretsManager reads a secret of AWS Secrets Manager, or a
+	// key of a JSON secret, e.g. aws-sm://registry#password, with the
+	// credentials of the AWS environment.
+	SchemeAWSSecretsManager = "aws-sm"
+)
+
+var schemes = []string{SchemeFile, SchemeVault, SchemeAWSSecretsManager}
+
+// Reference is a parsed reference to a secret.
+type Reference struct {
+	Scheme string
+
+	// Path is the file, the path of the Vault secret relative to /v1 or the
+	// name or ARN of the AWS secret.
+	Path string
+
+	// Field is the field of the secret after #, empty for the whole secret.
+	Field string
+}
+
+// IsReference returns true if value references a secret.
+func IsReference(value string) bool {
+	for _, scheme := range schemes {
+		if strings.HasPrefix(value, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses a reference to a secret.
+func Parse(value string) (*Reference, error) {
+	i := strings.Index(value, "://")
+	if i < 0 || !IsReference(value) {
+		return nil, fmt.Errorf("%q isn't a reference to a secret, use file://, vault:// or aws-sm://", value)
+	}
+
+	ref := &Reference{Scheme: value[:i], Path: value[i+3:]}
+	if j := strings.LastIndex(ref.Path, "#"); j >= 0 && ref.Scheme != SchemeFile {
+		ref.Path, ref.Field = ref.Path[:j], ref.Path[j+1:]
+	}
+
+	if ref.Path == "" {
+		return nil, fmt.Errorf("The reference to a secret %q has no path", value)
+	}
+
+	return ref, nil
+}
+
+func (r *Reference) String() string {
+	if r.Field == "" {
+		return fmt.Sprintf("%s://%s", r.Scheme, r.Path)
+	}
+	return fmt.Sprintf("%s://%s#%s", r.Scheme, r.Path, r.Field)
+}
+
+// Resolve returns the secret referenced by value.
+func Resolve(value string) (string, error) {
+	ref, err := Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	var secret string
+	switch ref.Scheme {
+	case SchemeFile:
+		secret, err = readFile(ref)
+	case SchemeVault:
+		secret, err = readVault(ref)
+	case SchemeAWSSecretsManager:
+		secret, err = readAWSSecretsManager(ref)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Error resolving the secret %s: %s", ref, err)
+	}
+
+	return secret, nil
+}
+
+// ResolveEnv returns the environment variables whose values reference
+// secrets, e.g. KEY=vault://..., with the secrets in place of the
+// references.
+func ResolveEnv(env []string) ([]string, error) {
+	resolved := []string{}
+	for _, e := range env {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid environment variable %q, expected KEY=VALUE", e)
+		}
+
+		secret, err := Resolve(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, parts[0]+"="+secret)
+	}
+	return resolved, nil
+}
+
+// readFile returns the content of the file, without its final line break.
+func readFile(ref *Reference) (string, error) {
+	content, err := ioutil.ReadFile(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// field returns a field of a JSON object, a string or the JSON of any other
+// value.  The field may be omitted if the object has a single one.
+func field(data map[string]interface{}, name string) (string, error) {
+	if name == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("the secret has %d fields, give one after #", len(data))
+		}
+		for key := range data {
+			name = key
+		}
+	}
+
+	value, ok := data[name]
+	if !ok {
+		return "", fmt.Errorf("the secret has no field %q", name)
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,171 @@
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setEnv(t *testing.T, env map[string]string) func() {
+	saved := map[string]string{}
+	for key, value := range env {
+		saved[key] = os.Getenv(key)
+		os.Setenv(key, value)
+	}
+	return func() {
+		for key, value := range saved {
+			os.Setenv(key, value)
+		}
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	assert.True(t, IsReference("file:///run/secret"))
+	assert.True(t, IsReference("vault://secret/data/registry#password"))
+	assert.True(t, IsReference("aws-sm://registry"))
+	assert.False(t, IsReference("http://proxy:3128"))
+	assert.False(t, IsReference("plain"))
+}
+
+func TestParse(t *testing.T) {
+	ref, err := Parse("vault://secret/data/registry#password")
+	assert.NoError(t, err)
+	assert.Equal(t, &Reference{Scheme: SchemeVault, Path: "secret/data/registry", Field: "password"}, ref)
+
+	ref, err = Parse("file:///home/me/#secret")
+	assert.NoError(t, err)
+	assert.Equal(t, &Reference{Scheme: SchemeFile, Path: "/home/me/#secret"}, ref)
+
+	_, err = Parse("aws-sm://#password")
+	assert.EqualError(t, err, `The reference to a secret "aws-sm://#password" has no path`)
+
+	_, err = Parse("https://example.com")
+	assert.Error(t, err)
+}
+
+func TestResolveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-secrets-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("s3cret\n"), 0600))
+
+	secret, err := Resolve("file://" + path)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", secret)
+
+	_, err = Resolve("file://" + filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestResolveVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/registry":
+			w.Write([]byte(`{"data":{"data":{"user":"me","password":"s3cret"},"metadata":{"version":2}}}`))
+		case "/v1/kv/token":
+			w.Write([]byte(`{"data":{"value":"t0ken"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	defer setEnv(t, map[string]string{"VAULT_ADDR": server.URL, "VAULT_TOKEN": "token", "VAULT_CACERT": ""})()
+
+	secret, err := Resolve("vault://secret/data/registry#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", secret)
+
+	secret, err = Resolve("vault://kv/token")
+	assert.NoError(t, err)
+	assert.Equal(t, "t0ken", secret)
+
+	_, err = Resolve("vault://secret/data/registry")
+	assert.EqualError(t, err, "Error resolving the secret vault://secret/data/registry: the secret has 2 fields, give one after #")
+
+	_, err = Resolve("vault://secret/data/missing#password")
+	assert.EqualError(t, err, "Error resolving the secret vault://secret/data/missing#password: Vault returned 404")
+
+	os.Setenv("VAULT_TOKEN", "other")
+	_, err = Resolve("vault://kv/token")
+	assert.EqualError(t, err, "Error resolving the secret vault://kv/token: Vault returned 403: permission denied")
+}
+
+func TestResolveAWSSecretsManager(t *testing.T) {
+	targets := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets = append(targets, r.Header.Get("X-Amz-Target"))
+
+		input := &getSecretValueInput{}
+		json.NewDecoder(r.Body).Decode(input)
+
+		switch input.SecretID {
+		case "registry":
+			w.Write([]byte(`{"Name":"registry","SecretString":"{\"user\":\"me\",\"password\":\"s3cret\"}"}`))
+		case "arn:aws:secretsmanager:eu-west-1:123456789012:secret:token":
+			w.Write([]byte(`{"Name":"token","SecretString":"t0ken"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+		}
+	}))
+	defer server.Close()
+
+	defer setEnv(t, map[string]string{"AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "SECRET", "AWS_REGION": "us-east-1"})()
+
+	savedEndpoint := secretsManagerEndpoint
+	secretsManagerEndpoint = server.URL
+	defer func() { secretsManagerEndpoint = savedEndpoint }()
+
+	secret, err := Resolve("aws-sm://registry#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", secret)
+
+	secret, err = Resolve("aws-sm://arn:aws:secretsmanager:eu-west-1:123456789012:secret:token")
+	assert.NoError(t, err)
+	assert.Equal(t, "t0ken", secret)
+
+	_, err = Resolve("aws-sm://missing")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ResourceNotFoundException")
+
+	assert.Equal(t, "secretsmanager.GetSecretValue", targets[0])
+}
+
+func TestSecretsManagerRegion(t *testing.T) {
+	defer setEnv(t, map[string]string{"AWS_REGION": "", "AWS_DEFAULT_REGION": "us-west-2"})()
+
+	assert.Equal(t, "eu-west-1", secretsManagerRegion("arn:aws:secretsmanager:eu-west-1:123456789012:secret:token"))
+	assert.Equal(t, "us-west-2", secretsManagerRegion("registry"))
+}
+
+func TestResolveEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-secrets-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("s3cret"), 0600))
+
+	env, err := ResolveEnv([]string{"PASSWORD=file://" + path})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PASSWORD=s3cret"}, env)
+
+	_, err = ResolveEnv([]string{"PASSWORD"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	errNoVaultAddr  = errors.New("VAULT_ADDR isn't set")
+	errNoVaultToken = errors.New("VAULT_TOKEN isn't set")
+)
+
+func newVaultHTTPClient(caCertPath string) (*http.Client, error) {
+	client := &http.Client{}
+	if caCertPath == "" {
+		return client, nil
+	}
+
+	caCert, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the CA certificate of Vault: %s", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("Error reading the CA certificate of Vault: no certificate found in %s", caCertPath)
+	}
+
+	client.Transport = &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: certPool},
+	}
+
+	return client, nil
+}
+
+// readVault reads a field of a secret of the KV secrets engine, whose
+// version 2 nests the fields in data.data, e.g. secret/data/registry, and
+// version 1 in data, e.g. kv/registry.
+func readVault(ref *Reference) (string, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", errNoVaultAddr
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		return "", errNoVaultToken
+	}
+
+	client, err := newVaultHTTPClient(os.Getenv("VAULT_CACERT"))
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/%s", addr, strings.TrimLeft(ref.Path, "/")), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode >= 300 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(body, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return "", fmt.Errorf("Vault returned %d: %s", resp.StatusCode, strings.Join(vaultErr.Errors, ", "))
+		}
+		return "", fmt.Errorf("Vault returned %d", resp.StatusCode)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", err
+	}
+
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	return field(data, ref.Field)
+}
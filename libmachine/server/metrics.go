@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// The metrics of the machines are served in the text format of Prometheus,
+// for the fleets to be alerted on from the existing monitoring:
+//
+//	docker_machine_state{machine,driver,state}        1 for the state of the machine, 0 for the others
+//	docker_machine_cert_expiry_days{machine,cert}     days until the ca, server and client certificates expire
+//	docker_machine_engine_info{machine,version}       1 for the version of the engine, when it's known
+//	docker_machine_last_provision_timestamp_seconds   when the machine was last provisioned
+//	docker_machine_scrape_error{machine}              1 if the machine couldn't be queried
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// machineStates are the states docker_machine_state has a series for.
+var machineStates = []state.State{
+	state.Running,
+	state.Paused,
+	state.Saved,
+	state.Stopped,
+	state.Stopping,
+	state.Starting,
+	state.Error,
+	state.Timeout,
+}
+
+// MachineMetrics is what the metrics of a machine are made of.
+type MachineMetrics struct {
+	Name       string
+	DriverName string
+	State      state.State
+
+	// EngineVersion is the version of the engine, e.g. 24.0.7, empty if
+	// the machine isn't running or the version couldn't be queried.
+	EngineVersion string
+
+	// CertsExpiry is when the certificates of the machine expire, by
+	// ca, server and client.
+	CertsExpiry map[string]time.Time
+
+	// LastProvisioned is zero if the machine was never provisioned since
+	// it's recorded.
+	LastProvisioned time.Time
+
+	// Error is set if the machine couldn't be loaded or queried.
+	Error string
+}
+
+// MetricsHandler is the http.Handler of the metrics, which queries the
+// machines on every scrape.
+type MetricsHandler struct {
+	// Collect returns the metrics of the machines.
+	Collect func() ([]MachineMetrics, error)
+
+	now func() time.Time
+}
+
+// NewMetricsHandler returns a handler serving the metrics collected by
+// collect on /metrics.
+func NewMetricsHandler(collect func() ([]MachineMetrics, error)) *MetricsHandler {
+	return &MetricsHandler{
+		Collect: collect,
+		now:     time.Now,
+	}
+}
+
+func (m *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, errNotAllowed.Error(), http.StatusMethodNotAllowed)
+		return
+	}
+
+	machines, err := m.Collect()
+	if err != nil {
+		log.Warnf("Error collecting the metrics: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(m.render(machines))
+}
+
+// render writes the metrics of the machines, sorted by name.
+func (m *MetricsHandler) render(machines []MachineMetrics) []byte {
+	sort.Slice(machines, func(i, j int) bool { return machines[i].Name < machines[j].Name })
+	now := m.now()
+
+	var buf bytes.Buffer
+
+	writeHeader(&buf, "docker_machine_state", "State of the machine, 1 for its current state.")
+	for _, machine := range machines {
+		if machine.Error != "" && machine.State == state.None {
+			continue
+		}
+		for _, st := range machineStates {
+			value := 0.0
+			if machine.State == st {
+				value = 1
+			}
+			writeSample(&buf, "docker_machine_state", value, "machine", machine.Name, "driver", machine.DriverName, "state", st.String())
+		}
+	}
+
+	writeHeader(&buf, "docker_machine_cert_expiry_days", "Days until the certificate of the machine expires.")
+	for _, machine := range machines {
+		for _, name := range []string{"ca", "server", "client"} {
+			if expiry, ok := machine.CertsExpiry[name]; ok {
+				writeSample(&buf, "docker_machine_cert_expiry_days", expiry.Sub(now).Hours()/24, "machine", machine.Name, "cert", name)
+			}
+		}
+	}
+
+	writeHeader(&buf, "docker_machine_engine_info", "Version of the engine of the machine.")
+	for _, machine := range machines {
+		if machine.EngineVersion != "" {
+			writeSample(&buf, "docker_machine_engine_info", 1, "machine", machine.Name, "version", machine.EngineVersion)
+		}
+	}
+
+	writeHeader(&buf, "docker_machine_last_provision_timestamp_seconds", "When the machine was last provisioned, in seconds since the epoch.")
+	for _, machine := range machines {
+		if !machine.LastProvisioned.IsZero() {
+			writeSample(&buf, "docker_machine_last_provision_timestamp_seconds", float64(machine.LastProvisioned.Unix()), "machine", machine.Name)
+		}
+	}
+
+	writeHeader(&buf, "docker_machine_scrape_error", "1 if the machine couldn't be queried.")
+	for _, machine := range machines {
+		value := 0.0
+		if machine.Error != "" {
+			value = 1
+		}
+		writeSample(&buf, "docker_machine_scrape_error", value, "machine", machine.Name)
+	}
+
+	return buf.Bytes()
+}
+
+func writeHeader(buf *bytes.Buffer, name, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// labelValueReplacer escapes the values of the labels as the text format
+// expects.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeSample writes a sample with its labels, given as name and value
+// pairs.
+func writeSample(buf *bytes.Buffer, name string, value float64, labels ...string) {
+	pairs := []string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelValueReplacer.Replace(labels[i+1])))
+	}
+
+	fmt.Fprintf(buf, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatValue(value))
+}
+
+// formatValue formats a value with up to 3 decimals.
+func formatValue(value float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.3f", value), "0"), ".")
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMetricsHandler(machines []MachineMetrics, err error) *MetricsHandler {
+	m := NewMetricsHandler(func() ([]MachineMetrics, error) {
+		return machines, err
+	})
+	m.now = func() time.Time {
+		return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return m
+}
+
+func scrape(m *MetricsHandler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestMetrics(t *testing.T) {
+	m := newTestMetricsHandler([]MachineMetrics{
+		{
+			Name:       "web",
+			DriverName: "amazonec2",
+			State:      state.Stopped,
+		},
+		{
+			Name:          "dev",
+			DriverName:    "virtualbox",
+			State:         state.Running,
+			EngineVersion: "24.0.7",
+			CertsExpiry: map[string]time.Time{
+				"server": time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+				"ca":     time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+			},
+			LastProvisioned: time.Unix(1700000000, 0),
+		},
+		{
+			Name:       `bad"name`,
+			DriverName: "not found",
+			State:      state.Error,
+			Error:      "Host does not exist",
+		},
+	}, nil)
+
+	w := scrape(m, "GET", "/metrics")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "# HELP docker_machine_state State of the machine, 1 for its current state.\n# TYPE docker_machine_state gauge\n")
+	assert.Contains(t, body, `docker_machine_state{machine="dev",driver="virtualbox",state="Running"} 1`+"\n")
+	assert.Contains(t, body, `docker_machine_state{machine="dev",driver="virtualbox",state="Stopped"} 0`+"\n")
+	assert.Contains(t, body, `docker_machine_state{machine="web",driver="amazonec2",state="Stopped"} 1`+"\n")
+	assert.Contains(t, body, `docker_machine_state{machine="bad\"name",driver="not found",state="Error"} 1`+"\n")
+	assert.Contains(t, body, `docker_machine_cert_expiry_days{machine="dev",cert="ca"} -1`+"\n")
+	assert.Contains(t, body, `docker_machine_cert_expiry_days{machine="dev",cert="server"} 30.5`+"\n")
+	assert.Contains(t, body, `docker_machine_engine_info{machine="dev",version="24.0.7"} 1`+"\n")
+	assert.Contains(t, body, `docker_machine_last_provision_timestamp_seconds{machine="dev"} 1700000000`+"\n")
+	assert.Contains(t, body, `docker_machine_scrape_error{machine="bad\"name"} 1`+"\n")
+	assert.Contains(t, body, `docker_machine_scrape_error{machine="web"} 0`+"\n")
+	assert.NotContains(t, body, `engine_info{machine="web"`)
+	assert.NotContains(t, body, `last_provision_timestamp_seconds{machine="web"`)
+
+	// The machines are sorted by name
+	assert.True(t, strings.Index(body, `{machine="bad\"name"`) < strings.Index(body, `{machine="dev"`))
+}
+
+func TestMetricsNotFound(t *testing.T) {
+	m := newTestMetricsHandler(nil, nil)
+
+	assert.Equal(t, http.StatusNotFound, scrape(m, "GET", "/machines").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, scrape(m, "POST", "/metrics").Code)
+}
+
+func TestMetricsCollectError(t *testing.T) {
+	m := newTestMetricsHandler(nil, errors.New("store unavailable"))
+
+	w := scrape(m, "GET", "/metrics")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "store unavailable")
+}
@@ -0,0 +1,541 @@
+// Package server serves the machines of a libmachine API over HTTP and JSON,
+// so that web UIs and programs in other languages can manage them without
+// running the CLI.
+//
+// Every request is authenticated by the token of the server, given as
+// "Authorization: Bearer <token>".  The routes are:
+//
+//	GET    /machines              list the machines and their state
+//	POST   /machines              create a machine
+//	GET    /machines/<name>       inspect a machine
+//	DELETE /machines/<name>       remove a machine
+//	GET    /machines/<name>/status
+//	GET    /machines/<name>/env   the environment of the Docker client
+//	POST   /machines/<name>/ssh   run a command on a machine
+//	POST   /machines/<name>/start, /stop, /restart
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+var (
+	errUnauthorized   = errors.New("Missing or invalid token")
+	errNotFound       = errors.New("Not found")
+	errNotAllowed     = errors.New("Method not allowed")
+	errNoMachineName  = errors.New("The name of the machine is required")
+	errNoDriverName   = errors.New("The name of the driver is required")
+	errNoSSHCommand   = errors.New("The command to run is required")
+	errInvalidRequest = errors.New("Invalid JSON request")
+)
+
+// Machine is a machine as listed by GET /machines.
+type Machine struct {
+	Name       string
+	DriverName string
+	State      string
+	URL        string `json:",omitempty"`
+	Error      string `json:",omitempty"`
+}
+
+// CreateRequest is the machine to create with POST /machines.
+type CreateRequest struct {
+	Name       string
+	DriverName string
+
+	// DriverOptions are the create flags of the driver without their
+	// dashes, e.g. "amazonec2-region", the others keep their default.
+	DriverOptions map[string]interface{}
+
+	Labels map[string]string
+}
+
+// Env is the environment of the Docker client returned by GET
+// /machines/<name>/env.
+type Env struct {
+	DockerHost        string `json:"DOCKER_HOST"`
+	DockerCertPath    string `json:"DOCKER_CERT_PATH"`
+	DockerTLSVerify   string `json:"DOCKER_TLS_VERIFY"`
+	DockerMachineName string `json:"DOCKER_MACHINE_NAME"`
+}
+
+// ExecRequest is the command to run with POST /machines/<name>/ssh.
+type ExecRequest struct {
+	Command string
+}
+
+// ExecResponse is the output of the command, and why it failed if it did.
+type ExecResponse struct {
+	Output string
+	Error  string `json:",omitempty"`
+}
+
+// Error is how the errors are returned, along with the status code.
+type Error struct {
+	Code    string
+	Message string
+}
+
+// Server is the http.Handler of the API.
+type Server struct {
+	// NewAPI returns the API a request is served with, which is closed
+	// once the request is served.
+	NewAPI func() libmachine.API
+
+	// Token authenticates the requests.
+	Token string
+}
+
+// NewServer returns a server authenticating the requests with token.
+func NewServer(newAPI func() libmachine.API, token string) *Server {
+	return &Server{
+		NewAPI: newAPI,
+		Token:  token,
+	}
+}
+
+// NewToken returns a random token.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "machines" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	api := s.NewAPI()
+	defer api.Close()
+
+	log.Debugf("%s %s", r.Method, r.URL.Path)
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.list(w, api)
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.create(w, r, api)
+	case len(parts) == 1:
+		writeError(w, http.StatusMethodNotAllowed, errNotAllowed)
+	default:
+		s.serveMachine(w, r, api, parts[1], parts[2:])
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+
+	header := r.Header.Get("Authorization")
+	if s.Token == "" || !strings.HasPrefix(header, prefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(header[len(prefix):]), []byte(s.Token)) == 1
+}
+
+func (s *Server) serveMachine(w http.ResponseWriter, r *http.Request, api libmachine.API, name string, action []string) {
+	route := r.Method
+	if len(action) > 0 {
+		route += " " + action[0]
+	}
+
+	handlers := map[string]func(http.ResponseWriter, *http.Request, libmachine.API, *host.Host){
+		"GET":          inspect,
+		"DELETE":       remove,
+		"GET status":   status,
+		"GET env":      env,
+		"POST ssh":     exec,
+		"POST start":   hostAction((*host.Host).Start),
+		"POST stop":    hostAction((*host.Host).Stop),
+		"POST restart": hostAction((*host.Host).Restart),
+	}
+
+	handler, ok := handlers[route]
+	if !ok {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	h, err := api.Load(name)
+	if err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+
+	handler(w, r, api, h)
+}
+
+func (s *Server) list(w http.ResponseWriter, api libmachine.API) {
+	names, err := api.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	machines := []Machine{}
+	for _, name := range names {
+		h, err := api.Load(name)
+		if err != nil {
+			machines = append(machines, Machine{Name: name, Error: err.Error()})
+			continue
+		}
+		machines = append(machines, newMachine(h))
+	}
+
+	writeJSON(w, http.StatusOK, machines)
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, api libmachine.API) {
+	var req CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidRequest)
+		return
+	}
+
+	switch {
+	case req.Name == "":
+		writeError(w, http.StatusBadRequest, errNoMachineName)
+		return
+	case req.DriverName == "":
+		writeError(w, http.StatusBadRequest, errNoDriverName)
+		return
+	case !host.ValidateHostName(req.Name):
+		writeError(w, http.StatusBadRequest, mcnerror.ErrInvalidHostname)
+		return
+	}
+
+	exists, err := api.Exists(req.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if exists {
+		writeError(w, http.StatusConflict, mcnerror.ErrHostAlreadyExists{Name: req.Name})
+		return
+	}
+
+	h, err := newHost(api, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The creation is canceled, and what was created removed, if the
+	// client goes away.
+	if err := api.CreateContext(r.Context(), h); err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newMachine(h))
+}
+
+// newHost configures a new machine like create does, with the default values
+// of the create flags which aren't driver options.
+func newHost(api libmachine.API, req CreateRequest) (*host.Host, error) {
+	machinesDir := api.GetMachinesDir()
+
+	storeID := ""
+	if store, ok := api.(persist.IdentifiedStore); ok {
+		id, err := store.StoreID()
+		if err != nil {
+			return nil, err
+		}
+		storeID = id
+	}
+
+	rawDriver, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: req.Name,
+		StorePath:   filepath.Dir(machinesDir),
+		StoreID:     storeID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := api.NewHost(req.DriverName, rawDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	authOptions := h.HostOptions.AuthOptions
+	authOptions.ServerCertPath = filepath.Join(machinesDir, req.Name, "server.pem")
+	authOptions.ServerKeyPath = filepath.Join(machinesDir, req.Name, "server-key.pem")
+	authOptions.StorePath = filepath.Join(machinesDir, req.Name)
+	h.HostOptions.EngineOptions.StorageDriver = ""
+	h.Labels = req.Labels
+
+	opts, err := driverOptions(h.Driver.GetCreateFlags(), req.DriverOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.Driver.SetConfigFromFlags(opts); err != nil {
+		return nil, fmt.Errorf("Error setting machine configuration from the driver options: %s", err)
+	}
+
+	return h, nil
+}
+
+// driverOptions returns the default values of the create flags of a driver,
+// overridden by the options of the request converted from their JSON type.
+func driverOptions(flags []mcnflag.Flag, options map[string]interface{}) (rpcdriver.RPCFlags, error) {
+	opts := rpcdriver.RPCFlags{
+		Values: map[string]interface{}{},
+	}
+
+	byName := map[string]mcnflag.Flag{}
+	for _, f := range flags {
+		byName[f.String()] = f
+		opts.Values[f.String()] = f.Default()
+		if f.Default() == nil {
+			opts.Values[f.String()] = false
+		}
+	}
+
+	for name, value := range options {
+		f, ok := byName[name]
+		if !ok {
+			return opts, fmt.Errorf("Unknown driver option %q", name)
+		}
+
+		converted, ok := convertOption(f, value)
+		if !ok {
+			return opts, fmt.Errorf("Invalid value %v for the driver option %q", value, name)
+		}
+		opts.Values[name] = converted
+	}
+
+	return opts, nil
+}
+
+func convertOption(f mcnflag.Flag, value interface{}) (interface{}, bool) {
+	switch f.(type) {
+	case mcnflag.IntFlag, *mcnflag.IntFlag:
+		n, ok := value.(float64)
+		if !ok || n != float64(int(n)) {
+			return nil, false
+		}
+		return int(n), true
+	case mcnflag.BoolFlag, *mcnflag.BoolFlag:
+		b, ok := value.(bool)
+		return b, ok
+	case mcnflag.StringSliceFlag, *mcnflag.StringSliceFlag:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, false
+		}
+		values := []string{}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, s)
+		}
+		return values, true
+	default:
+		s, ok := value.(string)
+		return s, ok
+	}
+}
+
+func inspect(w http.ResponseWriter, r *http.Request, api libmachine.API, h *host.Host) {
+	writeJSON(w, http.StatusOK, h)
+}
+
+// remove removes a machine like rm: its pre-remove hooks are run, its DNS
+// record unregistered and it's removed from its groups.
+func remove(w http.ResponseWriter, r *http.Request, api libmachine.API, h *host.Host) {
+	if err := h.Remove(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := api.Remove(h.Name); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if store, ok := api.(persist.GroupStore); ok {
+		groups, err := store.LoadGroups()
+		if err == nil && groups.RemoveMachine(h.Name) {
+			err = store.SaveGroups(groups)
+		}
+		if err != nil {
+			log.Warnf("Error updating the groups of machines: %s", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func status(w http.ResponseWriter, r *http.Request, api libmachine.API, h *host.Host) {
+	s, err := h.Driver.GetState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct{ State string }{s.String()})
+}
+
+func env(w http.ResponseWriter, r *http.Request, api libmachine.API, h *host.Host) {
+	dockerHost, _, err := check.DefaultConnChecker.Check(h, false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("Error checking TLS connection: %s", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Env{
+		DockerHost:        dockerHost,
+		DockerCertPath:    filepath.Join(api.GetMachinesDir(), h.Name),
+		DockerTLSVerify:   "1",
+		DockerMachineName: h.Name,
+	})
+}
+
+func exec(w http.ResponseWriter, r *http.Request, api libmachine.API, h *host.Host) {
+	var req ExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidRequest)
+		return
+	}
+	if req.Command == "" {
+		writeError(w, http.StatusBadRequest, errNoSSHCommand)
+		return
+	}
+
+	output, err := h.RunSSHCommand(req.Command)
+
+	resp := ExecResponse{Output: output}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func hostAction(action func(*host.Host) error) func(http.ResponseWriter, *http.Request, libmachine.API, *host.Host) {
+	return func(w http.ResponseWriter, r *http.Request, api libmachine.API, h *host.Host) {
+		if err := action(h); err != nil {
+			writeError(w, statusCode(err), err)
+			return
+		}
+
+		// Starting a machine may change its IP address
+		if err := api.Save(h); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newMachine(h))
+	}
+}
+
+func newMachine(h *host.Host) Machine {
+	machine := Machine{
+		Name:       h.Name,
+		DriverName: h.DriverName,
+	}
+
+	s, err := h.Driver.GetState()
+	if err != nil {
+		machine.Error = err.Error()
+		return machine
+	}
+	machine.State = s.String()
+
+	if url, err := h.URL(); err == nil {
+		machine.URL = url
+	}
+
+	return machine
+}
+
+func statusCode(err error) int {
+	switch err.(type) {
+	case mcnerror.ErrHostDoesNotExist:
+		return http.StatusNotFound
+	case mcnerror.ErrHostAlreadyExists:
+		return http.StatusConflict
+	case mcnerror.ErrHostAlreadyInState:
+		return http.StatusConflict
+	case mcnerror.ErrDuringPreCreate:
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+func errorCode(err error) string {
+	switch err.(type) {
+	case mcnerror.ErrHostDoesNotExist:
+		return "HostDoesNotExist"
+	case mcnerror.ErrHostAlreadyExists:
+		return "HostAlreadyExists"
+	case mcnerror.ErrHostAlreadyInState:
+		return "HostAlreadyInState"
+	case mcnerror.ErrDuringPreCreate:
+		return "PreCreateCheckFailed"
+	}
+
+	switch err {
+	case errUnauthorized:
+		return "Unauthorized"
+	case errNotFound:
+		return "NotFound"
+	case errNotAllowed:
+		return "MethodNotAllowed"
+	case mcnerror.ErrInvalidHostname:
+		return "InvalidHostname"
+	case errNoMachineName, errNoDriverName, errNoSSHCommand, errInvalidRequest:
+		return "InvalidArguments"
+	}
+
+	return "Error"
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, struct{ Error Error }{Error{
+		Code:    errorCode(err),
+		Message: err.Error(),
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debugf("Error writing the response: %s", err)
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/hooks"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer() (*libmachinetest.FakeAPI, *Server) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "running",
+				DriverName: "fakedriver",
+				Driver:     &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"},
+			},
+			{
+				Name:       "stopped",
+				DriverName: "fakedriver",
+				Driver:     &fakedriver.Driver{MockState: state.Stopped},
+			},
+		},
+	}
+
+	return api, NewServer(func() libmachine.API { return api }, "secret")
+}
+
+func serve(s *Server, method, path, body, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestUnauthorized(t *testing.T) {
+	_, s := newTestServer()
+
+	for _, token := range []string{"", "wrong"} {
+		w := serve(s, "GET", "/machines", "", token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"Code":"Unauthorized"`)
+	}
+
+	s.Token = ""
+	assert.Equal(t, http.StatusUnauthorized, serve(s, "GET", "/machines", "", "").Code)
+}
+
+func TestList(t *testing.T) {
+	_, s := newTestServer()
+
+	w := serve(s, "GET", "/machines", "", "secret")
+
+	var machines []Machine
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &machines))
+	assert.Equal(t, []Machine{
+		{Name: "running", DriverName: "fakedriver", State: "Running", URL: "tcp://10.0.0.1:2376"},
+		{Name: "stopped", DriverName: "fakedriver", State: "Stopped"},
+	}, machines)
+}
+
+func TestStatus(t *testing.T) {
+	_, s := newTestServer()
+
+	w := serve(s, "GET", "/machines/stopped/status", "", "secret")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"State":"Stopped"}`, w.Body.String())
+}
+
+func TestMachineNotFound(t *testing.T) {
+	_, s := newTestServer()
+
+	w := serve(s, "GET", "/machines/unknown/status", "", "secret")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"Code":"HostDoesNotExist"`)
+
+	assert.Equal(t, http.StatusNotFound, serve(s, "GET", "/machines/running/logs", "", "secret").Code)
+	assert.Equal(t, http.StatusNotFound, serve(s, "GET", "/volumes", "", "secret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(s, "PUT", "/machines", "", "secret").Code)
+}
+
+func TestStop(t *testing.T) {
+	api, s := newTestServer()
+
+	w := serve(s, "POST", "/machines/running/stop", "", "secret")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, state.Stopped, libmachinetest.State(api, "running"))
+}
+
+func TestRemove(t *testing.T) {
+	api, s := newTestServer()
+
+	w := serve(s, "DELETE", "/machines/stopped", "", "secret")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.False(t, libmachinetest.Exists(api, "stopped"))
+}
+
+func TestRemoveUpdatesGroups(t *testing.T) {
+	api, s := newTestServer()
+	api.Groups = persist.Groups{"ci": {"running", "stopped"}}
+
+	w := serve(s, "DELETE", "/machines/stopped", "", "secret")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, persist.Groups{"ci": {"running"}}, api.Groups)
+}
+
+func TestRemoveFailingHook(t *testing.T) {
+	hooks.SetDefault(&hooks.Hooks{Hooks: []hooks.Hook{{Events: []hooks.EventType{hooks.PreRemove}, Command: "false"}}})
+	defer hooks.SetDefault(&hooks.Hooks{})
+
+	api, s := newTestServer()
+
+	w := serve(s, "DELETE", "/machines/stopped", "", "secret")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Error running the pre-remove hooks")
+	assert.True(t, libmachinetest.Exists(api, "stopped"))
+}
+
+func TestCreateInvalid(t *testing.T) {
+	_, s := newTestServer()
+
+	tests := []struct {
+		body string
+		code int
+		msg  string
+	}{
+		{`{`, http.StatusBadRequest, "Invalid JSON request"},
+		{`{"DriverName": "virtualbox"}`, http.StatusBadRequest, "The name of the machine is required"},
+		{`{"Name": "dev"}`, http.StatusBadRequest, "The name of the driver is required"},
+		{`{"Name": "dev/1", "DriverName": "virtualbox"}`, http.StatusBadRequest, "Invalid hostname specified"},
+		{`{"Name": "running", "DriverName": "virtualbox"}`, http.StatusConflict, `"Code":"HostAlreadyExists"`},
+	}
+
+	for _, test := range tests {
+		w := serve(s, "POST", "/machines", test.body, "secret")
+
+		assert.Equal(t, test.code, w.Code, test.body)
+		assert.Contains(t, w.Body.String(), test.msg)
+	}
+}
+
+func TestDriverOptions(t *testing.T) {
+	flags := []mcnflag.Flag{
+		mcnflag.StringFlag{Name: "do-region", Value: "nyc3"},
+		mcnflag.IntFlag{Name: "do-size", Value: 1},
+		mcnflag.BoolFlag{Name: "do-backups"},
+		mcnflag.StringSliceFlag{Name: "do-tags"},
+	}
+
+	var options map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"do-size": 4, "do-backups": true, "do-tags": ["a", "b"]}`), &options))
+
+	opts, err := driverOptions(flags, options)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "nyc3", opts.Values["do-region"])
+	assert.Equal(t, 4, opts.Values["do-size"])
+	assert.Equal(t, true, opts.Values["do-backups"])
+	assert.Equal(t, []string{"a", "b"}, opts.Values["do-tags"])
+
+	_, err = driverOptions(flags, map[string]interface{}{"do-image": "ubuntu"})
+	assert.EqualError(t, err, `Unknown driver option "do-image"`)
+
+	_, err = driverOptions(flags, map[string]interface{}{"do-size": 1.5})
+	assert.EqualError(t, err, `Invalid value 1.5 for the driver option "do-size"`)
+}
@@ -0,0 +1,72 @@
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// certificateRenewer renews the certificate of a key before a client uses
+// it, nil unless a certificate authority signs the keys.
+var certificateRenewer func(keyPath, principal string) error
+
+// SetCertificateRenewer sets the function renewing the certificates of the
+// keys the clients authenticate with.  Only the keys which already have a
+// certificate, i.e. of the machines trusting the certificate authority, are
+// renewed.
+func SetCertificateRenewer(renewer func(keyPath, principal string) error) {
+	certificateRenewer = renewer
+}
+
+// CertificatePath returns the path of the certificate of a private key,
+// where OpenSSH looks it up.
+func CertificatePath(keyPath string) string {
+	return keyPath + "-cert.pub"
+}
+
+func hasCertificate(keyPath string) bool {
+	_, err := os.Stat(CertificatePath(keyPath))
+	return err == nil
+}
+
+// renewCertificates renews the certificates of the keys of auth which have
+// one.  The keys are still tried alone if it fails.
+func renewCertificates(user string, auth *Auth) {
+	if certificateRenewer == nil {
+		return
+	}
+
+	for _, keyPath := range auth.Keys {
+		if keyPath == "" || !hasCertificate(keyPath) {
+			continue
+		}
+
+		if err := certificateRenewer(keyPath, user); err != nil {
+			log.Warnf("Error renewing the SSH certificate of %s: %s", keyPath, err)
+		}
+	}
+}
+
+// certificateSigner returns the signer authenticating with the certificate
+// of a private key.
+func certificateSigner(keyPath string, privateKey ssh.Signer) (ssh.Signer, error) {
+	content, err := ioutil.ReadFile(CertificatePath(keyPath))
+	if err != nil {
+		return nil, err
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an SSH certificate", CertificatePath(keyPath))
+	}
+
+	return ssh.NewCertSigner(certificate, privateKey)
+}
@@ -0,0 +1,117 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// writeCertificate signs the public key of keyPath with a throwaway CA and
+// writes the certificate next to the key.
+func writeCertificate(t *testing.T, keyPath string) {
+	content, err := ioutil.ReadFile(keyPath + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate := &ssh.Certificate{
+		Key:             key,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"docker"},
+		ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := certificate.SignCert(rand.Reader, caSigner); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(CertificatePath(keyPath), ssh.MarshalAuthorizedKey(certificate), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenewCertificates(t *testing.T) {
+	defer SetCertificateRenewer(nil)
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	withCert := filepath.Join(tmpDir, "id_rsa")
+	withoutCert := filepath.Join(tmpDir, "other_rsa")
+	assert.NoError(t, GenerateSSHKey(withCert))
+	assert.NoError(t, GenerateSSHKey(withoutCert))
+	writeCertificate(t, withCert)
+
+	renewed := []string{}
+	SetCertificateRenewer(func(keyPath, principal string) error {
+		renewed = append(renewed, keyPath+"@"+principal)
+		return nil
+	})
+
+	renewCertificates("docker", &Auth{Keys: []string{withCert, withoutCert}})
+	assert.Equal(t, []string{withCert + "@docker"}, renewed)
+}
+
+func TestNativeConfigWithCertificate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keyPath := filepath.Join(tmpDir, "id_rsa")
+	assert.NoError(t, GenerateSSHKey(keyPath))
+	writeCertificate(t, keyPath)
+
+	privateKey, err := ioutil.ReadFile(keyPath)
+	assert.NoError(t, err)
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	assert.NoError(t, err)
+
+	certSigner, err := certificateSigner(keyPath, signer)
+	assert.NoError(t, err)
+	assert.Equal(t, ssh.CertAlgoRSAv01, certSigner.PublicKey().Type())
+
+	config, err := NewNativeConfig("docker", &Auth{Keys: []string{keyPath}})
+	assert.NoError(t, err)
+	assert.Len(t, config.Auth, 1)
+}
+
+func TestExternalClientWithCertificate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keyPath := filepath.Join(tmpDir, "id_rsa")
+	assert.NoError(t, GenerateSSHKey(keyPath))
+	writeCertificate(t, keyPath)
+
+	client, err := NewExternalClient("ssh", "docker", "localhost", 22, &Auth{Keys: []string{keyPath}})
+	assert.NoError(t, err)
+	assert.Contains(t, client.BaseArgs, "CertificateFile="+CertificatePath(keyPath))
+}
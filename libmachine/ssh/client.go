@@ -4,20 +4,27 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/machine/libmachine/log"
-	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/retry"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 type Client interface {
 	Output(command string) (string, error)
+
+	// OutputWithInput runs a command fed with input on its standard input,
+	// e.g. to upload a file, and returns its combined output.
+	OutputWithInput(command string, input io.Reader) (string, error)
+
 	Shell(args ...string) error
 
 	// Start starts the specified command without waiting for it to finish. You
@@ -34,15 +41,17 @@ type Client interface {
 }
 
 type ExternalClient struct {
-	BaseArgs   []string
-	BinaryPath string
-	cmd        *exec.Cmd
+	BaseArgs    []string
+	BinaryPath  string
+	ControlPath string
+	cmd         *exec.Cmd
 }
 
 type NativeClient struct {
 	Config      ssh.ClientConfig
 	Hostname    string
 	Port        int
+	ProxyJump   *ProxyJump
 	openSession *ssh.Session
 }
 
@@ -71,8 +80,6 @@ var (
 		"-o", "LogLevel=quiet", // suppress "Warning: Permanently added '[localhost]:2022' (ECDSA) to the list of known hosts."
 		"-o", "ConnectionAttempts=3", // retry 3 times if SSH connection fails
 		"-o", "ConnectTimeout=10", // timeout after 10 seconds
-		"-o", "ControlMaster=no", // disable ssh multiplexing
-		"-o", "ControlPath=none",
 	}
 	defaultClientType = External
 )
@@ -90,27 +97,48 @@ func SetDefaultClient(clientType ClientType) {
 }
 
 func NewClient(user string, host string, port int, auth *Auth) (Client, error) {
+	return NewProxyJumpClient(user, host, port, auth, nil)
+}
+
+// NewProxyJumpClient returns a client connecting to the host through a
+// bastion, unless jump is nil.
+func NewProxyJumpClient(user string, host string, port int, auth *Auth, jump *ProxyJump) (Client, error) {
+	renewCertificates(user, auth)
+
 	sshBinaryPath, err := exec.LookPath("ssh")
 	if err != nil {
 		log.Debug("SSH binary not found, using native Go implementation")
-		client, err := NewNativeClient(user, host, port, auth)
+		client, err := newNativeProxyJumpClient(user, host, port, auth, jump)
 		log.Debug(client)
 		return client, err
 	}
 
 	if defaultClientType == Native {
 		log.Debug("Using SSH client type: native")
-		client, err := NewNativeClient(user, host, port, auth)
+		client, err := newNativeProxyJumpClient(user, host, port, auth, jump)
 		log.Debug(client)
 		return client, err
 	}
 
 	log.Debug("Using SSH client type: external")
 	client, err := NewExternalClient(sshBinaryPath, user, host, port, auth)
+	if err == nil && jump != nil {
+		client.BaseArgs = append(client.BaseArgs, "-J", jump.String())
+	}
 	log.Debug(client)
 	return client, err
 }
 
+func newNativeProxyJumpClient(user, host string, port int, auth *Auth, jump *ProxyJump) (Client, error) {
+	client, err := NewNativeClient(user, host, port, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	client.(*NativeClient).ProxyJump = jump
+	return client, nil
+}
+
 func NewNativeClient(user, host string, port int, auth *Auth) (Client, error) {
 	config, err := NewNativeConfig(user, auth)
 	if err != nil {
@@ -127,6 +155,7 @@ func NewNativeClient(user, host string, port int, auth *Auth) (Client, error) {
 func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 	var (
 		authMethods []ssh.AuthMethod
+		signers     []ssh.Signer
 	)
 
 	for _, k := range auth.Keys {
@@ -140,7 +169,20 @@ func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 			return ssh.ClientConfig{}, err
 		}
 
-		authMethods = append(authMethods, ssh.PublicKeys(privateKey))
+		if hasCertificate(k) {
+			certSigner, err := certificateSigner(k, privateKey)
+			if err != nil {
+				return ssh.ClientConfig{}, err
+			}
+			signers = append(signers, certSigner)
+		}
+
+		signers = append(signers, privateKey)
+	}
+
+	// The client tries a single method of each type, with all its keys.
+	if len(signers) > 0 {
+		authMethods = append(authMethods, ssh.PublicKeys(signers...))
 	}
 
 	for _, p := range auth.Passwords {
@@ -153,31 +195,78 @@ func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 	}, nil
 }
 
-func (client *NativeClient) dialSuccess() bool {
-	if _, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", client.Hostname, client.Port), &client.Config); err != nil {
-		log.Debugf("Error dialing TCP: %s", err)
-		return false
+func (client *NativeClient) address() string {
+	return net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port))
+}
+
+// dialOnce connects to the host, through the bastion if there's one.
+func (client *NativeClient) dialOnce() (*ssh.Client, error) {
+	if client.ProxyJump != nil {
+		return dialThrough(client.ProxyJump, client.address(), &client.Config)
 	}
-	return true
+
+	return ssh.Dial("tcp", client.address(), &client.Config)
 }
 
-func (client *NativeClient) session(command string) (*ssh.Session, error) {
-	if err := mcnutils.WaitFor(client.dialSuccess); err != nil {
+// dial connects to the host, retrying until it's reachable.
+func (client *NativeClient) dial() (*ssh.Client, error) {
+	var conn *ssh.Client
+
+	dialSuccess := func() error {
+		var err error
+		if conn, err = client.dialOnce(); err != nil {
+			log.Debugf("Error dialing TCP: %s", err)
+		}
+		return err
+	}
+
+	if err := retry.For(retry.SSH).Do(dialSuccess); err != nil {
 		return nil, fmt.Errorf("Error attempting SSH client dial: %s", err)
 	}
 
-	conn, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", client.Hostname, client.Port), &client.Config)
+	return conn, nil
+}
+
+// connection returns the pooled connection to the host, or a new one if the
+// connections aren't reused.
+func (client *NativeClient) connection() (*ssh.Client, error) {
+	if !multiplexing {
+		return client.dial()
+	}
+
+	return connections.get(client.Config.User+"@"+client.address(), client.dial)
+}
+
+// session opens a session on the host, the error being a *ConnectionError.
+func (client *NativeClient) session(command string) (*ssh.Session, error) {
+	conn, err := client.connection()
 	if err != nil {
-		return nil, fmt.Errorf("Mysterious error dialing TCP for SSH (we already succeeded at least once) : %s", err)
+		return nil, &ConnectionError{err}
 	}
 
-	return conn.NewSession()
+	session, err := conn.NewSession()
+	if err != nil && multiplexing {
+		// The pooled connection may have been closed since it was last used,
+		// e.g. by a reboot of the machine.
+		log.Debugf("Error opening an SSH session, reconnecting: %s", err)
+		connections.remove(client.Config.User+"@"+client.address(), conn)
+
+		if conn, err = client.connection(); err != nil {
+			return nil, &ConnectionError{err}
+		}
+		session, err = conn.NewSession()
+	}
+	if err != nil {
+		return nil, &ConnectionError{err}
+	}
+
+	return session, nil
 }
 
 func (client *NativeClient) Output(command string) (string, error) {
 	session, err := client.session(command)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	output, err := session.CombinedOutput(command)
@@ -186,10 +275,23 @@ func (client *NativeClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+func (client *NativeClient) OutputWithInput(command string, input io.Reader) (string, error) {
+	session, err := client.session(command)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	session.Stdin = input
+	output, err := session.CombinedOutput(command)
+
+	return string(output), err
+}
+
 func (client *NativeClient) OutputWithPty(command string) (string, error) {
 	session, err := client.session(command)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	fd := int(os.Stdin.Fd())
@@ -208,7 +310,8 @@ func (client *NativeClient) OutputWithPty(command string) (string, error) {
 	// request tty -- fixes error with hosts that use
 	// "Defaults requiretty" in /etc/sudoers - I'm looking at you RedHat
 	if err := session.RequestPty("xterm", termHeight, termWidth, modes); err != nil {
-		return "", err
+		session.Close()
+		return "", &ConnectionError{err}
 	}
 
 	output, err := session.CombinedOutput(command)
@@ -246,15 +349,26 @@ func (client *NativeClient) Wait() error {
 	return err
 }
 
+// Shell runs an interactive shell, or a command, on the host.  The leading
+// OpenSSH options of args are supported, see parseShellArgs.  The error is an
+// *ssh.ExitError if the command failed on the host.
 func (client *NativeClient) Shell(args ...string) error {
-	var (
-		termWidth, termHeight int
-	)
-	conn, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", client.Hostname, client.Port), &client.Config)
+	opts, args, err := parseShellArgs(args)
 	if err != nil {
 		return err
 	}
 
+	var conn *ssh.Client
+	if opts.proxyCommand != "" {
+		conn, err = dialProxyCommand(opts.proxyCommand, client)
+	} else {
+		conn, err = client.dialOnce()
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
 	session, err := conn.NewSession()
 	if err != nil {
 		return err
@@ -266,44 +380,62 @@ func (client *NativeClient) Shell(args ...string) error {
 	session.Stderr = os.Stderr
 	session.Stdin = os.Stdin
 
-	modes := ssh.TerminalModes{
-		ssh.ECHO: 1,
+	if opts.forwardAgent {
+		if err := forwardAgent(conn, session); err != nil {
+			return err
+		}
+	}
+
+	if opts.forwardX11 {
+		if err := forwardX11(conn, session); err != nil {
+			return err
+		}
 	}
 
 	fd := os.Stdin.Fd()
+	isTerminal := term.IsTerminal(fd)
 
-	if term.IsTerminal(fd) {
-		oldState, err := term.MakeRaw(fd)
-		if err != nil {
-			return err
+	if opts.wantTTY(isTerminal) {
+		termWidth, termHeight := 80, 24
+
+		if isTerminal {
+			oldState, err := term.MakeRaw(fd)
+			if err != nil {
+				return err
+			}
+
+			defer term.RestoreTerminal(fd, oldState)
+
+			if winsize, err := term.GetWinsize(fd); err == nil {
+				termWidth = int(winsize.Width)
+				termHeight = int(winsize.Height)
+			}
+
+			defer watchWindowSize(fd, session)()
 		}
 
-		defer term.RestoreTerminal(fd, oldState)
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm"
+		}
 
-		winsize, err := term.GetWinsize(fd)
-		if err != nil {
-			termWidth = 80
-			termHeight = 24
-		} else {
-			termWidth = int(winsize.Width)
-			termHeight = int(winsize.Height)
+		modes := ssh.TerminalModes{
+			ssh.ECHO: 1,
 		}
-	}
 
-	if err := session.RequestPty("xterm", termHeight, termWidth, modes); err != nil {
-		return err
+		if err := session.RequestPty(termType, termHeight, termWidth, modes); err != nil {
+			return err
+		}
 	}
 
 	if len(args) == 0 {
 		if err := session.Shell(); err != nil {
 			return err
 		}
-		session.Wait()
-	} else {
-		session.Run(strings.Join(args, " "))
+		return session.Wait()
 	}
 
-	return nil
+	return session.Run(strings.Join(args, " "))
 }
 
 func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (*ExternalClient, error) {
@@ -311,7 +443,10 @@ func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (
 		BinaryPath: sshBinaryPath,
 	}
 
-	args := append(baseSSHArgs, fmt.Sprintf("%s@%s", user, host))
+	client.ControlPath = controlPath(user, host, port)
+
+	args := append(baseSSHArgs, multiplexSSHArgs(client.ControlPath)...)
+	args = append(args, fmt.Sprintf("%s@%s", user, host))
 
 	// If no identities are explicitly provided, also look at the identities
 	// offered by ssh-agent
@@ -341,6 +476,9 @@ func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (
 				}
 			}
 			args = append(args, "-i", privateKeyPath)
+			if hasCertificate(privateKeyPath) {
+				args = append(args, "-o", "CertificateFile="+CertificatePath(privateKeyPath))
+			}
 		}
 	}
 
@@ -357,10 +495,22 @@ func getSSHCmd(binaryPath string, args ...string) *exec.Cmd {
 }
 
 func (client *ExternalClient) Output(command string) (string, error) {
+	client.ensureMaster()
+
 	args := append(client.BaseArgs, command)
 	cmd := getSSHCmd(client.BinaryPath, args...)
 	output, err := cmd.CombinedOutput()
-	return string(output), err
+	return string(output), externalError(string(output), err)
+}
+
+func (client *ExternalClient) OutputWithInput(command string, input io.Reader) (string, error) {
+	client.ensureMaster()
+
+	args := append(client.BaseArgs, command)
+	cmd := getSSHCmd(client.BinaryPath, args...)
+	cmd.Stdin = input
+	output, err := cmd.CombinedOutput()
+	return string(output), externalError(string(output), err)
 }
 
 func (client *ExternalClient) Shell(args ...string) error {
@@ -377,6 +527,8 @@ func (client *ExternalClient) Shell(args ...string) error {
 }
 
 func (client *ExternalClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
+	client.ensureMaster()
+
 	args := append(client.BaseArgs, command)
 	cmd := getSSHCmd(client.BinaryPath, args...)
 
@@ -412,3 +564,70 @@ func (client *ExternalClient) Wait() error {
 	client.cmd = nil
 	return err
 }
+
+// ConnectionError is the error of a command which wasn't started because
+// connecting to the host, or opening the session, failed.
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return e.Err.Error()
+}
+
+// connectionFailures prefix the errors the ssh binary reports when it
+// couldn't connect to the host, whereas it reports e.g. "Connection to host
+// closed by remote host." when the connection dropped during the command.
+var connectionFailures = []string{
+	"ssh: ",
+	"kex_exchange_identification: ",
+	"Connection closed by ",
+	"Connection reset by ",
+	"Connection timed out during banner exchange",
+	"Permission denied ",
+}
+
+// externalError returns a *ConnectionError when the ssh binary exited with
+// 255 before starting the command, according to the last line of its output.
+func externalError(output string, err error) error {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 255 {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	for _, prefix := range connectionFailures {
+		if strings.HasPrefix(last, prefix) {
+			return &ConnectionError{err}
+		}
+	}
+
+	return err
+}
+
+// IsConnectionError reports whether a command failed before it was started
+// on the host, because connecting to it or opening the session failed.  Only
+// these failures are worth retrying: once started, the command may have had
+// effects, even if the connection dropped before it exited, e.g. a reboot.
+func IsConnectionError(err error) bool {
+	_, ok := err.(*ConnectionError)
+	return ok
+}
+
+// RetryOutput runs a command until it reaches the host, within the budget of
+// the command phase, and returns its output.  It isn't run again once it was
+// started, whatever its error.
+func RetryOutput(output func() (string, error)) (string, error) {
+	var out string
+	err := retry.For(retry.Command).Do(func() error {
+		var err error
+		out, err = output()
+		if err != nil && !IsConnectionError(err) {
+			return retry.Permanent(err)
+		}
+		return err
+	})
+
+	return out, err
+}
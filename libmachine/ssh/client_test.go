@@ -1,9 +1,11 @@
 package ssh
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"runtime"
 	"testing"
 
@@ -104,3 +106,55 @@ func TestNewExternalClient(t *testing.T) {
 		}
 	}
 }
+
+func TestIsConnectionError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the exit statuses are those of sh")
+	}
+
+	exited := exec.Command("sh", "-c", "exit 255").Run()
+	failed := exec.Command("sh", "-c", "exit 1").Run()
+
+	assert.True(t, IsConnectionError(&ConnectionError{errors.New("dial tcp: connection refused")}))
+	assert.False(t, IsConnectionError(errors.New("EOF")))
+	assert.False(t, IsConnectionError(nil))
+
+	assert.True(t, IsConnectionError(externalError("ssh: connect to host 10.0.0.1 port 22: Connection refused\n", exited)))
+	assert.True(t, IsConnectionError(externalError("Warning: Permanently added '10.0.0.1' (ECDSA) to the list of known hosts.\r\nPermission denied (publickey).\r\n", exited)))
+	assert.False(t, IsConnectionError(externalError("Connection to 10.0.0.1 closed by remote host.\n", exited)))
+	assert.False(t, IsConnectionError(externalError("ssh: connect to host 10.0.0.1 port 22: Connection refused\n", failed)))
+}
+
+func TestRetryOutputRetriesConnectionErrors(t *testing.T) {
+	attempts := 0
+	output, err := RetryOutput(func() (string, error) {
+		attempts++
+		if attempts == 1 {
+			return "", &ConnectionError{errors.New("dial tcp: connection refused")}
+		}
+		return "ok", nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", output)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestRetryOutputDoesNotRunDroppedCommandAgain(t *testing.T) {
+	defer CloseConnections()
+
+	server := newTestServer(t)
+	server.dropping = "sudo reboot"
+	defer server.listener.Close()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port(), &Auth{})
+	assert.NoError(t, err)
+
+	_, err = RetryOutput(func() (string, error) {
+		return client.Output("sudo reboot")
+	})
+
+	assert.Error(t, err)
+	assert.False(t, IsConnectionError(err))
+	assert.Equal(t, []string{"sudo reboot"}, server.ran())
+}
@@ -0,0 +1,187 @@
+package ssh
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// Provisioning runs dozens of commands on a machine, which would each open an
+// SSH connection.  The connections are instead kept open and shared: the
+// native client pools its connections by host, and the external client runs
+// an OpenSSH ControlMaster the commands go through.
+
+const (
+	keepAliveInterval = 30 * time.Second
+	controlPersist    = "60s"
+)
+
+var (
+	multiplexing = true
+
+	connections = &connPool{
+		conns: map[string]*ssh.Client{},
+	}
+)
+
+// SetMultiplexing enables or disables the reuse of the SSH connections.
+func SetMultiplexing(enabled bool) {
+	multiplexing = enabled
+}
+
+// CloseConnections closes the connections kept open by the native client.
+func CloseConnections() {
+	connections.closeAll()
+}
+
+type connPool struct {
+	sync.Mutex
+	conns map[string]*ssh.Client
+}
+
+// get returns the open connection for key, dialing it if needed.
+func (p *connPool) get(key string, dial func() (*ssh.Client, error)) (*ssh.Client, error) {
+	p.Lock()
+	conn, ok := p.conns[key]
+	p.Unlock()
+
+	if ok {
+		return conn, nil
+	}
+
+	// The lock isn't held while dialing, which retries for a while
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if existing, ok := p.conns[key]; ok {
+		conn.Close()
+		return existing, nil
+	}
+
+	p.conns[key] = conn
+	go p.watch(key, conn)
+
+	return conn, nil
+}
+
+// watch removes a connection from the pool once it's closed, or once the
+// server stops answering the keepalives, e.g. after a reboot.
+func (p *connPool) watch(key string, conn *ssh.Client) {
+	closed := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			p.remove(key, conn)
+			return
+		case <-ticker.C:
+			if _, _, err := conn.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				log.Debugf("SSH connection to %s lost: %s", key, err)
+				p.remove(key, conn)
+				return
+			}
+		}
+	}
+}
+
+func (p *connPool) remove(key string, conn *ssh.Client) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.conns[key] == conn {
+		delete(p.conns, key)
+	}
+	conn.Close()
+}
+
+func (p *connPool) closeAll() {
+	p.Lock()
+	defer p.Unlock()
+
+	for key, conn := range p.conns {
+		conn.Close()
+		delete(p.conns, key)
+	}
+}
+
+// controlPath returns the path of the ControlMaster socket of a host, or ""
+// if the external client can't multiplex its connections.  It is kept short
+// since the path of a socket is limited to about a hundred characters.  The
+// sockets are kept in a directory only the user can access, since whoever
+// connects to one runs commands on the machine.
+func controlPath(user, host string, port int) string {
+	if !multiplexing || runtime.GOOS == "windows" {
+		return ""
+	}
+
+	dir, err := controlDir()
+	if err != nil {
+		log.Debugf("Not multiplexing the SSH connections: %s", err)
+		return ""
+	}
+
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s@%s:%d", user, host, port)))
+
+	return filepath.Join(dir, fmt.Sprintf("%x", hash[:8]))
+}
+
+// ensureMaster starts the ControlMaster of the client unless it's running.
+// The master goes to the background once connected, and exits after being
+// idle for a while.  Its output is discarded: it would otherwise keep the
+// output of the command starting it open.
+func (client *ExternalClient) ensureMaster() {
+	if client.ControlPath == "" {
+		return
+	}
+
+	check := getSSHCmd(client.BinaryPath, append(client.BaseArgs, "-O", "check")...)
+	if err := check.Run(); err == nil {
+		return
+	}
+
+	// A master which died leaves its socket behind
+	os.Remove(client.ControlPath)
+
+	args := append([]string{"-o", "ControlMaster=yes", "-o", "ControlPersist=" + controlPersist, "-f", "-N"}, client.BaseArgs...)
+	master := getSSHCmd(client.BinaryPath, args...)
+
+	log.Debug(master)
+
+	// The commands connect directly if the master can't be started
+	if err := master.Run(); err != nil {
+		log.Debugf("Error starting the SSH ControlMaster: %s", err)
+	}
+}
+
+func multiplexSSHArgs(controlPath string) []string {
+	if controlPath == "" {
+		return []string{
+			"-o", "ControlMaster=no", // disable ssh multiplexing
+			"-o", "ControlPath=none",
+		}
+	}
+
+	return []string{
+		"-o", "ControlMaster=no", // the master is started by ensureMaster
+		"-o", "ControlPath=" + controlPath,
+	}
+}
@@ -0,0 +1,316 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an SSH server echoing the commands it runs, which counts its
+// connections and the commands it ran.  It drops the connection instead of
+// answering the dropping command, as a reboot of the machine would.
+type testServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	dropping string
+
+	mu       sync.Mutex
+	conns    []*ssh.ServerConn
+	commands []string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &testServer{
+		listener: listener,
+		config:   config,
+	}
+	go server.serve()
+
+	return server
+}
+
+func (s *testServer) serve() {
+	for {
+		tcpConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		conn, channels, requests, err := ssh.NewServerConn(tcpConn, s.config)
+		if err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		go ssh.DiscardRequests(requests)
+		go s.handleChannels(conn, channels)
+	}
+}
+
+func (s *testServer) handleChannels(conn *ssh.ServerConn, channels <-chan ssh.NewChannel) {
+	for newChannel := range channels {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			go s.handleDirectTCPIP(newChannel)
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+
+				req.Reply(true, nil)
+				command := string(req.Payload[4:])
+
+				s.mu.Lock()
+				s.commands = append(s.commands, command)
+				s.mu.Unlock()
+
+				if command == s.dropping {
+					conn.Close()
+					return
+				}
+
+				channel.Write([]byte(command))
+				channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
+				channel.Close()
+			}
+		}()
+	}
+}
+
+// handleDirectTCPIP connects a forwarded channel to its destination, as sshd
+// does for ssh -L.
+func (s *testServer) handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var destination struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &destination); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(destination.Host, fmt.Sprint(destination.Port)))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	go func() {
+		io.Copy(conn, channel)
+		conn.Close()
+	}()
+	io.Copy(channel, conn)
+	channel.Close()
+}
+
+func (s *testServer) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// dropConnections closes the connections on the server side, as a reboot of
+// the machine would.
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *testServer) ran() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.commands...)
+}
+
+func (s *testServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestNativeClientReusesConnection(t *testing.T) {
+	defer CloseConnections()
+
+	server := newTestServer(t)
+	defer server.listener.Close()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port(), &Auth{})
+	assert.NoError(t, err)
+
+	for _, command := range []string{"hostname", "uname -r", "exit 0"} {
+		output, err := client.Output(command)
+		assert.NoError(t, err)
+		assert.Equal(t, command, output)
+	}
+
+	assert.Equal(t, 1, server.connCount())
+}
+
+func TestNativeClientReconnects(t *testing.T) {
+	defer CloseConnections()
+
+	server := newTestServer(t)
+	defer server.listener.Close()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port(), &Auth{})
+	assert.NoError(t, err)
+
+	_, err = client.Output("hostname")
+	assert.NoError(t, err)
+
+	server.dropConnections()
+
+	output, err := client.Output("hostname")
+	assert.NoError(t, err)
+	assert.Equal(t, "hostname", output)
+	assert.Equal(t, 2, server.connCount())
+}
+
+func TestNativeClientWithoutMultiplexing(t *testing.T) {
+	SetMultiplexing(false)
+	defer SetMultiplexing(true)
+
+	server := newTestServer(t)
+	defer server.listener.Close()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port(), &Auth{})
+	assert.NoError(t, err)
+
+	client.Output("hostname")
+	client.Output("hostname")
+
+	assert.Equal(t, 2, server.connCount())
+}
+
+func TestExternalClientControlPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("OpenSSH for Windows doesn't multiplex its connections")
+	}
+
+	runtimeDir, err := ioutil.TempDir("", "machine-runtime")
+	assert.NoError(t, err)
+	defer os.RemoveAll(runtimeDir)
+
+	defer os.Setenv("XDG_RUNTIME_DIR", os.Getenv("XDG_RUNTIME_DIR"))
+	os.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+	client, err := NewExternalClient("/usr/bin/ssh", "docker", "localhost", 22, &Auth{})
+	assert.NoError(t, err)
+
+	args := strings.Join(client.BaseArgs, " ")
+	assert.Equal(t, filepath.Join(runtimeDir, "docker-machine"), filepath.Dir(client.ControlPath))
+	assert.Contains(t, args, "-o ControlPath="+client.ControlPath)
+
+	info, err := os.Stat(filepath.Join(runtimeDir, "docker-machine"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	other, err := NewExternalClient("/usr/bin/ssh", "docker", "localhost", 2222, &Auth{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, client.ControlPath, other.ControlPath)
+
+	SetMultiplexing(false)
+	defer SetMultiplexing(true)
+
+	client, err = NewExternalClient("/usr/bin/ssh", "docker", "localhost", 22, &Auth{})
+	assert.NoError(t, err)
+	assert.Empty(t, client.ControlPath)
+	assert.Contains(t, strings.Join(client.BaseArgs, " "), "-o ControlPath=none")
+}
+
+func TestExternalClientControlPathSharedDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("OpenSSH for Windows doesn't multiplex its connections")
+	}
+
+	runtimeDir, err := ioutil.TempDir("", "machine-runtime")
+	assert.NoError(t, err)
+	defer os.RemoveAll(runtimeDir)
+
+	defer os.Setenv("XDG_RUNTIME_DIR", os.Getenv("XDG_RUNTIME_DIR"))
+	os.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+	assert.NoError(t, os.Mkdir(filepath.Join(runtimeDir, "docker-machine"), 0755))
+	assert.NoError(t, os.Chmod(filepath.Join(runtimeDir, "docker-machine"), 0755))
+
+	client, err := NewExternalClient("/usr/bin/ssh", "docker", "localhost", 22, &Auth{})
+	assert.NoError(t, err)
+	assert.Empty(t, client.ControlPath)
+	assert.Contains(t, strings.Join(client.BaseArgs, " "), "-o ControlPath=none")
+}
+
+func TestExternalClientReusesConnection(t *testing.T) {
+	sshBinaryPath, err := exec.LookPath("ssh")
+	if err != nil || runtime.GOOS == "windows" {
+		t.Skip("OpenSSH is required")
+	}
+
+	server := newTestServer(t)
+	defer server.listener.Close()
+
+	client, err := NewExternalClient(sshBinaryPath, "docker", "127.0.0.1", server.port(), &Auth{})
+	assert.NoError(t, err)
+	defer exec.Command(sshBinaryPath, append(client.BaseArgs, "-O", "exit")...).Run()
+
+	for _, command := range []string{"hostname", "uname -r"} {
+		output, err := client.Output(command)
+		assert.NoError(t, err)
+		assert.Equal(t, command, output)
+	}
+
+	assert.Equal(t, 1, server.connCount())
+}
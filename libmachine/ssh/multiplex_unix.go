@@ -0,0 +1,41 @@
+//go:build !windows
+// +build !windows
+
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// controlDir returns the private directory of the ControlMaster sockets of
+// the user, docker-machine in $XDG_RUNTIME_DIR, or else machine-<uid> in
+// /tmp, creating it if needed.  A directory created by another user, or which
+// other users can access, is refused.
+func controlDir() (string, error) {
+	dir := filepath.Join("/tmp", fmt.Sprintf("machine-%d", os.Getuid()))
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		dir = filepath.Join(runtimeDir, "docker-machine")
+	}
+
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Getuid() {
+		return "", fmt.Errorf("%s is not a directory of the user", dir)
+	}
+	if info.Mode().Perm() != 0700 {
+		return "", fmt.Errorf("%s can be accessed by other users", dir)
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,9 @@
+package ssh
+
+import "errors"
+
+// controlDir is never used since OpenSSH for Windows doesn't multiplex its
+// connections.
+func controlDir() (string, error) {
+	return "", errors.New("OpenSSH for Windows doesn't multiplex its connections")
+}
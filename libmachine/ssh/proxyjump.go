@@ -0,0 +1,123 @@
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"golang.org/x/crypto/ssh"
+)
+
+// ProxyJump is a bastion host the SSH connections to a machine go through,
+// e.g. to reach a machine on a private subnet.
+type ProxyJump struct {
+	User string
+	Host string
+	Port int
+}
+
+// defaultIdentities are the keys of the user tried on the bastion by the
+// native client, as the external one does.
+var defaultIdentities = []string{"id_rsa", "id_ecdsa", "id_ed25519"}
+
+// ParseProxyJump parses a bastion given as [user@]host[:port], like the
+// ProxyJump option of OpenSSH.  The user defaults to defaultUser.
+func ParseProxyJump(s, defaultUser string) (*ProxyJump, error) {
+	jump := &ProxyJump{
+		User: defaultUser,
+		Port: 22,
+	}
+
+	hostPort := s
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		jump.User = s[:i]
+		hostPort = s[i+1:]
+	}
+
+	jump.Host = hostPort
+	if host, port, err := net.SplitHostPort(hostPort); err == nil {
+		portNum, err := strconv.Atoi(port)
+		if err != nil || portNum <= 0 || portNum > 65535 {
+			return nil, fmt.Errorf("Invalid SSH proxy jump %q: invalid port %q", s, port)
+		}
+		jump.Host = host
+		jump.Port = portNum
+	}
+
+	if jump.Host == "" || jump.User == "" || strings.ContainsAny(jump.Host, "[]") {
+		return nil, fmt.Errorf("Invalid SSH proxy jump %q, expected [user@]host[:port]", s)
+	}
+
+	return jump, nil
+}
+
+func (jump *ProxyJump) address() string {
+	return net.JoinHostPort(jump.Host, strconv.Itoa(jump.Port))
+}
+
+// String returns the bastion in the form of the -J option of OpenSSH.
+func (jump *ProxyJump) String() string {
+	return fmt.Sprintf("%s@%s", jump.User, jump.address())
+}
+
+// proxyJumpConfig returns the configuration of the connection to the
+// bastion: the keys of the machine are tried, then the default keys of the
+// user.
+func proxyJumpConfig(jump *ProxyJump, config ssh.ClientConfig) *ssh.ClientConfig {
+	authMethods := append([]ssh.AuthMethod{}, config.Auth...)
+
+	for _, name := range defaultIdentities {
+		key, err := ioutil.ReadFile(filepath.Join(mcnutils.GetHomeDir(), ".ssh", name))
+		if err != nil {
+			continue
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			log.Debugf("Skipping the SSH key %s for the bastion: %s", name, err)
+			continue
+		}
+
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+
+	return &ssh.ClientConfig{
+		User: jump.User,
+		Auth: authMethods,
+	}
+}
+
+// dialThrough connects to address through the bastion.  The connection to
+// the bastion is closed with the one to the host.
+func dialThrough(jump *ProxyJump, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	bastion, err := ssh.Dial("tcp", jump.address(), proxyJumpConfig(jump, *config))
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to the bastion %s: %s", jump, err)
+	}
+
+	conn, err := bastion.Dial("tcp", address)
+	if err != nil {
+		bastion.Close()
+		return nil, fmt.Errorf("Error connecting to %s through the bastion %s: %s", address, jump, err)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		bastion.Close()
+		return nil, err
+	}
+
+	client := ssh.NewClient(c, chans, reqs)
+	go func() {
+		client.Wait()
+		bastion.Close()
+	}()
+
+	return client, nil
+}
@@ -0,0 +1,53 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProxyJump(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected *ProxyJump
+	}{
+		{"bastion.example.com", &ProxyJump{User: "docker", Host: "bastion.example.com", Port: 22}},
+		{"ubuntu@bastion.example.com", &ProxyJump{User: "ubuntu", Host: "bastion.example.com", Port: 22}},
+		{"ubuntu@10.0.0.1:2222", &ProxyJump{User: "ubuntu", Host: "10.0.0.1", Port: 2222}},
+		{"[fd00::1]:2222", &ProxyJump{User: "docker", Host: "fd00::1", Port: 2222}},
+	}
+
+	for _, test := range tests {
+		jump, err := ParseProxyJump(test.input, "docker")
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, jump)
+	}
+}
+
+func TestParseProxyJumpInvalid(t *testing.T) {
+	for _, input := range []string{"", "ubuntu@", "bastion:ssh", "bastion:70000", "[fd00::1"} {
+		_, err := ParseProxyJump(input, "docker")
+
+		assert.Error(t, err, input)
+	}
+}
+
+func TestProxyJumpString(t *testing.T) {
+	jump := &ProxyJump{User: "ubuntu", Host: "fd00::1", Port: 22}
+
+	assert.Equal(t, "ubuntu@[fd00::1]:22", jump.String())
+}
+
+func TestExternalClientProxyJump(t *testing.T) {
+	jump := &ProxyJump{User: "ubuntu", Host: "bastion.example.com", Port: 22}
+
+	client, err := NewProxyJumpClient("docker", "10.0.0.5", 22, &Auth{}, jump)
+	assert.NoError(t, err)
+
+	if external, ok := client.(*ExternalClient); ok {
+		assert.Equal(t, []string{"-J", "ubuntu@bastion.example.com:22"}, external.BaseArgs[len(external.BaseArgs)-2:])
+	} else {
+		assert.Equal(t, jump, client.(*NativeClient).ProxyJump)
+	}
+}
@@ -0,0 +1,684 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The packets of version 3 of the SFTP protocol, the one OpenSSH implements.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpSetstat  = 9
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpMkdir    = 14
+	sftpStat     = 17
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+	sftpProtocol = 3
+)
+
+const (
+	sftpFlagRead   = 0x01
+	sftpFlagWrite  = 0x02
+	sftpFlagCreate = 0x08
+	sftpFlagTrunc  = 0x10
+)
+
+const (
+	sftpAttrSize        = 0x01
+	sftpAttrUIDGID      = 0x02
+	sftpAttrPermissions = 0x04
+	sftpAttrTimes       = 0x08
+	sftpAttrExtended    = 0x80000000
+)
+
+const (
+	sftpStatusOK               = 0
+	sftpStatusEOF              = 1
+	sftpStatusNoSuchFile       = 2
+	sftpStatusPermissionDenied = 3
+)
+
+// The types of files in the permissions of the attributes.
+const (
+	sftpModeType    = 0170000
+	sftpModeDir     = 0040000
+	sftpModeSymlink = 0120000
+	sftpModeRegular = 0100000
+	sftpModeSetuid  = 04000
+	sftpModeSetgid  = 02000
+	sftpModeSticky  = 01000
+)
+
+const (
+	// sftpChunkSize is the size of the reads and writes, which every
+	// server accepts.
+	sftpChunkSize = 32 * 1024
+
+	sftpMaxPacketSize = 256 * 1024
+)
+
+var errSFTPPacket = errors.New("Error: malformed SFTP packet")
+
+// SFTPClient is a client of the SFTP server of a machine, which copies files
+// without an scp binary on either side.
+type SFTPClient struct {
+	r      io.Reader
+	w      io.WriteCloser
+	closer func() error
+
+	lock   sync.Mutex
+	nextID uint32
+}
+
+// NewSFTPClient connects to the SFTP server of the host, through the bastion
+// unless jump is nil.  It always uses the native client.
+func NewSFTPClient(user, host string, port int, auth *Auth, jump *ProxyJump) (*SFTPClient, error) {
+	renewCertificates(user, auth)
+
+	client, err := newNativeProxyJumpClient(user, host, port, auth, jump)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.(*NativeClient).dial()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	w, err := session.StdinPipe()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r, err := session.StdoutPipe()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Error starting the SFTP server of %s: %s", host, err)
+	}
+
+	c, err := newSFTPClient(r, w)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c.closer = func() error {
+		session.Close()
+		return conn.Close()
+	}
+	return c, nil
+}
+
+// newSFTPClient negotiates the version of the protocol with the server at
+// the other end of r and w.
+func newSFTPClient(r io.Reader, w io.WriteCloser) (*SFTPClient, error) {
+	c := &SFTPClient{r: r, w: w}
+
+	if err := writeSFTPPacket(w, sftpInit, appendUint32(nil, sftpProtocol)); err != nil {
+		return nil, fmt.Errorf("Error initializing SFTP: %s", err)
+	}
+
+	typ, data, err := readSFTPPacket(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing SFTP: %s", err)
+	}
+
+	d := &sftpDecoder{data: data}
+	if version := d.uint32(); typ != sftpVersion || d.err != nil || version < sftpProtocol {
+		return nil, fmt.Errorf("Error initializing SFTP: the server doesn't support version %d of the protocol", sftpProtocol)
+	}
+
+	return c, nil
+}
+
+// Close closes the connection to the server.
+func (c *SFTPClient) Close() error {
+	c.w.Close()
+	if c.closer != nil {
+		return c.closer()
+	}
+	return nil
+}
+
+// request sends a request and returns the type and the content of the
+// response, after its ID.
+func (c *SFTPClient) request(typ byte, payload []byte) (byte, *sftpDecoder, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.nextID++
+	id := c.nextID
+
+	if err := writeSFTPPacket(c.w, typ, append(appendUint32(nil, id), payload...)); err != nil {
+		return 0, nil, err
+	}
+
+	respType, data, err := readSFTPPacket(c.r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	d := &sftpDecoder{data: data}
+	if respID := d.uint32(); d.err != nil || respID != id {
+		return 0, nil, errSFTPPacket
+	}
+
+	return respType, d, nil
+}
+
+// status returns the error of a response which should be a successful
+// status.
+func (c *SFTPClient) status(op, name string, typ byte, d *sftpDecoder) error {
+	if typ != sftpStatus {
+		return &os.PathError{Op: op, Path: name, Err: errSFTPPacket}
+	}
+	return statusError(op, name, d)
+}
+
+func statusError(op, name string, d *sftpDecoder) error {
+	code := d.uint32()
+	message := d.string()
+	if d.err != nil {
+		return &os.PathError{Op: op, Path: name, Err: d.err}
+	}
+
+	switch code {
+	case sftpStatusOK:
+		return nil
+	case sftpStatusEOF:
+		return io.EOF
+	case sftpStatusNoSuchFile:
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	case sftpStatusPermissionDenied:
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return &os.PathError{Op: op, Path: name, Err: errors.New(message)}
+}
+
+// handle returns the handle a request opening a file or a directory
+// responds with.
+func (c *SFTPClient) handle(op, name string, typ byte, payload []byte) (string, error) {
+	respType, d, err := c.request(typ, payload)
+	if err != nil {
+		return "", err
+	}
+
+	if respType != sftpHandle {
+		return "", c.status(op, name, respType, d)
+	}
+
+	handle := d.string()
+	return handle, d.err
+}
+
+func (c *SFTPClient) stat(op string, typ byte, name string) (os.FileInfo, error) {
+	respType, d, err := c.request(typ, appendString(nil, name))
+	if err != nil {
+		return nil, err
+	}
+
+	if respType != sftpAttrs {
+		return nil, c.status(op, name, respType, d)
+	}
+
+	attrs := d.attrs()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &sftpFileInfo{name: path.Base(name), attrs: attrs}, nil
+}
+
+// Stat returns the information of a file, following the symbolic links.
+func (c *SFTPClient) Stat(name string) (os.FileInfo, error) {
+	return c.stat("stat", sftpStat, name)
+}
+
+// Lstat returns the information of a file, not following the symbolic
+// links.
+func (c *SFTPClient) Lstat(name string) (os.FileInfo, error) {
+	return c.stat("lstat", sftpLstat, name)
+}
+
+// ReadDir returns the entries of a directory sorted by name.
+func (c *SFTPClient) ReadDir(name string) ([]os.FileInfo, error) {
+	handle, err := c.handle("opendir", name, sftpOpendir, appendString(nil, name))
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(name, handle)
+
+	entries := []os.FileInfo{}
+	for {
+		respType, d, err := c.request(sftpReaddir, appendString(nil, handle))
+		if err != nil {
+			return nil, err
+		}
+
+		if respType != sftpName {
+			if err := c.status("readdir", name, respType, d); err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+
+		count := d.uint32()
+		for i := uint32(0); i < count && d.err == nil; i++ {
+			filename := d.string()
+			d.string() // the long name, as ls -l prints it
+			attrs := d.attrs()
+			if filename != "." && filename != ".." {
+				entries = append(entries, &sftpFileInfo{name: filename, attrs: attrs})
+			}
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Mkdir creates a directory.
+func (c *SFTPClient) Mkdir(name string, mode os.FileMode) error {
+	payload := appendAttrs(appendString(nil, name), sftpFileAttrs{flags: sftpAttrPermissions, mode: fromFileMode(mode)})
+	respType, d, err := c.request(sftpMkdir, payload)
+	if err != nil {
+		return err
+	}
+	return c.status("mkdir", name, respType, d)
+}
+
+// Chmod changes the mode of a file.
+func (c *SFTPClient) Chmod(name string, mode os.FileMode) error {
+	payload := appendAttrs(appendString(nil, name), sftpFileAttrs{flags: sftpAttrPermissions, mode: fromFileMode(mode)})
+	respType, d, err := c.request(sftpSetstat, payload)
+	if err != nil {
+		return err
+	}
+	return c.status("chmod", name, respType, d)
+}
+
+func (c *SFTPClient) open(name string, flags uint32, offset int64) (*sftpFile, error) {
+	payload := appendAttrs(appendUint32(appendString(nil, name), flags), sftpFileAttrs{})
+	handle, err := c.handle("open", name, sftpOpen, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpFile{client: c, name: name, handle: handle, offset: offset}, nil
+}
+
+// OpenAt opens a file for reading from the offset.
+func (c *SFTPClient) OpenAt(name string, offset int64) (io.ReadCloser, error) {
+	return c.open(name, sftpFlagRead, offset)
+}
+
+// CreateAt opens a file for writing from the offset, creating it if it
+// doesn't exist and truncating it if the offset is 0.
+func (c *SFTPClient) CreateAt(name string, offset int64) (io.WriteCloser, error) {
+	flags := uint32(sftpFlagWrite | sftpFlagCreate)
+	if offset == 0 {
+		flags |= sftpFlagTrunc
+	}
+	return c.open(name, flags, offset)
+}
+
+// Glob returns the paths matching a pattern, like filepath.Glob.
+func (c *SFTPClient) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	if !hasGlobMeta(pattern) {
+		if _, err := c.Lstat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := path.Split(pattern)
+	dir = cleanGlobDir(dir)
+
+	dirs := []string{dir}
+	if hasGlobMeta(dir) {
+		var err error
+		if dirs, err = c.Glob(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	matches := []string{}
+	for _, d := range dirs {
+		entries, err := c.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if matched, _ := path.Match(file, entry.Name()); matched {
+				matches = append(matches, path.Join(d, entry.Name()))
+			}
+		}
+	}
+	return matches, nil
+}
+
+// cleanGlobDir returns the directory of a pattern as path.Split gives it.
+func cleanGlobDir(dir string) string {
+	switch dir {
+	case "":
+		return "."
+	case "/":
+		return dir
+	}
+	return dir[:len(dir)-1]
+}
+
+// Join joins the elements of a path of the machine.
+func (c *SFTPClient) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Base returns the last element of a path of the machine.
+func (c *SFTPClient) Base(name string) string {
+	return path.Base(name)
+}
+
+func (c *SFTPClient) closeHandle(name, handle string) error {
+	respType, d, err := c.request(sftpClose, appendString(nil, handle))
+	if err != nil {
+		return err
+	}
+	return c.status("close", name, respType, d)
+}
+
+// sftpFile is a file opened on the server, read or written sequentially
+// from an offset.
+type sftpFile struct {
+	client *SFTPClient
+	name   string
+	handle string
+	offset int64
+}
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	if len(p) > sftpChunkSize {
+		p = p[:sftpChunkSize]
+	}
+
+	payload := appendUint32(appendUint64(appendString(nil, f.handle), uint64(f.offset)), uint32(len(p)))
+	respType, d, err := f.client.request(sftpRead, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	if respType != sftpData {
+		return 0, f.client.status("read", f.name, respType, d)
+	}
+
+	data := d.string()
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	n := copy(p, data)
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *sftpFile) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		if len(chunk) > sftpChunkSize {
+			chunk = chunk[:sftpChunkSize]
+		}
+
+		payload := appendBytes(appendUint64(appendString(nil, f.handle), uint64(f.offset)), chunk)
+		respType, d, err := f.client.request(sftpWrite, payload)
+		if err != nil {
+			return written, err
+		}
+
+		if err := f.client.status("write", f.name, respType, d); err != nil {
+			return written, err
+		}
+
+		written += len(chunk)
+		f.offset += int64(len(chunk))
+	}
+	return written, nil
+}
+
+func (f *sftpFile) Close() error {
+	return f.client.closeHandle(f.name, f.handle)
+}
+
+// sftpFileAttrs are the attributes of a file, of which only the size, the
+// mode and the modification time are kept.
+type sftpFileAttrs struct {
+	flags uint32
+	size  uint64
+	mode  uint32
+	atime uint32
+	mtime uint32
+}
+
+// sftpFileInfo is the os.FileInfo of a file on the server.
+type sftpFileInfo struct {
+	name  string
+	attrs sftpFileAttrs
+}
+
+func (fi *sftpFileInfo) Name() string       { return fi.name }
+func (fi *sftpFileInfo) Size() int64        { return int64(fi.attrs.size) }
+func (fi *sftpFileInfo) Mode() os.FileMode  { return toFileMode(fi.attrs.mode) }
+func (fi *sftpFileInfo) ModTime() time.Time { return time.Unix(int64(fi.attrs.mtime), 0) }
+func (fi *sftpFileInfo) IsDir() bool        { return fi.Mode().IsDir() }
+func (fi *sftpFileInfo) Sys() interface{}   { return nil }
+
+// toFileMode converts the permissions of the attributes, in the format of
+// st_mode, to an os.FileMode.
+func toFileMode(mode uint32) os.FileMode {
+	m := os.FileMode(mode & 0777)
+
+	switch mode & sftpModeType {
+	case sftpModeDir:
+		m |= os.ModeDir
+	case sftpModeSymlink:
+		m |= os.ModeSymlink
+	case sftpModeRegular:
+	default:
+		m |= os.ModeIrregular
+	}
+
+	if mode&sftpModeSetuid != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&sftpModeSetgid != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&sftpModeSticky != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+// fromFileMode converts the permissions of an os.FileMode to the ones of
+// the attributes.
+func fromFileMode(m os.FileMode) uint32 {
+	mode := uint32(m.Perm())
+
+	switch {
+	case m.IsDir():
+		mode |= sftpModeDir
+	case m&os.ModeSymlink != 0:
+		mode |= sftpModeSymlink
+	case m.IsRegular():
+		mode |= sftpModeRegular
+	}
+
+	if m&os.ModeSetuid != 0 {
+		mode |= sftpModeSetuid
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= sftpModeSetgid
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= sftpModeSticky
+	}
+	return mode
+}
+
+func readSFTPPacket(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > sftpMaxPacketSize {
+		return 0, nil, errSFTPPacket
+	}
+
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[4], data, nil
+}
+
+func writeSFTPPacket(w io.Writer, typ byte, payload []byte) error {
+	packet := appendUint32(nil, uint32(len(payload)+1))
+	packet = append(packet, typ)
+	_, err := w.Write(append(packet, payload...))
+	return err
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+func appendBytes(b []byte, data []byte) []byte {
+	return append(appendUint32(b, uint32(len(data))), data...)
+}
+
+func appendAttrs(b []byte, attrs sftpFileAttrs) []byte {
+	b = appendUint32(b, attrs.flags)
+	if attrs.flags&sftpAttrSize != 0 {
+		b = appendUint64(b, attrs.size)
+	}
+	if attrs.flags&sftpAttrPermissions != 0 {
+		b = appendUint32(b, attrs.mode)
+	}
+	if attrs.flags&sftpAttrTimes != 0 {
+		b = appendUint32(appendUint32(b, attrs.atime), attrs.mtime)
+	}
+	return b
+}
+
+// sftpDecoder reads the fields of a packet, keeping the first error so that
+// it's checked once after them.
+type sftpDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *sftpDecoder) next(n int) []byte {
+	if d.err != nil || len(d.data) < n {
+		d.err = errSFTPPacket
+		return nil
+	}
+
+	field := d.data[:n]
+	d.data = d.data[n:]
+	return field
+}
+
+func (d *sftpDecoder) uint32() uint32 {
+	if field := d.next(4); field != nil {
+		return binary.BigEndian.Uint32(field)
+	}
+	return 0
+}
+
+func (d *sftpDecoder) uint64() uint64 {
+	if field := d.next(8); field != nil {
+		return binary.BigEndian.Uint64(field)
+	}
+	return 0
+}
+
+func (d *sftpDecoder) string() string {
+	length := d.uint32()
+	if length > uint32(len(d.data)) {
+		d.err = errSFTPPacket
+		return ""
+	}
+	return string(d.next(int(length)))
+}
+
+func (d *sftpDecoder) attrs() sftpFileAttrs {
+	attrs := sftpFileAttrs{flags: d.uint32()}
+	if attrs.flags&sftpAttrSize != 0 {
+		attrs.size = d.uint64()
+	}
+	if attrs.flags&sftpAttrUIDGID != 0 {
+		d.uint32()
+		d.uint32()
+	}
+	if attrs.flags&sftpAttrPermissions != 0 {
+		attrs.mode = d.uint32()
+	}
+	if attrs.flags&sftpAttrTimes != 0 {
+		attrs.atime = d.uint32()
+		attrs.mtime = d.uint32()
+	}
+	if attrs.flags&sftpAttrExtended != 0 {
+		count := d.uint32()
+		for i := uint32(0); i < count && d.err == nil; i++ {
+			d.string()
+			d.string()
+		}
+	}
+	return attrs
+}
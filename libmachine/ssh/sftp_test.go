@@ -0,0 +1,225 @@
+package ssh
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSFTPServer serves the files of a local directory, answering the
+// requests of the client as the SFTP server of a machine would.
+type testSFTPServer struct {
+	root    string
+	handles map[string]*os.File
+	dirs    map[string][]os.FileInfo
+}
+
+func newTestSFTPClient(t *testing.T, root string) *SFTPClient {
+	requests, requestsWriter := io.Pipe()
+	responses, responsesWriter := io.Pipe()
+
+	server := &testSFTPServer{root: root, handles: map[string]*os.File{}, dirs: map[string][]os.FileInfo{}}
+	go server.serve(requests, responsesWriter)
+
+	client, err := newSFTPClient(responses, requestsWriter)
+	assert.NoError(t, err)
+	return client
+}
+
+func (s *testSFTPServer) serve(r io.Reader, w io.WriteCloser) {
+	defer w.Close()
+
+	for {
+		typ, data, err := readSFTPPacket(r)
+		if err != nil {
+			return
+		}
+
+		d := &sftpDecoder{data: data}
+		if typ == sftpInit {
+			writeSFTPPacket(w, sftpVersion, appendUint32(nil, sftpProtocol))
+			continue
+		}
+
+		id := d.uint32()
+		respType, payload := s.handle(typ, d)
+		writeSFTPPacket(w, respType, append(appendUint32(nil, id), payload...))
+	}
+}
+
+func (s *testSFTPServer) handle(typ byte, d *sftpDecoder) (byte, []byte) {
+	switch typ {
+	case sftpStat, sftpLstat:
+		info, err := os.Stat(s.path(d.string()))
+		if err != nil {
+			return testSFTPStatus(err)
+		}
+		return sftpAttrs, appendAttrs(nil, testSFTPAttrs(info))
+	case sftpOpen:
+		name, flags := d.string(), d.uint32()
+		osFlags := os.O_RDONLY
+		if flags&sftpFlagWrite != 0 {
+			osFlags = os.O_WRONLY
+		}
+		if flags&sftpFlagCreate != 0 {
+			osFlags |= os.O_CREATE
+		}
+		if flags&sftpFlagTrunc != 0 {
+			osFlags |= os.O_TRUNC
+		}
+		f, err := os.OpenFile(s.path(name), osFlags, 0644)
+		if err != nil {
+			return testSFTPStatus(err)
+		}
+		handle := strconv.Itoa(len(s.handles) + len(s.dirs))
+		s.handles[handle] = f
+		return sftpHandle, appendString(nil, handle)
+	case sftpRead:
+		f, offset, length := s.handles[d.string()], d.uint64(), d.uint32()
+		data := make([]byte, length)
+		n, err := f.ReadAt(data, int64(offset))
+		if n == 0 && err != nil {
+			return testSFTPStatus(err)
+		}
+		return sftpData, appendBytes(nil, data[:n])
+	case sftpWrite:
+		f, offset, data := s.handles[d.string()], d.uint64(), d.string()
+		_, err := f.WriteAt([]byte(data), int64(offset))
+		return testSFTPStatus(err)
+	case sftpClose:
+		handle := d.string()
+		if f, ok := s.handles[handle]; ok {
+			return testSFTPStatus(f.Close())
+		}
+		return testSFTPStatus(nil)
+	case sftpOpendir:
+		entries, err := ioutil.ReadDir(s.path(d.string()))
+		if err != nil {
+			return testSFTPStatus(err)
+		}
+		handle := strconv.Itoa(len(s.handles) + len(s.dirs))
+		s.dirs[handle] = entries
+		return sftpHandle, appendString(nil, handle)
+	case sftpReaddir:
+		handle := d.string()
+		entries := s.dirs[handle]
+		if entries == nil {
+			return testSFTPStatus(io.EOF)
+		}
+		s.dirs[handle] = []os.FileInfo{}
+		if len(entries) == 0 {
+			return testSFTPStatus(io.EOF)
+		}
+		payload := appendUint32(nil, uint32(len(entries)))
+		for _, entry := range entries {
+			payload = appendString(appendString(payload, entry.Name()), entry.Name())
+			payload = appendAttrs(payload, testSFTPAttrs(entry))
+		}
+		return sftpName, payload
+	case sftpMkdir:
+		name, attrs := d.string(), d.attrs()
+		return testSFTPStatus(os.Mkdir(s.path(name), toFileMode(attrs.mode).Perm()))
+	case sftpSetstat:
+		name, attrs := d.string(), d.attrs()
+		return testSFTPStatus(os.Chmod(s.path(name), toFileMode(attrs.mode).Perm()))
+	}
+	return sftpStatus, appendString(appendString(appendUint32(nil, 8), "unsupported"), "")
+}
+
+func (s *testSFTPServer) path(name string) string {
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+func testSFTPAttrs(info os.FileInfo) sftpFileAttrs {
+	return sftpFileAttrs{
+		flags: sftpAttrSize | sftpAttrPermissions | sftpAttrTimes,
+		size:  uint64(info.Size()),
+		mode:  fromFileMode(info.Mode()),
+		mtime: uint32(info.ModTime().Unix()),
+	}
+}
+
+func testSFTPStatus(err error) (byte, []byte) {
+	code := uint32(sftpStatusOK)
+	switch {
+	case err == io.EOF:
+		code = sftpStatusEOF
+	case os.IsNotExist(err):
+		code = sftpStatusNoSuchFile
+	case err != nil:
+		code = 4
+	}
+	return sftpStatus, appendString(appendString(appendUint32(nil, code), ""), "")
+}
+
+func TestSFTPClientStat(t *testing.T) {
+	root, err := ioutil.TempDir("", "sftp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "foo.txt"), []byte("foo"), 0640))
+
+	client := newTestSFTPClient(t, root)
+	defer client.Close()
+
+	info, err := client.Stat("foo.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo.txt", info.Name())
+	assert.Equal(t, int64(3), info.Size())
+	assert.Equal(t, os.FileMode(0640), info.Mode())
+
+	info, err = client.Stat(".")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	_, err = client.Stat("bar.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSFTPClientCopy(t *testing.T) {
+	local, err := ioutil.TempDir("", "local")
+	assert.NoError(t, err)
+	defer os.RemoveAll(local)
+
+	remote, err := ioutil.TempDir("", "sftp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(remote)
+
+	content := make([]byte, 3*sftpChunkSize+100)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(local, "app", "bin"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(local, "app", "bin", "run.sh"), []byte("#!/bin/sh\n"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(local, "app", "data"), content, 0600))
+
+	client := newTestSFTPClient(t, remote)
+	defer client.Close()
+
+	err = Copy(LocalFileSystem{}, filepath.Join(local, "app"), client, ".", TransferOptions{Recursive: true})
+	assert.NoError(t, err)
+
+	copied, err := ioutil.ReadFile(filepath.Join(remote, "app", "data"))
+	assert.NoError(t, err)
+	assert.Equal(t, content, copied)
+
+	info, err := os.Stat(filepath.Join(remote, "app", "bin", "run.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode())
+
+	matches, err := client.Glob("app/*/*.sh")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app/bin/run.sh"}, matches)
+
+	err = Copy(client, "app/d*", LocalFileSystem{}, filepath.Join(local, "data.copy"), TransferOptions{})
+	assert.NoError(t, err)
+
+	copied, err = ioutil.ReadFile(filepath.Join(local, "data.copy"))
+	assert.NoError(t, err)
+	assert.Equal(t, content, copied)
+}
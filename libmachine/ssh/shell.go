@@ -0,0 +1,374 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// The native client understands the OpenSSH options of docker-machine ssh
+// which users passed to the external client until now: -A, -X, -t, -T and
+// the -o options matching them, plus ProxyCommand.
+
+const (
+	ttyAuto = iota
+	ttyForce
+	ttyNever
+)
+
+// shellOptions are the options given before the command of a shell.
+type shellOptions struct {
+	forwardAgent bool
+	forwardX11   bool
+	tty          int
+	proxyCommand string
+}
+
+// parseShellArgs splits the leading OpenSSH options of the arguments of a
+// shell from its command.
+func parseShellArgs(args []string) (shellOptions, []string, error) {
+	opts := shellOptions{}
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		arg := args[0]
+		args = args[1:]
+
+		switch arg {
+		case "--":
+			return opts, args, nil
+		case "-A":
+			opts.forwardAgent = true
+		case "-a":
+			opts.forwardAgent = false
+		case "-X", "-Y":
+			opts.forwardX11 = true
+		case "-x":
+			opts.forwardX11 = false
+		case "-t":
+			opts.tty = ttyForce
+		case "-T":
+			opts.tty = ttyNever
+		case "-o":
+			if len(args) == 0 {
+				return opts, nil, fmt.Errorf("Missing value of the SSH option -o")
+			}
+			if err := opts.set(args[0]); err != nil {
+				return opts, nil, err
+			}
+			args = args[1:]
+		default:
+			if !strings.HasPrefix(arg, "-o") {
+				return opts, nil, fmt.Errorf("The SSH option %s isn't supported by the native client, use --native-ssh=false", arg)
+			}
+			if err := opts.set(strings.TrimPrefix(arg, "-o")); err != nil {
+				return opts, nil, err
+			}
+		}
+	}
+
+	return opts, args, nil
+}
+
+// set sets an option given with -o, as Key=Value or Key Value.
+func (opts *shellOptions) set(option string) error {
+	parts := strings.SplitN(strings.TrimSpace(option), "=", 2)
+	if len(parts) != 2 {
+		parts = strings.SplitN(strings.TrimSpace(option), " ", 2)
+	}
+	if len(parts) != 2 {
+		return fmt.Errorf("Invalid SSH option %q, expected Key=Value", option)
+	}
+	key, value := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+
+	switch key {
+	case "forwardagent":
+		opts.forwardAgent = value == "yes"
+	case "forwardx11", "forwardx11trusted":
+		opts.forwardX11 = value == "yes"
+	case "requesttty":
+		switch value {
+		case "yes", "force":
+			opts.tty = ttyForce
+		case "no":
+			opts.tty = ttyNever
+		default:
+			opts.tty = ttyAuto
+		}
+	case "proxycommand":
+		if value != "none" {
+			opts.proxyCommand = value
+		}
+	default:
+		return fmt.Errorf("The SSH option %s isn't supported by the native client, use --native-ssh=false", parts[0])
+	}
+
+	return nil
+}
+
+// wantTTY tells whether the shell needs a terminal: by default when it's
+// interactive, so that the output of piped commands isn't altered.
+func (opts shellOptions) wantTTY(stdinIsTerminal bool) bool {
+	switch opts.tty {
+	case ttyForce:
+		return true
+	case ttyNever:
+		return false
+	}
+
+	return stdinIsTerminal
+}
+
+// proxyCommandArgs expands the tokens of a ProxyCommand and returns the
+// command line running it with the shell.
+func proxyCommandArgs(command, user, host string, port int) []string {
+	command = strings.NewReplacer(
+		"%%", "%",
+		"%h", host,
+		"%p", strconv.Itoa(port),
+		"%r", user,
+	).Replace(command)
+
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+
+	return []string{"/bin/sh", "-c", command}
+}
+
+// proxyCommandConn is a connection to the standard input and output of a
+// ProxyCommand.
+type proxyCommandConn struct {
+	cmd *exec.Cmd
+	io.Reader
+	io.WriteCloser
+}
+
+func (c *proxyCommandConn) Close() error {
+	c.WriteCloser.Close()
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+	return c.cmd.Wait()
+}
+
+func (c *proxyCommandConn) LocalAddr() net.Addr                { return proxyCommandAddr{} }
+func (c *proxyCommandConn) RemoteAddr() net.Addr               { return proxyCommandAddr{} }
+func (c *proxyCommandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *proxyCommandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *proxyCommandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type proxyCommandAddr struct{}
+
+func (proxyCommandAddr) Network() string { return "proxy-command" }
+func (proxyCommandAddr) String() string  { return "proxy-command" }
+
+// dialProxyCommand connects to the host over the standard input and output
+// of a ProxyCommand, such as nc or a cloud provider's tunnel.
+func dialProxyCommand(command string, client *NativeClient) (*ssh.Client, error) {
+	args := proxyCommandArgs(command, client.Config.User, client.Hostname, client.Port)
+	log.Debugf("Connecting through the proxy command %q", args[len(args)-1])
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error running the proxy command: %s", err)
+	}
+
+	conn := &proxyCommandConn{cmd: cmd, Reader: stdout, WriteCloser: stdin}
+	c, chans, reqs, err := ssh.NewClientConn(conn, client.address(), &client.Config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Error connecting through the proxy command: %s", err)
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// forwardChannels accepts the channels the host opens and connects each of
+// them to a new local connection.
+func forwardChannels(channels <-chan ssh.NewChannel, dial func() (net.Conn, error)) {
+	for newChannel := range channels {
+		local, err := dial()
+		if err != nil {
+			log.Debugf("Error forwarding a %s channel: %s", newChannel.ChannelType(), err)
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			local.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+
+		go func() {
+			defer local.Close()
+			defer channel.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				io.Copy(local, channel)
+				done <- struct{}{}
+			}()
+			go func() {
+				io.Copy(channel, local)
+				channel.CloseWrite()
+				done <- struct{}{}
+			}()
+			<-done
+		}()
+	}
+}
+
+// forwardAgent forwards the local SSH agent to the session.
+func forwardAgent(conn *ssh.Client, session *ssh.Session) error {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		log.Warn("SSH_AUTH_SOCK is not set, the SSH agent isn't forwarded")
+		return nil
+	}
+
+	channels := conn.HandleChannelOpen("auth-agent@openssh.com")
+	if channels != nil {
+		go forwardChannels(channels, func() (net.Conn, error) {
+			return net.Dial("unix", socket)
+		})
+	}
+
+	if ok, err := session.SendRequest("auth-agent-req@openssh.com", true, nil); err != nil || !ok {
+		return fmt.Errorf("The machine refused to forward the SSH agent")
+	}
+
+	return nil
+}
+
+// x11Display is a local X display, such as :0, localhost:10.0 or the
+// socket path of XQuartz.
+type x11Display struct {
+	host   string
+	number int
+	screen uint32
+}
+
+func parseX11Display(display string) (x11Display, error) {
+	i := strings.LastIndex(display, ":")
+	if i < 0 {
+		return x11Display{}, fmt.Errorf("Invalid DISPLAY %q", display)
+	}
+
+	parts := strings.SplitN(display[i+1:], ".", 2)
+	number, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return x11Display{}, fmt.Errorf("Invalid DISPLAY %q", display)
+	}
+
+	var screen uint64
+	if len(parts) == 2 {
+		if screen, err = strconv.ParseUint(parts[1], 10, 32); err != nil {
+			return x11Display{}, fmt.Errorf("Invalid DISPLAY %q", display)
+		}
+	}
+
+	return x11Display{host: display[:i], number: number, screen: uint32(screen)}, nil
+}
+
+func (d x11Display) dial() (net.Conn, error) {
+	switch {
+	case strings.HasPrefix(d.host, "/"):
+		return net.Dial("unix", d.host+":"+strconv.Itoa(d.number))
+	case d.host == "" || d.host == "unix":
+		return net.Dial("unix", fmt.Sprintf("/tmp/.X11-unix/X%d", d.number))
+	default:
+		return net.Dial("tcp", net.JoinHostPort(d.host, strconv.Itoa(6000+d.number)))
+	}
+}
+
+// x11Cookie returns the authorization protocol and cookie of the display
+// from xauth, or a random cookie for X servers without authorization.
+func x11Cookie(display string) (string, string) {
+	output, err := exec.Command("xauth", "list", display).Output()
+	if err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			if fields := strings.Fields(line); len(fields) == 3 {
+				return fields[1], fields[2]
+			}
+		}
+	}
+
+	cookie := make([]byte, 16)
+	rand.Read(cookie)
+	return "MIT-MAGIC-COOKIE-1", hex.EncodeToString(cookie)
+}
+
+// forwardX11 forwards the connections to the X display of the session to
+// the local display.
+func forwardX11(conn *ssh.Client, session *ssh.Session) error {
+	display, err := parseX11Display(os.Getenv("DISPLAY"))
+	if err != nil {
+		log.Warnf("The X11 connections aren't forwarded: %s", err)
+		return nil
+	}
+
+	channels := conn.HandleChannelOpen("x11")
+	if channels != nil {
+		go forwardChannels(channels, display.dial)
+	}
+
+	protocol, cookie := x11Cookie(os.Getenv("DISPLAY"))
+	payload := ssh.Marshal(struct {
+		SingleConnection bool
+		AuthProtocol     string
+		AuthCookie       string
+		ScreenNumber     uint32
+	}{false, protocol, cookie, display.screen})
+
+	if ok, err := session.SendRequest("x11-req", true, payload); err != nil || !ok {
+		return fmt.Errorf("The machine refused to forward X11, is X11Forwarding enabled in its sshd_config?")
+	}
+
+	return nil
+}
+
+// windowChange sends the new size of the terminal to the session.
+func windowChange(session *ssh.Session, height, width int) error {
+	_, err := session.SendRequest("window-change", false, ssh.Marshal(struct {
+		Columns uint32
+		Rows    uint32
+		Width   uint32
+		Height  uint32
+	}{uint32(width), uint32(height), 0, 0}))
+	return err
+}
+
+// ExitStatus returns the exit status of the remote command a shell failed
+// with, false if it failed for another reason.
+func ExitStatus(err error) (int, bool) {
+	switch e := err.(type) {
+	case *ssh.ExitError:
+		return e.ExitStatus(), true
+	case *exec.ExitError:
+		return e.ExitCode(), e.ExitCode() > 0
+	}
+
+	return 0, false
+}
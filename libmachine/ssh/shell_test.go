@@ -0,0 +1,96 @@
+package ssh
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseShellArgs(t *testing.T) {
+	var tests = []struct {
+		args     []string
+		expected shellOptions
+		command  []string
+	}{
+		{[]string{}, shellOptions{}, []string{}},
+		{[]string{"df", "-h"}, shellOptions{}, []string{"df", "-h"}},
+		{[]string{"-A", "-X", "-t", "-o", "ProxyCommand=nc %h %p", "--", "ls", "-l"}, shellOptions{forwardAgent: true, forwardX11: true, tty: ttyForce, proxyCommand: "nc %h %p"}, []string{"ls", "-l"}},
+		{[]string{"-oForwardAgent=yes", "-T", "uptime"}, shellOptions{forwardAgent: true, tty: ttyNever}, []string{"uptime"}},
+		{[]string{"-o", "RequestTTY no", "-Y"}, shellOptions{forwardX11: true, tty: ttyNever}, []string{}},
+		{[]string{"-A", "-a", "-o", "ProxyCommand=none"}, shellOptions{}, []string{}},
+	}
+
+	for _, test := range tests {
+		opts, command, err := parseShellArgs(test.args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, opts)
+		assert.Equal(t, test.command, command)
+	}
+}
+
+func TestParseShellArgsInvalid(t *testing.T) {
+	_, _, err := parseShellArgs([]string{"-v", "ls"})
+	assert.EqualError(t, err, "The SSH option -v isn't supported by the native client, use --native-ssh=false")
+
+	_, _, err = parseShellArgs([]string{"-o", "StrictHostKeyChecking=no"})
+	assert.EqualError(t, err, "The SSH option StrictHostKeyChecking isn't supported by the native client, use --native-ssh=false")
+
+	_, _, err = parseShellArgs([]string{"-o"})
+	assert.EqualError(t, err, "Missing value of the SSH option -o")
+}
+
+func TestWantTTY(t *testing.T) {
+	assert.True(t, shellOptions{}.wantTTY(true))
+	assert.False(t, shellOptions{}.wantTTY(false))
+	assert.True(t, shellOptions{tty: ttyForce}.wantTTY(false))
+	assert.False(t, shellOptions{tty: ttyNever}.wantTTY(true))
+}
+
+func TestProxyCommandArgs(t *testing.T) {
+	args := proxyCommandArgs("ssh -W %h:%p %r@bastion 100%%", "docker", "10.0.0.2", 22)
+
+	assert.Equal(t, "ssh -W 10.0.0.2:22 docker@bastion 100%", args[len(args)-1])
+}
+
+func TestParseX11Display(t *testing.T) {
+	var tests = []struct {
+		display  string
+		expected x11Display
+	}{
+		{":0", x11Display{number: 0}},
+		{"localhost:10.1", x11Display{host: "localhost", number: 10, screen: 1}},
+		{"/private/tmp/com.apple.launchd.x/org.xquartz:0", x11Display{host: "/private/tmp/com.apple.launchd.x/org.xquartz", number: 0}},
+	}
+
+	for _, test := range tests {
+		display, err := parseX11Display(test.display)
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, display)
+	}
+
+	for _, display := range []string{"", "localhost", ":x", ":0.x"} {
+		_, err := parseX11Display(display)
+		assert.Error(t, err)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	status, ok := ExitStatus(exec.Command("sh", "-c", "exit 3").Run())
+	assert.True(t, ok)
+	assert.Equal(t, 3, status)
+
+	_, ok = ExitStatus(errors.New("connection refused"))
+	assert.False(t, ok)
+
+	_, ok = ExitStatus(nil)
+	assert.False(t, ok)
+}
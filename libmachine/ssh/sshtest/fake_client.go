@@ -1,6 +1,9 @@
 package sshtest
 
-import "io"
+import (
+	"io"
+	"io/ioutil"
+)
 
 type CmdResult struct {
 	Out string
@@ -10,6 +13,7 @@ type CmdResult struct {
 type FakeClient struct {
 	ActivatedShell []string
 	Outputs        map[string]CmdResult
+	Inputs         map[string][]byte
 }
 
 func (fsc *FakeClient) Output(command string) (string, error) {
@@ -17,6 +21,19 @@ func (fsc *FakeClient) Output(command string) (string, error) {
 	return outerr.Out, outerr.Err
 }
 
+func (fsc *FakeClient) OutputWithInput(command string, input io.Reader) (string, error) {
+	content, err := ioutil.ReadAll(input)
+	if err != nil {
+		return "", err
+	}
+	if fsc.Inputs == nil {
+		fsc.Inputs = map[string][]byte{}
+	}
+	fsc.Inputs[command] = content
+
+	return fsc.Output(command)
+}
+
 func (fsc *FakeClient) Shell(args ...string) error {
 	fsc.ActivatedShell = args
 	return nil
@@ -0,0 +1,268 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileSystem is where files are copied from or to: the local file system,
+// or the one of a machine through an SFTPClient.
+type FileSystem interface {
+	Stat(name string) (os.FileInfo, error)
+
+	ReadDir(name string) ([]os.FileInfo, error)
+
+	// OpenAt opens a file for reading from the offset.
+	OpenAt(name string, offset int64) (io.ReadCloser, error)
+
+	// CreateAt opens a file for writing from the offset, creating it if it
+	// doesn't exist and truncating it if the offset is 0.
+	CreateAt(name string, offset int64) (io.WriteCloser, error)
+
+	Mkdir(name string, mode os.FileMode) error
+
+	Chmod(name string, mode os.FileMode) error
+
+	Glob(pattern string) ([]string, error)
+
+	Join(elem ...string) string
+
+	Base(name string) string
+}
+
+// LocalFileSystem is the file system of the local host.
+type LocalFileSystem struct{}
+
+func (LocalFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (LocalFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
+
+func (LocalFileSystem) OpenAt(name string, offset int64) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (LocalFileSystem) CreateAt(name string, offset int64) (io.WriteCloser, error) {
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(name, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (LocalFileSystem) Mkdir(name string, mode os.FileMode) error {
+	return os.Mkdir(name, mode)
+}
+
+func (LocalFileSystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (LocalFileSystem) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+func (LocalFileSystem) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (LocalFileSystem) Base(name string) string {
+	return filepath.Base(name)
+}
+
+// TransferProgress is told of the progress of the copy of each file.
+type TransferProgress interface {
+	// Start is called before a file is copied, from the offset if the
+	// copy resumes.
+	Start(name string, size, offset int64)
+
+	// Add is called when bytes of the file are copied.
+	Add(n int)
+
+	// Done is called once the file is copied.
+	Done()
+}
+
+// TransferOptions are the options of Copy.
+type TransferOptions struct {
+	// Recursive copies the directories with their content.
+	Recursive bool
+
+	// Resume copies the rest of the files which are shorter at the
+	// destination, e.g. after an interrupted copy, instead of copying them
+	// again.  The files of the same size are skipped.
+	Resume bool
+
+	// Progress is told of the progress of the copy, unless it's nil.
+	Progress TransferProgress
+}
+
+// Copy copies the files matching a pattern from a file system to another,
+// keeping their modes.  Like with cp, they are copied into the target if
+// it's a directory, which it must be if several files match.  Copying
+// between two machines streams the files through the local host.
+func Copy(src FileSystem, pattern string, dst FileSystem, target string, opts TransferOptions) error {
+	sources := []string{pattern}
+	if hasGlobMeta(pattern) {
+		matches, err := src.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("Error: no file matches %s", pattern)
+		}
+		sources = matches
+	}
+
+	targetInfo, err := dst.Stat(target)
+	intoDir := err == nil && targetInfo.IsDir()
+	if len(sources) > 1 && !intoDir {
+		return fmt.Errorf("Error: %s is not a directory, several files can only be copied into one", target)
+	}
+
+	for _, source := range sources {
+		destination := target
+		if intoDir {
+			destination = dst.Join(target, src.Base(source))
+		}
+
+		if err := copyPath(src, source, dst, destination, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyPath(src FileSystem, source string, dst FileSystem, destination string, opts TransferOptions) error {
+	info, err := src.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		if !opts.Recursive {
+			return fmt.Errorf("Error: %s is a directory, copy it with --recursive", source)
+		}
+		return copyDir(src, source, dst, destination, info, opts)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("Error: %s is not a regular file", source)
+	}
+	return copyFile(src, source, dst, destination, info, opts)
+}
+
+func copyDir(src FileSystem, source string, dst FileSystem, destination string, info os.FileInfo, opts TransferOptions) error {
+	existing, err := dst.Stat(destination)
+	switch {
+	case err != nil:
+		// The directory stays writable until its content is copied.
+		if err := dst.Mkdir(destination, info.Mode().Perm()|0700); err != nil {
+			return err
+		}
+	case !existing.IsDir():
+		return fmt.Errorf("Error: %s is not a directory", destination)
+	}
+
+	entries, err := src.ReadDir(source)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := copyPath(src, src.Join(source, entry.Name()), dst, dst.Join(destination, entry.Name()), opts); err != nil {
+			return err
+		}
+	}
+
+	return dst.Chmod(destination, info.Mode().Perm())
+}
+
+func copyFile(src FileSystem, source string, dst FileSystem, destination string, info os.FileInfo, opts TransferOptions) error {
+	var offset int64
+	if opts.Resume {
+		if existing, err := dst.Stat(destination); err == nil && existing.Mode().IsRegular() {
+			switch {
+			case existing.Size() == info.Size():
+				return dst.Chmod(destination, info.Mode().Perm())
+			case existing.Size() < info.Size():
+				offset = existing.Size()
+			}
+		}
+	}
+
+	r, err := src.OpenAt(source, offset)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := dst.CreateAt(destination, offset)
+	if err != nil {
+		return err
+	}
+
+	if opts.Progress != nil {
+		opts.Progress.Start(src.Base(source), info.Size(), offset)
+		r = &progressReader{ReadCloser: r, progress: opts.Progress}
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if opts.Progress != nil {
+		opts.Progress.Done()
+	}
+
+	return dst.Chmod(destination, info.Mode().Perm())
+}
+
+// progressReader tells the progress of the reads.
+type progressReader struct {
+	io.ReadCloser
+	progress TransferProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.progress.Add(n)
+	return n, err
+}
+
+// hasGlobMeta returns true if the path has the special characters of a
+// pattern.
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
@@ -0,0 +1,131 @@
+package ssh
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingProgress records the progress of the copy.
+type recordingProgress struct {
+	started []string
+	offsets []int64
+	copied  int
+	done    int
+}
+
+func (p *recordingProgress) Start(name string, size, offset int64) {
+	p.started = append(p.started, name)
+	p.offsets = append(p.offsets, offset)
+}
+
+func (p *recordingProgress) Add(n int) {
+	p.copied += n
+}
+
+func (p *recordingProgress) Done() {
+	p.done++
+}
+
+func newTransferDirs(t *testing.T) (string, string) {
+	src, err := ioutil.TempDir("", "src")
+	assert.NoError(t, err)
+
+	dst, err := ioutil.TempDir("", "dst")
+	assert.NoError(t, err)
+
+	return src, dst
+}
+
+func TestCopyGlob(t *testing.T) {
+	src, dst := newTransferDirs(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a.log"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "b.log"), []byte("bb"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "c.txt"), []byte("c"), 0644))
+
+	progress := &recordingProgress{}
+	err := Copy(LocalFileSystem{}, filepath.Join(src, "*.log"), LocalFileSystem{}, dst, TransferOptions{Progress: progress})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.log", "b.log"}, progress.started)
+	assert.Equal(t, 3, progress.copied)
+	assert.Equal(t, 2, progress.done)
+
+	info, err := os.Stat(filepath.Join(dst, "a.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode())
+
+	_, err = os.Stat(filepath.Join(dst, "c.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCopyErrors(t *testing.T) {
+	src, dst := newTransferDirs(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	assert.NoError(t, os.Mkdir(filepath.Join(src, "dir"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a.log"), []byte("a"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "b.log"), []byte("b"), 0644))
+
+	err := Copy(LocalFileSystem{}, filepath.Join(src, "dir"), LocalFileSystem{}, dst, TransferOptions{})
+	assert.EqualError(t, err, "Error: "+filepath.Join(src, "dir")+" is a directory, copy it with --recursive")
+
+	err = Copy(LocalFileSystem{}, filepath.Join(src, "*.log"), LocalFileSystem{}, filepath.Join(dst, "all.log"), TransferOptions{})
+	assert.EqualError(t, err, "Error: "+filepath.Join(dst, "all.log")+" is not a directory, several files can only be copied into one")
+
+	err = Copy(LocalFileSystem{}, filepath.Join(src, "*.txt"), LocalFileSystem{}, dst, TransferOptions{})
+	assert.EqualError(t, err, "Error: no file matches "+filepath.Join(src, "*.txt"))
+}
+
+func TestCopyRecursive(t *testing.T) {
+	src, dst := newTransferDirs(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "conf", "ssl"), 0750))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "conf", "ssl", "key.pem"), []byte("key"), 0400))
+
+	err := Copy(LocalFileSystem{}, filepath.Join(src, "conf"), LocalFileSystem{}, filepath.Join(dst, "etc"), TransferOptions{Recursive: true})
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dst, "etc", "ssl", "key.pem"))
+	assert.NoError(t, err)
+	assert.Equal(t, "key", string(content))
+
+	info, err := os.Stat(filepath.Join(dst, "etc", "ssl"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.ModeDir|0750, info.Mode())
+}
+
+func TestCopyResume(t *testing.T) {
+	src, dst := newTransferDirs(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "image.iso"), []byte("0123456789"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dst, "image.iso"), []byte("0123"), 0644))
+
+	progress := &recordingProgress{}
+	err := Copy(LocalFileSystem{}, filepath.Join(src, "image.iso"), LocalFileSystem{}, dst, TransferOptions{Resume: true, Progress: progress})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4}, progress.offsets)
+	assert.Equal(t, 6, progress.copied)
+
+	content, err := ioutil.ReadFile(filepath.Join(dst, "image.iso"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+
+	progress = &recordingProgress{}
+	err = Copy(LocalFileSystem{}, filepath.Join(src, "image.iso"), LocalFileSystem{}, dst, TransferOptions{Resume: true, Progress: progress})
+
+	assert.NoError(t, err)
+	assert.Empty(t, progress.started)
+}
@@ -0,0 +1,292 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	tunnelKeepAliveInterval = 30 * time.Second
+	tunnelKeepAliveTimeout  = 15 * time.Second
+	tunnelRetryInterval     = 5 * time.Second
+)
+
+const tunnelDialAttempts = 3
+
+// Forward is a local address forwarded to an address reached from the
+// machine, like the ones of ssh -L.
+type Forward struct {
+	LocalAddress  string
+	RemoteAddress string
+}
+
+func (f Forward) String() string {
+	return fmt.Sprintf("%s -> %s", f.LocalAddress, f.RemoteAddress)
+}
+
+// ParseForward parses a forward given as [BIND:]PORT:[HOST:]HOSTPORT, e.g.
+// 8080:80 or 5432:db:5432.  The local port is bound on the loopback interface
+// and the host is the machine itself unless they are given.
+func ParseForward(s string) (Forward, error) {
+	parts := strings.Split(s, ":")
+
+	var bind, localPort, host, remotePort string
+	switch len(parts) {
+	case 2:
+		bind, localPort, host, remotePort = "127.0.0.1", parts[0], "localhost", parts[1]
+	case 3:
+		bind, localPort, host, remotePort = "127.0.0.1", parts[0], parts[1], parts[2]
+	case 4:
+		bind, localPort, host, remotePort = parts[0], parts[1], parts[2], parts[3]
+	default:
+		return Forward{}, fmt.Errorf("Invalid forward %q, expected [BIND:]PORT:[HOST:]HOSTPORT", s)
+	}
+
+	for _, port := range []string{localPort, remotePort} {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return Forward{}, fmt.Errorf("Invalid forward %q, %q is not a port", s, port)
+		}
+	}
+	if bind == "" || host == "" {
+		return Forward{}, fmt.Errorf("Invalid forward %q, expected [BIND:]PORT:[HOST:]HOSTPORT", s)
+	}
+
+	return Forward{
+		LocalAddress:  net.JoinHostPort(bind, localPort),
+		RemoteAddress: net.JoinHostPort(host, remotePort),
+	}, nil
+}
+
+// Tunnel forwards local ports over an SSH connection to the machine, which
+// it re-establishes whenever it's lost, e.g. when the machine restarts.  The
+// local ports stay open in the meantime.
+type Tunnel struct {
+	client    *NativeClient
+	forwards  []Forward
+	listeners []net.Listener
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	conn    *ssh.Client
+	stopped bool
+}
+
+// NewTunnel returns a tunnel to the host, through the bastion unless jump is
+// nil.  It always uses the native client, which keeps the connection alive.
+func NewTunnel(user, host string, port int, auth *Auth, jump *ProxyJump, forwards []Forward) (*Tunnel, error) {
+	client, err := newNativeProxyJumpClient(user, host, port, auth, jump)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tunnel{
+		client:   client.(*NativeClient),
+		forwards: forwards,
+	}
+	t.cond = sync.NewCond(&t.lock)
+	return t, nil
+}
+
+// Start listens on the local ports and connects to the machine, so that the
+// errors are reported before the tunnel runs.
+func (t *Tunnel) Start() error {
+	for _, f := range t.forwards {
+		l, err := net.Listen("tcp", f.LocalAddress)
+		if err != nil {
+			t.closeListeners()
+			return fmt.Errorf("Error listening on %s: %s", f.LocalAddress, err)
+		}
+		t.listeners = append(t.listeners, l)
+	}
+
+	conn, err := t.client.dialOnce()
+	if err != nil {
+		t.closeListeners()
+		return fmt.Errorf("Error connecting to %s: %s", t.client.address(), err)
+	}
+	t.setConn(conn)
+
+	for i, l := range t.listeners {
+		go t.serve(l, t.forwards[i])
+	}
+	return nil
+}
+
+// Run keeps the tunnel started by Start up until stop is closed, then closes
+// it.
+func (t *Tunnel) Run(stop <-chan struct{}) {
+	defer t.close()
+
+	for {
+		conn := t.connection()
+		if !t.watch(conn, stop) {
+			return
+		}
+
+		t.setConn(nil)
+		log.Warnf("Lost the connection to %s, reconnecting", t.client.address())
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(tunnelRetryInterval):
+			}
+
+			conn, err := t.client.dialOnce()
+			if err != nil {
+				log.Debugf("Error reconnecting to %s: %s", t.client.address(), err)
+				continue
+			}
+
+			t.setConn(conn)
+			log.Infof("Reconnected to %s", t.client.address())
+			break
+		}
+	}
+}
+
+// watch waits for the connection to be lost, which it notices with keepalive
+// requests, and returns false if stop is closed first.
+func (t *Tunnel) watch(conn *ssh.Client, stop <-chan struct{}) bool {
+	closed := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(tunnelKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			conn.Close()
+			return false
+		case <-closed:
+			return true
+		case <-ticker.C:
+			replied := make(chan error, 1)
+			go func() {
+				_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+				replied <- err
+			}()
+
+			select {
+			case err := <-replied:
+				if err != nil {
+					conn.Close()
+				}
+			case <-time.After(tunnelKeepAliveTimeout):
+				conn.Close()
+			}
+		}
+	}
+}
+
+func (t *Tunnel) serve(l net.Listener, f Forward) {
+	for {
+		local, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go t.forward(local, f)
+	}
+}
+
+// forward copies a local connection to the remote address and back, waiting
+// for the tunnel to reconnect if needed.
+func (t *Tunnel) forward(local net.Conn, f Forward) {
+	defer local.Close()
+
+	var remote net.Conn
+	for attempt := 0; remote == nil; attempt++ {
+		conn := t.connection()
+		if conn == nil {
+			return
+		}
+
+		var err error
+		if remote, err = conn.Dial("tcp", f.RemoteAddress); err != nil {
+			// The machine refusing the forward is final, the connection
+			// breaking before the tunnel noticed isn't.
+			if _, refused := err.(*ssh.OpenChannelError); refused || attempt == tunnelDialAttempts-1 {
+				log.Warnf("Error forwarding %s: %s", f, err)
+				return
+			}
+			t.dropConn(conn)
+		}
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// connection returns the connection to the machine, once there's one, or nil
+// if the tunnel is closed.
+func (t *Tunnel) connection() *ssh.Client {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for t.conn == nil && !t.stopped {
+		t.cond.Wait()
+	}
+	return t.conn
+}
+
+func (t *Tunnel) setConn(conn *ssh.Client) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.conn = conn
+	t.cond.Broadcast()
+}
+
+// dropConn closes a broken connection, unless the tunnel already replaced
+// it, so that the next forwards wait for the tunnel to reconnect.
+func (t *Tunnel) dropConn(conn *ssh.Client) {
+	t.lock.Lock()
+	if t.conn == conn {
+		t.conn = nil
+	}
+	t.lock.Unlock()
+
+	conn.Close()
+}
+
+func (t *Tunnel) close() {
+	t.lock.Lock()
+	t.stopped = true
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	t.cond.Broadcast()
+	t.lock.Unlock()
+
+	t.closeListeners()
+}
+
+func (t *Tunnel) closeListeners() {
+	for _, l := range t.listeners {
+		l.Close()
+	}
+	t.listeners = nil
+}
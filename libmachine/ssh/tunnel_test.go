@@ -0,0 +1,137 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseForward(t *testing.T) {
+	var tests = []struct {
+		forward  string
+		expected Forward
+		valid    bool
+	}{
+		{"8080:80", Forward{"127.0.0.1:8080", "localhost:80"}, true},
+		{"5432:db:5432", Forward{"127.0.0.1:5432", "db:5432"}, true},
+		{"0.0.0.0:8080:localhost:80", Forward{"0.0.0.0:8080", "localhost:80"}, true},
+		{"8080", Forward{}, false},
+		{"8080:http", Forward{}, false},
+		{"0:80", Forward{}, false},
+		{"8080::80", Forward{}, false},
+		{"a:b:c:d:e", Forward{}, false},
+	}
+
+	for _, test := range tests {
+		forward, err := ParseForward(test.forward)
+
+		assert.Equal(t, test.valid, err == nil, test.forward)
+		assert.Equal(t, test.expected, forward, test.forward)
+	}
+}
+
+// newEchoServer returns the address of a server echoing the lines it
+// receives.
+func newEchoServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintln(conn, scanner.Text())
+				}
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func freeLocalAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func echoThrough(address, line string) (string, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	fmt.Fprintln(conn, line)
+	return bufio.NewReader(conn).ReadString('\n')
+}
+
+func TestTunnelForwardsAndReconnects(t *testing.T) {
+	defer func(interval time.Duration) { tunnelRetryInterval = interval }(tunnelRetryInterval)
+	tunnelRetryInterval = 10 * time.Millisecond
+
+	server := newTestServer(t)
+	defer server.listener.Close()
+
+	echo := newEchoServer(t)
+	defer echo.Close()
+
+	forward := Forward{LocalAddress: freeLocalAddress(t), RemoteAddress: echo.Addr().String()}
+	tunnel, err := NewTunnel("docker", "127.0.0.1", server.port(), &Auth{}, nil, []Forward{forward})
+	assert.NoError(t, err)
+	assert.NoError(t, tunnel.Start())
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		tunnel.Run(stop)
+		close(done)
+	}()
+
+	line, err := echoThrough(forward.LocalAddress, "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+
+	server.dropConnections()
+
+	line, err = echoThrough(forward.LocalAddress, "again")
+	assert.NoError(t, err)
+	assert.Equal(t, "again\n", line)
+	assert.Equal(t, 2, server.connCount())
+
+	close(stop)
+	<-done
+
+	_, err = net.Dial("tcp", forward.LocalAddress)
+	assert.Error(t, err)
+}
+
+func TestTunnelStartFailsOnUsedPort(t *testing.T) {
+	server := newTestServer(t)
+	defer server.listener.Close()
+
+	used, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer used.Close()
+
+	forward := Forward{LocalAddress: used.Addr().String(), RemoteAddress: "localhost:80"}
+	tunnel, err := NewTunnel("docker", "127.0.0.1", server.port(), &Auth{}, nil, []Forward{forward})
+	assert.NoError(t, err)
+
+	assert.Error(t, tunnel.Start())
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package ssh
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/docker/docker/pkg/term"
+	"golang.org/x/crypto/ssh"
+)
+
+// watchWindowSize resizes the terminal of the session with the local one,
+// until the returned function is called.
+func watchWindowSize(fd uintptr, session *ssh.Session) func() {
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-resized:
+				if winsize, err := term.GetWinsize(fd); err == nil {
+					windowChange(session, int(winsize.Height), int(winsize.Width))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(resized)
+		close(done)
+	}
+}
@@ -0,0 +1,43 @@
+package ssh
+
+import (
+	"time"
+
+	"github.com/docker/docker/pkg/term"
+	"golang.org/x/crypto/ssh"
+)
+
+// watchWindowSize resizes the terminal of the session with the local one,
+// until the returned function is called.  Windows has no SIGWINCH, so the
+// size of the console is polled.
+func watchWindowSize(fd uintptr, session *ssh.Session) func() {
+	done := make(chan struct{})
+
+	go func() {
+		var width, height uint16
+		if winsize, err := term.GetWinsize(fd); err == nil {
+			width, height = winsize.Width, winsize.Height
+		}
+
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				winsize, err := term.GetWinsize(fd)
+				if err != nil || (winsize.Width == width && winsize.Height == height) {
+					continue
+				}
+				width, height = winsize.Width, winsize.Height
+				windowChange(session, int(height), int(width))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,178 @@
+package sshuser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+)
+
+const (
+	sudoersFile   = "/etc/sudoers.d/90-docker-machine"
+	sshdConfig    = "/etc/ssh/sshd_config"
+	sshdBlockFrom = "# BEGIN docker-machine"
+	sshdBlockTo   = "# END docker-machine"
+)
+
+// The settings come first in sshd_config, before its Include of
+// sshd_config.d, since sshd keeps the first value of each setting.
+var sshdSettings = []string{
+	"PermitRootLogin no",
+	"PasswordAuthentication no",
+	"ChallengeResponseAuthentication no",
+}
+
+var (
+	ErrInvalidUser  = errors.New("Error: the SSH user must be a lowercase user name other than root, e.g. machine")
+	ErrRootLogin    = errors.New("Error: the root login can't be disabled while the machine is accessed as root, create a user with --provision-create-user")
+	ErrNotSupported = errors.New("Error: the SSH user can only be created on machines with useradd and sudo")
+
+	validUser = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+)
+
+type Options struct {
+	// User is created with the SSH keys of the user of the image and
+	// passwordless sudo, and the machine is accessed as this user from then
+	// on.
+	User string `json:",omitempty"`
+
+	// DisableRoot disables the root login and the password authentication
+	// of sshd.
+	DisableRoot bool `json:",omitempty"`
+}
+
+// IsEnabled returns true if the SSH access of the machine is configured.
+func (o *Options) IsEnabled() bool {
+	return o != nil && (o.User != "" || o.DisableRoot)
+}
+
+// Validate checks the options given on the command line.
+func (o *Options) Validate() error {
+	if o.User != "" && (o.User == "root" || !validUser.MatchString(o.User)) {
+		return ErrInvalidUser
+	}
+
+	return nil
+}
+
+// Configure creates the SSH user of the machine and switches the driver to
+// it, then disables the root login.  The new user is checked before sshd is
+// reconfigured, so that the machine is never left unreachable.
+func Configure(p provision.Provisioner, options *Options) error {
+	if !options.IsEnabled() {
+		return nil
+	}
+
+	d := p.GetDriver()
+
+	if options.User != "" && options.User != d.GetSSHUsername() {
+		if err := createUser(p, options.User); err != nil {
+			return err
+		}
+
+		previousUser := d.GetSSHUsername()
+		if err := setSSHUser(d, options.User); err != nil {
+			return err
+		}
+
+		// The check runs on a new connection, since the provisioner may
+		// reuse one still logged in as the previous user
+		if output, err := drivers.RunSSHCommandFromDriver(d, "sudo -n true"); err != nil {
+			setSSHUser(d, previousUser)
+			return fmt.Errorf("Error connecting to the machine as %s: %s\n%s", options.User, err, output)
+		}
+	}
+
+	if !options.DisableRoot {
+		return nil
+	}
+
+	if d.GetSSHUsername() == "root" {
+		return ErrRootLogin
+	}
+
+	return disableRootLogin(p)
+}
+
+// createUser creates a user allowed to run sudo without a password, with
+// the authorized keys of the current user and no password.  Provisioning
+// runs arbitrary commands as root, such as the install scripts, so sudo
+// isn't restricted to a list of commands.
+func createUser(p provision.Provisioner, user string) error {
+	if _, err := p.SSHCommand("command -v useradd && command -v sudo"); err != nil {
+		return ErrNotSupported
+	}
+
+	log.Infof("Creating the SSH user %s...", user)
+
+	commands := []string{
+		fmt.Sprintf("id -u %[1]s >/dev/null 2>&1 || sudo useradd -m -s \"$(command -v bash || echo /bin/sh)\" %[1]s", user),
+		fmt.Sprintf("sudo usermod -p '*' %s", user),
+		fmt.Sprintf("if getent group docker >/dev/null; then sudo usermod -aG docker %s; fi", user),
+		fmt.Sprintf("home=$(getent passwd %[1]s | cut -d: -f6) && sudo install -d -m 700 \"$home/.ssh\" && sudo cat \"$HOME/.ssh/authorized_keys\" | sudo tee \"$home/.ssh/authorized_keys\" >/dev/null && sudo chmod 600 \"$home/.ssh/authorized_keys\" && sudo chown -R %[1]s: \"$home/.ssh\"", user),
+		fmt.Sprintf("printf '%%s\\n' '%[1]s ALL=(ALL) NOPASSWD:ALL' 'Defaults:%[1]s !requiretty' | sudo tee %[2]s.tmp >/dev/null && sudo chmod 440 %[2]s.tmp && sudo visudo -cf %[2]s.tmp && sudo mv %[2]s.tmp %[2]s", user, sudoersFile),
+	}
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error creating the SSH user %s: %s\n%s", user, err, output)
+		}
+	}
+
+	return nil
+}
+
+// setSSHUser changes the SSH user of the driver, which is saved with the
+// machine.  The driver may run in a plugin, its configuration is updated
+// through its JSON representation like when it's loaded.
+func setSSHUser(d drivers.Driver, user string) error {
+	rawDriver, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("Error reading the driver configuration: %s", err)
+	}
+
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(rawDriver, &config); err != nil {
+		return fmt.Errorf("Error reading the driver configuration: %s", err)
+	}
+
+	config["SSHUser"] = user
+
+	if rawDriver, err = json.Marshal(config); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(rawDriver, d); err != nil {
+		return fmt.Errorf("Error updating the driver configuration: %s", err)
+	}
+
+	return nil
+}
+
+// disableRootLogin prepends the settings to sshd_config, replacing the ones
+// of a previous provisioning, checks the new configuration and reloads sshd.
+// The open connections are kept by the reload.
+func disableRootLogin(p provision.Provisioner) error {
+	log.Info("Disabling the root login and the password authentication over SSH...")
+
+	block := "'" + sshdBlockFrom + "'"
+	for _, setting := range sshdSettings {
+		block += " '" + setting + "'"
+	}
+	block += " '" + sshdBlockTo + "'"
+
+	commands := []string{
+		fmt.Sprintf("{ printf '%%s\\n' %[1]s; sed '/^%[2]s$/,/^%[3]s$/d' %[4]s; } | sudo tee %[4]s.machine >/dev/null && sudo sshd -t -f %[4]s.machine && sudo cp %[4]s.machine %[4]s && sudo rm -f %[4]s.machine", block, sshdBlockFrom, sshdBlockTo, sshdConfig),
+		"sudo systemctl reload sshd 2>/dev/null || sudo systemctl reload ssh 2>/dev/null || sudo service ssh reload",
+	}
+	for _, command := range commands {
+		if output, err := p.SSHCommand(command); err != nil {
+			return fmt.Errorf("Error configuring sshd: %s\n%s", err, output)
+		}
+	}
+
+	return nil
+}
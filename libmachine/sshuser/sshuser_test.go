@@ -0,0 +1,259 @@
+package sshuser
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/stretchr/testify/assert"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// sshDriver is a fake driver with the SSH settings of the base driver.
+type sshDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *sshDriver) GetSSHHostname() (string, error) {
+	return "127.0.0.1", nil
+}
+
+func (d *sshDriver) GetSSHKeyPath() string {
+	return d.BaseDriver.GetSSHKeyPath()
+}
+
+func (d *sshDriver) GetSSHPort() (int, error) {
+	return d.BaseDriver.GetSSHPort()
+}
+
+func (d *sshDriver) GetSSHUsername() string {
+	return d.BaseDriver.GetSSHUsername()
+}
+
+// testServer is an SSH server accepting any key, recording the commands it
+// runs with the user running them, which fail when they start with one of
+// the failing prefixes, e.g. "machine: sudo".
+type testServer struct {
+	listener net.Listener
+	config   *cryptossh.ServerConfig
+	failing  []string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newTestServer(t *testing.T, failing ...string) *testServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := cryptossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &cryptossh.ServerConfig{
+		PublicKeyCallback: func(cryptossh.ConnMetadata, cryptossh.PublicKey) (*cryptossh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &testServer{
+		listener: listener,
+		config:   config,
+		failing:  failing,
+	}
+	go server.serve()
+
+	return server
+}
+
+func (s *testServer) serve() {
+	for {
+		tcpConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		conn, channels, requests, err := cryptossh.NewServerConn(tcpConn, s.config)
+		if err != nil {
+			continue
+		}
+
+		go cryptossh.DiscardRequests(requests)
+		go s.handleChannels(conn.User(), channels)
+	}
+}
+
+func (s *testServer) handleChannels(user string, channels <-chan cryptossh.NewChannel) {
+	for newChannel := range channels {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+
+				req.Reply(true, nil)
+				channel.SendRequest("exit-status", false, []byte{0, 0, 0, s.run(user + ": " + string(req.Payload[4:]))})
+				channel.Close()
+			}
+		}()
+	}
+}
+
+// run records a command and returns its exit status.
+func (s *testServer) run(command string) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = append(s.commands, command)
+	for _, prefix := range s.failing {
+		if strings.HasPrefix(command, prefix) {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (s *testServer) ran() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.commands...)
+}
+
+// testProvisioner runs the commands with the SSH commander of the
+// provisioners, which reuses its client.
+type testProvisioner struct {
+	provision.FakeProvisioner
+	commander *provision.GenericSSHCommander
+}
+
+func (p *testProvisioner) GetDriver() drivers.Driver {
+	return p.commander.Driver
+}
+
+func (p *testProvisioner) SSHCommand(args string) (string, error) {
+	return p.commander.SSHCommand(args)
+}
+
+func newTestProvisioner(t *testing.T, user string, failing ...string) (*testProvisioner, *testServer) {
+	ssh.SetDefaultClient(ssh.Native)
+
+	dir, err := ioutil.TempDir("", "sshuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_rsa")
+	if err := ssh.GenerateSSHKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t, failing...)
+	d := &sshDriver{&fakedriver.Driver{BaseDriver: &drivers.BaseDriver{
+		SSHUser:    user,
+		SSHPort:    server.listener.Addr().(*net.TCPAddr).Port,
+		SSHKeyPath: keyPath,
+	}}}
+
+	return &testProvisioner{commander: &provision.GenericSSHCommander{Driver: d}}, server
+}
+
+func (s *testServer) close(p *testProvisioner) {
+	s.listener.Close()
+	os.RemoveAll(filepath.Dir(p.GetDriver().GetSSHKeyPath()))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Options{User: "machine"}).Validate())
+	assert.NoError(t, (&Options{DisableRoot: true}).Validate())
+	assert.Equal(t, ErrInvalidUser, (&Options{User: "root"}).Validate())
+	assert.Equal(t, ErrInvalidUser, (&Options{User: "Machine"}).Validate())
+	assert.Equal(t, ErrInvalidUser, (&Options{User: "machine; rm -rf /"}).Validate())
+}
+
+func TestConfigure(t *testing.T) {
+	defer ssh.CloseConnections()
+
+	p, server := newTestProvisioner(t, "root")
+	defer server.close(p)
+
+	assert.NoError(t, Configure(p, &Options{User: "machine", DisableRoot: true}))
+	assert.Equal(t, "machine", p.GetDriver().GetSSHUsername())
+
+	commands := server.ran()
+	assert.Equal(t, "root: command -v useradd && command -v sudo", commands[0])
+	assert.Contains(t, commands, `root: id -u machine >/dev/null 2>&1 || sudo useradd -m -s "$(command -v bash || echo /bin/sh)" machine`)
+	assert.Contains(t, commands, "root: printf '%s\\n' 'machine ALL=(ALL) NOPASSWD:ALL' 'Defaults:machine !requiretty' | sudo tee /etc/sudoers.d/90-docker-machine.tmp >/dev/null && sudo chmod 440 /etc/sudoers.d/90-docker-machine.tmp && sudo visudo -cf /etc/sudoers.d/90-docker-machine.tmp && sudo mv /etc/sudoers.d/90-docker-machine.tmp /etc/sudoers.d/90-docker-machine")
+
+	last := commands[len(commands)-3:]
+	assert.Equal(t, "machine: sudo -n true", last[0])
+	assert.Equal(t, "machine: { printf '%s\\n' '# BEGIN docker-machine' 'PermitRootLogin no' 'PasswordAuthentication no' 'ChallengeResponseAuthentication no' '# END docker-machine'; sed '/^# BEGIN docker-machine$/,/^# END docker-machine$/d' /etc/ssh/sshd_config; } | sudo tee /etc/ssh/sshd_config.machine >/dev/null && sudo sshd -t -f /etc/ssh/sshd_config.machine && sudo cp /etc/ssh/sshd_config.machine /etc/ssh/sshd_config && sudo rm -f /etc/ssh/sshd_config.machine", last[1])
+	assert.Equal(t, "machine: sudo systemctl reload sshd 2>/dev/null || sudo systemctl reload ssh 2>/dev/null || sudo service ssh reload", last[2])
+}
+
+func TestConfigureRevertsUserWithoutSudo(t *testing.T) {
+	defer ssh.CloseConnections()
+
+	p, server := newTestProvisioner(t, "root", "machine: sudo -n true")
+	defer server.close(p)
+
+	err := Configure(p, &Options{User: "machine", DisableRoot: true})
+
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "Error connecting to the machine as machine: "))
+	assert.Equal(t, "root", p.GetDriver().GetSSHUsername())
+	assert.Contains(t, server.ran(), "machine: sudo -n true")
+	for _, command := range server.ran() {
+		assert.NotContains(t, command, "sshd")
+	}
+}
+
+func TestConfigureDisableRootOnly(t *testing.T) {
+	defer ssh.CloseConnections()
+
+	p, server := newTestProvisioner(t, "root")
+	defer server.close(p)
+
+	assert.Equal(t, ErrRootLogin, Configure(p, &Options{DisableRoot: true}))
+	assert.Empty(t, server.ran())
+
+	p, server = newTestProvisioner(t, "ubuntu")
+	defer server.close(p)
+
+	assert.NoError(t, Configure(p, &Options{DisableRoot: true}))
+	assert.Len(t, server.ran(), 2)
+}
+
+func TestConfigureNotSupported(t *testing.T) {
+	defer ssh.CloseConnections()
+
+	p, server := newTestProvisioner(t, "root", "root: command -v useradd")
+	defer server.close(p)
+
+	assert.Equal(t, ErrNotSupported, Configure(p, &Options{User: "machine"}))
+	assert.Equal(t, "root", p.GetDriver().GetSSHUsername())
+}
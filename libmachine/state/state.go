@@ -1,5 +1,10 @@
 package state
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // State represents the state of a host
 type State int
 
@@ -34,3 +39,37 @@ func (s State) String() string {
 	}
 	return ""
 }
+
+// MarshalJSON encodes the state as its string representation so that it is
+// readable in JSON output.  This is deliberately not a TextMarshaler, which
+// would change how states are sent to driver plugins over RPC.
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a state from its string representation, or from
+// the number the states were encoded as before, e.g. by older plugins or in
+// older configurations.
+func (s *State) UnmarshalJSON(data []byte) error {
+	var number int
+	if err := json.Unmarshal(data, &number); err == nil {
+		if number < 0 || number >= len(states) {
+			return fmt.Errorf("Unknown state: %d", number)
+		}
+		*s = State(number)
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	for i, stateName := range states {
+		if stateName == name {
+			*s = State(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown state: %q", name)
+}
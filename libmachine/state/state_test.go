@@ -1,6 +1,7 @@
 package state
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -15,3 +16,39 @@ func TestDaemonCreate(t *testing.T) {
 		t.Fatal("Error state should be 'Error'")
 	}
 }
+
+func TestStateJSON(t *testing.T) {
+	b, err := json.Marshal(map[string]State{"State": Stopped})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"State":"Stopped"}` {
+		t.Fatalf("unexpected JSON for a state: %s", b)
+	}
+
+	var decoded map[string]State
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["State"] != Stopped {
+		t.Fatalf("expected Stopped, got %s", decoded["State"])
+	}
+
+	if err := json.Unmarshal([]byte(`{"State":"Dancing"}`), &decoded); err == nil {
+		t.Fatal("expected an error decoding an unknown state")
+	}
+}
+
+func TestStateJSONNumber(t *testing.T) {
+	var decoded map[string]State
+	if err := json.Unmarshal([]byte(`{"State":1}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["State"] != Running {
+		t.Fatalf("expected Running, got %s", decoded["State"])
+	}
+
+	if err := json.Unmarshal([]byte(`{"State":42}`), &decoded); err == nil {
+		t.Fatal("expected an error decoding an unknown state")
+	}
+}
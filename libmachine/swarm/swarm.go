@@ -4,6 +4,10 @@ const (
 	DiscoveryServiceEndpoint = "https://discovery-stage.hub.docker.com/v1"
 )
 
+// Options configure the Swarm containers of a machine.
+//
+// Deprecated: the clusters are created in swarm mode with the options of the
+// swarmmode package.
 type Options struct {
 	IsSwarm            bool
 	Address            string
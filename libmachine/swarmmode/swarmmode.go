@@ -0,0 +1,252 @@
+// Package swarmmode makes the engines of the machines the nodes of a cluster
+// in swarm mode, with docker swarm init and join run over the TLS API of
+// their engine.  It replaces the swarm containers of the swarm package.
+package swarmmode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+)
+
+const (
+	// DefaultPort is the port of the managers joined by the nodes.
+	DefaultPort = 2377
+
+	// apiVersion is the first version of the engine API with swarm mode.
+	apiVersion = "v1.24"
+)
+
+var (
+	ErrManagerAndWorker = errors.New("Error: --swarm-mode-manager and --swarm-mode-worker are mutually exclusive")
+	ErrNoJoin           = errors.New("Error: --swarm-mode-worker requires the manager to join with --swarm-mode-join")
+	ErrNoJoinToken      = errors.New("Error: No join token for the swarm, the manager must be a swarm mode manager created with Machine")
+
+	// newClient returns the HTTP client of the API of an engine and its
+	// base URL, replaced by the tests.
+	newClient = engineClient
+)
+
+type Options struct {
+	Manager bool
+	Worker  bool
+
+	// Join is the name of the manager machine whose swarm is joined.
+	Join string
+
+	// RemoteAddr and JoinToken are the address of the manager joined and
+	// the token of the role of the node, copied from the manager.
+	RemoteAddr string
+	JoinToken  string
+
+	// Addr is the address advertised by a manager, and WorkerToken and
+	// ManagerToken join the nodes to its swarm.  They're retrieved once
+	// the swarm is initialized or joined.
+	Addr         string
+	WorkerToken  string
+	ManagerToken string
+}
+
+// IsSwarmMode returns true if the machine is a node of a swarm.
+func (o *Options) IsSwarmMode() bool {
+	return o != nil && (o.Manager || o.Worker)
+}
+
+// Validate checks the options given on the command line.
+func (o *Options) Validate() error {
+	if o.Manager && o.Worker {
+		return ErrManagerAndWorker
+	}
+
+	if o.Worker && o.Join == "" {
+		return ErrNoJoin
+	}
+
+	return nil
+}
+
+// SetJoin copies from the options of the manager joined its address and the
+// token of the role of the node.
+func (o *Options) SetJoin(manager *Options) error {
+	if !manager.IsSwarmMode() || !manager.Manager || manager.Addr == "" {
+		return ErrNoJoinToken
+	}
+
+	token := manager.WorkerToken
+	if o.Manager {
+		token = manager.ManagerToken
+	}
+	if token == "" {
+		return ErrNoJoinToken
+	}
+
+	o.RemoteAddr = manager.Addr
+	o.JoinToken = token
+
+	return nil
+}
+
+// Addr returns the address of a node given its IP address.
+func Addr(ip string) string {
+	return net.JoinHostPort(ip, fmt.Sprint(DefaultPort))
+}
+
+type joinTokens struct {
+	Worker  string
+	Manager string
+}
+
+type swarmInfo struct {
+	JoinTokens joinTokens
+}
+
+type engineInfo struct {
+	Swarm struct {
+		LocalNodeState string
+	}
+}
+
+type initRequest struct {
+	ListenAddr    string
+	AdvertiseAddr string
+}
+
+type joinRequest struct {
+	ListenAddr    string
+	AdvertiseAddr string
+	RemoteAddrs   []string
+	JoinToken     string
+}
+
+// Configure initializes a swarm on the engine of a manager, or joins the
+// swarm of the manager given in the options, unless the engine already is a
+// node.  The managers record their address and the join tokens of their
+// swarm in the options.
+func Configure(dockerHost mcndockerclient.DockerHost, ip string, options *Options) error {
+	if !options.IsSwarmMode() {
+		return nil
+	}
+
+	var info engineInfo
+	if err := request(dockerHost, "GET", "/info", nil, &info); err != nil {
+		return err
+	}
+
+	switch {
+	case info.Swarm.LocalNodeState == "active":
+		log.Debug("the engine already is a node of a swarm")
+	case options.JoinToken != "":
+		log.Infof("Joining the swarm of %s...", options.Join)
+		join := joinRequest{
+			ListenAddr:    Addr("0.0.0.0"),
+			AdvertiseAddr: Addr(ip),
+			RemoteAddrs:   []string{options.RemoteAddr},
+			JoinToken:     options.JoinToken,
+		}
+		if err := request(dockerHost, "POST", "/swarm/join", join, nil); err != nil {
+			return fmt.Errorf("Error joining the swarm: %s", err)
+		}
+	case options.Manager:
+		log.Info("Initializing the swarm...")
+		init := initRequest{
+			ListenAddr:    Addr("0.0.0.0"),
+			AdvertiseAddr: Addr(ip),
+		}
+		if err := request(dockerHost, "POST", "/swarm/init", init, nil); err != nil {
+			return fmt.Errorf("Error initializing the swarm: %s", err)
+		}
+	default:
+		return ErrNoJoinToken
+	}
+
+	if !options.Manager {
+		return nil
+	}
+
+	var swarm swarmInfo
+	if err := request(dockerHost, "GET", "/swarm", nil, &swarm); err != nil {
+		return fmt.Errorf("Error retrieving the join tokens of the swarm: %s", err)
+	}
+
+	options.Addr = Addr(ip)
+	options.WorkerToken = swarm.JoinTokens.Worker
+	options.ManagerToken = swarm.JoinTokens.Manager
+
+	return nil
+}
+
+func engineClient(dockerHost mcndockerclient.DockerHost) (*http.Client, string, error) {
+	url, err := dockerHost.URL()
+	if err != nil {
+		return nil, "", err
+	}
+
+	tlsConfig, err := cert.ReadTLSConfig(url, dockerHost.AuthOptions())
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to read TLS config: %s", err)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	return client, strings.Replace(url, "tcp://", "https://", 1), nil
+}
+
+// request calls the API of an engine, with in as the JSON body and the JSON
+// response decoded in out.
+func request(dockerHost mcndockerclient.DockerHost, method, path string, in, out interface{}) error {
+	client, baseURL, err := newClient(dockerHost)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/%s%s", baseURL, apiVersion, path), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return errors.New(apiErr.Message)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,115 @@
+package swarmmode
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEngine serves the swarm endpoints of the engine API, recording the
+// requests to init and join.
+type fakeEngine struct {
+	nodeState string
+	init      *initRequest
+	join      *joinRequest
+}
+
+func (e *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v1.24/info":
+		json.NewEncoder(w).Encode(map[string]interface{}{"Swarm": map[string]string{"LocalNodeState": e.nodeState}})
+	case "GET /v1.24/swarm":
+		json.NewEncoder(w).Encode(swarmInfo{JoinTokens: joinTokens{Worker: "SWMTKN-worker", Manager: "SWMTKN-manager"}})
+	case "POST /v1.24/swarm/init":
+		e.init = &initRequest{}
+		json.NewDecoder(r.Body).Decode(e.init)
+		e.nodeState = "active"
+	case "POST /v1.24/swarm/join":
+		if e.nodeState == "active" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"This node is already part of a swarm."}`))
+			return
+		}
+		e.join = &joinRequest{}
+		json.NewDecoder(r.Body).Decode(e.join)
+		e.nodeState = "active"
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func withFakeEngine(t *testing.T, engine *fakeEngine) func() {
+	server := httptest.NewServer(engine)
+	newClient = func(dockerHost mcndockerclient.DockerHost) (*http.Client, string, error) {
+		return server.Client(), server.URL, nil
+	}
+
+	return func() {
+		server.Close()
+		newClient = engineClient
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Options{Manager: true}).Validate())
+	assert.NoError(t, (&Options{Worker: true, Join: "manager"}).Validate())
+	assert.Equal(t, ErrManagerAndWorker, (&Options{Manager: true, Worker: true}).Validate())
+	assert.Equal(t, ErrNoJoin, (&Options{Worker: true}).Validate())
+}
+
+func TestConfigureManager(t *testing.T) {
+	engine := &fakeEngine{nodeState: "inactive"}
+	defer withFakeEngine(t, engine)()
+
+	options := &Options{Manager: true}
+
+	assert.NoError(t, Configure(&mcndockerclient.RemoteDocker{}, "10.0.0.1", options))
+
+	assert.Equal(t, &initRequest{ListenAddr: "0.0.0.0:2377", AdvertiseAddr: "10.0.0.1:2377"}, engine.init)
+	assert.Equal(t, "10.0.0.1:2377", options.Addr)
+	assert.Equal(t, "SWMTKN-worker", options.WorkerToken)
+	assert.Equal(t, "SWMTKN-manager", options.ManagerToken)
+}
+
+func TestConfigureWorker(t *testing.T) {
+	engine := &fakeEngine{nodeState: "inactive"}
+	defer withFakeEngine(t, engine)()
+
+	options := &Options{Worker: true, Join: "manager", RemoteAddr: "10.0.0.1:2377", JoinToken: "SWMTKN-worker"}
+
+	assert.NoError(t, Configure(&mcndockerclient.RemoteDocker{}, "10.0.0.2", options))
+
+	assert.Equal(t, []string{"10.0.0.1:2377"}, engine.join.RemoteAddrs)
+	assert.Equal(t, "SWMTKN-worker", engine.join.JoinToken)
+	assert.Equal(t, "10.0.0.2:2377", engine.join.AdvertiseAddr)
+	assert.Empty(t, options.WorkerToken)
+}
+
+func TestConfigureAlreadyActive(t *testing.T) {
+	engine := &fakeEngine{nodeState: "active"}
+	defer withFakeEngine(t, engine)()
+
+	options := &Options{Worker: true, Join: "manager", RemoteAddr: "10.0.0.1:2377", JoinToken: "SWMTKN-worker"}
+
+	assert.NoError(t, Configure(&mcndockerclient.RemoteDocker{}, "10.0.0.2", options))
+	assert.Nil(t, engine.join)
+}
+
+func TestConfigureWithoutSwarmMode(t *testing.T) {
+	assert.NoError(t, Configure(&mcndockerclient.RemoteDocker{}, "10.0.0.1", nil))
+}
+
+func TestSetJoin(t *testing.T) {
+	manager := &Options{Manager: true, Addr: "10.0.0.1:2377", WorkerToken: "SWMTKN-worker", ManagerToken: "SWMTKN-manager"}
+
+	worker := &Options{Worker: true}
+	assert.NoError(t, worker.SetJoin(manager))
+	assert.Equal(t, "SWMTKN-worker", worker.JoinToken)
+
+	assert.Equal(t, ErrNoJoinToken, worker.SetJoin(nil))
+	assert.Equal(t, ErrNoJoinToken, worker.SetJoin(&Options{Worker: true, Addr: "10.0.0.3:2377"}))
+}
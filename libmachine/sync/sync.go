@@ -0,0 +1,206 @@
+// Package sync keeps a local directory and a directory of a machine in sync
+// with rsync over SSH.
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const (
+	// DefaultLocalInterval is the delay between two scans of the local
+	// directory.
+	DefaultLocalInterval = 500 * time.Millisecond
+
+	// DefaultRemoteInterval is the delay between two pulls of the directory
+	// of the machine, whose changes can't be watched.
+	DefaultRemoteInterval = 5 * time.Second
+)
+
+var errNoRsync = errors.New("Error: You must have a copy of the rsync binary locally to use the sync mode.")
+
+// Remote is a directory of a machine reached over SSH.
+type Remote struct {
+	User      string
+	Host      string
+	Port      int
+	KeyPath   string
+	ProxyJump string
+	Path      string
+}
+
+// SSHOptions returns the options of the SSH client connecting to the
+// machine, in the form of ssh -o.
+func (r *Remote) SSHOptions() []string {
+	options := []string{
+		"IdentitiesOnly=yes",
+		"StrictHostKeyChecking=no",
+		"UserKnownHostsFile=/dev/null",
+		"LogLevel=quiet",
+		fmt.Sprintf("Port=%d", r.Port),
+	}
+
+	if r.KeyPath != "" {
+		options = append(options, "IdentityFile="+r.KeyPath)
+	}
+
+	if r.ProxyJump != "" {
+		options = append(options, "ProxyJump="+r.ProxyJump)
+	}
+
+	return options
+}
+
+// Location returns the directory in the form of user@host:path.
+func (r *Remote) Location() string {
+	return fmt.Sprintf("%s@%s:%s", r.User, mcnutils.BracketHost(r.Host), r.Path)
+}
+
+// Runner runs rsync with the given arguments.
+type Runner interface {
+	Run(args []string) error
+}
+
+type rsyncRunner struct {
+	path string
+}
+
+func (r *rsyncRunner) Run(args []string) error {
+	cmd := exec.Command(r.path, args...)
+	cmd.Stderr = os.Stderr
+
+	log.Debug(*cmd)
+	return cmd.Run()
+}
+
+// Syncer syncs a local directory and a directory of a machine both ways.  The
+// local directory is scanned for changes, which are pushed as soon as they are
+// found, and the changes of the machine are pulled periodically.  A file
+// changed on both sides is kept in its most recent version.  Removed files
+// aren't synced, to never lose a file changed on the other side.
+type Syncer struct {
+	Local          string
+	Remote         Remote
+	Runner         Runner
+	LocalInterval  time.Duration
+	RemoteInterval time.Duration
+}
+
+func NewSyncer(local string, remote Remote) (*Syncer, error) {
+	path, err := exec.LookPath("rsync")
+	if err != nil {
+		return nil, errNoRsync
+	}
+
+	return &Syncer{
+		Local:          local,
+		Remote:         remote,
+		Runner:         &rsyncRunner{path: path},
+		LocalInterval:  DefaultLocalInterval,
+		RemoteInterval: DefaultRemoteInterval,
+	}, nil
+}
+
+// sshCommand returns the SSH command rsync connects to the machine with.
+func (s *Syncer) sshCommand() string {
+	command := []string{"ssh"}
+	for _, option := range s.Remote.SSHOptions() {
+		command = append(command, "-o", quoteArg(option))
+	}
+
+	return strings.Join(command, " ")
+}
+
+// quoteArg quotes an argument of the SSH command for rsync, which splits it
+// on spaces.
+func quoteArg(arg string) string {
+	if !strings.ContainsAny(arg, " \t'") {
+		return arg
+	}
+
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+func (s *Syncer) rsyncArgs(src, dest string) []string {
+	return []string{"--archive", "--compress", "--update", "-e", s.sshCommand(), src, dest}
+}
+
+// Push copies the files of the local directory which are newer than the ones
+// of the machine.
+func (s *Syncer) Push() error {
+	args := s.rsyncArgs(strings.TrimSuffix(s.Local, "/")+"/", s.Remote.Location())
+	// The directory of the machine is created on the first push.
+	args = append([]string{"--rsync-path", fmt.Sprintf("mkdir -p %s && rsync", quoteArg(s.Remote.Path))}, args...)
+
+	return s.Runner.Run(args)
+}
+
+// Pull copies the files of the machine which are newer than the ones of the
+// local directory.
+func (s *Syncer) Pull() error {
+	return s.Runner.Run(s.rsyncArgs(strings.TrimSuffix(s.Remote.Location(), "/")+"/", s.Local))
+}
+
+// Run syncs the directories both ways, then keeps them in sync until stop is
+// closed.
+func (s *Syncer) Run(stop <-chan struct{}) error {
+	if err := os.MkdirAll(s.Local, 0755); err != nil {
+		return err
+	}
+
+	log.Infof("Syncing %s with %s...", s.Local, s.Remote.Location())
+	if err := s.Push(); err != nil {
+		return fmt.Errorf("Error pushing %s: %s", s.Local, err)
+	}
+	if err := s.Pull(); err != nil {
+		return fmt.Errorf("Error pulling %s: %s", s.Remote.Location(), err)
+	}
+
+	watcher, err := NewWatcher(s.Local)
+	if err != nil {
+		return err
+	}
+
+	localTicker := time.NewTicker(s.LocalInterval)
+	defer localTicker.Stop()
+
+	remoteTicker := time.NewTicker(s.RemoteInterval)
+	defer remoteTicker.Stop()
+
+	log.Info("The directories are in sync, watching for changes...")
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-localTicker.C:
+			changed, err := watcher.Changed()
+			if err != nil {
+				return err
+			}
+
+			if changed {
+				log.Debugf("pushing the changes of %s", s.Local)
+				if err := s.Push(); err != nil {
+					log.Warnf("Error pushing %s: %s", s.Local, err)
+				}
+			}
+		case <-remoteTicker.C:
+			if err := s.Pull(); err != nil {
+				log.Warnf("Error pulling %s: %s", s.Remote.Location(), err)
+				continue
+			}
+
+			// The pulled files aren't local changes to push back.
+			if _, err := watcher.Changed(); err != nil {
+				return err
+			}
+		}
+	}
+}
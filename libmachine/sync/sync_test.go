@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingRunner struct {
+	runs [][]string
+}
+
+func (r *recordingRunner) Run(args []string) error {
+	r.runs = append(r.runs, args)
+	return nil
+}
+
+func newTestSyncer(local string) (*Syncer, *recordingRunner) {
+	runner := &recordingRunner{}
+
+	return &Syncer{
+		Local: local,
+		Remote: Remote{
+			User:    "docker",
+			Host:    "1.2.3.4",
+			Port:    22,
+			KeyPath: "/store/machines/dev/id_rsa",
+			Path:    "/home/docker/src",
+		},
+		Runner:         runner,
+		LocalInterval:  10 * time.Millisecond,
+		RemoteInterval: time.Hour,
+	}, runner
+}
+
+func TestSSHOptions(t *testing.T) {
+	remote := &Remote{User: "docker", Host: "1.2.3.4", Port: 2222, KeyPath: "/key", ProxyJump: "admin@bastion:22"}
+
+	assert.Equal(t, []string{
+		"IdentitiesOnly=yes",
+		"StrictHostKeyChecking=no",
+		"UserKnownHostsFile=/dev/null",
+		"LogLevel=quiet",
+		"Port=2222",
+		"IdentityFile=/key",
+		"ProxyJump=admin@bastion:22",
+	}, remote.SSHOptions())
+}
+
+func TestPushAndPull(t *testing.T) {
+	syncer, runner := newTestSyncer("/src/")
+	syncer.Remote.KeyPath = "/Users/John Doe/id_rsa"
+
+	assert.NoError(t, syncer.Push())
+	assert.NoError(t, syncer.Pull())
+
+	sshCommand := "ssh -o IdentitiesOnly=yes -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o LogLevel=quiet -o Port=22 -o 'IdentityFile=/Users/John Doe/id_rsa'"
+	assert.Equal(t, [][]string{
+		{"--rsync-path", "mkdir -p /home/docker/src && rsync", "--archive", "--compress", "--update", "-e", sshCommand, "/src/", "docker@1.2.3.4:/home/docker/src"},
+		{"--archive", "--compress", "--update", "-e", sshCommand, "docker@1.2.3.4:/home/docker/src/", "/src/"},
+	}, runner.runs)
+}
+
+func TestRunPushesLocalChanges(t *testing.T) {
+	local, err := ioutil.TempDir("", "machine-sync-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(local)
+
+	syncer, runner := newTestSyncer(local)
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- syncer.Run(stop)
+	}()
+
+	// The initial push and pull.
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(local, "main.go"), []byte("package main"), 0644))
+	time.Sleep(100 * time.Millisecond)
+
+	close(stop)
+	assert.NoError(t, <-done)
+
+	assert.Len(t, runner.runs, 3)
+	assert.Equal(t, "--rsync-path", runner.runs[2][0])
+}
+
+func TestWatcherChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-sync-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "main.go")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("package main"), 0644))
+
+	watcher, err := NewWatcher(dir)
+	assert.NoError(t, err)
+
+	changed, err := watcher.Changed()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("package main\n"), 0644))
+	changed, err = watcher.Changed()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	assert.NoError(t, os.Remove(path))
+	changed, err = watcher.Changed()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	changed, err = watcher.Changed()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
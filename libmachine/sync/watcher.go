@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Watcher detects the changes of a directory tree by comparing the size, the
+// mode and the modification time of its files between two scans.  Polling is
+// used rather than the file system events, which differ between the
+// platforms.
+type Watcher struct {
+	dir   string
+	files map[string]fileInfo
+}
+
+type fileInfo struct {
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i fileInfo) equal(other fileInfo) bool {
+	return i.size == other.size && i.mode == other.mode && i.modTime.Equal(other.modTime)
+}
+
+// NewWatcher scans the directory tree, the changes are detected from there.
+func NewWatcher(dir string) (*Watcher, error) {
+	w := &Watcher{dir: dir}
+
+	files, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.files = files
+
+	return w, nil
+}
+
+// Changed scans the directory tree again and returns true if a file was
+// created, modified or removed since the previous scan.
+func (w *Watcher) Changed() (bool, error) {
+	files, err := w.scan()
+	if err != nil {
+		return false, err
+	}
+
+	changed := len(files) != len(w.files)
+	if !changed {
+		for path, info := range files {
+			if previous, ok := w.files[path]; !ok || !previous.equal(info) {
+				changed = true
+				break
+			}
+		}
+	}
+
+	w.files = files
+	return changed, nil
+}
+
+func (w *Watcher) scan() (map[string]fileInfo, error) {
+	files := map[string]fileInfo{}
+
+	err := filepath.Walk(w.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files removed during the scan are picked up by the next one.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		files[path] = fileInfo{
+			size:    info.Size(),
+			mode:    info.Mode(),
+			modTime: info.ModTime(),
+		}
+		return nil
+	})
+
+	return files, err
+}
@@ -0,0 +1,108 @@
+package libmachine
+
+import (
+	"time"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	// MinWatchInterval is the interval between two polls of the state of a
+	// watched machine while it's changing, e.g. starting.
+	MinWatchInterval = time.Second
+
+	// MaxWatchInterval is the interval the polls back off to while the
+	// state of a watched machine doesn't change.
+	MaxWatchInterval = 30 * time.Second
+)
+
+// StateChange is a transition of the state of a watched machine.  The first
+// change of a watch has no Previous state, it gives the state the machine
+// was in when the watch started.
+type StateChange struct {
+	Machine  string
+	Previous state.State
+	State    state.State
+	Error    string `json:",omitempty"`
+	Time     time.Time
+}
+
+// Watch returns the state changes of a machine, until stop is closed.
+func (api *Client) Watch(name string, stop <-chan struct{}) (<-chan StateChange, error) {
+	h, err := api.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return WatchHost(h, MinWatchInterval, MaxWatchInterval, stop), nil
+}
+
+// WatchHost polls the state of a machine and sends its changes on the
+// returned channel, which is closed once stop is closed.  The machine is
+// polled every min interval while its state is changing or transitional,
+// and the interval doubles, up to max, while it stays the same.
+func WatchHost(h *host.Host, min, max time.Duration, stop <-chan struct{}) <-chan StateChange {
+	changes := make(chan StateChange)
+
+	go func() {
+		defer close(changes)
+
+		var (
+			previous  state.State
+			lastError string
+			interval  = min
+			first     = true
+		)
+
+		for {
+			currentState, err := h.Driver.GetState()
+			errorMessage := ""
+			if err != nil {
+				log.Debugf("Error getting the state of %s: %s", h.Name, err)
+				currentState = state.Error
+				errorMessage = err.Error()
+			}
+
+			if first || currentState != previous || errorMessage != lastError {
+				change := StateChange{
+					Machine:  h.Name,
+					Previous: previous,
+					State:    currentState,
+					Error:    errorMessage,
+					Time:     time.Now(),
+				}
+
+				select {
+				case changes <- change:
+				case <-stop:
+					return
+				}
+
+				first = false
+				previous, lastError = currentState, errorMessage
+				interval = min
+			} else if !isTransitional(currentState) {
+				interval *= 2
+				if interval > max {
+					interval = max
+				}
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+
+	return changes
+}
+
+// isTransitional tells whether a machine is about to change state on its
+// own.
+func isTransitional(s state.State) bool {
+	return s == state.Starting || s == state.Stopping
+}
@@ -0,0 +1,30 @@
+package winrm
+
+import "github.com/docker/machine/libmachine/drivers"
+
+// Commander runs PowerShell scripts on the machine of a driver.  It stands
+// for the SSH commander of the provisioners of Windows hosts.
+type Commander struct {
+	Driver  drivers.Driver
+	Options *Options
+
+	client *Client
+}
+
+func (c *Commander) SSHCommand(args string) (string, error) {
+	if c.client == nil {
+		ip, err := c.Driver.GetIP()
+		if err != nil {
+			return "", err
+		}
+		c.client = NewClient(ip, c.Options)
+	}
+
+	output, err := c.client.RunPowerShell(args)
+	if err != nil {
+		// The address of the machine may have changed, e.g. on a restart
+		c.client = nil
+	}
+
+	return output, err
+}
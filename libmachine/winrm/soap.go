@@ -0,0 +1,230 @@
+package winrm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// The commands go through the WS-Management protocol: a shell is created,
+// the command is run in it, its output is received until it's done, then
+// the shell is deleted.
+
+const (
+	namespaceShell = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell"
+
+	actionCreate  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	actionDelete  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	actionCommand = namespaceShell + "/Command"
+	actionReceive = namespaceShell + "/Receive"
+	actionSignal  = namespaceShell + "/Signal"
+
+	resourceURI      = namespaceShell + "/cmd"
+	commandStateDone = namespaceShell + "/CommandState/Done"
+	signalTerminate  = namespaceShell + "/signal/terminate"
+
+	// The service answers a receive with this fault when the command didn't
+	// output anything for the duration of the operation timeout.
+	faultOperationTimeout = "2150858793"
+
+	envelopeTemplate = `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">
+<env:Header>
+<a:To>%s</a:To>
+<a:ReplyTo><a:Address env:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:Address></a:ReplyTo>
+<w:MaxEnvelopeSize env:mustUnderstand="true">153600</w:MaxEnvelopeSize>
+<a:MessageID>uuid:%s</a:MessageID>
+<w:Locale env:mustUnderstand="false" xml:lang="en-US"/>
+<w:OperationTimeout>PT60S</w:OperationTimeout>
+<w:ResourceURI env:mustUnderstand="true">%s</w:ResourceURI>
+<a:Action env:mustUnderstand="true">%s</a:Action>
+%s</env:Header>
+<env:Body>%s</env:Body>
+</env:Envelope>`
+)
+
+type envelope struct {
+	Body struct {
+		Fault *struct {
+			Reason string `xml:"Reason>Text"`
+			Detail struct {
+				WSManFault struct {
+					Code    string `xml:"Code,attr"`
+					Message string `xml:"Message"`
+				}
+			}
+		}
+		Shell struct {
+			ShellID string `xml:"ShellId"`
+		}
+		CommandResponse struct {
+			CommandID string `xml:"CommandId"`
+		}
+		ReceiveResponse struct {
+			Streams []struct {
+				Name    string `xml:"Name,attr"`
+				Content string `xml:",chardata"`
+			} `xml:"Stream"`
+			CommandState struct {
+				State    string `xml:"State,attr"`
+				ExitCode int    `xml:"ExitCode"`
+			}
+		}
+	}
+}
+
+// Fault is an error returned by the WinRM service.
+type Fault struct {
+	Code    string
+	Message string
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("WinRM fault %s: %s", f.Code, f.Message)
+}
+
+func (c *Client) createShell() (string, error) {
+	options := `<w:OptionSet><w:Option Name="WINRS_NOPROFILE">FALSE</w:Option><w:Option Name="WINRS_CODEPAGE">65001</w:Option></w:OptionSet>`
+	body := `<rsp:Shell><rsp:InputStreams>stdin</rsp:InputStreams><rsp:OutputStreams>stdout stderr</rsp:OutputStreams></rsp:Shell>`
+
+	resp, err := c.post(actionCreate, options, body)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Body.Shell.ShellID, nil
+}
+
+func (c *Client) execute(shellID, command string) (string, error) {
+	header := selector(shellID) + `<w:OptionSet><w:Option Name="WINRS_CONSOLEMODE_STDIN">TRUE</w:Option><w:Option Name="WINRS_SKIP_CMD_SHELL">FALSE</w:Option></w:OptionSet>`
+	body := fmt.Sprintf(`<rsp:CommandLine><rsp:Command>%s</rsp:Command></rsp:CommandLine>`, escape(command))
+
+	resp, err := c.post(actionCommand, header, body)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Body.CommandResponse.CommandID, nil
+}
+
+// receive copies the output of a command until it's done, and returns its
+// exit code.
+func (c *Client) receive(shellID, commandID string, stdout, stderr io.Writer) (int, error) {
+	body := fmt.Sprintf(`<rsp:Receive><rsp:DesiredStream CommandId="%s">stdout stderr</rsp:DesiredStream></rsp:Receive>`, escape(commandID))
+
+	for {
+		resp, err := c.post(actionReceive, selector(shellID), body)
+		if fault, ok := err.(*Fault); ok && fault.Code == faultOperationTimeout {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		for _, stream := range resp.Body.ReceiveResponse.Streams {
+			content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stream.Content))
+			if err != nil {
+				return 0, err
+			}
+
+			if stream.Name == "stderr" {
+				stderr.Write(content)
+			} else {
+				stdout.Write(content)
+			}
+		}
+
+		commandState := resp.Body.ReceiveResponse.CommandState
+		if commandState.State == commandStateDone {
+			return commandState.ExitCode, nil
+		}
+	}
+}
+
+func (c *Client) signal(shellID, commandID string) error {
+	body := fmt.Sprintf(`<rsp:Signal CommandId="%s"><rsp:Code>%s</rsp:Code></rsp:Signal>`, escape(commandID), signalTerminate)
+
+	_, err := c.post(actionSignal, selector(shellID), body)
+	return err
+}
+
+func (c *Client) deleteShell(shellID string) error {
+	_, err := c.post(actionDelete, selector(shellID), "")
+	return err
+}
+
+func (c *Client) post(action, header, body string) (*envelope, error) {
+	message := fmt.Sprintf(envelopeTemplate, escape(c.URL), messageID(), resourceURI, action, header, body)
+
+	req, err := http.NewRequest("POST", c.URL, strings.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	req.SetBasicAuth(c.User, c.Password)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("WinRM authentication failed for user %q", c.User)
+	}
+
+	result := &envelope{}
+	if err := xml.Unmarshal(content, result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("WinRM request failed: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("Error parsing the WinRM response: %s", err)
+	}
+
+	if fault := result.Body.Fault; fault != nil {
+		message := strings.TrimSpace(fault.Detail.WSManFault.Message)
+		if message == "" {
+			message = strings.TrimSpace(fault.Reason)
+		}
+		return nil, &Fault{
+			Code:    fault.Detail.WSManFault.Code,
+			Message: message,
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("WinRM request failed: %s", resp.Status)
+	}
+
+	return result, nil
+}
+
+func selector(shellID string) string {
+	return fmt.Sprintf(`<w:SelectorSet><w:Selector Name="ShellId">%s</w:Selector></w:SelectorSet>`, escape(shellID))
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// messageID returns a random UUID identifying a message.
+func messageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,179 @@
+package winrm
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	DefaultHTTPSPort = 5986
+	DefaultHTTPPort  = 5985
+
+	requestTimeout = 90 * time.Second
+)
+
+// Options are the settings used to reach a machine over WinRM.
+type Options struct {
+	User     string
+	Password string
+	Port     int
+
+	// HTTP disables TLS, which the WinRM service of a fresh Windows host
+	// may only be listening without.
+	HTTP bool
+
+	// Insecure skips the verification of the certificate of the WinRM
+	// service, self-signed on most images.
+	Insecure bool
+}
+
+// GetPort returns the port of the WinRM service.
+func (o *Options) GetPort() int {
+	if o.Port != 0 {
+		return o.Port
+	}
+
+	if o.HTTP {
+		return DefaultHTTPPort
+	}
+
+	return DefaultHTTPSPort
+}
+
+// Client runs commands on a Windows machine through its WinRM service.
+type Client struct {
+	URL      string
+	User     string
+	Password string
+
+	http *http.Client
+}
+
+// ExitError is returned when a command exits with a non-zero code.
+type ExitError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s exited with code %d", e.Command, e.ExitCode)
+	}
+
+	return fmt.Sprintf("%s exited with code %d: %s", e.Command, e.ExitCode, e.Stderr)
+}
+
+func NewClient(host string, options *Options) *Client {
+	scheme := "https"
+	if options.HTTP {
+		scheme = "http"
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: options.Insecure},
+	}
+
+	return &Client{
+		URL:      fmt.Sprintf("%s://%s/wsman", scheme, net.JoinHostPort(host, strconv.Itoa(options.GetPort()))),
+		User:     options.User,
+		Password: options.Password,
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   requestTimeout,
+		},
+	}
+}
+
+// Run runs a command with cmd.exe and returns its standard output.
+func (c *Client) Run(command string) (string, error) {
+	shellID, err := c.createShell()
+	if err != nil {
+		return "", err
+	}
+	defer c.deleteShell(shellID)
+
+	commandID, err := c.execute(shellID, command)
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode, err := c.receive(shellID, commandID, &stdout, &stderr)
+	c.signal(shellID, commandID)
+	if err != nil {
+		return "", err
+	}
+
+	if exitCode != 0 {
+		return stdout.String(), &ExitError{
+			Command:  commandName(command),
+			ExitCode: exitCode,
+			Stderr:   strings.TrimSpace(cleanStderr(stderr.String())),
+		}
+	}
+
+	return stdout.String(), nil
+}
+
+// RunPowerShell runs a PowerShell script and returns its standard output.
+// The script stops at the first error, which makes it exit with code 1.
+func (c *Client) RunPowerShell(script string) (string, error) {
+	return c.Run(PowerShellCommand(script))
+}
+
+// PowerShellCommand returns the command line running a PowerShell script.
+// The script is passed encoded, which spares quoting it for cmd.exe.
+func PowerShellCommand(script string) string {
+	script = "$ProgressPreference = 'SilentlyContinue'\r\n$ErrorActionPreference = 'Stop'\r\n" + script
+
+	encoded := utf16.Encode([]rune(script))
+	buf := make([]byte, 2*len(encoded))
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(buf[2*i:], r)
+	}
+
+	return "powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -EncodedCommand " + base64.StdEncoding.EncodeToString(buf)
+}
+
+// commandName returns the name of a command for the error messages, as the
+// encoded scripts are unreadable.
+func commandName(command string) string {
+	if strings.HasPrefix(command, "powershell ") {
+		return "powershell"
+	}
+
+	return strings.SplitN(command, " ", 2)[0]
+}
+
+// cleanStderr extracts the error messages from the CLIXML PowerShell writes
+// on its standard error when it isn't attached to a console.
+func cleanStderr(stderr string) string {
+	if !strings.HasPrefix(stderr, "#< CLIXML") {
+		return stderr
+	}
+
+	var lines []string
+	for _, s := range strings.Split(stderr, `<S S="Error">`)[1:] {
+		line := strings.SplitN(s, "</S>", 2)[0]
+		line = strings.Replace(line, "_x000D_", "", -1)
+		line = strings.Replace(line, "_x000A_", "\n", -1)
+		lines = append(lines, line)
+	}
+
+	return unescapeXML(strings.Join(lines, ""))
+}
+
+func unescapeXML(s string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&amp;", "&").Replace(s)
+}
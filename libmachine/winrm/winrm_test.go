@@ -0,0 +1,186 @@
+package winrm
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeService answers the WS-Management requests of the client, running
+// the commands with run.
+type fakeService struct {
+	run      func(command string) (stdout, stderr string, exitCode int)
+	timeouts int
+
+	commands []string
+	actions  []string
+}
+
+func (s *fakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, password, _ := r.BasicAuth()
+	if user != "Administrator" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	content, _ := ioutil.ReadAll(r.Body)
+	request := string(content)
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+
+	switch {
+	case strings.Contains(request, actionCreate):
+		s.actions = append(s.actions, "create")
+		fmt.Fprint(w, response(`<rsp:Shell><rsp:ShellId>SHELL-1</rsp:ShellId></rsp:Shell>`))
+	case strings.Contains(request, actionCommand):
+		s.actions = append(s.actions, "command")
+		command := between(request, "<rsp:Command>", "</rsp:Command>")
+		s.commands = append(s.commands, command)
+		fmt.Fprint(w, response(`<rsp:CommandResponse><rsp:CommandId>COMMAND-1</rsp:CommandId></rsp:CommandResponse>`))
+	case strings.Contains(request, actionReceive):
+		s.actions = append(s.actions, "receive")
+		if s.timeouts > 0 {
+			s.timeouts--
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, response(`<s:Fault><s:Reason><s:Text>timed out</s:Text></s:Reason><s:Detail><f:WSManFault xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault" Code="2150858793"><f:Message>The WS-Management service cannot complete the operation within the time specified in OperationTimeout.</f:Message></f:WSManFault></s:Detail></s:Fault>`))
+			return
+		}
+		stdout, stderr, code := s.run(s.commands[len(s.commands)-1])
+		fmt.Fprint(w, response(fmt.Sprintf(`<rsp:ReceiveResponse><rsp:Stream Name="stdout" CommandId="COMMAND-1">%s</rsp:Stream><rsp:Stream Name="stderr" CommandId="COMMAND-1">%s</rsp:Stream><rsp:CommandState CommandId="COMMAND-1" State="%s"><rsp:ExitCode>%d</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`,
+			base64.StdEncoding.EncodeToString([]byte(stdout)),
+			base64.StdEncoding.EncodeToString([]byte(stderr)),
+			commandStateDone,
+			code)))
+	case strings.Contains(request, actionSignal):
+		s.actions = append(s.actions, "signal")
+		fmt.Fprint(w, response(`<rsp:SignalResponse/>`))
+	case strings.Contains(request, actionDelete):
+		s.actions = append(s.actions, "delete")
+		fmt.Fprint(w, response(""))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func response(body string) string {
+	return `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell"><s:Header/><s:Body>` + body + `</s:Body></s:Envelope>`
+}
+
+func between(s, start, end string) string {
+	s = s[strings.Index(s, start)+len(start):]
+	return s[:strings.Index(s, end)]
+}
+
+func newTestClient(t *testing.T, service *fakeService, password string) (*Client, func()) {
+	server := httptest.NewServer(service)
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	assert.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	assert.NoError(t, err)
+
+	client := NewClient(host, &Options{
+		User:     "Administrator",
+		Password: password,
+		Port:     portNumber,
+		HTTP:     true,
+	})
+
+	return client, server.Close
+}
+
+func decodePowerShell(t *testing.T, command string) string {
+	encoded := command[strings.LastIndex(command, " ")+1:]
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	assert.NoError(t, err)
+
+	runes := make([]uint16, len(raw)/2)
+	for i := range runes {
+		runes[i] = binary.LittleEndian.Uint16(raw[2*i:])
+	}
+
+	return string(utf16.Decode(runes))
+}
+
+func TestOptionsPort(t *testing.T) {
+	assert.Equal(t, 5986, (&Options{}).GetPort())
+	assert.Equal(t, 5985, (&Options{HTTP: true}).GetPort())
+	assert.Equal(t, 15986, (&Options{Port: 15986}).GetPort())
+}
+
+func TestNewClientURL(t *testing.T) {
+	assert.Equal(t, "https://10.0.0.4:5986/wsman", NewClient("10.0.0.4", &Options{}).URL)
+	assert.Equal(t, "http://10.0.0.4:5985/wsman", NewClient("10.0.0.4", &Options{HTTP: true}).URL)
+}
+
+func TestRun(t *testing.T) {
+	service := &fakeService{
+		run: func(command string) (string, string, int) {
+			return "WIN-HOST\r\n", "", 0
+		},
+	}
+	client, closeServer := newTestClient(t, service, "secret")
+	defer closeServer()
+
+	output, err := client.Run("hostname")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "WIN-HOST\r\n", output)
+	assert.Equal(t, []string{"hostname"}, service.commands)
+	assert.Equal(t, []string{"create", "command", "receive", "signal", "delete"}, service.actions)
+}
+
+func TestRunRetriesReceiveOnTimeout(t *testing.T) {
+	service := &fakeService{
+		run: func(command string) (string, string, int) {
+			return "done", "", 0
+		},
+		timeouts: 2,
+	}
+	client, closeServer := newTestClient(t, service, "secret")
+	defer closeServer()
+
+	output, err := client.Run("ping -n 120 localhost")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "done", output)
+	assert.Equal(t, []string{"create", "command", "receive", "receive", "receive", "signal", "delete"}, service.actions)
+}
+
+func TestRunPowerShellExitError(t *testing.T) {
+	var script string
+	service := &fakeService{
+		run: func(command string) (string, string, int) {
+			script = decodePowerShell(t, command)
+			return "", `#< CLIXML
+<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><S S="Error">Get-Service : Cannot find any service with service name 'docker'._x000D__x000A_</S></Objs>`, 1
+		},
+	}
+	client, closeServer := newTestClient(t, service, "secret")
+	defer closeServer()
+
+	_, err := client.RunPowerShell("Get-Service docker")
+
+	assert.EqualError(t, err, "powershell exited with code 1: Get-Service : Cannot find any service with service name 'docker'.")
+	assert.True(t, strings.HasSuffix(script, "$ErrorActionPreference = 'Stop'\r\nGet-Service docker"))
+	assert.True(t, strings.HasPrefix(service.commands[0], "powershell -NoProfile -NonInteractive"))
+}
+
+func TestRunAuthenticationFailure(t *testing.T) {
+	client, closeServer := newTestClient(t, &fakeService{}, "wrong")
+	defer closeServer()
+
+	_, err := client.Run("hostname")
+
+	assert.EqualError(t, err, `WinRM authentication failed for user "Administrator"`)
+}